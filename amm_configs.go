@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+/*
+NOTE(@hadydotai): AmmConfig fee tiers.
+
+Every CP-Swap pool points at an AmmConfig, and that's where its fees live: the trade fee taken from every swap, and the
+protocol, fund and creator shares of it. There are only a handful of them, one per fee tier, created by Raydium's admin
+at the PDA ["amm_config", index as a big endian u16]. amm-configs lists them all, so someone creating a pool can pick an
+index and a trader can check a pool's fee tier is one of them.
+
+An AmmConfig the program owns is one of the canonical ones whatever its address, only the admin can create them, but
+the address is checked against its index anyway, it's free and it catches us misreading the account. -pool marks the
+config the pool uses, and says so when it's none of them.
+
+The protocol and fund fee rates are shares of the trade fee, not of the swap, both in millionths like the trade fee.
+The creator fee is on the swap, on top of the trade fee, for pools created with one.
+*/
+
+// ammConfigEntry is an AmmConfig and where it lives.
+type ammConfigEntry struct {
+	address   solana.PublicKey
+	config    *raydium_cp_swap.AmmConfig
+	canonical bool // address is the PDA of its index
+}
+
+// ammConfigPDA is where the program keeps the AmmConfig with index.
+func ammConfigPDA(index uint16) (solana.PublicKey, error) {
+	pda, _, err := solana.FindProgramAddress([][]byte{[]byte("amm_config"), binary.BigEndian.AppendUint16(nil, index)}, raydium_cp_swap.ProgramID)
+	if err != nil {
+		return solana.PublicKey{}, fmt.Errorf("deriving the amm config of index %d failed: %w", index, err)
+	}
+	return pda, nil
+}
+
+// fetchAmmConfigs scans the program for every AmmConfig, sorted by index.
+func fetchAmmConfigs(ctx context.Context, client *rpc.Client) ([]ammConfigEntry, error) {
+	accounts, err := client.GetProgramAccountsWithOpts(ctx, raydium_cp_swap.ProgramID, &rpc.GetProgramAccountsOpts{
+		Encoding: solana.EncodingBase64,
+		Filters: []rpc.RPCFilter{
+			{Memcmp: &rpc.RPCFilterMemcmp{Offset: 0, Bytes: raydium_cp_swap.Account_AmmConfig[:]}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("rpc call getProgramAccounts failed: %w", err)
+	}
+	var entries []ammConfigEntry
+	for _, acc := range accounts {
+		config, err := raydium_cp_swap.ParseAccount_AmmConfig(acc.Account.Data.GetBinary())
+		if err != nil {
+			log.Printf("warning: skipping amm config %s: %v", acc.Pubkey, err)
+			continue
+		}
+		pda, err := ammConfigPDA(config.Index)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, ammConfigEntry{address: acc.Pubkey, config: config, canonical: pda.Equals(acc.Pubkey)})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].config.Index < entries[j].config.Index })
+	return entries, nil
+}
+
+// renderAmmConfigs lays the configs out one per row, marking the one at pool when it's set.
+func renderAmmConfigs(entries []ammConfigEntry, pool solana.PublicKey) string {
+	builder := &strings.Builder{}
+	t := table.NewWriter()
+	t.SetOutputMirror(builder)
+	t.SetTitle("CP-Swap AmmConfigs")
+	t.AppendHeader(table.Row{"", "Index", "Address", "Trade fee", "Protocol share", "Fund share", "Creator fee", "Create pool fee", "New pools"})
+	for _, e := range entries {
+		var marks []string
+		if e.address.Equals(pool) {
+			marks = append(marks, "pool")
+		}
+		if !e.canonical {
+			marks = append(marks, "not at its PDA")
+		}
+		newPools := "allowed"
+		if e.config.DisableCreatePool {
+			newPools = "disabled"
+		}
+		t.AppendRow(table.Row{
+			strings.Join(marks, ", "), e.config.Index, e.address,
+			formatFeeRate(e.config.TradeFeeRate), formatFeeRate(e.config.ProtocolFeeRate), formatFeeRate(e.config.FundFeeRate),
+			formatFeeRate(e.config.CreatorFeeRate), fmtSOL(e.config.CreatePoolFee), newPools,
+		})
+	}
+	t.AppendFooter(table.Row{"", fmt.Sprintf("%d configs", len(entries))})
+	t.Render()
+	return builder.String()
+}
+
+type ammConfigJSON struct {
+	Index             uint16 `json:"index"`
+	Address           string `json:"address"`
+	Canonical         bool   `json:"canonical"`
+	TradeFeeRate      uint64 `json:"tradeFeeRate"`
+	ProtocolFeeRate   uint64 `json:"protocolFeeRate"`
+	FundFeeRate       uint64 `json:"fundFeeRate"`
+	CreatorFeeRate    uint64 `json:"creatorFeeRate"`
+	CreatePoolFee     uint64 `json:"createPoolFeeLamports"`
+	DisableCreatePool bool   `json:"disableCreatePool"`
+	ProtocolOwner     string `json:"protocolOwner"`
+	FundOwner         string `json:"fundOwner"`
+}
+
+func ammConfigsJSON(entries []ammConfigEntry) []ammConfigJSON {
+	out := make([]ammConfigJSON, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, ammConfigJSON{
+			Index: e.config.Index, Address: e.address.String(), Canonical: e.canonical,
+			TradeFeeRate: e.config.TradeFeeRate, ProtocolFeeRate: e.config.ProtocolFeeRate, FundFeeRate: e.config.FundFeeRate,
+			CreatorFeeRate: e.config.CreatorFeeRate, CreatePoolFee: e.config.CreatePoolFee, DisableCreatePool: e.config.DisableCreatePool,
+			ProtocolOwner: e.config.ProtocolOwner.String(), FundOwner: e.config.FundOwner.String(),
+		})
+	}
+	return out
+}
+
+// poolConfigVerdict says which of entries the pool's config is, or that it's none of them.
+func poolConfigVerdict(entries []ammConfigEntry, pool, config solana.PublicKey) string {
+	for _, e := range entries {
+		if e.address.Equals(config) {
+			return fmt.Sprintf("Pool %s uses config %d, a %s trade fee.", pool, e.config.Index, formatFeeRate(e.config.TradeFeeRate))
+		}
+	}
+	return fmt.Sprintf("Pool %s uses config %s, which isn't one of the program's AmmConfigs.", pool, config)
+}
+
+func runAmmConfigsCommand(args []string) error {
+	fs := flag.NewFlagSet("amm-configs", flag.ExitOnError)
+	nf := addNetworkFlags(fs)
+	var (
+		poolTarget = fs.String("pool", "", "Mark the config this pool (address or pair) uses")
+		asJSON     = fs.Bool("json", false, "Print the configs as JSON instead of a table")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, nf.specs())
+	client := nf.connect()
+	ctx, stop := interruptContext()
+	defer stop()
+
+	quoteCtx, cancel := deadlines.forQuote(ctx)
+	defer cancel()
+	entries, err := fetchAmmConfigs(quoteCtx, client)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(ammConfigsJSON(entries))
+	}
+	var pool, poolConfig solana.PublicKey
+	if *poolTarget != "" {
+		if pool, err = resolvePoolTarget(quoteCtx, client, *poolTarget, SymbolMapping{}); err != nil {
+			return err
+		}
+		state, err := fetchPoolState(quoteCtx, client, pool)
+		if err != nil {
+			return err
+		}
+		poolConfig = state.AmmConfig
+	}
+	fmt.Print(renderAmmConfigs(entries, poolConfig))
+	if !pool.IsZero() {
+		fmt.Println(poolConfigVerdict(entries, pool, poolConfig))
+	}
+	return nil
+}
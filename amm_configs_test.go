@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+func TestAmmConfigPDA(t *testing.T) {
+	defer func(saved solana.PublicKey) { raydium_cp_swap.ProgramID = saved }(raydium_cp_swap.ProgramID)
+	raydium_cp_swap.ProgramID = networks["mainnet"][RaydiumProgramID].(solana.PublicKey)
+	// Config 0 on mainnet, the 0.25% tier most pools use.
+	pda, err := ammConfigPDA(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := solana.MustPublicKeyFromBase58("D4FPEruKEHrG5TenZ2mpDGEfu1iUvTiqBxvpU8HLBvC2"); !pda.Equals(want) {
+		t.Errorf("config 0 at %s, want %s", pda, want)
+	}
+	if other, _ := ammConfigPDA(1); other.Equals(pda) {
+		t.Error("configs 0 and 1 at the same address")
+	}
+}
+
+func TestRenderAmmConfigs(t *testing.T) {
+	standard, stray, pool := snapshotKey(1), snapshotKey(2), snapshotKey(3)
+	entries := []ammConfigEntry{
+		{address: standard, canonical: true, config: &raydium_cp_swap.AmmConfig{Index: 0, TradeFeeRate: 2500, ProtocolFeeRate: 120000, FundFeeRate: 40000, CreatePoolFee: 150_000_000}},
+		{address: stray, config: &raydium_cp_swap.AmmConfig{Index: 1, TradeFeeRate: 10000, DisableCreatePool: true}},
+	}
+	out := renderAmmConfigs(entries, standard)
+	for _, want := range []string{"0.25%", "12%", "4%", "0.150000000 SOL", "pool", "not at its PDA", "disabled", "2 CONFIGS"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("%q missing from\n%s", want, out)
+		}
+	}
+	if v := poolConfigVerdict(entries, pool, standard); !strings.Contains(v, "config 0, a 0.25% trade fee") {
+		t.Errorf("verdict %q", v)
+	}
+	if v := poolConfigVerdict(entries, pool, pool); !strings.Contains(v, "isn't one of") {
+		t.Errorf("verdict on an unknown config %q", v)
+	}
+}
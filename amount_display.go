@@ -0,0 +1,157 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+/*
+NOTE(@hadydotai): How amounts read.
+
+Amounts used to be printed with every decimal their token has, 0.000012345 SOL reads fine but 1523456.123456789 BONK
+and 0.000000001 SOL less so. Three settings change how every amount printed for humans reads, in tables, logs and the
+"display" half of JSON amounts (the "raw" half never changes, that's what it's for):
+
+  - -sig-figs N keeps N significant digits and drops the trailing zeros. It only ever cuts decimals, never the whole
+    part, 1523456.123 at 4 is 1523456, and 0.000012345 at 3 is 0.0000123. 0, the default, keeps every decimal.
+  - -number-locale groups the thousands and picks the decimal mark: plain (1234567.5, the default), en (1,234,567.5),
+    de (1.234.567,5), fr (1 234 567,5) or ch (1'234'567.5).
+  - -dust-sci prints amounts below it (and above zero) in scientific notation, with -dust-sci 0.0001 1 lamport is
+    1e-9 SOL. With -sig-figs the mantissa keeps that many digits, without it every digit the amount has.
+
+Amounts that get read back don't go through any of this. An intent made up from an amount (a percentage resolved to
+tokens, a split route's legs, compound's swaps) and the accounting CSVs of history keep plain full decimals, the
+settings are only for reading. -raw-amounts still wins over all of them.
+*/
+
+// numberLocale is how the digits of a decimal are grouped and split.
+type numberLocale struct {
+	name    string
+	group   string // between groups of three digits of the whole part, "" for no grouping
+	decimal string
+}
+
+var numberLocales = []numberLocale{
+	{name: "plain", decimal: "."},
+	{name: "en", group: ",", decimal: "."},
+	{name: "de", group: ".", decimal: ","},
+	{name: "fr", group: " ", decimal: ","},
+	{name: "ch", group: "'", decimal: "."},
+}
+
+func localeNames() []string {
+	names := make([]string, len(numberLocales))
+	for i, l := range numberLocales {
+		names[i] = l.name
+	}
+	return names
+}
+
+// localize rewrites s, a decimal as FloatString makes them, for the locale.
+func (l numberLocale) localize(s string) string {
+	sign, digits := "", s
+	if rest, ok := strings.CutPrefix(s, "-"); ok {
+		sign, digits = "-", rest
+	}
+	whole, frac, hasFrac := strings.Cut(digits, ".")
+	if l.group != "" && len(whole) > 3 {
+		var b strings.Builder
+		for i, d := range whole {
+			if i > 0 && (len(whole)-i)%3 == 0 {
+				b.WriteString(l.group)
+			}
+			b.WriteRune(d)
+		}
+		whole = b.String()
+	}
+	if !hasFrac {
+		return sign + whole
+	}
+	return sign + whole + l.decimal + frac
+}
+
+// amountDisplay is how amounts are printed for humans, set by -sig-figs, -number-locale and -dust-sci.
+type amountDisplay struct {
+	sigFigs int // 0 keeps every decimal
+	locale  numberLocale
+	dust    *big.Rat // nonzero amounts below it are printed in scientific notation, nil for never
+}
+
+var display = amountDisplay{locale: numberLocales[0]}
+
+// addAmountFlags registers -raw-amounts and the settings for how amounts read.
+func addAmountFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&rawAmounts, "raw-amounts", false, "Print token amounts as raw integer base units instead of decimals")
+	fs.Func("sig-figs", "Print token amounts to this many significant digits, 0 (the default) prints every decimal", func(s string) error {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return fmt.Errorf("sig-figs has to be a whole number of digits, 0 for all of them, got %q", s)
+		}
+		display.sigFigs = n
+		return nil
+	})
+	fs.Func("number-locale", "How amounts group thousands and mark decimals, one of "+strings.Join(localeNames(), ", ")+" (default plain)", func(s string) error {
+		i := slices.IndexFunc(numberLocales, func(l numberLocale) bool { return strings.EqualFold(l.name, s) })
+		if i < 0 {
+			return fmt.Errorf("number-locale has to be one of %s, got %q", strings.Join(localeNames(), ", "), s)
+		}
+		display.locale = numberLocales[i]
+		return nil
+	})
+	fs.Func("dust-sci", "Print amounts below this (e.g. 0.0001) in scientific notation, off by default", func(s string) error {
+		dust, ok := new(big.Rat).SetString(s)
+		if !ok || dust.Sign() <= 0 {
+			return fmt.Errorf("dust-sci has to be a positive amount, got %q", s)
+		}
+		display.dust = dust
+		return nil
+	})
+}
+
+// format renders raw, an amount in base units of a token with decimals decimals, with at most places decimals.
+func (d amountDisplay) format(raw *big.Int, decimals uint8, places int) string {
+	if raw == nil {
+		return "0"
+	}
+	value := new(big.Rat).SetFrac(raw, fixedPointScale(decimals))
+	if raw.Sign() != 0 && d.dust != nil && new(big.Rat).Abs(value).Cmp(d.dust) < 0 {
+		return d.scientific(raw, decimals)
+	}
+	if d.sigFigs == 0 {
+		return d.locale.localize(value.FloatString(places))
+	}
+	if raw.Sign() != 0 {
+		places = min(places, max(0, d.sigFigs-1-leadingExponent(raw, decimals)))
+	}
+	return d.locale.localize(trimDecimal(value.FloatString(places)))
+}
+
+// leadingExponent is the power of ten of raw's leading digit once it's scaled down by decimals, 3 for 1234.5 and -5 for
+// 0.0000123.
+func leadingExponent(raw *big.Int, decimals uint8) int {
+	return len(new(big.Int).Abs(raw).String()) - 1 - int(decimals)
+}
+
+// scientific renders raw as a mantissa and a power of ten, 1.23e-7.
+func (d amountDisplay) scientific(raw *big.Int, decimals uint8) string {
+	digits := new(big.Int).Abs(raw).String()
+	exp := leadingExponent(raw, decimals)
+	places := len(strings.TrimRight(digits, "0")) - 1
+	if d.sigFigs > 0 {
+		places = min(places, d.sigFigs-1)
+	}
+	mantissa := new(big.Rat).SetFrac(new(big.Int).Abs(raw), fixedPointScale(uint8(len(digits)-1)))
+	m := trimDecimal(mantissa.FloatString(places))
+	if m == "10" {
+		m, exp = "1", exp+1
+	}
+	sign := ""
+	if raw.Sign() < 0 {
+		sign = "-"
+	}
+	return sign + d.locale.localize(m) + "e" + strconv.Itoa(exp)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"math/big"
+	"testing"
+)
+
+func TestAmountDisplay(t *testing.T) {
+	defer func(d amountDisplay) { display = d }(display)
+	cases := []struct {
+		args     []string
+		raw      int64
+		decimals uint8
+		want     string
+	}{
+		{nil, 1_523_456_123_456_789, 9, "1523456.123456789"},
+		{[]string{"-sig-figs", "4"}, 1_523_456_123_456_789, 9, "1523456"},
+		{[]string{"-sig-figs", "4"}, 1_234_500, 6, "1.235"},
+		{[]string{"-sig-figs", "3"}, 12_345, 9, "0.0000123"},
+		{[]string{"-sig-figs", "3"}, 1_500_000_000, 9, "1.5"},
+		{[]string{"-sig-figs", "3"}, 0, 9, "0"},
+		{[]string{"-number-locale", "en"}, 1_234_567_500_000, 6, "1,234,567.500000"},
+		{[]string{"-number-locale", "de", "-sig-figs", "8"}, 1_234_567_500_000, 6, "1.234.567,5"},
+		{[]string{"-number-locale", "fr", "-sig-figs", "8"}, -1_234_567_500_000, 6, "-1 234 567,5"},
+		{[]string{"-number-locale", "ch"}, 999, 0, "999"},
+		{[]string{"-dust-sci", "0.0001"}, 1, 9, "1e-9"},
+		{[]string{"-dust-sci", "0.0001"}, 12_300, 9, "1.23e-5"},
+		{[]string{"-dust-sci", "0.0001", "-sig-figs", "2"}, 12_345, 9, "1.2e-5"},
+		{[]string{"-dust-sci", "0.0001", "-sig-figs", "1"}, 99_999, 9, "1e-4"},
+		{[]string{"-dust-sci", "0.0001", "-number-locale", "de"}, 12_300, 9, "1,23e-5"},
+		{[]string{"-dust-sci", "0.0001"}, 100_000, 9, "0.000100000"},
+	}
+	for _, c := range cases {
+		display = amountDisplay{locale: numberLocales[0]}
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		addAmountFlags(fs)
+		if err := fs.Parse(c.args); err != nil {
+			t.Fatalf("%v: %v", c.args, err)
+		}
+		if got := fmtAmount(big.NewInt(c.raw), c.decimals); got != c.want {
+			t.Errorf("%v: %d with %d decimals is %q, want %q", c.args, c.raw, c.decimals, got, c.want)
+		}
+	}
+
+	display = amountDisplay{locale: numberLocales[2], sigFigs: 8}
+	if got := newAmountJSON(big.NewInt(1_234_567_500_000), 6); got.Raw != "1234567500000" || got.Display != "1.234.567,5" {
+		t.Errorf("JSON amount is %q/%q, want the raw integer and the display formatted", got.Raw, got.Display)
+	}
+
+	for _, args := range [][]string{{"-sig-figs", "-1"}, {"-number-locale", "xx"}, {"-dust-sci", "0"}} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		addAmountFlags(fs)
+		if err := fs.Parse(args); err == nil {
+			t.Errorf("%v parsed", args)
+		}
+	}
+}
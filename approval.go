@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+/*
+NOTE(@hadydotai): Two-person approval.
+
+Review bundles (tx_bundle.go) split planning a swap from sending it, but whoever holds the hot wallet can still do
+both. With -approval-above, a swap worth more than that many dollars isn't sent: -no-tui writes it out as an approval
+request instead, a review bundle signed by the hot wallet that proposed it. A second keyholder runs `approve` on the
+request, reads what it does and countersigns its hash with their own key. Executing it with -execute-bundle checks both
+signatures and refuses it without a countersignature from someone other than the proposer, and with -approvers, from
+one of the keys in that file.
+
+The signatures are ed25519 over "raydium-client approval:<hash>" with the same keypair files that sign transactions,
+so a keyholder needs nothing new. This is a check the client keeps, not the chain. The swap only needs the hot wallet's
+signature to land, and someone with that key and another client can send what they like. Where that matters the funds
+belong behind an on-chain multisig such as Squads, which -squads-vault proposes swaps to (squads.go).
+
+A review bundle's hash is an approval its planner can give themselves, so -export-bundle (and `batch run
+-export-bundle`) writes a bundle with an entry over the threshold as an approval request instead, and executing a
+bundle refuses such an entry, before anything is sent, unless the bundle is a request a second keyholder countersigned.
+
+Everywhere else a swap is sent (the TUI, the engines, -split, Jupiter) has nowhere to put a request, so a swap over the
+threshold is refused there by a trade hook. A swap that can't be valued counts as over it.
+*/
+
+var (
+	// approvalAboveUSD is -approval-above, nil when no swap needs approving.
+	approvalAboveUSD *big.Rat
+	// approverKeys is -approvers, nil when anyone but the proposer can approve.
+	approverKeys  map[solana.PublicKey]bool
+	approvalPrice = currentUSDPrice
+)
+
+func addApprovalFlags(fs *flag.FlagSet) {
+	fs.Func("approval-above", "Swaps worth more than this many dollars need a second keyholder's approval, see `approve`", func(s string) error {
+		limit, ok := new(big.Rat).SetString(strings.TrimPrefix(s, "$"))
+		if !ok || limit.Sign() < 0 {
+			return fmt.Errorf("approval-above has to be a dollar amount, got %q", s)
+		}
+		approvalAboveUSD = limit
+		turnOnTradeHook("approval", approvalHook{})
+		return nil
+	})
+	fs.Func("approvers", "File of the public keys allowed to approve swaps, one per line (default anyone but the proposer)", func(path string) error {
+		keys, err := readKeyList(path)
+		if err != nil {
+			return err
+		}
+		approverKeys = keys
+		return nil
+	})
+}
+
+// needsApproval reports whether s is over -approval-above and why.
+func needsApproval(ctx context.Context, s hookSwap) (bool, string) {
+	if approvalAboveUSD == nil {
+		return false, ""
+	}
+	usd, err := usdNotional(ctx, approvalPrice, s, nil, nil)
+	if err != nil {
+		return true, fmt.Sprintf("the swap can't be valued against -approval-above (%v)", err)
+	}
+	if usd.Cmp(approvalAboveUSD) <= 0 {
+		return false, ""
+	}
+	return true, fmt.Sprintf("the swap is worth up to %s, over the %s -approval-above", fmtUSD(usd), fmtUSD(approvalAboveUSD))
+}
+
+// bundleNeedsApproval reports whether an entry of bundle is over -approval-above and why, valued at what it pays at
+// most as reviewed. A countersigned approval request has had its approval.
+func bundleNeedsApproval(ctx context.Context, bundle *txBundle) (bool, string, error) {
+	if approvalAboveUSD == nil || bundle.approved {
+		return false, "", nil
+	}
+	payer, err := solana.PublicKeyFromBase58(bundle.Payer)
+	if err != nil {
+		return false, "", fmt.Errorf("bundle has an invalid payer %q: %w", bundle.Payer, err)
+	}
+	for i, e := range bundle.Entries {
+		s, err := e.hookSwap(payer, false)
+		if err != nil {
+			return false, "", fmt.Errorf("bundle entry %d (%s): %w", i, e.Intent, err)
+		}
+		if over, why := needsApproval(ctx, s); over {
+			return true, fmt.Sprintf("bundle entry %d (%s): %s", i, e.Intent, why), nil
+		}
+	}
+	return false, "", nil
+}
+
+// exportTxBundle writes bundle to path for review, or as an approval request signed by payer when an entry of it is
+// over -approval-above. Nothing is sent.
+func exportTxBundle(ctx context.Context, path string, bundle txBundle, payer solana.PrivateKey, out io.Writer) error {
+	over, why, err := bundleNeedsApproval(ctx, &bundle)
+	if err != nil {
+		return err
+	}
+	if !over {
+		hash, err := writeTxBundle(path, bundle)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Bundle written to %s\nSHA-256: %s\nNothing was sent. Execute after review with -execute-bundle %s -bundle-hash %s\n", path, hash, path, hash)
+		return nil
+	}
+	hash, err := writeApprovalRequest(path, bundle, payer)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s, so it's written as an approval request.\nApproval request written to %s\nSHA-256: %s\nNothing was sent. ", why, path, hash)
+	fmt.Fprintf(out, "A second keyholder approves it with `approve %s -keypair <their keypair>`, then execute it with -execute-bundle %s -bundle-hash %s\n", path, path, hash)
+	return nil
+}
+
+// approvalHook refuses swaps over -approval-above on the paths that can't write an approval request.
+type approvalHook struct{ noTradeHook }
+
+func (approvalHook) PreSend(ctx context.Context, s hookSwap) error {
+	if s.Approved {
+		return nil
+	}
+	if over, why := needsApproval(ctx, s); over {
+		return fmt.Errorf("%s, it needs a second keyholder's approval, run it with -no-tui to write an approval request", why)
+	}
+	return nil
+}
+
+// approvalSignature is one keyholder's signature over a bundle's hash.
+type approvalSignature struct {
+	Signer    string    `json:"signer"`
+	Signature string    `json:"signature"`
+	SignedAt  time.Time `json:"signedAt"`
+}
+
+func approvalMessage(hash string) []byte {
+	return []byte("raydium-client approval:" + strings.ToLower(hash))
+}
+
+func signApproval(key solana.PrivateKey, hash string) (approvalSignature, error) {
+	sig, err := key.Sign(approvalMessage(hash))
+	if err != nil {
+		return approvalSignature{}, fmt.Errorf("signing the approval failed: %w", err)
+	}
+	return approvalSignature{Signer: key.PublicKey().String(), Signature: sig.String(), SignedAt: time.Now().UTC()}, nil
+}
+
+// verify checks the signature is its signer's over hash and returns the signer.
+func (as approvalSignature) verify(hash string) (solana.PublicKey, error) {
+	signer, err := solana.PublicKeyFromBase58(as.Signer)
+	if err != nil {
+		return solana.PublicKey{}, fmt.Errorf("invalid signer %q: %w", as.Signer, err)
+	}
+	sig, err := solana.SignatureFromBase58(as.Signature)
+	if err != nil {
+		return solana.PublicKey{}, fmt.Errorf("invalid signature from %s: %w", Addr(as.Signer), err)
+	}
+	if !sig.Verify(signer, approvalMessage(hash)) {
+		return solana.PublicKey{}, fmt.Errorf("%s's signature isn't over this bundle", Addr(as.Signer))
+	}
+	return signer, nil
+}
+
+// checkApprovals refuses an approval request that no second keyholder approved. Bundles that aren't requests are left
+// alone, their hash is the approval.
+func checkApprovals(file txBundleFile, bundle *txBundle, hash string) error {
+	if file.Proposal == nil {
+		return nil
+	}
+	proposer, err := file.Proposal.verify(hash)
+	if err != nil {
+		return fmt.Errorf("the approval request's proposal: %w", err)
+	}
+	if proposer.String() != bundle.Payer {
+		return fmt.Errorf("the approval request was proposed by %s, not its payer %s", Addr(proposer.String()), Addr(bundle.Payer))
+	}
+	for _, a := range file.Approvals {
+		approver, err := a.verify(hash)
+		if err != nil {
+			return fmt.Errorf("the approval request's approval: %w", err)
+		}
+		if !approver.Equals(proposer) && (approverKeys == nil || approverKeys[approver]) {
+			return nil
+		}
+	}
+	if approverKeys != nil {
+		return errors.New("none of -approvers approved this request yet, refusing to execute it")
+	}
+	return errors.New("nobody but its proposer approved this request yet, refusing to execute it")
+}
+
+// writeApprovalRequest writes bundle to path signed by its proposer, and returns its hash.
+func writeApprovalRequest(path string, bundle txBundle, proposer solana.PrivateKey) (string, error) {
+	file, err := newTxBundleFile(bundle)
+	if err != nil {
+		return "", err
+	}
+	proposal, err := signApproval(proposer, file.SHA256)
+	if err != nil {
+		return "", err
+	}
+	file.Proposal = &proposal
+	return file.SHA256, saveTxBundleFile(path, file)
+}
+
+// approveRequest adds key's approval to the request.
+func approveRequest(file *txBundleFile, bundle *txBundle, hash string, key solana.PrivateKey) error {
+	if file.Proposal == nil {
+		return errors.New("this is a review bundle, not an approval request, approve it by handing its hash to -bundle-hash")
+	}
+	proposer, err := file.Proposal.verify(hash)
+	if err != nil {
+		return fmt.Errorf("the proposal: %w", err)
+	}
+	if proposer.String() != bundle.Payer {
+		return fmt.Errorf("the request was proposed by %s, not its payer %s", Addr(proposer.String()), Addr(bundle.Payer))
+	}
+	approver := key.PublicKey()
+	if approver.Equals(proposer) {
+		return errors.New("the proposer can't approve their own request")
+	}
+	if approverKeys != nil && !approverKeys[approver] {
+		return fmt.Errorf("%s isn't one of -approvers", Addr(approver.String()))
+	}
+	for _, a := range file.Approvals {
+		if a.Signer == approver.String() {
+			return fmt.Errorf("%s already approved this request", Addr(approver.String()))
+		}
+	}
+	approval, err := signApproval(key, hash)
+	if err != nil {
+		return err
+	}
+	file.Approvals = append(file.Approvals, approval)
+	return nil
+}
+
+// describeApprovalRequest is what the approver reads before signing.
+func describeApprovalRequest(bundle *txBundle, file txBundleFile, hash string) string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "Approval request %s\n", hash)
+	fmt.Fprintf(b, "Proposed by %s on %s, planned %s\n", file.Proposal.Signer, bundle.Network, bundle.CreatedAt.Format(time.RFC3339))
+	for i, e := range bundle.Entries {
+		fmt.Fprintf(b, "  %d. %s on pool %s\n", i+1, e.Intent, e.Pool)
+		if most, ok := e.Amounts["maxAmountIn"]; ok {
+			fmt.Fprintf(b, "     pays at most %s %s\n", most.Display, e.TokenIn.Symbol)
+		}
+		if least, ok := e.Amounts["minAmountOut"]; ok {
+			fmt.Fprintf(b, "     gets at least %s %s\n", least.Display, e.TokenOut.Symbol)
+		}
+	}
+	for _, a := range file.Approvals {
+		fmt.Fprintf(b, "Approved by %s at %s\n", a.Signer, a.SignedAt.Format(time.RFC3339))
+	}
+	return b.String()
+}
+
+func runApproveCommand(args []string) error {
+	fs := flag.NewFlagSet("approve", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: approve -keypair <file> [flags] <approval request>\n")
+		fs.PrintDefaults()
+	}
+	keypair := fs.String("keypair", "", "Keypair file of the approving keyholder")
+	addApprovalFlags(fs)
+	// The request reads naturally first, `approve request.json -keypair k.json`, flag stops at the first argument.
+	var path string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		path, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, []FlagSpec{{Name: "keypair", Value: keypair, Rules: []FlagRule{NotEmpty()}}})
+	if path == "" && fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+	if path == "" {
+		fs.Usage()
+		return errors.New("missing approval request")
+	}
+	key, err := solana.PrivateKeyFromSolanaKeygenFile(*keypair)
+	if err != nil {
+		return fmt.Errorf("failed to load the approver's key: %w", err)
+	}
+	file, bundle, hash, err := loadTxBundleFile(path)
+	if err != nil {
+		return err
+	}
+	if file.Proposal == nil {
+		return errors.New("this is a review bundle, not an approval request, approve it by handing its hash to -bundle-hash")
+	}
+	fmt.Fprint(os.Stdout, describeApprovalRequest(bundle, file, hash))
+	ok, err := promptYesNo(fmt.Sprintf("Approve it as %s?", key.PublicKey()))
+	if err != nil || !ok {
+		return errors.New("not approved")
+	}
+	if err := approveRequest(&file, bundle, hash, key); err != nil {
+		return err
+	}
+	if err := saveTxBundleFile(path, file); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "Approved. Execute it with -execute-bundle %s -bundle-hash %s\n", path, hash)
+	return nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+func TestApprovalRequest(t *testing.T) {
+	defer func(saved map[solana.PublicKey]bool) { approverKeys = saved }(approverKeys)
+	approverKeys = nil
+	proposer, approver, stranger := solana.NewWallet().PrivateKey, solana.NewWallet().PrivateKey, solana.NewWallet().PrivateKey
+	bundle, _ := newTestBundle(t)
+	bundle.Payer = proposer.PublicKey().String()
+	path := filepath.Join(t.TempDir(), "request.json")
+	hash, err := writeApprovalRequest(path, bundle, proposer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readTxBundle(path, hash); err == nil || !strings.Contains(err.Error(), "nobody but its proposer") {
+		t.Fatalf("an unapproved request: %v", err)
+	}
+
+	file, loaded, actual, err := loadTxBundleFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := approveRequest(&file, loaded, actual, proposer); err == nil {
+		t.Error("the proposer approved their own request")
+	}
+	approverKeys = map[solana.PublicKey]bool{approver.PublicKey(): true}
+	if err := approveRequest(&file, loaded, actual, stranger); err == nil || !strings.Contains(err.Error(), "-approvers") {
+		t.Errorf("a key off -approvers: %v", err)
+	}
+	if err := approveRequest(&file, loaded, actual, approver); err != nil {
+		t.Fatal(err)
+	}
+	if err := approveRequest(&file, loaded, actual, approver); err == nil {
+		t.Error("approved twice by the same key")
+	}
+	if err := saveTxBundleFile(path, file); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readTxBundle(path, hash); err != nil {
+		t.Fatalf("an approved request: %v", err)
+	}
+	if out := describeApprovalRequest(loaded, file, actual); !strings.Contains(out, "gets at least 1.990000000") || !strings.Contains(out, "Approved by "+approver.PublicKey().String()) {
+		t.Errorf("description %q", out)
+	}
+
+	// An approval moved onto another request doesn't carry over, and -approvers set at execution counts too.
+	other := bundle
+	other.Network = "mainnet"
+	otherPath := filepath.Join(t.TempDir(), "other.json")
+	otherHash, err := writeApprovalRequest(otherPath, other, proposer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherFile, otherBundle, _, err := loadTxBundleFile(otherPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherFile.Approvals = file.Approvals
+	if err := checkApprovals(otherFile, otherBundle, otherHash); err == nil || !strings.Contains(err.Error(), "isn't over this bundle") {
+		t.Errorf("a copied approval: %v", err)
+	}
+	approverKeys = map[solana.PublicKey]bool{stranger.PublicKey(): true}
+	if _, err := readTxBundle(path, hash); err == nil || !strings.Contains(err.Error(), "none of -approvers") {
+		t.Errorf("approved by someone off -approvers: %v", err)
+	}
+}
+
+func TestNeedsApproval(t *testing.T) {
+	defer func(above *big.Rat, price func(context.Context, solana.PublicKey, string) (usdPrice, time.Duration, error)) {
+		approvalAboveUSD, approvalPrice = above, price
+	}(approvalAboveUSD, approvalPrice)
+	symm, sol, usdc := hookTestSymbols()
+	approvalPrice = func(_ context.Context, mint solana.PublicKey, sym string) (usdPrice, time.Duration, error) {
+		if mint.Equals(sol) {
+			return usdPrice{Price: big.NewRat(150, 1)}, 0, nil
+		}
+		return usdPrice{}, 0, errors.New("no price for " + sym)
+	}
+	swap := func(in SwapLeg, maxIn int64) hookSwap {
+		return hookSwap{TokenIn: in, TokenOut: SwapLeg{Mint: usdc, Decimals: 6}, MaxIn: big.NewInt(maxIn), MinOut: big.NewInt(1), Symbols: symm}
+	}
+	solLeg := SwapLeg{Mint: sol, Decimals: 9}
+
+	approvalAboveUSD = nil
+	if over, _ := needsApproval(t.Context(), swap(solLeg, 100e9)); over {
+		t.Error("needs approval without -approval-above")
+	}
+	approvalAboveUSD = big.NewRat(1000, 1)
+	if over, _ := needsApproval(t.Context(), swap(solLeg, 6e9)); over {
+		t.Error("$900 is under $1000")
+	}
+	if over, why := needsApproval(t.Context(), swap(solLeg, 7e9)); !over || !strings.Contains(why, "$1050.00") {
+		t.Errorf("$1050: %v %q", over, why)
+	}
+	if over, why := needsApproval(t.Context(), swap(SwapLeg{Mint: snapshotKey(101)}, 1)); !over || !strings.Contains(why, "can't be valued") {
+		t.Errorf("an unpriced swap: %v %q", over, why)
+	}
+	if err := (approvalHook{}).PreSend(t.Context(), swap(solLeg, 7e9)); err == nil || !strings.Contains(err.Error(), "-no-tui") {
+		t.Errorf("the hook let it through: %v", err)
+	}
+}
+
+func TestBundleApproval(t *testing.T) {
+	defer func(above *big.Rat, price func(context.Context, solana.PublicKey, string) (usdPrice, time.Duration, error), keys map[solana.PublicKey]bool) {
+		approvalAboveUSD, approvalPrice, approverKeys = above, price, keys
+	}(approvalAboveUSD, approvalPrice, approverKeys)
+	deadline := deadlines
+	t.Cleanup(func() { deadlines = deadline })
+	deadlines.Send = 100 * time.Millisecond
+	approvalAboveUSD, approverKeys = big.NewRat(1000, 1), nil
+	approvalPrice = func(context.Context, solana.PublicKey, string) (usdPrice, time.Duration, error) {
+		return usdPrice{Price: big.NewRat(5000, 1)}, 0, nil
+	}
+	proposer, approver := solana.NewWallet().PrivateKey, solana.NewWallet().PrivateKey
+	bundle := *transferBundle(t, proposer, 1)
+
+	// Exported over the threshold it's a request, its own hash doesn't get it sent.
+	path := filepath.Join(t.TempDir(), "big.json")
+	var out strings.Builder
+	if err := exportTxBundle(t.Context(), path, bundle, proposer, &out); err != nil || !strings.Contains(out.String(), "approval request") {
+		t.Fatalf("export: %v %q", err, out.String())
+	}
+	file, loaded, hash, err := loadTxBundleFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readTxBundle(path, hash); err == nil || !strings.Contains(err.Error(), "nobody but its proposer") {
+		t.Fatalf("executed the exported swap on its own hash: %v", err)
+	}
+
+	// Nor does a review bundle written some other way.
+	reviewPath := filepath.Join(t.TempDir(), "review.json")
+	reviewHash, err := writeTxBundle(reviewPath, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	review, err := readTxBundle(reviewPath, reviewHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, sent := bundleNode(t, -1, -1)
+	if err := executeTxBundle(t.Context(), client, proposer, review, "devnet"); err == nil || !strings.Contains(err.Error(), "countersigned") || len(sent()) != 0 {
+		t.Fatalf("a review bundle over -approval-above, sent %d: %v", len(sent()), err)
+	}
+
+	// Countersigned, it goes out.
+	if err := approveRequest(&file, loaded, hash, approver); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveTxBundleFile(path, file); err != nil {
+		t.Fatal(err)
+	}
+	approved, err := readTxBundle(path, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := executeTxBundle(t.Context(), client, proposer, approved, "devnet"); err != nil || len(sent()) != 1 {
+		t.Fatalf("a countersigned request, sent %d: %v", len(sent()), err)
+	}
+}
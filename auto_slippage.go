@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): Slippage off the pool's volatility.
+
+A fixed -slippage is either too tight on a pool that's moving, and the swap fails, or too loose on one that isn't, and
+it hands whoever's sandwiching us room to work with. `-slippage auto` sets it per pool from how much the price has
+been moving: the realized volatility over the last -auto-slippage-window of the pool's observation ring (the same
+history `pool candles` reads, see candles.go), times -auto-slippage-multiple, clamped to -auto-slippage-min and
+-auto-slippage-max.
+
+Each observation segment is one average price. The log return between neighbouring segments, squared and summed, over
+the time between their midpoints is the price's variance per unit of time, and its square root at one minute is the
+volatility the multiple is applied to: about how far the price wanders in the minute between quoting and landing. The
+window counts back from the newest observation rather than from now, a quiet pool's last trades are the history it
+has. Fewer than three segments in the window can't say much, the ring is too thin or nobody's been trading, and the
+slippage goes to the max bound, not knowing isn't a reason to be tight.
+
+It's settled once, on the pool the swap goes to, after -best has picked one, and printed with what it came from.
+*/
+
+const autoSlippageHorizon = time.Minute
+
+type slippageFlags struct {
+	pct      float64
+	auto     bool
+	multiple *float64
+	min      *float64
+	max      *float64
+	window   *time.Duration
+}
+
+func addSlippageFlags(fs *flag.FlagSet) *slippageFlags {
+	sf := &slippageFlags{pct: 0.5}
+	fs.Func("slippage", "Slippage tolerance percentage (e.g. 0.5 for 0.5%), or 'auto' to set it off the pool's recent volatility (default 0.5)", func(s string) error {
+		if strings.EqualFold(strings.TrimSpace(s), "auto") {
+			sf.auto = true
+			return nil
+		}
+		pct, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("slippage takes a percentage or 'auto', got %q", s)
+		}
+		if _, err := makeSlippageRatio(pct); err != nil {
+			return err
+		}
+		sf.pct, sf.auto = pct, false
+		return nil
+	})
+	sf.multiple = fs.Float64("auto-slippage-multiple", 3, "With -slippage auto, the slippage is this many times the price's volatility per minute")
+	sf.min = fs.Float64("auto-slippage-min", 0.1, "With -slippage auto, the lowest slippage percentage it sets")
+	sf.max = fs.Float64("auto-slippage-max", 3, "With -slippage auto, the highest slippage percentage it sets, and the one it sets without enough history")
+	sf.window = fs.Duration("auto-slippage-window", 30*time.Minute, "With -slippage auto, how far back the volatility is measured")
+	return sf
+}
+
+func (sf *slippageFlags) validate() error {
+	if !sf.auto {
+		return nil
+	}
+	switch {
+	case *sf.multiple <= 0:
+		return errors.New("-auto-slippage-multiple has to be greater than zero")
+	case *sf.min < 0 || *sf.max < *sf.min:
+		return errors.New("-auto-slippage-min can't be negative or over -auto-slippage-max")
+	case *sf.window <= 0:
+		return errors.New("-auto-slippage-window has to be greater than zero")
+	}
+	if _, err := makeSlippageRatio(*sf.max); err != nil {
+		return fmt.Errorf("-auto-slippage-max: %w", err)
+	}
+	return nil
+}
+
+// priceVolatility is the realized volatility of segments' prices over the window before the newest one ends, per
+// horizon, as a fraction. It also returns how many segments that took in.
+func priceVolatility(segments []priceSegment, window, horizon time.Duration) (float64, int) {
+	if len(segments) == 0 {
+		return 0, 0
+	}
+	since := segments[len(segments)-1].end.Add(-window)
+	var (
+		prev     *priceSegment
+		sumSq    float64
+		elapsed  time.Duration
+		included int
+	)
+	midpoint := func(s *priceSegment) time.Time { return s.start.Add(s.end.Sub(s.start) / 2) }
+	for i := range segments {
+		seg := &segments[i]
+		if seg.end.Before(since) || seg.price.Sign() <= 0 {
+			continue
+		}
+		included++
+		if prev != nil {
+			ratio, _ := new(big.Rat).Quo(seg.price, prev.price).Float64()
+			r := math.Log(ratio)
+			sumSq += r * r
+			elapsed += midpoint(seg).Sub(midpoint(prev))
+		}
+		prev = seg
+	}
+	if elapsed <= 0 {
+		return 0, included
+	}
+	return math.Sqrt(sumSq / float64(elapsed) * float64(horizon)), included
+}
+
+// autoSlippagePct is the slippage percentage for volatility (a fraction per autoSlippageHorizon), clamped.
+func (sf *slippageFlags) autoSlippagePct(volatility float64, segments int) float64 {
+	if segments < 3 {
+		return *sf.max
+	}
+	return math.Min(math.Max(volatility*100**sf.multiple, *sf.min), *sf.max)
+}
+
+// resolve is the slippage percentage to swap on pool with and a line saying where it came from. Without auto that's
+// -slippage as passed.
+func (sf *slippageFlags) resolve(ctx context.Context, client *rpc.Client, pool *raydium_cp_swap.PoolState) (float64, string, error) {
+	if !sf.auto {
+		return sf.pct, "", nil
+	}
+	readCtx, cancel := deadlines.forQuote(ctx)
+	defer cancel()
+	state, err := fetchObservationState(readCtx, client, pool.ObservationKey)
+	if err != nil {
+		return 0, "", fmt.Errorf("-slippage auto reads the pool's price history: %w", err)
+	}
+	volatility, n := priceVolatility(observationSegments(state, [2]uint8{pool.Mint0Decimals, pool.Mint1Decimals}), *sf.window, autoSlippageHorizon)
+	pct := sf.autoSlippagePct(volatility, n)
+	if n < 3 {
+		return pct, fmt.Sprintf("Slippage auto: %s, the max, the pool has %d prices in the last %s, too few to measure its volatility", floatPct(pct), n, *sf.window), nil
+	}
+	return pct, fmt.Sprintf("Slippage auto: %s, %g x %s volatility per minute over the last %s (%d prices), clamped to %s-%s",
+		floatPct(pct), *sf.multiple, floatPct(volatility*100), *sf.window, n, floatPct(*sf.min), floatPct(*sf.max)), nil
+}
+
+// floatPct is pct, a percentage, written like pctString does.
+func floatPct(pct float64) string {
+	return pctString(new(big.Rat).SetFloat64(pct / 100))
+}
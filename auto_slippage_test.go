@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"math"
+	"math/big"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func parseSlippageFlags(t *testing.T, args ...string) (*slippageFlags, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	sf := addSlippageFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return sf, sf.validate()
+}
+
+func TestSlippageFlags(t *testing.T) {
+	if sf, err := parseSlippageFlags(t); err != nil || sf.auto || sf.pct != 0.5 {
+		t.Errorf("default: %+v, %v", sf, err)
+	}
+	if sf, err := parseSlippageFlags(t, "-slippage", "1.5"); err != nil || sf.auto || sf.pct != 1.5 {
+		t.Errorf("-slippage 1.5: %+v, %v", sf, err)
+	}
+	if sf, err := parseSlippageFlags(t, "-slippage", "auto"); err != nil || !sf.auto {
+		t.Errorf("-slippage auto: %+v, %v", sf, err)
+	}
+	for _, args := range [][]string{
+		{"-slippage", "fast"},
+		{"-slippage", "120"},
+		{"-slippage", "auto", "-auto-slippage-min", "2", "-auto-slippage-max", "1"},
+		{"-slippage", "auto", "-auto-slippage-multiple", "0"},
+	} {
+		if _, err := parseSlippageFlags(t, args...); err == nil {
+			t.Errorf("%v passed", args)
+		}
+	}
+}
+
+// alternatingSegments are n minute long segments whose price goes back and forth by step, a log return of about step
+// between every two.
+func alternatingSegments(n int, step float64) []priceSegment {
+	start := time.Unix(1_700_000_000, 0).UTC()
+	up := new(big.Rat).SetFloat64(100 * math.Exp(step))
+	var segments []priceSegment
+	for i := range n {
+		price := big.NewRat(100, 1)
+		if i%2 == 1 {
+			price = up
+		}
+		segStart := start.Add(time.Duration(i) * time.Minute)
+		segments = append(segments, priceSegment{start: segStart, end: segStart.Add(time.Minute), price: price})
+	}
+	return segments
+}
+
+func TestPriceVolatility(t *testing.T) {
+	// A 1% move every minute is 1% volatility per minute, and 2% over four minutes.
+	segments := alternatingSegments(20, 0.01)
+	if vol, n := priceVolatility(segments, time.Hour, time.Minute); math.Abs(vol-0.01) > 1e-9 || n != 20 {
+		t.Errorf("per minute %v over %d segments", vol, n)
+	}
+	if vol, _ := priceVolatility(segments, time.Hour, 4*time.Minute); math.Abs(vol-0.02) > 1e-9 {
+		t.Errorf("per 4 minutes %v", vol)
+	}
+	// The window counts back from the newest segment.
+	if _, n := priceVolatility(segments, 5*time.Minute, time.Minute); n != 6 {
+		t.Errorf("%d segments in the last 5 minutes", n)
+	}
+	if vol, n := priceVolatility(nil, time.Hour, time.Minute); vol != 0 || n != 0 {
+		t.Errorf("no history %v, %d", vol, n)
+	}
+
+	sf, _ := parseSlippageFlags(t, "-slippage", "auto", "-auto-slippage-multiple", "3", "-auto-slippage-min", "0.1", "-auto-slippage-max", "2")
+	for _, tc := range []struct {
+		vol      float64
+		segments int
+		want     float64
+	}{
+		{0.002, 10, 0.6},
+		{0.0001, 10, 0.1},
+		{0.05, 10, 2},
+		{0.002, 2, 2},
+	} {
+		if got := sf.autoSlippagePct(tc.vol, tc.segments); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("volatility %v over %d segments: %v%%, want %v%%", tc.vol, tc.segments, got, tc.want)
+		}
+	}
+}
+
+func TestResolveAutoSlippage(t *testing.T) {
+	pool, _, _ := snapshotPool()
+	times, prices := []uint64{}, []int64{}
+	for i := range 11 {
+		times = append(times, 1_700_000_000+uint64(i)*60)
+		prices = append(prices, 100+int64(i%2))
+	}
+	ring := observationRing(times, prices, big.NewInt(0), 10)
+	srv := chainServer(t, map[solana.PublicKey]chainAccount{
+		pool.ObservationKey: {owner: raydium_cp_swap.ProgramID, data: anchorAccount(t, raydium_cp_swap.Account_ObservationState, *ring)},
+	}, nil, &atomic.Int64{})
+
+	sf, _ := parseSlippageFlags(t, "-slippage", "auto")
+	pct, why, err := sf.resolve(context.Background(), rpc.New(srv.URL), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The price moves about 1% a minute, three times that is just under the default 3% max.
+	if pct < 2.9 || pct >= 3 || !strings.Contains(why, "over the last 30m0s (10 prices)") {
+		t.Errorf("%v%%, %s", pct, why)
+	}
+
+	fixed, _ := parseSlippageFlags(t, "-slippage", "0.7")
+	if pct, why, err := fixed.resolve(context.Background(), nil, pool); pct != 0.7 || why != "" || err != nil {
+		t.Errorf("fixed: %v%%, %q, %v", pct, why, err)
+	}
+}
@@ -0,0 +1,699 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+/*
+NOTE(@hadydotai): Backtests.
+
+`backtest <history> -strategy ...` replays a pool's recorded reserves through the same quoting the live commands use
+and reports what each strategy would have traded. The history is one of:
+
+  - a `price stream` recording, its JSON lines as they were printed, the pool is the one the lines name
+  - an indexer export, a CSV with a header and the columns time, reserve0 and reserve1 (raw base units of token0 and
+    token1, slot is read when it's there), time in RFC 3339 or unix seconds, -pool says which pool it is
+
+The pool is read once for what the history doesn't carry, its tokens, their decimals and the trade fee, everything
+after that is offline. A strategy is its kind, a colon and what the live command takes, -strategy can be repeated to
+compare several on the same history:
+
+	limit:buy 10 SOL when price <= 140 USDC    fills once, at the first state the trigger holds on
+	dca:pay 100 USDC on @every 6h              any schedule `dca run` takes, starting from the first state
+	twap:sell 50 SOL over 4h every 15m         equal slices from the first state, no jitter
+
+Every fill is quoted against the last recorded state at or before its time, with the trade fee and the impact of
+its own size, and one over -max-impact is skipped like the live commands skip it. The fills don't move the recorded
+reserves, the history is what the pool did without us, so a strategy that takes a big share of the pool over and over
+looks better than it would have done. Amounts are tokens, a percentage of a wallet or dollars have nothing to resolve
+against back then, and a schedule that runs past the end of the history stops there, a TWAP has to fit in it.
+
+Per strategy it reports the fills, the average price (counter token per target token, like a limit price), the
+slippage against the mid-price of the state each fill was quoted on (worse is positive, fee and impact included) and
+the P&L in the counter token, what was bought marked at the last state's mid-price against what it cost, or what a
+sale raised against what was sold was worth there.
+*/
+
+// reserveState is the pool's reserves at a moment of its history, raw units of token0 and token1.
+type reserveState struct {
+	at       time.Time
+	slot     uint64 // 0 when the history doesn't say
+	reserves [2]*big.Int
+}
+
+// mid is the state's mid-price, the other token per token i, raw units, nil while the pool holds none of i.
+func (s reserveState) mid(i int) *big.Rat {
+	if s.reserves[i].Sign() == 0 {
+		return nil
+	}
+	return new(big.Rat).SetFrac(s.reserves[1-i], s.reserves[i])
+}
+
+// reserveHistory is a pool's reserve states, oldest first.
+type reserveHistory struct {
+	pool   string // empty when the history doesn't name it
+	states []reserveState
+}
+
+// at is the last state at or before t.
+func (h *reserveHistory) at(t time.Time) (reserveState, bool) {
+	i := sort.Search(len(h.states), func(i int) bool { return h.states[i].at.After(t) })
+	if i == 0 {
+		return reserveState{}, false
+	}
+	return h.states[i-1], true
+}
+
+// readReserveHistory reads a history file, a CSV export when it ends in .csv and a price stream recording otherwise.
+func readReserveHistory(path string) (*reserveHistory, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening the history failed: %w", err)
+	}
+	defer f.Close()
+	var h *reserveHistory
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		h, err = readReserveCSV(f)
+	} else {
+		h, err = readPriceTicks(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s failed: %w", path, err)
+	}
+	if len(h.states) == 0 {
+		return nil, fmt.Errorf("%s has no reserve states", path)
+	}
+	sort.SliceStable(h.states, func(i, j int) bool { return h.states[i].at.Before(h.states[j].at) })
+	return h, nil
+}
+
+// readPriceTicks reads the JSON lines `price stream` prints.
+func readPriceTicks(r io.Reader) (*reserveHistory, error) {
+	h := &reserveHistory{}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+		var tick priceTick
+		if err := json.Unmarshal([]byte(text), &tick); err != nil {
+			return nil, fmt.Errorf("line %d isn't a price stream line: %w", line, err)
+		}
+		switch {
+		case h.pool == "":
+			h.pool = tick.Pool
+		case tick.Pool != "" && tick.Pool != h.pool:
+			return nil, fmt.Errorf("line %d is pool %s and the lines before it %s, a history is one pool's", line, tick.Pool, h.pool)
+		}
+		r0, ok0 := parseRawReserve(tick.BaseReserve.Raw)
+		r1, ok1 := parseRawReserve(tick.QuoteReserve.Raw)
+		if !ok0 || !ok1 || tick.Time.IsZero() {
+			return nil, fmt.Errorf("line %d is missing its time or raw reserves", line)
+		}
+		h.states = append(h.states, reserveState{at: tick.Time, slot: tick.Slot, reserves: [2]*big.Int{r0, r1}})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// readReserveCSV reads an indexer export, columns found by name in the header.
+func readReserveCSV(r io.Reader) (*reserveHistory, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading the CSV header failed: %w", err)
+	}
+	cols := map[string]int{}
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, want := range []string{"time", "reserve0", "reserve1"} {
+		if _, ok := cols[want]; !ok {
+			return nil, fmt.Errorf("the CSV has no %s column, it needs time, reserve0 and reserve1", want)
+		}
+	}
+	slotCol, hasSlot := cols["slot"]
+	h := &reserveHistory{}
+	for row := 2; ; row++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading CSV row %d failed: %w", row, err)
+		}
+		at, err := parseHistoryTime(rec[cols["time"]])
+		if err != nil {
+			return nil, fmt.Errorf("CSV row %d: %w", row, err)
+		}
+		r0, ok0 := parseRawReserve(rec[cols["reserve0"]])
+		r1, ok1 := parseRawReserve(rec[cols["reserve1"]])
+		if !ok0 || !ok1 {
+			return nil, fmt.Errorf("CSV row %d: reserves have to be whole numbers of base units, got %q and %q", row, rec[cols["reserve0"]], rec[cols["reserve1"]])
+		}
+		state := reserveState{at: at, reserves: [2]*big.Int{r0, r1}}
+		if hasSlot && rec[slotCol] != "" {
+			if state.slot, err = strconv.ParseUint(rec[slotCol], 10, 64); err != nil {
+				return nil, fmt.Errorf("CSV row %d: slot %q isn't a slot number", row, rec[slotCol])
+			}
+		}
+		h.states = append(h.states, state)
+	}
+	return h, nil
+}
+
+func parseRawReserve(s string) (*big.Int, bool) {
+	v, ok := new(big.Int).SetString(strings.TrimSpace(s), 10)
+	if !ok || v.Sign() < 0 {
+		return nil, false
+	}
+	return v, true
+}
+
+// parseHistoryTime reads an export's time, RFC 3339 or unix seconds.
+func parseHistoryTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("time %q is neither RFC 3339 nor unix seconds", s)
+	}
+	return t, nil
+}
+
+const (
+	backtestLimit = "limit"
+	backtestDCA   = "dca"
+	backtestTWAP  = "twap"
+)
+
+// backtestStrategy is one -strategy, parsed.
+type backtestStrategy struct {
+	spec        string
+	kind        string
+	instruction *IntentInstruction
+	cond        limitCondition // a limit's trigger
+	sched       schedule       // a DCA's schedule
+	twap        twapPlan
+}
+
+// parseBacktestStrategy parses `<kind>:<what the live command takes>`, see the note at the top.
+func parseBacktestStrategy(spec string) (*backtestStrategy, error) {
+	kind, rest, ok := strings.Cut(strings.TrimSpace(spec), ":")
+	if !ok {
+		return nil, fmt.Errorf("strategy %q doesn't say its kind, put limit:, dca: or twap: in front of it", spec)
+	}
+	s := &backtestStrategy{spec: strings.TrimSpace(spec), kind: strings.ToLower(strings.TrimSpace(kind))}
+	rest = strings.TrimSpace(rest)
+	lower := strings.ToLower(rest)
+	var intentPart string
+	switch s.kind {
+	case backtestLimit:
+		order, err := parseLimitOrder(rest)
+		if err != nil {
+			return nil, err
+		}
+		s.instruction, s.cond = order.instruction, order.cond
+	case backtestDCA:
+		idx := strings.LastIndex(lower, " on ")
+		if idx < 0 {
+			return nil, fmt.Errorf("dca strategy %q is missing its schedule, expected `dca:<intent> on <schedule>`", rest)
+		}
+		sched, err := parseSchedule(rest[idx+len(" on "):])
+		if err != nil {
+			return nil, err
+		}
+		s.sched, intentPart = sched, rest[:idx]
+	case backtestTWAP:
+		over, every := strings.LastIndex(lower, " over "), strings.LastIndex(lower, " every ")
+		if over < 0 || every < over {
+			return nil, fmt.Errorf("twap strategy %q is malformed, expected `twap:<intent> over <window> every <interval>`", rest)
+		}
+		window, err := time.ParseDuration(strings.TrimSpace(rest[over+len(" over ") : every]))
+		if err != nil {
+			return nil, fmt.Errorf("twap strategy %q has an invalid window: %w", rest, err)
+		}
+		interval, err := time.ParseDuration(strings.TrimSpace(rest[every+len(" every "):]))
+		if err != nil {
+			return nil, fmt.Errorf("twap strategy %q has an invalid interval: %w", rest, err)
+		}
+		switch {
+		case interval <= 0:
+			return nil, fmt.Errorf("twap strategy %q has to send slices more often than never", rest)
+		case window < 2*interval:
+			return nil, fmt.Errorf("twap strategy %q has to fit at least two slices in its window", rest)
+		case window/interval > maxTWAPSlices:
+			return nil, fmt.Errorf("twap strategy %q comes to more than %d slices", rest, maxTWAPSlices)
+		}
+		s.twap, intentPart = twapPlan{window: window, every: interval}, rest[:over]
+	default:
+		return nil, fmt.Errorf("strategy kind %q isn't one of %s, %s or %s", kind, backtestLimit, backtestDCA, backtestTWAP)
+	}
+	if s.instruction == nil {
+		instruction, err := parseIntent(strings.TrimSpace(intentPart))
+		if err != nil {
+			return nil, err
+		}
+		s.instruction = instruction
+	}
+	ii := s.instruction
+	switch {
+	case ii.TargetSymbol == "":
+		return nil, fmt.Errorf("strategy %q has to name a token", s.spec)
+	case ii.AmountPct != nil:
+		return nil, fmt.Errorf("strategy %q trades a percentage of a wallet, a backtest has none, give it an amount", s.spec)
+	case ii.AmountUSD != nil:
+		return nil, fmt.Errorf("strategy %q is in dollars, the history has no dollar prices, give it an amount in tokens", s.spec)
+	case ii.Condition != nil:
+		return nil, fmt.Errorf("strategy %q has an at price, a trigger is a %s: strategy", s.spec, backtestLimit)
+	}
+	return s, nil
+}
+
+// backtester replays a history through a venue.
+type backtester struct {
+	venue     Venue
+	symm      SymbolMapping
+	decimals  [2]uint8
+	history   *reserveHistory
+	maxImpact *big.Rat // nil for no cap
+}
+
+// backtestFill is a simulated trade, raw units.
+type backtestFill struct {
+	at      time.Time
+	target  *big.Int
+	counter *big.Int
+	mid     *big.Rat // counter per target of the state it was quoted on
+}
+
+// backtestResult is what a strategy did over the history.
+type backtestResult struct {
+	strategy *backtestStrategy
+	target   int  // which of the pool's tokens the strategy's intent names
+	selling  bool // the intent pays the target token
+	fills    []backtestFill
+	skipped  int // runs that were due but didn't quote, or quoted over -max-impact
+}
+
+func (bt *backtester) quote(st reserveState, req swapQuoteRequest) (*CPIntent, error) {
+	balances := []*PoolBalance{{Balance: st.reserves[0], Decimals: bt.decimals[0]}, {Balance: st.reserves[1], Decimals: bt.decimals[1]}}
+	return bt.venue.Quote(req, balances)
+}
+
+func (bt *backtester) impactOK(intent *CPIntent) bool {
+	impact, err := intent.PriceImpact()
+	return err == nil && (bt.maxImpact == nil || impact.Cmp(bt.maxImpact) <= 0)
+}
+
+// fill runs one due execution at t, against the state the pool was in then.
+func (bt *backtester) fill(res *backtestResult, t time.Time, req swapQuoteRequest) {
+	st, ok := bt.history.at(t)
+	if !ok {
+		res.skipped++
+		return
+	}
+	intent, err := bt.quote(st, req)
+	if err != nil || !bt.impactOK(intent) {
+		res.skipped++
+		return
+	}
+	res.record(t, st, intent)
+}
+
+func (r *backtestResult) record(t time.Time, st reserveState, intent *CPIntent) {
+	in, out := intent.QuotedInOut()
+	fill := backtestFill{at: t, target: out, counter: in, mid: st.mid(r.target)}
+	if r.selling {
+		fill.target, fill.counter = in, out
+	}
+	r.fills = append(r.fills, fill)
+}
+
+// run replays the history through s.
+func (bt *backtester) run(s *backtestStrategy) (*backtestResult, error) {
+	mints := bt.venue.Mints()
+	targetMint, ok := bt.symm.MaybeMintFromToken(s.instruction.TargetSymbol)
+	if !ok || (!targetMint.Equals(mints[0]) && !targetMint.Equals(mints[1])) {
+		return nil, fmt.Errorf("strategy %q: %s isn't one of the pool's tokens, %s and %s", s.spec, s.instruction.TargetSymbol, bt.symm.SymFrom(mints[0]), bt.symm.SymFrom(mints[1]))
+	}
+	target := tokenIndex(bt.venue, targetMint)
+	if err := s.instruction.checkCounter(mints[1-target], bt.symm); err != nil {
+		return nil, fmt.Errorf("strategy %q: %w", s.spec, err)
+	}
+	if s.kind == backtestLimit && s.cond.unit != "" && !bt.symm.namesMint(s.cond.unit, mints[1-target]) {
+		return nil, fmt.Errorf("strategy %q: the price is in %s, but %s is priced in %s on this pool", s.spec, s.cond.unit, s.instruction.TargetSymbol, bt.symm.SymFrom(mints[1-target]))
+	}
+	amount, err := fmtForMath(s.instruction.AmountStr, bt.decimals[target])
+	if err != nil {
+		return nil, fmt.Errorf("strategy %q: %w", s.spec, err)
+	}
+	res := &backtestResult{strategy: s, target: target, selling: s.instruction.Dir == SwapDirSell}
+	req := swapQuoteRequest{TargetMint: targetMint, Amount: amount, Dir: s.instruction.Dir, Slippage: new(big.Rat)}
+	states := bt.history.states
+	start, end := states[0].at, states[len(states)-1].at
+	switch s.kind {
+	case backtestLimit:
+		for _, st := range states {
+			intent, err := bt.quote(st, req)
+			if err != nil || !s.cond.holds(targetPrice(intent)) || !bt.impactOK(intent) {
+				continue
+			}
+			res.record(st.at, st, intent)
+			break
+		}
+	case backtestDCA:
+		// Cron fields are read in local time, like `dca run` reads them.
+		for at := s.sched.next(start.Local()); !at.IsZero() && !at.After(end); at = s.sched.next(at) {
+			bt.fill(res, at, req)
+		}
+	case backtestTWAP:
+		slices := s.twap.schedule(amount, func() float64 { return 0.5 })
+		if len(slices) == 0 {
+			return nil, fmt.Errorf("strategy %q has nothing to slice", s.spec)
+		}
+		if last := start.Add(slices[len(slices)-1].at); last.After(end) {
+			return nil, fmt.Errorf("strategy %q doesn't fit in the history, its last slice is due at %s and the history ends at %s",
+				s.spec, last.Local().Format(time.DateTime), end.Local().Format(time.DateTime))
+		}
+		for _, slice := range slices {
+			req.Amount = slice.amount
+			bt.fill(res, start.Add(slice.at), req)
+		}
+	}
+	return res, nil
+}
+
+// totals are what the strategy traded of the target and the counter token, and what that much target token came to
+// in the counter token at the mid-price of every fill, raw units.
+func (r *backtestResult) totals() (target, counter *big.Int, atMid *big.Rat) {
+	target, counter, atMid = new(big.Int), new(big.Int), new(big.Rat)
+	for _, f := range r.fills {
+		target.Add(target, f.target)
+		counter.Add(counter, f.counter)
+		if f.mid != nil {
+			atMid.Add(atMid, new(big.Rat).Mul(new(big.Rat).SetInt(f.target), f.mid))
+		}
+	}
+	return target, counter, atMid
+}
+
+// slippage is how much worse than the mid-price the fills came out, volume weighted, negative when it's better. Nil
+// without fills.
+func (r *backtestResult) slippage() *big.Rat {
+	_, counter, atMid := r.totals()
+	if atMid.Sign() == 0 {
+		return nil
+	}
+	ratio := new(big.Rat).Quo(new(big.Rat).SetInt(counter), atMid)
+	if r.selling {
+		return ratio.Sub(big.NewRat(1, 1), ratio)
+	}
+	return ratio.Sub(ratio, big.NewRat(1, 1))
+}
+
+// pnl is the strategy's profit in raw counter units, its target token marked at mark (counter per target, raw).
+func (r *backtestResult) pnl(mark *big.Rat) *big.Rat {
+	target, counter, _ := r.totals()
+	if mark == nil {
+		return nil
+	}
+	value := new(big.Rat).Mul(new(big.Rat).SetInt(target), mark)
+	if r.selling {
+		return value.Sub(new(big.Rat).SetInt(counter), value)
+	}
+	return value.Sub(value, new(big.Rat).SetInt(counter))
+}
+
+// displayPrice turns a raw counter per target price into display units.
+func (bt *backtester) displayPrice(r *backtestResult, price *big.Rat) string {
+	if price == nil {
+		return "n/a"
+	}
+	counterDec := bt.decimals[1-r.target]
+	scaled := new(big.Rat).Mul(price, new(big.Rat).SetFrac(fixedPointScale(bt.decimals[r.target]), fixedPointScale(counterDec)))
+	return trimDecimal(scaled.FloatString(int(counterDec)))
+}
+
+// avgPrice is the strategy's average fill, counter per target in raw units, nil without fills.
+func (r *backtestResult) avgPrice() *big.Rat {
+	target, counter, _ := r.totals()
+	if target.Sign() == 0 {
+		return nil
+	}
+	return new(big.Rat).SetFrac(counter, target)
+}
+
+func ratInt(r *big.Rat) *big.Int {
+	if r == nil {
+		return nil
+	}
+	return new(big.Int).Quo(r.Num(), r.Denom())
+}
+
+func (bt *backtester) mark(r *backtestResult) *big.Rat {
+	return bt.history.states[len(bt.history.states)-1].mid(r.target)
+}
+
+func renderBacktest(bt *backtester, results []*backtestResult) string {
+	builder := &strings.Builder{}
+	states := bt.history.states
+	t := table.NewWriter()
+	t.SetOutputMirror(builder)
+	t.SetTitle("Backtest " + bt.venue.Address().String())
+	t.Style().Size.WidthMax = 200
+	t.AppendHeader(table.Row{"Strategy", "Fills", "Skipped", "Traded", "For", "Avg Price", "Slippage", "P&L", "P&L %"})
+	mints := bt.venue.Mints()
+	for _, r := range results {
+		targetSym, counterSym := bt.symm.SymFrom(mints[r.target]), bt.symm.SymFrom(mints[1-r.target])
+		counterDec := bt.decimals[1-r.target]
+		target, counter, _ := r.totals()
+		slippage, pnlPct, pnlCell := "n/a", "n/a", "n/a"
+		if s := r.slippage(); s != nil {
+			slippage = pctString(s)
+		}
+		if pnl := r.pnl(bt.mark(r)); pnl != nil && len(r.fills) > 0 {
+			pnlCell = formatTokenAmount(ratInt(pnl), counterDec, counterSym)
+			if counter.Sign() > 0 {
+				pnlPct = pctString(new(big.Rat).Quo(pnl, new(big.Rat).SetInt(counter)))
+			}
+		}
+		price := bt.displayPrice(r, r.avgPrice())
+		if price != "n/a" {
+			price += " " + counterSym
+		}
+		t.AppendRow(table.Row{r.strategy.spec, len(r.fills), r.skipped, formatTokenAmount(target, bt.decimals[r.target], targetSym),
+			formatTokenAmount(counter, counterDec, counterSym), price, slippage, pnlCell, pnlPct})
+	}
+	t.Render()
+	first, last := states[0], states[len(states)-1]
+	fmt.Fprintf(builder, "Replayed %d states from %s to %s. Prices are the counter token per token traded, slippage is against the mid-price at each fill (worse is positive) and P&L is marked at the last state's mid-price.\n",
+		len(states), first.at.Local().Format(time.DateTime), last.at.Local().Format(time.DateTime))
+	return builder.String()
+}
+
+type backtestJSON struct {
+	Pool       string                 `json:"pool"`
+	From       time.Time              `json:"from"`
+	To         time.Time              `json:"to"`
+	States     int                    `json:"states"`
+	Strategies []backtestStrategyJSON `json:"strategies"`
+}
+
+type backtestStrategyJSON struct {
+	Strategy      string             `json:"strategy"`
+	Kind          string             `json:"kind"`
+	Fills         int                `json:"fills"`
+	Skipped       int                `json:"skipped"`
+	Traded        amountJSON         `json:"traded"`
+	TradedSymbol  string             `json:"tradedSymbol"`
+	Counter       amountJSON         `json:"counter"`
+	CounterSymbol string             `json:"counterSymbol"`
+	Price         string             `json:"price,omitempty"` // counter per traded token
+	MarkPrice     string             `json:"markPrice,omitempty"`
+	Slippage      string             `json:"slippage,omitempty"` // percent, worse is positive
+	PnL           *amountJSON        `json:"pnl,omitempty"`      // in the counter token
+	PnLPct        string             `json:"pnlPct,omitempty"`
+	Trades        []backtestFillJSON `json:"trades"`
+}
+
+type backtestFillJSON struct {
+	Time     time.Time  `json:"time"`
+	Traded   amountJSON `json:"traded"`
+	Counter  amountJSON `json:"counter"`
+	Price    string     `json:"price"`
+	MidPrice string     `json:"midPrice,omitempty"`
+}
+
+func backtestAsJSON(bt *backtester, results []*backtestResult) backtestJSON {
+	states := bt.history.states
+	out := backtestJSON{Pool: bt.venue.Address().String(), From: states[0].at, To: states[len(states)-1].at, States: len(states), Strategies: []backtestStrategyJSON{}}
+	mints := bt.venue.Mints()
+	for _, r := range results {
+		targetDec, counterDec := bt.decimals[r.target], bt.decimals[1-r.target]
+		target, counter, _ := r.totals()
+		sj := backtestStrategyJSON{
+			Strategy:      r.strategy.spec,
+			Kind:          r.strategy.kind,
+			Fills:         len(r.fills),
+			Skipped:       r.skipped,
+			Traded:        newAmountJSON(target, targetDec),
+			TradedSymbol:  bt.symm.SymFrom(mints[r.target]),
+			Counter:       newAmountJSON(counter, counterDec),
+			CounterSymbol: bt.symm.SymFrom(mints[1-r.target]),
+			Trades:        []backtestFillJSON{},
+		}
+		if mark := bt.mark(r); mark != nil {
+			sj.MarkPrice = bt.displayPrice(r, mark)
+		}
+		if len(r.fills) > 0 {
+			sj.Price = bt.displayPrice(r, r.avgPrice())
+			if s := r.slippage(); s != nil {
+				sj.Slippage = pctString(s)
+			}
+			if pnl := r.pnl(bt.mark(r)); pnl != nil {
+				sj.PnL = ptrTo(newAmountJSON(ratInt(pnl), counterDec))
+				if counter.Sign() > 0 {
+					sj.PnLPct = pctString(new(big.Rat).Quo(pnl, new(big.Rat).SetInt(counter)))
+				}
+			}
+		}
+		for _, f := range r.fills {
+			fj := backtestFillJSON{Time: f.at, Traded: newAmountJSON(f.target, targetDec), Counter: newAmountJSON(f.counter, counterDec)}
+			if f.target.Sign() > 0 {
+				fj.Price = bt.displayPrice(r, new(big.Rat).SetFrac(f.counter, f.target))
+			}
+			if f.mid != nil {
+				fj.MidPrice = bt.displayPrice(r, f.mid)
+			}
+			sj.Trades = append(sj.Trades, fj)
+		}
+		out.Strategies = append(out.Strategies, sj)
+	}
+	return out
+}
+
+func runBacktestCommand(args []string) error {
+	fs := flag.NewFlagSet("backtest", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: backtest [flags] <history file> -strategy <kind:spec> [-strategy ...]\n")
+		fs.PrintDefaults()
+	}
+	nf := addNetworkFlags(fs)
+	var (
+		poolTarget   = fs.String("pool", "", "Pool the history is of, address or pair, needed for a CSV export (a price stream recording names its pool)")
+		maxImpactPct = fs.Float64("max-impact", 1, "Skip a fill when its price impact (including the trade fee) is above this percentage")
+		asJSON       = fs.Bool("json", false, "Print the results and every simulated trade as JSON")
+		specs        []string
+	)
+	fs.Func("strategy", "A strategy to replay, limit:<order>, dca:<intent> on <schedule> or twap:<intent> over <window> every <interval>, repeatable", func(s string) error {
+		specs = append(specs, s)
+		return nil
+	})
+	addAmountFlags(fs)
+	// The history reads naturally first, `backtest ticks.jsonl -strategy ...`, flag stops at the first argument.
+	var path string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		path, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, nf.specs())
+	if path == "" && fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+	switch {
+	case path == "":
+		fs.Usage()
+		return errors.New("missing history file")
+	case len(specs) == 0:
+		fs.Usage()
+		return errors.New("missing -strategy, give at least one")
+	case *maxImpactPct <= 0:
+		return errors.New("max-impact must be greater than zero")
+	}
+	maxImpact, ok := new(big.Rat).SetString(fmt.Sprintf("%g", *maxImpactPct))
+	if !ok {
+		return fmt.Errorf("invalid max-impact %v", *maxImpactPct)
+	}
+	maxImpact.Quo(maxImpact, big.NewRat(100, 1))
+	strategies := make([]*backtestStrategy, len(specs))
+	for i, spec := range specs {
+		s, err := parseBacktestStrategy(spec)
+		if err != nil {
+			return err
+		}
+		strategies[i] = s
+	}
+	history, err := readReserveHistory(path)
+	if err != nil {
+		return err
+	}
+	target := *poolTarget
+	if target == "" {
+		target = history.pool
+	}
+	if target == "" {
+		return fmt.Errorf("%s doesn't say which pool it's of, pass -pool", path)
+	}
+
+	client := nf.connect()
+	ctx, stop := interruptContext()
+	defer stop()
+	quoteCtx, cancel := deadlines.forQuote(ctx)
+	poolAddr, err := resolvePoolTarget(quoteCtx, client, target, SymbolMapping{})
+	cancel()
+	if err != nil {
+		return err
+	}
+	if history.pool != "" && history.pool != poolAddr.String() {
+		return fmt.Errorf("%s is a history of pool %s, not %s", path, history.pool, poolAddr)
+	}
+	loaded, err := loadPool(ctx, client, poolAddr)
+	if err != nil {
+		return err
+	}
+	bt := &backtester{
+		venue:     loaded.venue(),
+		symm:      loaded.symbolsMap,
+		decimals:  [2]uint8{loaded.pool.Mint0Decimals, loaded.pool.Mint1Decimals},
+		history:   history,
+		maxImpact: maxImpact,
+	}
+	results := make([]*backtestResult, len(strategies))
+	for i, s := range strategies {
+		if results[i], err = bt.run(s); err != nil {
+			return err
+		}
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(backtestAsJSON(bt, results))
+	}
+	fmt.Print(renderBacktest(bt, results))
+	return nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+func TestReadReserveHistory(t *testing.T) {
+	ticks := `{"time":"2026-01-01T01:00:00Z","slot":2,"pool":"P","base":"SOL","quote":"USDC","baseReserve":{"raw":"1000000000000"},"quoteReserve":{"raw":"140000000000"}}
+
+{"time":"2026-01-01T00:00:00Z","slot":1,"pool":"P","base":"SOL","quote":"USDC","baseReserve":{"raw":"1000000000000"},"quoteReserve":{"raw":"150000000000"}}
+`
+	h, err := readPriceTicks(strings.NewReader(ticks))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.pool != "P" || len(h.states) != 2 || h.states[0].slot != 2 || h.states[1].reserves[1].Int64() != 150_000_000_000 {
+		t.Errorf("price ticks: %+v", h)
+	}
+	if _, err := readPriceTicks(strings.NewReader(strings.Replace(ticks, `"pool":"P","base":"SOL","quote":"USDC","baseReserve":{"raw":"1000000000000"},"quoteReserve":{"raw":"150000000000"}`, `"pool":"Q","baseReserve":{"raw":"1"},"quoteReserve":{"raw":"1"}`, 1))); err == nil {
+		t.Error("a history of two pools was read")
+	}
+
+	csvData := "slot,time,reserve0,reserve1\n7,1767225600,1000,150\n,2026-01-01T01:00:00Z,1000,140\n"
+	h, err = readReserveCSV(strings.NewReader(csvData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.states) != 2 || h.states[0].slot != 7 || !h.states[0].at.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) || h.states[1].reserves[1].Int64() != 140 {
+		t.Errorf("CSV: %+v", h.states)
+	}
+	for name, data := range map[string]string{
+		"no reserve1":  "time,reserve0\n1767225600,1000\n",
+		"decimal":      "time,reserve0,reserve1\n1767225600,10.5,150\n",
+		"unknown time": "time,reserve0,reserve1\nyesterday,1000,150\n",
+	} {
+		if _, err := readReserveCSV(strings.NewReader(data)); err == nil {
+			t.Errorf("%s: read without an error", name)
+		}
+	}
+}
+
+func TestParseBacktestStrategy(t *testing.T) {
+	s, err := parseBacktestStrategy("twap:sell 3 SOL over 3h every 1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.kind != backtestTWAP || s.twap.window != 3*time.Hour || s.twap.every != time.Hour || s.instruction.TargetSymbol != "SOL" {
+		t.Errorf("twap: %+v", s)
+	}
+	if s, err := parseBacktestStrategy("dca:pay 150 USDC on @every 1h"); err != nil || s.sched.String() != "@every 1h0m0s" {
+		t.Errorf("dca: %+v, %v", s, err)
+	}
+	if s, err := parseBacktestStrategy("limit:buy 1 SOL when price <= 141 USDC"); err != nil || s.cond.op != "<=" {
+		t.Errorf("limit: %+v, %v", s, err)
+	}
+	for _, spec := range []string{
+		"buy 1 SOL",
+		"grid:buy 1 SOL",
+		"dca:pay 150 USDC",
+		"dca:pay 10% SOL on @every 1h",
+		"twap:sell 3 SOL over 1h every 1h",
+		"twap:sell 3 SOL every 1h over 3h",
+	} {
+		if _, err := parseBacktestStrategy(spec); err == nil {
+			t.Errorf("%q parsed", spec)
+		}
+	}
+}
+
+// backtestPool is snapshotPool's pool (SOL/USDC) with a history whose mid-price goes 150, 140, 130 and 160, an hour
+// apart.
+func backtestPool(t *testing.T) *backtester {
+	t.Helper()
+	pool, address, _ := snapshotPool()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := &reserveHistory{}
+	for i, usdc := range []int64{150, 140, 130, 160} {
+		h.states = append(h.states, reserveState{at: start.Add(time.Duration(i) * time.Hour), reserves: [2]*big.Int{big.NewInt(1_000_000_000_000), big.NewInt(usdc * 1_000_000_000)}})
+	}
+	symm := SymbolMapping{
+		mintToSymbol: map[string]string{pool.Token0Mint.String(): "SOL", pool.Token1Mint.String(): "USDC"},
+		symbolToMint: map[string]solana.PublicKey{"SOL": pool.Token0Mint, "USDC": pool.Token1Mint},
+	}
+	return &backtester{
+		venue:     &cpSwapVenue{address: address, pool: pool, ammConfig: &raydium_cp_swap.AmmConfig{TradeFeeRate: 2500}},
+		symm:      symm,
+		decimals:  [2]uint8{9, 6},
+		history:   h,
+		maxImpact: big.NewRat(1, 100),
+	}
+}
+
+func mustBacktest(t *testing.T, bt *backtester, spec string) *backtestResult {
+	t.Helper()
+	res, err := bt.run(mustParse(t, spec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestBacktestRun(t *testing.T) {
+	bt := backtestPool(t)
+
+	// At 140 buying 1 SOL costs about 140.49 with the fee, so the order fills on the second state.
+	limit := mustBacktest(t, bt, "limit:buy 1 SOL when price <= 141 USDC")
+	if len(limit.fills) != 1 || !limit.fills[0].at.Equal(bt.history.states[1].at) || limit.fills[0].target.Int64() != 1_000_000_000 {
+		t.Fatalf("limit fills: %+v", limit.fills)
+	}
+	if got := bt.displayPrice(limit, limit.avgPrice()); got != "140.49137" {
+		t.Errorf("limit price %s", got)
+	}
+	if s := limit.slippage(); s == nil || s.Sign() <= 0 || s.Cmp(big.NewRat(1, 100)) > 0 {
+		t.Errorf("limit slippage %v, want a little worse than mid", s)
+	}
+	// Bought at about 140.49, marked at 160.
+	if pnl := ratInt(limit.pnl(bt.mark(limit))); pnl.Int64() != 19_508_630 {
+		t.Errorf("limit P&L %s", pnl)
+	}
+
+	// Due at 1h, 2h and 3h, the start isn't a run.
+	dca := mustBacktest(t, bt, "dca:pay 150 USDC on @every 1h")
+	if len(dca.fills) != 3 || dca.skipped != 0 || dca.target != 1 || !dca.selling {
+		t.Fatalf("dca: %+v", dca)
+	}
+	if target, counter, _ := dca.totals(); target.Int64() != 450_000_000 || counter.Sign() <= 0 {
+		t.Errorf("dca paid %s USDC for %s SOL", target, counter)
+	}
+
+	twap := mustBacktest(t, bt, "twap:sell 3 SOL over 3h every 1h")
+	if len(twap.fills) != 3 || !twap.fills[2].at.Equal(bt.history.states[2].at) {
+		t.Fatalf("twap fills: %+v", twap.fills)
+	}
+	// Sold into 150, 140 and 130 and marked at 160, holding would have been better.
+	if pnl := twap.pnl(bt.mark(twap)); pnl.Sign() >= 0 {
+		t.Errorf("twap P&L %v, want a loss", pnl)
+	}
+
+	if _, err := bt.run(mustParse(t, "twap:sell 3 SOL over 8h every 1h")); err == nil {
+		t.Error("a twap longer than the history ran")
+	}
+	if _, err := bt.run(mustParse(t, "dca:pay 1 BONK on @every 1h")); err == nil {
+		t.Error("a token the pool doesn't have ran")
+	}
+
+	// 100 SOL is 10% of the pool, far over the 1% impact cap.
+	if whale := mustBacktest(t, bt, "dca:pay 100 SOL on @every 1h"); len(whale.fills) != 0 || whale.skipped != 3 {
+		t.Errorf("over -max-impact: %d fills, %d skipped", len(whale.fills), whale.skipped)
+	}
+
+	results := []*backtestResult{limit, dca, twap}
+	if out := renderBacktest(bt, results); !strings.Contains(out, "140.49137 USDC") || !strings.Contains(out, "Replayed 4 states") {
+		t.Errorf("report:\n%s", out)
+	}
+	if js := backtestAsJSON(bt, results); len(js.Strategies) != 3 || js.Strategies[0].PnL.Raw != "19508630" || js.Strategies[0].MarkPrice != "160" || len(js.Strategies[2].Trades) != 3 {
+		t.Errorf("as JSON: %+v", js)
+	}
+}
+
+func mustParse(t *testing.T, spec string) *backtestStrategy {
+	t.Helper()
+	s, err := parseBacktestStrategy(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
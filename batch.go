@@ -0,0 +1,490 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"gopkg.in/yaml.v3"
+)
+
+/*
+NOTE(@hadydotai): Batch orders.
+
+`batch run orders.yaml` sends a list of intents in one go, each on its own pool with its own slippage and priority
+fee, instead of a shell loop around the swap flow that loads everything again for every line and stops making sense
+of the results after the third. The file looks like:
+
+	orders:
+	  - name: sol-to-usdc
+	    pool: 7JuwJuNU88gurFnyWeiyGKbFmExMWcmRZntn9imEzdny
+	    intent: pay 0.1 SOL
+	    slippage: 1
+	    fee-preset: turbo
+	  - pool: 2gMw5rZ1xVvWL5K2bXb1qJ4rA5zW6Gq6QXkM7BRmAYi3
+	    intent: sell 50% BONK
+	    cu-price: 20000
+
+An order's slippage, fee-preset, cu-limit and cu-price are optional, what it leaves out comes from the flags. Every
+order is checked before anything is sent, a typo in the fifth order fails the batch up front rather than after the
+first four went out.
+
+Orders run one at a time, in the file's order, unless -concurrency says more of them may be in flight at once. They
+still start in the file's order. Orders paying or receiving SOL go one at a time whatever -concurrency says, they
+share the wallet's wSOL account and one closing it under another would fail it (see wsol.go). Each order goes through
+the same checks a swap from the flow does (see executeIntentOnce) and a failed order doesn't stop the others, unless
+-stop-on-failure, then the orders that hadn't started are skipped.
+
+What happened to every order is printed as a table at the end and written to -out as JSON, and the command fails when
+any order did, so a script can tell. Sends are journaled in -journal, keyed by the order's name, so running the batch
+again after a crash settles what was in flight rather than sending it twice.
+
+-export-bundle sends nothing, it plans every order into one review bundle (see tx_bundle.go), an entry per order in
+the file's order, for executing as a whole once it's approved.
+*/
+
+type batchStatus string
+
+const (
+	batchFilled  batchStatus = "filled"
+	batchFailed  batchStatus = "failed"
+	batchSkipped batchStatus = "skipped"
+)
+
+// batchFile is what `batch run` reads.
+type batchFile struct {
+	Orders []batchOrder `yaml:"orders"`
+}
+
+// batchOrder is one intent of a batch, with what it overrides of the flags.
+type batchOrder struct {
+	Name      string   `yaml:"name"`
+	Pool      string   `yaml:"pool"`
+	Intent    string   `yaml:"intent"`
+	Slippage  *float64 `yaml:"slippage"`
+	FeePreset string   `yaml:"fee-preset"`
+	CULimit   uint32   `yaml:"cu-limit"`
+	CUPrice   *uint64  `yaml:"cu-price"`
+
+	pool solana.PublicKey
+}
+
+// batchResult is what happened to one order, as the -out file has it.
+type batchResult struct {
+	Order       string      `json:"order"`
+	Pool        string      `json:"pool"`
+	Intent      string      `json:"intent"`
+	Status      batchStatus `json:"status"`
+	Signature   string      `json:"signature,omitempty"`
+	Paid        *amountJSON `json:"paid,omitempty"`
+	PaidSymbol  string      `json:"paidSymbol,omitempty"`
+	Received    *amountJSON `json:"received,omitempty"`
+	RecvSymbol  string      `json:"receivedSymbol,omitempty"`
+	FeeLamports uint64      `json:"feeLamports,omitempty"`
+	Explorer    string      `json:"explorer,omitempty"`
+	Error       string      `json:"error,omitempty"`
+	Code        string      `json:"code,omitempty"`    // the error's, see error_codes.go
+	RetryOf     []string    `json:"retryOf,omitempty"` // earlier attempts at the order, see send_journal.go
+	Started     time.Time   `json:"started,omitzero"`
+	Elapsed     string      `json:"elapsed,omitempty"`
+}
+
+// loadBatchFile reads the orders at path and checks every one of them, orders without a name are named by their
+// place in the file.
+func loadBatchFile(path string) ([]batchOrder, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading batch %s: %w", path, err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	var file batchFile
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("batch %s: %w", path, err)
+	}
+	if len(file.Orders) == 0 {
+		return nil, fmt.Errorf("batch %s has no orders", path)
+	}
+	names := make(map[string]int, len(file.Orders))
+	for i := range file.Orders {
+		o := &file.Orders[i]
+		if o.Name = strings.TrimSpace(o.Name); o.Name == "" {
+			o.Name = fmt.Sprintf("#%d", i+1)
+		}
+		if first, ok := names[o.Name]; ok {
+			return nil, fmt.Errorf("batch %s: orders %d and %d are both named %q", path, first+1, i+1, o.Name)
+		}
+		names[o.Name] = i
+		if err := o.check(); err != nil {
+			return nil, fmt.Errorf("batch %s: order %s: %w", path, o.Name, err)
+		}
+	}
+	return file.Orders, nil
+}
+
+// check validates the order as far as it can without the chain.
+func (o *batchOrder) check() error {
+	if o.Pool == "" {
+		return errors.New("pool is missing")
+	}
+	pool, err := solana.PublicKeyFromBase58(o.Pool)
+	if err != nil {
+		return fmt.Errorf("pool %q isn't a base58 address: %w", o.Pool, err)
+	}
+	o.pool = pool
+	if o.Intent == "" {
+		return errors.New("intent is missing")
+	}
+	if _, err := parseIntent(o.Intent); err != nil {
+		return err
+	}
+	if o.Slippage != nil && (*o.Slippage < 0 || *o.Slippage > 100) {
+		return fmt.Errorf("slippage has to be between 0 and 100, got %g", *o.Slippage)
+	}
+	if o.FeePreset != "" {
+		if o.FeePreset, err = parseFeePreset(o.FeePreset); err != nil {
+			return err
+		}
+	}
+	if o.CULimit != 0 {
+		if err := checkUnitLimit(uint64(o.CULimit)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// budget is the compute budget the order pays on network, the flags' with the order's settings over them.
+func (o *batchOrder) budget(network string) feePreset {
+	p := computeBudget
+	if o.FeePreset != "" {
+		p.preset = o.FeePreset
+	}
+	if o.CULimit != 0 {
+		p.unitLimit = o.CULimit
+	}
+	if o.CUPrice != nil {
+		p.unitPrice = o.CUPrice
+	}
+	p.useNetwork(network)
+	return p.resolved
+}
+
+// runBatch runs orders through execute, at most concurrency at once, starting them in order. Once ctx is done, or an
+// order failed with stopOnFailure, the orders that haven't started are skipped.
+func runBatch(ctx context.Context, orders []batchOrder, concurrency int, stopOnFailure bool, execute func(batchOrder) batchResult) []batchResult {
+	results := make([]batchResult, len(orders))
+	sem := make(chan struct{}, max(concurrency, 1))
+	var (
+		wg     sync.WaitGroup
+		failed atomic.Bool
+	)
+	for i, o := range orders {
+		sem <- struct{}{}
+		if reason := batchSkipReason(ctx, stopOnFailure && failed.Load()); reason != "" {
+			<-sem
+			results[i] = batchResult{Order: o.Name, Pool: o.Pool, Intent: o.Intent, Status: batchSkipped, Error: reason}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = execute(o)
+			if results[i].Status == batchFailed {
+				failed.Store(true)
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func batchSkipReason(ctx context.Context, stopped bool) string {
+	switch {
+	case ctx.Err() != nil:
+		return "the batch was interrupted before it started"
+	case stopped:
+		return "an earlier order failed and -stop-on-failure is set"
+	}
+	return ""
+}
+
+type batchRunner struct {
+	ctx         context.Context
+	client      *rpc.Client
+	payer       solana.PrivateKey
+	network     string
+	slippagePct float64 // what orders without a slippage of their own use
+	journal     *sendJournal
+	receipts    string // receipts store to record fills in, empty for none
+
+	solMu sync.Mutex // held by orders paying or receiving SOL, see the note at the top
+}
+
+// builderFor loads o's pool and quotes on it with o's slippage and priority fee.
+func (br *batchRunner) builderFor(o batchOrder) (*TableBuilder, error) {
+	slippage := br.slippagePct
+	if o.Slippage != nil {
+		slippage = *o.Slippage
+	}
+	loaded, err := loadPool(br.ctx, br.client, o.pool)
+	if err != nil {
+		return nil, err
+	}
+	builder, err := newTableBuilder(br.ctx, br.client, loaded, slippage)
+	if err != nil {
+		return nil, err
+	}
+	builder.useWallet(br.payer.PublicKey())
+	builder.useComputeBudget(o.budget(br.network))
+	return builder, nil
+}
+
+// planBundle plans every order as an entry of one bundle, in the file's order, nothing is sent. An order that can't be
+// planned fails the whole bundle, a reviewer approves the batch as a whole.
+func (br *batchRunner) planBundle(orders []batchOrder) (txBundle, error) {
+	bundle := txBundle{
+		Version:   txBundleVersion,
+		CreatedAt: time.Now().UTC(),
+		Network:   br.network,
+		ProgramID: raydium_cp_swap.ProgramID.String(),
+		Payer:     br.payer.PublicKey().String(),
+	}
+	for _, o := range orders {
+		builder, err := br.builderFor(o)
+		if err != nil {
+			return txBundle{}, fmt.Errorf("order %s: %w", o.Name, err)
+		}
+		_, intent, err := builder.Build(o.Intent)
+		if err != nil {
+			return txBundle{}, fmt.Errorf("order %s: %w", o.Name, err)
+		}
+		plan, err := planSwap(br.ctx, br.client, br.payer.PublicKey(), intent)
+		if err != nil {
+			return txBundle{}, fmt.Errorf("order %s: %w", o.Name, err)
+		}
+		entry, err := newTxBundleEntry(plan, builder.symbols())
+		if err != nil {
+			return txBundle{}, fmt.Errorf("order %s: preparing bundle failed: %w", o.Name, err)
+		}
+		bundle.Entries = append(bundle.Entries, entry)
+	}
+	return bundle, nil
+}
+
+// execute quotes and sends one order.
+func (br *batchRunner) execute(o batchOrder) (res batchResult) {
+	res = batchResult{Order: o.Name, Pool: o.Pool, Intent: o.Intent, Status: batchFailed, Started: time.Now().UTC()}
+	defer func() { res.Elapsed = time.Since(res.Started).Round(time.Millisecond).String() }()
+	fail := func(err error) batchResult {
+		log.Printf("batch: %s failed: %v", o.Name, err)
+		res.Error, res.Code = err.Error(), errorCode(err)
+		return res
+	}
+	builder, err := br.builderFor(o)
+	if err != nil {
+		return fail(err)
+	}
+	if mints := builder.snapshot().venue.Mints(); isNativeSOL(mints[0]) || isNativeSOL(mints[1]) {
+		br.solMu.Lock()
+		defer br.solMu.Unlock()
+	}
+	_, intent, err := builder.Build(o.Intent)
+	if err != nil {
+		return fail(err)
+	}
+	res.Intent = intent.String()
+	guard := newSendGuard(br.journal, "batch "+o.Name)
+	log.Printf("batch: %s: sending %s on %s", o.Name, intent, Addr(o.Pool))
+	summary, sig, err := executeIntentOnce(br.ctx, br.client, br.payer, builder, intent, guard)
+	if !sig.IsZero() {
+		res.Signature, res.Explorer = sig.String(), explorerTxURL(br.network, sig)
+		res.RetryOf = guard.lineage(sig)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	guard.done()
+	res.Status = batchFilled
+	res.FeeLamports = summary.FeeLamports
+	res.PaidSymbol, res.RecvSymbol = summary.PaidSymbol, summary.ReceivedSymbol
+	if summary.PaidAmount != nil {
+		res.Paid = ptrTo(newAmountJSON(summary.PaidAmount, summary.PaidDecimals))
+	}
+	if summary.ReceivedAmount != nil {
+		res.Received = ptrTo(newAmountJSON(summary.ReceivedAmount, summary.ReceivedDecimals))
+	}
+	log.Printf("batch: %s: filled, %s", o.Name, sig)
+	if br.receipts != "" {
+		rcpt := newSwapReceipt("batch", o.Pool, res.Intent, summary, res.Explorer)
+		rcpt.RetryOf = res.RetryOf
+		if rerr := appendReceipt(br.receipts, rcpt); rerr != nil {
+			log.Printf("warning: recording the receipt failed: %v", rerr)
+		}
+	}
+	return res
+}
+
+func renderBatchSummary(results []batchResult) string {
+	builder := &strings.Builder{}
+	amount := func(a *amountJSON, symbol string) string {
+		if a == nil {
+			return ""
+		}
+		return a.String() + " " + symbol
+	}
+	counts := map[batchStatus]int{}
+	t := table.NewWriter()
+	t.SetOutputMirror(builder)
+	t.SetTitle("Batch Results")
+	t.AppendHeader(table.Row{"Order", "Intent", "Status", "Paid", "Received", "Signature / Error"})
+	for _, r := range results {
+		counts[r.Status]++
+		detail := r.Signature
+		if r.Error != "" {
+			detail = r.Error
+		}
+		t.AppendRow(table.Row{r.Order, r.Intent, strings.ToUpper(string(r.Status)), amount(r.Paid, r.PaidSymbol), amount(r.Received, r.RecvSymbol), detail})
+	}
+	t.AppendFooter(table.Row{"", "", fmt.Sprintf("%d filled, %d failed, %d skipped", counts[batchFilled], counts[batchFailed], counts[batchSkipped])})
+	t.Render()
+	return builder.String()
+}
+
+// writeBatchResults writes results to path as JSON, next to it first and renamed into place.
+func writeBatchResults(path string, results []batchResult) error {
+	raw, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("writing batch results: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(raw, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing batch results: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing batch results: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func runBatchCommand(args []string) error {
+	return dispatchSubcommand("batch", map[string]func([]string) error{
+		"run": runBatchRunCommand,
+	}, args)
+}
+
+func runBatchRunCommand(args []string) error {
+	fs := flag.NewFlagSet("batch run", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: raydium-client batch run [flags] orders.yaml\n")
+		fs.PrintDefaults()
+	}
+	nf := addNetworkFlags(fs)
+	wf := addWebhookFlags(fs)
+	addCloseEmptyATAsFlag(fs)
+	addTradeHookFlags(fs)
+	addMintPolicyFlags(fs)
+	addSpendLimitFlags(fs)
+	addApprovalFlags(fs)
+	addRebroadcastFlags(fs)
+	addMinOutFromSimFlag(fs)
+	addComputeBudgetFlags(fs)
+	var (
+		hotwalletPath = fs.String("hotwallet", "", "Path to the hotwallet to use for signing transactions")
+		slippagePct   = fs.Float64("slippage", 0.5, "Slippage tolerance percentage for orders that don't set their own")
+		concurrency   = fs.Int("concurrency", 1, "How many orders may be in flight at once, 1 runs them one after another")
+		stopOnFailure = fs.Bool("stop-on-failure", false, "Skip the orders that haven't started once one fails")
+		outPath       = fs.String("out", "batch-results.json", "File the result of every order is written to (JSON)")
+		receiptsPath  = fs.String("receipts", "", "Append every fill to this receipts file (JSON lines)")
+		journalPath   = fs.String("journal", "", "Journal every send to this file, running the batch again settles what's still in flight instead of sending again (kept in memory when empty)")
+		exportBundle  = fs.String("export-bundle", "", "Plan every order into one reviewable JSON bundle at this path instead of sending them")
+	)
+	addAmountFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, append(nf.specs(),
+		FlagSpec{Name: "hotwallet", Value: hotwalletPath, Rules: []FlagRule{NotEmpty()}},
+		FlagSpec{Name: "out", Value: outPath, Rules: []FlagRule{NotEmpty()}},
+	))
+	if fs.NArg() != 1 {
+		return errors.New("batch run takes the orders file, e.g. `batch run orders.yaml`")
+	}
+	if *concurrency < 1 {
+		return errors.New("concurrency has to be at least 1")
+	}
+	orders, err := loadBatchFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	journal, err := openSendJournal(*journalPath)
+	if err != nil {
+		return err
+	}
+	payer, err := solana.PrivateKeyFromSolanaKeygenFile(*hotwalletPath)
+	if err != nil {
+		return fmt.Errorf("failed to load private key from hot wallet: %w", err)
+	}
+	flushWebhooks, err := wf.start("batch", *nf.network)
+	if err != nil {
+		return err
+	}
+	defer flushWebhooks()
+	client := nf.connect()
+	ctx, stop := interruptContext()
+	defer stop()
+	runner := &batchRunner{
+		ctx:         ctx,
+		client:      client,
+		payer:       payer,
+		network:     *nf.network,
+		slippagePct: *slippagePct,
+		journal:     journal,
+		receipts:    *receiptsPath,
+	}
+	if *exportBundle != "" {
+		bundle, err := runner.planBundle(orders)
+		if err != nil {
+			return err
+		}
+		log.Printf("batch: %d orders planned", len(bundle.Entries))
+		return exportTxBundle(ctx, *exportBundle, bundle, payer, os.Stdout)
+	}
+	log.Printf("batch: %d orders, %d at a time", len(orders), *concurrency)
+	results := runBatch(ctx, orders, *concurrency, *stopOnFailure, runner.execute)
+	fmt.Fprint(os.Stdout, renderBatchSummary(results))
+	if err := writeBatchResults(*outPath, results); err != nil {
+		return err
+	}
+	failed := 0
+	for _, r := range results {
+		if r.Status != batchFilled {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d orders didn't fill, see %s", failed, len(results), *outPath)
+	}
+	return nil
+}
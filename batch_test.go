@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const testBatchPool = "7JuwJuNU88gurFnyWeiyGKbFmExMWcmRZntn9imEzdny"
+
+func writeBatchFile(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "orders.yaml")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadBatchFile(t *testing.T) {
+	path := writeBatchFile(t, `
+orders:
+  - name: first
+    pool: `+testBatchPool+`
+    intent: pay 0.1 SOL
+    slippage: 1
+    fee-preset: Turbo
+  - pool: `+testBatchPool+`
+    intent: sell 50% BONK
+    cu-limit: 300000
+    cu-price: 0
+`)
+	orders, err := loadBatchFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 2 {
+		t.Fatalf("got %d orders, want 2", len(orders))
+	}
+	first, second := orders[0], orders[1]
+	if first.Name != "first" || first.Slippage == nil || *first.Slippage != 1 || first.FeePreset != "turbo" {
+		t.Errorf("first order %+v", first)
+	}
+	if first.pool.String() != testBatchPool {
+		t.Errorf("pool %s, want %s", first.pool, testBatchPool)
+	}
+	if second.Name != "#2" || second.Slippage != nil || second.CULimit != 300_000 || second.CUPrice == nil || *second.CUPrice != 0 {
+		t.Errorf("second order %+v", second)
+	}
+}
+
+func TestLoadBatchFileRejected(t *testing.T) {
+	for _, tc := range []struct {
+		name, body, want string
+	}{
+		{"no orders", "orders: []\n", "no orders"},
+		{"unknown field", "orders:\n  - pool: " + testBatchPool + "\n    intent: pay 1 SOL\n    slipage: 1\n", "slipage"},
+		{"no pool", "orders:\n  - intent: pay 1 SOL\n", "pool is missing"},
+		{"bad pool", "orders:\n  - pool: nope\n    intent: pay 1 SOL\n", "base58"},
+		{"no intent", "orders:\n  - pool: " + testBatchPool + "\n", "intent is missing"},
+		{"bad intent", "orders:\n  - pool: " + testBatchPool + "\n    intent: give me SOL\n", "order #1"},
+		{"bad slippage", "orders:\n  - pool: " + testBatchPool + "\n    intent: pay 1 SOL\n    slippage: 101\n", "slippage"},
+		{"bad preset", "orders:\n  - pool: " + testBatchPool + "\n    intent: pay 1 SOL\n    fee-preset: ludicrous\n", "fee preset"},
+		{"bad limit", "orders:\n  - pool: " + testBatchPool + "\n    intent: pay 1 SOL\n    cu-limit: 1400001\n", "compute unit limit"},
+		{"same name", "orders:\n  - name: a\n    pool: " + testBatchPool + "\n    intent: pay 1 SOL\n  - name: a\n    pool: " + testBatchPool + "\n    intent: pay 2 SOL\n", "both named"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := loadBatchFile(writeBatchFile(t, tc.body))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("error %v, want it to mention %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestBatchOrderBudget(t *testing.T) {
+	if err := parseComputeBudgetFlags(t, "-cu-price", "5000"); err != nil {
+		t.Fatal(err)
+	}
+	price := uint64(0)
+	for _, tc := range []struct {
+		name  string
+		order batchOrder
+		want  feePreset
+	}{
+		{"the flags'", batchOrder{}, feePreset{unitLimit: 400_000, unitPrice: 5_000}},
+		{"its own price", batchOrder{CUPrice: &price}, feePreset{unitLimit: 400_000, unitPrice: 0}},
+		{"its own limit", batchOrder{CULimit: 250_000}, feePreset{unitLimit: 250_000, unitPrice: 5_000}},
+		{"its own preset, the flag's price over it", batchOrder{FeePreset: "turbo"}, feePreset{unitLimit: 400_000, unitPrice: 5_000}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.order.budget("mainnet"); got != tc.want {
+				t.Fatalf("budget %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+	if computeBudget.unitLimit != 0 || *computeBudget.unitPrice != 5_000 {
+		t.Errorf("an order's budget changed the flags' %+v", computeBudget)
+	}
+}
+
+func testBatchOrders(n int) []batchOrder {
+	orders := make([]batchOrder, n)
+	for i := range orders {
+		orders[i] = batchOrder{Name: fmt.Sprintf("#%d", i+1), Pool: testBatchPool, Intent: "pay 1 SOL"}
+	}
+	return orders
+}
+
+func TestRunBatchConcurrency(t *testing.T) {
+	for _, concurrency := range []int{1, 3} {
+		t.Run(fmt.Sprint(concurrency), func(t *testing.T) {
+			var (
+				inFlight, most atomic.Int32
+				mu             sync.Mutex
+				started        []string
+			)
+			results := runBatch(context.Background(), testBatchOrders(8), concurrency, false, func(o batchOrder) batchResult {
+				mu.Lock()
+				started = append(started, o.Name)
+				mu.Unlock()
+				n := inFlight.Add(1)
+				for {
+					m := most.Load()
+					if n <= m || most.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				inFlight.Add(-1)
+				return batchResult{Order: o.Name, Status: batchFilled}
+			})
+			if got := most.Load(); got != int32(concurrency) {
+				t.Errorf("%d orders in flight at most, want %d", got, concurrency)
+			}
+			for i, r := range results {
+				if want := fmt.Sprintf("#%d", i+1); r.Order != want || r.Status != batchFilled {
+					t.Errorf("result %d is %+v, want %s filled", i, r, want)
+				}
+			}
+			if concurrency == 1 && strings.Join(started, " ") != "#1 #2 #3 #4 #5 #6 #7 #8" {
+				t.Errorf("started in the order %v", started)
+			}
+		})
+	}
+}
+
+func TestRunBatchStopOnFailure(t *testing.T) {
+	execute := func(o batchOrder) batchResult {
+		if o.Name == "#2" {
+			return batchResult{Order: o.Name, Status: batchFailed, Error: "boom"}
+		}
+		return batchResult{Order: o.Name, Status: batchFilled}
+	}
+	statuses := func(results []batchResult) string {
+		var s []string
+		for _, r := range results {
+			s = append(s, string(r.Status))
+		}
+		return strings.Join(s, " ")
+	}
+	if got := statuses(runBatch(context.Background(), testBatchOrders(4), 1, false, execute)); got != "filled failed filled filled" {
+		t.Errorf("without -stop-on-failure: %s", got)
+	}
+	if got := statuses(runBatch(context.Background(), testBatchOrders(4), 1, true, execute)); got != "filled failed skipped skipped" {
+		t.Errorf("with -stop-on-failure: %s", got)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got := statuses(runBatch(ctx, testBatchOrders(2), 1, false, execute)); got != "skipped skipped" {
+		t.Errorf("interrupted: %s", got)
+	}
+}
+
+func TestBatchResultsOut(t *testing.T) {
+	results := []batchResult{
+		{Order: "first", Intent: "pay 0.1 SOL", Status: batchFilled, Signature: "sig1",
+			Paid: ptrTo(newAmountJSON(big.NewInt(100_000_000), 9)), PaidSymbol: "SOL",
+			Received: ptrTo(newAmountJSON(big.NewInt(15_000_000), 6)), RecvSymbol: "USDC"},
+		{Order: "second", Intent: "sell 50% BONK", Status: batchFailed, Error: "the quote moved"},
+		{Order: "third", Intent: "pay 1 SOL", Status: batchSkipped, Error: "an earlier order failed"},
+	}
+	out := renderBatchSummary(results)
+	for _, want := range []string{"Batch Results", "0.100000000 SOL", "15.000000 USDC", "FAILED", "the quote moved", "1 FILLED, 1 FAILED, 1 SKIPPED"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("summary is missing %q:\n%s", want, out)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "results.json")
+	if err := writeBatchResults(path, results); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []batchResult
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0].Paid.String() != "0.100000000" || got[1].Error != "the quote moved" || got[2].Status != batchSkipped {
+		t.Errorf("results read back as %+v", got)
+	}
+}
+
+func TestBatchBudgetOnIntent(t *testing.T) {
+	tb, _, _ := percentBuilder(t, 0, 0)
+	_, intent, err := tb.Build("pay 1 SOL")
+	if err != nil || intent == nil {
+		t.Fatalf("intent %v, err %v", intent, err)
+	}
+	if intent.ComputeBudget != nil {
+		t.Errorf("an intent without a budget of its own got %+v", *intent.ComputeBudget)
+	}
+	want := feePreset{unitLimit: 250_000, unitPrice: 7}
+	tb.useComputeBudget(want)
+	if _, intent, err = tb.Build("pay 1 SOL"); err != nil || intent == nil {
+		t.Fatalf("intent %v, err %v", intent, err)
+	}
+	if intent.ComputeBudget == nil || *intent.ComputeBudget != want {
+		t.Errorf("the order's budget didn't make it onto the intent: %v", intent.ComputeBudget)
+	}
+}
+
+func TestBatchPlanBundle(t *testing.T) {
+	endpoint, addr := snapshotChain(t, &atomic.Int64{})
+	payer := solana.NewWallet().PrivateKey
+	runner := &batchRunner{ctx: context.Background(), client: rpc.New(endpoint), payer: payer, network: "devnet", slippagePct: 0.5}
+	orders := []batchOrder{
+		{Name: "first", Pool: addr.String(), Intent: "sell 10 USDC", pool: addr},
+		{Name: "second", Pool: addr.String(), Intent: "sell 20 USDC", pool: addr},
+	}
+	bundle, err := runner.planBundle(orders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "batch.json")
+	hash, err := writeTxBundle(path, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	approved, err := readTxBundle(path, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(approved.Entries) != 2 || approved.Entries[0].Intent != "sell 10 USDC" || approved.Entries[1].Intent != "sell 20 USDC" {
+		t.Fatalf("entries %+v", approved.Entries)
+	}
+	if approved.Payer != payer.PublicKey().String() || approved.ProgramID != raydium_cp_swap.ProgramID.String() {
+		t.Errorf("bundle for %s on %s", approved.Payer, approved.ProgramID)
+	}
+
+	orders = append(orders, batchOrder{Name: "unknown", Pool: addr.String(), Intent: "sell 1 BONK", pool: addr})
+	if _, err := runner.planBundle(orders); err == nil || !strings.Contains(err.Error(), "order unknown") {
+		t.Errorf("an order that can't be planned: %v", err)
+	}
+}
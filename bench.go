@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+/*
+NOTE(@hadydotai): Benchmarking the quote path.
+
+`bench quote -pools pools.txt -iterations 20` loads every pool in the file once, then quotes each of them -iterations
+times, -concurrency at a time, the way -watch and the HTTP API re-quote: read the reserves, run the curve, build the
+report. Every RPC call underneath is timed on its way through the transport (rpcFixtureFlags.wrap). What comes out:
+
+  - quotes per second over the run and the quote latency distribution (p50, p90, p99, max)
+  - the RPC latency distribution per method, so a slow quote can be told apart from a slow node
+  - failures, counted, a failed quote still took its time and is in the distribution
+
+Loading the pools isn't part of it, it's timed on its own and the RPC timings start over once it's done. The pools
+file is a pool (or pair) per line and the intent to quote on it, -intent for lines without one:
+
+	# pool                                       intent
+	7JuwJuNU88gurFnyWeiyGKbFmExMWcmRZntn9imEzdny sell 1 SOL
+	SOL/USDC
+
+-pprof <addr> serves net/http/pprof's handlers at /debug/pprof/ for as long as the bench runs, `go tool pprof` CPU and
+heap profiles of the quoting path come from there. They're on a mux of their own, nothing else we serve gets them.
+With -rpc-replay the node is out of the picture and what's measured is our own code.
+*/
+
+// benchPool is a line of the pools file.
+type benchPool struct {
+	target string
+	intent string
+}
+
+func loadBenchPools(path, defaultIntent string) ([]benchPool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading bench pools: %w", err)
+	}
+	defer f.Close()
+	var pools []benchPool
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		p := benchPool{target: fields[0], intent: strings.Join(fields[1:], " ")}
+		if p.intent == "" {
+			p.intent = defaultIntent
+		}
+		if p.intent == "" {
+			return nil, fmt.Errorf("bench pools %s line %d: %s has no intent and there's no -intent", path, n, p.target)
+		}
+		pools = append(pools, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading bench pools: %w", err)
+	}
+	if len(pools) == 0 {
+		return nil, fmt.Errorf("bench pools %s lists no pools", path)
+	}
+	return pools, nil
+}
+
+// latencyStats is a distribution of latencies.
+type latencyStats struct {
+	count              int
+	p50, p90, p99, max time.Duration
+}
+
+func newLatencyStats(samples []time.Duration) latencyStats {
+	if len(samples) == 0 {
+		return latencyStats{}
+	}
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	// Nearest rank, the smallest sample at least p percent of them are at or below.
+	rank := func(p int) time.Duration {
+		i := (p*len(sorted)+99)/100 - 1
+		return sorted[max(i, 0)]
+	}
+	return latencyStats{count: len(sorted), p50: rank(50), p90: rank(90), p99: rank(99), max: sorted[len(sorted)-1]}
+}
+
+// rpcTimings times every call made through the transports it wraps, by method.
+type rpcTimings struct {
+	mu       sync.Mutex
+	byMethod map[string][]time.Duration
+	failed   map[string]int
+}
+
+func newRPCTimings() *rpcTimings {
+	return &rpcTimings{byMethod: map[string][]time.Duration{}, failed: map[string]int{}}
+}
+
+func (rt *rpcTimings) wrap(next rpc.JSONRPCClient) rpc.JSONRPCClient {
+	return &timedTransport{next: next, timings: rt}
+}
+
+func (rt *rpcTimings) add(method string, took time.Duration, err error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.byMethod[method] = append(rt.byMethod[method], took)
+	if err != nil {
+		rt.failed[method]++
+	}
+}
+
+// reset forgets every call timed so far.
+func (rt *rpcTimings) reset() {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.byMethod, rt.failed = map[string][]time.Duration{}, map[string]int{}
+}
+
+// rpcMethodStats is a method's calls, how many failed and how long they took.
+type rpcMethodStats struct {
+	method string
+	failed int
+	latencyStats
+}
+
+func (rt *rpcTimings) stats() []rpcMethodStats {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	stats := make([]rpcMethodStats, 0, len(rt.byMethod))
+	for method, samples := range rt.byMethod {
+		stats = append(stats, rpcMethodStats{method: method, failed: rt.failed[method], latencyStats: newLatencyStats(samples)})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].method < stats[j].method })
+	return stats
+}
+
+// timedTransport passes every call through to next and times it.
+type timedTransport struct {
+	next    rpc.JSONRPCClient
+	timings *rpcTimings
+}
+
+func (tt *timedTransport) CallForInto(ctx context.Context, out any, method string, params []any) error {
+	start := time.Now()
+	err := tt.next.CallForInto(ctx, out, method, params)
+	tt.timings.add(method, time.Since(start), err)
+	return err
+}
+
+func (tt *timedTransport) CallWithCallback(ctx context.Context, method string, params []any, callback func(*http.Request, *http.Response) error) error {
+	start := time.Now()
+	err := tt.next.CallWithCallback(ctx, method, params, callback)
+	tt.timings.add(method, time.Since(start), err)
+	return err
+}
+
+func (tt *timedTransport) CallBatch(ctx context.Context, requests jsonrpc.RPCRequests) (jsonrpc.RPCResponses, error) {
+	start := time.Now()
+	res, err := tt.next.CallBatch(ctx, requests)
+	tt.timings.add("batch", time.Since(start), err)
+	return res, err
+}
+
+// benchTarget is a loaded pool and the intent to quote on it.
+type benchTarget struct {
+	name    string
+	intent  string
+	builder *TableBuilder
+}
+
+// quoteBench is what a bench run measured.
+type quoteBench struct {
+	quotes  latencyStats
+	failed  int
+	elapsed time.Duration
+	errs    map[string]string // the first failure by pool
+}
+
+// runQuoteBench quotes every target iterations times, concurrency quotes at a time, until it's done or ctx is.
+func runQuoteBench(ctx context.Context, targets []benchTarget, iterations, concurrency int) quoteBench {
+	jobs := make(chan benchTarget)
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+		res       = quoteBench{errs: map[string]string{}}
+		wg        sync.WaitGroup
+	)
+	start := time.Now()
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range jobs {
+				began := time.Now()
+				_, err := quoteOnce(t.builder, t.intent)
+				took := time.Since(began)
+				mu.Lock()
+				latencies = append(latencies, took)
+				if err != nil {
+					res.failed++
+					if _, ok := res.errs[t.name]; !ok {
+						res.errs[t.name] = err.Error()
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+feed:
+	for range iterations {
+		for _, t := range targets {
+			select {
+			case jobs <- t:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	res.elapsed = time.Since(start)
+	res.quotes = newLatencyStats(latencies)
+	return res
+}
+
+func renderQuoteBench(res quoteBench, rpcStats []rpcMethodStats, pools, iterations, concurrency int, loading time.Duration) string {
+	builder := &strings.Builder{}
+	ms := func(d time.Duration) string { return fmt.Sprintf("%.1fms", float64(d.Microseconds())/1000) }
+	rate := 0.0
+	if res.elapsed > 0 {
+		rate = float64(res.quotes.count) / res.elapsed.Seconds()
+	}
+	t := table.NewWriter()
+	t.SetOutputMirror(builder)
+	t.SetTitle(fmt.Sprintf("Quote Bench (%d pools x %d iterations, %d at a time, loaded in %s)", pools, iterations, concurrency, loading.Round(time.Millisecond)))
+	t.AppendHeader(table.Row{"Quotes", "Failed", "Elapsed", "Quotes/s", "p50", "p90", "p99", "Max"})
+	t.AppendRow(table.Row{res.quotes.count, res.failed, res.elapsed.Round(time.Millisecond), fmt.Sprintf("%.1f", rate), ms(res.quotes.p50), ms(res.quotes.p90), ms(res.quotes.p99), ms(res.quotes.max)})
+	t.Render()
+
+	r := table.NewWriter()
+	r.SetOutputMirror(builder)
+	r.SetTitle("RPC Latency")
+	r.AppendHeader(table.Row{"Method", "Calls", "Failed", "p50", "p90", "p99", "Max"})
+	for _, s := range rpcStats {
+		r.AppendRow(table.Row{s.method, s.count, s.failed, ms(s.p50), ms(s.p90), ms(s.p99), ms(s.max)})
+	}
+	r.Render()
+
+	if len(res.errs) > 0 {
+		names := make([]string, 0, len(res.errs))
+		for name := range res.errs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(builder, "%s: %s\n", name, res.errs[name])
+		}
+	}
+	return builder.String()
+}
+
+// servePprof serves net/http/pprof on addr until the returned stop is called.
+func servePprof(addr string) (stop func(), err error) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("pprof can't listen on %s: %w", addr, err)
+	}
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("warning: pprof stopped: %v", err)
+		}
+	}()
+	log.Printf("pprof on http://%s/debug/pprof/", ln.Addr())
+	return func() { srv.Close() }, nil
+}
+
+func runBenchCommand(args []string) error {
+	return dispatchSubcommand("bench", map[string]func([]string) error{
+		"quote": runBenchQuoteCommand,
+	}, args)
+}
+
+func runBenchQuoteCommand(args []string) error {
+	fs := flag.NewFlagSet("bench quote", flag.ExitOnError)
+	nf := addNetworkFlags(fs)
+	var (
+		poolsPath   = fs.String("pools", "", "File of pools (or pairs) to quote, one per line with its intent")
+		intentLine  = fs.String("intent", "", "Intent to quote on pools whose line doesn't have one")
+		iterations  = fs.Int("iterations", 10, "How many times every pool is quoted")
+		concurrency = fs.Int("concurrency", 1, "How many quotes may be in flight at once")
+		slippagePct = fs.Float64("slippage", 0.5, "Slippage tolerance percentage the quotes are built with")
+		pprofAddr   = fs.String("pprof", "", "Serve net/http/pprof on this address (e.g. localhost:6060) while the bench runs")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, append(nf.specs(),
+		FlagSpec{Name: "pools", Value: poolsPath, Rules: []FlagRule{NotEmpty()}},
+	))
+	if *iterations < 1 || *concurrency < 1 {
+		return errors.New("-iterations and -concurrency have to be at least 1")
+	}
+	pools, err := loadBenchPools(*poolsPath, *intentLine)
+	if err != nil {
+		return err
+	}
+	if *pprofAddr != "" {
+		stop, err := servePprof(*pprofAddr)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+
+	timings := newRPCTimings()
+	nf.fixtures.wrap = timings.wrap
+	client := nf.connect()
+	ctx, stop := interruptContext()
+	defer stop()
+
+	flow := &swapFlow{client: client, network: *nf.network, slippagePct: *slippagePct, out: os.Stdout}
+	targets := make([]benchTarget, 0, len(pools))
+	loadStart := time.Now()
+	for _, p := range pools {
+		builder, _, err := flow.openPool(ctx, p.target)
+		if err != nil {
+			return fmt.Errorf("loading %s: %w", p.target, err)
+		}
+		builder.cache = nil // every quote timed from scratch
+		targets = append(targets, benchTarget{name: p.target, intent: p.intent, builder: builder})
+	}
+	loading := time.Since(loadStart)
+	timings.reset()
+
+	log.Printf("bench: %d pools, %d iterations, %d at a time", len(targets), *iterations, *concurrency)
+	res := runQuoteBench(ctx, targets, *iterations, *concurrency)
+	fmt.Fprint(os.Stdout, renderQuoteBench(res, timings.stats(), len(targets), *iterations, *concurrency, loading))
+	return nil
+}
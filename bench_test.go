@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+func TestLoadBenchPools(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pools.txt")
+	if err := os.WriteFile(path, []byte("# pool intent\n\nAAA sell 1 SOL\nSOL/USDC\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pools, err := loadBenchPools(path, "buy 5 USDC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pools) != 2 || pools[0] != (benchPool{"AAA", "sell 1 SOL"}) || pools[1] != (benchPool{"SOL/USDC", "buy 5 USDC"}) {
+		t.Errorf("pools %+v", pools)
+	}
+	if _, err := loadBenchPools(path, ""); err == nil || !strings.Contains(err.Error(), "line 4") {
+		t.Errorf("a line without an intent and no -intent: %v", err)
+	}
+}
+
+func TestLatencyStats(t *testing.T) {
+	var samples []time.Duration
+	for i := 100; i >= 1; i-- {
+		samples = append(samples, time.Duration(i)*time.Millisecond)
+	}
+	s := newLatencyStats(samples)
+	if s.count != 100 || s.p50 != 50*time.Millisecond || s.p90 != 90*time.Millisecond || s.p99 != 99*time.Millisecond || s.max != 100*time.Millisecond {
+		t.Errorf("stats %+v", s)
+	}
+	if one := newLatencyStats([]time.Duration{time.Second}); one.p50 != time.Second || one.p99 != time.Second {
+		t.Errorf("one sample %+v", one)
+	}
+	if none := newLatencyStats(nil); none.count != 0 {
+		t.Errorf("no samples %+v", none)
+	}
+}
+
+func TestRunQuoteBench(t *testing.T) {
+	endpoint, addr := snapshotChain(t, &atomic.Int64{})
+	timings := newRPCTimings()
+	client := rpc.NewWithCustomRPCClient(timings.wrap(jsonrpc.NewClient(endpoint)))
+	flow := &swapFlow{client: client, network: "devnet", slippagePct: 0.5, out: &bytes.Buffer{}}
+	builder, _, err := flow.openPool(context.Background(), addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder.cache = nil
+	timings.reset()
+	targets := []benchTarget{
+		{name: "good", intent: "sell 1 SOL", builder: builder},
+		{name: "bad", intent: "sell 1 BONK", builder: builder},
+	}
+	res := runQuoteBench(context.Background(), targets, 3, 2)
+	if res.quotes.count != 6 || res.failed != 3 {
+		t.Errorf("%d quotes, %d failed, want 6 and 3", res.quotes.count, res.failed)
+	}
+	if _, ok := res.errs["bad"]; !ok || len(res.errs) != 1 {
+		t.Errorf("errors %v", res.errs)
+	}
+	stats := timings.stats()
+	if len(stats) == 0 {
+		t.Fatal("no rpc calls were timed")
+	}
+	calls := 0
+	for _, s := range stats {
+		calls += s.count
+	}
+	if calls == 0 || calls%3 != 0 {
+		t.Errorf("%d rpc calls timed for 3 good quotes", calls)
+	}
+	out := renderQuoteBench(res, stats, len(targets), 3, 2, time.Second)
+	if !strings.Contains(out, "RPC Latency") || !strings.Contains(out, "bad: ") {
+		t.Errorf("rendered:\n%s", out)
+	}
+}
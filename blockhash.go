@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): Blockhashes.
+
+A transaction is only good for as long as its blockhash is, 150 blocks, about a minute. Two things used to eat into
+that. The blockhash was asked for at finalized commitment, which is already ~32 slots behind the tip by the time it's
+handed back, and whether it had expired was asked of isBlockhashValid, a yes or no with nothing to say how close we
+were. Now every transaction is signed on a blockhash fetched right then, at confirmed commitment, and the
+lastValidBlockHeight that comes with it is kept, so expiry is the chain's block height passing it. It's never judged
+by the local clock, a machine whose clock is off by a minute would otherwise call a live transaction dead, or the
+other way around.
+
+Every send then settles (see settleAttempt and landTransaction): a transaction whose block height passed with it
+verifiably not landed comes back as an expiredTxError, "expired before landing", instead of a swap that sat pending
+until its deadline ran out. One rebuild on a fresh blockhash is on by default, -rebuild-expired 0 turns it off.
+
+A blockhash the manager didn't hand out (one read back out of the send journal from an older run) is checked with
+isBlockhashValid like before.
+*/
+
+// maxTrackedBlockhashes bounds the blockhashes kept, the oldest go first. Each send uses one, a long running engine
+// would otherwise keep every one it ever used.
+const maxTrackedBlockhashes = 256
+
+// blockhashManager hands out fresh blockhashes and remembers until which block height each is good for.
+type blockhashManager struct {
+	mu        sync.Mutex
+	lastValid map[solana.Hash]uint64
+	order     []solana.Hash
+}
+
+// blockhashes is every blockhash the process signed with.
+var blockhashes = &blockhashManager{lastValid: map[solana.Hash]uint64{}}
+
+// latest fetches the blockhash to sign with, it's meant to be called right before signing.
+func (m *blockhashManager) latest(ctx context.Context, client *rpc.Client) (solana.Hash, error) {
+	recent, err := client.GetLatestBlockhash(ctx, rpc.CommitmentConfirmed)
+	if err != nil {
+		return solana.Hash{}, fmt.Errorf("rpc call getLatestBlockhash failed: %w", err)
+	}
+	m.track(recent.Value.Blockhash, recent.Value.LastValidBlockHeight)
+	return recent.Value.Blockhash, nil
+}
+
+// track remembers hash as good through block height lastValid, zero is unknown.
+func (m *blockhashManager) track(hash solana.Hash, lastValid uint64) {
+	if lastValid == 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.lastValid[hash]; !ok {
+		m.order = append(m.order, hash)
+	}
+	m.lastValid[hash] = lastValid
+	for len(m.order) > maxTrackedBlockhashes {
+		delete(m.lastValid, m.order[0])
+		m.order = m.order[1:]
+	}
+}
+
+// validThrough is the last block height hash is good for, zero when it isn't one we know.
+func (m *blockhashManager) validThrough(hash solana.Hash) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastValid[hash]
+}
+
+// expired is whether nothing signed on hash can land any more.
+func (m *blockhashManager) expired(ctx context.Context, client *rpc.Client, hash solana.Hash) (bool, error) {
+	lastValid := m.validThrough(hash)
+	if lastValid == 0 {
+		valid, err := client.IsBlockhashValid(ctx, hash, rpc.CommitmentProcessed)
+		if err != nil {
+			return false, fmt.Errorf("rpc call isBlockhashValid failed: %w", err)
+		}
+		return !valid.Value, nil
+	}
+	height, err := client.GetBlockHeight(ctx, rpc.CommitmentProcessed)
+	if err != nil {
+		return false, fmt.Errorf("rpc call getBlockHeight failed: %w", err)
+	}
+	return height > lastValid, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+func TestBlockhashManager(t *testing.T) {
+	ls, client := newLeaderServer(t, 1, true)
+	m := &blockhashManager{lastValid: map[solana.Hash]uint64{}}
+	ctx := context.Background()
+	hash, err := m.latest(ctx, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.validThrough(hash); got != 100 {
+		t.Fatalf("valid through %d, want 100", got)
+	}
+	// The chain's at 100, then 101.
+	for i, want := range []bool{false, true} {
+		expired, err := m.expired(ctx, client, hash)
+		if err != nil || expired != want {
+			t.Errorf("check %d: expired %v (%v), want %v", i, expired, err, want)
+		}
+	}
+
+	// One it never handed out is asked about instead.
+	other := solana.Hash{9}
+	if expired, err := m.expired(ctx, client, other); err != nil || expired {
+		t.Errorf("an unknown blockhash the first time: %v %v", expired, err)
+	}
+	if expired, err := m.expired(ctx, client, other); err != nil || !expired {
+		t.Errorf("an unknown blockhash after: %v %v", expired, err)
+	}
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if ls.height != 102 {
+		t.Errorf("block height was asked for %d times, want 2", ls.height-100)
+	}
+}
+
+func TestBlockhashManagerBounded(t *testing.T) {
+	m := &blockhashManager{lastValid: map[solana.Hash]uint64{}}
+	for i := range maxTrackedBlockhashes + 10 {
+		m.track(solana.Hash{byte(i), byte(i >> 8)}, uint64(i+1))
+	}
+	m.track(solana.Hash{0xff, 0xff}, 0)
+	if len(m.lastValid) != maxTrackedBlockhashes || len(m.order) != maxTrackedBlockhashes {
+		t.Errorf("tracking %d blockhashes, want %d", len(m.lastValid), maxTrackedBlockhashes)
+	}
+	if m.validThrough(solana.Hash{0, 0}) != 0 || m.validThrough(solana.Hash{(maxTrackedBlockhashes + 9) & 0xff, 1}) == 0 {
+		t.Error("evicted the wrong end")
+	}
+}
+
+func TestJournalKeepsLastValidBlockHeight(t *testing.T) {
+	_, client := newLeaderServer(t, 1, false)
+	payer := solana.NewWallet().PrivateKey
+	tx, _ := sentTx(t, client, payer)
+	journal, _ := openSendJournal("")
+	if err := newSendGuard(journal, "k").sending(tx); err != nil {
+		t.Fatal(err)
+	}
+	if got := journal.attempts("k")[0].LastValidBlockHeight; got != 250 {
+		t.Errorf("journaled good through %d, want 250", got)
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): Candles from the observation ring.
+
+Every CP-Swap pool has an ObservationState account, a ring of 100 observations the program writes on swaps, at most
+one every 15 seconds. Each holds a timestamp and the cumulative price of token0 (in token1, Q32.32, raw units) summed
+over time, the way Uniswap v2's oracle does it. Two neighbouring observations give the pool's average price between
+them: the difference in the cumulative price over the difference in time. So the ring is a price history we can read
+in one getAccountInfo, no indexer and no crawling transactions like pool stats does.
+
+It's a coarse history. Each segment between two observations is one average, a spike and its reversal inside it don't
+show, and the price is the one the swaps saw before they moved it. How far back it goes depends on the pool, 100
+observations 15 seconds apart at the very least, days on a quiet pool. A segment that spans several candles (nobody
+swapped for a while) counts in each of them, the price held through all of them.
+
+The cumulative prices are u128s that wrap, the difference is taken mod 2^128 so a wrap in between doesn't matter.
+*/
+
+// observationUpdateInterval is how often at most the program writes an observation.
+const observationUpdateInterval = 15 * time.Second
+
+// priceSegment is the pool's average price, token1 per token0, from start to end.
+type priceSegment struct {
+	start, end time.Time
+	price      *big.Rat
+}
+
+// candle is one interval's open, high, low and close.
+type candle struct {
+	start                  time.Time
+	open, high, low, close *big.Rat
+}
+
+var (
+	q32     = new(big.Rat).SetInt(new(big.Int).Lsh(big.NewInt(1), 32))
+	mod2128 = new(big.Int).Lsh(big.NewInt(1), 128)
+)
+
+func fetchObservationState(ctx context.Context, client *rpc.Client, key solana.PublicKey) (*raydium_cp_swap.ObservationState, error) {
+	res, err := client.GetAccountInfoWithOpts(ctx, key, &rpc.GetAccountInfoOpts{Encoding: solana.EncodingBase64})
+	if err != nil {
+		return nil, fmt.Errorf("rpc call getAccountInfo for the pool's observations failed: %w", err)
+	}
+	if res == nil || res.Value == nil {
+		return nil, fmt.Errorf("observation account %s returned no data", key)
+	}
+	state, err := raydium_cp_swap.ParseAccount_ObservationState(res.Value.Data.GetBinary())
+	if err != nil {
+		return nil, fmt.Errorf("parsing the pool's ObservationState failed: %w", err)
+	}
+	return state, nil
+}
+
+// observationSegments turns the ring into price segments, oldest first. decimals are token0's and token1's.
+func observationSegments(state *raydium_cp_swap.ObservationState, decimals [2]uint8) []priceSegment {
+	if state == nil || !state.Initialized {
+		return nil
+	}
+	n := len(state.Observations)
+	// The slot after the newest is the oldest once the ring has gone round, unwritten slots have no timestamp.
+	var ordered []raydium_cp_swap.Observation
+	for i := 1; i <= n; i++ {
+		obs := state.Observations[(int(state.ObservationIndex)+i)%n]
+		if obs.BlockTimestamp != 0 {
+			ordered = append(ordered, obs)
+		}
+	}
+	scale := new(big.Rat).SetFrac(fixedPointScale(decimals[0]), fixedPointScale(decimals[1]))
+	var segments []priceSegment
+	for i := 1; i < len(ordered); i++ {
+		prev, cur := ordered[i-1], ordered[i]
+		if cur.BlockTimestamp <= prev.BlockTimestamp {
+			continue
+		}
+		delta := new(big.Int).Sub(cur.CumulativeToken0PriceX32.BigInt(), prev.CumulativeToken0PriceX32.BigInt())
+		delta.Mod(delta, mod2128)
+		price := new(big.Rat).SetFrac(delta, new(big.Int).SetUint64(cur.BlockTimestamp-prev.BlockTimestamp))
+		price.Quo(price, q32)
+		price.Mul(price, scale)
+		segments = append(segments, priceSegment{
+			start: time.Unix(int64(prev.BlockTimestamp), 0).UTC(),
+			end:   time.Unix(int64(cur.BlockTimestamp), 0).UTC(),
+			price: price,
+		})
+	}
+	return segments
+}
+
+// buildCandles cuts segments into candles interval long, at most the last most of them. A candle covers [start,
+// start+interval), a segment counts in every candle it overlaps.
+func buildCandles(segments []priceSegment, interval time.Duration, most int) []candle {
+	if len(segments) == 0 || interval <= 0 || most <= 0 {
+		return nil
+	}
+	// The candle the last segment ends in, and the earliest one that's still among the last most.
+	lastStart := segments[len(segments)-1].end.Add(-time.Nanosecond).Truncate(interval)
+	first := lastStart.Add(-time.Duration(most-1) * interval)
+	if start := segments[0].start.Truncate(interval); start.After(first) {
+		first = start
+	}
+	var candles []candle
+	for _, seg := range segments {
+		if !seg.end.After(first) {
+			continue
+		}
+		from := seg.start
+		if from.Before(first) {
+			from = first
+		}
+		for at := from.Truncate(interval); at.Before(seg.end); at = at.Add(interval) {
+			if len(candles) == 0 || candles[len(candles)-1].start.Before(at) {
+				candles = append(candles, candle{start: at, open: seg.price, high: seg.price, low: seg.price, close: seg.price})
+				continue
+			}
+			c := &candles[len(candles)-1]
+			c.close = seg.price
+			if seg.price.Cmp(c.high) > 0 {
+				c.high = seg.price
+			}
+			if seg.price.Cmp(c.low) < 0 {
+				c.low = seg.price
+			}
+		}
+	}
+	if len(candles) > most {
+		candles = candles[len(candles)-most:]
+	}
+	return candles
+}
+
+// candleIntervals are what autoCandleInterval picks from.
+var candleIntervals = []time.Duration{
+	15 * time.Second, 30 * time.Second, time.Minute, 5 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 4 * time.Hour, 24 * time.Hour,
+}
+
+// autoCandleInterval is the shortest of candleIntervals that fits the segments' span in n candles.
+func autoCandleInterval(segments []priceSegment, n int) time.Duration {
+	if len(segments) == 0 || n <= 0 {
+		return time.Minute
+	}
+	span := segments[len(segments)-1].end.Sub(segments[0].start)
+	for _, iv := range candleIntervals {
+		if time.Duration(n)*iv >= span {
+			return iv
+		}
+	}
+	return candleIntervals[len(candleIntervals)-1]
+}
+
+// renderCandles draws candles height rows tall, one column each, a price axis on the left and the first and last
+// candle's times under them. Bodies are █ when the price rose over the candle and ░ when it fell, wicks │.
+func renderCandles(candles []candle, interval time.Duration, height int, unit string, decimals int) string {
+	if len(candles) == 0 {
+		return "No candles, the pool's observation ring is empty.\n"
+	}
+	height = max(height, 3)
+	low, high := candles[0].low, candles[0].high
+	for _, c := range candles {
+		if c.low.Cmp(low) < 0 {
+			low = c.low
+		}
+		if c.high.Cmp(high) > 0 {
+			high = c.high
+		}
+	}
+	lowF, _ := low.Float64()
+	highF, _ := high.Float64()
+	row := func(r *big.Rat) int {
+		if highF == lowF {
+			return height / 2
+		}
+		f, _ := r.Float64()
+		return int((highF - f) / (highF - lowF) * float64(height-1))
+	}
+	label := func(r *big.Rat) string { return trimDecimal(r.FloatString(decimals)) }
+	labels := map[int]string{0: label(high), height - 1: label(low)}
+	width := max(len(labels[0]), len(labels[height-1]))
+
+	grid := make([][]rune, height)
+	for i := range grid {
+		grid[i] = []rune(strings.Repeat(" ", len(candles)))
+	}
+	for x, c := range candles {
+		body := '█'
+		if c.close.Cmp(c.open) < 0 {
+			body = '░'
+		}
+		top, bottom := row(c.open), row(c.close)
+		if top > bottom {
+			top, bottom = bottom, top
+		}
+		for y := row(c.high); y <= row(c.low); y++ {
+			grid[y][x] = '│'
+			if y >= top && y <= bottom {
+				grid[y][x] = body
+			}
+		}
+	}
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%s, %d candles of %s\n", unit, len(candles), interval)
+	for y, line := range grid {
+		fmt.Fprintf(b, "%*s ┤%s\n", width, labels[y], string(line))
+	}
+	from, to := candles[0].start.Format("15:04"), candles[len(candles)-1].start.Format("15:04")
+	gap := max(len(candles)-len(from)-len(to), 1)
+	fmt.Fprintf(b, "%*s  %s%s%s\n", width, "", from, strings.Repeat(" ", gap), to)
+	return b.String()
+}
+
+// writeCandlesCSV writes one candle a row, start in RFC 3339 and the prices at decimals places.
+func writeCandlesCSV(w io.Writer, candles []candle, decimals int) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"start", "open", "high", "low", "close"})
+	for _, c := range candles {
+		_ = cw.Write([]string{c.start.Format(time.RFC3339), c.open.FloatString(decimals), c.high.FloatString(decimals), c.low.FloatString(decimals), c.close.FloatString(decimals)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type candleJSON struct {
+	Start time.Time `json:"start"`
+	Open  string    `json:"open"`
+	High  string    `json:"high"`
+	Low   string    `json:"low"`
+	Close string    `json:"close"`
+}
+
+func candlesJSON(candles []candle, decimals int) []candleJSON {
+	out := make([]candleJSON, 0, len(candles))
+	for _, c := range candles {
+		out = append(out, candleJSON{Start: c.start, Open: c.open.FloatString(decimals), High: c.high.FloatString(decimals), Low: c.low.FloatString(decimals), Close: c.close.FloatString(decimals)})
+	}
+	return out
+}
+
+// poolCandles reads pool's observation ring and cuts it into at most n candles, interval long or, when it's 0, the
+// shortest interval that covers the ring. It returns the interval it used.
+func poolCandles(ctx context.Context, client *rpc.Client, pool *raydium_cp_swap.PoolState, interval time.Duration, n int) ([]candle, time.Duration, error) {
+	state, err := fetchObservationState(ctx, client, pool.ObservationKey)
+	if err != nil {
+		return nil, 0, err
+	}
+	segments := observationSegments(state, [2]uint8{pool.Mint0Decimals, pool.Mint1Decimals})
+	if interval == 0 {
+		interval = autoCandleInterval(segments, n)
+	}
+	return buildCandles(segments, interval, n), interval, nil
+}
+
+func runPoolCandlesCommand(args []string) error {
+	fs := flag.NewFlagSet("pool candles", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: pool candles [flags] <pool address or pair>\n")
+		fs.PrintDefaults()
+	}
+	nf := addNetworkFlags(fs)
+	var (
+		interval = fs.Duration("interval", 0, "Length of a candle, at least 15s (default the shortest that fits the pool's history in -candles)")
+		count    = fs.Int("candles", 60, "Most candles to show, the latest ones")
+		height   = fs.Int("height", 16, "Rows the chart is drawn in")
+		asCSV    = fs.Bool("csv", false, "Print the candles as CSV instead of a chart")
+		asJSON   = fs.Bool("json", false, "Print the candles as JSON instead of a chart")
+	)
+	// The pool reads naturally first, `pool candles <pool> -network mainnet`, flag stops at the first argument.
+	var target string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		target, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, nf.specs())
+	if target == "" && fs.NArg() > 0 {
+		target = fs.Arg(0)
+	}
+	switch {
+	case target == "":
+		fs.Usage()
+		return errors.New("missing pool")
+	case *interval != 0 && *interval < observationUpdateInterval:
+		return fmt.Errorf("-interval has to be at least %s, the pool doesn't observe its price more often", observationUpdateInterval)
+	case *count <= 0 || *height <= 0:
+		return errors.New("-candles and -height must be greater than zero")
+	case *asCSV && *asJSON:
+		return errors.New("-csv and -json don't go together")
+	}
+	client := nf.connect()
+	ctx, stop := interruptContext()
+	defer stop()
+
+	quoteCtx, cancel := deadlines.forQuote(ctx)
+	defer cancel()
+	poolAddr, err := resolvePoolTarget(quoteCtx, client, target, SymbolMapping{})
+	if err != nil {
+		return err
+	}
+	pool, err := fetchPoolState(quoteCtx, client, poolAddr)
+	if err != nil {
+		return err
+	}
+	candles, used, err := poolCandles(quoteCtx, client, pool, *interval, *count)
+	if err != nil {
+		return err
+	}
+	decimals := int(pool.Mint0Decimals) + int(pool.Mint1Decimals)
+	switch {
+	case *asCSV:
+		return writeCandlesCSV(os.Stdout, candles, decimals)
+	case *asJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(candlesJSON(candles, decimals))
+	}
+	symm := makeSymbolMapping(ctx, client, []solana.PublicKey{pool.Token0Mint, pool.Token1Mint})
+	userAliases.apply(symm, pool.Token0Mint, pool.Token1Mint)
+	fmt.Print(renderCandles(candles, used, *height, symm.SymFrom(pool.Token1Mint)+" per "+symm.SymFrom(pool.Token0Mint), decimals))
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	bin "github.com/gagliardetto/binary"
+)
+
+// observationRing writes observations at the given times with the price (raw token1 per raw token0) holding over the
+// segment before each, starting cumulative at start, into a ring whose newest slot is newest.
+func observationRing(times []uint64, prices []int64, start *big.Int, newest int) *raydium_cp_swap.ObservationState {
+	state := &raydium_cp_swap.ObservationState{Initialized: true, ObservationIndex: uint16(newest)}
+	n := len(state.Observations)
+	cum := new(big.Int).Set(start)
+	for i, ts := range times {
+		if i > 0 {
+			step := new(big.Int).Lsh(big.NewInt(prices[i-1]), 32)
+			step.Mul(step, new(big.Int).SetUint64(ts-times[i-1]))
+			cum.Add(cum, step)
+			cum.Mod(cum, mod2128)
+		}
+		b := cum.FillBytes(make([]byte, 16))
+		slot := (newest - (len(times) - 1 - i) + n) % n
+		state.Observations[slot] = raydium_cp_swap.Observation{
+			BlockTimestamp:           ts,
+			CumulativeToken0PriceX32: bin.Uint128{Hi: new(big.Int).SetBytes(b[:8]).Uint64(), Lo: new(big.Int).SetBytes(b[8:]).Uint64()},
+		}
+	}
+	return state
+}
+
+func TestObservationSegments(t *testing.T) {
+	times := []uint64{1000, 1060, 1120, 1300}
+	prices := []int64{150, 160, 140}
+	// Right below 2^128, so the cumulative price wraps on the way, and the ring wraps around its end too.
+	start := new(big.Int).Sub(mod2128, big.NewInt(1<<40))
+	segs := observationSegments(observationRing(times, prices, start, 1), [2]uint8{0, 0})
+	if len(segs) != 3 {
+		t.Fatalf("%d segments, want 3", len(segs))
+	}
+	for i, seg := range segs {
+		if seg.price.Cmp(big.NewRat(prices[i], 1)) != 0 || seg.start.Unix() != int64(times[i]) || seg.end.Unix() != int64(times[i+1]) {
+			t.Errorf("segment %d: %s at %v-%v", i, seg.price.FloatString(4), seg.start.Unix(), seg.end.Unix())
+		}
+	}
+
+	// 9 decimals against 6, 150 USDC per SOL is 0.15 raw.
+	state := &raydium_cp_swap.ObservationState{Initialized: true, ObservationIndex: 1}
+	state.Observations[0] = raydium_cp_swap.Observation{BlockTimestamp: 100}
+	state.Observations[1] = raydium_cp_swap.Observation{BlockTimestamp: 110, CumulativeToken0PriceX32: bin.Uint128{Lo: 10 * (3 << 32) / 20}}
+	if segs := observationSegments(state, [2]uint8{9, 6}); len(segs) != 1 || segs[0].price.Cmp(big.NewRat(150, 1)) != 0 {
+		t.Errorf("scaled segments %v", segs)
+	}
+	if segs := observationSegments(&raydium_cp_swap.ObservationState{}, [2]uint8{}); segs != nil {
+		t.Errorf("an uninitialized ring gave %v", segs)
+	}
+}
+
+func TestBuildCandles(t *testing.T) {
+	at := func(s int64) time.Time { return time.Unix(s, 0).UTC() }
+	seg := func(from, to, price int64) priceSegment {
+		return priceSegment{start: at(from), end: at(to), price: big.NewRat(price, 1)}
+	}
+	segments := []priceSegment{seg(0, 30, 10), seg(30, 45, 14), seg(45, 60, 12), seg(60, 200, 9)}
+	candles := buildCandles(segments, time.Minute, 10)
+	if len(candles) != 4 {
+		t.Fatalf("%d candles, want 4", len(candles))
+	}
+	first := candles[0]
+	if first.open.Cmp(big.NewRat(10, 1)) != 0 || first.high.Cmp(big.NewRat(14, 1)) != 0 || first.low.Cmp(big.NewRat(10, 1)) != 0 || first.close.Cmp(big.NewRat(12, 1)) != 0 {
+		t.Errorf("first candle %s %s %s %s", first.open, first.high, first.low, first.close)
+	}
+	// The quiet segment after a minute holds across the three candles it spans.
+	for _, c := range candles[1:] {
+		if c.open.Cmp(big.NewRat(9, 1)) != 0 || c.close.Cmp(big.NewRat(9, 1)) != 0 {
+			t.Errorf("candle at %v %s-%s", c.start.Unix(), c.open, c.close)
+		}
+	}
+	if last := buildCandles(segments, time.Minute, 2); len(last) != 2 || last[0].start.Unix() != 120 {
+		t.Errorf("the last two candles %v", last)
+	}
+	if iv := autoCandleInterval(segments, 10); iv != 30*time.Second {
+		t.Errorf("auto interval %v for 200s in 10 candles", iv)
+	}
+
+	chart := renderCandles(candles, time.Minute, 5, "USDC per SOL", 0)
+	if !strings.Contains(chart, "14 ┤") || !strings.Contains(chart, "9 ┤") || !strings.Contains(chart, "4 candles of 1m0s") {
+		t.Errorf("chart\n%s", chart)
+	}
+	var csv bytes.Buffer
+	if err := writeCandlesCSV(&csv, candles[:1], 2); err != nil {
+		t.Fatal(err)
+	}
+	if want := "start,open,high,low,close\n1970-01-01T00:00:00Z,10.00,14.00,10.00,12.00\n"; csv.String() != want {
+		t.Errorf("csv %q", csv.String())
+	}
+}
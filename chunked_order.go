@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+/*
+NOTE(@hadydotai): Chunking big orders.
+
+-split spreads an order over pools, this spreads it over time. An order bigger than -chunk-above percent of the pool's
+reserve on its known side (what's paid for `sell`/`pay`, what's received for `buy`/`receive`) goes out as -chunks
+sequential transactions instead of one. Between them we wait -chunk-interval, which is the whole point: arbitrage
+pulls the pool back toward the market after every fill, and the next chunk is quoted off the reserves as they are
+then, not as they were when the order started. Each chunk carries its own slippage guard off its own quote.
+
+What we watch is the cumulative slippage: how far the rate the filled chunks got on average (output per input, same
+for buying and selling) has fallen from the rate the first chunk was quoted at. Before a chunk is sent its quote is
+added in and, if that would take the order past -chunk-max-slippage, we stop there. A fill that comes back worse than
+quoted is counted as it landed and can stop the order too. Stopping leaves the rest of the order unsent, it's
+reported, never retried.
+
+The chunks are equal, the rounding remainder goes with the first. Orders under the threshold go out as one swap like
+always.
+*/
+
+const maxChunks = 50
+
+type chunkFlags struct {
+	above       *float64
+	chunks      *int
+	maxSlippage *float64
+	interval    *time.Duration
+}
+
+func addChunkFlags(fs *flag.FlagSet) *chunkFlags {
+	return &chunkFlags{
+		above:       fs.Float64("chunk-above", 0, "Send -intent in -chunks transactions, re-quoted in between, when it's over this percentage of the pool's reserve (e.g. 2), needs -no-tui"),
+		chunks:      fs.Int("chunks", 4, "How many transactions a chunked order goes out in, see -chunk-above"),
+		maxSlippage: fs.Float64("chunk-max-slippage", 1, "Stop a chunked order early once its average rate is this percentage worse than its first chunk's quote"),
+		interval:    fs.Duration("chunk-interval", 10*time.Second, "How long to wait between the chunks of a chunked order"),
+	}
+}
+
+func (cf *chunkFlags) enabled() bool {
+	return *cf.above > 0
+}
+
+func (cf *chunkFlags) plan() (chunkPlan, error) {
+	switch {
+	case *cf.above >= 100:
+		return chunkPlan{}, errors.New("-chunk-above is a percentage of the pool's reserve, under 100")
+	case *cf.chunks < 2 || *cf.chunks > maxChunks:
+		return chunkPlan{}, fmt.Errorf("-chunks takes 2 to %d", maxChunks)
+	case *cf.interval < 0:
+		return chunkPlan{}, errors.New("-chunk-interval can't be negative")
+	}
+	maxSlippage, err := makeSlippageRatio(*cf.maxSlippage)
+	if err != nil {
+		return chunkPlan{}, fmt.Errorf("-chunk-max-slippage: %w", err)
+	}
+	return chunkPlan{
+		above:       new(big.Rat).SetFloat64(*cf.above / 100),
+		chunks:      *cf.chunks,
+		maxSlippage: maxSlippage,
+		interval:    *cf.interval,
+	}, nil
+}
+
+type chunkPlan struct {
+	above       *big.Rat // the share of the known side's reserve an order has to be over to get chunked
+	chunks      int
+	maxSlippage *big.Rat
+	interval    time.Duration
+}
+
+// reserveShare is intent's known amount as a fraction of the pool's reserve of the same token.
+func reserveShare(intent *CPIntent) (*big.Rat, error) {
+	reserve := intent.ReserveIn
+	if intent.SwapKind == SwapKindBaseOutput {
+		reserve = intent.ReserveOut
+	}
+	if reserve == nil || reserve.Balance == nil || reserve.Balance.Sign() == 0 {
+		return nil, errors.New("the pool's reserves are unavailable")
+	}
+	return new(big.Rat).SetFrac(intent.Amounts.KnownAmount, reserve.Balance), nil
+}
+
+// applies reports whether intent is big enough to be chunked.
+func (p chunkPlan) applies(intent *CPIntent) bool {
+	share, err := reserveShare(intent)
+	return err == nil && share.Cmp(p.above) > 0
+}
+
+// chunkSizes divides total into n equal chunks, the remainder going with the first. An amount too small to divide n
+// ways is divided into as many chunks as it has units.
+func chunkSizes(total *big.Int, n int) []*big.Int {
+	count := big.NewInt(int64(n))
+	if total.Cmp(count) < 0 {
+		count.Set(total)
+	}
+	chunk, rem := new(big.Int).QuoRem(total, count, new(big.Int))
+	sizes := make([]*big.Int, count.Int64())
+	for i := range sizes {
+		sizes[i] = new(big.Int).Set(chunk)
+	}
+	if len(sizes) > 0 {
+		sizes[0].Add(sizes[0], rem)
+	}
+	return sizes
+}
+
+type chunkFill struct {
+	intent   *CPIntent
+	summary  txSummaryData
+	sig      solana.Signature
+	paid     *big.Int
+	received *big.Int
+	slippage *big.Rat // the order's cumulative slippage once this chunk filled
+}
+
+// chunkedOrder is what a chunked order did, as far as it got.
+type chunkedOrder struct {
+	plan     chunkPlan
+	sizes    []*big.Int
+	fills    []chunkFill
+	baseline *big.Rat // output per input the first chunk was quoted at
+	paid     *big.Int
+	received *big.Int
+	// stopped says why the order ended before its last chunk, empty when it didn't.
+	stopped string
+}
+
+// slippageAt is the cumulative slippage of having paid and received in total, against the baseline. Negative when
+// the order's doing better than its first quote.
+func (o *chunkedOrder) slippageAt(paid, received *big.Int) *big.Rat {
+	if o.baseline == nil || paid.Sign() == 0 {
+		return new(big.Rat)
+	}
+	rate := new(big.Rat).SetFrac(received, paid)
+	return new(big.Rat).Sub(big.NewRat(1, 1), rate.Quo(rate, o.baseline))
+}
+
+type (
+	// chunkQuoter quotes amount of the order's known side off the pool as it is now.
+	chunkQuoter func(ctx context.Context, amount *big.Int) (*CPIntent, error)
+	chunkSender func(ctx context.Context, chunk *CPIntent) (txSummaryData, solana.Signature, error)
+)
+
+// runChunkedOrder sends total in plan.chunks chunks, quoting each one just before it's sent. The order comes back
+// with whatever filled, an error included.
+func runChunkedOrder(ctx context.Context, plan chunkPlan, total *big.Int, quote chunkQuoter, send chunkSender) (*chunkedOrder, error) {
+	order := &chunkedOrder{plan: plan, sizes: chunkSizes(total, plan.chunks), paid: new(big.Int), received: new(big.Int)}
+	for i, size := range order.sizes {
+		if i > 0 && plan.interval > 0 {
+			timer := time.NewTimer(plan.interval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				order.stopped = "interrupted"
+				return order, ctx.Err()
+			case <-timer.C:
+			}
+		}
+		chunk, err := quote(ctx, size)
+		if err != nil {
+			return order, fmt.Errorf("quoting chunk %d of %d failed: %w", i+1, len(order.sizes), err)
+		}
+		in, out := chunk.QuotedInOut()
+		if order.baseline == nil {
+			order.baseline = new(big.Rat).SetFrac(out, in)
+		}
+		projected := order.slippageAt(new(big.Int).Add(order.paid, in), new(big.Int).Add(order.received, out))
+		if projected.Cmp(plan.maxSlippage) > 0 {
+			order.stopped = fmt.Sprintf("chunk %d of %d would take the order's slippage to %s, over the %s limit", i+1, len(order.sizes), pctString(projected), pctString(plan.maxSlippage))
+			return order, nil
+		}
+
+		summary, sig, err := send(ctx, chunk)
+		if err != nil {
+			if !sig.IsZero() {
+				order.fills = append(order.fills, chunkFill{intent: chunk, summary: summary, sig: sig})
+			}
+			return order, fmt.Errorf("chunk %d of %d failed: %w", i+1, len(order.sizes), err)
+		}
+		paid, received := summary.PaidAmount, summary.ReceivedAmount
+		if paid == nil || received == nil {
+			// Sent but the balances couldn't be read back, the quote is the best record there is.
+			paid, received = in, out
+		}
+		order.paid.Add(order.paid, paid)
+		order.received.Add(order.received, received)
+		slippage := order.slippageAt(order.paid, order.received)
+		order.fills = append(order.fills, chunkFill{intent: chunk, summary: summary, sig: sig, paid: paid, received: received, slippage: slippage})
+		if i < len(order.sizes)-1 && slippage.Cmp(plan.maxSlippage) > 0 {
+			order.stopped = fmt.Sprintf("the order's slippage reached %s after chunk %d of %d, over the %s limit", pctString(slippage), i+1, len(order.sizes), pctString(plan.maxSlippage))
+			return order, nil
+		}
+	}
+	return order, nil
+}
+
+// quoteChunk is base for amount instead, quoted off the pool's reserves read now, with its own slippage guard.
+func quoteChunk(ctx context.Context, client *rpc.Client, builder *TableBuilder, base *CPIntent, amount *big.Int) (*CPIntent, error) {
+	snap := builder.snapshot()
+	quoteCtx, cancel := deadlines.forQuote(ctx)
+	balances, errs, _ := readReserves(quoteCtx, client, snap.venue)
+	cancel()
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("reading the pool's reserves failed: %w", err)
+		}
+		if balances[i] == nil || balances[i].Balance == nil {
+			return nil, errors.New("the pool's reserves are unavailable")
+		}
+	}
+	fresh := *base
+	in := tokenIndex(snap.venue, base.TokenIn.Mint)
+	fresh.ReserveIn, fresh.ReserveOut = balances[in], balances[1-in]
+	return splitIntent(&fresh, amount, snap.slippageRat)
+}
+
+// renderChunkedOrder shows every chunk that was sent, the totals, and why the order stopped if it did.
+func renderChunkedOrder(order *chunkedOrder, symm SymbolMapping, network string) string {
+	builder := &strings.Builder{}
+	if len(order.fills) > 0 {
+		first := order.fills[0].intent
+		inSym, outSym := symm.SymFrom(first.TokenIn.Mint), symm.SymFrom(first.TokenOut.Mint)
+		inDec, outDec := first.TokenIn.Decimals, first.TokenOut.Decimals
+		t := table.NewWriter()
+		t.SetOutputMirror(builder)
+		t.SetTitle("Chunked order")
+		t.Style().Size.WidthMax = 160
+		t.AppendHeader(table.Row{"Chunk", "Status", "Paid", "Received", "Slippage", "Transaction"})
+		for i, fill := range order.fills {
+			status, slippage := fill.summary.Status, "n/a"
+			if status == "" {
+				status = "pending"
+			}
+			if fill.slippage != nil {
+				slippage = pctString(fill.slippage)
+			}
+			t.AppendRow(table.Row{fmt.Sprintf("%d/%d", i+1, len(order.sizes)), strings.ToUpper(status),
+				formatTokenAmount(fill.paid, inDec, inSym), formatTokenAmount(fill.received, outDec, outSym), slippage,
+				explorerTxURL(network, fill.sig)})
+		}
+		t.AppendFooter(table.Row{"Total", "", formatTokenAmount(order.paid, inDec, inSym), formatTokenAmount(order.received, outDec, outSym),
+			pctString(order.slippageAt(order.paid, order.received)), ""})
+		t.Render()
+	}
+	if order.stopped != "" {
+		fmt.Fprintf(builder, "Stopped, %s. %d of %d chunks were sent, the rest of the order wasn't.\n", order.stopped, len(order.fills), len(order.sizes))
+	}
+	return builder.String()
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+// chunkPool is a pool the chunks trade against in memory. With arbitrage, the reserves go back to where they started
+// between chunks, the way the market would pull them back given the time.
+type chunkPool struct {
+	base      *CPIntent
+	in, out   *big.Int
+	arbitrage bool
+	sent      int
+}
+
+func newChunkPool(t *testing.T, intentLine string, arbitrage bool) *chunkPool {
+	base := splitCandidate(t, snapshotKey(60), 1, 2500, intentLine).intent
+	return &chunkPool{base: base, in: new(big.Int).Set(base.ReserveIn.Balance), out: new(big.Int).Set(base.ReserveOut.Balance), arbitrage: arbitrage}
+}
+
+func (p *chunkPool) quote(ctx context.Context, amount *big.Int) (*CPIntent, error) {
+	fresh := *p.base
+	fresh.ReserveIn = &PoolBalance{Balance: new(big.Int).Set(p.in), Decimals: p.base.ReserveIn.Decimals}
+	fresh.ReserveOut = &PoolBalance{Balance: new(big.Int).Set(p.out), Decimals: p.base.ReserveOut.Decimals}
+	slippage, _ := makeSlippageRatio(0.5)
+	return splitIntent(&fresh, amount, slippage)
+}
+
+func (p *chunkPool) send(ctx context.Context, chunk *CPIntent) (txSummaryData, solana.Signature, error) {
+	p.sent++
+	in, out := chunk.QuotedInOut()
+	if !p.arbitrage {
+		p.in.Add(p.in, in)
+		p.out.Sub(p.out, out)
+	}
+	return txSummaryData{Status: "confirmed", PaidAmount: in, ReceivedAmount: out}, solana.Signature{byte(p.sent)}, nil
+}
+
+func chunkTestPlan(chunks int, maxSlippagePct float64) chunkPlan {
+	maxSlippage, _ := makeSlippageRatio(maxSlippagePct)
+	return chunkPlan{above: big.NewRat(2, 100), chunks: chunks, maxSlippage: maxSlippage}
+}
+
+func TestChunkSizes(t *testing.T) {
+	for _, tc := range []struct {
+		total int64
+		n     int
+		want  string
+	}{
+		{10, 4, "4 2 2 2"},
+		{8, 4, "2 2 2 2"},
+		{2, 4, "1 1"},
+	} {
+		var got []string
+		for _, size := range chunkSizes(big.NewInt(tc.total), tc.n) {
+			got = append(got, size.String())
+		}
+		if strings.Join(got, " ") != tc.want {
+			t.Errorf("chunkSizes(%d, %d) = %v, want %s", tc.total, tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestChunkPlanApplies(t *testing.T) {
+	plan := chunkTestPlan(4, 1)
+	// The pool holds 1000 SOL and 150000 USDC.
+	for intentLine, want := range map[string]bool{
+		"sell 200 SOL":  true,
+		"sell 10 SOL":   false,
+		"buy 5000 USDC": true,
+		"buy 10 SOL":    false,
+	} {
+		if got := plan.applies(newChunkPool(t, intentLine, false).base); got != want {
+			t.Errorf("%s: applies = %v, want %v", intentLine, got, want)
+		}
+	}
+}
+
+func TestRunChunkedOrder(t *testing.T) {
+	// The market pulls the pool back between chunks, every chunk fills near the first one's rate and the order as a
+	// whole gets more than it would have in one go.
+	pool := newChunkPool(t, "sell 200 SOL", true)
+	order, err := runChunkedOrder(context.Background(), chunkTestPlan(4, 1), pool.base.Amounts.KnownAmount, pool.quote, pool.send)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(order.fills) != 4 || order.stopped != "" {
+		t.Fatalf("%d chunks filled, stopped %q", len(order.fills), order.stopped)
+	}
+	if order.paid.Cmp(pool.base.Amounts.KnownAmount) != 0 {
+		t.Errorf("paid %s in total, the order was for %s", order.paid, pool.base.Amounts.KnownAmount)
+	}
+	if order.received.Cmp(pool.base.Amounts.QuoteAmount) <= 0 {
+		t.Errorf("received %s, no more than the %s of one swap", order.received, pool.base.Amounts.QuoteAmount)
+	}
+	if out := renderChunkedOrder(order, SymbolMapping{}, "devnet"); !strings.Contains(out, "4/4") || strings.Contains(out, "Stopped") {
+		t.Errorf("rendered as:\n%s", out)
+	}
+
+	// Nothing moves the pool back, every chunk gets a worse rate than the last, and the order stops before the one
+	// that would take it over the limit.
+	for _, intentLine := range []string{"sell 200 SOL", "buy 20000 USDC"} {
+		pool := newChunkPool(t, intentLine, false)
+		plan := chunkTestPlan(8, 5)
+		order, err := runChunkedOrder(context.Background(), plan, pool.base.Amounts.KnownAmount, pool.quote, pool.send)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if order.stopped == "" || len(order.fills) < 2 || len(order.fills) == 8 || pool.sent != len(order.fills) {
+			t.Fatalf("%s: %d chunks filled, %d sent, stopped %q", intentLine, len(order.fills), pool.sent, order.stopped)
+		}
+		if slippage := order.slippageAt(order.paid, order.received); slippage.Cmp(plan.maxSlippage) > 0 || slippage.Sign() <= 0 {
+			t.Errorf("%s: stopped at %s slippage, the limit is %s", intentLine, pctString(slippage), pctString(plan.maxSlippage))
+		}
+		if out := renderChunkedOrder(order, SymbolMapping{}, "devnet"); !strings.Contains(out, "the rest of the order wasn't") {
+			t.Errorf("%s: rendered as:\n%s", intentLine, out)
+		}
+	}
+}
+
+func TestRunChunkedOrderSendFails(t *testing.T) {
+	pool := newChunkPool(t, "sell 200 SOL", true)
+	send := func(ctx context.Context, chunk *CPIntent) (txSummaryData, solana.Signature, error) {
+		if pool.sent == 2 {
+			return txSummaryData{}, solana.Signature{}, errors.New("blockhash expired")
+		}
+		return pool.send(ctx, chunk)
+	}
+	order, err := runChunkedOrder(context.Background(), chunkTestPlan(4, 1), pool.base.Amounts.KnownAmount, pool.quote, send)
+	if err == nil || !strings.Contains(err.Error(), "chunk 3 of 4") {
+		t.Fatalf("err = %v", err)
+	}
+	if len(order.fills) != 2 {
+		t.Errorf("%d chunks recorded, want the 2 that filled", len(order.fills))
+	}
+}
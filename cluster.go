@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): Which cluster is the RPC on.
+
+-network picks the Raydium deployment and the default RPC, -rpc can point anywhere. Pass a mainnet pool with the devnet
+RPC (easy, -rpc used to default to devnet whatever -network said) and the pool account simply isn't there, what came
+back was a getAccountInfo "not found" dressed up as a rate limit. So on startup we ask the RPC for its genesis hash,
+every cluster has its own and mainnet/devnet/testnet's are fixed. Anything else is a local validator (or a fork of one),
+which can host either deployment, so it goes with whatever -network says.
+
+  - an RPC on mainnet with -network devnet (or the other way around) stops before anything is loaded, naming the
+    cluster it's actually on
+  - the Raydium CP-Swap program has to be deployed (and executable) on the cluster, on a local validator that means
+    cloning it in
+  - a pool that isn't there is "pool X not found on <cluster>", an account that isn't a pool says who owns it
+
+Replaying RPC fixtures skips all of it, nobody recorded a genesis hash. An RPC that won't answer gets a warning and the
+run carries on, it'd fail on the next call anyway with a better idea why.
+*/
+
+var genesisHashes = map[string]string{
+	"5eykt4UsFv8P8NJdTREpY1vzqKqZKvdpKuc147dw2N9d": "mainnet",
+	"EtWTRABZaYq6iMfeYKouRu166VU2xqa1wcaWoxPkrZBG": "devnet",
+	"4uhcVJyU9pJkvQyS88uRDiswHXSCkY3zQawwpjk2NsNY": "testnet",
+}
+
+// connectedCluster is the cluster the RPC turned out to be on, -network until we know better.
+var connectedCluster = "devnet"
+
+// detectCluster names the cluster behind client by its genesis hash, one we don't know is a local validator.
+func detectCluster(ctx context.Context, client *rpc.Client) (string, error) {
+	hash, err := client.GetGenesisHash(ctx)
+	if err != nil {
+		return "", fmt.Errorf("rpc call getGenesisHash failed: %w", err)
+	}
+	if name, ok := genesisHashes[hash.String()]; ok {
+		return name, nil
+	}
+	return "localnet", nil
+}
+
+// checkCluster is why -network can't be used against cluster, nil when it can. program is the Raydium CP-Swap
+// program's account on the cluster, nil when there's none.
+func checkCluster(network, cluster string, program *rpc.Account) error {
+	if cluster != "localnet" && cluster != network {
+		if _, ok := networks[cluster]; !ok {
+			return fmt.Errorf("the RPC is on %s, Raydium CP-Swap isn't deployed there, use a mainnet or devnet RPC", cluster)
+		}
+		return fmt.Errorf("the RPC is on %s but -network is %s, pass -network %s (or an RPC on %s)", cluster, network, cluster, network)
+	}
+	if program == nil || !program.Executable {
+		if cluster == "localnet" {
+			return fmt.Errorf("the Raydium CP-Swap program %s isn't deployed on this local validator, start it with --clone-upgradeable-program %s", raydium_cp_swap.ProgramID, raydium_cp_swap.ProgramID)
+		}
+		return fmt.Errorf("the Raydium CP-Swap program %s isn't deployed on %s", raydium_cp_swap.ProgramID, cluster)
+	}
+	return nil
+}
+
+// useCluster makes sure the RPC is on network and has the Raydium program, and exits when it doesn't. It has to run
+// after raydium_cp_swap.ProgramID is set.
+func useCluster(client *rpc.Client, network string, replaying bool) {
+	connectedCluster = network
+	if replaying {
+		return
+	}
+	ctx, cancel := deadlines.forMetadata(context.Background())
+	defer cancel()
+	cluster, err := detectCluster(ctx, client)
+	if err != nil {
+		log.Printf("warning: %v, can't tell which cluster the RPC is on", err)
+		return
+	}
+	program, err := accountOrNil(ctx, client, raydium_cp_swap.ProgramID)
+	if err != nil {
+		log.Printf("warning: %v, can't tell if Raydium CP-Swap is deployed on %s", err, cluster)
+		return
+	}
+	if err := checkCluster(network, cluster, program); err != nil {
+		log.Fatalf("%s\n", err)
+	}
+	connectedCluster = cluster
+}
+
+// poolNotFoundError is a pool address with no account behind it on the cluster we're connected to.
+type poolNotFoundError struct {
+	pool    solana.PublicKey
+	cluster string
+}
+
+func (e *poolNotFoundError) Error() string {
+	return fmt.Sprintf("pool %s not found on %s, if it's on another cluster pass -network (mainnet, devnet or localnet)", e.pool, e.cluster)
+}
+
+func (e *poolNotFoundError) Is(target error) bool { return target == ErrAccountMissing }
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestDetectCluster(t *testing.T) {
+	for hash, want := range map[string]string{
+		"5eykt4UsFv8P8NJdTREpY1vzqKqZKvdpKuc147dw2N9d": "mainnet",
+		"EtWTRABZaYq6iMfeYKouRu166VU2xqa1wcaWoxPkrZBG": "devnet",
+		solana.Hash{7}.String():                        "localnet",
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":"%s"}`, hash)
+		}))
+		got, err := detectCluster(context.Background(), rpc.New(srv.URL))
+		srv.Close()
+		if err != nil || got != want {
+			t.Errorf("genesis %s is %q (%v), want %q", hash, got, err, want)
+		}
+	}
+}
+
+func TestCheckCluster(t *testing.T) {
+	deployed := &rpc.Account{Executable: true}
+	for _, tc := range []struct {
+		network, cluster string
+		program          *rpc.Account
+		want             string // empty when it's fine
+	}{
+		{"devnet", "devnet", deployed, ""},
+		{"devnet", "mainnet", deployed, "pass -network mainnet"},
+		{"mainnet", "devnet", deployed, "pass -network devnet"},
+		{"mainnet", "testnet", deployed, "isn't deployed there"},
+		{"mainnet", "localnet", deployed, ""},
+		{"localnet", "localnet", nil, "--clone-upgradeable-program"},
+		{"mainnet", "mainnet", &rpc.Account{}, "isn't deployed on mainnet"},
+	} {
+		err := checkCluster(tc.network, tc.cluster, tc.program)
+		if (tc.want == "") != (err == nil) || (err != nil && !strings.Contains(err.Error(), tc.want)) {
+			t.Errorf("-network %s on %s: %v, want %q", tc.network, tc.cluster, err, tc.want)
+		}
+	}
+}
+
+func TestFetchPoolStateElsewhere(t *testing.T) {
+	t.Cleanup(func() { connectedCluster = "devnet" })
+	connectedCluster = "devnet"
+	missing, mint := snapshotKey(1), snapshotKey(2)
+	client := transferRPC(t, map[solana.PublicKey]*rpc.Account{
+		mint: {Owner: solana.TokenProgramID, Data: rpc.DataBytesOrJSONFromBytes(mintData(1, 6, nil, nil))},
+	}, nil)
+
+	_, err := fetchPoolState(context.Background(), client, missing)
+	var notFound *poolNotFoundError
+	if !errors.As(err, &notFound) || !strings.Contains(err.Error(), "not found on devnet") {
+		t.Errorf("a pool that isn't there: %v", err)
+	}
+	if _, err := fetchPoolState(context.Background(), client, mint); err == nil || !strings.Contains(err.Error(), "isn't a Raydium CP-Swap pool") || !strings.Contains(err.Error(), solana.TokenProgramID.String()) {
+		t.Errorf("a mint passed as a pool: %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): Subcommands.
+
+The swap flow is still the default, `raydium-client -pool ... -intent ...` keeps working the way it always has. Anything
+that isn't a swap lives under a subcommand, `raydium-client <command> [subcommand] -flags`. Each command gets its own
+FlagSet so flags don't leak across commands, and they all go through the same validation helpers in config.go.
+*/
+
+// command is a subcommand of the client. run receives everything after the command name.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = map[string]command{
+	"alias":       {name: "alias", summary: "Symbol to mint aliases kept across runs (add, remove, list)", run: runAliasCommand},
+	"amm-configs": {name: "amm-configs", summary: "List the program's AmmConfigs, the fee tiers pools pick from", run: runAmmConfigsCommand},
+	"approve":     {name: "approve", summary: "Countersign a swap's approval request as the second keyholder", run: runApproveCommand},
+	"backtest":    {name: "backtest", summary: "Replay recorded pool reserves through limit, DCA and TWAP strategies and report their P&L", run: runBacktestCommand},
+	"batch":       {name: "batch", summary: "Run a file of intents, each on its own pool, one after another or a few at once (run)", run: runBatchCommand},
+	"bench":       {name: "bench", summary: "Measure quote throughput and RPC latency over a file of pools (quote)", run: runBenchCommand},
+	"dca":         {name: "dca", summary: "Recurring swaps on a schedule (run, report)", run: runDCACommand},
+	"decode":      {name: "decode", summary: "Decode a program account or transaction's instructions with the Anchor IDL", run: runDecodeCommand},
+	"devnet":      {name: "devnet", summary: "Fund the wallet, mint a test token and create a pool on devnet (airdrop, create-mint, create-pool)", run: runDevnetCommand},
+	"farm":        {name: "farm", summary: "Stake a pool's LP tokens in its Raydium farm and harvest the rewards (rewards, stake, unstake, harvest, compound)", run: runFarmCommand},
+	"history":     {name: "history", summary: "Export filled swaps for accounting, or list a wallet's transactions (export, wallet)", run: runHistoryCommand},
+	"inspect":     {name: "inspect", summary: "What a landed swap transaction paid, received and filled at, whoever sent it", run: runInspectCommand},
+	"limit":       {name: "limit", summary: "Swap once the quote crosses a trigger price", run: runLimitCommand},
+	"monitor":     {name: "monitor", summary: "Long running monitors (pool, wallet, new-pools)", run: runMonitorCommand},
+	"pool":        {name: "pool", summary: "Pool analytics (stats, candles, apr, depth)", run: runPoolCommand},
+	"pool-index":  {name: "pool-index", summary: "Local index of every pool pair lookups read instead of scanning (build, watch, list)", run: runPoolIndexCommand},
+	"position":    {name: "position", summary: "What an LP position has lost to price moves against holding (il)", run: runPositionCommand},
+	"portfolio":   {name: "portfolio", summary: "What the wallet holds and what it's worth in USDC", run: runPortfolioCommand},
+	"price":       {name: "price", summary: "Stream a pool's reserves and price as JSON lines on every change (stream)", run: runPriceCommand},
+	"rpc":         {name: "rpc", summary: "Check and rank the RPC endpoints by latency, slot lag, rate limits and websocket (check)", run: runRPCCommand},
+	"reclaim":     {name: "reclaim", summary: "Close empty token accounts and reclaim their rent", run: runReclaimCommand},
+	"send":        {name: "send", summary: "Send SOL or a token to another wallet, e.g. send 5 USDC <address>", run: runSendCommand},
+	"serve":       {name: "serve", summary: "HTTP JSON API for quotes and swaps", run: runServeCommand},
+	"snipe":       {name: "snipe", summary: "Swap the moment a pool opens, pre-built and capped by a max price", run: runSnipeCommand},
+	"stop":        {name: "stop", summary: "Sell a held token on stop-loss, take-profit or a trailing stop", run: runStopCommand},
+	"tape":        {name: "tape", summary: "Stream a pool's swaps live as they land", run: runTapeCommand},
+	"tokens":      {name: "tokens", summary: "Token list symbols fall back on (refresh, lookup)", run: runTokensCommand},
+	"tutorial":    {name: "tutorial", summary: "Guided first swap on devnet", run: runTutorialCommand},
+	"vectors":     {name: "vectors", summary: "Generate quote math test cases from real swap transactions", run: runVectorsCommand},
+	"why":         {name: "why", summary: "Explain why a swap transaction failed", run: runWhyCommand},
+}
+
+func lookupCommand(name string) (command, bool) {
+	cmd, ok := commands[name]
+	return cmd, ok
+}
+
+func commandUsage() string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "Commands:\n")
+	for _, name := range names {
+		fmt.Fprintf(b, "  %-12s %s\n", name, commands[name].summary)
+	}
+	return b.String()
+}
+
+// dispatchSubcommand takes the leading word out of args and runs the matching subcommand from table, for commands that
+// group several subcommands under one name (e.g. `monitor pool`).
+func dispatchSubcommand(parent string, table map[string]func(args []string) error, args []string) error {
+	names := make([]string, 0, len(table))
+	for name := range table {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("%s needs a subcommand, one of [%s]", parent, strings.Join(names, ", "))
+	}
+	run, ok := table[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown %s subcommand %q, expected one of [%s]", parent, args[0], strings.Join(names, ", "))
+	}
+	return run(args[1:])
+}
+
+// networkFlags are the flags every command that talks to the chain needs.
+type networkFlags struct {
+	rpcEP     *string
+	network   *string
+	aliases   *string
+	tokenList *string
+	poolIndex *string
+	enhanced  *string
+	raydium   *bool
+	fixtures  *rpcFixtureFlags
+}
+
+func addNetworkFlags(fs *flag.FlagSet) *networkFlags {
+	fs.Var(&deadlines, "deadlines", deadlinesUsage)
+	addPriceFlags(fs)
+	addExplorerFlag(fs)
+	addProgramFlags(fs)
+	addQuorumFlags(fs)
+	return &networkFlags{
+		rpcEP:     fs.String("rpc", "", "RPC to connect to, the -network's public endpoint when empty"),
+		network:   fs.String("network", "devnet", "Network to connect to, accepted values are 'mainnet', 'devnet' or 'localnet'"),
+		aliases:   addAliasesFlag(fs),
+		tokenList: addTokenListFlag(fs),
+		poolIndex: addPoolIndexFlag(fs),
+		enhanced:  addEnhancedAPIFlag(fs),
+		raydium:   addRaydiumAPIFlag(fs),
+		fixtures:  addRPCFixtureFlags(fs),
+	}
+}
+
+func (nf *networkFlags) specs() []FlagSpec {
+	return append([]FlagSpec{
+		{Name: "rpc", Value: nf.rpcEP, Rules: []FlagRule{Requires("network")}},
+		{Name: "network", Value: nf.network, Rules: []FlagRule{NotEmpty(), OneOf("mainnet", "devnet", "localnet")}},
+	}, priceSpecs()...)
+}
+
+// connect points the generated bindings at the right program deployment, settles the compute budget for the network
+// and returns a client for the RPC. It also loads the symbol aliases, token list and pool index and picks the
+// -enhanced-api provider, everything that connects goes on to load pools. With -rpc-record or -rpc-replay the client records its calls or answers them from fixtures (see
+// rpc_fixtures.go). Unless it's replaying, the RPC has to be on -network with the Raydium program deployed (see
+// cluster.go).
+func (nf *networkFlags) connect() *rpc.Client {
+	useAliasesFile(*nf.aliases)
+	useTokenListFile(*nf.tokenList)
+	useNetworkPrograms(*nf.network)
+	computeBudget.useNetwork(*nf.network)
+	usePoolIndexFile(*nf.poolIndex, *nf.network)
+	if len(*nf.rpcEP) == 0 {
+		*nf.rpcEP = networks[*nf.network][DefaultRPC].(string)
+	}
+	useEnhancedAPI(*nf.enhanced, *nf.rpcEP, *nf.network)
+	useRaydiumAPI(*nf.raydium, *nf.network)
+	client := nf.fixtures.dial(*nf.rpcEP)
+	useCluster(client, *nf.network, *nf.fixtures.replay != "")
+	return client
+}
+
+func runCommandOrExit(cmd command, args []string) {
+	if err := cmd.run(args); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.name, err)
+		os.Exit(exitCode(err))
+	}
+	os.Exit(0)
+}
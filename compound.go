@@ -0,0 +1,639 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+/*
+NOTE(@hadydotai): Auto-compounding.
+
+`farm compound <pool>` keeps a farmed position growing by itself. On a -schedule it harvests the farm's rewards (see
+farm.go), swaps each of them half and half into the pool's two tokens, deposits those into the pool and stakes the LP
+tokens that come out back in the farm. Left running, rewards become stake, which earns more rewards.
+
+A round only harvests once it's worth it. -min-harvest is the least of each reward that is ("5 RAY"), a round where
+nothing has reached it waits for the next one; without it anything pending is. A reward that's one of the pool's own
+tokens isn't swapped into that side, its half goes in as it is. The swaps are the same swaps a `-no-tui` intent sends
+(quote, slippage guard, drift check, hooks and webhooks), each on the CP-Swap pool for the reward and the side's token,
+and one whose price impact is over -max-impact waits for a later round rather than going through. The deposit is
+CP-Swap's own instruction from the generated bindings, sized off the pool's reserves less the fees it owes, like the
+program does, and asking for -slippage less LP than the amounts would mint so the price can move a little under it.
+The deposit and the stake go in one transaction, the new LP never sits in the wallet unstaked.
+
+What a round takes out of the farm but doesn't get back in (a swap over the cap, a deposit that failed) is carried to
+the next round, in memory: stopping leaves it in the wallet, where it's yours. What was harvested is read off the
+wallet's balances either side of the harvest, never assumed, so only what this run harvested is ever swapped or
+deposited, nothing else the wallet holds is touched. The deposit takes at most the amounts it's given, the odd unit
+rounding leaves over stays in the wallet.
+
+-dry-run previews a round without sending anything: what's pending, the swaps as quoted and the LP the deposit would
+mint, then exits.
+*/
+
+// harvestThresholds is -min-harvest, the least of each reward token worth harvesting, by mint.
+type harvestThresholds map[solana.PublicKey]*big.Int
+
+// parseMinHarvest reads -min-harvest, comma separated amounts of the farm's reward tokens, "5 RAY, 0.1 SOL".
+func parseMinHarvest(raw string, lf *loadedFarm) (harvestThresholds, error) {
+	out := harvestThresholds{}
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		amount, token, ok := strings.Cut(item, " ")
+		if !ok {
+			return nil, fmt.Errorf("-min-harvest %q needs an amount and a reward token, e.g. \"5 RAY\"", item)
+		}
+		token = strings.TrimSpace(token)
+		i := slices.IndexFunc(lf.rewards, func(m *mintAccount) bool { return m.Address.String() == token })
+		if i < 0 {
+			i = slices.IndexFunc(lf.symbols, func(sym string) bool { return normalizeSymbol(sym) == normalizeSymbol(token) })
+		}
+		if i < 0 {
+			return nil, fmt.Errorf("-min-harvest: %s isn't one of the farm's rewards (%s)", token, strings.Join(lf.symbols, ", "))
+		}
+		least, err := fmtForMath(amount, lf.rewards[i].Decimals)
+		if err != nil {
+			return nil, fmt.Errorf("-min-harvest: %w", err)
+		}
+		out[lf.rewards[i].Address] = least
+	}
+	return out, nil
+}
+
+// due reports whether pending, an amount per reward of lf, is worth harvesting: any reward at its threshold, or
+// anything pending at all without thresholds.
+func (th harvestThresholds) due(lf *loadedFarm, pending []*big.Int) bool {
+	for i, amount := range pending {
+		if amount.Sign() == 0 {
+			continue
+		}
+		least, ok := th[lf.rewards[i].Address]
+		if len(th) == 0 || (ok && amount.Cmp(least) >= 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// compoundLeg is the part of a harvested reward going into one side of the pool.
+type compoundLeg struct {
+	reward solana.PublicKey
+	side   int
+	amount *big.Int
+}
+
+// swaps reports whether the leg has to be swapped, a reward that's the side's token goes in as it is.
+func (l compoundLeg) swaps(pool [2]solana.PublicKey) bool {
+	return !l.reward.Equals(pool[l.side])
+}
+
+// splitRewards halves each of rewards across the pool's two sides, in order, the odd unit going to token1.
+func splitRewards(rewards map[solana.PublicKey]*big.Int, order []solana.PublicKey) []compoundLeg {
+	var legs []compoundLeg
+	for _, mint := range order {
+		amount := rewards[mint]
+		if amount == nil || amount.Sign() == 0 {
+			continue
+		}
+		half := new(big.Int).Rsh(amount, 1)
+		if half.Sign() > 0 {
+			legs = append(legs, compoundLeg{reward: mint, side: 0, amount: half})
+		}
+		legs = append(legs, compoundLeg{reward: mint, side: 1, amount: new(big.Int).Sub(amount, half)})
+	}
+	return legs
+}
+
+// depositReserves are the pool's vault balances less the fees it owes the protocol, fund and creator, what a deposit
+// is priced against.
+func depositReserves(pool *raydium_cp_swap.PoolState, vaults [2]*big.Int) [2]*big.Int {
+	owed := [2]uint64{
+		pool.ProtocolFeesToken0 + pool.FundFeesToken0 + pool.CreatorFeesToken0,
+		pool.ProtocolFeesToken1 + pool.FundFeesToken1 + pool.CreatorFeesToken1,
+	}
+	var out [2]*big.Int
+	for i := range out {
+		out[i] = new(big.Int).Sub(vaults[i], new(big.Int).SetUint64(owed[i]))
+		if out[i].Sign() < 0 {
+			out[i].SetInt64(0)
+		}
+	}
+	return out
+}
+
+// depositLP is the LP a deposit of at most amounts mints from a pool with reserves and supply LP out, less slippage,
+// zero when either side is empty.
+func depositLP(amounts, reserves [2]*big.Int, supply *big.Int, slippage *big.Rat) *big.Int {
+	var lp *big.Int
+	for i := range amounts {
+		if amounts[i].Sign() == 0 || reserves[i].Sign() == 0 {
+			return new(big.Int)
+		}
+		side := new(big.Int).Mul(amounts[i], supply)
+		side.Quo(side, reserves[i])
+		if lp == nil || side.Cmp(lp) < 0 {
+			lp = side
+		}
+	}
+	keep := new(big.Rat).Sub(big.NewRat(1, 1), slippage)
+	return lp.Mul(lp, keep.Num()).Quo(lp, keep.Denom())
+}
+
+// compounder runs `farm compound`, round after round on one pool and its farm.
+type compounder struct {
+	ctx        context.Context
+	client     *rpc.Client
+	payer      solana.PrivateKey
+	network    string
+	pool       solana.PublicKey
+	farm       string // the farm's address
+	slippage   float64
+	slipRat    *big.Rat // slippage as a fraction, what the deposit gives up
+	maxImpact  *big.Rat
+	thresholds harvestThresholds
+	builders   map[solana.PublicKey]*TableBuilder // the swap pools, by address
+
+	// rewards and sides are what earlier rounds harvested and didn't get into the pool, by reward token and by pool
+	// side once swapped.
+	rewards    map[solana.PublicKey]*big.Int
+	sides      [2]*big.Int
+	staked     *big.Int // LP this run has staked
+	lpDecimals uint8
+}
+
+// roundState is a round's view of the pool and farm, read fresh at the start of it.
+type roundState struct {
+	lp  *loadedPool
+	lf  *loadedFarm
+	pos *farmPosition
+}
+
+func (c *compounder) load() (*roundState, error) {
+	ctx, cancel := deadlines.forQuote(c.ctx)
+	defer cancel()
+	lp, err := loadPool(ctx, c.client, c.pool)
+	if err != nil {
+		return nil, err
+	}
+	if lp.mints[0] == nil || lp.mints[1] == nil {
+		return nil, fmt.Errorf("couldn't read the mints of pool %s", Addr(c.pool.String()))
+	}
+	lf, err := loadFarm(ctx, c.client, c.network, c.farm)
+	if err != nil {
+		return nil, err
+	}
+	if !lf.state.lpMint.Equals(lp.pool.LpMint) {
+		return nil, fmt.Errorf("farm %s stakes %s, not pool %s's LP token", Addr(lf.state.address.String()), Addr(lf.state.lpMint.String()), Addr(c.pool.String()))
+	}
+	pos, err := readFarmPosition(ctx, c.client, lf, c.payer.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+	return &roundState{lp: lp, lf: lf, pos: pos}, nil
+}
+
+// builder is the quote builder for the pool swapping mint into the side's token, the pool itself when it's the pair.
+func (c *compounder) builder(rs *roundState, mint solana.PublicKey, side int) (*TableBuilder, error) {
+	tokens := [2]solana.PublicKey{rs.lp.pool.Token0Mint, rs.lp.pool.Token1Mint}
+	pool := c.pool
+	if !mint.Equals(tokens[1-side]) {
+		ctx, cancel := deadlines.forQuote(c.ctx)
+		found, err := resolvePoolTarget(ctx, c.client, mint.String()+"/"+tokens[side].String(), SymbolMapping{})
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		pool = found
+	}
+	if b, ok := c.builders[pool]; ok {
+		return b, nil
+	}
+	loaded, err := loadPool(c.ctx, c.client, pool)
+	if err != nil {
+		return nil, err
+	}
+	b, err := newTableBuilder(c.ctx, c.client, loaded, c.slippage)
+	if err != nil {
+		return nil, err
+	}
+	b.useWallet(c.payer.PublicKey())
+	c.builders[pool] = b
+	return b, nil
+}
+
+// quoteLeg quotes selling the leg's reward for its side's token, refusing a quote over -max-impact.
+func (c *compounder) quoteLeg(rs *roundState, leg compoundLeg) (*TableBuilder, *CPIntent, error) {
+	b, err := c.builder(rs, leg.reward, leg.side)
+	if err != nil {
+		return nil, nil, err
+	}
+	i := slices.IndexFunc(rs.lf.rewards, func(m *mintAccount) bool { return m.Address.Equals(leg.reward) })
+	if i < 0 {
+		return nil, nil, fmt.Errorf("%s isn't one of farm %s's rewards", Addr(leg.reward.String()), Addr(rs.lf.state.address.String()))
+	}
+	dec := rs.lf.rewards[i].Decimals
+	line := fmt.Sprintf("sell %s %s", fmtForDisplay(leg.amount, dec, int(dec)), b.symbols().SymFrom(leg.reward))
+	_, intent, err := b.Build(line)
+	if err != nil {
+		return nil, nil, err
+	}
+	impact, err := intent.PriceImpact()
+	if err != nil {
+		return nil, nil, err
+	}
+	if impact.Cmp(c.maxImpact) > 0 {
+		return nil, nil, fmt.Errorf("%s: price impact %s is over the %s cap, waiting for a later round", line, pctString(impact), pctString(c.maxImpact))
+	}
+	return b, intent, nil
+}
+
+// rewardBalances is what the wallet holds of each of lf's reward tokens.
+func (c *compounder) rewardBalances(lf *loadedFarm) ([]*big.Int, error) {
+	ctx, cancel := deadlines.forQuote(c.ctx)
+	defer cancel()
+	out := make([]*big.Int, len(lf.rewards))
+	for i, mint := range lf.rewards {
+		balance, err := walletTokenBalance(ctx, c.client, c.payer.PublicKey(), mint.Address, mint.Program)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = balance
+	}
+	return out, nil
+}
+
+// harvest claims what's pending and carries what the wallet's balances say came in.
+func (c *compounder) harvest(rs *roundState) error {
+	before, err := c.rewardBalances(rs.lf)
+	if err != nil {
+		return err
+	}
+	action, err := planFarmAction(rs.lf, c.payer.PublicKey(), "harvest", new(big.Int))
+	if err != nil {
+		return err
+	}
+	if _, err := sendFarmInstructions(c.ctx, c.client, c.payer, c.network, action.instructions); err != nil {
+		return fmt.Errorf("harvesting failed: %w", err)
+	}
+	after, err := c.rewardBalances(rs.lf)
+	if err != nil {
+		return fmt.Errorf("harvested, but reading what came in failed, it stays in the wallet: %w", err)
+	}
+	for i, mint := range rs.lf.rewards {
+		got := new(big.Int).Sub(after[i], before[i])
+		if got.Sign() <= 0 {
+			continue
+		}
+		c.rewards[mint.Address] = new(big.Int).Add(c.carried(mint.Address), got)
+		log.Printf("compound: harvested %s", formatTokenAmount(got, mint.Decimals, rs.lf.symbols[i]))
+	}
+	return nil
+}
+
+func (c *compounder) carried(mint solana.PublicKey) *big.Int {
+	if amount, ok := c.rewards[mint]; ok {
+		return amount
+	}
+	return new(big.Int)
+}
+
+// swap sends the legs that need swapping and moves what each brought in over to its side. A leg that fails stops the
+// round, what it and the legs after it hold is carried.
+func (c *compounder) swap(rs *roundState) error {
+	tokens := [2]solana.PublicKey{rs.lp.pool.Token0Mint, rs.lp.pool.Token1Mint}
+	for _, leg := range splitRewards(c.rewards, rewardMints(rs.lf)) {
+		got := leg.amount
+		if leg.swaps(tokens) {
+			b, intent, err := c.quoteLeg(rs, leg)
+			if err != nil {
+				return err
+			}
+			summary, _, err := executeIntent(c.ctx, c.client, c.payer, b, intent)
+			if err != nil {
+				return fmt.Errorf("swapping %s failed: %w", intent, err)
+			}
+			if got = summary.ReceivedAmount; got == nil {
+				// Sent, but the balances couldn't be read back, the quote's minimum is what it got at least.
+				got = intent.Amounts.MinAmountOut
+			}
+		}
+		c.rewards[leg.reward] = new(big.Int).Sub(c.rewards[leg.reward], leg.amount)
+		c.sides[leg.side].Add(c.sides[leg.side], got)
+	}
+	return nil
+}
+
+// rewardMints are lf's reward tokens in slot order.
+func rewardMints(lf *loadedFarm) []solana.PublicKey {
+	out := make([]solana.PublicKey, len(lf.rewards))
+	for i, mint := range lf.rewards {
+		out[i] = mint.Address
+	}
+	return out
+}
+
+// depositInstructions deposit amounts into the pool for lp LP and stake it, wrapping SOL for a side that's SOL.
+func (c *compounder) depositInstructions(rs *roundState, amounts [2]*big.Int, lp *big.Int) ([]solana.Instruction, error) {
+	owner := c.payer.PublicKey()
+	pool := rs.lp.pool
+	authority, err := swapAuthority()
+	if err != nil {
+		return nil, err
+	}
+	userLP, err := associatedTokenAddress(owner, pool.LpMint, rs.lf.lpMint.Program)
+	if err != nil {
+		return nil, err
+	}
+	ixs := []solana.Instruction{createATAInstruction(owner, userLP, owner, pool.LpMint, rs.lf.lpMint.Program)}
+	wsol, err := newWSOLManager(c.client, owner)
+	if err != nil {
+		return nil, err
+	}
+	var accounts [2]solana.PublicKey
+	for i, mint := range []solana.PublicKey{pool.Token0Mint, pool.Token1Mint} {
+		if isNativeSOL(mint) {
+			ctx, cancel := deadlines.forQuote(c.ctx)
+			account, wrap, err := wsol.prepareInput(ctx, amounts[i])
+			cancel()
+			if err != nil {
+				return nil, err
+			}
+			accounts[i], ixs = account, append(ixs, wrap...)
+			continue
+		}
+		if accounts[i], err = associatedTokenAddress(owner, mint, rs.lp.mints[i].Program); err != nil {
+			return nil, err
+		}
+	}
+	deposit, err := raydium_cp_swap.NewDepositInstruction(
+		lp.Uint64(), amounts[0].Uint64(), amounts[1].Uint64(),
+		owner, authority, rs.lp.address, userLP, accounts[0], accounts[1], pool.Token0Vault, pool.Token1Vault,
+		solana.TokenProgramID, solana.Token2022ProgramID, pool.Token0Mint, pool.Token1Mint, pool.LpMint,
+	)
+	if err != nil {
+		return nil, err
+	}
+	stake, err := planFarmAction(rs.lf, owner, "stake", lp)
+	if err != nil {
+		return nil, err
+	}
+	closes, err := wsol.closeInstructions()
+	if err != nil {
+		return nil, err
+	}
+	ixs = append(append(append(ixs, deposit), stake.instructions...), closes...)
+	return ixs, nil
+}
+
+// deposit puts what the swaps brought in into the pool and stakes the LP it mints.
+func (c *compounder) deposit(rs *roundState) error {
+	if rs.lp.pool.Status&1 != 0 {
+		return fmt.Errorf("pool %s has deposits disabled, carrying the round", Addr(c.pool.String()))
+	}
+	ctx, cancel := deadlines.forQuote(c.ctx)
+	balances, errs := poolReserves(ctx, c.client, rs.lp.pool, rs.lp.mints)
+	cancel()
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("reading the pool's reserves failed: %w", err)
+	}
+	reserves := depositReserves(rs.lp.pool, [2]*big.Int{balances[0].Balance, balances[1].Balance})
+	lp := depositLP(c.sides, reserves, new(big.Int).SetUint64(rs.lp.pool.LpSupply), c.slipRat)
+	if lp.Sign() == 0 {
+		return errors.New("what's been swapped so far mints no LP, carrying it to the next round")
+	}
+	ixs, err := c.depositInstructions(rs, c.sides, lp)
+	if err != nil {
+		return err
+	}
+	if _, err := sendFarmInstructions(c.ctx, c.client, c.payer, c.network, ixs); err != nil {
+		return fmt.Errorf("depositing failed: %w", err)
+	}
+	c.staked.Add(c.staked, lp)
+	c.sides = [2]*big.Int{new(big.Int), new(big.Int)}
+	log.Printf("compound: staked %s LP more", fmtAmount(lp, rs.lp.pool.LpMintDecimals))
+	return nil
+}
+
+// round is one harvest, swap, deposit and stake, whatever of it there's something to do for.
+func (c *compounder) round() error {
+	rs, err := c.load()
+	if err != nil {
+		return err
+	}
+	if c.thresholds.due(rs.lf, rs.pos.pending) {
+		if err := c.harvest(rs); err != nil {
+			return err
+		}
+	} else if len(splitRewards(c.rewards, rewardMints(rs.lf))) == 0 && c.sides[0].Sign() == 0 && c.sides[1].Sign() == 0 {
+		log.Printf("compound: nothing pending has reached -min-harvest yet")
+		return nil
+	}
+	if err := c.swap(rs); err != nil {
+		return err
+	}
+	return c.deposit(rs)
+}
+
+// preview is -dry-run, a round with what's pending as the harvest, quoted instead of sent.
+func (c *compounder) preview() (string, error) {
+	rs, err := c.load()
+	if err != nil {
+		return "", err
+	}
+	builder := &strings.Builder{}
+	t := table.NewWriter()
+	t.SetOutputMirror(builder)
+	t.SetTitle(fmt.Sprintf("Compound preview, farm %s", Addr(rs.lf.state.address.String())))
+	t.AppendHeader(table.Row{"Step", "Amounts"})
+	pending := map[solana.PublicKey]*big.Int{}
+	for i, mint := range rs.lf.rewards {
+		pending[mint.Address] = rs.pos.pending[i]
+		t.AppendRow(table.Row{"Harvest", formatTokenAmount(rs.pos.pending[i], mint.Decimals, rs.lf.symbols[i])})
+	}
+	if !c.thresholds.due(rs.lf, rs.pos.pending) {
+		t.AppendRow(table.Row{"", "nothing pending has reached -min-harvest, a round now would wait"})
+		t.Render()
+		return builder.String(), nil
+	}
+	tokens := [2]solana.PublicKey{rs.lp.pool.Token0Mint, rs.lp.pool.Token1Mint}
+	symbols := [2]string{rs.lp.symbolsMap.SymFrom(tokens[0]), rs.lp.symbolsMap.SymFrom(tokens[1])}
+	decimals := [2]uint8{rs.lp.mints[0].Decimals, rs.lp.mints[1].Decimals}
+	amounts := [2]*big.Int{new(big.Int), new(big.Int)}
+	for _, leg := range splitRewards(pending, rewardMints(rs.lf)) {
+		got := leg.amount
+		if leg.swaps(tokens) {
+			_, intent, err := c.quoteLeg(rs, leg)
+			if err != nil {
+				t.AppendRow(table.Row{"Swap", err.Error()})
+				continue
+			}
+			_, got = intent.QuotedInOut()
+			t.AppendRow(table.Row{"Swap", fmt.Sprintf("%s for %s", intent, formatTokenAmount(got, decimals[leg.side], symbols[leg.side]))})
+		}
+		amounts[leg.side].Add(amounts[leg.side], got)
+	}
+	ctx, cancel := deadlines.forQuote(c.ctx)
+	balances, errs := poolReserves(ctx, c.client, rs.lp.pool, rs.lp.mints)
+	cancel()
+	if err := errors.Join(errs...); err != nil {
+		return "", fmt.Errorf("reading the pool's reserves failed: %w", err)
+	}
+	reserves := depositReserves(rs.lp.pool, [2]*big.Int{balances[0].Balance, balances[1].Balance})
+	lp := depositLP(amounts, reserves, new(big.Int).SetUint64(rs.lp.pool.LpSupply), c.slipRat)
+	t.AppendRow(table.Row{"Deposit", fmt.Sprintf("up to %s + %s", formatTokenAmount(amounts[0], decimals[0], symbols[0]), formatTokenAmount(amounts[1], decimals[1], symbols[1]))})
+	t.AppendRow(table.Row{"Stake", fmt.Sprintf("%s LP, %s LP staked after", fmtAmount(lp, rs.lp.pool.LpMintDecimals), fmtAmount(new(big.Int).Add(rs.pos.deposited(), lp), rs.lp.pool.LpMintDecimals))})
+	t.Render()
+	return builder.String(), nil
+}
+
+// run rounds on sched until the context is done, a failed round is logged and what it held is carried.
+func (c *compounder) run(sched schedule) error {
+	defer func() {
+		log.Printf("compound: stopped, %s LP staked by this run", fmtAmount(c.staked, c.lpDecimals))
+		for mint, amount := range c.rewards {
+			if amount.Sign() > 0 {
+				log.Printf("compound: %s raw units of reward %s were harvested and are left in the wallet", amount, Addr(mint.String()))
+			}
+		}
+	}()
+	for {
+		if err := c.round(); err != nil {
+			if c.ctx.Err() != nil {
+				return nil
+			}
+			log.Printf("compound: %v", err)
+		}
+		at := sched.next(time.Now())
+		if at.IsZero() {
+			return fmt.Errorf("schedule %q never fires", sched)
+		}
+		log.Printf("compound: next round at %s", at.Local().Format(time.DateTime))
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-c.ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+func runFarmCompoundCommand(args []string) error {
+	fs := flag.NewFlagSet("farm compound", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: farm compound [flags] <pool address or pair> -hotwallet <path>\n")
+		fs.PrintDefaults()
+	}
+	nf := addNetworkFlags(fs)
+	addComputeBudgetFlags(fs)
+	var (
+		hotwalletPath = fs.String("hotwallet", "", "Path to the hotwallet whose stake is compounded")
+		farmAddr      = fs.String("farm", "", "The farm to compound in, when the pool's LP token has more than one")
+		scheduleSpec  = fs.String("schedule", "@every 6h", "When to run a round, \"@every 6h\", \"@daily\" or a five field cron line")
+		minHarvest    = fs.String("min-harvest", "", "Least of each reward worth harvesting, e.g. \"5 RAY, 0.1 SOL\", anything pending is when empty")
+		slippagePct   = fs.Float64("slippage", 0.5, "Slippage tolerance percentage for the swaps and the deposit")
+		maxImpactPct  = fs.Float64("max-impact", 1, "Leave a reward for a later round when swapping it has a price impact (including the trade fee) above this percentage")
+		dryRun        = fs.Bool("dry-run", false, "Preview a round, what's pending, the swaps as quoted and the LP deposited, nothing is sent")
+	)
+	addAmountFlags(fs)
+	target, args := splitFarmTarget(args)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, append(nf.specs(),
+		FlagSpec{Name: "hotwallet", Value: hotwalletPath, Rules: []FlagRule{NotEmpty()}},
+		FlagSpec{Name: "schedule", Value: scheduleSpec, Rules: []FlagRule{NotEmpty()}},
+	))
+	if target == "" && fs.NArg() > 0 {
+		target = fs.Arg(0)
+	}
+	if target == "" {
+		fs.Usage()
+		return errors.New("missing pool")
+	}
+	if *maxImpactPct <= 0 {
+		return errors.New("max-impact must be greater than zero")
+	}
+	slipRat, err := makeSlippageRatio(*slippagePct)
+	if err != nil {
+		return err
+	}
+	sched, err := parseSchedule(*scheduleSpec)
+	if err != nil {
+		return err
+	}
+	maxImpact, ok := new(big.Rat).SetString(fmt.Sprintf("%g", *maxImpactPct))
+	if !ok {
+		return fmt.Errorf("invalid max-impact %v", *maxImpactPct)
+	}
+	maxImpact.Quo(maxImpact, big.NewRat(100, 1))
+	payer, err := solana.PrivateKeyFromSolanaKeygenFile(*hotwalletPath)
+	if err != nil {
+		return fmt.Errorf("failed to load private key from hot wallet: %w", err)
+	}
+	client := nf.connect()
+	ctx, stop := interruptContext()
+	defer stop()
+
+	quoteCtx, cancel := deadlines.forQuote(ctx)
+	poolAddr, err := resolvePoolTarget(quoteCtx, client, target, SymbolMapping{})
+	if err != nil {
+		cancel()
+		return err
+	}
+	farm := cmp.Or(*farmAddr, poolAddr.String())
+	lf, err := loadFarm(quoteCtx, client, *nf.network, farm)
+	cancel()
+	if err != nil {
+		return err
+	}
+	thresholds, err := parseMinHarvest(*minHarvest, lf)
+	if err != nil {
+		return err
+	}
+	c := &compounder{
+		ctx:        ctx,
+		client:     client,
+		payer:      payer,
+		network:    *nf.network,
+		pool:       poolAddr,
+		farm:       lf.state.address.String(),
+		slippage:   *slippagePct,
+		slipRat:    slipRat,
+		maxImpact:  maxImpact,
+		thresholds: thresholds,
+		builders:   map[solana.PublicKey]*TableBuilder{},
+		rewards:    map[solana.PublicKey]*big.Int{},
+		sides:      [2]*big.Int{new(big.Int), new(big.Int)},
+		staked:     new(big.Int),
+		lpDecimals: lf.lpMint.Decimals,
+	}
+	if *dryRun {
+		out, err := c.preview()
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(os.Stdout, out)
+		fmt.Println("Dry run, nothing was sent.")
+		return nil
+	}
+	return c.run(sched)
+}
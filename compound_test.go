@@ -0,0 +1,79 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+func TestSplitRewards(t *testing.T) {
+	ray, usdc := snapshotKey(81), snapshotKey(82)
+	pool := [2]solana.PublicKey{wSOLMint, usdc}
+	legs := splitRewards(map[solana.PublicKey]*big.Int{ray: big.NewInt(1001), usdc: big.NewInt(40)}, []solana.PublicKey{ray, usdc})
+	if len(legs) != 4 {
+		t.Fatalf("legs %+v", legs)
+	}
+	// RAY is swapped into both sides, the odd unit going to token1.
+	if legs[0].amount.Int64() != 500 || legs[1].amount.Int64() != 501 || !legs[0].swaps(pool) || !legs[1].swaps(pool) {
+		t.Errorf("RAY legs %+v %+v", legs[0], legs[1])
+	}
+	// A reward that's the pool's own token goes into its side as it is.
+	if legs[2].side != 0 || !legs[2].swaps(pool) || legs[3].side != 1 || legs[3].swaps(pool) {
+		t.Errorf("USDC legs %+v %+v", legs[2], legs[3])
+	}
+	if legs := splitRewards(map[solana.PublicKey]*big.Int{ray: big.NewInt(1)}, []solana.PublicKey{ray}); len(legs) != 1 || legs[0].side != 1 {
+		t.Errorf("a single unit split into %+v", legs)
+	}
+}
+
+func TestDepositLP(t *testing.T) {
+	pool := &raydium_cp_swap.PoolState{ProtocolFeesToken0: 400, FundFeesToken0: 100, CreatorFeesToken1: 1_000}
+	reserves := depositReserves(pool, [2]*big.Int{big.NewInt(100_000_500), big.NewInt(15_000_001_000)})
+	if reserves[0].Int64() != 100_000_000 || reserves[1].Int64() != 15_000_000_000 {
+		t.Fatalf("reserves %s, %s", reserves[0], reserves[1])
+	}
+	supply := big.NewInt(1_000_000)
+	// 1% of token0 but only half a percent of token1, the deposit is held to the smaller side.
+	amounts := [2]*big.Int{big.NewInt(1_000_000), big.NewInt(75_000_000)}
+	if got := depositLP(amounts, reserves, supply, new(big.Rat)); got.Int64() != 5_000 {
+		t.Errorf("LP %s", got)
+	}
+	if got := depositLP(amounts, reserves, supply, big.NewRat(1, 100)); got.Int64() != 4_950 {
+		t.Errorf("LP less 1%% slippage %s", got)
+	}
+	if got := depositLP([2]*big.Int{big.NewInt(0), big.NewInt(75_000_000)}, reserves, supply, new(big.Rat)); got.Sign() != 0 {
+		t.Errorf("one sided deposit mints %s", got)
+	}
+}
+
+func TestHarvestThresholds(t *testing.T) {
+	lf := &loadedFarm{
+		rewards: []*mintAccount{{Address: snapshotKey(81), Decimals: 6}, {Address: snapshotKey(82), Decimals: 9}},
+		symbols: []string{"RAY", "SOL"},
+	}
+	th, err := parseMinHarvest("5 ray, 0.1 "+snapshotKey(82).String(), lf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if th[snapshotKey(81)].Int64() != 5_000_000 || th[snapshotKey(82)].Int64() != 100_000_000 {
+		t.Fatalf("thresholds %v", th)
+	}
+	if th.due(lf, []*big.Int{big.NewInt(4_999_999), big.NewInt(99_999_999)}) {
+		t.Error("due under both thresholds")
+	}
+	if !th.due(lf, []*big.Int{big.NewInt(0), big.NewInt(100_000_000)}) {
+		t.Error("not due at the SOL threshold")
+	}
+	none, _ := parseMinHarvest("", lf)
+	if !none.due(lf, []*big.Int{big.NewInt(1), big.NewInt(0)}) || none.due(lf, []*big.Int{big.NewInt(0), big.NewInt(0)}) {
+		t.Error("without thresholds anything pending is due, and nothing isn't")
+	}
+	for _, bad := range []string{"5", "5 BONK", "five RAY"} {
+		if _, err := parseMinHarvest(bad, lf); err == nil {
+			t.Errorf("%q parsed", bad)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	solana "github.com/gagliardetto/solana-go"
+	computebudget "github.com/gagliardetto/solana-go/programs/compute-budget"
+)
+
+/*
+NOTE(@hadydotai): Compute budget.
+
+Every swap transaction opens with two compute budget instructions, the most compute units it may use and the
+priority fee per unit, in micro-lamports. Both used to be constants, with a unit limit far past the 1.4M the runtime
+allows, so every swap was priced as if it used all of it. What it actually pays in priority fee is limit * price, so
+the limit wants to be close to what the swap needs and the price close to what gets it in, and the second depends on
+the network. Nobody's competing for devnet blockspace, on mainnet during a launch everybody is.
+
+So they're a preset, picked per network unless -fee-preset says otherwise:
+
+  - low: cheapest that still tends to land, the devnet default
+  - normal: the mainnet default
+  - turbo: for when the swap has to go in now
+
+-cu-limit and -cu-price override either half of whatever preset applies. The limit covers a swap split three ways
+with its accounts created and SOL wrapped, what simulating a few of those came to plus headroom.
+*/
+
+// maxComputeUnitLimit is the most a transaction can ask for, the runtime rejects anything above it.
+const maxComputeUnitLimit = 1_400_000
+
+type feePreset struct {
+	unitLimit uint32
+	unitPrice uint64 // micro-lamports per compute unit
+}
+
+var (
+	feePresets = map[string]map[string]feePreset{
+		"devnet": {
+			"low":    {unitLimit: 400_000, unitPrice: 0},
+			"normal": {unitLimit: 400_000, unitPrice: 1_000},
+			"turbo":  {unitLimit: 400_000, unitPrice: 10_000},
+		},
+		"mainnet": {
+			"low":    {unitLimit: 400_000, unitPrice: 1_000},
+			"normal": {unitLimit: 400_000, unitPrice: 50_000},
+			"turbo":  {unitLimit: 400_000, unitPrice: 1_000_000},
+		},
+		"localnet": {
+			"low":    {unitLimit: 400_000, unitPrice: 0},
+			"normal": {unitLimit: 400_000, unitPrice: 0},
+			"turbo":  {unitLimit: 400_000, unitPrice: 0},
+		},
+	}
+	defaultFeePresets = map[string]string{
+		"devnet":   "low",
+		"mainnet":  "normal",
+		"localnet": "low",
+	}
+)
+
+// computeBudgetPolicy is -fee-preset, -cu-limit and -cu-price, resolved against the network by useNetwork.
+type computeBudgetPolicy struct {
+	preset    string  // empty is the network's default
+	unitLimit uint32  // 0 takes the preset's
+	unitPrice *uint64 // nil takes the preset's, zero is a price like any other
+
+	resolved feePreset
+}
+
+// computeBudget is set by the compute budget flags, every swap transaction is built with it.
+var computeBudget = computeBudgetPolicy{resolved: feePresets["devnet"][defaultFeePresets["devnet"]]}
+
+func feePresetNames() []string {
+	names := make([]string, 0, len(feePresets["mainnet"]))
+	for name := range feePresets["mainnet"] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func addComputeBudgetFlags(fs *flag.FlagSet) {
+	fs.Func("fee-preset", fmt.Sprintf("Priority fee preset, one of [%s] (low on devnet, normal on mainnet when unset)", strings.Join(feePresetNames(), ", ")), func(s string) error {
+		name, err := parseFeePreset(s)
+		if err != nil {
+			return err
+		}
+		computeBudget.preset = name
+		return nil
+	})
+	fs.Func("cu-limit", fmt.Sprintf("Compute unit limit of a swap transaction, up to %d (the fee preset's when unset)", maxComputeUnitLimit), func(s string) error {
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return err
+		}
+		if err := checkUnitLimit(n); err != nil {
+			return err
+		}
+		computeBudget.unitLimit = uint32(n)
+		return nil
+	})
+	fs.Func("cu-price", "Priority fee in micro-lamports per compute unit (the fee preset's when unset)", func(s string) error {
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		computeBudget.unitPrice = &n
+		return nil
+	})
+}
+
+// parseFeePreset is the preset s names.
+func parseFeePreset(s string) (string, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if _, ok := feePresets["mainnet"][name]; !ok {
+		return "", fmt.Errorf("unknown fee preset %q, expected one of [%s]", s, strings.Join(feePresetNames(), ", "))
+	}
+	return name, nil
+}
+
+func checkUnitLimit(n uint64) error {
+	if n == 0 || n > maxComputeUnitLimit {
+		return fmt.Errorf("compute unit limit has to be between 1 and %d", maxComputeUnitLimit)
+	}
+	return nil
+}
+
+// useNetwork settles the budget for network, the preset first and the flags over it.
+func (p *computeBudgetPolicy) useNetwork(network string) {
+	preset := p.preset
+	if preset == "" {
+		preset = defaultFeePresets[network]
+	}
+	p.resolved = feePresets[network][preset]
+	if p.unitLimit != 0 {
+		p.resolved.unitLimit = p.unitLimit
+	}
+	if p.unitPrice != nil {
+		p.resolved.unitPrice = *p.unitPrice
+	}
+}
+
+// instructions are the compute budget instructions a swap transaction opens with.
+func (p *computeBudgetPolicy) instructions() []solana.Instruction {
+	return p.resolved.instructions()
+}
+
+func (p feePreset) instructions() []solana.Instruction {
+	return []solana.Instruction{
+		computebudget.NewSetComputeUnitLimitInstruction(p.unitLimit).Build(),
+		computebudget.NewSetComputeUnitPriceInstruction(p.unitPrice).Build(),
+	}
+}
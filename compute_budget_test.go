@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"io"
+	"testing"
+)
+
+func parseComputeBudgetFlags(t *testing.T, args ...string) error {
+	t.Helper()
+	prev := computeBudget
+	t.Cleanup(func() { computeBudget = prev })
+	computeBudget = computeBudgetPolicy{}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	addComputeBudgetFlags(fs)
+	return fs.Parse(args)
+}
+
+func TestComputeBudgetResolves(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		network string
+		args    []string
+		want    feePreset
+	}{
+		{"devnet default", "devnet", nil, feePresets["devnet"]["low"]},
+		{"mainnet default", "mainnet", nil, feePresets["mainnet"]["normal"]},
+		{"preset", "mainnet", []string{"-fee-preset", "Turbo"}, feePresets["mainnet"]["turbo"]},
+		{"limit over the preset", "mainnet", []string{"-fee-preset", "low", "-cu-limit", "250000"}, feePreset{unitLimit: 250_000, unitPrice: 1_000}},
+		{"no priority fee", "mainnet", []string{"-cu-price", "0"}, feePreset{unitLimit: 400_000, unitPrice: 0}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := parseComputeBudgetFlags(t, tc.args...); err != nil {
+				t.Fatal(err)
+			}
+			computeBudget.useNetwork(tc.network)
+			if computeBudget.resolved != tc.want {
+				t.Fatalf("resolved %+v, want %+v", computeBudget.resolved, tc.want)
+			}
+			ixs := computeBudget.instructions()
+			limit, _ := ixs[0].Data()
+			price, _ := ixs[1].Data()
+			if limit[0] != 2 || binary.LittleEndian.Uint32(limit[1:]) != tc.want.unitLimit {
+				t.Errorf("unit limit instruction %x", limit)
+			}
+			if price[0] != 3 || binary.LittleEndian.Uint64(price[1:]) != tc.want.unitPrice {
+				t.Errorf("unit price instruction %x", price)
+			}
+		})
+	}
+}
+
+func TestComputeBudgetFlagsRejected(t *testing.T) {
+	for _, args := range [][]string{
+		{"-fee-preset", "ludicrous"},
+		{"-cu-limit", "0"},
+		{"-cu-limit", "1400001"},
+		{"-cu-price", "-1"},
+	} {
+		if err := parseComputeBudgetFlags(t, args...); err == nil {
+			t.Errorf("%v was accepted", args)
+		}
+	}
+}
@@ -36,7 +36,7 @@ func ValidateConfigOrExit(fs *flag.FlagSet, specs []FlagSpec) {
 		fmt.Fprintf(os.Stderr, "configuration error: %v\n\n", err)
 		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
 		fs.PrintDefaults()
-		os.Exit(2)
+		os.Exit(exitUsage)
 	}
 }
 
@@ -87,7 +87,7 @@ func (cp ConstantProduct) QuoteOut(amountIn *big.Int) (*big.Int, error) {
 		return nil, errors.New("pool balances unavailable for quote out")
 	}
 	if cp.TokenInReserve.Balance.Sign() <= 0 || cp.TokenOutReserve.Balance.Sign() <= 0 {
-		return nil, errors.New("pool reserves must be greater than zero for quote out")
+		return nil, classify(ErrInsufficientLiquidity, errors.New("pool reserves must be greater than zero for quote out"))
 	}
 
 	// X, Y initial reserves
@@ -106,23 +106,22 @@ func (cp ConstantProduct) QuoteOut(amountIn *big.Int) (*big.Int, error) {
 	//					=> our target amountOut will be subtracting initial reserve from the new reserve
 	//				Y - {newReserveOut} = dY
 	//	science.
+	//
+	// NOTE(@hadydotai): Which is dY = (dX * Y) / (X + dX), and that's how the program computes it, rounding the
+	// division down. Working it out as Y - floor(K / (X + dX)) instead rounds the other way and promises one base
+	// unit more than the program pays whenever the division isn't exact, a min-out at zero slippage would fail.
 	reserveIn, reserveOut := cp.TokenInReserve.Balance, cp.TokenOutReserve.Balance
 	netAmountIn, err := cp.amountAfterTradeFee(amountIn)
 	if err != nil {
 		return nil, err
 	}
-	constantProductK := new(big.Int).Mul(reserveIn, reserveOut)
 	updatedReserveIn := new(big.Int).Add(reserveIn, netAmountIn)
-	newReserveOut := new(big.Int).Quo(constantProductK, updatedReserveIn)
-	amountOut := new(big.Int).Sub(reserveOut, newReserveOut)
+	amountOut := new(big.Int).Mul(netAmountIn, reserveOut)
+	amountOut.Quo(amountOut, updatedReserveIn)
 	if amountOut.Sign() <= 0 {
 		// NOTE(@hadydotai): we'd only end up here if we math our way into draining the pool on one side,
 		// I think this should be a flat out error and yell at the user for it, maybe?
-		return nil, errors.New("trade would not yield a positive output amount")
-	}
-	if amountOut.Cmp(reserveOut) >= 0 {
-		available := fmtForDisplay(reserveOut, cp.TokenOutReserve.Decimals, int(cp.TokenOutReserve.Decimals))
-		return nil, fmt.Errorf("requested output would exceed available %s liquidity", available)
+		return nil, classify(ErrInsufficientLiquidity, errors.New("trade would not yield a positive output amount"))
 	}
 	return amountOut, nil
 }
@@ -144,7 +143,7 @@ func (cp ConstantProduct) QuoteIn(amountOut *big.Int) (*big.Int, error) {
 		return nil, errors.New("pool balances unavailable for quote in")
 	}
 	if cp.TokenInReserve.Balance.Sign() <= 0 || cp.TokenOutReserve.Balance.Sign() <= 0 {
-		return nil, errors.New("pool reserves must be greater than zero for quote in")
+		return nil, classify(ErrInsufficientLiquidity, errors.New("pool reserves must be greater than zero for quote in"))
 	}
 	// X, Y initial reserves
 	// pre-swap:  	K = X*Y
@@ -162,18 +161,22 @@ func (cp ConstantProduct) QuoteIn(amountOut *big.Int) (*big.Int, error) {
 	//					=> our target amountIn will
 	//				{newReserveIn} - X = dX
 	//	science.
+	//
+	// NOTE(@hadydotai): Same story as QuoteOut, the program computes dX = (X * dY) / (Y - dY) rounding up, so we do
+	// too. floor(K / (Y - dY)) - X rounds down and asks for a base unit less than the program takes.
 
 	reserveIn, reserveOut := cp.TokenInReserve.Balance, cp.TokenOutReserve.Balance
-	constantProductK := new(big.Int).Mul(reserveIn, reserveOut)
 
 	if amountOut.Cmp(reserveOut) >= 0 {
 		requested := fmtForDisplay(amountOut, cp.TokenOutReserve.Decimals, int(cp.TokenOutReserve.Decimals))
 		available := fmtForDisplay(reserveOut, cp.TokenOutReserve.Decimals, int(cp.TokenOutReserve.Decimals))
-		return nil, fmt.Errorf("requested %s exceeds available %s liquidity", requested, available)
+		return nil, classify(ErrInsufficientLiquidity, fmt.Errorf("requested %s exceeds available %s liquidity", requested, available))
 	}
 	updatedReserveOut := new(big.Int).Sub(reserveOut, amountOut)
-	newReserveIn := new(big.Int).Quo(constantProductK, updatedReserveOut)
-	netAmountIn := new(big.Int).Sub(newReserveIn, reserveIn)
+	netAmountIn, rem := new(big.Int).QuoRem(new(big.Int).Mul(reserveIn, amountOut), updatedReserveOut, new(big.Int))
+	if rem.Sign() > 0 {
+		netAmountIn.Add(netAmountIn, big.NewInt(1))
+	}
 	if netAmountIn.Sign() <= 0 {
 		// NOTE(@hadydotai): we'd only end up here if we math our way into draining the pool on one side,
 		// I think this should be a flat out error and yell at the user for it, maybe?
@@ -186,6 +189,27 @@ func (cp ConstantProduct) QuoteIn(amountOut *big.Int) (*big.Int, error) {
 	return grossAmountIn, nil
 }
 
+// PriceImpact is how much worse the rate of a trade is compared to the pool's spot rate before the trade, as a fraction
+// (0.01 is 1%). It's measured on what actually changes hands, so the trade fee is part of it.
+//
+//	spot rate:      Y / X
+//	trade rate:     dY / dX
+//	impact:         1 - (dY / dX) / (Y / X) = 1 - (dY * X) / (dX * Y)
+func (cp ConstantProduct) PriceImpact(amountIn, amountOut *big.Int) (*big.Rat, error) {
+	if amountIn == nil || amountOut == nil || amountIn.Sign() <= 0 || amountOut.Sign() <= 0 {
+		return nil, errors.New("amounts must be greater than zero for price impact")
+	}
+	if cp.TokenInReserve == nil || cp.TokenOutReserve == nil || cp.TokenInReserve.Balance == nil || cp.TokenOutReserve.Balance == nil {
+		return nil, errors.New("pool reserves unavailable for price impact")
+	}
+	if cp.TokenInReserve.Balance.Sign() <= 0 || cp.TokenOutReserve.Balance.Sign() <= 0 {
+		return nil, errors.New("pool reserves must be greater than zero for price impact")
+	}
+	num := new(big.Int).Mul(amountOut, cp.TokenInReserve.Balance)
+	den := new(big.Int).Mul(amountIn, cp.TokenOutReserve.Balance)
+	return new(big.Rat).Sub(big.NewRat(1, 1), new(big.Rat).SetFrac(num, den)), nil
+}
+
 func makeSlippageRatio(percent float64) (*big.Rat, error) {
 	if percent < 0 {
 		return nil, fmt.Errorf("slippage percent must be >= 0")
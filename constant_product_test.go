@@ -20,7 +20,7 @@ func TestQuoteOut(t *testing.T) {
 	if err != nil {
 		t.Fatalf("QuoteOut failed: %v", err)
 	}
-	want := big.NewInt(181)
+	want := big.NewInt(180)
 	if got.Cmp(want) != 0 {
 		t.Fatalf("QuoteOut mismatch: got %s want %s", got, want)
 	}
@@ -35,8 +35,12 @@ func TestQuoteOutErrors(t *testing.T) {
 		t.Fatalf("expected error for zero amount")
 	}
 	cp = newConstantProduct(10, 5, 0)
-	if _, err := cp.QuoteOut(big.NewInt(10_000)); err == nil {
-		t.Fatalf("expected error when requesting more than reserve")
+	if _, err := cp.QuoteOut(big.NewInt(1)); err == nil {
+		t.Fatalf("expected error when the output rounds down to nothing")
+	}
+	// Selling any amount never takes the whole reserve, dY = dX * Y / (X + dX) stays under Y.
+	if out, err := cp.QuoteOut(big.NewInt(10_000)); err != nil || out.Cmp(big.NewInt(5)) >= 0 {
+		t.Fatalf("selling 10000 into a reserve of 5 = %v, %v", out, err)
 	}
 }
 
@@ -47,7 +51,7 @@ func TestQuoteIn(t *testing.T) {
 	if err != nil {
 		t.Fatalf("QuoteIn failed: %v", err)
 	}
-	want := big.NewInt(112)
+	want := big.NewInt(113)
 	if got.Cmp(want) != 0 {
 		t.Fatalf("QuoteIn mismatch: got %s want %s", got, want)
 	}
@@ -66,6 +70,24 @@ func TestQuoteInErrors(t *testing.T) {
 	}
 }
 
+func TestPriceImpact(t *testing.T) {
+	cp := newConstantProduct(1000, 2000, 3000)
+	got, err := cp.PriceImpact(big.NewInt(100), big.NewInt(181))
+	if err != nil {
+		t.Fatalf("PriceImpact failed: %v", err)
+	}
+	if want := big.NewRat(19, 200); got.Cmp(want) != 0 {
+		t.Fatalf("PriceImpact mismatch: got %s want %s", got.FloatString(6), want.FloatString(6))
+	}
+	if _, err := cp.PriceImpact(big.NewInt(0), big.NewInt(1)); err == nil {
+		t.Fatalf("expected error for zero amount")
+	}
+	cp.TokenOutReserve = nil
+	if _, err := cp.PriceImpact(big.NewInt(100), big.NewInt(181)); err == nil {
+		t.Fatalf("expected error for missing reserves")
+	}
+}
+
 func TestAmountAfterTradeFee(t *testing.T) {
 	cp := ConstantProduct{TradeFeeRate: 2500}
 	amount := big.NewInt(1_000_000)
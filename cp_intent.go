@@ -52,13 +52,42 @@ type IntentInstruction struct {
 	AmountStr    string
 	Dir          SwapDir
 	TargetSymbol string
+	// CounterSymbol is the other token when the intent names it (`for USDC`, `with SOL`), empty otherwise.
+	CounterSymbol string
+	// Condition is the intent's `at` clause, a limit on the target's price, nil without one.
+	Condition *limitCondition
+	// AmountPct is set when the amount is relative to the wallet's balance ("50%", "all"), as a fraction of it.
+	AmountPct *big.Rat
+	// AmountUSD is set when the amount is in dollars ("$50 of SOL"), priced by -price-source when quoted.
+	AmountUSD *big.Rat
 }
 
+// String renders the intent back in the grammar it was parsed from, symbols upper cased.
 func (ii *IntentInstruction) String() string {
-	if ii.TargetSymbol == "" {
-		return fmt.Sprintf("%s %s", ii.Verb, ii.AmountStr)
+	amount := ii.AmountStr
+	if ii.AmountUSD != nil {
+		amount += " of"
 	}
-	return fmt.Sprintf("%s %s %s", ii.Verb, ii.AmountStr, ii.TargetSymbol)
+	var s string
+	switch {
+	case ii.TargetSymbol == "":
+		s = fmt.Sprintf("%s %s", ii.Verb, amount)
+	case ii.CounterSymbol == "":
+		s = fmt.Sprintf("%s %s %s", ii.Verb, amount, ii.TargetSymbol)
+	case ii.Dir == SwapDirSell && (ii.Verb == "buy" || ii.Verb == "get"):
+		s = fmt.Sprintf("%s %s with %s %s", ii.Verb, ii.CounterSymbol, amount, ii.TargetSymbol)
+	case ii.Dir == SwapDirSell:
+		s = fmt.Sprintf("%s %s %s for %s", ii.Verb, amount, ii.TargetSymbol, ii.CounterSymbol)
+	default:
+		s = fmt.Sprintf("%s %s %s with %s", ii.Verb, amount, ii.TargetSymbol, ii.CounterSymbol)
+	}
+	if c := ii.Condition; c != nil {
+		s += fmt.Sprintf(" at %s %s", c.op, trimDecimal(c.price.FloatString(18)))
+		if c.unit != "" {
+			s += " " + c.unit
+		}
+	}
+	return s
 }
 
 // CPIntent captures the resolved swap details derived from the pool + user intent.
@@ -69,6 +98,15 @@ type CPIntent struct {
 	TokenIn     SwapLeg
 	TokenOut    SwapLeg
 	Pool        PoolAccounts
+	// Reserves the quote was computed against, oriented the way the swap flows.
+	ReserveIn  *PoolBalance
+	ReserveOut *PoolBalance
+	// WalletBalance is the balance a percentage amount was taken from, nil for absolute amounts.
+	WalletBalance *big.Int
+	// Venue is what the intent was quoted on, see venueOf.
+	Venue Venue
+	// ComputeBudget is what its transaction pays in priority fee, nil for the one the compute budget flags set.
+	ComputeBudget *feePreset
 }
 
 // String renders the original intent instruction for UI purposes.
@@ -109,6 +147,31 @@ func (ci *CPIntent) CounterLeg() *SwapLeg {
 	}
 }
 
+// QuotedInOut returns the quoted amounts going into and coming out of the pool, before slippage.
+func (ci *CPIntent) QuotedInOut() (amountIn, amountOut *big.Int) {
+	if ci == nil {
+		return nil, nil
+	}
+	switch ci.SwapKind {
+	case SwapKindBaseInput:
+		return ci.Amounts.KnownAmount, ci.Amounts.QuoteAmount
+	case SwapKindBaseOutput:
+		return ci.Amounts.QuoteAmount, ci.Amounts.KnownAmount
+	default:
+		return nil, nil
+	}
+}
+
+// PriceImpact of the quoted trade against the reserves it was quoted on, see ConstantProduct.PriceImpact.
+func (ci *CPIntent) PriceImpact() (*big.Rat, error) {
+	if ci == nil {
+		return nil, errors.New("cp intent missing")
+	}
+	amountIn, amountOut := ci.QuotedInOut()
+	cp := ConstantProduct{TokenInReserve: ci.ReserveIn, TokenOutReserve: ci.ReserveOut}
+	return cp.PriceImpact(amountIn, amountOut)
+}
+
 // BuildSwapInstruction materializes the concrete Raydium instruction for the CPIntent.
 func (ci *CPIntent) BuildSwapInstruction(payer solana.PublicKey, authority solana.PublicKey, inputATA solana.PublicKey, outputATA solana.PublicKey) (solana.Instruction, error) {
 	if ci == nil {
@@ -170,21 +233,57 @@ func (ci *CPIntent) BuildSwapInstruction(payer solana.PublicKey, authority solan
 
 // NewCPIntent derives a CPIntent by combining pool data, balances, and the user instruction.
 func NewCPIntent(cp ConstantProduct, pool *raydium_cp_swap.PoolState, poolAddress solana.PublicKey, instruction *IntentInstruction, targetMint solana.PublicKey, balances ...*PoolBalance) (*CPIntent, error) {
+	if err := checkBalances(balances); err != nil {
+		return nil, err
+	}
+	if instruction.Dir != SwapDirBuy && instruction.Dir != SwapDirSell {
+		return nil, fmt.Errorf("swap direction unknown for verb %s", instruction.Verb)
+	}
+	decimals := balances[1].Decimals
+	if targetMint.Equals(pool.Token0Mint) {
+		decimals = balances[0].Decimals
+	}
+	knownAmount, err := fmtForMath(instruction.AmountStr, decimals)
+	if err != nil {
+		return nil, err
+	}
+	intent, err := quoteCPIntent(cp, pool, poolAddress, instruction.Dir, targetMint, knownAmount, balances...)
+	if err != nil {
+		return nil, err
+	}
+	intent.Instruction = instruction
+	return intent, nil
+}
+
+func checkBalances(balances []*PoolBalance) error {
+	if len(balances) != 2 {
+		return fmt.Errorf("a pool has two balances, got %d", len(balances))
+	}
 	for i, bal := range balances {
 		if bal == nil || bal.Balance == nil {
-			return nil, fmt.Errorf("missing balance information for token index %d", i)
+			return fmt.Errorf("missing balance information for token index %d", i)
 		}
 	}
+	return nil
+}
+
+// quoteCPIntent is NewCPIntent for an amount already in targetMint's base units, selling it or buying it as dir says.
+// The intent comes back without an instruction, that's the caller's to set.
+func quoteCPIntent(cp ConstantProduct, pool *raydium_cp_swap.PoolState, poolAddress solana.PublicKey, dir SwapDir, targetMint solana.PublicKey, knownAmount *big.Int, balances ...*PoolBalance) (*CPIntent, error) {
+	if err := checkBalances(balances); err != nil {
+		return nil, err
+	}
 
 	targetIsToken0 := targetMint.Equals(pool.Token0Mint)
 
 	makeLeg := func(mint, vault, program solana.PublicKey, decimals uint8) SwapLeg {
 		return SwapLeg{Mint: mint, Vault: vault, Program: program, Decimals: decimals}
 	}
+	token0 := makeLeg(pool.Token0Mint, pool.Token0Vault, pool.Token0Program, balances[0].Decimals)
+	token1 := makeLeg(pool.Token1Mint, pool.Token1Vault, pool.Token1Program, balances[1].Decimals)
 
 	intent := &CPIntent{
-		Instruction: instruction,
-		SwapKind:    SwapKindUnknown,
+		SwapKind: SwapKindUnknown,
 		Pool: PoolAccounts{
 			Address:     poolAddress,
 			AmmConfig:   pool.AmmConfig,
@@ -193,30 +292,19 @@ func NewCPIntent(cp ConstantProduct, pool *raydium_cp_swap.PoolState, poolAddres
 	}
 
 	var (
-		knownAmount *big.Int
-		quote       *big.Int
-		err         error
+		quote *big.Int
+		err   error
 	)
 
-	switch instruction.Dir {
+	switch dir {
 	case SwapDirBuy:
 		intent.SwapKind = SwapKindBaseOutput
 		if targetIsToken0 {
-			knownAmount, err = fmtForMath(instruction.AmountStr, balances[0].Decimals)
-			if err != nil {
-				return nil, err
-			}
 			cp.TokenInReserve, cp.TokenOutReserve = balances[1], balances[0]
-			intent.TokenIn = makeLeg(pool.Token1Mint, pool.Token1Vault, pool.Token1Program, balances[1].Decimals)
-			intent.TokenOut = makeLeg(pool.Token0Mint, pool.Token0Vault, pool.Token0Program, balances[0].Decimals)
+			intent.TokenIn, intent.TokenOut = token1, token0
 		} else {
-			knownAmount, err = fmtForMath(instruction.AmountStr, balances[1].Decimals)
-			if err != nil {
-				return nil, err
-			}
 			cp.TokenInReserve, cp.TokenOutReserve = balances[0], balances[1]
-			intent.TokenIn = makeLeg(pool.Token0Mint, pool.Token0Vault, pool.Token0Program, balances[0].Decimals)
-			intent.TokenOut = makeLeg(pool.Token1Mint, pool.Token1Vault, pool.Token1Program, balances[1].Decimals)
+			intent.TokenIn, intent.TokenOut = token0, token1
 		}
 		quote, err = cp.QuoteIn(knownAmount)
 		if err != nil {
@@ -230,21 +318,11 @@ func NewCPIntent(cp ConstantProduct, pool *raydium_cp_swap.PoolState, poolAddres
 	case SwapDirSell:
 		intent.SwapKind = SwapKindBaseInput
 		if targetIsToken0 {
-			knownAmount, err = fmtForMath(instruction.AmountStr, balances[0].Decimals)
-			if err != nil {
-				return nil, err
-			}
 			cp.TokenInReserve, cp.TokenOutReserve = balances[0], balances[1]
-			intent.TokenIn = makeLeg(pool.Token0Mint, pool.Token0Vault, pool.Token0Program, balances[0].Decimals)
-			intent.TokenOut = makeLeg(pool.Token1Mint, pool.Token1Vault, pool.Token1Program, balances[1].Decimals)
+			intent.TokenIn, intent.TokenOut = token0, token1
 		} else {
-			knownAmount, err = fmtForMath(instruction.AmountStr, balances[1].Decimals)
-			if err != nil {
-				return nil, err
-			}
 			cp.TokenInReserve, cp.TokenOutReserve = balances[1], balances[0]
-			intent.TokenIn = makeLeg(pool.Token1Mint, pool.Token1Vault, pool.Token1Program, balances[1].Decimals)
-			intent.TokenOut = makeLeg(pool.Token0Mint, pool.Token0Vault, pool.Token0Program, balances[0].Decimals)
+			intent.TokenIn, intent.TokenOut = token1, token0
 		}
 		quote, err = cp.QuoteOut(knownAmount)
 		if err != nil {
@@ -256,11 +334,12 @@ func NewCPIntent(cp ConstantProduct, pool *raydium_cp_swap.PoolState, poolAddres
 		}
 		intent.Amounts.MinAmountOut = minOut
 	default:
-		return nil, fmt.Errorf("swap direction unknown for verb %s", instruction.Verb)
+		return nil, errors.New("swap direction unknown")
 	}
 
 	intent.Amounts.KnownAmount = cloneInt(knownAmount)
 	intent.Amounts.QuoteAmount = cloneInt(quote)
+	intent.ReserveIn, intent.ReserveOut = cp.TokenInReserve, cp.TokenOutReserve
 
 	return intent, nil
 }
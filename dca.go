@@ -0,0 +1,613 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+/*
+NOTE(@hadydotai): Dollar cost averaging.
+
+`dca run` executes the same intent, say `pay 0.1 SOL`, on a schedule (see schedule.go) until it's stopped or the
+-max-total cap would be crossed. Every execution is quoted fresh and has to pass the price impact cap, and the
+slippage guard is set from that quote like any other swap, so a bad moment gets skipped rather than filled.
+
+Everything that happened is written to the -state file after every execution, so a restart picks up where it left
+off: the cap counts what was already spent, and the next execution is scheduled from the last one. Slots missed while
+we were down aren't replayed, if one was due we run once and get back on schedule. A state file belongs to one intent
+on one pool, pointing it at a different plan is an error rather than a silent merge.
+
+Sends are journaled next to the state, in <state>.journal (see send_journal.go). An execution whose send failed
+without saying whether it went out isn't recorded as failed, it's tried again once the attempt has settled, and an
+attempt that landed, while we were down included, is recorded as the execution rather than repeated.
+
+With -good-for or -expires-at the plan ends there, -good-for counted from when the plan was first started. What
+expired goes in the state as an execution of its own (see order_expiry.go).
+
+`dca report` prints the executions and the average fill price from a state file without touching the chain.
+*/
+
+type dcaStatus string
+
+const (
+	dcaFilled  dcaStatus = "filled"
+	dcaFailed  dcaStatus = "failed"
+	dcaSkipped dcaStatus = "skipped"
+	dcaExpired dcaStatus = receiptExpired
+)
+
+type dcaExecution struct {
+	Time      time.Time   `json:"time"`
+	Status    dcaStatus   `json:"status"`
+	Signature string      `json:"signature,omitempty"`
+	Explorer  string      `json:"explorer,omitempty"`
+	Paid      *amountJSON `json:"paid,omitempty"`
+	Received  *amountJSON `json:"received,omitempty"`
+	Reason    string      `json:"reason,omitempty"`
+	RetryOf   []string    `json:"retryOf,omitempty"` // earlier attempts at this execution, see send_journal.go
+}
+
+// dcaState is what the -state file holds. The token fields describe the pay and receive sides of the intent, they
+// don't change between executions and are what the report needs to make sense of the raw amounts.
+type dcaState struct {
+	Pool         string         `json:"pool"`
+	Intent       string         `json:"intent"`
+	Schedule     string         `json:"schedule"`
+	SwapKind     string         `json:"swapKind"`
+	PaySymbol    string         `json:"paySymbol"`
+	PayDecimals  uint8          `json:"payDecimals"`
+	RecvSymbol   string         `json:"receiveSymbol"`
+	RecvDecimals uint8          `json:"receiveDecimals"`
+	Created      time.Time      `json:"created,omitzero"`   // when the plan was first started
+	ExpiresAt    time.Time      `json:"expiresAt,omitzero"` // when the plan ends, zero when it runs until stopped
+	Executions   []dcaExecution `json:"executions"`
+}
+
+func loadDCAState(path string) (*dcaState, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading dca state %s: %w", path, err)
+	}
+	state := &dcaState{}
+	if err := json.Unmarshal(raw, state); err != nil {
+		return nil, fmt.Errorf("dca state %s is corrupt: %w", path, err)
+	}
+	return state, nil
+}
+
+// save writes the state next to its destination and renames it into place, a crash mid-write leaves the previous
+// state intact rather than half a file.
+func (s *dcaState) save(path string) error {
+	raw, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("writing dca state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(raw, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing dca state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing dca state: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *dcaState) lastRun() (time.Time, bool) {
+	if len(s.Executions) == 0 {
+		return time.Time{}, false
+	}
+	return s.Executions[len(s.Executions)-1].Time, true
+}
+
+// knownSide picks the amount the intent fixed out of a pay/receive pair, the side the cap is counted in.
+func (s *dcaState) knownSide(paid, received *big.Int) *big.Int {
+	if s.SwapKind == SwapKindBaseOutput.String() {
+		return received
+	}
+	return paid
+}
+
+func rawOf(a *amountJSON) *big.Int {
+	if a == nil {
+		return nil
+	}
+	v, ok := new(big.Int).SetString(a.Raw, 10)
+	if !ok {
+		return nil
+	}
+	return v
+}
+
+type dcaSummary struct {
+	filled, failed, skipped int
+	paid, received          *big.Int
+	// price is what one unit of the intent's token went for on average, in units of the other token, same as -watch
+	// and limit orders quote it. nil until something has filled.
+	price *big.Rat
+}
+
+func summarizeDCA(s *dcaState) dcaSummary {
+	sum := dcaSummary{paid: new(big.Int), received: new(big.Int)}
+	for _, ex := range s.Executions {
+		switch ex.Status {
+		case dcaFilled:
+			sum.filled++
+			if p, r := rawOf(ex.Paid), rawOf(ex.Received); p != nil && r != nil {
+				sum.paid.Add(sum.paid, p)
+				sum.received.Add(sum.received, r)
+			}
+		case dcaFailed:
+			sum.failed++
+		case dcaSkipped:
+			sum.skipped++
+		}
+	}
+	if sum.paid.Sign() == 0 || sum.received.Sign() == 0 {
+		return sum
+	}
+	// counter per target: for `pay X` the target is what we pay, for `buy X` it's what we receive.
+	counter, target := sum.received, sum.paid
+	counterDec, targetDec := s.RecvDecimals, s.PayDecimals
+	if s.SwapKind == SwapKindBaseOutput.String() {
+		counter, target = sum.paid, sum.received
+		counterDec, targetDec = s.PayDecimals, s.RecvDecimals
+	}
+	sum.price = new(big.Rat).SetFrac(counter, target)
+	sum.price.Mul(sum.price, new(big.Rat).SetFrac(fixedPointScale(targetDec), fixedPointScale(counterDec)))
+	return sum
+}
+
+// spent is the total of the intent's fixed side across filled executions, what -max-total is measured against.
+func (s *dcaState) spent() *big.Int {
+	total := new(big.Int)
+	for _, ex := range s.Executions {
+		if ex.Status != dcaFilled {
+			continue
+		}
+		if v := s.knownSide(rawOf(ex.Paid), rawOf(ex.Received)); v != nil {
+			total.Add(total, v)
+		}
+	}
+	return total
+}
+
+func (s *dcaState) priceUnit() string {
+	if s.SwapKind == SwapKindBaseOutput.String() {
+		return s.PaySymbol + "/" + s.RecvSymbol
+	}
+	return s.RecvSymbol + "/" + s.PaySymbol
+}
+
+func renderDCAReport(s *dcaState) string {
+	builder := &strings.Builder{}
+	amount := func(a *amountJSON, symbol string) string {
+		if a == nil {
+			return ""
+		}
+		return a.String() + " " + symbol
+	}
+	t := table.NewWriter()
+	t.SetOutputMirror(builder)
+	t.SetTitle("DCA Executions")
+	t.AppendHeader(table.Row{"Time", "Status", "Paid", "Received", "Signature / Reason"})
+	for _, ex := range s.Executions {
+		detail := ex.Signature
+		if ex.Reason != "" {
+			detail = ex.Reason
+		}
+		t.AppendRow(table.Row{ex.Time.Local().Format(time.DateTime), strings.ToUpper(string(ex.Status)), amount(ex.Paid, s.PaySymbol), amount(ex.Received, s.RecvSymbol), detail})
+	}
+	t.Render()
+
+	sum := summarizeDCA(s)
+	avg := "n/a"
+	if sum.price != nil {
+		avg = fmt.Sprintf("%s %s", sum.price.FloatString(int(max(s.PayDecimals, s.RecvDecimals))), s.priceUnit())
+	}
+	t = table.NewWriter()
+	t.SetOutputMirror(builder)
+	t.SetTitle("DCA Summary")
+	t.AppendHeader(table.Row{"Field", "Value"})
+	t.AppendRow(table.Row{"Intent", s.Intent})
+	t.AppendRow(table.Row{"Pool", s.Pool})
+	t.AppendRow(table.Row{"Schedule", s.Schedule})
+	if !s.ExpiresAt.IsZero() {
+		t.AppendRow(table.Row{"Expires", s.ExpiresAt.Local().Format(time.DateTime)})
+	}
+	t.AppendRow(table.Row{"Executions", fmt.Sprintf("%d filled, %d failed, %d skipped", sum.filled, sum.failed, sum.skipped)})
+	t.AppendRow(table.Row{"Total paid", formatTokenAmount(sum.paid, s.PayDecimals, s.PaySymbol)})
+	t.AppendRow(table.Row{"Total received", formatTokenAmount(sum.received, s.RecvDecimals, s.RecvSymbol)})
+	t.AppendRow(table.Row{"Average price", avg})
+	t.Render()
+	return builder.String()
+}
+
+var errDCACapReached = errors.New("the next execution would go over -max-total")
+
+type dcaEngine struct {
+	ctx       context.Context
+	client    *rpc.Client
+	builder   *TableBuilder
+	payer     solana.PrivateKey
+	sched     schedule
+	maxImpact *big.Rat
+	maxTotal  *big.Int // in raw units of the intent's fixed side, nil for no cap
+	statePath string
+	state     *dcaState
+	journal   *sendJournal
+	network   string
+}
+
+// dcaRetryPause is how long an execution that couldn't be settled waits before it's tried again.
+var dcaRetryPause = 5 * time.Second
+
+// guard is the idempotency key of the next execution, its number in the plan.
+func (de *dcaEngine) guard() *sendGuard {
+	return newSendGuard(de.journal, fmt.Sprintf("dca %s #%d", de.state.Intent, len(de.state.Executions)+1))
+}
+
+// capAllows reports whether spending another perRun keeps the plan within -max-total.
+func (de *dcaEngine) capAllows(perRun *big.Int) bool {
+	if de.maxTotal == nil {
+		return true
+	}
+	return new(big.Int).Add(de.state.spent(), perRun).Cmp(de.maxTotal) <= 0
+}
+
+// nextRun is when the next execution is due, never earlier than now.
+func (de *dcaEngine) nextRun(now time.Time) time.Time {
+	last, ok := de.state.lastRun()
+	if !ok {
+		return de.sched.next(now)
+	}
+	// Stored times are UTC, cron fields are read in the same zone as now.
+	at := de.sched.next(last.In(now.Location()))
+	if !at.IsZero() && at.Before(now) {
+		return now
+	}
+	return at
+}
+
+// expired reports whether the plan has run out of time by now.
+func (de *dcaEngine) expired(now time.Time) bool {
+	return !de.state.ExpiresAt.IsZero() && !now.Before(de.state.ExpiresAt)
+}
+
+// expire records the plan as expired, once.
+func (de *dcaEngine) expire() error {
+	if n := len(de.state.Executions); n > 0 && de.state.Executions[n-1].Status == dcaExpired {
+		log.Printf("dca: the plan expired at %s", de.state.ExpiresAt.Local().Format(time.DateTime))
+		return nil
+	}
+	de.state.Executions = append(de.state.Executions, dcaExecution{
+		Time:   time.Now().UTC(),
+		Status: dcaExpired,
+		Reason: fmt.Sprintf("the plan was good until %s", de.state.ExpiresAt.Local().Format(time.DateTime)),
+	})
+	if err := de.state.save(de.statePath); err != nil {
+		return err
+	}
+	recordExpiry("", "dca", de.state.Pool, de.state.Intent, "", de.state.ExpiresAt)
+	log.Printf("dca: the plan expired, nothing more will be sent")
+	return nil
+}
+
+// execute quotes and, when the quote passes the checks, sends one execution of the intent. An earlier attempt at the
+// same execution that may still land is settled first. When that can't be done, or the send fails without saying
+// whether it went out, the error comes back and nothing should be recorded, the execution is tried again.
+func (de *dcaEngine) execute(guard *sendGuard) (dcaExecution, error) {
+	ex := dcaExecution{Time: time.Now().UTC()}
+	_, intent, err := de.builder.Build(de.state.Intent)
+	if guard.inFlight() {
+		if err != nil {
+			return ex, fmt.Errorf("settling the last attempt: %w", err)
+		}
+		ctx, cancel := deadlines.forSend(de.ctx)
+		summary, sig, landed, err := landedAttempt(ctx, de.client, de.builder.snapshot(), intent, guard)
+		cancel()
+		if err != nil {
+			return ex, err
+		}
+		if landed {
+			return de.filled(ex, intent, summary, sig, guard), nil
+		}
+	}
+	if err != nil {
+		ex.Status, ex.Reason = dcaFailed, err.Error()
+		return ex, nil
+	}
+	if !de.capAllows(intent.Amounts.KnownAmount) {
+		return ex, errDCACapReached
+	}
+	impact, err := intent.PriceImpact()
+	if err != nil {
+		ex.Status, ex.Reason = dcaFailed, err.Error()
+		return ex, nil
+	}
+	if impact.Cmp(de.maxImpact) > 0 {
+		ex.Status = dcaSkipped
+		ex.Reason = fmt.Sprintf("price impact %s%% is over the %s%% cap",
+			new(big.Rat).Mul(impact, big.NewRat(100, 1)).FloatString(2), new(big.Rat).Mul(de.maxImpact, big.NewRat(100, 1)).FloatString(2))
+		return ex, nil
+	}
+	summary, sig, err := executeIntentOnce(de.ctx, de.client, de.payer, de.builder, intent, guard)
+	var ambiguous *ambiguousSendError
+	if errors.As(err, &ambiguous) {
+		return ex, err
+	}
+	if err != nil {
+		if !sig.IsZero() {
+			ex.Signature, ex.Explorer = sig.String(), explorerTxURL(de.network, sig)
+		}
+		ex.Status, ex.Reason, ex.RetryOf = dcaFailed, err.Error(), guard.lineage(sig)
+		return ex, nil
+	}
+	return de.filled(ex, intent, summary, sig, guard), nil
+}
+
+// filled records sig, the swap of intent, as the execution.
+func (de *dcaEngine) filled(ex dcaExecution, intent *CPIntent, summary txSummaryData, sig solana.Signature, guard *sendGuard) dcaExecution {
+	ex.Status, ex.Signature, ex.RetryOf = dcaFilled, sig.String(), guard.lineage(sig)
+	ex.Explorer = explorerTxURL(de.network, sig)
+	paid, received := summary.PaidAmount, summary.ReceivedAmount
+	if paid == nil || received == nil {
+		// NOTE(@hadydotai): We sent it but couldn't read the balances back, the quote is the best record we have and
+		// counting it keeps the cap honest.
+		paid, received = intent.QuotedInOut()
+		ex.Reason = "confirmation failed, amounts are the quote's"
+	}
+	ex.Paid = ptrTo(newAmountJSON(paid, de.state.PayDecimals))
+	ex.Received = ptrTo(newAmountJSON(received, de.state.RecvDecimals))
+	return ex
+}
+
+func (de *dcaEngine) run() error {
+	_, intent, err := de.builder.Build(de.state.Intent)
+	if err != nil {
+		return err
+	}
+	perRun := intent.Amounts.KnownAmount
+	defer func() {
+		fmt.Fprint(os.Stdout, renderDCAReport(de.state))
+	}()
+	for {
+		if !de.capAllows(perRun) {
+			log.Printf("dca: -max-total reached, done")
+			return nil
+		}
+		if de.expired(time.Now()) {
+			return de.expire()
+		}
+		at := de.nextRun(time.Now())
+		if at.IsZero() {
+			return fmt.Errorf("schedule %q never fires", de.sched)
+		}
+		if !de.state.ExpiresAt.IsZero() && !at.Before(de.state.ExpiresAt) {
+			// The next execution would be past the plan's end, wait out the plan instead.
+			at = de.state.ExpiresAt
+			log.Printf("dca: no execution of %q left before the plan expires at %s", de.state.Intent, at.Local().Format(time.DateTime))
+		} else {
+			log.Printf("dca: next execution of %q at %s", de.state.Intent, at.Local().Format(time.DateTime))
+		}
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-de.ctx.Done():
+			timer.Stop()
+			log.Println("dca stopped")
+			return nil
+		case <-timer.C:
+		}
+		if de.expired(time.Now()) {
+			return de.expire()
+		}
+		guard := de.guard()
+		ex, err := de.execute(guard)
+		if errors.Is(err, errDCACapReached) {
+			log.Printf("dca: -max-total reached, done")
+			return nil
+		}
+		if err != nil {
+			if de.ctx.Err() != nil {
+				log.Println("dca stopped")
+				return nil
+			}
+			log.Printf("dca: %v, trying the execution again in %s", err, dcaRetryPause)
+			select {
+			case <-de.ctx.Done():
+			case <-time.After(dcaRetryPause):
+			}
+			continue
+		}
+		if de.ctx.Err() != nil && ex.Status == dcaFailed && ex.Signature == "" {
+			// Interrupted mid-flight before anything was sent, not worth a record.
+			log.Println("dca stopped")
+			return nil
+		}
+		de.state.Executions = append(de.state.Executions, ex)
+		if err := de.state.save(de.statePath); err != nil {
+			return err
+		}
+		guard.done()
+		switch ex.Status {
+		case dcaFilled:
+			log.Printf("dca: filled, paid %s %s, received %s %s", ex.Paid, de.state.PaySymbol, ex.Received, de.state.RecvSymbol)
+		default:
+			log.Printf("dca: %s: %s", ex.Status, ex.Reason)
+		}
+	}
+}
+
+func runDCACommand(args []string) error {
+	return dispatchSubcommand("dca", map[string]func([]string) error{
+		"run":    runDCARunCommand,
+		"report": runDCAReportCommand,
+	}, args)
+}
+
+func runDCARunCommand(args []string) error {
+	fs := flag.NewFlagSet("dca run", flag.ExitOnError)
+	nf := addNetworkFlags(fs)
+	wf := addWebhookFlags(fs)
+	addCloseEmptyATAsFlag(fs)
+	addTradeHookFlags(fs)
+	addMintPolicyFlags(fs)
+	addSpendLimitFlags(fs)
+	addApprovalFlags(fs)
+	addRebroadcastFlags(fs)
+	addMinOutFromSimFlag(fs)
+	addComputeBudgetFlags(fs)
+	var (
+		hotwalletPath = fs.String("hotwallet", "", "Path to the hotwallet to use for signing transactions")
+		poolAddr      = fs.String("pool", "", "Pool to trade against")
+		intentLine    = fs.String("intent", "", "Intent to execute every time, e.g. \"pay 0.1 SOL\"")
+		scheduleSpec  = fs.String("schedule", "", "When to execute, \"@every 6h\", \"@daily\" or a five field cron line like \"0 9 * * 1-5\"")
+		statePath     = fs.String("state", "dca-state.json", "File the executions are recorded in, resumed from on restart")
+		maxTotalStr   = fs.String("max-total", "", "Stop before the intent's amount, summed over all executions, would exceed this (same token as the intent)")
+		slippagePct   = fs.Float64("slippage", 0.5, "Slippage tolerance percentage (e.g. 0.5 for 0.5%)")
+		maxImpactPct  = fs.Float64("max-impact", 1, "Skip an execution when its price impact (including the trade fee) is above this percentage")
+	)
+	expiry := addExpiryFlags(fs, 0)
+	addAmountFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, append(nf.specs(),
+		FlagSpec{Name: "hotwallet", Value: hotwalletPath, Rules: []FlagRule{NotEmpty()}},
+		FlagSpec{Name: "pool", Value: poolAddr, Rules: []FlagRule{NotEmpty()}},
+		FlagSpec{Name: "intent", Value: intentLine, Rules: []FlagRule{NotEmpty()}},
+		FlagSpec{Name: "schedule", Value: scheduleSpec, Rules: []FlagRule{NotEmpty()}},
+		FlagSpec{Name: "state", Value: statePath, Rules: []FlagRule{NotEmpty()}},
+	))
+	if *maxImpactPct <= 0 {
+		return errors.New("max-impact must be greater than zero")
+	}
+	sched, err := parseSchedule(*scheduleSpec)
+	if err != nil {
+		return err
+	}
+	maxImpact, ok := new(big.Rat).SetString(fmt.Sprintf("%g", *maxImpactPct))
+	if !ok {
+		return fmt.Errorf("invalid max-impact %v", *maxImpactPct)
+	}
+	maxImpact.Quo(maxImpact, big.NewRat(100, 1))
+
+	payer, err := solana.PrivateKeyFromSolanaKeygenFile(*hotwalletPath)
+	if err != nil {
+		return fmt.Errorf("failed to load private key from hot wallet: %w", err)
+	}
+	poolPubK, err := solana.PublicKeyFromBase58(*poolAddr)
+	if err != nil {
+		return fmt.Errorf("deriving public key from pool address (base58) failed, make sure it's base58 encoded: %w", err)
+	}
+	flushWebhooks, err := wf.start("dca", *nf.network)
+	if err != nil {
+		return err
+	}
+	defer flushWebhooks()
+	client := nf.connect()
+	ctx, stop := interruptContext()
+	defer stop()
+	loaded, err := loadPool(ctx, client, poolPubK)
+	if err != nil {
+		return err
+	}
+	builder, err := newTableBuilder(ctx, client, loaded, *slippagePct)
+	if err != nil {
+		return err
+	}
+	builder.useWallet(payer.PublicKey())
+	_, intent, err := builder.Build(*intentLine)
+	if err != nil {
+		return err
+	}
+
+	state, err := loadDCAState(*statePath)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		state = &dcaState{Pool: poolPubK.String(), Intent: intent.String(), Created: time.Now().UTC()}
+	} else if state.Pool != poolPubK.String() || state.Intent != intent.String() {
+		return fmt.Errorf("%s holds the plan %q on pool %s, use another -state for %q on %s",
+			*statePath, state.Intent, state.Pool, intent.String(), poolPubK)
+	}
+	state.Schedule = sched.String()
+	if state.Created.IsZero() {
+		state.Created = time.Now().UTC()
+	}
+	state.ExpiresAt = expiry.deadline(state.Created)
+	state.SwapKind = intent.SwapKind.String()
+	state.PaySymbol, state.PayDecimals = builder.symbols().SymFrom(intent.TokenIn.Mint), intent.TokenIn.Decimals
+	state.RecvSymbol, state.RecvDecimals = builder.symbols().SymFrom(intent.TokenOut.Mint), intent.TokenOut.Decimals
+
+	journal, err := openSendJournal(*statePath + ".journal")
+	if err != nil {
+		return err
+	}
+	engine := &dcaEngine{
+		ctx:       ctx,
+		client:    client,
+		builder:   builder,
+		payer:     payer,
+		sched:     sched,
+		maxImpact: maxImpact,
+		statePath: *statePath,
+		state:     state,
+		journal:   journal,
+		network:   *nf.network,
+	}
+	if *maxTotalStr != "" {
+		knownDecimals := intent.TokenIn.Decimals
+		if intent.SwapKind == SwapKindBaseOutput {
+			knownDecimals = intent.TokenOut.Decimals
+		}
+		engine.maxTotal, err = fmtForMath(*maxTotalStr, knownDecimals)
+		if err != nil {
+			return fmt.Errorf("invalid max-total: %w", err)
+		}
+	}
+	if err := state.save(*statePath); err != nil {
+		return err
+	}
+	return engine.run()
+}
+
+func runDCAReportCommand(args []string) error {
+	fs := flag.NewFlagSet("dca report", flag.ExitOnError)
+	statePath := fs.String("state", "dca-state.json", "State file written by `dca run`")
+	addAmountFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, []FlagSpec{{Name: "state", Value: statePath, Rules: []FlagRule{NotEmpty()}}})
+	state, err := loadDCAState(*statePath)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		return fmt.Errorf("no dca state at %s", *statePath)
+	}
+	fmt.Fprint(os.Stdout, renderDCAReport(state))
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func sampleDCAState() *dcaState {
+	at := time.Date(2024, 5, 15, 9, 0, 0, 0, time.UTC)
+	sol := func(raw int64) *amountJSON { return ptrTo(newAmountJSON(big.NewInt(raw), 9)) }
+	usdc := func(raw int64) *amountJSON { return ptrTo(newAmountJSON(big.NewInt(raw), 6)) }
+	return &dcaState{
+		Pool:         "pool",
+		Intent:       "pay 0.1 SOL",
+		Schedule:     "@daily",
+		SwapKind:     SwapKindBaseInput.String(),
+		PaySymbol:    "SOL",
+		PayDecimals:  9,
+		RecvSymbol:   "USDC",
+		RecvDecimals: 6,
+		Executions: []dcaExecution{
+			{Time: at, Status: dcaFilled, Signature: "sig1", Paid: sol(100_000_000), Received: usdc(15_000_000)},
+			{Time: at.Add(24 * time.Hour), Status: dcaFailed, Reason: "pool moved"},
+			{Time: at.Add(48 * time.Hour), Status: dcaFilled, Signature: "sig2", Paid: sol(100_000_000), Received: usdc(16_000_000)},
+			{Time: at.Add(72 * time.Hour), Status: dcaSkipped, Reason: "price impact"},
+		},
+	}
+}
+
+func TestSummarizeDCA(t *testing.T) {
+	sum := summarizeDCA(sampleDCAState())
+	if sum.filled != 2 || sum.failed != 1 || sum.skipped != 1 {
+		t.Fatalf("unexpected counts %+v", sum)
+	}
+	if sum.price == nil || sum.price.Cmp(big.NewRat(155, 1)) != 0 {
+		t.Fatalf("expected an average of 155 USDC/SOL, got %v", sum.price)
+	}
+
+	// The same fills seen as `buy 15 USDC`-style intents price the received token in what was paid.
+	s := sampleDCAState()
+	s.SwapKind = SwapKindBaseOutput.String()
+	if got := summarizeDCA(s).price; got.Cmp(big.NewRat(2, 310)) != 0 {
+		t.Fatalf("expected 0.2/31 SOL/USDC, got %v", got)
+	}
+	if got := s.spent(); got.Cmp(big.NewInt(31_000_000)) != 0 {
+		t.Fatalf("buy intents cap what's received, got %s", got)
+	}
+}
+
+func TestDCAStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dca.json")
+	if s, err := loadDCAState(path); s != nil || err != nil {
+		t.Fatalf("missing state should be (nil, nil), got (%v, %v)", s, err)
+	}
+	want := sampleDCAState()
+	if err := want.save(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := loadDCAState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Executions) != 4 || got.spent().Cmp(big.NewInt(200_000_000)) != 0 {
+		t.Fatalf("state didn't survive the round trip: %+v", got)
+	}
+}
+
+func TestDCAEngineSchedulingAndCap(t *testing.T) {
+	state := sampleDCAState()
+	sched, _ := parseSchedule("@daily")
+	de := &dcaEngine{state: state, sched: sched, maxTotal: big.NewInt(300_000_000)}
+
+	last, _ := state.lastRun()
+	// Back within a day of the last execution: the next daily slot.
+	now := last.Add(3 * time.Hour)
+	if got := de.nextRun(now); !got.Equal(time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("got %s", got)
+	}
+	// Down for a week: run once now, missed slots aren't replayed.
+	now = last.Add(7 * 24 * time.Hour)
+	if got := de.nextRun(now); !got.Equal(now) {
+		t.Fatalf("expected an immediate catch-up run, got %s", got)
+	}
+
+	if !de.capAllows(big.NewInt(100_000_000)) {
+		t.Fatalf("0.2 spent plus 0.1 is exactly the 0.3 cap")
+	}
+	if de.capAllows(big.NewInt(100_000_001)) {
+		t.Fatalf("expected the cap to stop the run")
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+NOTE(@hadydotai): Per-operation deadlines.
+
+Everything used to run under one 3 minute context, created at startup. A slow metadata RPC or a user taking their
+time in the TUI ate into the same budget the transaction needed to land, and the TUI quietly stopped working once the
+3 minutes were up. Now the process context only ends on interrupt, and each operation gets its own deadline, derived
+from whatever context it's called with:
+
+  - quote: loading a pool and reading its vault balances for a quote
+  - metadata: looking up the metadata of a pool's tokens, all of them at once, a missing symbol isn't worth waiting
+    on, we fall back to the mint
+  - send: planning, signing, sending and confirming a swap, one budget for all of it. A blockhash is good for
+    about a minute, 90s covers that plus confirmation
+  - watch: how long -watch keeps going, 0 runs until interrupted
+
+-deadlines overrides any of them, e.g. `-deadlines quote=5s,send=2m`. Zero means no deadline.
+*/
+
+type deadlinePolicy struct {
+	Quote    time.Duration
+	Metadata time.Duration
+	Send     time.Duration
+	Watch    time.Duration
+}
+
+var defaultDeadlines = deadlinePolicy{
+	Quote:    10 * time.Second,
+	Metadata: 5 * time.Second,
+	Send:     90 * time.Second,
+	Watch:    0,
+}
+
+// deadlines is set by -deadlines.
+var deadlines = defaultDeadlines
+
+const deadlinesUsage = "Per-operation deadlines as op=duration pairs, any of quote, metadata, send, watch (0 means none)"
+
+// within derives a context that's done after d, or only when parent is if d is zero.
+func within(parent context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, d)
+}
+
+func (p deadlinePolicy) forQuote(ctx context.Context) (context.Context, context.CancelFunc) {
+	return within(ctx, p.Quote)
+}
+
+func (p deadlinePolicy) forMetadata(ctx context.Context) (context.Context, context.CancelFunc) {
+	return within(ctx, p.Metadata)
+}
+
+func (p deadlinePolicy) forSend(ctx context.Context) (context.Context, context.CancelFunc) {
+	return within(ctx, p.Send)
+}
+
+func (p deadlinePolicy) forWatch(ctx context.Context) (context.Context, context.CancelFunc) {
+	return within(ctx, p.Watch)
+}
+
+func (p *deadlinePolicy) fields() map[string]*time.Duration {
+	return map[string]*time.Duration{
+		"quote":    &p.Quote,
+		"metadata": &p.Metadata,
+		"send":     &p.Send,
+		"watch":    &p.Watch,
+	}
+}
+
+// String renders the policy the way Set reads it, in a fixed order.
+func (p *deadlinePolicy) String() string {
+	if p == nil {
+		return ""
+	}
+	return fmt.Sprintf("quote=%s,metadata=%s,send=%s,watch=%s", p.Quote, p.Metadata, p.Send, p.Watch)
+}
+
+// Set overrides the operations named in a comma separated op=duration list, the rest keep their deadline.
+func (p *deadlinePolicy) Set(v string) error {
+	next := *p
+	fields := next.fields()
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return fmt.Errorf("deadline %q must be <operation>=<duration>", part)
+		}
+		field, ok := fields[strings.TrimSpace(name)]
+		if !ok {
+			return fmt.Errorf("unknown operation %q, expected one of quote, metadata, send, watch", name)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("deadline for %s: %w", name, err)
+		}
+		if d < 0 {
+			return fmt.Errorf("deadline for %s can't be negative", name)
+		}
+		*field = d
+	}
+	*p = next
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDeadlinePolicySet(t *testing.T) {
+	p := defaultDeadlines
+	if err := p.Set("quote=5s, send=2m,watch=1h"); err != nil {
+		t.Fatal(err)
+	}
+	want := deadlinePolicy{Quote: 5 * time.Second, Metadata: 5 * time.Second, Send: 2 * time.Minute, Watch: time.Hour}
+	if p != want {
+		t.Fatalf("got %+v, want %+v", p, want)
+	}
+	if got := p.String(); got != "quote=5s,metadata=5s,send=2m0s,watch=1h0m0s" {
+		t.Errorf("String() = %q", got)
+	}
+	var round deadlinePolicy
+	if err := round.Set(p.String()); err != nil || round != p {
+		t.Errorf("round trip: got %+v, %v", round, err)
+	}
+	for _, bad := range []string{"quote", "confirm=5s", "send=soon", "quote=-1s"} {
+		before := p
+		if err := p.Set(bad); err == nil {
+			t.Errorf("Set(%q) accepted", bad)
+		}
+		if p != before {
+			t.Errorf("Set(%q) changed the policy to %+v", bad, p)
+		}
+	}
+}
+
+func TestWithin(t *testing.T) {
+	ctx, cancel := within(context.Background(), 0)
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("a zero deadline should leave the context without one")
+	}
+	ctx, cancel = defaultDeadlines.forMetadata(context.Background())
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	if !ok || time.Until(deadline) > defaultDeadlines.Metadata {
+		t.Errorf("metadata deadline %v, %v", deadline, ok)
+	}
+}
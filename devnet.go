@@ -0,0 +1,461 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	tokenprog "github.com/gagliardetto/solana-go/programs/token"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): Devnet helpers.
+
+Exercising the whole tool, swaps, limits, DCA, batch runs, shouldn't take mainnet funds, and devnet pools come and go
+with whatever tokens someone happened to make. `devnet` sets up a playground of your own:
+
+  - airdrop: asks the faucet for SOL for the hot wallet and waits for it to show up
+  - create-mint: a throwaway SPL token with the hot wallet as its mint authority and the whole supply minted to it,
+    -symbol records an alias so intents can name it right away
+  - create-pool: a CP-Swap pool of two mints (SOL works as is) seeded with the hot wallet's tokens, on the AmmConfig
+    -config picks (see amm-configs)
+
+They refuse any network but devnet, there's no faucet on mainnet and a pool made by mistake there costs real SOL.
+
+A pool's accounts are all PDAs of the program, seeded with its config and its mints in order, token 0 being the mint
+whose bytes sort first, which is what the program checks. The program takes a creation fee (the config's
+createPoolFee) into a receiver fixed per deployment, devnet's is below.
+*/
+
+// devnetCreatePoolFeeReceiver is where the devnet deployment of cp-swap takes pool creation fees.
+var devnetCreatePoolFeeReceiver = solana.MustPublicKeyFromBase58("G11FKBRaAkHAKuLCgLM6K6NUc9rTjPAznRCjZifrTQe2")
+
+// maxAirdropSOL is as much as the devnet faucet hands out in one request.
+const maxAirdropSOL = 5
+
+func runDevnetCommand(args []string) error {
+	return dispatchSubcommand("devnet", map[string]func([]string) error{
+		"airdrop":     runDevnetAirdropCommand,
+		"create-mint": runDevnetCreateMintCommand,
+		"create-pool": runDevnetCreatePoolCommand,
+	}, args)
+}
+
+// devnetSession is what every devnet helper starts from.
+type devnetSession struct {
+	client  *rpc.Client
+	payer   solana.PrivateKey
+	network string
+}
+
+// requireDevnet refuses every network but devnet.
+func requireDevnet(network string) error {
+	if network != "devnet" {
+		return fmt.Errorf("devnet helpers only run on devnet, not %s", network)
+	}
+	return nil
+}
+
+// startDevnet validates the subcommand's flags, refuses anything but devnet, loads the hot wallet and connects.
+func startDevnet(fs *flag.FlagSet, nf *networkFlags, hotwalletPath *string, specs ...FlagSpec) (*devnetSession, error) {
+	ValidateConfigOrExit(fs, append(append(nf.specs(),
+		FlagSpec{Name: "hotwallet", Value: hotwalletPath, Rules: []FlagRule{NotEmpty()}},
+	), specs...))
+	if err := requireDevnet(*nf.network); err != nil {
+		return nil, err
+	}
+	payer, err := solana.PrivateKeyFromSolanaKeygenFile(*hotwalletPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load private key from hot wallet: %w", err)
+	}
+	return &devnetSession{client: nf.connect(), payer: payer, network: *nf.network}, nil
+}
+
+// send signs ixs with the hot wallet and any signers, sends them and waits for them to land.
+func (s *devnetSession) send(ctx context.Context, ixs []solana.Instruction, signers ...solana.PrivateKey) (solana.Signature, error) {
+	ctx, cancel := deadlines.forSend(ctx)
+	defer cancel()
+	tx, err := unsignedTransaction(ctx, s.client, s.payer.PublicKey(), append(computeBudget.instructions(), ixs...))
+	if err != nil {
+		return solana.Signature{}, err
+	}
+	keys := append([]solana.PrivateKey{s.payer}, signers...)
+	if _, err := tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
+		for i := range keys {
+			if keys[i].PublicKey().Equals(key) {
+				return &keys[i]
+			}
+		}
+		return nil
+	}); err != nil {
+		return solana.Signature{}, fmt.Errorf("signing transaction failed: %w", err)
+	}
+	sig, err := sendTransaction(ctx, s.client, tx)
+	if err != nil {
+		return solana.Signature{}, err
+	}
+	fmt.Println(explorerTxURL(s.network, sig))
+	status, result, err := waitForTransactionResult(ctx, s.client, sig)
+	if err != nil {
+		return sig, fmt.Errorf("waiting for %s failed: %w", sig, err)
+	}
+	if status == "failed" {
+		failed := &txFailedError{sig: sig}
+		if result != nil && result.Meta != nil {
+			failed.txErr, failed.logs = result.Meta.Err, result.Meta.LogMessages
+		}
+		return sig, failed
+	}
+	return sig, nil
+}
+
+// requestAirdrop asks the faucet for lamports for owner and waits for its balance to show them.
+func requestAirdrop(ctx context.Context, client *rpc.Client, owner solana.PublicKey, lamports uint64) error {
+	before, err := client.GetBalance(ctx, owner, rpc.CommitmentConfirmed)
+	if err != nil {
+		return fmt.Errorf("rpc call getBalance failed: %w", err)
+	}
+	ctx, cancel := deadlines.forSend(ctx)
+	defer cancel()
+	if _, err := client.RequestAirdrop(ctx, owner, lamports, rpc.CommitmentConfirmed); err != nil {
+		return fmt.Errorf("rpc call requestAirdrop failed: %w", err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+		if now, err := client.GetBalance(ctx, owner, rpc.CommitmentConfirmed); err == nil && now.Value > before.Value {
+			return nil
+		}
+	}
+}
+
+func runDevnetAirdropCommand(args []string) error {
+	fs := flag.NewFlagSet("devnet airdrop", flag.ExitOnError)
+	nf := addNetworkFlags(fs)
+	var (
+		hotwalletPath = fs.String("hotwallet", "", "Path to the hotwallet to fund")
+		sol           = fs.String("sol", "1", fmt.Sprintf("How much SOL to ask for, at most %d", maxAirdropSOL))
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	s, err := startDevnet(fs, nf, hotwalletPath, FlagSpec{Name: "sol", Value: sol, Rules: []FlagRule{NotEmpty()}})
+	if err != nil {
+		return err
+	}
+	lamports, err := fmtForMath(*sol, 9)
+	if err != nil {
+		return fmt.Errorf("sol: %w", err)
+	}
+	if lamports.Cmp(new(big.Int).SetUint64(maxAirdropSOL*solana.LAMPORTS_PER_SOL)) > 0 {
+		return fmt.Errorf("the faucet hands out at most %d SOL at a time", maxAirdropSOL)
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+
+	fmt.Printf("Asking the faucet for %s for %s...\n", fmtSOL(lamports.Uint64()), s.payer.PublicKey())
+	if err := requestAirdrop(ctx, s.client, s.payer.PublicKey(), lamports.Uint64()); err != nil {
+		return fmt.Errorf("%w, the public faucet is rate limited, https://faucet.solana.com takes %s too", err, s.payer.PublicKey())
+	}
+	balance, err := s.client.GetBalance(ctx, s.payer.PublicKey(), rpc.CommitmentConfirmed)
+	if err != nil {
+		return fmt.Errorf("rpc call getBalance failed: %w", err)
+	}
+	fmt.Printf("The wallet holds %s.\n", fmtSOL(balance.Value))
+	return nil
+}
+
+// createMintInstructions make mint a classic SPL token with payer as its mint authority, no freeze authority, and
+// mint supply to payer's associated account, which they return.
+func createMintInstructions(payer, mint solana.PublicKey, rent uint64, decimals uint8, supply uint64) ([]solana.Instruction, solana.PublicKey, error) {
+	ata, err := associatedTokenAddress(payer, mint, solana.TokenProgramID)
+	if err != nil {
+		return nil, solana.PublicKey{}, err
+	}
+	initialize, err := tokenprog.NewInitializeMint2InstructionBuilder().
+		SetDecimals(decimals).
+		SetMintAuthority(payer).
+		SetMintAccount(mint).
+		ValidateAndBuild()
+	if err != nil {
+		return nil, solana.PublicKey{}, fmt.Errorf("building initialize mint failed: %w", err)
+	}
+	mintTo, err := tokenprog.NewMintToInstruction(supply, mint, ata, payer, nil).ValidateAndBuild()
+	if err != nil {
+		return nil, solana.PublicKey{}, fmt.Errorf("building mint to failed: %w", err)
+	}
+	return []solana.Instruction{
+		system.NewCreateAccountInstruction(rent, baseMintLen, solana.TokenProgramID, payer, mint).Build(),
+		initialize,
+		createATAInstruction(payer, ata, payer, mint, solana.TokenProgramID),
+		mintTo,
+	}, ata, nil
+}
+
+func runDevnetCreateMintCommand(args []string) error {
+	fs := flag.NewFlagSet("devnet create-mint", flag.ExitOnError)
+	nf := addNetworkFlags(fs)
+	addComputeBudgetFlags(fs)
+	var (
+		hotwalletPath = fs.String("hotwallet", "", "Path to the hotwallet that pays for the mint and receives its supply")
+		decimals      = fs.Uint("decimals", 6, "Decimals of the token, at most 9")
+		supply        = fs.String("supply", "1000000", "How many tokens to mint to the hot wallet")
+		symbol        = fs.String("symbol", "", "Record an alias for the new mint so intents can name it (see `alias`)")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	s, err := startDevnet(fs, nf, hotwalletPath, FlagSpec{Name: "supply", Value: supply, Rules: []FlagRule{NotEmpty()}})
+	if err != nil {
+		return err
+	}
+	if *decimals > 9 {
+		return fmt.Errorf("decimals has to be at most 9, got %d", *decimals)
+	}
+	amount, err := fmtForMath(*supply, uint8(*decimals))
+	if err != nil {
+		return fmt.Errorf("supply: %w", err)
+	}
+	if !amount.IsUint64() {
+		return fmt.Errorf("a supply of %s doesn't fit a mint", *supply)
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+
+	rent, err := s.client.GetMinimumBalanceForRentExemption(ctx, baseMintLen, rpc.CommitmentConfirmed)
+	if err != nil {
+		return fmt.Errorf("rpc call getMinimumBalanceForRentExemption failed: %w", err)
+	}
+	mint := solana.NewWallet().PrivateKey
+	ixs, ata, err := createMintInstructions(s.payer.PublicKey(), mint.PublicKey(), rent, uint8(*decimals), amount.Uint64())
+	if err != nil {
+		return err
+	}
+	if _, err := s.send(ctx, ixs, mint); err != nil {
+		return err
+	}
+	fmt.Printf("Mint %s, %s tokens in %s\n%s\n", mint.PublicKey(), fmtAmount(amount, uint8(*decimals)), ata, explorerAccountURL(s.network, mint.PublicKey()))
+	if *symbol != "" {
+		aliases, err := loadSymbolAliases(*nf.aliases)
+		if err != nil {
+			return err
+		}
+		sym, err := aliases.add(*symbol, mint.PublicKey().String())
+		if err != nil {
+			return fmt.Errorf("the mint was created, recording its alias failed: %w", err)
+		}
+		fmt.Printf("%s is now %s (%s)\n", sym, mint.PublicKey(), aliases.path)
+	}
+	return nil
+}
+
+// cpPoolAddresses are the PDAs of the pool of token0 and token1 under config.
+type cpPoolAddresses struct {
+	pool, authority, lpMint, vault0, vault1, observation solana.PublicKey
+}
+
+func deriveCPPoolAddresses(config, token0, token1 solana.PublicKey) (cpPoolAddresses, error) {
+	pda := func(seeds ...[]byte) (solana.PublicKey, error) {
+		addr, _, err := solana.FindProgramAddress(seeds, raydium_cp_swap.ProgramID)
+		return addr, err
+	}
+	var a cpPoolAddresses
+	var err error
+	if a.pool, err = pda([]byte("pool"), config.Bytes(), token0.Bytes(), token1.Bytes()); err != nil {
+		return a, fmt.Errorf("deriving the pool address failed: %w", err)
+	}
+	if a.authority, err = swapAuthority(); err != nil {
+		return a, err
+	}
+	for _, p := range []struct {
+		dst   *solana.PublicKey
+		seeds [][]byte
+	}{
+		{&a.lpMint, [][]byte{[]byte("pool_lp_mint"), a.pool.Bytes()}},
+		{&a.vault0, [][]byte{[]byte("pool_vault"), a.pool.Bytes(), token0.Bytes()}},
+		{&a.vault1, [][]byte{[]byte("pool_vault"), a.pool.Bytes(), token1.Bytes()}},
+		{&a.observation, [][]byte{[]byte("observation"), a.pool.Bytes()}},
+	} {
+		if *p.dst, err = pda(p.seeds...); err != nil {
+			return a, fmt.Errorf("deriving the pool's accounts failed: %w", err)
+		}
+	}
+	return a, nil
+}
+
+// poolSide is one of the mints a pool is created with and what it's seeded with.
+type poolSide struct {
+	mint   *mintAccount
+	amount *big.Int
+}
+
+// orderPoolSides puts the sides in the program's order, token 0 is the mint whose bytes sort first.
+func orderPoolSides(a, b poolSide) (poolSide, poolSide, error) {
+	switch bytes.Compare(a.mint.Address.Bytes(), b.mint.Address.Bytes()) {
+	case 0:
+		return poolSide{}, poolSide{}, errors.New("a pool needs two different mints")
+	case 1:
+		return b, a, nil
+	}
+	return a, b, nil
+}
+
+// parsePoolMint reads a mint argument, SOL being wSOL.
+func parsePoolMint(s string) (solana.PublicKey, error) {
+	if normalizeSymbol(s) == "SOL" {
+		return wSOLMint, nil
+	}
+	mint, err := solana.PublicKeyFromBase58(s)
+	if err != nil {
+		return solana.PublicKey{}, fmt.Errorf("mint %q isn't SOL or a base58 address: %w", s, err)
+	}
+	return mint, nil
+}
+
+func runDevnetCreatePoolCommand(args []string) error {
+	fs := flag.NewFlagSet("devnet create-pool", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: raydium-client devnet create-pool [flags] <mint-a> <mint-b>\n")
+		fs.PrintDefaults()
+	}
+	nf := addNetworkFlags(fs)
+	addComputeBudgetFlags(fs)
+	var (
+		hotwalletPath = fs.String("hotwallet", "", "Path to the hotwallet that creates the pool and seeds it")
+		amountA       = fs.String("amount-a", "", "How much of the first mint to seed the pool with")
+		amountB       = fs.String("amount-b", "", "How much of the second mint to seed the pool with")
+		configIndex   = fs.Uint("config", 0, "Index of the AmmConfig (fee tier) the pool uses, see `amm-configs`")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	s, err := startDevnet(fs, nf, hotwalletPath,
+		FlagSpec{Name: "amount-a", Value: amountA, Rules: []FlagRule{NotEmpty()}},
+		FlagSpec{Name: "amount-b", Value: amountB, Rules: []FlagRule{NotEmpty()}},
+	)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("create-pool takes the two mints, e.g. `devnet create-pool -amount-a 10 -amount-b 1000 SOL <mint>`")
+	}
+	if *configIndex > 0xffff {
+		return fmt.Errorf("config index %d is past the last there can be", *configIndex)
+	}
+	var mints [2]solana.PublicKey
+	for i := range mints {
+		if mints[i], err = parsePoolMint(fs.Arg(i)); err != nil {
+			return err
+		}
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+
+	quoteCtx, cancel := deadlines.forQuote(ctx)
+	defer cancel()
+	accounts, err := fetchMintAccounts(quoteCtx, s.client, mints[0], mints[1])
+	if err != nil {
+		return err
+	}
+	var sides [2]poolSide
+	for i, amount := range []string{*amountA, *amountB} {
+		sides[i].mint = accounts[i]
+		if sides[i].amount, err = fmtForMath(amount, accounts[i].Decimals); err != nil {
+			return fmt.Errorf("amount of %s: %w", Addr(mints[i].String()), err)
+		}
+	}
+	side0, side1, err := orderPoolSides(sides[0], sides[1])
+	if err != nil {
+		return err
+	}
+
+	config, err := ammConfigPDA(uint16(*configIndex))
+	if err != nil {
+		return err
+	}
+	configAcc, err := accountOrNil(quoteCtx, s.client, config)
+	if err != nil {
+		return err
+	}
+	if configAcc == nil {
+		return fmt.Errorf("there's no AmmConfig with index %d, `amm-configs` lists them", *configIndex)
+	}
+	ammConfig, err := raydium_cp_swap.ParseAccount_AmmConfig(configAcc.Data.GetBinary())
+	if err != nil {
+		return fmt.Errorf("decoding AmmConfig %d failed: %w", *configIndex, err)
+	}
+	if ammConfig.DisableCreatePool {
+		return fmt.Errorf("AmmConfig %d doesn't take new pools", *configIndex)
+	}
+	addrs, err := deriveCPPoolAddresses(config, side0.mint.Address, side1.mint.Address)
+	if err != nil {
+		return err
+	}
+	if existing, err := accountOrNil(quoteCtx, s.client, addrs.pool); err != nil {
+		return err
+	} else if existing != nil {
+		return fmt.Errorf("pool %s already trades this pair on AmmConfig %d", addrs.pool, *configIndex)
+	}
+
+	wsol, err := newWSOLManager(s.client, s.payer.PublicKey())
+	if err != nil {
+		return err
+	}
+	var ixs []solana.Instruction
+	var creatorAccounts [2]solana.PublicKey
+	for i, side := range []poolSide{side0, side1} {
+		if isNativeSOL(side.mint.Address) {
+			var wrap []solana.Instruction
+			if creatorAccounts[i], wrap, err = wsol.prepareInput(quoteCtx, side.amount); err != nil {
+				return err
+			}
+			ixs = append(ixs, wrap...)
+			continue
+		}
+		if creatorAccounts[i], err = associatedTokenAddress(s.payer.PublicKey(), side.mint.Address, side.mint.Program); err != nil {
+			return err
+		}
+	}
+	creatorLP, err := associatedTokenAddress(s.payer.PublicKey(), addrs.lpMint, solana.TokenProgramID)
+	if err != nil {
+		return err
+	}
+	initialize, err := raydium_cp_swap.NewInitializeInstruction(
+		side0.amount.Uint64(), side1.amount.Uint64(), 0,
+		s.payer.PublicKey(), config, addrs.authority, addrs.pool,
+		side0.mint.Address, side1.mint.Address, addrs.lpMint,
+		creatorAccounts[0], creatorAccounts[1], creatorLP,
+		addrs.vault0, addrs.vault1, devnetCreatePoolFeeReceiver, addrs.observation,
+		solana.TokenProgramID, side0.mint.Program, side1.mint.Program,
+		solana.SPLAssociatedTokenAccountProgramID, solana.SystemProgramID, solana.SysVarRentPubkey,
+	)
+	if err != nil {
+		return fmt.Errorf("building the initialize instruction failed: %w", err)
+	}
+	ixs = append(ixs, initialize)
+	closeIxs, err := wsol.closeInstructions()
+	if err != nil {
+		return err
+	}
+	cancel()
+
+	fmt.Printf("Creating pool %s on AmmConfig %d (%s trade fee, %s creation fee)\n",
+		addrs.pool, *configIndex, formatFeeRate(ammConfig.TradeFeeRate), fmtSOL(ammConfig.CreatePoolFee))
+	if _, err := s.send(ctx, append(ixs, closeIxs...)); err != nil {
+		return err
+	}
+	fmt.Printf("Pool %s is open, swap on it with -network devnet -pool %s\n%s\n", addrs.pool, addrs.pool, explorerAccountURL(s.network, addrs.pool))
+	return nil
+}
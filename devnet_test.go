@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+func TestRequireDevnet(t *testing.T) {
+	if err := requireDevnet("devnet"); err != nil {
+		t.Error(err)
+	}
+	if err := requireDevnet("mainnet"); err == nil || !strings.Contains(err.Error(), "only run on devnet") {
+		t.Errorf("mainnet: %v", err)
+	}
+}
+
+func TestCreateMintInstructions(t *testing.T) {
+	payer, mint := snapshotKey(1), snapshotKey(2)
+	ixs, ata, err := createMintInstructions(payer, mint, 1_461_600, 6, 1_000_000_000_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := associatedTokenAddress(payer, mint, solana.TokenProgramID)
+	if !ata.Equals(want) {
+		t.Errorf("supply goes to %s, want %s", ata, want)
+	}
+	programs := []solana.PublicKey{solana.SystemProgramID, solana.TokenProgramID, solana.SPLAssociatedTokenAccountProgramID, solana.TokenProgramID}
+	if len(ixs) != len(programs) {
+		t.Fatalf("%d instructions, want %d", len(ixs), len(programs))
+	}
+	for i, p := range programs {
+		if !ixs[i].ProgramID().Equals(p) {
+			t.Errorf("instruction %d goes to %s, want %s", i, ixs[i].ProgramID(), p)
+		}
+	}
+	// The new mint account signs its own creation.
+	if accounts := ixs[0].Accounts(); !accounts[1].PublicKey.Equals(mint) || !accounts[1].IsSigner {
+		t.Errorf("create account accounts %v", accounts)
+	}
+	// InitializeMint2 is tag 20, decimals, the mint authority and no freeze authority.
+	if data, _ := ixs[1].Data(); data[0] != 20 || data[1] != 6 || !bytes.Equal(data[2:34], payer.Bytes()) || data[34] != 0 {
+		t.Errorf("initialize mint data %x", data)
+	}
+}
+
+func TestDeriveCPPoolAddresses(t *testing.T) {
+	config, token0, token1 := snapshotKey(3), snapshotKey(4), snapshotKey(5)
+	addrs, err := deriveCPPoolAddresses(config, token0, token1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, _, _ := solana.FindProgramAddress([][]byte{[]byte("pool"), config.Bytes(), token0.Bytes(), token1.Bytes()}, raydium_cp_swap.ProgramID)
+	vault1, _, _ := solana.FindProgramAddress([][]byte{[]byte("pool_vault"), pool.Bytes(), token1.Bytes()}, raydium_cp_swap.ProgramID)
+	auth, _ := swapAuthority()
+	if !addrs.pool.Equals(pool) || !addrs.vault1.Equals(vault1) || !addrs.authority.Equals(auth) {
+		t.Errorf("addresses %+v", addrs)
+	}
+	if addrs.vault0.Equals(addrs.vault1) || addrs.lpMint.Equals(addrs.observation) {
+		t.Error("two of the pool's accounts derived the same")
+	}
+	swapped, _ := deriveCPPoolAddresses(config, token1, token0)
+	if swapped.pool.Equals(pool) {
+		t.Error("the mints' order doesn't change the pool address")
+	}
+}
+
+func TestOrderPoolSides(t *testing.T) {
+	low := poolSide{mint: &mintAccount{Address: snapshotKey(1)}, amount: big.NewInt(1)}
+	high := poolSide{mint: &mintAccount{Address: snapshotKey(2)}, amount: big.NewInt(2)}
+	for _, in := range [][2]poolSide{{low, high}, {high, low}} {
+		a, b, err := orderPoolSides(in[0], in[1])
+		if err != nil || a.amount.Int64() != 1 || b.amount.Int64() != 2 {
+			t.Errorf("ordered as %v, %v (%v)", a.amount, b.amount, err)
+		}
+	}
+	if _, _, err := orderPoolSides(low, low); err == nil {
+		t.Error("a pool of one mint with itself was ordered")
+	}
+}
+
+func TestParsePoolMint(t *testing.T) {
+	if got, err := parsePoolMint("sol"); err != nil || !got.Equals(wSOLMint) {
+		t.Errorf("sol is %s, %v", got, err)
+	}
+	if got, err := parsePoolMint(devnetUSDCMint); err != nil || got.String() != devnetUSDCMint {
+		t.Errorf("a mint is %s, %v", got, err)
+	}
+	if _, err := parsePoolMint("USDC"); err == nil {
+		t.Error("a symbol parsed as a mint")
+	}
+}
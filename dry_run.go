@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+/*
+NOTE(@hadydotai): Dry runs, a ledger of the swap.
+
+-dry-run plans the swap exactly as it would go out (planSwap: compute budget, account creation, wrapping, the swap,
+closing) and, instead of sending it, shows where every lamport and token unit would go, instruction by instruction.
+Closing the wrong wSOL account, or closing it before the swap is done with it, is how a swap burns money (see wsol.go),
+and the transaction's overall balance changes don't show which instruction did it.
+
+A simulation only reports the accounts as they are once the whole transaction ran, so each instruction gets its own:
+the first k instructions are simulated for k = 1..n, asking the node for the tracked accounts after each, and the
+difference between one prefix and the next is what instruction k did. The starting point is the accounts as they are
+now. Tracked are the wallet, its accounts for both tokens (the wSOL account for SOL) and the pool's vaults for both,
+every account a plain swap moves value between. Each prefix is an unsigned transaction with its blockhash replaced by
+the node, nothing is signed and nothing can be sent on.
+
+Lamports between the tracked accounts have to add up: creating an account moves rent from the wallet into it, wrapping
+moves SOL into the wSOL account, the swap moves it to the vault, closing moves what's left back to the wallet. Any
+instruction after the compute budget that leaves the tracked accounts with fewer lamports than before sent them
+somewhere the ledger doesn't see, and that's called out under the ledger. Tokens don't have to add up, syncing wSOL
+turns lamports into tokens and a Token-2022 fee holds some back.
+*/
+
+// ledgerAccount is an account the dry run follows.
+type ledgerAccount struct {
+	label   string
+	address solana.PublicKey
+}
+
+// ledgerBalance is an account's lamports and, for a token account, its token amount. A missing account is all zero.
+type ledgerBalance struct {
+	lamports uint64
+	tokens   *big.Int // nil when the account isn't a token account
+}
+
+func ledgerBalanceOf(acc *rpc.Account) ledgerBalance {
+	if acc == nil {
+		return ledgerBalance{}
+	}
+	bal := ledgerBalance{lamports: acc.Lamports}
+	data := acc.Data.GetBinary()
+	if (acc.Owner.Equals(solana.TokenProgramID) || acc.Owner.Equals(solana.Token2022ProgramID)) && len(data) >= tokenAccountAmountOffset+8 {
+		bal.tokens = new(big.Int).SetUint64(binary.LittleEndian.Uint64(data[tokenAccountAmountOffset : tokenAccountAmountOffset+8]))
+	}
+	return bal
+}
+
+// ledgerStep is what one instruction did to the tracked accounts.
+type ledgerStep struct {
+	instruction string
+	before      []ledgerBalance
+	after       []ledgerBalance
+}
+
+// lamportDelta is how much account i's lamports changed.
+func (s ledgerStep) lamportDelta(i int) *big.Int {
+	return new(big.Int).Sub(new(big.Int).SetUint64(s.after[i].lamports), new(big.Int).SetUint64(s.before[i].lamports))
+}
+
+// tokenDelta is how much account i's tokens changed, a missing account holding none.
+func (s ledgerStep) tokenDelta(i int) *big.Int {
+	tokens := func(b ledgerBalance) *big.Int {
+		if b.tokens == nil {
+			return new(big.Int)
+		}
+		return b.tokens
+	}
+	return new(big.Int).Sub(tokens(s.after[i]), tokens(s.before[i]))
+}
+
+// leaked is the lamports the tracked accounts lost in total, zero when they add up.
+func (s ledgerStep) leaked() *big.Int {
+	net := new(big.Int)
+	for i := range s.after {
+		net.Add(net, s.lamportDelta(i))
+	}
+	if net.Sign() >= 0 {
+		return new(big.Int)
+	}
+	return net.Neg(net)
+}
+
+type dryRunLedger struct {
+	accounts []ledgerAccount
+	decimals []uint8 // each account's token decimals
+	steps    []ledgerStep
+}
+
+// ledgerAccounts are the accounts intent's swap moves value between for payer.
+func ledgerAccounts(plan *swapPlan, symm SymbolMapping) ([]ledgerAccount, []uint8) {
+	intent := plan.intent
+	inSym, outSym := symm.SymFrom(intent.TokenIn.Mint), symm.SymFrom(intent.TokenOut.Mint)
+	accountLabel := func(mint solana.PublicKey, sym string) string {
+		if isNativeSOL(mint) {
+			return "wallet wSOL account"
+		}
+		return "wallet " + sym + " account"
+	}
+	return []ledgerAccount{
+			{label: "wallet", address: plan.payer},
+			{label: accountLabel(intent.TokenIn.Mint, inSym), address: plan.inputATA},
+			{label: accountLabel(intent.TokenOut.Mint, outSym), address: plan.outputATA},
+			{label: "pool " + inSym + " vault", address: intent.TokenIn.Vault},
+			{label: "pool " + outSym + " vault", address: intent.TokenOut.Vault},
+		},
+		[]uint8{9, intent.TokenIn.Decimals, intent.TokenOut.Decimals, intent.TokenIn.Decimals, intent.TokenOut.Decimals}
+}
+
+// describeInstruction names what ix does in a swap plan.
+func describeInstruction(ix solana.Instruction, symm SymbolMapping) string {
+	data, _ := ix.Data()
+	accounts := ix.Accounts()
+	switch program := ix.ProgramID(); {
+	case program.Equals(solana.ComputeBudget):
+		if len(data) > 0 && data[0] == 2 {
+			return "set compute unit limit"
+		}
+		return "set compute unit price"
+	case program.Equals(solana.SPLAssociatedTokenAccountProgramID) && len(accounts) > 3:
+		if isNativeSOL(accounts[3].PublicKey) {
+			return "create wSOL account"
+		}
+		return "create " + symm.SymFrom(accounts[3].PublicKey) + " account"
+	case program.Equals(solana.SystemProgramID):
+		return "wrap SOL"
+	case program.Equals(solana.TokenProgramID) && len(data) > 0 && data[0] == 17:
+		return "sync wSOL"
+	case program.Equals(solana.TokenProgramID) && len(data) > 0 && data[0] == 9:
+		return "close wSOL account"
+	case program.Equals(raydium_cp_swap.ProgramID):
+		return "swap"
+	default:
+		return "call " + program.String()
+	}
+}
+
+// simulateLedger simulates plan's instructions a prefix at a time and records what each one did to the tracked
+// accounts. When an instruction fails the ledger up to it comes back with the error.
+func simulateLedger(ctx context.Context, client *rpc.Client, plan *swapPlan, symm SymbolMapping) (*dryRunLedger, error) {
+	accounts, decimals := ledgerAccounts(plan, symm)
+	addresses := make([]solana.PublicKey, len(accounts))
+	for i, acc := range accounts {
+		addresses[i] = acc.address
+	}
+	start, err := client.GetMultipleAccountsWithOpts(ctx, addresses, &rpc.GetMultipleAccountsOpts{Encoding: solana.EncodingBase64, Commitment: rpc.CommitmentProcessed})
+	if err != nil {
+		return nil, fmt.Errorf("rpc call getMultipleAccounts for the swap's accounts failed: %w", err)
+	}
+	if len(start.Value) != len(addresses) {
+		return nil, fmt.Errorf("asked for %d accounts, the RPC answered with %d", len(addresses), len(start.Value))
+	}
+	before := make([]ledgerBalance, len(accounts))
+	for i, acc := range start.Value {
+		before[i] = ledgerBalanceOf(acc)
+	}
+	ledger := &dryRunLedger{accounts: accounts, decimals: decimals}
+	for k := 1; k <= len(plan.instructions); k++ {
+		ix := plan.instructions[k-1]
+		tx, err := unsignedTransaction(ctx, client, plan.payer, plan.instructions[:k])
+		if err != nil {
+			return nil, err
+		}
+		tx.Signatures = make([]solana.Signature, tx.Message.Header.NumRequiredSignatures)
+		res, err := client.SimulateTransactionWithOpts(ctx, tx, &rpc.SimulateTransactionOpts{
+			ReplaceRecentBlockhash: true,
+			Commitment:             rpc.CommitmentProcessed,
+			Accounts:               &rpc.SimulateTransactionAccountsOpts{Encoding: solana.EncodingBase64, Addresses: addresses},
+		})
+		name := describeInstruction(ix, symm)
+		if err != nil {
+			return nil, fmt.Errorf("simulating up to instruction %d (%s) failed: %w", k, name, err)
+		}
+		if res.Value == nil {
+			return nil, errors.New("simulating the swap failed, the node returned no result")
+		}
+		if res.Value.Err != nil {
+			if failure, ok := decodeProgramFailure(res.Value.Err, res.Value.Logs); ok {
+				return ledger, fmt.Errorf("instruction %d (%s) hit %s, nothing was sent", k, name, failure)
+			}
+			return ledger, fmt.Errorf("instruction %d (%s) failed: %v, nothing was sent", k, name, res.Value.Err)
+		}
+		if len(res.Value.Accounts) != len(addresses) {
+			return nil, fmt.Errorf("asked the simulation for %d accounts, it answered with %d", len(addresses), len(res.Value.Accounts))
+		}
+		after := make([]ledgerBalance, len(accounts))
+		for i, acc := range res.Value.Accounts {
+			after[i] = ledgerBalanceOf(acc)
+		}
+		ledger.steps = append(ledger.steps, ledgerStep{instruction: name, before: before, after: after})
+		before = after
+	}
+	return ledger, nil
+}
+
+// signedAmount is delta in decimals with its sign, + for what came in.
+func signedAmount(delta *big.Int, decimals uint8) string {
+	if delta.Sign() > 0 {
+		return "+" + fmtAmount(delta, decimals)
+	}
+	return fmtAmount(delta, decimals)
+}
+
+// renderLedger shows every instruction's changes to the tracked accounts, what each account ends up with net, and any
+// lamports that left the tracked accounts.
+func renderLedger(ledger *dryRunLedger) string {
+	builder := &strings.Builder{}
+	t := table.NewWriter()
+	t.SetOutputMirror(builder)
+	t.SetTitle("Dry run ledger")
+	t.Style().Size.WidthMax = 160
+	t.AppendHeader(table.Row{"#", "Instruction", "Account", "Lamports", "Tokens"})
+	var leaks []string
+	for k, step := range ledger.steps {
+		changed := false
+		for i, acc := range ledger.accounts {
+			lamports, tokens := step.lamportDelta(i), step.tokenDelta(i)
+			if lamports.Sign() == 0 && tokens.Sign() == 0 {
+				continue
+			}
+			tokenCell := ""
+			if tokens.Sign() != 0 {
+				tokenCell = signedAmount(tokens, ledger.decimals[i])
+			}
+			t.AppendRow(table.Row{k + 1, step.instruction, acc.label, signedAmount(lamports, 0), tokenCell})
+			changed = true
+		}
+		if !changed {
+			t.AppendRow(table.Row{k + 1, step.instruction, "no change", "", ""})
+		}
+		if leaked := step.leaked(); leaked.Sign() > 0 && !strings.HasPrefix(step.instruction, "set compute unit") {
+			leaks = append(leaks, fmt.Sprintf("Warning: instruction %d (%s) sends %s lamports out of the accounts above, check where they go before sending this swap.", k+1, step.instruction, leaked))
+		}
+	}
+	if len(ledger.steps) > 0 {
+		t.AppendSeparator()
+		first, last := ledger.steps[0], ledger.steps[len(ledger.steps)-1]
+		net := ledgerStep{before: first.before, after: last.after}
+		for i, acc := range ledger.accounts {
+			tokenCell := ""
+			if tokens := net.tokenDelta(i); tokens.Sign() != 0 {
+				tokenCell = signedAmount(tokens, ledger.decimals[i])
+			}
+			t.AppendRow(table.Row{"", "net", acc.label, signedAmount(net.lamportDelta(i), 0), tokenCell})
+		}
+	}
+	t.Render()
+	for _, leak := range leaks {
+		fmt.Fprintln(builder, leak)
+	}
+	return builder.String()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestDescribeInstruction(t *testing.T) {
+	usdc := snapshotKey(9)
+	symm := SymbolMapping{mintToSymbol: map[string]string{usdc.String(): "USDC"}}
+	ataFor := func(mint solana.PublicKey) solana.Instruction {
+		accounts := solana.AccountMetaSlice{
+			solana.Meta(snapshotKey(80)), solana.Meta(snapshotKey(81)), solana.Meta(snapshotKey(80)), solana.Meta(mint),
+		}
+		return solana.NewInstruction(solana.SPLAssociatedTokenAccountProgramID, accounts, nil)
+	}
+	for _, tc := range []struct {
+		ix   solana.Instruction
+		want string
+	}{
+		{solana.NewInstruction(solana.ComputeBudget, nil, []byte{2, 0, 0, 0, 0}), "set compute unit limit"},
+		{solana.NewInstruction(solana.ComputeBudget, nil, []byte{3, 0, 0, 0, 0, 0, 0, 0, 0}), "set compute unit price"},
+		{ataFor(usdc), "create USDC account"},
+		{ataFor(wSOLMint), "create wSOL account"},
+		{solana.NewInstruction(solana.SystemProgramID, nil, []byte{2, 0, 0, 0}), "wrap SOL"},
+		{solana.NewInstruction(solana.TokenProgramID, nil, []byte{17}), "sync wSOL"},
+		{solana.NewInstruction(solana.TokenProgramID, nil, []byte{9}), "close wSOL account"},
+		{solana.NewInstruction(raydium_cp_swap.ProgramID, nil, []byte{1}), "swap"},
+		{solana.NewInstruction(snapshotKey(7), nil, nil), "call " + snapshotKey(7).String()},
+	} {
+		if got := describeInstruction(tc.ix, symm); got != tc.want {
+			t.Errorf("%q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestLedgerBalanceOf(t *testing.T) {
+	if bal := ledgerBalanceOf(nil); bal.lamports != 0 || bal.tokens != nil {
+		t.Errorf("missing account %+v", bal)
+	}
+	token := &rpc.Account{Lamports: tokenAccountRent, Owner: solana.TokenProgramID, Data: rpc.DataBytesOrJSONFromBytes(tokenAccountData(wSOLMint, 42))}
+	if bal := ledgerBalanceOf(token); bal.lamports != tokenAccountRent || bal.tokens == nil || bal.tokens.Int64() != 42 {
+		t.Errorf("token account %+v", bal)
+	}
+	wallet := &rpc.Account{Lamports: 7, Owner: solana.SystemProgramID, Data: rpc.DataBytesOrJSONFromBytes(nil)}
+	if bal := ledgerBalanceOf(wallet); bal.lamports != 7 || bal.tokens != nil {
+		t.Errorf("wallet %+v", bal)
+	}
+}
+
+// wsolLedger is the ledger of selling 1 SOL for 150 USDC through a fresh wSOL account, with the close sending the
+// account's lamports back to the wallet, or somewhere outside the ledger.
+func wsolLedger(closeToWallet bool) *dryRunLedger {
+	const sol = 1_000_000_000
+	tokens := func(n int64) *big.Int { return big.NewInt(n) }
+	// wallet, wSOL account, USDC account, SOL vault, USDC vault
+	states := [][]ledgerBalance{
+		{{lamports: 5 * sol}, {}, {lamports: tokenAccountRent, tokens: tokens(0)}, {lamports: tokenAccountRent, tokens: tokens(1000 * sol)}, {lamports: tokenAccountRent, tokens: tokens(150_000_000_000)}},
+	}
+	next := func(change func(s []ledgerBalance)) {
+		s := append([]ledgerBalance(nil), states[len(states)-1]...)
+		change(s)
+		states = append(states, s)
+	}
+	next(func(s []ledgerBalance) {}) // compute budget
+	next(func(s []ledgerBalance) {
+		s[0].lamports -= tokenAccountRent
+		s[1] = ledgerBalance{lamports: tokenAccountRent, tokens: tokens(0)}
+	})
+	next(func(s []ledgerBalance) { s[0].lamports -= sol; s[1].lamports += sol })
+	next(func(s []ledgerBalance) { s[1].tokens = tokens(sol) })
+	next(func(s []ledgerBalance) {
+		s[1].lamports -= sol
+		s[1].tokens = tokens(0)
+		s[3].lamports += sol
+		s[3].tokens = tokens(1001 * sol)
+		s[2].tokens = tokens(150_000_000)
+		s[4].tokens = tokens(149_850_000_000)
+	})
+	next(func(s []ledgerBalance) {
+		if closeToWallet {
+			s[0].lamports += s[1].lamports
+		}
+		s[1] = ledgerBalance{}
+	})
+	names := []string{"set compute unit limit", "create wSOL account", "wrap SOL", "sync wSOL", "swap", "close wSOL account"}
+	ledger := &dryRunLedger{
+		accounts: []ledgerAccount{{label: "wallet"}, {label: "wallet wSOL account"}, {label: "wallet USDC account"}, {label: "pool SOL vault"}, {label: "pool USDC vault"}},
+		decimals: []uint8{9, 9, 6, 9, 6},
+	}
+	for i, name := range names {
+		ledger.steps = append(ledger.steps, ledgerStep{instruction: name, before: states[i], after: states[i+1]})
+	}
+	return ledger
+}
+
+func TestRenderLedger(t *testing.T) {
+	out := renderLedger(wsolLedger(true))
+	for _, want := range []string{"create wSOL account", "-2039280", "+1.000000000", "+150.000000", "no change"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("%q isn't in\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Warning") {
+		t.Errorf("every lamport is accounted for, still warned:\n%s", out)
+	}
+	// Net, the wallet paid the SOL it sold and got the rent back.
+	if !strings.Contains(out, "-1000000000") {
+		t.Errorf("the wallet's net isn't the SOL sold:\n%s", out)
+	}
+	if step := wsolLedger(true).steps[5]; step.lamportDelta(0).Int64() != tokenAccountRent || step.leaked().Sign() != 0 {
+		t.Errorf("closing to the wallet: %v to the wallet, %v leaked", step.lamportDelta(0), step.leaked())
+	}
+
+	burnt := renderLedger(wsolLedger(false))
+	if !strings.Contains(burnt, "Warning: instruction 6 (close wSOL account) sends 2039280 lamports out of the accounts above") {
+		t.Errorf("closing elsewhere wasn't called out:\n%s", burnt)
+	}
+}
+
+func TestLedgerTokenDelta(t *testing.T) {
+	step := ledgerStep{
+		before: []ledgerBalance{{}},
+		after:  []ledgerBalance{{lamports: 1, tokens: big.NewInt(5)}},
+	}
+	if d := step.tokenDelta(0); d.Int64() != 5 {
+		t.Errorf("a created account's tokens %v", d)
+	}
+	if d := (ledgerStep{before: step.after, after: step.before}).tokenDelta(0); d.Int64() != -5 {
+		t.Errorf("a closed account's tokens %v", d)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): Enhanced provider APIs.
+
+Plain RPC gets a pool's symbols one mint at a time, a getAccountInfo for the mint and another for its metadata account,
+which is most of a cold start. And it knows a wallet's history only as a list of signatures. Helius and Triton both
+serve the Digital Asset Standard (DAS) API on their RPC endpoints, getAssetBatch names every mint in one call, and
+Helius also has an enhanced transactions API that says what each of a wallet's transactions did ("swapped 1 SOL for
+150 USDC on Raydium").
+
+-enhanced-api turns a provider on, and it only ever comes first: a mint the provider doesn't name is looked up over
+plain RPC as before, and a history it can't serve is read with getSignaturesForAddress, with a warning either way.
+The provider is reached at -rpc, that's where its DAS API is. Helius' enhanced transactions live at api.helius.xyz and
+need the API key, which is taken from the -rpc URL's api-key parameter or HELIUS_API_KEY.
+*/
+
+// enhancedAPI is what a provider adds on top of plain RPC. Either method can fail, callers fall back on plain RPC.
+type enhancedAPI interface {
+	name() string
+	// assets names mints in one go, a mint missing from the map is one the provider doesn't know.
+	assets(ctx context.Context, mints []solana.PublicKey) (map[solana.PublicKey]Token, error)
+	// walletHistory is wallet's latest transactions, newest first, before the signature before when it's set.
+	walletHistory(ctx context.Context, wallet solana.PublicKey, before string, limit int) ([]walletTx, error)
+}
+
+// enhanced is the provider -enhanced-api picked, nil for plain RPC only.
+var enhanced enhancedAPI
+
+var errNoEnhancedHistory = errors.New("the provider has no transaction history API")
+
+// walletTx is a transaction in a wallet's history. Type and Description are only known from an enhanced API.
+type walletTx struct {
+	Signature   string    `json:"signature"`
+	Slot        uint64    `json:"slot"`
+	Time        time.Time `json:"time"`
+	Failed      bool      `json:"failed"`
+	Type        string    `json:"type,omitempty"`
+	Description string    `json:"description,omitempty"`
+	FeeLamports uint64    `json:"feeLamports,omitempty"`
+}
+
+func addEnhancedAPIFlag(fs *flag.FlagSet) *string {
+	name := new(string)
+	fs.Func("enhanced-api", "Use the -rpc provider's enhanced API for metadata and history, 'helius' or 'triton'", func(s string) error {
+		switch s = strings.ToLower(strings.TrimSpace(s)); s {
+		case "helius", "triton":
+			*name = s
+			return nil
+		}
+		return fmt.Errorf("unknown provider %q, expected helius or triton", s)
+	})
+	return name
+}
+
+// useEnhancedAPI sets enhanced to the provider called name reached at rpcEP.
+func useEnhancedAPI(name, rpcEP, network string) {
+	switch name {
+	case "helius":
+		key := os.Getenv("HELIUS_API_KEY")
+		if u, err := url.Parse(rpcEP); err == nil && key == "" {
+			key = u.Query().Get("api-key")
+		}
+		api := "https://api.helius.xyz"
+		if network == "devnet" {
+			api = "https://api-devnet.helius.xyz"
+		}
+		enhanced = &heliusAPI{das: dasAPI{provider: "helius", endpoint: rpcEP}, api: api, key: key}
+	case "triton":
+		enhanced = &dasAPI{provider: "triton", endpoint: rpcEP}
+	default:
+		enhanced = nil
+	}
+}
+
+// hideEndpoint is err with secret, an endpoint or API key it may quote, left out. Provider URLs carry the API key.
+func hideEndpoint(err error, secret string) error {
+	if secret == "" || !strings.Contains(err.Error(), secret) {
+		return err
+	}
+	return errors.New(strings.ReplaceAll(err.Error(), secret, "<redacted>"))
+}
+
+// dasAPI is the DAS API on a provider's RPC endpoint, Triton serves nothing else we use.
+type dasAPI struct {
+	provider string
+	endpoint string
+}
+
+func (d *dasAPI) name() string {
+	return d.provider
+}
+
+// dasAsset is the part of a DAS asset we read. Fungible tokens carry their symbol in token_info too, some only there.
+type dasAsset struct {
+	ID      string `json:"id"`
+	Content struct {
+		Metadata struct {
+			Name   string `json:"name"`
+			Symbol string `json:"symbol"`
+		} `json:"metadata"`
+	} `json:"content"`
+	TokenInfo struct {
+		Symbol string `json:"symbol"`
+	} `json:"token_info"`
+}
+
+// dasBatchLimit is the most ids getAssetBatch takes in one call.
+const dasBatchLimit = 1000
+
+func (d *dasAPI) assets(ctx context.Context, mints []solana.PublicKey) (map[solana.PublicKey]Token, error) {
+	found := make(map[solana.PublicKey]Token, len(mints))
+	for start := 0; start < len(mints); start += dasBatchLimit {
+		batch := mints[start:min(start+dasBatchLimit, len(mints))]
+		ids := make([]string, len(batch))
+		for i, mint := range batch {
+			ids[i] = mint.String()
+		}
+		var body struct {
+			Result []*dasAsset `json:"result"`
+			Error  *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		req := map[string]any{"jsonrpc": "2.0", "id": 1, "method": "getAssetBatch", "params": map[string]any{"ids": ids}}
+		if err := postJSON(ctx, d.endpoint, req, &body); err != nil {
+			return nil, fmt.Errorf("%s getAssetBatch failed: %w", d.provider, hideEndpoint(err, d.endpoint))
+		}
+		if body.Error != nil {
+			return nil, fmt.Errorf("%s getAssetBatch failed: %s", d.provider, body.Error.Message)
+		}
+		for _, asset := range body.Result {
+			if asset == nil {
+				continue
+			}
+			mint, err := solana.PublicKeyFromBase58(asset.ID)
+			if err != nil {
+				continue
+			}
+			symbol := asset.Content.Metadata.Symbol
+			if strings.TrimSpace(symbol) == "" {
+				symbol = asset.TokenInfo.Symbol
+			}
+			token := Token{Name: trimMeta(asset.Content.Metadata.Name), Symbol: trimMeta(symbol), Source: d.provider}
+			if token.Symbol != "" {
+				found[mint] = token
+			}
+		}
+	}
+	return found, nil
+}
+
+func (d *dasAPI) walletHistory(context.Context, solana.PublicKey, string, int) ([]walletTx, error) {
+	return nil, errNoEnhancedHistory
+}
+
+// heliusAPI is Helius' DAS API plus its enhanced transactions.
+type heliusAPI struct {
+	das dasAPI
+	api string
+	key string
+}
+
+func (h *heliusAPI) name() string {
+	return "helius"
+}
+
+func (h *heliusAPI) assets(ctx context.Context, mints []solana.PublicKey) (map[solana.PublicKey]Token, error) {
+	return h.das.assets(ctx, mints)
+}
+
+func (h *heliusAPI) walletHistory(ctx context.Context, wallet solana.PublicKey, before string, limit int) ([]walletTx, error) {
+	if h.key == "" {
+		return nil, errors.New("helius' transaction history needs an API key, in the -rpc URL's api-key or HELIUS_API_KEY")
+	}
+	q := url.Values{"api-key": {h.key}, "limit": {fmt.Sprint(limit)}}
+	if before != "" {
+		q.Set("before", before)
+	}
+	var body []struct {
+		Signature        string `json:"signature"`
+		Slot             uint64 `json:"slot"`
+		Timestamp        int64  `json:"timestamp"`
+		Type             string `json:"type"`
+		Source           string `json:"source"`
+		Description      string `json:"description"`
+		Fee              uint64 `json:"fee"`
+		TransactionError any    `json:"transactionError"`
+	}
+	if err := fetchJSON(ctx, h.api+"/v0/addresses/"+wallet.String()+"/transactions?"+q.Encode(), &body); err != nil {
+		return nil, fmt.Errorf("helius transaction history failed: %w", hideEndpoint(err, h.key))
+	}
+	txs := make([]walletTx, 0, len(body))
+	for _, tx := range body {
+		kind := tx.Type
+		if tx.Source != "" && tx.Source != "UNKNOWN" {
+			kind += " (" + tx.Source + ")"
+		}
+		txs = append(txs, walletTx{
+			Signature:   tx.Signature,
+			Slot:        tx.Slot,
+			Time:        time.Unix(tx.Timestamp, 0).UTC(),
+			Failed:      tx.TransactionError != nil,
+			Type:        kind,
+			Description: trimMeta(tx.Description),
+			FeeLamports: tx.Fee,
+		})
+	}
+	return txs, nil
+}
+
+// rpcWalletHistory is wallet's history as plain RPC has it, signatures and whether they failed.
+func rpcWalletHistory(ctx context.Context, client *rpc.Client, wallet solana.PublicKey, before string, limit int) ([]walletTx, error) {
+	opts := &rpc.GetSignaturesForAddressOpts{Limit: &limit, Commitment: rpc.CommitmentConfirmed}
+	if before != "" {
+		sig, err := solana.SignatureFromBase58(before)
+		if err != nil {
+			return nil, fmt.Errorf("-before %q isn't a signature: %w", before, err)
+		}
+		opts.Before = sig
+	}
+	sigs, err := client.GetSignaturesForAddressWithOpts(ctx, wallet, opts)
+	if err != nil {
+		return nil, fmt.Errorf("rpc call getSignaturesForAddress failed: %w", err)
+	}
+	txs := make([]walletTx, 0, len(sigs))
+	for _, s := range sigs {
+		tx := walletTx{Signature: s.Signature.String(), Slot: s.Slot, Failed: s.Err != nil}
+		if s.BlockTime != nil {
+			tx.Time = s.BlockTime.Time().UTC()
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
+
+// walletHistory reads wallet's history from the enhanced API when there's one that has it, from plain RPC otherwise.
+// It returns where the history came from.
+func walletHistory(ctx context.Context, client *rpc.Client, wallet solana.PublicKey, before string, limit int) ([]walletTx, string, error) {
+	if enhanced != nil {
+		txs, err := enhanced.walletHistory(ctx, wallet, before, limit)
+		if err == nil {
+			return txs, enhanced.name(), nil
+		}
+		if !errors.Is(err, errNoEnhancedHistory) {
+			log.Printf("warning: %v, reading the history over plain RPC", err)
+		}
+	}
+	txs, err := rpcWalletHistory(ctx, client, wallet, before, limit)
+	return txs, "rpc", err
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestEnhancedAPIMetadata(t *testing.T) {
+	defer func(saved enhancedAPI) { enhanced = saved }(enhanced)
+	named, fungible, unknown := snapshotKey(1), snapshotKey(2), snapshotKey(3)
+	var rpcCalls atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Method == "getAssetBatch" {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":[
+				{"id":%q,"content":{"metadata":{"name":"Bonk","symbol":"BONK\u001b[2J"}}},
+				{"id":%q,"content":{"metadata":{"name":"","symbol":""}},"token_info":{"symbol":"USDT"}},
+				null]}`, named, fungible)
+			return
+		}
+		rpcCalls.Add(1)
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"context":{"slot":1},"value":null}}`, req.ID)
+	}))
+	t.Cleanup(srv.Close)
+
+	useEnhancedAPI("triton", srv.URL, "mainnet")
+	symm := makeSymbolMapping(t.Context(), rpc.New(srv.URL), []solana.PublicKey{named, fungible, unknown})
+	if symm.SymFrom(named) != "BONK[2J" || symm.SourceOf(named) != "triton" || symm.SymFrom(fungible) != "USDT" {
+		t.Errorf("named %q from %q, fungible %q", symm.SymFrom(named), symm.SourceOf(named), symm.SymFrom(fungible))
+	}
+	// Only the mint the provider didn't know went out over plain RPC.
+	if symm.SourceOf(unknown) != symbolSourceMint || rpcCalls.Load() != 1 {
+		t.Errorf("unknown mint from %q after %d RPC calls", symm.SourceOf(unknown), rpcCalls.Load())
+	}
+
+	// A provider that's down is only a warning, everything's looked up the plain way.
+	useEnhancedAPI("triton", "http://127.0.0.1:1/secret-token", "mainnet")
+	if _, err := enhanced.assets(t.Context(), []solana.PublicKey{named}); err == nil || strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("unreachable provider: %v", err)
+	}
+}
+
+func TestWalletHistory(t *testing.T) {
+	defer func(saved enhancedAPI) { enhanced = saved }(enhanced)
+	wallet, sig := snapshotKey(9), solana.Signature{7}
+	helius := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v0/addresses/"+wallet.String()+"/transactions" || r.URL.Query().Get("api-key") != "k" || r.URL.Query().Get("limit") != "5" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `[{"signature":%q,"slot":42,"timestamp":1772366400,"type":"SWAP","source":"RAYDIUM","description":"swapped 1 SOL for 150 USDC","fee":5000,"transactionError":null}]`, sig)
+	}))
+	t.Cleanup(helius.Close)
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":[{"signature":%q,"slot":42,"blockTime":1772366400,"err":{"InstructionError":[0,{"Custom":6005}]},"memo":null}]}`, req.ID, sig)
+	}))
+	t.Cleanup(node.Close)
+	client := rpc.New(node.URL)
+
+	useEnhancedAPI("helius", node.URL+"/?api-key=k", "mainnet")
+	enhanced.(*heliusAPI).api = helius.URL
+	txs, source, err := walletHistory(t.Context(), client, wallet, "", 5)
+	if err != nil || source != "helius" || len(txs) != 1 || txs[0].Type != "SWAP (RAYDIUM)" || txs[0].FeeLamports != 5000 || txs[0].Failed {
+		t.Fatalf("helius history %+v from %s, %v", txs, source, err)
+	}
+	if out := renderWalletHistory(wallet, txs, source); !strings.Contains(out, "swapped 1 SOL for 150 USDC") || !strings.Contains(out, "2026-03-01 12:00:00") {
+		t.Errorf("rendered\n%s", out)
+	}
+
+	// Triton has no history API, and Helius without a key can't use its own, both read it over plain RPC.
+	for _, provider := range []string{"triton", "helius"} {
+		useEnhancedAPI(provider, node.URL, "mainnet")
+		txs, source, err := walletHistory(t.Context(), client, wallet, "", 5)
+		if err != nil || source != "rpc" || len(txs) != 1 || !txs[0].Failed || txs[0].Type != "" || txs[0].Slot != 42 {
+			t.Errorf("%s: history %+v from %s, %v", provider, txs, source, err)
+		}
+	}
+}
+
+func TestHideEndpoint(t *testing.T) {
+	err := hideEndpoint(errors.New(`Get "https://rpc.example.com/?api-key=abc": EOF`), "abc")
+	if strings.Contains(err.Error(), "abc") {
+		t.Errorf("%v", err)
+	}
+}
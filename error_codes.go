@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+/*
+NOTE(@hadydotai): What went wrong, for scripts.
+
+Everything used to fail with exit status 1 and a sentence, and anything driving the client had to match on the
+sentence to tell "the pool is paused" from "the RPC is down". The failures worth telling apart are five kinds, each
+with its own exit status and, wherever JSON carries an "error", a "code" next to it:
+
+  | kind                     | exit | code                     |
+  |--------------------------|------|--------------------------|
+  | ErrInsufficientLiquidity | 3    | insufficient_liquidity   |
+  | ErrSlippageExceeded      | 4    | slippage_exceeded        |
+  | ErrPoolDisabled          | 5    | pool_disabled            |
+  | ErrRPCUnavailable        | 6    | rpc_unavailable          |
+  | ErrAccountMissing        | 7    | account_missing          |
+
+Anything else is exit status 1 and code "failed", and a configuration error is still 2 with no JSON at all.
+
+Errors are marked where we know what they are (the curve running out of reserves, the pool's status, a pool or vault
+that isn't there, the quote drifting past the guard before sending) with classify, which keeps the message as it was
+and makes errors.Is(err, ErrPoolDisabled) true. What comes back from the chain and the RPC is known by its shape
+instead: a program error by its cp-swap code (see pool_fallback.go), a missing account the way isAccountMissingErr
+knows one, and an RPC that can't be reached, rate limits us (429), fails on its side (5xx) or is behind (-32005).
+
+Build returns an unquotableError when it could quote nothing, with the report that shows why, so the kind of the
+failure makes it out of Build too.
+*/
+
+var (
+	ErrInsufficientLiquidity = errors.New("insufficient liquidity")
+	ErrSlippageExceeded      = errors.New("slippage exceeded")
+	ErrPoolDisabled          = errors.New("pool disabled")
+	ErrRPCUnavailable        = errors.New("rpc unavailable")
+	ErrAccountMissing        = errors.New("account missing")
+)
+
+// Exit statuses other than those of errorKinds.
+const (
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+// errorKind is one kind of failure as automation sees it.
+type errorKind struct {
+	err  error
+	code string
+	exit int
+}
+
+var errorKinds = []errorKind{
+	{ErrInsufficientLiquidity, "insufficient_liquidity", 3},
+	{ErrSlippageExceeded, "slippage_exceeded", 4},
+	{ErrPoolDisabled, "pool_disabled", 5},
+	{ErrRPCUnavailable, "rpc_unavailable", 6},
+	{ErrAccountMissing, "account_missing", 7},
+}
+
+// classifiedError is err marked as kind, it reads as err and errors.Is matches kind.
+type classifiedError struct {
+	kind error
+	err  error
+}
+
+func (e *classifiedError) Error() string        { return e.err.Error() }
+func (e *classifiedError) Unwrap() error        { return e.err }
+func (e *classifiedError) Is(target error) bool { return target == e.kind }
+
+// classify marks err as kind, one of the Err kinds above. A nil err stays nil.
+func classify(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{kind: kind, err: err}
+}
+
+// classifyRPC marks err, from reading accounts, as a missing account or otherwise an RPC that couldn't answer.
+func classifyRPC(err error) error {
+	if isAccountMissingErr(err) {
+		return classify(ErrAccountMissing, err)
+	}
+	return classify(ErrRPCUnavailable, err)
+}
+
+// cpSwapErrorKinds are the kinds of cp-swap's custom errors that have one.
+var cpSwapErrorKinds = map[uint64]error{
+	cpSwapErrNotApproved:       ErrPoolDisabled,
+	cpSwapErrExceededSlippage:  ErrSlippageExceeded,
+	cpSwapErrZeroTradingTokens: ErrInsufficientLiquidity,
+	cpSwapErrInsufficientVault: ErrInsufficientLiquidity,
+}
+
+// kindOf is the kind of err, nil when it isn't one.
+func kindOf(err error) *errorKind {
+	if err == nil {
+		return nil
+	}
+	for i := range errorKinds {
+		if errors.Is(err, errorKinds[i].err) {
+			return &errorKinds[i]
+		}
+	}
+	var kind error
+	if code, ok := customErrorCode(err); ok {
+		kind = cpSwapErrorKinds[code]
+	}
+	switch {
+	case kind != nil:
+	case isAccountMissingErr(err):
+		kind = ErrAccountMissing
+	case rpcUnavailable(err):
+		kind = ErrRPCUnavailable
+	default:
+		return nil
+	}
+	for i := range errorKinds {
+		if errorKinds[i].err == kind {
+			return &errorKinds[i]
+		}
+	}
+	return nil
+}
+
+// rpcUnavailable reports whether err is the RPC not answering rather than answering no.
+func rpcUnavailable(err error) bool {
+	var httpErr *jsonrpc.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code == http.StatusTooManyRequests || httpErr.Code >= 500
+	}
+	var rpcErr *jsonrpc.RPCError
+	if errors.As(err, &rpcErr) {
+		// -32005 is a node behind the cluster, -32004 a block it no longer has.
+		return rpcErr.Code == -32005 || rpcErr.Code == -32004
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// errorCode is err's code for JSON, "" for no error.
+func errorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+	if kind := kindOf(err); kind != nil {
+		return kind.code
+	}
+	return "failed"
+}
+
+// exitCode is the exit status for err.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if kind := kindOf(err); kind != nil {
+		return kind.exit
+	}
+	return exitFailure
+}
+
+// unquotableError is an intent Build couldn't quote, the report Build returns with it shows why.
+type unquotableError struct {
+	intent string
+	err    error
+}
+
+func (e *unquotableError) Error() string {
+	return fmt.Sprintf("intent %q can't be quoted against the pool's current reserves: %v", e.intent, e.err)
+}
+
+func (e *unquotableError) Unwrap() error { return e.err }
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+func TestErrorKinds(t *testing.T) {
+	pool, addr, _ := snapshotPool()
+	paused := *pool
+	paused.Status = poolStatusDisableSwap
+	cases := []struct {
+		name string
+		err  error
+		code string
+		exit int
+	}{
+		{"nil", nil, "", 0},
+		{"plain", errors.New("something else"), "failed", exitFailure},
+		{"curve", classify(ErrInsufficientLiquidity, errors.New("requested 2000 exceeds available 1000 liquidity")), "insufficient_liquidity", 3},
+		{"wrapped", fmt.Errorf("building: %w", classify(ErrInsufficientLiquidity, errors.New("short"))), "insufficient_liquidity", 3},
+		{"slippage on chain", asPoolFailure(addr, errors.New(`{"InstructionError":[3,{"Custom":6005}]}`)), "slippage_exceeded", 4},
+		{"slippage in logs", errors.New("Program log: custom program error: 0x1775"), "slippage_exceeded", 4},
+		{"drift", &quoteDriftError{drift: 2, slippage: 0.5}, "slippage_exceeded", 4},
+		{"vault short", asPoolFailure(addr, errors.New(`map[Custom:6012]`)), "insufficient_liquidity", 3},
+		{"paused", checkPoolTradable(addr, &paused, time.Now()), "pool_disabled", 5},
+		{"rate limited", fmt.Errorf("reading: %w", jsonrpc.NewHTTPError(http.StatusTooManyRequests, errors.New("too many requests"))), "rpc_unavailable", 6},
+		{"node behind", &jsonrpc.RPCError{Code: -32005, Message: "node is behind"}, "rpc_unavailable", 6},
+		{"bad request", jsonrpc.NewHTTPError(http.StatusBadRequest, errors.New("bad request")), "failed", exitFailure},
+		{"not found", fmt.Errorf("getAccountInfo: %w", rpc.ErrNotFound), "account_missing", 7},
+		{"no pool", &poolNotFoundError{pool: addr, cluster: "devnet"}, "account_missing", 7},
+		{"read failed", classifyRPC(errors.New("connection refused")), "rpc_unavailable", 6},
+	}
+	for _, c := range cases {
+		if got := errorCode(c.err); got != c.code {
+			t.Errorf("%s: code %q, want %q", c.name, got, c.code)
+		}
+		if got := exitCode(c.err); got != c.exit {
+			t.Errorf("%s: exit %d, want %d", c.name, got, c.exit)
+		}
+	}
+	if err := classify(ErrPoolDisabled, errors.New("paused")); err.Error() != "paused" || !errors.Is(err, ErrPoolDisabled) || errors.Is(err, ErrSlippageExceeded) {
+		t.Errorf("classified error %v reads differently or matches the wrong kind", err)
+	}
+}
+
+func TestBuildUnquotable(t *testing.T) {
+	tb, _, _ := percentBuilder(t, 0, 0)
+	report, intent, err := tb.Build("buy 2000 SOL")
+	var unquotable *unquotableError
+	if !errors.As(err, &unquotable) || intent != nil || !strings.Contains(report, "exceeds available") {
+		t.Fatalf("got intent %v, err %v, report\n%s", intent, err, report)
+	}
+	if !errors.Is(err, ErrInsufficientLiquidity) || exitCode(err) != 3 {
+		t.Errorf("%v isn't insufficient liquidity", err)
+	}
+
+	// A pool that can't be read says so, whatever was asked of it.
+	tb.usePool(&loadedPool{address: snapshotKey(7), pool: &raydium_cp_swap.PoolState{Token0Mint: snapshotKey(8), Token1Mint: snapshotKey(9), Token0Vault: snapshotKey(10), Token1Vault: snapshotKey(11)},
+		ammConfig: &raydium_cp_swap.AmmConfig{TradeFeeRate: 2500}, symbolsMap: tb.symbols()})
+	if _, _, err := tb.Build("sell 1 SOL"); err == nil || errorCode(err) == "failed" {
+		t.Errorf("unreadable reserves: got %v (%s)", err, errorCode(err))
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+/*
+NOTE(@hadydotai): These examples double as documentation and as a tripwire. They run against a frozen snapshot of a
+SOL/USDC pool rather than the chain, so their output is stable, and if any of the signatures they call change shape
+the test binary stops compiling.
+*/
+
+// snapshotKey is a stand-in account address, deterministic so the examples have stable output.
+func snapshotKey(seed byte) solana.PublicKey {
+	return solana.PublicKeyFromBytes(bytes.Repeat([]byte{seed}, solana.PublicKeyLength))
+}
+
+// snapshotPool is a SOL/USDC pool holding 1,000 SOL and 150,000 USDC with a 0.25% trade fee.
+func snapshotPool() (*raydium_cp_swap.PoolState, solana.PublicKey, []*PoolBalance) {
+	pool := &raydium_cp_swap.PoolState{
+		AmmConfig:      snapshotKey(1),
+		Token0Vault:    snapshotKey(2),
+		Token1Vault:    snapshotKey(3),
+		Token0Mint:     solana.SolMint,
+		Token1Mint:     solana.MustPublicKeyFromBase58("EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"),
+		Token0Program:  solana.TokenProgramID,
+		Token1Program:  solana.TokenProgramID,
+		ObservationKey: snapshotKey(4),
+	}
+	balances := []*PoolBalance{
+		{Balance: big.NewInt(1_000_000_000_000), Decimals: 9},
+		{Balance: big.NewInt(150_000_000_000), Decimals: 6},
+	}
+	return pool, snapshotKey(5), balances
+}
+
+func ExampleConstantProduct_QuoteOut() {
+	_, _, balances := snapshotPool()
+	cp := ConstantProduct{
+		TokenInReserve:  balances[0],
+		TokenOutReserve: balances[1],
+		TradeFeeRate:    2500,
+	}
+	// Selling 1 SOL into the pool.
+	out, err := cp.QuoteOut(big.NewInt(1_000_000_000))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(fmtForDisplay(out, 6, 6), "USDC")
+	// Output: 149.475897 USDC
+}
+
+func ExampleConstantProduct_QuoteIn() {
+	_, _, balances := snapshotPool()
+	cp := ConstantProduct{
+		TokenInReserve:  balances[1],
+		TokenOutReserve: balances[0],
+		TradeFeeRate:    2500,
+	}
+	// Buying exactly 1 SOL out of the pool.
+	in, err := cp.QuoteIn(big.NewInt(1_000_000_000))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(fmtForDisplay(in, 6, 6), "USDC")
+	// Output: 150.526468 USDC
+}
+
+func ExampleNewCPIntent() {
+	pool, poolAddr, balances := snapshotPool()
+	slippage, err := makeSlippageRatio(0.5)
+	if err != nil {
+		panic(err)
+	}
+	instruction, err := parseIntent("sell 2.5 SOL")
+	if err != nil {
+		panic(err)
+	}
+	cp := ConstantProduct{TradeFeeRate: 2500, SlippageRatio: slippage}
+	intent, err := NewCPIntent(cp, pool, poolAddr, instruction, pool.Token0Mint, balances...)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(intent.SwapKind)
+	fmt.Println("pay:     ", fmtForDisplay(intent.Amounts.KnownAmount, 9, 9), "SOL")
+	fmt.Println("quote:   ", fmtForDisplay(intent.Amounts.QuoteAmount, 6, 6), "USDC")
+	fmt.Println("min out: ", fmtForDisplay(intent.Amounts.MinAmountOut, 6, 6), "USDC")
+	// Output:
+	// swap_base_input
+	// pay:      2.500000000 SOL
+	// quote:    373.132002 USDC
+	// min out:  371.266341 USDC
+}
+
+func ExampleCPIntent_BuildSwapInstruction() {
+	pool, poolAddr, balances := snapshotPool()
+	slippage, err := makeSlippageRatio(1)
+	if err != nil {
+		panic(err)
+	}
+	instruction, err := parseIntent("buy 10 USDC")
+	if err != nil {
+		panic(err)
+	}
+	cp := ConstantProduct{TradeFeeRate: 2500, SlippageRatio: slippage}
+	intent, err := NewCPIntent(cp, pool, poolAddr, instruction, pool.Token1Mint, balances...)
+	if err != nil {
+		panic(err)
+	}
+	payer := snapshotKey(6)
+	ix, err := intent.BuildSwapInstruction(payer, snapshotKey(7), snapshotKey(8), snapshotKey(9))
+	if err != nil {
+		panic(err)
+	}
+	data, err := ix.Data()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(intent.SwapKind)
+	fmt.Println("max in:  ", fmtForDisplay(intent.Amounts.MaxAmountIn, 9, 9), "SOL")
+	fmt.Println("accounts:", len(ix.Accounts()))
+	fmt.Println("signer:  ", ix.Accounts()[0].PublicKey.Equals(payer), ix.Accounts()[0].IsSigner)
+	fmt.Printf("data:     %x\n", data)
+	// Output:
+	// swap_base_output
+	// max in:   0.067506591 SOL
+	// accounts: 13
+	// signer:   true true
+	// data:     37d96256a34ab4ad9f110604000000008096980000000000
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+/*
+NOTE(@hadydotai): Explorer links.
+
+Every send prints a link to its transaction, and receipts, batch results, DCA executions and webhooks carry it as
+"explorer". It used to be the Solana explorer, always. People have their favourite, -explorer picks it: solana
+(explorer.solana.com), solscan or solanafm. Each takes the cluster differently, mainnet is their default and needs no
+parameter, devnet does:
+
+	solana    https://explorer.solana.com/tx/<sig>?cluster=devnet
+	solscan   https://solscan.io/tx/<sig>?cluster=devnet
+	solanafm  https://solana.fm/tx/<sig>?cluster=devnet-solana
+
+Accounts (a pool or mint the devnet helpers create) link the same way under /address/. Localnet links are "custom"
+(localnet-solana on SolanaFM), the explorers look for it at localhost:8899 on your machine.
+*/
+
+// blockExplorer is a block explorer's URL scheme.
+type blockExplorer struct {
+	base     string            // up to and excluding the /tx/ or /address/ path
+	clusters map[string]string // the cluster query parameter by network, none for mainnet
+}
+
+var blockExplorers = map[string]blockExplorer{
+	"solana":   {base: "https://explorer.solana.com", clusters: map[string]string{"devnet": "devnet", "localnet": "custom"}},
+	"solscan":  {base: "https://solscan.io", clusters: map[string]string{"devnet": "devnet", "localnet": "custom"}},
+	"solanafm": {base: "https://solana.fm", clusters: map[string]string{"devnet": "devnet-solana", "localnet": "localnet-solana"}},
+}
+
+// explorerName is set by -explorer.
+var explorerName = "solana"
+
+func explorerNames() []string {
+	names := make([]string, 0, len(blockExplorers))
+	for name := range blockExplorers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func addExplorerFlag(fs *flag.FlagSet) {
+	fs.Func("explorer", fmt.Sprintf("Block explorer transaction links go to, one of [%s] (default %s)", strings.Join(explorerNames(), ", "), explorerName), func(s string) error {
+		name := strings.ToLower(strings.TrimSpace(s))
+		if _, ok := blockExplorers[name]; !ok {
+			return fmt.Errorf("unknown explorer %q, expected one of [%s]", s, strings.Join(explorerNames(), ", "))
+		}
+		explorerName = name
+		return nil
+	})
+}
+
+// explorerURL is the link to path (tx/<sig> or address/<key>) on the chosen explorer, for network.
+func explorerURL(network, path string) string {
+	ex := blockExplorers[explorerName]
+	url := ex.base + "/" + path
+	if cluster, ok := ex.clusters[network]; ok {
+		url += "?cluster=" + cluster
+	}
+	return url
+}
+
+// explorerTxURL links to the transaction on the chosen explorer, for the network we sent it to.
+func explorerTxURL(network string, sig solana.Signature) string {
+	return explorerURL(network, "tx/"+sig.String())
+}
+
+// explorerAccountURL links to an account on the chosen explorer.
+func explorerAccountURL(network string, key solana.PublicKey) string {
+	return explorerURL(network, "address/"+key.String())
+}
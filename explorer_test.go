@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"testing"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+func TestExplorerTxURL(t *testing.T) {
+	t.Cleanup(func() { explorerName = "solana" })
+	sig := solana.Signature{1}
+	for _, tc := range []struct {
+		explorer, network, want string
+	}{
+		{"solana", "mainnet", "https://explorer.solana.com/tx/" + sig.String()},
+		{"solana", "devnet", "https://explorer.solana.com/tx/" + sig.String() + "?cluster=devnet"},
+		{"solscan", "mainnet", "https://solscan.io/tx/" + sig.String()},
+		{"solscan", "devnet", "https://solscan.io/tx/" + sig.String() + "?cluster=devnet"},
+		{"solanafm", "mainnet", "https://solana.fm/tx/" + sig.String()},
+		{"solanafm", "devnet", "https://solana.fm/tx/" + sig.String() + "?cluster=devnet-solana"},
+	} {
+		explorerName = tc.explorer
+		if got := explorerTxURL(tc.network, sig); got != tc.want {
+			t.Errorf("%s on %s: %s, want %s", tc.explorer, tc.network, got, tc.want)
+		}
+	}
+	explorerName = "solscan"
+	if got, want := explorerAccountURL("devnet", wSOLMint), "https://solscan.io/address/"+wSOLMint.String()+"?cluster=devnet"; got != want {
+		t.Errorf("account link %s, want %s", got, want)
+	}
+}
+
+func TestExplorerFlag(t *testing.T) {
+	t.Cleanup(func() { explorerName = "solana" })
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	addExplorerFlag(fs)
+	if err := fs.Parse([]string{"-explorer", "SolanaFM"}); err != nil || explorerName != "solanafm" {
+		t.Errorf("explorer %q, %v", explorerName, err)
+	}
+	if err := fs.Parse([]string{"-explorer", "etherscan"}); err == nil {
+		t.Error("an unknown explorer was taken")
+	}
+}
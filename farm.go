@@ -0,0 +1,789 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+/*
+NOTE(@hadydotai): Farms.
+
+Some pools pay rewards on top of their trading fees through Raydium's farm program (farm v6, the one farms are made on
+these days, CP-Swap pools' included). LP tokens staked in a farm sit in its LP vault and earn each of the farm's reward
+tokens by the second, in proportion to the farm's total stake. `farm` is the wallet's side of that:
+
+  - `farm rewards <pool or farm>` shows what -owner (or the -hotwallet) has staked and what's pending.
+  - `farm stake <pool or farm> <amount>` moves LP tokens from the wallet into the farm, `farm unstake` takes them back,
+    a decimal amount, a percentage or `all` of what's held or staked.
+  - `farm harvest <pool or farm>` claims what's pending and leaves the stake where it is.
+  - `farm compound <pool>` turns the rewards back into stake on a schedule, see compound.go.
+
+There are no bindings for the farm program the way raydium_cp_swap has them for CP-Swap, it doesn't publish an IDL to
+generate them from, so its two accounts and its deposit and withdraw instructions are laid out by hand below, after
+Raydium's SDK. The farm holds up to five reward slots. The wallet's ledger, a PDA of the farm and the wallet, holds the
+LP staked and a reward debt per slot; what's pending is the stake times the slot's reward per share, less the debt. The
+reward per share only moves when the farm is touched, so pending is worked out the way the program does it, from the
+slot's last update to now (our clock, not the cluster's, it's an estimate to the second or so).
+
+A deposit and a withdraw both pay out everything pending, so a harvest is a withdraw of nothing. The first stake creates
+the ledger, the program does that itself. Rewards go to the wallet's associated accounts for the reward tokens, those
+are created (idempotently, the wallet pays their rent) in front of every instruction, as is the LP account an unstake
+pays back into.
+
+A pool is named the way every other command names one, its address or a pair, and its farm is the farm program's
+account staking the pool's LP mint, found with Raydium's API under -raydium-api or a getProgramAccounts scan. A pool with
+more than one farm is an error listing them, name the farm's address instead. The farm program is in the networks table
+for mainnet (and localnet, clone it in), there's no devnet deployment the client knows of.
+
+Stake, unstake and harvest are shown and have to be confirmed with y before they go out, -yes skips the question.
+*/
+
+const (
+	farmDepositInstruction  = 1
+	farmWithdrawInstruction = 2
+
+	// Farm state: an 8 byte discriminator, the state, nonce and number of reward slots in use, the u128 reward
+	// multiplier, three reward period bounds, the LP mint and vault, then the reward slots. The creator and padding
+	// after them aren't read.
+	farmRewardCountOffset = 24
+	farmMultiplierOffset  = 32
+	farmLPMintOffset      = 72
+	farmLPVaultOffset     = 104
+	farmRewardsOffset     = 136
+	farmRewardSlots       = 5
+	// A reward slot: state, open, end and last update times, total, emitted and claimed, the reward per second, the u128
+	// reward per share, the vault, mint and sender, the reward type and 15 u64s of padding.
+	farmRewardLen   = 304
+	farmStateMinLen = farmRewardsOffset + farmRewardSlots*farmRewardLen
+
+	// Farm ledger: an 8 byte discriminator, the state, the farm and owner, the LP deposited and a u128 reward debt per
+	// slot. The vote locked balance and padding after them aren't read.
+	farmLedgerFarmOffset      = 16
+	farmLedgerOwnerOffset     = 48
+	farmLedgerDepositedOffset = 80
+	farmLedgerDebtsOffset     = 88
+	farmLedgerMinLen          = farmLedgerDebtsOffset + farmRewardSlots*16
+
+	farmLedgerSeed = "farmer_info_associated_seed"
+)
+
+// farmReward is one of a farm's reward slots.
+type farmReward struct {
+	openTime   uint64
+	endTime    uint64
+	lastUpdate uint64
+	perSecond  uint64
+	perShare   *big.Int // accumulated reward per staked LP unit, times the farm's multiplier
+	vault      solana.PublicKey
+	mint       solana.PublicKey
+}
+
+// farmState is a farm as the program keeps it, the slots in use only.
+type farmState struct {
+	address    solana.PublicKey
+	multiplier *big.Int
+	lpMint     solana.PublicKey
+	lpVault    solana.PublicKey
+	rewards    []farmReward
+}
+
+// farmLedger is a wallet's stake in a farm.
+type farmLedger struct {
+	deposited uint64
+	debts     [farmRewardSlots]*big.Int
+}
+
+// readU128 reads a little endian u128.
+func readU128(b []byte) *big.Int {
+	be := make([]byte, 16)
+	for i := range be {
+		be[i] = b[15-i]
+	}
+	return new(big.Int).SetBytes(be)
+}
+
+func decodeFarmState(address solana.PublicKey, data []byte) (*farmState, error) {
+	if len(data) < farmStateMinLen {
+		return nil, fmt.Errorf("%s isn't a farm, its account is %d bytes", Addr(address.String()), len(data))
+	}
+	slots := binary.LittleEndian.Uint64(data[farmRewardCountOffset:])
+	if slots > farmRewardSlots {
+		return nil, fmt.Errorf("farm %s has %d reward slots in use, there are only %d", Addr(address.String()), slots, farmRewardSlots)
+	}
+	f := &farmState{
+		address:    address,
+		multiplier: readU128(data[farmMultiplierOffset:]),
+		lpMint:     solana.PublicKeyFromBytes(data[farmLPMintOffset : farmLPMintOffset+32]),
+		lpVault:    solana.PublicKeyFromBytes(data[farmLPVaultOffset : farmLPVaultOffset+32]),
+	}
+	if f.multiplier.Sign() == 0 {
+		return nil, fmt.Errorf("farm %s has no reward multiplier, it isn't a farm v6 account", Addr(address.String()))
+	}
+	for i := range int(slots) {
+		slot := data[farmRewardsOffset+i*farmRewardLen:]
+		f.rewards = append(f.rewards, farmReward{
+			openTime:   binary.LittleEndian.Uint64(slot[8:]),
+			endTime:    binary.LittleEndian.Uint64(slot[16:]),
+			lastUpdate: binary.LittleEndian.Uint64(slot[24:]),
+			perSecond:  binary.LittleEndian.Uint64(slot[56:]),
+			perShare:   readU128(slot[64:]),
+			vault:      solana.PublicKeyFromBytes(slot[80:112]),
+			mint:       solana.PublicKeyFromBytes(slot[112:144]),
+		})
+	}
+	return f, nil
+}
+
+// decodeFarmLedger reads owner's ledger in farm, refusing one that's someone else's.
+func decodeFarmLedger(data []byte, farm, owner solana.PublicKey) (*farmLedger, error) {
+	if len(data) < farmLedgerMinLen {
+		return nil, fmt.Errorf("farm ledger is %d bytes, expected at least %d", len(data), farmLedgerMinLen)
+	}
+	if got := solana.PublicKeyFromBytes(data[farmLedgerFarmOffset : farmLedgerFarmOffset+32]); !got.Equals(farm) {
+		return nil, fmt.Errorf("farm ledger is for farm %s, not %s", Addr(got.String()), Addr(farm.String()))
+	}
+	if got := solana.PublicKeyFromBytes(data[farmLedgerOwnerOffset : farmLedgerOwnerOffset+32]); !got.Equals(owner) {
+		return nil, fmt.Errorf("farm ledger belongs to %s, not %s", Addr(got.String()), Addr(owner.String()))
+	}
+	l := &farmLedger{deposited: binary.LittleEndian.Uint64(data[farmLedgerDepositedOffset:])}
+	for i := range l.debts {
+		l.debts[i] = readU128(data[farmLedgerDebtsOffset+i*16:])
+	}
+	return l, nil
+}
+
+// perShareAt is the slot's reward per share brought up to now, with staked LP in the farm, the way the program brings
+// it up before paying out.
+func (r farmReward) perShareAt(multiplier, staked *big.Int, now uint64) *big.Int {
+	acc := new(big.Int).Set(r.perShare)
+	from, to := max(r.lastUpdate, r.openTime), min(now, r.endTime)
+	if staked.Sign() == 0 || to <= from {
+		return acc
+	}
+	emitted := new(big.Int).Mul(new(big.Int).SetUint64(to-from), new(big.Int).SetUint64(r.perSecond))
+	emitted.Mul(emitted, multiplier).Quo(emitted, staked)
+	return acc.Add(acc, emitted)
+}
+
+// pending is what the ledger has earned in each of the farm's reward slots at now, with staked LP in the farm.
+func (f *farmState) pending(l *farmLedger, staked *big.Int, now uint64) []*big.Int {
+	out := make([]*big.Int, len(f.rewards))
+	for i, r := range f.rewards {
+		out[i] = new(big.Int)
+		if l == nil {
+			continue
+		}
+		earned := new(big.Int).Mul(new(big.Int).SetUint64(l.deposited), r.perShareAt(f.multiplier, staked, now))
+		earned.Quo(earned, f.multiplier).Sub(earned, l.debts[i])
+		if earned.Sign() > 0 {
+			out[i] = earned
+		}
+	}
+	return out
+}
+
+// farmAuthority is the PDA the farm's vaults belong to.
+func farmAuthority(program, farm solana.PublicKey) (solana.PublicKey, error) {
+	authority, _, err := solana.FindProgramAddress([][]byte{farm[:]}, program)
+	return authority, err
+}
+
+// farmLedgerAddress is owner's ledger in farm.
+func farmLedgerAddress(program, farm, owner solana.PublicKey) (solana.PublicKey, error) {
+	ledger, _, err := solana.FindProgramAddress([][]byte{farm[:], owner[:], []byte(farmLedgerSeed)}, program)
+	return ledger, err
+}
+
+// farmInstruction is a deposit or withdraw (kind) of amount LP between owner's userLP account and farm, paying out
+// what's pending into rewardAccounts, one per reward slot. The deposit has the system program in second place, it
+// creates the ledger when there isn't one.
+func farmInstruction(program solana.PublicKey, f *farmState, kind byte, owner, userLP solana.PublicKey, rewardAccounts []solana.PublicKey, amount uint64) (solana.Instruction, error) {
+	if len(rewardAccounts) != len(f.rewards) {
+		return nil, fmt.Errorf("farm %s has %d reward slots, got %d accounts to pay them into", Addr(f.address.String()), len(f.rewards), len(rewardAccounts))
+	}
+	authority, err := farmAuthority(program, f.address)
+	if err != nil {
+		return nil, err
+	}
+	ledger, err := farmLedgerAddress(program, f.address, owner)
+	if err != nil {
+		return nil, err
+	}
+	metas := solana.AccountMetaSlice{solana.Meta(solana.TokenProgramID)}
+	if kind == farmDepositInstruction {
+		metas = append(metas, solana.Meta(solana.SystemProgramID))
+	}
+	metas = append(metas,
+		solana.Meta(f.address).WRITE(),
+		solana.Meta(authority),
+		solana.Meta(f.lpVault).WRITE(),
+		solana.Meta(ledger).WRITE(),
+		solana.Meta(owner).SIGNER().WRITE(),
+		solana.Meta(userLP).WRITE(),
+	)
+	for i, r := range f.rewards {
+		metas = append(metas, solana.Meta(r.vault).WRITE(), solana.Meta(rewardAccounts[i]).WRITE())
+	}
+	data := make([]byte, 9)
+	data[0] = kind
+	binary.LittleEndian.PutUint64(data[1:], amount)
+	return solana.NewInstruction(program, metas, data), nil
+}
+
+// farmProgram is the farm program on network.
+func farmProgram(network string) (solana.PublicKey, error) {
+	program, ok := networks[network][RaydiumFarmProgramID].(solana.PublicKey)
+	if !ok {
+		return solana.PublicKey{}, fmt.Errorf("there's no Raydium farm program on %s that the client knows of", network)
+	}
+	return program, nil
+}
+
+// findFarms are the farms on program staking lpMint, from the API when it's on, the chain otherwise.
+func findFarms(ctx context.Context, client *rpc.Client, program, lpMint solana.PublicKey) ([]solana.PublicKey, error) {
+	if raydiumAPI != nil {
+		farms, err := raydiumAPI.farmsByLP(ctx, program, lpMint)
+		if err == nil && len(farms) > 0 {
+			return farms, nil
+		}
+		if err != nil {
+			log.Printf("warning: %v, scanning the chain for farms instead", err)
+		}
+	}
+	accounts, err := client.GetProgramAccountsWithOpts(ctx, program, &rpc.GetProgramAccountsOpts{
+		Encoding:  solana.EncodingBase64,
+		DataSlice: &rpc.DataSlice{Offset: ptrTo(uint64(0)), Length: ptrTo(uint64(0))},
+		Filters:   []rpc.RPCFilter{{Memcmp: &rpc.RPCFilterMemcmp{Offset: farmLPMintOffset, Bytes: lpMint.Bytes()}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("rpc call getProgramAccounts for farms failed: %w", err)
+	}
+	farms := make([]solana.PublicKey, 0, len(accounts))
+	for _, acc := range accounts {
+		farms = append(farms, acc.Pubkey)
+	}
+	return farms, nil
+}
+
+// loadedFarm is a farm with what it takes to show it and build its instructions.
+type loadedFarm struct {
+	program solana.PublicKey
+	state   *farmState
+	lpMint  *mintAccount
+	rewards []*mintAccount // one per reward slot
+	symbols []string       // the reward tokens'
+}
+
+// loadFarm reads the farm target names, a farm's address, or a pool's address or pair whose LP the farm stakes.
+func loadFarm(ctx context.Context, client *rpc.Client, network, target string) (*loadedFarm, error) {
+	program, err := farmProgram(network)
+	if err != nil {
+		return nil, err
+	}
+	var acc *rpc.Account
+	address, err := solana.PublicKeyFromBase58(target)
+	if err == nil {
+		if acc, err = accountOrNil(ctx, client, address); err != nil {
+			return nil, err
+		}
+	}
+	if acc == nil || !acc.Owner.Equals(program) {
+		poolAddr, err := resolvePoolTarget(ctx, client, target, SymbolMapping{})
+		if err != nil {
+			return nil, err
+		}
+		lp, err := loadPool(ctx, client, poolAddr)
+		if err != nil {
+			return nil, err
+		}
+		farms, err := findFarms(ctx, client, program, lp.pool.LpMint)
+		if err != nil {
+			return nil, err
+		}
+		switch len(farms) {
+		case 0:
+			return nil, fmt.Errorf("no farm stakes pool %s's LP token", Addr(poolAddr.String()))
+		case 1:
+			address = farms[0]
+		default:
+			names := make([]string, len(farms))
+			for i, farm := range farms {
+				names[i] = farm.String()
+			}
+			return nil, fmt.Errorf("pool %s's LP token has %d farms (%s), name the one you want by its address",
+				Addr(poolAddr.String()), len(farms), strings.Join(names, ", "))
+		}
+		if acc, err = accountOrNil(ctx, client, address); err != nil {
+			return nil, err
+		}
+		if acc == nil {
+			return nil, fmt.Errorf("farm %s doesn't exist", Addr(address.String()))
+		}
+	}
+	state, err := decodeFarmState(address, acc.Data.GetBinary())
+	if err != nil {
+		return nil, err
+	}
+	mints := []solana.PublicKey{state.lpMint}
+	for _, r := range state.rewards {
+		mints = append(mints, r.mint)
+	}
+	accounts, err := fetchMintAccounts(ctx, client, mints...)
+	if err != nil {
+		return nil, err
+	}
+	lf := &loadedFarm{program: program, state: state, lpMint: accounts[0], rewards: accounts[1:]}
+	for _, r := range state.rewards {
+		lf.symbols = append(lf.symbols, cmp.Or(knownSymbol(r.mint), Addr(r.mint.String()).String()))
+	}
+	return lf, nil
+}
+
+// farmPosition is owner's stake in a farm as read, ledger is nil when owner has never staked.
+type farmPosition struct {
+	owner   solana.PublicKey
+	ledger  *farmLedger
+	staked  *big.Int // the farm's total stake, its LP vault's balance
+	now     uint64
+	pending []*big.Int
+}
+
+// deposited is the LP owner has staked.
+func (p *farmPosition) deposited() *big.Int {
+	if p.ledger == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).SetUint64(p.ledger.deposited)
+}
+
+// readFarmPosition reads owner's ledger and the farm's total stake in one call and works out what's pending.
+func readFarmPosition(ctx context.Context, client *rpc.Client, lf *loadedFarm, owner solana.PublicKey) (*farmPosition, error) {
+	ledger, err := farmLedgerAddress(lf.program, lf.state.address, owner)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.GetMultipleAccountsWithOpts(ctx, []solana.PublicKey{ledger, lf.state.lpVault}, &rpc.GetMultipleAccountsOpts{
+		Encoding:   solana.EncodingBase64,
+		Commitment: rpc.CommitmentConfirmed,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("rpc call getMultipleAccounts for the farm ledger failed: %w", err)
+	}
+	if res == nil || len(res.Value) != 2 || res.Value[1] == nil {
+		return nil, fmt.Errorf("couldn't read farm %s's LP vault", Addr(lf.state.address.String()))
+	}
+	pos := &farmPosition{owner: owner, now: uint64(time.Now().Unix())}
+	if res.Value[0] != nil {
+		if pos.ledger, err = decodeFarmLedger(res.Value[0].Data.GetBinary(), lf.state.address, owner); err != nil {
+			return nil, err
+		}
+	}
+	vault := res.Value[1].Data.GetBinary()
+	if len(vault) < tokenAccountAmountOffset+8 {
+		return nil, fmt.Errorf("farm %s's LP vault isn't a token account", Addr(lf.state.address.String()))
+	}
+	pos.staked = new(big.Int).SetUint64(binary.LittleEndian.Uint64(vault[tokenAccountAmountOffset:]))
+	pos.pending = lf.state.pending(pos.ledger, pos.staked, pos.now)
+	return pos, nil
+}
+
+// perDay is what owner's stake earns of reward slot i a day at the current rate, zero once the slot has ended.
+func (lf *loadedFarm) perDay(p *farmPosition, i int) *big.Int {
+	r := lf.state.rewards[i]
+	if p.staked.Sign() == 0 || p.now >= r.endTime || p.now < r.openTime {
+		return new(big.Int)
+	}
+	day := new(big.Int).Mul(new(big.Int).SetUint64(r.perSecond), big.NewInt(86400))
+	day.Mul(day, p.deposited())
+	return day.Quo(day, p.staked)
+}
+
+// rewardState is how reward slot i stands at now.
+func (lf *loadedFarm) rewardState(i int, now uint64) string {
+	r := lf.state.rewards[i]
+	at := func(ts uint64) string { return time.Unix(int64(ts), 0).UTC().Format(time.DateTime) }
+	switch {
+	case now < r.openTime:
+		return "starts " + at(r.openTime)
+	case now >= r.endTime:
+		return "ended " + at(r.endTime)
+	default:
+		return "until " + at(r.endTime)
+	}
+}
+
+func (lf *loadedFarm) render(p *farmPosition) string {
+	builder := &strings.Builder{}
+	t := table.NewWriter()
+	t.SetOutputMirror(builder)
+	t.SetTitle(fmt.Sprintf("Farm %s", Addr(lf.state.address.String())))
+	t.AppendHeader(table.Row{"Field", "Value"})
+	t.AppendRow(table.Row{"Owner", p.owner})
+	t.AppendRow(table.Row{"LP mint", lf.state.lpMint})
+	share := "-"
+	if p.staked.Sign() > 0 {
+		share = pctString(new(big.Rat).SetFrac(p.deposited(), p.staked))
+	}
+	t.AppendRow(table.Row{"Staked", fmt.Sprintf("%s LP of %s (%s)", fmtAmount(p.deposited(), lf.lpMint.Decimals), fmtAmount(p.staked, lf.lpMint.Decimals), share)})
+	for i := range lf.state.rewards {
+		dec, sym := lf.rewards[i].Decimals, lf.symbols[i]
+		line := fmt.Sprintf("%s pending, %s a day, %s", formatTokenAmount(p.pending[i], dec, sym), formatTokenAmount(lf.perDay(p, i), dec, sym), lf.rewardState(i, p.now))
+		t.AppendRow(table.Row{"Reward " + sym, line})
+	}
+	if len(lf.state.rewards) == 0 {
+		t.AppendRow(table.Row{"Rewards", "none, the farm has no reward slots in use"})
+	}
+	t.Render()
+	return builder.String()
+}
+
+// farmRewardJSON is a reward slot in structured output.
+type farmRewardJSON struct {
+	Mint    string     `json:"mint"`
+	Symbol  string     `json:"symbol"`
+	Pending amountJSON `json:"pending"`
+	PerDay  amountJSON `json:"perDay"`
+	Ends    time.Time  `json:"ends"`
+}
+
+// farmPositionJSON is `farm rewards -json`.
+type farmPositionJSON struct {
+	Farm    string           `json:"farm"`
+	Owner   string           `json:"owner"`
+	LPMint  string           `json:"lpMint"`
+	Staked  amountJSON       `json:"staked"`
+	Total   amountJSON       `json:"totalStaked"`
+	Rewards []farmRewardJSON `json:"rewards"`
+}
+
+func (lf *loadedFarm) json(p *farmPosition) farmPositionJSON {
+	out := farmPositionJSON{
+		Farm:    lf.state.address.String(),
+		Owner:   p.owner.String(),
+		LPMint:  lf.state.lpMint.String(),
+		Staked:  newAmountJSON(p.deposited(), lf.lpMint.Decimals),
+		Total:   newAmountJSON(p.staked, lf.lpMint.Decimals),
+		Rewards: []farmRewardJSON{},
+	}
+	for i, r := range lf.state.rewards {
+		out.Rewards = append(out.Rewards, farmRewardJSON{
+			Mint:    r.mint.String(),
+			Symbol:  lf.symbols[i],
+			Pending: newAmountJSON(p.pending[i], lf.rewards[i].Decimals),
+			PerDay:  newAmountJSON(lf.perDay(p, i), lf.rewards[i].Decimals),
+			Ends:    time.Unix(int64(r.endTime), 0).UTC(),
+		})
+	}
+	return out
+}
+
+// farmAmount is amount, decimal or relative to available ("50%", "all"), in raw LP units, of is what available is.
+func farmAmount(amount string, available *big.Int, decimals uint8, of string) (*big.Int, error) {
+	frac, relative, err := parsePercentAmount(amount)
+	if err != nil {
+		return nil, err
+	}
+	var raw *big.Int
+	if relative {
+		share := new(big.Rat).Mul(new(big.Rat).SetInt(available), frac)
+		raw = new(big.Int).Quo(share.Num(), share.Denom())
+	} else if raw, err = fmtForMath(amount, decimals); err != nil {
+		return nil, err
+	}
+	if raw.Sign() == 0 {
+		return nil, fmt.Errorf("%s of the %s LP %s is nothing", amount, fmtAmount(available, decimals), of)
+	}
+	if raw.Cmp(available) > 0 {
+		return nil, fmt.Errorf("%s LP is more than the %s %s", fmtAmount(raw, decimals), fmtAmount(available, decimals), of)
+	}
+	if !raw.IsUint64() {
+		return nil, fmt.Errorf("%s is more than a single instruction can move", amount)
+	}
+	return raw, nil
+}
+
+// farmAction is a stake, unstake or harvest ready to be confirmed and sent.
+type farmAction struct {
+	name         string
+	amount       *big.Int // LP staked or unstaked, zero for a harvest
+	instructions []solana.Instruction
+}
+
+// planFarmAction builds name for owner, moving amount LP.
+func planFarmAction(lf *loadedFarm, owner solana.PublicKey, name string, amount *big.Int) (*farmAction, error) {
+	userLP, err := associatedTokenAddress(owner, lf.state.lpMint, lf.lpMint.Program)
+	if err != nil {
+		return nil, err
+	}
+	action := &farmAction{name: name, amount: amount}
+	kind := byte(farmWithdrawInstruction)
+	if name == "stake" {
+		kind = farmDepositInstruction
+	} else {
+		action.instructions = append(action.instructions, createATAInstruction(owner, userLP, owner, lf.state.lpMint, lf.lpMint.Program))
+	}
+	rewardAccounts := make([]solana.PublicKey, len(lf.rewards))
+	for i, mint := range lf.rewards {
+		if rewardAccounts[i], err = associatedTokenAddress(owner, mint.Address, mint.Program); err != nil {
+			return nil, err
+		}
+		action.instructions = append(action.instructions, createATAInstruction(owner, rewardAccounts[i], owner, mint.Address, mint.Program))
+	}
+	ix, err := farmInstruction(lf.program, lf.state, kind, owner, userLP, rewardAccounts, amount.Uint64())
+	if err != nil {
+		return nil, err
+	}
+	action.instructions = append(action.instructions, ix)
+	return action, nil
+}
+
+func (lf *loadedFarm) renderAction(a *farmAction, p *farmPosition) string {
+	builder := &strings.Builder{}
+	t := table.NewWriter()
+	t.SetOutputMirror(builder)
+	t.SetTitle(fmt.Sprintf("Farm %s", a.name))
+	t.AppendHeader(table.Row{"Field", "Value"})
+	t.AppendRow(table.Row{"Farm", lf.state.address})
+	t.AppendRow(table.Row{"Wallet", p.owner})
+	after := p.deposited()
+	switch a.name {
+	case "stake":
+		t.AppendRow(table.Row{"Stake", fmtAmount(a.amount, lf.lpMint.Decimals) + " LP"})
+		after.Add(after, a.amount)
+	case "unstake":
+		t.AppendRow(table.Row{"Unstake", fmtAmount(a.amount, lf.lpMint.Decimals) + " LP"})
+		after.Sub(after, a.amount)
+	}
+	t.AppendRow(table.Row{"Staked after", fmtAmount(after, lf.lpMint.Decimals) + " LP"})
+	for i := range lf.state.rewards {
+		t.AppendRow(table.Row{"Paid out", formatTokenAmount(p.pending[i], lf.rewards[i].Decimals, lf.symbols[i])})
+	}
+	t.Render()
+	return builder.String()
+}
+
+// sendFarmInstructions signs and sends ixs behind the compute budget, prints the explorer link and waits for it to land.
+func sendFarmInstructions(ctx context.Context, client *rpc.Client, payer solana.PrivateKey, network string, ixs []solana.Instruction) (solana.Signature, error) {
+	ctx, cancel := deadlines.forSend(ctx)
+	defer cancel()
+	sig, err := signAndSend(ctx, client, payer, append(computeBudget.instructions(), ixs...))
+	if err != nil {
+		return solana.Signature{}, err
+	}
+	fmt.Println(explorerTxURL(network, sig))
+	status, result, err := waitForTransactionResult(ctx, client, sig)
+	if err != nil {
+		return sig, fmt.Errorf("waiting for %s failed: %w", sig, err)
+	}
+	if status == "failed" {
+		failed := &txFailedError{sig: sig}
+		if result != nil && result.Meta != nil {
+			failed.txErr, failed.logs = result.Meta.Err, result.Meta.LogMessages
+		}
+		return sig, failed
+	}
+	return sig, nil
+}
+
+func runFarmCommand(args []string) error {
+	return dispatchSubcommand("farm", map[string]func([]string) error{
+		"rewards":  runFarmRewardsCommand,
+		"stake":    func(args []string) error { return runFarmActionCommand("stake", args) },
+		"unstake":  func(args []string) error { return runFarmActionCommand("unstake", args) },
+		"harvest":  func(args []string) error { return runFarmActionCommand("harvest", args) },
+		"compound": runFarmCompoundCommand,
+	}, args)
+}
+
+// splitFarmTarget takes the pool or farm off the front of args, it reads naturally first, `farm rewards <pool>
+// -owner ...`, and flag stops at the first argument.
+func splitFarmTarget(args []string) (string, []string) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		return args[0], args[1:]
+	}
+	return "", args
+}
+
+func runFarmRewardsCommand(args []string) error {
+	fs := flag.NewFlagSet("farm rewards", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: farm rewards [flags] <pool address, pair or farm address> -owner <wallet>\n")
+		fs.PrintDefaults()
+	}
+	nf := addNetworkFlags(fs)
+	var (
+		owner         = fs.String("owner", "", "Wallet whose stake is shown")
+		hotwalletPath = fs.String("hotwallet", "", "Show the stake of this hotwallet instead of -owner")
+		asJSON        = fs.Bool("json", false, "Print the stake and rewards as JSON instead of a table")
+	)
+	addAmountFlags(fs)
+	target, args := splitFarmTarget(args)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, nf.specs())
+	if target == "" && fs.NArg() > 0 {
+		target = fs.Arg(0)
+	}
+	if target == "" {
+		fs.Usage()
+		return errors.New("missing pool or farm")
+	}
+	var ownerKey solana.PublicKey
+	switch {
+	case *owner != "" && *hotwalletPath != "":
+		return errors.New("-owner and -hotwallet both name the wallet, pass one")
+	case *owner != "":
+		var err error
+		if ownerKey, err = solana.PublicKeyFromBase58(*owner); err != nil {
+			return fmt.Errorf("-owner isn't a wallet address: %w", err)
+		}
+	case *hotwalletPath != "":
+		payer, err := solana.PrivateKeyFromSolanaKeygenFile(*hotwalletPath)
+		if err != nil {
+			return fmt.Errorf("failed to load private key from hot wallet: %w", err)
+		}
+		ownerKey = payer.PublicKey()
+	default:
+		return errors.New("farm rewards needs the wallet, -owner or -hotwallet")
+	}
+	client := nf.connect()
+	ctx, stop := interruptContext()
+	defer stop()
+
+	quoteCtx, cancel := deadlines.forQuote(ctx)
+	defer cancel()
+	lf, err := loadFarm(quoteCtx, client, *nf.network, target)
+	if err != nil {
+		return err
+	}
+	pos, err := readFarmPosition(quoteCtx, client, lf, ownerKey)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(lf.json(pos))
+	}
+	fmt.Print(lf.render(pos))
+	return nil
+}
+
+// runFarmActionCommand is `farm stake`, `farm unstake` and `farm harvest`, which differ in the amount and which way it
+// goes.
+func runFarmActionCommand(name string, args []string) error {
+	fs := flag.NewFlagSet("farm "+name, flag.ExitOnError)
+	usage := "Usage: farm %s [flags] <pool address, pair or farm address> <amount> -hotwallet <path>\n"
+	if name == "harvest" {
+		usage = "Usage: farm %s [flags] <pool address, pair or farm address> -hotwallet <path>\n"
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), usage, name)
+		fs.PrintDefaults()
+	}
+	nf := addNetworkFlags(fs)
+	addComputeBudgetFlags(fs)
+	var (
+		hotwalletPath = fs.String("hotwallet", "", "Path to the hotwallet whose LP tokens are staked")
+		yes           = fs.Bool("yes", false, "Send without asking to confirm first")
+	)
+	addAmountFlags(fs)
+	target, args := splitFarmTarget(args)
+	var amountArg string
+	if name != "harvest" && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		amountArg, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, append(nf.specs(),
+		FlagSpec{Name: "hotwallet", Value: hotwalletPath, Rules: []FlagRule{NotEmpty()}},
+	))
+	if target == "" || (name != "harvest" && amountArg == "") {
+		fs.Usage()
+		if name == "harvest" {
+			return errors.New("missing pool or farm")
+		}
+		return fmt.Errorf("%s takes the pool or farm and the amount, e.g. `farm %s <pool> all`", name, name)
+	}
+	payer, err := solana.PrivateKeyFromSolanaKeygenFile(*hotwalletPath)
+	if err != nil {
+		return fmt.Errorf("failed to load private key from hot wallet: %w", err)
+	}
+	client := nf.connect()
+	ctx, stop := interruptContext()
+	defer stop()
+
+	quoteCtx, cancel := deadlines.forQuote(ctx)
+	lf, err := loadFarm(quoteCtx, client, *nf.network, target)
+	if err != nil {
+		cancel()
+		return err
+	}
+	pos, err := readFarmPosition(quoteCtx, client, lf, payer.PublicKey())
+	if err != nil {
+		cancel()
+		return err
+	}
+	amount := new(big.Int)
+	switch name {
+	case "stake":
+		var held *big.Int
+		if held, err = walletTokenBalance(quoteCtx, client, payer.PublicKey(), lf.state.lpMint, lf.lpMint.Program); err == nil {
+			amount, err = farmAmount(amountArg, held, lf.lpMint.Decimals, "the wallet holds")
+		}
+	case "unstake":
+		amount, err = farmAmount(amountArg, pos.deposited(), lf.lpMint.Decimals, "staked")
+	case "harvest":
+		if pos.ledger == nil || pos.ledger.deposited == 0 {
+			err = fmt.Errorf("nothing is staked in farm %s, there's nothing to harvest", Addr(lf.state.address.String()))
+		}
+	}
+	cancel()
+	if err != nil {
+		return err
+	}
+	action, err := planFarmAction(lf, payer.PublicKey(), name, amount)
+	if err != nil {
+		return err
+	}
+	fmt.Print(lf.renderAction(action, pos))
+	if !*yes {
+		ok, err := promptYesNo("Send it?")
+		if err != nil {
+			return fmt.Errorf("reading the confirmation failed, pass -yes to send without asking: %w", err)
+		}
+		if !ok {
+			fmt.Println("Nothing was sent.")
+			return nil
+		}
+	}
+
+	if _, err := sendFarmInstructions(ctx, client, payer, *nf.network, action.instructions); err != nil {
+		return err
+	}
+	switch name {
+	case "stake":
+		fmt.Printf("staked %s LP in farm %s\n", fmtAmount(amount, lf.lpMint.Decimals), lf.state.address)
+	case "unstake":
+		fmt.Printf("unstaked %s LP from farm %s\n", fmtAmount(amount, lf.lpMint.Decimals), lf.state.address)
+	default:
+		fmt.Printf("harvested farm %s\n", lf.state.address)
+	}
+	return nil
+}
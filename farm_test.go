@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/binary"
+	"math/big"
+	"strings"
+	"testing"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+// putU128 writes v little endian into b.
+func putU128(b []byte, v *big.Int) {
+	be := v.FillBytes(make([]byte, 16))
+	for i := range 16 {
+		b[i] = be[15-i]
+	}
+}
+
+// testFarm is a farm paying RAY at 1 a second (6 decimals) from t=1000 to t=2000, last updated at t=1000, with a
+// multiplier of 1e9.
+func testFarm(t *testing.T) (solana.PublicKey, []byte) {
+	t.Helper()
+	farm := snapshotKey(71)
+	data := make([]byte, farmStateMinLen+64)
+	binary.LittleEndian.PutUint64(data[farmRewardCountOffset:], 1)
+	putU128(data[farmMultiplierOffset:], big.NewInt(1_000_000_000))
+	copy(data[farmLPMintOffset:], snapshotKey(72).Bytes())
+	copy(data[farmLPVaultOffset:], snapshotKey(73).Bytes())
+	slot := data[farmRewardsOffset:]
+	binary.LittleEndian.PutUint64(slot[0:], 1)
+	binary.LittleEndian.PutUint64(slot[8:], 1000)
+	binary.LittleEndian.PutUint64(slot[16:], 2000)
+	binary.LittleEndian.PutUint64(slot[24:], 1000)
+	binary.LittleEndian.PutUint64(slot[56:], 1_000_000)
+	copy(slot[80:], snapshotKey(74).Bytes())
+	copy(slot[112:], snapshotKey(75).Bytes())
+	return farm, data
+}
+
+func testFarmLedger(farm, owner solana.PublicKey, deposited uint64, debt int64) []byte {
+	data := make([]byte, farmLedgerMinLen+128)
+	copy(data[farmLedgerFarmOffset:], farm.Bytes())
+	copy(data[farmLedgerOwnerOffset:], owner.Bytes())
+	binary.LittleEndian.PutUint64(data[farmLedgerDepositedOffset:], deposited)
+	putU128(data[farmLedgerDebtsOffset:], big.NewInt(debt))
+	return data
+}
+
+func TestFarmPending(t *testing.T) {
+	address, data := testFarm(t)
+	f, err := decodeFarmState(address, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.rewards) != 1 || !f.lpMint.Equals(snapshotKey(72)) || !f.rewards[0].mint.Equals(snapshotKey(75)) {
+		t.Fatalf("decoded %+v", f)
+	}
+	owner := snapshotKey(76)
+	l, err := decodeFarmLedger(testFarmLedger(address, owner, 250, 0), address, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	staked := big.NewInt(1000)
+	// A quarter of the stake, 100 seconds in: a quarter of 100 RAY.
+	if got := f.pending(l, staked, 1100); got[0].Int64() != 25_000_000 {
+		t.Errorf("pending %s", got[0])
+	}
+	// Nothing accrues past the end.
+	if got := f.pending(l, staked, 5000); got[0].Int64() != 250_000_000 {
+		t.Errorf("pending after the end %s", got[0])
+	}
+	// The debt is what was already paid out, a harvest at t=1100 leaves nothing pending then.
+	debt := new(big.Int).Mul(f.rewards[0].perShareAt(f.multiplier, staked, 1100), big.NewInt(250))
+	paid, _ := decodeFarmLedger(testFarmLedger(address, owner, 250, debt.Quo(debt, f.multiplier).Int64()), address, owner)
+	if got := f.pending(paid, staked, 1100); got[0].Sign() != 0 {
+		t.Errorf("pending after a harvest %s", got[0])
+	}
+	if got := f.pending(nil, staked, 1100); got[0].Sign() != 0 {
+		t.Errorf("pending without a ledger %s", got[0])
+	}
+
+	if _, err := decodeFarmLedger(testFarmLedger(address, snapshotKey(77), 250, 0), address, owner); err == nil {
+		t.Error("someone else's ledger decoded")
+	}
+	if _, err := decodeFarmState(address, data[:100]); err == nil {
+		t.Error("a short account decoded as a farm")
+	}
+}
+
+func TestFarmInstruction(t *testing.T) {
+	address, data := testFarm(t)
+	f, _ := decodeFarmState(address, data)
+	program := solana.MustPublicKeyFromBase58("FarmqiPv5eAj3j1GMdMCMUGXqPUvmquZtMy86QH6rzhG")
+	owner, userLP, userRAY := snapshotKey(76), snapshotKey(78), snapshotKey(79)
+
+	deposit, err := farmInstruction(program, f, farmDepositInstruction, owner, userLP, []solana.PublicKey{userRAY}, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accounts := deposit.Accounts()
+	if len(accounts) != 10 || !accounts[1].PublicKey.Equals(solana.SystemProgramID) || !accounts[6].IsSigner ||
+		!accounts[8].PublicKey.Equals(f.rewards[0].vault) || !accounts[9].PublicKey.Equals(userRAY) {
+		t.Errorf("deposit accounts %v", accounts)
+	}
+	ledger, _ := farmLedgerAddress(program, address, owner)
+	if !accounts[5].PublicKey.Equals(ledger) {
+		t.Errorf("ledger %s, want %s", accounts[5].PublicKey, ledger)
+	}
+	if got, _ := deposit.Data(); got[0] != farmDepositInstruction || binary.LittleEndian.Uint64(got[1:]) != 500 {
+		t.Errorf("deposit data %v", got)
+	}
+
+	withdraw, _ := farmInstruction(program, f, farmWithdrawInstruction, owner, userLP, []solana.PublicKey{userRAY}, 0)
+	if accounts := withdraw.Accounts(); len(accounts) != 9 || !accounts[1].PublicKey.Equals(address) {
+		t.Errorf("withdraw accounts %v", accounts)
+	}
+	if _, err := farmInstruction(program, f, farmWithdrawInstruction, owner, userLP, nil, 0); err == nil {
+		t.Error("no account for the reward")
+	}
+}
+
+func TestFarmAmount(t *testing.T) {
+	staked := big.NewInt(4_000_000_000)
+	for amount, want := range map[string]int64{"all": 4_000_000_000, "25%": 1_000_000_000, "1.5": 1_500_000_000} {
+		got, err := farmAmount(amount, staked, 9, "staked")
+		if err != nil || got.Int64() != want {
+			t.Errorf("%s is %v, %v", amount, got, err)
+		}
+	}
+	if _, err := farmAmount("5", staked, 9, "staked"); err == nil || !strings.Contains(err.Error(), "4.000000000 staked") {
+		t.Errorf("more than staked: %v", err)
+	}
+	if _, err := farmAmount("all", new(big.Int), 9, "staked"); err == nil {
+		t.Error("all of nothing")
+	}
+}
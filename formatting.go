@@ -20,6 +20,51 @@ func fmtForDisplay(raw *big.Int, decimals uint8, precision int) string {
 	return rat.FloatString(precision)
 }
 
+/*
+NOTE(@hadydotai): Raw amounts.
+
+Everything on chain is an integer in base units, the decimals are only there for us humans. Printing 1.23456789 for
+something that's really 1234567891 and then having someone parse it back is how integrators lose the last digit. So
+structured output (JSON) always carries both, and -raw-amounts switches everything we print for humans over to the
+raw integers too.
+*/
+
+// rawAmounts is set by -raw-amounts, amounts are printed as raw base unit integers rather than decimals.
+var rawAmounts bool
+
+// fmtAmount renders an on-chain amount, as a decimal or as the raw integer when -raw-amounts is set.
+func fmtAmount(raw *big.Int, decimals uint8) string {
+	if raw == nil {
+		return "0"
+	}
+	if rawAmounts {
+		return raw.String()
+	}
+	return display.format(raw, decimals, int(decimals))
+}
+
+// amountJSON is how amounts appear in structured output, the raw integer alongside its decimal rendering (as
+// -sig-figs, -number-locale and -dust-sci have it).
+type amountJSON struct {
+	Raw     string `json:"raw"`
+	Display string `json:"display"`
+}
+
+func newAmountJSON(v *big.Int, decimals uint8) amountJSON {
+	if v == nil {
+		return amountJSON{}
+	}
+	return amountJSON{Raw: v.String(), Display: display.format(v, decimals, int(decimals))}
+}
+
+// String follows -raw-amounts, for when a structured amount ends up printed for humans.
+func (a amountJSON) String() string {
+	if rawAmounts {
+		return a.Raw
+	}
+	return a.Display
+}
+
 func fmtForMath(amountStr string, decimals uint8) (*big.Int, error) {
 	rat, ok := new(big.Rat).SetString(amountStr)
 	if !ok {
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"hadydotai/raydium-client/raydiumpb"
+
+	solana "github.com/gagliardetto/solana-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+/*
+NOTE(@hadydotai): gRPC API.
+
+Same server as the HTTP API, different wire. `serve -grpc 127.0.0.1:9090` starts it next to the HTTP listener, sharing
+the pool cache, the wallet and the one-swap-at-a-time lock, so a swap sent over one can't race a swap sent over the
+other. The schema is raydiumpb/raydium.proto, its messages mirror CPIntent and SwapAmounts rather than the JSON
+shapes, integrations that care about latency usually care about the raw numbers too.
+
+BuildSwap is the one thing HTTP doesn't do: it plans the swap for any wallet and hands back the unsigned transaction,
+so the caller can keep their keys and sign on their side. StreamPoolUpdates is fed by the same vault watcher limit
+orders use, an update goes out whenever a vault changes, and at least every -poll.
+
+HTTP statuses map onto gRPC codes, every error the HTTP API knows how to classify keeps its meaning here.
+*/
+
+type grpcServer struct {
+	raydiumpb.UnimplementedRaydiumClientServer
+	api  *apiServer
+	wsEP string
+	poll time.Duration
+	done <-chan struct{} // closed on shutdown, ends the streams so a graceful stop doesn't wait on them forever
+}
+
+func newGRPCServer(api *apiServer, wsEP string, poll time.Duration, done <-chan struct{}) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if err := api.authorizeGRPC(ctx); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := api.authorizeGRPC(ss.Context()); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	)
+	raydiumpb.RegisterRaydiumClientServer(srv, &grpcServer{api: api, wsEP: wsEP, poll: poll, done: done})
+	return srv
+}
+
+// authorizeGRPC is authorize for gRPC, the token comes in the authorization metadata.
+func (s *apiServer) authorizeGRPC(ctx context.Context) error {
+	if s.token == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	want := []byte("Bearer " + s.token)
+	for _, got := range md.Get("authorization") {
+		if subtle.ConstantTimeCompare([]byte(got), want) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or wrong bearer token")
+}
+
+// grpcError carries an apiError's meaning over to a gRPC status.
+func grpcError(err error) error {
+	var ae *apiError
+	if !errors.As(err, &ae) {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	code := codes.Unknown
+	switch ae.status {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusConflict, http.StatusUnprocessableEntity:
+		code = codes.FailedPrecondition
+	}
+	return status.Error(code, err.Error())
+}
+
+func fromPBQuote(req *raydiumpb.QuoteRequest) (quoteRequest, error) {
+	if req.GetPool() == "" || req.GetIntent() == "" {
+		return quoteRequest{}, badRequest("pool and intent are required")
+	}
+	return quoteRequest{Pool: req.GetPool(), Intent: req.GetIntent(), Slippage: req.Slippage}, nil
+}
+
+func pbAmount(v *big.Int, decimals uint8) *raydiumpb.Amount {
+	if v == nil {
+		return nil
+	}
+	a := newAmountJSON(v, decimals)
+	return &raydiumpb.Amount{Raw: a.Raw, Display: a.Display}
+}
+
+func pbLeg(leg SwapLeg, symm SymbolMapping) *raydiumpb.SwapLeg {
+	return &raydiumpb.SwapLeg{
+		Mint:     leg.Mint.String(),
+		Vault:    leg.Vault.String(),
+		Program:  leg.Program.String(),
+		Decimals: uint32(leg.Decimals),
+		Symbol:   symm.SymFrom(leg.Mint),
+	}
+}
+
+func pbIntent(intent *CPIntent, symm SymbolMapping) *raydiumpb.CPIntent {
+	// The known amount is in the token the intent named, the input for exact input swaps, the output otherwise.
+	knownDec, quoteDec := intent.TokenIn.Decimals, intent.TokenOut.Decimals
+	kind := raydiumpb.SwapKind_SWAP_KIND_UNKNOWN
+	switch intent.SwapKind {
+	case SwapKindBaseInput:
+		kind = raydiumpb.SwapKind_SWAP_KIND_BASE_INPUT
+	case SwapKindBaseOutput:
+		kind = raydiumpb.SwapKind_SWAP_KIND_BASE_OUTPUT
+		knownDec, quoteDec = quoteDec, knownDec
+	}
+	out := &raydiumpb.CPIntent{
+		Intent:   intent.String(),
+		SwapKind: kind,
+		Amounts: &raydiumpb.SwapAmounts{
+			KnownAmount:  pbAmount(intent.Amounts.KnownAmount, knownDec),
+			QuoteAmount:  pbAmount(intent.Amounts.QuoteAmount, quoteDec),
+			MinAmountOut: pbAmount(intent.Amounts.MinAmountOut, intent.TokenOut.Decimals),
+			MaxAmountIn:  pbAmount(intent.Amounts.MaxAmountIn, intent.TokenIn.Decimals),
+		},
+		TokenIn:  pbLeg(intent.TokenIn, symm),
+		TokenOut: pbLeg(intent.TokenOut, symm),
+		Pool: &raydiumpb.PoolAccounts{
+			Address:     intent.Pool.Address.String(),
+			AmmConfig:   intent.Pool.AmmConfig.String(),
+			Observation: intent.Pool.Observation.String(),
+		},
+	}
+	if intent.ReserveIn != nil {
+		out.ReserveIn = pbAmount(intent.ReserveIn.Balance, intent.ReserveIn.Decimals)
+	}
+	if intent.ReserveOut != nil {
+		out.ReserveOut = pbAmount(intent.ReserveOut.Balance, intent.ReserveOut.Decimals)
+	}
+	return out
+}
+
+func pbQuoteReply(intent *CPIntent, resp quoteResponse, symm SymbolMapping) *raydiumpb.QuoteReply {
+	return &raydiumpb.QuoteReply{
+		Intent:      pbIntent(intent, symm),
+		Slippage:    resp.Slippage,
+		PriceImpact: resp.PriceImpact,
+		Price:       resp.Price,
+		PriceUnit:   resp.PriceUnit,
+	}
+}
+
+func (g *grpcServer) Quote(ctx context.Context, req *raydiumpb.QuoteRequest) (*raydiumpb.QuoteReply, error) {
+	qr, err := fromPBQuote(req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	builder, intent, resp, err := g.api.quote(ctx, qr)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return pbQuoteReply(intent, resp, builder.symbols()), nil
+}
+
+func (g *grpcServer) BuildSwap(ctx context.Context, req *raydiumpb.BuildSwapRequest) (*raydiumpb.BuildSwapReply, error) {
+	payer, err := solana.PublicKeyFromBase58(req.GetPayer())
+	if err != nil {
+		return nil, grpcError(badRequest("payer %q isn't a base58 address: %v", req.GetPayer(), err))
+	}
+	qr, err := fromPBQuote(req.GetQuote())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	qr.Wallet = payer.String() // a percentage amount is of what the payer holds
+	builder, intent, resp, err := g.api.quote(ctx, qr)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	snap := builder.snapshot()
+	if err := snap.venue.CheckTradable(time.Now()); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	planCtx, cancel := deadlines.forQuote(ctx)
+	defer cancel()
+	plan, err := planSwap(planCtx, g.api.client, payer, intent)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	tx, err := unsignedTransaction(planCtx, g.api.client, payer, plan.instructions)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	// NOTE(@hadydotai): Zeroed signatures hold the slots, that's the wire format wallets and web3.js expect for a
+	// transaction still waiting on its signers.
+	tx.Signatures = make([]solana.Signature, tx.Message.Header.NumRequiredSignatures)
+	wire, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, status.Error(codes.Internal, fmt.Sprintf("encoding transaction failed: %v", err))
+	}
+	return &raydiumpb.BuildSwapReply{
+		Quote:           pbQuoteReply(intent, resp, snap.symm),
+		Transaction:     base64.StdEncoding.EncodeToString(wire),
+		RecentBlockhash: tx.Message.RecentBlockhash.String(),
+	}, nil
+}
+
+func (g *grpcServer) SubmitSwap(ctx context.Context, req *raydiumpb.SubmitSwapRequest) (*raydiumpb.SubmitSwapReply, error) {
+	qr, err := fromPBQuote(req.GetQuote())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if req.MaxImpact != nil {
+		if *req.MaxImpact <= 0 {
+			return nil, grpcError(badRequest("max_impact must be greater than zero"))
+		}
+		qr.MaxImpact = req.MaxImpact
+	}
+	receipt, err := g.api.swap(ctx, qr)
+	if receipt == nil {
+		return nil, grpcError(err)
+	}
+	reply := &raydiumpb.SubmitSwapReply{
+		Signature:      receipt.Signature,
+		Status:         receipt.Status,
+		FeeLamports:    receipt.FeeLamports,
+		PaidSymbol:     receipt.PaidSymbol,
+		ReceivedSymbol: receipt.RecvSymbol,
+		Explorer:       receipt.Explorer,
+	}
+	if receipt.Paid != nil {
+		reply.Paid = &raydiumpb.Amount{Raw: receipt.Paid.Raw, Display: receipt.Paid.Display}
+	}
+	if receipt.Received != nil {
+		reply.Received = &raydiumpb.Amount{Raw: receipt.Received.Raw, Display: receipt.Received.Display}
+	}
+	if err != nil {
+		reply.Error = err.Error()
+	}
+	return reply, nil
+}
+
+func (g *grpcServer) StreamPoolUpdates(req *raydiumpb.PoolUpdatesRequest, stream grpc.ServerStreamingServer[raydiumpb.PoolUpdate]) error {
+	ctx := stream.Context()
+	loaded, err := g.api.pool(ctx, req.GetPool())
+	if err != nil {
+		return grpcError(err)
+	}
+	var builder *TableBuilder
+	slippage := g.api.slippagePct
+	if req.Slippage != nil {
+		slippage = req.GetSlippage()
+	}
+	if req.GetIntent() != "" {
+		if builder, err = newTableBuilder(ctx, g.api.client, loaded, slippage); err != nil {
+			return grpcError(badRequest("%v", err))
+		}
+	}
+	vaults := []solana.PublicKey{loaded.pool.Token0Vault, loaded.pool.Token1Vault}
+	notify := make(chan struct{}, 1)
+	notify <- struct{}{} // the first update goes out right away
+	go watchVaults(ctx, g.wsEP, vaults, g.poll, notify)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-g.done:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case <-notify:
+		}
+		update, err := g.poolUpdate(ctx, loaded, builder, req.GetIntent(), slippage)
+		if err != nil {
+			return grpcError(err)
+		}
+		if err := stream.Send(update); err != nil {
+			return err
+		}
+	}
+}
+
+// poolUpdate reads the reserves once and re-quotes the intent against them, a failed quote doesn't end the stream.
+func (g *grpcServer) poolUpdate(ctx context.Context, loaded *loadedPool, builder *TableBuilder, intentLine string, slippage float64) (*raydiumpb.PoolUpdate, error) {
+	quoteCtx, cancel := deadlines.forQuote(ctx)
+	defer cancel()
+	balances, errs := poolReserves(quoteCtx, g.api.client, loaded.pool, loaded.mints)
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	update := &raydiumpb.PoolUpdate{
+		UnixMillis: time.Now().UnixMilli(),
+		Pool:       loaded.address.String(),
+		Reserve0:   pbAmount(balances[0].Balance, balances[0].Decimals),
+		Reserve1:   pbAmount(balances[1].Balance, balances[1].Decimals),
+		Price:      poolPrice(balances[0], balances[1]),
+	}
+	if builder == nil {
+		return update, nil
+	}
+	if _, intent, err := builder.Build(intentLine); err != nil {
+		update.Error = err.Error()
+	} else {
+		update.Quote = pbQuoteReply(intent, newQuoteResponse(builder, intent, slippage), builder.symbols())
+	}
+	return update, nil
+}
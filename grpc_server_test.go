@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"hadydotai/raydium-client/raydiumpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dialTestGRPC serves the api over an in-memory connection and returns a client for it.
+func dialTestGRPC(t *testing.T, api *apiServer) raydiumpb.RaydiumClientClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	done := make(chan struct{})
+	srv := newGRPCServer(api, "", time.Hour, done)
+	go srv.Serve(lis)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		close(done)
+		srv.Stop()
+	})
+	return raydiumpb.NewRaydiumClientClient(conn)
+}
+
+func TestGRPCQuote(t *testing.T) {
+	api, addr := newTestAPIServer(t)
+	client := dialTestGRPC(t, api)
+	reply, err := client.Quote(context.Background(), &raydiumpb.QuoteRequest{Pool: addr.String(), Intent: "buy 10 USDC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	intent := reply.GetIntent()
+	if intent.GetSwapKind() != raydiumpb.SwapKind_SWAP_KIND_BASE_OUTPUT || intent.GetTokenOut().GetSymbol() != "USDC" {
+		t.Errorf("unexpected intent %v", intent)
+	}
+	// Exact output, the known amount is in USDC (6 decimals) and the max in is in SOL (9 decimals).
+	if got := intent.GetAmounts().GetKnownAmount().GetRaw(); got != "10000000" {
+		t.Errorf("known amount = %s, want 10000000", got)
+	}
+	if intent.GetAmounts().GetMaxAmountIn() == nil || intent.GetAmounts().GetMinAmountOut() != nil {
+		t.Errorf("exact output swaps carry a max in and no min out, got %v", intent.GetAmounts())
+	}
+	if intent.GetReserveOut().GetRaw() != "150000000000" || reply.GetSlippage() != 0.5 || reply.GetPriceImpact() == "" {
+		t.Errorf("unexpected reply %v", reply)
+	}
+
+	for _, tc := range []struct {
+		req  *raydiumpb.QuoteRequest
+		want codes.Code
+	}{
+		{&raydiumpb.QuoteRequest{Pool: addr.String()}, codes.InvalidArgument},
+		{&raydiumpb.QuoteRequest{Pool: "not-base58!", Intent: "pay 1 SOL"}, codes.InvalidArgument},
+		{&raydiumpb.QuoteRequest{Pool: addr.String(), Intent: "pay 1 BONK"}, codes.FailedPrecondition},
+	} {
+		if _, err := client.Quote(context.Background(), tc.req); status.Code(err) != tc.want {
+			t.Errorf("%v: got %v, want %v", tc.req, err, tc.want)
+		}
+	}
+}
+
+func TestGRPCSubmitSwapNeedsWallet(t *testing.T) {
+	api, addr := newTestAPIServer(t)
+	client := dialTestGRPC(t, api)
+	_, err := client.SubmitSwap(context.Background(), &raydiumpb.SubmitSwapRequest{Quote: &raydiumpb.QuoteRequest{Pool: addr.String(), Intent: "pay 1 SOL"}})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("got %v, want PermissionDenied", err)
+	}
+}
+
+func TestGRPCAuth(t *testing.T) {
+	api, addr := newTestAPIServer(t)
+	api.token = "s3cret"
+	client := dialTestGRPC(t, api)
+	req := &raydiumpb.QuoteRequest{Pool: addr.String(), Intent: "pay 1 SOL"}
+	if _, err := client.Quote(context.Background(), req); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("no token: got %v", err)
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer s3cret")
+	if _, err := client.Quote(ctx, req); err != nil {
+		t.Errorf("with token: %v", err)
+	}
+}
+
+func TestGRPCStreamPoolUpdates(t *testing.T) {
+	api, addr := newTestAPIServer(t)
+	client := dialTestGRPC(t, api)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.StreamPoolUpdates(ctx, &raydiumpb.PoolUpdatesRequest{Pool: addr.String(), Intent: "pay 1 SOL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	update, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update.GetPool() != addr.String() || update.GetPrice() != "150.000000" || update.GetReserve0().GetRaw() != "1000000000000" {
+		t.Errorf("unexpected update %v", update)
+	}
+	if update.GetQuote().GetIntent().GetAmounts().GetQuoteAmount() == nil || update.GetError() != "" {
+		t.Errorf("the update should carry a quote, got %v", update)
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+/*
+NOTE(@hadydotai): History export.
+
+Whoever does the books wants every fill as a row in their tool's import format, not a JSON lines file. `history export`
+reads a receipts file (receipts.go) and writes one row per fill in plain CSV, or in the CSV Koinly and QuickBooks
+import.
+
+A receipt is what we saw when the swap was sent, and some were written before the swap confirmed (a pending receipt
+from a send that ran out of time). So unless -offline is given, each receipt's transaction is looked up on chain: its
+block time is when the fill happened, its fee is what was paid, and whether it landed at all is the chain's answer,
+not the receipt's. Only fills that landed are exported, a swap that failed moved nothing but its fee, and a receipt the
+chain has never heard of is left out with a warning.
+
+Koinly's universal format has a row per trade, sent and received side by side. QuickBooks has no notion of a trade, a
+bank import is a date, a description and an amount, so a fill turns into a row for each token it moved and one for the
+fee, each description starting with the token so they can be split into one account per token.
+
+`history wallet` is the other way round, what the chain says a wallet did, receipts or not. With -enhanced-api helius
+each transaction comes with what it was and did (enhanced_api.go), otherwise it's signatures, times and whether they
+failed.
+*/
+
+// historyFormats are what -format takes.
+var historyFormats = []string{"csv", "koinly", "quickbooks"}
+
+// historyFill is a receipt and what the chain says about its transaction.
+type historyFill struct {
+	swapReceipt
+	slot uint64 // 0 when it wasn't looked up
+}
+
+// confirmFills looks every receipt's transaction up and keeps the ones that landed, with their block time and fee. It
+// stops short when ctx ends.
+func confirmFills(ctx context.Context, client *rpc.Client, receipts []swapReceipt) []historyFill {
+	var fills []historyFill
+	for _, r := range receipts {
+		if ctx.Err() != nil {
+			return fills
+		}
+		sig, err := solana.SignatureFromBase58(r.Signature)
+		if err != nil {
+			log.Printf("warning: skipping the receipt of %q, its signature %q is invalid", r.Intent, r.Signature)
+			continue
+		}
+		lookupCtx, cancel := deadlines.forQuote(ctx)
+		maxVersion := uint64(0)
+		res, err := client.GetTransaction(lookupCtx, sig, &rpc.GetTransactionOpts{
+			Encoding:                       solana.EncodingBase64,
+			Commitment:                     rpc.CommitmentConfirmed,
+			MaxSupportedTransactionVersion: &maxVersion,
+		})
+		cancel()
+		switch {
+		case errors.Is(err, rpc.ErrNotFound) || (err == nil && res == nil):
+			log.Printf("warning: skipping %s (%s), the chain has no record of it", Addr(r.Signature), r.Intent)
+			continue
+		case err != nil:
+			log.Printf("warning: couldn't look %s up, exporting it as its receipt has it: %v", Addr(r.Signature), err)
+			if isFill(r.Status) {
+				fills = append(fills, historyFill{swapReceipt: r})
+			}
+			continue
+		}
+		if res.Meta != nil && res.Meta.Err != nil {
+			continue
+		}
+		fill := historyFill{swapReceipt: r, slot: res.Slot}
+		fill.Status = "confirmed"
+		if res.BlockTime != nil {
+			fill.Time = res.BlockTime.Time().UTC()
+		}
+		if res.Meta != nil {
+			fill.FeeLamports = res.Meta.Fee
+		}
+		if fill.Paid == nil || fill.Received == nil {
+			log.Printf("warning: %s landed but its receipt doesn't say what it paid and received, check it with `why %s`", Addr(r.Signature), r.Signature)
+		}
+		fills = append(fills, fill)
+	}
+	return fills
+}
+
+// offlineFills are the receipts that say they landed, taken at their word.
+func offlineFills(receipts []swapReceipt) []historyFill {
+	var fills []historyFill
+	for _, r := range receipts {
+		if isFill(r.Status) {
+			fills = append(fills, historyFill{swapReceipt: r})
+		}
+	}
+	return fills
+}
+
+func isFill(status string) bool {
+	return status == "confirmed" || status == "finalized"
+}
+
+func amountParts(a *amountJSON) (raw, display string) {
+	if a == nil {
+		return "", ""
+	}
+	return a.Raw, a.Display
+}
+
+func feeSOL(lamports uint64) string {
+	return fmtForDisplay(new(big.Int).SetUint64(lamports), 9, 9)
+}
+
+// writeHistory writes fills to w in format, one of historyFormats.
+func writeHistory(w io.Writer, format string, fills []historyFill) error {
+	cw := csv.NewWriter(w)
+	switch format {
+	case "csv":
+		_ = cw.Write([]string{"time", "signature", "status", "slot", "command", "pool", "intent",
+			"paid_symbol", "paid_raw", "paid", "received_symbol", "received_raw", "received", "fee_lamports", "fee_sol", "notional_usd"})
+		for _, f := range fills {
+			paidRaw, paid := amountParts(f.Paid)
+			recvRaw, recv := amountParts(f.Received)
+			slot := ""
+			if f.slot != 0 {
+				slot = fmt.Sprint(f.slot)
+			}
+			_ = cw.Write([]string{f.Time.Format(time.RFC3339), f.Signature, f.Status, slot, f.Command, f.Pool, f.Intent,
+				f.PaidSymbol, paidRaw, paid, f.RecvSymbol, recvRaw, recv, fmt.Sprint(f.FeeLamports), feeSOL(f.FeeLamports), f.NotionalUSD})
+		}
+	case "koinly":
+		_ = cw.Write([]string{"Date", "Sent Amount", "Sent Currency", "Received Amount", "Received Currency",
+			"Fee Amount", "Fee Currency", "Net Worth Amount", "Net Worth Currency", "Label", "Description", "TxHash"})
+		for _, f := range fills {
+			_, paid := amountParts(f.Paid)
+			_, recv := amountParts(f.Received)
+			worthCurrency := ""
+			if f.NotionalUSD != "" {
+				worthCurrency = "USD"
+			}
+			_ = cw.Write([]string{f.Time.Format("2006-01-02 15:04:05 UTC"), paid, f.PaidSymbol, recv, f.RecvSymbol,
+				feeSOL(f.FeeLamports), "SOL", f.NotionalUSD, worthCurrency, "", f.Intent, f.Signature})
+		}
+	case "quickbooks":
+		_ = cw.Write([]string{"Date", "Description", "Amount"})
+		for _, f := range fills {
+			date, what := f.Time.Format("01/02/2006"), fmt.Sprintf("%s (%s)", f.Intent, f.Signature)
+			if _, paid := amountParts(f.Paid); paid != "" {
+				_ = cw.Write([]string{date, f.PaidSymbol + ": paid in swap " + what, "-" + paid})
+			}
+			if _, recv := amountParts(f.Received); recv != "" {
+				_ = cw.Write([]string{date, f.RecvSymbol + ": received in swap " + what, recv})
+			}
+			_ = cw.Write([]string{date, "SOL: network fee of swap " + what, "-" + feeSOL(f.FeeLamports)})
+		}
+	default:
+		return fmt.Errorf("unknown format %q, expected one of [%s]", format, strings.Join(historyFormats, ", "))
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func runHistoryCommand(args []string) error {
+	return dispatchSubcommand("history", map[string]func([]string) error{
+		"export": runHistoryExportCommand,
+		"wallet": runHistoryWalletCommand,
+	}, args)
+}
+
+func runHistoryExportCommand(args []string) error {
+	fs := flag.NewFlagSet("history export", flag.ExitOnError)
+	nf := addNetworkFlags(fs)
+	var (
+		receiptsPath = fs.String("receipts", "", "Receipts file (JSON lines) to export, as written by -receipts")
+		format       = fs.String("format", "csv", fmt.Sprintf("Export format, one of [%s]", strings.Join(historyFormats, ", ")))
+		outPath      = fs.String("o", "", "Write the export to this file instead of stdout")
+		offline      = fs.Bool("offline", false, "Take the receipts at their word instead of looking every transaction up on chain")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, append(nf.specs(),
+		FlagSpec{Name: "receipts", Value: receiptsPath, Rules: []FlagRule{NotEmpty()}},
+		FlagSpec{Name: "format", Value: format, Rules: []FlagRule{OneOf(historyFormats...)}},
+	))
+	receipts, err := readReceipts(*receiptsPath)
+	if err != nil {
+		return fmt.Errorf("reading receipts: %w", err)
+	}
+	var fills []historyFill
+	if *offline {
+		fills = offlineFills(receipts)
+	} else {
+		client := nf.connect()
+		ctx, stop := interruptContext()
+		defer stop()
+		fills = confirmFills(ctx, client, receipts)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+
+	out := io.Writer(os.Stdout)
+	if *outPath != "" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := writeHistory(out, strings.ToLower(strings.TrimSpace(*format)), fills); err != nil {
+		return err
+	}
+	log.Printf("exported %d fills of %d receipts", len(fills), len(receipts))
+	return nil
+}
+
+// renderWalletHistory lays txs out one per row, source is where they came from (see walletHistory).
+func renderWalletHistory(wallet solana.PublicKey, txs []walletTx, source string) string {
+	builder := &strings.Builder{}
+	t := table.NewWriter()
+	t.SetOutputMirror(builder)
+	t.SetTitle(wallet.String())
+	t.AppendHeader(table.Row{"Time", "Signature", "Status", "Type", "Description", "Fee"})
+	for _, tx := range txs {
+		status, when, kind, fee := "ok", "", tx.Type, ""
+		if tx.Failed {
+			status = "failed"
+		}
+		if !tx.Time.IsZero() {
+			when = tx.Time.Format(time.DateTime)
+		}
+		if kind == "" {
+			kind = "-"
+		}
+		if tx.FeeLamports != 0 {
+			fee = fmtSOL(tx.FeeLamports)
+		}
+		t.AppendRow(table.Row{when, Addr(tx.Signature), status, kind, tx.Description, fee})
+	}
+	t.AppendFooter(table.Row{fmt.Sprintf("%d transactions", len(txs)), "from " + source, "", "", "", ""})
+	t.Render()
+	return builder.String()
+}
+
+func runHistoryWalletCommand(args []string) error {
+	fs := flag.NewFlagSet("history wallet", flag.ExitOnError)
+	nf := addNetworkFlags(fs)
+	var wallet string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		wallet, args = args[0], args[1:]
+	}
+	var (
+		limit  = fs.Int("limit", 25, "How many transactions to show, up to 100")
+		before = fs.String("before", "", "Show the transactions before this signature, for the next page")
+		asJSON = fs.Bool("json", false, "Print the transactions as JSON instead of a table")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, nf.specs())
+	address, err := solana.PublicKeyFromBase58(wallet)
+	if err != nil {
+		return fmt.Errorf("history wallet needs a wallet address: %w", err)
+	}
+	if *limit < 1 || *limit > 100 {
+		return fmt.Errorf("-limit has to be between 1 and 100, got %d", *limit)
+	}
+	client := nf.connect()
+	ctx, stop := interruptContext()
+	defer stop()
+	lookupCtx, cancel := deadlines.forQuote(ctx)
+	defer cancel()
+	txs, source, err := walletHistory(lookupCtx, client, address, *before, *limit)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(txs)
+	}
+	fmt.Print(renderWalletHistory(address, txs, source))
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func historyReceipt(sig solana.Signature, status string) swapReceipt {
+	return swapReceipt{
+		Time: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), Command: "limit", Pool: snapshotKey(1).String(), Intent: "pay 1 SOL",
+		Signature: sig.String(), Status: status,
+		Paid: ptrTo(newAmountJSON(big.NewInt(1e9), 9)), PaidSymbol: "SOL",
+		Received: ptrTo(newAmountJSON(big.NewInt(150e6), 6)), RecvSymbol: "USDC",
+		FeeLamports: 5000, NotionalUSD: "150.00",
+	}
+}
+
+func TestConfirmFills(t *testing.T) {
+	landed, failed, unknown, pending := solana.Signature{1}, solana.Signature{2}, solana.Signature{3}, solana.Signature{4}
+	blockTime := time.Date(2026, 3, 1, 12, 0, 7, 0, time.UTC)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Params []json.RawMessage `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		var sig string
+		json.Unmarshal(req.Params[0], &sig)
+		switch sig {
+		case landed.String(), pending.String():
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"slot":42,"blockTime":%d,"meta":{"err":null,"fee":7000},"transaction":null}}`, req.ID, blockTime.Unix())
+		case failed.String():
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"slot":43,"meta":{"err":{"InstructionError":[0,{"Custom":6005}]},"fee":5000},"transaction":null}}`, req.ID)
+		default:
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":null}`, req.ID)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	receipts := []swapReceipt{
+		historyReceipt(landed, "confirmed"), historyReceipt(failed, "confirmed"),
+		historyReceipt(unknown, "confirmed"), historyReceipt(pending, "pending"),
+	}
+	fills := confirmFills(t.Context(), rpc.New(srv.URL), receipts)
+	if len(fills) != 2 || fills[0].Signature != landed.String() || fills[1].Signature != pending.String() {
+		t.Fatalf("fills %+v", fills)
+	}
+	if f := fills[1]; f.Status != "confirmed" || !f.Time.Equal(blockTime) || f.FeeLamports != 7000 || f.slot != 42 {
+		t.Errorf("the pending receipt that landed %+v", f)
+	}
+	if offline := offlineFills(receipts); len(offline) != 3 {
+		t.Errorf("%d offline fills, want the 3 receipts that say they landed", len(offline))
+	}
+}
+
+func TestWriteHistory(t *testing.T) {
+	fills := []historyFill{{swapReceipt: historyReceipt(solana.Signature{1}, "confirmed"), slot: 42}}
+	sig := solana.Signature{1}.String()
+	for format, want := range map[string][]string{
+		"csv": {
+			"time,signature,status,slot,command,pool,intent,paid_symbol,paid_raw,paid,received_symbol,received_raw,received,fee_lamports,fee_sol,notional_usd",
+			"2026-03-01T12:00:00Z," + sig + ",confirmed,42,limit," + snapshotKey(1).String() + ",pay 1 SOL,SOL,1000000000,1.000000000,USDC,150000000,150.000000,5000,0.000005000,150.00",
+		},
+		"koinly": {
+			"Date,Sent Amount,Sent Currency,Received Amount,Received Currency,Fee Amount,Fee Currency,Net Worth Amount,Net Worth Currency,Label,Description,TxHash",
+			"2026-03-01 12:00:00 UTC,1.000000000,SOL,150.000000,USDC,0.000005000,SOL,150.00,USD,,pay 1 SOL," + sig,
+		},
+		"quickbooks": {
+			"Date,Description,Amount",
+			"03/01/2026,SOL: paid in swap pay 1 SOL (" + sig + "),-1.000000000",
+			"03/01/2026,USDC: received in swap pay 1 SOL (" + sig + "),150.000000",
+			"03/01/2026,SOL: network fee of swap pay 1 SOL (" + sig + "),-0.000005000",
+		},
+	} {
+		var out bytes.Buffer
+		if err := writeHistory(&out, format, fills); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Split(strings.TrimSpace(out.String()), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("%s:\n got %q\nwant %q", format, got, want)
+		}
+	}
+	if err := writeHistory(&bytes.Buffer{}, "xlsx", fills); err == nil {
+		t.Error("wrote an unknown format")
+	}
+}
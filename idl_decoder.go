@@ -0,0 +1,700 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): IDL decoder.
+
+The generated bindings in raydium_cp_swap decode what we compiled them for, and nothing else: a field Raydium added
+since, an account or instruction we never generated, an IDL we want to try before regenerating. `decode` works off the
+Anchor IDL itself, loaded when it runs (the copy in idls/ is built in, -idl reads another), and walks an account's or an
+instruction's bytes field by field from the types the IDL declares.
+
+It's a debugging tool, so it's generic over the IDL rather than over Anchor: the primitives, pubkeys, strings, bytes,
+options, vecs, fixed arrays, structs and enums, which is everything cp-swap's IDL uses and what the Borsh layout of
+most Anchor programs comes down to. Zero-copy accounts (PoolState, ObservationState) are repr(C, packed), byte for
+byte the same layout, a zero-copy type that isn't packed would have padding we don't model and is refused.
+
+`decode <pubkey>` prints the account, matched on its 8 byte discriminator. `decode <signature>` prints every cp-swap
+instruction in the transaction, inner ones included, with its arguments and named accounts, and the events the program
+logged.
+*/
+
+//go:embed idls/raydium_cp_swap.json
+var bundledCPSwapIDL []byte
+
+type anchorIDL struct {
+	Address  string `json:"address"`
+	Metadata struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"metadata"`
+	Instructions []idlInstruction `json:"instructions"`
+	Accounts     []idlNamedItem   `json:"accounts"`
+	Events       []idlNamedItem   `json:"events"`
+	Types        []idlTypeDef     `json:"types"`
+}
+
+// idlNamedItem is an account or event, its layout is the type of the same name.
+type idlNamedItem struct {
+	Name          string  `json:"name"`
+	Discriminator [8]byte `json:"discriminator"`
+}
+
+type idlInstruction struct {
+	Name          string           `json:"name"`
+	Discriminator [8]byte          `json:"discriminator"`
+	Accounts      []idlAccountItem `json:"accounts"`
+	Args          []idlField       `json:"args"`
+}
+
+// idlAccountItem is an instruction account, or a group of them when Accounts is set.
+type idlAccountItem struct {
+	Name     string           `json:"name"`
+	Writable bool             `json:"writable"`
+	Signer   bool             `json:"signer"`
+	Accounts []idlAccountItem `json:"accounts"`
+}
+
+type idlField struct {
+	Name string  `json:"name"`
+	Type idlType `json:"type"`
+}
+
+type idlTypeDef struct {
+	Name string `json:"name"`
+	Repr *struct {
+		Kind   string `json:"kind"`
+		Packed bool   `json:"packed"`
+	} `json:"repr"`
+	Type struct {
+		Kind     string          `json:"kind"`
+		Fields   json.RawMessage `json:"fields"`
+		Variants []struct {
+			Name   string          `json:"name"`
+			Fields json.RawMessage `json:"fields"`
+		} `json:"variants"`
+	} `json:"type"`
+}
+
+// idlType is one of: a primitive ("u64", "pubkey"), {"array": [T, n]}, {"vec": T}, {"option": T} or
+// {"defined": {"name": N}}.
+type idlType struct {
+	prim    string
+	elem    *idlType
+	length  int // for arrays
+	vec     bool
+	option  bool
+	defined string
+}
+
+func (t *idlType) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &t.prim); err == nil {
+		return nil
+	}
+	var compound struct {
+		Array   []json.RawMessage `json:"array"`
+		Vec     *idlType          `json:"vec"`
+		Option  *idlType          `json:"option"`
+		Defined json.RawMessage   `json:"defined"`
+	}
+	if err := json.Unmarshal(b, &compound); err != nil {
+		return fmt.Errorf("IDL type %s: %w", b, err)
+	}
+	switch {
+	case len(compound.Array) == 2:
+		t.elem = &idlType{}
+		if err := json.Unmarshal(compound.Array[0], t.elem); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(compound.Array[1], &t.length); err != nil {
+			return fmt.Errorf("IDL array %s has a length that isn't a number", b)
+		}
+	case compound.Vec != nil:
+		t.elem, t.vec = compound.Vec, true
+	case compound.Option != nil:
+		t.elem, t.option = compound.Option, true
+	case compound.Defined != nil:
+		// Older IDLs name the type directly, newer ones wrap it in {"name": ...}.
+		var named struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(compound.Defined, &t.defined); err != nil {
+			if err := json.Unmarshal(compound.Defined, &named); err != nil {
+				return fmt.Errorf("IDL type %s: %w", b, err)
+			}
+			t.defined = named.Name
+		}
+	default:
+		return fmt.Errorf("IDL type %s isn't one we know", b)
+	}
+	return nil
+}
+
+// idlNode is a decoded value, a leaf with a value or a struct, array or enum with children.
+type idlNode struct {
+	name     string
+	value    string
+	children []idlNode
+	compound bool
+}
+
+// idlDecoder decodes against one IDL.
+type idlDecoder struct {
+	idl   *anchorIDL
+	types map[string]*idlTypeDef
+}
+
+// loadIDL reads the IDL at path, or the built in cp-swap one when path is empty.
+func loadIDL(path string) (*idlDecoder, error) {
+	raw := bundledCPSwapIDL
+	if path != "" {
+		var err error
+		if raw, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("reading IDL %s: %w", path, err)
+		}
+	}
+	idl := &anchorIDL{}
+	if err := json.Unmarshal(raw, idl); err != nil {
+		return nil, fmt.Errorf("parsing IDL: %w", err)
+	}
+	d := &idlDecoder{idl: idl, types: make(map[string]*idlTypeDef, len(idl.Types))}
+	for i := range idl.Types {
+		d.types[idl.Types[i].Name] = &idl.Types[i]
+	}
+	return d, nil
+}
+
+// idlReader reads Borsh values off data in order.
+type idlReader struct {
+	data []byte
+	off  int
+}
+
+func (r *idlReader) take(n int) ([]byte, error) {
+	if n < 0 || r.off+n > len(r.data) {
+		return nil, fmt.Errorf("needs %d more bytes at offset %d, there are %d", n, r.off, len(r.data)-r.off)
+	}
+	b := r.data[r.off : r.off+n]
+	r.off += n
+	return b, nil
+}
+
+func (r *idlReader) length() (int, error) {
+	b, err := r.take(4)
+	if err != nil {
+		return 0, err
+	}
+	return int(binary.LittleEndian.Uint32(b)), nil
+}
+
+// leInt is b, little endian, as an integer, two's complement when signed.
+func leInt(b []byte, signed bool) *big.Int {
+	be := make([]byte, len(b))
+	for i := range b {
+		be[len(b)-1-i] = b[i]
+	}
+	v := new(big.Int).SetBytes(be)
+	if signed && len(b) > 0 && b[len(b)-1]&0x80 != 0 {
+		v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(8*len(b))))
+	}
+	return v
+}
+
+var idlIntSizes = map[string]int{"u8": 1, "i8": 1, "u16": 2, "i16": 2, "u32": 4, "i32": 4, "u64": 8, "i64": 8, "u128": 16, "i128": 16}
+
+func (d *idlDecoder) decode(r *idlReader, name string, t idlType) (idlNode, error) {
+	node := idlNode{name: name}
+	switch {
+	case t.prim != "":
+		if size, ok := idlIntSizes[t.prim]; ok {
+			b, err := r.take(size)
+			if err != nil {
+				return node, err
+			}
+			node.value = leInt(b, t.prim[0] == 'i').String()
+			return node, nil
+		}
+		switch t.prim {
+		case "bool":
+			b, err := r.take(1)
+			if err != nil {
+				return node, err
+			}
+			node.value = strconv.FormatBool(b[0] != 0)
+		case "f32":
+			b, err := r.take(4)
+			if err != nil {
+				return node, err
+			}
+			node.value = strconv.FormatFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(b))), 'g', -1, 32)
+		case "f64":
+			b, err := r.take(8)
+			if err != nil {
+				return node, err
+			}
+			node.value = strconv.FormatFloat(math.Float64frombits(binary.LittleEndian.Uint64(b)), 'g', -1, 64)
+		case "pubkey", "publicKey":
+			b, err := r.take(32)
+			if err != nil {
+				return node, err
+			}
+			node.value = solana.PublicKeyFromBytes(b).String()
+		case "string", "bytes":
+			n, err := r.length()
+			if err != nil {
+				return node, err
+			}
+			b, err := r.take(n)
+			if err != nil {
+				return node, err
+			}
+			if t.prim == "string" {
+				node.value = strconv.Quote(string(b))
+			} else {
+				node.value = fmt.Sprintf("0x%x", b)
+			}
+		default:
+			return node, fmt.Errorf("%s has type %q, which the decoder doesn't know", name, t.prim)
+		}
+		return node, nil
+	case t.option:
+		b, err := r.take(1)
+		if err != nil {
+			return node, err
+		}
+		if b[0] == 0 {
+			node.value = "none"
+			return node, nil
+		}
+		return d.decode(r, name, *t.elem)
+	case t.elem != nil:
+		n := t.length
+		if t.vec {
+			var err error
+			if n, err = r.length(); err != nil {
+				return node, err
+			}
+		}
+		node.compound = true
+		for i := 0; i < n; i++ {
+			child, err := d.decode(r, fmt.Sprintf("[%d]", i), *t.elem)
+			if err != nil {
+				return node, fmt.Errorf("%s%s", name, err)
+			}
+			node.children = append(node.children, child)
+		}
+		return node, nil
+	case t.defined != "":
+		def, ok := d.types[t.defined]
+		if !ok {
+			return node, fmt.Errorf("%s has type %s, which the IDL doesn't define", name, t.defined)
+		}
+		return d.decodeDefined(r, name, def)
+	}
+	return node, fmt.Errorf("%s has an empty type", name)
+}
+
+func (d *idlDecoder) decodeDefined(r *idlReader, name string, def *idlTypeDef) (idlNode, error) {
+	if def.Repr != nil && def.Repr.Kind == "c" && !def.Repr.Packed {
+		return idlNode{name: name}, fmt.Errorf("%s is repr(C) without packed, its padding isn't something the decoder models", def.Name)
+	}
+	switch def.Type.Kind {
+	case "struct":
+		return d.decodeFields(r, name, def.Type.Fields)
+	case "enum":
+		b, err := r.take(1)
+		if err != nil {
+			return idlNode{name: name}, err
+		}
+		if int(b[0]) >= len(def.Type.Variants) {
+			return idlNode{name: name}, fmt.Errorf("%s is variant %d of %s, which only has %d", name, b[0], def.Name, len(def.Type.Variants))
+		}
+		variant := def.Type.Variants[b[0]]
+		if len(variant.Fields) == 0 {
+			return idlNode{name: name, value: variant.Name}, nil
+		}
+		node, err := d.decodeFields(r, name, variant.Fields)
+		node.value = variant.Name
+		return node, err
+	}
+	return idlNode{name: name}, fmt.Errorf("%s is a %q, which the decoder doesn't know", def.Name, def.Type.Kind)
+}
+
+// decodeFields decodes a struct's (or enum variant's) fields, named ones or a tuple of bare types.
+func (d *idlDecoder) decodeFields(r *idlReader, name string, raw json.RawMessage) (idlNode, error) {
+	node := idlNode{name: name, compound: true}
+	var fields []idlField
+	if err := json.Unmarshal(raw, &fields); err != nil || (len(fields) > 0 && fields[0].Name == "") {
+		var tuple []idlType
+		if err := json.Unmarshal(raw, &tuple); err != nil {
+			return node, fmt.Errorf("%s has fields the decoder can't read: %w", name, err)
+		}
+		fields = make([]idlField, len(tuple))
+		for i, t := range tuple {
+			fields[i] = idlField{Name: strconv.Itoa(i), Type: t}
+		}
+	}
+	for _, f := range fields {
+		child, err := d.decode(r, f.Name, f.Type)
+		if err != nil {
+			return node, err
+		}
+		node.children = append(node.children, child)
+	}
+	return node, nil
+}
+
+// decodeNamed decodes data, an account or event of the IDL's, whichever's discriminator it starts with.
+func (d *idlDecoder) decodeNamed(items []idlNamedItem, data []byte) (idlNode, error) {
+	if len(data) < 8 {
+		return idlNode{}, fmt.Errorf("%d bytes is too short for a discriminator", len(data))
+	}
+	for _, item := range items {
+		if !bytes.Equal(item.Discriminator[:], data[:8]) {
+			continue
+		}
+		def, ok := d.types[item.Name]
+		if !ok {
+			return idlNode{}, fmt.Errorf("the IDL has no type for %s", item.Name)
+		}
+		r := &idlReader{data: data, off: 8}
+		node, err := d.decodeDefined(r, item.Name, def)
+		if err != nil {
+			return node, err
+		}
+		if rest := len(data) - r.off; rest > 0 {
+			node.children = append(node.children, idlNode{name: "(trailing)", value: fmt.Sprintf("%d bytes", rest)})
+		}
+		return node, nil
+	}
+	return idlNode{}, fmt.Errorf("discriminator %x isn't one of %s's", data[:8], d.idl.Metadata.Name)
+}
+
+func (d *idlDecoder) decodeAccount(data []byte) (idlNode, error) {
+	return d.decodeNamed(d.idl.Accounts, data)
+}
+
+func (d *idlDecoder) decodeEvent(data []byte) (idlNode, error) {
+	return d.decodeNamed(d.idl.Events, data)
+}
+
+func flattenIDLAccounts(items []idlAccountItem, prefix string) []idlAccountItem {
+	var flat []idlAccountItem
+	for _, item := range items {
+		if len(item.Accounts) > 0 {
+			flat = append(flat, flattenIDLAccounts(item.Accounts, prefix+item.Name+".")...)
+			continue
+		}
+		item.Name = prefix + item.Name
+		flat = append(flat, item)
+	}
+	return flat
+}
+
+// decodeInstruction decodes an instruction of the IDL's program, its arguments and the accounts it was given by name.
+func (d *idlDecoder) decodeInstruction(data []byte, accounts []solana.PublicKey) (idlNode, error) {
+	if len(data) < 8 {
+		return idlNode{}, fmt.Errorf("%d bytes is too short for a discriminator", len(data))
+	}
+	for _, ix := range d.idl.Instructions {
+		if !bytes.Equal(ix.Discriminator[:], data[:8]) {
+			continue
+		}
+		node := idlNode{name: ix.Name, compound: true}
+		r := &idlReader{data: data, off: 8}
+		args := idlNode{name: "args", compound: true}
+		for _, f := range ix.Args {
+			child, err := d.decode(r, f.Name, f.Type)
+			if err != nil {
+				return node, fmt.Errorf("%s: %w", ix.Name, err)
+			}
+			args.children = append(args.children, child)
+		}
+		accs := idlNode{name: "accounts", compound: true}
+		named := flattenIDLAccounts(ix.Accounts, "")
+		for i, key := range accounts {
+			if i >= len(named) {
+				accs.children = append(accs.children, idlNode{name: fmt.Sprintf("remaining[%d]", i-len(named)), value: key.String()})
+				continue
+			}
+			value := key.String()
+			var marks []string
+			if named[i].Writable {
+				marks = append(marks, "writable")
+			}
+			if named[i].Signer {
+				marks = append(marks, "signer")
+			}
+			if len(marks) > 0 {
+				value += " (" + strings.Join(marks, ", ") + ")"
+			}
+			accs.children = append(accs.children, idlNode{name: named[i].Name, value: value})
+		}
+		if len(accounts) < len(named) {
+			accs.children = append(accs.children, idlNode{name: "(missing)", value: fmt.Sprintf("%d of the %d accounts the IDL lists", len(named)-len(accounts), len(named))})
+		}
+		node.children = []idlNode{args, accs}
+		return node, nil
+	}
+	return idlNode{}, fmt.Errorf("discriminator %x isn't one of %s's instructions", data[:8], d.idl.Metadata.Name)
+}
+
+// isArray reports whether n is an array or vec, its children are named by index.
+func (n idlNode) isArray() bool {
+	return n.compound && len(n.children) > 0 && strings.HasPrefix(n.children[0].name, "[")
+}
+
+// allZero reports whether n is an array of zeroes, padding most of the time.
+func (n idlNode) allZero() bool {
+	if !n.isArray() {
+		return false
+	}
+	for _, c := range n.children {
+		if c.compound || c.value != "0" {
+			return false
+		}
+	}
+	return true
+}
+
+// blank reports whether every value under n is zero, an unused slot in an array of structs.
+func (n idlNode) blank() bool {
+	if !n.compound {
+		return n.value == "0" || n.value == "false" || n.value == solana.PublicKey{}.String()
+	}
+	for _, c := range n.children {
+		if !c.blank() {
+			return false
+		}
+	}
+	return true
+}
+
+// inline is n on one line, for array elements.
+func (n idlNode) inline() string {
+	if !n.compound {
+		return n.value
+	}
+	if n.allZero() {
+		return fmt.Sprintf("[0 × %d]", len(n.children))
+	}
+	parts := make([]string, len(n.children))
+	for i, c := range n.children {
+		if n.isArray() {
+			parts[i] = c.inline()
+		} else {
+			parts[i] = c.name + ": " + c.inline()
+		}
+	}
+	if n.isArray() {
+		return "[" + strings.Join(parts, ", ") + "]"
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// render writes n to b as indented lines. Arrays go on one line, or one line per element when they hold structs, with
+// runs of blank elements (the unused part of the observation ring) on one line between them.
+func (n idlNode) render(b *strings.Builder, indent string) {
+	if !n.compound {
+		fmt.Fprintf(b, "%s%s: %s\n", indent, n.name, n.value)
+		return
+	}
+	if n.isArray() && !n.children[0].compound {
+		fmt.Fprintf(b, "%s%s: %s\n", indent, n.name, n.inline())
+		return
+	}
+	if n.value != "" {
+		fmt.Fprintf(b, "%s%s: %s\n", indent, n.name, n.value)
+	} else {
+		fmt.Fprintf(b, "%s%s:\n", indent, n.name)
+	}
+	if !n.isArray() {
+		for _, c := range n.children {
+			c.render(b, indent+"  ")
+		}
+		return
+	}
+	for i := 0; i < len(n.children); i++ {
+		c := n.children[i]
+		if !c.blank() {
+			fmt.Fprintf(b, "%s  %s %s\n", indent, c.name, c.inline())
+			continue
+		}
+		j := i
+		for j+1 < len(n.children) && n.children[j+1].blank() {
+			j++
+		}
+		if j == i {
+			fmt.Fprintf(b, "%s  [%d] blank\n", indent, i)
+		} else {
+			fmt.Fprintf(b, "%s  [%d..%d] blank\n", indent, i, j)
+		}
+		i = j
+	}
+}
+
+func (n idlNode) String() string {
+	b := &strings.Builder{}
+	n.render(b, "")
+	return b.String()
+}
+
+// decodeAccountAt fetches address and decodes it, it has to be owned by the program.
+func (d *idlDecoder) decodeAccountAt(ctx context.Context, client *rpc.Client, address solana.PublicKey) (string, error) {
+	res, err := client.GetAccountInfoWithOpts(ctx, address, &rpc.GetAccountInfoOpts{Encoding: solana.EncodingBase64})
+	if err != nil {
+		return "", fmt.Errorf("rpc call getAccountInfo failed: %w", err)
+	}
+	if res == nil || res.Value == nil {
+		return "", fmt.Errorf("account %s doesn't exist", address)
+	}
+	if !res.Value.Owner.Equals(raydium_cp_swap.ProgramID) {
+		return "", fmt.Errorf("%s is owned by %s, not %s, the decoder only reads the program's own accounts", address, res.Value.Owner, raydium_cp_swap.ProgramID)
+	}
+	data := res.Value.Data.GetBinary()
+	node, err := d.decodeAccount(data)
+	if err != nil {
+		return "", fmt.Errorf("decoding %s: %w", address, err)
+	}
+	return fmt.Sprintf("%s at %s, %d bytes\n%s", node.name, address, len(data), node), nil
+}
+
+// decodeTransaction fetches sig and decodes its instructions to the program, inner ones included, and the events the
+// program logged.
+func (d *idlDecoder) decodeTransaction(ctx context.Context, client *rpc.Client, sig solana.Signature) (string, error) {
+	maxVersion := uint64(0)
+	res, err := client.GetTransaction(ctx, sig, &rpc.GetTransactionOpts{
+		Encoding:                       solana.EncodingBase64,
+		Commitment:                     rpc.CommitmentConfirmed,
+		MaxSupportedTransactionVersion: &maxVersion,
+	})
+	if err != nil {
+		return "", fmt.Errorf("rpc call getTransaction for %s failed: %w", sig, err)
+	}
+	if res == nil || res.Meta == nil || res.Transaction == nil {
+		return "", fmt.Errorf("transaction %s has no metadata", sig)
+	}
+	tx, err := res.Transaction.GetTransaction()
+	if err != nil {
+		return "", fmt.Errorf("decoding transaction %s: %w", sig, err)
+	}
+	keys := txAccountKeys(tx, res.Meta)
+	b := &strings.Builder{}
+	status := "succeeded"
+	if res.Meta.Err != nil {
+		status = fmt.Sprintf("failed: %v", res.Meta.Err)
+	}
+	fmt.Fprintf(b, "Transaction %s at slot %d, %s\n", sig, res.Slot, status)
+
+	inner := map[int][]solana.CompiledInstruction{}
+	for _, set := range res.Meta.InnerInstructions {
+		inner[int(set.Index)] = set.Instructions
+	}
+	describe := func(label string, ix solana.CompiledInstruction) {
+		if int(ix.ProgramIDIndex) >= len(keys) {
+			fmt.Fprintf(b, "\n%s references account %d, the transaction only has %d\n", label, ix.ProgramIDIndex, len(keys))
+			return
+		}
+		program := keys[ix.ProgramIDIndex]
+		if !program.Equals(raydium_cp_swap.ProgramID) {
+			fmt.Fprintf(b, "\n%s program %s, not decoded\n", label, program)
+			return
+		}
+		accounts := make([]solana.PublicKey, 0, len(ix.Accounts))
+		for _, i := range ix.Accounts {
+			if int(i) < len(keys) {
+				accounts = append(accounts, keys[i])
+			}
+		}
+		node, err := d.decodeInstruction(ix.Data, accounts)
+		if err != nil {
+			fmt.Fprintf(b, "\n%s %s instruction that doesn't decode: %v\n", label, d.idl.Metadata.Name, err)
+			return
+		}
+		fmt.Fprintf(b, "\n%s ", label)
+		node.render(b, "")
+	}
+	for i, ix := range tx.Message.Instructions {
+		describe(fmt.Sprintf("#%d", i), ix)
+		for j, in := range inner[i] {
+			describe(fmt.Sprintf("#%d.%d (inner)", i, j+1), in)
+		}
+	}
+	for _, pd := range programDataLogs(res.Meta.LogMessages, raydium_cp_swap.ProgramID) {
+		node, err := d.decodeEvent(pd.data)
+		if err != nil {
+			fmt.Fprintf(b, "\nEvent under #%d that doesn't decode: %v\n", pd.instruction, err)
+			continue
+		}
+		fmt.Fprintf(b, "\nEvent under #%d ", pd.instruction)
+		node.render(b, "")
+	}
+	return b.String(), nil
+}
+
+func runDecodeCommand(args []string) error {
+	fs := flag.NewFlagSet("decode", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: decode [flags] <account address|transaction signature>\n")
+		fs.PrintDefaults()
+	}
+	nf := addNetworkFlags(fs)
+	idlPath := fs.String("idl", "", "Anchor IDL to decode with, the built in cp-swap IDL when empty")
+	var target string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		target, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, nf.specs())
+	if target == "" {
+		fs.Usage()
+		return errors.New("missing an account address or transaction signature")
+	}
+	decoder, err := loadIDL(*idlPath)
+	if err != nil {
+		return err
+	}
+	client := nf.connect()
+	ctx, stop := interruptContext()
+	defer stop()
+	lookupCtx, cancel := deadlines.forQuote(ctx)
+	defer cancel()
+	var out string
+	if address, err := solana.PublicKeyFromBase58(target); err == nil {
+		out, err = decoder.decodeAccountAt(lookupCtx, client, address)
+		if err != nil {
+			return err
+		}
+	} else if sig, err := solana.SignatureFromBase58(target); err == nil {
+		out, err = decoder.decodeTransaction(lookupCtx, client, sig)
+		if err != nil {
+			return err
+		}
+	} else {
+		return fmt.Errorf("%q is neither an account address nor a transaction signature", target)
+	}
+	fmt.Print(out)
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+func TestIDLDecoder(t *testing.T) {
+	d, err := loadIDL("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The IDL and the generated bindings agree on what's what.
+	for name, want := range map[string][8]byte{
+		"PoolState":        raydium_cp_swap.Account_PoolState,
+		"AmmConfig":        raydium_cp_swap.Account_AmmConfig,
+		"ObservationState": raydium_cp_swap.Account_ObservationState,
+	} {
+		found := false
+		for _, acc := range d.idl.Accounts {
+			found = found || (acc.Name == name && acc.Discriminator == want)
+		}
+		if !found {
+			t.Errorf("%s's discriminator isn't %v in the IDL", name, want)
+		}
+	}
+
+	mint0, mint1 := snapshotKey(1), snapshotKey(2)
+	pool, _ := newTestPoolState()
+	pool.Token0Mint, pool.Token1Mint, pool.LpSupply = mint0, mint1, 123456789
+	node, err := d.decodeAccount(anchorAccount(t, raydium_cp_swap.Account_PoolState, pool))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := node.String()
+	for _, want := range []string{"token_0_mint: " + mint0.String(), "lp_supply: 123456789", "padding: [0 × "} {
+		if !strings.Contains(out, want) {
+			t.Errorf("PoolState is missing %q\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "(trailing)") {
+		t.Errorf("PoolState didn't decode to its end\n%s", out)
+	}
+
+	data := append([]byte{}, raydium_cp_swap.Instruction_SwapBaseInput[:]...)
+	data = binary.LittleEndian.AppendUint64(data, 1_000_000)
+	data = binary.LittleEndian.AppendUint64(data, 990)
+	accounts := make([]solana.PublicKey, 14)
+	for i := range accounts {
+		accounts[i] = snapshotKey(byte(10 + i))
+	}
+	ix, err := d.decodeInstruction(data, accounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = ix.String()
+	for _, want := range []string{"swap_base_input:", "amount_in: 1000000", "minimum_amount_out: 990", "payer: " + accounts[0].String() + " (signer)", "remaining[0]: "} {
+		if !strings.Contains(out, want) {
+			t.Errorf("swap_base_input is missing %q\n%s", want, out)
+		}
+	}
+	if _, err := d.decodeInstruction(data[:12], accounts); err == nil {
+		t.Error("decoded a truncated instruction")
+	}
+
+	event := anchorAccount(t, raydium_cp_swap.Event_SwapEvent, &raydium_cp_swap.SwapEvent{PoolId: snapshotKey(3), InputAmount: 500, BaseInput: true})
+	ev, err := d.decodeEvent(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := ev.String(); !strings.Contains(out, "SwapEvent:") || !strings.Contains(out, "input_amount: 500") || !strings.Contains(out, "base_input: true") {
+		t.Errorf("SwapEvent\n%s", out)
+	}
+	if _, err := d.decodeAccount(event); err == nil {
+		t.Error("an event decoded as an account")
+	}
+}
@@ -0,0 +1,502 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+/*
+NOTE(@hadydotai): Auditing a fill.
+
+`why` is for swaps that failed, `inspect <signature>` is for the ones that didn't: what did that swap, ours or another
+wallet's or bot's, actually get? It fetches the transaction, finds every cp-swap swap in it and reports each
+the way a quote is reported, reserves, trade fee and slippage guard, except the amounts are what happened instead of
+what we expect to happen: what was paid and received, the price that works out to, and how far that is from the
+pool's price before the swap.
+
+The amounts come from the swap's event when it's in the logs (see swap_events.go), what the user's side of the trade
+saw, transfer fees and all. Without one (truncated logs, an old deployment) they're what the vaults moved by, which is
+the same unless a mint takes a transfer fee or something else in the transaction moved the vaults. The event reports
+the trade fee too, otherwise it's worked out from the AmmConfig's current rate, marked as an estimate.
+
+The reserves are the vaults' balances before and after the whole transaction. For a transaction that swaps the same
+pool twice, the second swap's "before" is really before the first one, and its price impact is off by as much.
+
+Most swaps other tools make go through an aggregator, a CPI into cp-swap inside the aggregator's instruction. Those
+are read from the transaction's inner instructions, which the node records with the same accounts and data a top
+level swap has, and paired with the events emitted deeper than the top level under the same instruction, in order.
+*/
+
+// swapInspection is one cp-swap swap in a landed transaction, as it happened. Everything keyed in and out is the
+// swap's input and output token.
+type swapInspection struct {
+	instruction  int // the top level instruction, the aggregator's for a swap made through one
+	inner        int // its position among the instruction's inner instructions, -1 for a top level swap
+	args         swapArgs
+	pool         solana.PublicKey
+	inMint       solana.PublicKey
+	outMint      solana.PublicKey
+	inDecimals   uint8
+	outDecimals  uint8
+	inBefore     *big.Int // the vaults before the transaction
+	outBefore    *big.Int
+	inAfter      *big.Int // and after it
+	outAfter     *big.Int
+	paid         *big.Int
+	received     *big.Int
+	tradeFee     *big.Int // as the swap's event reports it, nil when it doesn't
+	fromEvent    bool     // paid and received are the event's, not the vaults'
+	tradeFeeRate uint64   // the AmmConfig's current rate
+	inIsToken0   bool
+	inSymbol     string
+	outSymbol    string
+}
+
+// inspectSwaps decodes every cp-swap swap in a landed transaction, top level or made through an aggregator, pairing
+// each with the event it emitted. Pool state, symbols and the fee rate are left to the caller.
+func inspectSwaps(res *rpc.GetTransactionResult) ([]swapInspection, error) {
+	if res == nil || res.Meta == nil || res.Transaction == nil {
+		return nil, errors.New("the transaction has no metadata")
+	}
+	tx, err := res.Transaction.GetTransaction()
+	if err != nil {
+		return nil, fmt.Errorf("decoding transaction: %w", err)
+	}
+	keys := txAccountKeys(tx, res.Meta)
+	var events []loggedSwapEvent
+	if !logsTruncated(res.Meta.LogMessages) {
+		events = loggedSwapEvents(res.Meta.LogMessages, raydium_cp_swap.ProgramID)
+	}
+	paired := make([]bool, len(events))
+	var swaps []swapInspection
+	for i, ix := range tx.Message.Instructions {
+		s, ok, err := inspectSwap(res.Meta, keys, ix, i, -1, events, paired)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			swaps = append(swaps, s)
+		}
+		for _, inner := range res.Meta.InnerInstructions {
+			if int(inner.Index) != i {
+				continue
+			}
+			for j, cpi := range inner.Instructions {
+				s, ok, err := inspectSwap(res.Meta, keys, cpi, i, j, events, paired)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					swaps = append(swaps, s)
+				}
+			}
+		}
+	}
+	return swaps, nil
+}
+
+// inspectSwap decodes ix when it's a cp-swap swap, top level instruction i or its inner instruction inner (-1 for
+// none). It takes the first event not yet paired emitted under the same instruction at the same depth, for its pool.
+func inspectSwap(meta *rpc.TransactionMeta, keys solana.PublicKeySlice, ix solana.CompiledInstruction, i, inner int, events []loggedSwapEvent, paired []bool) (swapInspection, bool, error) {
+	if int(ix.ProgramIDIndex) >= len(keys) || !keys[ix.ProgramIDIndex].Equals(raydium_cp_swap.ProgramID) || len(ix.Accounts) <= swapAccOutputMint {
+		return swapInspection{}, false, nil
+	}
+	args, ok := decodeSwapArgs(ix.Data)
+	if !ok {
+		return swapInspection{}, false, nil
+	}
+	where := fmt.Sprintf("instruction %d", i)
+	if inner >= 0 {
+		where = fmt.Sprintf("instruction %d's inner instruction %d", i, inner)
+	}
+	for _, idx := range ix.Accounts {
+		if int(idx) >= len(keys) {
+			return swapInspection{}, false, fmt.Errorf("%s references account %d, the transaction only has %d", where, idx, len(keys))
+		}
+	}
+	s := swapInspection{instruction: i, inner: inner, args: args, pool: keys[ix.Accounts[swapAccPool]],
+		inMint: keys[ix.Accounts[swapAccInputMint]], outMint: keys[ix.Accounts[swapAccOutputMint]]}
+	inVault, outVault := int(ix.Accounts[swapAccInputVault]), int(ix.Accounts[swapAccOutputVault])
+	var okIn, okOut bool
+	s.inBefore, s.inDecimals, okIn = tokenBalanceAt(meta.PreTokenBalances, inVault)
+	s.outBefore, s.outDecimals, okOut = tokenBalanceAt(meta.PreTokenBalances, outVault)
+	if !okIn || !okOut {
+		return swapInspection{}, false, fmt.Errorf("the vaults' balances before %s aren't in the transaction's token balances", where)
+	}
+	s.inAfter, _, okIn = tokenBalanceAt(meta.PostTokenBalances, inVault)
+	s.outAfter, _, okOut = tokenBalanceAt(meta.PostTokenBalances, outVault)
+	if !okIn || !okOut {
+		return swapInspection{}, false, fmt.Errorf("the vaults' balances after %s aren't in the transaction's token balances", where)
+	}
+	for k, le := range events {
+		if !paired[k] && (le.depth == 1) == (inner < 0) && le.instruction == i && le.event.PoolId.Equals(s.pool) {
+			paired[k] = true
+			fill := swapFill{event: le.event, hasTradeFee: le.hasTradeFee}
+			s.paid, s.received, s.fromEvent = fill.paid(), fill.received(), true
+			if le.hasTradeFee {
+				s.tradeFee = new(big.Int).SetUint64(le.event.TradeFee)
+			}
+			break
+		}
+	}
+	if !s.fromEvent {
+		s.paid = new(big.Int).Sub(s.inAfter, s.inBefore)
+		s.received = new(big.Int).Sub(s.outBefore, s.outAfter)
+	}
+	if s.paid.Sign() <= 0 || s.received.Sign() <= 0 {
+		return swapInspection{}, false, fmt.Errorf("%s paid %s and received %s, that's not a swap that filled", where, s.paid, s.received)
+	}
+	return s, true, nil
+}
+
+func (s swapInspection) kind() string {
+	if s.args.baseInput {
+		return "swap_base_input"
+	}
+	return "swap_base_output"
+}
+
+// price is how much output one input buys at amountIn for amountOut, in whole tokens.
+func (s swapInspection) price(amountIn, amountOut *big.Int) *big.Rat {
+	price := new(big.Rat).SetFrac(amountOut, amountIn)
+	return price.Mul(price, new(big.Rat).SetFrac(fixedPointScale(s.inDecimals), fixedPointScale(s.outDecimals)))
+}
+
+func (s swapInspection) priceString(price *big.Rat) string {
+	return trimDecimal(price.FloatString(int(s.outDecimals)))
+}
+
+func (s swapInspection) realizedPrice() *big.Rat { return s.price(s.paid, s.received) }
+func (s swapInspection) poolPrice() *big.Rat     { return s.price(s.inBefore, s.outBefore) }
+
+// impact is how much worse the fill was than the pool's price before it, trade fee included.
+func (s swapInspection) impact() *big.Rat {
+	ratio := new(big.Rat).Quo(s.realizedPrice(), s.poolPrice())
+	return ratio.Sub(big.NewRat(1, 1), ratio)
+}
+
+// guard is the swap's slippage guard, in the output token for base input and the input token for base output.
+func (s swapInspection) guard() *big.Int { return new(big.Int).SetUint64(s.args.limit) }
+
+// guardRoom is how much further the price could have moved before the guard failed the swap, nil when there was no
+// guard.
+func (s swapInspection) guardRoom() *big.Rat {
+	if s.args.limit == 0 {
+		return nil
+	}
+	var room *big.Rat
+	if s.args.baseInput {
+		room = new(big.Rat).SetFrac(s.received, s.guard())
+	} else {
+		room = new(big.Rat).SetFrac(s.guard(), s.paid)
+	}
+	return room.Sub(room, big.NewRat(1, 1))
+}
+
+// fee is the trade fee in the input token, estimated reports whether it's worked out from the fee rate rather than
+// reported by the event. Like the program, the fee is rounded up.
+func (s swapInspection) fee() (fee *big.Int, estimated bool) {
+	if s.tradeFee != nil {
+		return s.tradeFee, false
+	}
+	fee = new(big.Int).Mul(s.paid, new(big.Int).SetUint64(s.tradeFeeRate))
+	fee.Add(fee, big.NewInt(feeRateDenom-1))
+	return fee.Quo(fee, big.NewInt(feeRateDenom)), true
+}
+
+func (s swapInspection) feeString() string {
+	fee, estimated := s.fee()
+	out := formatTokenAmount(fee, s.inDecimals, s.inSymbol)
+	if estimated {
+		out += " (est. at " + formatFeeRate(s.tradeFeeRate) + ")"
+	}
+	return out
+}
+
+func (s swapInspection) render() string {
+	builder := &strings.Builder{}
+	t := table.NewWriter()
+	t.SetOutputMirror(builder)
+	t.SetTitle(s.pool.String())
+	source := "the vault balances"
+	if s.fromEvent {
+		source = "the swap's event"
+	}
+	at := fmt.Sprintf("Instruction #%d", s.instruction)
+	if s.inner >= 0 {
+		at += fmt.Sprintf(", through an aggregator (inner #%d)", s.inner)
+	}
+	t.SetCaption("%s, cp-swap %s, amounts from %s.", at, s.kind(), source)
+	t.Style().Size.WidthMax = 120
+	// row puts the input token's cell and the output token's in their pool columns.
+	row := func(name string, in, out any) table.Row {
+		if s.inIsToken0 {
+			return table.Row{name, in, out}
+		}
+		return table.Row{name, out, in}
+	}
+	merged := table.RowConfig{AutoMerge: true, AutoMergeAlign: text.AlignLeft}
+	t.AppendHeader(table.Row{"", "Token 0", "Token 1"})
+	t.AppendRow(row("Symbol", s.inSymbol, s.outSymbol))
+	t.AppendRow(row("Reserves before", fmtAmount(s.inBefore, s.inDecimals), fmtAmount(s.outBefore, s.outDecimals)))
+	t.AppendRow(row("Reserves after", fmtAmount(s.inAfter, s.inDecimals), fmtAmount(s.outAfter, s.outDecimals)))
+	t.AppendRow(row("Decimals", s.inDecimals, s.outDecimals))
+	t.AppendSeparator()
+	tradeFeeRow := formatFeeRate(s.tradeFeeRate)
+	t.AppendRow(table.Row{"Trade fee", tradeFeeRow, tradeFeeRow}, merged)
+
+	t.AppendSeparator()
+	t.AppendRow(row("Filled", "paid "+formatTokenAmount(s.paid, s.inDecimals, s.inSymbol), "received "+formatTokenAmount(s.received, s.outDecimals, s.outSymbol)))
+	guard := "none"
+	if room := s.guardRoom(); room != nil {
+		symbol, decimals, verb := s.outSymbol, s.outDecimals, "min receive"
+		if !s.args.baseInput {
+			symbol, decimals, verb = s.inSymbol, s.inDecimals, "max pay"
+		}
+		guard = fmt.Sprintf("%s %s, %s to spare", verb, formatTokenAmount(s.guard(), decimals, symbol), pctString(room))
+	}
+	if s.args.baseInput {
+		t.AppendRow(row("Slippage guard", "", guard))
+	} else {
+		t.AppendRow(row("Slippage guard", guard, ""))
+	}
+	unit := s.outSymbol + " per " + s.inSymbol
+	realized := s.priceString(s.realizedPrice()) + " " + unit
+	t.AppendRow(table.Row{"Realized price", realized, realized}, merged)
+	poolPrice := s.priceString(s.poolPrice()) + " " + unit
+	t.AppendRow(table.Row{"Pool price before", poolPrice, poolPrice}, merged)
+	impact := pctString(s.impact()) + ", trade fee included"
+	t.AppendRow(table.Row{"Price impact", impact, impact}, merged)
+	t.AppendRow(row("Trade fee paid", s.feeString(), ""))
+	t.Render()
+	return builder.String()
+}
+
+type swapInspectionJSON struct {
+	Instruction       int           `json:"instruction"`
+	Inner             *int          `json:"inner,omitempty"` // its inner instruction, for a swap made through an aggregator
+	Kind              string        `json:"kind"`
+	Pool              string        `json:"pool"`
+	InputMint         string        `json:"inputMint"`
+	OutputMint        string        `json:"outputMint"`
+	InputSymbol       string        `json:"inputSymbol"`
+	OutputSymbol      string        `json:"outputSymbol"`
+	Paid              amountJSON    `json:"paid"`
+	Received          amountJSON    `json:"received"`
+	Guard             amountJSON    `json:"guard"`
+	ReservesBefore    [2]amountJSON `json:"reservesBefore"`
+	ReservesAfter     [2]amountJSON `json:"reservesAfter"`
+	PriceUnit         string        `json:"priceUnit"`
+	Price             string        `json:"price"`
+	PoolPrice         string        `json:"poolPrice"`
+	Impact            string        `json:"impact"`
+	TradeFee          amountJSON    `json:"tradeFee"`
+	TradeFeeEstimated bool          `json:"tradeFeeEstimated"`
+	FromEvent         bool          `json:"fromEvent"`
+}
+
+func (s swapInspection) json() swapInspectionJSON {
+	guardDecimals := s.outDecimals
+	if !s.args.baseInput {
+		guardDecimals = s.inDecimals
+	}
+	fee, estimated := s.fee()
+	var inner *int
+	if s.inner >= 0 {
+		inner = &s.inner
+	}
+	return swapInspectionJSON{
+		Instruction:       s.instruction,
+		Inner:             inner,
+		Kind:              s.kind(),
+		Pool:              s.pool.String(),
+		InputMint:         s.inMint.String(),
+		OutputMint:        s.outMint.String(),
+		InputSymbol:       s.inSymbol,
+		OutputSymbol:      s.outSymbol,
+		Paid:              newAmountJSON(s.paid, s.inDecimals),
+		Received:          newAmountJSON(s.received, s.outDecimals),
+		Guard:             newAmountJSON(s.guard(), guardDecimals),
+		ReservesBefore:    [2]amountJSON{newAmountJSON(s.inBefore, s.inDecimals), newAmountJSON(s.outBefore, s.outDecimals)},
+		ReservesAfter:     [2]amountJSON{newAmountJSON(s.inAfter, s.inDecimals), newAmountJSON(s.outAfter, s.outDecimals)},
+		PriceUnit:         s.outSymbol + " per " + s.inSymbol,
+		Price:             s.priceString(s.realizedPrice()),
+		PoolPrice:         s.priceString(s.poolPrice()),
+		Impact:            pctString(s.impact()),
+		TradeFee:          newAmountJSON(fee, s.inDecimals),
+		TradeFeeEstimated: estimated,
+		FromEvent:         s.fromEvent,
+	}
+}
+
+// txInspection is a landed transaction and the swaps in it.
+type txInspection struct {
+	sig      solana.Signature
+	slot     uint64
+	landed   time.Time
+	fee      uint64 // the network fee, in lamports
+	explorer string
+	swaps    []swapInspection
+}
+
+func (ti txInspection) String() string {
+	builder := &strings.Builder{}
+	t := table.NewWriter()
+	t.SetOutputMirror(builder)
+	t.SetTitle("Transaction")
+	t.AppendRow(table.Row{"Signature", ti.sig.String()})
+	t.AppendRow(table.Row{"Slot", ti.slot})
+	if !ti.landed.IsZero() {
+		t.AppendRow(table.Row{"Landed", ti.landed.UTC().Format(time.RFC3339)})
+	}
+	t.AppendRow(table.Row{"Network fee", formatLamports(ti.fee)})
+	t.AppendRow(table.Row{"Explorer", ti.explorer})
+	t.Render()
+	for _, s := range ti.swaps {
+		builder.WriteString("\n" + s.render())
+	}
+	return builder.String()
+}
+
+type txInspectionJSON struct {
+	Signature string               `json:"signature"`
+	Slot      uint64               `json:"slot"`
+	Landed    string               `json:"landed,omitempty"`
+	Fee       amountJSON           `json:"fee"`
+	Explorer  string               `json:"explorer"`
+	Swaps     []swapInspectionJSON `json:"swaps"`
+}
+
+func (ti txInspection) json() txInspectionJSON {
+	out := txInspectionJSON{
+		Signature: ti.sig.String(),
+		Slot:      ti.slot,
+		Fee:       newAmountJSON(new(big.Int).SetUint64(ti.fee), 9),
+		Explorer:  ti.explorer,
+		Swaps:     []swapInspectionJSON{},
+	}
+	if !ti.landed.IsZero() {
+		out.Landed = ti.landed.UTC().Format(time.RFC3339)
+	}
+	for _, s := range ti.swaps {
+		out.Swaps = append(out.Swaps, s.json())
+	}
+	return out
+}
+
+// inspectTransaction fetches sig and works out what every cp-swap swap in it filled at.
+func inspectTransaction(ctx context.Context, client *rpc.Client, network string, sig solana.Signature) (txInspection, error) {
+	maxVersion := uint64(0)
+	res, err := client.GetTransaction(ctx, sig, &rpc.GetTransactionOpts{
+		Encoding:                       solana.EncodingBase64,
+		Commitment:                     rpc.CommitmentConfirmed,
+		MaxSupportedTransactionVersion: &maxVersion,
+	})
+	if err != nil {
+		return txInspection{}, fmt.Errorf("rpc call getTransaction for %s failed: %w", sig, classifyRPC(err))
+	}
+	if res.Meta == nil {
+		return txInspection{}, fmt.Errorf("transaction %s has no metadata", sig)
+	}
+	if res.Meta.Err != nil {
+		return txInspection{}, fmt.Errorf("transaction %s failed on chain and filled nothing, `why %s` explains why", sig, sig)
+	}
+	swaps, err := inspectSwaps(res)
+	if err != nil {
+		return txInspection{}, fmt.Errorf("transaction %s: %w", sig, err)
+	}
+	if len(swaps) == 0 {
+		return txInspection{}, fmt.Errorf("transaction %s has no cp-swap swap instruction, a swap routed through an aggregator isn't one", sig)
+	}
+	ti := txInspection{sig: sig, slot: res.Slot, fee: res.Meta.Fee, explorer: explorerTxURL(network, sig), swaps: swaps}
+	if res.BlockTime != nil {
+		ti.landed = res.BlockTime.Time()
+	}
+
+	var mints []solana.PublicKey
+	pools := map[solana.PublicKey]*raydium_cp_swap.PoolState{}
+	configs := map[solana.PublicKey]*raydium_cp_swap.AmmConfig{}
+	for i := range ti.swaps {
+		s := &ti.swaps[i]
+		mints = append(mints, s.inMint, s.outMint)
+		pool, ok := pools[s.pool]
+		if !ok {
+			if pool, err = fetchPoolState(ctx, client, s.pool); err != nil {
+				return txInspection{}, err
+			}
+			pools[s.pool] = pool
+		}
+		cfg, ok := configs[pool.AmmConfig]
+		if !ok {
+			if cfg, err = fetchAmmConfig(ctx, client, pool.AmmConfig); err != nil {
+				return txInspection{}, err
+			}
+			configs[pool.AmmConfig] = cfg
+		}
+		s.tradeFeeRate = cfg.TradeFeeRate
+		s.inIsToken0 = pool.Token0Mint.Equals(s.inMint)
+	}
+	symm := makeSymbolMapping(ctx, client, mints)
+	for i := range ti.swaps {
+		ti.swaps[i].inSymbol, ti.swaps[i].outSymbol = symm.SymFrom(ti.swaps[i].inMint), symm.SymFrom(ti.swaps[i].outMint)
+	}
+	return ti, nil
+}
+
+func runInspectCommand(args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: inspect [flags] <signature>\n")
+		fs.PrintDefaults()
+	}
+	nf := addNetworkFlags(fs)
+	asJSON := fs.Bool("json", false, "Print the fills as JSON instead of tables")
+	addAmountFlags(fs)
+	// The signature reads naturally first, `inspect <sig> -network mainnet`, flag stops at the first argument.
+	var sigArg string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		sigArg, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, nf.specs())
+	if sigArg == "" && fs.NArg() > 0 {
+		sigArg = fs.Arg(0)
+	}
+	if sigArg == "" {
+		fs.Usage()
+		return errors.New("missing transaction signature")
+	}
+	sig, err := solana.SignatureFromBase58(sigArg)
+	if err != nil {
+		return fmt.Errorf("invalid signature %q: %w", sigArg, err)
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	ti, err := inspectTransaction(ctx, nf.connect(), *nf.network, sig)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(ti.json())
+	}
+	fmt.Print(ti.String())
+	return nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	computebudget "github.com/gagliardetto/solana-go/programs/compute-budget"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// inspectPool is one pool's side of an inspected transaction, its vaults' balances before and after.
+type inspectPool struct {
+	pool, inVault, outVault, inMint, outMint solana.PublicKey
+	decimals                                 [2]uint8
+	pre, post                                [2]string
+}
+
+func newInspectPool(inDecimals, outDecimals uint8, pre, post [2]string) inspectPool {
+	key := func() solana.PublicKey { return solana.NewWallet().PublicKey() }
+	return inspectPool{pool: key(), inVault: key(), outVault: key(), inMint: key(), outMint: key(), decimals: [2]uint8{inDecimals, outDecimals}, pre: pre, post: post}
+}
+
+func (p inspectPool) swap(t *testing.T, payer solana.PublicKey, baseInput bool, first, second uint64) solana.Instruction {
+	t.Helper()
+	key := solana.NewWallet().PublicKey()
+	build := raydium_cp_swap.NewSwapBaseInputInstruction
+	if !baseInput {
+		build = raydium_cp_swap.NewSwapBaseOutputInstruction
+	}
+	ix, err := build(first, second, payer, key, key, p.pool, key, key, p.inVault, p.outVault, key, key, p.inMint, p.outMint, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ix
+}
+
+func inspectResult(t *testing.T, payer solana.PublicKey, ixs []solana.Instruction, logs []string, pools ...inspectPool) *rpc.GetTransactionResult {
+	t.Helper()
+	tx, err := solana.NewTransaction(ixs, solana.Hash{1}, solana.TransactionPayer(payer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &rpc.TransactionResultEnvelope{}
+	if err := env.UnmarshalJSON(raw); err != nil {
+		t.Fatal(err)
+	}
+	index := func(key solana.PublicKey) int {
+		for i, k := range tx.Message.AccountKeys {
+			if k.Equals(key) {
+				return i
+			}
+		}
+		t.Fatalf("%s isn't in the transaction", key)
+		return -1
+	}
+	meta := &rpc.TransactionMeta{LogMessages: logs, Fee: 5000}
+	for _, p := range pools {
+		meta.PreTokenBalances = append(meta.PreTokenBalances,
+			makeTokenBalance(index(p.inVault), p.inMint, p.pre[0], p.decimals[0]), makeTokenBalance(index(p.outVault), p.outMint, p.pre[1], p.decimals[1]))
+		meta.PostTokenBalances = append(meta.PostTokenBalances,
+			makeTokenBalance(index(p.inVault), p.inMint, p.post[0], p.decimals[0]), makeTokenBalance(index(p.outVault), p.outMint, p.post[1], p.decimals[1]))
+	}
+	return &rpc.GetTransactionResult{Transaction: env, Meta: meta}
+}
+
+func TestInspectSwaps(t *testing.T) {
+	defer func(d amountDisplay) { display = d }(display)
+	display = amountDisplay{locale: numberLocales[0]}
+	payer := solana.NewWallet().PublicKey()
+	cpSwap := raydium_cp_swap.ProgramID.String()
+	// A sells 0.01 IN at a pool price of 2 OUT, its event says what it got. B buys 0.1 OUT for at most 0.11 IN and
+	// emits no event, the vaults say.
+	a := newInspectPool(6, 9, [2]string{"1000000", "2000000000"}, [2]string{"1010000", "1980300000"})
+	b := newInspectPool(6, 6, [2]string{"5000000", "5000000"}, [2]string{"5102000", "4900000"})
+	ev := raydium_cp_swap.SwapEvent{PoolId: a.pool, InputAmount: 10_000, OutputAmount: 19_700_000, BaseInput: true, TradeFee: 25}
+	logs := append(swapLogs(t, ev), "Program "+cpSwap+" invoke [1]", "Program "+cpSwap+" success")
+	res := inspectResult(t, payer, []solana.Instruction{
+		computebudget.NewSetComputeUnitLimitInstruction(400_000).Build(),
+		a.swap(t, payer, true, 10_000, 19_000_000),
+		b.swap(t, payer, false, 110_000, 100_000),
+	}, logs, a, b)
+
+	swaps, err := inspectSwaps(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(swaps) != 2 {
+		t.Fatalf("%d swaps, want 2", len(swaps))
+	}
+	sa, sb := swaps[0], swaps[1]
+	sa.inSymbol, sa.outSymbol, sa.tradeFeeRate, sa.inIsToken0 = "IN", "OUT", 2500, true
+	sb.inSymbol, sb.outSymbol, sb.tradeFeeRate = "IN", "OUT", 2500
+
+	if sa.instruction != 1 || !sa.fromEvent || sa.paid.Int64() != 10_000 || sa.received.Int64() != 19_700_000 {
+		t.Errorf("A: %+v", sa)
+	}
+	if got := sa.priceString(sa.realizedPrice()); got != "1.97" {
+		t.Errorf("A filled at %s, want 1.97", got)
+	}
+	if got := pctString(sa.impact()); got != "1.5%" {
+		t.Errorf("A impact %s, want 1.5%%", got)
+	}
+	if got := pctString(sa.guardRoom()); got != "3.68%" {
+		t.Errorf("A had %s to spare, want 3.68%%", got)
+	}
+	if fee, estimated := sa.fee(); fee.Int64() != 25 || estimated {
+		t.Errorf("A trade fee %s (estimated %v), want the event's 25", fee, estimated)
+	}
+
+	if sb.instruction != 2 || sb.fromEvent || sb.args.baseInput || sb.paid.Int64() != 102_000 || sb.received.Int64() != 100_000 {
+		t.Errorf("B: %+v", sb)
+	}
+	// 102000 * 0.25% is 255, rounded up like the program.
+	if fee, estimated := sb.fee(); fee.Int64() != 255 || !estimated {
+		t.Errorf("B trade fee %s (estimated %v), want 255 worked out from the rate", fee, estimated)
+	}
+	if got := pctString(sb.guardRoom()); got != "7.84%" {
+		t.Errorf("B had %s to spare, want 7.84%%", got)
+	}
+
+	report := sb.render()
+	for _, want := range []string{"swap_base_output", "the vault balances", "max pay 0.110000 IN, 7.84% to spare", "0.000255 IN (est. at 0.25%)", "1.96%"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("B's report is missing %q:\n%s", want, report)
+		}
+	}
+	// B's input is token 1, the table keeps the pool's order.
+	if strings.Index(report, "OUT") > strings.Index(report, " IN ") {
+		t.Errorf("B's columns aren't in pool order:\n%s", report)
+	}
+	js := sa.json()
+	if js.Price != "1.97" || js.Paid.Raw != "10000" || js.Received.Raw != "19700000" || js.TradeFeeEstimated || js.Guard.Raw != "19000000" {
+		t.Errorf("A as JSON: %+v", js)
+	}
+
+	// Truncated logs can't be trusted for A's event, the vaults are read instead.
+	res.Meta.LogMessages = append(logs[:4], "Log truncated")
+	if swaps, err := inspectSwaps(res); err != nil || swaps[0].fromEvent || swaps[0].received.Cmp(big.NewInt(19_700_000)) != 0 {
+		t.Errorf("truncated logs: %v, %+v", err, swaps)
+	}
+
+	none := inspectResult(t, payer, []solana.Instruction{computebudget.NewSetComputeUnitLimitInstruction(400_000).Build()}, nil)
+	if swaps, err := inspectSwaps(none); err != nil || len(swaps) != 0 {
+		t.Errorf("a transaction without a swap: %v, %+v", err, swaps)
+	}
+}
+
+func TestInspectAggregatorSwaps(t *testing.T) {
+	payer := solana.NewWallet().PublicKey()
+	cpSwap := raydium_cp_swap.ProgramID.String()
+	a := newInspectPool(6, 9, [2]string{"1000000", "2000000000"}, [2]string{"1010000", "1980300000"})
+	ev := raydium_cp_swap.SwapEvent{PoolId: a.pool, InputAmount: 10_000, OutputAmount: 19_700_000, BaseInput: true, TradeFee: 25}
+	// A router's instruction (the compute budget program standing in for one) calls into cp-swap.
+	logs := []string{
+		"Program ComputeBudget111111111111111111111111111111 invoke [1]",
+		"Program ComputeBudget111111111111111111111111111111 success",
+		"Program ComputeBudget111111111111111111111111111111 invoke [1]",
+		"Program " + cpSwap + " invoke [2]",
+		programDataLine(t, ev),
+		"Program " + cpSwap + " success",
+		"Program ComputeBudget111111111111111111111111111111 success",
+	}
+	res := inspectResult(t, payer, []solana.Instruction{
+		computebudget.NewSetComputeUnitLimitInstruction(400_000).Build(),
+		a.swap(t, payer, true, 10_000, 19_000_000),
+	}, logs, a)
+	tx, err := res.Transaction.GetTransaction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	swap := tx.Message.Instructions[1]
+	tx.Message.Instructions[1] = solana.CompiledInstruction{ProgramIDIndex: tx.Message.Instructions[0].ProgramIDIndex, Accounts: swap.Accounts}
+	res.Meta.InnerInstructions = []rpc.InnerInstruction{{Index: 1, Instructions: []solana.CompiledInstruction{swap}}}
+	raw, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Transaction = &rpc.TransactionResultEnvelope{}
+	if err := res.Transaction.UnmarshalJSON(raw); err != nil {
+		t.Fatal(err)
+	}
+
+	swaps, err := inspectSwaps(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(swaps) != 1 {
+		t.Fatalf("%d swaps, want the one made through the router", len(swaps))
+	}
+	s := swaps[0]
+	if s.instruction != 1 || s.inner != 0 || !s.fromEvent || s.paid.Int64() != 10_000 || s.received.Int64() != 19_700_000 || !s.pool.Equals(a.pool) {
+		t.Errorf("routed swap: %+v", s)
+	}
+	if js := s.json(); js.Inner == nil || *js.Inner != 0 {
+		t.Errorf("routed swap as JSON has inner %v", js.Inner)
+	}
+	if report := s.render(); !strings.Contains(report, "through an aggregator (inner #0)") {
+		t.Errorf("the report doesn't say it was routed:\n%s", report)
+	}
+}
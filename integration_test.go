@@ -0,0 +1,252 @@
+//go:build integration
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math/big"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	atapkg "github.com/gagliardetto/solana-go/programs/associated-token-account"
+	computebudget "github.com/gagliardetto/solana-go/programs/compute-budget"
+	"github.com/gagliardetto/solana-go/programs/system"
+	tokenprog "github.com/gagliardetto/solana-go/programs/token"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): Integration tests.
+
+These run the real code path against a real cp-swap program: a fresh pool between two throwaway mints is created, and
+buy and sell intents go through openPool, quote and executeIntent exactly like `-no-tui` sends them. What the chain
+says was paid and received has to match the quote, within the pool's trade fee. Nothing else trades on the pool, so any
+bigger gap is the quoting being wrong, not the market moving.
+
+They're behind the integration build tag and need a node, see contribute.md for starting solana-test-validator with
+the devnet program cloned into it. The environment:
+
+	RAYDIUM_IT_RPC                RPC to use, the local validator by default
+	RAYDIUM_IT_PROGRAM            cp-swap program id, the devnet deployment by default (that's what gets cloned)
+	RAYDIUM_IT_AMM_CONFIG_INDEX   which AmmConfig the pool is created under, 0 by default
+	RAYDIUM_IT_POOL_FEE_RECEIVER  the create pool fee receiver the program was built with, required
+	RAYDIUM_IT_KEYPAIR            a funded keypair file, without it a throwaway key is airdropped SOL
+
+On devnet proper, pass a funded RAYDIUM_IT_KEYPAIR, the faucet rarely hands out enough for a pool.
+*/
+
+const itDecimals = 6
+
+type itEnv struct {
+	client   *rpc.Client
+	payer    solana.PrivateKey
+	config   solana.PublicKey
+	feeRecv  solana.PublicKey
+	deadline time.Duration
+}
+
+func integrationEnv(t *testing.T) *itEnv {
+	t.Helper()
+	feeRecv := os.Getenv("RAYDIUM_IT_POOL_FEE_RECEIVER")
+	if feeRecv == "" {
+		t.Skip("RAYDIUM_IT_POOL_FEE_RECEIVER isn't set, see contribute.md for running the integration tests")
+	}
+	env := &itEnv{feeRecv: solana.MustPublicKeyFromBase58(feeRecv), deadline: 2 * time.Minute}
+	endpoint := os.Getenv("RAYDIUM_IT_RPC")
+	if endpoint == "" {
+		endpoint = rpc.LocalNet_RPC
+	}
+	env.client = rpc.New(endpoint)
+	raydium_cp_swap.ProgramID = networks["devnet"][RaydiumProgramID].(solana.PublicKey)
+	if program := os.Getenv("RAYDIUM_IT_PROGRAM"); program != "" {
+		raydium_cp_swap.ProgramID = solana.MustPublicKeyFromBase58(program)
+	}
+	index := uint64(0)
+	if raw := os.Getenv("RAYDIUM_IT_AMM_CONFIG_INDEX"); raw != "" {
+		var err error
+		if index, err = strconv.ParseUint(raw, 10, 16); err != nil {
+			t.Fatalf("RAYDIUM_IT_AMM_CONFIG_INDEX: %v", err)
+		}
+	}
+	env.config = itPDA(t, []byte("amm_config"), binary.BigEndian.AppendUint16(nil, uint16(index)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), env.deadline)
+	defer cancel()
+	if path := os.Getenv("RAYDIUM_IT_KEYPAIR"); path != "" {
+		key, err := solana.PrivateKeyFromSolanaKeygenFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		env.payer = key
+	} else {
+		env.payer = solana.NewWallet().PrivateKey
+		sig, err := env.client.RequestAirdrop(ctx, env.payer.PublicKey(), 10*solana.LAMPORTS_PER_SOL, rpc.CommitmentConfirmed)
+		if err != nil {
+			t.Fatalf("airdrop failed, is the validator running? %v", err)
+		}
+		itAwait(ctx, t, env.client, sig)
+	}
+	return env
+}
+
+func itPDA(t *testing.T, seeds ...[]byte) solana.PublicKey {
+	t.Helper()
+	pda, _, err := solana.FindProgramAddress(seeds, raydium_cp_swap.ProgramID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pda
+}
+
+// itAwait waits for sig to land and fails the test if it failed.
+func itAwait(ctx context.Context, t *testing.T, client *rpc.Client, sig solana.Signature) {
+	t.Helper()
+	status, result, err := waitForTransactionResult(ctx, client, sig)
+	if err != nil {
+		t.Fatalf("waiting on %s: %v", sig, err)
+	}
+	if result == nil || result.Meta == nil || result.Meta.Err != nil {
+		t.Fatalf("transaction %s %s: %+v", sig, status, result)
+	}
+}
+
+// itSend sends ixs paid by the env's payer, signed by it and signers, and waits for them to land.
+func (env *itEnv) send(ctx context.Context, t *testing.T, ixs []solana.Instruction, signers ...solana.PrivateKey) {
+	t.Helper()
+	tx, err := unsignedTransaction(ctx, env.client, env.payer.PublicKey(), ixs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signers = append(signers, env.payer)
+	if _, err := tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
+		for i := range signers {
+			if signers[i].PublicKey().Equals(key) {
+				return &signers[i]
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sig, err := sendTransaction(ctx, env.client, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	itAwait(ctx, t, env.client, sig)
+}
+
+// mint creates a mint and credits supply of it to the payer.
+func (env *itEnv) mint(ctx context.Context, t *testing.T, supply uint64) solana.PublicKey {
+	t.Helper()
+	mint := solana.NewWallet().PrivateKey
+	payer := env.payer.PublicKey()
+	rent, err := env.client.GetMinimumBalanceForRentExemption(ctx, tokenprog.MINT_SIZE, rpc.CommitmentConfirmed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ata, _, err := solana.FindAssociatedTokenAddress(payer, mint.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.send(ctx, t, []solana.Instruction{
+		system.NewCreateAccountInstruction(rent, tokenprog.MINT_SIZE, solana.TokenProgramID, payer, mint.PublicKey()).Build(),
+		tokenprog.NewInitializeMint2InstructionBuilder().SetDecimals(itDecimals).SetMintAuthority(payer).SetMintAccount(mint.PublicKey()).Build(),
+		atapkg.NewCreateInstruction(payer, payer, mint.PublicKey()).Build(),
+		tokenprog.NewMintToInstruction(supply, mint.PublicKey(), ata, payer, nil).Build(),
+	}, mint)
+	return mint.PublicKey()
+}
+
+// createPool opens a pool between two fresh mints, seeded with reserve0 and reserve1, and waits for it to open.
+func (env *itEnv) createPool(ctx context.Context, t *testing.T, reserve0, reserve1 uint64) (solana.PublicKey, [2]solana.PublicKey) {
+	t.Helper()
+	a, b := env.mint(ctx, t, 10*reserve0), env.mint(ctx, t, 10*reserve1)
+	// The program wants token0 below token1.
+	if bytes.Compare(a[:], b[:]) > 0 {
+		a, b = b, a
+		reserve0, reserve1 = reserve1, reserve0
+	}
+	payer := env.payer.PublicKey()
+	pool := itPDA(t, []byte("pool"), env.config[:], a[:], b[:])
+	lpMint := itPDA(t, []byte("pool_lp_mint"), pool[:])
+	auth, err := swapAuthority()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ata := func(mint solana.PublicKey) solana.PublicKey {
+		addr, _, err := solana.FindAssociatedTokenAddress(payer, mint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return addr
+	}
+	ix, err := raydium_cp_swap.NewInitializeInstruction(reserve0, reserve1, 0,
+		payer, env.config, auth, pool, a, b, lpMint, ata(a), ata(b), ata(lpMint),
+		itPDA(t, []byte("pool_vault"), pool[:], a[:]), itPDA(t, []byte("pool_vault"), pool[:], b[:]),
+		env.feeRecv, itPDA(t, []byte("observation"), pool[:]),
+		solana.TokenProgramID, solana.TokenProgramID, solana.TokenProgramID,
+		solana.SPLAssociatedTokenAccountProgramID, solana.SystemProgramID, solana.SysVarRentPubkey,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.send(ctx, t, []solana.Instruction{computebudget.NewSetComputeUnitLimitInstruction(400_000).Build(), ix})
+	// A pool opens the second after it's created at the earliest.
+	time.Sleep(2 * time.Second)
+	return pool, [2]solana.PublicKey{a, b}
+}
+
+func TestIntegrationSwapRealizesQuote(t *testing.T) {
+	env := integrationEnv(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 4*env.deadline)
+	defer cancel()
+	pool, mints := env.createPool(ctx, t, 1_000_000*1e6, 2_000_000*1e6)
+	userAliases = &symbolAliases{bySymbol: map[string]string{"ITA": mints[0].String(), "ITB": mints[1].String()}}
+	t.Cleanup(func() { userAliases = &symbolAliases{bySymbol: map[string]string{}} })
+
+	for _, intentLine := range []string{"sell 1000 ITA", "buy 500 ITA", "pay 2500 ITB", "receive 750 ITB"} {
+		t.Run(intentLine, func(t *testing.T) {
+			flow := &swapFlow{client: env.client, payer: env.payer, network: "devnet", intentLine: intentLine, slippagePct: 1, out: &bytes.Buffer{}}
+			builder, _, err := flow.openPool(ctx, pool.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, intent, err := flow.quote(builder, func(symbol, mint string) (bool, error) {
+				t.Fatalf("asked to map %s, it's aliased", symbol)
+				return false, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			summary, sig, err := executeIntent(ctx, env.client, env.payer, builder, intent)
+			if err != nil {
+				t.Fatalf("swap %s failed: %v", sig, err)
+			}
+			if summary.PaidAmount == nil || summary.ReceivedAmount == nil {
+				t.Fatalf("swap %s: no realized amounts in %+v", sig, summary)
+			}
+
+			// The known side is exact, the quoted side within the trade fee of the quote.
+			known, quoted := summary.PaidAmount, summary.ReceivedAmount
+			if intent.SwapKind == SwapKindBaseOutput {
+				known, quoted = quoted, known
+			}
+			if known.Cmp(intent.Amounts.KnownAmount) != 0 {
+				t.Errorf("swap %s moved %s of the known side, the intent was for %s", sig, known, intent.Amounts.KnownAmount)
+			}
+			tolerance := new(big.Int).Mul(intent.Amounts.QuoteAmount, new(big.Int).SetUint64(builder.snapshot().venue.FeeRate()))
+			tolerance.Quo(tolerance, big.NewInt(feeRateDenom))
+			tolerance.Add(tolerance, big.NewInt(1))
+			if gap := new(big.Int).Sub(quoted, intent.Amounts.QuoteAmount); gap.Abs(gap).Cmp(tolerance) > 0 {
+				t.Errorf("swap %s realized %s against a quote of %s, off by more than %s", sig, quoted, intent.Amounts.QuoteAmount, tolerance)
+			}
+		})
+	}
+}
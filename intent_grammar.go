@@ -0,0 +1,393 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+/*
+NOTE(@hadydotai): Intent grammar.
+
+The first grammar was `<verb> <amount> <symbol>` split on whitespace, which is all the math needs but not how people
+talk about swaps. v2 keeps every v1 intent working and adds:
+
+	swap 2 SOL for USDC              sell exactly 2 SOL, the for names the token you get
+	buy 50 USDC with SOL             buy exactly 50 USDC, the with names the token you pay in
+	buy USDC with 1 SOL              spend exactly 1 SOL on USDC, an exact input swap even though it says buy
+	pay 1 SOL at >= 150 USDC         only if 1 SOL goes for at least 150 USDC
+	sell 50% SOL, sell all SOL       half, or all, of the wallet's SOL, see wallet_amounts.go
+	buy $50 of BONK                  spend $50 worth of the other token on BONK, see price_source.go
+
+Wherever a symbol goes, the mint can go instead, its whole address or an unambiguous prefix of it (mintPrefixMin
+characters or more, any case), for pools whose tokens have no symbol or share one.
+
+The token after for/with is checked against the pool, naming a token the pool doesn't trade is an error rather than
+being quietly ignored. The at clause is a limit on the price of the token the amount is in (the target), in units of
+the other token, the same price limit orders use. It's checked against the quote, and the slippage guard is tightened
+so the worst fill the program accepts still honours it. A limit order's `when` waits for the price, `at` doesn't, an
+intent whose price doesn't hold right now simply isn't sent.
+
+Errors point at the token that broke the parse, by column and by marking it in the intent, e.g.
+`expected "for", "with", "at" or the end of the intent, got "to" at column 12: swap 2 SOL >>to<< USDC`.
+
+	intent := verb amount SYM [("for" | "with") SYM] [at]
+	        | buy-verb SYM "with" amount SYM [at]
+	amount := decimal | decimal "%" | "all" | "$" decimal ["of"]
+	at     := "at" op price [SYM]
+*/
+
+type intentTokenKind uint8
+
+const (
+	intentTokenWord intentTokenKind = iota
+	intentTokenNumber
+	intentTokenOp
+)
+
+type intentToken struct {
+	kind intentTokenKind
+	text string
+	pos  int // byte offset into the intent
+}
+
+// intentSyntaxError points at the token an intent stopped making sense at. A nil token means the intent ended early.
+type intentSyntaxError struct {
+	line string
+	tok  *intentToken
+	msg  string
+}
+
+func (e *intentSyntaxError) Error() string {
+	line := strings.TrimRight(e.line, " \t")
+	if e.tok == nil {
+		return fmt.Sprintf("%s at the end of the intent: %s >>_<<", e.msg, strings.TrimSpace(line))
+	}
+	end := e.tok.pos + len(e.tok.text)
+	marked := strings.TrimLeft(line[:e.tok.pos], " \t") + ">>" + line[e.tok.pos:end] + "<<" + line[end:]
+	return fmt.Sprintf("%s, got %q at column %d: %s", e.msg, e.tok.text, e.tok.pos+1, marked)
+}
+
+func isIntentOpRune(r rune) bool {
+	return r == '<' || r == '>' || r == '='
+}
+
+// tokenizeIntent splits on whitespace, and around comparison operators so `at<=150` reads like `at <= 150`.
+func tokenizeIntent(line string) []intentToken {
+	var toks []intentToken
+	runes := []rune(line)
+	offset := func(i int) int { return len(string(runes[:i])) }
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case isIntentOpRune(r):
+			j := i
+			for j < len(runes) && isIntentOpRune(runes[j]) {
+				j++
+			}
+			toks = append(toks, intentToken{kind: intentTokenOp, text: string(runes[i:j]), pos: offset(i)})
+			i = j
+		default:
+			j := i
+			for j < len(runes) && !unicode.IsSpace(runes[j]) && !isIntentOpRune(runes[j]) {
+				j++
+			}
+			kind := intentTokenWord
+			if unicode.IsDigit(r) || r == '.' {
+				kind = intentTokenNumber
+			}
+			toks = append(toks, intentToken{kind: kind, text: string(runes[i:j]), pos: offset(i)})
+			i = j
+		}
+	}
+	return toks
+}
+
+type intentParser struct {
+	line string
+	toks []intentToken
+	next int
+}
+
+func (p *intentParser) peek() *intentToken {
+	if p.next >= len(p.toks) {
+		return nil
+	}
+	return &p.toks[p.next]
+}
+
+func (p *intentParser) fail(tok *intentToken, format string, args ...any) error {
+	return &intentSyntaxError{line: p.line, tok: tok, msg: fmt.Sprintf(format, args...)}
+}
+
+// keyword consumes the next token when it's the given word, in any case.
+func (p *intentParser) keyword(word string) bool {
+	if tok := p.peek(); tok != nil && tok.kind == intentTokenWord && strings.EqualFold(tok.text, word) {
+		p.next++
+		return true
+	}
+	return false
+}
+
+// amount reads the amount into ii: an absolute one, one relative to the wallet's balance ("50%", "all") as a
+// fraction of it, or one in dollars ("$50", "$50 of").
+func (p *intentParser) amount(ii *IntentInstruction) error {
+	tok := p.peek()
+	if tok != nil && strings.HasPrefix(tok.text, "$") {
+		usd, ok := new(big.Rat).SetString(tok.text[1:])
+		if !ok || usd.Sign() <= 0 {
+			return p.fail(tok, "a dollar amount must be a positive decimal number, like $50")
+		}
+		p.next++
+		p.keyword("of")
+		ii.AmountStr, ii.AmountUSD = tok.text, usd
+		return nil
+	}
+	if tok != nil && (tok.kind == intentTokenNumber || strings.EqualFold(tok.text, "all")) {
+		frac, isPct, err := parsePercentAmount(tok.text)
+		if err != nil {
+			return p.fail(tok, "%v", err)
+		}
+		if isPct {
+			p.next++
+			ii.AmountStr, ii.AmountPct = strings.ToLower(tok.text), frac
+			return nil
+		}
+	}
+	if tok == nil || tok.kind != intentTokenNumber {
+		return p.fail(tok, "expected an amount")
+	}
+	if v, ok := new(big.Rat).SetString(tok.text); !ok || v.Sign() <= 0 {
+		return p.fail(tok, "amount must be a positive decimal number, a percentage like 50%% or all, or dollars like $50")
+	}
+	p.next++
+	ii.AmountStr = tok.text
+	return nil
+}
+
+func (p *intentParser) symbol(what string) (string, error) {
+	tok := p.peek()
+	if tok == nil || tok.kind != intentTokenWord || isIntentKeyword(tok.text) {
+		return "", p.fail(tok, "expected %s", what)
+	}
+	p.next++
+	return intentSymbol(tok.text), nil
+}
+
+// intentSymbol is a token as an intent names it, a symbol upper cased or a mint address as typed, base58 is case
+// sensitive.
+func intentSymbol(text string) string {
+	if _, err := solana.PublicKeyFromBase58(text); err == nil {
+		return text
+	}
+	return strings.ToUpper(text)
+}
+
+func isIntentKeyword(word string) bool {
+	switch strings.ToLower(word) {
+	case "for", "with", "at", "all", "of":
+		return true
+	}
+	return false
+}
+
+// condition parses what follows "at": <op> <price> [<symbol>].
+func (p *intentParser) condition() (*limitCondition, error) {
+	tok := p.peek()
+	if tok == nil || tok.kind != intentTokenOp {
+		return nil, p.fail(tok, "expected one of <=, >=, <, > after at")
+	}
+	switch tok.text {
+	case "<=", ">=", "<", ">":
+	default:
+		return nil, p.fail(tok, "operator isn't supported, use one of <=, >=, <, >")
+	}
+	p.next++
+	cond := &limitCondition{op: tok.text}
+	tok = p.peek()
+	if tok == nil || tok.kind != intentTokenNumber {
+		return nil, p.fail(tok, "expected a price")
+	}
+	price, ok := new(big.Rat).SetString(tok.text)
+	if !ok || price.Sign() <= 0 {
+		return nil, p.fail(tok, "price must be a positive decimal number")
+	}
+	p.next++
+	cond.price = price
+	if tok := p.peek(); tok != nil && tok.kind == intentTokenWord {
+		cond.unit = intentSymbol(tok.text)
+		p.next++
+	}
+	return cond, nil
+}
+
+func (p *intentParser) parse() (*IntentInstruction, error) {
+	verbTok := p.peek()
+	if verbTok == nil {
+		return nil, p.fail(nil, "intent is empty, expected <verb> <amount> <token-symbol>")
+	}
+	verb := strings.ToLower(verbTok.text)
+	dir, err := verbToSwapDir(verb)
+	if err != nil || verbTok.kind != intentTokenWord {
+		return nil, p.fail(verbTok, "expected a verb, one of pay, sell, swap, buy, get")
+	}
+	p.next++
+	ii := &IntentInstruction{Verb: verb, Dir: dir}
+
+	if tok := p.peek(); dir == SwapDirBuy && tok != nil && tok.kind == intentTokenWord && !isIntentKeyword(tok.text) && !strings.HasPrefix(tok.text, "$") {
+		// NOTE(@hadydotai): `buy USDC with 1 SOL` fixes what's paid, not what's bought, so it's an exact input swap of
+		// the token after with. The verb stays as typed, it's what the user reads back.
+		if ii.CounterSymbol, err = p.symbol("the token to buy"); err != nil {
+			return nil, err
+		}
+		if !p.keyword("with") {
+			return nil, p.fail(p.peek(), `expected "with" and the amount to spend`)
+		}
+		if err = p.amount(ii); err != nil {
+			return nil, err
+		}
+		if ii.TargetSymbol, err = p.symbol("the token to pay with"); err != nil {
+			return nil, err
+		}
+		ii.Dir = SwapDirSell
+	} else {
+		if err = p.amount(ii); err != nil {
+			return nil, err
+		}
+		amountTok := &p.toks[p.next-1]
+		if ii.AmountUSD != nil && strings.EqualFold(amountTok.text, "of") {
+			amountTok = &p.toks[p.next-2]
+		}
+		if ii.TargetSymbol, err = p.symbol("a token symbol"); err != nil {
+			return nil, err
+		}
+		if ii.AmountPct != nil && dir != SwapDirSell {
+			return nil, p.fail(amountTok, "%s can only be of what you pay, try `%s %s with %s <token>`", ii.AmountStr, verb, ii.TargetSymbol, ii.AmountStr)
+		}
+		switch {
+		case p.keyword("for"):
+			if dir != SwapDirSell {
+				return nil, p.fail(&p.toks[p.next-1], `%s names the token it gets, use "with" for the one it pays in`, verb)
+			}
+			if ii.CounterSymbol, err = p.symbol("the token to get"); err != nil {
+				return nil, err
+			}
+		case p.keyword("with"):
+			if dir != SwapDirBuy {
+				return nil, p.fail(&p.toks[p.next-1], `%s names the token it pays, use "for" for the one it gets`, verb)
+			}
+			if ii.CounterSymbol, err = p.symbol("the token to pay with"); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if ii.CounterSymbol != "" && ii.CounterSymbol == ii.TargetSymbol {
+		return nil, p.fail(&p.toks[p.next-1], "can't swap a token for itself")
+	}
+	if p.keyword("at") {
+		if ii.AmountUSD != nil && ii.Dir == SwapDirBuy {
+			// The dollars are spent on the other token, an at clause on the price of the one bought would be checked
+			// against a swap that's no longer about it.
+			return nil, p.fail(&p.toks[p.next-1], "a price limit on a dollar amount only works when paying, try `buy %s with %s of <token> at ...`", ii.TargetSymbol, ii.AmountStr)
+		}
+		if ii.Condition, err = p.condition(); err != nil {
+			return nil, err
+		}
+	}
+	if tok := p.peek(); tok != nil {
+		expected := `expected the end of the intent`
+		switch {
+		case ii.Condition != nil:
+		case ii.CounterSymbol != "":
+			expected = `expected "at" or the end of the intent`
+		default:
+			expected = `expected "for", "with", "at" or the end of the intent`
+		}
+		return nil, p.fail(tok, "%s", expected)
+	}
+	return ii, nil
+}
+
+func parseIntent(intentLine string) (*IntentInstruction, error) {
+	p := &intentParser{line: intentLine, toks: tokenizeIntent(intentLine)}
+	return p.parse()
+}
+
+// checkCounter makes sure the token named after for/with is the other side of the pool.
+func (ii *IntentInstruction) checkCounter(counterMint solana.PublicKey, symm SymbolMapping) error {
+	if ii.CounterSymbol == "" {
+		return nil
+	}
+	mint, ok := symm.MaybeMintFromToken(ii.CounterSymbol)
+	if !ok || !mint.Equals(counterMint) {
+		return fmt.Errorf("%s isn't traded against %s in this pool, the other token is %s", ii.CounterSymbol, ii.TargetSymbol, symm.SymFrom(counterMint))
+	}
+	return nil
+}
+
+// applyCondition checks the intent's at clause against the quote, and tightens the slippage guard to its price.
+func (ii *IntentInstruction) applyCondition(intent *CPIntent, symm SymbolMapping) error {
+	cond := ii.Condition
+	if cond == nil {
+		return nil
+	}
+	counterSym := symm.SymFrom(intent.CounterLeg().Mint)
+	if cond.unit != "" && !symm.namesMint(cond.unit, intent.CounterLeg().Mint) {
+		return fmt.Errorf("the price is in %s, but %s is priced in %s on this pool", cond.unit, ii.TargetSymbol, counterSym)
+	}
+	price := targetPrice(intent)
+	if !cond.holds(price) {
+		current := "unknown"
+		if price != nil {
+			current = price.FloatString(int(intent.CounterLeg().Decimals))
+		}
+		return fmt.Errorf("%s is at %s %s, the intent needs it at %s %s", ii.TargetSymbol, current, counterSym, cond.op, cond.price.FloatString(int(intent.CounterLeg().Decimals)))
+	}
+	clampToLimit(intent, *cond)
+	return nil
+}
+
+// flipIntent is line the other way round, buying what it sold or selling what it bought, the same amount of the same
+// token. An at clause is dropped, a limit set for one side of the trade means nothing for the other.
+func flipIntent(line string) (string, error) {
+	ii, err := parseIntent(line)
+	if err != nil {
+		return "", err
+	}
+	if ii.AmountPct != nil {
+		return "", fmt.Errorf("%s is of the wallet's balance, it can only be sold", ii.AmountStr)
+	}
+	flipped := *ii
+	flipped.Condition = nil
+	if ii.Dir == SwapDirSell {
+		flipped.Verb, flipped.Dir = "buy", SwapDirBuy
+	} else {
+		flipped.Verb, flipped.Dir = "sell", SwapDirSell
+	}
+	return flipped.String(), nil
+}
+
+// retargetIntent is line with its amount in other, the pool's other token, and the token it was in taking other's
+// place when the intent named it. An at clause is dropped, it was a price of the token the amount was in.
+func retargetIntent(line, other string) (string, error) {
+	ii, err := parseIntent(line)
+	if err != nil {
+		return "", err
+	}
+	other = intentSymbol(other)
+	if other == ii.TargetSymbol {
+		return "", fmt.Errorf("the amount is already in %s", other)
+	}
+	retargeted := *ii
+	retargeted.Condition = nil
+	retargeted.TargetSymbol = other
+	if ii.CounterSymbol != "" {
+		retargeted.CounterSymbol = ii.TargetSymbol
+	}
+	return retargeted.String(), nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseIntentV2(t *testing.T) {
+	tests := []struct {
+		line    string
+		dir     SwapDir
+		amount  string
+		target  string
+		counter string
+		op      string
+		unit    string
+		str     string
+	}{
+		{line: "pay 1 sol", dir: SwapDirSell, amount: "1", target: "SOL", str: "pay 1 SOL"},
+		{line: "swap 2 SOL for usdc", dir: SwapDirSell, amount: "2", target: "SOL", counter: "USDC", str: "swap 2 SOL for USDC"},
+		{line: "buy 50 USDC with SOL", dir: SwapDirBuy, amount: "50", target: "USDC", counter: "SOL", str: "buy 50 USDC with SOL"},
+		{line: "Buy usdc WITH 1 sol", dir: SwapDirSell, amount: "1", target: "SOL", counter: "USDC", str: "buy USDC with 1 SOL"},
+		{line: "pay 1 SOL at >= 150 usdc", dir: SwapDirSell, amount: "1", target: "SOL", op: ">=", unit: "USDC", str: "pay 1 SOL at >= 150 USDC"},
+		{line: "swap 2 SOL for USDC at>=149.5", dir: SwapDirSell, amount: "2", target: "SOL", counter: "USDC", op: ">=", str: "swap 2 SOL for USDC at >= 149.5"},
+		{line: "get 10 USDC at<0.007", dir: SwapDirBuy, amount: "10", target: "USDC", op: "<", str: "get 10 USDC at < 0.007"},
+	}
+	for _, tc := range tests {
+		ii, err := parseIntent(tc.line)
+		if err != nil {
+			t.Errorf("%q: %v", tc.line, err)
+			continue
+		}
+		if ii.Dir != tc.dir || ii.AmountStr != tc.amount || ii.TargetSymbol != tc.target || ii.CounterSymbol != tc.counter {
+			t.Errorf("%q: parsed %+v", tc.line, ii)
+		}
+		if (ii.Condition == nil) != (tc.op == "") || (ii.Condition != nil && (ii.Condition.op != tc.op || ii.Condition.unit != tc.unit)) {
+			t.Errorf("%q: condition %+v", tc.line, ii.Condition)
+		}
+		if got := ii.String(); got != tc.str {
+			t.Errorf("%q: String() = %q, want %q", tc.line, got, tc.str)
+		}
+		// What String renders parses back to the same thing, fallback pools and receipts rely on it.
+		again, err := parseIntent(ii.String())
+		if err != nil || again.String() != ii.String() {
+			t.Errorf("%q: round trip gave %v, %v", tc.line, again, err)
+		}
+	}
+}
+
+func TestParseIntentErrors(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"", "intent is empty"},
+		{"swap 2 SOL to USDC", `expected "for", "with", "at" or the end of the intent, got "to" at column 12: swap 2 SOL >>to<< USDC`},
+		{"pay SOL", `expected an amount, got "SOL" at column 5`},
+		{"pay -1 SOL", `expected an amount, got "-1"`},
+		{"pay 0 SOL", "amount must be a positive decimal number"},
+		{"pay 1", "expected a token symbol at the end of the intent: pay 1 >>_<<"},
+		{"trade 1 SOL", `expected a verb, one of pay, sell, swap, buy, get, got "trade" at column 1`},
+		{"buy 5 USDC for SOL", `buy names the token it gets, use "with" for the one it pays in`},
+		{"sell 5 SOL with USDC", `sell names the token it pays, use "for" for the one it gets`},
+		{"buy USDC for 1 SOL", `expected "with" and the amount to spend, got "for"`},
+		{"swap 1 SOL for SOL", "can't swap a token for itself"},
+		{"pay 1 SOL at == 150", `operator isn't supported`},
+		{"pay 1 SOL at >= cheap", `expected a price, got "cheap"`},
+		{"pay 1 SOL at >= 150 USDC now", `expected the end of the intent, got "now" at column 26`},
+	}
+	for _, tc := range tests {
+		_, err := parseIntent(tc.line)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%q: got %v, want it to contain %q", tc.line, err, tc.want)
+		}
+	}
+}
+
+func TestBuildIntentV2(t *testing.T) {
+	api, addr := newTestAPIServer(t)
+	quote := func(line string) (*CPIntent, error) {
+		_, intent, _, err := api.quote(context.Background(), quoteRequest{Pool: addr.String(), Intent: line})
+		return intent, err
+	}
+
+	plain, err := quote("pay 1 SOL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	worded, err := quote("buy USDC with 1 SOL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if worded.SwapKind != SwapKindBaseInput || worded.Amounts.QuoteAmount.Cmp(plain.Amounts.QuoteAmount) != 0 {
+		t.Errorf("buy USDC with 1 SOL should quote like pay 1 SOL, got %v", worded.Amounts.QuoteAmount)
+	}
+	if _, err := quote("swap 1 SOL for BONK"); err == nil || !strings.Contains(err.Error(), "BONK isn't traded against SOL") {
+		t.Errorf("want the counter token checked against the pool, got %v", err)
+	}
+
+	// 1 SOL goes for about 149.1 USDC after the fee, the at clause passes or fails on that.
+	held, err := quote("pay 1 SOL at >= 149 USDC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	floor := rawCounterAtLimit(held, held.Instruction.Condition.price, true)
+	if held.Amounts.MinAmountOut.Cmp(floor) < 0 {
+		t.Errorf("min out %v should be clamped to the at price %v", held.Amounts.MinAmountOut, floor)
+	}
+	// A price that doesn't hold shows up in the quote table, with no intent to send.
+	tb, err := newTableBuilder(context.Background(), api.client, api.pools[addr].loaded, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for line, want := range map[string]string{
+		"pay 1 SOL at >= 155":     "needs it at >= 155",
+		"pay 1 SOL at >= 149 SOL": "priced in USDC",
+	} {
+		report, intent, err := tb.Build(line)
+		if !errors.As(err, new(*unquotableError)) || intent != nil || !strings.Contains(report, want) {
+			t.Errorf("%q: got intent %v, err %v, report\n%s", line, intent, err, report)
+		}
+	}
+
+	// Mints can stand in for symbols, whole or as a prefix, in any case.
+	sol, usdc := tb.symbols().MintFromSym("SOL").String(), tb.symbols().MintFromSym("USDC").String()
+	for _, line := range []string{
+		"pay 1 " + sol,
+		"swap 1 SOL for " + strings.ToLower(usdc[:6]),
+		"pay 1 " + sol[:5] + " at >= 149 " + usdc,
+	} {
+		byMint, err := quote(line)
+		if err != nil || byMint.Amounts.QuoteAmount.Cmp(plain.Amounts.QuoteAmount) != 0 {
+			t.Errorf("%q: quoted %v, %v", line, byMint, err)
+		}
+	}
+}
+
+func TestFlipAndRetargetIntent(t *testing.T) {
+	flips := map[string]string{
+		"pay 1 SOL":                "buy 1 SOL",
+		"swap 2 SOL for USDC":      "buy 2 SOL with USDC",
+		"buy 50 USDC with SOL":     "sell 50 USDC for SOL",
+		"buy USDC with 1 SOL":      "buy 1 SOL with USDC",
+		"pay 1 SOL at >= 150 USDC": "buy 1 SOL",
+		"buy $50 of BONK":          "sell $50 of BONK",
+	}
+	for line, want := range flips {
+		got, err := flipIntent(line)
+		if err != nil || got != want {
+			t.Errorf("flip %q = %q, %v, want %q", line, got, err, want)
+		}
+	}
+	if _, err := flipIntent("sell 50% SOL"); err == nil {
+		t.Error("a percentage of the wallet flipped to a buy")
+	}
+
+	retargets := map[string]string{
+		"buy 50 USDC":              "buy 50 SOL",
+		"swap 2 SOL for USDC":      "swap 2 USDC for SOL",
+		"buy USDC with 1 SOL":      "buy SOL with 1 USDC",
+		"pay 1 SOL at >= 150 USDC": "pay 1 USDC",
+	}
+	for line, want := range retargets {
+		ii, _ := parseIntent(line)
+		other := map[string]string{"SOL": "USDC", "USDC": "SOL"}[ii.TargetSymbol]
+		got, err := retargetIntent(line, other)
+		if err != nil || got != want {
+			t.Errorf("retarget %q = %q, %v, want %q", line, got, err, want)
+		}
+	}
+	if _, err := retargetIntent("pay 1 SOL", "sol"); err == nil {
+		t.Error("retargeted to the token the amount is already in")
+	}
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+/*
+NOTE(@hadydotai): Routing through Jupiter.
+
+A single CP-Swap pool isn't always the best place for a swap, Jupiter routes across every DEX on mainnet and will
+split and hop where it pays. With -via jupiter we ask Jupiter's swap API for a quote on the same swap, same amount,
+same direction, same slippage, put it next to the direct quote, and go with whichever gives more (or costs less). When
+-pool names a pair with no CP-Swap pool at all, Jupiter is the only route and it's used on its own.
+
+Jupiter hands back a whole transaction, built and with a recent blockhash, for us to sign. We don't know what's in
+its route and can't check it the way we check our own instructions, so the least we do is refuse anything that isn't
+paid for by the wallet or that wants another signer. The slippage guard is Jupiter's, built off the -slippage we
+passed it.
+
+Jupiter is mainnet only. Webhooks and -close-empty-atas only apply to direct swaps, a Jupiter route isn't one pool
+and doesn't leave behind accounts we made.
+*/
+
+var jupiterSwapURL = "https://lite-api.jup.ag/swap/v1"
+
+// jupiterQuote is the part of Jupiter's quote we show and compare, raw is the whole of it, /swap wants it back as is.
+type jupiterQuote struct {
+	raw         json.RawMessage
+	inAmount    *big.Int
+	outAmount   *big.Int
+	threshold   *big.Int // least that comes out for an exact input, most that goes in for an exact output
+	exactOut    bool
+	priceImpact *big.Rat
+	hops        []string // the AMMs the route goes through, in order
+}
+
+// jupiterQuoteRequest is a swap the way Jupiter's quote API takes it, amount is what's exact.
+type jupiterQuoteRequest struct {
+	inputMint   solana.PublicKey
+	outputMint  solana.PublicKey
+	amount      *big.Int
+	exactOut    bool
+	slippageBps uint64
+}
+
+func slippageBps(slippagePct float64) uint64 {
+	return uint64(math.Round(slippagePct * 100))
+}
+
+// jupiterRequestFor is the same swap as intent, for Jupiter to quote.
+func jupiterRequestFor(intent *CPIntent, slippagePct float64) jupiterQuoteRequest {
+	return jupiterQuoteRequest{
+		inputMint:   intent.TokenIn.Mint,
+		outputMint:  intent.TokenOut.Mint,
+		amount:      intent.Amounts.KnownAmount,
+		exactOut:    intent.SwapKind == SwapKindBaseOutput,
+		slippageBps: slippageBps(slippagePct),
+	}
+}
+
+func fetchJupiterQuote(ctx context.Context, req jupiterQuoteRequest) (*jupiterQuote, error) {
+	mode := "ExactIn"
+	if req.exactOut {
+		mode = "ExactOut"
+	}
+	query := url.Values{
+		"inputMint":   {req.inputMint.String()},
+		"outputMint":  {req.outputMint.String()},
+		"amount":      {req.amount.String()},
+		"slippageBps": {strconv.FormatUint(req.slippageBps, 10)},
+		"swapMode":    {mode},
+	}
+	var raw json.RawMessage
+	if err := fetchJSON(ctx, jupiterSwapURL+"/quote?"+query.Encode(), &raw); err != nil {
+		return nil, fmt.Errorf("jupiter quote failed: %w", err)
+	}
+	return decodeJupiterQuote(raw)
+}
+
+func decodeJupiterQuote(raw json.RawMessage) (*jupiterQuote, error) {
+	var body struct {
+		InAmount             string `json:"inAmount"`
+		OutAmount            string `json:"outAmount"`
+		OtherAmountThreshold string `json:"otherAmountThreshold"`
+		SwapMode             string `json:"swapMode"`
+		PriceImpactPct       string `json:"priceImpactPct"`
+		RoutePlan            []struct {
+			SwapInfo struct {
+				Label string `json:"label"`
+			} `json:"swapInfo"`
+		} `json:"routePlan"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, fmt.Errorf("decoding jupiter quote failed: %w", err)
+	}
+	q := &jupiterQuote{raw: raw, exactOut: body.SwapMode == "ExactOut"}
+	for _, field := range []struct {
+		name  string
+		value string
+		dst   **big.Int
+	}{{"inAmount", body.InAmount, &q.inAmount}, {"outAmount", body.OutAmount, &q.outAmount}, {"otherAmountThreshold", body.OtherAmountThreshold, &q.threshold}} {
+		v, ok := new(big.Int).SetString(field.value, 10)
+		if !ok || v.Sign() < 0 {
+			return nil, fmt.Errorf("jupiter quote has an invalid %s %q", field.name, field.value)
+		}
+		*field.dst = v
+	}
+	if q.inAmount.Sign() == 0 || q.outAmount.Sign() == 0 {
+		return nil, errors.New("jupiter found no route for this amount")
+	}
+	if impact, ok := new(big.Rat).SetString(body.PriceImpactPct); ok {
+		q.priceImpact = impact
+	}
+	for _, hop := range body.RoutePlan {
+		q.hops = append(q.hops, hop.SwapInfo.Label)
+	}
+	return q, nil
+}
+
+// postJSON POSTs in as JSON to endpoint and decodes the JSON answer into out.
+func postJSON(ctx context.Context, endpoint string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := priceHTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s answered %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// jupiterSwapTransaction fetches the transaction for q, paid for by payer, checked but not signed yet.
+func jupiterSwapTransaction(ctx context.Context, q *jupiterQuote, payer solana.PublicKey) (*solana.Transaction, error) {
+	var body struct {
+		SwapTransaction string `json:"swapTransaction"`
+	}
+	req := map[string]any{
+		"quoteResponse":           q.raw,
+		"userPublicKey":           payer.String(),
+		"wrapAndUnwrapSol":        true,
+		"dynamicComputeUnitLimit": true,
+	}
+	if err := postJSON(ctx, jupiterSwapURL+"/swap", req, &body); err != nil {
+		return nil, fmt.Errorf("jupiter swap transaction failed: %w", err)
+	}
+	tx, err := solana.TransactionFromBase64(body.SwapTransaction)
+	if err != nil {
+		return nil, fmt.Errorf("decoding jupiter's transaction failed: %w", err)
+	}
+	if len(tx.Message.AccountKeys) == 0 || !tx.Message.AccountKeys[0].Equals(payer) {
+		return nil, errors.New("jupiter's transaction isn't paid for by the wallet, refusing to sign it")
+	}
+	if tx.Message.Header.NumRequiredSignatures != 1 {
+		return nil, fmt.Errorf("jupiter's transaction wants %d signers, refusing to sign it", tx.Message.Header.NumRequiredSignatures)
+	}
+	return tx, nil
+}
+
+// routeComparison is the direct quote and Jupiter's for the same swap, and which of the two is used.
+type routeComparison struct {
+	tokenIn, tokenOut SwapLeg
+	exactOut          bool
+	direct            *CPIntent // nil when there's no direct pool
+	jupiter           *jupiterQuote
+	jupiterErr        error // why Jupiter couldn't quote, the direct route is used
+	useJupiter        bool
+}
+
+// compareWithJupiter quotes intent on Jupiter and picks the better of the two, the direct route wins a tie.
+func compareWithJupiter(ctx context.Context, intent *CPIntent, slippagePct float64) *routeComparison {
+	rc := &routeComparison{tokenIn: intent.TokenIn, tokenOut: intent.TokenOut, exactOut: intent.SwapKind == SwapKindBaseOutput, direct: intent}
+	quoteCtx, cancel := deadlines.forQuote(ctx)
+	defer cancel()
+	rc.jupiter, rc.jupiterErr = fetchJupiterQuote(quoteCtx, jupiterRequestFor(intent, slippagePct))
+	if rc.jupiterErr != nil {
+		return rc
+	}
+	if rc.exactOut {
+		rc.useJupiter = rc.jupiter.inAmount.Cmp(intent.Amounts.QuoteAmount) < 0
+	} else {
+		rc.useJupiter = rc.jupiter.outAmount.Cmp(intent.Amounts.QuoteAmount) > 0
+	}
+	return rc
+}
+
+// jupiterOnlyRoute quotes intentLine on Jupiter for a pair that has no CP-Swap pool to compare with. The pair's
+// sides are mints, SOL, or aliases.
+func jupiterOnlyRoute(ctx context.Context, client *rpc.Client, target, intentLine string, slippagePct float64) (*routeComparison, SymbolMapping, error) {
+	_, pair, isPair, err := parsePoolTarget(target)
+	if err != nil {
+		return nil, SymbolMapping{}, err
+	}
+	if !isPair {
+		return nil, SymbolMapping{}, errors.New("jupiter needs a pair (e.g. SOL/BONK) when there's no pool")
+	}
+	var mints [2]solana.PublicKey
+	for i, token := range pair {
+		if mints[i], err = resolvePairToken(token, SymbolMapping{}); err != nil {
+			return nil, SymbolMapping{}, err
+		}
+	}
+	quoteCtx, cancel := deadlines.forQuote(ctx)
+	accounts, err := fetchMintAccounts(quoteCtx, client, mints[0], mints[1])
+	cancel()
+	if err != nil {
+		return nil, SymbolMapping{}, err
+	}
+	symm := makeSymbolMapping(ctx, client, mints[:])
+	userAliases.apply(symm, mints[:]...)
+
+	ii, err := parseIntent(intentLine)
+	if err != nil {
+		return nil, symm, err
+	}
+	if ii.AmountPct != nil || ii.AmountUSD != nil {
+		return nil, symm, errors.New("without a pool, jupiter needs an exact amount, not a percentage or a dollar amount")
+	}
+	targetMint, ok := symm.MaybeMintFromToken(ii.TargetSymbol)
+	if !ok {
+		return nil, symm, fmt.Errorf("%s isn't either side of %s/%s", ii.TargetSymbol, pair[0], pair[1])
+	}
+	legs := [2]SwapLeg{
+		{Mint: accounts[0].Address, Program: accounts[0].Program, Decimals: accounts[0].Decimals},
+		{Mint: accounts[1].Address, Program: accounts[1].Program, Decimals: accounts[1].Decimals},
+	}
+	targetLeg, counterLeg := legs[0], legs[1]
+	if targetMint.Equals(legs[1].Mint) {
+		targetLeg, counterLeg = legs[1], legs[0]
+	}
+	amount, err := fmtForMath(ii.AmountStr, targetLeg.Decimals)
+	if err != nil {
+		return nil, symm, err
+	}
+	rc := &routeComparison{useJupiter: true}
+	switch ii.Dir {
+	case SwapDirSell:
+		rc.tokenIn, rc.tokenOut = targetLeg, counterLeg
+	case SwapDirBuy:
+		rc.tokenIn, rc.tokenOut, rc.exactOut = counterLeg, targetLeg, true
+	default:
+		return nil, symm, fmt.Errorf("swap direction unknown for verb %s", ii.Verb)
+	}
+	req := jupiterQuoteRequest{inputMint: rc.tokenIn.Mint, outputMint: rc.tokenOut.Mint, amount: amount, exactOut: rc.exactOut, slippageBps: slippageBps(slippagePct)}
+	quoteCtx, cancel = deadlines.forQuote(ctx)
+	defer cancel()
+	if rc.jupiter, err = fetchJupiterQuote(quoteCtx, req); err != nil {
+		return nil, symm, err
+	}
+	return rc, symm, nil
+}
+
+// renderRouteComparison puts the routes side by side, marking the one that's used.
+func renderRouteComparison(rc *routeComparison, symm SymbolMapping) string {
+	builder := &strings.Builder{}
+	t := table.NewWriter()
+	t.SetOutputMirror(builder)
+	t.SetTitle("Routes")
+	t.Style().Size.WidthMax = 160
+	inSym, outSym := symm.SymFrom(rc.tokenIn.Mint), symm.SymFrom(rc.tokenOut.Mint)
+	pay := func(v *big.Int) string { return formatTokenAmount(v, rc.tokenIn.Decimals, inSym) }
+	receive := func(v *big.Int) string { return formatTokenAmount(v, rc.tokenOut.Decimals, outSym) }
+	guardHeader, guard := "Min receive", receive
+	if rc.exactOut {
+		guardHeader, guard = "Max pay", pay
+	}
+	t.AppendHeader(table.Row{"", "Route", "Pay", "Receive", guardHeader, "Price impact"})
+	mark := func(used bool) string {
+		if used {
+			return "used"
+		}
+		return ""
+	}
+	if d := rc.direct; d != nil {
+		in, out := d.QuotedInOut()
+		limit := d.Amounts.MinAmountOut
+		if rc.exactOut {
+			limit = d.Amounts.MaxAmountIn
+		}
+		t.AppendRow(table.Row{mark(!rc.useJupiter), "Raydium CP-Swap " + Addr(d.Pool.Address.String()).String(), pay(in), receive(out), guard(limit), poolImpact(d)})
+	}
+	switch q := rc.jupiter; {
+	case q != nil:
+		impact := "n/a"
+		if q.priceImpact != nil {
+			impact = pctString(q.priceImpact)
+		}
+		t.AppendRow(table.Row{mark(rc.useJupiter), "Jupiter: " + strings.Join(q.hops, " → "), pay(q.inAmount), receive(q.outAmount), guard(q.threshold), impact})
+	case rc.jupiterErr != nil:
+		msg := rc.jupiterErr.Error()
+		t.AppendRow(table.Row{"", "Jupiter", msg, msg, msg, msg}, table.RowConfig{AutoMerge: true})
+	}
+	t.Render()
+	return builder.String()
+}
+
+// executeJupiter signs and sends Jupiter's transaction for the compared swap and waits for the outcome.
+func executeJupiter(ctx context.Context, client *rpc.Client, payer solana.PrivateKey, rc *routeComparison, symm SymbolMapping) (txSummaryData, solana.Signature, error) {
+	ctx, cancel := deadlines.forSend(ctx)
+	defer cancel()
+	hooked := hookSwap{Intent: rc.direct.String(), Wallet: payer.PublicKey(), TokenIn: rc.tokenIn, TokenOut: rc.tokenOut, MaxIn: rc.jupiter.inAmount, MinOut: rc.jupiter.threshold, Symbols: symm}
+	if rc.exactOut {
+		hooked.MaxIn, hooked.MinOut = rc.jupiter.threshold, rc.jupiter.outAmount
+	}
+	if err := preSendHooks(ctx, hooked); err != nil {
+		return txSummaryData{}, solana.Signature{}, err
+	}
+	payerPub := payer.PublicKey()
+	tx, err := jupiterSwapTransaction(ctx, rc.jupiter, payerPub)
+	if err != nil {
+		return txSummaryData{}, solana.Signature{}, err
+	}
+	if _, err := tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
+		if key.Equals(payerPub) {
+			return &payer
+		}
+		return nil
+	}); err != nil {
+		return txSummaryData{}, solana.Signature{}, fmt.Errorf("signing transaction failed: %w", err)
+	}
+	sig, err := sendTransaction(ctx, client, tx)
+	if err != nil {
+		return txSummaryData{}, solana.Signature{}, err
+	}
+	log.Println("Tx: ", sig.String())
+	// NOTE(@hadydotai): There's no vault to diff on a Jupiter route, what the wallet's own token accounts gained
+	// and lost is what was paid and received. A wSOL account Jupiter opens and closes in the same transaction shows
+	// no balance either side, so native SOL legs come back as n/a.
+	walletLeg := func(leg SwapLeg) SwapLeg {
+		ata, _ := associatedTokenAddress(payerPub, leg.Mint, legProgram(leg))
+		leg.Vault = ata
+		return leg
+	}
+	summary, waitErr := awaitRouteSummary(ctx, client, sig, []SwapLeg{walletLeg(rc.tokenIn)}, []SwapLeg{walletLeg(rc.tokenOut)},
+		symm.SymFrom(rc.tokenIn.Mint), symm.SymFrom(rc.tokenOut.Mint))
+	if waitErr != nil {
+		log.Printf("warning: waiting for transaction confirmation failed: %v", waitErr)
+	}
+	postConfirmHooks(ctx, hooked, summary)
+	if summary.Status == "failed" {
+		return summary, sig, &txFailedError{sig: sig, txErr: summary.TxErr, logs: summary.Logs}
+	}
+	return summary, sig, nil
+}
+
+// runJupiterRoute executes the route on Jupiter and prints the outcome like a direct swap.
+func runJupiterRoute(ctx context.Context, client *rpc.Client, payer solana.PrivateKey, rc *routeComparison, symm SymbolMapping, network string) error {
+	summary, sig, err := executeJupiter(ctx, client, payer, rc, symm)
+	if !sig.IsZero() {
+		fmt.Fprintln(os.Stdout, renderTxSummary(summary))
+		fmt.Fprintln(os.Stdout, explorerTxURL(network, sig))
+	}
+	return err
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+)
+
+// useJupiterAPI points the Jupiter swap API at handler for the rest of the test.
+func useJupiterAPI(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	prev := jupiterSwapURL
+	jupiterSwapURL = srv.URL
+	t.Cleanup(func() { jupiterSwapURL = prev })
+}
+
+func TestCompareWithJupiter(t *testing.T) {
+	pool, addr, balances := snapshotPool()
+	instruction, err := parseIntent("sell 2.5 SOL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slippage, _ := makeSlippageRatio(0.5)
+	direct, err := NewCPIntent(ConstantProduct{TradeFeeRate: 2500, SlippageRatio: slippage}, pool, addr, instruction, pool.Token0Mint, balances...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	symm := SymbolMapping{mintToSymbol: map[string]string{pool.Token0Mint.String(): "SOL", pool.Token1Mint.String(): "USDC"}}
+
+	var outAmount string
+	useJupiterAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/quote" || q.Get("inputMint") != pool.Token0Mint.String() || q.Get("amount") != "2500000000" ||
+			q.Get("swapMode") != "ExactIn" || q.Get("slippageBps") != "50" {
+			http.Error(w, "unexpected request "+r.URL.String(), http.StatusBadRequest)
+			return
+		}
+		if outAmount == "" {
+			http.Error(w, `{"error":"no route"}`, http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"inAmount":"2500000000","outAmount":%q,"otherAmountThreshold":"1","swapMode":"ExactIn","priceImpactPct":"0.001",
+			"routePlan":[{"swapInfo":{"label":"Whirlpool"}},{"swapInfo":{"label":"Raydium CLMM"}}]}`, outAmount)
+	})
+
+	// The direct pool quotes 373.132002 USDC.
+	outAmount = "374000000"
+	rc := compareWithJupiter(context.Background(), direct, 0.5)
+	if rc.jupiterErr != nil || !rc.useJupiter {
+		t.Fatalf("jupiter pays more and wasn't used: %v", rc.jupiterErr)
+	}
+	out := renderRouteComparison(rc, symm)
+	for _, want := range []string{"Whirlpool → Raydium CLMM", "374.000000 USDC", "373.132002 USDC", "used"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("comparison is missing %q:\n%s", want, out)
+		}
+	}
+
+	outAmount = "373000000"
+	if rc := compareWithJupiter(context.Background(), direct, 0.5); rc.useJupiter {
+		t.Error("jupiter pays less and was used")
+	}
+
+	outAmount = ""
+	rc = compareWithJupiter(context.Background(), direct, 0.5)
+	if rc.jupiterErr == nil || rc.useJupiter {
+		t.Fatalf("want the direct route when jupiter can't quote, got %v", rc.jupiterErr)
+	}
+	if out := renderRouteComparison(rc, symm); !strings.Contains(out, "jupiter quote failed") {
+		t.Errorf("jupiter's failure isn't shown:\n%s", out)
+	}
+}
+
+func TestJupiterSwapTransaction(t *testing.T) {
+	wallet, stranger := snapshotKey(60), snapshotKey(61)
+	var payer solana.PublicKey
+	useJupiterAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			QuoteResponse json.RawMessage `json:"quoteResponse"`
+			UserPublicKey string          `json:"userPublicKey"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || r.URL.Path != "/swap" || string(req.QuoteResponse) != `{"routePlan":[]}` {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		tx, err := solana.NewTransaction(
+			[]solana.Instruction{system.NewTransferInstruction(1, payer, snapshotKey(62)).Build()},
+			solana.Hash(snapshotKey(63)),
+			solana.TransactionPayer(payer),
+		)
+		if err != nil {
+			t.Error(err)
+		}
+		fmt.Fprintf(w, `{"swapTransaction":%q}`, tx.MustToBase64())
+	})
+	q := &jupiterQuote{raw: json.RawMessage(`{"routePlan":[]}`)}
+
+	payer = wallet
+	tx, err := jupiterSwapTransaction(context.Background(), q, wallet)
+	if err != nil || !tx.Message.AccountKeys[0].Equals(wallet) {
+		t.Fatalf("got %v, %v", tx, err)
+	}
+	payer = stranger
+	if _, err := jupiterSwapTransaction(context.Background(), q, wallet); err == nil || !strings.Contains(err.Error(), "isn't paid for by the wallet") {
+		t.Errorf("want someone else's transaction refused, got %v", err)
+	}
+}
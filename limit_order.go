@@ -0,0 +1,491 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/ws"
+)
+
+/*
+NOTE(@hadydotai): Limit orders.
+
+An order is an intent plus a trigger, `buy 100 TOKEN when price <= 0.005 SOL`. The price is always the price of the
+token the intent names (the target), in units of the other token of the pool (the counter), same as -watch prints it.
+
+We subscribe to both pool vaults over WebSocket so we re-quote the moment reserves move, with a slow poll underneath
+in case the socket quietly dies (they do). When the trigger holds we don't just fire, the quote we act on has to pass:
+  - the trigger, on a quote fetched right then, not the one that woke us up
+  - the price impact cap, a thin pool can satisfy a price for 1 token and not for 100
+  - the slippage guard gets tightened so that even the worst fill the program accepts honours the limit price,
+    otherwise a `buy when price <= X` with 1% slippage could legally fill at X + 1%
+Sends that fail, or land and fail, count against -max-retries, a quote or lookup that fails only waits for the next
+trigger. A send that fails without saying whether it went out is settled before anything else is sent, it may be the
+fill (see send_journal.go). The order gives up at -good-for or -expires-at (see order_expiry.go).
+*/
+
+type limitCondition struct {
+	op    string // one of <=, >=, <, >
+	price *big.Rat
+	unit  string // optional, the counter token symbol the price is quoted in
+}
+
+func (c limitCondition) holds(price *big.Rat) bool {
+	if price == nil {
+		return false
+	}
+	cmp := price.Cmp(c.price)
+	switch c.op {
+	case "<=":
+		return cmp <= 0
+	case "<":
+		return cmp < 0
+	case ">=":
+		return cmp >= 0
+	case ">":
+		return cmp > 0
+	default:
+		return false
+	}
+}
+
+func (c limitCondition) String() string {
+	s := fmt.Sprintf("price %s %s", c.op, c.price.FloatString(9))
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if c.unit != "" {
+		s += " " + c.unit
+	}
+	return s
+}
+
+// trigger decides, quote by quote, whether an order fires. It hands back the condition that held, which is what the
+// slippage guard gets clamped to (see clampToLimit).
+type trigger interface {
+	fires(price *big.Rat) (limitCondition, bool)
+	String() string
+}
+
+func (c limitCondition) fires(price *big.Rat) (limitCondition, bool) {
+	return c, c.holds(price)
+}
+
+type limitOrder struct {
+	intent      string
+	instruction *IntentInstruction
+	cond        limitCondition
+}
+
+func (o *limitOrder) String() string {
+	return fmt.Sprintf("%s when %s", o.intent, o.cond)
+}
+
+// parseLimitOrder parses `<intent> when price <op> <price> [<symbol>]`.
+func parseLimitOrder(line string) (*limitOrder, error) {
+	lower := strings.ToLower(line)
+	idx := strings.LastIndex(lower, " when ")
+	if idx < 0 {
+		return nil, fmt.Errorf("limit order %q is missing its trigger, expected `<intent> when price <op> <price> [<symbol>]`", line)
+	}
+	intentPart := strings.TrimSpace(line[:idx])
+	instruction, err := parseIntent(intentPart)
+	if err != nil {
+		return nil, err
+	}
+	if instruction.TargetSymbol == "" {
+		return nil, fmt.Errorf("limit order intent %q has to name a token, the trigger price is quoted per that token", intentPart)
+	}
+	if instruction.Condition != nil {
+		return nil, fmt.Errorf("limit order intent %q already has a trigger, put the price in `when` rather than `at`", intentPart)
+	}
+	fields := strings.Fields(line[idx+len(" when "):])
+	if len(fields) < 3 || len(fields) > 4 || !strings.EqualFold(fields[0], "price") {
+		return nil, fmt.Errorf("limit order trigger %q is malformed, expected `price <op> <price> [<symbol>]`", strings.Join(fields, " "))
+	}
+	cond := limitCondition{op: fields[1]}
+	switch cond.op {
+	case "<=", ">=", "<", ">":
+	default:
+		return nil, fmt.Errorf("limit order operator %q isn't supported, use one of <=, >=, <, >", cond.op)
+	}
+	price, ok := new(big.Rat).SetString(fields[2])
+	if !ok || price.Sign() <= 0 {
+		return nil, fmt.Errorf("limit order price %q must be a positive decimal number", fields[2])
+	}
+	cond.price = price
+	if len(fields) == 4 {
+		cond.unit = fields[3]
+	}
+	return &limitOrder{intent: intentPart, instruction: instruction, cond: cond}, nil
+}
+
+// rawCounterAtLimit is the counter amount, in raw units, that trading the intent's known amount at exactly the limit
+// price comes to. Rounded in the direction that keeps us on the right side of the limit.
+func rawCounterAtLimit(intent *CPIntent, price *big.Rat, roundUp bool) *big.Int {
+	counter := intent.CounterLeg()
+	knownDecimals := intent.TokenIn.Decimals
+	if intent.SwapKind == SwapKindBaseOutput {
+		knownDecimals = intent.TokenOut.Decimals
+	}
+	v := new(big.Rat).Mul(price, new(big.Rat).SetInt(intent.Amounts.KnownAmount))
+	v.Mul(v, new(big.Rat).SetFrac(fixedPointScale(counter.Decimals), fixedPointScale(knownDecimals)))
+	q, r := new(big.Int).QuoRem(v.Num(), v.Denom(), new(big.Int))
+	if roundUp && r.Sign() > 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	return q
+}
+
+// clampToLimit tightens the slippage guard so the worst fill the program would accept still honours the limit price.
+// Only the trigger directions that bound what we pay or receive are clamped, a `sell when price <= X` is a stop, and
+// there the trigger isn't a promise about the fill.
+func clampToLimit(intent *CPIntent, cond limitCondition) {
+	switch {
+	case intent.SwapKind == SwapKindBaseInput && (cond.op == ">=" || cond.op == ">"):
+		floor := rawCounterAtLimit(intent, cond.price, true)
+		if intent.Amounts.MinAmountOut == nil || intent.Amounts.MinAmountOut.Cmp(floor) < 0 {
+			intent.Amounts.MinAmountOut = floor
+		}
+	case intent.SwapKind == SwapKindBaseOutput && (cond.op == "<=" || cond.op == "<"):
+		ceil := rawCounterAtLimit(intent, cond.price, false)
+		if intent.Amounts.MaxAmountIn == nil || intent.Amounts.MaxAmountIn.Cmp(ceil) > 0 {
+			intent.Amounts.MaxAmountIn = ceil
+		}
+	}
+}
+
+// wsEndpointFor guesses the WebSocket endpoint of an RPC, which for every provider we've seen is the same URL with a
+// ws scheme.
+func wsEndpointFor(rpcEP string) string {
+	switch {
+	case strings.HasPrefix(rpcEP, "https://"):
+		return "wss://" + strings.TrimPrefix(rpcEP, "https://")
+	case strings.HasPrefix(rpcEP, "http://"):
+		return "ws://" + strings.TrimPrefix(rpcEP, "http://")
+	default:
+		return rpcEP
+	}
+}
+
+// watchVaults pokes notify whenever either vault changes, and every poll interval regardless.
+func watchVaults(ctx context.Context, wsEP string, vaults []solana.PublicKey, poll time.Duration, notify chan<- struct{}) {
+	poke := func() {
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	}
+	if wsEP != "" {
+		client, err := ws.Connect(ctx, wsEP)
+		if err != nil {
+			log.Printf("warning: websocket connection to %s failed, falling back to polling every %s: %v", wsEP, poll, err)
+		} else {
+			go func() {
+				<-ctx.Done()
+				client.Close()
+			}()
+			for _, vault := range vaults {
+				sub, err := client.AccountSubscribe(vault, rpc.CommitmentProcessed)
+				if err != nil {
+					log.Printf("warning: subscribing to vault %s failed, relying on polling: %v", Addr(vault.String()), err)
+					continue
+				}
+				go func(sub *ws.AccountSubscription) {
+					defer sub.Unsubscribe()
+					for {
+						if _, err := sub.Recv(ctx); err != nil {
+							if ctx.Err() == nil {
+								log.Printf("warning: vault subscription ended, relying on polling: %v", err)
+							}
+							return
+						}
+						poke()
+					}
+				}(sub)
+			}
+		}
+	}
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			poke()
+		}
+	}
+}
+
+var errLimitOrderExpired = errors.New("limit order expired before it could be filled")
+
+type limitEngine struct {
+	ctx        context.Context
+	client     *rpc.Client
+	builder    *TableBuilder
+	payer      solana.PrivateKey
+	network    string
+	kind       string // what we call the order in logs, "limit order", "stop"
+	intent     string
+	trigger    trigger
+	unit       string   // the counter symbol trigger prices are quoted in, when the user gave one
+	maxImpact  *big.Rat // nil for no cap
+	maxRetries int
+	expiresAt  time.Time // zero for an order that never expires
+	wsEP       string
+	poll       time.Duration
+	receipts   string // receipts store to record the fill in, empty for none
+	guard      *sendGuard
+}
+
+// quote re-quotes the order's intent against the pool as it is right now.
+func (le *limitEngine) quote() (*CPIntent, *big.Rat, error) {
+	_, intent, err := le.builder.Build(le.intent)
+	if err != nil {
+		return nil, nil, err
+	}
+	return intent, targetPrice(intent), nil
+}
+
+// tryFill checks the trigger and, when it holds and the quote is safe to act on, sends the swap. It returns whether
+// the order got filled, and the error of a send that failed or landed and failed, the only ones that count against
+// -max-retries. A quote or a lookup that fails is logged and the order waits for the next trigger, a flaky node
+// shouldn't cancel an order that never got to send.
+func (le *limitEngine) tryFill() (bool, error) {
+	intent, price, err := le.quote()
+	if err != nil {
+		log.Printf("warning: %s: quoting failed, waiting for the next trigger: %v", le.intent, err)
+		return false, nil
+	}
+	if le.guard.inFlight() {
+		// An attempt that failed to send may have gone out anyway, if it landed it's the fill whatever the price is now.
+		ctx, cancel := deadlines.forSend(le.ctx)
+		summary, sig, landed, err := landedAttempt(ctx, le.client, le.builder.snapshot(), intent, le.guard)
+		cancel()
+		if err != nil {
+			log.Printf("warning: %s: looking up the attempt still in flight failed, waiting for the next trigger: %v", le.intent, err)
+			return false, nil
+		}
+		if landed {
+			le.report(intent, summary, sig, le.trigger.String())
+			return true, nil
+		}
+	}
+	counterSym := le.builder.symbols().SymFrom(intent.CounterLeg().Mint)
+	priceStr := price.FloatString(int(intent.CounterLeg().Decimals))
+	cond, ok := le.trigger.fires(price)
+	if !ok {
+		log.Printf("%s: price %s %s, waiting for %s", le.intent, priceStr, counterSym, le.trigger)
+		return false, nil
+	}
+	over, impact, err := le.overImpactCap(intent)
+	if err != nil {
+		log.Printf("warning: %s: working out the price impact failed, waiting for the next trigger: %v", le.intent, err)
+		return false, nil
+	}
+	if over {
+		log.Printf("%s: trigger holds at %s %s but price impact %s%% exceeds the %s%% cap, waiting",
+			le.intent, priceStr, counterSym,
+			new(big.Rat).Mul(impact, big.NewRat(100, 1)).FloatString(2), new(big.Rat).Mul(le.maxImpact, big.NewRat(100, 1)).FloatString(2))
+		return false, nil
+	}
+	clampToLimit(intent, cond)
+	log.Printf("%s: %s holds at %s %s, sending", le.intent, cond, priceStr, counterSym)
+	summary, sig, err := executeIntentOnce(le.ctx, le.client, le.payer, le.builder, intent, le.guard)
+	if sig.IsZero() {
+		return false, err
+	}
+	le.report(intent, summary, sig, cond.String())
+	return err == nil, err
+}
+
+// overImpactCap reports whether intent's price impact is over -max-impact, and the impact. Without a cap nothing is.
+func (le *limitEngine) overImpactCap(intent *CPIntent) (bool, *big.Rat, error) {
+	if le.maxImpact == nil {
+		return false, nil, nil
+	}
+	impact, err := intent.PriceImpact()
+	if err != nil {
+		return false, nil, err
+	}
+	return impact.Cmp(le.maxImpact) > 0, impact, nil
+}
+
+// report prints a sent swap and records its receipt, with the attempts before it when it took more than one.
+func (le *limitEngine) report(intent *CPIntent, summary txSummaryData, sig solana.Signature, trigger string) {
+	fmt.Fprintln(os.Stdout, renderTxSummary(summary))
+	fmt.Fprintln(os.Stdout, explorerTxURL(le.network, sig))
+	if le.receipts != "" {
+		rcpt := newSwapReceipt(le.kind, le.builder.snapshot().address.String(), intent.String(), summary, explorerTxURL(le.network, sig))
+		rcpt.Trigger = trigger
+		rcpt.RetryOf = le.guard.lineage(sig)
+		if rerr := appendReceipt(le.receipts, rcpt); rerr != nil {
+			log.Printf("warning: recording the receipt failed: %v", rerr)
+		}
+	}
+}
+
+func (le *limitEngine) run() error {
+	intent, _, err := le.quote()
+	if err != nil {
+		return err
+	}
+	symm := le.builder.symbols()
+	counterSym := symm.SymFrom(intent.CounterLeg().Mint)
+	if le.unit != "" && !symm.namesMint(le.unit, intent.CounterLeg().Mint) {
+		return fmt.Errorf("%s price is in %s, but %s is quoted in %s on this pool", le.kind, le.unit, le.intent, counterSym)
+	}
+
+	ctx, cancel := context.WithCancel(le.ctx)
+	defer cancel()
+	triggers := make(chan struct{}, 1)
+	triggers <- struct{}{} // check once right away
+	_, pool := le.builder.currentPool()
+	go watchVaults(ctx, le.wsEP, []solana.PublicKey{pool.Token0Vault, pool.Token1Vault}, le.poll, triggers)
+	var expired <-chan time.Time
+	if !le.expiresAt.IsZero() {
+		timer := time.NewTimer(time.Until(le.expiresAt))
+		defer timer.Stop()
+		expired = timer.C
+		log.Printf("%s placed: %s when %s (expires %s)", le.kind, le.intent, le.trigger, le.expiresAt.Local().Format(time.DateTime))
+	} else {
+		log.Printf("%s placed: %s when %s", le.kind, le.intent, le.trigger)
+	}
+
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("%s cancelled", le.kind)
+			return nil
+		case <-expired:
+			recordExpiry(le.receipts, le.kind, le.builder.snapshot().address.String(), le.intent, le.trigger.String(), le.expiresAt)
+			return errLimitOrderExpired
+		case <-triggers:
+			filled, err := le.tryFill()
+			if filled {
+				le.guard.done()
+				return nil
+			}
+			if err == nil {
+				continue
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+			failures++
+			if failures > le.maxRetries {
+				return fmt.Errorf("giving up after %d failed send attempts: %w", failures, err)
+			}
+			log.Printf("warning: send attempt %d failed, will retry on the next trigger: %v", failures, err)
+		}
+	}
+}
+
+func runLimitCommand(args []string) error {
+	fs := flag.NewFlagSet("limit", flag.ExitOnError)
+	nf := addNetworkFlags(fs)
+	wf := addWebhookFlags(fs)
+	addCloseEmptyATAsFlag(fs)
+	addTradeHookFlags(fs)
+	addMintPolicyFlags(fs)
+	addSpendLimitFlags(fs)
+	addApprovalFlags(fs)
+	addRebroadcastFlags(fs)
+	addMinOutFromSimFlag(fs)
+	addComputeBudgetFlags(fs)
+	var (
+		hotwalletPath = fs.String("hotwallet", "", "Path to the hotwallet to use for signing transactions")
+		poolAddr      = fs.String("pool", "", "Pool to trade against")
+		orderLine     = fs.String("order", "", "Limit order, e.g. \"buy 100 TOKEN when price <= 0.005 SOL\"")
+		slippagePct   = fs.Float64("slippage", 0.5, "Slippage tolerance percentage (e.g. 0.5 for 0.5%)")
+		maxImpactPct  = fs.Float64("max-impact", 1, "Don't fill while the trade's price impact (including the trade fee) is above this percentage")
+		maxRetries    = fs.Int("max-retries", 3, "How many failed send attempts to tolerate before giving up")
+		wsEP          = fs.String("ws", "", "WebSocket endpoint for reserve updates, derived from -rpc when empty")
+		poll          = fs.Duration("poll", 15*time.Second, "Re-quote at least this often, even without reserve updates")
+		receiptsPath  = fs.String("receipts", "", "Append the fill to this receipts file (JSON lines)")
+		journalPath   = fs.String("journal", "", "Journal every send to this file, a restart settles what's still in flight instead of sending again (kept in memory when empty)")
+	)
+	expiry := addExpiryFlags(fs, time.Hour)
+	addAmountFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, append(nf.specs(),
+		FlagSpec{Name: "hotwallet", Value: hotwalletPath, Rules: []FlagRule{NotEmpty()}},
+		FlagSpec{Name: "pool", Value: poolAddr, Rules: []FlagRule{NotEmpty()}},
+		FlagSpec{Name: "order", Value: orderLine, Rules: []FlagRule{NotEmpty()}},
+	))
+	if *maxImpactPct <= 0 || *maxRetries < 0 || *poll <= 0 {
+		return errors.New("max-impact and poll must be greater than zero, max-retries can't be negative")
+	}
+	order, err := parseLimitOrder(*orderLine)
+	if err != nil {
+		return err
+	}
+	maxImpact, ok := new(big.Rat).SetString(fmt.Sprintf("%g", *maxImpactPct))
+	if !ok {
+		return fmt.Errorf("invalid max-impact %v", *maxImpactPct)
+	}
+	maxImpact.Quo(maxImpact, big.NewRat(100, 1))
+
+	journal, err := openSendJournal(*journalPath)
+	if err != nil {
+		return err
+	}
+	payer, err := solana.PrivateKeyFromSolanaKeygenFile(*hotwalletPath)
+	if err != nil {
+		return fmt.Errorf("failed to load private key from hot wallet: %w", err)
+	}
+	poolPubK, err := solana.PublicKeyFromBase58(*poolAddr)
+	if err != nil {
+		return fmt.Errorf("deriving public key from pool address (base58) failed, make sure it's base58 encoded: %w", err)
+	}
+	flushWebhooks, err := wf.start("limit", *nf.network)
+	if err != nil {
+		return err
+	}
+	defer flushWebhooks()
+	client := nf.connect()
+	if *wsEP == "" {
+		*wsEP = wsEndpointFor(*nf.rpcEP)
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	loaded, err := loadPool(ctx, client, poolPubK)
+	if err != nil {
+		return err
+	}
+	builder, err := newTableBuilder(ctx, client, loaded, *slippagePct)
+	if err != nil {
+		return err
+	}
+	builder.useWallet(payer.PublicKey())
+	engine := &limitEngine{
+		ctx:        ctx,
+		client:     client,
+		builder:    builder,
+		payer:      payer,
+		network:    *nf.network,
+		kind:       "limit order",
+		intent:     order.intent,
+		trigger:    order.cond,
+		unit:       order.cond.unit,
+		maxImpact:  maxImpact,
+		maxRetries: *maxRetries,
+		expiresAt:  expiry.deadline(time.Now()),
+		wsEP:       *wsEP,
+		poll:       *poll,
+		receipts:   *receiptsPath,
+		guard:      newSendGuard(journal, fmt.Sprintf("limit %s %s", poolPubK, order)),
+	}
+	return engine.run()
+}
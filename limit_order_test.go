@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestParseLimitOrder(t *testing.T) {
+	order, err := parseLimitOrder("buy 100 TOKEN when price <= 0.005 SOL")
+	if err != nil {
+		t.Fatalf("parseLimitOrder: %v", err)
+	}
+	if order.intent != "buy 100 TOKEN" || order.instruction.Dir != SwapDirBuy {
+		t.Fatalf("unexpected intent %+v", order)
+	}
+	if order.cond.op != "<=" || order.cond.price.Cmp(big.NewRat(1, 200)) != 0 || order.cond.unit != "SOL" {
+		t.Fatalf("unexpected condition %+v", order.cond)
+	}
+	if got := order.String(); got != "buy 100 TOKEN when price <= 0.005 SOL" {
+		t.Fatalf("got %q", got)
+	}
+
+	for _, bad := range []string{
+		"buy 100 TOKEN",
+		"buy 100 when price <= 1",
+		"buy 100 TOKEN when price == 1",
+		"buy 100 TOKEN when price <= -1",
+		"buy 100 TOKEN when cost <= 1",
+		"buy 100 TOKEN when price <=",
+	} {
+		if _, err := parseLimitOrder(bad); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestLimitConditionHolds(t *testing.T) {
+	limit := big.NewRat(150, 1)
+	tests := []struct {
+		op    string
+		price int64
+		want  bool
+	}{
+		{"<=", 150, true}, {"<=", 151, false},
+		{"<", 150, false}, {"<", 149, true},
+		{">=", 150, true}, {">=", 149, false},
+		{">", 150, false}, {">", 151, true},
+	}
+	for _, tc := range tests {
+		cond := limitCondition{op: tc.op, price: limit}
+		if got := cond.holds(big.NewRat(tc.price, 1)); got != tc.want {
+			t.Fatalf("%d %s 150: got %v want %v", tc.price, tc.op, got, tc.want)
+		}
+	}
+	if (limitCondition{op: "<=", price: limit}).holds(nil) {
+		t.Fatalf("a missing price should never trigger")
+	}
+}
+
+func TestClampToLimit(t *testing.T) {
+	pool, poolAddr, balances := snapshotPool()
+	cp := ConstantProduct{TradeFeeRate: 2500, SlippageRatio: mustSlippageRatio(t, 1)}
+
+	buy, err := NewCPIntent(cp, pool, poolAddr, &IntentInstruction{Verb: "buy", AmountStr: "10", Dir: SwapDirBuy, TargetSymbol: "USDC"}, pool.Token1Mint, balances...)
+	if err != nil {
+		t.Fatalf("NewCPIntent: %v", err)
+	}
+	// 10 USDC at no more than 0.0066 SOL each is 0.066 SOL, tighter than the 1% slippage guard.
+	clampToLimit(buy, limitCondition{op: "<=", price: big.NewRat(66, 10_000)})
+	if buy.Amounts.MaxAmountIn.Cmp(big.NewInt(66_000_000)) != 0 {
+		t.Fatalf("expected max in clamped to 66000000, got %s", buy.Amounts.MaxAmountIn)
+	}
+	// A looser limit leaves the slippage guard alone.
+	before := new(big.Int).Set(buy.Amounts.MaxAmountIn)
+	clampToLimit(buy, limitCondition{op: "<=", price: big.NewRat(1, 1)})
+	if buy.Amounts.MaxAmountIn.Cmp(before) != 0 {
+		t.Fatalf("looser limit shouldn't loosen the guard, got %s", buy.Amounts.MaxAmountIn)
+	}
+
+	sell, err := NewCPIntent(cp, pool, poolAddr, &IntentInstruction{Verb: "sell", AmountStr: "1", Dir: SwapDirSell, TargetSymbol: "SOL"}, pool.Token0Mint, balances...)
+	if err != nil {
+		t.Fatalf("NewCPIntent: %v", err)
+	}
+	clampToLimit(sell, limitCondition{op: ">=", price: big.NewRat(149, 1)})
+	if sell.Amounts.MinAmountOut.Cmp(big.NewInt(149_000_000)) != 0 {
+		t.Fatalf("expected min out raised to 149000000, got %s", sell.Amounts.MinAmountOut)
+	}
+	// Stops don't promise anything about the fill, the guard stays as is.
+	minOut := new(big.Int).Set(sell.Amounts.MinAmountOut)
+	clampToLimit(sell, limitCondition{op: "<=", price: big.NewRat(200, 1)})
+	if sell.Amounts.MinAmountOut.Cmp(minOut) != 0 {
+		t.Fatalf("stop trigger shouldn't touch the guard")
+	}
+}
+
+func TestWsEndpointFor(t *testing.T) {
+	if got := wsEndpointFor("https://api.devnet.solana.com"); got != "wss://api.devnet.solana.com" {
+		t.Fatalf("got %q", got)
+	}
+	if got := wsEndpointFor("http://127.0.0.1:8899"); got != "ws://127.0.0.1:8899" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestLimitEngineSurvivesQuoteFailures(t *testing.T) {
+	age := quoteCacheAge
+	t.Cleanup(func() { quoteCacheAge = age })
+	quoteCacheAge = 0
+
+	pool, addr, balances := snapshotPool()
+	vaults := vaultBalanceServer(t, map[solana.PublicKey]*PoolBalance{pool.Token0Vault: balances[0], pool.Token1Vault: balances[1]})
+	// The node answers the two vault reads of the quote placing the order, and fails every read after it.
+	var reads atomic.Int64
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reads.Add(1) > 2 {
+			http.Error(w, "node is down", http.StatusServiceUnavailable)
+			return
+		}
+		resp, err := http.Post(vaults.URL, "application/json", r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		io.Copy(w, resp.Body)
+	}))
+	t.Cleanup(node.Close)
+	symm := SymbolMapping{
+		mintToSymbol: map[string]string{pool.Token0Mint.String(): "SOL", pool.Token1Mint.String(): "USDC"},
+		symbolToMint: map[string]solana.PublicKey{"SOL": pool.Token0Mint, "USDC": pool.Token1Mint},
+		unresolved:   map[string]struct{}{},
+	}
+	lp := &loadedPool{address: addr, pool: pool, ammConfig: &raydium_cp_swap.AmmConfig{TradeFeeRate: 2500}, symbolsMap: symm}
+	tb, err := newTableBuilder(context.Background(), rpc.New(node.URL), lp, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, err := parseLimitOrder("sell 1 SOL when price >= 200 USDC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	le := &limitEngine{
+		ctx:        context.Background(),
+		client:     rpc.New(node.URL),
+		builder:    tb,
+		kind:       "limit order",
+		intent:     order.intent,
+		trigger:    order.cond,
+		maxImpact:  big.NewRat(1, 100),
+		maxRetries: 0,
+		expiresAt:  time.Now().Add(300 * time.Millisecond),
+		poll:       10 * time.Millisecond,
+	}
+	// With -max-retries 0 a single counted failure would give up, the order has to run out its clock instead.
+	if err := le.run(); !errors.Is(err, errLimitOrderExpired) {
+		t.Fatalf("run ended with %v, want it to expire", err)
+	}
+	if got := reads.Load(); got < 6 {
+		t.Errorf("%d reads, want the order to keep quoting while the node fails", got)
+	}
+}
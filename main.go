@@ -10,16 +10,14 @@ import (
 	"log"
 	"math/big"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
-	"hadydotai/raydium-client/raydium_cp_swap"
-
 	solana "github.com/gagliardetto/solana-go"
 	atapkg "github.com/gagliardetto/solana-go/programs/associated-token-account"
-	computebudget "github.com/gagliardetto/solana-go/programs/compute-budget"
 	"github.com/gagliardetto/solana-go/programs/system"
-	tokenprog "github.com/gagliardetto/solana-go/programs/token"
 	"github.com/gagliardetto/solana-go/rpc"
 	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
 	"github.com/jedib0t/go-pretty/v6/table"
@@ -28,6 +26,10 @@ import (
 const (
 	RaydiumProgramID = iota
 	DefaultRPC
+	USDCMint
+	RaydiumCLMMProgramID
+	RaydiumAMMv4ProgramID
+	RaydiumFarmProgramID
 )
 
 const (
@@ -36,21 +38,35 @@ const (
 )
 
 var (
-	ATAProgramID     = atapkg.ProgramID
-	SystemProgramID  = system.ProgramID
-	DefaultUnitLimit = uint32(200_000_000) // rough ballpark, https://solana.com/docs/core/fees and from simulating a few transactions
-	DefaultUnitPrice = uint64(5000)        // micro-lamports, 0.005 lamports
+	ATAProgramID    = atapkg.ProgramID
+	SystemProgramID = system.ProgramID
 
 	wSOLMint = solana.MustPublicKeyFromBase58(string(wSOLMintAddr))
 
 	networks = map[string]map[int]any{
 		"devnet": {
-			RaydiumProgramID: solana.MustPublicKeyFromBase58("DRaycpLY18LhpbydsBWbVJtxpNv9oXPgjRSfpF2bWpYb"),
-			DefaultRPC:       rpc.DevNet_RPC,
+			RaydiumProgramID:      solana.MustPublicKeyFromBase58("DRaycpLY18LhpbydsBWbVJtxpNv9oXPgjRSfpF2bWpYb"),
+			DefaultRPC:            rpc.DevNet_RPC,
+			USDCMint:              solana.MustPublicKeyFromBase58("4zMMC9srt5Ri5X14GAgXhaHii3GnPAEERYPJgZJDncDU"),
+			RaydiumCLMMProgramID:  solana.MustPublicKeyFromBase58("devi51mZmdwUJGU9hjN27vEz64Gps7uUefqxg27EAtH"),
+			RaydiumAMMv4ProgramID: solana.MustPublicKeyFromBase58("HWy1jotHpo6UqeQxx49dpYYdQB8wj9Qk9MdxwjLvDHB8"),
 		},
 		"mainnet": {
-			RaydiumProgramID: solana.MustPublicKeyFromBase58("CPMMoo8L3F4NbTegBCKVNunggL7H1ZpdTHKxQB5qKP1C"),
-			DefaultRPC:       rpc.MainNetBeta_RPC,
+			RaydiumProgramID:      solana.MustPublicKeyFromBase58("CPMMoo8L3F4NbTegBCKVNunggL7H1ZpdTHKxQB5qKP1C"),
+			DefaultRPC:            rpc.MainNetBeta_RPC,
+			USDCMint:              solana.MustPublicKeyFromBase58("EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"),
+			RaydiumCLMMProgramID:  solana.MustPublicKeyFromBase58("CAMMCzo5YL8w4VFF8KVHrK22GGUsp5VTaW7grrKgrWqK"),
+			RaydiumAMMv4ProgramID: solana.MustPublicKeyFromBase58("675kPX9MHTjS2zt1qfr1NYHuzeLXfQM9H24wFSUt1Mp8"),
+			RaydiumFarmProgramID:  solana.MustPublicKeyFromBase58("FarmqiPv5eAj3j1GMdMCMUGXqPUvmquZtMy86QH6rzhG"),
+		},
+		// A solana-test-validator with the mainnet program (and whatever pools and mints you need) cloned in.
+		"localnet": {
+			RaydiumProgramID:      solana.MustPublicKeyFromBase58("CPMMoo8L3F4NbTegBCKVNunggL7H1ZpdTHKxQB5qKP1C"),
+			DefaultRPC:            rpc.LocalNet_RPC,
+			USDCMint:              solana.MustPublicKeyFromBase58("EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"),
+			RaydiumCLMMProgramID:  solana.MustPublicKeyFromBase58("CAMMCzo5YL8w4VFF8KVHrK22GGUsp5VTaW7grrKgrWqK"),
+			RaydiumAMMv4ProgramID: solana.MustPublicKeyFromBase58("675kPX9MHTjS2zt1qfr1NYHuzeLXfQM9H24wFSUt1Mp8"),
+			RaydiumFarmProgramID:  solana.MustPublicKeyFromBase58("FarmqiPv5eAj3j1GMdMCMUGXqPUvmquZtMy86QH6rzhG"),
 		},
 	}
 )
@@ -74,6 +90,28 @@ func (addr Addr) String() string {
 	return string(rs[:head]) + ellipsis + string(rs[len(rs)-tail:])
 }
 
+// interruptContext is the context a command runs under, done on the first SIGINT or SIGTERM so in-flight RPC calls
+// are cancelled and the command unwinds through its defers. Only the first signal is caught, a second one kills the
+// process the usual way, for when the unwinding is what's stuck.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+// debugLogging turns on debugf, set RAYDIUM_CLIENT_DEBUG to anything to get it.
+var debugLogging = os.Getenv("RAYDIUM_CLIENT_DEBUG") != ""
+
+// debugf logs what's only worth seeing when chasing down something slow or odd.
+func debugf(format string, args ...any) {
+	if debugLogging {
+		log.Printf("debug: "+format, args...)
+	}
+}
+
 func isNativeSOL(mint solana.PublicKey) bool {
 	return mint.Equals(wSOLMint)
 }
@@ -95,9 +133,14 @@ func isAccountMissingErr(err error) bool {
 }
 
 func promptSymbolMappingCLI(symbol string, mint string) (bool, error) {
+	return promptYesNo(fmt.Sprintf("Symbol %s is unknown. Map it to mint %s (%s)?", symbol, mint, Addr(mint)))
+}
+
+// promptYesNo asks question on stdin until it gets a y or an n.
+func promptYesNo(question string) (bool, error) {
 	reader := bufio.NewReader(os.Stdin)
 	for {
-		fmt.Printf("Symbol %s is unknown. Map it to mint %s (%s)? [y/n]: ", symbol, mint, Addr(mint))
+		fmt.Printf("%s [y/n]: ", question)
 		resp, err := reader.ReadString('\n')
 		if err != nil {
 			if errors.Is(err, io.EOF) {
@@ -117,70 +160,6 @@ func promptSymbolMappingCLI(symbol string, mint string) (bool, error) {
 	}
 }
 
-func makeATAIfMissing(ctx context.Context, c *rpc.Client, payer solana.PublicKey, owner solana.PublicKey, mint solana.PublicKey) (solana.PublicKey, []solana.Instruction, error) {
-	ata, _, err := solana.FindAssociatedTokenAddress(owner, mint)
-	if err != nil {
-		return solana.PublicKey{}, nil, err
-	}
-
-	var ixs []solana.Instruction
-	_, err = c.GetAccountInfoWithOpts(ctx, ata, &rpc.GetAccountInfoOpts{
-		Commitment: rpc.CommitmentProcessed,
-	})
-	if err != nil {
-		if !isAccountMissingErr(err) {
-			return solana.PublicKey{}, nil, err
-		}
-	} else {
-		// account exists, nothing to do
-		return ata, nil, nil
-	}
-
-	ix := atapkg.NewCreateInstruction(
-		payer,
-		owner,
-		mint,
-	).Build()
-	ixs = append(ixs, ix)
-
-	return ata, ixs, nil
-}
-
-func wrapNativeIfNeeded(ctx context.Context, c *rpc.Client, owner solana.PublicKey, ata solana.PublicKey, mint solana.PublicKey, required *big.Int) ([]solana.Instruction, error) {
-	if required == nil || required.Sign() <= 0 {
-		return nil, nil
-	}
-	if !isNativeSOL(mint) {
-		return nil, nil
-	}
-	if !required.IsUint64() {
-		return nil, fmt.Errorf("required native amount exceeds uint64")
-	}
-	deficit := new(big.Int).Set(required)
-	balance, err := c.GetTokenAccountBalance(ctx, ata, rpc.CommitmentProcessed)
-	if err != nil {
-		if !isAccountMissingErr(err) {
-			return nil, err
-		}
-	} else if balance != nil && balance.Value != nil {
-		if existing, ok := new(big.Int).SetString(balance.Value.Amount, 10); ok {
-			deficit.Sub(deficit, existing)
-			if deficit.Sign() <= 0 {
-				return nil, nil
-			}
-		}
-	}
-	if !deficit.IsUint64() {
-		return nil, fmt.Errorf("wrap deficit exceeds uint64")
-	}
-	lamports := deficit.Uint64()
-	wrapIxs := []solana.Instruction{
-		system.NewTransferInstruction(lamports, owner, ata).Build(),
-		tokenprog.NewSyncNativeInstruction(ata).Build(),
-	}
-	return wrapIxs, nil
-}
-
 type txSummaryData struct {
 	Signature        solana.Signature
 	Status           string
@@ -191,6 +170,9 @@ type txSummaryData struct {
 	ReceivedAmount   *big.Int
 	ReceivedDecimals uint8
 	ReceivedSymbol   string
+	TradeFee         *big.Int // the pool's trade fee, in the paid token, when the swap's event reports it
+	TxErr            any      // the transaction error reported by the chain, when it failed
+	Logs             []string // the transaction's program logs, what TxErr is decoded with
 }
 
 func renderTxSummary(data txSummaryData) string {
@@ -207,11 +189,17 @@ func renderTxSummary(data txSummaryData) string {
 	t.AppendRow(table.Row{"Status", strings.ToUpper(status)})
 	t.AppendRow(table.Row{"Paid", formatTokenAmount(data.PaidAmount, data.PaidDecimals, data.PaidSymbol)})
 	t.AppendRow(table.Row{"Received", formatTokenAmount(data.ReceivedAmount, data.ReceivedDecimals, data.ReceivedSymbol)})
+	if data.TradeFee != nil {
+		t.AppendRow(table.Row{"Trade fee", formatTokenAmount(data.TradeFee, data.PaidDecimals, data.PaidSymbol)})
+	}
 	feeStr := "n/a"
 	if data.FeeLamports > 0 {
 		feeStr = formatLamports(data.FeeLamports)
 	}
 	t.AppendRow(table.Row{"Fee", feeStr})
+	if failure, ok := decodeProgramFailure(data.TxErr, data.Logs); ok {
+		t.AppendRow(table.Row{"Error", failure.String()})
+	}
 	t.Render()
 	return builder.String()
 }
@@ -220,6 +208,9 @@ func formatTokenAmount(amount *big.Int, decimals uint8, symbol string) string {
 	if amount == nil {
 		return "n/a"
 	}
+	if rawAmounts {
+		return fmt.Sprintf("%s %s", amount, symbol)
+	}
 	precision := int(decimals)
 	if precision > 8 {
 		precision = 8
@@ -227,12 +218,15 @@ func formatTokenAmount(amount *big.Int, decimals uint8, symbol string) string {
 	if precision < 2 {
 		precision = 2
 	}
-	return fmt.Sprintf("%s %s", fmtForDisplay(amount, decimals, precision), symbol)
+	return fmt.Sprintf("%s %s", display.format(amount, decimals, precision), symbol)
 }
 
 func formatLamports(lamports uint64) string {
+	if rawAmounts {
+		return fmt.Sprintf("%d lamports", lamports)
+	}
 	val := new(big.Int).SetUint64(lamports)
-	return fmt.Sprintf("%s SOL", fmtForDisplay(val, 9, 9))
+	return fmt.Sprintf("%s SOL", display.format(val, 9, 9))
 }
 
 func tokenBalanceAmount(balances []rpc.TokenBalance, accountIndex int, mint solana.PublicKey) (*big.Int, bool) {
@@ -286,17 +280,18 @@ func tokenDeltaFromResult(result *rpc.GetTransactionResult, account solana.Publi
 }
 
 func waitForTransactionResult(ctx context.Context, client *rpc.Client, sig solana.Signature) (string, *rpc.GetTransactionResult, error) {
+	// NOTE(@hadydotai): No timeout of our own, callers hand us what's left of their send deadline.
 	status := "pending"
-	waitCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
 	for {
 		select {
-		case <-waitCtx.Done():
-			return status, nil, waitCtx.Err()
+		case <-ctx.Done():
+			return status, nil, ctx.Err()
 		default:
-			resp, err := client.GetTransaction(waitCtx, sig, &rpc.GetTransactionOpts{
+			resp, err := client.GetTransaction(ctx, sig, &rpc.GetTransactionOpts{
 				Encoding:   solana.EncodingBase64,
 				Commitment: rpc.CommitmentConfirmed,
+				// Jupiter's transactions are v0, ours are legacy, this covers both.
+				MaxSupportedTransactionVersion: ptrTo(rpc.MaxSupportedTransactionVersion0),
 			})
 			if err != nil {
 				if errors.Is(err, rpc.ErrNotFound) {
@@ -305,7 +300,7 @@ func waitForTransactionResult(ctx context.Context, client *rpc.Client, sig solan
 				}
 				return status, nil, err
 			}
-			status = deriveSignatureStatus(waitCtx, client, sig, resp)
+			status = deriveSignatureStatus(ctx, client, sig, resp)
 			return status, resp, nil
 		}
 	}
@@ -339,86 +334,311 @@ func deriveSignatureStatus(ctx context.Context, client *rpc.Client, sig solana.S
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := lookupCommand(os.Args[1]); ok {
+			runCommandOrExit(cmd, os.Args[2:])
+		}
+	}
+	err := runSwapCLI()
+	switch {
+	case errors.Is(err, errRetryDeclined):
+		log.Println("Aborting...")
+		os.Exit(exitFailure)
+	case errors.Is(err, context.Canceled):
+		log.Println("Interrupted.")
+		os.Exit(exitFailure)
+	case err != nil:
+		log.Println(err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// runSwapCLI is the swap itself, everything that isn't a subcommand. It returns instead of exiting so whatever it
+// deferred (the terminal, in-flight state) gets cleaned up on the way out.
+func runSwapCLI() error {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags]\n       %s <command> [subcommand] [flags]\n\n", os.Args[0], os.Args[0])
+		fmt.Fprint(flag.CommandLine.Output(), commandUsage())
+		fmt.Fprintf(flag.CommandLine.Output(), "\nFlags:\n")
+		flag.PrintDefaults()
+	}
 	var (
-		hotwalletPath = flag.String("hotwallet", "", "Path to the hotwallet to use for signing transactions")
-		rpcEP         = flag.String("rpc", rpc.DevNet_RPC, "RPC to connect to")
-		network       = flag.String("network", "devnet", "Network to connect to, accepted values are 'mainnet', or 'devnet'")
-		poolAddr      = flag.String("pool", "", "Pool to interact with")
-		intentLine    = flag.String("intent", "", "Intent (<verb> <amount> <token-symbol>), e.g. \"pay 1 SOL\"")
-		slippagePct   = flag.Float64("slippage", 0.5, "Slippage tolerance percentage (e.g. 0.5 for 0.5%)")
-		noTUI         = flag.Bool("no-tui", false, "Don't enter TUI")
+		hotwalletPath    = flag.String("hotwallet", "", "Path to the hotwallet to use for signing transactions")
+		rpcEP            = flag.String("rpc", "", "RPC to connect to, the -network's public endpoint when empty")
+		network          = flag.String("network", "devnet", "Network to connect to, accepted values are 'mainnet', 'devnet' or 'localnet'")
+		poolAddr         = flag.String("pool", "", "Pool to interact with")
+		intentLine       = flag.String("intent", "", "Intent (<verb> <amount> <token-symbol>), e.g. \"pay 1 SOL\"")
+		noTUI            = flag.Bool("no-tui", false, "Don't enter TUI")
+		exportBundle     = flag.String("export-bundle", "", "Write the planned transaction to a reviewable JSON bundle at this path instead of sending it")
+		dryRun           = flag.Bool("dry-run", false, "Simulate the swap an instruction at a time and show where every lamport and token goes, nothing is sent, needs -no-tui")
+		executeBundle    = flag.String("execute-bundle", "", "Execute a previously exported and approved JSON bundle from this path")
+		bundleHash       = flag.String("bundle-hash", "", "Approved SHA-256 hash of the bundle passed to -execute-bundle")
+		watch            = flag.Duration("watch", 0, "Re-quote -intent on this interval (e.g. 5s) and print each quote, nothing is sent")
+		watchJSON        = flag.Bool("watch-json", false, "With -watch, print each quote as a JSON event instead of a line")
+		fallbackPools    = flag.Bool("fallback-pools", false, "When a swap fails because of the pool (paused, drained, slippage), offer to retry on the next best pool for the pair")
+		comparePairPools = flag.Bool("compare", false, "Quote -intent on every CP-Swap pool for the pair, show them side by side and exit, nothing is sent")
+		bestPairPool     = flag.Bool("best", false, "Quote -intent on every CP-Swap pool for the pair and use the one with the best quote")
+		via              = flag.String("via", "raydium", "Route the swap 'raydium' (the pool only) or 'jupiter' (compare with Jupiter's quote and use the better one), needs -no-tui")
+		splitPools       = flag.Int("split", 0, "Split -intent across up to this many (2 or 3) CP-Swap pools for the pair to cut price impact, sent as one transaction, needs -no-tui")
+		yes              = flag.Bool("yes", false, "With -no-tui, confirm up front that the swap should be sent once it's quoted")
+		confirmPriceAt   *big.Rat
 	)
+	flag.Func("confirm-price", "With -no-tui, re-quote right before sending and abort if the token's price in the other token is further from this than -slippage", func(s string) error {
+		price, ok := new(big.Rat).SetString(s)
+		if !ok || price.Sign() <= 0 {
+			return fmt.Errorf("confirm-price has to be a positive price, got %q", s)
+		}
+		confirmPriceAt = price
+		return nil
+	})
+	slippage := addSlippageFlags(flag.CommandLine)
+	slippagePct := &slippage.pct
+	addAmountFlags(flag.CommandLine)
+	flag.Var(&deadlines, "deadlines", deadlinesUsage)
+	addPriceFlags(flag.CommandLine)
+	addExplorerFlag(flag.CommandLine)
+	addProgramFlags(flag.CommandLine)
+	addQuorumFlags(flag.CommandLine)
+	addCloseEmptyATAsFlag(flag.CommandLine)
+	addRebroadcastFlags(flag.CommandLine)
+	addMinOutFromSimFlag(flag.CommandLine)
+	addComputeBudgetFlags(flag.CommandLine)
+	addSandwichFlags(flag.CommandLine)
+	addTradeHookFlags(flag.CommandLine)
+	addMintPolicyFlags(flag.CommandLine)
+	addSpendLimitFlags(flag.CommandLine)
+	addApprovalFlags(flag.CommandLine)
+	addNudgeFlag(flag.CommandLine)
+	addQuoteCacheFlag(flag.CommandLine)
+	aliasesPath := addAliasesFlag(flag.CommandLine)
+	tokenListPath := addTokenListFlag(flag.CommandLine)
+	poolIndexPath := addPoolIndexFlag(flag.CommandLine)
+	enhancedAPIName := addEnhancedAPIFlag(flag.CommandLine)
+	raydiumAPIEnabled := addRaydiumAPIFlag(flag.CommandLine)
+	fixtures := addRPCFixtureFlags(flag.CommandLine)
+	snapshots := addSnapshotFlags(flag.CommandLine)
+	chunking := addChunkFlags(flag.CommandLine)
+	twapping := addTWAPFlags(flag.CommandLine)
+	squads := addSquadsFlags(flag.CommandLine)
 	flag.Parse()
 
+	var replay *runSnapshot
+	if *snapshots.replay != "" {
+		var err error
+		if replay, err = loadRunSnapshot(*snapshots.replay); err != nil {
+			return err
+		}
+		replay.fill(flag.CommandLine, network, poolAddr, intentLine)
+		*noTUI = true
+	}
+
 	validations := []FlagSpec{
 		{Name: "rpc", Value: rpcEP, Rules: []FlagRule{Requires("network")}},
-		{Name: "network", Value: network, Rules: []FlagRule{NotEmpty(), OneOf("mainnet", "devnet")}},
-		{Name: "hotwallet", Value: hotwalletPath, Rules: []FlagRule{NotEmpty()}},
-		{Name: "pool", Value: poolAddr, Rules: []FlagRule{NotEmpty()}},
+		{Name: "via", Value: via, Rules: []FlagRule{OneOf("raydium", "jupiter")}},
+		{Name: "network", Value: network, Rules: []FlagRule{NotEmpty(), OneOf("mainnet", "devnet", "localnet")}},
+	}
+	validations = append(validations, priceSpecs()...)
+	// NOTE(@hadydotai): Without -hotwallet the client is read-only. Quoting, watching and comparing only read pools,
+	// there's nothing to sign so no reason to demand a wallet, only what sends or plans a transaction for one does.
+	readOnly := *hotwalletPath == ""
+	if readOnly && (*executeBundle != "" || *exportBundle != "" || *dryRun || *splitPools != 0 || chunking.enabled() || twapping.enabled() || squads.enabled() || *fallbackPools || *via == "jupiter") {
+		validations = append(validations, FlagSpec{Name: "hotwallet", Value: hotwalletPath, Rules: []FlagRule{NotEmpty()}})
+	}
+	if *executeBundle != "" {
+		validations = append(validations, FlagSpec{Name: "bundle-hash", Value: bundleHash, Rules: []FlagRule{NotEmpty()}})
+	} else {
+		validations = append(validations, FlagSpec{Name: "pool", Value: poolAddr, Rules: []FlagRule{NotEmpty()}})
 	}
-	if *noTUI {
+	if (*noTUI || *watch > 0 || *comparePairPools || *bestPairPool || *splitPools != 0) && *executeBundle == "" {
 		validations = append(validations, FlagSpec{Name: "intent", Value: intentLine, Rules: []FlagRule{NotEmpty()}})
 	}
 	ValidateConfigOrExit(flag.CommandLine, validations)
-
-	raydium_cp_swap.ProgramID = networks[*network][RaydiumProgramID].(solana.PublicKey)
-	if len(*rpcEP) == 0 {
-		*rpcEP = networks[*network][DefaultRPC].(string)
+	if replay != nil && (!readOnly || *executeBundle != "" || *exportBundle != "" || *watch > 0 || *comparePairPools || *bestPairPool || *splitPools != 0 || *via == "jupiter" || chunking.enabled() || squads.enabled() || *snapshots.record != "" || *fixtures.record != "" || *fixtures.replay != "") {
+		return errors.New("-replay quotes the snapshot's intent again and nothing else, it doesn't go with -hotwallet, bundles, -watch, -compare, -best, -split, -via jupiter, -chunk-above, -squads-vault, -snapshot or the -rpc fixture flags")
+	}
+	if err := slippage.validate(); err != nil {
+		return err
+	}
+	if *snapshots.record != "" && (*fixtures.record != "" || *fixtures.replay != "") {
+		return errors.New("-snapshot records the run's RPC calls itself, it doesn't go with -rpc-record or -rpc-replay")
+	}
+	*via = strings.ToLower(strings.TrimSpace(*via))
+	if *via == "jupiter" {
+		switch {
+		case *network != "mainnet":
+			return errors.New("-via jupiter only works on mainnet, jupiter doesn't route devnet")
+		case !*noTUI || *exportBundle != "" || *executeBundle != "" || *splitPools != 0:
+			return errors.New("-via jupiter sends whichever route wins as soon as it's compared, it needs -no-tui and doesn't go with bundles or -split")
+		}
+	}
+	if *splitPools != 0 {
+		switch {
+		case *splitPools < 2 || *splitPools > maxSplitPools:
+			return fmt.Errorf("-split takes 2 to %d pools", maxSplitPools)
+		case !*noTUI || *exportBundle != "" || *executeBundle != "":
+			return errors.New("-split sends the route as soon as it's planned, it needs -no-tui and doesn't go in bundles")
+		}
+	}
+	// NOTE(@hadydotai): Without the TUI there's no screen to say yes on, the report scrolls by and the swap goes out
+	// right behind it. Anything that sends has to be told to up front, a script that forgot to is refused before it
+	// touches the chain.
+	if *dryRun && (!*noTUI || *exportBundle != "" || *executeBundle != "" || *splitPools != 0 || *via == "jupiter" || chunking.enabled() || twapping.enabled() || squads.enabled() || *watch > 0 || *comparePairPools) {
+		return errors.New("-dry-run simulates a single swap, it needs -no-tui and doesn't go with bundles, -split, -via jupiter, -chunk-above, -twap-window, -squads-vault, -watch or -compare")
+	}
+	if *noTUI && !readOnly && !*yes && !*dryRun && *exportBundle == "" && *executeBundle == "" && *watch == 0 && !*comparePairPools {
+		return errors.New("-no-tui sends the swap as soon as it's quoted, pass -yes to confirm that's what you want, or drop -no-tui to confirm it in the TUI")
+	}
+	if confirmPriceAt != nil && (!*noTUI || *exportBundle != "" || *executeBundle != "" || *splitPools != 0) {
+		return errors.New("-confirm-price checks the quote right before it's sent, it needs -no-tui and doesn't go with bundles or -split")
+	}
+	var chunks chunkPlan
+	if chunking.enabled() {
+		var err error
+		if chunks, err = chunking.plan(); err != nil {
+			return err
+		}
+		if !*noTUI || *exportBundle != "" || *executeBundle != "" || *splitPools != 0 || *via == "jupiter" || *fallbackPools {
+			return errors.New("-chunk-above sends the chunks one after the other as they're quoted, it needs -no-tui and doesn't go with bundles, -split, -via jupiter or -fallback-pools")
+		}
+	}
+	var twap twapPlan
+	if twapping.enabled() {
+		var err error
+		if twap, err = twapping.plan(); err != nil {
+			return err
+		}
+		if !*noTUI || *exportBundle != "" || *executeBundle != "" || *splitPools != 0 || *via == "jupiter" || chunking.enabled() || squads.enabled() || *fallbackPools || *watch > 0 {
+			return errors.New("-twap-window sends the slices one after the other as they come due, it needs -no-tui and doesn't go with bundles, -split, -via jupiter, -chunk-above, -squads-vault, -fallback-pools or -watch")
+		}
 	}
-	client := rpc.New(*rpcEP)
-
-	// NOTE(@hadydotai): A latest blockhash transaction will likely invalidate in anycase after about a minute,
-	// so this leaves us with about 2 minutes of working time, if our RPC node is that slow, then we've got a problem.
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
-	defer cancel()
 
-	payer, err := solana.PrivateKeyFromSolanaKeygenFile(*hotwalletPath)
-	if err != nil {
-		log.Fatalf("failed to load private key from hot wallet: %s\n", err)
+	var squadsVault solana.PublicKey
+	if squads.enabled() {
+		if !*noTUI || *exportBundle != "" || *executeBundle != "" || *splitPools != 0 || *via == "jupiter" || chunking.enabled() || *fallbackPools || *watch > 0 {
+			return errors.New("-squads-vault proposes a single swap, it needs -no-tui and doesn't go with bundles, -split, -via jupiter, -chunk-above, -fallback-pools or -watch")
+		}
+		var err error
+		if squadsVault, err = squads.vault(); err != nil {
+			return err
+		}
 	}
 
-	poolPubK, err := solana.PublicKeyFromBase58(*poolAddr)
-	if err != nil {
-		log.Fatalf("deriving public key from pool address (base58) failed, make sure it's base58 encoded: %s\n", err)
+	useNetworkPrograms(*network)
+	computeBudget.useNetwork(*network)
+	usePoolIndexFile(*poolIndexPath, *network)
+	if len(*rpcEP) == 0 {
+		*rpcEP = networks[*network][DefaultRPC].(string)
 	}
-	accountInfo, err := client.GetAccountInfoWithOpts(ctx, poolPubK, &rpc.GetAccountInfoOpts{Encoding: solana.EncodingBase64})
-	if err != nil {
-		log.Fatalf("rpc call getAccountInfo for Pool failed, check if the RPC endpoint is valid, or if you're being limited: %s\n", err)
+	useEnhancedAPI(*enhancedAPIName, *rpcEP, *network)
+	useRaydiumAPI(*raydiumAPIEnabled, *network)
+	var (
+		client   *rpc.Client
+		recorder *snapshotRecorder
+	)
+	switch {
+	case replay != nil:
+		client = replay.replayClient()
+	case *snapshots.record != "":
+		recorder = newSnapshotRecorder(*snapshots.record, *network, *poolAddr, *intentLine)
+		client = recorder.dial(*rpcEP)
+	default:
+		client = fixtures.dial(*rpcEP)
+	}
+	useCluster(client, *network, *fixtures.replay != "" || replay != nil)
+	useAliasesFile(*aliasesPath)
+	useTokenListFile(*tokenListPath)
+
+	// NOTE(@hadydotai): The process context only ends on interrupt, every operation runs under its own deadline from
+	// -deadlines (see deadlines.go), a slow metadata lookup can't eat into the time a swap needs to land.
+	ctx, stop := interruptContext()
+	defer stop()
+	if *watch > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = deadlines.forWatch(ctx)
+		defer cancel()
+	}
+
+	var payer solana.PrivateKey
+	if !readOnly {
+		var err error
+		payer, err = solana.PrivateKeyFromSolanaKeygenFile(*hotwalletPath)
+		if err != nil {
+			return fmt.Errorf("failed to load private key from hot wallet: %w", err)
+		}
+		recorder.useWallet(payer.PublicKey())
 	}
 
-	pool, err := raydium_cp_swap.ParseAccount_PoolState(accountInfo.Value.Data.GetBinary())
-	if err != nil {
-		log.Fatalf("parsing PoolState failed, make sure the pool address you passed is a Raydium CP-Swap/CPMM pool: %s\n", err)
+	if *executeBundle != "" {
+		bundle, err := readTxBundle(*executeBundle, *bundleHash)
+		if err != nil {
+			return err
+		}
+		if err := executeTxBundle(ctx, client, payer, bundle, *network); err != nil {
+			return fmt.Errorf("executing bundle failed: %w", err)
+		}
+		return nil
 	}
 
-	poolAmm, err := client.GetAccountInfoWithOpts(ctx, pool.AmmConfig, &rpc.GetAccountInfoOpts{Encoding: solana.EncodingBase64})
+	flow := &swapFlow{client: client, payer: payer, network: *network, intentLine: *intentLine, slippagePct: *slippagePct, out: os.Stdout}
+	builder, poolPubK, err := flow.openPool(ctx, *poolAddr)
+	if errors.Is(err, errNoPoolForPair) && *via == "jupiter" {
+		// No direct pool to compare with, Jupiter is the only route there is.
+		if err := flow.jupiterOnly(ctx, *poolAddr); err != nil {
+			return err
+		}
+		return nil
+	}
 	if err != nil {
-		log.Fatalf("rpc call getAccountInfo for Pool's AmmConfig failed, check if the RPC endpoint is valid, or if you're being limited: %s\n", err)
+		return err
 	}
-	if poolAmm == nil || poolAmm.Value == nil {
-		log.Fatalf("amm config account %s returned no data\n", pool.AmmConfig)
+	if squads.enabled() {
+		// The vault is the wallet being swapped from, percentage amounts are of its balances.
+		builder.useWallet(squadsVault)
+		recorder.useWallet(squadsVault)
 	}
-	poolAmmConfig, err := raydium_cp_swap.ParseAccount_AmmConfig(poolAmm.Value.Data.GetBinary())
-	if err != nil {
-		// NOTE(@hadydotai): Just occurred to me, if the pool is inactive, are we going to end up here?
-		log.Fatalf("parsing pool's AmmConfig failed: %s\n", err)
+	if replay != nil && replay.Wallet != "" {
+		wallet, err := solana.PublicKeyFromBase58(replay.Wallet)
+		if err != nil {
+			return fmt.Errorf("the snapshot's wallet %q isn't a key: %w", replay.Wallet, err)
+		}
+		builder.useWallet(wallet)
 	}
 
-	tokenMints := []solana.PublicKey{pool.Token0Mint, pool.Token1Mint}
-	symm := makeSymbolMapping(ctx, client, tokenMints)
+	if *comparePairPools || *bestPairPool {
+		if err := flow.compare(ctx, builder, poolPubK, *bestPairPool); err != nil {
+			return err
+		}
+		if *comparePairPools {
+			return nil
+		}
+	}
+	if slippage.auto {
+		_, pool := builder.currentPool()
+		pct, why, err := slippage.resolve(ctx, client, pool)
+		if err != nil {
+			return err
+		}
+		if err := builder.SetSlippagePct(pct); err != nil {
+			return err
+		}
+		*slippagePct, flow.slippagePct = pct, pct
+		fmt.Fprintln(os.Stdout, why)
+	}
 
-	builder := &TableBuilder{
-		ctx:               ctx,
-		client:            client,
-		pool:              pool,
-		poolAmmConfig:     poolAmmConfig,
-		poolAddress:       *poolAddr,
-		poolPubKey:        poolPubK,
-		symm:              symm,
-		userSymbolAliases: make(map[string]solana.PublicKey),
+	if *splitPools != 0 {
+		if err := flow.split(ctx, builder, *splitPools); err != nil {
+			return err
+		}
+		return nil
 	}
-	if err := builder.SetSlippagePct(*slippagePct); err != nil {
-		log.Fatalf("invalid slippage: %s\n", err)
+
+	if *watch > 0 {
+		err := watchIntent(ctx, builder, *intentLine, *watch, *watchJSON, os.Stdout)
+		log.Println(builder.cache.stats())
+		if err != nil {
+			return fmt.Errorf("watching intent failed: %w", err)
+		}
+		return nil
 	}
 
 	var (
@@ -427,164 +647,104 @@ func main() {
 	)
 
 	if *noTUI {
-		for {
-			report, intentMeta, err = builder.Build(*intentLine)
-			if err == nil {
-				break
-			}
-			var mapErr *MissingSymbolMappingError
-			if errors.As(err, &mapErr) {
-				mapped, promptErr := promptSymbolMappingCLI(mapErr.Symbol, mapErr.Mint)
-				if promptErr != nil {
-					log.Fatalf("failed to prompt for symbol mapping: %s\n", promptErr)
-				}
-				if !mapped {
-					log.Fatalf("symbol %s remains unmapped; aborting\n", mapErr.Symbol)
-				}
-				symm.MapSymToMint(mapErr.Symbol, mapErr.Mint)
-				continue
-			}
-			log.Fatalf("building intent report failed: %s\n", err)
+		spendLimits.confirm = promptYesNo
+		report, intentMeta, err = flow.quote(builder, promptSymbolMappingCLI)
+		if err != nil {
+			// An intent that can't be quoted still has its report, saying why.
+			fmt.Fprint(os.Stdout, report)
+			return err
 		}
 		_, _ = fmt.Fprintf(os.Stdout, "%s", report)
+		if replay != nil {
+			if err := replay.checkQuote(newQuoteResponse(builder, intentMeta, *slippagePct)); err != nil {
+				return err
+			}
+			fmt.Fprintln(os.Stdout, "Replayed offline, the quote matches the snapshot.")
+			return nil
+		}
+		recorder.addQuote(newQuoteResponse(builder, intentMeta, *slippagePct))
+		if readOnly {
+			fmt.Fprintln(os.Stdout, "Read-only, no -hotwallet loaded, nothing was sent.")
+			return nil
+		}
 	} else {
-		ui := newTermUI(builder)
-		intentMeta, report, err = ui.Run(*intentLine)
+		// NOTE(@hadydotai): When exporting a bundle the TUI must not send anything, so it only resolves the intent
+		// and hands it back like it used to.
+		var executor *swapExecutor
+		if *exportBundle == "" {
+			executor = &swapExecutor{ctx: ctx, client: client, payer: payer, network: *network, fallbackPools: *fallbackPools}
+		}
+		ui := newTermUI(builder, executor)
+		ui.readOnly = readOnly
+		initialIntent := *intentLine
+		if initialIntent == "" {
+			initialIntent, ui.statusMessage = defaultIntent(ctx, builder)
+		}
+		intentMeta, report, err = ui.Run(ctx, initialIntent)
+		if stats := builder.cache.stats(); stats.quotes > 1 {
+			log.Println(stats)
+		}
+		if executor != nil {
+			for _, receipt := range ui.Receipts() {
+				fmt.Fprintln(os.Stdout, receipt)
+			}
+		}
 		if err != nil {
-			log.Fatalf("interactive UI failed: %s\n", err)
+			return fmt.Errorf("interactive UI failed: %w", err)
+		}
+		if executor != nil {
+			return nil
 		}
 		if intentMeta == nil { // user has chosen to reject or bailout
 			log.Println("Aborting...")
-			os.Exit(0)
+			return nil
 		}
 		if report != "" {
 			fmt.Fprintln(os.Stdout, report)
 		}
+		recorder.addQuote(newQuoteResponse(builder, intentMeta, *slippagePct))
 	}
 	if intentMeta == nil {
-		log.Fatalln("intent resolution failed, no transaction to build")
+		return errors.New("intent resolution failed, no transaction to build")
 	}
-	// now we do the swap, finally.
-	payerPub := payer.PublicKey()
-	inATA, inATAixs, err := makeATAIfMissing(ctx, client, payerPub, payerPub, intentMeta.TokenIn.Mint)
-	if err != nil {
-		log.Fatalf("attempts to get/make ATA for input token failed: %s\n", err)
+	if *exportBundle != "" {
+		if err := flow.exportBundle(ctx, builder, intentMeta, *exportBundle); err != nil {
+			return err
+		}
+		return nil
 	}
-	outATA, outATAixs, err := makeATAIfMissing(ctx, client, payerPub, payerPub, intentMeta.TokenOut.Mint)
-	if err != nil {
-		log.Fatalf("attempts to get/make ATA for output token failed: %s\n", err)
+	if *dryRun {
+		return flow.dryRun(ctx, builder, intentMeta)
 	}
-	inATACreated := len(inATAixs) > 0
-
-	auth, _, err := solana.FindProgramAddress(
-		[][]byte{[]byte("vault_and_lp_mint_auth_seed")}, // https://github.com/raydium-io/raydium-cp-swap/blob/master/programs/cp-swap/src/lib.rs#L43
-		raydium_cp_swap.ProgramID,
-	)
-	if err != nil {
-		log.Fatalf("rpc call findProgramAddress failed: %s \n", err)
+	if confirmPriceAt != nil {
+		if intentMeta, err = flow.confirmPrice(builder, confirmPriceAt); err != nil {
+			return err
+		}
 	}
-	swapIx, err := intentMeta.BuildSwapInstruction(
-		payerPub,
-		auth,
-		inATA,
-		outATA,
-	)
-	if err != nil {
-		log.Fatalf("failed to build swap instruction: %s\n", err)
+	if squads.enabled() {
+		return flow.proposeSquads(ctx, intentMeta, squads)
 	}
-
-	// NOTE(@hadydotai): I guess we don't need this, but maybe we can expose it to the user
-	cb1 := computebudget.NewSetComputeUnitLimitInstruction(uint32(DefaultUnitLimit)).Build()
-	cb2 := computebudget.NewSetComputeUnitPriceInstruction(DefaultUnitPrice).Build()
-
-	requiredInput := intentMeta.RequiredInputAmount()
-	if requiredInput == nil {
-		log.Fatalln("required input amount missing for swap")
+	if over, why := needsApproval(ctx, newHookSwap(intentMeta, builder.symbols(), payer.PublicKey())); over {
+		return flow.requestApproval(ctx, builder, intentMeta, "", why)
 	}
-	wrapIxs, err := wrapNativeIfNeeded(ctx, client, payerPub, inATA, intentMeta.TokenIn.Mint, requiredInput)
-	if err != nil {
-		log.Fatalf("wrapping native token failed: %s\n", err)
-	}
-
-	var ixs []solana.Instruction
-	ixs = append(ixs, cb1, cb2)
-	ixs = append(ixs, inATAixs...)
-	ixs = append(ixs, outATAixs...)
-	ixs = append(ixs, wrapIxs...)
-	ixs = append(ixs, swapIx)
-	// NOTE(@hadydotai): Was mulling over the transactions and realized I don't close the wSOL temporary ATA we create when
-	// dealing with SOL. Then a thought struck me, if we accidently close the output ATA we'll burn the money we just received.
-	// So this right here, is a very fucking critical. Any wrong state in any of these values, and we're cooking money.
-	if isNativeSOL(intentMeta.TokenIn.Mint) && inATACreated {
-		closeIx := tokenprog.NewCloseAccountInstructionBuilder().
-			SetAccount(inATA).
-			SetDestinationAccount(payerPub).
-			SetOwnerAccount(payerPub).
-			Build()
-		ixs = append(ixs, closeIx)
-	}
-
-	recent, err := client.GetLatestBlockhash(ctx, rpc.CommitmentFinalized)
-	if err != nil {
-		log.Fatalf("rpc call getLatestBlockhash failed: %s\n", err)
+	if *via == "jupiter" {
+		sent, err := flow.preferJupiter(ctx, builder, intentMeta)
+		if err != nil {
+			return err
+		}
+		if sent {
+			return nil
+		}
 	}
-	tx, err := solana.NewTransaction(
-		ixs,
-		recent.Value.Blockhash,
-		solana.TransactionPayer(payerPub),
-	)
-	if err != nil {
-		log.Fatalf("building transaction failed: %s\n", err)
+	if twapping.enabled() {
+		return flow.twap(ctx, builder, intentMeta, twap)
 	}
-	if _, err := tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
-		if key.Equals(payerPub) {
-			return &payer
+	if chunking.enabled() && chunks.applies(intentMeta) {
+		if err := flow.chunked(ctx, builder, intentMeta, chunks); err != nil {
+			return err
 		}
 		return nil
-	}); err != nil {
-		log.Fatalf("signing transaction failed: %s\n", err)
 	}
-
-	sig, err := client.SendTransaction(ctx, tx)
-	if err != nil {
-		log.Fatalf("sending transaction failed: %s\n", err.Error())
-	}
-	log.Println("Tx: ", sig.String())
-	status, txResult, waitErr := waitForTransactionResult(ctx, client, sig)
-	if waitErr != nil && !errors.Is(waitErr, context.DeadlineExceeded) && !errors.Is(waitErr, context.Canceled) {
-		log.Printf("warning: waiting for transaction confirmation failed: %v", waitErr)
-	}
-	var txMeta *rpc.TransactionMeta
-	if txResult != nil {
-		txMeta = txResult.Meta
-	}
-	feeLamports := uint64(0)
-	if txMeta != nil {
-		feeLamports = txMeta.Fee
-	}
-	var paidDelta, receivedDelta *big.Int
-	if delta, ok := tokenDeltaFromResult(txResult, intentMeta.TokenIn.Vault, intentMeta.TokenIn.Mint); ok {
-		if delta.Sign() < 0 {
-			delta.Neg(delta)
-		}
-		paidDelta = delta
-	}
-	if delta, ok := tokenDeltaFromResult(txResult, intentMeta.TokenOut.Vault, intentMeta.TokenOut.Mint); ok {
-		if delta.Sign() > 0 {
-			delta = new(big.Int).Neg(delta)
-		}
-		receivedDelta = new(big.Int).Abs(delta)
-	}
-	summary := renderTxSummary(txSummaryData{
-		Signature:        sig,
-		Status:           status,
-		FeeLamports:      feeLamports,
-		PaidAmount:       paidDelta,
-		PaidDecimals:     intentMeta.TokenIn.Decimals,
-		PaidSymbol:       symm.SymFrom(intentMeta.TokenIn.Mint),
-		ReceivedAmount:   receivedDelta,
-		ReceivedDecimals: intentMeta.TokenOut.Decimals,
-		ReceivedSymbol:   symm.SymFrom(intentMeta.TokenOut.Mint),
-	})
-	fmt.Fprintln(os.Stdout, summary)
+	// now we do the swap, finally.
+	return flow.swap(ctx, builder, intentMeta, *fallbackPools, promptYesNo)
 }
@@ -3,7 +3,9 @@ package main
 import (
 	"encoding/json"
 	"math/big"
+	"syscall"
 	"testing"
+	"time"
 
 	solana "github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/rpc"
@@ -78,3 +80,16 @@ func TestTokenDeltaFromResultRequiresBalances(t *testing.T) {
 		t.Fatalf("expected no delta when balances missing")
 	}
 }
+
+func TestInterruptContext(t *testing.T) {
+	ctx, stop := interruptContext()
+	defer stop()
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("SIGTERM didn't cancel the context")
+	}
+}
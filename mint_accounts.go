@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): Mint accounts.
+
+Decimals used to come from one place, the getTokenAccountBalance answer for each vault. When that call failed there
+was nothing to quote with, and when an RPC got it wrong there was nothing to check it against. The mint is where
+decimals actually live, so both of a pool's mints are read when it loads, in one getMultipleAccounts, and kept on the
+loadedPool. Decimals never change after a mint is created, reading them once is enough. Supply does change, it's
+only shown.
+
+Reading the reserves (poolReserves) then checks each balance's decimals against its mint and refuses a balance that
+disagrees, quoting with the wrong decimals is off by orders of magnitude. A vault whose balance call failed is read
+directly instead, again one getMultipleAccounts, the amount decoded out of the token account and the decimals taken
+from the mint.
+
+Both token programs share these layouts, Token-2022 appends its extensions after them:
+
+	Mint (82 bytes)                              Token account (165 bytes)
+	 0  mint_authority    COption<Pubkey>  36     0  mint    Pubkey  32
+	36  supply            u64               8    32  owner   Pubkey  32
+	44  decimals          u8                1    64  amount  u64      8
+	45  is_initialized    bool              1    ...
+	46  freeze_authority  COption<Pubkey>  36
+
+COption is a u32 tag, 0 for none and 1 for some, followed by the 32 bytes either way. A Token-2022 account with
+extensions is padded to 165 bytes and carries its AccountType right after, 1 for a mint, which is the only way to tell
+a mint with extensions from a token account.
+*/
+
+// tokenAccountAmountOffset is where a token account's amount sits, the lengths and account types are with the
+// Token-2022 metadata parsing in token_metadata.go.
+const tokenAccountAmountOffset = 64
+
+// mintAccount is what we read out of an SPL Token or Token-2022 mint.
+type mintAccount struct {
+	Address         solana.PublicKey
+	Program         solana.PublicKey
+	Supply          uint64
+	Decimals        uint8
+	MintAuthority   *solana.PublicKey // nil once minting is disabled
+	FreezeAuthority *solana.PublicKey // nil when token accounts can't be frozen
+}
+
+func decodeCOptionPubkey(b []byte) (*solana.PublicKey, error) {
+	switch tag := binary.LittleEndian.Uint32(b[:4]); tag {
+	case 0:
+		return nil, nil
+	case 1:
+		pk := solana.PublicKeyFromBytes(b[4:36])
+		return &pk, nil
+	default:
+		return nil, fmt.Errorf("invalid option tag %d", tag)
+	}
+}
+
+func describeAuthority(authority *solana.PublicKey) string {
+	if authority == nil {
+		return "none"
+	}
+	return Addr(authority.String()).String()
+}
+
+// authorityString is authority for JSON, nil when it's been revoked.
+func authorityString(authority *solana.PublicKey) *string {
+	if authority == nil {
+		return nil
+	}
+	return ptrTo(authority.String())
+}
+
+func decodeMintAccount(data []byte) (*mintAccount, error) {
+	if len(data) < baseMintLen {
+		return nil, fmt.Errorf("account is %d bytes, a mint is at least %d", len(data), baseMintLen)
+	}
+	if len(data) == baseAccountLen || (len(data) > baseAccountLen && data[baseAccountLen] != accountTypeMint) {
+		return nil, errors.New("account is a token account, not a mint")
+	}
+	if data[45] != 1 {
+		return nil, errors.New("mint isn't initialized")
+	}
+	mintAuthority, err := decodeCOptionPubkey(data[0:36])
+	if err != nil {
+		return nil, fmt.Errorf("mint authority: %w", err)
+	}
+	freezeAuthority, err := decodeCOptionPubkey(data[46:82])
+	if err != nil {
+		return nil, fmt.Errorf("freeze authority: %w", err)
+	}
+	return &mintAccount{
+		Supply:          binary.LittleEndian.Uint64(data[36:44]),
+		Decimals:        data[44],
+		MintAuthority:   mintAuthority,
+		FreezeAuthority: freezeAuthority,
+	}, nil
+}
+
+// fetchMintAccounts reads and decodes mints in a single getMultipleAccounts, in the order given.
+func fetchMintAccounts(ctx context.Context, client *rpc.Client, mints ...solana.PublicKey) ([]*mintAccount, error) {
+	res, err := client.GetMultipleAccountsWithOpts(ctx, mints, &rpc.GetMultipleAccountsOpts{Encoding: solana.EncodingBase64})
+	if err != nil {
+		return nil, fmt.Errorf("rpc call getMultipleAccounts for mints failed: %w", err)
+	}
+	if res == nil || len(res.Value) != len(mints) {
+		return nil, errors.New("rpc call getMultipleAccounts for mints returned the wrong number of accounts")
+	}
+	out := make([]*mintAccount, len(mints))
+	for i, acc := range res.Value {
+		if acc == nil {
+			return nil, fmt.Errorf("mint %s doesn't exist", Addr(mints[i].String()))
+		}
+		if !acc.Owner.Equals(solana.TokenProgramID) && !acc.Owner.Equals(solana.Token2022ProgramID) {
+			return nil, fmt.Errorf("%s isn't a token mint, it's owned by %s", Addr(mints[i].String()), Addr(acc.Owner.String()))
+		}
+		m, err := decodeMintAccount(acc.Data.GetBinary())
+		if err != nil {
+			return nil, fmt.Errorf("decoding mint %s failed: %w", Addr(mints[i].String()), err)
+		}
+		m.Address, m.Program = mints[i], acc.Owner
+		out[i] = m
+	}
+	return out, nil
+}
+
+// poolReserves reads the pool's vault balances and checks them against its mints, see the note at the top. A mint
+// left nil (it couldn't be read when the pool loaded) leaves that balance unchecked, as it always used to be.
+//
+// Returns two slices of length 2, like poolBalances.
+func poolReserves(ctx context.Context, client *rpc.Client, pool *raydium_cp_swap.PoolState, mints [2]*mintAccount) ([]*PoolBalance, []error) {
+	vaults := []solana.PublicKey{pool.Token0Vault, pool.Token1Vault}
+	balances, errs := poolBalances(ctx, client, vaults)
+	var failed []int
+	for i := range vaults {
+		if errs[i] != nil && mints[i] != nil {
+			failed = append(failed, i)
+		}
+	}
+	if len(failed) > 0 {
+		readVaultAccounts(ctx, client, vaults, mints, failed, balances, errs)
+	}
+	for i, bal := range balances {
+		if bal == nil || mints[i] == nil || bal.Decimals == mints[i].Decimals {
+			continue
+		}
+		balances[i] = nil
+		errs[i] = fmt.Errorf("the vault balance says %d decimals but mint %s has %d, the RPC may be serving bad data",
+			bal.Decimals, Addr(mints[i].Address.String()), mints[i].Decimals)
+	}
+	return balances, errs
+}
+
+// readVaultAccounts fills in the balances at failed by decoding the vault token accounts themselves. A vault that
+// can't be read this way either keeps the error its balance call got.
+func readVaultAccounts(ctx context.Context, client *rpc.Client, vaults []solana.PublicKey, mints [2]*mintAccount, failed []int, balances []*PoolBalance, errs []error) {
+	keys := make([]solana.PublicKey, len(failed))
+	for j, i := range failed {
+		keys[j] = vaults[i]
+	}
+	res, err := client.GetMultipleAccountsWithOpts(ctx, keys, &rpc.GetMultipleAccountsOpts{
+		Encoding:   solana.EncodingBase64,
+		Commitment: rpc.CommitmentFinalized,
+	})
+	if err != nil || res == nil || len(res.Value) != len(keys) {
+		return
+	}
+	for j, i := range failed {
+		acc := res.Value[j]
+		if acc == nil {
+			continue
+		}
+		data := acc.Data.GetBinary()
+		if len(data) < baseAccountLen || !solana.PublicKeyFromBytes(data[:32]).Equals(mints[i].Address) {
+			continue
+		}
+		amount := binary.LittleEndian.Uint64(data[tokenAccountAmountOffset : tokenAccountAmountOffset+8])
+		balances[i] = &PoolBalance{Balance: new(big.Int).SetUint64(amount), Decimals: mints[i].Decimals}
+		errs[i] = nil
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func mintData(supply uint64, decimals uint8, mintAuthority, freezeAuthority *solana.PublicKey) []byte {
+	data := make([]byte, baseMintLen)
+	putOption := func(b []byte, pk *solana.PublicKey) {
+		if pk != nil {
+			binary.LittleEndian.PutUint32(b, 1)
+			copy(b[4:], pk[:])
+		}
+	}
+	putOption(data[0:36], mintAuthority)
+	binary.LittleEndian.PutUint64(data[36:44], supply)
+	data[44], data[45] = decimals, 1
+	putOption(data[46:82], freezeAuthority)
+	return data
+}
+
+func tokenAccountData(mint solana.PublicKey, amount uint64) []byte {
+	data := make([]byte, baseAccountLen)
+	copy(data, mint[:])
+	binary.LittleEndian.PutUint64(data[tokenAccountAmountOffset:], amount)
+	return data
+}
+
+func TestDecodeMintAccount(t *testing.T) {
+	authority := snapshotKey(8)
+	m, err := decodeMintAccount(mintData(5_000_000, 6, nil, &authority))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Supply != 5_000_000 || m.Decimals != 6 || m.MintAuthority != nil || m.FreezeAuthority == nil || !m.FreezeAuthority.Equals(authority) {
+		t.Errorf("decoded %+v", m)
+	}
+
+	// A Token-2022 mint with extensions is padded to 165 bytes and tagged as a mint.
+	ext := append(mintData(1, 9, &authority, nil), make([]byte, baseAccountLen-baseMintLen+1)...)
+	ext[baseAccountLen] = accountTypeMint
+	if m, err := decodeMintAccount(ext); err != nil || m.Decimals != 9 || m.MintAuthority == nil {
+		t.Errorf("token-2022 mint: %+v, %v", m, err)
+	}
+
+	uninitialized := mintData(0, 6, nil, nil)
+	uninitialized[45] = 0
+	badTag := mintData(0, 6, nil, nil)
+	badTag[0] = 7
+	for name, data := range map[string][]byte{
+		"short":          make([]byte, 40),
+		"token account":  tokenAccountData(solana.SolMint, 1),
+		"uninitialized":  uninitialized,
+		"bad option tag": badTag,
+	} {
+		if _, err := decodeMintAccount(data); err == nil {
+			t.Errorf("%s: want an error", name)
+		}
+	}
+}
+
+// accountsServer answers getMultipleAccounts from accounts, and getTokenAccountBalance from balances like
+// vaultBalanceServer.
+func accountsServer(t *testing.T, accounts map[solana.PublicKey][]byte, balances map[solana.PublicKey]*PoolBalance) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Params) == 0 {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		switch req.Method {
+		case "getMultipleAccounts":
+			var keys []string
+			json.Unmarshal(req.Params[0], &keys)
+			values := make([]string, len(keys))
+			for i, key := range keys {
+				data, ok := accounts[solana.MustPublicKeyFromBase58(key)]
+				if !ok {
+					values[i] = "null"
+					continue
+				}
+				values[i] = fmt.Sprintf(`{"data":[%q,"base64"],"executable":false,"lamports":1,"owner":%q,"rentEpoch":0}`,
+					base64.StdEncoding.EncodeToString(data), solana.TokenProgramID)
+			}
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"context":{"slot":1},"value":[%s]}}`, req.ID, strings.Join(values, ","))
+		case "getTokenAccountBalance":
+			var key string
+			json.Unmarshal(req.Params[0], &key)
+			bal, ok := balances[solana.MustPublicKeyFromBase58(key)]
+			if !ok {
+				fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"error":{"code":-32602,"message":"Invalid param: could not find account"}}`, req.ID)
+				return
+			}
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"context":{"slot":1},"value":{"amount":"%s","decimals":%d,"uiAmountString":"0"}}}`,
+				req.ID, bal.Balance, bal.Decimals)
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestPoolReserves(t *testing.T) {
+	pool, _, balances := snapshotPool()
+	srv := accountsServer(t, map[solana.PublicKey][]byte{
+		pool.Token0Mint:  mintData(10_000_000_000_000, 9, nil, nil),
+		pool.Token1Mint:  mintData(80_000_000_000_000, 6, nil, nil),
+		pool.Token1Vault: tokenAccountData(pool.Token1Mint, 150_000_000_000),
+	}, map[solana.PublicKey]*PoolBalance{pool.Token0Vault: balances[0]}) // token1's balance call fails
+	client := rpc.New(srv.URL)
+
+	mints, err := fetchMintAccounts(context.Background(), client, pool.Token0Mint, pool.Token1Mint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, errs := poolReserves(context.Background(), client, pool, [2]*mintAccount{mints[0], mints[1]})
+	if errs[0] != nil || errs[1] != nil {
+		t.Fatalf("reserves failed: %v", errs)
+	}
+	if got[1].Balance.Cmp(balances[1].Balance) != 0 || got[1].Decimals != 6 {
+		t.Errorf("token1 read off its vault account as %v with %d decimals", got[1].Balance, got[1].Decimals)
+	}
+
+	// Without the mints there's nothing to fall back on.
+	if _, errs := poolReserves(context.Background(), client, pool, [2]*mintAccount{}); errs[1] == nil {
+		t.Error("want token1's balance error kept without its mint")
+	}
+
+	// A balance that disagrees with its mint is refused.
+	wrong := *mints[0]
+	wrong.Decimals = 8
+	got, errs = poolReserves(context.Background(), client, pool, [2]*mintAccount{&wrong, mints[1]})
+	if got[0] != nil || errs[0] == nil || !strings.Contains(errs[0].Error(), "says 9 decimals but mint") {
+		t.Errorf("mismatch not caught: %v, %v", got[0], errs[0])
+	}
+
+	if _, err := fetchMintAccounts(context.Background(), client, pool.Token0Mint, snapshotKey(9)); err == nil || !strings.Contains(err.Error(), "doesn't exist") {
+		t.Errorf("want a missing mint reported, got %v", err)
+	}
+}
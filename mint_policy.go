@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+/*
+NOTE(@hadydotai): Mint allowlist and denylist.
+
+A symbol is only a name, and names come from places we don't control: the token's own metadata, a token list, an alias
+somebody typed once. A scam mint calling itself USDC resolves just as well as USDC. Someone at the TUI sees the mint
+next to the symbol, a bot running limit orders overnight doesn't, so -allow-mints and -deny-mints take files of mints
+(one per line, # comments) and every symbol or pair is checked the moment it resolves to a mint, before a pool is
+looked up or a quote is made.
+
+With an allowlist, a mint that isn't on it is refused, whatever it's called. That's the one to give an unattended bot,
+list the handful of tokens it's meant to trade and a mis-resolved symbol can't go anywhere else. A denylist refuses
+what's on it and lets the rest through. Both can be given, the denylist wins.
+
+The denylist trade hook (trade_hooks.go) reads the same files, it's there for checks at quote and send time alongside
+other hooks, this is the one that stops a bad mint before anything is quoted.
+*/
+
+// mintPolicy is which mints swaps are allowed to touch, a nil allow is every mint that isn't denied.
+type mintPolicy struct {
+	allow map[solana.PublicKey]bool
+	deny  map[solana.PublicKey]bool
+}
+
+// tradeMints is the policy from -allow-mints and -deny-mints.
+var tradeMints mintPolicy
+
+func addMintPolicyFlags(fs *flag.FlagSet) {
+	fs.Func("allow-mints", "File of mints, one per line, swaps may only touch these, repeatable", func(path string) error {
+		mints, err := readKeyList(path)
+		if err != nil {
+			return err
+		}
+		if tradeMints.allow == nil {
+			tradeMints.allow = map[solana.PublicKey]bool{}
+		}
+		for mint := range mints {
+			tradeMints.allow[mint] = true
+		}
+		return nil
+	})
+	fs.Func("deny-mints", "File of mints, one per line, swaps may never touch these, repeatable", func(path string) error {
+		mints, err := readKeyList(path)
+		if err != nil {
+			return err
+		}
+		if tradeMints.deny == nil {
+			tradeMints.deny = map[solana.PublicKey]bool{}
+		}
+		for mint := range mints {
+			tradeMints.deny[mint] = true
+		}
+		return nil
+	})
+}
+
+// check refuses mint, which name resolved to, when the policy doesn't let swaps touch it.
+func (mp *mintPolicy) check(name string, mint solana.PublicKey) error {
+	switch {
+	case mp.deny[mint]:
+		return fmt.Errorf("%s resolved to %s, which is on the denylist", name, mint)
+	case mp.allow != nil && !mp.allow[mint]:
+		return fmt.Errorf("%s resolved to %s, which isn't on the allowlist", name, mint)
+	}
+	return nil
+}
+
+// readKeyList reads a file of public keys (mints, wallets), one per line, # starts a comment.
+func readKeyList(path string) (map[solana.PublicKey]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	keys := map[solana.PublicKey]bool{}
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		key, err := solana.PublicKeyFromBase58(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %q isn't a public key: %w", path, n, line, err)
+		}
+		keys[key] = true
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return keys, nil
+}
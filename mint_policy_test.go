@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func writeMintList(t *testing.T, mints ...solana.PublicKey) string {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("# test list\n\n")
+	for _, mint := range mints {
+		b.WriteString(mint.String() + "  # a mint\n")
+	}
+	path := filepath.Join(t.TempDir(), "mints")
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMintPolicyFlags(t *testing.T) {
+	defer func(saved mintPolicy) { tradeMints = saved }(tradeMints)
+	tradeMints = mintPolicy{}
+	sol, usdc, scam := snapshotKey(93), snapshotKey(94), snapshotKey(95)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(&strings.Builder{})
+	addMintPolicyFlags(fs)
+	if err := fs.Parse([]string{"-allow-mints", writeMintList(t, sol, usdc), "-deny-mints", writeMintList(t, usdc)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tradeMints.check("SOL", sol); err != nil {
+		t.Errorf("an allowed mint was refused: %v", err)
+	}
+	if err := tradeMints.check("USDC", usdc); err == nil || !strings.Contains(err.Error(), "denylist") {
+		t.Errorf("the denylist should win over the allowlist, got %v", err)
+	}
+	if err := tradeMints.check("USDC", scam); err == nil || !strings.Contains(err.Error(), "USDC resolved to "+scam.String()) {
+		t.Errorf("a mint off the allowlist: %v", err)
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad")
+	if err := os.WriteFile(bad, []byte(sol.String()+"\nnot-a-mint\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse([]string{"-deny-mints", bad}); err == nil || !strings.Contains(err.Error(), bad+":2:") {
+		t.Errorf("a bad line should say where it is, got %v", err)
+	}
+}
+
+func TestMintPolicyAtResolution(t *testing.T) {
+	defer func(saved mintPolicy) { tradeMints = saved }(tradeMints)
+	pool, addr, balances := snapshotPool()
+	// The pool's USDC is a look-alike, only the real one is allowed.
+	tradeMints = mintPolicy{allow: map[solana.PublicKey]bool{pool.Token0Mint: true, snapshotKey(96): true}}
+	symm := SymbolMapping{
+		mintToSymbol: map[string]string{pool.Token0Mint.String(): "SOL", pool.Token1Mint.String(): "USDC"},
+		symbolToMint: map[string]solana.PublicKey{"SOL": pool.Token0Mint, "USDC": pool.Token1Mint},
+		unresolved:   map[string]struct{}{},
+	}
+
+	if _, err := resolvePairToken("USDC", symm); err == nil || !strings.Contains(err.Error(), "allowlist") {
+		t.Errorf("resolving the look-alike: %v", err)
+	}
+	if mint, err := resolvePairToken("SOL", symm); err != nil || !mint.Equals(pool.Token0Mint) {
+		t.Errorf("resolving SOL got %s, %v", mint, err)
+	}
+
+	srv := vaultBalanceServer(t, map[solana.PublicKey]*PoolBalance{pool.Token0Vault: balances[0], pool.Token1Vault: balances[1]})
+	lp := &loadedPool{address: addr, pool: pool, ammConfig: &raydium_cp_swap.AmmConfig{TradeFeeRate: 2500}, symbolsMap: symm}
+	tb, err := newTableBuilder(t.Context(), rpc.New(srv.URL), lp, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Selling SOL still pays into the look-alike, it's refused whichever side the intent names.
+	if _, intent, err := tb.Build("sell 1 SOL"); err == nil || intent != nil {
+		t.Errorf("quoted %v on a pool with a mint off the allowlist, err %v", intent, err)
+	}
+}
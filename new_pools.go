@@ -0,0 +1,502 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): New pools.
+
+`monitor new-pools` announces every cp-swap pool as it's created. It subscribes to the logs of transactions mentioning
+the cp-swap program, and one that logged an Initialize (or InitializeWithPermission) is fetched and its instructions
+read, top level and inner ones alike since launchpads create their pools through a CPI. The instruction names the
+pool, its mints and vaults, and the vaults' balances after the transaction are the liquidity it opened with. The pool
+itself is then read at confirmed commitment (finalized lags a dozen seconds behind, the pool wouldn't be there yet)
+for its open time and config, and both mints go through the token safety inspection (see token_safety.go).
+
+Most new pools are noise, so the filters decide which get announced:
+
+  - -paired-with SOL,USDC: only pools with one of these on one side. The other side is the new token.
+  - -min-liquidity "10 SOL, 1000 USDC": only pools that opened with at least this much of one of these tokens. A pool
+    with none of the tokens named doesn't pass.
+  - -reject mint-authority,freeze-authority: skip pools whose new token has one of these risks. With -paired-with the
+    paired token isn't checked, USDC's freeze authority isn't what you're asking about. Without it both are.
+
+A pool that passes is printed, one line or one JSON object with -json, and with -webhook POSTed as pool.created (see
+webhooks.go). One that doesn't is logged with the reason. Like the tape, a dropped subscription reconnects after a
+second and pools created in between are missed, not replayed.
+*/
+
+// Account positions in Initialize, InitializeWithPermission has its payer first and the rest one further along.
+const (
+	initAccCreator   = 0
+	initAccAmmConfig = 1
+	initAccPool      = 3
+	initAccToken0    = 4
+	initAccToken1    = 5
+	initAccVault0    = 10
+	initAccVault1    = 11
+)
+
+// newPool is a pool created in a transaction.
+type newPool struct {
+	at           time.Time
+	signature    solana.Signature
+	pool         solana.PublicKey
+	creator      solana.PublicKey
+	ammConfig    solana.PublicKey
+	mints        [2]solana.PublicKey
+	vaults       [2]solana.PublicKey
+	decimals     [2]uint8
+	symbols      [2]string
+	liquidity    [2]*big.Int // what the vaults held after the transaction
+	openTime     time.Time
+	tradeFeeRate uint64
+	safety       [2]tokenSafety
+}
+
+// initializeLogged is a cheap check on a transaction's logs for a pool being created, before fetching it.
+func initializeLogged(logs []string) bool {
+	for _, line := range logs {
+		if line == "Program log: Instruction: Initialize" || line == "Program log: Instruction: InitializeWithPermission" {
+			return true
+		}
+	}
+	return false
+}
+
+// initializedPools reads the pools created in a transaction out of its cp-swap Initialize instructions. A failed
+// transaction created nothing.
+func initializedPools(res *rpc.GetTransactionResult, sig solana.Signature, at time.Time) ([]newPool, error) {
+	if res == nil || res.Meta == nil || res.Transaction == nil {
+		return nil, errors.New("the transaction has no metadata")
+	}
+	if res.Meta.Err != nil {
+		return nil, nil
+	}
+	tx, err := res.Transaction.GetTransaction()
+	if err != nil {
+		return nil, fmt.Errorf("decoding transaction: %w", err)
+	}
+	if res.BlockTime != nil {
+		at = res.BlockTime.Time()
+	}
+	keys := txAccountKeys(tx, res.Meta)
+	ixs := append([]solana.CompiledInstruction{}, tx.Message.Instructions...)
+	for _, set := range res.Meta.InnerInstructions {
+		ixs = append(ixs, set.Instructions...)
+	}
+	var pools []newPool
+	for _, ix := range ixs {
+		if int(ix.ProgramIDIndex) >= len(keys) || !keys[ix.ProgramIDIndex].Equals(raydium_cp_swap.ProgramID) || len(ix.Data) < 32 {
+			continue
+		}
+		var disc [8]byte
+		copy(disc[:], ix.Data[:8])
+		shift := 0
+		switch disc {
+		case raydium_cp_swap.Instruction_Initialize:
+		case raydium_cp_swap.Instruction_InitializeWithPermission:
+			shift = 1
+		default:
+			continue
+		}
+		if len(ix.Accounts) <= initAccVault1+shift {
+			return nil, fmt.Errorf("an initialize instruction has %d accounts, want at least %d", len(ix.Accounts), initAccVault1+shift+1)
+		}
+		key := func(pos int) (solana.PublicKey, error) {
+			idx := int(ix.Accounts[pos+shift])
+			if idx >= len(keys) {
+				return solana.PublicKey{}, fmt.Errorf("an initialize instruction references account %d, the transaction only has %d", idx, len(keys))
+			}
+			return keys[idx], nil
+		}
+		p := newPool{at: at, signature: sig, openTime: time.Unix(int64(binary.LittleEndian.Uint64(ix.Data[24:32])), 0)}
+		for _, f := range []struct {
+			pos int
+			dst *solana.PublicKey
+		}{
+			{initAccCreator, &p.creator}, {initAccAmmConfig, &p.ammConfig}, {initAccPool, &p.pool},
+			{initAccToken0, &p.mints[0]}, {initAccToken1, &p.mints[1]}, {initAccVault0, &p.vaults[0]}, {initAccVault1, &p.vaults[1]},
+		} {
+			if *f.dst, err = key(f.pos); err != nil {
+				return nil, err
+			}
+		}
+		for i, pos := range []int{initAccVault0, initAccVault1} {
+			amount, decimals, ok := tokenBalanceAt(res.Meta.PostTokenBalances, int(ix.Accounts[pos+shift]))
+			if !ok {
+				return nil, fmt.Errorf("pool %s's vault balances aren't in the transaction's token balances", p.pool)
+			}
+			p.liquidity[i], p.decimals[i] = amount, decimals
+		}
+		pools = append(pools, p)
+	}
+	return pools, nil
+}
+
+// risky is the risks of side i worth mentioning, none when it's one of the paired with tokens.
+func (p newPool) risky(i int, pairedWith []solana.PublicKey) []tokenRisk {
+	if slices.Contains(pairedWith, p.mints[i]) {
+		return nil
+	}
+	return p.safety[i].risks
+}
+
+func (p newPool) opens() string {
+	if !p.openTime.After(p.at) {
+		return "open now"
+	}
+	return "opens " + p.openTime.Local().Format("2006-01-02 15:04:05")
+}
+
+func (p newPool) line() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%s  new pool %s %s/%s  %s + %s  fee %s  %s  %s",
+		p.at.Local().Format("15:04:05"), Addr(p.pool.String()), p.symbols[0], p.symbols[1],
+		formatTokenAmount(p.liquidity[0], p.decimals[0], p.symbols[0]), formatTokenAmount(p.liquidity[1], p.decimals[1], p.symbols[1]),
+		formatFeeRate(p.tradeFeeRate), p.opens(), Addr(p.signature.String()))
+	for i := range p.mints {
+		if len(p.safety[i].risks) > 0 {
+			fmt.Fprintf(b, "\n    %s: %s", p.symbols[i], p.safety[i])
+		}
+	}
+	return b.String()
+}
+
+type newPoolTokenJSON struct {
+	Mint      string      `json:"mint"`
+	Symbol    string      `json:"symbol"`
+	Liquidity amountJSON  `json:"liquidity"`
+	Risks     []tokenRisk `json:"risks"`
+}
+
+type newPoolJSON struct {
+	Time         time.Time           `json:"time"`
+	Signature    string              `json:"signature"`
+	Pool         string              `json:"pool"`
+	Creator      string              `json:"creator"`
+	AmmConfig    string              `json:"ammConfig"`
+	TradeFeeRate string              `json:"tradeFeeRate"`
+	OpenTime     time.Time           `json:"openTime"`
+	Tokens       [2]newPoolTokenJSON `json:"tokens"`
+}
+
+func (p newPool) json() newPoolJSON {
+	js := newPoolJSON{
+		Time:         p.at,
+		Signature:    p.signature.String(),
+		Pool:         p.pool.String(),
+		Creator:      p.creator.String(),
+		AmmConfig:    p.ammConfig.String(),
+		TradeFeeRate: formatFeeRate(p.tradeFeeRate),
+		OpenTime:     p.openTime,
+	}
+	for i := range p.mints {
+		risks := p.safety[i].risks
+		if risks == nil {
+			risks = []tokenRisk{}
+		}
+		js.Tokens[i] = newPoolTokenJSON{Mint: p.mints[i].String(), Symbol: p.symbols[i], Liquidity: newAmountJSON(p.liquidity[i], p.decimals[i]), Risks: risks}
+	}
+	return js
+}
+
+// newPoolFilter is which new pools are worth announcing, the zero value lets every one through.
+type newPoolFilter struct {
+	pairedWith   []solana.PublicKey
+	minLiquidity map[solana.PublicKey]*big.Int
+	reject       []string
+}
+
+// check says why the pool isn't worth announcing, nil when it is.
+func (f newPoolFilter) check(p newPool) error {
+	if len(f.pairedWith) > 0 && !slices.Contains(f.pairedWith, p.mints[0]) && !slices.Contains(f.pairedWith, p.mints[1]) {
+		return errors.New("not paired with a -paired-with token")
+	}
+	if len(f.minLiquidity) > 0 {
+		enough := false
+		for i, mint := range p.mints {
+			if floor, ok := f.minLiquidity[mint]; ok && p.liquidity[i].Cmp(floor) >= 0 {
+				enough = true
+			}
+		}
+		if !enough {
+			return errors.New("opened with less than -min-liquidity")
+		}
+	}
+	for i := range p.mints {
+		for _, r := range p.risky(i, f.pairedWith) {
+			if slices.Contains(f.reject, r.Name) {
+				return fmt.Errorf("%s has %s (%s)", p.symbols[i], r.Name, r.Detail)
+			}
+		}
+	}
+	return nil
+}
+
+// parseMinLiquidity reads -min-liquidity's "10 SOL, 1000 USDC" into raw amounts per mint.
+func parseMinLiquidity(ctx context.Context, client *rpc.Client, spec string) (map[solana.PublicKey]*big.Int, error) {
+	type entry struct {
+		amount string
+		mint   solana.PublicKey
+	}
+	var entries []entry
+	for _, part := range strings.Split(spec, ",") {
+		fields := strings.Fields(part)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("-min-liquidity entry %q must look like <amount> <token>", strings.TrimSpace(part))
+		}
+		mint, err := lookupPairToken(fields[1], SymbolMapping{})
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{amount: fields[0], mint: mint})
+	}
+	mints := make([]solana.PublicKey, len(entries))
+	for i, e := range entries {
+		mints[i] = e.mint
+	}
+	accounts, err := fetchMintAccounts(ctx, client, mints...)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[solana.PublicKey]*big.Int, len(entries))
+	for i, e := range entries {
+		amount, err := fmtForMath(e.amount, accounts[i].Decimals)
+		if err != nil {
+			return nil, fmt.Errorf("-min-liquidity: %w", err)
+		}
+		out[e.mint] = amount
+	}
+	return out, nil
+}
+
+// newPoolWatcher streams the pools created on cp-swap.
+type newPoolWatcher struct {
+	client   *rpc.Client
+	wsEP     string
+	feeRates map[solana.PublicKey]uint64 // trade fee rate per amm config
+	symbols  map[solana.PublicKey]string
+}
+
+// describe fills in what the transaction doesn't say about a new pool: its state, fee, tokens' safety and symbols.
+func (w *newPoolWatcher) describe(ctx context.Context, p *newPool) error {
+	acc, err := w.client.GetAccountInfoWithOpts(ctx, p.pool, &rpc.GetAccountInfoOpts{Encoding: solana.EncodingBase64, Commitment: rpc.CommitmentConfirmed})
+	if err != nil {
+		return fmt.Errorf("rpc call getAccountInfo for pool %s failed: %w", Addr(p.pool.String()), classifyRPC(err))
+	}
+	if acc == nil || acc.Value == nil {
+		return classify(ErrAccountMissing, fmt.Errorf("pool %s isn't there", Addr(p.pool.String())))
+	}
+	state, err := raydium_cp_swap.ParseAccount_PoolState(acc.Value.Data.GetBinary())
+	if err != nil {
+		return fmt.Errorf("parsing PoolState of %s failed: %w", Addr(p.pool.String()), err)
+	}
+	p.ammConfig, p.openTime = state.AmmConfig, time.Unix(int64(state.OpenTime), 0)
+	p.decimals = [2]uint8{state.Mint0Decimals, state.Mint1Decimals}
+	rate, ok := w.feeRates[state.AmmConfig]
+	if !ok {
+		config, err := fetchAmmConfig(ctx, w.client, state.AmmConfig)
+		if err != nil {
+			return err
+		}
+		rate = config.TradeFeeRate
+		w.feeRates[state.AmmConfig] = rate
+	}
+	p.tradeFeeRate = rate
+	safety, err := inspectTokenSafety(ctx, w.client, p.mints[0], p.mints[1])
+	if err != nil {
+		return err
+	}
+	p.safety = [2]tokenSafety{safety[0], safety[1]}
+
+	var unknown []solana.PublicKey
+	for _, mint := range p.mints {
+		if _, ok := w.symbols[mint]; !ok {
+			unknown = append(unknown, mint)
+		}
+	}
+	if len(unknown) > 0 {
+		symm := makeSymbolMapping(ctx, w.client, unknown)
+		for _, mint := range unknown {
+			w.symbols[mint] = symm.SymFrom(mint)
+		}
+	}
+	p.symbols = [2]string{w.symbols[p.mints[0]], w.symbols[p.mints[1]]}
+	return nil
+}
+
+// fetch reads a transaction that logged an Initialize and returns the pools it created, described.
+func (w *newPoolWatcher) fetch(ctx context.Context, sig solana.Signature) ([]newPool, error) {
+	ctx, cancel := deadlines.forQuote(ctx)
+	defer cancel()
+	maxVersion := uint64(0)
+	res, err := w.client.GetTransaction(ctx, sig, &rpc.GetTransactionOpts{
+		Encoding:                       solana.EncodingBase64,
+		Commitment:                     rpc.CommitmentConfirmed,
+		MaxSupportedTransactionVersion: &maxVersion,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("rpc call getTransaction for %s failed: %w", sig, err)
+	}
+	pools, err := initializedPools(res, sig, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("reading transaction %s: %w", sig, err)
+	}
+	for i := range pools {
+		if err := w.describe(ctx, &pools[i]); err != nil {
+			return nil, fmt.Errorf("describing pool %s: %w", Addr(pools[i].pool.String()), err)
+		}
+	}
+	return pools, nil
+}
+
+// stream subscribes to cp-swap's logs until ctx ends, sending every pool created to pools in the order they landed,
+// and closes pools when it's done.
+func (w *newPoolWatcher) stream(ctx context.Context, pools chan<- newPool) {
+	defer close(pools)
+	for first := true; ctx.Err() == nil; first = false {
+		if !first {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(tapeReconnectDelay):
+			}
+		}
+		sub, closeSub, err := subscribeMentions(ctx, w.wsEP, raydium_cp_swap.ProgramID)
+		if err != nil {
+			log.Printf("monitor new-pools: subscribing failed, retrying: %v", err)
+			continue
+		}
+		if first {
+			log.Printf("monitor new-pools: watching %s for new pools", raydium_cp_swap.ProgramID)
+		} else {
+			log.Printf("monitor new-pools: reconnected, pools created while it was down aren't shown")
+		}
+		for {
+			res, err := sub.Recv(ctx)
+			if err != nil {
+				closeSub()
+				if ctx.Err() == nil {
+					log.Printf("monitor new-pools: subscription dropped, reconnecting: %v", err)
+				}
+				break
+			}
+			if res == nil || res.Value.Err != nil || !initializeLogged(res.Value.Logs) {
+				continue
+			}
+			found, err := w.fetch(ctx, res.Value.Signature)
+			if err != nil {
+				log.Printf("warning: %v, its pools aren't shown", err)
+				continue
+			}
+			for _, p := range found {
+				select {
+				case pools <- p:
+				case <-ctx.Done():
+					closeSub()
+					return
+				}
+			}
+		}
+	}
+}
+
+func followNewPools(w io.Writer, asJSON bool, pools <-chan newPool, filter newPoolFilter) error {
+	enc := json.NewEncoder(w)
+	for p := range pools {
+		if err := filter.check(p); err != nil {
+			log.Printf("monitor new-pools: skipping %s/%s pool %s, %v", p.symbols[0], p.symbols[1], Addr(p.pool.String()), err)
+			continue
+		}
+		if asJSON {
+			if err := enc.Encode(p.json()); err != nil {
+				return err
+			}
+		} else {
+			fmt.Fprintln(w, p.line())
+		}
+		notifier.poolCreated(p)
+	}
+	return nil
+}
+
+func runMonitorNewPoolsCommand(args []string) error {
+	fs := flag.NewFlagSet("monitor new-pools", flag.ExitOnError)
+	nf := addNetworkFlags(fs)
+	wf := addWebhookFlags(fs)
+	var (
+		wsEP         = fs.String("ws", "", "WebSocket endpoint to subscribe on, derived from -rpc when empty")
+		asJSON       = fs.Bool("json", false, "Print every new pool as a line of JSON")
+		pairedWith   = fs.String("paired-with", "", "Only pools with one of these tokens on a side, comma separated (e.g. SOL,USDC)")
+		minLiquidity = fs.String("min-liquidity", "", "Only pools that opened with at least this much of one of these tokens (e.g. \"10 SOL, 1000 USDC\")")
+		reject       = fs.String("reject", "", "Skip pools whose new token has one of these risks, comma separated: "+strings.Join(tokenRiskNames, ", "))
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, nf.specs())
+	var filter newPoolFilter
+	for _, token := range strings.Split(*pairedWith, ",") {
+		if token = strings.TrimSpace(token); token == "" {
+			continue
+		}
+		mint, err := lookupPairToken(token, SymbolMapping{})
+		if err != nil {
+			return fmt.Errorf("-paired-with: %w", err)
+		}
+		filter.pairedWith = append(filter.pairedWith, mint)
+	}
+	for _, name := range strings.Split(*reject, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if !slices.Contains(tokenRiskNames, name) {
+			return fmt.Errorf("-reject %q isn't a risk, pick from %s", name, strings.Join(tokenRiskNames, ", "))
+		}
+		filter.reject = append(filter.reject, name)
+	}
+	client := nf.connect()
+	if *wsEP == "" {
+		*wsEP = wsEndpointFor(*nf.rpcEP)
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	if strings.TrimSpace(*minLiquidity) != "" {
+		quoteCtx, cancel := deadlines.forQuote(ctx)
+		floors, err := parseMinLiquidity(quoteCtx, client, *minLiquidity)
+		cancel()
+		if err != nil {
+			return err
+		}
+		filter.minLiquidity = floors
+	}
+	flush, err := wf.start("monitor new-pools", *nf.network)
+	if err != nil {
+		return err
+	}
+	defer flush()
+
+	watcher := &newPoolWatcher{client: client, wsEP: *wsEP, feeRates: map[solana.PublicKey]uint64{}, symbols: map[solana.PublicKey]string{}}
+	pools := make(chan newPool, 16)
+	go watcher.stream(ctx, pools)
+	return followNewPools(os.Stdout, *asJSON, pools, filter)
+}
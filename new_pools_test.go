@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func initializeIx(t *testing.T, creator solana.PublicKey, p inspectPool, withPermission bool) solana.Instruction {
+	t.Helper()
+	key := solana.NewWallet().PublicKey()
+	var (
+		ix  solana.Instruction
+		err error
+	)
+	if withPermission {
+		ix, err = raydium_cp_swap.NewInitializeWithPermissionInstruction(1, 1, 1_700_000_000, raydium_cp_swap.CreatorFeeOn_BothToken, creator, creator, key, key, p.pool,
+			p.inMint, p.outMint, key, key, key, key, p.inVault, p.outVault, key, key, key, key, key, key, key, key)
+	} else {
+		ix, err = raydium_cp_swap.NewInitializeInstruction(1, 1, 1_700_000_000, creator, key, key, p.pool,
+			p.inMint, p.outMint, key, key, key, key, p.inVault, p.outVault, key, key, key, key, key, key, key, key)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ix
+}
+
+func TestInitializedPools(t *testing.T) {
+	creator := solana.NewWallet().PublicKey()
+	sig := solana.Signature{2}
+	at := time.Unix(1_700_000_000, 0)
+	p := newInspectPool(9, 6, [2]string{"0", "0"}, [2]string{"10000000000", "5000000000"})
+
+	res := inspectResult(t, creator, []solana.Instruction{initializeIx(t, creator, p, false)}, nil, p)
+	pools, err := initializedPools(res, sig, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pools) != 1 {
+		t.Fatalf("%d pools, want 1", len(pools))
+	}
+	got := pools[0]
+	if !got.pool.Equals(p.pool) || !got.creator.Equals(creator) || got.mints != [2]solana.PublicKey{p.inMint, p.outMint} ||
+		got.vaults != [2]solana.PublicKey{p.inVault, p.outVault} || got.signature != sig || got.openTime.Unix() != 1_700_000_000 {
+		t.Errorf("got %+v", got)
+	}
+	if got.liquidity[0].Int64() != 10_000_000_000 || got.liquidity[1].Int64() != 5_000_000_000 || got.decimals != [2]uint8{9, 6} {
+		t.Errorf("liquidity %v, decimals %v", got.liquidity, got.decimals)
+	}
+
+	// With permission, the payer comes first and the rest moves one along.
+	permitted := inspectResult(t, creator, []solana.Instruction{initializeIx(t, creator, p, true)}, nil, p)
+	if pools, err := initializedPools(permitted, sig, at); err != nil || len(pools) != 1 || !pools[0].pool.Equals(p.pool) || !pools[0].vaults[1].Equals(p.outVault) {
+		t.Errorf("with permission: %v, %+v", err, pools)
+	}
+
+	// A launchpad creates the pool through a CPI, the instruction is an inner one and the top level one is the
+	// launchpad's.
+	tx, err := res.Transaction.GetTransaction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	initialize := tx.Message.Instructions[0]
+	tx.Message.Instructions[0].ProgramIDIndex = 0
+	raw, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cpi := &rpc.GetTransactionResult{Transaction: &rpc.TransactionResultEnvelope{}, Meta: res.Meta}
+	if err := cpi.Transaction.UnmarshalJSON(raw); err != nil {
+		t.Fatal(err)
+	}
+	if pools, err := initializedPools(cpi, sig, at); err != nil || len(pools) != 0 {
+		t.Fatalf("without the inner instruction: %v, %+v", err, pools)
+	}
+	meta := *res.Meta
+	meta.InnerInstructions = []rpc.InnerInstruction{{Index: 0, Instructions: []solana.CompiledInstruction{initialize}}}
+	cpi.Meta = &meta
+	if pools, err := initializedPools(cpi, sig, at); err != nil || len(pools) != 1 || !pools[0].pool.Equals(p.pool) {
+		t.Errorf("through a CPI: %v, %+v", err, pools)
+	}
+
+	failed := *res.Meta
+	failed.Err = "InstructionError"
+	if pools, err := initializedPools(&rpc.GetTransactionResult{Transaction: res.Transaction, Meta: &failed}, sig, at); err != nil || pools != nil {
+		t.Errorf("a failed transaction: %v, %+v", err, pools)
+	}
+}
+
+func TestNewPoolFilter(t *testing.T) {
+	sol, usdc, token := wSOLMint, solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	authority := solana.NewWallet().PublicKey()
+	pool := func(quote solana.PublicKey, quoteAmount int64, risks ...tokenRisk) newPool {
+		return newPool{
+			mints:     [2]solana.PublicKey{token, quote},
+			symbols:   [2]string{"NEW", "QUOTE"},
+			liquidity: [2]*big.Int{big.NewInt(1_000_000), big.NewInt(quoteAmount)},
+			safety:    [2]tokenSafety{{risks: risks}, {risks: []tokenRisk{{Name: riskFreezeAuthority, Detail: "held by " + authority.String()}}}},
+		}
+	}
+	filter := newPoolFilter{
+		pairedWith:   []solana.PublicKey{sol, usdc},
+		minLiquidity: map[solana.PublicKey]*big.Int{sol: big.NewInt(10_000_000_000)},
+		reject:       []string{riskMintAuthority, riskFreezeAuthority},
+	}
+	for name, c := range map[string]struct {
+		pool newPool
+		want string // empty when it passes
+	}{
+		"passes":           {pool(sol, 10_000_000_000), ""},
+		"not paired":       {pool(solana.NewWallet().PublicKey(), 10_000_000_000), "-paired-with"},
+		"too little":       {pool(sol, 9_999_999_999), "-min-liquidity"},
+		"no named minimum": {pool(usdc, 1_000_000_000_000), "-min-liquidity"},
+		"rejected risk":    {pool(sol, 10_000_000_000, tokenRisk{Name: riskMintAuthority, Detail: "held by someone"}), "NEW has mint-authority"},
+		"unrejected risk":  {pool(sol, 10_000_000_000, tokenRisk{Name: riskTransferFee, Detail: "1%"}), ""},
+	} {
+		err := filter.check(c.pool)
+		switch {
+		case c.want == "" && err != nil:
+			t.Errorf("%s: %v, want it to pass", name, err)
+		case c.want != "" && (err == nil || !strings.Contains(err.Error(), c.want)):
+			t.Errorf("%s: %v, want it skipped for %s", name, err, c.want)
+		}
+	}
+
+	// Without -paired-with both sides are checked, the quote token's freeze authority too.
+	if err := (newPoolFilter{reject: []string{riskFreezeAuthority}}).check(pool(sol, 1)); err == nil || !strings.Contains(err.Error(), "QUOTE") {
+		t.Errorf("both sides checked: %v", err)
+	}
+	if err := (newPoolFilter{}).check(pool(sol, 1)); err != nil {
+		t.Errorf("no filter: %v", err)
+	}
+}
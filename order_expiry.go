@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+)
+
+/*
+NOTE(@hadydotai): Order expiry.
+
+An order left running overnight fires whenever its trigger finally holds, which can be hours after the price it was
+placed against stopped meaning anything. -good-for <duration> and -expires-at <time> put an end to it: whichever comes
+first, the order is cancelled instead, a receipt with the status "expired" goes in the receipts store (the -state file
+for DCA) and an order.expired webhook goes out, so an order that didn't fill is as visible as one that did. A limit
+order is good for an hour unless told otherwise, stops and DCA plans run until they're stopped.
+*/
+
+// receiptExpired is the status of a receipt recording an order that expired unfilled.
+const receiptExpired = "expired"
+
+// expiresAtLayouts are what -expires-at takes besides RFC 3339, in local time.
+var expiresAtLayouts = []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"}
+
+// expiryFlags are -expires-at and -good-for.
+type expiryFlags struct {
+	at         time.Time
+	goodFor    time.Duration
+	goodForSet bool
+}
+
+// addExpiryFlags adds -expires-at and -good-for to fs, an order is good for goodFor unless they say otherwise, zero
+// is no limit.
+func addExpiryFlags(fs *flag.FlagSet, goodFor time.Duration) *expiryFlags {
+	ef := &expiryFlags{goodFor: goodFor}
+	fs.Func("expires-at", "Cancel the order if it hasn't filled by this time, RFC 3339 or local \"2006-01-02 15:04\"", func(s string) error {
+		at, err := parseExpiresAt(s, time.Now())
+		if err != nil {
+			return err
+		}
+		ef.at = at
+		return nil
+	})
+	usage := "Cancel the order if it hasn't filled within this long (e.g. 4h)"
+	if goodFor > 0 {
+		usage += fmt.Sprintf(" (default %s)", goodFor)
+	}
+	fs.Func("good-for", usage, ef.setGoodFor)
+	return ef
+}
+
+func (ef *expiryFlags) setGoodFor(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	if d <= 0 {
+		return fmt.Errorf("good-for has to be greater than zero, got %s", s)
+	}
+	ef.goodFor, ef.goodForSet = d, true
+	return nil
+}
+
+// deadline is when an order placed at now expires, zero when it doesn't. With both flags the earlier wins, an
+// -expires-at on its own replaces the default -good-for.
+func (ef *expiryFlags) deadline(now time.Time) time.Time {
+	var at time.Time
+	if ef.goodFor > 0 && (ef.goodForSet || ef.at.IsZero()) {
+		at = now.Add(ef.goodFor)
+	}
+	if !ef.at.IsZero() && (at.IsZero() || ef.at.Before(at)) {
+		at = ef.at
+	}
+	return at
+}
+
+// parseExpiresAt reads an -expires-at time, which has to be after now.
+func parseExpiresAt(s string, now time.Time) (time.Time, error) {
+	at, err := time.Parse(time.RFC3339, s)
+	for _, layout := range expiresAtLayouts {
+		if err == nil {
+			break
+		}
+		at, err = time.ParseInLocation(layout, s, now.Location())
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expires-at %q isn't RFC 3339 or \"2006-01-02 15:04\"", s)
+	}
+	if !at.After(now) {
+		return time.Time{}, fmt.Errorf("expires-at %s has already passed", at.Local().Format(time.DateTime))
+	}
+	return at, nil
+}
+
+// recordExpiry writes the expired order to receipts, when there's a store, and announces it on the webhook.
+func recordExpiry(receipts, command, pool, intent, trigger string, deadline time.Time) {
+	if receipts != "" {
+		rcpt := swapReceipt{Time: time.Now().UTC(), Command: command, Pool: pool, Intent: intent, Trigger: trigger, Status: receiptExpired}
+		if err := appendReceipt(receipts, rcpt); err != nil {
+			log.Printf("warning: recording the expiry failed: %v", err)
+		}
+	}
+	notifier.orderExpired(pool, intent, deadline)
+}
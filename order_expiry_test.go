@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExpiryDeadline(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	tomorrow := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+	at, _ := time.Parse(time.RFC3339, tomorrow)
+	parse := func(goodFor time.Duration, args ...string) (*expiryFlags, error) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		ef := addExpiryFlags(fs, goodFor)
+		return ef, fs.Parse(args)
+	}
+	for _, tc := range []struct {
+		name    string
+		goodFor time.Duration
+		args    []string
+		want    time.Time
+	}{
+		{"the default", time.Hour, nil, now.Add(time.Hour)},
+		{"no default", 0, nil, time.Time{}},
+		{"good-for", time.Hour, []string{"-good-for", "4h"}, now.Add(4 * time.Hour)},
+		{"expires-at replaces the default", time.Hour, []string{"-expires-at", tomorrow}, at},
+		{"the earlier of both", 0, []string{"-expires-at", tomorrow, "-good-for", "30m"}, now.Add(30 * time.Minute)},
+	} {
+		ef, err := parse(tc.goodFor, tc.args...)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := ef.deadline(now); !got.Equal(tc.want) {
+			t.Errorf("%s: deadline %s, want %s", tc.name, got, tc.want)
+		}
+	}
+	for _, args := range [][]string{{"-good-for", "0s"}, {"-expires-at", "2001-01-01 10:00"}, {"-expires-at", "tomorrow"}} {
+		if _, err := parse(0, args...); err == nil {
+			t.Errorf("%v parsed", args)
+		}
+	}
+
+	local, err := parseExpiresAt("2031-06-02 09:30", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2031, 6, 2, 9, 30, 0, 0, now.Location()); !local.Equal(want) {
+		t.Errorf("local time parsed as %s, want %s", local, want)
+	}
+}
+
+func TestOrderExpiryRecorded(t *testing.T) {
+	sink := &webhookSink{}
+	notifier = newTestNotifier(t, sink, "")
+	t.Cleanup(func() { notifier = nil })
+
+	dir := t.TempDir()
+	receipts := filepath.Join(dir, "receipts.jsonl")
+	deadline := time.Now().Add(-time.Minute)
+	recordExpiry(receipts, "limit order", "pool", "buy 100 TOKEN", "price <= 0.005", deadline)
+	got, err := readReceipts(receipts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Status != receiptExpired || got[0].Signature != "" || got[0].Trigger != "price <= 0.005" {
+		t.Errorf("receipts %+v, want the one expired order", got)
+	}
+
+	// A DCA plan records its expiry in its state, once however often it's restarted.
+	state := sampleDCAState()
+	state.ExpiresAt = deadline
+	de := &dcaEngine{state: state, statePath: filepath.Join(dir, "dca.json")}
+	if !de.expired(time.Now()) || de.expired(deadline.Add(-time.Second)) {
+		t.Error("expired before or not after the deadline")
+	}
+	for range 2 {
+		if err := de.expire(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saved, err := loadDCAState(de.statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := len(saved.Executions)
+	if n != len(sampleDCAState().Executions)+1 || saved.Executions[n-1].Status != dcaExpired || !saved.ExpiresAt.Equal(deadline) {
+		t.Errorf("state %+v, want one expired execution at the end", saved.Executions)
+	}
+
+	notifier.close(5 * time.Second)
+	if len(sink.events) != 2 || sink.events[0].Event != webhookOrderExpired || sink.events[1].Intent != state.Intent {
+		t.Errorf("webhooks %+v, want an order.expired for each order", sink.events)
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+/*
+NOTE(@hadydotai): Fee APR, for deciding on a deposit.
+
+`pool apr <pool>` is what the pool's LPs earned lately, as a yearly rate on what's in it. It's the pool stats
+(pool_stats.go) with one more step: the trade fees over the window, less the protocol and fund shares the AMM config
+takes out of them, valued in token1 at the pool's current price, over the pool's reserves valued the same way (both
+sides, twice the token1 reserve), and scaled from the window up to a year. The window is the part the stats actually
+read, a -max-txs cut makes it shorter, not the rate lower.
+
+-deposit "10 SOL" adds what a deposit of that would get. A cp-swap deposit goes in at the pool's ratio, so 10 SOL
+comes with the matching token1, and the deposit's share of the pool after it goes in is its share of the fees. The
+projected earnings are that share of the window's LP fees at the same pace, per day, 30 days and year, in token1.
+
+It's a look back, not a promise: volume comes and goes, and what the position is worth moves with the price (the
+impermanent loss) as much as with the fees, neither of which is in here. The creator fee isn't in the trade fee and
+isn't counted either.
+*/
+
+const aprYear = 365 * 24 * time.Hour
+
+// feeAPR is what a pool's LPs earned over a stats window, everything valued in token1 base units at the current price.
+type feeAPR struct {
+	stats    *poolStats
+	reserves [2]*big.Int
+	lpShare  *big.Rat // of the trade fees, what's left after the protocol and fund shares
+	lpFees   *big.Rat // over the window
+	tvl      *big.Rat
+	covered  time.Duration // the part of the window the stats read
+}
+
+// estimateFeeAPR values ps's fees against reserves with cfg's fee split.
+func estimateFeeAPR(ps *poolStats, cfg *raydium_cp_swap.AmmConfig, reserves [2]*big.Int) (*feeAPR, error) {
+	if reserves[0] == nil || reserves[1] == nil || reserves[0].Sign() <= 0 || reserves[1].Sign() <= 0 {
+		return nil, errors.New("the pool is empty, there's nothing to earn a rate on")
+	}
+	share := new(big.Rat).SetFrac64(feeRateDenom-int64(cfg.ProtocolFeeRate)-int64(cfg.FundFeeRate), feeRateDenom)
+	if share.Sign() < 0 {
+		share.SetInt64(0)
+	}
+	since := ps.from
+	if ps.scanned.After(since) {
+		since = ps.scanned
+	}
+	a := &feeAPR{stats: ps, reserves: reserves, lpShare: share, covered: ps.to.Sub(since)}
+	fees := new(big.Rat)
+	for i := range ps.fees {
+		if ps.fees[i] != nil {
+			fees.Add(fees, a.inToken1(i, ps.fees[i]))
+		}
+	}
+	a.lpFees = fees.Mul(fees, share)
+	a.tvl = new(big.Rat).SetInt(new(big.Int).Lsh(reserves[1], 1))
+	return a, nil
+}
+
+// inToken1 is amount of token i valued in token1 base units at the reserves' ratio.
+func (a *feeAPR) inToken1(i int, amount *big.Int) *big.Rat {
+	v := new(big.Rat).SetInt(amount)
+	if i == 0 {
+		v.Mul(v, new(big.Rat).SetFrac(a.reserves[1], a.reserves[0]))
+	}
+	return v
+}
+
+// perYear is the LP fees at the window's pace for a year, nil when the window read nothing.
+func (a *feeAPR) perYear() *big.Rat {
+	if a.covered <= 0 {
+		return nil
+	}
+	return new(big.Rat).Mul(a.lpFees, new(big.Rat).SetFrac64(int64(aprYear), int64(a.covered)))
+}
+
+// apr is the yearly fee rate on the pool's reserves, nil when the window read nothing.
+func (a *feeAPR) apr() *big.Rat {
+	perYear := a.perYear()
+	if perYear == nil {
+		return nil
+	}
+	return perYear.Quo(perYear, a.tvl)
+}
+
+// lpDeposit is a deposit of amount of token i and the matching amount of the other token.
+type lpDeposit struct {
+	amounts [2]*big.Int
+	value   *big.Rat // in token1 base units
+	share   *big.Rat // of the pool once it's in
+}
+
+// deposit is what depositing amount of token i into the pool comes to.
+func (a *feeAPR) deposit(i int, amount *big.Int) lpDeposit {
+	d := lpDeposit{}
+	d.amounts[i] = amount
+	other := new(big.Int).Mul(amount, a.reserves[1-i])
+	d.amounts[1-i] = other.Quo(other, a.reserves[i])
+	d.value = new(big.Rat).Mul(a.inToken1(i, amount), big.NewRat(2, 1))
+	d.share = new(big.Rat).Quo(d.value, new(big.Rat).Add(a.tvl, d.value))
+	return d
+}
+
+// earnings is d's share of the LP fees over period at the window's pace, in token1 base units, nil when the window
+// read nothing.
+func (a *feeAPR) earnings(d lpDeposit, period time.Duration) *big.Int {
+	perYear := a.perYear()
+	if perYear == nil {
+		return nil
+	}
+	perYear.Mul(perYear, d.share)
+	perYear.Mul(perYear, new(big.Rat).SetFrac64(int64(period), int64(aprYear)))
+	return new(big.Int).Quo(perYear.Num(), perYear.Denom())
+}
+
+// parseDeposit reads "<amount> <symbol>" for one of ps's tokens, as flag was given it, into the token's index and its
+// base units.
+func parseDeposit(flag, s string, ps *poolStats) (int, *big.Int, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return 0, nil, fmt.Errorf("-%s takes an amount and a token, e.g. \"10 %s\", got %q", flag, ps.symbols[0], s)
+	}
+	for i, sym := range ps.symbols {
+		if !strings.EqualFold(fields[1], sym) {
+			continue
+		}
+		amount, err := fmtForMath(fields[0], ps.decimals[i])
+		if err != nil {
+			return 0, nil, fmt.Errorf("-%s: %w", flag, err)
+		}
+		return i, amount, nil
+	}
+	return 0, nil, fmt.Errorf("-%s is in %s or %s, the pool's tokens, not %s", flag, ps.symbols[0], ps.symbols[1], fields[1])
+}
+
+// ratAmount is v, token1 base units, rounded down for display.
+func ratAmount(v *big.Rat) *big.Int {
+	return new(big.Int).Quo(v.Num(), v.Denom())
+}
+
+var depositPeriods = []struct {
+	name   string
+	period time.Duration
+}{
+	{"day", 24 * time.Hour},
+	{"30 days", 30 * 24 * time.Hour},
+	{"year", aprYear},
+}
+
+func (a *feeAPR) render(d *lpDeposit) string {
+	ps := a.stats
+	quote := func(v *big.Int) string { return formatTokenAmount(v, ps.decimals[1], ps.symbols[1]) }
+	t := table.NewWriter()
+	t.SetTitle(fmt.Sprintf("Fee APR, pool %s, %s/%s", Addr(ps.pool.String()), ps.symbols[0], ps.symbols[1]))
+	window := a.covered.Round(time.Minute).String()
+	if ps.scanned.After(ps.from) {
+		window += " (cut short by -max-txs)"
+	}
+	t.AppendRow(table.Row{"Window", window})
+	t.AppendRow(table.Row{"Swaps", ps.swaps})
+	for i := range ps.mints {
+		t.AppendRow(table.Row{"Fees " + ps.symbols[i], fmtAmount(ps.fees[i], ps.decimals[i])})
+	}
+	t.AppendRow(table.Row{"LP share of fees", pctString(a.lpShare)})
+	t.AppendRow(table.Row{"LP fees", quote(ratAmount(a.lpFees))})
+	t.AppendRow(table.Row{"Liquidity", quote(ratAmount(a.tvl))})
+	apr := "no fees read in the window"
+	if r := a.apr(); r != nil {
+		apr = pctString(r)
+	}
+	t.AppendRow(table.Row{"Fee APR", apr})
+	if ps.api != nil {
+		t.AppendRow(table.Row{"Raydium API, 24h", ps.api.dayLine()})
+	}
+	if d != nil {
+		t.AppendSeparator()
+		t.AppendRow(table.Row{"Deposit", fmt.Sprintf("%s + %s", formatTokenAmount(d.amounts[0], ps.decimals[0], ps.symbols[0]), quote(d.amounts[1]))})
+		t.AppendRow(table.Row{"Worth", quote(ratAmount(d.value))})
+		t.AppendRow(table.Row{"Pool share", pctString(d.share)})
+		for _, p := range depositPeriods {
+			earned := "no fees read in the window"
+			if v := a.earnings(*d, p.period); v != nil {
+				earned = quote(v)
+			}
+			t.AppendRow(table.Row{"Est. fees per " + p.name, earned})
+		}
+	}
+	t.SetCaption("At the window's volume and today's price. Price moves (impermanent loss) aren't in it.")
+	return t.Render()
+}
+
+type poolAPRJSON struct {
+	Pool      string           `json:"pool"`
+	Window    string           `json:"window"`
+	Swaps     int              `json:"swaps"`
+	LPShare   string           `json:"lpShare"`
+	LPFees    amountJSON       `json:"lpFees"` // in token1
+	Liquidity amountJSON       `json:"liquidity"`
+	APR       string           `json:"apr,omitempty"`
+	Deposit   *depositJSON     `json:"deposit,omitempty"`
+	Unit      string           `json:"unit"` // what lpFees, liquidity and the earnings are in
+	Earnings  []earningJSON    `json:"earnings,omitempty"`
+	API       *raydiumPoolJSON `json:"raydiumApi,omitempty"`
+}
+
+type depositJSON struct {
+	Amounts [2]amountJSON `json:"amounts"`
+	Value   amountJSON    `json:"value"`
+	Share   string        `json:"share"`
+}
+
+type earningJSON struct {
+	Period string     `json:"period"`
+	Fees   amountJSON `json:"fees"`
+}
+
+func (a *feeAPR) json(d *lpDeposit) poolAPRJSON {
+	ps := a.stats
+	out := poolAPRJSON{
+		Pool:      ps.pool.String(),
+		Window:    a.covered.Round(time.Minute).String(),
+		Swaps:     ps.swaps,
+		LPShare:   pctString(a.lpShare),
+		LPFees:    newAmountJSON(ratAmount(a.lpFees), ps.decimals[1]),
+		Liquidity: newAmountJSON(ratAmount(a.tvl), ps.decimals[1]),
+		Unit:      ps.symbols[1],
+	}
+	if r := a.apr(); r != nil {
+		out.APR = pctString(r)
+	}
+	if ps.api != nil {
+		out.API = ps.api.json()
+	}
+	if d != nil {
+		out.Deposit = &depositJSON{
+			Amounts: [2]amountJSON{newAmountJSON(d.amounts[0], ps.decimals[0]), newAmountJSON(d.amounts[1], ps.decimals[1])},
+			Value:   newAmountJSON(ratAmount(d.value), ps.decimals[1]),
+			Share:   pctString(d.share),
+		}
+		for _, p := range depositPeriods {
+			if v := a.earnings(*d, p.period); v != nil {
+				out.Earnings = append(out.Earnings, earningJSON{Period: p.name, Fees: newAmountJSON(v, ps.decimals[1])})
+			}
+		}
+	}
+	return out
+}
+
+func runPoolAPRCommand(args []string) error {
+	fs := flag.NewFlagSet("pool apr", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: pool apr [flags] <pool address or pair>\n")
+		fs.PrintDefaults()
+	}
+	nf := addNetworkFlags(fs)
+	var (
+		window  = fs.Duration("window", 7*24*time.Hour, "How far back to measure the fees")
+		maxTxs  = fs.Int("max-txs", 5000, "Most of the pool's transactions to read, the window is cut short when there are more")
+		deposit = fs.String("deposit", "", "Project the fees a deposit of this earns, e.g. \"10 SOL\", paired with the matching amount of the other token")
+		asJSON  = fs.Bool("json", false, "Print the estimate as JSON instead of a table")
+	)
+	addAmountFlags(fs)
+	// The pool reads naturally first, `pool apr <pool> -network mainnet`, flag stops at the first argument.
+	var target string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		target, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, nf.specs())
+	if target == "" && fs.NArg() > 0 {
+		target = fs.Arg(0)
+	}
+	if target == "" {
+		fs.Usage()
+		return errors.New("missing pool")
+	}
+	if *window <= 0 || *maxTxs <= 0 {
+		return errors.New("-window and -max-txs must be greater than zero")
+	}
+	client := nf.connect()
+	ctx, stop := interruptContext()
+	defer stop()
+
+	quoteCtx, cancel := deadlines.forQuote(ctx)
+	poolAddr, err := resolvePoolTarget(quoteCtx, client, target, SymbolMapping{})
+	cancel()
+	if err != nil {
+		return err
+	}
+	lp, err := loadPool(ctx, client, poolAddr)
+	if err != nil {
+		return err
+	}
+	ps, err := collectPoolStats(ctx, client, lp, *window, *maxTxs)
+	if err != nil {
+		return err
+	}
+	var (
+		depositToken  int
+		depositAmount *big.Int
+	)
+	if *deposit != "" {
+		if depositToken, depositAmount, err = parseDeposit("deposit", *deposit, ps); err != nil {
+			return err
+		}
+	}
+	quoteCtx, cancel = deadlines.forQuote(ctx)
+	balances, errs := poolReserves(quoteCtx, client, lp.pool, lp.mints)
+	cancel()
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("reading the pool's reserves failed: %w", err)
+	}
+	a, err := estimateFeeAPR(ps, lp.ammConfig, [2]*big.Int{balances[0].Balance, balances[1].Balance})
+	if err != nil {
+		return err
+	}
+	var d *lpDeposit
+	if depositAmount != nil {
+		dep := a.deposit(depositToken, depositAmount)
+		d = &dep
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(a.json(d))
+	}
+	fmt.Println(a.render(d))
+	return nil
+}
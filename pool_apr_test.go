@@ -0,0 +1,89 @@
+package main
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+)
+
+// aprPool is a SOL/USDC pool at 150 USDC per SOL, 100 SOL deep, that took 1 SOL and 150 USDC in trade fees in a day.
+func aprPool(t *testing.T) *feeAPR {
+	t.Helper()
+	to := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	ps := testPoolStats(to.Add(-24*time.Hour), to)
+	ps.scanned = ps.from
+	ps.swaps = 40
+	ps.fees = [2]*big.Int{big.NewInt(1_000_000_000), big.NewInt(150_000_000)}
+	cfg := &raydium_cp_swap.AmmConfig{TradeFeeRate: 2500, ProtocolFeeRate: 120_000, FundFeeRate: 40_000}
+	a, err := estimateFeeAPR(ps, cfg, [2]*big.Int{big.NewInt(100_000_000_000), big.NewInt(15_000_000_000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestEstimateFeeAPR(t *testing.T) {
+	a := aprPool(t)
+	// 300 USDC of fees, LPs keep 84% of it, on 30000 USDC of liquidity: 0.84% a day.
+	if got := ratAmount(a.lpFees); got.Int64() != 252_000_000 {
+		t.Errorf("LP fees %s", got)
+	}
+	if got := pctString(a.apr()); got != "306.6%" {
+		t.Errorf("APR %s", got)
+	}
+
+	// A -max-txs cut shortens the window rather than lowering the rate.
+	cut := aprPool(t)
+	cut.stats.scanned = cut.stats.to.Add(-12 * time.Hour)
+	cut, _ = estimateFeeAPR(cut.stats, &raydium_cp_swap.AmmConfig{ProtocolFeeRate: 120_000, FundFeeRate: 40_000}, cut.reserves)
+	if got := pctString(cut.apr()); got != "613.2%" {
+		t.Errorf("APR over half the window %s", got)
+	}
+
+	empty := aprPool(t)
+	empty.stats.scanned = empty.stats.to
+	if empty, _ = estimateFeeAPR(empty.stats, &raydium_cp_swap.AmmConfig{}, empty.reserves); empty.apr() != nil {
+		t.Errorf("nothing read, still an APR %s", pctString(empty.apr()))
+	}
+	if _, err := estimateFeeAPR(a.stats, &raydium_cp_swap.AmmConfig{}, [2]*big.Int{big.NewInt(0), big.NewInt(1)}); err == nil {
+		t.Error("an empty pool has an APR")
+	}
+}
+
+func TestFeeAPRDeposit(t *testing.T) {
+	a := aprPool(t)
+	i, amount, err := parseDeposit("deposit", "10 sol", a.stats)
+	if err != nil || i != 0 || amount.Int64() != 10_000_000_000 {
+		t.Fatalf("parsed %d %s, %v", i, amount, err)
+	}
+	d := a.deposit(i, amount)
+	// 10 SOL goes in with 1500 USDC, 3000 USDC of the 33000 in the pool after it.
+	if d.amounts[1].Int64() != 1_500_000_000 || ratAmount(d.value).Int64() != 3_000_000_000 || pctString(d.share) != "9.09%" {
+		t.Errorf("deposit %s + %s worth %s, %s of the pool", d.amounts[0], d.amounts[1], ratAmount(d.value), pctString(d.share))
+	}
+	if got := a.earnings(d, 24*time.Hour); got.Int64() != 22_909_090 {
+		t.Errorf("per day %s", got)
+	}
+	if got := a.earnings(d, aprYear); got.Int64() != 8_361_818_181 {
+		t.Errorf("per year %s", got)
+	}
+
+	out := a.render(&d)
+	for _, want := range []string{"306.6%", "10.00000000 SOL + 1500.000000 USDC", "22.909090 USDC", "84%"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("%q isn't in\n%s", want, out)
+		}
+	}
+	if js := a.json(&d); js.APR != "306.6%" || len(js.Earnings) != 3 || js.Deposit.Share != "9.09%" {
+		t.Errorf("json %+v", js)
+	}
+
+	for _, bad := range []string{"10", "10 BONK", "ten SOL", "0.0000000001 SOL"} {
+		if _, _, err := parseDeposit("deposit", bad, a.stats); err == nil {
+			t.Errorf("%q parsed", bad)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+/*
+NOTE(@hadydotai): Comparing pools.
+
+A pair often has more than one CP-Swap pool, with different fee tiers and very different depth, and the pool you were
+handed isn't necessarily the one that pays best. -compare quotes the intent on every CP-Swap pool for the pair and
+shows them side by side: fee, liquidity, price impact and what you'd get (or pay). -best does the same and then uses
+the pool with the best quote.
+
+Only CP-Swap pools are compared, that's all this client can quote and swap on. CLMM and AMM v4 pools for the same pair
+exist and may well be better, they just aren't looked at.
+
+Every pool is loaded and quoted on its own builder, in parallel but at most poolQuoteConcurrency at a time, public RPCs
+rate limit a burst of getAccountInfo long before we'd run out of pools. The same code backs -fallback-pools, which is
+the same comparison minus the pools already tried.
+*/
+
+const poolQuoteConcurrency = 4
+
+type poolCandidate struct {
+	address solana.PublicKey
+	loaded  *loadedPool
+	venue   Venue
+	report  string
+	intent  *CPIntent
+	err     error // why the pool couldn't be quoted, the rest is empty when set
+}
+
+// quotePools quotes intentLine on each of addrs, carrying over the symbols snap knows. Every address gets a candidate
+// back, in the order given.
+func quotePools(ctx context.Context, client *rpc.Client, snap quoteSnapshot, addrs []solana.PublicKey, intentLine string) []poolCandidate {
+	candidates := make([]poolCandidate, len(addrs))
+	sem := make(chan struct{}, poolQuoteConcurrency)
+	wg := sync.WaitGroup{}
+	for i, addr := range addrs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			candidates[i] = quotePool(ctx, client, snap, addr, intentLine)
+		}()
+	}
+	wg.Wait()
+	return candidates
+}
+
+func quotePool(ctx context.Context, client *rpc.Client, snap quoteSnapshot, addr solana.PublicKey, intentLine string) poolCandidate {
+	c := poolCandidate{address: addr}
+	loaded, err := loadPool(ctx, client, addr)
+	if err != nil {
+		c.err = err
+		return c
+	}
+	c.venue = loaded.venue()
+	if err := c.venue.CheckTradable(time.Now()); err != nil {
+		c.err = err
+		return c
+	}
+	// NOTE(@hadydotai): Symbols the user mapped by hand only live in the current pool's mapping, carry them over
+	// or the same intent won't resolve on the new pool.
+	for _, mint := range snap.venue.Mints() {
+		if sym, ok := snap.symm.MaybeSymFrom(mint); ok {
+			loaded.symbolsMap.MapSymToMint(sym, mint.String())
+		}
+	}
+	tb, err := newTableBuilder(ctx, client, loaded, snap.slippagePct)
+	if err != nil {
+		c.err = err
+		return c
+	}
+	tb.useWallet(snap.wallet)
+	report, intent, err := tb.Build(intentLine)
+	if err != nil {
+		c.err = err
+		return c
+	}
+	c.loaded, c.report, c.intent = loaded, report, intent
+	return c
+}
+
+// comparePools quotes intentLine on every CP-Swap pool for the current pool's pair, the current one included. The
+// pools that took the quote come first, best first, then the ones that didn't.
+func comparePools(ctx context.Context, client *rpc.Client, current *TableBuilder, intentLine string) ([]poolCandidate, error) {
+	snap := current.snapshot()
+	mints := snap.venue.Mints()
+	addrs, err := findPoolsByMints(ctx, client, mints[0], mints[1])
+	if err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(addrs, snap.address.Equals) {
+		addrs = append(addrs, snap.address)
+	}
+	candidates := quotePools(ctx, client, snap, addrs, intentLine)
+	rankCandidates(candidates)
+	return candidates, nil
+}
+
+// rankCandidates puts the pools that took the quote first, best first, keeping the order of the ones that didn't.
+func rankCandidates(candidates []poolCandidate) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if (a.err == nil) != (b.err == nil) {
+			return a.err == nil
+		}
+		return a.err == nil && betterQuote(a.intent, b.intent)
+	})
+}
+
+// bestPool is the candidate with the best quote, false when no pool took it.
+func bestPool(candidates []poolCandidate) (poolCandidate, bool) {
+	if len(candidates) == 0 || candidates[0].err != nil {
+		return poolCandidate{}, false
+	}
+	return candidates[0], true
+}
+
+// renderPoolComparison lays candidates, as comparePools sorts them, out side by side.
+func renderPoolComparison(candidates []poolCandidate, current solana.PublicKey, symm SymbolMapping) string {
+	builder := &strings.Builder{}
+	t := table.NewWriter()
+	t.SetOutputMirror(builder)
+	t.SetTitle("CP-Swap pools for the pair")
+	t.Style().Size.WidthMax = 160
+	amountHeader := "Receive (est.)"
+	if best, ok := bestPool(candidates); ok && best.intent.SwapKind == SwapKindBaseOutput {
+		amountHeader = "Pay (est.)"
+	}
+	t.AppendHeader(table.Row{"", "Pool", "Fee", "Liquidity", "Price impact", amountHeader})
+	for i, c := range candidates {
+		var marks []string
+		if i == 0 && c.err == nil {
+			marks = append(marks, "best")
+		}
+		if c.address.Equals(current) {
+			marks = append(marks, "current")
+		}
+		mark := strings.Join(marks, ", ")
+		if c.err != nil {
+			t.AppendRow(table.Row{mark, c.address, c.err.Error(), c.err.Error(), c.err.Error(), c.err.Error()}, table.RowConfig{AutoMerge: true})
+			continue
+		}
+		t.AppendRow(table.Row{mark, c.address, formatFeeRate(c.venue.FeeRate()), poolLiquidity(c.intent, symm), poolImpact(c.intent), poolQuoteAmount(c.intent, symm)})
+	}
+	t.Render()
+	return builder.String()
+}
+
+func poolLiquidity(intent *CPIntent, symm SymbolMapping) string {
+	return fmt.Sprintf("%s / %s",
+		formatTokenAmount(intent.ReserveIn.Balance, intent.ReserveIn.Decimals, symm.SymFrom(intent.TokenIn.Mint)),
+		formatTokenAmount(intent.ReserveOut.Balance, intent.ReserveOut.Decimals, symm.SymFrom(intent.TokenOut.Mint)))
+}
+
+func poolImpact(intent *CPIntent) string {
+	impact, err := intent.PriceImpact()
+	if err != nil {
+		return "n/a"
+	}
+	return pctString(impact)
+}
+
+// poolQuoteAmount is the side of the quote the pools compete on, what's received when selling, what's paid when buying.
+func poolQuoteAmount(intent *CPIntent, symm SymbolMapping) string {
+	leg := intent.CounterLeg()
+	return formatTokenAmount(intent.Amounts.QuoteAmount, leg.Decimals, symm.SymFrom(leg.Mint))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+func TestPoolComparison(t *testing.T) {
+	pool, _, balances := snapshotPool()
+	symm := SymbolMapping{
+		mintToSymbol: map[string]string{pool.Token0Mint.String(): "SOL", pool.Token1Mint.String(): "USDC"},
+		symbolToMint: map[string]solana.PublicKey{"SOL": pool.Token0Mint, "USDC": pool.Token1Mint},
+		unresolved:   map[string]struct{}{},
+	}
+	instruction, err := parseIntent("sell 2.5 SOL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slippage, err := makeSlippageRatio(0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quoted := func(addr solana.PublicKey, feeRate uint64) poolCandidate {
+		loaded := &loadedPool{address: addr, pool: pool, ammConfig: &raydium_cp_swap.AmmConfig{TradeFeeRate: feeRate}, symbolsMap: symm}
+		venue := loaded.venue()
+		intent, err := quoteInstruction(venue, instruction, pool.Token0Mint, slippage, balances)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return poolCandidate{address: addr, loaded: loaded, venue: venue, intent: intent}
+	}
+
+	if _, ok := bestPool(nil); ok {
+		t.Error("no pools, no best")
+	}
+	candidates := []poolCandidate{
+		{address: snapshotKey(40), err: errors.New("swaps are disabled")},
+		quoted(snapshotKey(41), 10000), // 1%
+		quoted(snapshotKey(42), 2500),  // 0.25%, pays the most
+	}
+	rankCandidates(candidates)
+	best, ok := bestPool(candidates)
+	if !ok || !best.address.Equals(snapshotKey(42)) {
+		t.Fatalf("best is %s, want the cheaper fee tier", best.address)
+	}
+	if !candidates[1].address.Equals(snapshotKey(41)) || candidates[2].err == nil {
+		t.Fatalf("pools that couldn't quote should sort last: %v", candidates)
+	}
+
+	out := renderPoolComparison(candidates, snapshotKey(41), symm)
+	for _, want := range []string{"RECEIVE (EST.)", "best", "current", "0.25%", "373.132002 USDC", "swaps are disabled"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("comparison is missing %q:\n%s", want, out)
+		}
+	}
+
+	if _, ok := bestPool(candidates[2:]); ok {
+		t.Error("no pool took the quote, there shouldn't be a best")
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+/*
+NOTE(@hadydotai): Depth along the curve.
+
+A quote answers for one size. `pool depth <pool>` answers for many at once: it samples the constant product curve at
+order sizes from small to large, each way (selling token0 for token1 and selling token1 for token0), and shows what
+each size gets, its average price and its price impact, with a bar for the impact so the shape of the curve shows at
+a glance. It's the same math a quote uses (constant_product.go), trade fee included, against the vault balances, so
+a size in the chart quotes to what the TUI would show for it.
+
+The largest size is the one that just reaches -max-impact (10% by default). With the fee f, a trade of dx against
+reserve X has an impact of 1 - X(1-f) / (X + dx(1-f)), so the size for an impact of I is
+
+	dx = X * ((1-f) / (1-I) - 1) / (1-f)
+
+and the others halve down from it, -points of them, rounded down to two significant digits so they read as 0.5, 1,
+2 and not 0.48828125. Impact only grows with size, so none of them is over the cap. An impact cap under the pool's
+fee has no size at all, every trade pays the fee.
+
+It's a snapshot of the reserves, other swaps move the curve, and the creator fee some pools take on top isn't in it,
+same as in a quote.
+*/
+
+// depthPoint is one size along the curve.
+type depthPoint struct {
+	size, out *big.Int
+	price     *big.Rat // average, token1 per token0 in display units
+	impact    *big.Rat
+}
+
+// depthSide is the curve selling token in for the other.
+type depthSide struct {
+	in     int
+	points []depthPoint
+}
+
+// depthCurve is a pool's curve sampled each way.
+type depthCurve struct {
+	pool      solana.PublicKey
+	symbols   [2]string
+	decimals  [2]uint8
+	reserves  [2]*big.Int
+	feeRate   uint64
+	maxImpact *big.Rat
+	sides     [2]depthSide
+}
+
+// maxDepthSize is the most of reserveIn a trade can sell before its impact passes maxImpact, at the pool's fee rate.
+func maxDepthSize(reserveIn *big.Int, feeRate uint64, maxImpact *big.Rat) (*big.Int, error) {
+	fee := big.NewRat(int64(feeRate), feeRateDenom)
+	if maxImpact.Cmp(fee) <= 0 || maxImpact.Cmp(big.NewRat(1, 1)) >= 0 {
+		return nil, fmt.Errorf("the impact cap has to be over the pool's %s trade fee and under 100%%, got %s", pctString(fee), pctString(maxImpact))
+	}
+	net := new(big.Rat).Sub(big.NewRat(1, 1), fee)
+	dx := new(big.Rat).Quo(net, new(big.Rat).Sub(big.NewRat(1, 1), maxImpact))
+	dx.Sub(dx, big.NewRat(1, 1))
+	dx.Quo(dx, net)
+	dx.Mul(dx, new(big.Rat).SetInt(reserveIn))
+	return ratAmount(dx), nil
+}
+
+// roundDown2 is v rounded down to two significant digits.
+func roundDown2(v *big.Int) *big.Int {
+	digits := len(v.String())
+	if digits <= 2 {
+		return new(big.Int).Set(v)
+	}
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits-2)), nil)
+	out := new(big.Int).Quo(v, unit)
+	return out.Mul(out, unit)
+}
+
+// depthSizes are points sizes halving down from largest, smallest first, rounded and without repeats.
+func depthSizes(largest *big.Int, points int) []*big.Int {
+	var sizes []*big.Int
+	size := new(big.Int).Set(largest)
+	for range points {
+		if rounded := roundDown2(size); rounded.Sign() > 0 && (len(sizes) == 0 || rounded.Cmp(sizes[0]) != 0) {
+			sizes = append([]*big.Int{rounded}, sizes...)
+		}
+		size.Rsh(size, 1)
+	}
+	return sizes
+}
+
+// newDepthCurve samples the curve at reserves each way, points sizes up to the one at maxImpact.
+func newDepthCurve(pool solana.PublicKey, symbols [2]string, decimals [2]uint8, reserves [2]*big.Int, feeRate uint64, maxImpact *big.Rat, points int) (*depthCurve, error) {
+	if reserves[0] == nil || reserves[1] == nil || reserves[0].Sign() <= 0 || reserves[1].Sign() <= 0 {
+		return nil, errors.New("the pool is empty, there's no curve to sample")
+	}
+	d := &depthCurve{pool: pool, symbols: symbols, decimals: decimals, reserves: reserves, feeRate: feeRate, maxImpact: maxImpact}
+	for in := range 2 {
+		d.sides[in].in = in
+		largest, err := maxDepthSize(reserves[in], feeRate, maxImpact)
+		if err != nil {
+			return nil, err
+		}
+		cp := ConstantProduct{
+			TokenInReserve:  &PoolBalance{Balance: reserves[in], Decimals: decimals[in]},
+			TokenOutReserve: &PoolBalance{Balance: reserves[1-in], Decimals: decimals[1-in]},
+			TradeFeeRate:    feeRate,
+		}
+		for _, size := range depthSizes(largest, points) {
+			out, err := cp.QuoteOut(size)
+			if err != nil {
+				// Too small to get anything out, a larger size will.
+				continue
+			}
+			impact, err := cp.PriceImpact(size, out)
+			if err != nil {
+				return nil, err
+			}
+			amounts := [2]*big.Int{}
+			amounts[in], amounts[1-in] = size, out
+			d.sides[in].points = append(d.sides[in].points, depthPoint{size: size, out: out, price: d.price(amounts[0], amounts[1]), impact: impact})
+		}
+	}
+	return d, nil
+}
+
+// price is amount1 over amount0, token1 per token0 in display units.
+func (d *depthCurve) price(amount0, amount1 *big.Int) *big.Rat {
+	price := new(big.Rat).SetFrac(amount1, amount0)
+	return price.Mul(price, new(big.Rat).SetFrac(fixedPointScale(d.decimals[0]), fixedPointScale(d.decimals[1])))
+}
+
+func (d *depthCurve) priceString(price *big.Rat) string {
+	return trimDecimal(price.FloatString(int(d.decimals[1])))
+}
+
+// impactBar is impact as a bar width columns long at the impact cap.
+func (d *depthCurve) impactBar(impact *big.Rat, width int) string {
+	filled := new(big.Rat).Quo(impact, d.maxImpact)
+	filled.Mul(filled, big.NewRat(int64(width), 1))
+	n := min(int(ratAmount(filled).Int64()), width)
+	return strings.Repeat("█", max(n, 1))
+}
+
+func (d *depthCurve) render() string {
+	b := &strings.Builder{}
+	unit := fmt.Sprintf("%s per %s", d.symbols[1], d.symbols[0])
+	t := table.NewWriter()
+	t.SetTitle(fmt.Sprintf("Depth, pool %s, %s/%s", Addr(d.pool.String()), d.symbols[0], d.symbols[1]))
+	t.AppendRow(table.Row{"Spot price (" + unit + ")", d.priceString(d.price(d.reserves[0], d.reserves[1]))})
+	t.AppendRow(table.Row{"Reserves", formatTokenAmount(d.reserves[0], d.decimals[0], d.symbols[0]) + " + " + formatTokenAmount(d.reserves[1], d.decimals[1], d.symbols[1])})
+	t.AppendRow(table.Row{"Trade fee", pctString(big.NewRat(int64(d.feeRate), feeRateDenom))})
+	b.WriteString(t.Render() + "\n")
+	for _, side := range d.sides {
+		in, out := side.in, 1-side.in
+		t := table.NewWriter()
+		t.SetTitle(fmt.Sprintf("Selling %s for %s", d.symbols[in], d.symbols[out]))
+		t.AppendHeader(table.Row{"Size", "Receive", "Avg price (" + unit + ")", "Impact", ""})
+		for _, p := range side.points {
+			t.AppendRow(table.Row{
+				formatTokenAmount(p.size, d.decimals[in], d.symbols[in]),
+				formatTokenAmount(p.out, d.decimals[out], d.symbols[out]),
+				d.priceString(p.price),
+				pctString(p.impact),
+				d.impactBar(p.impact, 24),
+			})
+		}
+		t.SetCaption("Up to a %s impact. Quoted at the current reserves, other swaps move the curve.", pctString(d.maxImpact))
+		b.WriteString(t.Render() + "\n")
+	}
+	return b.String()
+}
+
+type depthJSON struct {
+	Pool      string          `json:"pool"`
+	PriceUnit string          `json:"priceUnit"`
+	SpotPrice string          `json:"spotPrice"`
+	Reserves  [2]amountJSON   `json:"reserves"`
+	TradeFee  string          `json:"tradeFee"`
+	MaxImpact string          `json:"maxImpact"`
+	Sides     []depthSideJSON `json:"sides"`
+}
+
+type depthSideJSON struct {
+	Sell    string           `json:"sell"`
+	Receive string           `json:"receive"`
+	Points  []depthPointJSON `json:"points"`
+}
+
+type depthPointJSON struct {
+	Size    amountJSON `json:"size"`
+	Receive amountJSON `json:"receive"`
+	Price   string     `json:"price"`
+	Impact  string     `json:"impact"`
+}
+
+func (d *depthCurve) json() depthJSON {
+	out := depthJSON{
+		Pool:      d.pool.String(),
+		PriceUnit: d.symbols[1] + " per " + d.symbols[0],
+		SpotPrice: d.priceString(d.price(d.reserves[0], d.reserves[1])),
+		Reserves:  [2]amountJSON{newAmountJSON(d.reserves[0], d.decimals[0]), newAmountJSON(d.reserves[1], d.decimals[1])},
+		TradeFee:  pctString(big.NewRat(int64(d.feeRate), feeRateDenom)),
+		MaxImpact: pctString(d.maxImpact),
+	}
+	for _, side := range d.sides {
+		in, recv := side.in, 1-side.in
+		js := depthSideJSON{Sell: d.symbols[in], Receive: d.symbols[recv], Points: []depthPointJSON{}}
+		for _, p := range side.points {
+			js.Points = append(js.Points, depthPointJSON{
+				Size:    newAmountJSON(p.size, d.decimals[in]),
+				Receive: newAmountJSON(p.out, d.decimals[recv]),
+				Price:   d.priceString(p.price),
+				Impact:  pctString(p.impact),
+			})
+		}
+		out.Sides = append(out.Sides, js)
+	}
+	return out
+}
+
+func runPoolDepthCommand(args []string) error {
+	fs := flag.NewFlagSet("pool depth", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: pool depth [flags] <pool address or pair>\n")
+		fs.PrintDefaults()
+	}
+	nf := addNetworkFlags(fs)
+	var (
+		points    = fs.Int("points", 12, "Order sizes to sample each way")
+		maxImpact = fs.Float64("max-impact", 10, "Price impact percentage the largest size reaches, the others halve down from it")
+		asJSON    = fs.Bool("json", false, "Print the sampled curve as JSON instead of tables")
+	)
+	addAmountFlags(fs)
+	// The pool reads naturally first, `pool depth <pool> -network mainnet`, flag stops at the first argument.
+	var target string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		target, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, nf.specs())
+	if target == "" && fs.NArg() > 0 {
+		target = fs.Arg(0)
+	}
+	switch {
+	case target == "":
+		fs.Usage()
+		return errors.New("missing pool")
+	case *points <= 0 || *points > 64:
+		return errors.New("-points must be between 1 and 64")
+	case *maxImpact <= 0 || *maxImpact >= 100:
+		return errors.New("-max-impact must be a percentage between 0 and 100")
+	}
+	impact := new(big.Rat)
+	impact.SetFloat64(*maxImpact)
+	impact.Quo(impact, big.NewRat(100, 1))
+	client := nf.connect()
+	ctx, stop := interruptContext()
+	defer stop()
+
+	quoteCtx, cancel := deadlines.forQuote(ctx)
+	defer cancel()
+	poolAddr, err := resolvePoolTarget(quoteCtx, client, target, SymbolMapping{})
+	if err != nil {
+		return err
+	}
+	lp, err := loadPool(quoteCtx, client, poolAddr)
+	if err != nil {
+		return err
+	}
+	balances, errs := poolReserves(quoteCtx, client, lp.pool, lp.mints)
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("reading the pool's reserves failed: %w", err)
+	}
+	symbols := [2]string{lp.symbolsMap.SymFrom(lp.pool.Token0Mint), lp.symbolsMap.SymFrom(lp.pool.Token1Mint)}
+	decimals := [2]uint8{lp.pool.Mint0Decimals, lp.pool.Mint1Decimals}
+	d, err := newDepthCurve(poolAddr, symbols, decimals, [2]*big.Int{balances[0].Balance, balances[1].Balance}, lp.ammConfig.TradeFeeRate, impact, *points)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d.json())
+	}
+	fmt.Print(d.render())
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// depthPool is a SOL/USDC pool at 150 USDC per SOL, 100 SOL deep, with a 0.25% trade fee, sampled up to 10% impact.
+func depthPool(t *testing.T, points int) *depthCurve {
+	t.Helper()
+	d, err := newDepthCurve(snapshotKey(82), [2]string{"SOL", "USDC"}, [2]uint8{9, 6},
+		[2]*big.Int{big.NewInt(100_000_000_000), big.NewInt(15_000_000_000)}, 2500, big.NewRat(1, 10), points)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestDepthCurve(t *testing.T) {
+	d := depthPool(t, 12)
+	for _, side := range d.sides {
+		if len(side.points) < 10 {
+			t.Fatalf("side %d has %d points", side.in, len(side.points))
+		}
+		last := side.points[len(side.points)-1]
+		// The largest size is just under the cap, rounding only takes it lower.
+		if last.impact.Cmp(d.maxImpact) > 0 || last.impact.Cmp(big.NewRat(9, 100)) < 0 {
+			t.Errorf("side %d largest impact %s", side.in, pctString(last.impact))
+		}
+		for i := 1; i < len(side.points); i++ {
+			prev, p := side.points[i-1], side.points[i]
+			if p.size.Cmp(prev.size) <= 0 || p.impact.Cmp(prev.impact) < 0 {
+				t.Errorf("side %d isn't increasing at %d: %s %s after %s %s", side.in, i, p.size, pctString(p.impact), prev.size, pctString(prev.impact))
+			}
+		}
+	}
+	// Selling SOL gets less than spot for it, selling USDC pays more than spot for SOL.
+	spot := d.price(d.reserves[0], d.reserves[1])
+	if sell := d.sides[0].points[0].price; sell.Cmp(spot) >= 0 {
+		t.Errorf("selling SOL at %s, spot %s", d.priceString(sell), d.priceString(spot))
+	}
+	if buy := d.sides[1].points[0].price; buy.Cmp(spot) <= 0 {
+		t.Errorf("buying SOL at %s, spot %s", d.priceString(buy), d.priceString(spot))
+	}
+
+	out := d.render()
+	for _, want := range []string{"Selling SOL for USDC", "Selling USDC for SOL", "Spot price (USDC per SOL)", "150", "█"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("%q isn't in\n%s", want, out)
+		}
+	}
+	js := d.json()
+	if len(js.Sides) != 2 || js.Sides[1].Sell != "USDC" || len(js.Sides[0].Points) != len(d.sides[0].points) || js.SpotPrice != "150" {
+		t.Errorf("json %+v", js)
+	}
+}
+
+func TestDepthSizes(t *testing.T) {
+	sizes := depthSizes(big.NewInt(11_481_481_481), 4)
+	want := []int64{1_400_000_000, 2_800_000_000, 5_700_000_000, 11_000_000_000}
+	if len(sizes) != len(want) {
+		t.Fatalf("sizes %v", sizes)
+	}
+	for i := range want {
+		if sizes[i].Int64() != want[i] {
+			t.Errorf("size %d is %s, want %d", i, sizes[i], want[i])
+		}
+	}
+	// Halving a tiny size runs into repeats and zero, neither is sampled.
+	if sizes := depthSizes(big.NewInt(3), 5); len(sizes) != 2 || sizes[0].Int64() != 1 {
+		t.Errorf("tiny sizes %v", sizes)
+	}
+	if _, err := maxDepthSize(big.NewInt(1_000), 2500, big.NewRat(1, 1000)); err == nil {
+		t.Error("a cap under the fee has a size")
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+/*
+NOTE(@hadydotai): Falling back to another pool.
+
+Some swap failures are about the pool rather than about us: the admin paused swaps, one side got drained, or the
+reserves keep moving past our slippage guard. There's usually more than one CP-Swap pool for a pair, so with
+-fallback-pools we go looking for the next best one, quote the same intent against it, show the user the new numbers,
+and only retry once they say yes. Anything that isn't pool specific (bad wallet balance, RPC down) still just fails.
+
+Raydium's custom error codes, from programs/cp-swap/src/error.rs. Anchor numbers them from 6000 in declaration order.
+*/
+
+const (
+	cpSwapErrNotApproved       = 6000 // pool status disallows swapping, or the pool isn't open yet
+	cpSwapErrExceededSlippage  = 6005
+	cpSwapErrZeroTradingTokens = 6006
+	cpSwapErrInsufficientVault = 6012
+)
+
+var poolSpecificErrors = map[uint64]string{
+	cpSwapErrNotApproved:       "swapping is paused on the pool or it isn't open yet",
+	cpSwapErrExceededSlippage:  "the pool moved past the slippage guard",
+	cpSwapErrZeroTradingTokens: "the pool can't produce any output for this amount",
+	cpSwapErrInsufficientVault: "the pool vault doesn't hold enough liquidity",
+}
+
+// poolFailure is a swap failure that's down to the pool, trying another pool might work.
+type poolFailure struct {
+	pool   solana.PublicKey
+	reason string
+	kind   error // which of the error kinds (error_codes.go) it is, nil for none
+	err    error
+}
+
+func (pf *poolFailure) Error() string {
+	if pf.err == nil {
+		return fmt.Sprintf("pool %s: %s", Addr(pf.pool.String()), pf.reason)
+	}
+	return fmt.Sprintf("pool %s: %s: %v", Addr(pf.pool.String()), pf.reason, pf.err)
+}
+
+func (pf *poolFailure) Unwrap() error {
+	return pf.err
+}
+
+func (pf *poolFailure) Is(target error) bool {
+	return pf.kind != nil && target == pf.kind
+}
+
+// Instruction errors show up as {"Custom":6005} from preflight, map[Custom:6005] from transaction meta, and
+// "custom program error: 0x1775" in logs, depending on who's doing the telling.
+var (
+	customErrDecimalRe = regexp.MustCompile(`Custom"?:\s*(\d+)`)
+	customErrHexRe     = regexp.MustCompile(`custom program error: 0x([0-9a-fA-F]+)`)
+)
+
+// customErrorCode digs a program's custom error code out of whatever shape the failure came in.
+func customErrorCode(v any) (uint64, bool) {
+	if v == nil {
+		return 0, false
+	}
+	var text string
+	switch t := v.(type) {
+	case error:
+		text = t.Error()
+		// NOTE(@hadydotai): RPC errors keep the interesting part (the simulation result) in Data, not in the message.
+		var rpcErr *jsonrpc.RPCError
+		if errors.As(t, &rpcErr) {
+			text += fmt.Sprintf(" %+v", rpcErr.Data)
+		}
+	default:
+		text = fmt.Sprintf("%+v", t)
+	}
+	if m := customErrDecimalRe.FindStringSubmatch(text); m != nil {
+		if code, err := strconv.ParseUint(m[1], 10, 32); err == nil {
+			return code, true
+		}
+	}
+	if m := customErrHexRe.FindStringSubmatch(text); m != nil {
+		if code, err := strconv.ParseUint(m[1], 16, 32); err == nil {
+			return code, true
+		}
+	}
+	return 0, false
+}
+
+// asPoolFailure classifies err, returning nil when the failure has nothing to do with the pool.
+func asPoolFailure(pool solana.PublicKey, err error) *poolFailure {
+	var pf *poolFailure
+	if errors.As(err, &pf) {
+		return pf
+	}
+	code, ok := customErrorCode(err)
+	if !ok {
+		return nil
+	}
+	reason, ok := poolSpecificErrors[code]
+	if !ok {
+		return nil
+	}
+	return &poolFailure{pool: pool, reason: reason, kind: cpSwapErrorKinds[code], err: err}
+}
+
+// checkPoolTradable catches the failures we can see coming without spending a transaction on them.
+func checkPoolTradable(address solana.PublicKey, pool *raydium_cp_swap.PoolState, now time.Time) *poolFailure {
+	if pool.Status&poolStatusDisableSwap != 0 {
+		return &poolFailure{pool: address, reason: "swapping is disabled on the pool (" + describePoolStatus(pool.Status) + ")", kind: ErrPoolDisabled}
+	}
+	if pool.OpenTime > uint64(now.Unix()) {
+		return &poolFailure{pool: address, reason: "the pool doesn't open until " + unixString(pool.OpenTime), kind: ErrPoolDisabled}
+	}
+	return nil
+}
+
+// betterQuote reports whether a is a better deal than b for the same intent: more out when selling, less in when buying.
+func betterQuote(a, b *CPIntent) bool {
+	if a.SwapKind == SwapKindBaseOutput {
+		return a.Amounts.QuoteAmount.Cmp(b.Amounts.QuoteAmount) < 0
+	}
+	return a.Amounts.QuoteAmount.Cmp(b.Amounts.QuoteAmount) > 0
+}
+
+// alternatePools quotes the intent against every other CP-Swap pool for the pair and returns the ones that can take
+// it, best quote first. Pools in exclude (already tried) and pools that can't trade right now are skipped.
+func alternatePools(ctx context.Context, client *rpc.Client, current *TableBuilder, intentLine string, exclude map[solana.PublicKey]bool) ([]poolCandidate, error) {
+	snap := current.snapshot()
+	mints := snap.venue.Mints()
+	addrs, err := findPoolsByMints(ctx, client, mints[0], mints[1])
+	if err != nil {
+		return nil, err
+	}
+	addrs = slices.DeleteFunc(addrs, func(addr solana.PublicKey) bool { return exclude[addr] })
+	var candidates []poolCandidate
+	for _, c := range quotePools(ctx, client, snap, addrs, intentLine) {
+		if c.err == nil {
+			candidates = append(candidates, c)
+		}
+	}
+	rankCandidates(candidates)
+	return candidates, nil
+}
+
+// executeIntent sends the intent against the builder's current pool and waits for the outcome. A transaction that
+// lands but fails comes back as a txFailedError, alongside its summary.
+func executeIntent(ctx context.Context, client *rpc.Client, payer solana.PrivateKey, builder *TableBuilder, intent *CPIntent) (txSummaryData, solana.Signature, error) {
+	return executeIntentOnce(ctx, client, payer, builder, intent, nil)
+}
+
+// executeIntentOnce is executeIntent under guard (see send_journal.go): whatever's still in flight under its key is
+// settled first, and an earlier attempt that landed is the outcome, nothing new is sent. A nil guard sends right away.
+func executeIntentOnce(ctx context.Context, client *rpc.Client, payer solana.PrivateKey, builder *TableBuilder, intent *CPIntent, guard *sendGuard) (txSummaryData, solana.Signature, error) {
+	snap := builder.snapshot()
+	ctx, cancel := deadlines.forSend(ctx)
+	defer cancel()
+	if guard != nil {
+		summary, sig, landed, err := landedAttempt(ctx, client, snap, intent, guard)
+		if err != nil || landed {
+			return summary, sig, err
+		}
+	}
+	if err := snap.venue.CheckTradable(time.Now()); err != nil {
+		return txSummaryData{}, solana.Signature{}, err
+	}
+	intent, err := refreshPercentIntent(ctx, client, builder, intent)
+	if err != nil {
+		return txSummaryData{}, solana.Signature{}, err
+	}
+	if err := checkQuoteDrift(ctx, client, builder, intent); err != nil {
+		return txSummaryData{}, solana.Signature{}, err
+	}
+	if minOutFromSim {
+		if intent, err = guardFromSimulation(ctx, client, payer.PublicKey(), intent, snap.slippageRat); err != nil {
+			return txSummaryData{}, solana.Signature{}, err
+		}
+	}
+	return sendRoute(ctx, client, payer, snap, intent, []*CPIntent{intent}, guard)
+}
+
+// landedAttempt settles what's in flight under guard and, when an earlier attempt landed, reads back what it paid and
+// received. intent is the swap as quoted now, the attempt traded the same pool the same way.
+func landedAttempt(ctx context.Context, client *rpc.Client, snap quoteSnapshot, intent *CPIntent, guard *sendGuard) (txSummaryData, solana.Signature, bool, error) {
+	sig, landed, err := guard.settle(ctx, client)
+	if err != nil || !landed {
+		return txSummaryData{}, solana.Signature{}, false, err
+	}
+	log.Printf("earlier attempt %s landed, not sending again", sig)
+	summary, err := awaitSwapSummary(ctx, client, sig, intent.TokenIn, intent.TokenOut,
+		snap.symm.SymFrom(intent.TokenIn.Mint), snap.symm.SymFrom(intent.TokenOut.Mint))
+	if err != nil {
+		log.Printf("warning: reading back transaction %s failed: %v", sig, err)
+	}
+	return summary, sig, true, nil
+}
+
+// sendRoute plans, sends and waits on the legs in one transaction. intent describes the swap as a whole, it's what
+// webhooks and the empty account cleanup see, for a single leg it's that leg. With a guard the transaction is
+// journaled before it's sent.
+func sendRoute(ctx context.Context, client *rpc.Client, payer solana.PrivateKey, snap quoteSnapshot, intent *CPIntent, legs []*CPIntent, guard *sendGuard) (txSummaryData, solana.Signature, error) {
+	hook := newSwapHook(notifier, snap, intent)
+	hook.quoteAccepted()
+	hooked := newHookSwap(intent, snap.symm, payer.PublicKey())
+	if err := preSendHooks(ctx, hooked); err != nil {
+		hook.sendFailed(err)
+		return txSummaryData{}, solana.Signature{}, err
+	}
+	plan, err := planRoute(ctx, client, payer.PublicKey(), legs)
+	if err != nil {
+		hook.sendFailed(err)
+		return txSummaryData{}, solana.Signature{}, err
+	}
+	tx, err := signTransaction(ctx, client, payer, plan.instructions)
+	if err == nil {
+		err = guard.sending(tx)
+	}
+	if err != nil {
+		hook.sendFailed(err)
+		return txSummaryData{}, solana.Signature{}, err
+	}
+	sig, err := sendTransaction(ctx, client, tx)
+	if err != nil {
+		err = guard.sendFailed(tx, err)
+		hook.sendFailed(err)
+		return txSummaryData{}, solana.Signature{}, err
+	}
+	hook.sent(sig)
+	log.Println("Tx: ", sig.String())
+	if sig, err = landTransaction(ctx, client, payer, tx, plan.instructions, guard); err != nil {
+		hook.sendFailed(err)
+		return txSummaryData{}, sig, err
+	}
+	legsIn, legsOut := make([]SwapLeg, len(legs)), make([]SwapLeg, len(legs))
+	for i, leg := range legs {
+		legsIn[i], legsOut[i] = leg.TokenIn, leg.TokenOut
+	}
+	summary, waitErr := awaitRouteSummary(ctx, client, sig, legsIn, legsOut,
+		snap.symm.SymFrom(intent.TokenIn.Mint), snap.symm.SymFrom(intent.TokenOut.Mint),
+	)
+	if waitErr != nil {
+		log.Printf("warning: waiting for transaction confirmation failed: %v", waitErr)
+	}
+	hook.landed(summary)
+	postConfirmHooks(ctx, hooked, summary)
+	guard.outcome(sig, summary.Status)
+	if summary.Status == "failed" {
+		return summary, sig, &txFailedError{sig: sig, txErr: summary.TxErr, logs: summary.Logs}
+	}
+	if closeEmptyATAs {
+		if reclaimed, err := closeEmptySwapAccounts(ctx, client, payer, intent); err != nil {
+			log.Printf("warning: %v", err)
+		} else if reclaimed > 0 {
+			log.Printf("closed empty token accounts, reclaimed %s SOL", fmtAmount(new(big.Int).SetUint64(reclaimed), 9))
+		}
+	}
+	return summary, sig, nil
+}
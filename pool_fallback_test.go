@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+func TestCustomErrorCode(t *testing.T) {
+	cases := []struct {
+		name string
+		in   any
+		code uint64
+		ok   bool
+	}{
+		{"preflight json", errors.New(`{"InstructionError":[2,{"Custom":6005}]}`), 6005, true},
+		{"meta map", map[string]any{"InstructionError": []any{2, map[string]any{"Custom": 6012}}}, 6012, true},
+		{"log line", errors.New("Program log: custom program error: 0x1770"), 6000, true},
+		{"rpc data", fmt.Errorf("send: %w", &jsonrpc.RPCError{Message: "simulation failed", Data: map[string]any{"err": map[string]any{"Custom": 6006}}}), 6006, true},
+		{"unrelated", errors.New("insufficient funds for rent"), 0, false},
+		{"nil", nil, 0, false},
+	}
+	for _, tc := range cases {
+		code, ok := customErrorCode(tc.in)
+		if code != tc.code || ok != tc.ok {
+			t.Errorf("%s: got (%d, %v), want (%d, %v)", tc.name, code, ok, tc.code, tc.ok)
+		}
+	}
+}
+
+func TestAsPoolFailure(t *testing.T) {
+	pool := solana.NewWallet().PublicKey()
+	pf := asPoolFailure(pool, errors.New(`custom program error: 0x1775`))
+	if pf == nil || pf.reason != poolSpecificErrors[cpSwapErrExceededSlippage] {
+		t.Fatalf("expected slippage to be a pool failure, got %v", pf)
+	}
+	if asPoolFailure(pool, errors.New(`{"Custom":1}`)) != nil {
+		t.Fatalf("token program errors aren't the pool's fault")
+	}
+	wrapped := fmt.Errorf("retry: %w", &poolFailure{pool: pool, reason: "drained"})
+	if pf := asPoolFailure(solana.PublicKey{}, wrapped); pf == nil || pf.pool != pool {
+		t.Fatalf("expected the wrapped pool failure back, got %v", pf)
+	}
+}
+
+func TestCheckPoolTradable(t *testing.T) {
+	addr := solana.NewWallet().PublicKey()
+	now := time.Unix(1_700_000_000, 0)
+	if pf := checkPoolTradable(addr, &raydium_cp_swap.PoolState{OpenTime: 1}, now); pf != nil {
+		t.Fatalf("open pool reported as %v", pf)
+	}
+	if checkPoolTradable(addr, &raydium_cp_swap.PoolState{Status: poolStatusDisableSwap}, now) == nil {
+		t.Fatalf("expected disabled swaps to be caught")
+	}
+	if checkPoolTradable(addr, &raydium_cp_swap.PoolState{OpenTime: uint64(now.Unix()) + 60}, now) == nil {
+		t.Fatalf("expected a pool that isn't open yet to be caught")
+	}
+}
+
+func TestBetterQuote(t *testing.T) {
+	quote := func(kind SwapKind, q int64) *CPIntent {
+		return &CPIntent{SwapKind: kind, Amounts: SwapAmounts{QuoteAmount: big.NewInt(q)}}
+	}
+	if !betterQuote(quote(SwapKindBaseInput, 110), quote(SwapKindBaseInput, 100)) {
+		t.Fatalf("selling: more out should win")
+	}
+	if !betterQuote(quote(SwapKindBaseOutput, 90), quote(SwapKindBaseOutput, 100)) {
+		t.Fatalf("buying: less in should win")
+	}
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/ws"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+/*
+NOTE(@hadydotai): Pool index.
+
+Every pair lookup (`-pool SOL/USDC`, -compare, -best, the portfolio's prices) is two getProgramAccounts scans of the
+whole CP-Swap program. They're slow, public RPCs rate limit them hard and some don't serve them at all. The pool index
+is the scan done once and kept: every pool's mints, its AmmConfig and trade fee, and its LP supply as the last-known
+liquidity, written to the user's config directory (-pool-index) by `pool-index build`.
+
+findPoolsByMints reads the index when there's one for the network, and only scans when the index has no pool for the
+pair, so a pool created after the index was built is still found the slow way. The index is kept fresh with a
+programSubscribe on the pool accounts: `pool-index watch` runs it in the foreground, and `serve` runs it in the
+background whenever an index is loaded, both writing what changed back every -pool-index-save. Each entry keeps the
+slot it was seen at, an update older than what's already there is dropped.
+
+An index is only used on the network and program it was built for, one built on devnet does nothing on mainnet, point
+-pool-index at a file per network to keep both.
+*/
+
+// poolIndexEntry is what the index keeps of a pool.
+type poolIndexEntry struct {
+	Mint0        string `json:"mint0"`
+	Mint1        string `json:"mint1"`
+	AmmConfig    string `json:"ammConfig"`
+	TradeFeeRate uint64 `json:"tradeFeeRate"` // 0 when the config couldn't be read
+	LpSupply     uint64 `json:"lpSupply"`
+	Status       uint8  `json:"status"`
+	Slot         uint64 `json:"slot"`
+}
+
+type poolIndex struct {
+	Network string                    `json:"network"`
+	Program string                    `json:"program"`
+	Built   time.Time                 `json:"built"`
+	Slot    uint64                    `json:"slot"` // the slot the full scan started at
+	Pools   map[string]poolIndexEntry `json:"pools"`
+
+	mu    sync.RWMutex
+	fees  map[string]uint64 // amm config -> trade fee rate
+	dirty bool
+}
+
+// pairIndex is the index pair lookups read, nil when there's none for the network.
+var pairIndex *poolIndex
+
+func defaultPoolIndexPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "pool-index.json"
+	}
+	return filepath.Join(dir, "raydium-client", "pool-index.json")
+}
+
+func addPoolIndexFlag(fs *flag.FlagSet) *string {
+	return fs.String("pool-index", defaultPoolIndexPath(), "Pool index pair lookups read instead of scanning the program, see `pool-index build`")
+}
+
+// loadPoolIndex reads an index written by save, a missing file is no index.
+func loadPoolIndex(path string) (*poolIndex, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading pool index %s: %w", path, err)
+	}
+	idx := &poolIndex{}
+	if err := json.Unmarshal(raw, idx); err != nil {
+		return nil, fmt.Errorf("pool index %s is corrupt: %w", path, err)
+	}
+	if idx.Pools == nil {
+		idx.Pools = map[string]poolIndexEntry{}
+	}
+	return idx, nil
+}
+
+// usePoolIndexFile makes the index at path what pair lookups read, when it was built for network and the program
+// we're pointed at. An index that can't be read is warned about and lookups scan like they always did.
+func usePoolIndexFile(path, network string) {
+	pairIndex = nil
+	if path == "" {
+		return
+	}
+	idx, err := loadPoolIndex(path)
+	if err != nil {
+		log.Printf("warning: %v, scanning for pools instead", err)
+		return
+	}
+	if idx == nil || idx.Network != network || idx.Program != raydium_cp_swap.ProgramID.String() {
+		return
+	}
+	pairIndex = idx
+}
+
+// save writes the index to path, replacing whatever is there.
+func (idx *poolIndex) save(path string) error {
+	idx.mu.Lock()
+	raw, err := json.Marshal(idx)
+	idx.dirty = false
+	idx.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("writing pool index: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("writing pool index: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(raw, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing pool index: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing pool index: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// refreshFees reads the program's AmmConfigs again, for the trade fee of each pool's tier.
+func (idx *poolIndex) refreshFees(ctx context.Context, client *rpc.Client) error {
+	configs, err := fetchAmmConfigs(ctx, client)
+	if err != nil {
+		return err
+	}
+	fees := make(map[string]uint64, len(configs))
+	for _, c := range configs {
+		fees[c.address.String()] = c.config.TradeFeeRate
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.fees = fees
+	for addr, entry := range idx.Pools {
+		if fee, ok := fees[entry.AmmConfig]; ok && fee != entry.TradeFeeRate {
+			entry.TradeFeeRate = fee
+			idx.Pools[addr] = entry
+			idx.dirty = true
+		}
+	}
+	return nil
+}
+
+// apply records the PoolState in data for address as seen at slot. It reports whether the pool's AmmConfig is one the
+// index doesn't know the fee of.
+func (idx *poolIndex) apply(address solana.PublicKey, data []byte, slot uint64) (unknownConfig bool, err error) {
+	pool, err := raydium_cp_swap.ParseAccount_PoolState(data)
+	if err != nil {
+		return false, err
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if prev, ok := idx.Pools[address.String()]; ok && prev.Slot > slot {
+		return false, nil
+	}
+	fee, known := idx.fees[pool.AmmConfig.String()]
+	idx.Pools[address.String()] = poolIndexEntry{
+		Mint0:        pool.Token0Mint.String(),
+		Mint1:        pool.Token1Mint.String(),
+		AmmConfig:    pool.AmmConfig.String(),
+		TradeFeeRate: fee,
+		LpSupply:     pool.LpSupply,
+		Status:       pool.Status,
+		Slot:         slot,
+	}
+	idx.dirty = true
+	return !known, nil
+}
+
+// lookup is every indexed pool trading the pair, in either token order, sorted by address like findPoolsByMints. A
+// nil index knows no pools.
+func (idx *poolIndex) lookup(mintA, mintB solana.PublicKey) []solana.PublicKey {
+	if idx == nil {
+		return nil
+	}
+	a, b := mintA.String(), mintB.String()
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	var found []solana.PublicKey
+	for addr, entry := range idx.Pools {
+		if (entry.Mint0 == a && entry.Mint1 == b) || (entry.Mint0 == b && entry.Mint1 == a) {
+			found = append(found, solana.MustPublicKeyFromBase58(addr))
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return bytes.Compare(found[i][:], found[j][:]) < 0 })
+	return found
+}
+
+// buildPoolIndex scans the program for every pool, the one heavy call the index exists to save.
+func buildPoolIndex(ctx context.Context, client *rpc.Client, network string) (*poolIndex, error) {
+	idx := &poolIndex{
+		Network: network,
+		Program: raydium_cp_swap.ProgramID.String(),
+		Built:   time.Now().UTC(),
+		Pools:   map[string]poolIndexEntry{},
+	}
+	if err := idx.refreshFees(ctx, client); err != nil {
+		return nil, err
+	}
+	slot, err := client.GetSlot(ctx, rpc.CommitmentConfirmed)
+	if err != nil {
+		return nil, fmt.Errorf("rpc call getSlot failed: %w", err)
+	}
+	idx.Slot = slot
+	accounts, err := client.GetProgramAccountsWithOpts(ctx, raydium_cp_swap.ProgramID, &rpc.GetProgramAccountsOpts{
+		Encoding:   solana.EncodingBase64,
+		Commitment: rpc.CommitmentConfirmed,
+		Filters: []rpc.RPCFilter{
+			{Memcmp: &rpc.RPCFilterMemcmp{Offset: 0, Bytes: raydium_cp_swap.Account_PoolState[:]}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("rpc call getProgramAccounts failed: %w", err)
+	}
+	for _, acc := range accounts {
+		if _, err := idx.apply(acc.Pubkey, acc.Account.Data.GetBinary(), slot); err != nil {
+			log.Printf("warning: skipping pool %s: %v", acc.Pubkey, err)
+		}
+	}
+	return idx, nil
+}
+
+// follow keeps the index up to date from a programSubscribe on the pool accounts until ctx ends or the subscription
+// does, writing it to path every saveEvery while it has changes and once more on the way out.
+func (idx *poolIndex) follow(ctx context.Context, client *rpc.Client, wsEP, path string, saveEvery time.Duration) error {
+	wsClient, err := ws.Connect(ctx, wsEP)
+	if err != nil {
+		return fmt.Errorf("websocket connection to %s failed: %w", wsEP, err)
+	}
+	defer wsClient.Close()
+	sub, err := wsClient.ProgramSubscribeWithOpts(raydium_cp_swap.ProgramID, rpc.CommitmentConfirmed, solana.EncodingBase64, []rpc.RPCFilter{
+		{Memcmp: &rpc.RPCFilterMemcmp{Offset: 0, Bytes: raydium_cp_swap.Account_PoolState[:]}},
+	})
+	if err != nil {
+		return fmt.Errorf("subscribing to the program's pools failed: %w", err)
+	}
+	defer sub.Unsubscribe()
+
+	saveIfDirty := func() {
+		idx.mu.RLock()
+		dirty := idx.dirty
+		idx.mu.RUnlock()
+		if dirty {
+			if err := idx.save(path); err != nil {
+				log.Printf("warning: %v", err)
+			}
+		}
+	}
+	defer saveIfDirty()
+	ticker := time.NewTicker(saveEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			saveIfDirty()
+		case err := <-sub.Err():
+			return fmt.Errorf("pool subscription ended: %w", err)
+		case res := <-sub.Response():
+			if res == nil || res.Value.Account == nil {
+				continue
+			}
+			unknownConfig, err := idx.apply(res.Value.Pubkey, res.Value.Account.Data.GetBinary(), res.Context.Slot)
+			if err != nil {
+				log.Printf("warning: skipping an update to pool %s: %v", res.Value.Pubkey, err)
+				continue
+			}
+			if unknownConfig {
+				// A pool on a fee tier created after the index was, read the tiers again to learn its fee.
+				feeCtx, cancel := deadlines.forQuote(ctx)
+				if err := idx.refreshFees(feeCtx, client); err != nil {
+					log.Printf("warning: reading the fee tiers failed: %v", err)
+				}
+				cancel()
+			}
+		}
+	}
+}
+
+// followPoolIndexInBackground runs follow on the loaded index until ctx ends, for long running commands.
+func followPoolIndexInBackground(ctx context.Context, client *rpc.Client, wsEP, path string) {
+	if pairIndex == nil {
+		return
+	}
+	go func() {
+		if err := pairIndex.follow(ctx, client, wsEP, path, 30*time.Second); err != nil {
+			log.Printf("warning: the pool index stopped refreshing: %v", err)
+		}
+	}()
+}
+
+func runPoolIndexCommand(args []string) error {
+	return dispatchSubcommand("pool-index", map[string]func([]string) error{
+		"build": runPoolIndexBuildCommand,
+		"watch": runPoolIndexWatchCommand,
+		"list":  runPoolIndexListCommand,
+	}, args)
+}
+
+func runPoolIndexBuildCommand(args []string) error {
+	fs := flag.NewFlagSet("pool-index build", flag.ExitOnError)
+	nf := addNetworkFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, append(nf.specs(), FlagSpec{Name: "pool-index", Value: nf.poolIndex, Rules: []FlagRule{NotEmpty()}}))
+	client := nf.connect()
+	ctx, stop := interruptContext()
+	defer stop()
+	started := time.Now()
+	idx, err := buildPoolIndex(ctx, client, *nf.network)
+	if err != nil {
+		return err
+	}
+	if err := idx.save(*nf.poolIndex); err != nil {
+		return err
+	}
+	log.Printf("indexed %d pools on %s as of slot %d in %s, written to %s", len(idx.Pools), *nf.network, idx.Slot, time.Since(started).Round(time.Millisecond), *nf.poolIndex)
+	return nil
+}
+
+func runPoolIndexWatchCommand(args []string) error {
+	fs := flag.NewFlagSet("pool-index watch", flag.ExitOnError)
+	nf := addNetworkFlags(fs)
+	var (
+		wsEP      = fs.String("ws", "", "WebSocket endpoint to subscribe on, derived from -rpc when empty")
+		saveEvery = fs.Duration("pool-index-save", 30*time.Second, "How often changes are written to the index")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, append(nf.specs(), FlagSpec{Name: "pool-index", Value: nf.poolIndex, Rules: []FlagRule{NotEmpty()}}))
+	if *saveEvery <= 0 {
+		return fmt.Errorf("-pool-index-save has to be positive, got %s", *saveEvery)
+	}
+	client := nf.connect()
+	if *wsEP == "" {
+		*wsEP = wsEndpointFor(*nf.rpcEP)
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	if pairIndex == nil {
+		log.Printf("no pool index for %s at %s yet, building it", *nf.network, *nf.poolIndex)
+		idx, err := buildPoolIndex(ctx, client, *nf.network)
+		if err != nil {
+			return err
+		}
+		if err := idx.save(*nf.poolIndex); err != nil {
+			return err
+		}
+		pairIndex = idx
+	} else if err := pairIndex.refreshFees(ctx, client); err != nil {
+		return err
+	}
+	log.Printf("following %d pools on %s, writing changes to %s every %s", len(pairIndex.Pools), *nf.network, *nf.poolIndex, *saveEvery)
+	return pairIndex.follow(ctx, client, *wsEP, *nf.poolIndex, *saveEvery)
+}
+
+func runPoolIndexListCommand(args []string) error {
+	fs := flag.NewFlagSet("pool-index list", flag.ExitOnError)
+	nf := addNetworkFlags(fs)
+	var pair string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		pair, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, nf.specs())
+	nf.connect()
+	if pairIndex == nil {
+		return fmt.Errorf("there's no pool index for %s at %s, run `pool-index build` first", *nf.network, *nf.poolIndex)
+	}
+	_, tokens, isPair, err := parsePoolTarget(pair)
+	if err != nil || !isPair {
+		return fmt.Errorf("pool-index list needs a pair like SOL/USDC")
+	}
+	var mints [2]solana.PublicKey
+	for i, token := range tokens {
+		if mints[i], err = lookupPairToken(token, SymbolMapping{}); err != nil {
+			return err
+		}
+	}
+	fmt.Print(renderPoolIndexEntries(pairIndex, mints))
+	return nil
+}
+
+// renderPoolIndexEntries lays out what the index has on the pair's pools, most liquid first.
+func renderPoolIndexEntries(idx *poolIndex, mints [2]solana.PublicKey) string {
+	pools := idx.lookup(mints[0], mints[1])
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	sort.SliceStable(pools, func(i, j int) bool {
+		return idx.Pools[pools[i].String()].LpSupply > idx.Pools[pools[j].String()].LpSupply
+	})
+	builder := &strings.Builder{}
+	t := table.NewWriter()
+	t.SetOutputMirror(builder)
+	t.SetTitle(fmt.Sprintf("Indexed pools for %s/%s", Addr(mints[0].String()), Addr(mints[1].String())))
+	t.AppendHeader(table.Row{"Pool", "Trade fee", "LP supply", "Status", "Seen at slot"})
+	for _, pool := range pools {
+		entry := idx.Pools[pool.String()]
+		fee := "?"
+		if entry.TradeFeeRate != 0 {
+			fee = formatFeeRate(entry.TradeFeeRate)
+		}
+		t.AppendRow(table.Row{pool.String(), fee, entry.LpSupply, describePoolStatus(entry.Status), entry.Slot})
+	}
+	t.AppendFooter(table.Row{fmt.Sprintf("%d pools", len(pools)), "", "", "", fmt.Sprintf("built %s", idx.Built.Format(time.RFC3339))})
+	t.Render()
+	return builder.String()
+}
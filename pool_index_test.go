@@ -0,0 +1,80 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+func TestPoolIndex(t *testing.T) {
+	defer func(saved *poolIndex) { pairIndex = saved }(pairIndex)
+	sol, usdc, bonk := snapshotKey(1), snapshotKey(2), snapshotKey(3)
+	tier, stray := snapshotKey(4), snapshotKey(5)
+	poolAt := func(mint0, mint1, config solana.PublicKey, lpSupply uint64) []byte {
+		return anchorAccount(t, raydium_cp_swap.Account_PoolState, raydium_cp_swap.PoolState{Token0Mint: mint0, Token1Mint: mint1, AmmConfig: config, LpSupply: lpSupply})
+	}
+	idx := &poolIndex{Network: "devnet", Program: raydium_cp_swap.ProgramID.String(), Pools: map[string]poolIndexEntry{}, fees: map[string]uint64{tier.String(): 2500}}
+	for _, p := range []struct {
+		addr   byte
+		data   []byte
+		config bool
+	}{
+		{20, poolAt(sol, usdc, tier, 1000), false},
+		{10, poolAt(usdc, sol, stray, 50), true},
+		{30, poolAt(sol, bonk, tier, 7), false},
+	} {
+		unknown, err := idx.apply(snapshotKey(p.addr), p.data, 100)
+		if err != nil || unknown != p.config {
+			t.Fatalf("pool %d: unknown config %v, %v", p.addr, unknown, err)
+		}
+	}
+	if _, err := idx.apply(snapshotKey(40), []byte{1, 2, 3}, 100); err == nil {
+		t.Error("indexed garbage")
+	}
+
+	// An older update doesn't undo a newer one.
+	if _, err := idx.apply(snapshotKey(20), poolAt(sol, usdc, tier, 2000), 101); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.apply(snapshotKey(20), poolAt(sol, usdc, tier, 1), 99); err != nil {
+		t.Fatal(err)
+	}
+	if e := idx.Pools[snapshotKey(20).String()]; e.LpSupply != 2000 || e.Slot != 101 || e.TradeFeeRate != 2500 {
+		t.Errorf("entry %+v", e)
+	}
+
+	pools := idx.lookup(usdc, sol)
+	if len(pools) != 2 || !pools[0].Equals(snapshotKey(10)) || !pools[1].Equals(snapshotKey(20)) {
+		t.Errorf("SOL/USDC pools %v", pools)
+	}
+	out := renderPoolIndexEntries(idx, [2]solana.PublicKey{sol, usdc})
+	if !strings.Contains(out, "0.25%") || !strings.Contains(out, "?") || strings.Index(out, snapshotKey(20).String()) > strings.Index(out, snapshotKey(10).String()) {
+		t.Errorf("the more liquid pool isn't listed first with its fee\n%s", out)
+	}
+
+	path := filepath.Join(t.TempDir(), "pool-index.json")
+	if err := idx.save(path); err != nil {
+		t.Fatal(err)
+	}
+	usePoolIndexFile(path, "mainnet")
+	if pairIndex != nil {
+		t.Error("a devnet index was used on mainnet")
+	}
+	usePoolIndexFile(path, "devnet")
+	if pairIndex == nil || len(pairIndex.Pools) != 3 {
+		t.Fatalf("loaded index %+v", pairIndex)
+	}
+	// Pair lookups are answered from the index, without an RPC.
+	found, err := findPoolsByMints(t.Context(), nil, sol, bonk)
+	if err != nil || len(found) != 1 || !found[0].Equals(snapshotKey(30)) {
+		t.Errorf("SOL/BONK from the index %v, %v", found, err)
+	}
+	usePoolIndexFile(filepath.Join(t.TempDir(), "missing.json"), "devnet")
+	if pairIndex != nil {
+		t.Error("a missing file gave an index")
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const (
+	// NOTE(@hadydotai): PoolState is laid out as 8 bytes of anchor discriminator, then amm_config, pool_creator,
+	// token0_vault, token1_vault, lp_mint, each a 32 byte pubkey, so token0_mint starts at 8+5*32 and token1_mint right
+	// after it. If Raydium ever reshuffles PoolState this breaks silently (we'd just find no pools), keep an eye on the IDL.
+	poolStateToken0MintOffset = 8 + 5*32
+	poolStateToken1MintOffset = poolStateToken0MintOffset + 32
+)
+
+// fetchPoolState fetches and decodes a Raydium CP-Swap PoolState account.
+func fetchPoolState(ctx context.Context, client *rpc.Client, poolPubK solana.PublicKey) (*raydium_cp_swap.PoolState, error) {
+	accountInfo, err := client.GetAccountInfoWithOpts(ctx, poolPubK, &rpc.GetAccountInfoOpts{Encoding: solana.EncodingBase64})
+	if isAccountMissingErr(err) || (err == nil && (accountInfo == nil || accountInfo.Value == nil)) {
+		return nil, &poolNotFoundError{pool: poolPubK, cluster: connectedCluster}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rpc call getAccountInfo for Pool failed, check if the RPC endpoint is valid, or if you're being limited: %w", classifyRPC(err))
+	}
+	if owner := accountInfo.Value.Owner; !owner.Equals(raydium_cp_swap.ProgramID) {
+		if name := otherRaydiumProgram(connectedCluster, owner); name != "" {
+			return nil, fmt.Errorf("%s is a Raydium %s pool, only CP-Swap (CPMM) pools are supported", poolPubK, name)
+		}
+		return nil, fmt.Errorf("%s on %s isn't a Raydium CP-Swap pool, the account belongs to %s", poolPubK, connectedCluster, owner)
+	}
+	pool, err := raydium_cp_swap.ParseAccount_PoolState(accountInfo.Value.Data.GetBinary())
+	if err != nil {
+		return nil, fmt.Errorf("parsing PoolState failed, make sure the pool address you passed is a Raydium CP-Swap/CPMM pool: %w", err)
+	}
+	return pool, nil
+}
+
+// fetchAmmConfig fetches and decodes the AmmConfig account a pool points at.
+func fetchAmmConfig(ctx context.Context, client *rpc.Client, ammConfig solana.PublicKey) (*raydium_cp_swap.AmmConfig, error) {
+	poolAmm, err := client.GetAccountInfoWithOpts(ctx, ammConfig, &rpc.GetAccountInfoOpts{Encoding: solana.EncodingBase64})
+	if err != nil {
+		return nil, fmt.Errorf("rpc call getAccountInfo for Pool's AmmConfig failed, check if the RPC endpoint is valid, or if you're being limited: %w", classifyRPC(err))
+	}
+	if poolAmm == nil || poolAmm.Value == nil {
+		return nil, classify(ErrAccountMissing, fmt.Errorf("amm config account %s returned no data", ammConfig))
+	}
+	poolAmmConfig, err := raydium_cp_swap.ParseAccount_AmmConfig(poolAmm.Value.Data.GetBinary())
+	if err != nil {
+		// NOTE(@hadydotai): Just occurred to me, if the pool is inactive, are we going to end up here?
+		return nil, fmt.Errorf("parsing pool's AmmConfig failed: %w", err)
+	}
+	return poolAmmConfig, nil
+}
+
+// loadedPool is everything we pull from the chain before we can quote against a pool.
+type loadedPool struct {
+	address    solana.PublicKey
+	pool       *raydium_cp_swap.PoolState
+	ammConfig  *raydium_cp_swap.AmmConfig
+	symbolsMap SymbolMapping
+	mints      [2]*mintAccount // token0 and token1 mints, nil when they couldn't be read
+}
+
+func loadPool(ctx context.Context, client *rpc.Client, poolPubK solana.PublicKey) (*loadedPool, error) {
+	quoteCtx, cancel := deadlines.forQuote(ctx)
+	defer cancel()
+	pool, err := fetchPoolState(quoteCtx, client, poolPubK)
+	if err != nil {
+		return nil, err
+	}
+	ammConfig, err := fetchAmmConfig(quoteCtx, client, pool.AmmConfig)
+	if err != nil {
+		return nil, err
+	}
+	lp := &loadedPool{address: poolPubK, pool: pool, ammConfig: ammConfig}
+	// Without the mints we still quote, the balances' decimals just go unchecked.
+	if mints, err := fetchMintAccounts(quoteCtx, client, pool.Token0Mint, pool.Token1Mint); err != nil {
+		log.Printf("warning: %v", err)
+	} else {
+		lp.mints = [2]*mintAccount{mints[0], mints[1]}
+	}
+	lp.symbolsMap = makeSymbolMapping(ctx, client, []solana.PublicKey{pool.Token0Mint, pool.Token1Mint})
+	userAliases.apply(lp.symbolsMap, pool.Token0Mint, pool.Token1Mint)
+	return lp, nil
+}
+
+// findPoolsByMints scans the program for CP-Swap pools trading the given pair, in either token order. Results are
+// sorted by address so repeated lookups pick the same pool. The pool index (pool_index.go) answers instead when it
+// knows the pair.
+func findPoolsByMints(ctx context.Context, client *rpc.Client, mintA, mintB solana.PublicKey) ([]solana.PublicKey, error) {
+	if indexed := pairIndex.lookup(mintA, mintB); len(indexed) > 0 {
+		return indexed, nil
+	}
+	if raydiumAPI != nil {
+		listed, err := raydiumAPI.cpPoolsByMints(ctx, mintA, mintB)
+		switch {
+		case err != nil:
+			log.Printf("warning: %v, scanning the program for the pair instead", err)
+		case len(listed) > 0:
+			sort.Slice(listed, func(i, j int) bool { return bytes.Compare(listed[i][:], listed[j][:]) < 0 })
+			return listed, nil
+		}
+	}
+	var found []solana.PublicKey
+	orders := [][2]solana.PublicKey{{mintA, mintB}, {mintB, mintA}}
+	for _, order := range orders {
+		accounts, err := client.GetProgramAccountsWithOpts(ctx, raydium_cp_swap.ProgramID, &rpc.GetProgramAccountsOpts{
+			Encoding: solana.EncodingBase64,
+			// NOTE(@hadydotai): We don't want the data, only the addresses, so we ask for a zero length slice.
+			DataSlice: &rpc.DataSlice{Offset: ptrTo(uint64(0)), Length: ptrTo(uint64(0))},
+			Filters: []rpc.RPCFilter{
+				{Memcmp: &rpc.RPCFilterMemcmp{Offset: 0, Bytes: raydium_cp_swap.Account_PoolState[:]}},
+				{Memcmp: &rpc.RPCFilterMemcmp{Offset: poolStateToken0MintOffset, Bytes: order[0].Bytes()}},
+				{Memcmp: &rpc.RPCFilterMemcmp{Offset: poolStateToken1MintOffset, Bytes: order[1].Bytes()}},
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("rpc call getProgramAccounts failed: %w", err)
+		}
+		for _, acc := range accounts {
+			found = append(found, acc.Pubkey)
+		}
+		if mintA.Equals(mintB) {
+			break
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return bytes.Compare(found[i][:], found[j][:]) < 0 })
+	return found, nil
+}
+
+// parsePoolTarget splits a user supplied pool target into either a pool address, or a pair of tokens separated by
+// a slash (e.g. "SOL/USDC"). Each side of a pair can be a symbol or a mint address.
+func parsePoolTarget(target string) (pool string, pair [2]string, isPair bool, err error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return "", pair, false, errors.New("pool target cannot be empty")
+	}
+	if !strings.Contains(target, "/") {
+		return target, pair, false, nil
+	}
+	parts := strings.Split(target, "/")
+	if len(parts) != 2 {
+		return "", pair, false, fmt.Errorf("pair %q must look like <token>/<token>", target)
+	}
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return "", pair, false, fmt.Errorf("pair %q is missing a token", target)
+		}
+		pair[i] = part
+	}
+	return "", pair, true, nil
+}
+
+// resolvePairToken turns one side of a pair into a mint, refusing mints -allow-mints or -deny-mints rule out.
+func resolvePairToken(token string, symm SymbolMapping) (solana.PublicKey, error) {
+	mint, err := lookupPairToken(token, symm)
+	if err != nil {
+		return solana.PublicKey{}, err
+	}
+	if err := tradeMints.check(token, mint); err != nil {
+		return solana.PublicKey{}, err
+	}
+	return mint, nil
+}
+
+// lookupPairToken is the mint for one side of a pair. Raw mint addresses are taken as is, symbols are looked up in the
+// symbol mapping we already know about, then in the user's aliases (SOL always maps to wrapped SOL).
+func lookupPairToken(token string, symm SymbolMapping) (solana.PublicKey, error) {
+	if pk, err := solana.PublicKeyFromBase58(token); err == nil {
+		return pk, nil
+	}
+	sym := normalizeSymbol(token)
+	if mint, ok := symm.MaybeMintFromSym(sym); ok {
+		return mint, nil
+	}
+	if mint := userAliases.mint(sym); mint != "" {
+		return solana.PublicKeyFromBase58(mint)
+	}
+	if sym == "SOL" || sym == "WSOL" {
+		return wSOLMint, nil
+	}
+	return solana.PublicKey{}, fmt.Errorf("can't resolve %s to a mint, use the mint address instead", token)
+}
+
+var errNoPoolForPair = errors.New("no CP-Swap pool found")
+
+// resolvePoolTarget takes whatever the user typed (address or pair) and settles on a single pool address.
+func resolvePoolTarget(ctx context.Context, client *rpc.Client, target string, symm SymbolMapping) (solana.PublicKey, error) {
+	poolAddr, pair, isPair, err := parsePoolTarget(target)
+	if err != nil {
+		return solana.PublicKey{}, err
+	}
+	if !isPair {
+		pk, err := solana.PublicKeyFromBase58(poolAddr)
+		if err != nil {
+			return solana.PublicKey{}, fmt.Errorf("deriving public key from pool address (base58) failed, make sure it's base58 encoded: %w", err)
+		}
+		return pk, nil
+	}
+	mintA, err := resolvePairToken(pair[0], symm)
+	if err != nil {
+		return solana.PublicKey{}, err
+	}
+	mintB, err := resolvePairToken(pair[1], symm)
+	if err != nil {
+		return solana.PublicKey{}, err
+	}
+	pools, err := findPoolsByMints(ctx, client, mintA, mintB)
+	if err != nil {
+		return solana.PublicKey{}, err
+	}
+	if len(pools) == 0 {
+		return solana.PublicKey{}, fmt.Errorf("%w for %s/%s", errNoPoolForPair, pair[0], pair[1])
+	}
+	return pools[0], nil
+}
+
+func ptrTo[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+func TestParsePoolTarget(t *testing.T) {
+	addr := solana.NewWallet().PublicKey().String()
+	pool, _, isPair, err := parsePoolTarget("  " + addr + " ")
+	if err != nil || isPair || pool != addr {
+		t.Fatalf("unexpected address parse: pool=%q isPair=%v err=%v", pool, isPair, err)
+	}
+	_, pair, isPair, err := parsePoolTarget("sol / USDC")
+	if err != nil || !isPair {
+		t.Fatalf("expected pair, got isPair=%v err=%v", isPair, err)
+	}
+	if pair[0] != "sol" || pair[1] != "USDC" {
+		t.Fatalf("unexpected pair %v", pair)
+	}
+	for _, bad := range []string{"", "SOL/", "/USDC", "A/B/C"} {
+		if _, _, _, err := parsePoolTarget(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestResolvePairToken(t *testing.T) {
+	mint := solana.NewWallet().PublicKey()
+	symm := SymbolMapping{
+		mintToSymbol: map[string]string{mint.String(): "RAY"},
+		symbolToMint: map[string]solana.PublicKey{"RAY": mint},
+		unresolved:   map[string]struct{}{},
+	}
+	if got, err := resolvePairToken("ray", symm); err != nil || !got.Equals(mint) {
+		t.Fatalf("symbol lookup failed: %s %v", got, err)
+	}
+	if got, err := resolvePairToken("sol", symm); err != nil || !got.Equals(wSOLMint) {
+		t.Fatalf("SOL should resolve to wSOL: %s %v", got, err)
+	}
+	if got, err := resolvePairToken(mint.String(), symm); err != nil || !got.Equals(mint) {
+		t.Fatalf("raw mint lookup failed: %s %v", got, err)
+	}
+	if _, err := resolvePairToken("NOPE", symm); err == nil {
+		t.Fatalf("expected unknown symbol to fail")
+	}
+}
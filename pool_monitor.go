@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// PoolState.Status is a bit field, a set bit disables the operation.
+// https://github.com/raydium-io/raydium-cp-swap/blob/master/programs/cp-swap/src/states/pool.rs
+const (
+	poolStatusDisableDeposit  uint8 = 1 << 0
+	poolStatusDisableWithdraw uint8 = 1 << 1
+	poolStatusDisableSwap     uint8 = 1 << 2
+)
+
+type alertSeverity string
+
+const (
+	severityInfo     alertSeverity = "info"
+	severityWarning  alertSeverity = "warning"
+	severityCritical alertSeverity = "critical"
+)
+
+func describePoolStatus(status uint8) string {
+	var disabled []string
+	if status&poolStatusDisableDeposit != 0 {
+		disabled = append(disabled, "deposit")
+	}
+	if status&poolStatusDisableWithdraw != 0 {
+		disabled = append(disabled, "withdraw")
+	}
+	if status&poolStatusDisableSwap != 0 {
+		disabled = append(disabled, "swap")
+	}
+	if len(disabled) == 0 {
+		return "normal"
+	}
+	return strings.Join(disabled, ", ") + " disabled"
+}
+
+// poolParams is one observation of the pool settings that matter to anyone trading against the pool.
+type poolParams struct {
+	pool      *raydium_cp_swap.PoolState
+	ammConfig *raydium_cp_swap.AmmConfig
+}
+
+type poolParamChange struct {
+	Field    string
+	From     string
+	To       string
+	Severity alertSeverity
+	Message  string
+}
+
+func fetchPoolParams(ctx context.Context, client *rpc.Client, poolPubK solana.PublicKey) (poolParams, error) {
+	pool, err := fetchPoolState(ctx, client, poolPubK)
+	if err != nil {
+		return poolParams{}, err
+	}
+	ammConfig, err := fetchAmmConfig(ctx, client, pool.AmmConfig)
+	if err != nil {
+		return poolParams{}, err
+	}
+	return poolParams{pool: pool, ammConfig: ammConfig}, nil
+}
+
+// diffPoolParams reports every change between two observations that can affect an open position or strategy on the
+// pool. Fee counters going down is how we spot the admin collecting fees, the program never decrements them otherwise.
+func diffPoolParams(prev, next poolParams) []poolParamChange {
+	var changes []poolParamChange
+	add := func(field string, from, to any, severity alertSeverity, msg string) {
+		changes = append(changes, poolParamChange{
+			Field:    field,
+			From:     fmt.Sprint(from),
+			To:       fmt.Sprint(to),
+			Severity: severity,
+			Message:  msg,
+		})
+	}
+	feeChange := func(field string, from, to uint64) {
+		if from == to {
+			return
+		}
+		add(field, formatFeeRate(from), formatFeeRate(to), severityWarning,
+			fmt.Sprintf("%s changed from %s to %s", field, formatFeeRate(from), formatFeeRate(to)))
+	}
+
+	pp, np := prev.pool, next.pool
+	if pp.Status != np.Status {
+		severity := severityWarning
+		if np.Status&poolStatusDisableSwap != 0 && pp.Status&poolStatusDisableSwap == 0 {
+			severity = severityCritical
+		}
+		add("status", describePoolStatus(pp.Status), describePoolStatus(np.Status), severity,
+			fmt.Sprintf("pool status changed from %q to %q", describePoolStatus(pp.Status), describePoolStatus(np.Status)))
+	}
+	if !pp.AmmConfig.Equals(np.AmmConfig) {
+		add("amm config", pp.AmmConfig, np.AmmConfig, severityCritical,
+			fmt.Sprintf("pool moved from AmmConfig %s to %s", Addr(pp.AmmConfig.String()), Addr(np.AmmConfig.String())))
+	}
+	if pp.OpenTime != np.OpenTime {
+		add("open time", pp.OpenTime, np.OpenTime, severityWarning,
+			fmt.Sprintf("pool open time changed from %s to %s", unixString(pp.OpenTime), unixString(np.OpenTime)))
+	}
+	if pp.EnableCreatorFee != np.EnableCreatorFee || pp.CreatorFeeOn != np.CreatorFeeOn {
+		add("creator fee mode", fmt.Sprintf("enabled=%v on=%d", pp.EnableCreatorFee, pp.CreatorFeeOn),
+			fmt.Sprintf("enabled=%v on=%d", np.EnableCreatorFee, np.CreatorFeeOn), severityWarning, "pool creator fee mode changed")
+	}
+	collected := func(field string, from, to uint64) {
+		if to < from {
+			add(field, from, to, severityInfo, fmt.Sprintf("%s collected by the pool authority (%d -> %d)", field, from, to))
+		}
+	}
+	collected("protocol fees token0", pp.ProtocolFeesToken0, np.ProtocolFeesToken0)
+	collected("protocol fees token1", pp.ProtocolFeesToken1, np.ProtocolFeesToken1)
+	collected("fund fees token0", pp.FundFeesToken0, np.FundFeesToken0)
+	collected("fund fees token1", pp.FundFeesToken1, np.FundFeesToken1)
+	collected("creator fees token0", pp.CreatorFeesToken0, np.CreatorFeesToken0)
+	collected("creator fees token1", pp.CreatorFeesToken1, np.CreatorFeesToken1)
+
+	pc, nc := prev.ammConfig, next.ammConfig
+	feeChange("trade fee", pc.TradeFeeRate, nc.TradeFeeRate)
+	feeChange("protocol fee", pc.ProtocolFeeRate, nc.ProtocolFeeRate)
+	feeChange("fund fee", pc.FundFeeRate, nc.FundFeeRate)
+	feeChange("creator fee", pc.CreatorFeeRate, nc.CreatorFeeRate)
+	if pc.CreatePoolFee != nc.CreatePoolFee {
+		add("create pool fee", pc.CreatePoolFee, nc.CreatePoolFee, severityInfo,
+			fmt.Sprintf("create pool fee changed from %s to %s", formatLamports(pc.CreatePoolFee), formatLamports(nc.CreatePoolFee)))
+	}
+	if pc.DisableCreatePool != nc.DisableCreatePool {
+		add("disable create pool", pc.DisableCreatePool, nc.DisableCreatePool, severityInfo, "AmmConfig pool creation toggle changed")
+	}
+	if !pc.ProtocolOwner.Equals(nc.ProtocolOwner) {
+		add("protocol owner", pc.ProtocolOwner, nc.ProtocolOwner, severityWarning,
+			fmt.Sprintf("protocol fee owner changed from %s to %s", Addr(pc.ProtocolOwner.String()), Addr(nc.ProtocolOwner.String())))
+	}
+	if !pc.FundOwner.Equals(nc.FundOwner) {
+		add("fund owner", pc.FundOwner, nc.FundOwner, severityWarning,
+			fmt.Sprintf("fund fee owner changed from %s to %s", Addr(pc.FundOwner.String()), Addr(nc.FundOwner.String())))
+	}
+	return changes
+}
+
+func unixString(ts uint64) string {
+	return time.Unix(int64(ts), 0).UTC().Format(time.RFC3339)
+}
+
+// monitorPool polls the pool every interval and hands every detected change to alert, until ctx is done.
+func monitorPool(ctx context.Context, client *rpc.Client, poolPubK solana.PublicKey, interval time.Duration, alert func(poolParamChange)) error {
+	prev, err := fetchPoolParams(ctx, client, poolPubK)
+	if err != nil {
+		return err
+	}
+	log.Printf("monitoring pool %s: status %s, trade fee %s, protocol fee %s, fund fee %s, creator fee %s",
+		Addr(poolPubK.String()), describePoolStatus(prev.pool.Status),
+		formatFeeRate(prev.ammConfig.TradeFeeRate), formatFeeRate(prev.ammConfig.ProtocolFeeRate),
+		formatFeeRate(prev.ammConfig.FundFeeRate), formatFeeRate(prev.ammConfig.CreatorFeeRate))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			next, err := fetchPoolParams(ctx, client, poolPubK)
+			if err != nil {
+				if errors.Is(err, context.Canceled) {
+					return nil
+				}
+				// NOTE(@hadydotai): A flaky RPC shouldn't kill a daemon, we'll just try again on the next tick.
+				log.Printf("warning: refreshing pool parameters failed: %v", err)
+				continue
+			}
+			for _, change := range diffPoolParams(prev, next) {
+				alert(change)
+			}
+			prev = next
+		}
+	}
+}
+
+func runMonitorCommand(args []string) error {
+	return dispatchSubcommand("monitor", map[string]func([]string) error{
+		"pool":      runMonitorPoolCommand,
+		"wallet":    runMonitorWalletCommand,
+		"new-pools": runMonitorNewPoolsCommand,
+	}, args)
+}
+
+func runMonitorPoolCommand(args []string) error {
+	fs := flag.NewFlagSet("monitor pool", flag.ExitOnError)
+	nf := addNetworkFlags(fs)
+	poolAddr := fs.String("pool", "", "Pool to monitor")
+	interval := fs.Duration("interval", 30*time.Second, "How often to re-read the pool and its AmmConfig")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, append(nf.specs(), FlagSpec{Name: "pool", Value: poolAddr, Rules: []FlagRule{NotEmpty()}}))
+	if *interval <= 0 {
+		return errors.New("interval must be greater than zero")
+	}
+	poolPubK, err := solana.PublicKeyFromBase58(*poolAddr)
+	if err != nil {
+		return fmt.Errorf("deriving public key from pool address (base58) failed, make sure it's base58 encoded: %w", err)
+	}
+	client := nf.connect()
+	ctx, stop := interruptContext()
+	defer stop()
+	return monitorPool(ctx, client, poolPubK, *interval, func(change poolParamChange) {
+		log.Printf("ALERT [%s] %s: %s", strings.ToUpper(string(change.Severity)), Addr(poolPubK.String()), change.Message)
+	})
+}
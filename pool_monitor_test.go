@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+func newTestPoolParams() poolParams {
+	pool, _ := newTestPoolState()
+	pool.ProtocolFeesToken0 = 500
+	pool.FundFeesToken1 = 700
+	return poolParams{
+		pool: pool,
+		ammConfig: &raydium_cp_swap.AmmConfig{
+			TradeFeeRate:    2500,
+			ProtocolFeeRate: 120000,
+			FundFeeRate:     40000,
+			ProtocolOwner:   solana.NewWallet().PublicKey(),
+			FundOwner:       solana.NewWallet().PublicKey(),
+		},
+	}
+}
+
+// cloneParams copies an observation so the next one can be mutated independently.
+func cloneParams(p poolParams) poolParams {
+	pool, cfg := *p.pool, *p.ammConfig
+	return poolParams{pool: &pool, ammConfig: &cfg}
+}
+
+func TestDiffPoolParamsNoChanges(t *testing.T) {
+	prev := newTestPoolParams()
+	if changes := diffPoolParams(prev, cloneParams(prev)); len(changes) != 0 {
+		t.Fatalf("expected no changes, got %+v", changes)
+	}
+}
+
+func TestDiffPoolParamsDetectsChanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		mutate   func(p *poolParams)
+		field    string
+		severity alertSeverity
+	}{
+		{"swap paused", func(p *poolParams) { p.pool.Status = poolStatusDisableSwap }, "status", severityCritical},
+		{"deposit paused", func(p *poolParams) { p.pool.Status = poolStatusDisableDeposit }, "status", severityWarning},
+		{"trade fee", func(p *poolParams) { p.ammConfig.TradeFeeRate = 10000 }, "trade fee", severityWarning},
+		{"fund fee", func(p *poolParams) { p.ammConfig.FundFeeRate = 0 }, "fund fee", severityWarning},
+		{"amm config", func(p *poolParams) { p.pool.AmmConfig = solana.NewWallet().PublicKey() }, "amm config", severityCritical},
+		{"protocol owner", func(p *poolParams) { p.ammConfig.ProtocolOwner = solana.NewWallet().PublicKey() }, "protocol owner", severityWarning},
+		{"fees collected", func(p *poolParams) { p.pool.ProtocolFeesToken0 = 0 }, "protocol fees token0", severityInfo},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			prev := newTestPoolParams()
+			next := cloneParams(prev)
+			tc.mutate(&next)
+			changes := diffPoolParams(prev, next)
+			if len(changes) != 1 {
+				t.Fatalf("expected exactly one change, got %+v", changes)
+			}
+			if changes[0].Field != tc.field || changes[0].Severity != tc.severity {
+				t.Fatalf("expected %s/%s, got %s/%s", tc.field, tc.severity, changes[0].Field, changes[0].Severity)
+			}
+		})
+	}
+}
+
+func TestDiffPoolParamsIgnoresAccruingFees(t *testing.T) {
+	prev := newTestPoolParams()
+	next := cloneParams(prev)
+	next.pool.ProtocolFeesToken0 += 100
+	next.pool.FundFeesToken1 += 100
+	if changes := diffPoolParams(prev, next); len(changes) != 0 {
+		t.Fatalf("fees accruing from swaps shouldn't alert, got %+v", changes)
+	}
+}
+
+func TestDescribePoolStatus(t *testing.T) {
+	if got := describePoolStatus(0); got != "normal" {
+		t.Fatalf("got %q", got)
+	}
+	if got := describePoolStatus(poolStatusDisableDeposit | poolStatusDisableSwap); got != "deposit, swap disabled" {
+		t.Fatalf("got %q", got)
+	}
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+/*
+NOTE(@hadydotai): Pool stats.
+
+`pool stats <pool>` is what the pool did lately: how many swaps, how much went through it, what it earned in fees and
+where its price went. There's no indexer behind this, it's read straight off the chain: the pool's recent signatures
+(getSignaturesForAddress, newest first, a page of up to 1000 at a time) back to the start of the window, and every
+successful transaction among them fetched for its logs.
+
+The swaps come out of those logs as cp-swap's SwapEvents (see swap_events.go), the same events `vectors` and the swap
+summary read, filtered to the ones whose pool is this one. Unlike the swap summary this keeps events from any depth,
+most of a busy pool's volume comes through routers calling into it, and that's volume all the same. Each event says
+which mint went in, so every swap adds to both tokens' volume, what went in on one side and what came out on the
+other, and its trade fee adds to the fees of the token that went in. The trade fee is all of it, what LPs keep and
+the protocol and fund shares, the creator fee isn't in it. Events from before cp-swap started logging the mints can't
+say which way the swap went and are left out, with a count.
+
+The price after each swap is the reserves' ratio once the swap moved them, the reserves before (the event has them)
+plus what went in, less what came out, token1 per token0. The sparkline is that series cut into -buckets slices of
+the window, each the last price in it, a slice without a swap carrying the one before.
+
+A busy pool can have more transactions in a day than anyone wants fetched. -max-txs caps how many signatures are read,
+when the cap hits before the window's start the stats say how far back they actually go.
+*/
+
+const (
+	poolStatsConcurrency = 4
+	poolStatsPageSize    = 1000
+)
+
+// sparkTicks are a sparkline's levels, lowest first.
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// poolSwap is one swap on the pool, as its event has it.
+type poolSwap struct {
+	at    time.Time
+	event raydium_cp_swap.SwapEvent
+}
+
+// pricePoint is the pool's price, token1 per token0, right after a swap.
+type pricePoint struct {
+	at    time.Time
+	price *big.Rat
+}
+
+// poolStats is what a pool did over a window.
+type poolStats struct {
+	pool      solana.PublicKey
+	mints     [2]solana.PublicKey
+	decimals  [2]uint8
+	symbols   [2]string
+	from, to  time.Time
+	scanned   time.Time // how far back the signatures went, from unless -max-txs cut them short
+	swaps     int
+	undecoded int // events that don't say which way they went
+	unread    int // transactions that couldn't be fetched
+	volume    [2]*big.Int
+	fees      [2]*big.Int
+	series    []pricePoint
+	api       *raydiumPoolInfo // what Raydium's API says of the pool, nil without -raydium-api
+}
+
+// addSwaps tallies swaps, in any order, into the stats. Swaps outside the window are left out.
+func (ps *poolStats) addSwaps(swaps []poolSwap) {
+	for i := range ps.volume {
+		if ps.volume[i] == nil {
+			ps.volume[i], ps.fees[i] = new(big.Int), new(big.Int)
+		}
+	}
+	sort.SliceStable(swaps, func(i, j int) bool { return swaps[i].at.Before(swaps[j].at) })
+	for _, sw := range swaps {
+		if sw.at.Before(ps.from) || sw.at.After(ps.to) {
+			continue
+		}
+		ev := sw.event
+		in := -1
+		for i, mint := range ps.mints {
+			if ev.InputMint.Equals(mint) {
+				in = i
+			}
+		}
+		if in < 0 {
+			ps.undecoded++
+			continue
+		}
+		out := 1 - in
+		ps.swaps++
+		ps.volume[in].Add(ps.volume[in], new(big.Int).SetUint64(ev.InputAmount))
+		ps.volume[out].Add(ps.volume[out], new(big.Int).SetUint64(ev.OutputAmount))
+		ps.fees[in].Add(ps.fees[in], new(big.Int).SetUint64(ev.TradeFee))
+
+		var reserves [2]*big.Int
+		reserves[in] = new(big.Int).Add(new(big.Int).SetUint64(ev.InputVaultBefore), new(big.Int).SetUint64(ev.InputAmount))
+		reserves[out] = new(big.Int).Sub(new(big.Int).SetUint64(ev.OutputVaultBefore), new(big.Int).SetUint64(ev.OutputAmount))
+		if reserves[0].Sign() <= 0 || reserves[1].Sign() <= 0 {
+			continue
+		}
+		price := new(big.Rat).SetFrac(reserves[1], reserves[0])
+		price.Mul(price, new(big.Rat).SetFrac(fixedPointScale(ps.decimals[0]), fixedPointScale(ps.decimals[1])))
+		ps.series = append(ps.series, pricePoint{at: sw.at, price: price})
+	}
+}
+
+// buckets cuts the price series into n slices of the window, each the last price in it or in the slices before, nil
+// before the first swap.
+func (ps *poolStats) buckets(n int) []*big.Rat {
+	out := make([]*big.Rat, n)
+	span := ps.to.Sub(ps.from)
+	if n <= 0 || span <= 0 {
+		return out
+	}
+	for _, pt := range ps.series {
+		i := int(int64(pt.at.Sub(ps.from)) * int64(n) / int64(span))
+		out[min(max(i, 0), n-1)] = pt.price
+	}
+	for i := 1; i < n; i++ {
+		if out[i] == nil {
+			out[i] = out[i-1]
+		}
+	}
+	return out
+}
+
+// priceRange is the series' first, last, lowest and highest prices, nil without swaps.
+func (ps *poolStats) priceRange() (open, last, low, high *big.Rat) {
+	for _, pt := range ps.series {
+		if open == nil {
+			open, low, high = pt.price, pt.price, pt.price
+		}
+		last = pt.price
+		if pt.price.Cmp(low) < 0 {
+			low = pt.price
+		}
+		if pt.price.Cmp(high) > 0 {
+			high = pt.price
+		}
+	}
+	return open, last, low, high
+}
+
+func (ps *poolStats) priceString(price *big.Rat) string {
+	if price == nil {
+		return ""
+	}
+	return trimDecimal(price.FloatString(int(ps.decimals[0]) + int(ps.decimals[1])))
+}
+
+func (ps *poolStats) priceUnit() string {
+	return ps.symbols[1] + " per " + ps.symbols[0]
+}
+
+// sparkline draws values on sparkTicks, scaled between their lowest and highest, a nil value is a blank.
+func sparkline(values []*big.Rat) string {
+	var low, high *big.Rat
+	for _, v := range values {
+		if v == nil {
+			continue
+		}
+		if low == nil || v.Cmp(low) < 0 {
+			low = v
+		}
+		if high == nil || v.Cmp(high) > 0 {
+			high = v
+		}
+	}
+	b := &strings.Builder{}
+	for _, v := range values {
+		switch {
+		case v == nil:
+			b.WriteRune(' ')
+		case low.Cmp(high) == 0:
+			b.WriteRune(sparkTicks[len(sparkTicks)/2])
+		default:
+			level := new(big.Rat).Sub(v, low)
+			level.Quo(level, new(big.Rat).Sub(high, low))
+			level.Mul(level, big.NewRat(int64(len(sparkTicks)-1), 1))
+			i := new(big.Int).Quo(level.Num(), level.Denom()).Int64()
+			b.WriteRune(sparkTicks[i])
+		}
+	}
+	return b.String()
+}
+
+// recentPoolSwaps reads the pool's swaps back to since, fetching at most maxTxs signatures. scanned is the time of the
+// oldest signature read, it's after since when maxTxs ran out first. A transaction that can't be fetched is counted in
+// unread rather than failing the lot, on a busy pool the RPC's rate limit is bound to bite somewhere.
+func recentPoolSwaps(ctx context.Context, client *rpc.Client, pool solana.PublicKey, since time.Time, maxTxs int) (swaps []poolSwap, scanned time.Time, unread int, err error) {
+	var (
+		sigs   []*rpc.TransactionSignature
+		before solana.Signature
+	)
+	scanned = time.Now()
+	for len(sigs) < maxTxs {
+		page, err := client.GetSignaturesForAddressWithOpts(ctx, pool, &rpc.GetSignaturesForAddressOpts{
+			Limit:      ptrTo(min(poolStatsPageSize, maxTxs-len(sigs))),
+			Before:     before,
+			Commitment: rpc.CommitmentConfirmed,
+		})
+		if err != nil {
+			return nil, scanned, 0, fmt.Errorf("rpc call getSignaturesForAddress failed: %w", err)
+		}
+		reachedSince := false
+		for _, sig := range page {
+			if len(sigs) == maxTxs {
+				break
+			}
+			if sig == nil || sig.BlockTime == nil {
+				continue
+			}
+			at := sig.BlockTime.Time()
+			if at.Before(since) {
+				reachedSince = true
+				break
+			}
+			scanned = at
+			sigs = append(sigs, sig)
+		}
+		if reachedSince || len(page) == 0 {
+			scanned = since
+			break
+		}
+		before = page[len(page)-1].Signature
+	}
+
+	perTx := make([][]poolSwap, len(sigs))
+	errs := make([]error, len(sigs))
+	sem := make(chan struct{}, poolStatsConcurrency)
+	wg := sync.WaitGroup{}
+	for i, sig := range sigs {
+		if sig.Err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			perTx[i], errs[i] = poolSwapsOf(ctx, client, pool, sig.Signature, sig.BlockTime.Time())
+		}()
+	}
+	wg.Wait()
+	var firstErr error
+	for i := range sigs {
+		if errs[i] != nil {
+			unread++
+			firstErr = cmp.Or(firstErr, errs[i])
+			continue
+		}
+		swaps = append(swaps, perTx[i]...)
+	}
+	if unread > 0 {
+		log.Printf("warning: %d of the pool's transactions couldn't be read, the first because %v", unread, firstErr)
+	}
+	return swaps, scanned, unread, nil
+}
+
+// poolSwapsOf is every swap on pool in the transaction, however deep the pool was called.
+func poolSwapsOf(ctx context.Context, client *rpc.Client, pool solana.PublicKey, sig solana.Signature, at time.Time) ([]poolSwap, error) {
+	maxVersion := uint64(0)
+	tx, err := client.GetTransaction(ctx, sig, &rpc.GetTransactionOpts{
+		Encoding:                       solana.EncodingBase64,
+		Commitment:                     rpc.CommitmentConfirmed,
+		MaxSupportedTransactionVersion: &maxVersion,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("rpc call getTransaction for %s failed: %w", sig, err)
+	}
+	if tx == nil || tx.Meta == nil || tx.Meta.Err != nil {
+		return nil, nil
+	}
+	var swaps []poolSwap
+	for _, le := range loggedSwapEvents(tx.Meta.LogMessages, raydium_cp_swap.ProgramID) {
+		if le.event.PoolId.Equals(pool) {
+			swaps = append(swaps, poolSwap{at: at, event: le.event})
+		}
+	}
+	return swaps, nil
+}
+
+func (ps *poolStats) render(buckets int) string {
+	t := table.NewWriter()
+	t.SetTitle(fmt.Sprintf("Pool %s, %s/%s", Addr(ps.pool.String()), ps.symbols[0], ps.symbols[1]))
+	window := fmt.Sprintf("%s to %s", ps.from.Format(time.RFC3339), ps.to.Format(time.RFC3339))
+	if ps.scanned.After(ps.from) {
+		window += fmt.Sprintf(" (only back to %s, raise -max-txs)", ps.scanned.Format(time.RFC3339))
+	}
+	t.AppendRow(table.Row{"Window", window})
+	swaps := fmt.Sprint(ps.swaps)
+	if ps.undecoded > 0 {
+		swaps += fmt.Sprintf(" (%d more from before cp-swap logged the mints, not counted)", ps.undecoded)
+	}
+	if ps.unread > 0 {
+		swaps += fmt.Sprintf(" (%d transactions couldn't be read)", ps.unread)
+	}
+	t.AppendRow(table.Row{"Swaps", swaps})
+	for i := range ps.mints {
+		t.AppendRow(table.Row{"Volume " + ps.symbols[i], fmtAmount(ps.volume[i], ps.decimals[i])})
+	}
+	for i := range ps.mints {
+		t.AppendRow(table.Row{"Fees " + ps.symbols[i], fmtAmount(ps.fees[i], ps.decimals[i])})
+	}
+	if open, last, low, high := ps.priceRange(); open != nil {
+		change := new(big.Rat).Quo(new(big.Rat).Sub(last, open), open)
+		t.AppendRow(table.Row{"Price (" + ps.priceUnit() + ")", fmt.Sprintf("%s, %s over the window", ps.priceString(last), fmtSignedPct(change))})
+		t.AppendRow(table.Row{"Low / high", ps.priceString(low) + " / " + ps.priceString(high)})
+		t.AppendRow(table.Row{"Chart", sparkline(ps.buckets(buckets))})
+	}
+	if ps.api != nil {
+		t.AppendRow(table.Row{"Raydium API, 24h", ps.api.dayLine()})
+	}
+	return t.Render()
+}
+
+// fmtSignedPct renders a ratio as a percentage with its sign.
+func fmtSignedPct(r *big.Rat) string {
+	s := trimDecimal(new(big.Rat).Mul(r, big.NewRat(100, 1)).FloatString(2)) + "%"
+	if r.Sign() >= 0 {
+		s = "+" + s
+	}
+	return s
+}
+
+type poolStatsTokenJSON struct {
+	Mint   string     `json:"mint"`
+	Symbol string     `json:"symbol"`
+	Volume amountJSON `json:"volume"`
+	Fees   amountJSON `json:"fees"`
+}
+
+type pricePointJSON struct {
+	Time  time.Time `json:"time"`
+	Price string    `json:"price"`
+}
+
+type poolStatsJSON struct {
+	Pool      string                `json:"pool"`
+	From      time.Time             `json:"from"`
+	To        time.Time             `json:"to"`
+	Scanned   time.Time             `json:"scannedFrom"` // later than from when -max-txs cut the window short
+	Swaps     int                   `json:"swaps"`
+	Undecoded int                   `json:"undecoded,omitempty"`
+	Unread    int                   `json:"unread,omitempty"` // transactions that couldn't be fetched
+	Tokens    [2]poolStatsTokenJSON `json:"tokens"`
+	PriceUnit string                `json:"priceUnit"`
+	Series    []pricePointJSON      `json:"series"` // the price after every swap, oldest first
+	API       *raydiumPoolJSON      `json:"raydiumApi,omitempty"`
+}
+
+func (ps *poolStats) json() poolStatsJSON {
+	out := poolStatsJSON{
+		Pool:      ps.pool.String(),
+		From:      ps.from,
+		To:        ps.to,
+		Scanned:   ps.scanned,
+		Swaps:     ps.swaps,
+		Undecoded: ps.undecoded,
+		Unread:    ps.unread,
+		PriceUnit: ps.priceUnit(),
+		Series:    make([]pricePointJSON, 0, len(ps.series)),
+	}
+	for i := range ps.mints {
+		out.Tokens[i] = poolStatsTokenJSON{
+			Mint:   ps.mints[i].String(),
+			Symbol: ps.symbols[i],
+			Volume: newAmountJSON(ps.volume[i], ps.decimals[i]),
+			Fees:   newAmountJSON(ps.fees[i], ps.decimals[i]),
+		}
+	}
+	for _, pt := range ps.series {
+		out.Series = append(out.Series, pricePointJSON{Time: pt.at, Price: ps.priceString(pt.price)})
+	}
+	if ps.api != nil {
+		out.API = ps.api.json()
+	}
+	return out
+}
+
+// collectPoolStats reads what the loaded pool did over the last window, at most maxTxs transactions of it.
+func collectPoolStats(ctx context.Context, client *rpc.Client, lp *loadedPool, window time.Duration, maxTxs int) (*poolStats, error) {
+	if lp.mints[0] == nil || lp.mints[1] == nil {
+		return nil, fmt.Errorf("couldn't read the mints of pool %s", Addr(lp.address.String()))
+	}
+	now := time.Now()
+	ps := &poolStats{
+		pool:     lp.address,
+		mints:    [2]solana.PublicKey{lp.pool.Token0Mint, lp.pool.Token1Mint},
+		decimals: [2]uint8{lp.mints[0].Decimals, lp.mints[1].Decimals},
+		symbols:  [2]string{lp.symbolsMap.SymFrom(lp.pool.Token0Mint), lp.symbolsMap.SymFrom(lp.pool.Token1Mint)},
+		from:     now.Add(-window),
+		to:       now,
+	}
+	swaps, scanned, unread, err := recentPoolSwaps(ctx, client, lp.address, ps.from, maxTxs)
+	if err != nil {
+		return nil, err
+	}
+	ps.scanned, ps.unread = scanned, unread
+	ps.addSwaps(swaps)
+	ps.api = raydiumPoolInfoFor(ctx, lp.address)
+	return ps, nil
+}
+
+func runPoolCommand(args []string) error {
+	return dispatchSubcommand("pool", map[string]func([]string) error{
+		"apr":     runPoolAPRCommand,
+		"candles": runPoolCandlesCommand,
+		"depth":   runPoolDepthCommand,
+		"stats":   runPoolStatsCommand,
+	}, args)
+}
+
+func runPoolStatsCommand(args []string) error {
+	fs := flag.NewFlagSet("pool stats", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: pool stats [flags] <pool address or pair>\n")
+		fs.PrintDefaults()
+	}
+	nf := addNetworkFlags(fs)
+	var (
+		window  = fs.Duration("window", 24*time.Hour, "How far back to look")
+		maxTxs  = fs.Int("max-txs", 5000, "Most of the pool's transactions to read, the window is cut short when there are more")
+		buckets = fs.Int("buckets", 48, "Points on the price chart")
+		asJSON  = fs.Bool("json", false, "Print the stats as JSON, with the price after every swap, instead of a table")
+	)
+	addAmountFlags(fs)
+	// The pool reads naturally first, `pool stats <pool> -network mainnet`, flag stops at the first argument.
+	var target string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		target, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, nf.specs())
+	if target == "" && fs.NArg() > 0 {
+		target = fs.Arg(0)
+	}
+	if target == "" {
+		fs.Usage()
+		return errors.New("missing pool")
+	}
+	if *window <= 0 || *maxTxs <= 0 || *buckets <= 0 {
+		return errors.New("-window, -max-txs and -buckets must be greater than zero")
+	}
+	client := nf.connect()
+	ctx, stop := interruptContext()
+	defer stop()
+
+	quoteCtx, cancel := deadlines.forQuote(ctx)
+	poolAddr, err := resolvePoolTarget(quoteCtx, client, target, SymbolMapping{})
+	cancel()
+	if err != nil {
+		return err
+	}
+	lp, err := loadPool(ctx, client, poolAddr)
+	if err != nil {
+		return err
+	}
+	ps, err := collectPoolStats(ctx, client, lp, *window, *maxTxs)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(ps.json())
+	}
+	fmt.Println(ps.render(*buckets))
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func testPoolStats(from, to time.Time) *poolStats {
+	return &poolStats{
+		pool:     snapshotKey(50),
+		mints:    [2]solana.PublicKey{wSOLMint, snapshotKey(51)},
+		decimals: [2]uint8{9, 6},
+		symbols:  [2]string{"SOL", "USDC"},
+		from:     from,
+		to:       to,
+	}
+}
+
+func TestPoolStatsAddSwaps(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ps := testPoolStats(from, from.Add(4*time.Hour))
+	usdc := ps.mints[1]
+	ps.addSwaps([]poolSwap{
+		// USDC in, SOL out, listed out of order: reserves go to 100 SOL and 20000 USDC, 200 USDC per SOL.
+		{at: from.Add(3 * time.Hour), event: raydium_cp_swap.SwapEvent{InputMint: usdc, OutputMint: wSOLMint,
+			InputVaultBefore: 19_000_000_000, OutputVaultBefore: 105_000_000_000, InputAmount: 1_000_000_000, OutputAmount: 5_000_000_000, TradeFee: 2_500_000}},
+		// SOL in, USDC out: reserves go to 105 SOL and 19000 USDC.
+		{at: from.Add(time.Hour), event: raydium_cp_swap.SwapEvent{InputMint: wSOLMint, OutputMint: usdc,
+			InputVaultBefore: 100_000_000_000, OutputVaultBefore: 20_000_000_000, InputAmount: 5_000_000_000, OutputAmount: 1_000_000_000, TradeFee: 12_500_000}},
+		// Before cp-swap logged the mints.
+		{at: from.Add(2 * time.Hour), event: raydium_cp_swap.SwapEvent{InputAmount: 1, OutputAmount: 1}},
+		{at: from.Add(-time.Hour), event: raydium_cp_swap.SwapEvent{InputMint: wSOLMint, OutputMint: usdc, InputAmount: 1, OutputAmount: 1}},
+	})
+	if ps.swaps != 2 || ps.undecoded != 1 {
+		t.Fatalf("%d swaps, %d undecoded", ps.swaps, ps.undecoded)
+	}
+	if ps.volume[0].Cmp(big.NewInt(10_000_000_000)) != 0 || ps.volume[1].Cmp(big.NewInt(2_000_000_000)) != 0 {
+		t.Errorf("volume %s SOL, %s USDC", ps.volume[0], ps.volume[1])
+	}
+	if ps.fees[0].Cmp(big.NewInt(12_500_000)) != 0 || ps.fees[1].Cmp(big.NewInt(2_500_000)) != 0 {
+		t.Errorf("fees %s SOL, %s USDC", ps.fees[0], ps.fees[1])
+	}
+	open, last, low, high := ps.priceRange()
+	if ps.priceString(open) != "180.952380952380952" || ps.priceString(last) != "200" || low != open || high != last {
+		t.Errorf("prices open %s last %s low %s high %s", ps.priceString(open), ps.priceString(last), ps.priceString(low), ps.priceString(high))
+	}
+	buckets := ps.buckets(4)
+	if buckets[0] != nil || buckets[1] != open || buckets[2] != open || buckets[3] != last {
+		t.Errorf("buckets %v", buckets)
+	}
+	out := ps.json()
+	if len(out.Series) != 2 || out.Series[1].Price != "200" || out.Tokens[0].Volume.Display != "10.000000000" || out.PriceUnit != "USDC per SOL" {
+		t.Errorf("json %+v", out)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	if got := sparkline([]*big.Rat{nil, big.NewRat(1, 1), big.NewRat(8, 1), big.NewRat(4, 1)}); got != " ▁█▄" {
+		t.Errorf("sparkline %q", got)
+	}
+	if got := sparkline([]*big.Rat{big.NewRat(3, 1), big.NewRat(3, 1)}); got != "▅▅" {
+		t.Errorf("flat sparkline %q", got)
+	}
+}
+
+func TestRecentPoolSwaps(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	pool, other := snapshotKey(52), snapshotKey(53)
+	mine := raydium_cp_swap.SwapEvent{PoolId: pool, InputMint: wSOLMint, InputAmount: 7, OutputAmount: 3}
+	theirs := raydium_cp_swap.SwapEvent{PoolId: other, InputMint: wSOLMint, InputAmount: 1, OutputAmount: 1}
+	cpSwap := raydium_cp_swap.ProgramID.String()
+	sigs := []struct {
+		sig    solana.Signature
+		at     time.Time
+		failed bool
+	}{
+		{solana.Signature{1}, now.Add(-time.Minute), false},
+		{solana.Signature{2}, now.Add(-2 * time.Minute), true},
+		{solana.Signature{3}, now.Add(-3 * time.Minute), false},
+		{solana.Signature{4}, now.Add(-time.Hour), false},
+	}
+	var mu sync.Mutex
+	fetched := map[string]bool{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Params) == 0 {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		switch req.Method {
+		case "getSignaturesForAddress":
+			var page []string
+			for _, s := range sigs {
+				txErr := "null"
+				if s.failed {
+					txErr = `{"InstructionError":[0,{"Custom":6005}]}`
+				}
+				page = append(page, fmt.Sprintf(`{"signature":%q,"slot":1,"err":%s,"memo":null,"blockTime":%d}`, s.sig, txErr, s.at.Unix()))
+			}
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":[%s]}`, req.ID, strings.Join(page, ","))
+		case "getTransaction":
+			var sig string
+			json.Unmarshal(req.Params[0], &sig)
+			mu.Lock()
+			fetched[sig] = true
+			mu.Unlock()
+			logs, _ := json.Marshal([]string{
+				"Program " + cpSwap + " invoke [1]",
+				programDataLine(t, mine),
+				"Program " + cpSwap + " invoke [2]",
+				programDataLine(t, theirs),
+				"Program " + cpSwap + " success",
+				"Program " + cpSwap + " success",
+			})
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"slot":1,"meta":{"err":null,"fee":5000,"logMessages":%s},"transaction":null}}`, req.ID, logs)
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	swaps, scanned, unread, err := recentPoolSwaps(t.Context(), rpc.New(srv.URL), pool, now.Add(-10*time.Minute), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(swaps) != 2 || swaps[0].event.InputAmount != 7 || !swaps[0].at.Equal(now.Add(-time.Minute)) || unread != 0 {
+		t.Fatalf("swaps %+v, %d unread", swaps, unread)
+	}
+	if !scanned.Equal(now.Add(-10 * time.Minute)) {
+		t.Errorf("scanned back to %s, the whole window was read", scanned)
+	}
+	if fetched[solana.Signature{2}.String()] || fetched[solana.Signature{4}.String()] {
+		t.Errorf("fetched %v, the failed transaction and the one before the window shouldn't be", fetched)
+	}
+
+	// Capped before the window's start.
+	_, scanned, _, err = recentPoolSwaps(t.Context(), rpc.New(srv.URL), pool, now.Add(-10*time.Minute), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !scanned.Equal(now.Add(-2 * time.Minute)) {
+		t.Errorf("capped at 2 transactions, scanned back to %s", scanned)
+	}
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+/*
+NOTE(@hadydotai): Portfolio.
+
+`portfolio` is everything the wallet holds and what it's worth, priced by the pools we'd be swapping on anyway. The
+holdings are the wallet's token accounts under both token programs, summed per mint (a wallet can hold the same mint
+in more than its associated account), plus its SOL. Native SOL and wSOL are the same thing to a swap, the client
+wraps and unwraps as it goes, so they're one SOL row.
+
+Every token is priced in the network's USDC. A token with a CP-Swap pool against USDC is priced off that pool, one
+without but with a pool against wSOL is priced in SOL and then SOL in USDC, anything else is listed without a value.
+When several pools trade the pair the deepest one wins, the one holding the most of the quote token, the shallow
+ones are the easiest to push around. The price is the pool's mid price, its reserves' ratio. Selling the whole
+holding would get less than that, by the price impact a quote would show, this is what it's worth, not what it
+sells for.
+
+Symbols come from the same place the quote table's do (makeSymbolMapping: metadata, then the token list, then the
+aliases on top). Unpriced holdings are still listed, they just don't add to the total.
+*/
+
+// portfolioMintBatch is how many mints go in one getMultipleAccounts, the RPC's limit.
+const portfolioMintBatch = 100
+
+// holding is what the wallet holds of one mint, and what it's worth when a pool prices it.
+type holding struct {
+	mint     solana.PublicKey
+	amount   *big.Int
+	decimals uint8
+	accounts int // token accounts it's spread over, native SOL counts as none
+	symbol   string
+	source   string
+	price    *pairPrice
+}
+
+// value is the holding in raw quote token units, nil when it isn't priced.
+func (h *holding) value(quoteDecimals uint8) *big.Int {
+	if h.price == nil {
+		return nil
+	}
+	v := new(big.Rat).SetFrac(h.amount, fixedPointScale(h.decimals))
+	v.Mul(v, h.price.price)
+	v.Mul(v, new(big.Rat).SetInt(fixedPointScale(quoteDecimals)))
+	return new(big.Int).Quo(v.Num(), v.Denom())
+}
+
+// holdingsFromAccounts sums token account data (as getTokenAccountsByOwner returns it) and a native SOL balance per
+// mint, leaving out empty accounts.
+func holdingsFromAccounts(lamports uint64, accounts [][]byte) map[solana.PublicKey]*holding {
+	held := map[solana.PublicKey]*holding{}
+	add := func(mint solana.PublicKey, amount uint64, account int) {
+		h, ok := held[mint]
+		if !ok {
+			h = &holding{mint: mint, amount: new(big.Int)}
+			held[mint] = h
+		}
+		h.amount.Add(h.amount, new(big.Int).SetUint64(amount))
+		h.accounts += account
+	}
+	if lamports > 0 {
+		add(wSOLMint, lamports, 0)
+	}
+	for _, data := range accounts {
+		if len(data) < baseAccountLen {
+			continue
+		}
+		amount := binary.LittleEndian.Uint64(data[tokenAccountAmountOffset : tokenAccountAmountOffset+8])
+		if amount == 0 {
+			continue
+		}
+		add(solana.PublicKeyFromBytes(data[:32]), amount, 1)
+	}
+	return held
+}
+
+// walletHoldings reads what owner holds, with each mint's decimals, sorted by mint.
+func walletHoldings(ctx context.Context, client *rpc.Client, owner solana.PublicKey) ([]*holding, error) {
+	balance, err := client.GetBalance(ctx, owner, rpc.CommitmentConfirmed)
+	if err != nil {
+		return nil, fmt.Errorf("rpc call getBalance failed: %w", err)
+	}
+	var accounts [][]byte
+	for _, program := range []solana.PublicKey{solana.TokenProgramID, solana.Token2022ProgramID} {
+		res, err := client.GetTokenAccountsByOwner(ctx, owner,
+			&rpc.GetTokenAccountsConfig{ProgramId: ptrTo(program)},
+			&rpc.GetTokenAccountsOpts{Encoding: solana.EncodingBase64, Commitment: rpc.CommitmentConfirmed})
+		if err != nil {
+			return nil, fmt.Errorf("rpc call getTokenAccountsByOwner failed: %w", err)
+		}
+		if res == nil {
+			continue
+		}
+		for _, acc := range res.Value {
+			if acc != nil && acc.Account.Data != nil {
+				accounts = append(accounts, acc.Account.Data.GetBinary())
+			}
+		}
+	}
+	var lamports uint64
+	if balance != nil {
+		lamports = balance.Value
+	}
+	held := holdingsFromAccounts(lamports, accounts)
+	holdings := make([]*holding, 0, len(held))
+	for _, h := range held {
+		holdings = append(holdings, h)
+	}
+	sort.Slice(holdings, func(i, j int) bool { return bytes.Compare(holdings[i].mint[:], holdings[j].mint[:]) < 0 })
+	for start := 0; start < len(holdings); start += portfolioMintBatch {
+		batch := holdings[start:min(start+portfolioMintBatch, len(holdings))]
+		mints := make([]solana.PublicKey, len(batch))
+		for i, h := range batch {
+			mints[i] = h.mint
+		}
+		decoded, err := fetchMintAccounts(ctx, client, mints...)
+		if err != nil {
+			return nil, err
+		}
+		for i, m := range decoded {
+			batch[i].decimals = m.Decimals
+		}
+	}
+	return holdings, nil
+}
+
+// pairPrice is one whole token's price in the quote token, and where it came from.
+type pairPrice struct {
+	price  *big.Rat
+	pool   solana.PublicKey
+	viaSOL bool // priced in SOL on pool, then SOL in the quote token
+}
+
+// poolDepth is a pool trading a token against a quote token, with its reserves of each.
+type poolDepth struct {
+	pool         solana.PublicKey
+	reserve      *PoolBalance
+	quoteReserve *PoolBalance
+}
+
+// midPrice is the token's price in the quote token at the pool's reserves, in display units.
+func (pd poolDepth) midPrice() *big.Rat {
+	price := new(big.Rat).SetFrac(pd.quoteReserve.Balance, pd.reserve.Balance)
+	return price.Mul(price, new(big.Rat).SetFrac(fixedPointScale(pd.reserve.Decimals), fixedPointScale(pd.quoteReserve.Decimals)))
+}
+
+// deepestPool is the pool holding the most of the quote token, ok is false when none has reserves on both sides.
+func deepestPool(pools []poolDepth) (poolDepth, bool) {
+	var best poolDepth
+	found := false
+	for _, pd := range pools {
+		if pd.reserve == nil || pd.quoteReserve == nil || pd.reserve.Balance.Sign() == 0 || pd.quoteReserve.Balance.Sign() == 0 {
+			continue
+		}
+		if !found || pd.quoteReserve.Balance.Cmp(best.quoteReserve.Balance) > 0 {
+			best, found = pd, true
+		}
+	}
+	return best, found
+}
+
+// pairDepths reads the reserves of every tradable CP-Swap pool for mint against quote. A pool that can't be read is
+// skipped, it can't price anything.
+func pairDepths(ctx context.Context, client *rpc.Client, mint, quote solana.PublicKey) ([]poolDepth, error) {
+	addrs, err := findPoolsByMints(ctx, client, mint, quote)
+	if err != nil {
+		return nil, err
+	}
+	var depths []poolDepth
+	for _, addr := range addrs {
+		pool, err := fetchPoolState(ctx, client, addr)
+		if err != nil {
+			log.Printf("warning: skipping pool %s: %v", Addr(addr.String()), err)
+			continue
+		}
+		if checkPoolTradable(addr, pool, time.Now()) != nil {
+			continue
+		}
+		balances, errs := poolBalances(ctx, client, []solana.PublicKey{pool.Token0Vault, pool.Token1Vault})
+		if errs[0] != nil || errs[1] != nil {
+			continue
+		}
+		pd := poolDepth{pool: addr, reserve: balances[0], quoteReserve: balances[1]}
+		if pool.Token0Mint.Equals(quote) {
+			pd.reserve, pd.quoteReserve = balances[1], balances[0]
+		}
+		depths = append(depths, pd)
+	}
+	return depths, nil
+}
+
+// portfolioPricer prices mints in quote, going through SOL for tokens without a pool against quote. SOL's own price
+// is looked up once.
+type portfolioPricer struct {
+	client   *rpc.Client
+	quote    solana.PublicKey
+	solPrice *pairPrice
+	solDone  bool
+}
+
+// priceOf is mint's price in the quote token, nil when no pool prices it.
+func (pp *portfolioPricer) priceOf(ctx context.Context, mint solana.PublicKey) (*pairPrice, error) {
+	if mint.Equals(pp.quote) {
+		return &pairPrice{price: big.NewRat(1, 1)}, nil
+	}
+	if mint.Equals(wSOLMint) {
+		return pp.sol(ctx)
+	}
+	direct, err := pairDepths(ctx, pp.client, mint, pp.quote)
+	if err != nil {
+		return nil, err
+	}
+	if best, ok := deepestPool(direct); ok {
+		return &pairPrice{price: best.midPrice(), pool: best.pool}, nil
+	}
+	inSOL, err := pairDepths(ctx, pp.client, mint, wSOLMint)
+	if err != nil {
+		return nil, err
+	}
+	best, ok := deepestPool(inSOL)
+	if !ok {
+		return nil, nil
+	}
+	sol, err := pp.sol(ctx)
+	if err != nil || sol == nil {
+		return nil, err
+	}
+	return &pairPrice{price: new(big.Rat).Mul(best.midPrice(), sol.price), pool: best.pool, viaSOL: true}, nil
+}
+
+func (pp *portfolioPricer) sol(ctx context.Context) (*pairPrice, error) {
+	if pp.solDone {
+		return pp.solPrice, nil
+	}
+	depths, err := pairDepths(ctx, pp.client, wSOLMint, pp.quote)
+	if err != nil {
+		return nil, err
+	}
+	pp.solDone = true
+	if best, ok := deepestPool(depths); ok {
+		pp.solPrice = &pairPrice{price: best.midPrice(), pool: best.pool}
+	}
+	return pp.solPrice, nil
+}
+
+// portfolio is a wallet's holdings valued in a quote token.
+type portfolio struct {
+	wallet        solana.PublicKey
+	quote         solana.PublicKey
+	quoteSymbol   string
+	quoteDecimals uint8
+	holdings      []*holding
+}
+
+// sortByValue puts the most valuable holdings first and the unpriced ones last, by symbol.
+func (p *portfolio) sortByValue() {
+	sort.SliceStable(p.holdings, func(i, j int) bool {
+		vi, vj := p.holdings[i].value(p.quoteDecimals), p.holdings[j].value(p.quoteDecimals)
+		switch {
+		case vi == nil && vj == nil:
+			return p.holdings[i].symbol < p.holdings[j].symbol
+		case vi == nil || vj == nil:
+			return vj == nil
+		}
+		return vi.Cmp(vj) > 0
+	})
+}
+
+// total is what the priced holdings add up to in raw quote token units, and how many holdings weren't priced.
+func (p *portfolio) total() (*big.Int, int) {
+	total, unpriced := new(big.Int), 0
+	for _, h := range p.holdings {
+		if v := h.value(p.quoteDecimals); v != nil {
+			total.Add(total, v)
+		} else {
+			unpriced++
+		}
+	}
+	return total, unpriced
+}
+
+// priceString is h's price in the quote token, with enough digits for a token worth a fraction of a cent.
+func (p *portfolio) priceString(h *holding) string {
+	if h.price == nil {
+		return ""
+	}
+	return trimDecimal(h.price.price.FloatString(int(p.quoteDecimals) + int(h.decimals)))
+}
+
+func (p *portfolio) pricedVia(h *holding) string {
+	switch {
+	case h.price == nil:
+		return "no pool"
+	case h.mint.Equals(p.quote):
+		return ""
+	case h.price.viaSOL:
+		return Addr(h.price.pool.String()).String() + " via SOL"
+	}
+	return Addr(h.price.pool.String()).String()
+}
+
+type portfolioHoldingJSON struct {
+	Mint         string      `json:"mint"`
+	Symbol       string      `json:"symbol"`
+	SymbolSource string      `json:"symbolSource"`
+	Balance      amountJSON  `json:"balance"`
+	Accounts     int         `json:"accounts"`
+	Price        string      `json:"price,omitempty"` // quote token per token, missing when no pool prices it
+	Value        *amountJSON `json:"value,omitempty"`
+	Pool         string      `json:"pool,omitempty"`
+	ViaSOL       bool        `json:"viaSOL,omitempty"`
+}
+
+type portfolioJSON struct {
+	Wallet    string                 `json:"wallet"`
+	Quote     string                 `json:"quote"`
+	QuoteMint string                 `json:"quoteMint"`
+	Holdings  []portfolioHoldingJSON `json:"holdings"`
+	Total     amountJSON             `json:"total"`
+	Unpriced  int                    `json:"unpriced"` // holdings left out of the total
+}
+
+func (p *portfolio) json() portfolioJSON {
+	total, unpriced := p.total()
+	out := portfolioJSON{
+		Wallet:    p.wallet.String(),
+		Quote:     p.quoteSymbol,
+		QuoteMint: p.quote.String(),
+		Holdings:  make([]portfolioHoldingJSON, 0, len(p.holdings)),
+		Total:     newAmountJSON(total, p.quoteDecimals),
+		Unpriced:  unpriced,
+	}
+	for _, h := range p.holdings {
+		hj := portfolioHoldingJSON{
+			Mint:         h.mint.String(),
+			Symbol:       h.symbol,
+			SymbolSource: h.source,
+			Balance:      newAmountJSON(h.amount, h.decimals),
+			Accounts:     h.accounts,
+			Price:        p.priceString(h),
+		}
+		if v := h.value(p.quoteDecimals); v != nil {
+			hj.Value = ptrTo(newAmountJSON(v, p.quoteDecimals))
+		}
+		if h.price != nil && !h.mint.Equals(p.quote) {
+			hj.Pool, hj.ViaSOL = h.price.pool.String(), h.price.viaSOL
+		}
+		out.Holdings = append(out.Holdings, hj)
+	}
+	return out
+}
+
+func (p *portfolio) render() string {
+	t := table.NewWriter()
+	t.AppendHeader(table.Row{"Symbol", "Mint", "Balance", "Price (" + p.quoteSymbol + ")", "Value (" + p.quoteSymbol + ")", "Priced on"})
+	for _, h := range p.holdings {
+		value := ""
+		if v := h.value(p.quoteDecimals); v != nil {
+			value = fmtAmount(v, p.quoteDecimals)
+		}
+		t.AppendRow(table.Row{h.symbol, Addr(h.mint.String()), fmtAmount(h.amount, h.decimals), p.priceString(h), value, p.pricedVia(h)})
+	}
+	total, unpriced := p.total()
+	note := ""
+	if unpriced > 0 {
+		note = fmt.Sprintf("%d unpriced", unpriced)
+	}
+	t.AppendFooter(table.Row{fmt.Sprintf("%d tokens", len(p.holdings)), "", "", "", fmtAmount(total, p.quoteDecimals), note})
+	return t.Render()
+}
+
+// loadPortfolio reads what owner holds and prices it in quote.
+func loadPortfolio(ctx context.Context, client *rpc.Client, owner, quote solana.PublicKey) (*portfolio, error) {
+	quoteCtx, cancel := deadlines.forQuote(ctx)
+	holdings, err := walletHoldings(quoteCtx, client, owner)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	quoteMint, err := fetchMintAccounts(quoteCtx, client, quote)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("reading the quote token: %w", err)
+	}
+	mints := []solana.PublicKey{quote}
+	for _, h := range holdings {
+		mints = append(mints, h.mint)
+	}
+	symm := makeSymbolMapping(ctx, client, mints)
+	userAliases.apply(symm, mints...)
+	p := &portfolio{wallet: owner, quote: quote, quoteSymbol: symm.SymFrom(quote), quoteDecimals: quoteMint[0].Decimals, holdings: holdings}
+	pricer := &portfolioPricer{client: client, quote: quote}
+	for _, h := range holdings {
+		h.symbol, h.source = symm.SymFrom(h.mint), symm.SourceOf(h.mint)
+		priceCtx, cancel := deadlines.forQuote(ctx)
+		h.price, err = pricer.priceOf(priceCtx, h.mint)
+		cancel()
+		if err != nil {
+			log.Printf("warning: pricing %s failed: %v", h.symbol, err)
+			h.price = nil
+		}
+	}
+	p.sortByValue()
+	return p, nil
+}
+
+func runPortfolioCommand(args []string) error {
+	fs := flag.NewFlagSet("portfolio", flag.ExitOnError)
+	nf := addNetworkFlags(fs)
+	var (
+		hotwalletPath = fs.String("hotwallet", "", "Path to the hotwallet whose holdings get listed")
+		asJSON        = fs.Bool("json", false, "Print the portfolio as JSON instead of a table")
+	)
+	addAmountFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, append(nf.specs(),
+		FlagSpec{Name: "hotwallet", Value: hotwalletPath, Rules: []FlagRule{NotEmpty()}},
+	))
+	payer, err := solana.PrivateKeyFromSolanaKeygenFile(*hotwalletPath)
+	if err != nil {
+		return fmt.Errorf("failed to load private key from hot wallet: %w", err)
+	}
+	client := nf.connect()
+	ctx, stop := interruptContext()
+	defer stop()
+
+	p, err := loadPortfolio(ctx, client, payer.PublicKey(), networks[*nf.network][USDCMint].(solana.PublicKey))
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(p.json())
+	}
+	if len(p.holdings) == 0 {
+		fmt.Println("the wallet holds nothing")
+		return nil
+	}
+	fmt.Println(p.render())
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestHoldingsFromAccounts(t *testing.T) {
+	owner, bonk, dust := snapshotKey(30), snapshotKey(31), snapshotKey(32)
+	held := holdingsFromAccounts(2_000_000_000, [][]byte{
+		ownedTokenAccount(wSOLMint, owner, 500_000_000, nil),
+		ownedTokenAccount(bonk, owner, 100, nil),
+		ownedTokenAccount(bonk, owner, 50, nil),
+		ownedTokenAccount(dust, owner, 0, nil),
+		{1, 2, 3},
+	})
+	if len(held) != 2 {
+		t.Fatalf("got %d holdings, want SOL and BONK", len(held))
+	}
+	if sol := held[wSOLMint]; sol.amount.Cmp(big.NewInt(2_500_000_000)) != 0 || sol.accounts != 1 {
+		t.Errorf("SOL is %s over %d accounts, want native and wrapped together", sol.amount, sol.accounts)
+	}
+	if b := held[bonk]; b.amount.Cmp(big.NewInt(150)) != 0 || b.accounts != 2 {
+		t.Errorf("BONK is %s over %d accounts", b.amount, b.accounts)
+	}
+}
+
+func TestDeepestPool(t *testing.T) {
+	bal := func(v int64, decimals uint8) *PoolBalance {
+		return &PoolBalance{Balance: big.NewInt(v), Decimals: decimals}
+	}
+	shallow := poolDepth{pool: snapshotKey(33), reserve: bal(1_000_000_000, 9), quoteReserve: bal(100_000_000, 6)}
+	deep := poolDepth{pool: snapshotKey(34), reserve: bal(10_000_000_000, 9), quoteReserve: bal(1_500_000_000, 6)}
+	drained := poolDepth{pool: snapshotKey(35), reserve: bal(0, 9), quoteReserve: bal(9_000_000_000, 6)}
+	best, ok := deepestPool([]poolDepth{shallow, drained, deep})
+	if !ok || !best.pool.Equals(deep.pool) {
+		t.Fatalf("picked %s, want the deep pool", best.pool)
+	}
+	if got := best.midPrice(); got.Cmp(big.NewRat(150, 1)) != 0 {
+		t.Errorf("mid price %s, want 150", got.FloatString(6))
+	}
+	if _, ok := deepestPool([]poolDepth{drained}); ok {
+		t.Error("a pool with an empty side priced something")
+	}
+}
+
+func TestPortfolioValues(t *testing.T) {
+	usdc, bonk, junk := snapshotKey(36), snapshotKey(37), snapshotKey(38)
+	p := &portfolio{quote: usdc, quoteSymbol: "USDC", quoteDecimals: 6, holdings: []*holding{
+		{mint: junk, symbol: "JUNK", amount: big.NewInt(1), decimals: 0},
+		{mint: usdc, symbol: "USDC", amount: big.NewInt(5_000_000), decimals: 6, price: &pairPrice{price: big.NewRat(1, 1)}},
+		{mint: wSOLMint, symbol: "SOL", amount: big.NewInt(2_000_000_000), decimals: 9, price: &pairPrice{price: big.NewRat(150, 1), pool: snapshotKey(39)}},
+		{mint: bonk, symbol: "BONK", amount: big.NewInt(100_000_000), decimals: 5, price: &pairPrice{price: big.NewRat(3, 100_000), pool: snapshotKey(40), viaSOL: true}},
+	}}
+	p.sortByValue()
+	var order []string
+	for _, h := range p.holdings {
+		order = append(order, h.symbol)
+	}
+	if want := []string{"SOL", "USDC", "BONK", "JUNK"}; len(order) != len(want) || order[0] != want[0] || order[1] != want[1] || order[2] != want[2] || order[3] != want[3] {
+		t.Errorf("sorted %v, want %v", order, want)
+	}
+	total, unpriced := p.total()
+	// 2 SOL at 150, 5 USDC, 1000 BONK at 0.00003.
+	if total.Cmp(big.NewInt(305_030_000)) != 0 || unpriced != 1 {
+		t.Errorf("total %s with %d unpriced", total, unpriced)
+	}
+	out := p.json()
+	if out.Total.Display != "305.030000" || out.Unpriced != 1 {
+		t.Errorf("total %+v, %d unpriced", out.Total, out.Unpriced)
+	}
+	bonkJSON := out.Holdings[2]
+	if !bonkJSON.ViaSOL || bonkJSON.Price != "0.00003" || bonkJSON.Value == nil || bonkJSON.Value.Raw != "30000" {
+		t.Errorf("BONK came out as %+v", bonkJSON)
+	}
+	if usdcJSON := out.Holdings[1]; usdcJSON.Pool != "" || usdcJSON.Price != "1" {
+		t.Errorf("USDC came out as %+v", usdcJSON)
+	}
+	if junkJSON := out.Holdings[3]; junkJSON.Value != nil || junkJSON.Price != "" {
+		t.Errorf("JUNK came out as %+v", junkJSON)
+	}
+}
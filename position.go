@@ -0,0 +1,358 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+/*
+NOTE(@hadydotai): Impermanent loss.
+
+`position il <pool> -entry-price P0` is what providing liquidity has cost against just holding the tokens, from the
+price the position went in at (token1 per token0, the price `pool stats` and the quotes show). A constant product
+pool rebalances as the price moves, selling the side that's going up, so an LP ends up with less than the same tokens
+held would be worth. With k the price now over the entry price that's
+
+	2*sqrt(k)/(1+k) - 1
+
+of what holding is worth, zero at the entry price and worse the further the price has gone, either way. It's the same
+whatever the position's size, and it's all the command shows without one.
+
+The position is what's held of the pool's LP token, -lp as an amount or -owner as the wallet to read it from (its
+associated account for the LP mint). Its share of the LP supply is its share of the reserves, the tokens a withdrawal
+would pay out, valued in token1 at the pool's price. What holding would be worth comes from what went in, -deposited
+"10 SOL" with the matching token1 at the entry price, the way a deposit goes in at the pool's ratio. Without it the
+hold value is worked back from the position's value and the loss, which takes the position's fees as if they'd been
+held too. With both, the position against holding is the loss plus the fees, and the fees are what's left of it.
+
+Breakeven fee income is the loss as an amount: the fees the position has to have earned, over its life, to come out
+even with holding at today's price. There's no record of deposits kept to read the entry from, the client doesn't
+deposit liquidity itself, so the entry price and amounts are the user's to give.
+*/
+
+// impermanentLoss is the LP's loss against holding when the price went from entry to now, a fraction, zero or below.
+func impermanentLoss(entry, now *big.Rat) *big.Rat {
+	k := new(big.Float).SetPrec(256).SetRat(new(big.Rat).Quo(now, entry))
+	sqrtK := new(big.Float).SetPrec(256).Sqrt(k)
+	ratio := new(big.Float).SetPrec(256).Quo(new(big.Float).Mul(sqrtK, big.NewFloat(2)), new(big.Float).Add(k, big.NewFloat(1)))
+	// Rounded to 18 places, a binary float's 0.8 is a hair under and would show as a unit off in every amount.
+	loss, _ := new(big.Rat).SetString(ratio.Text('f', 18))
+	return loss.Sub(loss, big.NewRat(1, 1))
+}
+
+// positionIL is a position's impermanent loss in a pool. Values are token1 base units.
+type positionIL struct {
+	pool     solana.PublicKey
+	symbols  [2]string
+	decimals [2]uint8
+	entry    *big.Rat // token1 per token0
+	price    *big.Rat // token1 per token0 now
+	rawPrice *big.Rat // token1 base units per token0 base unit now
+	loss     *big.Rat
+
+	lp         *big.Int // LP tokens held, nil without a position
+	lpDecimals uint8
+	share      *big.Rat
+	amounts    [2]*big.Int // what the LP tokens withdraw to
+	value      *big.Rat
+
+	deposited [2]*big.Int // nil without -deposited
+	hold      *big.Rat    // what holding is worth now, nil with neither a position nor a deposit
+}
+
+// newPositionIL works out the loss at reserves for entry (token1 per token0) with the pool's decimals.
+func newPositionIL(pool solana.PublicKey, symbols [2]string, decimals [2]uint8, reserves [2]*big.Int, entry *big.Rat) (*positionIL, error) {
+	if reserves[0] == nil || reserves[1] == nil || reserves[0].Sign() <= 0 || reserves[1].Sign() <= 0 {
+		return nil, errors.New("the pool is empty, it has no price to compare with")
+	}
+	if entry.Sign() <= 0 {
+		return nil, errors.New("-entry-price has to be greater than zero")
+	}
+	p := &positionIL{pool: pool, symbols: symbols, decimals: decimals, entry: entry}
+	p.rawPrice = new(big.Rat).SetFrac(reserves[1], reserves[0])
+	p.price = new(big.Rat).Mul(p.rawPrice, new(big.Rat).SetFrac(fixedPointScale(decimals[0]), fixedPointScale(decimals[1])))
+	p.loss = impermanentLoss(entry, p.price)
+	return p, nil
+}
+
+// withLP sizes the position at lp of supply LP tokens.
+func (p *positionIL) withLP(lp, supply *big.Int, lpDecimals uint8, reserves [2]*big.Int) error {
+	if supply.Sign() <= 0 {
+		return errors.New("the pool has no LP supply")
+	}
+	if lp.Cmp(supply) > 0 {
+		return fmt.Errorf("%s LP tokens is more than the pool's supply of %s", fmtAmount(lp, lpDecimals), fmtAmount(supply, lpDecimals))
+	}
+	p.lp, p.lpDecimals = lp, lpDecimals
+	p.share = new(big.Rat).SetFrac(lp, supply)
+	for i := range reserves {
+		amount := new(big.Int).Mul(reserves[i], lp)
+		p.amounts[i] = amount.Quo(amount, supply)
+	}
+	p.value = p.inToken1(p.amounts)
+	if p.deposited[0] == nil {
+		p.hold = new(big.Rat).Quo(p.value, new(big.Rat).Add(big.NewRat(1, 1), p.loss))
+	}
+	return nil
+}
+
+// withDeposit sets what went in, amount of token i and the other token at the entry price.
+func (p *positionIL) withDeposit(i int, amount *big.Int) {
+	p.deposited[i] = amount
+	// The entry price in base units, token1 per token0.
+	rawEntry := new(big.Rat).Mul(p.entry, new(big.Rat).SetFrac(fixedPointScale(p.decimals[1]), fixedPointScale(p.decimals[0])))
+	other := new(big.Rat).SetInt(amount)
+	if i == 0 {
+		other.Mul(other, rawEntry)
+	} else {
+		other.Quo(other, rawEntry)
+	}
+	p.deposited[1-i] = ratAmount(other)
+	p.hold = p.inToken1(p.deposited)
+}
+
+func (p *positionIL) inToken1(amounts [2]*big.Int) *big.Rat {
+	v := new(big.Rat).Mul(new(big.Rat).SetInt(amounts[0]), p.rawPrice)
+	return v.Add(v, new(big.Rat).SetInt(amounts[1]))
+}
+
+// breakeven is the fee income that makes up for the loss, nil without a hold value.
+func (p *positionIL) breakeven() *big.Rat {
+	if p.hold == nil {
+		return nil
+	}
+	return new(big.Rat).Mul(p.hold, new(big.Rat).Neg(p.loss))
+}
+
+// vsHold is the position's value less holding's and the fees that leaves, nil unless both a position and a deposit
+// were given.
+func (p *positionIL) vsHold() (diff, fees *big.Rat) {
+	if p.value == nil || p.deposited[0] == nil {
+		return nil, nil
+	}
+	diff = new(big.Rat).Sub(p.value, p.hold)
+	fees = new(big.Rat).Add(diff, p.breakeven())
+	return diff, fees
+}
+
+func (p *positionIL) priceString(price *big.Rat) string {
+	return trimDecimal(price.FloatString(int(p.decimals[1])))
+}
+
+// signedToken1 is v, token1 base units, with its sign.
+func (p *positionIL) signedToken1(v *big.Rat) string {
+	s := formatTokenAmount(ratAmount(new(big.Rat).Abs(v)), p.decimals[1], p.symbols[1])
+	if v.Sign() < 0 {
+		return "-" + s
+	}
+	return "+" + s
+}
+
+func (p *positionIL) render() string {
+	quote := func(v *big.Rat) string { return formatTokenAmount(ratAmount(v), p.decimals[1], p.symbols[1]) }
+	pair := func(amounts [2]*big.Int) string {
+		return formatTokenAmount(amounts[0], p.decimals[0], p.symbols[0]) + " + " + formatTokenAmount(amounts[1], p.decimals[1], p.symbols[1])
+	}
+	t := table.NewWriter()
+	t.SetTitle(fmt.Sprintf("Impermanent loss, pool %s, %s/%s", Addr(p.pool.String()), p.symbols[0], p.symbols[1]))
+	unit := fmt.Sprintf(" (%s per %s)", p.symbols[1], p.symbols[0])
+	change := new(big.Rat).Quo(new(big.Rat).Sub(p.price, p.entry), p.entry)
+	t.AppendRow(table.Row{"Entry price" + unit, p.priceString(p.entry)})
+	t.AppendRow(table.Row{"Price now" + unit, fmt.Sprintf("%s, %s", p.priceString(p.price), fmtSignedPct(change))})
+	t.AppendRow(table.Row{"Impermanent loss", fmtSignedPct(p.loss) + " against holding"})
+	if p.lp != nil {
+		t.AppendSeparator()
+		t.AppendRow(table.Row{"LP tokens", fmt.Sprintf("%s, %s of the pool", fmtAmount(p.lp, p.lpDecimals), pctString(p.share))})
+		t.AppendRow(table.Row{"Withdraws to", pair(p.amounts)})
+		t.AppendRow(table.Row{"Position worth", quote(p.value)})
+	}
+	if p.hold != nil {
+		held := quote(p.hold)
+		if p.deposited[0] != nil {
+			held = fmt.Sprintf("%s (%s)", held, pair(p.deposited))
+		} else {
+			held += " (worked back from the position, its fees counted as held)"
+		}
+		t.AppendRow(table.Row{"Holding worth", held})
+		t.AppendRow(table.Row{"Loss", p.signedToken1(new(big.Rat).Neg(p.breakeven()))})
+		t.AppendRow(table.Row{"Breakeven fee income", quote(p.breakeven())})
+	} else {
+		t.AppendRow(table.Row{"Breakeven fee income", pctString(new(big.Rat).Neg(p.loss)) + " of what holding is worth"})
+	}
+	if diff, fees := p.vsHold(); diff != nil {
+		t.AppendRow(table.Row{"Against holding", p.signedToken1(diff)})
+		t.AppendRow(table.Row{"Fees earned (est.)", p.signedToken1(fees)})
+	}
+	return t.Render()
+}
+
+type positionILJSON struct {
+	Pool           string         `json:"pool"`
+	PriceUnit      string         `json:"priceUnit"`
+	EntryPrice     string         `json:"entryPrice"`
+	Price          string         `json:"price"`
+	Loss           string         `json:"impermanentLoss"`
+	LP             *amountJSON    `json:"lp,omitempty"`
+	Share          string         `json:"share,omitempty"`
+	Amounts        *[2]amountJSON `json:"amounts,omitempty"`
+	Value          *amountJSON    `json:"value,omitempty"` // this and the amounts below are in token1
+	Deposited      *[2]amountJSON `json:"deposited,omitempty"`
+	Hold           *amountJSON    `json:"hold,omitempty"`
+	Breakeven      *amountJSON    `json:"breakevenFees,omitempty"`
+	AgainstHolding *amountJSON    `json:"againstHolding,omitempty"`
+	FeesEarned     *amountJSON    `json:"feesEarned,omitempty"`
+}
+
+func (p *positionIL) json() positionILJSON {
+	token1 := func(v *big.Rat) *amountJSON {
+		a := newAmountJSON(ratAmount(v), p.decimals[1])
+		return &a
+	}
+	pair := func(amounts [2]*big.Int) *[2]amountJSON {
+		return &[2]amountJSON{newAmountJSON(amounts[0], p.decimals[0]), newAmountJSON(amounts[1], p.decimals[1])}
+	}
+	out := positionILJSON{
+		Pool:       p.pool.String(),
+		PriceUnit:  p.symbols[1] + " per " + p.symbols[0],
+		EntryPrice: p.priceString(p.entry),
+		Price:      p.priceString(p.price),
+		Loss:       fmtSignedPct(p.loss),
+	}
+	if p.lp != nil {
+		lp := newAmountJSON(p.lp, p.lpDecimals)
+		out.LP, out.Share, out.Amounts, out.Value = &lp, pctString(p.share), pair(p.amounts), token1(p.value)
+	}
+	if p.deposited[0] != nil {
+		out.Deposited = pair(p.deposited)
+	}
+	if p.hold != nil {
+		out.Hold, out.Breakeven = token1(p.hold), token1(p.breakeven())
+	}
+	if diff, fees := p.vsHold(); diff != nil {
+		out.AgainstHolding, out.FeesEarned = token1(diff), token1(fees)
+	}
+	return out
+}
+
+func runPositionCommand(args []string) error {
+	return dispatchSubcommand("position", map[string]func([]string) error{
+		"il": runPositionILCommand,
+	}, args)
+}
+
+func runPositionILCommand(args []string) error {
+	fs := flag.NewFlagSet("position il", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: position il [flags] <pool address or pair> -entry-price <price>\n")
+		fs.PrintDefaults()
+	}
+	nf := addNetworkFlags(fs)
+	var (
+		entryPrice = fs.String("entry-price", "", "The pool's price (token1 per token0) when the position went in")
+		lpAmount   = fs.String("lp", "", "LP tokens the position holds")
+		owner      = fs.String("owner", "", "Read the position's LP tokens from this wallet instead of -lp")
+		deposited  = fs.String("deposited", "", "What went in, e.g. \"10 SOL\", paired with the other token at -entry-price")
+		asJSON     = fs.Bool("json", false, "Print the figures as JSON instead of a table")
+	)
+	addAmountFlags(fs)
+	// The pool reads naturally first, `position il <pool> -entry-price 140`, flag stops at the first argument.
+	var target string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		target, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, append(nf.specs(), FlagSpec{Name: "entry-price", Value: entryPrice, Rules: []FlagRule{NotEmpty()}}))
+	if target == "" && fs.NArg() > 0 {
+		target = fs.Arg(0)
+	}
+	if target == "" {
+		fs.Usage()
+		return errors.New("missing pool")
+	}
+	entry, ok := new(big.Rat).SetString(*entryPrice)
+	if !ok || entry.Sign() <= 0 {
+		return fmt.Errorf("-entry-price takes a price above zero, got %q", *entryPrice)
+	}
+	if *lpAmount != "" && *owner != "" {
+		return errors.New("-lp and -owner both size the position, pass one")
+	}
+	var ownerKey solana.PublicKey
+	if *owner != "" {
+		var err error
+		if ownerKey, err = solana.PublicKeyFromBase58(*owner); err != nil {
+			return fmt.Errorf("-owner isn't a wallet address: %w", err)
+		}
+	}
+	client := nf.connect()
+	ctx, stop := interruptContext()
+	defer stop()
+
+	quoteCtx, cancel := deadlines.forQuote(ctx)
+	defer cancel()
+	poolAddr, err := resolvePoolTarget(quoteCtx, client, target, SymbolMapping{})
+	if err != nil {
+		return err
+	}
+	lp, err := loadPool(ctx, client, poolAddr)
+	if err != nil {
+		return err
+	}
+	if lp.mints[0] == nil || lp.mints[1] == nil {
+		return fmt.Errorf("couldn't read the mints of pool %s", Addr(poolAddr.String()))
+	}
+	balances, errs := poolReserves(quoteCtx, client, lp.pool, lp.mints)
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("reading the pool's reserves failed: %w", err)
+	}
+	reserves := [2]*big.Int{balances[0].Balance, balances[1].Balance}
+	symbols := [2]string{lp.symbolsMap.SymFrom(lp.pool.Token0Mint), lp.symbolsMap.SymFrom(lp.pool.Token1Mint)}
+	decimals := [2]uint8{lp.mints[0].Decimals, lp.mints[1].Decimals}
+	p, err := newPositionIL(poolAddr, symbols, decimals, reserves, entry)
+	if err != nil {
+		return err
+	}
+	if *deposited != "" {
+		i, amount, err := parseDeposit("deposited", *deposited, &poolStats{symbols: symbols, decimals: decimals})
+		if err != nil {
+			return err
+		}
+		p.withDeposit(i, amount)
+	}
+	var held *big.Int
+	switch {
+	case *lpAmount != "":
+		if held, err = fmtForMath(*lpAmount, lp.pool.LpMintDecimals); err != nil {
+			return fmt.Errorf("-lp: %w", err)
+		}
+	case !ownerKey.IsZero():
+		// cp-swap makes every LP mint under the classic token program, whatever the pool's tokens are.
+		if held, err = walletTokenBalance(quoteCtx, client, ownerKey, lp.pool.LpMint, solana.TokenProgramID); err != nil {
+			return err
+		}
+		if held.Sign() == 0 {
+			return fmt.Errorf("%s holds none of pool %s's LP token", Addr(ownerKey.String()), Addr(poolAddr.String()))
+		}
+	}
+	if held != nil {
+		if err := p.withLP(held, new(big.Int).SetUint64(lp.pool.LpSupply), lp.pool.LpMintDecimals, reserves); err != nil {
+			return err
+		}
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(p.json())
+	}
+	fmt.Println(p.render())
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestImpermanentLoss(t *testing.T) {
+	for _, tc := range []struct {
+		entry, now *big.Rat
+		want       string
+	}{
+		{big.NewRat(100, 1), big.NewRat(100, 1), "+0%"},
+		// Four times the price, or a quarter of it, is 20% behind holding either way.
+		{big.NewRat(100, 1), big.NewRat(400, 1), "-20%"},
+		{big.NewRat(400, 1), big.NewRat(100, 1), "-20%"},
+		{big.NewRat(100, 1), big.NewRat(200, 1), "-5.72%"},
+	} {
+		if got := fmtSignedPct(impermanentLoss(tc.entry, tc.now)); got != tc.want {
+			t.Errorf("%s to %s: %s, want %s", tc.entry, tc.now, got, tc.want)
+		}
+	}
+}
+
+// ilPosition is 10% of a SOL/USDC pool of 100 SOL and 40000 USDC, 400 USDC per SOL, entered at 100.
+func ilPosition(t *testing.T) (*positionIL, [2]*big.Int) {
+	t.Helper()
+	reserves := [2]*big.Int{big.NewInt(100_000_000_000), big.NewInt(40_000_000_000)}
+	p, err := newPositionIL(snapshotKey(60), [2]string{"SOL", "USDC"}, [2]uint8{9, 6}, reserves, big.NewRat(100, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, reserves
+}
+
+func TestPositionIL(t *testing.T) {
+	// Without a position only the rate is known.
+	p, reserves := ilPosition(t)
+	if p.breakeven() != nil || !strings.Contains(p.render(), "20% of what holding is worth") {
+		t.Errorf("without a position\n%s", p.render())
+	}
+
+	// 10 SOL and 4000 USDC are worth 8000 USDC, 20% short of the 10000 holding is worth.
+	if err := p.withLP(big.NewInt(100_000_000_000), big.NewInt(1_000_000_000_000), 9, reserves); err != nil {
+		t.Fatal(err)
+	}
+	if p.amounts[0].Int64() != 10_000_000_000 || p.amounts[1].Int64() != 4_000_000_000 || ratAmount(p.value).Int64() != 8_000_000_000 {
+		t.Errorf("position %s + %s worth %s", p.amounts[0], p.amounts[1], p.value)
+	}
+	if ratAmount(p.hold).Int64() != 10_000_000_000 || ratAmount(p.breakeven()).Int64() != 2_000_000_000 {
+		t.Errorf("hold %s, breakeven %s", p.hold, p.breakeven())
+	}
+	if diff, _ := p.vsHold(); diff != nil {
+		t.Errorf("against holding %s without -deposited", diff)
+	}
+
+	// 19 SOL went in with 1900 USDC: holding is worth 9500, the loss 1900 of it, and the position's 1500 short, so it
+	// earned 400 in fees.
+	p, _ = ilPosition(t)
+	p.withDeposit(0, big.NewInt(19_000_000_000))
+	if err := p.withLP(big.NewInt(100_000_000_000), big.NewInt(1_000_000_000_000), 9, reserves); err != nil {
+		t.Fatal(err)
+	}
+	diff, fees := p.vsHold()
+	if p.deposited[1].Int64() != 1_900_000_000 || ratAmount(p.hold).Int64() != 9_500_000_000 || diff == nil || fees == nil {
+		t.Fatalf("deposited %s, hold %s", p.deposited[1], p.hold)
+	}
+	if ratAmount(new(big.Rat).Neg(diff)).Int64() != 1_500_000_000 || ratAmount(fees).Int64() != 400_000_000 {
+		t.Errorf("against holding %s, fees %s", diff, fees)
+	}
+	out := p.render()
+	for _, want := range []string{"-20% against holding", "10% of the pool", "-1500.000000 USDC", "+400.000000 USDC", "1900.000000 USDC"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("%q isn't in\n%s", want, out)
+		}
+	}
+	if js := p.json(); js.Loss != "-20%" || js.FeesEarned == nil || js.FeesEarned.Display != "400.000000" {
+		t.Errorf("json %+v", js)
+	}
+
+	if err := p.withLP(big.NewInt(2), big.NewInt(1), 9, reserves); err == nil {
+		t.Error("more LP tokens than the supply passed")
+	}
+}
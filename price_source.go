@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): USD prices.
+
+`buy $50 of BONK` needs to know what a dollar is in the token being paid, which the pool can't say, it only knows
+one token in terms of the other. So there's a price source, picked with -price-source:
+
+  - jupiter: Jupiter's price API, prices about anything that trades on mainnet.
+  - pyth: Pyth's Hermes API, oracle prices with a confidence and a publish time, but only for the feeds we know the
+    id of (pythFeeds), SOL and the major stables.
+
+A price is only good for so long, -price-max-age (a minute by default) is how old it can be before the intent is
+refused rather than sized off it. Pyth says when it published. Jupiter doesn't say when, it says at which slot, so
+the age is how many slots mainnet has moved on since, at 400ms a slot. It's an estimate, slots skip, but a stale
+price is off by minutes, not by a slot or two.
+
+Both are mainnet prices, there are no devnet prices. A USD amount on devnet is priced like mainnet SOL, which is
+what you'd expect for SOL and means nothing for a devnet-only mint, which neither source will have.
+*/
+
+// usdPrice is what one whole token is worth in USD, as of PublishedAt.
+type usdPrice struct {
+	Price       *big.Rat
+	PublishedAt time.Time
+	Source      string
+}
+
+type priceSource interface {
+	usdPrice(ctx context.Context, mint solana.PublicKey) (usdPrice, error)
+}
+
+var (
+	// priceSourceName and priceMaxAge are set by -price-source and -price-max-age.
+	priceSourceName = "jupiter"
+	priceMaxAge     = time.Minute
+
+	jupiterPriceURL = "https://lite-api.jup.ag/price/v3"
+	pythHermesURL   = "https://hermes.pyth.network"
+	// mainnetSlotRPC is where Jupiter's slots are compared against, whatever -network is.
+	mainnetSlotRPC = rpc.MainNetBeta_RPC
+
+	priceHTTP = &http.Client{Timeout: 10 * time.Second}
+)
+
+const slotDuration = 400 * time.Millisecond
+
+// pythFeeds are the Pyth price feed ids (the USD pair) of the mints the pyth source can price.
+var pythFeeds = map[solana.PublicKey]string{
+	solana.SolMint: "ef0d8b6fda2ceba41da15d4095d1da392a0d2f8ed0c6c7bc0f4cfac8c280b56d",
+	solana.MustPublicKeyFromBase58("EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"): "eaa020c61cc479712813461ce153894a96a6c00b21ed0cfc2798d1f9a9e9c94a", // USDC
+	solana.MustPublicKeyFromBase58("Es9vMFrzaCERmJfrF4H2FYD4KCoNkY11McCe8BenwNYB"): "2b89b9dc8fdf9f34709a5b106b472f0f39bb6ca9ce04b0fd7f2e971688e2e53b", // USDT
+}
+
+func addPriceFlags(fs *flag.FlagSet) {
+	fs.StringVar(&priceSourceName, "price-source", priceSourceName, "Where USD amounts ($50) are priced, 'jupiter' or 'pyth'")
+	fs.DurationVar(&priceMaxAge, "price-max-age", priceMaxAge, "Refuse USD amounts priced off a price older than this")
+}
+
+func priceSpecs() []FlagSpec {
+	return []FlagSpec{{Name: "price-source", Value: &priceSourceName, Rules: []FlagRule{NotEmpty(), OneOf("jupiter", "pyth")}}}
+}
+
+// newPriceSource is the source -price-source names.
+func newPriceSource(name string) (priceSource, error) {
+	switch strings.ToLower(name) {
+	case "jupiter":
+		return jupiterPrices{slots: rpc.New(mainnetSlotRPC)}, nil
+	case "pyth":
+		return pythPrices{}, nil
+	}
+	return nil, fmt.Errorf("unknown price source %q, expected jupiter or pyth", name)
+}
+
+// fetchJSON GETs endpoint and decodes its JSON body into out.
+func fetchJSON(ctx context.Context, endpoint string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := priceHTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %s", req.URL.Host, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type jupiterPrices struct {
+	slots *rpc.Client
+}
+
+func (j jupiterPrices) usdPrice(ctx context.Context, mint solana.PublicKey) (usdPrice, error) {
+	var body map[string]struct {
+		USDPrice float64 `json:"usdPrice"`
+		BlockID  uint64  `json:"blockId"`
+	}
+	if err := fetchJSON(ctx, jupiterPriceURL+"?ids="+url.QueryEscape(mint.String()), &body); err != nil {
+		return usdPrice{}, fmt.Errorf("jupiter price lookup failed: %w", err)
+	}
+	quote, ok := body[mint.String()]
+	if !ok || quote.USDPrice <= 0 {
+		return usdPrice{}, fmt.Errorf("jupiter has no price for %s", Addr(mint.String()))
+	}
+	slot, err := j.slots.GetSlot(ctx, rpc.CommitmentConfirmed)
+	if err != nil {
+		return usdPrice{}, fmt.Errorf("rpc call getSlot failed, can't tell how old jupiter's price is: %w", err)
+	}
+	var behind uint64
+	if slot > quote.BlockID {
+		behind = slot - quote.BlockID
+	}
+	return usdPrice{
+		Price:       new(big.Rat).SetFloat64(quote.USDPrice),
+		PublishedAt: time.Now().Add(-time.Duration(behind) * slotDuration),
+		Source:      "jupiter",
+	}, nil
+}
+
+type pythPrices struct{}
+
+func (pythPrices) usdPrice(ctx context.Context, mint solana.PublicKey) (usdPrice, error) {
+	feed, ok := pythFeeds[mint]
+	if !ok {
+		return usdPrice{}, fmt.Errorf("there's no pyth feed known for %s, try -price-source jupiter", Addr(mint.String()))
+	}
+	var body struct {
+		Parsed []struct {
+			ID    string `json:"id"`
+			Price struct {
+				Price       string `json:"price"`
+				Expo        int    `json:"expo"`
+				PublishTime int64  `json:"publish_time"`
+			} `json:"price"`
+		} `json:"parsed"`
+	}
+	endpoint := pythHermesURL + "/v2/updates/price/latest?parsed=true&ids[]=" + feed
+	if err := fetchJSON(ctx, endpoint, &body); err != nil {
+		return usdPrice{}, fmt.Errorf("pyth price lookup failed: %w", err)
+	}
+	if len(body.Parsed) == 0 {
+		return usdPrice{}, errors.New("pyth returned no price")
+	}
+	p := body.Parsed[0].Price
+	mantissa, ok := new(big.Int).SetString(p.Price, 10)
+	if !ok || mantissa.Sign() <= 0 {
+		return usdPrice{}, fmt.Errorf("pyth returned an invalid price %q", p.Price)
+	}
+	// price is mantissa * 10^expo, expo is negative for every USD feed but handle both.
+	exp := p.Expo
+	if exp < 0 {
+		exp = -exp
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil)
+	price := new(big.Rat).SetInt(mantissa)
+	if p.Expo < 0 {
+		price.Quo(price, new(big.Rat).SetInt(scale))
+	} else {
+		price.Mul(price, new(big.Rat).SetInt(scale))
+	}
+	return usdPrice{Price: price, PublishedAt: time.Unix(p.PublishTime, 0), Source: "pyth"}, nil
+}
+
+// usdConversion is a USD amount turned into an amount of the token that's paid.
+type usdConversion struct {
+	instruction *IntentInstruction // the intent as an exact input of the paid token
+	inputMint   solana.PublicKey
+	price       usdPrice
+	age         time.Duration
+}
+
+// resolveUSD turns the instruction's USD amount into an exact amount of what's paid, inputMint, at the current price.
+// Buying is turned into paying, `buy $50 of BONK` spends $50 worth of the other token.
+func resolveUSD(ctx context.Context, ii *IntentInstruction, inputMint solana.PublicKey, inputSym string, decimals uint8) (*usdConversion, error) {
+	price, age, err := currentUSDPrice(ctx, inputMint, inputSym)
+	if err != nil {
+		return nil, fmt.Errorf("%w, refusing to size the swap off it", err)
+	}
+	amount := new(big.Rat).Quo(ii.AmountUSD, price.Price)
+	amount.Mul(amount, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)))
+	raw := new(big.Int).Quo(amount.Num(), amount.Denom())
+	if raw.Sign() == 0 {
+		return nil, fmt.Errorf("%s is less than the smallest unit of %s", ii.AmountStr, inputSym)
+	}
+	resolved := *ii
+	resolved.AmountStr = fmtForDisplay(raw, decimals, int(decimals))
+	resolved.AmountUSD = nil
+	if ii.Dir == SwapDirBuy {
+		resolved.Dir = SwapDirSell
+		resolved.CounterSymbol = ii.TargetSymbol
+		resolved.TargetSymbol = inputSym
+	}
+	return &usdConversion{instruction: &resolved, inputMint: inputMint, price: price, age: age}, nil
+}
+
+// currentUSDPrice is mint's USD price from -price-source and how old it is, refused when it's older than
+// -price-max-age.
+func currentUSDPrice(ctx context.Context, mint solana.PublicKey, sym string) (usdPrice, time.Duration, error) {
+	src, err := newPriceSource(priceSourceName)
+	if err != nil {
+		return usdPrice{}, 0, err
+	}
+	quoteCtx, cancel := deadlines.forQuote(ctx)
+	defer cancel()
+	price, err := src.usdPrice(quoteCtx, mint)
+	if err != nil {
+		return usdPrice{}, 0, err
+	}
+	age := max(time.Since(price.PublishedAt), 0)
+	if priceMaxAge > 0 && age > priceMaxAge {
+		return usdPrice{}, 0, fmt.Errorf("%s's %s price is %s old, older than -price-max-age %s", price.Source, sym, age.Round(time.Second), priceMaxAge)
+	}
+	return price, age, nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+// usePythPrice points the pyth source at a Hermes stand in answering with price (8 decimals) published at publishedAt.
+func usePythPrice(t *testing.T, price int64, publishedAt time.Time) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/updates/price/latest" || r.URL.Query().Get("ids[]") != pythFeeds[solana.SolMint] {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"parsed":[{"id":%q,"price":{"price":"%d","conf":"1000","expo":-8,"publish_time":%d}}]}`,
+			pythFeeds[solana.SolMint], price, publishedAt.Unix())
+	}))
+	t.Cleanup(srv.Close)
+	prevURL, prevSource := pythHermesURL, priceSourceName
+	pythHermesURL, priceSourceName = srv.URL, "pyth"
+	t.Cleanup(func() { pythHermesURL, priceSourceName = prevURL, prevSource })
+}
+
+func TestPythPrice(t *testing.T) {
+	at := time.Now().Add(-5 * time.Second).Truncate(time.Second)
+	usePythPrice(t, 14_725_000_000, at)
+	p, err := pythPrices{}.usdPrice(context.Background(), solana.SolMint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Price.Cmp(big.NewRat(589, 4)) != 0 || !p.PublishedAt.Equal(at) || p.Source != "pyth" {
+		t.Errorf("got %v at %v from %s", p.Price.FloatString(4), p.PublishedAt, p.Source)
+	}
+	if _, err := (pythPrices{}).usdPrice(context.Background(), snapshotKey(9)); err == nil || !strings.Contains(err.Error(), "no pyth feed known") {
+		t.Errorf("want a mint without a feed refused, got %v", err)
+	}
+}
+
+func TestJupiterPriceAge(t *testing.T) {
+	mint := solana.SolMint
+	prices := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{%q:{"usdPrice":147.5,"blockId":1000,"decimals":9}}`, r.URL.Query().Get("ids"))
+	}))
+	t.Cleanup(prices.Close)
+	slots := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":1150}`)
+	}))
+	t.Cleanup(slots.Close)
+	prevURL, prevRPC := jupiterPriceURL, mainnetSlotRPC
+	jupiterPriceURL, mainnetSlotRPC = prices.URL, slots.URL
+	t.Cleanup(func() { jupiterPriceURL, mainnetSlotRPC = prevURL, prevRPC })
+
+	src, err := newPriceSource("jupiter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := src.usdPrice(context.Background(), mint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 150 slots behind is about a minute.
+	age := time.Since(p.PublishedAt)
+	if p.Price.Cmp(big.NewRat(295, 2)) != 0 || age < 59*time.Second || age > 61*time.Second {
+		t.Errorf("got %v, %s old", p.Price.FloatString(2), age)
+	}
+}
+
+func TestParseUSDIntent(t *testing.T) {
+	for line, want := range map[string]string{
+		"buy $50 of bonk":             "buy $50 of BONK",
+		"buy $50 BONK":                "buy $50 of BONK",
+		"sell $12.5 of SOL at >= 150": "sell $12.5 of SOL at >= 150",
+		"buy usdc with $20 of sol":    "buy USDC with $20 of SOL",
+	} {
+		ii, err := parseIntent(line)
+		if err != nil {
+			t.Errorf("%q: %v", line, err)
+			continue
+		}
+		if ii.AmountUSD == nil || ii.String() != want {
+			t.Errorf("%q: parsed %+v, String() = %q, want %q", line, ii, ii.String(), want)
+		}
+	}
+	for line, want := range map[string]string{
+		"buy $0 of BONK":             "a dollar amount must be a positive decimal number",
+		"buy $fifty of BONK":         `got "$fifty" at column 5`,
+		"buy $50 of BONK at <= 0.01": "a price limit on a dollar amount only works when paying",
+	} {
+		if _, err := parseIntent(line); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: got %v, want it to contain %q", line, err, want)
+		}
+	}
+}
+
+func TestBuildUSDIntent(t *testing.T) {
+	api, addr := newTestAPIServer(t)
+	usePythPrice(t, 15_000_000_000, time.Now())
+	tb, err := newTableBuilder(context.Background(), api.client, api.pools[addr].loaded, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// At $150 a SOL, $75 is half a SOL.
+	report, intent, err := tb.Build("sell $75 of SOL")
+	if err != nil || intent == nil {
+		t.Fatalf("got intent %v, err %v, report\n%s", intent, err, report)
+	}
+	if intent.SwapKind != SwapKindBaseInput || intent.Amounts.KnownAmount.Cmp(big.NewInt(500_000_000)) != 0 {
+		t.Errorf("sell $75 of SOL quoted %v", intent.Amounts.KnownAmount)
+	}
+	if !strings.Contains(report, "$75 at $150/SOL (pyth, ") || !strings.Contains(report, "old) = 0.500000000 SOL") {
+		t.Errorf("report is missing the conversion\n%s", report)
+	}
+
+	// Buying with dollars spends that much of the other token.
+	_, bought, err := tb.Build("buy $300 of USDC")
+	if err != nil || bought == nil {
+		t.Fatalf("buy $300 of USDC: %v, %v", bought, err)
+	}
+	if bought.SwapKind != SwapKindBaseInput || !bought.TokenIn.Mint.Equals(solana.SolMint) || bought.Amounts.KnownAmount.Cmp(big.NewInt(2_000_000_000)) != 0 {
+		t.Errorf("buy $300 of USDC should pay 2 SOL, got %v of %s", bought.Amounts.KnownAmount, bought.TokenIn.Mint)
+	}
+	if bought.String() != "buy $300 of USDC" {
+		t.Errorf("intent reads %q", bought)
+	}
+
+	// A stale price is refused.
+	usePythPrice(t, 15_000_000_000, time.Now().Add(-2*priceMaxAge))
+	if report, intent, err := tb.Build("sell $75 of SOL"); !errors.As(err, new(*unquotableError)) || intent != nil || !strings.Contains(report, "older than -price-max-age") {
+		t.Errorf("got intent %v, err %v, report\n%s", intent, err, report)
+	}
+}
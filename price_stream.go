@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): Price streams.
+
+`price stream <pool>` prints a JSON line every time the pool's reserves change, for piping into jq, a chart or a bot.
+It's the vault watcher limit orders and the gRPC stream use (watchVaults), accountSubscribe on both vaults with a
+-poll underneath. On every poke both vaults are read in one getMultipleAccounts, so the two reserves are from the same
+slot and the line says which, and a line only goes out when they moved since the last one, a poll that finds nothing
+new prints nothing:
+
+	{"time":"...","slot":312345678,"pool":"...","base":"SOL","quote":"USDC","baseReserve":{...},"quoteReserve":{...},
+	 "midPrice":"151.234561","liquidity":{...}}
+
+Base is the pool's token0 and quote its token1, same as GET /pool's price. The mid-price is quote per base off the
+reserves, no fee and no impact. Liquidity is the pool's depth in the quote token, both sides valued at the mid-price,
+so twice the quote reserve. Reserves are the vault balances, like every quote reads them.
+
+`serve` has it as Server-Sent Events, GET /pool/{address}/stream sends an `event: price` per change with the line as
+its data, until the client goes away or the server shuts down. A read that fails is logged and the stream carries on
+with the next poke, a node hiccup shouldn't end a stream someone is charting off.
+*/
+
+// priceTick is the pool's reserves and price at a slot.
+type priceTick struct {
+	Time         time.Time  `json:"time"`
+	Slot         uint64     `json:"slot"`
+	Pool         string     `json:"pool"`
+	Base         string     `json:"base"`
+	Quote        string     `json:"quote"`
+	BaseReserve  amountJSON `json:"baseReserve"`
+	QuoteReserve amountJSON `json:"quoteReserve"`
+	MidPrice     string     `json:"midPrice,omitempty"` // quote per base, empty while the base side is empty
+	Liquidity    amountJSON `json:"liquidity"`          // in the quote token
+}
+
+// sameReserves is whether t and other saw the pool holding the same.
+func (t priceTick) sameReserves(other priceTick) bool {
+	return t.BaseReserve.Raw == other.BaseReserve.Raw && t.QuoteReserve.Raw == other.QuoteReserve.Raw
+}
+
+// readPriceTick reads both of the pool's vaults in one call and prices the pool off them.
+func readPriceTick(ctx context.Context, client *rpc.Client, loaded *loadedPool) (priceTick, error) {
+	vaults := []solana.PublicKey{loaded.pool.Token0Vault, loaded.pool.Token1Vault}
+	res, err := client.GetMultipleAccountsWithOpts(ctx, vaults, &rpc.GetMultipleAccountsOpts{Encoding: solana.EncodingBase64, Commitment: rpc.CommitmentConfirmed})
+	if err != nil {
+		return priceTick{}, fmt.Errorf("rpc call getMultipleAccounts for the pool's vaults failed: %w", err)
+	}
+	if len(res.Value) != len(vaults) {
+		return priceTick{}, fmt.Errorf("asked for %d vaults, the RPC answered with %d", len(vaults), len(res.Value))
+	}
+	decimals := [2]uint8{loaded.pool.Mint0Decimals, loaded.pool.Mint1Decimals}
+	reserves := make([]*PoolBalance, 2)
+	for i, acc := range res.Value {
+		if acc == nil {
+			return priceTick{}, fmt.Errorf("vault %s doesn't exist", Addr(vaults[i].String()))
+		}
+		data := acc.Data.GetBinary()
+		if len(data) < tokenAccountAmountOffset+8 {
+			return priceTick{}, fmt.Errorf("vault %s isn't a token account", Addr(vaults[i].String()))
+		}
+		amount := binary.LittleEndian.Uint64(data[tokenAccountAmountOffset : tokenAccountAmountOffset+8])
+		reserves[i] = &PoolBalance{Balance: new(big.Int).SetUint64(amount), Decimals: decimals[i]}
+	}
+	symm := loaded.symbolsMap
+	return priceTick{
+		Time:         time.Now().UTC(),
+		Slot:         res.Context.Slot,
+		Pool:         loaded.address.String(),
+		Base:         symm.SymFrom(loaded.pool.Token0Mint),
+		Quote:        symm.SymFrom(loaded.pool.Token1Mint),
+		BaseReserve:  newAmountJSON(reserves[0].Balance, reserves[0].Decimals),
+		QuoteReserve: newAmountJSON(reserves[1].Balance, reserves[1].Decimals),
+		MidPrice:     poolPrice(reserves[0], reserves[1]),
+		Liquidity:    newAmountJSON(new(big.Int).Lsh(reserves[1].Balance, 1), reserves[1].Decimals),
+	}, nil
+}
+
+// streamPrices calls emit with a tick whenever the pool's reserves change, the first one right away, until ctx is done
+// or emit fails.
+func streamPrices(ctx context.Context, client *rpc.Client, loaded *loadedPool, wsEP string, poll time.Duration, emit func(priceTick) error) error {
+	notify := make(chan struct{}, 1)
+	notify <- struct{}{}
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go watchVaults(watchCtx, wsEP, []solana.PublicKey{loaded.pool.Token0Vault, loaded.pool.Token1Vault}, poll, notify)
+	var last *priceTick
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-notify:
+		}
+		readCtx, cancelRead := deadlines.forQuote(ctx)
+		tick, err := readPriceTick(readCtx, client, loaded)
+		cancelRead()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Printf("warning: reading %s's reserves failed, waiting for the next update: %v", Addr(loaded.address.String()), err)
+			continue
+		}
+		if last != nil && tick.sameReserves(*last) {
+			continue
+		}
+		last = &tick
+		if err := emit(tick); err != nil {
+			return err
+		}
+	}
+}
+
+// handlePoolStream is streamPrices as Server-Sent Events.
+func (s *apiServer) handlePoolStream(w http.ResponseWriter, r *http.Request) {
+	loaded, err := s.pool(r.Context(), r.PathValue("address"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, errors.New("the connection can't stream"))
+		return
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	if s.streams != nil {
+		defer context.AfterFunc(s.streams, cancel)()
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	err = streamPrices(ctx, s.client, loaded, s.wsEP, s.poll, func(tick priceTick) error {
+		raw, err := json.Marshal(tick)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "event: price\ndata: %s\n\n", raw); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+	if err != nil && ctx.Err() == nil {
+		log.Printf("price stream for %s ended: %v", Addr(loaded.address.String()), err)
+	}
+}
+
+func runPriceCommand(args []string) error {
+	return dispatchSubcommand("price", map[string]func([]string) error{
+		"stream": runPriceStreamCommand,
+	}, args)
+}
+
+func runPriceStreamCommand(args []string) error {
+	fs := flag.NewFlagSet("price stream", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: raydium-client price stream [flags] <pool>\n")
+		fs.PrintDefaults()
+	}
+	nf := addNetworkFlags(fs)
+	var (
+		wsEP = fs.String("ws", "", "WebSocket endpoint for reserve updates, derived from -rpc when empty")
+		poll = fs.Duration("poll", 15*time.Second, "Read the reserves at least this often, even without updates")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, nf.specs())
+	if fs.NArg() != 1 {
+		return errors.New("price stream takes the pool, an address or a pair like SOL/USDC")
+	}
+	if *poll <= 0 {
+		return errors.New("poll must be greater than zero")
+	}
+	client := nf.connect()
+	if *wsEP == "" {
+		*wsEP = wsEndpointFor(*nf.rpcEP)
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	poolPubK, err := resolvePoolTarget(ctx, client, fs.Arg(0), SymbolMapping{})
+	if err != nil {
+		return err
+	}
+	loaded, err := loadPool(ctx, client, poolPubK)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	return streamPrices(ctx, client, loaded, *wsEP, *poll, func(tick priceTick) error {
+		return enc.Encode(tick)
+	})
+}
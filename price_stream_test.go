@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// priceChain is a node holding snapshotPool with its vaults holding base and quote.
+func priceChain(t *testing.T, base, quote uint64) *httptest.Server {
+	t.Helper()
+	pool, addr, _ := snapshotPool()
+	pool.Mint0Decimals, pool.Mint1Decimals = 9, 6
+	return chainServer(t, map[solana.PublicKey]chainAccount{
+		addr:             {owner: raydium_cp_swap.ProgramID, data: anchorAccount(t, raydium_cp_swap.Account_PoolState, *pool)},
+		pool.AmmConfig:   {owner: raydium_cp_swap.ProgramID, data: anchorAccount(t, raydium_cp_swap.Account_AmmConfig, raydium_cp_swap.AmmConfig{TradeFeeRate: 2500})},
+		pool.Token0Mint:  {owner: solana.TokenProgramID, data: mintData(10_000_000_000_000, 9, nil, nil)},
+		pool.Token1Mint:  {owner: solana.TokenProgramID, data: mintData(80_000_000_000_000, 6, nil, nil)},
+		pool.Token0Vault: {owner: solana.TokenProgramID, data: tokenAccountData(pool.Token0Mint, base)},
+		pool.Token1Vault: {owner: solana.TokenProgramID, data: tokenAccountData(pool.Token1Mint, quote)},
+	}, nil, &atomic.Int64{})
+}
+
+func TestReadPriceTick(t *testing.T) {
+	client := rpc.New(priceChain(t, 1_000_000_000_000, 150_000_000_000).URL)
+	_, addr, _ := snapshotPool()
+	loaded, err := loadPool(context.Background(), client, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tick, err := readPriceTick(context.Background(), client, loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tick.Slot != 1 || tick.Pool != addr.String() || tick.Base != "SOL" || tick.Quote != "USDC" {
+		t.Errorf("tick %+v", tick)
+	}
+	if tick.BaseReserve.Display != "1000.000000000" || tick.QuoteReserve.Display != "150000.000000" || tick.MidPrice != "150.000000" || tick.Liquidity.Display != "300000.000000" {
+		t.Errorf("reserves %s and %s, price %s, liquidity %s", tick.BaseReserve.Display, tick.QuoteReserve.Display, tick.MidPrice, tick.Liquidity.Display)
+	}
+}
+
+func TestStreamPricesOnlyOnChange(t *testing.T) {
+	before, after := priceChain(t, 1_000_000_000_000, 150_000_000_000), priceChain(t, 999_000_000_000, 150_150_150_150)
+	beforeURL, _ := url.Parse(before.URL)
+	afterURL, _ := url.Parse(after.URL)
+	var target atomic.Pointer[url.URL]
+	target.Store(beforeURL)
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httputil.NewSingleHostReverseProxy(target.Load()).ServeHTTP(w, r)
+	}))
+	defer node.Close()
+	client := rpc.New(node.URL)
+	_, addr, _ := snapshotPool()
+	loaded, err := loadPool(context.Background(), client, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var ticks []priceTick
+	err = streamPrices(ctx, client, loaded, "", 10*time.Millisecond, func(tick priceTick) error {
+		ticks = append(ticks, tick)
+		if len(ticks) == 1 {
+			// A few polls that find the same reserves go by before they move.
+			time.AfterFunc(100*time.Millisecond, func() { target.Store(afterURL) })
+		}
+		if len(ticks) == 2 {
+			cancel()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ticks) != 2 {
+		t.Fatalf("%d ticks, want one per reserve change", len(ticks))
+	}
+	if ticks[0].BaseReserve.Raw != "1000000000000" || ticks[1].BaseReserve.Raw != "999000000000" || ticks[0].sameReserves(ticks[1]) {
+		t.Errorf("ticks %+v", ticks)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+/*
+NOTE(@hadydotai): Program errors.
+
+A swap that fails comes back as `{"InstructionError":[3,{"Custom":6005}]}` from confirmation, or as a simulation
+failure from sendTransaction whose logs end in `custom program error: 0x1775`. Either way it's a number, and which
+table it belongs to depends on the program that raised it: 6005 from cp-swap isn't 6005 from some other Anchor
+program, 1 from the token program isn't 1 from anyone else. The logs say which program failed (the innermost
+`Program <id> failed:` line, see failingProgram), and when it's an Anchor program they usually say a lot more:
+
+  Program log: AnchorError thrown in programs/cp-swap/src/instructions/swap_base_input.rs:153. Error Code:
+  ExceededSlippage. Error Number: 6005. Error Message: Exceeds desired slippage limit.
+
+decodeProgramFailure puts those together and names the error, what it means for someone swapping, and when there's
+something to do about it, what. Anchor's own errors (the account and constraint checks, below 6000) come out of the
+same log line, those mean an account passed in isn't the one the program expects, and the fix is on our side or the
+pool's, not the user's. Without logs a custom code of 6000 or more is taken to be cp-swap's, the only Anchor program
+our own transactions call.
+
+`why <signature>` goes further for a landed swap, it replays the quote (see why.go). This is what every failed send
+and every failed confirmation says on the spot.
+*/
+
+// programErrorInfo is a program's error code, named, with what it means and what to do about it.
+type programErrorInfo struct {
+	name    string
+	meaning string
+	hint    string // empty when there's nothing to do but read the meaning
+}
+
+// cpSwapErrorNames are all of cp-swap's custom errors, from the IDL, with what they mean for someone swapping.
+var cpSwapErrorNames = map[uint64]programErrorInfo{
+	6000: {"NotApproved", "the pool doesn't allow this right now, swapping is paused or the pool isn't open yet",
+		"check the pool with `monitor pool`, or trade on another pool for the pair with -best or -fallback-pools"},
+	6001: {"InvalidOwner", "an account isn't owned by who it should be",
+		"make sure -hotwallet is the wallet that holds the tokens"},
+	6002: {"EmptySupply", "the pool has no LP supply", "the pool is empty, pick another pool for the pair (-compare)"},
+	6003: {"InvalidInput", "the instruction's input is invalid", "quote again, a zero amount or a stale quote can't be swapped"},
+	6004: {"IncorrectLpMint", "the LP mint doesn't belong to the pool", ""},
+	6005: {"ExceededSlippage", "the swap would have gone past its slippage guard",
+		"the price moved between the quote and the swap, quote again, raise -slippage, or trade less at a time (-chunk-above)"},
+	6006: {"ZeroTradingTokens", "the amount is too small to produce anything on the other side", "trade a larger amount"},
+	6007: {"NotSupportMint", "one of the mints uses a token extension the pool doesn't support",
+		"this pool can't trade the token, pick another pool for the pair"},
+	6008: {"InvalidVault", "a vault passed in isn't the pool's",
+		"the pool's accounts don't match what was sent, make sure -network is the pool's and load it again"},
+	6009: {"InitLpAmountTooLess", "the initial liquidity is too small", ""},
+	6010: {"TransferFeeCalculateNotMatch", "the token's transfer fee doesn't add up",
+		"the token's transfer fee may have changed since the quote, quote again"},
+	6011: {"MathOverflow", "the swap math overflowed", "the amount is too large for the pool, trade less"},
+	6012: {"InsufficientVault", "the pool vault doesn't hold enough to pay out",
+		"trade less, or use a deeper pool for the pair (-compare)"},
+	6013: {"InvalidFeeModel", "the pool's fee configuration is invalid", ""},
+	6014: {"NoFeeCollect", "there are no fees to collect", ""},
+}
+
+// tokenProgramErrorNames are the SPL Token (and Token-2022) errors a swap can run into.
+var tokenProgramErrorNames = map[uint64]programErrorInfo{
+	0: {"NotRentExempt", "a token account would drop below rent exemption", "keep a little more SOL in the wallet"},
+	1: {"InsufficientFunds", "a token account didn't hold enough for the transfer",
+		"the wallet doesn't hold enough of the token to pay, check its balance or trade less"},
+	2: {"InvalidMint", "a mint is invalid", ""},
+	3: {"MintMismatch", "a token account belongs to a different mint", ""},
+	4: {"OwnerMismatch", "a token account belongs to a different owner", "make sure -hotwallet is the wallet that holds the tokens"},
+	9: {"UninitializedState", "a token account isn't initialized", ""},
+	17: {"AccountFrozen", "a token account is frozen",
+		"the token's freeze authority froze the account, it can't move until it's thawed"},
+}
+
+// anchorAccountHint is what to do about any of Anchor's account checks failing, they all come down to the same thing.
+const anchorAccountHint = "make sure -network is the pool's and load the pool again"
+
+// anchorFrameworkErrors are the errors Anchor raises itself, before the program's own code runs.
+var anchorFrameworkErrors = map[uint64]programErrorInfo{
+	100:  {"InstructionMissing", "the instruction has no discriminator", ""},
+	101:  {"InstructionFallbackNotFound", "the program doesn't know the instruction", "the program ID for -network is likely wrong"},
+	102:  {"InstructionDidNotDeserialize", "the program couldn't read the instruction's arguments", ""},
+	2000: {"ConstraintMut", "an account that has to be writable isn't", anchorAccountHint},
+	2001: {"ConstraintHasOne", "an account doesn't belong to the pool", anchorAccountHint},
+	2002: {"ConstraintSigner", "an account that has to sign didn't", ""},
+	2003: {"ConstraintRaw", "an account failed one of the program's checks", anchorAccountHint},
+	2004: {"ConstraintOwner", "an account is owned by the wrong program", anchorAccountHint},
+	2006: {"ConstraintSeeds", "an account isn't at the address its seeds derive", anchorAccountHint},
+	2012: {"ConstraintAddress", "an account isn't at the address the program expects", anchorAccountHint},
+	2014: {"ConstraintTokenMint", "a token account belongs to a different mint", anchorAccountHint},
+	2015: {"ConstraintTokenOwner", "a token account belongs to a different owner", "make sure -hotwallet is the wallet that holds the tokens"},
+	3001: {"AccountDiscriminatorNotFound", "an account has no data where the program expects one of its own", anchorAccountHint},
+	3002: {"AccountDiscriminatorMismatch", "an account is a different kind of account than the program expects", anchorAccountHint},
+	3003: {"AccountDidNotDeserialize", "the program couldn't read an account", anchorAccountHint},
+	3005: {"AccountNotEnoughKeys", "the instruction is missing accounts", ""},
+	3006: {"AccountNotMutable", "an account that has to be writable isn't", anchorAccountHint},
+	3007: {"AccountOwnedByWrongProgram", "an account is owned by the wrong program", anchorAccountHint},
+	3008: {"InvalidProgramId", "a program passed in isn't the one expected", "the program ID for -network is likely wrong"},
+	3012: {"AccountNotInitialized", "an account the program expects doesn't exist yet", anchorAccountHint},
+}
+
+var anchorErrorRe = regexp.MustCompile(`AnchorError (?:thrown in \S+?|caused by account: (\S+?)|occurred)\. Error Code: (\w+)\. Error Number: (\d+)\. Error Message: (.*?)\.?$`)
+
+// anchorLogError is the `AnchorError ...` line of a failure's logs.
+type anchorLogError struct {
+	account string // the account the error was raised on, when Anchor says
+	name    string
+	code    uint64
+	message string
+}
+
+func parseAnchorError(logs []string) (anchorLogError, bool) {
+	for _, line := range logs {
+		m := anchorErrorRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		code, err := strconv.ParseUint(m[3], 10, 32)
+		if err != nil {
+			continue
+		}
+		return anchorLogError{account: m[1], name: m[2], code: code, message: m[4]}, true
+	}
+	return anchorLogError{}, false
+}
+
+// programFailure is a failed instruction's error, decoded.
+type programFailure struct {
+	program string // "cp-swap", "token program", or the program's address
+	code    uint64
+	info    programErrorInfo
+	account string
+}
+
+func (f programFailure) String() string {
+	s := fmt.Sprintf("%s error %d", f.program, f.code)
+	if f.info.name != "" {
+		s += " " + f.info.name
+	}
+	if f.account != "" {
+		s += " on account " + f.account
+	}
+	if f.info.meaning != "" {
+		s += ": " + f.info.meaning
+	}
+	if f.info.hint != "" {
+		s += ", " + f.info.hint
+	}
+	return s
+}
+
+// decodeProgramFailure names the custom error in txErr (a transaction error as the chain reports it) with the help of
+// the failure's logs, either may be missing. It's false when there's no program error to name, a runtime failure
+// like running out of compute isn't one.
+func decodeProgramFailure(txErr any, logs []string) (programFailure, bool) {
+	failure := parseTxFailure(txErr)
+	anchorErr, fromAnchor := parseAnchorError(logs)
+	if !failure.hasCustom && !fromAnchor {
+		return programFailure{}, false
+	}
+	code := failure.custom
+	if fromAnchor {
+		code = anchorErr.code
+	}
+	program, known := failingProgram(logs)
+	if !known && code >= 6000 {
+		program, known = raydium_cp_swap.ProgramID, true
+	}
+	f := programFailure{program: "program", code: code, account: anchorErr.account}
+	var names map[uint64]programErrorInfo
+	switch {
+	case !known:
+	case program.Equals(raydium_cp_swap.ProgramID):
+		f.program, names = "cp-swap", cpSwapErrorNames
+	case program.Equals(solana.TokenProgramID), program.Equals(solana.Token2022ProgramID):
+		f.program, names = "token program", tokenProgramErrorNames
+	default:
+		f.program = "program " + program.String()
+	}
+	if code < 6000 && fromAnchor {
+		names = anchorFrameworkErrors
+	}
+	if info, ok := names[code]; ok {
+		f.info = info
+	} else if fromAnchor && anchorErr.message != "" {
+		f.info = programErrorInfo{name: anchorErr.name, meaning: strings.ToLower(anchorErr.message[:1]) + anchorErr.message[1:]}
+	}
+	return f, true
+}
+
+// decodeSendFailure is decodeProgramFailure for a send the RPC refused because simulating it failed, the simulation's
+// error and logs ride along in the RPC error's data.
+func decodeSendFailure(err error) (programFailure, bool) {
+	var rpcErr *jsonrpc.RPCError
+	if !errors.As(err, &rpcErr) {
+		return programFailure{}, false
+	}
+	data, ok := rpcErr.Data.(map[string]any)
+	if !ok {
+		return programFailure{}, false
+	}
+	var logs []string
+	if raw, ok := data["logs"].([]any); ok {
+		for _, line := range raw {
+			if s, ok := line.(string); ok {
+				logs = append(logs, s)
+			}
+		}
+	}
+	return decodeProgramFailure(data["err"], logs)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+func txErrJSON(t *testing.T, raw string) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestDecodeProgramFailure(t *testing.T) {
+	cpSwap, token := raydium_cp_swap.ProgramID.String(), solana.TokenProgramID.String()
+	other := solana.MustPublicKeyFromBase58("JUP6LkbZbjS1jKKwapdHNy74zcZ3tLUZoi5QNyVTaV4").String()
+	for _, tc := range []struct {
+		name  string
+		txErr string
+		logs  []string
+		want  []string // in the decoded string, in order
+	}{
+		{
+			name:  "slippage from the anchor log",
+			txErr: `{"InstructionError":[3,{"Custom":6005}]}`,
+			logs: []string{
+				"Program " + cpSwap + " invoke [1]",
+				"Program log: AnchorError thrown in programs/cp-swap/src/instructions/swap_base_input.rs:153. Error Code: ExceededSlippage. Error Number: 6005. Error Message: Exceeds desired slippage limit.",
+				"Program " + cpSwap + " failed: custom program error: 0x1775",
+			},
+			want: []string{"cp-swap error 6005 ExceededSlippage", "slippage guard", "raise -slippage"},
+		},
+		{
+			name:  "no logs",
+			txErr: `{"InstructionError":[2,{"Custom":6000}]}`,
+			want:  []string{"cp-swap error 6000 NotApproved", "monitor pool"},
+		},
+		{
+			name:  "token program underneath",
+			txErr: `{"InstructionError":[3,{"Custom":1}]}`,
+			logs: []string{
+				"Program " + cpSwap + " invoke [1]",
+				"Program " + token + " invoke [2]",
+				"Program log: Error: insufficient funds",
+				"Program " + token + " failed: custom program error: 0x1",
+				"Program " + cpSwap + " failed: custom program error: 0x1",
+			},
+			want: []string{"token program error 1 InsufficientFunds", "check its balance"},
+		},
+		{
+			name:  "anchor account check",
+			txErr: `{"InstructionError":[3,{"Custom":2006}]}`,
+			logs: []string{
+				"Program " + cpSwap + " invoke [1]",
+				"Program log: AnchorError caused by account: authority. Error Code: ConstraintSeeds. Error Number: 2006. Error Message: A seeds constraint was violated.",
+				"Program " + cpSwap + " failed: custom program error: 0x7d6",
+			},
+			want: []string{"cp-swap error 2006 ConstraintSeeds on account authority", "-network"},
+		},
+		{
+			name:  "someone else's anchor program",
+			txErr: `{"InstructionError":[4,{"Custom":6001}]}`,
+			logs: []string{
+				"Program " + other + " invoke [1]",
+				"Program log: AnchorError occurred. Error Code: SlippageToleranceExceeded. Error Number: 6001. Error Message: Slippage tolerance exceeded.",
+				"Program " + other + " failed: custom program error: 0x1771",
+			},
+			want: []string{"program " + other + " error 6001 SlippageToleranceExceeded: slippage tolerance exceeded"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, ok := decodeProgramFailure(txErrJSON(t, tc.txErr), tc.logs)
+			if !ok {
+				t.Fatal("nothing decoded")
+			}
+			got, at := f.String(), 0
+			for _, w := range tc.want {
+				i := strings.Index(got[at:], w)
+				if i < 0 {
+					t.Fatalf("%q doesn't have %q after position %d", got, w, at)
+				}
+				at += i + len(w)
+			}
+		})
+	}
+}
+
+func TestDecodeProgramFailureRuntime(t *testing.T) {
+	for _, txErr := range []string{`"InsufficientFundsForFee"`, `{"InstructionError":[0,"ComputationalBudgetExceeded"]}`} {
+		if f, ok := decodeProgramFailure(txErrJSON(t, txErr), nil); ok {
+			t.Errorf("%s decoded as %s", txErr, f)
+		}
+	}
+}
+
+func TestDecodeSendFailure(t *testing.T) {
+	rpcErr := &jsonrpc.RPCError{
+		Code:    -32002,
+		Message: "Transaction simulation failed: Error processing Instruction 3: custom program error: 0x1775",
+		Data: map[string]any{
+			"err": txErrJSON(t, `{"InstructionError":[3,{"Custom":6005}]}`),
+			"logs": []any{
+				"Program " + raydium_cp_swap.ProgramID.String() + " invoke [1]",
+				"Program " + raydium_cp_swap.ProgramID.String() + " failed: custom program error: 0x1775",
+			},
+		},
+	}
+	f, ok := decodeSendFailure(fmt.Errorf("wrapped: %w", rpcErr))
+	if !ok || f.info.name != "ExceededSlippage" {
+		t.Fatalf("decodeSendFailure = %+v, %v", f, ok)
+	}
+	if _, ok := decodeSendFailure(fmt.Errorf("connection reset")); ok {
+		t.Error("decoded an error that isn't the RPC's")
+	}
+}
+
+func TestTxFailedErrorNamesTheError(t *testing.T) {
+	err := &txFailedError{sig: solana.Signature{1}, txErr: txErrJSON(t, `{"InstructionError":[3,{"Custom":6012}]}`)}
+	if !strings.Contains(err.Error(), "InsufficientVault") {
+		t.Errorf("error reads %q", err)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+/*
+NOTE(@hadydotai): Program deployments.
+
+raydium_cp_swap.ProgramID is a variable in the generated bindings, every PDA we derive (authority, vaults, configs,
+observations) and every account we check the owner of goes through it. -network sets it from the networks table, that
+covers Raydium's own mainnet and devnet deployments. A fork, a deployment of your own on devnet, or a local validator
+with the program loaded under a different address needs -cp-program-id, it wins over the table. The pool index is
+keyed by program, an index built against another deployment is ignored.
+
+The table also knows where Raydium's CLMM and AMM v4 programs live on each cluster. Nothing here trades on them (yet,
+see venue.go), but a pool address that belongs to one of them gets named as such instead of "isn't a CP-Swap pool".
+*/
+
+// cpProgramOverride is set by -cp-program-id, the zero key leaves the network's deployment.
+var cpProgramOverride solana.PublicKey
+
+func addProgramFlags(fs *flag.FlagSet) {
+	fs.Func("cp-program-id", "Raydium CP-Swap program to use instead of the -network's deployment, for forks and local validators", func(s string) error {
+		key, err := solana.PublicKeyFromBase58(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("invalid program id %q: %w", s, err)
+		}
+		cpProgramOverride = key
+		return nil
+	})
+}
+
+// useNetworkPrograms points the generated bindings at network's CP-Swap deployment, or at -cp-program-id.
+func useNetworkPrograms(network string) {
+	raydium_cp_swap.ProgramID = networks[network][RaydiumProgramID].(solana.PublicKey)
+	if !cpProgramOverride.IsZero() {
+		raydium_cp_swap.ProgramID = cpProgramOverride
+	}
+}
+
+// otherRaydiumProgram names the Raydium program other than CP-Swap that owner is on network, empty when it isn't one.
+func otherRaydiumProgram(network string, owner solana.PublicKey) string {
+	for _, program := range []struct {
+		entry int
+		name  string
+	}{
+		{RaydiumCLMMProgramID, "CLMM"},
+		{RaydiumAMMv4ProgramID, "AMM v4"},
+	} {
+		if key, ok := networks[network][program.entry].(solana.PublicKey); ok && key.Equals(owner) {
+			return program.name
+		}
+	}
+	return ""
+}
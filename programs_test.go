@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestCPProgramOverride(t *testing.T) {
+	defer func(saved solana.PublicKey) { raydium_cp_swap.ProgramID = saved }(raydium_cp_swap.ProgramID)
+	t.Cleanup(func() { cpProgramOverride = solana.PublicKey{} })
+
+	useNetworkPrograms("mainnet")
+	if want := networks["mainnet"][RaydiumProgramID].(solana.PublicKey); !raydium_cp_swap.ProgramID.Equals(want) {
+		t.Errorf("mainnet's program is %s, want %s", raydium_cp_swap.ProgramID, want)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	addProgramFlags(fs)
+	if err := fs.Parse([]string{"-cp-program-id", "nope"}); err == nil {
+		t.Error("a program id that isn't a key was taken")
+	}
+	fork := snapshotKey(9)
+	if err := fs.Parse([]string{"-cp-program-id", fork.String()}); err != nil {
+		t.Fatal(err)
+	}
+	useNetworkPrograms("devnet")
+	if !raydium_cp_swap.ProgramID.Equals(fork) {
+		t.Errorf("the override didn't take, the program is %s", raydium_cp_swap.ProgramID)
+	}
+}
+
+func TestFetchPoolStateOtherRaydiumProgram(t *testing.T) {
+	t.Cleanup(func() { connectedCluster = "devnet" })
+	connectedCluster = "mainnet"
+	clmmPool := snapshotKey(3)
+	client := transferRPC(t, map[solana.PublicKey]*rpc.Account{
+		clmmPool: {Owner: networks["mainnet"][RaydiumCLMMProgramID].(solana.PublicKey), Data: rpc.DataBytesOrJSONFromBytes(make([]byte, 8))},
+	}, nil)
+	if _, err := fetchPoolState(context.Background(), client, clmmPool); err == nil || !strings.Contains(err.Error(), "is a Raydium CLMM pool") {
+		t.Errorf("a CLMM pool: %v", err)
+	}
+	if got := otherRaydiumProgram("devnet", networks["devnet"][RaydiumAMMv4ProgramID].(solana.PublicKey)); got != "AMM v4" {
+		t.Errorf("devnet's AMM v4 is %q", got)
+	}
+	if got := otherRaydiumProgram("mainnet", solana.TokenProgramID); got != "" {
+		t.Errorf("the token program is %q", got)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"math/big"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): Quorum reads.
+
+A quote is only as good as the reserves it's worked out from, and those come from whatever -rpc says. An RPC that's
+lagging behind the cluster hands out stale reserves, one that's broken or hostile can hand out anything, and nothing in
+a single answer tells them apart from the real thing. -quorum-rpc adds endpoints to ask alongside -rpc: the pool state
+and both vaults are read from every one of them at once, in a single getMultipleAccounts so each answer is one slot's
+view, and the answers are compared byte for byte.
+
+Answers that match are one view, seen as of the newest slot any of them answered at. A view needs -quorum endpoints
+behind it (a majority by default), and of the views that have that, the freshest one is quoted. There's no quote when
+none does, reserves the endpoints can't agree on aren't reserves we want to trade against. Every endpoint that didn't
+back the quoted view is named in the report's Quorum row: one that failed, one that's behind (its view is older, which
+is usually just lag) and one that disagrees at the same slot or later, which is the one to worry about.
+
+Endpoints are shown by host only, RPC URLs tend to carry API keys in their path or query.
+*/
+
+// rpcQuorumConfig is what -quorum-rpc and -quorum set, quorum reads are off until an endpoint is added.
+type rpcQuorumConfig struct {
+	endpoints []string // hosts, for the report
+	urls      []string
+	clients   []*rpc.Client
+	size      int // 0 for a majority
+}
+
+var rpcQuorum = &rpcQuorumConfig{}
+
+func addQuorumFlags(fs *flag.FlagSet) {
+	fs.Func("quorum-rpc", "Also read pool state and vault balances from this RPC and only quote what enough of them agree on, repeatable or comma separated", func(s string) error {
+		for _, ep := range strings.Split(s, ",") {
+			ep = strings.TrimSpace(ep)
+			if ep == "" {
+				continue
+			}
+			u, err := url.Parse(ep)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("%q isn't an http(s) RPC endpoint", ep)
+			}
+			rpcQuorum.endpoints = append(rpcQuorum.endpoints, u.Host)
+			rpcQuorum.urls = append(rpcQuorum.urls, ep)
+			rpcQuorum.clients = append(rpcQuorum.clients, rpc.New(ep))
+		}
+		return nil
+	})
+	fs.IntVar(&rpcQuorum.size, "quorum", 0, "How many RPCs, -rpc included, have to agree on the reserves with -quorum-rpc, a majority when 0")
+}
+
+func (q *rpcQuorumConfig) enabled() bool {
+	return len(q.clients) > 0
+}
+
+// need is how many of n endpoints have to agree.
+func (q *rpcQuorumConfig) need(n int) int {
+	if q.size > 0 {
+		return q.size
+	}
+	return n/2 + 1
+}
+
+// quorumAnswer is one endpoint's read of the accounts, data is nil for an account it doesn't have.
+type quorumAnswer struct {
+	endpoint string
+	slot     uint64
+	data     [][]byte
+	err      error
+}
+
+func (a *quorumAnswer) sameData(b *quorumAnswer) bool {
+	for i := range a.data {
+		if (a.data[i] == nil) != (b.data[i] == nil) || !bytes.Equal(a.data[i], b.data[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// quorumView is what the quorum agreed on, and who didn't go along with it.
+type quorumView struct {
+	slot      uint64
+	data      [][]byte
+	agreed    int
+	asked     int
+	divergent []string
+}
+
+func (v *quorumView) String() string {
+	s := fmt.Sprintf("%d of %d RPCs agree at slot %d", v.agreed, v.asked, v.slot)
+	if len(v.divergent) > 0 {
+		s += ", " + strings.Join(v.divergent, "; ")
+	}
+	return s
+}
+
+// read reads keys from primary (-rpc) and every quorum endpoint and returns the freshest view enough of them agree on.
+func (q *rpcQuorumConfig) read(ctx context.Context, primary *rpc.Client, keys []solana.PublicKey) (*quorumView, error) {
+	clients := append([]*rpc.Client{primary}, q.clients...)
+	names := append([]string{"-rpc"}, q.endpoints...)
+	need := q.need(len(clients))
+	if need > len(clients) {
+		return nil, fmt.Errorf("-quorum %d needs more RPCs than the %d there are", need, len(clients))
+	}
+	answers := make([]quorumAnswer, len(clients))
+	wg := sync.WaitGroup{}
+	for i, client := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			answers[i] = readQuorumAnswer(ctx, client, names[i], keys)
+		}()
+	}
+	wg.Wait()
+	return agreeOn(answers, need)
+}
+
+func readQuorumAnswer(ctx context.Context, client *rpc.Client, name string, keys []solana.PublicKey) quorumAnswer {
+	answer := quorumAnswer{endpoint: name}
+	res, err := client.GetMultipleAccountsWithOpts(ctx, keys, &rpc.GetMultipleAccountsOpts{
+		Encoding:   solana.EncodingBase64,
+		Commitment: rpc.CommitmentFinalized,
+	})
+	switch {
+	case err != nil:
+		answer.err = err
+	case res == nil || len(res.Value) != len(keys):
+		answer.err = fmt.Errorf("asked for %d accounts, got an answer for some other number", len(keys))
+	default:
+		answer.slot = res.Context.Slot
+		answer.data = make([][]byte, len(keys))
+		for i, acc := range res.Value {
+			if acc != nil {
+				answer.data[i] = acc.Data.GetBinary()
+			}
+		}
+	}
+	return answer
+}
+
+// agreeOn groups answers with the same data and picks the freshest group of at least need, see the note at the top.
+func agreeOn(answers []quorumAnswer, need int) (*quorumView, error) {
+	type group struct {
+		members []int
+		slot    uint64
+	}
+	var groups []*group
+	for i := range answers {
+		if answers[i].err != nil {
+			continue
+		}
+		var g *group
+		for _, candidate := range groups {
+			if answers[candidate.members[0]].sameData(&answers[i]) {
+				g = candidate
+				break
+			}
+		}
+		if g == nil {
+			g = &group{}
+			groups = append(groups, g)
+		}
+		g.members = append(g.members, i)
+		g.slot = max(g.slot, answers[i].slot)
+	}
+	sort.SliceStable(groups, func(a, b int) bool {
+		if groups[a].slot != groups[b].slot {
+			return groups[a].slot > groups[b].slot
+		}
+		return len(groups[a].members) > len(groups[b].members)
+	})
+	var chosen *group
+	for _, g := range groups {
+		if len(g.members) >= need {
+			chosen = g
+			break
+		}
+	}
+	if chosen == nil {
+		var what []string
+		for _, a := range answers {
+			if a.err != nil {
+				what = append(what, fmt.Sprintf("%s failed: %v", a.endpoint, a.err))
+			} else {
+				what = append(what, fmt.Sprintf("%s at slot %d", a.endpoint, a.slot))
+			}
+		}
+		return nil, fmt.Errorf("no %d of %d RPCs agree on the pool's accounts (%s)", need, len(answers), strings.Join(what, ", "))
+	}
+
+	view := &quorumView{slot: chosen.slot, data: answers[chosen.members[0]].data, agreed: len(chosen.members), asked: len(answers)}
+	agreed := make(map[int]bool, len(chosen.members))
+	for _, i := range chosen.members {
+		agreed[i] = true
+	}
+	for i, a := range answers {
+		switch {
+		case agreed[i]:
+		case a.err != nil:
+			view.divergent = append(view.divergent, fmt.Sprintf("%s failed", a.endpoint))
+		case a.slot < view.slot:
+			view.divergent = append(view.divergent, fmt.Sprintf("%s is %d slots behind", a.endpoint, view.slot-a.slot))
+		default:
+			view.divergent = append(view.divergent, fmt.Sprintf("%s disagrees at slot %d", a.endpoint, a.slot))
+		}
+	}
+	return view, nil
+}
+
+// quorumReserves is poolReserves read through the quorum: the pool's state and vaults as the quorum has them. The
+// freshest pool state is checked again for whether it's still tradable and still has the vaults the pool loaded with.
+//
+// Returns two slices of length 2, like poolBalances, and the view the quorum agreed on, nil when it didn't.
+func quorumReserves(ctx context.Context, client *rpc.Client, address solana.PublicKey, pool *raydium_cp_swap.PoolState, mints [2]*mintAccount) ([]*PoolBalance, []error, *quorumView) {
+	balances, errs := make([]*PoolBalance, 2), make([]error, 2)
+	fail := func(err error) ([]*PoolBalance, []error, *quorumView) {
+		errs[0], errs[1] = err, err
+		return balances, errs, nil
+	}
+	view, err := rpcQuorum.read(ctx, client, []solana.PublicKey{address, pool.Token0Vault, pool.Token1Vault})
+	if err != nil {
+		return fail(classify(ErrRPCUnavailable, err))
+	}
+	if view.data[0] == nil {
+		return fail(classify(ErrAccountMissing, fmt.Errorf("the RPCs agree pool %s doesn't exist", Addr(address.String()))))
+	}
+	state, err := raydium_cp_swap.ParseAccount_PoolState(view.data[0])
+	if err != nil {
+		return fail(fmt.Errorf("parsing the pool state the RPCs agree on failed: %w", err))
+	}
+	if !state.Token0Vault.Equals(pool.Token0Vault) || !state.Token1Vault.Equals(pool.Token1Vault) {
+		return fail(fmt.Errorf("pool %s has other vaults than when it loaded, load it again", Addr(address.String())))
+	}
+	if pf := checkPoolTradable(address, state, time.Now()); pf != nil {
+		return fail(pf)
+	}
+	poolMints := [2]solana.PublicKey{state.Token0Mint, state.Token1Mint}
+	decimals := [2]uint8{state.Mint0Decimals, state.Mint1Decimals}
+	for i := range balances {
+		data := view.data[i+1]
+		if len(data) < baseAccountLen || !solana.PublicKeyFromBytes(data[:32]).Equals(poolMints[i]) {
+			errs[i] = fmt.Errorf("vault %d isn't a token account of %s", i, Addr(poolMints[i].String()))
+			continue
+		}
+		if mints[i] != nil && mints[i].Decimals != decimals[i] {
+			errs[i] = fmt.Errorf("the pool says %d decimals but mint %s has %d, the RPCs may be serving bad data",
+				decimals[i], Addr(poolMints[i].String()), mints[i].Decimals)
+			continue
+		}
+		amount := binary.LittleEndian.Uint64(data[tokenAccountAmountOffset : tokenAccountAmountOffset+8])
+		balances[i] = &PoolBalance{Balance: new(big.Int).SetUint64(amount), Decimals: decimals[i]}
+	}
+	return balances, errs, view
+}
+
+// readReserves is venue.Reserves, read through the quorum when -quorum-rpc is on. The view is nil without one.
+func readReserves(ctx context.Context, client *rpc.Client, venue Venue) ([]*PoolBalance, []error, *quorumView) {
+	if cp, ok := venue.(*cpSwapVenue); ok && rpcQuorum.enabled() {
+		return quorumReserves(ctx, client, cp.address, cp.pool, cp.mints)
+	}
+	balances, errs := venue.Reserves(ctx, client)
+	return balances, errs, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// quorumNode is an RPC answering getMultipleAccounts from accounts as of slot.
+func quorumNode(t *testing.T, slot uint64, accounts map[solana.PublicKey][]byte) *rpc.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Params []json.RawMessage `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		var keys []string
+		json.Unmarshal(req.Params[0], &keys)
+		values := make([]string, len(keys))
+		for i, key := range keys {
+			data, ok := accounts[solana.MustPublicKeyFromBase58(key)]
+			if !ok {
+				values[i] = "null"
+				continue
+			}
+			values[i] = fmt.Sprintf(`{"data":[%q,"base64"],"executable":false,"lamports":1,"owner":%q,"rentEpoch":0}`,
+				base64.StdEncoding.EncodeToString(data), solana.TokenProgramID)
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"context":{"slot":%d},"value":[%s]}}`, req.ID, slot, strings.Join(values, ","))
+	}))
+	t.Cleanup(srv.Close)
+	return rpc.New(srv.URL)
+}
+
+func TestAgreeOn(t *testing.T) {
+	answer := func(name string, slot uint64, value byte) quorumAnswer {
+		return quorumAnswer{endpoint: name, slot: slot, data: [][]byte{{value}, nil}}
+	}
+	view, err := agreeOn([]quorumAnswer{answer("a", 100, 1), answer("b", 101, 1), answer("c", 90, 2)}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view.slot != 101 || view.agreed != 2 || view.data[0][0] != 1 || view.String() != "2 of 3 RPCs agree at slot 101, c is 11 slots behind" {
+		t.Errorf("view %s", view)
+	}
+
+	// A lone endpoint ahead with other values is outvoted, and called out.
+	view, err = agreeOn([]quorumAnswer{answer("a", 100, 1), answer("b", 100, 1), answer("c", 120, 2), {endpoint: "d", err: fmt.Errorf("timeout")}}, 2)
+	if err != nil || view.slot != 100 || !strings.Contains(view.String(), "c disagrees at slot 120; d failed") {
+		t.Errorf("view %v, %v", view, err)
+	}
+	// Once two agree on the newer values, those win.
+	if view, err := agreeOn([]quorumAnswer{answer("a", 100, 1), answer("b", 100, 1), answer("c", 120, 2), answer("d", 119, 2)}, 2); err != nil || view.slot != 120 || view.data[0][0] != 2 {
+		t.Errorf("view %v, %v", view, err)
+	}
+
+	if _, err := agreeOn([]quorumAnswer{answer("a", 100, 1), answer("b", 100, 2), answer("c", 100, 3)}, 2); err == nil || !strings.Contains(err.Error(), "no 2 of 3 RPCs agree") {
+		t.Errorf("three different answers: %v", err)
+	}
+}
+
+func TestQuorumReserves(t *testing.T) {
+	defer func(saved rpcQuorumConfig) { *rpcQuorum = saved }(*rpcQuorum)
+	pool, addr := newTestPoolState()
+	pool.Mint0Decimals, pool.Mint1Decimals = 9, 6
+	honest := map[solana.PublicKey][]byte{
+		addr:             anchorAccount(t, raydium_cp_swap.Account_PoolState, *pool),
+		pool.Token0Vault: tokenAccountData(pool.Token0Mint, 1_000_000_000),
+		pool.Token1Vault: tokenAccountData(pool.Token1Mint, 150_000_000),
+	}
+	lying := map[solana.PublicKey][]byte{
+		addr:             honest[addr],
+		pool.Token0Vault: tokenAccountData(pool.Token0Mint, 1),
+		pool.Token1Vault: honest[pool.Token1Vault],
+	}
+	primary := quorumNode(t, 200, lying)
+	*rpcQuorum = rpcQuorumConfig{
+		endpoints: []string{"one", "two"},
+		clients:   []*rpc.Client{quorumNode(t, 180, honest), quorumNode(t, 181, honest)},
+	}
+	balances, errs, view := quorumReserves(t.Context(), primary, addr, pool, [2]*mintAccount{})
+	if errs[0] != nil || errs[1] != nil {
+		t.Fatal(errs)
+	}
+	if balances[0].Balance.Int64() != 1_000_000_000 || balances[0].Decimals != 9 || balances[1].Balance.Int64() != 150_000_000 {
+		t.Errorf("balances %v %v", balances[0], balances[1])
+	}
+	if view.String() != "2 of 3 RPCs agree at slot 181, -rpc disagrees at slot 200" {
+		t.Errorf("view %s", view)
+	}
+
+	rpcQuorum.size = 3
+	if _, errs, view := quorumReserves(t.Context(), primary, addr, pool, [2]*mintAccount{}); view != nil || errs[0] == nil {
+		t.Errorf("a quorum of 3 got %v, %v", view, errs)
+	}
+	rpcQuorum.size = 0
+
+	// The quorum's pool state is checked too, a pool that's paused since it loaded isn't quoted.
+	paused := *pool
+	paused.Status = poolStatusDisableSwap
+	honest[addr] = anchorAccount(t, raydium_cp_swap.Account_PoolState, paused)
+	if _, errs, _ := quorumReserves(t.Context(), primary, addr, pool, [2]*mintAccount{}); errs[0] == nil || !strings.Contains(errs[0].Error(), "swapping is disabled") {
+		t.Errorf("paused pool: %v", errs)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"sync"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): Quote cache.
+
+-watch re-quotes the same intent every few seconds, and in the TUI the same few intents come round again and again
+(f twice, a nudge up and back down, an intent typed again). Each of those read the pool's vaults and the wallet's token accounts and
+worked the quote out from scratch, even when nobody had traded on the pool since the last time. Every TableBuilder now
+keeps a small cache of its quotes:
+
+  - the reserves a quote read are reused for -quote-cache-age (400ms by default, about a slot) without reading them
+    again, so a burst of keypresses reads the vaults once. 0 reads them for every quote.
+  - the quote itself, and the cost estimate that goes with it, is kept under what it was worked out from: the pool,
+    what its vaults held, the token, direction and absolute amount quoted, the slippage, the wallet and its compute
+    budget. The same intent against the same reserves is the same quote, it's taken from the cache and not worked
+    out again. It's keyed on what the vaults held, not the slot they were read at, a slot passes every 400ms whether
+    anyone swapped or not and a key with it in would hardly ever hit.
+
+Anything that can change without the reserves changing still runs for every quote: a percentage amount reads the
+wallet's balance and a dollar amount the price source before the key is made, and the pre-quote hooks and the `at`
+check see every quote. The cost estimate is the one thing taken on trust, whether the wallet has the swap's token
+accounts, but those only change with a swap, and a swap moves the reserves. Right before sending the reserves are
+read again regardless, the drift check (quote_drift.go) never goes through the cache.
+
+How often it helped is kept as it goes, the TUI shows it in its header and -watch and the TUI log it on the way out.
+A nil cache keeps nothing, bench sets its builders' to nil so what it times is a quote from scratch.
+*/
+
+// quoteCacheAge is -quote-cache-age.
+var quoteCacheAge = 400 * time.Millisecond
+
+func addQuoteCacheFlag(fs *flag.FlagSet) {
+	fs.DurationVar(&quoteCacheAge, "quote-cache-age", quoteCacheAge, "How long a pool's reserves are reused between quotes before they're read again, 0 reads them for every quote")
+}
+
+// quoteCacheSize is the most quotes a cache holds, it starts over when it's full.
+const quoteCacheSize = 256
+
+// quoteCacheKey is everything a quote and its cost estimate are worked out from.
+type quoteCacheKey struct {
+	pool     solana.PublicKey
+	reserves [2]string
+	target   solana.PublicKey
+	dir      SwapDir
+	amount   string // absolute, a percentage or dollar amount resolved to one
+	slippage string
+	wallet   solana.PublicKey
+	budget   feePreset
+}
+
+func newQuoteCacheKey(pool solana.PublicKey, balances []*PoolBalance, instruction *IntentInstruction, target solana.PublicKey, snap quoteSnapshot) (quoteCacheKey, bool) {
+	if len(balances) != 2 || balances[0] == nil || balances[1] == nil || balances[0].Balance == nil || balances[1].Balance == nil {
+		return quoteCacheKey{}, false
+	}
+	key := quoteCacheKey{
+		pool:     pool,
+		reserves: [2]string{balances[0].Balance.String(), balances[1].Balance.String()},
+		target:   target,
+		dir:      instruction.Dir,
+		amount:   instruction.AmountStr,
+		slippage: snap.slippageRat.RatString(),
+		wallet:   snap.wallet,
+		budget:   computeBudget.resolved,
+	}
+	if snap.budget != nil {
+		key.budget = *snap.budget
+	}
+	return key, true
+}
+
+type quoteCacheEntry struct {
+	intent *CPIntent
+	cost   *swapCost // nil until the quote got as far as estimating it
+}
+
+// cachedReserves are a pool's reserves as last read.
+type cachedReserves struct {
+	pool     solana.PublicKey
+	balances []*PoolBalance
+	quorum   *quorumView
+	at       time.Time
+}
+
+type quoteCache struct {
+	mu       sync.Mutex
+	entries  map[quoteCacheKey]*quoteCacheEntry
+	reserves *cachedReserves
+
+	hits, misses       int
+	reserveHits, reads int
+}
+
+func newQuoteCache() *quoteCache {
+	return &quoteCache{entries: make(map[quoteCacheKey]*quoteCacheEntry)}
+}
+
+// readReserves is venue's reserves, the ones read last when they're for the same pool and younger than
+// -quote-cache-age. Failed reads aren't kept.
+func (c *quoteCache) readReserves(ctx context.Context, client *rpc.Client, venue Venue, now time.Time) ([]*PoolBalance, []error, *quorumView) {
+	if c == nil {
+		return readReserves(ctx, client, venue)
+	}
+	c.mu.Lock()
+	if r := c.reserves; r != nil && quoteCacheAge > 0 && r.pool.Equals(venue.Address()) && now.Sub(r.at) < quoteCacheAge {
+		c.reserveHits++
+		c.mu.Unlock()
+		return r.balances, make([]error, len(r.balances)), r.quorum
+	}
+	c.mu.Unlock()
+	balances, errs, quorum := readReserves(ctx, client, venue)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reads++
+	c.reserves = nil
+	if errors.Join(errs...) == nil {
+		c.reserves = &cachedReserves{pool: venue.Address(), balances: balances, quorum: quorum, at: now}
+	}
+	return balances, errs, quorum
+}
+
+// lookup is the quote kept under key, a copy the caller is free to change, and its entry for the cost estimate. Both
+// are nil on a miss.
+func (c *quoteCache) lookup(key quoteCacheKey) (*CPIntent, *quoteCacheEntry) {
+	if c == nil {
+		return nil, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, nil
+	}
+	c.hits++
+	return copyIntent(entry.intent), entry
+}
+
+// store keeps a copy of intent under key and returns its entry.
+func (c *quoteCache) store(key quoteCacheKey, intent *CPIntent) *quoteCacheEntry {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= quoteCacheSize {
+		clear(c.entries)
+	}
+	entry := &quoteCacheEntry{intent: copyIntent(intent)}
+	c.entries[key] = entry
+	return entry
+}
+
+// costOf is the cost estimate kept in entry, or what estimate makes of it, kept for next time.
+func (c *quoteCache) costOf(entry *quoteCacheEntry, estimate func() swapCost) swapCost {
+	if c == nil {
+		return estimate()
+	}
+	c.mu.Lock()
+	if entry != nil && entry.cost != nil {
+		cost := *entry.cost
+		c.mu.Unlock()
+		return cost
+	}
+	c.mu.Unlock()
+	cost := estimate()
+	if entry != nil && cost.rentErr == "" {
+		c.mu.Lock()
+		entry.cost = &cost
+		c.mu.Unlock()
+	}
+	return cost
+}
+
+// copyIntent is intent with amounts of its own, quoting code changes them in place (the at clause tightens the guard).
+func copyIntent(intent *CPIntent) *CPIntent {
+	c := *intent
+	c.Amounts = SwapAmounts{
+		KnownAmount:  cloneInt(intent.Amounts.KnownAmount),
+		QuoteAmount:  cloneInt(intent.Amounts.QuoteAmount),
+		MinAmountOut: cloneInt(intent.Amounts.MinAmountOut),
+		MaxAmountIn:  cloneInt(intent.Amounts.MaxAmountIn),
+	}
+	return &c
+}
+
+// quoteCacheStats is how often the cache saved a quote or a reserves read.
+type quoteCacheStats struct {
+	hits, quotes       int
+	reserveHits, reads int
+}
+
+func (c *quoteCache) stats() quoteCacheStats {
+	if c == nil {
+		return quoteCacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return quoteCacheStats{hits: c.hits, quotes: c.hits + c.misses, reserveHits: c.reserveHits, reads: c.reserveHits + c.reads}
+}
+
+// hitRate is the share of quotes taken from the cache, in percent.
+func (s quoteCacheStats) hitRate() float64 {
+	if s.quotes == 0 {
+		return 0
+	}
+	return 100 * float64(s.hits) / float64(s.quotes)
+}
+
+func (s quoteCacheStats) String() string {
+	return fmt.Sprintf("quote cache: %d of %d quotes reused (%.0f%%), %d of %d reserve reads skipped", s.hits, s.quotes, s.hitRate(), s.reserveHits, s.reads)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestQuoteCache(t *testing.T) {
+	age := quoteCacheAge
+	t.Cleanup(func() { quoteCacheAge = age })
+	quoteCacheAge = time.Hour
+
+	pool, addr, balances := snapshotPool()
+	vaults := vaultBalanceServer(t, map[solana.PublicKey]*PoolBalance{pool.Token0Vault: balances[0], pool.Token1Vault: balances[1]})
+	var reads atomic.Int64
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reads.Add(1)
+		resp, err := http.Post(vaults.URL, "application/json", r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		io.Copy(w, resp.Body)
+	}))
+	t.Cleanup(node.Close)
+	symm := SymbolMapping{
+		mintToSymbol: map[string]string{pool.Token0Mint.String(): "SOL", pool.Token1Mint.String(): "USDC"},
+		symbolToMint: map[string]solana.PublicKey{"SOL": pool.Token0Mint, "USDC": pool.Token1Mint},
+		unresolved:   map[string]struct{}{},
+	}
+	lp := &loadedPool{address: addr, pool: pool, ammConfig: &raydium_cp_swap.AmmConfig{TradeFeeRate: 2500}, symbolsMap: symm}
+	tb, err := newTableBuilder(context.Background(), rpc.New(node.URL), lp, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, intent, err := tb.Build("sell 10 SOL")
+	if err != nil || intent == nil {
+		t.Fatal(first, err)
+	}
+	// Whatever the caller does with its quote stays out of the cache.
+	intent.Amounts.MinAmountOut.SetInt64(0)
+	again, cached, err := tb.Build("sell 10 SOL")
+	if err != nil || again != first || cached.Amounts.MinAmountOut.Sign() == 0 {
+		t.Fatalf("the cached quote differs\n%s\n%s", first, again)
+	}
+	if got := reads.Load(); got != 2 {
+		t.Errorf("%d vault reads for two quotes in a slot, want 2", got)
+	}
+	tb.Build("sell 11 SOL")
+	stats := tb.cache.stats()
+	if stats.hits != 1 || stats.quotes != 3 || stats.reserveHits != 2 || stats.reads != 3 {
+		t.Errorf("stats %+v", stats)
+	}
+
+	// Reserves read for every quote, the same reserves still make the same quote.
+	quoteCacheAge = 0
+	tb.Build("sell 10 SOL")
+	if stats := tb.cache.stats(); stats.hits != 2 || reads.Load() != 4 {
+		t.Errorf("stats %+v after %d reads", stats, reads.Load())
+	}
+	if !strings.Contains(tb.cache.stats().String(), "2 of 4 quotes reused (50%)") {
+		t.Errorf("%s", tb.cache.stats())
+	}
+	// Another slippage is another quote.
+	tb.SetSlippagePct(1)
+	tb.Build("sell 10 SOL")
+	if stats := tb.cache.stats(); stats.hits != 2 {
+		t.Errorf("a quote at another slippage came from the cache, %+v", stats)
+	}
+}
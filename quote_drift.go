@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): A quote is computed off the reserves as they were when it was shown, and the user can sit on it
+for as long as they like before saying yes. The transaction's slippage guard still protects them, but a pool that moved
+past it only fails on chain, after the fee is paid and the blockhash is spent. So the last thing before a swap is
+planned is another read of the vaults and the same amount quoted against them. When the fresh quote wouldn't clear the
+guard the swap is refused with a quoteDriftError and nothing is sent, the TUI puts the fresh quote up to be confirmed
+again. The guard itself stays the one the user saw, a fresh quote within it doesn't move it.
+*/
+
+// quoteDriftError is a swap whose quote moved past its slippage guard between being quoted and being sent.
+type quoteDriftError struct {
+	drift    float64 // how far the counter amount moved against the swap, in percent of the quote
+	slippage float64
+	quoted   string // the counter amount as quoted, with its symbol
+	fresh    string // the same amount quoted now
+}
+
+func (e *quoteDriftError) Error() string {
+	return fmt.Sprintf("the quote moved %.2f%% against the swap since it was quoted (%s, now %s), past the %.2f%% slippage",
+		e.drift, e.quoted, e.fresh, e.slippage)
+}
+
+func (e *quoteDriftError) Is(target error) bool { return target == ErrSlippageExceeded }
+
+// checkQuoteDrift reads the reserves of intent's venue again and quotes the same amount against them, refusing intent
+// with a quoteDriftError when the fresh quote no longer clears its slippage guard.
+func checkQuoteDrift(ctx context.Context, client *rpc.Client, builder *TableBuilder, intent *CPIntent) error {
+	snap := builder.snapshot()
+	venue := venueOf(intent)
+	req := swapQuoteRequest{TargetMint: intent.TokenIn.Mint, Amount: intent.Amounts.KnownAmount, Dir: SwapDirSell, Slippage: snap.slippageRat}
+	if intent.SwapKind == SwapKindBaseOutput {
+		req.TargetMint, req.Dir = intent.TokenOut.Mint, SwapDirBuy
+	}
+	balances, errs, _ := readReserves(ctx, client, venue)
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("reading the reserves again before sending failed: %w", classifyRPC(err))
+	}
+	if err := checkBalances(balances); err != nil {
+		return err
+	}
+	fresh, err := venue.Quote(req, balances)
+	if err != nil {
+		return fmt.Errorf("quoting again before sending failed: %w", err)
+	}
+	drift, clears := quoteDrift(intent, fresh.Amounts.QuoteAmount)
+	if clears {
+		return nil
+	}
+	counter := intent.CounterLeg()
+	symbol := snap.symm.SymFrom(counter.Mint)
+	return &quoteDriftError{
+		drift:    drift,
+		slippage: snap.slippagePct,
+		quoted:   fmt.Sprintf("%s %s", fmtAmount(intent.Amounts.QuoteAmount, counter.Decimals), symbol),
+		fresh:    fmt.Sprintf("%s %s", fmtAmount(fresh.Amounts.QuoteAmount, counter.Decimals), symbol),
+	}
+}
+
+// quoteDrift is how far quote, the counter amount intent's known amount goes for now, has moved against the swap from
+// intent's own quote, in percent of it, 0 when it moved the swap's way. clears reports whether quote still falls
+// within intent's slippage guard.
+func quoteDrift(intent *CPIntent, quote *big.Int) (drift float64, clears bool) {
+	quoted := intent.Amounts.QuoteAmount
+	worse := new(big.Int)
+	switch intent.SwapKind {
+	case SwapKindBaseInput:
+		// Selling, less coming out is worse.
+		worse.Sub(quoted, quote)
+		clears = intent.Amounts.MinAmountOut == nil || quote.Cmp(intent.Amounts.MinAmountOut) >= 0
+	case SwapKindBaseOutput:
+		// Buying, more going in is worse.
+		worse.Sub(quote, quoted)
+		clears = intent.Amounts.MaxAmountIn == nil || quote.Cmp(intent.Amounts.MaxAmountIn) <= 0
+	default:
+		return 0, true
+	}
+	if worse.Sign() <= 0 || quoted.Sign() == 0 {
+		return 0, clears
+	}
+	drift, _ = new(big.Rat).SetFrac(new(big.Int).Mul(worse, big.NewInt(100)), quoted).Float64()
+	return drift, clears
+}
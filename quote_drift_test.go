@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestCheckQuoteDrift(t *testing.T) {
+	pool, addr, balances := snapshotPool()
+	symm := SymbolMapping{
+		mintToSymbol: map[string]string{pool.Token0Mint.String(): "SOL", pool.Token1Mint.String(): "USDC"},
+		symbolToMint: map[string]solana.PublicKey{"SOL": pool.Token0Mint, "USDC": pool.Token1Mint},
+		unresolved:   map[string]struct{}{},
+	}
+	// reserves is a node holding the pool's vaults scaled by sol and usdc thousandths.
+	reserves := func(sol, usdc int64) *rpc.Client {
+		scaled := func(b *PoolBalance, by int64) *PoolBalance {
+			v := new(big.Int).Mul(b.Balance, big.NewInt(by))
+			return &PoolBalance{Balance: v.Quo(v, big.NewInt(1000)), Decimals: b.Decimals}
+		}
+		return rpc.New(vaultBalanceServer(t, map[solana.PublicKey]*PoolBalance{
+			pool.Token0Vault: scaled(balances[0], sol),
+			pool.Token1Vault: scaled(balances[1], usdc),
+		}).URL)
+	}
+	lp := &loadedPool{address: addr, pool: pool, ammConfig: &raydium_cp_swap.AmmConfig{TradeFeeRate: 2500}, symbolsMap: symm}
+	tb, err := newTableBuilder(context.Background(), reserves(1000, 1000), lp, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		intent    string
+		sol, usdc int64
+		drifted   bool
+	}{
+		{"sell 10 SOL", 1000, 1000, false},
+		{"sell 10 SOL", 1000, 1002, false}, // the swap's way
+		{"sell 10 SOL", 1000, 998, false},  // against it, within the slippage
+		{"sell 10 SOL", 1000, 990, true},
+		{"buy 1000 USDC", 1000, 1000, false},
+		{"buy 1000 USDC", 995, 1000, false},
+		{"buy 1000 USDC", 1003, 1000, false},
+		{"buy 1000 USDC", 1010, 1000, true},
+	} {
+		_, intent, err := tb.Build(tc.intent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = checkQuoteDrift(t.Context(), reserves(tc.sol, tc.usdc), tb, intent)
+		var drift *quoteDriftError
+		if got := errors.As(err, &drift); got != tc.drifted || (!got && err != nil) {
+			t.Errorf("%s on reserves at %d/%d thousandths: %v", tc.intent, tc.sol, tc.usdc, err)
+			continue
+		}
+		if tc.drifted && (drift.drift < 0.5 || drift.slippage != 0.5) {
+			t.Errorf("%s drifted %.2f%% against a %.2f%% slippage", tc.intent, drift.drift, drift.slippage)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+/*
+NOTE(@hadydotai): Raydium's API.
+
+Raydium runs a public HTTP API (api-v3.raydium.io, api-v3-devnet.raydium.io on devnet) over its own indexer: every
+pool with its TVL in USD, volume, fee and reward APRs over a day, week and month, and the farms paying rewards on top.
+None of that is anything the client needs, it reads the chain for everything, but it's a lot cheaper than the chain for
+a few things, so -raydium-api turns it on for them:
+
+  - finding a pair's pools, one HTTP call instead of two getProgramAccounts scans (after the pool index, see
+    pool_index.go, which is cheaper still). Only the API's CP-Swap pools on the program the client talks to are
+    taken, and only their addresses, the pools are loaded from the chain like any other.
+  - finding the farms staking a pool's LP token (see farm.go), the same trade against a getProgramAccounts scan of
+    the farm program.
+  - `pool stats` and `pool apr` show the API's day of volume, fees and APRs, farm rewards included, next to what they
+    read off the chain. Those are figures the chain doesn't have (USD, farm rewards), they're shown as the API's and
+    nothing is worked out from them.
+
+It's strictly optional. Off by default, and when the API can't be reached, answers with an error or doesn't know the
+pair, the lookup goes on to the chain the way it would without it, with a warning, and the stats go without the API's
+rows. There's no API for localnet.
+*/
+
+var raydiumAPIURLs = map[string]string{
+	"mainnet": "https://api-v3.raydium.io",
+	"devnet":  "https://api-v3-devnet.raydium.io",
+}
+
+// raydiumAPI is the API -raydium-api turned on, nil when it's off.
+var raydiumAPI *raydiumAPIClient
+
+type raydiumAPIClient struct {
+	base string
+}
+
+func addRaydiumAPIFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("raydium-api", false, "Find pools and enrich pool stats with Raydium's public API, falling back on the chain when it's unreachable")
+}
+
+// useRaydiumAPI sets raydiumAPI for network when enabled.
+func useRaydiumAPI(enabled bool, network string) {
+	raydiumAPI = nil
+	if !enabled {
+		return
+	}
+	base, ok := raydiumAPIURLs[network]
+	if !ok {
+		log.Printf("warning: Raydium's API doesn't serve %s, -raydium-api is off", network)
+		return
+	}
+	raydiumAPI = &raydiumAPIClient{base: base}
+}
+
+// raydiumAPIToken is a pool token as the API has it.
+type raydiumAPIToken struct {
+	Address  string `json:"address"`
+	Symbol   string `json:"symbol"`
+	Decimals uint8  `json:"decimals"`
+}
+
+// raydiumAPIPeriod is a pool's figures over a period, the API has a day, week and month. USD amounts, APRs in percent.
+type raydiumAPIPeriod struct {
+	Volume    float64   `json:"volume"`
+	VolumeFee float64   `json:"volumeFee"`
+	APR       float64   `json:"apr"`
+	FeeAPR    float64   `json:"feeApr"`
+	RewardAPR []float64 `json:"rewardApr"`
+}
+
+// raydiumPoolInfo is what the API knows of a pool.
+type raydiumPoolInfo struct {
+	ID          string              `json:"id"`
+	ProgramID   string              `json:"programId"`
+	MintA       raydiumAPIToken     `json:"mintA"`
+	MintB       raydiumAPIToken     `json:"mintB"`
+	FeeRate     float64             `json:"feeRate"`
+	TVL         float64             `json:"tvl"`
+	Day         raydiumAPIPeriod    `json:"day"`
+	FarmCount   int                 `json:"farmOngoingCount"`
+	FarmRewards []raydiumFarmReward `json:"rewardDefaultInfos"`
+}
+
+// raydiumFarmReward is a token a pool's farms pay out.
+type raydiumFarmReward struct {
+	Mint raydiumAPIToken `json:"mint"`
+}
+
+// raydiumAPIResponse is the envelope every answer comes in.
+type raydiumAPIResponse[T any] struct {
+	Success bool   `json:"success"`
+	Msg     string `json:"msg"`
+	Data    T      `json:"data"`
+}
+
+func (c *raydiumAPIClient) get(ctx context.Context, path string, query url.Values, data any) error {
+	resp := raydiumAPIResponse[any]{Data: data}
+	if err := fetchJSON(ctx, c.base+path+"?"+query.Encode(), &resp); err != nil {
+		return fmt.Errorf("raydium API %s failed: %w", path, err)
+	}
+	if !resp.Success {
+		return fmt.Errorf("raydium API %s failed: %s", path, cmp.Or(resp.Msg, "no reason given"))
+	}
+	return nil
+}
+
+// isCPSwap is whether the API's pool is one of the program the client talks to.
+func (p raydiumPoolInfo) isCPSwap() bool {
+	return p.ProgramID == raydium_cp_swap.ProgramID.String()
+}
+
+// cpPoolsByMints are the API's CP-Swap pools for the pair, deepest first.
+func (c *raydiumAPIClient) cpPoolsByMints(ctx context.Context, mintA, mintB solana.PublicKey) ([]solana.PublicKey, error) {
+	var page struct {
+		Data []raydiumPoolInfo `json:"data"`
+	}
+	query := url.Values{
+		"mint1":         {mintA.String()},
+		"mint2":         {mintB.String()},
+		"poolType":      {"standard"},
+		"poolSortField": {"liquidity"},
+		"sortType":      {"desc"},
+		"pageSize":      {"100"},
+		"page":          {"1"},
+	}
+	if err := c.get(ctx, "/pools/info/mint", query, &page); err != nil {
+		return nil, err
+	}
+	var pools []solana.PublicKey
+	for _, info := range page.Data {
+		if !info.isCPSwap() {
+			continue
+		}
+		pk, err := solana.PublicKeyFromBase58(info.ID)
+		if err != nil {
+			return nil, fmt.Errorf("raydium API has a pool with a bad address %q: %w", info.ID, err)
+		}
+		pools = append(pools, pk)
+	}
+	return pools, nil
+}
+
+// raydiumFarmInfo is a farm as the API lists it, only what's needed to find it on the chain.
+type raydiumFarmInfo struct {
+	ID        string `json:"id"`
+	ProgramID string `json:"programId"`
+}
+
+// farmsByLP are the API's farms on program staking lpMint.
+func (c *raydiumAPIClient) farmsByLP(ctx context.Context, program, lpMint solana.PublicKey) ([]solana.PublicKey, error) {
+	var page struct {
+		Data []raydiumFarmInfo `json:"data"`
+	}
+	query := url.Values{"lp": {lpMint.String()}, "pageSize": {"100"}, "page": {"1"}}
+	if err := c.get(ctx, "/farms/info/lp", query, &page); err != nil {
+		return nil, err
+	}
+	var farms []solana.PublicKey
+	for _, info := range page.Data {
+		if info.ProgramID != program.String() {
+			continue
+		}
+		pk, err := solana.PublicKeyFromBase58(info.ID)
+		if err != nil {
+			return nil, fmt.Errorf("raydium API has a farm with a bad address %q: %w", info.ID, err)
+		}
+		farms = append(farms, pk)
+	}
+	return farms, nil
+}
+
+var errNotInRaydiumAPI = errors.New("raydium API doesn't know the pool")
+
+// poolInfo is what the API knows of pool.
+func (c *raydiumAPIClient) poolInfo(ctx context.Context, pool solana.PublicKey) (*raydiumPoolInfo, error) {
+	var infos []*raydiumPoolInfo
+	if err := c.get(ctx, "/pools/info/ids", url.Values{"ids": {pool.String()}}, &infos); err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		if info != nil && info.ID == pool.String() {
+			return info, nil
+		}
+	}
+	return nil, errNotInRaydiumAPI
+}
+
+// raydiumPoolInfoFor is what the API knows of pool when it's on, nil when it's off or couldn't say, with a warning.
+func raydiumPoolInfoFor(ctx context.Context, pool solana.PublicKey) *raydiumPoolInfo {
+	if raydiumAPI == nil {
+		return nil
+	}
+	readCtx, cancel := deadlines.forQuote(ctx)
+	defer cancel()
+	info, err := raydiumAPI.poolInfo(readCtx, pool)
+	if err != nil {
+		log.Printf("warning: %v, showing what the chain has only", err)
+		return nil
+	}
+	return info
+}
+
+// usd is v US dollars, to the cent.
+func usd(v float64) string {
+	return "$" + strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// rewards is the farm part of the day's APR and the reward tokens, empty without farms.
+func (p raydiumPoolInfo) rewards() string {
+	if p.FarmCount == 0 {
+		return ""
+	}
+	var apr float64
+	for _, r := range p.Day.RewardAPR {
+		apr += r
+	}
+	var symbols []string
+	for _, r := range p.FarmRewards {
+		symbols = append(symbols, cmp.Or(r.Mint.Symbol, Addr(r.Mint.Address).String()))
+	}
+	out := fmt.Sprintf("%s from %d farm", formatPercent(apr), p.FarmCount)
+	if p.FarmCount != 1 {
+		out += "s"
+	}
+	if len(symbols) > 0 {
+		out += " paying " + strings.Join(symbols, ", ")
+	}
+	return out
+}
+
+// dayLine is the API's day of the pool on one line.
+func (p raydiumPoolInfo) dayLine() string {
+	line := fmt.Sprintf("TVL %s, volume %s, fees %s, APR %s (fees %s", usd(p.TVL), usd(p.Day.Volume), usd(p.Day.VolumeFee), formatPercent(p.Day.APR), formatPercent(p.Day.FeeAPR))
+	if rewards := p.rewards(); rewards != "" {
+		line += ", rewards " + rewards
+	}
+	return line + ")"
+}
+
+// raydiumPoolJSON is the API's figures in structured output.
+type raydiumPoolJSON struct {
+	TVL       float64 `json:"tvlUsd"`
+	Volume24h float64 `json:"volume24hUsd"`
+	Fees24h   float64 `json:"fees24hUsd"`
+	APR24h    float64 `json:"apr24h"` // percent, fees and rewards
+	FeeAPR24h float64 `json:"feeApr24h"`
+	RewardAPR float64 `json:"rewardApr24h"`
+	Farms     int     `json:"farms"`
+}
+
+func (p raydiumPoolInfo) json() *raydiumPoolJSON {
+	out := &raydiumPoolJSON{TVL: p.TVL, Volume24h: p.Day.Volume, Fees24h: p.Day.VolumeFee, APR24h: p.Day.APR, FeeAPR24h: p.Day.FeeAPR, Farms: p.FarmCount}
+	for _, r := range p.Day.RewardAPR {
+		out.RewardAPR += r
+	}
+	return out
+}
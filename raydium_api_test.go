@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// raydiumAPIServer answers the API's pool lookups with pools, and turns raydiumAPI on against it for the test.
+func raydiumAPIServer(t *testing.T, pools []raydiumPoolInfo) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		var data any
+		switch r.URL.Path {
+		case "/pools/info/mint":
+			var matched []raydiumPoolInfo
+			for _, p := range pools {
+				pair := map[string]bool{p.MintA.Address: true, p.MintB.Address: true}
+				if pair[q.Get("mint1")] && pair[q.Get("mint2")] {
+					matched = append(matched, p)
+				}
+			}
+			data = map[string]any{"count": len(matched), "data": matched, "hasNextPage": false}
+		case "/pools/info/ids":
+			var found []*raydiumPoolInfo
+			for _, id := range strings.Split(q.Get("ids"), ",") {
+				var info *raydiumPoolInfo
+				for i := range pools {
+					if pools[i].ID == id {
+						info = &pools[i]
+					}
+				}
+				found = append(found, info)
+			}
+			data = found
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"id": "x", "success": true, "data": data})
+	}))
+	t.Cleanup(srv.Close)
+	raydiumAPI = &raydiumAPIClient{base: srv.URL}
+	t.Cleanup(func() { raydiumAPI = nil })
+}
+
+func TestRaydiumAPIFindsPools(t *testing.T) {
+	usdc := snapshotKey(61)
+	cp, other := snapshotKey(62), snapshotKey(63)
+	raydiumAPIServer(t, []raydiumPoolInfo{
+		{ID: cp.String(), ProgramID: raydium_cp_swap.ProgramID.String(), MintA: raydiumAPIToken{Address: wSOLMint.String()}, MintB: raydiumAPIToken{Address: usdc.String()}},
+		// An AMM v4 pool for the same pair isn't one the client can swap on.
+		{ID: other.String(), ProgramID: "675kPX9MHTjS2zt1qfr1NYHuzeLXfQM9H24wFSUt1Mp8", MintA: raydiumAPIToken{Address: wSOLMint.String()}, MintB: raydiumAPIToken{Address: usdc.String()}},
+	})
+	// No RPC, the API's answer is all there is.
+	found, err := findPoolsByMints(t.Context(), nil, usdc, wSOLMint)
+	if err != nil || len(found) != 1 || !found[0].Equals(cp) {
+		t.Fatalf("found %v, %v", found, err)
+	}
+}
+
+func TestRaydiumAPIFallsBackOnTheChain(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(api.Close)
+	raydiumAPI = &raydiumAPIClient{base: api.URL}
+	t.Cleanup(func() { raydiumAPI = nil })
+
+	pool := snapshotKey(64)
+	var scans atomic.Int64
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Method != "getProgramAccounts" {
+			http.Error(w, "unexpected "+req.Method, http.StatusBadRequest)
+			return
+		}
+		result := "[]"
+		if scans.Add(1) == 1 {
+			result = fmt.Sprintf(`[{"pubkey":%q,"account":{"lamports":1,"owner":%q,"data":["","base64"],"executable":false,"rentEpoch":0}}]`, pool, raydium_cp_swap.ProgramID)
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":%s}`, req.ID, result)
+	}))
+	t.Cleanup(node.Close)
+
+	found, err := findPoolsByMints(t.Context(), rpc.New(node.URL), wSOLMint, snapshotKey(61))
+	if err != nil || len(found) != 1 || !found[0].Equals(pool) || scans.Load() != 2 {
+		t.Fatalf("found %v after %d scans, %v", found, scans.Load(), err)
+	}
+}
+
+func TestRaydiumPoolInfo(t *testing.T) {
+	pool := snapshotKey(65)
+	info := raydiumPoolInfo{
+		ID: pool.String(), ProgramID: raydium_cp_swap.ProgramID.String(), TVL: 1_250_000.5, FarmCount: 1,
+		Day:         raydiumAPIPeriod{Volume: 900_000, VolumeFee: 2250, APR: 80.5, FeeAPR: 65.7, RewardAPR: []float64{10.3, 4.5}},
+		FarmRewards: []raydiumFarmReward{{Mint: raydiumAPIToken{Symbol: "RAY"}}},
+	}
+	raydiumAPIServer(t, []raydiumPoolInfo{info})
+
+	got := raydiumPoolInfoFor(t.Context(), pool)
+	if got == nil {
+		t.Fatal("the API's pool wasn't found")
+	}
+	want := "TVL $1250000.50, volume $900000.00, fees $2250.00, APR 80.5% (fees 65.7%, rewards 14.8% from 1 farm paying RAY)"
+	if line := got.dayLine(); line != want {
+		t.Errorf("day line\n%s\nwant\n%s", line, want)
+	}
+	if js := got.json(); math.Abs(js.RewardAPR-14.8) > 1e-9 || js.Farms != 1 {
+		t.Errorf("json %+v", js)
+	}
+	if raydiumPoolInfoFor(t.Context(), snapshotKey(66)) != nil {
+		t.Error("a pool the API doesn't know has info")
+	}
+
+	// Shown in the stats when it's there.
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ps := testPoolStats(from, from.Add(time.Hour))
+	ps.addSwaps(nil)
+	ps.api = got
+	if out := ps.render(4); !strings.Contains(out, "Raydium API, 24h") {
+		t.Errorf("the API's row isn't in\n%s", out)
+	}
+}
@@ -0,0 +1,1199 @@
+// Programmatic quoting and swapping against Raydium CP-Swap pools, the gRPC twin of the HTTP API `serve` exposes.
+// The messages mirror CPIntent and SwapAmounts, contribute.md has the commands that regenerate the Go code.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: raydium.proto
+
+package raydiumpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SwapKind int32
+
+const (
+	SwapKind_SWAP_KIND_UNKNOWN     SwapKind = 0
+	SwapKind_SWAP_KIND_BASE_INPUT  SwapKind = 1
+	SwapKind_SWAP_KIND_BASE_OUTPUT SwapKind = 2
+)
+
+// Enum value maps for SwapKind.
+var (
+	SwapKind_name = map[int32]string{
+		0: "SWAP_KIND_UNKNOWN",
+		1: "SWAP_KIND_BASE_INPUT",
+		2: "SWAP_KIND_BASE_OUTPUT",
+	}
+	SwapKind_value = map[string]int32{
+		"SWAP_KIND_UNKNOWN":     0,
+		"SWAP_KIND_BASE_INPUT":  1,
+		"SWAP_KIND_BASE_OUTPUT": 2,
+	}
+)
+
+func (x SwapKind) Enum() *SwapKind {
+	p := new(SwapKind)
+	*p = x
+	return p
+}
+
+func (x SwapKind) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SwapKind) Descriptor() protoreflect.EnumDescriptor {
+	return file_raydium_proto_enumTypes[0].Descriptor()
+}
+
+func (SwapKind) Type() protoreflect.EnumType {
+	return &file_raydium_proto_enumTypes[0]
+}
+
+func (x SwapKind) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SwapKind.Descriptor instead.
+func (SwapKind) EnumDescriptor() ([]byte, []int) {
+	return file_raydium_proto_rawDescGZIP(), []int{0}
+}
+
+// Amount is a token amount in base units (raw) and in the token's decimals (display).
+type Amount struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Raw           string                 `protobuf:"bytes,1,opt,name=raw,proto3" json:"raw,omitempty"`
+	Display       string                 `protobuf:"bytes,2,opt,name=display,proto3" json:"display,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Amount) Reset() {
+	*x = Amount{}
+	mi := &file_raydium_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Amount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Amount) ProtoMessage() {}
+
+func (x *Amount) ProtoReflect() protoreflect.Message {
+	mi := &file_raydium_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Amount.ProtoReflect.Descriptor instead.
+func (*Amount) Descriptor() ([]byte, []int) {
+	return file_raydium_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Amount) GetRaw() string {
+	if x != nil {
+		return x.Raw
+	}
+	return ""
+}
+
+func (x *Amount) GetDisplay() string {
+	if x != nil {
+		return x.Display
+	}
+	return ""
+}
+
+type SwapLeg struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Mint          string                 `protobuf:"bytes,1,opt,name=mint,proto3" json:"mint,omitempty"`
+	Vault         string                 `protobuf:"bytes,2,opt,name=vault,proto3" json:"vault,omitempty"`
+	Program       string                 `protobuf:"bytes,3,opt,name=program,proto3" json:"program,omitempty"`
+	Decimals      uint32                 `protobuf:"varint,4,opt,name=decimals,proto3" json:"decimals,omitempty"`
+	Symbol        string                 `protobuf:"bytes,5,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SwapLeg) Reset() {
+	*x = SwapLeg{}
+	mi := &file_raydium_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SwapLeg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SwapLeg) ProtoMessage() {}
+
+func (x *SwapLeg) ProtoReflect() protoreflect.Message {
+	mi := &file_raydium_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SwapLeg.ProtoReflect.Descriptor instead.
+func (*SwapLeg) Descriptor() ([]byte, []int) {
+	return file_raydium_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SwapLeg) GetMint() string {
+	if x != nil {
+		return x.Mint
+	}
+	return ""
+}
+
+func (x *SwapLeg) GetVault() string {
+	if x != nil {
+		return x.Vault
+	}
+	return ""
+}
+
+func (x *SwapLeg) GetProgram() string {
+	if x != nil {
+		return x.Program
+	}
+	return ""
+}
+
+func (x *SwapLeg) GetDecimals() uint32 {
+	if x != nil {
+		return x.Decimals
+	}
+	return 0
+}
+
+func (x *SwapLeg) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+type SwapAmounts struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The amount the intent named, in the token it named.
+	KnownAmount *Amount `protobuf:"bytes,1,opt,name=known_amount,json=knownAmount,proto3" json:"known_amount,omitempty"`
+	// The counter amount the curve gives before slippage.
+	QuoteAmount *Amount `protobuf:"bytes,2,opt,name=quote_amount,json=quoteAmount,proto3" json:"quote_amount,omitempty"`
+	// Set for exact input swaps.
+	MinAmountOut *Amount `protobuf:"bytes,3,opt,name=min_amount_out,json=minAmountOut,proto3" json:"min_amount_out,omitempty"`
+	// Set for exact output swaps.
+	MaxAmountIn   *Amount `protobuf:"bytes,4,opt,name=max_amount_in,json=maxAmountIn,proto3" json:"max_amount_in,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SwapAmounts) Reset() {
+	*x = SwapAmounts{}
+	mi := &file_raydium_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SwapAmounts) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SwapAmounts) ProtoMessage() {}
+
+func (x *SwapAmounts) ProtoReflect() protoreflect.Message {
+	mi := &file_raydium_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SwapAmounts.ProtoReflect.Descriptor instead.
+func (*SwapAmounts) Descriptor() ([]byte, []int) {
+	return file_raydium_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SwapAmounts) GetKnownAmount() *Amount {
+	if x != nil {
+		return x.KnownAmount
+	}
+	return nil
+}
+
+func (x *SwapAmounts) GetQuoteAmount() *Amount {
+	if x != nil {
+		return x.QuoteAmount
+	}
+	return nil
+}
+
+func (x *SwapAmounts) GetMinAmountOut() *Amount {
+	if x != nil {
+		return x.MinAmountOut
+	}
+	return nil
+}
+
+func (x *SwapAmounts) GetMaxAmountIn() *Amount {
+	if x != nil {
+		return x.MaxAmountIn
+	}
+	return nil
+}
+
+type PoolAccounts struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Address       string                 `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	AmmConfig     string                 `protobuf:"bytes,2,opt,name=amm_config,json=ammConfig,proto3" json:"amm_config,omitempty"`
+	Observation   string                 `protobuf:"bytes,3,opt,name=observation,proto3" json:"observation,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PoolAccounts) Reset() {
+	*x = PoolAccounts{}
+	mi := &file_raydium_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PoolAccounts) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PoolAccounts) ProtoMessage() {}
+
+func (x *PoolAccounts) ProtoReflect() protoreflect.Message {
+	mi := &file_raydium_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PoolAccounts.ProtoReflect.Descriptor instead.
+func (*PoolAccounts) Descriptor() ([]byte, []int) {
+	return file_raydium_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *PoolAccounts) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *PoolAccounts) GetAmmConfig() string {
+	if x != nil {
+		return x.AmmConfig
+	}
+	return ""
+}
+
+func (x *PoolAccounts) GetObservation() string {
+	if x != nil {
+		return x.Observation
+	}
+	return ""
+}
+
+type CPIntent struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Intent   string                 `protobuf:"bytes,1,opt,name=intent,proto3" json:"intent,omitempty"`
+	SwapKind SwapKind               `protobuf:"varint,2,opt,name=swap_kind,json=swapKind,proto3,enum=raydium.v1.SwapKind" json:"swap_kind,omitempty"`
+	Amounts  *SwapAmounts           `protobuf:"bytes,3,opt,name=amounts,proto3" json:"amounts,omitempty"`
+	TokenIn  *SwapLeg               `protobuf:"bytes,4,opt,name=token_in,json=tokenIn,proto3" json:"token_in,omitempty"`
+	TokenOut *SwapLeg               `protobuf:"bytes,5,opt,name=token_out,json=tokenOut,proto3" json:"token_out,omitempty"`
+	Pool     *PoolAccounts          `protobuf:"bytes,6,opt,name=pool,proto3" json:"pool,omitempty"`
+	// Reserves the quote was computed against, oriented the way the swap flows.
+	ReserveIn     *Amount `protobuf:"bytes,7,opt,name=reserve_in,json=reserveIn,proto3" json:"reserve_in,omitempty"`
+	ReserveOut    *Amount `protobuf:"bytes,8,opt,name=reserve_out,json=reserveOut,proto3" json:"reserve_out,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CPIntent) Reset() {
+	*x = CPIntent{}
+	mi := &file_raydium_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CPIntent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CPIntent) ProtoMessage() {}
+
+func (x *CPIntent) ProtoReflect() protoreflect.Message {
+	mi := &file_raydium_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CPIntent.ProtoReflect.Descriptor instead.
+func (*CPIntent) Descriptor() ([]byte, []int) {
+	return file_raydium_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CPIntent) GetIntent() string {
+	if x != nil {
+		return x.Intent
+	}
+	return ""
+}
+
+func (x *CPIntent) GetSwapKind() SwapKind {
+	if x != nil {
+		return x.SwapKind
+	}
+	return SwapKind_SWAP_KIND_UNKNOWN
+}
+
+func (x *CPIntent) GetAmounts() *SwapAmounts {
+	if x != nil {
+		return x.Amounts
+	}
+	return nil
+}
+
+func (x *CPIntent) GetTokenIn() *SwapLeg {
+	if x != nil {
+		return x.TokenIn
+	}
+	return nil
+}
+
+func (x *CPIntent) GetTokenOut() *SwapLeg {
+	if x != nil {
+		return x.TokenOut
+	}
+	return nil
+}
+
+func (x *CPIntent) GetPool() *PoolAccounts {
+	if x != nil {
+		return x.Pool
+	}
+	return nil
+}
+
+func (x *CPIntent) GetReserveIn() *Amount {
+	if x != nil {
+		return x.ReserveIn
+	}
+	return nil
+}
+
+func (x *CPIntent) GetReserveOut() *Amount {
+	if x != nil {
+		return x.ReserveOut
+	}
+	return nil
+}
+
+type QuoteRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Pool  string                 `protobuf:"bytes,1,opt,name=pool,proto3" json:"pool,omitempty"`
+	// e.g. "pay 1 SOL" or "buy 50 USDC".
+	Intent string `protobuf:"bytes,2,opt,name=intent,proto3" json:"intent,omitempty"`
+	// Slippage percentage, the server's default when unset.
+	Slippage      *float64 `protobuf:"fixed64,3,opt,name=slippage,proto3,oneof" json:"slippage,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QuoteRequest) Reset() {
+	*x = QuoteRequest{}
+	mi := &file_raydium_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QuoteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QuoteRequest) ProtoMessage() {}
+
+func (x *QuoteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_raydium_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QuoteRequest.ProtoReflect.Descriptor instead.
+func (*QuoteRequest) Descriptor() ([]byte, []int) {
+	return file_raydium_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *QuoteRequest) GetPool() string {
+	if x != nil {
+		return x.Pool
+	}
+	return ""
+}
+
+func (x *QuoteRequest) GetIntent() string {
+	if x != nil {
+		return x.Intent
+	}
+	return ""
+}
+
+func (x *QuoteRequest) GetSlippage() float64 {
+	if x != nil && x.Slippage != nil {
+		return *x.Slippage
+	}
+	return 0
+}
+
+type QuoteReply struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Intent   *CPIntent              `protobuf:"bytes,1,opt,name=intent,proto3" json:"intent,omitempty"`
+	Slippage float64                `protobuf:"fixed64,2,opt,name=slippage,proto3" json:"slippage,omitempty"`
+	// Price impact in percent, trade fee included.
+	PriceImpact string `protobuf:"bytes,3,opt,name=price_impact,json=priceImpact,proto3" json:"price_impact,omitempty"`
+	// Counter token per target token.
+	Price         string `protobuf:"bytes,4,opt,name=price,proto3" json:"price,omitempty"`
+	PriceUnit     string `protobuf:"bytes,5,opt,name=price_unit,json=priceUnit,proto3" json:"price_unit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QuoteReply) Reset() {
+	*x = QuoteReply{}
+	mi := &file_raydium_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QuoteReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QuoteReply) ProtoMessage() {}
+
+func (x *QuoteReply) ProtoReflect() protoreflect.Message {
+	mi := &file_raydium_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QuoteReply.ProtoReflect.Descriptor instead.
+func (*QuoteReply) Descriptor() ([]byte, []int) {
+	return file_raydium_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *QuoteReply) GetIntent() *CPIntent {
+	if x != nil {
+		return x.Intent
+	}
+	return nil
+}
+
+func (x *QuoteReply) GetSlippage() float64 {
+	if x != nil {
+		return x.Slippage
+	}
+	return 0
+}
+
+func (x *QuoteReply) GetPriceImpact() string {
+	if x != nil {
+		return x.PriceImpact
+	}
+	return ""
+}
+
+func (x *QuoteReply) GetPrice() string {
+	if x != nil {
+		return x.Price
+	}
+	return ""
+}
+
+func (x *QuoteReply) GetPriceUnit() string {
+	if x != nil {
+		return x.PriceUnit
+	}
+	return ""
+}
+
+type BuildSwapRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Quote *QuoteRequest          `protobuf:"bytes,1,opt,name=quote,proto3" json:"quote,omitempty"`
+	// The wallet that pays and signs, base58.
+	Payer         string `protobuf:"bytes,2,opt,name=payer,proto3" json:"payer,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BuildSwapRequest) Reset() {
+	*x = BuildSwapRequest{}
+	mi := &file_raydium_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BuildSwapRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BuildSwapRequest) ProtoMessage() {}
+
+func (x *BuildSwapRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_raydium_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BuildSwapRequest.ProtoReflect.Descriptor instead.
+func (*BuildSwapRequest) Descriptor() ([]byte, []int) {
+	return file_raydium_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *BuildSwapRequest) GetQuote() *QuoteRequest {
+	if x != nil {
+		return x.Quote
+	}
+	return nil
+}
+
+func (x *BuildSwapRequest) GetPayer() string {
+	if x != nil {
+		return x.Payer
+	}
+	return ""
+}
+
+type BuildSwapReply struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Quote *QuoteReply            `protobuf:"bytes,1,opt,name=quote,proto3" json:"quote,omitempty"`
+	// Base64 wire encoding of the unsigned transaction, carrying a recent blockhash.
+	Transaction     string `protobuf:"bytes,2,opt,name=transaction,proto3" json:"transaction,omitempty"`
+	RecentBlockhash string `protobuf:"bytes,3,opt,name=recent_blockhash,json=recentBlockhash,proto3" json:"recent_blockhash,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *BuildSwapReply) Reset() {
+	*x = BuildSwapReply{}
+	mi := &file_raydium_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BuildSwapReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BuildSwapReply) ProtoMessage() {}
+
+func (x *BuildSwapReply) ProtoReflect() protoreflect.Message {
+	mi := &file_raydium_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BuildSwapReply.ProtoReflect.Descriptor instead.
+func (*BuildSwapReply) Descriptor() ([]byte, []int) {
+	return file_raydium_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *BuildSwapReply) GetQuote() *QuoteReply {
+	if x != nil {
+		return x.Quote
+	}
+	return nil
+}
+
+func (x *BuildSwapReply) GetTransaction() string {
+	if x != nil {
+		return x.Transaction
+	}
+	return ""
+}
+
+func (x *BuildSwapReply) GetRecentBlockhash() string {
+	if x != nil {
+		return x.RecentBlockhash
+	}
+	return ""
+}
+
+type SubmitSwapRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Quote *QuoteRequest          `protobuf:"bytes,1,opt,name=quote,proto3" json:"quote,omitempty"`
+	// Refuse the swap when the price impact in percent is above this.
+	MaxImpact     *float64 `protobuf:"fixed64,2,opt,name=max_impact,json=maxImpact,proto3,oneof" json:"max_impact,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitSwapRequest) Reset() {
+	*x = SubmitSwapRequest{}
+	mi := &file_raydium_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitSwapRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitSwapRequest) ProtoMessage() {}
+
+func (x *SubmitSwapRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_raydium_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitSwapRequest.ProtoReflect.Descriptor instead.
+func (*SubmitSwapRequest) Descriptor() ([]byte, []int) {
+	return file_raydium_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *SubmitSwapRequest) GetQuote() *QuoteRequest {
+	if x != nil {
+		return x.Quote
+	}
+	return nil
+}
+
+func (x *SubmitSwapRequest) GetMaxImpact() float64 {
+	if x != nil && x.MaxImpact != nil {
+		return *x.MaxImpact
+	}
+	return 0
+}
+
+type SubmitSwapReply struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Signature      string                 `protobuf:"bytes,1,opt,name=signature,proto3" json:"signature,omitempty"`
+	Status         string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	FeeLamports    uint64                 `protobuf:"varint,3,opt,name=fee_lamports,json=feeLamports,proto3" json:"fee_lamports,omitempty"`
+	Paid           *Amount                `protobuf:"bytes,4,opt,name=paid,proto3" json:"paid,omitempty"`
+	PaidSymbol     string                 `protobuf:"bytes,5,opt,name=paid_symbol,json=paidSymbol,proto3" json:"paid_symbol,omitempty"`
+	Received       *Amount                `protobuf:"bytes,6,opt,name=received,proto3" json:"received,omitempty"`
+	ReceivedSymbol string                 `protobuf:"bytes,7,opt,name=received_symbol,json=receivedSymbol,proto3" json:"received_symbol,omitempty"`
+	Explorer       string                 `protobuf:"bytes,8,opt,name=explorer,proto3" json:"explorer,omitempty"`
+	// Set when the transaction landed but failed, or confirming it did.
+	Error         string `protobuf:"bytes,9,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitSwapReply) Reset() {
+	*x = SubmitSwapReply{}
+	mi := &file_raydium_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitSwapReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitSwapReply) ProtoMessage() {}
+
+func (x *SubmitSwapReply) ProtoReflect() protoreflect.Message {
+	mi := &file_raydium_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitSwapReply.ProtoReflect.Descriptor instead.
+func (*SubmitSwapReply) Descriptor() ([]byte, []int) {
+	return file_raydium_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *SubmitSwapReply) GetSignature() string {
+	if x != nil {
+		return x.Signature
+	}
+	return ""
+}
+
+func (x *SubmitSwapReply) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *SubmitSwapReply) GetFeeLamports() uint64 {
+	if x != nil {
+		return x.FeeLamports
+	}
+	return 0
+}
+
+func (x *SubmitSwapReply) GetPaid() *Amount {
+	if x != nil {
+		return x.Paid
+	}
+	return nil
+}
+
+func (x *SubmitSwapReply) GetPaidSymbol() string {
+	if x != nil {
+		return x.PaidSymbol
+	}
+	return ""
+}
+
+func (x *SubmitSwapReply) GetReceived() *Amount {
+	if x != nil {
+		return x.Received
+	}
+	return nil
+}
+
+func (x *SubmitSwapReply) GetReceivedSymbol() string {
+	if x != nil {
+		return x.ReceivedSymbol
+	}
+	return ""
+}
+
+func (x *SubmitSwapReply) GetExplorer() string {
+	if x != nil {
+		return x.Explorer
+	}
+	return ""
+}
+
+func (x *SubmitSwapReply) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type PoolUpdatesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Pool  string                 `protobuf:"bytes,1,opt,name=pool,proto3" json:"pool,omitempty"`
+	// Re-quoted on every update when set.
+	Intent        string   `protobuf:"bytes,2,opt,name=intent,proto3" json:"intent,omitempty"`
+	Slippage      *float64 `protobuf:"fixed64,3,opt,name=slippage,proto3,oneof" json:"slippage,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PoolUpdatesRequest) Reset() {
+	*x = PoolUpdatesRequest{}
+	mi := &file_raydium_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PoolUpdatesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PoolUpdatesRequest) ProtoMessage() {}
+
+func (x *PoolUpdatesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_raydium_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PoolUpdatesRequest.ProtoReflect.Descriptor instead.
+func (*PoolUpdatesRequest) Descriptor() ([]byte, []int) {
+	return file_raydium_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *PoolUpdatesRequest) GetPool() string {
+	if x != nil {
+		return x.Pool
+	}
+	return ""
+}
+
+func (x *PoolUpdatesRequest) GetIntent() string {
+	if x != nil {
+		return x.Intent
+	}
+	return ""
+}
+
+func (x *PoolUpdatesRequest) GetSlippage() float64 {
+	if x != nil && x.Slippage != nil {
+		return *x.Slippage
+	}
+	return 0
+}
+
+type PoolUpdate struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	UnixMillis int64                  `protobuf:"varint,1,opt,name=unix_millis,json=unixMillis,proto3" json:"unix_millis,omitempty"`
+	Pool       string                 `protobuf:"bytes,2,opt,name=pool,proto3" json:"pool,omitempty"`
+	Reserve0   *Amount                `protobuf:"bytes,3,opt,name=reserve0,proto3" json:"reserve0,omitempty"`
+	Reserve1   *Amount                `protobuf:"bytes,4,opt,name=reserve1,proto3" json:"reserve1,omitempty"`
+	// Token1 per token0.
+	Price string      `protobuf:"bytes,5,opt,name=price,proto3" json:"price,omitempty"`
+	Quote *QuoteReply `protobuf:"bytes,6,opt,name=quote,proto3" json:"quote,omitempty"`
+	// Set when this update's quote failed, the stream keeps going.
+	Error         string `protobuf:"bytes,7,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PoolUpdate) Reset() {
+	*x = PoolUpdate{}
+	mi := &file_raydium_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PoolUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PoolUpdate) ProtoMessage() {}
+
+func (x *PoolUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_raydium_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PoolUpdate.ProtoReflect.Descriptor instead.
+func (*PoolUpdate) Descriptor() ([]byte, []int) {
+	return file_raydium_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *PoolUpdate) GetUnixMillis() int64 {
+	if x != nil {
+		return x.UnixMillis
+	}
+	return 0
+}
+
+func (x *PoolUpdate) GetPool() string {
+	if x != nil {
+		return x.Pool
+	}
+	return ""
+}
+
+func (x *PoolUpdate) GetReserve0() *Amount {
+	if x != nil {
+		return x.Reserve0
+	}
+	return nil
+}
+
+func (x *PoolUpdate) GetReserve1() *Amount {
+	if x != nil {
+		return x.Reserve1
+	}
+	return nil
+}
+
+func (x *PoolUpdate) GetPrice() string {
+	if x != nil {
+		return x.Price
+	}
+	return ""
+}
+
+func (x *PoolUpdate) GetQuote() *QuoteReply {
+	if x != nil {
+		return x.Quote
+	}
+	return nil
+}
+
+func (x *PoolUpdate) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+var File_raydium_proto protoreflect.FileDescriptor
+
+const file_raydium_proto_rawDesc = "" +
+	"\n" +
+	"\rraydium.proto\x12\n" +
+	"raydium.v1\"4\n" +
+	"\x06Amount\x12\x10\n" +
+	"\x03raw\x18\x01 \x01(\tR\x03raw\x12\x18\n" +
+	"\adisplay\x18\x02 \x01(\tR\adisplay\"\x81\x01\n" +
+	"\aSwapLeg\x12\x12\n" +
+	"\x04mint\x18\x01 \x01(\tR\x04mint\x12\x14\n" +
+	"\x05vault\x18\x02 \x01(\tR\x05vault\x12\x18\n" +
+	"\aprogram\x18\x03 \x01(\tR\aprogram\x12\x1a\n" +
+	"\bdecimals\x18\x04 \x01(\rR\bdecimals\x12\x16\n" +
+	"\x06symbol\x18\x05 \x01(\tR\x06symbol\"\xed\x01\n" +
+	"\vSwapAmounts\x125\n" +
+	"\fknown_amount\x18\x01 \x01(\v2\x12.raydium.v1.AmountR\vknownAmount\x125\n" +
+	"\fquote_amount\x18\x02 \x01(\v2\x12.raydium.v1.AmountR\vquoteAmount\x128\n" +
+	"\x0emin_amount_out\x18\x03 \x01(\v2\x12.raydium.v1.AmountR\fminAmountOut\x126\n" +
+	"\rmax_amount_in\x18\x04 \x01(\v2\x12.raydium.v1.AmountR\vmaxAmountIn\"i\n" +
+	"\fPoolAccounts\x12\x18\n" +
+	"\aaddress\x18\x01 \x01(\tR\aaddress\x12\x1d\n" +
+	"\n" +
+	"amm_config\x18\x02 \x01(\tR\tammConfig\x12 \n" +
+	"\vobservation\x18\x03 \x01(\tR\vobservation\"\x80\x03\n" +
+	"\bCPIntent\x12\x16\n" +
+	"\x06intent\x18\x01 \x01(\tR\x06intent\x121\n" +
+	"\tswap_kind\x18\x02 \x01(\x0e2\x14.raydium.v1.SwapKindR\bswapKind\x121\n" +
+	"\aamounts\x18\x03 \x01(\v2\x17.raydium.v1.SwapAmountsR\aamounts\x12.\n" +
+	"\btoken_in\x18\x04 \x01(\v2\x13.raydium.v1.SwapLegR\atokenIn\x120\n" +
+	"\ttoken_out\x18\x05 \x01(\v2\x13.raydium.v1.SwapLegR\btokenOut\x12,\n" +
+	"\x04pool\x18\x06 \x01(\v2\x18.raydium.v1.PoolAccountsR\x04pool\x121\n" +
+	"\n" +
+	"reserve_in\x18\a \x01(\v2\x12.raydium.v1.AmountR\treserveIn\x123\n" +
+	"\vreserve_out\x18\b \x01(\v2\x12.raydium.v1.AmountR\n" +
+	"reserveOut\"h\n" +
+	"\fQuoteRequest\x12\x12\n" +
+	"\x04pool\x18\x01 \x01(\tR\x04pool\x12\x16\n" +
+	"\x06intent\x18\x02 \x01(\tR\x06intent\x12\x1f\n" +
+	"\bslippage\x18\x03 \x01(\x01H\x00R\bslippage\x88\x01\x01B\v\n" +
+	"\t_slippage\"\xae\x01\n" +
+	"\n" +
+	"QuoteReply\x12,\n" +
+	"\x06intent\x18\x01 \x01(\v2\x14.raydium.v1.CPIntentR\x06intent\x12\x1a\n" +
+	"\bslippage\x18\x02 \x01(\x01R\bslippage\x12!\n" +
+	"\fprice_impact\x18\x03 \x01(\tR\vpriceImpact\x12\x14\n" +
+	"\x05price\x18\x04 \x01(\tR\x05price\x12\x1d\n" +
+	"\n" +
+	"price_unit\x18\x05 \x01(\tR\tpriceUnit\"X\n" +
+	"\x10BuildSwapRequest\x12.\n" +
+	"\x05quote\x18\x01 \x01(\v2\x18.raydium.v1.QuoteRequestR\x05quote\x12\x14\n" +
+	"\x05payer\x18\x02 \x01(\tR\x05payer\"\x8b\x01\n" +
+	"\x0eBuildSwapReply\x12,\n" +
+	"\x05quote\x18\x01 \x01(\v2\x16.raydium.v1.QuoteReplyR\x05quote\x12 \n" +
+	"\vtransaction\x18\x02 \x01(\tR\vtransaction\x12)\n" +
+	"\x10recent_blockhash\x18\x03 \x01(\tR\x0frecentBlockhash\"v\n" +
+	"\x11SubmitSwapRequest\x12.\n" +
+	"\x05quote\x18\x01 \x01(\v2\x18.raydium.v1.QuoteRequestR\x05quote\x12\"\n" +
+	"\n" +
+	"max_impact\x18\x02 \x01(\x01H\x00R\tmaxImpact\x88\x01\x01B\r\n" +
+	"\v_max_impact\"\xbe\x02\n" +
+	"\x0fSubmitSwapReply\x12\x1c\n" +
+	"\tsignature\x18\x01 \x01(\tR\tsignature\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12!\n" +
+	"\ffee_lamports\x18\x03 \x01(\x04R\vfeeLamports\x12&\n" +
+	"\x04paid\x18\x04 \x01(\v2\x12.raydium.v1.AmountR\x04paid\x12\x1f\n" +
+	"\vpaid_symbol\x18\x05 \x01(\tR\n" +
+	"paidSymbol\x12.\n" +
+	"\breceived\x18\x06 \x01(\v2\x12.raydium.v1.AmountR\breceived\x12'\n" +
+	"\x0freceived_symbol\x18\a \x01(\tR\x0ereceivedSymbol\x12\x1a\n" +
+	"\bexplorer\x18\b \x01(\tR\bexplorer\x12\x14\n" +
+	"\x05error\x18\t \x01(\tR\x05error\"n\n" +
+	"\x12PoolUpdatesRequest\x12\x12\n" +
+	"\x04pool\x18\x01 \x01(\tR\x04pool\x12\x16\n" +
+	"\x06intent\x18\x02 \x01(\tR\x06intent\x12\x1f\n" +
+	"\bslippage\x18\x03 \x01(\x01H\x00R\bslippage\x88\x01\x01B\v\n" +
+	"\t_slippage\"\xfb\x01\n" +
+	"\n" +
+	"PoolUpdate\x12\x1f\n" +
+	"\vunix_millis\x18\x01 \x01(\x03R\n" +
+	"unixMillis\x12\x12\n" +
+	"\x04pool\x18\x02 \x01(\tR\x04pool\x12.\n" +
+	"\breserve0\x18\x03 \x01(\v2\x12.raydium.v1.AmountR\breserve0\x12.\n" +
+	"\breserve1\x18\x04 \x01(\v2\x12.raydium.v1.AmountR\breserve1\x12\x14\n" +
+	"\x05price\x18\x05 \x01(\tR\x05price\x12,\n" +
+	"\x05quote\x18\x06 \x01(\v2\x16.raydium.v1.QuoteReplyR\x05quote\x12\x14\n" +
+	"\x05error\x18\a \x01(\tR\x05error*V\n" +
+	"\bSwapKind\x12\x15\n" +
+	"\x11SWAP_KIND_UNKNOWN\x10\x00\x12\x18\n" +
+	"\x14SWAP_KIND_BASE_INPUT\x10\x01\x12\x19\n" +
+	"\x15SWAP_KIND_BASE_OUTPUT\x10\x022\xaa\x02\n" +
+	"\rRaydiumClient\x129\n" +
+	"\x05Quote\x12\x18.raydium.v1.QuoteRequest\x1a\x16.raydium.v1.QuoteReply\x12E\n" +
+	"\tBuildSwap\x12\x1c.raydium.v1.BuildSwapRequest\x1a\x1a.raydium.v1.BuildSwapReply\x12H\n" +
+	"\n" +
+	"SubmitSwap\x12\x1d.raydium.v1.SubmitSwapRequest\x1a\x1b.raydium.v1.SubmitSwapReply\x12M\n" +
+	"\x11StreamPoolUpdates\x12\x1e.raydium.v1.PoolUpdatesRequest\x1a\x16.raydium.v1.PoolUpdate0\x01B$Z\"hadydotai/raydium-client/raydiumpbb\x06proto3"
+
+var (
+	file_raydium_proto_rawDescOnce sync.Once
+	file_raydium_proto_rawDescData []byte
+)
+
+func file_raydium_proto_rawDescGZIP() []byte {
+	file_raydium_proto_rawDescOnce.Do(func() {
+		file_raydium_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_raydium_proto_rawDesc), len(file_raydium_proto_rawDesc)))
+	})
+	return file_raydium_proto_rawDescData
+}
+
+var file_raydium_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_raydium_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_raydium_proto_goTypes = []any{
+	(SwapKind)(0),              // 0: raydium.v1.SwapKind
+	(*Amount)(nil),             // 1: raydium.v1.Amount
+	(*SwapLeg)(nil),            // 2: raydium.v1.SwapLeg
+	(*SwapAmounts)(nil),        // 3: raydium.v1.SwapAmounts
+	(*PoolAccounts)(nil),       // 4: raydium.v1.PoolAccounts
+	(*CPIntent)(nil),           // 5: raydium.v1.CPIntent
+	(*QuoteRequest)(nil),       // 6: raydium.v1.QuoteRequest
+	(*QuoteReply)(nil),         // 7: raydium.v1.QuoteReply
+	(*BuildSwapRequest)(nil),   // 8: raydium.v1.BuildSwapRequest
+	(*BuildSwapReply)(nil),     // 9: raydium.v1.BuildSwapReply
+	(*SubmitSwapRequest)(nil),  // 10: raydium.v1.SubmitSwapRequest
+	(*SubmitSwapReply)(nil),    // 11: raydium.v1.SubmitSwapReply
+	(*PoolUpdatesRequest)(nil), // 12: raydium.v1.PoolUpdatesRequest
+	(*PoolUpdate)(nil),         // 13: raydium.v1.PoolUpdate
+}
+var file_raydium_proto_depIdxs = []int32{
+	1,  // 0: raydium.v1.SwapAmounts.known_amount:type_name -> raydium.v1.Amount
+	1,  // 1: raydium.v1.SwapAmounts.quote_amount:type_name -> raydium.v1.Amount
+	1,  // 2: raydium.v1.SwapAmounts.min_amount_out:type_name -> raydium.v1.Amount
+	1,  // 3: raydium.v1.SwapAmounts.max_amount_in:type_name -> raydium.v1.Amount
+	0,  // 4: raydium.v1.CPIntent.swap_kind:type_name -> raydium.v1.SwapKind
+	3,  // 5: raydium.v1.CPIntent.amounts:type_name -> raydium.v1.SwapAmounts
+	2,  // 6: raydium.v1.CPIntent.token_in:type_name -> raydium.v1.SwapLeg
+	2,  // 7: raydium.v1.CPIntent.token_out:type_name -> raydium.v1.SwapLeg
+	4,  // 8: raydium.v1.CPIntent.pool:type_name -> raydium.v1.PoolAccounts
+	1,  // 9: raydium.v1.CPIntent.reserve_in:type_name -> raydium.v1.Amount
+	1,  // 10: raydium.v1.CPIntent.reserve_out:type_name -> raydium.v1.Amount
+	5,  // 11: raydium.v1.QuoteReply.intent:type_name -> raydium.v1.CPIntent
+	6,  // 12: raydium.v1.BuildSwapRequest.quote:type_name -> raydium.v1.QuoteRequest
+	7,  // 13: raydium.v1.BuildSwapReply.quote:type_name -> raydium.v1.QuoteReply
+	6,  // 14: raydium.v1.SubmitSwapRequest.quote:type_name -> raydium.v1.QuoteRequest
+	1,  // 15: raydium.v1.SubmitSwapReply.paid:type_name -> raydium.v1.Amount
+	1,  // 16: raydium.v1.SubmitSwapReply.received:type_name -> raydium.v1.Amount
+	1,  // 17: raydium.v1.PoolUpdate.reserve0:type_name -> raydium.v1.Amount
+	1,  // 18: raydium.v1.PoolUpdate.reserve1:type_name -> raydium.v1.Amount
+	7,  // 19: raydium.v1.PoolUpdate.quote:type_name -> raydium.v1.QuoteReply
+	6,  // 20: raydium.v1.RaydiumClient.Quote:input_type -> raydium.v1.QuoteRequest
+	8,  // 21: raydium.v1.RaydiumClient.BuildSwap:input_type -> raydium.v1.BuildSwapRequest
+	10, // 22: raydium.v1.RaydiumClient.SubmitSwap:input_type -> raydium.v1.SubmitSwapRequest
+	12, // 23: raydium.v1.RaydiumClient.StreamPoolUpdates:input_type -> raydium.v1.PoolUpdatesRequest
+	7,  // 24: raydium.v1.RaydiumClient.Quote:output_type -> raydium.v1.QuoteReply
+	9,  // 25: raydium.v1.RaydiumClient.BuildSwap:output_type -> raydium.v1.BuildSwapReply
+	11, // 26: raydium.v1.RaydiumClient.SubmitSwap:output_type -> raydium.v1.SubmitSwapReply
+	13, // 27: raydium.v1.RaydiumClient.StreamPoolUpdates:output_type -> raydium.v1.PoolUpdate
+	24, // [24:28] is the sub-list for method output_type
+	20, // [20:24] is the sub-list for method input_type
+	20, // [20:20] is the sub-list for extension type_name
+	20, // [20:20] is the sub-list for extension extendee
+	0,  // [0:20] is the sub-list for field type_name
+}
+
+func init() { file_raydium_proto_init() }
+func file_raydium_proto_init() {
+	if File_raydium_proto != nil {
+		return
+	}
+	file_raydium_proto_msgTypes[5].OneofWrappers = []any{}
+	file_raydium_proto_msgTypes[9].OneofWrappers = []any{}
+	file_raydium_proto_msgTypes[11].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_raydium_proto_rawDesc), len(file_raydium_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   13,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_raydium_proto_goTypes,
+		DependencyIndexes: file_raydium_proto_depIdxs,
+		EnumInfos:         file_raydium_proto_enumTypes,
+		MessageInfos:      file_raydium_proto_msgTypes,
+	}.Build()
+	File_raydium_proto = out.File
+	file_raydium_proto_goTypes = nil
+	file_raydium_proto_depIdxs = nil
+}
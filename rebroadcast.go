@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): Rebroadcasting.
+
+A transaction the RPC accepted isn't a transaction the leader got. The RPC forwards it, retries on its own for a
+while, and under load it just drops it, which is how a swap ends with us waiting out the send deadline on a signature
+nobody's ever going to see. With -rebroadcast <interval> we keep handing the same signed transaction to the RPC on
+that interval until it's seen confirmed or its blockhash expires. It's the same transaction, the same signature, so
+however many copies arrive it can only land once.
+
+Once the blockhash has expired and a last look says the signature never landed (see settleAttempt), nothing can land
+it any more, so with -rebuild-expired N the same instructions are signed again on a fresh blockhash and sent, up to N
+times. Only then: anything short of "expired and not seen" (an RPC error, a signature only seen processed) keeps us
+waiting, rebuilding on top of an attempt that might still land is the double fill the send journal is there to stop.
+The rebuilt attempt goes through the journal like the first one when the send has a guard.
+
+Every rebroadcast and rebuild is logged with the signature it's for. Rebroadcasting is off by default, the RPC's own
+retries are what every swap got before and still gets. One rebuild is on, an attempt is only rebuilt once it's
+verifiably dead, see blockhash.go.
+*/
+
+const (
+	minRebroadcastInterval = 100 * time.Millisecond
+	maxRebuilds            = 5
+)
+
+type rebroadcastPolicy struct {
+	every    time.Duration // 0 is off
+	rebuilds int
+}
+
+// defaultRebuilds is how many times an expired transaction is rebuilt unless -rebuild-expired says otherwise.
+const defaultRebuilds = 1
+
+// rebroadcast is set by -rebroadcast and -rebuild-expired.
+var rebroadcast = rebroadcastPolicy{rebuilds: defaultRebuilds}
+
+func addRebroadcastFlags(fs *flag.FlagSet) {
+	fs.Func("rebroadcast", "Resend an unconfirmed transaction on this interval (e.g. 2s) until it lands or its blockhash expires (off when unset)", func(s string) error {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		if d != 0 && d < minRebroadcastInterval {
+			return fmt.Errorf("rebroadcast interval has to be 0 or at least %s", minRebroadcastInterval)
+		}
+		rebroadcast.every = d
+		return nil
+	})
+	fs.Func("rebuild-expired", fmt.Sprintf("When a transaction's blockhash expires without it landing, sign it again on a fresh blockhash, up to this many times (0 to %d, default %d)", maxRebuilds, defaultRebuilds), func(s string) error {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		if n < 0 || n > maxRebuilds {
+			return fmt.Errorf("rebuild-expired has to be between 0 and %d", maxRebuilds)
+		}
+		rebroadcast.rebuilds = n
+		return nil
+	})
+}
+
+// expiredTxError is a transaction whose blockhash expired with it verifiably not landed, and no rebuilds left.
+type expiredTxError struct {
+	sig       solana.Signature
+	attempts  int
+	lastValid uint64 // the block height its blockhash was good through, zero when unknown
+}
+
+func (e *expiredTxError) Error() string {
+	msg := fmt.Sprintf("transaction %s expired before landing", e.sig)
+	if e.lastValid > 0 {
+		msg += fmt.Sprintf(", its blockhash was good through block %d", e.lastValid)
+	}
+	return msg + fmt.Sprintf(", %d attempt(s), nothing was sent that can still land", e.attempts)
+}
+
+// landTransaction sees tx, already sent once, through to landing the way the rebroadcast policy says: resent on an
+// interval while it's unconfirmed, and signed again from ixs on a fresh blockhash once it's expired. It returns the
+// signature of the attempt that landed, or an expiredTxError once the last attempt expired.
+func landTransaction(ctx context.Context, client *rpc.Client, payer solana.PrivateKey, tx *solana.Transaction, ixs []solana.Instruction, guard *sendGuard) (solana.Signature, error) {
+	for attempt := 1; ; attempt++ {
+		sig := tx.Signatures[0]
+		stop := rebroadcastUntil(ctx, client, tx, rebroadcast.every)
+		status, err := settleAttempt(ctx, client, sig, tx.Message.RecentBlockhash)
+		stop()
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+				// Out of time, the caller's wait reports it as pending like any other.
+				return sig, nil
+			}
+			return sig, err
+		}
+		if status != journalExpired {
+			return sig, nil
+		}
+		guard.expired(sig)
+		if attempt > rebroadcast.rebuilds {
+			return sig, &expiredTxError{sig: sig, attempts: attempt, lastValid: blockhashes.validThrough(tx.Message.RecentBlockhash)}
+		}
+		next, err := signTransaction(ctx, client, payer, ixs)
+		if err == nil {
+			err = guard.sending(next)
+		}
+		if err != nil {
+			return sig, fmt.Errorf("rebuilding expired transaction %s: %w", sig, err)
+		}
+		if _, err := sendTransaction(ctx, client, next); err != nil {
+			return sig, guard.sendFailed(next, err)
+		}
+		log.Printf("transaction %s expired without landing, rebuilt on a fresh blockhash as %s (rebuild %d of %d)",
+			sig, next.Signatures[0], attempt, rebroadcast.rebuilds)
+		tx = next
+	}
+}
+
+// rebroadcastUntil resends tx every interval until the returned stop is called. Preflight is skipped, the first send
+// already simulated it, and the RPC isn't asked to retry, that's what we're doing.
+func rebroadcastUntil(ctx context.Context, client *rpc.Client, tx *solana.Transaction, every time.Duration) (stop func()) {
+	if every <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		sig := tx.Signatures[0]
+		for n := 1; ; n++ {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			_, err := client.SendTransactionWithOpts(ctx, tx, rpc.TransactionOpts{SkipPreflight: true, MaxRetries: ptrTo(uint(0))})
+			switch {
+			case ctx.Err() != nil:
+				return
+			case err != nil:
+				log.Printf("rebroadcast %d of %s failed: %v", n, sig, err)
+			default:
+				log.Printf("rebroadcast %d of %s", n, sig)
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
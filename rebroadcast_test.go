@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// leaderServer is an RPC that drops transactions: a signature is only seen confirmed once it's been sent landAfter
+// times, never when it's in dropped, and with expire set a blockhash is only valid the first time it's asked about,
+// by block height or, for one it didn't hand out, isBlockhashValid.
+type leaderServer struct {
+	mu        sync.Mutex
+	landAfter int
+	expire    bool
+	latest    solana.Hash
+	height    uint64
+	sends     map[solana.Signature]int
+	dropped   map[solana.Signature]bool
+	expired   map[solana.Hash]bool
+}
+
+func newLeaderServer(t *testing.T, landAfter int, expire bool) (*leaderServer, *rpc.Client) {
+	t.Helper()
+	ls := &leaderServer{landAfter: landAfter, expire: expire, latest: solana.Hash{1}, height: 100,
+		sends: map[solana.Signature]int{}, dropped: map[solana.Signature]bool{}, expired: map[solana.Hash]bool{}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		result := func(value string) {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"context":{"slot":1},"value":%s}}`, req.ID, value)
+		}
+		ls.mu.Lock()
+		defer ls.mu.Unlock()
+		switch req.Method {
+		case "sendTransaction":
+			var raw string
+			json.Unmarshal(req.Params[0], &raw)
+			tx, err := solana.TransactionFromBase64(raw)
+			if err != nil {
+				http.Error(w, "unexpected request", http.StatusBadRequest)
+				return
+			}
+			ls.sends[tx.Signatures[0]]++
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":%q}`, req.ID, tx.Signatures[0])
+		case "getSignatureStatuses":
+			var sigs []string
+			json.Unmarshal(req.Params[0], &sigs)
+			if sig := solana.MustSignatureFromBase58(sigs[0]); !ls.dropped[sig] && ls.sends[sig] >= ls.landAfter {
+				result("[" + statusConfirmed + "]")
+			} else {
+				result("[null]")
+			}
+		case "isBlockhashValid":
+			var hash string
+			json.Unmarshal(req.Params[0], &hash)
+			h := solana.MustHashFromBase58(hash)
+			result(fmt.Sprint(!ls.expired[h]))
+			if ls.expire {
+				ls.expired[h] = true
+			}
+		case "getLatestBlockhash":
+			ls.latest[1]++
+			lastValid := ls.height + 150
+			if ls.expire {
+				lastValid = ls.height
+			}
+			result(fmt.Sprintf(`{"blockhash":%q,"lastValidBlockHeight":%d}`, ls.latest, lastValid))
+		case "getBlockHeight":
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":%d}`, req.ID, ls.height)
+			if ls.expire {
+				ls.height++
+			}
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return ls, rpc.New(srv.URL)
+}
+
+func (ls *leaderServer) sent(sig solana.Signature) int {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	return ls.sends[sig]
+}
+
+// sentTx signs a transfer to self and sends it once, the way sendRoute does before handing it to landTransaction.
+func sentTx(t *testing.T, client *rpc.Client, payer solana.PrivateKey) (*solana.Transaction, []solana.Instruction) {
+	t.Helper()
+	ixs := []solana.Instruction{system.NewTransferInstruction(1, payer.PublicKey(), payer.PublicKey()).Build()}
+	tx, err := signTransaction(context.Background(), client, payer, ixs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sendTransaction(context.Background(), client, tx); err != nil {
+		t.Fatal(err)
+	}
+	return tx, ixs
+}
+
+func withRebroadcast(t *testing.T, p rebroadcastPolicy) {
+	t.Helper()
+	prev, prevPoll := rebroadcast, settlePoll
+	rebroadcast, settlePoll = p, 5*time.Millisecond
+	t.Cleanup(func() { rebroadcast, settlePoll = prev, prevPoll })
+}
+
+// With everything off the transaction is still settled, an expired one comes back as such rather than pending.
+func TestLandTransactionOff(t *testing.T) {
+	withRebroadcast(t, rebroadcastPolicy{})
+	payer := solana.NewWallet().PrivateKey
+	ls, client := newLeaderServer(t, 5, true)
+	tx, ixs := sentTx(t, client, payer)
+	sig, err := landTransaction(context.Background(), client, payer, tx, ixs, nil)
+	var expired *expiredTxError
+	if !errors.As(err, &expired) || sig != tx.Signatures[0] {
+		t.Fatalf("landTransaction = %s, %v", sig, err)
+	}
+	if !strings.Contains(err.Error(), "expired before landing") || expired.lastValid != 100 {
+		t.Errorf("expired as %q (good through %d)", err, expired.lastValid)
+	}
+	if n := ls.sent(sig); n != 1 {
+		t.Errorf("sent %d times with rebroadcasting off", n)
+	}
+}
+
+func TestLandTransactionRebroadcasts(t *testing.T) {
+	withRebroadcast(t, rebroadcastPolicy{every: time.Millisecond})
+	payer := solana.NewWallet().PrivateKey
+	ls, client := newLeaderServer(t, 4, false)
+	tx, ixs := sentTx(t, client, payer)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sig, err := landTransaction(ctx, client, payer, tx, ixs, nil)
+	if err != nil || sig != tx.Signatures[0] {
+		t.Fatalf("landTransaction = %s, %v", sig, err)
+	}
+	if n := ls.sent(sig); n < 4 {
+		t.Errorf("landed after %d sends, the leader needed 4", n)
+	}
+}
+
+func TestLandTransactionExpired(t *testing.T) {
+	withRebroadcast(t, rebroadcastPolicy{every: time.Millisecond})
+	payer := solana.NewWallet().PrivateKey
+	_, client := newLeaderServer(t, 1000, true)
+	journal, _ := openSendJournal("")
+	guard := newSendGuard(journal, "limit order")
+	tx, ixs := sentTx(t, client, payer)
+	guard.sending(tx)
+	sig, err := landTransaction(context.Background(), client, payer, tx, ixs, guard)
+	var expired *expiredTxError
+	if !errors.As(err, &expired) || sig != tx.Signatures[0] {
+		t.Fatalf("landTransaction = %s, %v", sig, err)
+	}
+	if attempts := journal.attempts("limit order"); len(attempts) != 1 || attempts[0].Status != journalExpired {
+		t.Errorf("journal holds %+v", attempts)
+	}
+}
+
+func TestLandTransactionRebuilds(t *testing.T) {
+	withRebroadcast(t, rebroadcastPolicy{rebuilds: 2})
+	payer := solana.NewWallet().PrivateKey
+	ls, client := newLeaderServer(t, 1, true)
+	journal, _ := openSendJournal("")
+	guard := newSendGuard(journal, "dca #1")
+	tx, ixs := sentTx(t, client, payer)
+	guard.sending(tx)
+	// The leader never sees the first attempt.
+	ls.mu.Lock()
+	ls.dropped[tx.Signatures[0]] = true
+	ls.mu.Unlock()
+	sig, err := landTransaction(context.Background(), client, payer, tx, ixs, guard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig == tx.Signatures[0] {
+		t.Fatal("landed the expired attempt")
+	}
+	attempts := journal.attempts("dca #1")
+	if len(attempts) != 2 || attempts[0].Status != journalExpired || attempts[1].Signature != sig.String() {
+		t.Errorf("journal holds %+v", attempts)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+/*
+NOTE(@hadydotai): Receipts.
+
+The TUI keeps the receipts of a session in memory and prints them on the way out, which is fine when someone's
+watching. Commands that run unattended (limit orders, stops) need to leave them somewhere, so with -receipts they're
+appended to a JSON lines file, one receipt per line. Appending a line is as close to atomic as a plain file gets, and
+anything downstream can tail it. An order that expired unfilled gets a receipt too, with the status "expired" and no
+signature.
+*/
+
+type swapReceipt struct {
+	Time        time.Time   `json:"time"`
+	Command     string      `json:"command"`
+	Pool        string      `json:"pool"`
+	Intent      string      `json:"intent"`
+	Trigger     string      `json:"trigger,omitempty"`
+	Signature   string      `json:"signature,omitempty"`
+	Status      string      `json:"status"`
+	Paid        *amountJSON `json:"paid,omitempty"`
+	PaidSymbol  string      `json:"paidSymbol,omitempty"`
+	Received    *amountJSON `json:"received,omitempty"`
+	RecvSymbol  string      `json:"receivedSymbol,omitempty"`
+	FeeLamports uint64      `json:"feeLamports,omitempty"`
+	Explorer    string      `json:"explorer,omitempty"`
+	// Wallet and NotionalUSD are kept by the spend limits, what the swap was worth in dollars when it was sent.
+	Wallet      string `json:"wallet,omitempty"`
+	NotionalUSD string `json:"notionalUSD,omitempty"`
+	// RetryOf are the signatures of the attempts at the same fill before this one, oldest first. None of them landed.
+	RetryOf []string `json:"retryOf,omitempty"`
+}
+
+func newSwapReceipt(command, pool, intent string, summary txSummaryData, explorer string) swapReceipt {
+	r := swapReceipt{
+		Time:        time.Now().UTC(),
+		Command:     command,
+		Pool:        pool,
+		Intent:      intent,
+		Signature:   summary.Signature.String(),
+		Status:      summary.Status,
+		PaidSymbol:  summary.PaidSymbol,
+		RecvSymbol:  summary.ReceivedSymbol,
+		FeeLamports: summary.FeeLamports,
+		Explorer:    explorer,
+	}
+	if summary.PaidAmount != nil {
+		r.Paid = ptrTo(newAmountJSON(summary.PaidAmount, summary.PaidDecimals))
+	}
+	if summary.ReceivedAmount != nil {
+		r.Received = ptrTo(newAmountJSON(summary.ReceivedAmount, summary.ReceivedDecimals))
+	}
+	return r
+}
+
+func appendReceipt(path string, r swapReceipt) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening receipts %s: %w", path, err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("writing receipt to %s: %w", path, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAppendReceipt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "receipts.jsonl")
+	summary := txSummaryData{
+		Status:           "confirmed",
+		PaidAmount:       big.NewInt(1_500_000),
+		PaidDecimals:     6,
+		PaidSymbol:       "USDC",
+		ReceivedAmount:   big.NewInt(10_000_000),
+		ReceivedDecimals: 9,
+		ReceivedSymbol:   "SOL",
+	}
+	for range 2 {
+		if err := appendReceipt(path, newSwapReceipt("stop", "pool", "sell 1.5 USDC", summary, "")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSpace(raw), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("expected one receipt per line, got %d lines", len(lines))
+	}
+	var r swapReceipt
+	if err := json.Unmarshal(lines[1], &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Paid == nil || r.Paid.Display != "1.500000" || r.Received.Raw != "10000000" || r.Command != "stop" {
+		t.Fatalf("unexpected receipt %+v", r)
+	}
+}
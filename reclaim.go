@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	solana "github.com/gagliardetto/solana-go"
+	tokenprog "github.com/gagliardetto/solana-go/programs/token"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+/*
+NOTE(@hadydotai): Reclaiming rent.
+
+Every token account holds about 0.002 SOL of rent, and swapping leaves a trail of them behind: an account made for a
+token bought once and sold since, sitting empty. `reclaim` finds the wallet's empty token accounts, under both token
+programs, and closes them in batches, the rent goes back to the wallet.
+
+Only accounts we can actually close are picked: empty, not frozen, closable by the wallet (no close authority, or the
+wallet is it), and for Token-2022 holding no withheld transfer fees, those have to be harvested to the mint before the
+token program lets the account go. One account it refuses fails its whole batch. A stale read can't lose anything: the token program refuses to close an account that still holds
+tokens, and a wSOL account that got topped up in the meantime unwraps into the wallet, which is where the lamports
+go anyway.
+
+-keep lists mints (or symbols, resolved through the aliases and token list) whose accounts stay open even when
+empty, for tokens you'll be getting again and don't want to pay rent on twice.
+
+-close-empty-atas does the same right after a swap, for the two accounts the swap used, so `sell all BONK` doesn't
+leave an empty BONK account behind. wSOL is left to the WSOLManager (wsol.go) there, it already closes what it made.
+*/
+
+const (
+	tokenAccountStateOffset          = 108
+	tokenAccountCloseAuthorityOffset = 129
+	tokenAccountStateFrozen          = 2
+	extensionTypeTransferFeeAmount   = 2
+
+	// reclaimBatchSize closes per transaction, each one is an account and a few bytes of instruction, 20 leaves plenty
+	// of room under the packet size.
+	reclaimBatchSize = 20
+)
+
+// closeEmptyATAs, when set, closes the swap's token accounts it left empty once the swap confirms.
+var closeEmptyATAs bool
+
+func addCloseEmptyATAsFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&closeEmptyATAs, "close-empty-atas", false, "After a swap, close its token accounts that were left empty and reclaim their rent")
+}
+
+// reclaimableAccount is an empty token account the owner can close.
+type reclaimableAccount struct {
+	address  solana.PublicKey
+	mint     solana.PublicKey
+	program  solana.PublicKey
+	lamports uint64
+}
+
+// closableEmpty reads a token account's data and returns its mint when it's empty and owner can close it.
+func closableEmpty(data []byte, owner solana.PublicKey) (solana.PublicKey, bool) {
+	if len(data) < baseAccountLen || !solana.PublicKeyFromBytes(data[32:64]).Equals(owner) {
+		return solana.PublicKey{}, false
+	}
+	if binary.LittleEndian.Uint64(data[tokenAccountAmountOffset:tokenAccountAmountOffset+8]) != 0 {
+		return solana.PublicKey{}, false
+	}
+	if data[tokenAccountStateOffset] == tokenAccountStateFrozen {
+		return solana.PublicKey{}, false
+	}
+	closeAuthority, err := decodeCOptionPubkey(data[tokenAccountCloseAuthorityOffset:baseAccountLen])
+	if err != nil || (closeAuthority != nil && !closeAuthority.Equals(owner)) {
+		return solana.PublicKey{}, false
+	}
+	if withheldTransferFees(data[baseAccountLen:]) {
+		return solana.PublicKey{}, false
+	}
+	return solana.PublicKeyFromBytes(data[:32]), true
+}
+
+// withheldTransferFees reports whether a Token-2022 account's extensions, what follows its base layout, hold transfer
+// fees withheld from it. Extensions that don't parse count as holding some, better to leave an account open than fail
+// a batch.
+func withheldTransferFees(ext []byte) bool {
+	if len(ext) == 0 {
+		return false
+	}
+	if ext[0] != accountTypeAccount {
+		return true
+	}
+	r := binaryReader{b: ext[1:]}
+	for r.remaining() >= 4 {
+		typ, _ := r.le16()
+		if typ == extensionTypeUninitialized {
+			break
+		}
+		length, _ := r.le16()
+		value, ok := r.bytes(int(length))
+		if !ok {
+			return true
+		}
+		if typ == extensionTypeTransferFeeAmount {
+			return len(value) < 8 || binary.LittleEndian.Uint64(value[:8]) != 0
+		}
+	}
+	return false
+}
+
+// knownSymbol is what we'd call mint without loading a pool, an alias or the token list's symbol, empty otherwise.
+func knownSymbol(mint solana.PublicKey) string {
+	for _, sym := range userAliases.symbols() {
+		if userAliases.mint(sym) == mint.String() {
+			return sym
+		}
+	}
+	if entry, ok := knownTokens.lookup(mint); ok {
+		return normalizeSymbol(entry.Symbol)
+	}
+	return ""
+}
+
+// parseKeepList reads -keep, a comma separated list of mints and symbols.
+func parseKeepList(raw string) map[string]bool {
+	keep := map[string]bool{}
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if _, err := solana.PublicKeyFromBase58(item); err == nil {
+			keep[item] = true
+		} else {
+			keep[normalizeSymbol(item)] = true
+		}
+	}
+	return keep
+}
+
+func (ra reclaimableAccount) kept(keep map[string]bool) bool {
+	if keep[ra.mint.String()] {
+		return true
+	}
+	sym := knownSymbol(ra.mint)
+	return sym != "" && keep[sym]
+}
+
+// findReclaimable lists owner's empty token accounts under both token programs, leaving out kept mints, sorted by
+// address.
+func findReclaimable(ctx context.Context, client *rpc.Client, owner solana.PublicKey, keep map[string]bool) ([]reclaimableAccount, error) {
+	var found []reclaimableAccount
+	for _, program := range []solana.PublicKey{solana.TokenProgramID, solana.Token2022ProgramID} {
+		res, err := client.GetTokenAccountsByOwner(ctx, owner,
+			&rpc.GetTokenAccountsConfig{ProgramId: ptrTo(program)},
+			&rpc.GetTokenAccountsOpts{Encoding: solana.EncodingBase64, Commitment: rpc.CommitmentConfirmed})
+		if err != nil {
+			return nil, fmt.Errorf("rpc call getTokenAccountsByOwner failed: %w", err)
+		}
+		if res == nil {
+			continue
+		}
+		for _, acc := range res.Value {
+			if acc == nil || acc.Account.Data == nil {
+				continue
+			}
+			mint, ok := closableEmpty(acc.Account.Data.GetBinary(), owner)
+			if !ok {
+				continue
+			}
+			ra := reclaimableAccount{address: acc.Pubkey, mint: mint, program: acc.Account.Owner, lamports: acc.Account.Lamports}
+			if !ra.kept(keep) {
+				found = append(found, ra)
+			}
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return bytes.Compare(found[i].address[:], found[j].address[:]) < 0 })
+	return found, nil
+}
+
+// closeAccountInstruction closes account into owner under the token program that owns it, CloseAccount is the same
+// instruction in both.
+func closeAccountInstruction(ra reclaimableAccount, owner solana.PublicKey) (solana.Instruction, error) {
+	ix := tokenprog.NewCloseAccountInstructionBuilder().
+		SetAccount(ra.address).
+		SetDestinationAccount(owner).
+		SetOwnerAccount(owner).
+		Build()
+	data, err := ix.Data()
+	if err != nil {
+		return nil, err
+	}
+	return solana.NewInstruction(ra.program, ix.Accounts(), data), nil
+}
+
+// reclaimResult is how a batch of closes went.
+type reclaimResult struct {
+	sig      solana.Signature
+	accounts []reclaimableAccount
+	err      error
+}
+
+// totalRent is the rent closing accounts gives back, in lamports.
+func totalRent(accounts []reclaimableAccount) uint64 {
+	var total uint64
+	for _, ra := range accounts {
+		total += ra.lamports
+	}
+	return total
+}
+
+// reclaimAccounts closes accounts in batches of reclaimBatchSize, waiting for each batch before the next. A failed
+// batch doesn't stop the ones after it.
+func reclaimAccounts(ctx context.Context, client *rpc.Client, payer solana.PrivateKey, accounts []reclaimableAccount) []reclaimResult {
+	var results []reclaimResult
+	for start := 0; start < len(accounts); start += reclaimBatchSize {
+		batch := accounts[start:min(start+reclaimBatchSize, len(accounts))]
+		result := reclaimResult{accounts: batch}
+		result.sig, result.err = reclaimBatch(ctx, client, payer, batch)
+		results = append(results, result)
+	}
+	return results
+}
+
+func reclaimBatch(ctx context.Context, client *rpc.Client, payer solana.PrivateKey, batch []reclaimableAccount) (solana.Signature, error) {
+	ixs := make([]solana.Instruction, 0, len(batch))
+	for _, ra := range batch {
+		ix, err := closeAccountInstruction(ra, payer.PublicKey())
+		if err != nil {
+			return solana.Signature{}, err
+		}
+		ixs = append(ixs, ix)
+	}
+	ctx, cancel := deadlines.forSend(ctx)
+	defer cancel()
+	sig, err := signAndSend(ctx, client, payer, ixs)
+	if err != nil {
+		return solana.Signature{}, err
+	}
+	status, result, err := waitForTransactionResult(ctx, client, sig)
+	if err != nil {
+		return sig, fmt.Errorf("waiting for %s failed: %w", sig, err)
+	}
+	if status == "failed" {
+		failed := &txFailedError{sig: sig}
+		if result != nil && result.Meta != nil {
+			failed.txErr, failed.logs = result.Meta.Err, result.Meta.LogMessages
+		}
+		return sig, failed
+	}
+	return sig, nil
+}
+
+// closeEmptySwapAccounts is -close-empty-atas, closing whichever of the swap's token accounts it left empty. Native
+// SOL is skipped, see the note at the top.
+func closeEmptySwapAccounts(ctx context.Context, client *rpc.Client, payer solana.PrivateKey, intent *CPIntent) (uint64, error) {
+	owner := payer.PublicKey()
+	var atas []solana.PublicKey
+	for _, leg := range []SwapLeg{intent.TokenIn, intent.TokenOut} {
+		if isNativeSOL(leg.Mint) {
+			continue
+		}
+		ata, err := associatedTokenAddress(owner, leg.Mint, legProgram(leg))
+		if err != nil {
+			return 0, err
+		}
+		atas = append(atas, ata)
+	}
+	if len(atas) == 0 {
+		return 0, nil
+	}
+	res, err := client.GetMultipleAccountsWithOpts(ctx, atas, &rpc.GetMultipleAccountsOpts{
+		Encoding:   solana.EncodingBase64,
+		Commitment: rpc.CommitmentConfirmed,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("rpc call getMultipleAccounts for the swap's token accounts failed: %w", err)
+	}
+	var empty []reclaimableAccount
+	for i, acc := range res.Value {
+		if acc == nil || i >= len(atas) {
+			continue
+		}
+		if mint, ok := closableEmpty(acc.Data.GetBinary(), owner); ok {
+			empty = append(empty, reclaimableAccount{address: atas[i], mint: mint, program: acc.Owner, lamports: acc.Lamports})
+		}
+	}
+	if len(empty) == 0 {
+		return 0, nil
+	}
+	if _, err := reclaimBatch(ctx, client, payer, empty); err != nil {
+		return 0, fmt.Errorf("closing empty token accounts failed: %w", err)
+	}
+	return totalRent(empty), nil
+}
+
+func runReclaimCommand(args []string) error {
+	fs := flag.NewFlagSet("reclaim", flag.ExitOnError)
+	nf := addNetworkFlags(fs)
+	var (
+		hotwalletPath = fs.String("hotwallet", "", "Path to the hotwallet whose empty token accounts get closed")
+		keep          = fs.String("keep", "", "Comma separated mints or symbols whose accounts stay open even when empty")
+		dryRun        = fs.Bool("dry-run", false, "List what would be closed and how much rent it'd reclaim, nothing is sent")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, append(nf.specs(),
+		FlagSpec{Name: "hotwallet", Value: hotwalletPath, Rules: []FlagRule{NotEmpty()}},
+	))
+	payer, err := solana.PrivateKeyFromSolanaKeygenFile(*hotwalletPath)
+	if err != nil {
+		return fmt.Errorf("failed to load private key from hot wallet: %w", err)
+	}
+	client := nf.connect()
+	ctx, stop := interruptContext()
+	defer stop()
+
+	quoteCtx, cancel := deadlines.forQuote(ctx)
+	accounts, err := findReclaimable(quoteCtx, client, payer.PublicKey(), parseKeepList(*keep))
+	cancel()
+	if err != nil {
+		return err
+	}
+	if len(accounts) == 0 {
+		fmt.Println("no empty token accounts to close")
+		return nil
+	}
+	renderReclaimable(accounts)
+	if *dryRun {
+		fmt.Println("Nothing was sent.")
+		return nil
+	}
+
+	var reclaimed uint64
+	var failed []error
+	for _, result := range reclaimAccounts(ctx, client, payer, accounts) {
+		if result.err != nil {
+			failed = append(failed, result.err)
+			log.Printf("closing %d accounts failed: %v", len(result.accounts), result.err)
+			continue
+		}
+		reclaimed += totalRent(result.accounts)
+		fmt.Printf("closed %d accounts: %s\n", len(result.accounts), explorerTxURL(*nf.network, result.sig))
+	}
+	fmt.Printf("reclaimed %s SOL\n", fmtAmount(new(big.Int).SetUint64(reclaimed), 9))
+	return errors.Join(failed...)
+}
+
+func renderReclaimable(accounts []reclaimableAccount) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Account", "Mint", "Symbol", "Rent (SOL)"})
+	for _, ra := range accounts {
+		t.AppendRow(table.Row{ra.address, ra.mint, knownSymbol(ra.mint), fmtAmount(new(big.Int).SetUint64(ra.lamports), 9)})
+	}
+	t.AppendFooter(table.Row{fmt.Sprintf("%d accounts", len(accounts)), "", "", fmtAmount(new(big.Int).SetUint64(totalRent(accounts)), 9)})
+	t.Render()
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// ownedTokenAccount is a token account of mint held by owner, tweaked by edit.
+func ownedTokenAccount(mint, owner solana.PublicKey, amount uint64, edit func([]byte)) []byte {
+	data := tokenAccountData(mint, amount)
+	copy(data[32:64], owner[:])
+	data[tokenAccountStateOffset] = 1
+	if edit != nil {
+		edit(data)
+	}
+	return data
+}
+
+// withFeeAmount appends the Token-2022 extensions of an account under a transfer fee mint with withheld fees in it,
+// after the ImmutableOwner extension every Token-2022 ATA has.
+func withFeeAmount(data []byte, withheld uint64) []byte {
+	data = append(data, accountTypeAccount, 7, 0, 0, 0)
+	data = binary.LittleEndian.AppendUint16(data, extensionTypeTransferFeeAmount)
+	data = binary.LittleEndian.AppendUint16(data, 8)
+	return binary.LittleEndian.AppendUint64(data, withheld)
+}
+
+func TestClosableEmpty(t *testing.T) {
+	owner, mint := snapshotKey(20), snapshotKey(21)
+	if got, ok := closableEmpty(ownedTokenAccount(mint, owner, 0, nil), owner); !ok || !got.Equals(mint) {
+		t.Errorf("empty account: %s, %v", got, ok)
+	}
+	withCloseAuthority := func(authority solana.PublicKey) func([]byte) {
+		return func(data []byte) {
+			binary.LittleEndian.PutUint32(data[tokenAccountCloseAuthorityOffset:], 1)
+			copy(data[tokenAccountCloseAuthorityOffset+4:], authority[:])
+		}
+	}
+	if _, ok := closableEmpty(ownedTokenAccount(mint, owner, 0, withCloseAuthority(owner)), owner); !ok {
+		t.Error("the owner as close authority should still be closable")
+	}
+	if _, ok := closableEmpty(withFeeAmount(ownedTokenAccount(mint, owner, 0, nil), 0), owner); !ok {
+		t.Error("a Token-2022 account with no fees withheld should be closable")
+	}
+	for name, data := range map[string][]byte{
+		"holds tokens":        ownedTokenAccount(mint, owner, 1, nil),
+		"someone else's":      ownedTokenAccount(mint, snapshotKey(22), 0, nil),
+		"frozen":              ownedTokenAccount(mint, owner, 0, func(data []byte) { data[tokenAccountStateOffset] = tokenAccountStateFrozen }),
+		"other closer":        ownedTokenAccount(mint, owner, 0, withCloseAuthority(snapshotKey(23))),
+		"not a token account": make([]byte, 82),
+		"withheld fees":       withFeeAmount(ownedTokenAccount(mint, owner, 0, nil), 5),
+	} {
+		if _, ok := closableEmpty(data, owner); ok {
+			t.Errorf("%s: shouldn't be closable", name)
+		}
+	}
+}
+
+func TestFindReclaimable(t *testing.T) {
+	owner := snapshotKey(20)
+	bonk := solana.MustPublicKeyFromBase58("DezXAZ8z7PnrnRJjz3wXBoRgixCa6xjnB7YaB1pPB263")
+	accounts := map[string][]struct {
+		address solana.PublicKey
+		data    []byte
+	}{
+		solana.TokenProgramID.String(): {
+			{snapshotKey(30), ownedTokenAccount(bonk, owner, 0, nil)},
+			{snapshotKey(31), ownedTokenAccount(snapshotKey(21), owner, 0, nil)},
+			{snapshotKey(32), ownedTokenAccount(snapshotKey(24), owner, 5, nil)}, // not empty
+		},
+		solana.Token2022ProgramID.String(): {
+			{snapshotKey(33), ownedTokenAccount(snapshotKey(25), owner, 0, nil)},
+		},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "getTokenAccountsByOwner" || len(req.Params) < 2 {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		var conf struct {
+			ProgramID string `json:"programId"`
+		}
+		json.Unmarshal(req.Params[1], &conf)
+		var values []string
+		for _, acc := range accounts[conf.ProgramID] {
+			values = append(values, fmt.Sprintf(`{"pubkey":%q,"account":{"data":[%q,"base64"],"executable":false,"lamports":2039280,"owner":%q,"rentEpoch":0}}`,
+				acc.address, base64.StdEncoding.EncodeToString(acc.data), conf.ProgramID))
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"context":{"slot":1},"value":[%s]}}`, req.ID, strings.Join(values, ","))
+	}))
+	t.Cleanup(srv.Close)
+
+	found, err := findReclaimable(context.Background(), rpc.New(srv.URL), owner, parseKeepList(" bonk ,"+snapshotKey(25).String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || !found[0].address.Equals(snapshotKey(31)) || totalRent(found) != 2039280 {
+		t.Fatalf("found %+v, want only the unkept empty account", found)
+	}
+
+	found, err = findReclaimable(context.Background(), rpc.New(srv.URL), owner, nil)
+	if err != nil || len(found) != 3 || totalRent(found) != 3*2039280 {
+		t.Fatalf("found %d accounts, %v", len(found), err)
+	}
+	for _, ra := range found {
+		ix, err := closeAccountInstruction(ra, owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ix.ProgramID().Equals(ra.program) || !ix.Accounts()[0].PublicKey.Equals(ra.address) || !ix.Accounts()[1].PublicKey.Equals(owner) {
+			t.Errorf("closing %s goes through %s", ra.address, ix.ProgramID())
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"math/big"
 	"strings"
 	"sync"
+	"time"
 
 	solana "github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/rpc"
@@ -15,17 +16,64 @@ import (
 	"github.com/jedib0t/go-pretty/v6/text"
 )
 
+/*
+NOTE(@hadydotai): TableBuilder and concurrency.
+
+The builder started life owned by the TUI loop, one quote at a time. Now watchers, the limit engine, the TUI's
+background computes and (soon) a server all quote through one builder, some while the user switches pools or changes
+slippage. So the mutable parts (the pool, its config, slippage and the symbol mapping) sit behind mu, and every quote
+starts by taking a snapshot of them. A quote works off its snapshot only, it can't see half a pool switch, and nobody
+holds the lock across RPC calls.
+
+The symbol mapping is copy-on-write for the same reason: snapshots share its maps, so mapSymbol clones before
+writing and swaps the clone in, a mapping that's been handed out is never mutated again.
+*/
+
 type TableBuilder struct {
-	ctx               context.Context
-	client            *rpc.Client
-	pool              *raydium_cp_swap.PoolState
-	poolAmmConfig     *raydium_cp_swap.AmmConfig
-	poolAddress       string
-	poolPubKey        solana.PublicKey
-	slippagePct       float64
-	slippageRat       *big.Rat
-	symm              SymbolMapping
-	userSymbolAliases map[string]solana.PublicKey
+	ctx    context.Context
+	client *rpc.Client
+
+	mu            sync.RWMutex
+	pool          *raydium_cp_swap.PoolState
+	poolAmmConfig *raydium_cp_swap.AmmConfig
+	poolAddress   string
+	poolPubKey    solana.PublicKey
+	slippagePct   float64
+	slippageRat   *big.Rat
+	symm          SymbolMapping
+	mints         [2]*mintAccount
+	venue         Venue
+	wallet        solana.PublicKey
+	budget        *feePreset
+	cache         *quoteCache
+}
+
+// quoteSnapshot is everything a quote reads from the builder, taken in one go.
+type quoteSnapshot struct {
+	pool        *raydium_cp_swap.PoolState
+	ammConfig   *raydium_cp_swap.AmmConfig
+	address     solana.PublicKey
+	slippagePct float64
+	slippageRat *big.Rat
+	symm        SymbolMapping
+	mints       [2]*mintAccount
+	venue       Venue // what's quoted on, the CP-Swap fields above are its pool for what's still CP specific
+	wallet      solana.PublicKey
+	budget      *feePreset // nil for the compute budget flags'
+}
+
+// newTableBuilder returns a builder quoting against the loaded pool with the given slippage tolerance.
+func newTableBuilder(ctx context.Context, client *rpc.Client, lp *loadedPool, slippagePct float64) (*TableBuilder, error) {
+	tb := &TableBuilder{
+		ctx:    ctx,
+		client: client,
+		cache:  newQuoteCache(),
+	}
+	tb.usePool(lp)
+	if err := tb.SetSlippagePct(slippagePct); err != nil {
+		return nil, fmt.Errorf("invalid slippage: %w", err)
+	}
+	return tb, nil
 }
 
 func (tb *TableBuilder) SetSlippagePct(pct float64) error {
@@ -33,48 +81,131 @@ func (tb *TableBuilder) SetSlippagePct(pct float64) error {
 	if err != nil {
 		return err
 	}
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
 	tb.slippagePct = pct
 	tb.slippageRat = rat
 	return nil
 }
 
-func (tb *TableBuilder) slippage() (float64, *big.Rat) {
-	var ratioCopy *big.Rat
+// usePool points the builder at a freshly loaded pool, everything quoted afterwards is against this pool.
+func (tb *TableBuilder) usePool(lp *loadedPool) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.pool = lp.pool
+	tb.poolAmmConfig = lp.ammConfig
+	tb.poolAddress = lp.address.String()
+	tb.poolPubKey = lp.address
+	tb.symm = lp.symbolsMap
+	tb.mints = lp.mints
+	tb.venue = lp.venue()
+}
+
+// useWallet is the wallet percentage amounts ("sell 50% SOL") are taken from, without one they're refused.
+func (tb *TableBuilder) useWallet(wallet solana.PublicKey) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.wallet = wallet
+}
+
+// useComputeBudget has every intent quoted from here on pay budget instead of what the compute budget flags set.
+func (tb *TableBuilder) useComputeBudget(budget feePreset) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.budget = &budget
+}
+
+// mapSymbol maps sym to mint for every quote from here on, quotes already running keep the mapping they started with.
+func (tb *TableBuilder) mapSymbol(sym, mint string) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	symm := tb.symm.clone()
+	symm.MapSymToMint(sym, mint)
+	tb.symm = symm
+}
+
+func (tb *TableBuilder) snapshot() quoteSnapshot {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+	snap := quoteSnapshot{
+		pool:        tb.pool,
+		ammConfig:   tb.poolAmmConfig,
+		address:     tb.poolPubKey,
+		slippagePct: tb.slippagePct,
+		slippageRat: big.NewRat(0, 1),
+		symm:        tb.symm,
+		mints:       tb.mints,
+		venue:       tb.venue,
+		wallet:      tb.wallet,
+		budget:      tb.budget,
+	}
 	if tb.slippageRat != nil {
-		ratioCopy = new(big.Rat).Set(tb.slippageRat)
-	} else {
-		ratioCopy = big.NewRat(0, 1)
+		snap.slippageRat.Set(tb.slippageRat)
 	}
-	return tb.slippagePct, ratioCopy
+	return snap
+}
+
+func (tb *TableBuilder) slippage() (float64, *big.Rat) {
+	snap := tb.snapshot()
+	return snap.slippagePct, snap.slippageRat
+}
+
+// symbols is the symbol mapping as of now, safe to read from any goroutine.
+func (tb *TableBuilder) symbols() SymbolMapping {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+	return tb.symm
+}
+
+// currentPool is the pool quotes currently go against.
+func (tb *TableBuilder) currentPool() (solana.PublicKey, *raydium_cp_swap.PoolState) {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+	return tb.poolPubKey, tb.pool
 }
 
+// Build quotes intentLine against the pool and renders the report. When the intent can't be quoted the report still
+// comes back, saying why, with an unquotableError.
 func (tb *TableBuilder) Build(intentLine string) (string, *CPIntent, error) {
 	instruction, err := parseIntent(intentLine)
 	if err != nil {
 		return "", nil, err
 	}
-	targetMint, ok := tb.symm.MaybeMintFromSym(instruction.TargetSymbol)
+	snap := tb.snapshot()
+	targetMint, ok := snap.symm.MaybeMintFromToken(instruction.TargetSymbol)
 	if !ok {
-		candidate, ok := tb.symm.UnresolvedCandidate()
+		candidate, ok := snap.symm.UnresolvedCandidate()
 		if ok {
 			return "", nil, &MissingSymbolMappingError{Symbol: instruction.TargetSymbol, Mint: candidate}
 		}
 		return "", nil, fmt.Errorf("the ticker symbol you provided is either missing from our mapping or isn't part of the pool's pair: %s", instruction.TargetSymbol)
 	}
 
+	venue := snap.venue
+	mints := venue.Mints()
+	for _, mint := range mints {
+		if err := tradeMints.check(snap.symm.SymFrom(mint), mint); err != nil {
+			return "", nil, err
+		}
+	}
 	builder := &strings.Builder{}
 	t := table.NewWriter()
 	t.SetOutputMirror(builder)
-	t.SetTitle(tb.poolAddress)
-	t.SetCaption("CPMM/CP-Swap Raydium Pool")
+	t.SetTitle(venue.Address().String())
+	t.SetCaption(venue.Name())
 	t.Style().Size.WidthMax = 120
 	t.AppendHeader(table.Row{"", "Token 0", "Token 1"})
-	t.AppendRow(table.Row{"Symbol", tb.symm.SymFrom(tb.pool.Token0Mint), tb.symm.SymFrom(tb.pool.Token1Mint)})
+	t.AppendRow(table.Row{"Symbol", snap.symm.SymFrom(mints[0]), snap.symm.SymFrom(mints[1])})
+	if src0, src1 := snap.symm.SourceOf(mints[0]), snap.symm.SourceOf(mints[1]); src0 != "" || src1 != "" {
+		t.AppendRow(table.Row{"Symbol from", src0, src1})
+	}
 
 	// TODO(@hadydotai):BUG: This is a problem, poolBalances always creates slices of an exact size, so len(balances) will
 	// actually never be zero, it's not a signal for errors. In fact, neither are, balances and errs have a length.
 	// They might be zeroed out, but they're preallocated and have a length.
-	balances, errs := poolBalances(tb.ctx, tb.client, []solana.PublicKey{tb.pool.Token0Vault, tb.pool.Token1Vault})
+	quoteCtx, cancel := deadlines.forQuote(tb.ctx)
+	balances, errs, quorum := tb.cache.readReserves(quoteCtx, tb.client, venue, time.Now())
+	cancel()
 	if len(balances) == 0 {
 		return "", nil, errors.New("no balances available for pool")
 	}
@@ -89,45 +220,116 @@ func (tb *TableBuilder) Build(intentLine string) (string, *CPIntent, error) {
 			balancesDisplay[i+1] = "n/a"
 			continue
 		}
-		balancesDisplay[i+1] = fmtForDisplay(balances[i].Balance, balances[i].Decimals, int(balances[i].Decimals))
+		balancesDisplay[i+1] = fmtAmount(balances[i].Balance, balances[i].Decimals)
 	}
 	t.AppendRow(balancesDisplay)
+	if quorum != nil {
+		t.AppendRow(table.Row{"Quorum", quorum.String(), quorum.String()}, table.RowConfig{AutoMerge: true, AutoMergeAlign: text.AlignLeft})
+	}
 
 	decimals := []any{"Decimals"}
-	for _, bal := range balances {
-		if bal == nil {
+	for i, bal := range balances {
+		switch {
+		case bal != nil:
+			decimals = append(decimals, bal.Decimals)
+		case snap.mints[i] != nil:
+			decimals = append(decimals, snap.mints[i].Decimals)
+		default:
 			decimals = append(decimals, "n/a")
-			continue
 		}
-		decimals = append(decimals, bal.Decimals)
 	}
 	t.AppendRow(decimals)
+	if m0, m1 := snap.mints[0], snap.mints[1]; m0 != nil && m1 != nil {
+		t.AppendRow(table.Row{"Supply", fmtAmount(new(big.Int).SetUint64(m0.Supply), m0.Decimals), fmtAmount(new(big.Int).SetUint64(m1.Supply), m1.Decimals)})
+		t.AppendRow(table.Row{"Mint authority", describeAuthority(m0.MintAuthority), describeAuthority(m1.MintAuthority)})
+		t.AppendRow(table.Row{"Freeze authority", describeAuthority(m0.FreezeAuthority), describeAuthority(m1.FreezeAuthority)})
+	}
 	t.AppendSeparator()
-	tradeFeeRow := formatFeeRate(tb.poolAmmConfig.TradeFeeRate)
+	tradeFeeRow := formatFeeRate(venue.FeeRate())
 	t.AppendRow(table.Row{"Trade fee", tradeFeeRow, tradeFeeRow})
-	slippagePct, slippageRat := tb.slippage()
-	slippageDisplay := formatPercent(slippagePct)
+	slippageDisplay := formatPercent(snap.slippagePct)
 	t.AppendRow(table.Row{"Slippage", slippageDisplay, slippageDisplay}, table.RowConfig{AutoMerge: true, AutoMergeAlign: text.AlignLeft})
 
 	t.AppendSeparator()
-	cp := ConstantProduct{TradeFeeRate: tb.poolAmmConfig.TradeFeeRate, SlippageRatio: slippageRat}
 	intentRow := table.Row{"Intent", "", ""}
-	targetTokenCell := 0
-	if targetMint.Equals(tb.pool.Token1Mint) {
-		targetTokenCell = 1
+	counterMint := mints[0]
+	if tokenIndex(venue, targetMint) == 0 {
+		counterMint = mints[1]
 	}
+	if err := instruction.checkCounter(counterMint, snap.symm); err != nil {
+		return "", nil, err
+	}
+	var (
+		intentMeta    *CPIntent
+		intentErr     error
+		walletBalance *big.Int
+		usd           *usdConversion
+	)
+	resolved := instruction
+	if err := errors.Join(errs...); err != nil {
+		// The balances row says which read failed, this is so the error says what kind of failure it was.
+		if kindOf(err) == nil {
+			err = classifyRPC(err)
+		}
+		intentErr = fmt.Errorf("reading the pool's reserves failed: %w", err)
+	}
+	if intentErr == nil && instruction.AmountUSD != nil {
+		inputMint := targetMint
+		if instruction.Dir == SwapDirBuy {
+			inputMint = counterMint
+		}
+		if bal := balances[tokenIndex(venue, inputMint)]; bal == nil {
+			intentErr = errors.New("the pool's balances are unavailable")
+		} else if usd, intentErr = resolveUSD(tb.ctx, instruction, inputMint, snap.symm.SymFrom(inputMint), bal.Decimals); intentErr == nil {
+			// What's quoted from here on is paying inputMint, which is the other token when buying.
+			resolved = usd.instruction
+			if !inputMint.Equals(targetMint) {
+				targetMint, counterMint = counterMint, targetMint
+			}
+		}
+	}
+	targetTokenCell := tokenIndex(venue, targetMint)
 	counterTokenCell := 1 - targetTokenCell
-	intentMeta, intentErr := NewCPIntent(cp, tb.pool, tb.poolPubKey, instruction, targetMint, balances...)
-	if intentErr != nil {
-		errMsg := fmt.Sprintf("intent failed: %s", intentErr)
-		if instruction != nil {
-			errMsg = fmt.Sprintf("%s %s %s failed: %s", instruction.Verb, instruction.AmountStr, instruction.TargetSymbol, intentErr)
+	if intentErr == nil && instruction.AmountPct != nil {
+		if bal := balances[targetTokenCell]; bal == nil {
+			intentErr = errors.New("the pool's balances are unavailable")
+		} else {
+			resolved, walletBalance, intentErr = resolvePercent(tb.ctx, tb.client, snap.wallet, instruction, targetMint, poolTokenProgram(snap.pool, targetMint), bal.Decimals)
+		}
+	}
+	if intentErr == nil {
+		intentErr = preQuoteHooks(tb.ctx, hookQuote{Pool: venue.Address(), Mints: mints, Symbols: snap.symm, Instruction: instruction})
+	}
+	var cached *quoteCacheEntry
+	if intentErr == nil {
+		key, ok := newQuoteCacheKey(venue.Address(), balances, resolved, targetMint, snap)
+		if ok {
+			intentMeta, cached = tb.cache.lookup(key)
+		}
+		if intentMeta == nil {
+			intentMeta, intentErr = quoteInstruction(venue, resolved, targetMint, snap.slippageRat, balances)
+			if intentErr == nil && ok {
+				cached = tb.cache.store(key, intentMeta)
+			}
 		}
+	}
+	if intentErr == nil {
+		// The intent keeps the percentage or dollars as typed, a percentage is what's re-quoted when the balance moves
+		// before sending.
+		intentMeta.Instruction = instruction
+		intentMeta.WalletBalance = walletBalance
+		intentMeta.ComputeBudget = snap.budget
+		if err := instruction.applyCondition(intentMeta, snap.symm); err != nil {
+			intentMeta, intentErr = nil, err
+		}
+	}
+	if intentErr != nil {
+		errMsg := fmt.Sprintf("%s failed: %s", instruction, intentErr)
 		intentRow[targetTokenCell+1] = errMsg
 		intentRow[counterTokenCell+1] = errMsg
 		t.AppendRow(intentRow, table.RowConfig{AutoMerge: true})
 		t.Render()
-		return builder.String(), intentMeta, nil
+		return builder.String(), nil, &unquotableError{intent: intentLine, err: intentErr}
 	}
 
 	counterLeg := intentMeta.CounterLeg()
@@ -135,9 +337,9 @@ func (tb *TableBuilder) Build(intentLine string) (string, *CPIntent, error) {
 		return "", nil, errors.New("intent has no counter leg")
 	}
 	counterDecimals := counterLeg.Decimals
-	counterTokenAmount := fmtForDisplay(cloneInt(intentMeta.Amounts.QuoteAmount), counterDecimals, int(counterDecimals))
+	counterTokenAmount := fmtAmount(cloneInt(intentMeta.Amounts.QuoteAmount), counterDecimals)
 	intentText := intentMeta.String()
-	counterSymbol := tb.symm.SymFrom(counterLeg.Mint)
+	counterSymbol := snap.symm.SymFrom(counterLeg.Mint)
 
 	switch intentMeta.SwapKind {
 	case SwapKindBaseInput:
@@ -150,31 +352,84 @@ func (tb *TableBuilder) Build(intentLine string) (string, *CPIntent, error) {
 		panic("shouldn't be here, did we miss an early return checking for verbToSwapDir error value?")
 	}
 	t.AppendRow(intentRow)
+	if walletBalance != nil {
+		decimals := balances[targetTokenCell].Decimals
+		amountRow := table.Row{"Amount", "", ""}
+		amountRow[targetTokenCell+1] = fmt.Sprintf("%s of %s %s = %s %s", instruction.AmountStr,
+			fmtAmount(walletBalance, decimals), instruction.TargetSymbol, resolved.AmountStr, instruction.TargetSymbol)
+		t.AppendRow(amountRow)
+	}
+	if usd != nil {
+		inputSym := snap.symm.SymFrom(usd.inputMint)
+		usdRow := table.Row{"USD", "", ""}
+		usdRow[targetTokenCell+1] = fmt.Sprintf("%s at $%s/%s (%s, %s old) = %s %s", instruction.AmountStr,
+			trimDecimal(usd.price.Price.FloatString(6)), inputSym, usd.price.Source, usd.age.Round(time.Second), resolved.AmountStr, inputSym)
+		t.AppendRow(usdRow)
+	}
 
 	quoteRow := table.Row{"Quote (no slippage)", "", ""}
 	slippageRow := table.Row{"Slippage guard", "", ""}
 	switch intentMeta.SwapKind {
 	case SwapKindBaseInput:
 		outputDecimals := intentMeta.TokenOut.Decimals
-		outputSymbol := tb.symm.SymFrom(intentMeta.TokenOut.Mint)
-		estimate := fmtForDisplay(intentMeta.Amounts.QuoteAmount, outputDecimals, int(outputDecimals))
-		minOut := fmtForDisplay(intentMeta.Amounts.MinAmountOut, outputDecimals, int(outputDecimals))
+		outputSymbol := snap.symm.SymFrom(intentMeta.TokenOut.Mint)
+		estimate := fmtAmount(intentMeta.Amounts.QuoteAmount, outputDecimals)
+		minOut := fmtAmount(intentMeta.Amounts.MinAmountOut, outputDecimals)
 		quoteRow[counterTokenCell+1] = fmt.Sprintf("est. receive %s %s", estimate, outputSymbol)
 		slippageRow[counterTokenCell+1] = fmt.Sprintf("min receive %s %s", minOut, outputSymbol)
 	case SwapKindBaseOutput:
 		inputDecimals := intentMeta.TokenIn.Decimals
-		inputSymbol := tb.symm.SymFrom(intentMeta.TokenIn.Mint)
-		estimate := fmtForDisplay(intentMeta.Amounts.QuoteAmount, inputDecimals, int(inputDecimals))
-		maxIn := fmtForDisplay(intentMeta.Amounts.MaxAmountIn, inputDecimals, int(inputDecimals))
+		inputSymbol := snap.symm.SymFrom(intentMeta.TokenIn.Mint)
+		estimate := fmtAmount(intentMeta.Amounts.QuoteAmount, inputDecimals)
+		maxIn := fmtAmount(intentMeta.Amounts.MaxAmountIn, inputDecimals)
 		quoteRow[counterTokenCell+1] = fmt.Sprintf("est. pay %s %s", estimate, inputSymbol)
 		slippageRow[counterTokenCell+1] = fmt.Sprintf("max pay %s %s", maxIn, inputSymbol)
 	}
 	t.AppendRow(quoteRow)
 	t.AppendRow(slippageRow)
+	sandwich := sandwichRow(intentMeta, venue.FeeRate(), snap.slippagePct, snap.symm.SymFrom(intentMeta.TokenIn.Mint))
+	t.AppendRow(table.Row{"Sandwich risk", sandwich, sandwich}, table.RowConfig{AutoMerge: true, AutoMergeAlign: text.AlignLeft})
+	budget := computeBudget.resolved
+	if snap.budget != nil {
+		budget = *snap.budget
+	}
+	cost := tb.cache.costOf(cached, func() swapCost {
+		return estimateSwapCost(tb.ctx, tb.client, snap.wallet, intentMeta, budget, snap.symm)
+	}).String()
+	t.AppendRow(table.Row{"Est. cost", cost, cost}, table.RowConfig{AutoMerge: true, AutoMergeAlign: text.AlignLeft})
 	t.Render()
 	return builder.String(), intentMeta, nil
 }
 
+// quoteInstruction quotes instruction's absolute amount of targetMint on venue.
+func quoteInstruction(venue Venue, instruction *IntentInstruction, targetMint solana.PublicKey, slippage *big.Rat, balances []*PoolBalance) (*CPIntent, error) {
+	if err := checkBalances(balances); err != nil {
+		return nil, err
+	}
+	if instruction.Dir != SwapDirBuy && instruction.Dir != SwapDirSell {
+		return nil, fmt.Errorf("swap direction unknown for verb %s", instruction.Verb)
+	}
+	decimals := balances[tokenIndex(venue, targetMint)].Decimals
+	amount, err := fmtForMath(instruction.AmountStr, decimals)
+	if err != nil {
+		return nil, err
+	}
+	intent, err := venue.Quote(swapQuoteRequest{TargetMint: targetMint, Amount: amount, Dir: instruction.Dir, Slippage: slippage}, balances)
+	if err != nil {
+		return nil, err
+	}
+	intent.Instruction = instruction
+	return intent, nil
+}
+
+// tokenIndex is which of venue's mints mint is.
+func tokenIndex(venue Venue, mint solana.PublicKey) int {
+	if mint.Equals(venue.Mints()[1]) {
+		return 1
+	}
+	return 0
+}
+
 // poolBalances will fetch balances from all vaults concurrently or in parallel depending on how you configure Go exec env,
 // it's also cpu cache friendly. We don't side step the cache line, each Go routine owns and mutates its own data, no
 // shared data contention resulting in cache evictions
@@ -227,26 +482,6 @@ func poolBalances(ctx context.Context, client *rpc.Client, vaults []solana.Publi
 	return results, errs
 }
 
-func parseIntent(intentLine string) (*IntentInstruction, error) {
-	intentParts := strings.Fields(intentLine)
-	if len(intentParts) != 3 {
-		return nil, errors.New("intent instructions must be <verb> <amount> <token-symbol>")
-	}
-	verb := intentParts[0]
-	knownAmountStr := intentParts[1]
-	tokenSymbol := intentParts[2]
-	dir, err := verbToSwapDir(verb)
-	if err != nil {
-		return nil, err
-	}
-	return &IntentInstruction{
-		Verb:         verb,
-		AmountStr:    knownAmountStr,
-		Dir:          dir,
-		TargetSymbol: strings.ToUpper(tokenSymbol),
-	}, nil
-}
-
 func verbToSwapDir(verb string) (SwapDir, error) {
 	switch verb {
 	case "pay", "sell", "swap":
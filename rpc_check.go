@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+	"github.com/gagliardetto/solana-go/rpc/ws"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+/*
+NOTE(@hadydotai): Checking the RPCs.
+
+Which RPC to point -rpc at, and which ones to add with -quorum-rpc, used to be a guess. `rpc check` asks every one of
+them (-rpc, every -quorum-rpc, and any endpoint given as an argument) the same questions and ranks them:
+
+  - latency, -samples getLatestBlockhash calls one after another, p50 and max. It's the call every send starts with.
+  - slot lag, every endpoint's processed slot read at the same moment, how far each is behind the newest of them. An
+    endpoint more than -max-lag slots behind is serving reserves that much older.
+  - rate limiting, -burst getSlot calls all at once, how many came back 429. It's last so it can't slow the rest down,
+    and -burst 0 skips it for endpoints you'd rather not hammer.
+  - websocket, whether the endpoint's ws(s):// twin (see wsEndpointFor) sends a slot notification within -ws-timeout.
+    limit, tape and pool-index watch need it, everything else polls.
+
+The ranking puts the endpoints that answer, keep up and don't limit us first, in latency order, then the rate limited
+ones, then the ones behind, then the ones that didn't answer at all. The order it prints is the priority to give them:
+the first as -rpc, the ones after it as -quorum-rpc, `-json` has it as "order" for scripts that write the config.
+Endpoints are shown by host only, like the quorum's report, and when none answers the command fails as rpc_unavailable
+(see error_codes.go).
+*/
+
+// rpcEndpoint is an RPC to check, host is all of it that's shown.
+type rpcEndpoint struct {
+	host string
+	url  string
+}
+
+// rpcCheckEndpoints is -rpc, every -quorum-rpc and extra, in that order and each only once.
+func rpcCheckEndpoints(rpcEP string, quorum []string, extra []string) ([]rpcEndpoint, error) {
+	var endpoints []rpcEndpoint
+	seen := map[string]bool{}
+	for _, ep := range append(append([]string{rpcEP}, quorum...), extra...) {
+		u, err := url.Parse(ep)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("%q isn't an http(s) RPC endpoint", ep)
+		}
+		if seen[ep] {
+			continue
+		}
+		seen[ep] = true
+		endpoints = append(endpoints, rpcEndpoint{host: u.Host, url: ep})
+	}
+	return endpoints, nil
+}
+
+// rpcHealth is what checking one endpoint found.
+type rpcHealth struct {
+	endpoint rpcEndpoint
+	latency  latencyStats
+	failed   int   // samples that failed
+	err      error // the last failure, when no sample got an answer
+	slot     uint64
+	lag      uint64
+	burst    int // 0 when it wasn't tried
+	limited  int
+	wsFirst  time.Duration // until the first slot notification
+	wsErr    error
+}
+
+func (h rpcHealth) down() bool { return h.latency.count == 0 }
+
+// tier orders endpoints before their latency does: answering and keeping up, rate limited, behind, down.
+func (h rpcHealth) tier(maxLag uint64) int {
+	switch {
+	case h.down():
+		return 3
+	case h.lag > maxLag:
+		return 2
+	case h.limited > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (h rpcHealth) verdict(maxLag uint64) string {
+	switch h.tier(maxLag) {
+	case 3:
+		return "down"
+	case 2:
+		return fmt.Sprintf("behind %d slots", h.lag)
+	case 1:
+		return fmt.Sprintf("rate limited (%d of %d)", h.limited, h.burst)
+	}
+	if h.failed > 0 {
+		return fmt.Sprintf("ok, %d samples failed", h.failed)
+	}
+	return "ok"
+}
+
+// redact keeps the endpoint's URL, and whatever key is in it, out of msg.
+func (e rpcEndpoint) redact(msg string) string {
+	return strings.ReplaceAll(msg, e.url, e.host)
+}
+
+// rpcCheck is how `rpc check` asks the endpoints.
+type rpcCheck struct {
+	samples   int
+	burst     int
+	wsTimeout time.Duration
+}
+
+// run checks every endpoint, at the same time, and returns them ranked.
+func (c rpcCheck) run(ctx context.Context, endpoints []rpcEndpoint, maxLag uint64) []rpcHealth {
+	health := make([]rpcHealth, len(endpoints))
+	clients := make([]*rpc.Client, len(endpoints))
+	each := func(f func(i int)) {
+		var wg sync.WaitGroup
+		for i := range endpoints {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				f(i)
+			}()
+		}
+		wg.Wait()
+	}
+	each(func(i int) {
+		health[i].endpoint = endpoints[i]
+		clients[i] = rpc.New(endpoints[i].url)
+		c.sample(ctx, clients[i], &health[i])
+		if !health[i].down() {
+			health[i].wsFirst, health[i].wsErr = c.firstSlot(ctx, endpoints[i].url)
+		}
+	})
+	// The slots are read together so the lag is the endpoints', not the time between reading them.
+	each(func(i int) {
+		if !health[i].down() {
+			if slot, err := clients[i].GetSlot(ctx, rpc.CommitmentProcessed); err == nil {
+				health[i].slot = slot
+			}
+		}
+	})
+	var best uint64
+	for _, h := range health {
+		best = max(best, h.slot)
+	}
+	for i := range health {
+		if !health[i].down() {
+			health[i].lag = best - health[i].slot
+		}
+	}
+	if c.burst > 0 {
+		each(func(i int) {
+			if !health[i].down() {
+				health[i].burst, health[i].limited = c.burst, c.rateLimited(ctx, clients[i])
+			}
+		})
+	}
+	rankRPCHealth(health, maxLag)
+	return health
+}
+
+// sample times getLatestBlockhash -samples times, one after another.
+func (c rpcCheck) sample(ctx context.Context, client *rpc.Client, h *rpcHealth) {
+	var took []time.Duration
+	for range c.samples {
+		start := time.Now()
+		if _, err := client.GetLatestBlockhash(ctx, rpc.CommitmentConfirmed); err != nil {
+			h.failed++
+			h.err = err
+			continue
+		}
+		took = append(took, time.Since(start))
+	}
+	h.latency = newLatencyStats(took)
+	if len(took) > 0 {
+		h.err = nil
+	}
+}
+
+// rateLimited fires -burst getSlot calls at once and counts the ones turned away with a 429.
+func (c rpcCheck) rateLimited(ctx context.Context, client *rpc.Client) int {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		limited int
+	)
+	for range c.burst {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.GetSlot(ctx, rpc.CommitmentProcessed)
+			var httpErr *jsonrpc.HTTPError
+			if errors.As(err, &httpErr) && httpErr.Code == http.StatusTooManyRequests {
+				mu.Lock()
+				limited++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return limited
+}
+
+// firstSlot is how long the endpoint's websocket takes to send its first slot notification.
+func (c rpcCheck) firstSlot(ctx context.Context, rpcEP string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.wsTimeout)
+	defer cancel()
+	start := time.Now()
+	client, err := ws.Connect(ctx, wsEndpointFor(rpcEP))
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+	sub, err := client.SlotSubscribe()
+	if err != nil {
+		return 0, err
+	}
+	defer sub.Unsubscribe()
+	if _, err := sub.Recv(ctx); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// rankRPCHealth sorts health best first.
+func rankRPCHealth(health []rpcHealth, maxLag uint64) {
+	sort.SliceStable(health, func(i, j int) bool {
+		a, b := health[i], health[j]
+		if ta, tb := a.tier(maxLag), b.tier(maxLag); ta != tb {
+			return ta < tb
+		}
+		return a.latency.p50 < b.latency.p50
+	})
+}
+
+func renderRPCHealth(health []rpcHealth, samples int, maxLag uint64) string {
+	builder := &strings.Builder{}
+	ms := func(d time.Duration) string { return fmt.Sprintf("%.1fms", float64(d.Microseconds())/1000) }
+	t := table.NewWriter()
+	t.SetOutputMirror(builder)
+	t.SetTitle(fmt.Sprintf("RPC Health (%d endpoints, %d samples each)", len(health), samples))
+	t.AppendHeader(table.Row{"#", "Endpoint", "p50", "Max", "Failed", "Slot", "Lag", "Burst", "WebSocket", "Verdict"})
+	var errs []string
+	for i, h := range health {
+		if h.down() {
+			t.AppendRow(table.Row{i + 1, h.endpoint.host, "-", "-", fmt.Sprintf("%d/%d", h.failed, samples), "-", "-", "-", "-", h.verdict(maxLag)})
+			errs = append(errs, fmt.Sprintf("%s: %s", h.endpoint.host, h.endpoint.redact(h.err.Error())))
+			continue
+		}
+		burst := "-"
+		if h.burst > 0 {
+			burst = fmt.Sprintf("%d/%d limited", h.limited, h.burst)
+		}
+		wsCell := "ok, " + ms(h.wsFirst)
+		if h.wsErr != nil {
+			wsCell = "no"
+			errs = append(errs, fmt.Sprintf("%s websocket: %s", h.endpoint.host, h.endpoint.redact(h.wsErr.Error())))
+		}
+		t.AppendRow(table.Row{i + 1, h.endpoint.host, ms(h.latency.p50), ms(h.latency.max), fmt.Sprintf("%d/%d", h.failed, samples),
+			h.slot, h.lag, burst, wsCell, h.verdict(maxLag)})
+	}
+	t.Render()
+	for _, e := range errs {
+		fmt.Fprintln(builder, e)
+	}
+	order := make([]string, len(health))
+	for i, h := range health {
+		order[i] = h.endpoint.host
+	}
+	fmt.Fprintf(builder, "Priority: %s, the first as -rpc and the rest as -quorum-rpc.\n", strings.Join(order, ", "))
+	return builder.String()
+}
+
+type rpcHealthJSON struct {
+	Rank           int     `json:"rank"`
+	Endpoint       string  `json:"endpoint"`
+	P50Ms          float64 `json:"p50Ms"`
+	MaxMs          float64 `json:"maxMs"`
+	FailedSamples  int     `json:"failedSamples"`
+	Slot           uint64  `json:"slot,omitempty"`
+	SlotLag        uint64  `json:"slotLag"`
+	Burst          int     `json:"burst,omitempty"`
+	RateLimited    int     `json:"rateLimited"`
+	WebSocket      bool    `json:"websocket"`
+	WebSocketError string  `json:"websocketError,omitempty"`
+	Verdict        string  `json:"verdict"`
+	Error          string  `json:"error,omitempty"`
+	Code           string  `json:"code,omitempty"`
+}
+
+type rpcCheckJSON struct {
+	Endpoints []rpcHealthJSON `json:"endpoints"`
+	Order     []string        `json:"order"` // hosts, best first
+}
+
+func newRPCCheckJSON(health []rpcHealth, maxLag uint64) rpcCheckJSON {
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	out := rpcCheckJSON{Endpoints: make([]rpcHealthJSON, len(health)), Order: make([]string, len(health))}
+	for i, h := range health {
+		e := rpcHealthJSON{
+			Rank: i + 1, Endpoint: h.endpoint.host, P50Ms: ms(h.latency.p50), MaxMs: ms(h.latency.max), FailedSamples: h.failed,
+			Slot: h.slot, SlotLag: h.lag, Burst: h.burst, RateLimited: h.limited, WebSocket: !h.down() && h.wsErr == nil,
+			Verdict: h.verdict(maxLag),
+		}
+		if h.wsErr != nil {
+			e.WebSocketError = h.endpoint.redact(h.wsErr.Error())
+		}
+		if h.err != nil {
+			e.Error, e.Code = h.endpoint.redact(h.err.Error()), errorCode(classifyRPC(h.err))
+		}
+		out.Endpoints[i], out.Order[i] = e, h.endpoint.host
+	}
+	return out
+}
+
+func runRPCCommand(args []string) error {
+	return dispatchSubcommand("rpc", map[string]func([]string) error{
+		"check": runRPCCheckCommand,
+	}, args)
+}
+
+func runRPCCheckCommand(args []string) error {
+	fs := flag.NewFlagSet("rpc check", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: rpc check [flags] [endpoint...]\n")
+		fs.PrintDefaults()
+	}
+	nf := addNetworkFlags(fs)
+	var (
+		samples   = fs.Int("samples", 5, "getLatestBlockhash calls to time on each endpoint")
+		burst     = fs.Int("burst", 20, "getSlot calls to fire at each endpoint at once to see whether it rate limits, 0 skips it")
+		maxLag    = fs.Uint64("max-lag", 10, "Slots an endpoint can be behind the newest of them before it's ranked as behind")
+		wsTimeout = fs.Duration("ws-timeout", 5*time.Second, "How long to wait for an endpoint's websocket to send a slot")
+		asJSON    = fs.Bool("json", false, "Print the ranking as JSON instead of a table")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, nf.specs())
+	if *samples < 1 || *burst < 0 {
+		return errors.New("-samples has to be at least 1 and -burst can't be negative")
+	}
+	rpcEP := *nf.rpcEP
+	if rpcEP == "" {
+		rpcEP = networks[*nf.network][DefaultRPC].(string)
+	}
+	endpoints, err := rpcCheckEndpoints(rpcEP, rpcQuorum.urls, fs.Args())
+	if err != nil {
+		return err
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+
+	check := rpcCheck{samples: *samples, burst: *burst, wsTimeout: *wsTimeout}
+	health := check.run(ctx, endpoints, *maxLag)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(newRPCCheckJSON(health, *maxLag)); err != nil {
+			return err
+		}
+	} else {
+		fmt.Print(renderRPCHealth(health, *samples, *maxLag))
+	}
+	if health[0].down() {
+		return classify(ErrRPCUnavailable, fmt.Errorf("none of the %d endpoints answered", len(health)))
+	}
+	return nil
+}
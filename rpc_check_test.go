@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// rpcCheckServer answers getLatestBlockhash after delay and getSlot with slot, turning getSlot away with a 429 after
+// the first limitAfter of them when limitAfter isn't 0.
+func rpcCheckServer(t *testing.T, delay time.Duration, slot uint64, limitAfter int32) *httptest.Server {
+	t.Helper()
+	var slots atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		switch req.Method {
+		case "getLatestBlockhash":
+			time.Sleep(delay)
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"context":{"slot":%d},"value":{"blockhash":"11111111111111111111111111111111","lastValidBlockHeight":1}}}`, req.ID, slot)
+		case "getSlot":
+			if n := slots.Add(1); limitAfter > 0 && n > limitAfter {
+				http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+				return
+			}
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":%d}`, req.ID, slot)
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRPCCheck(t *testing.T) {
+	fast := rpcCheckServer(t, 0, 1000, 0)
+	slow := rpcCheckServer(t, 20*time.Millisecond, 1000, 0)
+	limited := rpcCheckServer(t, 0, 1000, 3)
+	behind := rpcCheckServer(t, 0, 950, 0)
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	endpoints, err := rpcCheckEndpoints(down.URL, []string{behind.URL, slow.URL, slow.URL}, []string{limited.URL, fast.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(endpoints) != 5 {
+		t.Fatalf("%d endpoints, want the repeated one once", len(endpoints))
+	}
+	health := rpcCheck{samples: 3, burst: 5, wsTimeout: 200 * time.Millisecond}.run(context.Background(), endpoints, 10)
+
+	var order []string
+	for _, h := range health {
+		order = append(order, h.endpoint.url)
+	}
+	want := []string{fast.URL, slow.URL, limited.URL, behind.URL, down.URL}
+	if strings.Join(order, " ") != strings.Join(want, " ") {
+		t.Fatalf("ranked\n%v\nwant\n%v", order, want)
+	}
+	verdicts := []string{"ok", "ok", "rate limited (3 of 5)", "behind 50 slots", "down"}
+	for i, h := range health {
+		if got := h.verdict(10); got != verdicts[i] {
+			t.Errorf("%s: %q, want %q", h.endpoint.host, got, verdicts[i])
+		}
+		if !h.down() && h.wsErr == nil {
+			t.Errorf("%s: a plain HTTP server has no websocket", h.endpoint.host)
+		}
+	}
+
+	report := renderRPCHealth(health, 3, 10)
+	if !strings.Contains(report, "Priority: "+strings.TrimPrefix(fast.URL, "http://")) || strings.Contains(report, "http://") {
+		t.Errorf("report ranks by host only:\n%s", report)
+	}
+	out := newRPCCheckJSON(health, 10)
+	if out.Order[0] != strings.TrimPrefix(fast.URL, "http://") || out.Endpoints[4].Code != "rpc_unavailable" || out.Endpoints[2].RateLimited != 3 {
+		t.Errorf("JSON %+v", out)
+	}
+
+	if _, err := rpcCheckEndpoints("wss://example.com", nil, nil); err == nil {
+		t.Error("a websocket URL isn't an RPC endpoint")
+	}
+}
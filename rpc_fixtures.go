@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+/*
+NOTE(@hadydotai): Recording and replaying RPC.
+
+Everything that reads the chain takes an *rpc.Client, and under it solana-go keeps a JSON-RPC transport
+(rpc.JSONRPCClient) that every call goes through as a method, its params and a result. That's the seam. With
+-rpc-record the real transport is wrapped and every call's method, params and raw result (or RPC error) is written to
+a fixture file as it comes back. With -rpc-replay there's no network at all, calls are answered from such a file.
+
+A call is matched on its method and its params exactly as they'd go over the wire, so a fixture only answers the calls
+that were recorded. Asking for anything else is an error naming the call, never a silent trip to a real node. The same
+call made more than once (balances during a -watch, signature statuses while waiting on a send) is answered in the
+order it was recorded, and the last answer keeps being given once they run out.
+
+Transport failures (timeouts, refused connections) aren't recorded, they aren't the chain's answer. HTTP that doesn't
+go through the RPC (Jupiter, price sources, webhooks) isn't covered either.
+
+The file is rewritten after every call, a run that dies half way still leaves the calls it made.
+*/
+
+type rpcFixtureFlags struct {
+	record *string
+	replay *string
+
+	// wrap, when set, goes around whichever transport the client ends up with (see bench.go).
+	wrap func(rpc.JSONRPCClient) rpc.JSONRPCClient
+}
+
+func addRPCFixtureFlags(fs *flag.FlagSet) *rpcFixtureFlags {
+	return &rpcFixtureFlags{
+		record: fs.String("rpc-record", "", "Record every RPC call and its answer to this fixture file"),
+		replay: fs.String("rpc-replay", "", "Answer RPC calls from this fixture file (see -rpc-record) instead of the network"),
+	}
+}
+
+// dial is the client for endpoint, recording or replaying as the flags ask.
+func (ff *rpcFixtureFlags) dial(endpoint string) *rpc.Client {
+	var transport rpc.JSONRPCClient
+	switch {
+	case *ff.record != "" && *ff.replay != "":
+		log.Fatalln("-rpc-record and -rpc-replay don't go together")
+	case *ff.replay != "":
+		fixtures, err := loadRPCFixtures(*ff.replay)
+		if err != nil {
+			log.Fatalf("%s\n", err)
+		}
+		transport = newReplayTransport(fixtures)
+	case *ff.record != "":
+		transport = newRecordingTransport(jsonrpc.NewClient(endpoint), *ff.record)
+	case ff.wrap == nil:
+		return rpc.New(endpoint)
+	default:
+		transport = jsonrpc.NewClient(endpoint)
+	}
+	if ff.wrap != nil {
+		transport = ff.wrap(transport)
+	}
+	return rpc.NewWithCustomRPCClient(transport)
+}
+
+// rpcCall is one recorded call. Exactly one of Result and Error is set.
+type rpcCall struct {
+	Method string            `json:"method"`
+	Params json.RawMessage   `json:"params"`
+	Result json.RawMessage   `json:"result,omitempty"`
+	Error  *jsonrpc.RPCError `json:"error,omitempty"`
+}
+
+type rpcFixtures struct {
+	Calls []rpcCall `json:"calls"`
+}
+
+func loadRPCFixtures(path string) (*rpcFixtures, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading rpc fixtures: %w", err)
+	}
+	var fixtures rpcFixtures
+	if err := json.Unmarshal(raw, &fixtures); err != nil {
+		return nil, fmt.Errorf("rpc fixtures %s are corrupt: %w", path, err)
+	}
+	return &fixtures, nil
+}
+
+func (f *rpcFixtures) save(path string) error {
+	raw, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(raw, '\n'), 0o644)
+}
+
+// rpcCallKey is what a call is matched on, its method and its params compacted.
+func rpcCallKey(method string, params json.RawMessage) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := json.Compact(buf, params); err != nil {
+		return "", err
+	}
+	return method + " " + buf.String(), nil
+}
+
+func marshalParams(params []any) (json.RawMessage, error) {
+	if params == nil {
+		return json.RawMessage("null"), nil
+	}
+	return json.Marshal(params)
+}
+
+// recordingTransport passes every call through to next and records it.
+type recordingTransport struct {
+	next rpc.JSONRPCClient
+	path string
+	save func(*rpcFixtures) error // writes what's recorded so far to path
+
+	mu       sync.Mutex
+	fixtures rpcFixtures
+}
+
+func newRecordingTransport(next rpc.JSONRPCClient, path string) *recordingTransport {
+	return &recordingTransport{next: next, path: path, save: func(f *rpcFixtures) error { return f.save(path) }}
+}
+
+func (rt *recordingTransport) CallForInto(ctx context.Context, out any, method string, params []any) error {
+	encoded, err := marshalParams(params)
+	if err != nil {
+		return fmt.Errorf("recording %s: %w", method, err)
+	}
+	var result json.RawMessage
+	callErr := rt.next.CallForInto(ctx, &result, method, params)
+	call := rpcCall{Method: method, Params: encoded}
+	var rpcErr *jsonrpc.RPCError
+	switch {
+	case errors.As(callErr, &rpcErr):
+		call.Error = rpcErr
+	case callErr != nil:
+		return callErr
+	default:
+		if result == nil {
+			result = json.RawMessage("null")
+		}
+		call.Result = result
+	}
+
+	rt.mu.Lock()
+	rt.fixtures.Calls = append(rt.fixtures.Calls, call)
+	saveErr := rt.save(&rt.fixtures)
+	rt.mu.Unlock()
+	if saveErr != nil {
+		log.Printf("warning: recording rpc fixtures to %s failed: %v", rt.path, saveErr)
+	}
+	if callErr != nil {
+		return callErr
+	}
+	return json.Unmarshal(result, out)
+}
+
+func (rt *recordingTransport) CallWithCallback(ctx context.Context, method string, params []any, callback func(*http.Request, *http.Response) error) error {
+	return fmt.Errorf("rpc %s can't be recorded, only plain calls are", method)
+}
+
+func (rt *recordingTransport) CallBatch(ctx context.Context, requests jsonrpc.RPCRequests) (jsonrpc.RPCResponses, error) {
+	return nil, errors.New("batched rpc calls can't be recorded, only plain calls are")
+}
+
+// replayTransport answers calls from fixtures and never touches the network.
+type replayTransport struct {
+	mu    sync.Mutex
+	calls map[string][]rpcCall
+	next  map[string]int
+}
+
+func newReplayTransport(fixtures *rpcFixtures) *replayTransport {
+	rt := &replayTransport{calls: map[string][]rpcCall{}, next: map[string]int{}}
+	for _, call := range fixtures.Calls {
+		key, err := rpcCallKey(call.Method, call.Params)
+		if err != nil {
+			continue // a hand edited entry that can't match anything anyway
+		}
+		rt.calls[key] = append(rt.calls[key], call)
+	}
+	return rt
+}
+
+func (rt *replayTransport) CallForInto(ctx context.Context, out any, method string, params []any) error {
+	encoded, err := marshalParams(params)
+	if err != nil {
+		return fmt.Errorf("replaying %s: %w", method, err)
+	}
+	key, err := rpcCallKey(method, encoded)
+	if err != nil {
+		return fmt.Errorf("replaying %s: %w", method, err)
+	}
+	rt.mu.Lock()
+	calls := rt.calls[key]
+	i := rt.next[key]
+	if i < len(calls)-1 {
+		rt.next[key] = i + 1
+	}
+	rt.mu.Unlock()
+	if len(calls) == 0 {
+		return fmt.Errorf("no rpc fixture for %s %s", method, encoded)
+	}
+	if call := calls[i]; call.Error != nil {
+		return call.Error
+	}
+	return json.Unmarshal(calls[i].Result, out)
+}
+
+func (rt *replayTransport) CallWithCallback(ctx context.Context, method string, params []any, callback func(*http.Request, *http.Response) error) error {
+	return fmt.Errorf("rpc %s can't be replayed, only plain calls are", method)
+}
+
+func (rt *replayTransport) CallBatch(ctx context.Context, requests jsonrpc.RPCRequests) (jsonrpc.RPCResponses, error) {
+	return nil, errors.New("batched rpc calls can't be replayed, only plain calls are")
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+type chainAccount struct {
+	owner solana.PublicKey
+	data  []byte
+}
+
+// chainServer is a node holding accounts, answering getAccountInfo, getMultipleAccounts and getBalance from them and
+// getTokenAccountBalance from balances. Every request it serves is counted in served.
+func chainServer(t *testing.T, accounts map[solana.PublicKey]chainAccount, balances map[solana.PublicKey]*PoolBalance, served *atomic.Int64) *httptest.Server {
+	t.Helper()
+	encode := func(key solana.PublicKey) string {
+		acc, ok := accounts[key]
+		if !ok {
+			return "null"
+		}
+		return fmt.Sprintf(`{"data":[%q,"base64"],"executable":false,"lamports":1,"owner":%q,"rentEpoch":0}`,
+			base64.StdEncoding.EncodeToString(acc.data), acc.owner)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served.Add(1)
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Params) == 0 {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		result := func(value string) {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"context":{"slot":1},"value":%s}}`, req.ID, value)
+		}
+		switch req.Method {
+		case "getAccountInfo", "getBalance", "getTokenAccountBalance":
+			var key string
+			json.Unmarshal(req.Params[0], &key)
+			pk := solana.MustPublicKeyFromBase58(key)
+			switch req.Method {
+			case "getAccountInfo":
+				result(encode(pk))
+			case "getBalance":
+				result(fmt.Sprint(len(accounts[pk].data)))
+			default:
+				bal, ok := balances[pk]
+				if !ok {
+					fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"error":{"code":-32602,"message":"Invalid param: could not find account"}}`, req.ID)
+					return
+				}
+				result(fmt.Sprintf(`{"amount":"%s","decimals":%d,"uiAmountString":"0"}`, bal.Balance, bal.Decimals))
+			}
+		case "getMultipleAccounts":
+			var keys []string
+			json.Unmarshal(req.Params[0], &keys)
+			values := make([]string, len(keys))
+			for i, key := range keys {
+				values[i] = encode(solana.MustPublicKeyFromBase58(key))
+			}
+			result("[" + strings.Join(values, ",") + "]")
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRecordAndReplayRPC(t *testing.T) {
+	present, missing := snapshotKey(70), snapshotKey(71)
+	var served atomic.Int64
+	srv := chainServer(t, map[solana.PublicKey]chainAccount{present: {owner: solana.SystemProgramID, data: make([]byte, 42)}},
+		map[solana.PublicKey]*PoolBalance{}, &served)
+	path := filepath.Join(t.TempDir(), "calls.json")
+	ctx := context.Background()
+
+	recording := rpc.NewWithCustomRPCClient(newRecordingTransport(jsonrpc.NewClient(srv.URL), path))
+	bal, err := recording.GetBalance(ctx, present, rpc.CommitmentConfirmed)
+	if err != nil || bal.Value != 42 {
+		t.Fatalf("recording getBalance = %v, %v", bal, err)
+	}
+	if _, err := recording.GetTokenAccountBalance(ctx, missing, rpc.CommitmentConfirmed); !isAccountMissingErr(err) {
+		t.Fatalf("recording a missing account: %v", err)
+	}
+	srv.Close()
+
+	fixtures, err := loadRPCFixtures(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures.Calls) != 2 || fixtures.Calls[1].Error == nil {
+		t.Fatalf("recorded %+v, want a result and an error", fixtures.Calls)
+	}
+	before := served.Load()
+	replay := rpc.NewWithCustomRPCClient(newReplayTransport(fixtures))
+	if bal, err := replay.GetBalance(ctx, present, rpc.CommitmentConfirmed); err != nil || bal.Value != 42 {
+		t.Errorf("replayed getBalance = %v, %v", bal, err)
+	}
+	// The node's error comes back as the same RPC error, so callers tell a missing account apart the same way.
+	if _, err := replay.GetTokenAccountBalance(ctx, missing, rpc.CommitmentConfirmed); !isAccountMissingErr(err) {
+		t.Errorf("replayed missing account: %v", err)
+	}
+	// Same method, different params, wasn't recorded.
+	if _, err := replay.GetBalance(ctx, missing, rpc.CommitmentConfirmed); err == nil || !strings.Contains(err.Error(), "no rpc fixture for getBalance") {
+		t.Errorf("unrecorded call: %v", err)
+	}
+	if served.Load() != before {
+		t.Error("replaying went to the node")
+	}
+}
+
+func TestReplayRepeatedCalls(t *testing.T) {
+	key := snapshotKey(72)
+	params, _ := marshalParams([]any{key, rpc.M{"commitment": rpc.CommitmentConfirmed}})
+	fixtures := &rpcFixtures{}
+	for _, lamports := range []string{"1", "2"} {
+		fixtures.Calls = append(fixtures.Calls, rpcCall{Method: "getBalance", Params: params,
+			Result: json.RawMessage(`{"context":{"slot":1},"value":` + lamports + `}`)})
+	}
+	client := rpc.NewWithCustomRPCClient(newReplayTransport(fixtures))
+
+	// Answered in the order they were recorded, the last answer sticks.
+	for _, want := range []uint64{1, 2, 2} {
+		bal, err := client.GetBalance(context.Background(), key, rpc.CommitmentConfirmed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bal.Value != want {
+			t.Errorf("getBalance = %d, want %d", bal.Value, want)
+		}
+	}
+}
+
+func TestRPCFixturesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calls.json")
+	if _, err := loadRPCFixtures(path); err == nil {
+		t.Error("a missing fixture file should fail to load")
+	}
+	var rpcErr *jsonrpc.RPCError
+	fixtures := &rpcFixtures{Calls: []rpcCall{{Method: "getSlot", Params: json.RawMessage("[]"), Error: &jsonrpc.RPCError{Code: -32005, Message: "node is behind"}}}}
+	if err := fixtures.save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadRPCFixtures(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = rpc.NewWithCustomRPCClient(newReplayTransport(loaded)).GetSlot(context.Background(), "")
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -32005 {
+		t.Errorf("replayed getSlot error = %v", err)
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+/*
+NOTE(@hadydotai): Run snapshots.
+
+-rpc-record keeps the calls a run made, enough to replay it but not much to read. A bug report wants to say what the
+run saw and what it made of it. -snapshot <file> records the run the same way (it's the same transport underneath) and
+writes, next to the calls:
+
+  - the network, pool, intent and wallet it ran with
+  - accounts, every account a call read (pool, AmmConfig, mints, vaults, metadata, the wallet's), with its owner,
+    lamports and size, the data itself stays in the call it came back in
+  - quotes, the intent as it was quoted, in the JSON the HTTP API answers /quote with
+
+-replay <file> runs it again offline: -network, -pool and -intent come from the snapshot unless they're passed, calls
+are answered from it like -rpc-replay does, percentages are of the recorded wallet's balances, and the intent is
+quoted and checked against the recorded quote. A quote that comes out different is an error, so a snapshot doubles as
+a test, same chain state in, same quote out. Replay only quotes, nothing is signed or sent.
+
+The quotes are the ones -no-tui computes, or the one the TUI hands back when exporting a bundle. Like -rpc-record only
+the RPC is covered, Jupiter, price sources and the enhanced APIs still go out over HTTP.
+*/
+
+type snapshotFlags struct {
+	record *string
+	replay *string
+}
+
+func addSnapshotFlags(fs *flag.FlagSet) *snapshotFlags {
+	return &snapshotFlags{
+		record: fs.String("snapshot", "", "Write every account the run reads, the quotes it computes and the RPC calls behind them to this file"),
+		replay: fs.String("replay", "", "Quote again offline from a -snapshot file and check the quote comes out the same"),
+	}
+}
+
+// runSnapshot is what -snapshot writes and -replay reads. Calls is what -rpc-replay reads too.
+type runSnapshot struct {
+	Network  string            `json:"network"`
+	Pool     string            `json:"pool"`
+	Intent   string            `json:"intent,omitempty"`
+	Wallet   string            `json:"wallet,omitempty"`
+	Quotes   []quoteResponse   `json:"quotes,omitempty"`
+	Accounts []snapshotAccount `json:"accounts"`
+	Calls    []rpcCall         `json:"calls"`
+}
+
+// snapshotAccount is an account as a call read it.
+type snapshotAccount struct {
+	Address    string `json:"address"`
+	Owner      string `json:"owner"`
+	Lamports   uint64 `json:"lamports"`
+	Size       int    `json:"size"`
+	Executable bool   `json:"executable,omitempty"`
+}
+
+// snapshotAccounts is every account calls read, the last read of each, by address.
+func snapshotAccounts(calls []rpcCall) []snapshotAccount {
+	seen := map[string]snapshotAccount{}
+	add := func(address string, acc *rpc.Account) {
+		if acc == nil {
+			return
+		}
+		seen[address] = snapshotAccount{
+			Address:    address,
+			Owner:      acc.Owner.String(),
+			Lamports:   acc.Lamports,
+			Size:       len(acc.Data.GetBinary()),
+			Executable: acc.Executable,
+		}
+	}
+	for _, call := range calls {
+		if call.Error != nil {
+			continue
+		}
+		switch call.Method {
+		case "getAccountInfo":
+			var params []json.RawMessage
+			var address string
+			var res rpc.GetAccountInfoResult
+			if json.Unmarshal(call.Params, &params) != nil || len(params) == 0 || json.Unmarshal(params[0], &address) != nil || json.Unmarshal(call.Result, &res) != nil {
+				continue
+			}
+			add(address, res.Value)
+		case "getMultipleAccounts":
+			var params []json.RawMessage
+			var addresses []string
+			var res rpc.GetMultipleAccountsResult
+			if json.Unmarshal(call.Params, &params) != nil || len(params) == 0 || json.Unmarshal(params[0], &addresses) != nil || json.Unmarshal(call.Result, &res) != nil {
+				continue
+			}
+			for i, acc := range res.Value {
+				if i < len(addresses) {
+					add(addresses[i], acc)
+				}
+			}
+		}
+	}
+	accounts := make([]snapshotAccount, 0, len(seen))
+	for _, acc := range seen {
+		accounts = append(accounts, acc)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Address < accounts[j].Address })
+	return accounts
+}
+
+func (s *runSnapshot) save(path string) error {
+	s.Accounts = snapshotAccounts(s.Calls)
+	raw, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(raw, '\n'), 0o644)
+}
+
+func loadRunSnapshot(path string) (*runSnapshot, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading snapshot: %w", err)
+	}
+	var snap runSnapshot
+	if err := json.Unmarshal(raw, &snap); err != nil {
+		return nil, fmt.Errorf("snapshot %s is corrupt: %w", path, err)
+	}
+	return &snap, nil
+}
+
+// snapshotRecorder writes a runSnapshot to path as the run goes, rewritten after every call and quote like
+// -rpc-record's fixtures are.
+type snapshotRecorder struct {
+	path string
+
+	mu   sync.Mutex
+	snap runSnapshot
+}
+
+func newSnapshotRecorder(path, network, pool, intent string) *snapshotRecorder {
+	return &snapshotRecorder{path: path, snap: runSnapshot{Network: network, Pool: pool, Intent: intent}}
+}
+
+// dial is a client for endpoint whose every call ends up in the snapshot.
+func (sr *snapshotRecorder) dial(endpoint string) *rpc.Client {
+	rt := newRecordingTransport(jsonrpc.NewClient(endpoint), sr.path)
+	rt.save = func(f *rpcFixtures) error {
+		sr.mu.Lock()
+		defer sr.mu.Unlock()
+		sr.snap.Calls = slices.Clone(f.Calls)
+		return sr.snap.save(sr.path)
+	}
+	return rpc.NewWithCustomRPCClient(rt)
+}
+
+// useWallet records whose balances the run quoted percentages of.
+func (sr *snapshotRecorder) useWallet(wallet solana.PublicKey) {
+	if sr == nil {
+		return
+	}
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.snap.Wallet = wallet.String()
+}
+
+// addQuote records a quote the run computed, a nil recorder (no -snapshot) does nothing.
+func (sr *snapshotRecorder) addQuote(q quoteResponse) {
+	if sr == nil {
+		return
+	}
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.snap.Quotes = append(sr.snap.Quotes, q)
+	if err := sr.snap.save(sr.path); err != nil {
+		log.Printf("warning: writing the snapshot to %s failed: %v", sr.path, err)
+	}
+}
+
+// replayClient answers from the snapshot's calls and never touches the network.
+func (s *runSnapshot) replayClient() *rpc.Client {
+	return rpc.NewWithCustomRPCClient(newReplayTransport(&rpcFixtures{Calls: s.Calls}))
+}
+
+// fill sets -network, -pool and -intent to what the snapshot ran with, unless they were passed on fs.
+func (s *runSnapshot) fill(fs *flag.FlagSet, network, pool, intent *string) {
+	passed := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { passed[f.Name] = true })
+	for name, v := range map[string]struct {
+		flag     *string
+		recorded string
+	}{
+		"network": {network, s.Network},
+		"pool":    {pool, s.Pool},
+		"intent":  {intent, s.Intent},
+	} {
+		if !passed[name] && v.recorded != "" {
+			*v.flag = v.recorded
+		}
+	}
+}
+
+// checkQuote is why q isn't the quote the snapshot recorded for the same pool and intent, nil when it is.
+func (s *runSnapshot) checkQuote(q quoteResponse) error {
+	for _, want := range s.Quotes {
+		if want.Pool != q.Pool || want.Intent != q.Intent {
+			continue
+		}
+		var diffs []string
+		diff := func(what, got, recorded string) {
+			if got != recorded {
+				diffs = append(diffs, fmt.Sprintf("%s %s, the snapshot has %s", what, orNone(got), orNone(recorded)))
+			}
+		}
+		diff("kind", q.SwapKind, want.SwapKind)
+		diff("pay", rawAmount(q.Pay), rawAmount(want.Pay))
+		diff("receive", rawAmount(q.Receive), rawAmount(want.Receive))
+		diff("min receive", rawAmount(q.MinReceive), rawAmount(want.MinReceive))
+		diff("max pay", rawAmount(q.MaxPay), rawAmount(want.MaxPay))
+		diff("price impact", q.PriceImpact, want.PriceImpact)
+		if len(diffs) > 0 {
+			return fmt.Errorf("the replayed quote for %q on %s differs from the snapshot: %s", q.Intent, q.Pool, strings.Join(diffs, ", "))
+		}
+		return nil
+	}
+	return fmt.Errorf("the snapshot has no quote for %q on %s to check against", q.Intent, q.Pool)
+}
+
+func rawAmount(a *amountJSON) string {
+	if a == nil {
+		return ""
+	}
+	return a.Raw
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
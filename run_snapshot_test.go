@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestRunSnapshotReplayed(t *testing.T) {
+	var served atomic.Int64
+	endpoint, addr := snapshotChain(t, &served)
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	quote := func(client *rpc.Client) quoteResponse {
+		t.Helper()
+		flow := &swapFlow{client: client, network: "devnet", intentLine: "sell 1 SOL", slippagePct: 0.5, out: &bytes.Buffer{}}
+		builder, _, err := flow.openPool(context.Background(), addr.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, intent, err := flow.quote(builder, func(symbol, mint string) (bool, error) { return false, nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+		return newQuoteResponse(builder, intent, flow.slippagePct)
+	}
+
+	recorder := newSnapshotRecorder(path, "devnet", addr.String(), "sell 1 SOL")
+	recorder.addQuote(quote(recorder.dial(endpoint)))
+
+	snap, err := loadRunSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.Network != "devnet" || snap.Pool != addr.String() || len(snap.Quotes) != 1 || len(snap.Calls) == 0 {
+		t.Fatalf("snapshot %s on %s, %d quotes, %d calls", snap.Pool, snap.Network, len(snap.Quotes), len(snap.Calls))
+	}
+	var poolAccount *snapshotAccount
+	for i, acc := range snap.Accounts {
+		if acc.Address == addr.String() {
+			poolAccount = &snap.Accounts[i]
+		}
+	}
+	if poolAccount == nil || poolAccount.Owner != raydium_cp_swap.ProgramID.String() || poolAccount.Size == 0 {
+		t.Errorf("the pool account is %+v in %+v", poolAccount, snap.Accounts)
+	}
+
+	before := served.Load()
+	replayed := quote(snap.replayClient())
+	if served.Load() != before {
+		t.Error("replaying went to the node")
+	}
+	if err := snap.checkQuote(replayed); err != nil {
+		t.Error(err)
+	}
+
+	// A quote that comes out different, or for another intent, doesn't pass.
+	snap.Quotes[0].Receive.Raw += "1"
+	if err := snap.checkQuote(replayed); err == nil || !strings.Contains(err.Error(), "receive") {
+		t.Errorf("a different quote: %v", err)
+	}
+	replayed.Intent = "sell 2 SOL"
+	if err := snap.checkQuote(replayed); err == nil || !strings.Contains(err.Error(), "no quote") {
+		t.Errorf("another intent: %v", err)
+	}
+}
+
+func TestRunSnapshotFill(t *testing.T) {
+	snap := &runSnapshot{Network: "mainnet", Pool: "P", Intent: "sell 1 SOL"}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	network, pool, intent := fs.String("network", "devnet", ""), fs.String("pool", "", ""), fs.String("intent", "", "")
+	if err := fs.Parse([]string{"-intent", "buy 1 USDC"}); err != nil {
+		t.Fatal(err)
+	}
+	snap.fill(fs, network, pool, intent)
+	if *network != "mainnet" || *pool != "P" || *intent != "buy 1 USDC" {
+		t.Errorf("filled -network %s -pool %s -intent %q", *network, *pool, *intent)
+	}
+}
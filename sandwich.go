@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+/*
+NOTE(@hadydotai): Sandwich risk.
+
+A swap with a slippage guard tells everyone watching the mempool (or the leader's queue) exactly how much worse a price
+it will swallow. A sandwich spends that: the attacker swaps the same way right before us, pushing the price until our
+swap only just clears its guard, we swap at the worse price, and the attacker swaps back right after, at the better
+price we left behind. On a constant product pool all of it can be worked out from the reserves, our size, our guard
+and the fee, so the report shows what the worst such sandwich would take before we send.
+
+estimateSandwich finds the largest front-run our guard still lets through (the guard gets weaker with every unit the
+attacker adds, so it's a search) and plays out all three swaps against the reserves. The profit is what the attacker
+ends with over what they put in, in our input token, trade fees on both of their legs included. Two things it leaves
+out: the attacker's transaction and tip costs, and the protocol and fund fee shares that leave the reserves, so it
+errs a little on the side of warning.
+
+When the profit is over -sandwich-warn (a percentage of what we pay in) the row says so, and what slippage would bring
+it under. We don't tighten the slippage ourselves, a tighter guard is also a swap that fails more often, that's the
+user's call. Sending through a private relay (Jito bundles and the like) takes the swap out of view entirely, this
+client doesn't do that, the row only points at it.
+*/
+
+// sandwichWarnPct is the sandwich profit, as a percentage of the swap's input, that the report warns about.
+var sandwichWarnPct = 0.1
+
+func addSandwichFlags(fs *flag.FlagSet) {
+	fs.Func("sandwich-warn", "Warn when a sandwich attack on the swap could take more than this percentage of what it pays in (default 0.1)", func(s string) error {
+		pct, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		if pct < 0 || pct >= 100 {
+			return errors.New("sandwich-warn has to be between 0 and 100")
+		}
+		sandwichWarnPct = pct
+		return nil
+	})
+}
+
+// sandwichRisk is the most profitable sandwich on a swap.
+type sandwichRisk struct {
+	frontRun *big.Int // what the attacker pays in ahead of us, in our input token
+	profit   *big.Int // what they end up with over frontRun, zero when no sandwich pays
+	input    *big.Int // what we pay in
+}
+
+// ratio is the profit as a fraction of what we pay in.
+func (sr *sandwichRisk) ratio() *big.Rat {
+	if sr.input.Sign() <= 0 {
+		return new(big.Rat)
+	}
+	return new(big.Rat).SetFrac(sr.profit, sr.input)
+}
+
+// over reports whether the profit is more than pct percent of what we pay in.
+func (sr *sandwichRisk) over(pct float64) bool {
+	limit := new(big.Rat).SetFloat64(pct / 100)
+	return sr.profit.Sign() > 0 && limit != nil && sr.ratio().Cmp(limit) > 0
+}
+
+// cpSwapOut is what amountIn buys from reserves reserveIn and reserveOut, the way QuoteOut works it out but without
+// refusing a zero.
+func cpSwapOut(reserveIn, reserveOut, amountIn *big.Int, feeRate uint64) *big.Int {
+	net := new(big.Int).Mul(amountIn, big.NewInt(feeRateDenom-int64(feeRate)))
+	net.Quo(net, big.NewInt(feeRateDenom))
+	out := new(big.Int).Mul(net, reserveOut)
+	return out.Quo(out, new(big.Int).Add(reserveIn, net))
+}
+
+// sandwichOutcome plays a front-run of frontRun, the intent's swap and the back-run against the reserves the intent
+// was quoted on. ok is false when the front-run pushes the intent past its slippage guard.
+func sandwichOutcome(intent *CPIntent, feeRate uint64, frontRun *big.Int) (profit *big.Int, ok bool) {
+	x := new(big.Int).Set(intent.ReserveIn.Balance)
+	y := new(big.Int).Set(intent.ReserveOut.Balance)
+	bought := cpSwapOut(x, y, frontRun, feeRate)
+	x.Add(x, frontRun)
+	y.Sub(y, bought)
+
+	switch intent.SwapKind {
+	case SwapKindBaseInput:
+		out := cpSwapOut(x, y, intent.Amounts.KnownAmount, feeRate)
+		if out.Cmp(intent.Amounts.MinAmountOut) < 0 || out.Sign() <= 0 {
+			return nil, false
+		}
+		x.Add(x, intent.Amounts.KnownAmount)
+		y.Sub(y, out)
+	case SwapKindBaseOutput:
+		cp := ConstantProduct{TokenInReserve: &PoolBalance{Balance: x}, TokenOutReserve: &PoolBalance{Balance: y}, TradeFeeRate: feeRate}
+		in, err := cp.QuoteIn(intent.Amounts.KnownAmount)
+		if err != nil || in.Cmp(intent.Amounts.MaxAmountIn) > 0 {
+			return nil, false
+		}
+		x.Add(x, in)
+		y.Sub(y, intent.Amounts.KnownAmount)
+	default:
+		return nil, false
+	}
+	back := cpSwapOut(y, x, bought, feeRate)
+	return back.Sub(back, frontRun), true
+}
+
+// estimateSandwich is the most an attacker could take sandwiching intent on a constant product pool charging feeRate.
+func estimateSandwich(intent *CPIntent, feeRate uint64) (*sandwichRisk, error) {
+	if intent == nil || intent.ReserveIn == nil || intent.ReserveOut == nil || intent.ReserveIn.Balance == nil || intent.ReserveOut.Balance == nil {
+		return nil, errors.New("the intent has no reserves to estimate a sandwich against")
+	}
+	if intent.ReserveIn.Balance.Sign() <= 0 || intent.ReserveOut.Balance.Sign() <= 0 {
+		return nil, errors.New("the pool's reserves are empty")
+	}
+	risk := &sandwichRisk{frontRun: new(big.Int), profit: new(big.Int), input: cloneInt(intent.Amounts.KnownAmount)}
+	if intent.SwapKind == SwapKindBaseOutput {
+		risk.input = cloneInt(intent.Amounts.QuoteAmount)
+	}
+	if risk.input == nil {
+		return nil, errors.New("the intent has no amounts")
+	}
+	// Find a front-run the guard refuses by doubling, then the largest one it takes between that and the last one it
+	// took. The intent's guard refusing any front-run at all means there's no room to sandwich it.
+	lo, hi := new(big.Int), big.NewInt(1)
+	for {
+		if _, ok := sandwichOutcome(intent, feeRate, hi); !ok {
+			break
+		}
+		lo.Set(hi)
+		hi.Lsh(hi, 1)
+	}
+	one := big.NewInt(1)
+	for new(big.Int).Sub(hi, lo).Cmp(one) > 0 {
+		mid := new(big.Int).Add(lo, hi)
+		mid.Rsh(mid, 1)
+		if _, ok := sandwichOutcome(intent, feeRate, mid); ok {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	if lo.Sign() == 0 {
+		return risk, nil
+	}
+	if profit, _ := sandwichOutcome(intent, feeRate, lo); profit.Sign() > 0 {
+		risk.frontRun, risk.profit = lo, profit
+	}
+	return risk, nil
+}
+
+// safeSlippagePct is the widest slippage, down from intent's, at which a sandwich takes no more than warnPct percent
+// of what the swap pays in, to two decimals.
+func safeSlippagePct(intent *CPIntent, feeRate uint64, currentPct, warnPct float64) (float64, error) {
+	risky := func(pct float64) (bool, error) {
+		slippage, err := makeSlippageRatio(pct)
+		if err != nil {
+			return false, err
+		}
+		requoted, err := requote(intent, intent.Amounts.KnownAmount, slippage)
+		if err != nil {
+			return false, err
+		}
+		risk, err := estimateSandwich(requoted, feeRate)
+		if err != nil {
+			return false, err
+		}
+		return risk.over(warnPct), nil
+	}
+	// Slippage is looked at in hundredths of a percent, nobody types finer than that.
+	lo, hi := 0, int(currentPct*100)
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		over, err := risky(float64(mid) / 100)
+		if err != nil {
+			return 0, err
+		}
+		if over {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return float64(lo) / 100, nil
+}
+
+// sandwichRow is the report's line on the intent's sandwich risk.
+func sandwichRow(intent *CPIntent, feeRate uint64, slippagePct float64, inputSymbol string) string {
+	risk, err := estimateSandwich(intent, feeRate)
+	if err != nil {
+		return fmt.Sprintf("unknown: %s", err)
+	}
+	if risk.profit.Sign() == 0 {
+		return "none, no sandwich pays at this slippage"
+	}
+	decimals := intent.TokenIn.Decimals
+	pct, _ := new(big.Rat).Mul(risk.ratio(), big.NewRat(100, 1)).Float64()
+	row := fmt.Sprintf("up to %s %s (%s of the swap) behind a %s %s front-run", fmtAmount(risk.profit, decimals), inputSymbol,
+		formatPercent(pct), fmtAmount(risk.frontRun, decimals), inputSymbol)
+	if !risk.over(sandwichWarnPct) {
+		return row
+	}
+	row += fmt.Sprintf("\nWARNING: over %s, ", formatPercent(sandwichWarnPct))
+	safe, err := safeSlippagePct(intent, feeRate, slippagePct, sandwichWarnPct)
+	if err != nil {
+		return row + "send it through a private relay (e.g. Jito) or tighten slippage"
+	}
+	return row + fmt.Sprintf("tighten slippage to %s or send it through a private relay (e.g. Jito)", formatPercent(safe))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+)
+
+func quoteSnapshotIntent(t *testing.T, line string, slippagePct float64) *CPIntent {
+	t.Helper()
+	pool, _, balances := snapshotPool()
+	venue := (&loadedPool{address: snapshotKey(70), pool: pool, ammConfig: &raydium_cp_swap.AmmConfig{TradeFeeRate: 2500}}).venue()
+	instruction, err := parseIntent(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slippage, err := makeSlippageRatio(slippagePct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intent, err := quoteInstruction(venue, instruction, pool.Token0Mint, slippage, balances)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return intent
+}
+
+func TestEstimateSandwich(t *testing.T) {
+	for _, line := range []string{"sell 10 SOL", "buy 10 SOL"} {
+		intent := quoteSnapshotIntent(t, line, 1)
+		risk, err := estimateSandwich(intent, 2500)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if risk.profit.Sign() <= 0 || risk.frontRun.Sign() <= 0 {
+			t.Fatalf("%s: a 1%% guard on 1%% of the pool should be worth sandwiching, got %s on %s", line, risk.profit, risk.frontRun)
+		}
+		// The front-run is the largest the guard lets through.
+		if _, ok := sandwichOutcome(intent, 2500, risk.frontRun); !ok {
+			t.Errorf("%s: the guard refuses the front-run it was sized to", line)
+		}
+		if _, ok := sandwichOutcome(intent, 2500, new(big.Int).Add(risk.frontRun, big.NewInt(1))); ok {
+			t.Errorf("%s: the guard takes a bigger front-run than %s", line, risk.frontRun)
+		}
+		if !risk.over(0.1) || risk.over(1) {
+			t.Errorf("%s: profit is %s of the swap", line, risk.ratio().FloatString(6))
+		}
+	}
+
+	tight, err := estimateSandwich(quoteSnapshotIntent(t, "sell 10 SOL", 0), 2500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tight.profit.Sign() != 0 {
+		t.Errorf("no slippage left room for a %s sandwich", tight.profit)
+	}
+}
+
+func TestSafeSlippagePct(t *testing.T) {
+	intent := quoteSnapshotIntent(t, "sell 10 SOL", 1)
+	safe, err := safeSlippagePct(intent, 2500, 1, 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if safe <= 0 || safe >= 1 {
+		t.Fatalf("suggested %v%%", safe)
+	}
+	risk, err := estimateSandwich(quoteSnapshotIntent(t, "sell 10 SOL", safe), 2500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if risk.over(0.1) {
+		t.Errorf("at the suggested %v%% a sandwich still takes %s of the swap", safe, risk.ratio().FloatString(6))
+	}
+
+	row := sandwichRow(intent, 2500, 1, "SOL")
+	if !strings.Contains(row, "WARNING") || !strings.Contains(row, "tighten slippage to "+formatPercent(safe)) {
+		t.Errorf("row %q", row)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+NOTE(@hadydotai): Schedules.
+
+Two flavours, because that's what people actually type:
+  - `@every 6h`, a fixed interval, anything time.ParseDuration takes
+  - the classic five field cron line, `minute hour day-of-month month day-of-week`, in local time. Each field takes
+    `*`, a number, a range `a-b`, a step `a-b/n` (or a star followed by `/n`), and comma separated lists of those.
+    Sunday is 0 (or 7). The usual shortcuts @hourly, @daily, @weekly and @monthly are there too.
+No seconds field, no names for months or days, no L/W/# extensions. Cron's odd rule is kept: when both day-of-month
+and day-of-week are restricted a day matches if either does. A field starting with `*`, stepped or not, isn't
+restricted, so every other day of the month on Mondays is the Mondays that fall on an odd day, like Vixie cron.
+*/
+
+type schedule interface {
+	// next returns the first time strictly after t the schedule fires, the zero time when it never does.
+	next(t time.Time) time.Time
+	String() string
+}
+
+type everySchedule struct {
+	interval time.Duration
+}
+
+func (s everySchedule) next(t time.Time) time.Time {
+	return t.Add(s.interval)
+}
+
+func (s everySchedule) String() string {
+	return "@every " + s.interval.String()
+}
+
+type cronSchedule struct {
+	spec                         string
+	minute, hour, dom, month     uint64 // bit n set means value n matches
+	dow                          uint64
+	domRestricted, dowRestricted bool
+}
+
+var cronShortcuts = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+func parseSchedule(spec string) (schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q has an invalid interval: %w", spec, err)
+		}
+		if d < time.Minute {
+			return nil, fmt.Errorf("schedule %q fires more often than once a minute", spec)
+		}
+		return everySchedule{interval: d}, nil
+	}
+	line := spec
+	if expanded, ok := cronShortcuts[spec]; ok {
+		line = expanded
+	}
+	fields := strings.Fields(line)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule %q should be `@every <duration>` or five cron fields (minute hour day-of-month month day-of-week)", spec)
+	}
+	cs := cronSchedule{spec: spec}
+	var err error
+	if cs.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("schedule %q minute: %w", spec, err)
+	}
+	if cs.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("schedule %q hour: %w", spec, err)
+	}
+	if cs.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("schedule %q day-of-month: %w", spec, err)
+	}
+	if cs.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("schedule %q month: %w", spec, err)
+	}
+	if cs.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("schedule %q day-of-week: %w", spec, err)
+	}
+	if cs.dow&(1<<7) != 0 {
+		cs.dow |= 1 // 7 is Sunday too
+	}
+	cs.domRestricted = !strings.HasPrefix(fields[2], "*")
+	cs.dowRestricted = !strings.HasPrefix(fields[4], "*")
+	return cs, nil
+}
+
+func parseCronField(field string, lo, hi int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if before, after, ok := strings.Cut(part, "/"); ok {
+			n, err := strconv.Atoi(after)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			rangePart, step = before, n
+		}
+		from, to := lo, hi
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			a, b, _ := strings.Cut(rangePart, "-")
+			var errA, errB error
+			from, errA = strconv.Atoi(a)
+			to, errB = strconv.Atoi(b)
+			if errA != nil || errB != nil || from > to {
+				return 0, fmt.Errorf("invalid range %q", rangePart)
+			}
+		default:
+			n, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", rangePart)
+			}
+			from, to = n, n
+			if step != 1 {
+				to = hi // `5/15` means from 5 to the end, every 15
+			}
+		}
+		if from < lo || to > hi {
+			return 0, fmt.Errorf("%q is outside %d-%d", part, lo, hi)
+		}
+		for v := from; v <= to; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (cs cronSchedule) dayMatches(t time.Time) bool {
+	domOK := cs.dom&(1<<uint(t.Day())) != 0
+	dowOK := cs.dow&(1<<uint(t.Weekday())) != 0
+	if cs.domRestricted && cs.dowRestricted {
+		return domOK || dowOK
+	}
+	return domOK && dowOK
+}
+
+func (cs cronSchedule) next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// Every field matches at least one value, so within a few years we either find a match or the spec can never
+	// fire (e.g. February 30th).
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		y, m, d := t.Date()
+		switch {
+		case cs.month&(1<<uint(m)) == 0:
+			t = time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+		case !cs.dayMatches(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		case cs.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, loc)
+		case cs.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (cs cronSchedule) String() string {
+	return cs.spec
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, spec := range []string{"", "@every 10s", "@every soon", "* * * *", "60 * * * *", "5-1 * * * *", "*/0 * * * *", "0 0 32 * *"} {
+		if _, err := parseSchedule(spec); err == nil {
+			t.Errorf("expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	// Wednesday.
+	from := time.Date(2024, 5, 15, 10, 7, 30, 0, time.UTC)
+	cases := []struct {
+		spec string
+		want time.Time
+	}{
+		{"@every 6h", from.Add(6 * time.Hour)},
+		{"@hourly", time.Date(2024, 5, 15, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 5, 16, 0, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 5, 15, 10, 15, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2024, 5, 16, 9, 0, 0, 0, time.UTC)},
+		{"30 8 * * 0", time.Date(2024, 5, 19, 8, 30, 0, 0, time.UTC)},
+		{"30 8 * * 7", time.Date(2024, 5, 19, 8, 30, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		// Day-of-month and day-of-week both restricted, either one will do: the 20th or the next Friday.
+		{"0 12 20 * 5", time.Date(2024, 5, 17, 12, 0, 0, 0, time.UTC)},
+		// A stepped * isn't a restriction, both have to match: the next Monday on an odd day.
+		{"0 9 */2 * 1", time.Date(2024, 5, 27, 9, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range cases {
+		s, err := parseSchedule(tc.spec)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.spec, err)
+		}
+		if got := s.next(from); !got.Equal(tc.want) {
+			t.Errorf("%q: got %s, want %s", tc.spec, got, tc.want)
+		}
+	}
+	never, _ := parseSchedule("0 0 30 2 *")
+	if got := never.next(from); !got.IsZero() {
+		t.Fatalf("February 30th shouldn't fire, got %s", got)
+	}
+}
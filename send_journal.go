@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+/*
+NOTE(@hadydotai): Not sending the same swap twice.
+
+The unattended engines retry: a limit order that failed to send tries again on the next trigger, a DCA execution
+that failed to send is tried again. That's only safe when the failure says the transaction didn't go anywhere, and a
+send can fail without saying that. A timeout or a dropped connection after the request went out leaves a signed
+transaction that the node may well have forwarded, and it can still land for as long as its blockhash is valid.
+Sending a fresh one on top of it is how an intent gets filled twice.
+
+So the engines send under a sendGuard, one idempotency key per fill they mean to make (the order, the DCA
+execution), with a journal behind it:
+
+  - the signed transaction's signature and blockhash go in the journal before it's handed to the RPC
+  - a send the node answered with an error never went out, it's marked rejected
+  - anything else that fails leaves the attempt in flight, and the error says so (ambiguousSendError)
+  - before the next attempt under the key is sent, every attempt in flight is settled: it landed, it landed and the
+    program rejected it, or its blockhash expired without it landing, in which case it never will. Until one of those
+    is known nothing is sent, we wait on it
+  - an attempt that landed is the fill, it's reported as such and nothing new goes out
+
+Every attempt under a key is kept, so a fill knows the attempts before it, receipts and DCA executions carry them
+as retryOf. The journal lives in a file when the engine has one (-journal, or next to the DCA state) so a restart
+settles what the last run left in flight, otherwise in memory for the life of the process.
+*/
+
+type journalStatus string
+
+const (
+	journalSent     journalStatus = "sent"     // handed to the RPC, not known to have landed or not to
+	journalRejected journalStatus = "rejected" // the node refused it, it never went out
+	journalLanded   journalStatus = "landed"
+	journalFailed   journalStatus = "failed"  // landed and the program rejected it
+	journalExpired  journalStatus = "expired" // its blockhash expired without it landing
+)
+
+type journalEntry struct {
+	Key       string        `json:"key"`
+	Signature string        `json:"signature"`
+	Blockhash string        `json:"blockhash"`
+	Time      time.Time     `json:"time"`
+	Status    journalStatus `json:"status"`
+
+	// LastValidBlockHeight is the last block height the blockhash is good for, zero in journals from before it was kept.
+	LastValidBlockHeight uint64 `json:"last_valid_block_height,omitzero"`
+}
+
+// sendJournal is every attempt the engines made, by idempotency key.
+type sendJournal struct {
+	path string // empty for a journal kept in memory
+
+	mu      sync.Mutex
+	Entries []journalEntry `json:"entries"`
+}
+
+// openSendJournal loads the journal at path, a missing file is an empty journal. An empty path is kept in memory.
+func openSendJournal(path string) (*sendJournal, error) {
+	j := &sendJournal{path: path}
+	if path == "" {
+		return j, nil
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading send journal %s: %w", path, err)
+	}
+	if err := json.Unmarshal(raw, j); err != nil {
+		return nil, fmt.Errorf("send journal %s is corrupt: %w", path, err)
+	}
+	return j, nil
+}
+
+// save writes the journal next to its destination and renames it into place, same as the DCA state. Callers hold mu.
+func (j *sendJournal) save() error {
+	if j.path == "" {
+		return nil
+	}
+	raw, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(j.path), filepath.Base(j.path)+".*")
+	if err != nil {
+		return fmt.Errorf("writing send journal: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(raw, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing send journal: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing send journal: %w", err)
+	}
+	return os.Rename(tmp.Name(), j.path)
+}
+
+// attempts are the key's entries, oldest first.
+func (j *sendJournal) attempts(key string) []journalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var out []journalEntry
+	for _, e := range j.Entries {
+		if e.Key == key {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func (j *sendJournal) record(e journalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Entries = append(j.Entries, e)
+	return j.save()
+}
+
+func (j *sendJournal) setStatus(sig string, status journalStatus) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for i := range j.Entries {
+		if j.Entries[i].Signature == sig {
+			j.Entries[i].Status = status
+		}
+	}
+	return j.save()
+}
+
+// forget drops the key's entries, once its fill is recorded elsewhere there's nothing left to settle.
+func (j *sendJournal) forget(key string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Entries = slices.DeleteFunc(j.Entries, func(e journalEntry) bool { return e.Key == key })
+	return j.save()
+}
+
+// ambiguousSendError is a send that failed without saying whether the transaction went out. It's still in flight as
+// far as the journal's concerned.
+type ambiguousSendError struct {
+	sig solana.Signature
+	err error
+}
+
+func (e *ambiguousSendError) Error() string {
+	return fmt.Sprintf("%v (transaction %s may still land, it's settled before anything else is sent)", e.err, e.sig)
+}
+
+func (e *ambiguousSendError) Unwrap() error {
+	return e.err
+}
+
+// sendRejected is whether a failed send was the node answering, and answering no, so nothing was forwarded. Any other
+// failure may have gone out.
+func sendRejected(err error) bool {
+	var rpcErr *jsonrpc.RPCError
+	return errors.As(err, &rpcErr)
+}
+
+// settlePoll is how often an attempt in flight is checked on while it's settled.
+var settlePoll = 2 * time.Second
+
+// sendGuard sends under one idempotency key of a journal.
+type sendGuard struct {
+	journal *sendJournal
+	key     string
+}
+
+func newSendGuard(journal *sendJournal, key string) *sendGuard {
+	return &sendGuard{journal: journal, key: key}
+}
+
+// inFlight reports whether an attempt under the key hasn't settled yet, or landed and hasn't been forgotten.
+func (g *sendGuard) inFlight() bool {
+	if g == nil {
+		return false
+	}
+	for _, e := range g.journal.attempts(g.key) {
+		if e.Status == journalSent || e.Status == journalLanded {
+			return true
+		}
+	}
+	return false
+}
+
+// sending journals tx under the key, before it's sent.
+func (g *sendGuard) sending(tx *solana.Transaction) error {
+	if g == nil {
+		return nil
+	}
+	return g.journal.record(journalEntry{
+		Key:                  g.key,
+		Signature:            tx.Signatures[0].String(),
+		Blockhash:            tx.Message.RecentBlockhash.String(),
+		LastValidBlockHeight: blockhashes.validThrough(tx.Message.RecentBlockhash),
+		Time:                 time.Now().UTC(),
+		Status:               journalSent,
+	})
+}
+
+// sendFailed records why tx didn't send and returns the error to hand back for it.
+func (g *sendGuard) sendFailed(tx *solana.Transaction, err error) error {
+	if g == nil {
+		return err
+	}
+	sig := tx.Signatures[0]
+	if sendRejected(err) {
+		if jerr := g.journal.setStatus(sig.String(), journalRejected); jerr != nil {
+			log.Printf("warning: %v", jerr)
+		}
+		return err
+	}
+	return &ambiguousSendError{sig: sig, err: err}
+}
+
+// outcome records what a sent attempt came to, as far as waiting on it found out.
+func (g *sendGuard) outcome(sig solana.Signature, status string) {
+	if g == nil {
+		return
+	}
+	var js journalStatus
+	switch status {
+	case "failed":
+		js = journalFailed
+	case "confirmed", "finalized":
+		js = journalLanded
+	default:
+		return // still in flight, settled before the next attempt
+	}
+	if err := g.journal.setStatus(sig.String(), js); err != nil {
+		log.Printf("warning: %v", err)
+	}
+}
+
+// expired records that sig's blockhash expired with it not landed, the rebroadcast loop found out on its own.
+func (g *sendGuard) expired(sig solana.Signature) {
+	if g == nil {
+		return
+	}
+	if err := g.journal.setStatus(sig.String(), journalExpired); err != nil {
+		log.Printf("warning: %v", err)
+	}
+}
+
+// settle waits until every attempt in flight under the key is known to have landed or known never to, and returns
+// the one that landed, if one did.
+func (g *sendGuard) settle(ctx context.Context, client *rpc.Client) (solana.Signature, bool, error) {
+	for _, e := range g.journal.attempts(g.key) {
+		switch e.Status {
+		case journalLanded:
+			return solana.MustSignatureFromBase58(e.Signature), true, nil
+		case journalSent:
+		default:
+			continue
+		}
+		sig, err := solana.SignatureFromBase58(e.Signature)
+		if err != nil {
+			return solana.Signature{}, false, fmt.Errorf("send journal entry %q: %w", e.Signature, err)
+		}
+		blockhash, err := solana.HashFromBase58(e.Blockhash)
+		if err != nil {
+			return solana.Signature{}, false, fmt.Errorf("send journal entry %q: %w", e.Signature, err)
+		}
+		blockhashes.track(blockhash, e.LastValidBlockHeight)
+		status, err := settleAttempt(ctx, client, sig, blockhash)
+		if err != nil {
+			return solana.Signature{}, false, fmt.Errorf("settling earlier attempt %s: %w", sig, err)
+		}
+		if err := g.journal.setStatus(e.Signature, status); err != nil {
+			return solana.Signature{}, false, err
+		}
+		if status == journalLanded {
+			return sig, true, nil
+		}
+	}
+	return solana.Signature{}, false, nil
+}
+
+// settleAttempt waits for sig to land, or for its blockhash to expire with it not landed.
+func settleAttempt(ctx context.Context, client *rpc.Client, sig solana.Signature, blockhash solana.Hash) (journalStatus, error) {
+	for {
+		status, known, err := signatureOutcome(ctx, client, sig)
+		if err != nil {
+			return "", err
+		}
+		if known {
+			return status, nil
+		}
+		expired, err := blockhashes.expired(ctx, client, blockhash)
+		if err != nil {
+			return "", err
+		}
+		if expired {
+			// Nothing can include it from here on, but it could have been included just before. One last look.
+			status, known, err := signatureOutcome(ctx, client, sig)
+			if err != nil {
+				return "", err
+			}
+			if known {
+				return status, nil
+			}
+			if status != journalSent {
+				return journalExpired, nil
+			}
+			// Seen processed, it's on a fork that may yet be confirmed. Expired is only what was never seen.
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(settlePoll):
+		}
+	}
+}
+
+// signatureOutcome is whether sig landed, known false while it hasn't been seen or is only processed. Seen processed
+// comes back as journalSent, unseen as no status at all.
+func signatureOutcome(ctx context.Context, client *rpc.Client, sig solana.Signature) (journalStatus, bool, error) {
+	resp, err := client.GetSignatureStatuses(ctx, true, sig)
+	if err != nil {
+		return "", false, fmt.Errorf("rpc call getSignatureStatuses failed: %w", err)
+	}
+	if resp == nil || len(resp.Value) == 0 || resp.Value[0] == nil {
+		return "", false, nil
+	}
+	val := resp.Value[0]
+	switch {
+	case val.Err != nil:
+		return journalFailed, true, nil
+	case val.ConfirmationStatus == rpc.ConfirmationStatusConfirmed || val.ConfirmationStatus == rpc.ConfirmationStatusFinalized:
+		return journalLanded, true, nil
+	default:
+		return journalSent, false, nil
+	}
+}
+
+// lineage are the attempts under the key before sig, oldest first.
+func (g *sendGuard) lineage(sig solana.Signature) []string {
+	if g == nil {
+		return nil
+	}
+	var out []string
+	for _, e := range g.journal.attempts(g.key) {
+		if e.Signature != sig.String() {
+			out = append(out, e.Signature)
+		}
+	}
+	return out
+}
+
+// done forgets the key once its fill has been recorded.
+func (g *sendGuard) done() {
+	if g == nil {
+		return
+	}
+	if err := g.journal.forget(g.key); err != nil {
+		log.Printf("warning: %v", err)
+	}
+}
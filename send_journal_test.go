@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+// settleServer answers getSignatureStatuses with the next of statuses (null while it's an empty string, the last one
+// sticking) and isBlockhashValid with valid.
+func settleServer(t *testing.T, valid bool, statuses ...string) *rpc.Client {
+	t.Helper()
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		value := ""
+		switch req.Method {
+		case "getSignatureStatuses":
+			status := statuses[min(calls, len(statuses)-1)]
+			calls++
+			if status == "" {
+				value = "[null]"
+			} else {
+				value = "[" + status + "]"
+			}
+		case "isBlockhashValid":
+			value = fmt.Sprint(valid)
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"context":{"slot":1},"value":%s}}`, req.ID, value)
+	}))
+	t.Cleanup(srv.Close)
+	return rpc.New(srv.URL)
+}
+
+const (
+	statusConfirmed = `{"slot":1,"confirmations":null,"err":null,"confirmationStatus":"confirmed"}`
+	statusProcessed = `{"slot":1,"confirmations":1,"err":null,"confirmationStatus":"processed"}`
+	statusFailed    = `{"slot":1,"confirmations":null,"err":{"InstructionError":[2,{"Custom":6005}]},"confirmationStatus":"confirmed"}`
+)
+
+func journalTx(n byte) *solana.Transaction {
+	return &solana.Transaction{Signatures: []solana.Signature{{n}}, Message: solana.Message{RecentBlockhash: solana.Hash{n}}}
+}
+
+func TestSendJournalFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sends.journal")
+	journal, err := openSendJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	guard := newSendGuard(journal, "limit order")
+	if guard.inFlight() {
+		t.Fatal("an empty journal has something in flight")
+	}
+	if err := guard.sending(journalTx(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := newSendGuard(journal, "another order").sending(journalTx(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A restart finds the attempt still in flight.
+	reopened, err := openSendJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	guard = newSendGuard(reopened, "limit order")
+	if !guard.inFlight() || len(reopened.attempts("limit order")) != 1 {
+		t.Fatalf("reopened journal holds %+v", reopened.Entries)
+	}
+	guard.done()
+	if reopened, _ := openSendJournal(path); len(reopened.Entries) != 1 || reopened.Entries[0].Key != "another order" {
+		t.Errorf("after done the journal holds %+v", reopened.Entries)
+	}
+}
+
+func TestSendGuardSendFailed(t *testing.T) {
+	journal, _ := openSendJournal("")
+	guard := newSendGuard(journal, "dca #1")
+
+	// The node answering with an error means nothing went out.
+	tx := journalTx(1)
+	guard.sending(tx)
+	rejected := &jsonrpc.RPCError{Code: -32002, Message: "Transaction simulation failed"}
+	if err := guard.sendFailed(tx, rejected); err != error(rejected) || guard.inFlight() {
+		t.Errorf("rejected send: %v, in flight %v", err, guard.inFlight())
+	}
+
+	// No answer at all and it may have.
+	tx = journalTx(2)
+	guard.sending(tx)
+	err := guard.sendFailed(tx, context.DeadlineExceeded)
+	var ambiguous *ambiguousSendError
+	if !errors.As(err, &ambiguous) || !errors.Is(err, context.DeadlineExceeded) || !guard.inFlight() {
+		t.Errorf("timed out send: %v, in flight %v", err, guard.inFlight())
+	}
+	if lineage := guard.lineage(tx.Signatures[0]); len(lineage) != 1 || lineage[0] != journalTx(1).Signatures[0].String() {
+		t.Errorf("lineage %v", lineage)
+	}
+}
+
+func TestSendGuardSettle(t *testing.T) {
+	settlePoll = time.Millisecond
+	t.Cleanup(func() { settlePoll = 2 * time.Second })
+	for _, tc := range []struct {
+		name     string
+		valid    bool
+		statuses []string
+		want     journalStatus
+	}{
+		{"landed", true, []string{statusConfirmed}, journalLanded},
+		{"landed once confirmed", true, []string{"", statusProcessed, statusConfirmed}, journalLanded},
+		{"landed and failed", true, []string{statusFailed}, journalFailed},
+		{"blockhash expired", false, []string{""}, journalExpired},
+		// Included right as the blockhash ran out, the last look catches it.
+		{"landed at expiry", false, []string{"", statusConfirmed}, journalLanded},
+		// Seen processed when the blockhash ran out, it could still be confirmed, so it's only expired once it's gone.
+		{"processed at expiry", false, []string{statusProcessed, statusProcessed, ""}, journalExpired},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			journal, _ := openSendJournal("")
+			guard := newSendGuard(journal, "dca #1")
+			guard.sending(journalTx(1))
+			guard.sendFailed(journalTx(1), context.DeadlineExceeded)
+			sig, landed, err := guard.settle(context.Background(), settleServer(t, tc.valid, tc.statuses...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := journal.attempts("dca #1")[0].Status; got != tc.want {
+				t.Errorf("settled as %s, want %s", got, tc.want)
+			}
+			if landed != (tc.want == journalLanded) || (landed && sig != journalTx(1).Signatures[0]) {
+				t.Errorf("settle = %s, %v", sig, landed)
+			}
+			if tc.want != journalLanded && guard.inFlight() {
+				t.Error("still in flight once settled")
+			}
+		})
+	}
+}
+
+func TestSendGuardSettleWaits(t *testing.T) {
+	journal, _ := openSendJournal("")
+	guard := newSendGuard(journal, "dca #1")
+	guard.sending(journalTx(1))
+	// Never seen and the blockhash never runs out, it could still land, so nothing is decided before the deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := guard.settle(ctx, settleServer(t, true, "")); err == nil {
+		t.Fatal("settled an attempt that could still land")
+	}
+	if !guard.inFlight() {
+		t.Error("an unsettled attempt dropped out of flight")
+	}
+}
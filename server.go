@@ -0,0 +1,541 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): HTTP API.
+
+`serve` puts the same quoting and swapping the CLI does behind a small JSON API, for dashboards and bots that would
+otherwise shell out to us and scrape tables:
+
+	POST /quote           {"pool": "...", "intent": "pay 1 SOL", "slippage": 0.5}   -> the quote
+	POST /swap            same body, plus "maxImpact" (percent)                     -> the receipt
+	GET  /pool/{address}                                                            -> pool, reserves and fees
+	GET  /pool/{address}/stream                                                     -> reserves and price as SSE
+	GET  /history                                                                   -> receipts of swaps sent
+
+Pools are loaded on first use and reloaded after poolCacheTTL, so a paused pool or a fee change is picked up, while
+reserves are read fresh for every quote like everywhere else. Each request gets its own TableBuilder on top of the
+cached pool, requests with different slippage never see each other's.
+
+Swaps are only enabled with -hotwallet, and go out one at a time, two swaps racing on the same wallet would race on
+its token accounts too. A server that can spend from a wallet doesn't listen beyond localhost without -token, every
+request then needs "Authorization: Bearer <token>".
+*/
+
+const (
+	poolCacheTTL   = 30 * time.Second
+	maxRequestBody = 1 << 20
+)
+
+type cachedPool struct {
+	loaded   *loadedPool
+	loadedAt time.Time
+}
+
+type apiServer struct {
+	ctx         context.Context
+	client      *rpc.Client
+	network     string
+	payer       solana.PrivateKey // nil when swaps are disabled
+	slippagePct float64
+	token       string
+	receipts    string
+
+	// Price streams (see price_stream.go) watch the vaults over wsEP, read at least every poll, and end with streams.
+	wsEP    string
+	poll    time.Duration
+	streams context.Context
+
+	poolsMu sync.Mutex
+	pools   map[solana.PublicKey]*cachedPool
+
+	swapMu    sync.Mutex
+	historyMu sync.Mutex
+	history   []swapReceipt
+}
+
+type quoteRequest struct {
+	Pool     string   `json:"pool"`
+	Intent   string   `json:"intent"`
+	Slippage *float64 `json:"slippage,omitempty"`
+	// MaxImpact refuses swaps whose price impact, trade fee included, is above this percentage.
+	MaxImpact *float64 `json:"maxImpact,omitempty"`
+	// Wallet is whose balance a percentage amount is of, the server's hot wallet when empty.
+	Wallet string `json:"wallet,omitempty"`
+}
+
+type quoteResponse struct {
+	quoteEvent
+	Slippage    float64 `json:"slippage"`
+	PriceImpact string  `json:"priceImpact,omitempty"` // percent
+}
+
+type poolTokenJSON struct {
+	Mint     string      `json:"mint"`
+	Symbol   string      `json:"symbol"`
+	Vault    string      `json:"vault"`
+	Decimals uint8       `json:"decimals"`
+	Reserve  *amountJSON `json:"reserve,omitempty"`
+	// Read off the mint, missing when it couldn't be. A null authority has been revoked.
+	Supply          *amountJSON `json:"supply,omitempty"`
+	MintAuthority   *string     `json:"mintAuthority,omitempty"`
+	FreezeAuthority *string     `json:"freezeAuthority,omitempty"`
+}
+
+type poolInfoJSON struct {
+	Address         string           `json:"address"`
+	AmmConfig       string           `json:"ammConfig"`
+	Tokens          [2]poolTokenJSON `json:"tokens"`
+	Price           string           `json:"price,omitempty"` // token1 per token0
+	TradeFeeRate    uint64           `json:"tradeFeeRate"`
+	ProtocolFeeRate uint64           `json:"protocolFeeRate"`
+	FundFeeRate     uint64           `json:"fundFeeRate"`
+	CreatorFeeRate  uint64           `json:"creatorFeeRate"`
+	Status          string           `json:"status"`
+	OpenTime        string           `json:"openTime"`
+}
+
+type apiError struct {
+	status int
+	err    error
+}
+
+func (e *apiError) Error() string { return e.err.Error() }
+
+func badRequest(format string, args ...any) error {
+	return &apiError{status: http.StatusBadRequest, err: fmt.Errorf(format, args...)}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("writing response failed: %v", err)
+	}
+}
+
+// writeError picks the status from the error: ours carry one, anything else is the chain or the RPC failing us.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusBadGateway
+	var ae *apiError
+	if errors.As(err, &ae) {
+		status = ae.status
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error(), "code": errorCode(err)})
+}
+
+func newAPIServer(ctx context.Context, client *rpc.Client, network string, slippagePct float64) *apiServer {
+	return &apiServer{
+		ctx:         ctx,
+		client:      client,
+		network:     network,
+		slippagePct: slippagePct,
+		poll:        15 * time.Second,
+		pools:       make(map[solana.PublicKey]*cachedPool),
+	}
+}
+
+func (s *apiServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /quote", s.handleQuote)
+	mux.HandleFunc("POST /swap", s.handleSwap)
+	mux.HandleFunc("GET /pool/{address}", s.handlePool)
+	mux.HandleFunc("GET /pool/{address}/stream", s.handlePoolStream)
+	mux.HandleFunc("GET /history", s.handleHistory)
+	return s.authorize(mux)
+}
+
+func (s *apiServer) authorize(next http.Handler) http.Handler {
+	if s.token == "" {
+		return next
+	}
+	want := []byte("Bearer " + s.token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or wrong bearer token"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// pool returns the pool, from the cache while it's fresh.
+func (s *apiServer) pool(ctx context.Context, addr string) (*loadedPool, error) {
+	pk, err := solana.PublicKeyFromBase58(addr)
+	if err != nil {
+		return nil, badRequest("pool %q isn't a base58 address: %v", addr, err)
+	}
+	s.poolsMu.Lock()
+	cached, ok := s.pools[pk]
+	s.poolsMu.Unlock()
+	if ok && time.Since(cached.loadedAt) < poolCacheTTL {
+		return cached.loaded, nil
+	}
+	loaded, err := loadPool(ctx, s.client, pk)
+	if err != nil {
+		return nil, err
+	}
+	s.poolsMu.Lock()
+	s.pools[pk] = &cachedPool{loaded: loaded, loadedAt: time.Now()}
+	s.poolsMu.Unlock()
+	return loaded, nil
+}
+
+func decodeQuoteRequest(w http.ResponseWriter, r *http.Request) (quoteRequest, error) {
+	var req quoteRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		return req, badRequest("invalid request body: %v", err)
+	}
+	if req.Pool == "" || req.Intent == "" {
+		return req, badRequest("pool and intent are required")
+	}
+	if req.MaxImpact != nil && *req.MaxImpact <= 0 {
+		return req, badRequest("maxImpact must be greater than zero")
+	}
+	return req, nil
+}
+
+// quote builds the intent against the pool on a builder of its own.
+func (s *apiServer) quote(ctx context.Context, req quoteRequest) (*TableBuilder, *CPIntent, quoteResponse, error) {
+	loaded, err := s.pool(ctx, req.Pool)
+	if err != nil {
+		return nil, nil, quoteResponse{}, err
+	}
+	slippage := s.slippagePct
+	if req.Slippage != nil {
+		slippage = *req.Slippage
+	}
+	builder, err := newTableBuilder(ctx, s.client, loaded, slippage)
+	if err != nil {
+		return nil, nil, quoteResponse{}, badRequest("%v", err)
+	}
+	switch {
+	case req.Wallet != "":
+		wallet, err := solana.PublicKeyFromBase58(req.Wallet)
+		if err != nil {
+			return nil, nil, quoteResponse{}, badRequest("wallet %q isn't a base58 address: %v", req.Wallet, err)
+		}
+		builder.useWallet(wallet)
+	case s.payer != nil:
+		builder.useWallet(s.payer.PublicKey())
+	}
+	_, intent, err := builder.Build(req.Intent)
+	if err != nil {
+		// Unknown symbols land here too, the message names the mint so the caller can use it.
+		return nil, nil, quoteResponse{}, &apiError{status: http.StatusUnprocessableEntity, err: err}
+	}
+	return builder, intent, newQuoteResponse(builder, intent, slippage), nil
+}
+
+func newQuoteResponse(builder *TableBuilder, intent *CPIntent, slippage float64) quoteResponse {
+	snap := builder.snapshot()
+	resp := quoteResponse{quoteEvent: newQuoteEvent(time.Now().UTC(), snap.address.String(), intent, snap.symm), Slippage: slippage}
+	if impact, err := intent.PriceImpact(); err == nil {
+		resp.PriceImpact = new(big.Rat).Mul(impact, big.NewRat(100, 1)).FloatString(4)
+	}
+	return resp
+}
+
+func (s *apiServer) handleQuote(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeQuoteRequest(w, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	_, _, resp, err := s.quote(r.Context(), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *apiServer) handleSwap(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeQuoteRequest(w, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	receipt, err := s.swap(r.Context(), req)
+	if receipt == nil {
+		writeError(w, err)
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusOK, struct {
+			swapReceipt
+			Error string `json:"error"`
+			Code  string `json:"code"`
+		}{*receipt, err.Error(), errorCode(err)})
+		return
+	}
+	writeJSON(w, http.StatusOK, receipt)
+}
+
+// swap quotes the request and sends it from the server's wallet. The receipt is nil when nothing was sent, otherwise
+// the swap is recorded and the error only says it failed on chain or couldn't be confirmed.
+func (s *apiServer) swap(ctx context.Context, req quoteRequest) (*swapReceipt, error) {
+	if s.payer == nil {
+		return nil, &apiError{status: http.StatusForbidden, err: errors.New("swaps are disabled, start the server with -hotwallet")}
+	}
+	s.swapMu.Lock()
+	defer s.swapMu.Unlock()
+	builder, intent, _, err := s.quote(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.MaxImpact != nil {
+		maxImpact := new(big.Rat).SetFloat64(*req.MaxImpact / 100)
+		if impact, err := intent.PriceImpact(); err != nil || impact.Cmp(maxImpact) > 0 {
+			return nil, &apiError{status: http.StatusConflict, err: fmt.Errorf("price impact is above %v%%, nothing was sent", *req.MaxImpact)}
+		}
+	}
+	// NOTE(@hadydotai): The swap runs on the server's context, not the request's. A client hanging up can't unsend a
+	// transaction, we see it through and record it either way.
+	summary, sig, err := executeIntent(s.ctx, s.client, s.payer, builder, intent)
+	if sig.IsZero() {
+		return nil, err
+	}
+	receipt := newSwapReceipt("serve", req.Pool, intent.String(), summary, explorerTxURL(s.network, sig))
+	s.record(receipt)
+	return &receipt, err
+}
+
+func (s *apiServer) record(receipt swapReceipt) {
+	s.historyMu.Lock()
+	s.history = append(s.history, receipt)
+	s.historyMu.Unlock()
+	if s.receipts != "" {
+		if err := appendReceipt(s.receipts, receipt); err != nil {
+			log.Printf("warning: %v", err)
+		}
+	}
+}
+
+func (s *apiServer) handlePool(w http.ResponseWriter, r *http.Request) {
+	loaded, err := s.pool(r.Context(), r.PathValue("address"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	p, cfg, symm := loaded.pool, loaded.ammConfig, loaded.symbolsMap
+	info := poolInfoJSON{
+		Address:   loaded.address.String(),
+		AmmConfig: p.AmmConfig.String(),
+		Tokens: [2]poolTokenJSON{
+			{Mint: p.Token0Mint.String(), Symbol: symm.SymFrom(p.Token0Mint), Vault: p.Token0Vault.String(), Decimals: p.Mint0Decimals},
+			{Mint: p.Token1Mint.String(), Symbol: symm.SymFrom(p.Token1Mint), Vault: p.Token1Vault.String(), Decimals: p.Mint1Decimals},
+		},
+		TradeFeeRate:    cfg.TradeFeeRate,
+		ProtocolFeeRate: cfg.ProtocolFeeRate,
+		FundFeeRate:     cfg.FundFeeRate,
+		CreatorFeeRate:  cfg.CreatorFeeRate,
+		Status:          describePoolStatus(p.Status),
+		OpenTime:        unixString(p.OpenTime),
+	}
+	quoteCtx, cancel := deadlines.forQuote(r.Context())
+	defer cancel()
+	for i, m := range loaded.mints {
+		if m == nil {
+			continue
+		}
+		info.Tokens[i].Decimals = m.Decimals
+		info.Tokens[i].Supply = ptrTo(newAmountJSON(new(big.Int).SetUint64(m.Supply), m.Decimals))
+		info.Tokens[i].MintAuthority, info.Tokens[i].FreezeAuthority = authorityString(m.MintAuthority), authorityString(m.FreezeAuthority)
+	}
+	balances, errs := poolReserves(quoteCtx, s.client, p, loaded.mints)
+	for i := range balances {
+		if errs[i] != nil {
+			writeError(w, errs[i])
+			return
+		}
+		info.Tokens[i].Reserve = ptrTo(newAmountJSON(balances[i].Balance, balances[i].Decimals))
+	}
+	info.Price = poolPrice(balances[0], balances[1])
+	writeJSON(w, http.StatusOK, info)
+}
+
+// poolPrice is how much token1 one token0 goes for at these reserves, in display units.
+func poolPrice(reserve0, reserve1 *PoolBalance) string {
+	if reserve0.Balance.Sign() == 0 {
+		return ""
+	}
+	price := new(big.Rat).SetFrac(reserve1.Balance, reserve0.Balance)
+	price.Mul(price, new(big.Rat).SetFrac(fixedPointScale(reserve0.Decimals), fixedPointScale(reserve1.Decimals)))
+	return price.FloatString(int(reserve1.Decimals))
+}
+
+func (s *apiServer) handleHistory(w http.ResponseWriter, r *http.Request) {
+	s.historyMu.Lock()
+	history := append([]swapReceipt{}, s.history...)
+	s.historyMu.Unlock()
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			writeError(w, badRequest("limit %q must be a non-negative integer", v))
+			return
+		}
+		history = history[max(len(history)-limit, 0):]
+	}
+	writeJSON(w, http.StatusOK, history)
+}
+
+// readReceipts loads a receipts file, a missing file is an empty history.
+func readReceipts(path string) ([]swapReceipt, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var receipts []swapReceipt
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var r swapReceipt
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		receipts = append(receipts, r)
+	}
+	return receipts, scanner.Err()
+}
+
+// isLoopback reports whether a listen address only accepts local connections.
+func isLoopback(listen string) bool {
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func runServeCommand(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	nf := addNetworkFlags(fs)
+	wf := addWebhookFlags(fs)
+	addCloseEmptyATAsFlag(fs)
+	addTradeHookFlags(fs)
+	addMintPolicyFlags(fs)
+	addSpendLimitFlags(fs)
+	addApprovalFlags(fs)
+	addRebroadcastFlags(fs)
+	addMinOutFromSimFlag(fs)
+	addComputeBudgetFlags(fs)
+	var (
+		listen        = fs.String("listen", "127.0.0.1:8080", "Address to listen on")
+		hotwalletPath = fs.String("hotwallet", "", "Wallet to sign swaps with, POST /swap is disabled without one")
+		slippagePct   = fs.Float64("slippage", 0.5, "Default slippage percentage, requests can set their own")
+		token         = fs.String("token", "", "Require this bearer token on every request (also read from RAYDIUM_CLIENT_TOKEN)")
+		receiptsPath  = fs.String("receipts", "", "Append every swap to this receipts file (JSON lines), and serve it from /history")
+		grpcListen    = fs.String("grpc", "", "Also serve the gRPC API on this address")
+		wsEP          = fs.String("ws", "", "WebSocket endpoint for the pool update and price streams and the pool index refresh, derived from -rpc when empty")
+		poll          = fs.Duration("poll", 15*time.Second, "Read a streamed pool's reserves at least this often, even without updates")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, append(nf.specs(),
+		FlagSpec{Name: "listen", Value: listen, Rules: []FlagRule{NotEmpty()}},
+	))
+	if *token == "" {
+		*token = os.Getenv("RAYDIUM_CLIENT_TOKEN")
+	}
+	for _, addr := range []string{*listen, *grpcListen} {
+		if addr != "" && *hotwalletPath != "" && *token == "" && !isLoopback(addr) {
+			return fmt.Errorf("refusing to serve swaps on %s without -token, anyone who can reach it could spend from the wallet", addr)
+		}
+	}
+	flushWebhooks, err := wf.start("serve", *nf.network)
+	if err != nil {
+		return err
+	}
+	defer flushWebhooks()
+	// NOTE(@hadydotai): Swaps run on a context of their own, an interrupt stops the server taking new requests but a
+	// swap that's already out gets to confirm.
+	s := newAPIServer(context.Background(), nf.connect(), *nf.network, *slippagePct)
+	s.token, s.receipts = *token, *receiptsPath
+	if *hotwalletPath != "" {
+		payer, err := solana.PrivateKeyFromSolanaKeygenFile(*hotwalletPath)
+		if err != nil {
+			return fmt.Errorf("failed to load private key from hot wallet: %w", err)
+		}
+		s.payer = payer
+	}
+	if *receiptsPath != "" {
+		history, err := readReceipts(*receiptsPath)
+		if err != nil {
+			return err
+		}
+		s.history = history
+	}
+
+	srv := &http.Server{Addr: *listen, Handler: s.handler(), ReadHeaderTimeout: 10 * time.Second}
+	ctx, stop := interruptContext()
+	defer stop()
+	if *wsEP == "" {
+		*wsEP = wsEndpointFor(*nf.rpcEP)
+	}
+	s.wsEP, s.poll, s.streams = *wsEP, *poll, ctx
+	followPoolIndexInBackground(ctx, s.client, *wsEP, *nf.poolIndex)
+	if *grpcListen != "" {
+		lis, err := net.Listen("tcp", *grpcListen)
+		if err != nil {
+			return fmt.Errorf("listening for gRPC on %s: %w", *grpcListen, err)
+		}
+		grpcSrv := newGRPCServer(s, *wsEP, *poll, ctx.Done())
+		go func() {
+			if err := grpcSrv.Serve(lis); err != nil {
+				log.Printf("gRPC server stopped: %v", err)
+				stop()
+			}
+		}()
+		defer grpcSrv.GracefulStop()
+		log.Printf("serving gRPC on %s", *grpcListen)
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := within(context.Background(), deadlines.Send)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	swaps := "disabled, no -hotwallet"
+	if s.payer != nil {
+		swaps = "enabled for " + s.payer.PublicKey().String()
+	}
+	log.Printf("serving on %s, swaps %s", *listen, swaps)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// newTestAPIServer serves the snapshot pool, already cached so the only RPC calls are vault balances.
+func newTestAPIServer(t *testing.T) (*apiServer, solana.PublicKey) {
+	t.Helper()
+	pool, addr, balances := snapshotPool()
+	symm := SymbolMapping{
+		mintToSymbol: map[string]string{pool.Token0Mint.String(): "SOL", pool.Token1Mint.String(): "USDC"},
+		symbolToMint: map[string]solana.PublicKey{"SOL": pool.Token0Mint, "USDC": pool.Token1Mint},
+		unresolved:   map[string]struct{}{},
+	}
+	srv := vaultBalanceServer(t, map[solana.PublicKey]*PoolBalance{pool.Token0Vault: balances[0], pool.Token1Vault: balances[1]})
+	s := newAPIServer(context.Background(), rpc.New(srv.URL), "devnet", 0.5)
+	s.pools[addr] = &cachedPool{
+		loaded:   &loadedPool{address: addr, pool: pool, ammConfig: &raydium_cp_swap.AmmConfig{TradeFeeRate: 2500}, symbolsMap: symm},
+		loadedAt: time.Now(),
+	}
+	return s, addr
+}
+
+func serveRequest(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rec
+}
+
+func TestServeQuote(t *testing.T) {
+	s, addr := newTestAPIServer(t)
+	h := s.handler()
+	rec := serveRequest(h, http.MethodPost, "/quote", `{"pool":"`+addr.String()+`","intent":"pay 1 SOL","slippage":1}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var resp quoteResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Intent != "pay 1 SOL" || resp.ReceiveSym != "USDC" || resp.Receive == nil || resp.MinReceive == nil || resp.Slippage != 1 {
+		t.Errorf("unexpected quote %+v", resp)
+	}
+	if resp.PriceImpact == "" {
+		t.Error("quote is missing its price impact")
+	}
+
+	for _, tc := range []struct {
+		body string
+		want int
+	}{
+		{`{"pool":"` + addr.String() + `"}`, http.StatusBadRequest},
+		{`{"pool":"not-base58!","intent":"pay 1 SOL"}`, http.StatusBadRequest},
+		{`{"pool":"` + addr.String() + `","intent":"pay 1 SOL","extra":true}`, http.StatusBadRequest},
+		{`{"pool":"` + addr.String() + `","intent":"pay 1 BONK"}`, http.StatusUnprocessableEntity},
+		{`{"pool":"` + addr.String() + `","intent":"pay 1 SOL","slippage":150}`, http.StatusBadRequest},
+	} {
+		if rec := serveRequest(h, http.MethodPost, "/quote", tc.body); rec.Code != tc.want {
+			t.Errorf("%s: status %d, want %d (%s)", tc.body, rec.Code, tc.want, rec.Body)
+		}
+	}
+}
+
+func TestServeSwapNeedsWallet(t *testing.T) {
+	s, addr := newTestAPIServer(t)
+	rec := serveRequest(s.handler(), http.MethodPost, "/swap", `{"pool":"`+addr.String()+`","intent":"pay 1 SOL"}`)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status %d, want 403: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestServePool(t *testing.T) {
+	s, addr := newTestAPIServer(t)
+	rec := serveRequest(s.handler(), http.MethodGet, "/pool/"+addr.String(), "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var info poolInfoJSON
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Tokens[0].Symbol != "SOL" || info.Tokens[1].Reserve == nil || info.Tokens[1].Reserve.Raw != "150000000000" {
+		t.Errorf("unexpected tokens %+v", info.Tokens)
+	}
+	if info.Price != "150.000000" || info.TradeFeeRate != 2500 {
+		t.Errorf("price %s, trade fee %d", info.Price, info.TradeFeeRate)
+	}
+}
+
+func TestServeHistoryAndAuth(t *testing.T) {
+	s, _ := newTestAPIServer(t)
+	s.receipts = filepath.Join(t.TempDir(), "receipts.jsonl")
+	s.record(swapReceipt{Command: "serve", Signature: "first"})
+	s.record(swapReceipt{Command: "serve", Signature: "second"})
+	s.token = "s3cret"
+	h := s.handler()
+
+	if rec := serveRequest(h, http.MethodGet, "/history", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("no token: status %d", rec.Code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/history?limit=1", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var history []swapReceipt
+	if err := json.Unmarshal(rec.Body.Bytes(), &history); err != nil {
+		t.Fatalf("status %d: %v", rec.Code, err)
+	}
+	if len(history) != 1 || history[0].Signature != "second" {
+		t.Errorf("history %+v, want the last receipt only", history)
+	}
+
+	// Recorded swaps are on disk too, a restarted server picks them up.
+	loaded, err := readReceipts(s.receipts)
+	if err != nil || len(loaded) != 2 {
+		t.Errorf("readReceipts = %d receipts, %v", len(loaded), err)
+	}
+	if missing, err := readReceipts(filepath.Join(t.TempDir(), "none.jsonl")); err != nil || missing != nil {
+		t.Errorf("a missing file should be an empty history, got %v, %v", missing, err)
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	for listen, want := range map[string]bool{
+		"127.0.0.1:8080": true,
+		"localhost:8080": true,
+		"[::1]:8080":     true,
+		":8080":          false,
+		"0.0.0.0:8080":   false,
+		"10.0.0.5:8080":  false,
+	} {
+		if got := isLoopback(listen); got != want {
+			t.Errorf("isLoopback(%q) = %v, want %v", listen, got, want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): Slippage guards from simulation.
+
+The slippage guard (min receive selling, max pay buying) is worked out off our own curve math. It matches the program
+to the last unit for plain SPL pairs, but the program rounds its own way, takes Token-2022 transfer fees on both legs
+and may be a newer deployment than the math was written against, and every unit our quote is off by is a unit of
+slippage the user never agreed to. With -min-out-from-sim the swap is simulated first, as the transaction that's about
+to go out, and the guard is put at -slippage off what cp-swap's SwapEvent in the simulation says the user would
+actually receive (or pay, buying) instead. The `at` clause's limit still holds over it.
+
+The simulated transaction is never signed, the node replaces its blockhash and skips signature checks, so it can't be
+forwarded and land as a second swap. When the simulation fails or has no event for the pool, nothing is sent.
+*/
+
+// minOutFromSim is -min-out-from-sim.
+var minOutFromSim bool
+
+func addMinOutFromSimFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&minOutFromSim, "min-out-from-sim", false, "Simulate each swap first and put its slippage guard off the simulated amounts instead of our own quote")
+}
+
+// guardFromSimulation simulates intent's swap for payer and returns a copy of it guarded off the simulated amounts,
+// slippage off what the user would receive selling, or pay buying.
+func guardFromSimulation(ctx context.Context, client *rpc.Client, payer solana.PublicKey, intent *CPIntent, slippage *big.Rat) (*CPIntent, error) {
+	plan, err := planSwap(ctx, client, payer, intent)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := unsignedTransaction(ctx, client, payer, plan.instructions)
+	if err != nil {
+		return nil, err
+	}
+	tx.Signatures = make([]solana.Signature, tx.Message.Header.NumRequiredSignatures)
+	res, err := client.SimulateTransactionWithOpts(ctx, tx, &rpc.SimulateTransactionOpts{ReplaceRecentBlockhash: true, Commitment: rpc.CommitmentProcessed})
+	if err != nil {
+		return nil, fmt.Errorf("simulating the swap failed: %w", err)
+	}
+	if res.Value == nil {
+		return nil, errors.New("simulating the swap failed, the node returned no result")
+	}
+	if res.Value.Err != nil {
+		if failure, ok := decodeProgramFailure(res.Value.Err, res.Value.Logs); ok {
+			return nil, fmt.Errorf("simulating the swap hit %s, nothing was sent", failure)
+		}
+		return nil, fmt.Errorf("simulating the swap failed: %v, nothing was sent", res.Value.Err)
+	}
+	fill, ok := simulatedFill(res.Value.Logs, intent.Pool.Address)
+	if !ok {
+		return nil, errors.New("the simulation has no swap event for the pool to put the slippage guard off, nothing was sent")
+	}
+	guarded, err := guardFromFill(intent, fill, slippage)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("slippage guard from simulation: %s", guardChange(intent, guarded))
+	return guarded, nil
+}
+
+// guardFromFill is a copy of intent guarded slippage off fill, what the user would receive selling, or pay buying. The
+// intent's `at` limit holds over it.
+func guardFromFill(intent *CPIntent, fill swapFill, slippage *big.Rat) (*CPIntent, error) {
+	guarded := *intent
+	var err error
+	switch intent.SwapKind {
+	case SwapKindBaseInput:
+		if guarded.Amounts.MinAmountOut, err = applySlippageFloor(fill.received(), slippage); err != nil {
+			return nil, err
+		}
+	case SwapKindBaseOutput:
+		if guarded.Amounts.MaxAmountIn, err = applySlippageCeil(fill.paid(), slippage); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("swap kind unknown, can't guard it")
+	}
+	if intent.Instruction != nil && intent.Instruction.Condition != nil {
+		clampToLimit(&guarded, *intent.Instruction.Condition)
+	}
+	return &guarded, nil
+}
+
+// simulatedFill is the swap cp-swap made on pool in a simulation's logs, the top level one.
+func simulatedFill(logs []string, pool solana.PublicKey) (swapFill, bool) {
+	for _, ev := range loggedSwapEvents(logs, raydium_cp_swap.ProgramID) {
+		if ev.depth == 1 && ev.event.PoolId.Equals(pool) {
+			return swapFill{pool: pool, event: ev.event, hasTradeFee: ev.hasTradeFee}, true
+		}
+	}
+	return swapFill{}, false
+}
+
+// guardChange describes how the guard moved from quoted to guarded.
+func guardChange(quoted, guarded *CPIntent) string {
+	decimals := quoted.CounterLeg().Decimals
+	if quoted.SwapKind == SwapKindBaseOutput {
+		return fmt.Sprintf("max pay %s, the quote's was %s", fmtAmount(guarded.Amounts.MaxAmountIn, decimals), fmtAmount(quoted.Amounts.MaxAmountIn, decimals))
+	}
+	return fmt.Sprintf("min receive %s, the quote's was %s", fmtAmount(guarded.Amounts.MinAmountOut, decimals), fmtAmount(quoted.Amounts.MinAmountOut, decimals))
+}
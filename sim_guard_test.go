@@ -0,0 +1,52 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+)
+
+func TestGuardFromSimulatedFill(t *testing.T) {
+	sell, _, poolAddr, _, _, slippage := newIntentFixture(t, SwapDirSell)
+	quotedMin := new(big.Int).Set(sell.Amounts.MinAmountOut)
+	logs := swapLogs(t, raydium_cp_swap.SwapEvent{PoolId: poolAddr, InputAmount: 10, OutputAmount: 25, OutputTransferFee: 5, BaseInput: true})
+	fill, ok := simulatedFill(logs, poolAddr)
+	if !ok {
+		t.Fatal("no fill for the pool in the simulation")
+	}
+	if _, ok := simulatedFill(logs, snapshotKey(9)); ok {
+		t.Error("found a fill for a pool the simulation didn't swap on")
+	}
+
+	// Selling, the guard is slippage off what would arrive, transfer fee taken.
+	guarded, err := guardFromFill(sell, fill, slippage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if guarded.Amounts.MinAmountOut.Cmp(big.NewInt(19)) != 0 {
+		t.Errorf("min receive %s, want 19 (1%% off 20)", guarded.Amounts.MinAmountOut)
+	}
+	if sell.Amounts.MinAmountOut.Cmp(quotedMin) != 0 {
+		t.Errorf("the quoted intent's guard moved to %s too", sell.Amounts.MinAmountOut)
+	}
+
+	// An `at` limit tighter than the simulated guard still holds.
+	sell.Instruction.Condition = &limitCondition{op: ">=", price: big.NewRat(3, 1)}
+	if guarded, err = guardFromFill(sell, fill, slippage); err != nil {
+		t.Fatal(err)
+	}
+	if guarded.Amounts.MinAmountOut.Cmp(big.NewInt(30)) != 0 {
+		t.Errorf("min receive %s with a limit at 3, want 30", guarded.Amounts.MinAmountOut)
+	}
+
+	// Buying, it's slippage over what would leave, transfer fee included.
+	buy, _, _, _, _, _ := newIntentFixture(t, SwapDirBuy)
+	fill = swapFill{pool: poolAddr, event: raydium_cp_swap.SwapEvent{InputAmount: 6, InputTransferFee: 1, OutputAmount: 10}}
+	if guarded, err = guardFromFill(buy, fill, slippage); err != nil {
+		t.Fatal(err)
+	}
+	if guarded.Amounts.MaxAmountIn.Cmp(big.NewInt(8)) != 0 {
+		t.Errorf("max pay %s, want 8 (1%% over 7, rounded up)", guarded.Amounts.MaxAmountIn)
+	}
+}
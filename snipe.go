@@ -0,0 +1,458 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): Sniping a pool's open.
+
+`snipe <POOL|PAIR> -intent "buy TOKEN with 1 SOL" -max-price 0.0001` swaps the moment a pool opens. A pool can be
+created with an open_time in the future, nobody can swap on it before then, and the first swaps after it get the
+creator's price. The point is to be in the first block that allows it, so nothing is worked out at that moment:
+-lead before the open, the intent is quoted, planned (accounts, wSOL, compute budget), checked and signed, and at the
+open the signed transaction is only sent. cp-swap wants the block's time strictly after open_time, so it fires a
+second after it by our clock.
+
+-max-price is the most paid per token received, in the token paid, and it's hard: the transaction's slippage guard
+is tightened to it the way a limit order's is (see clampToLimit), so no fill past it can land whatever the pool does
+in the meantime. A quote that's already past it when the swap is built isn't sent.
+
+A send that fails, the node refusing it because the chain's clock hasn't reached the open yet or the swap landing and
+failing, is tried again every -retry-every, -max-attempts in all. Before every retry the pool is read again and the
+swap quoted against it (the drift guard, see quote_drift.go), when the price has run past -max-price it stops right
+there rather than paying fees on swaps that can't fill. Sends go under a sendGuard (see send_journal.go), an attempt
+that may have gone out is settled before the next one, so the intent is never filled twice.
+
+The pool doesn't have to exist yet. Given its address, or a pair, snipe waits for it with the new-pool listener (see
+new_pools.go), checking the address every few seconds as well in case it was created before the subscription was up.
+A pair waits for a new pool trading it, not an existing one, and the new token's safety findings are logged when it
+shows up. The pool is read like everywhere else, at the RPC's finalized view, which lags its creation by a dozen
+seconds or so: a pool that opens the moment it's created is sniped that much late.
+*/
+
+// snipeFireDelay is how long after open_time the swap is sent, cp-swap wants the block's time past it.
+const snipeFireDelay = time.Second
+
+// snipePollEvery is how often a pool that isn't there yet is looked for, besides the listener.
+const snipePollEvery = 5 * time.Second
+
+// maxPriceCondition is -max-price as a condition on the intent's target price, the price limit orders and the slippage
+// guard clamp work with. Selling the known amount, the target is what's paid and its price is the inverse.
+func maxPriceCondition(intent *CPIntent, maxPrice *big.Rat) limitCondition {
+	if intent.SwapKind == SwapKindBaseInput {
+		return limitCondition{op: ">=", price: new(big.Rat).Inv(maxPrice)}
+	}
+	return limitCondition{op: "<=", price: maxPrice}
+}
+
+// paidPerReceived is what intent's quote pays per token it receives, in the token paid.
+func paidPerReceived(intent *CPIntent) *big.Rat {
+	price := targetPrice(intent)
+	if price == nil || price.Sign() == 0 {
+		return nil
+	}
+	if intent.SwapKind == SwapKindBaseInput {
+		return price.Inv(price)
+	}
+	return price
+}
+
+// snipeFireTime is when a swap on a pool with openTime goes out, now when the pool is already open.
+func snipeFireTime(openTime uint64, now time.Time) time.Time {
+	at := time.Unix(int64(openTime), 0).Add(snipeFireDelay)
+	if at.Before(now) {
+		return now
+	}
+	return at
+}
+
+type sniper struct {
+	ctx         context.Context
+	client      *rpc.Client
+	payer       solana.PrivateKey
+	network     string
+	wsEP        string
+	intent      string
+	maxPrice    *big.Rat
+	slippage    float64
+	lead        time.Duration
+	maxAttempts int
+	retryEvery  time.Duration
+	expiresAt   time.Time // zero when it waits for as long as it takes
+	receipts    string
+	guard       *sendGuard
+}
+
+// sleepUntil waits for at, returning false when the context ends or the snipe expires first.
+func (s *sniper) sleepUntil(at time.Time) bool {
+	if !s.expiresAt.IsZero() && s.expiresAt.Before(at) {
+		at = s.expiresAt
+	}
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+	select {
+	case <-s.ctx.Done():
+		return false
+	case <-timer.C:
+		return s.expiresAt.IsZero() || time.Now().Before(s.expiresAt)
+	}
+}
+
+// errSnipeCancelled is the snipe interrupted before it was sent.
+var errSnipeCancelled = errors.New("snipe cancelled")
+
+// expired is why the snipe stopped waiting, cancelled or out of time.
+func (s *sniper) expired() error {
+	if s.ctx.Err() != nil {
+		return errSnipeCancelled
+	}
+	return fmt.Errorf("snipe %s expired at %s before it was sent", s.intent, s.expiresAt.Local().Format(time.DateTime))
+}
+
+// awaitPool waits for the pool to be created, either address or, with pair set, the first new pool trading it.
+func (s *sniper) awaitPool(address solana.PublicKey, pair []solana.PublicKey) (solana.PublicKey, error) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	watcher := &newPoolWatcher{client: s.client, wsEP: s.wsEP, feeRates: map[solana.PublicKey]uint64{}, symbols: map[solana.PublicKey]string{}}
+	pools := make(chan newPool, 16)
+	go watcher.stream(ctx, pools)
+	poll := time.NewTicker(snipePollEvery)
+	defer poll.Stop()
+	var expired <-chan time.Time
+	if !s.expiresAt.IsZero() {
+		timer := time.NewTimer(time.Until(s.expiresAt))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return solana.PublicKey{}, s.expired()
+		case <-expired:
+			return solana.PublicKey{}, s.expired()
+		case <-poll.C:
+			if pair != nil {
+				continue
+			}
+			if _, err := fetchPoolState(ctx, s.client, address); err == nil {
+				return address, nil
+			}
+		case p, ok := <-pools:
+			if !ok {
+				return solana.PublicKey{}, s.expired()
+			}
+			switch {
+			case pair == nil && p.pool.Equals(address):
+			case pair != nil && ((p.mints[0].Equals(pair[0]) && p.mints[1].Equals(pair[1])) || (p.mints[0].Equals(pair[1]) && p.mints[1].Equals(pair[0]))):
+			default:
+				continue
+			}
+			for i := range p.mints {
+				if len(p.safety[i].risks) > 0 {
+					log.Printf("snipe: warning: %s %s", p.symbols[i], p.safety[i])
+				}
+			}
+			log.Printf("snipe: pool %s created, %s", p.pool, p.opens())
+			return p.pool, nil
+		}
+	}
+}
+
+// load reads the pool, waiting out the lag between its creation and the RPC serving it.
+func (s *sniper) load(address solana.PublicKey) (*loadedPool, error) {
+	for {
+		loaded, err := loadPool(s.ctx, s.client, address)
+		var notFound *poolNotFoundError
+		if !errors.As(err, &notFound) {
+			return loaded, err
+		}
+		if !s.sleepUntil(time.Now().Add(time.Second)) {
+			return nil, s.expired()
+		}
+	}
+}
+
+// prebuild quotes the intent, holds it to -max-price and plans it, everything the send at the open won't have time for.
+// A pool that won't take the swap at fireAt (swapping paused, the open moved) is refused here.
+func (s *sniper) prebuild(builder *TableBuilder, fireAt time.Time) (*CPIntent, *swapPlan, error) {
+	if err := builder.snapshot().venue.CheckTradable(fireAt); err != nil {
+		return nil, nil, err
+	}
+	_, intent, err := builder.Build(s.intent)
+	if err != nil {
+		return nil, nil, err
+	}
+	clampToLimit(intent, maxPriceCondition(intent, s.maxPrice))
+	symm := builder.symbols()
+	paid := symm.SymFrom(intent.TokenIn.Mint)
+	if _, clears := quoteDrift(intent, intent.Amounts.QuoteAmount); !clears {
+		return nil, nil, fmt.Errorf("%s is quoted at %s %s per %s, past -max-price %s", s.intent,
+			trimDecimal(paidPerReceived(intent).FloatString(int(intent.TokenIn.Decimals))), paid, symm.SymFrom(intent.TokenOut.Mint), trimDecimal(s.maxPrice.FloatString(int(intent.TokenIn.Decimals))))
+	}
+	if err := preSendHooks(s.ctx, newHookSwap(intent, symm, s.payer.PublicKey())); err != nil {
+		return nil, nil, err
+	}
+	plan, err := planSwap(s.ctx, s.client, s.payer.PublicKey(), intent)
+	if err != nil {
+		return nil, nil, err
+	}
+	return intent, plan, nil
+}
+
+// fire sends the signed transaction and sees it through to landing.
+func (s *sniper) fire(builder *TableBuilder, intent *CPIntent, plan *swapPlan, tx *solana.Transaction) (txSummaryData, solana.Signature, error) {
+	ctx, cancel := deadlines.forSend(s.ctx)
+	defer cancel()
+	if err := s.guard.sending(tx); err != nil {
+		return txSummaryData{}, solana.Signature{}, err
+	}
+	sig, err := sendTransaction(ctx, s.client, tx)
+	if err != nil {
+		return txSummaryData{}, solana.Signature{}, s.guard.sendFailed(tx, err)
+	}
+	log.Println("Tx: ", sig.String())
+	if sig, err = landTransaction(ctx, s.client, s.payer, tx, plan.instructions, s.guard); err != nil {
+		return txSummaryData{}, sig, err
+	}
+	symm := builder.symbols()
+	summary, waitErr := awaitSwapSummary(ctx, s.client, sig, intent.TokenIn, intent.TokenOut, symm.SymFrom(intent.TokenIn.Mint), symm.SymFrom(intent.TokenOut.Mint))
+	if waitErr != nil {
+		log.Printf("warning: waiting for transaction confirmation failed: %v", waitErr)
+	}
+	s.guard.outcome(sig, summary.Status)
+	if summary.Status == "failed" {
+		return summary, sig, &txFailedError{sig: sig, txErr: summary.TxErr, logs: summary.Logs}
+	}
+	postConfirmHooks(ctx, newHookSwap(intent, symm, s.payer.PublicKey()), summary)
+	return summary, sig, nil
+}
+
+func (s *sniper) report(builder *TableBuilder, intent *CPIntent, summary txSummaryData, sig solana.Signature) {
+	fmt.Fprintln(os.Stdout, renderTxSummary(summary))
+	fmt.Fprintln(os.Stdout, explorerTxURL(s.network, sig))
+	if s.receipts != "" {
+		rcpt := newSwapReceipt("snipe", builder.snapshot().address.String(), intent.String(), summary, explorerTxURL(s.network, sig))
+		rcpt.Trigger = "max price " + trimDecimal(s.maxPrice.FloatString(int(intent.TokenIn.Decimals)))
+		rcpt.RetryOf = s.guard.lineage(sig)
+		if err := appendReceipt(s.receipts, rcpt); err != nil {
+			log.Printf("warning: recording the receipt failed: %v", err)
+		}
+	}
+}
+
+func (s *sniper) run(loaded *loadedPool) error {
+	builder, err := newTableBuilder(s.ctx, s.client, loaded, s.slippage)
+	if err != nil {
+		return err
+	}
+	builder.useWallet(s.payer.PublicKey())
+	fireAt := snipeFireTime(loaded.pool.OpenTime, time.Now())
+	log.Printf("snipe: %s on %s, sending at %s", s.intent, loaded.address, fireAt.Local().Format("2006-01-02 15:04:05"))
+	if !s.sleepUntil(fireAt.Add(-s.lead)) {
+		return s.expired()
+	}
+	intent, plan, err := s.prebuild(builder, fireAt)
+	if err != nil {
+		return err
+	}
+	tx, err := signTransaction(s.ctx, s.client, s.payer, plan.instructions)
+	if err != nil {
+		return err
+	}
+	symm := builder.symbols()
+	inSym, outSym := symm.SymFrom(intent.TokenIn.Mint), symm.SymFrom(intent.TokenOut.Mint)
+	if intent.SwapKind == SwapKindBaseInput {
+		log.Printf("snipe: built and signed, pays %s for at least %s", formatTokenAmount(intent.Amounts.KnownAmount, intent.TokenIn.Decimals, inSym),
+			formatTokenAmount(intent.Amounts.MinAmountOut, intent.TokenOut.Decimals, outSym))
+	} else {
+		log.Printf("snipe: built and signed, gets %s for at most %s", formatTokenAmount(intent.Amounts.KnownAmount, intent.TokenOut.Decimals, outSym),
+			formatTokenAmount(intent.Amounts.MaxAmountIn, intent.TokenIn.Decimals, inSym))
+	}
+	if !s.sleepUntil(fireAt) {
+		return s.expired()
+	}
+	for attempt := 1; ; attempt++ {
+		summary, sig, err := s.fire(builder, intent, plan, tx)
+		if err == nil {
+			s.report(builder, intent, summary, sig)
+			s.guard.done()
+			return nil
+		}
+		if s.ctx.Err() != nil {
+			return errSnipeCancelled
+		}
+		if attempt >= s.maxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+		log.Printf("warning: attempt %d of %d failed, retrying in %s: %v", attempt, s.maxAttempts, s.retryEvery, err)
+		if !s.sleepUntil(time.Now().Add(s.retryEvery)) {
+			return s.expired()
+		}
+		ctx, cancel := deadlines.forSend(s.ctx)
+		summary, sig, landed, err := landedAttempt(ctx, s.client, builder.snapshot(), intent, s.guard)
+		cancel()
+		if err != nil {
+			return err
+		}
+		if landed {
+			s.report(builder, intent, summary, sig)
+			s.guard.done()
+			return nil
+		}
+		if err := checkQuoteDrift(s.ctx, s.client, builder, intent); err != nil {
+			return fmt.Errorf("not retrying, the pool moved past -max-price: %w", err)
+		}
+		if tx, err = signTransaction(s.ctx, s.client, s.payer, plan.instructions); err != nil {
+			return err
+		}
+	}
+}
+
+func runSnipeCommand(args []string) error {
+	fs := flag.NewFlagSet("snipe", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: snipe [flags] <POOL|PAIR>\n")
+		fs.PrintDefaults()
+	}
+	nf := addNetworkFlags(fs)
+	addTradeHookFlags(fs)
+	addMintPolicyFlags(fs)
+	addSpendLimitFlags(fs)
+	addRebroadcastFlags(fs)
+	addComputeBudgetFlags(fs)
+	var (
+		hotwalletPath = fs.String("hotwallet", "", "Path to the hotwallet to use for signing transactions")
+		intentLine    = fs.String("intent", "", "What to swap at the open, e.g. \"buy TOKEN with 1 SOL\"")
+		slippagePct   = fs.Float64("slippage", 5, "Slippage tolerance percentage, -max-price tightens it further")
+		lead          = fs.Duration("lead", 5*time.Second, "Build and sign the swap this long before the pool opens")
+		maxAttempts   = fs.Int("max-attempts", 5, "How many times to send before giving up")
+		retryEvery    = fs.Duration("retry-every", 500*time.Millisecond, "Wait this long between attempts")
+		wsEP          = fs.String("ws", "", "WebSocket endpoint for the new-pool listener, derived from -rpc when empty")
+		receiptsPath  = fs.String("receipts", "", "Append the fill to this receipts file (JSON lines)")
+		maxPrice      *big.Rat
+	)
+	fs.Func("max-price", "The most to pay per token received, in the token paid, required", func(s string) error {
+		price, ok := new(big.Rat).SetString(s)
+		if !ok || price.Sign() <= 0 {
+			return fmt.Errorf("max-price has to be a positive decimal number, got %q", s)
+		}
+		maxPrice = price
+		return nil
+	})
+	expiry := addExpiryFlags(fs, 0)
+	addAmountFlags(fs)
+	// The pool reads naturally first, `snipe <POOL> -intent ...`, flag stops at the first argument.
+	var target string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		target, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, append(nf.specs(),
+		FlagSpec{Name: "hotwallet", Value: hotwalletPath, Rules: []FlagRule{NotEmpty()}},
+		FlagSpec{Name: "intent", Value: intentLine, Rules: []FlagRule{NotEmpty()}},
+	))
+	if target == "" && fs.NArg() > 0 {
+		target = fs.Arg(0)
+	}
+	switch {
+	case target == "":
+		fs.Usage()
+		return errors.New("missing pool or pair")
+	case maxPrice == nil:
+		return errors.New("-max-price is required, a snipe isn't sent without a ceiling on what it pays")
+	case *maxAttempts <= 0 || *retryEvery <= 0:
+		return errors.New("-max-attempts and -retry-every must be greater than zero")
+	case *lead <= 0 || *lead > 45*time.Second:
+		// A blockhash is good for about a minute, the signed transaction has to still be valid at the open.
+		return errors.New("-lead must be between 0 and 45s")
+	}
+	poolAddr, tokens, isPair, err := parsePoolTarget(target)
+	if err != nil {
+		return err
+	}
+	payer, err := solana.PrivateKeyFromSolanaKeygenFile(*hotwalletPath)
+	if err != nil {
+		return fmt.Errorf("failed to load private key from hot wallet: %w", err)
+	}
+	client := nf.connect()
+	if *wsEP == "" {
+		*wsEP = wsEndpointFor(*nf.rpcEP)
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	journal, err := openSendJournal("")
+	if err != nil {
+		return err
+	}
+	s := &sniper{
+		ctx:         ctx,
+		client:      client,
+		payer:       payer,
+		network:     *nf.network,
+		wsEP:        *wsEP,
+		intent:      *intentLine,
+		maxPrice:    maxPrice,
+		slippage:    *slippagePct,
+		lead:        *lead,
+		maxAttempts: *maxAttempts,
+		retryEvery:  *retryEvery,
+		expiresAt:   expiry.deadline(time.Now()),
+		receipts:    *receiptsPath,
+		guard:       newSendGuard(journal, fmt.Sprintf("snipe %s %s", target, *intentLine)),
+	}
+
+	var address solana.PublicKey
+	if isPair {
+		pair := make([]solana.PublicKey, len(tokens))
+		for i, token := range tokens {
+			if pair[i], err = resolvePairToken(token, SymbolMapping{}); err != nil {
+				return err
+			}
+		}
+		log.Printf("snipe: waiting for a new %s pool on %s", target, raydium_cp_swap.ProgramID)
+		if address, err = s.awaitPool(solana.PublicKey{}, pair); err != nil {
+			return err
+		}
+	} else {
+		if address, err = solana.PublicKeyFromBase58(poolAddr); err != nil {
+			return fmt.Errorf("deriving public key from pool address (base58) failed, make sure it's base58 encoded: %w", err)
+		}
+		quoteCtx, cancel := deadlines.forQuote(ctx)
+		_, err := fetchPoolState(quoteCtx, client, address)
+		cancel()
+		var notFound *poolNotFoundError
+		if errors.As(err, &notFound) {
+			log.Printf("snipe: pool %s doesn't exist yet, waiting for it", address)
+			if _, err = s.awaitPool(address, nil); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+	}
+	loaded, err := s.load(address)
+	if err == nil {
+		err = s.run(loaded)
+	}
+	if errors.Is(err, errSnipeCancelled) {
+		log.Printf("snipe cancelled")
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestSnipeMaxPrice(t *testing.T) {
+	pool, poolAddr, balances := snapshotPool()
+	cp := ConstantProduct{TradeFeeRate: 2500, SlippageRatio: mustSlippageRatio(t, 5)}
+
+	// Spending 1 SOL at no more than 0.0068 SOL per USDC has to get at least 147.058824 USDC.
+	sell, err := NewCPIntent(cp, pool, poolAddr, &IntentInstruction{Verb: "sell", AmountStr: "1", Dir: SwapDirSell, TargetSymbol: "SOL"}, pool.Token0Mint, balances...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clampToLimit(sell, maxPriceCondition(sell, big.NewRat(68, 10_000)))
+	if sell.Amounts.MinAmountOut.Cmp(big.NewInt(147_058_824)) != 0 {
+		t.Errorf("min out %s, want 147058824", sell.Amounts.MinAmountOut)
+	}
+	if _, clears := quoteDrift(sell, sell.Amounts.QuoteAmount); !clears {
+		t.Errorf("a quote of %s clears a 0.0068 ceiling", sell.Amounts.QuoteAmount)
+	}
+	if got := paidPerReceived(sell); got.Cmp(big.NewRat(68, 10_000)) > 0 || got.Cmp(big.NewRat(66, 10_000)) < 0 {
+		t.Errorf("paid %s SOL per USDC, want about 0.00668", got.FloatString(6))
+	}
+	// Under the pool's price it can't fill, and the quote says so before anything is sent.
+	tight, _ := NewCPIntent(cp, pool, poolAddr, &IntentInstruction{Verb: "sell", AmountStr: "1", Dir: SwapDirSell, TargetSymbol: "SOL"}, pool.Token0Mint, balances...)
+	clampToLimit(tight, maxPriceCondition(tight, big.NewRat(66, 10_000)))
+	if _, clears := quoteDrift(tight, tight.Amounts.QuoteAmount); clears {
+		t.Errorf("a quote of %s doesn't clear a 0.0066 ceiling, min out %s", tight.Amounts.QuoteAmount, tight.Amounts.MinAmountOut)
+	}
+
+	// Buying 10 USDC at no more than 0.0066 SOL each pays at most 0.066 SOL.
+	buy, err := NewCPIntent(cp, pool, poolAddr, &IntentInstruction{Verb: "buy", AmountStr: "10", Dir: SwapDirBuy, TargetSymbol: "USDC"}, pool.Token1Mint, balances...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clampToLimit(buy, maxPriceCondition(buy, big.NewRat(66, 10_000)))
+	if buy.Amounts.MaxAmountIn.Cmp(big.NewInt(66_000_000)) != 0 {
+		t.Errorf("max in %s, want 66000000", buy.Amounts.MaxAmountIn)
+	}
+}
+
+func TestSnipeFireTime(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	if got := snipeFireTime(1_700_000_060, now); !got.Equal(time.Unix(1_700_000_061, 0)) {
+		t.Errorf("a pool opening in a minute fires at %s", got)
+	}
+	if got := snipeFireTime(1_600_000_000, now); !got.Equal(now) {
+		t.Errorf("an open pool fires at %s, want now", got)
+	}
+}
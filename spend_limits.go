@@ -0,0 +1,197 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+/*
+NOTE(@hadydotai): Spend limits.
+
+-max-trade-usd caps a single swap and -max-day-usd what one wallet swaps each UTC day, both in dollars so one limit
+covers every pair. They're a trade hook (see trade_hooks.go) that's turned on by giving either flag, so they guard
+every path a quoted swap is sent down, the engines included.
+
+A swap is valued at the most it can pay, its slippage guard, at -price-source's price for what it pays. A token the
+price source doesn't know is valued on the other side instead, at the least it gets, and a swap neither side of which
+can be priced is refused, a limit we can't check is one we can't keep. Once it lands it's valued again at what it
+actually paid, and that's what counts towards the day. A swap we stopped waiting on counts at its worst.
+
+The day is the daily-limit hook's, in dollars and per wallet: same ledger in the config dir, same reset at midnight
+UTC, so a token cap and the dollar cap never disagree about what went through today. Two swaps checked at the same
+moment (serve takes them concurrently) can both fit under the day's cap and together go over it, neither has landed
+when the other is checked.
+
+Every swap the limits see is also written to the spend ledger, a receipts file (receipts.go) with the wallet and
+dollar value on each line, in the config dir unless -spend-ledger says otherwise. It's a record for `history export`,
+the limits don't read it back.
+
+Going over is refused unless -override-limits is given, and even then someone has to say yes to each swap at the
+terminal. That's only possible with -no-tui, the TUI and the engines refuse outright, an override nobody confirmed is
+no limit at all.
+*/
+
+type spendLimitHook struct {
+	noTradeHook
+	perTrade *big.Rat        // nil for no cap
+	perDay   *dailyLimitHook // nil for no cap
+	ledger   string
+	override bool
+	// confirm asks whether to go over a limit, nil where there's nobody to ask.
+	confirm func(question string) (bool, error)
+	price   func(ctx context.Context, mint solana.PublicKey, sym string) (usdPrice, time.Duration, error)
+	now     func() time.Time
+
+	mu sync.Mutex
+}
+
+var spendLimits = &spendLimitHook{ledger: defaultSpendLedgerPath(), price: currentUSDPrice, now: time.Now}
+
+func defaultSpendLedgerPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "spend-ledger.jsonl"
+	}
+	return filepath.Join(dir, "raydium-client", "spend-ledger.jsonl")
+}
+
+func addSpendLimitFlags(fs *flag.FlagSet) {
+	dollars := func(name, s string) (*big.Rat, error) {
+		limit, ok := new(big.Rat).SetString(strings.TrimPrefix(s, "$"))
+		if !ok || limit.Sign() <= 0 {
+			return nil, fmt.Errorf("%s has to be a positive dollar amount, got %q", name, s)
+		}
+		turnOnTradeHook("spend-limits", spendLimits)
+		return limit, nil
+	}
+	fs.Func("max-trade-usd", "Refuse a single swap worth more than this many dollars", func(s string) (err error) {
+		spendLimits.perTrade, err = dollars("max-trade-usd", s)
+		return err
+	})
+	fs.Func("max-day-usd", "Refuse a swap that takes what the wallet swapped today (UTC) over this many dollars", func(s string) error {
+		limit, err := dollars("max-day-usd", s)
+		if err != nil {
+			return err
+		}
+		spendLimits.perDay = newDailyUSDLimit(limit, spendLimits.price)
+		return nil
+	})
+	fs.StringVar(&spendLimits.ledger, "spend-ledger", spendLimits.ledger, "Receipts file the spend limits record each swap in, with the wallet and its dollar value")
+	fs.BoolVar(&spendLimits.override, "override-limits", false, "Ask to go over -max-trade-usd or -max-day-usd instead of refusing, needs -no-tui")
+}
+
+// notional is what s is worth in dollars. paid and received are what it moved, nil for what it can move at worst.
+func (h *spendLimitHook) notional(ctx context.Context, s hookSwap, paid, received *big.Int) (*big.Rat, error) {
+	usd, err := usdNotional(ctx, h.price, s, paid, received)
+	if err != nil {
+		return nil, fmt.Errorf("can't value the swap for the spend limits: %w", err)
+	}
+	return usd, nil
+}
+
+// usdNotional is what s is worth in dollars at price, valued on what it pays, or on what it gets when what it pays
+// has no price. paid and received are what it moved, nil for what it can move at worst.
+func usdNotional(ctx context.Context, price func(context.Context, solana.PublicKey, string) (usdPrice, time.Duration, error), s hookSwap, paid, received *big.Int) (*big.Rat, error) {
+	value := func(leg SwapLeg, amount *big.Int) (*big.Rat, error) {
+		if amount == nil {
+			return nil, errors.New("no amount")
+		}
+		p, _, err := price(ctx, leg.Mint, s.Symbols.SymFrom(leg.Mint))
+		if err != nil {
+			return nil, err
+		}
+		usd := new(big.Rat).SetFrac(amount, fixedPointScale(leg.Decimals))
+		return usd.Mul(usd, p.Price), nil
+	}
+	usd, inErr := value(s.TokenIn, cmp.Or(paid, s.MaxIn))
+	if inErr == nil {
+		return usd, nil
+	}
+	usd, outErr := value(s.TokenOut, cmp.Or(received, s.MinOut))
+	if outErr != nil {
+		return nil, fmt.Errorf("%s: %v, %s: %v", s.Symbols.SymFrom(s.TokenIn.Mint), inErr, s.Symbols.SymFrom(s.TokenOut.Mint), outErr)
+	}
+	return usd, nil
+}
+
+func (h *spendLimitHook) PreSend(ctx context.Context, s hookSwap) error {
+	usd, err := h.notional(ctx, s, nil, nil)
+	if err != nil {
+		return err
+	}
+	var over []string
+	if h.perTrade != nil && usd.Cmp(h.perTrade) > 0 {
+		over = append(over, fmt.Sprintf("up to %s is over the %s per-trade limit", fmtUSD(usd), fmtUSD(h.perTrade)))
+	}
+	if h.perDay != nil {
+		reason, err := h.perDay.over(ctx, s)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			over = append(over, reason)
+		}
+	}
+	if len(over) == 0 {
+		return nil
+	}
+	reason := strings.Join(over, ", and ")
+	switch {
+	case !h.override:
+		return errors.New(reason)
+	case h.confirm == nil:
+		return fmt.Errorf("%s, and -override-limits needs someone to confirm at the terminal (-no-tui)", reason)
+	}
+	ok, err := h.confirm(fmt.Sprintf("%s: %s. Send it anyway?", s.Intent, reason))
+	if err != nil || !ok {
+		return fmt.Errorf("%s, going over wasn't confirmed", reason)
+	}
+	return nil
+}
+
+func (h *spendLimitHook) PostConfirm(ctx context.Context, s hookSwap, summary txSummaryData) error {
+	if summary.Status == "failed" {
+		return nil
+	}
+	if h.perDay != nil {
+		if err := h.perDay.PostConfirm(ctx, s, summary); err != nil {
+			return err
+		}
+	}
+	paid, received := summary.PaidAmount, summary.ReceivedAmount
+	if summary.Status == "pending" {
+		paid, received = nil, nil
+	}
+	usd, err := h.notional(ctx, s, paid, received)
+	if err != nil {
+		return err
+	}
+	pool := ""
+	if !s.Pool.IsZero() {
+		pool = s.Pool.String()
+	}
+	r := newSwapReceipt("", pool, s.Intent, summary, "")
+	r.Time = h.now().UTC()
+	r.Wallet, r.NotionalUSD = s.Wallet.String(), usd.FloatString(2)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(h.ledger), 0o755); err != nil {
+		return fmt.Errorf("writing the spend ledger: %w", err)
+	}
+	return appendReceipt(h.ledger, r)
+}
+
+func fmtUSD(usd *big.Rat) string {
+	return "$" + usd.FloatString(2)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+func testSpendLimits(t *testing.T, prices map[solana.PublicKey]*big.Rat) (*spendLimitHook, *time.Time) {
+	t.Helper()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	h := &spendLimitHook{
+		ledger: filepath.Join(t.TempDir(), "spend-ledger.jsonl"),
+		price: func(_ context.Context, mint solana.PublicKey, sym string) (usdPrice, time.Duration, error) {
+			if p, ok := prices[mint]; ok {
+				return usdPrice{Price: p, Source: "test"}, 0, nil
+			}
+			return usdPrice{}, 0, errors.New("no price for " + sym)
+		},
+		now: func() time.Time { return now },
+	}
+	return h, &now
+}
+
+func TestSpendLimits(t *testing.T) {
+	symm, sol, usdc := hookTestSymbols()
+	meme := snapshotKey(97)
+	h, now := testSpendLimits(t, map[solana.PublicKey]*big.Rat{sol: big.NewRat(150, 1), usdc: big.NewRat(1, 1)})
+	h.perTrade, h.perDay = big.NewRat(1000, 1), newDailyUSDLimit(big.NewRat(1500, 1), h.price)
+	h.perDay.path, h.perDay.now = filepath.Join(t.TempDir(), "daily-limit.json"), h.now
+	wallet, other := snapshotKey(98), snapshotKey(99)
+	solLeg, usdcLeg := SwapLeg{Mint: sol, Decimals: 9}, SwapLeg{Mint: usdc, Decimals: 6}
+	// sell pays up to n SOL, worth $150 each.
+	sell := func(w solana.PublicKey, n int64) hookSwap {
+		return hookSwap{Wallet: w, TokenIn: solLeg, TokenOut: usdcLeg, MaxIn: big.NewInt(n * 1e9), MinOut: big.NewInt(1), Symbols: symm}
+	}
+	ctx := t.Context()
+
+	if err := h.PreSend(ctx, sell(wallet, 7)); err == nil || !strings.Contains(err.Error(), "$1050.00 is over the $1000.00 per-trade") {
+		t.Errorf("a $1050 swap: %v", err)
+	}
+	if err := h.PreSend(ctx, sell(wallet, 6)); err != nil {
+		t.Fatal(err)
+	}
+	// It paid 5.5 SOL, $825 is what counts.
+	if err := h.PostConfirm(ctx, sell(wallet, 6), txSummaryData{Status: "confirmed", PaidAmount: big.NewInt(5_500_000_000), PaidDecimals: 9}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.PostConfirm(ctx, sell(wallet, 6), txSummaryData{Status: "failed"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.PreSend(ctx, sell(wallet, 5)); err == nil || !strings.Contains(err.Error(), "$825.00 already went through") {
+		t.Errorf("$825 + $750 is over the day: %v", err)
+	}
+	if err := h.PreSend(ctx, sell(other, 5)); err != nil {
+		t.Errorf("another wallet's day is its own: %v", err)
+	}
+
+	// Buying a token the price source doesn't know is valued at the USDC it pays, a swap neither side of which is
+	// priced is refused.
+	buyMeme := hookSwap{Wallet: wallet, TokenIn: usdcLeg, TokenOut: SwapLeg{Mint: meme, Decimals: 6}, MaxIn: big.NewInt(700e6), MinOut: big.NewInt(1), Symbols: symm}
+	if err := h.PreSend(ctx, buyMeme); err == nil || !strings.Contains(err.Error(), "today's $1500.00 limit") {
+		t.Errorf("$700 more on $825: %v", err)
+	}
+	memeOnly := hookSwap{Wallet: wallet, TokenIn: SwapLeg{Mint: meme}, TokenOut: SwapLeg{Mint: snapshotKey(100)}, MaxIn: big.NewInt(1), MinOut: big.NewInt(1), Symbols: symm}
+	if err := h.PreSend(ctx, memeOnly); err == nil || !strings.Contains(err.Error(), "can't value") {
+		t.Errorf("an unpriced swap: %v", err)
+	}
+
+	// The next UTC day starts over, and what was spent is in the daily-limit ledger, not the spend ledger.
+	*now = now.Add(12 * time.Hour)
+	if err := h.PreSend(ctx, sell(wallet, 6)); err != nil {
+		t.Errorf("the day should have started over: %v", err)
+	}
+	if receipts, err := readReceipts(h.ledger); err != nil || len(receipts) != 1 || receipts[0].NotionalUSD != "825.00" {
+		t.Errorf("spend ledger: %+v %v", receipts, err)
+	}
+}
+
+func TestSpendLimitsOverride(t *testing.T) {
+	symm, sol, usdc := hookTestSymbols()
+	h, _ := testSpendLimits(t, map[solana.PublicKey]*big.Rat{sol: big.NewRat(150, 1)})
+	h.perTrade = big.NewRat(100, 1)
+	s := hookSwap{Intent: "sell 1 SOL", TokenIn: SwapLeg{Mint: sol, Decimals: 9}, TokenOut: SwapLeg{Mint: usdc, Decimals: 6}, MaxIn: big.NewInt(1e9), Symbols: symm}
+	ctx := t.Context()
+
+	h.override = true
+	if err := h.PreSend(ctx, s); err == nil || !strings.Contains(err.Error(), "needs someone to confirm") {
+		t.Errorf("an override nobody can confirm: %v", err)
+	}
+	var asked string
+	answer := false
+	h.confirm = func(question string) (bool, error) {
+		asked = question
+		return answer, nil
+	}
+	if err := h.PreSend(ctx, s); err == nil || !strings.HasPrefix(asked, "sell 1 SOL: up to $150.00") {
+		t.Errorf("declined override went through (%v) after asking %q", err, asked)
+	}
+	answer = true
+	if err := h.PreSend(ctx, s); err != nil {
+		t.Errorf("confirmed override: %v", err)
+	}
+	h.override = false
+	if err := h.PreSend(ctx, s); err == nil {
+		t.Error("went over without -override-limits")
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+/*
+NOTE(@hadydotai): Splitting a swap across pools.
+
+On a constant product pool price impact grows with the size of the trade, so a large order pays less in total when it's
+spread over several pools for the same pair than when it all goes through the deepest one. With -split N we quote the
+intent on every CP-Swap pool for the pair (the same way -compare does), take the best N, and search for how to divide
+the amount between them.
+
+The search hands the amount out in splitSteps equal chunks, each chunk going to whichever pool gives the most for it
+(or charges the least, when buying) on top of what it already got. A pool's output only ever grows slower the more you
+put in, so giving every chunk to the best pool at the margin lands on the best split there is at that resolution, and
+it does it in splitSteps * N quotes instead of trying every ratio.
+
+All legs go in one transaction, one swap instruction per pool, so either every leg lands or none does. Every leg has
+its own slippage guard, worked out off its own quote. A transaction only fits so many accounts, and each pool brings
+five of its own, so we stop at maxSplitPools.
+*/
+
+const (
+	splitSteps    = 100
+	maxSplitPools = 3
+)
+
+type splitLeg struct {
+	address solana.PublicKey
+	feeRate uint64
+	intent  *CPIntent
+}
+
+// splitRoute is the intent divided between pools, legs in the order the pools ranked.
+type splitRoute struct {
+	legs []splitLeg
+	// single is the best quote any one pool gives for the whole amount, what the split is measured against.
+	single poolCandidate
+}
+
+// intents are the legs' intents, in order, ready for planRoute.
+func (r *splitRoute) intents() []*CPIntent {
+	intents := make([]*CPIntent, len(r.legs))
+	for i, leg := range r.legs {
+		intents[i] = leg.intent
+	}
+	return intents
+}
+
+// blended is the route as one intent: the user's instruction with the legs' amounts summed. It has no reserves, there
+// isn't a single pool to measure price impact against.
+func (r *splitRoute) blended() *CPIntent {
+	first := r.legs[0].intent
+	blended := *first
+	blended.Instruction = r.single.intent.Instruction
+	blended.WalletBalance = r.single.intent.WalletBalance
+	blended.ReserveIn, blended.ReserveOut = nil, nil
+	sum := func(get func(*CPIntent) *big.Int) *big.Int {
+		if get(first) == nil {
+			return nil
+		}
+		total := new(big.Int)
+		for _, leg := range r.legs {
+			total.Add(total, get(leg.intent))
+		}
+		return total
+	}
+	blended.Amounts = SwapAmounts{
+		KnownAmount:  sum(func(ci *CPIntent) *big.Int { return ci.Amounts.KnownAmount }),
+		QuoteAmount:  sum(func(ci *CPIntent) *big.Int { return ci.Amounts.QuoteAmount }),
+		MinAmountOut: sum(func(ci *CPIntent) *big.Int { return ci.Amounts.MinAmountOut }),
+		MaxAmountIn:  sum(func(ci *CPIntent) *big.Int { return ci.Amounts.MaxAmountIn }),
+	}
+	return &blended
+}
+
+// quoteAt is what amount costs or brings on the candidate's venue, the way its intent goes.
+func quoteAt(c poolCandidate, amount *big.Int) (*big.Int, error) {
+	if amount.Sign() == 0 {
+		return new(big.Int), nil
+	}
+	intent, err := requote(c.intent, amount, nil)
+	if err != nil {
+		return nil, err
+	}
+	return intent.Amounts.QuoteAmount, nil
+}
+
+// optimizeSplit divides the intent the candidates were quoted on between the best maxPools of them, candidates
+// ranked the way comparePools leaves them.
+func optimizeSplit(candidates []poolCandidate, slippage *big.Rat, maxPools int) (*splitRoute, error) {
+	maxPools = min(maxPools, maxSplitPools)
+	var pools []poolCandidate
+	for _, c := range candidates {
+		if c.err == nil && len(pools) < maxPools {
+			pools = append(pools, c)
+		}
+	}
+	if len(pools) == 0 {
+		return nil, errors.New("no CP-Swap pool for the pair can take this intent")
+	}
+	single := pools[0]
+	buying := single.intent.SwapKind == SwapKindBaseOutput
+	total := single.intent.Amounts.KnownAmount
+	for _, c := range pools[1:] {
+		if c.intent.SwapKind != single.intent.SwapKind || c.intent.Amounts.KnownAmount.Cmp(total) != 0 {
+			return nil, errors.New("the pools were quoted on different amounts, can't split between them")
+		}
+	}
+
+	steps := big.NewInt(splitSteps)
+	if total.Cmp(steps) < 0 {
+		steps.Set(total)
+	}
+	chunk, rem := new(big.Int).QuoRem(total, steps, new(big.Int))
+	alloc := make([]*big.Int, len(pools))
+	quotes := make([]*big.Int, len(pools))
+	for i := range pools {
+		alloc[i], quotes[i] = new(big.Int), new(big.Int)
+	}
+	for step := int64(0); step < steps.Int64(); step++ {
+		size := chunk
+		if step == 0 {
+			size = new(big.Int).Add(chunk, rem)
+		}
+		best, bestQuote, bestDelta := -1, (*big.Int)(nil), (*big.Int)(nil)
+		for i, c := range pools {
+			q, err := quoteAt(c, new(big.Int).Add(alloc[i], size))
+			if err != nil {
+				continue // the pool can't take any more of it
+			}
+			delta := new(big.Int).Sub(q, quotes[i])
+			if best == -1 || (buying && delta.Cmp(bestDelta) < 0) || (!buying && delta.Cmp(bestDelta) > 0) {
+				best, bestQuote, bestDelta = i, q, delta
+			}
+		}
+		if best == -1 {
+			return nil, fmt.Errorf("the pools can't take %s between them", single.intent)
+		}
+		alloc[best].Add(alloc[best], size)
+		quotes[best] = bestQuote
+	}
+
+	route := &splitRoute{single: single}
+	if split := sumQuotes(quotes); (buying && split.Cmp(single.intent.Amounts.QuoteAmount) >= 0) || (!buying && split.Cmp(single.intent.Amounts.QuoteAmount) <= 0) {
+		// Rounding can scatter a small order over pools for nothing, if it doesn't pay to split, don't.
+		route.legs = []splitLeg{{address: single.address, feeRate: single.venue.FeeRate(), intent: single.intent}}
+		return route, nil
+	}
+	for i, c := range pools {
+		if alloc[i].Sign() == 0 {
+			continue
+		}
+		intent, err := splitIntent(c.intent, alloc[i], slippage)
+		if err != nil {
+			return nil, err
+		}
+		route.legs = append(route.legs, splitLeg{address: c.address, feeRate: c.venue.FeeRate(), intent: intent})
+	}
+	return route, nil
+}
+
+func sumQuotes(quotes []*big.Int) *big.Int {
+	total := new(big.Int)
+	for _, q := range quotes {
+		total.Add(total, q)
+	}
+	return total
+}
+
+// splitIntent is base cut down to amount, quoted again with its own slippage guard.
+func splitIntent(base *CPIntent, amount *big.Int, slippage *big.Rat) (*CPIntent, error) {
+	leg, err := requote(base, amount, slippage)
+	if err != nil {
+		return nil, err
+	}
+	decimals := base.TokenIn.Decimals
+	if base.SwapKind == SwapKindBaseOutput {
+		decimals = base.TokenOut.Decimals
+	}
+	instruction := *base.Instruction
+	instruction.AmountStr = fmtForDisplay(amount, decimals, int(decimals))
+	instruction.AmountPct, instruction.AmountUSD = nil, nil
+	leg.Instruction = &instruction
+	return leg, nil
+}
+
+// planSplit quotes intentLine on the pair's pools and splits it between the best maxPools of them.
+func planSplit(ctx context.Context, client *rpc.Client, builder *TableBuilder, intentLine string, maxPools int) (*splitRoute, error) {
+	candidates, err := comparePools(ctx, client, builder, intentLine)
+	if err != nil {
+		return nil, err
+	}
+	return optimizeSplit(candidates, builder.snapshot().slippageRat, maxPools)
+}
+
+// executeSplit sends every leg of the route in one transaction and waits for the outcome, like executeIntent.
+func executeSplit(ctx context.Context, client *rpc.Client, payer solana.PrivateKey, builder *TableBuilder, route *splitRoute) (txSummaryData, solana.Signature, error) {
+	snap := builder.snapshot()
+	ctx, cancel := deadlines.forSend(ctx)
+	defer cancel()
+	return sendRoute(ctx, client, payer, snap, route.blended(), route.intents(), nil)
+}
+
+// renderSplitRoute shows each leg of the route, the total, and how it compares with the best single pool.
+func renderSplitRoute(route *splitRoute, symm SymbolMapping) string {
+	builder := &strings.Builder{}
+	t := table.NewWriter()
+	t.SetOutputMirror(builder)
+	t.SetTitle("Split route")
+	t.Style().Size.WidthMax = 160
+	blended := route.blended()
+	inSym, outSym := symm.SymFrom(blended.TokenIn.Mint), symm.SymFrom(blended.TokenOut.Mint)
+	inDec, outDec := blended.TokenIn.Decimals, blended.TokenOut.Decimals
+	t.AppendHeader(table.Row{"Pool", "Fee", "Share", "Pay", "Receive", "Price impact"})
+	for _, leg := range route.legs {
+		in, out := leg.intent.QuotedInOut()
+		share := new(big.Rat).SetFrac(leg.intent.Amounts.KnownAmount, blended.Amounts.KnownAmount)
+		t.AppendRow(table.Row{leg.address, formatFeeRate(leg.feeRate), pctString(share),
+			formatTokenAmount(in, inDec, inSym), formatTokenAmount(out, outDec, outSym), poolImpact(leg.intent)})
+	}
+	in, out := blended.QuotedInOut()
+	t.AppendFooter(table.Row{"Total", "", "", formatTokenAmount(in, inDec, inSym), formatTokenAmount(out, outDec, outSym), ""})
+	t.Render()
+
+	single := route.single.intent
+	gain := new(big.Int).Sub(blended.Amounts.QuoteAmount, single.Amounts.QuoteAmount)
+	format, sym, dec := "Splitting gets you %s more than the best single pool %s.\n", outSym, outDec
+	if blended.SwapKind == SwapKindBaseOutput {
+		gain.Neg(gain)
+		format, sym, dec = "Splitting costs %s less than the best single pool %s.\n", inSym, inDec
+	}
+	if len(route.legs) == 1 {
+		fmt.Fprintf(builder, "Splitting doesn't beat the best single pool %s for this amount, it all goes there.\n", Addr(route.single.address.String()))
+	} else {
+		fmt.Fprintf(builder, format, formatTokenAmount(gain, dec, sym), Addr(route.single.address.String()))
+	}
+	return builder.String()
+}
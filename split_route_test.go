@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+// splitCandidate quotes intentLine on a SOL/USDC pool scaled to depth times snapshotPool's reserves and
+// charging feeRate.
+func splitCandidate(t *testing.T, addr solana.PublicKey, depth int64, feeRate uint64, intentLine string) poolCandidate {
+	t.Helper()
+	pool, _, balances := snapshotPool()
+	for i, bal := range balances {
+		balances[i] = &PoolBalance{Balance: new(big.Int).Mul(bal.Balance, big.NewInt(depth)), Decimals: bal.Decimals}
+	}
+	instruction, err := parseIntent(intentLine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slippage, _ := makeSlippageRatio(0.5)
+	target := pool.Token0Mint
+	if instruction.TargetSymbol == "USDC" {
+		target = pool.Token1Mint
+	}
+	loaded := &loadedPool{address: addr, pool: pool, ammConfig: &raydium_cp_swap.AmmConfig{TradeFeeRate: feeRate}}
+	venue := loaded.venue()
+	intent, err := quoteInstruction(venue, instruction, target, slippage, balances)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return poolCandidate{address: addr, loaded: loaded, venue: venue, intent: intent}
+}
+
+func TestOptimizeSplit(t *testing.T) {
+	slippage, _ := makeSlippageRatio(0.5)
+	for _, intentLine := range []string{"sell 200 SOL", "buy 200 SOL"} {
+		candidates := []poolCandidate{
+			splitCandidate(t, snapshotKey(50), 3, 2500, intentLine),
+			splitCandidate(t, snapshotKey(51), 1, 2500, intentLine),
+		}
+		rankCandidates(candidates)
+		route, err := optimizeSplit(candidates, slippage, 2)
+		if err != nil {
+			t.Fatalf("%s: %v", intentLine, err)
+		}
+		if len(route.legs) != 2 {
+			t.Fatalf("%s: %d legs, want both pools used", intentLine, len(route.legs))
+		}
+		// Three times the depth takes three times the amount, give or take a step.
+		deep, shallow := route.legs[0].intent.Amounts.KnownAmount, route.legs[1].intent.Amounts.KnownAmount
+		if ratio, _ := new(big.Rat).SetFrac(deep, shallow).Float64(); ratio < 2.8 || ratio > 3.2 {
+			t.Errorf("%s: split %s / %s, want about 3:1", intentLine, deep, shallow)
+		}
+		blended := route.blended()
+		if blended.Amounts.KnownAmount.Cmp(candidates[0].intent.Amounts.KnownAmount) != 0 {
+			t.Errorf("%s: legs add up to %s", intentLine, blended.Amounts.KnownAmount)
+		}
+		if !betterQuote(blended, route.single.intent) {
+			t.Errorf("%s: split %s isn't better than the single pool %s", intentLine, blended.Amounts.QuoteAmount, route.single.intent.Amounts.QuoteAmount)
+		}
+		if out := renderSplitRoute(route, SymbolMapping{}); !strings.Contains(out, "than the best single pool") {
+			t.Errorf("%s: route rendered as:\n%s", intentLine, out)
+		}
+		for _, leg := range route.legs {
+			if leg.intent.SwapKind == SwapKindBaseInput && leg.intent.Amounts.MinAmountOut.Cmp(leg.intent.Amounts.QuoteAmount) >= 0 {
+				t.Errorf("%s: leg on %s has no slippage guard", intentLine, leg.address)
+			}
+		}
+	}
+
+	// A small order isn't worth splitting onto a pool charging four times the fee, it all goes to the cheap one.
+	candidates := []poolCandidate{splitCandidate(t, snapshotKey(50), 1, 2500, "sell 1 SOL"), splitCandidate(t, snapshotKey(51), 3, 10000, "sell 1 SOL")}
+	rankCandidates(candidates)
+	route, err := optimizeSplit(candidates, slippage, 2)
+	if err != nil || len(route.legs) != 1 || !route.legs[0].address.Equals(snapshotKey(50)) {
+		t.Fatalf("small order: %+v, %v", route, err)
+	}
+	if out := renderSplitRoute(route, SymbolMapping{}); !strings.Contains(out, "doesn't beat the best single pool") {
+		t.Errorf("single leg route rendered as:\n%s", out)
+	}
+
+	if _, err := optimizeSplit([]poolCandidate{{address: snapshotKey(52), err: errors.New("swaps are disabled")}}, slippage, 2); err == nil {
+		t.Error("want an error when no pool took the quote")
+	}
+}
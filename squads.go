@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+
+	solana "github.com/gagliardetto/solana-go"
+	computebudget "github.com/gagliardetto/solana-go/programs/compute-budget"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): Squads multisig proposals.
+
+Treasury funds don't sit behind a hot wallet, they sit in a Squads (v4) multisig vault, and a swap out of one goes
+through the multisig's own approval flow. With -squads-vault <multisig>, -no-tui plans the swap with the vault as the
+wallet, it pays, it receives and its token accounts are the ones created, and instead of sending it, wraps it in a
+vault transaction and opens a proposal for it. The members vote and execute it in the Squads app (or their CLI) as with
+any other proposal. Nothing moves until they do.
+
+The hot wallet only creates the proposal and pays the rent for it, so it has to be a member of the multisig with the
+initiate permission, we check that before building anything. The vault pays for the swap itself, token account rent
+included, so it needs a little SOL besides what it swaps.
+
+A few things don't carry over from sending a swap directly:
+  - Compute budget instructions can't run inside a vault transaction, the runtime only reads them from the top level.
+    They're dropped from the proposed swap, whoever executes it sets the budget on the transaction that does.
+  - The proposal's min-out is from the quote at the time it was proposed. Votes take hours, a proposal executed long
+    after the market moved fails its slippage check and has to be proposed again, that's the guard working.
+  - The whole swap goes in the transaction that creates the proposal, and that still has to fit in 1232 bytes. A
+    single pool swap does comfortably, which is why -split and the like aren't offered here.
+  - Trade hooks and -approval-above don't run, unlike with review bundles. The multisig is the approval, and what the
+    vault spends isn't the hot wallet's to count against its limits. The mint policy still applies when symbols resolve.
+*/
+
+// squadsProgramID is Squads v4, the same on mainnet and devnet.
+var squadsProgramID = solana.MustPublicKeyFromBase58("SQDS4ep65T869zMMBKyuUq6aD6EgTu8psMjkvj52pCf")
+
+// squadsPermissionInitiate is the permission bit of a member allowed to create transactions and proposals.
+const squadsPermissionInitiate = 1 << 0
+
+// squadsFlags is -squads-vault and -squads-vault-index.
+type squadsFlags struct {
+	multisig   solana.PublicKey
+	vaultIndex *uint
+}
+
+func addSquadsFlags(fs *flag.FlagSet) *squadsFlags {
+	sf := &squadsFlags{}
+	fs.Func("squads-vault", "Propose the swap to this Squads multisig's vault instead of sending it, needs -no-tui", func(s string) error {
+		multisig, err := solana.PublicKeyFromBase58(s)
+		if err != nil {
+			return fmt.Errorf("squads-vault has to be the multisig's address: %w", err)
+		}
+		sf.multisig = multisig
+		return nil
+	})
+	sf.vaultIndex = fs.Uint("squads-vault-index", 0, "Which of the multisig's vaults swaps with -squads-vault")
+	return sf
+}
+
+func (sf *squadsFlags) enabled() bool {
+	return !sf.multisig.IsZero()
+}
+
+func (sf *squadsFlags) vault() (solana.PublicKey, error) {
+	if *sf.vaultIndex > 255 {
+		return solana.PublicKey{}, errors.New("-squads-vault-index takes 0 to 255")
+	}
+	return squadsVaultPDA(sf.multisig, uint8(*sf.vaultIndex))
+}
+
+func squadsVaultPDA(multisig solana.PublicKey, index uint8) (solana.PublicKey, error) {
+	pda, _, err := solana.FindProgramAddress([][]byte{[]byte("multisig"), multisig[:], []byte("vault"), {index}}, squadsProgramID)
+	if err != nil {
+		return solana.PublicKey{}, fmt.Errorf("deriving the squads vault failed: %w", err)
+	}
+	return pda, nil
+}
+
+func squadsTransactionPDA(multisig solana.PublicKey, index uint64) (solana.PublicKey, error) {
+	pda, _, err := solana.FindProgramAddress([][]byte{[]byte("multisig"), multisig[:], []byte("transaction"), binary.LittleEndian.AppendUint64(nil, index)}, squadsProgramID)
+	if err != nil {
+		return solana.PublicKey{}, fmt.Errorf("deriving the squads transaction failed: %w", err)
+	}
+	return pda, nil
+}
+
+func squadsProposalPDA(multisig solana.PublicKey, index uint64) (solana.PublicKey, error) {
+	pda, _, err := solana.FindProgramAddress([][]byte{[]byte("multisig"), multisig[:], []byte("transaction"), binary.LittleEndian.AppendUint64(nil, index), []byte("proposal")}, squadsProgramID)
+	if err != nil {
+		return solana.PublicKey{}, fmt.Errorf("deriving the squads proposal failed: %w", err)
+	}
+	return pda, nil
+}
+
+// anchorDiscriminator is the 8 bytes Anchor starts an instruction ("global:<name>") or account ("account:<Name>") with.
+func anchorDiscriminator(preimage string) []byte {
+	sum := sha256.Sum256([]byte(preimage))
+	return sum[:8]
+}
+
+// squadsMultisig is the part of a Multisig account we need.
+type squadsMultisig struct {
+	threshold        uint16
+	transactionIndex uint64
+	members          map[solana.PublicKey]uint8 // member -> permission bits
+}
+
+// decodeSquadsMultisig reads a Multisig account: discriminator, create_key, config_authority, threshold u16,
+// time_lock u32, transaction_index u64, stale_transaction_index u64, rent_collector Option<Pubkey>, bump u8, then the
+// members as a Vec of (key, permissions u8).
+func decodeSquadsMultisig(data []byte) (*squadsMultisig, error) {
+	const fixed = 8 + 32 + 32 + 2 + 4 + 8 + 8
+	if len(data) < fixed+1 || !bytes.Equal(data[:8], anchorDiscriminator("account:Multisig")) {
+		return nil, errors.New("not a squads v4 multisig account")
+	}
+	ms := &squadsMultisig{
+		threshold:        binary.LittleEndian.Uint16(data[72:]),
+		transactionIndex: binary.LittleEndian.Uint64(data[78:]),
+		members:          map[solana.PublicKey]uint8{},
+	}
+	off := fixed
+	if data[off] == 1 {
+		off += 32
+	}
+	off += 1 + 1 // the option tag, the bump
+	if len(data) < off+4 {
+		return nil, errors.New("squads multisig account is cut short")
+	}
+	n := int(binary.LittleEndian.Uint32(data[off:]))
+	off += 4
+	if len(data) < off+n*33 {
+		return nil, errors.New("squads multisig account is cut short")
+	}
+	for range n {
+		ms.members[solana.PublicKeyFromBytes(data[off:off+32])] = data[off+32]
+		off += 33
+	}
+	return ms, nil
+}
+
+func fetchSquadsMultisig(ctx context.Context, client *rpc.Client, multisig solana.PublicKey) (*squadsMultisig, error) {
+	res, err := client.GetAccountInfoWithOpts(ctx, multisig, &rpc.GetAccountInfoOpts{Encoding: solana.EncodingBase64})
+	if err != nil {
+		return nil, fmt.Errorf("rpc call getAccountInfo for the squads multisig failed: %w", err)
+	}
+	if res == nil || res.Value == nil {
+		return nil, fmt.Errorf("squads multisig %s doesn't exist on this network", multisig)
+	}
+	if !res.Value.Owner.Equals(squadsProgramID) {
+		return nil, fmt.Errorf("%s isn't a squads v4 multisig, it's owned by %s", multisig, res.Value.Owner)
+	}
+	ms, err := decodeSquadsMultisig(res.Value.Data.GetBinary())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", multisig, err)
+	}
+	return ms, nil
+}
+
+// squadsTransactionMessage compiles ixs into the message a vault transaction carries, run with vault as its signer.
+// It's a transaction message laid out the Squads way: the header, then the account keys (writable signers with the
+// vault first, readonly signers, writable non-signers, readonly non-signers), the instructions indexing into them and
+// no lookup tables, every length a u8 but instruction data's, a u16.
+func squadsTransactionMessage(vault solana.PublicKey, ixs []solana.Instruction) ([]byte, error) {
+	type keyMeta struct{ signer, writable bool }
+	metas := map[solana.PublicKey]*keyMeta{vault: {signer: true, writable: true}}
+	order := []solana.PublicKey{vault}
+	note := func(key solana.PublicKey, signer, writable bool) {
+		m, ok := metas[key]
+		if !ok {
+			m = &keyMeta{}
+			metas[key] = m
+			order = append(order, key)
+		}
+		m.signer = m.signer || signer
+		m.writable = m.writable || writable
+	}
+	for _, ix := range ixs {
+		for _, a := range ix.Accounts() {
+			if a.IsSigner && !a.PublicKey.Equals(vault) {
+				return nil, fmt.Errorf("%s has to sign, a vault transaction only has the vault to sign with", a.PublicKey)
+			}
+			note(a.PublicKey, a.IsSigner, a.IsWritable)
+		}
+		note(ix.ProgramID(), false, false)
+	}
+
+	var groups [4][]solana.PublicKey
+	for _, key := range order {
+		m := metas[key]
+		switch {
+		case m.signer && m.writable:
+			groups[0] = append(groups[0], key)
+		case m.signer:
+			groups[1] = append(groups[1], key)
+		case m.writable:
+			groups[2] = append(groups[2], key)
+		default:
+			groups[3] = append(groups[3], key)
+		}
+	}
+	var keys []solana.PublicKey
+	for _, g := range groups {
+		keys = append(keys, g...)
+	}
+	if len(keys) > 255 || len(ixs) > 255 {
+		return nil, errors.New("the swap has too many accounts or instructions for a vault transaction")
+	}
+	index := make(map[solana.PublicKey]byte, len(keys))
+	for i, key := range keys {
+		index[key] = byte(i)
+	}
+
+	b := &bytes.Buffer{}
+	b.WriteByte(byte(len(groups[0]) + len(groups[1])))
+	b.WriteByte(byte(len(groups[0])))
+	b.WriteByte(byte(len(groups[2])))
+	b.WriteByte(byte(len(keys)))
+	for _, key := range keys {
+		b.Write(key[:])
+	}
+	b.WriteByte(byte(len(ixs)))
+	for _, ix := range ixs {
+		data, err := ix.Data()
+		if err != nil {
+			return nil, fmt.Errorf("encoding an instruction of the swap failed: %w", err)
+		}
+		if len(data) > 0xffff || len(ix.Accounts()) > 255 {
+			return nil, errors.New("an instruction of the swap is too big for a vault transaction")
+		}
+		b.WriteByte(index[ix.ProgramID()])
+		b.WriteByte(byte(len(ix.Accounts())))
+		for _, a := range ix.Accounts() {
+			b.WriteByte(index[a.PublicKey])
+		}
+		b.Write(binary.LittleEndian.AppendUint16(nil, uint16(len(data))))
+		b.Write(data)
+	}
+	b.WriteByte(0) // address_table_lookups
+	return b.Bytes(), nil
+}
+
+// squadsProposalInstructions creates vault transaction index of multisig carrying message and opens a proposal for
+// it, created and paid for by creator.
+func squadsProposalInstructions(multisig, creator solana.PublicKey, vaultIndex uint8, index uint64, message []byte, memo string) ([]solana.Instruction, error) {
+	transaction, err := squadsTransactionPDA(multisig, index)
+	if err != nil {
+		return nil, err
+	}
+	proposal, err := squadsProposalPDA(multisig, index)
+	if err != nil {
+		return nil, err
+	}
+
+	create := &bytes.Buffer{}
+	create.Write(anchorDiscriminator("global:vault_transaction_create"))
+	create.WriteByte(vaultIndex)
+	create.WriteByte(0) // ephemeral_signers
+	create.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(message))))
+	create.Write(message)
+	if memo == "" {
+		create.WriteByte(0)
+	} else {
+		create.WriteByte(1)
+		create.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(memo))))
+		create.WriteString(memo)
+	}
+
+	open := &bytes.Buffer{}
+	open.Write(anchorDiscriminator("global:proposal_create"))
+	open.Write(binary.LittleEndian.AppendUint64(nil, index))
+	open.WriteByte(0) // draft, it goes straight to voting
+
+	return []solana.Instruction{
+		solana.NewInstruction(squadsProgramID, solana.AccountMetaSlice{
+			solana.Meta(multisig).WRITE(),
+			solana.Meta(transaction).WRITE(),
+			solana.Meta(creator).SIGNER(),
+			solana.Meta(creator).SIGNER().WRITE(), // rent_payer
+			solana.Meta(solana.SystemProgramID),
+		}, create.Bytes()),
+		solana.NewInstruction(squadsProgramID, solana.AccountMetaSlice{
+			solana.Meta(multisig),
+			solana.Meta(proposal).WRITE(),
+			solana.Meta(creator).SIGNER(),
+			solana.Meta(creator).SIGNER().WRITE(), // rent_payer
+			solana.Meta(solana.SystemProgramID),
+		}, open.Bytes()),
+	}, nil
+}
+
+// squadsProposal is what proposing a swap to a multisig left on chain.
+type squadsProposal struct {
+	index       uint64
+	transaction solana.PublicKey
+	proposal    solana.PublicKey
+	sig         solana.Signature
+	threshold   uint16 // votes it needs to be approved
+}
+
+// proposeToSquads plans intent with the vault as the wallet and opens a proposal for it on multisig, signed and paid
+// for by payer.
+func proposeToSquads(ctx context.Context, client *rpc.Client, payer solana.PrivateKey, multisig solana.PublicKey, vaultIndex uint8, intent *CPIntent) (squadsProposal, error) {
+	ms, err := fetchSquadsMultisig(ctx, client, multisig)
+	if err != nil {
+		return squadsProposal{}, err
+	}
+	if ms.members[payer.PublicKey()]&squadsPermissionInitiate == 0 {
+		return squadsProposal{}, fmt.Errorf("the hot wallet %s isn't a member of %s allowed to initiate proposals", Addr(payer.PublicKey().String()), Addr(multisig.String()))
+	}
+	vault, err := squadsVaultPDA(multisig, vaultIndex)
+	if err != nil {
+		return squadsProposal{}, err
+	}
+	plan, err := planSwap(ctx, client, vault, intent)
+	if err != nil {
+		return squadsProposal{}, err
+	}
+	var inner []solana.Instruction
+	for _, ix := range plan.instructions {
+		if !ix.ProgramID().Equals(computebudget.ProgramID) {
+			inner = append(inner, ix)
+		}
+	}
+	message, err := squadsTransactionMessage(vault, inner)
+	if err != nil {
+		return squadsProposal{}, err
+	}
+	index := ms.transactionIndex + 1
+	ixs, err := squadsProposalInstructions(multisig, payer.PublicKey(), vaultIndex, index, message, intent.String())
+	if err != nil {
+		return squadsProposal{}, err
+	}
+	proposed := squadsProposal{index: index, threshold: ms.threshold}
+	proposed.transaction, _ = squadsTransactionPDA(multisig, index)
+	proposed.proposal, _ = squadsProposalPDA(multisig, index)
+
+	ctx, cancel := deadlines.forSend(ctx)
+	defer cancel()
+	proposed.sig, err = signAndSend(ctx, client, payer, append(computeBudget.instructions(), ixs...))
+	if err != nil {
+		return proposed, fmt.Errorf("creating the squads proposal failed: %w", err)
+	}
+	status, result, err := waitForTransactionResult(ctx, client, proposed.sig)
+	if err != nil {
+		return proposed, fmt.Errorf("waiting for %s failed: %w", proposed.sig, err)
+	}
+	if status == "failed" {
+		failed := &txFailedError{sig: proposed.sig}
+		if result != nil && result.Meta != nil {
+			failed.txErr, failed.logs = result.Meta.Err, result.Meta.LogMessages
+		}
+		return proposed, failed
+	}
+	return proposed, nil
+}
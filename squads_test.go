@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+func TestDecodeSquadsMultisig(t *testing.T) {
+	member, voter := snapshotKey(1), snapshotKey(2)
+	account := func(rentCollector bool) []byte {
+		b := &bytes.Buffer{}
+		b.Write(anchorDiscriminator("account:Multisig"))
+		b.Write(make([]byte, 64)) // create_key, config_authority
+		b.Write(binary.LittleEndian.AppendUint16(nil, 2))
+		b.Write(binary.LittleEndian.AppendUint32(nil, 0))
+		b.Write(binary.LittleEndian.AppendUint64(nil, 41))
+		b.Write(binary.LittleEndian.AppendUint64(nil, 40))
+		if rentCollector {
+			b.WriteByte(1)
+			b.Write(make([]byte, 32))
+		} else {
+			b.WriteByte(0)
+		}
+		b.WriteByte(255) // bump
+		b.Write(binary.LittleEndian.AppendUint32(nil, 2))
+		b.Write(member[:])
+		b.WriteByte(squadsPermissionInitiate | 2 | 4)
+		b.Write(voter[:])
+		b.WriteByte(2)
+		return b.Bytes()
+	}
+	for _, rentCollector := range []bool{false, true} {
+		ms, err := decodeSquadsMultisig(account(rentCollector))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ms.threshold != 2 || ms.transactionIndex != 41 {
+			t.Errorf("threshold %d, transaction index %d", ms.threshold, ms.transactionIndex)
+		}
+		if ms.members[member]&squadsPermissionInitiate == 0 || ms.members[voter]&squadsPermissionInitiate != 0 {
+			t.Errorf("members %v", ms.members)
+		}
+	}
+	if _, err := decodeSquadsMultisig(account(false)[:100]); err == nil {
+		t.Error("decoded a cut short account")
+	}
+	if _, err := decodeSquadsMultisig(make([]byte, 200)); err == nil {
+		t.Error("decoded an account that isn't a multisig")
+	}
+}
+
+func TestSquadsTransactionMessage(t *testing.T) {
+	multisig := snapshotKey(10)
+	vault, err := squadsVaultPDA(multisig, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other, _ := squadsVaultPDA(multisig, 1); other.Equals(vault) {
+		t.Error("vaults 0 and 1 are the same address")
+	}
+	program, pool, mint := snapshotKey(20), snapshotKey(21), snapshotKey(22)
+	ixs := []solana.Instruction{
+		solana.NewInstruction(program, solana.AccountMetaSlice{solana.Meta(mint), solana.Meta(vault).SIGNER().WRITE(), solana.Meta(pool).WRITE()}, []byte{7, 8}),
+	}
+	msg, err := squadsTransactionMessage(vault, ixs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &bytes.Buffer{}
+	want.Write([]byte{1, 1, 1, 4}) // signers, writable signers, writable non-signers, 4 keys
+	for _, key := range []solana.PublicKey{vault, pool, mint, program} {
+		want.Write(key[:])
+	}
+	want.Write([]byte{1, 3, 3, 2, 0, 1, 2, 0, 7, 8}) // 1 instruction, program 3, accounts mint vault pool, 2 bytes of data
+	want.WriteByte(0)
+	if !bytes.Equal(msg, want.Bytes()) {
+		t.Errorf("message\n got %x\nwant %x", msg, want.Bytes())
+	}
+
+	stranger := snapshotKey(30)
+	ixs = append(ixs, solana.NewInstruction(program, solana.AccountMetaSlice{solana.Meta(stranger).SIGNER()}, nil))
+	if _, err := squadsTransactionMessage(vault, ixs); err == nil {
+		t.Error("compiled a message with a signer other than the vault")
+	}
+
+	proposal, err := squadsProposalInstructions(multisig, stranger, 0, 42, msg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	create, _ := proposal[0].Data()
+	if !bytes.HasPrefix(create, anchorDiscriminator("global:vault_transaction_create")) || !bytes.Equal(create[10:14], binary.LittleEndian.AppendUint32(nil, uint32(len(msg)))) {
+		t.Errorf("vault_transaction_create data %x", create)
+	}
+	transaction, _ := squadsTransactionPDA(multisig, 42)
+	if !proposal[0].Accounts()[1].PublicKey.Equals(transaction) {
+		t.Error("the vault transaction isn't created at index 42")
+	}
+	open, _ := proposal[1].Data()
+	if binary.LittleEndian.Uint64(open[8:]) != 42 {
+		t.Errorf("proposal_create data %x", open)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+/*
+NOTE(@hadydotai): Stop-loss and take-profit.
+
+`stop` guards a position: it sells the held token (`sell 100 TOKEN`) the moment its price drops to -stop-loss or rises
+to -take-profit, whichever comes first. Price means the same thing it does for limit orders, the price of the token
+the intent sells, in the pool's other token, quoted for the full amount (so it's what we'd actually get, impact
+included).
+
+-trailing turns the stop into a trailing one: we remember the best price seen since the stop was placed and sell once
+the price falls that many percent below it. The stop only ever ratchets up, when both -stop-loss and -trailing are set
+the higher of the two levels applies.
+
+It runs on the limit order engine, same vault subscriptions and retries. Take-profit clamps the slippage guard to its
+price like a sell limit does, a stop doesn't, when it fires the point is getting out. For the same reason the impact
+cap is off unless -max-impact sets one: a stop fires when the price is crashing or liquidity is leaving the pool, the
+very moment impact is high, and a capped stop would sit there watching. The slippage guard still bounds the fill.
+*/
+
+type stopTrigger struct {
+	stopLoss   *big.Rat
+	takeProfit *big.Rat
+	trailing   *big.Rat // fraction below the peak, 0.05 for 5%
+	peak       *big.Rat
+}
+
+// level is the price the stop currently sits at, nil when there's no stop (take-profit only, or trailing with nothing
+// seen yet).
+func (st *stopTrigger) level() *big.Rat {
+	level := st.stopLoss
+	if st.trailing != nil && st.peak != nil {
+		trail := new(big.Rat).Mul(st.peak, new(big.Rat).Sub(big.NewRat(1, 1), st.trailing))
+		if level == nil || trail.Cmp(level) > 0 {
+			level = trail
+		}
+	}
+	return level
+}
+
+func (st *stopTrigger) fires(price *big.Rat) (limitCondition, bool) {
+	if price == nil {
+		return limitCondition{}, false
+	}
+	if st.trailing != nil && (st.peak == nil || price.Cmp(st.peak) > 0) {
+		st.peak = new(big.Rat).Set(price)
+	}
+	if st.takeProfit != nil && price.Cmp(st.takeProfit) >= 0 {
+		return limitCondition{op: ">=", price: st.takeProfit}, true
+	}
+	if level := st.level(); level != nil && price.Cmp(level) <= 0 {
+		return limitCondition{op: "<=", price: level}, true
+	}
+	return limitCondition{}, false
+}
+
+func (st *stopTrigger) String() string {
+	var parts []string
+	if level := st.level(); level != nil {
+		stop := (limitCondition{op: "<=", price: level}).String()
+		if st.trailing != nil {
+			stop += fmt.Sprintf(" (trailing %s%% below %s)",
+				trimDecimal(new(big.Rat).Mul(st.trailing, big.NewRat(100, 1)).FloatString(4)), trimDecimal(st.peak.FloatString(9)))
+		}
+		parts = append(parts, stop)
+	} else if st.trailing != nil {
+		parts = append(parts, fmt.Sprintf("price falls %s%% below the best price seen",
+			trimDecimal(new(big.Rat).Mul(st.trailing, big.NewRat(100, 1)).FloatString(4))))
+	}
+	if st.takeProfit != nil {
+		parts = append(parts, (limitCondition{op: ">=", price: st.takeProfit}).String())
+	}
+	return strings.Join(parts, " or ")
+}
+
+func trimDecimal(s string) string {
+	if !strings.Contains(s, ".") {
+		return s
+	}
+	return strings.TrimRight(strings.TrimRight(s, "0"), ".")
+}
+
+// parsePrice reads an optional positive price flag, nil when it wasn't given.
+func parsePrice(name, v string) (*big.Rat, error) {
+	if v == "" {
+		return nil, nil
+	}
+	price, ok := new(big.Rat).SetString(v)
+	if !ok || price.Sign() <= 0 {
+		return nil, fmt.Errorf("%s %q must be a positive decimal number", name, v)
+	}
+	return price, nil
+}
+
+func newStopTrigger(stopLoss, takeProfit, trailingPct string) (*stopTrigger, error) {
+	st := &stopTrigger{}
+	var err error
+	if st.stopLoss, err = parsePrice("stop-loss", stopLoss); err != nil {
+		return nil, err
+	}
+	if st.takeProfit, err = parsePrice("take-profit", takeProfit); err != nil {
+		return nil, err
+	}
+	if trailingPct != "" {
+		pct, ok := new(big.Rat).SetString(trailingPct)
+		if !ok || pct.Sign() <= 0 || pct.Cmp(big.NewRat(100, 1)) >= 0 {
+			return nil, fmt.Errorf("trailing %q must be a percentage between 0 and 100", trailingPct)
+		}
+		st.trailing = pct.Quo(pct, big.NewRat(100, 1))
+	}
+	if st.stopLoss == nil && st.takeProfit == nil && st.trailing == nil {
+		return nil, errors.New("set at least one of -stop-loss, -take-profit or -trailing")
+	}
+	if st.stopLoss != nil && st.takeProfit != nil && st.stopLoss.Cmp(st.takeProfit) >= 0 {
+		return nil, errors.New("stop-loss has to be below take-profit")
+	}
+	return st, nil
+}
+
+func runStopCommand(args []string) error {
+	fs := flag.NewFlagSet("stop", flag.ExitOnError)
+	nf := addNetworkFlags(fs)
+	wf := addWebhookFlags(fs)
+	addCloseEmptyATAsFlag(fs)
+	addTradeHookFlags(fs)
+	addMintPolicyFlags(fs)
+	addSpendLimitFlags(fs)
+	addApprovalFlags(fs)
+	addRebroadcastFlags(fs)
+	addMinOutFromSimFlag(fs)
+	addComputeBudgetFlags(fs)
+	var (
+		hotwalletPath = fs.String("hotwallet", "", "Path to the hotwallet to use for signing transactions")
+		poolAddr      = fs.String("pool", "", "Pool to sell into")
+		intentLine    = fs.String("intent", "", "What to sell when the stop fires, e.g. \"sell 100 TOKEN\"")
+		stopLoss      = fs.String("stop-loss", "", "Sell once the price drops to this or below")
+		takeProfit    = fs.String("take-profit", "", "Sell once the price rises to this or above")
+		trailing      = fs.String("trailing", "", "Trailing stop, sell once the price falls this many percent below the best price seen")
+		unit          = fs.String("unit", "", "Symbol the prices are quoted in, checked against the pool's other token")
+		slippagePct   = fs.Float64("slippage", 0.5, "Slippage tolerance percentage (e.g. 0.5 for 0.5%)")
+		maxImpactPct  = fs.Float64("max-impact", 0, "Don't sell while the trade's price impact (including the trade fee) is above this percentage (0 for no cap)")
+		maxRetries    = fs.Int("max-retries", 3, "How many failed send attempts to tolerate before giving up")
+		wsEP          = fs.String("ws", "", "WebSocket endpoint for reserve updates, derived from -rpc when empty")
+		poll          = fs.Duration("poll", 15*time.Second, "Re-quote at least this often, even without reserve updates")
+		receiptsPath  = fs.String("receipts", "", "Append the fill to this receipts file (JSON lines)")
+		journalPath   = fs.String("journal", "", "Journal every send to this file, a restart settles what's still in flight instead of sending again (kept in memory when empty)")
+	)
+	expiry := addExpiryFlags(fs, 0)
+	addAmountFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, append(nf.specs(),
+		FlagSpec{Name: "hotwallet", Value: hotwalletPath, Rules: []FlagRule{NotEmpty()}},
+		FlagSpec{Name: "pool", Value: poolAddr, Rules: []FlagRule{NotEmpty()}},
+		FlagSpec{Name: "intent", Value: intentLine, Rules: []FlagRule{NotEmpty()}},
+	))
+	if *maxImpactPct < 0 || *maxRetries < 0 || *poll <= 0 {
+		return errors.New("poll must be greater than zero, max-impact and max-retries can't be negative")
+	}
+	instruction, err := parseIntent(*intentLine)
+	if err != nil {
+		return err
+	}
+	if instruction.Dir != SwapDirSell {
+		return fmt.Errorf("stop intent %q has to sell the held token (pay, sell or swap)", *intentLine)
+	}
+	if instruction.Condition != nil {
+		return fmt.Errorf("stop intent %q can't carry an `at` price, the stop flags decide when it sells", *intentLine)
+	}
+	trig, err := newStopTrigger(*stopLoss, *takeProfit, *trailing)
+	if err != nil {
+		return err
+	}
+	var maxImpact *big.Rat
+	if *maxImpactPct > 0 {
+		var ok bool
+		if maxImpact, ok = new(big.Rat).SetString(fmt.Sprintf("%g", *maxImpactPct)); !ok {
+			return fmt.Errorf("invalid max-impact %v", *maxImpactPct)
+		}
+		maxImpact.Quo(maxImpact, big.NewRat(100, 1))
+	}
+
+	journal, err := openSendJournal(*journalPath)
+	if err != nil {
+		return err
+	}
+	payer, err := solana.PrivateKeyFromSolanaKeygenFile(*hotwalletPath)
+	if err != nil {
+		return fmt.Errorf("failed to load private key from hot wallet: %w", err)
+	}
+	poolPubK, err := solana.PublicKeyFromBase58(*poolAddr)
+	if err != nil {
+		return fmt.Errorf("deriving public key from pool address (base58) failed, make sure it's base58 encoded: %w", err)
+	}
+	flushWebhooks, err := wf.start("stop", *nf.network)
+	if err != nil {
+		return err
+	}
+	defer flushWebhooks()
+	client := nf.connect()
+	if *wsEP == "" {
+		*wsEP = wsEndpointFor(*nf.rpcEP)
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	loaded, err := loadPool(ctx, client, poolPubK)
+	if err != nil {
+		return err
+	}
+	builder, err := newTableBuilder(ctx, client, loaded, *slippagePct)
+	if err != nil {
+		return err
+	}
+	builder.useWallet(payer.PublicKey())
+	engine := &limitEngine{
+		ctx:        ctx,
+		client:     client,
+		builder:    builder,
+		payer:      payer,
+		network:    *nf.network,
+		kind:       "stop",
+		intent:     instruction.String(),
+		trigger:    trig,
+		unit:       *unit,
+		maxImpact:  maxImpact,
+		maxRetries: *maxRetries,
+		expiresAt:  expiry.deadline(time.Now()),
+		wsEP:       *wsEP,
+		poll:       *poll,
+		receipts:   *receiptsPath,
+		guard:      newSendGuard(journal, fmt.Sprintf("stop %s %s stop-loss=%s take-profit=%s trailing=%s", poolPubK, instruction, *stopLoss, *takeProfit, *trailing)),
+	}
+	return engine.run()
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestStopTriggerFixedLevels(t *testing.T) {
+	st, err := newStopTrigger("0.8", "1.5", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := st.fires(big.NewRat(1, 1)); ok {
+		t.Fatalf("1.0 is between the stop and the target")
+	}
+	cond, ok := st.fires(big.NewRat(8, 10))
+	if !ok || cond.op != "<=" || cond.price.Cmp(big.NewRat(8, 10)) != 0 {
+		t.Fatalf("expected the stop-loss to fire, got %v %v", cond, ok)
+	}
+	cond, ok = st.fires(big.NewRat(2, 1))
+	if !ok || cond.op != ">=" {
+		t.Fatalf("expected take-profit to fire, got %v %v", cond, ok)
+	}
+	if got := st.String(); got != "price <= 0.8 or price >= 1.5" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestStopTriggerTrailing(t *testing.T) {
+	st, err := newStopTrigger("0.5", "", "10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []*big.Rat{big.NewRat(1, 1), big.NewRat(2, 1), big.NewRat(19, 10)} {
+		if _, ok := st.fires(p); ok {
+			t.Fatalf("%s shouldn't fire, the stop is at %s", p.FloatString(2), st.level().FloatString(2))
+		}
+	}
+	if st.level().Cmp(big.NewRat(18, 10)) != 0 {
+		t.Fatalf("expected the stop to trail the 2.0 peak at 1.8, got %s", st.level().FloatString(4))
+	}
+	cond, ok := st.fires(big.NewRat(18, 10))
+	if !ok || cond.price.Cmp(big.NewRat(18, 10)) != 0 {
+		t.Fatalf("expected the trailing stop to fire at 1.8, got %v %v", cond, ok)
+	}
+}
+
+func TestNewStopTriggerValidation(t *testing.T) {
+	for _, tc := range [][3]string{
+		{"", "", ""},
+		{"2", "1", ""},
+		{"-1", "", ""},
+		{"", "", "100"},
+		{"", "", "abc"},
+	} {
+		if _, err := newStopTrigger(tc[0], tc[1], tc[2]); err == nil {
+			t.Errorf("expected %q to be rejected", tc)
+		}
+	}
+	st, err := newStopTrigger("", "", "5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := st.String(); got != "price falls 5% below the best price seen" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestStopFiresAtHighImpact(t *testing.T) {
+	pool, addr, balances := snapshotPool()
+	vaults := vaultBalanceServer(t, map[solana.PublicKey]*PoolBalance{pool.Token0Vault: balances[0], pool.Token1Vault: balances[1]})
+	symm := SymbolMapping{
+		mintToSymbol: map[string]string{pool.Token0Mint.String(): "SOL", pool.Token1Mint.String(): "USDC"},
+		symbolToMint: map[string]solana.PublicKey{"SOL": pool.Token0Mint, "USDC": pool.Token1Mint},
+		unresolved:   map[string]struct{}{},
+	}
+	lp := &loadedPool{address: addr, pool: pool, ammConfig: &raydium_cp_swap.AmmConfig{TradeFeeRate: 2500}, symbolsMap: symm}
+	tb, err := newTableBuilder(context.Background(), rpc.New(vaults.URL), lp, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := newStopTrigger("140", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	le := &limitEngine{builder: tb, kind: "stop", intent: "sell 200 SOL", trigger: st}
+	// A fifth of the pool's SOL, the quote is well under the 140 stop and the impact well over 5%.
+	intent, price, err := le.quote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := le.trigger.fires(price); !ok {
+		t.Fatalf("the stop didn't fire at %s", price.FloatString(2))
+	}
+	if over, _, err := le.overImpactCap(intent); over || err != nil {
+		t.Fatalf("a stop without -max-impact held back: %v %v", over, err)
+	}
+	le.maxImpact = big.NewRat(5, 100)
+	if over, impact, err := le.overImpactCap(intent); !over || err != nil {
+		t.Fatalf("-max-impact 5 let a %s impact through: %v", pctString(impact), err)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): What a swap costs on top of the swap.
+
+The report's amounts are what goes through the pool, the SOL the transaction itself takes comes on top and used to be
+left to the explorer to find out. The Est. cost row adds it up before anything's confirmed:
+
+  - the network fee, 5000 lamports per signature, and a swap has the one
+  - the priority fee, compute unit limit times the price (micro-lamports) from the compute budget (compute_budget.go),
+    what's charged whether the swap uses all the units or not
+  - rent for a token account the swap has to create to receive into (or pay from), planRoute creates the wallet's
+    ATA when it's missing, and that account stays the wallet's, rent included
+  - rent for the wSOL account, when SOL is wrapped or unwrapped through an account created for the swap. It's closed
+    in the same transaction and the rent comes straight back, so it's shown but not counted in the total, the wallet
+    only needs it for the moment.
+
+Token account rent is the rent-exempt minimum of a 165 byte account, the same on every cluster. A Token-2022 account
+carrying extensions is a little bigger and costs a little more. Whether the accounts exist is read with the quote, one
+getMultipleAccounts, and only with a wallet to read them for, without one the row has the fees and says the rent
+wasn't checked. A failed read leaves the rent out the same way rather than failing the quote.
+*/
+
+const (
+	lamportsPerSignature = 5000
+	// tokenAccountRent is the rent-exempt minimum of a 165 byte token account.
+	tokenAccountRent = 2_039_280
+)
+
+// swapCost is the SOL a swap transaction takes on top of the swap, in lamports.
+type swapCost struct {
+	networkFee  uint64
+	priorityFee uint64
+	budget      feePreset
+	accountRent uint64   // for token accounts the swap creates and leaves open
+	newAccounts []string // symbols of the tokens those accounts hold
+	wsolRent    uint64   // for the wSOL account created and closed by the swap, refunded
+	rentErr     string   // why the rent wasn't checked, empty when it was
+}
+
+func (c swapCost) total() uint64 {
+	return c.networkFee + c.priorityFee + c.accountRent
+}
+
+// priorityFee is what budget pays in lamports when every unit is charged, micro-lamports rounded up.
+func priorityFee(budget feePreset) uint64 {
+	fee := new(big.Int).Mul(new(big.Int).SetUint64(uint64(budget.unitLimit)), new(big.Int).SetUint64(budget.unitPrice))
+	fee.Add(fee, big.NewInt(999_999))
+	return fee.Quo(fee, big.NewInt(1_000_000)).Uint64()
+}
+
+// estimateSwapCost is what sending intent from wallet costs on top of the swap, with budget for the compute budget.
+// A zero wallet leaves the rent unchecked.
+func estimateSwapCost(ctx context.Context, client *rpc.Client, wallet solana.PublicKey, intent *CPIntent, budget feePreset, symm SymbolMapping) swapCost {
+	cost := swapCost{networkFee: lamportsPerSignature, priorityFee: priorityFee(budget), budget: budget}
+	if wallet.IsZero() {
+		cost.rentErr = "no wallet to check the token accounts of"
+		return cost
+	}
+	legs := []SwapLeg{intent.TokenIn, intent.TokenOut}
+	mints := []solana.PublicKey{intent.TokenIn.Mint, intent.TokenOut.Mint}
+	atas := make([]solana.PublicKey, len(mints))
+	for i, mint := range mints {
+		ata, err := associatedTokenAddress(wallet, mint, legProgram(legs[i]))
+		if err != nil {
+			cost.rentErr = err.Error()
+			return cost
+		}
+		atas[i] = ata
+	}
+	readCtx, cancel := deadlines.forQuote(ctx)
+	defer cancel()
+	res, err := client.GetMultipleAccountsWithOpts(readCtx, atas, &rpc.GetMultipleAccountsOpts{
+		Encoding:   solana.EncodingBase64,
+		Commitment: rpc.CommitmentProcessed,
+		// Only whether they exist matters, not what they hold.
+		DataSlice: &rpc.DataSlice{Offset: ptrTo(uint64(0)), Length: ptrTo(uint64(0))},
+	})
+	if err != nil || len(res.Value) != len(atas) {
+		cost.rentErr = "the token accounts couldn't be read"
+		return cost
+	}
+	for i, acc := range res.Value {
+		switch {
+		case acc != nil:
+		case isNativeSOL(mints[i]):
+			cost.wsolRent = tokenAccountRent
+		default:
+			cost.accountRent += tokenAccountRent
+			cost.newAccounts = append(cost.newAccounts, symm.SymFrom(mints[i]))
+		}
+	}
+	return cost
+}
+
+// String is the cost as the report's Est. cost row shows it, the total and then what it's made of, a line each.
+func (c swapCost) String() string {
+	lines := []string{
+		fmt.Sprintf("%s SOL (%d lamports)", trimDecimal(fmtAmount(new(big.Int).SetUint64(c.total()), 9)), c.total()),
+		fmt.Sprintf("network fee %d", c.networkFee),
+		fmt.Sprintf("priority fee %d (%d CU at %d micro-lamports)", c.priorityFee, c.budget.unitLimit, c.budget.unitPrice),
+	}
+	if c.accountRent > 0 {
+		lines = append(lines, fmt.Sprintf("%s account rent %d", strings.Join(c.newAccounts, " and "), c.accountRent))
+	}
+	switch {
+	case c.rentErr != "":
+		lines = append(lines, "account rent not included, "+c.rentErr)
+	case c.wsolRent > 0:
+		lines = append(lines, fmt.Sprintf("wSOL account rent %d, refunded in the same transaction", c.wsolRent))
+	}
+	return strings.Join(lines, "\n")
+}
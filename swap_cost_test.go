@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestPriorityFee(t *testing.T) {
+	for _, tc := range []struct {
+		budget feePreset
+		want   uint64
+	}{
+		{feePreset{unitLimit: 400_000, unitPrice: 50_000}, 20_000},
+		{feePreset{unitLimit: 400_000, unitPrice: 0}, 0},
+		// Micro-lamports round up to the next lamport.
+		{feePreset{unitLimit: 200_001, unitPrice: 5}, 2},
+	} {
+		if got := priorityFee(tc.budget); got != tc.want {
+			t.Errorf("%d CU at %d: %d lamports, want %d", tc.budget.unitLimit, tc.budget.unitPrice, got, tc.want)
+		}
+	}
+}
+
+func TestEstimateSwapCost(t *testing.T) {
+	pool, _, _ := snapshotPool()
+	symm := SymbolMapping{mintToSymbol: map[string]string{pool.Token0Mint.String(): "SOL", pool.Token1Mint.String(): "USDC"}}
+	wallet := snapshotKey(80)
+	usdcATA, _, _ := solana.FindAssociatedTokenAddress(wallet, pool.Token1Mint)
+	budget := feePreset{unitLimit: 400_000, unitPrice: 50_000}
+	sellSOL := &CPIntent{TokenIn: SwapLeg{Mint: pool.Token0Mint, Decimals: 9}, TokenOut: SwapLeg{Mint: pool.Token1Mint, Decimals: 6}}
+	buySOL := &CPIntent{TokenIn: sellSOL.TokenOut, TokenOut: sellSOL.TokenIn}
+
+	// Neither account exists: the USDC one is made and kept, the wSOL one is made and closed.
+	empty := rpc.New(chainServer(t, map[solana.PublicKey]chainAccount{}, nil, &atomic.Int64{}).URL)
+	cost := estimateSwapCost(context.Background(), empty, wallet, sellSOL, budget, symm)
+	if cost.networkFee != 5000 || cost.priorityFee != 20_000 || cost.accountRent != tokenAccountRent || cost.wsolRent != tokenAccountRent {
+		t.Errorf("cost %+v", cost)
+	}
+	if cost.total() != 5000+20_000+tokenAccountRent {
+		t.Errorf("total %d leaves the refunded wSOL rent out", cost.total())
+	}
+	out := cost.String()
+	for _, want := range []string{"0.00206428 SOL (2064280 lamports)", "USDC account rent 2039280", "wSOL account rent 2039280, refunded"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("%q isn't in\n%s", want, out)
+		}
+	}
+
+	// The USDC account is there, only the fees are paid.
+	held := rpc.New(chainServer(t, map[solana.PublicKey]chainAccount{usdcATA: {owner: solana.TokenProgramID, data: tokenAccountData(pool.Token1Mint, 1)}}, nil, &atomic.Int64{}).URL)
+	if cost := estimateSwapCost(context.Background(), held, wallet, buySOL, budget, symm); cost.accountRent != 0 || cost.total() != 25_000 {
+		t.Errorf("with the USDC account %+v", cost)
+	}
+
+	// Without a wallet there are no accounts to check, the fees are still there.
+	cost = estimateSwapCost(context.Background(), empty, solana.PublicKey{}, sellSOL, budget, symm)
+	if cost.total() != 25_000 || !strings.Contains(cost.String(), "account rent not included") {
+		t.Errorf("without a wallet %+v", cost)
+	}
+}
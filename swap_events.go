@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/base64"
+	"math/big"
+	"strings"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): Swap events.
+
+What a swap paid and received used to be read off the pool vaults, the difference between their balances before and
+after the transaction. That's right as long as nothing else in the transaction moves the same vaults, and it's the
+vault's side of the trade, not the user's: a Token-2022 mint with a transfer fee takes its cut on the way, so the
+vault gets less than the user paid, and the user gets less than the vault sent.
+
+cp-swap says exactly what it did. Every swap emits a SwapEvent (Anchor's emit!, a `Program data: <base64>` log line,
+not a self-CPI, so there's no inner instruction to look at) with the amounts it swapped, the transfer fees either
+side and the trade fee. loggedSwapEvents (which `vectors` reads them with too) walks the logs keeping track of which
+program is running, and swapFillsFromResult pairs each event cp-swap emitted with the top level instruction it ran
+in, the n-th `invoke [1]` line being the n-th instruction. The instruction's accounts say which pool and vaults the
+event is for, that's how a split route's legs get their own fill and how a cp-swap pool a router called through (a
+CPI, not a top level instruction) is left out.
+
+The user paid what was swapped plus the input transfer fee and got what was swapped less the output transfer fee.
+Older deployments emit a shorter event, fields it doesn't have read as zero, and the trade fee is only reported when
+it's there. Logs the node truncated may be missing events, so then, or when any leg has no event, everything falls
+back to the vault balances like before.
+*/
+
+// swapEventLen is a SwapEvent with its discriminator, swapEventTradeFeeEnd where its trade fee ends. Older
+// deployments stop before the mints.
+const (
+	swapEventLen         = 8 + 32 + 6*8 + 1 + 32 + 32 + 8 + 8 + 1
+	swapEventTradeFeeEnd = 8 + 32 + 6*8 + 1 + 32 + 32 + 8
+)
+
+// swapFill is one swap cp-swap made in a transaction, as its event has it, with the accounts it made it against.
+type swapFill struct {
+	pool        solana.PublicKey
+	inputVault  solana.PublicKey
+	outputVault solana.PublicKey
+	event       raydium_cp_swap.SwapEvent
+	hasTradeFee bool
+}
+
+// paid is what left the user's account, received what arrived in it.
+func (f swapFill) paid() *big.Int {
+	return new(big.Int).Add(new(big.Int).SetUint64(f.event.InputAmount), new(big.Int).SetUint64(f.event.InputTransferFee))
+}
+
+func (f swapFill) received() *big.Int {
+	return new(big.Int).Sub(new(big.Int).SetUint64(f.event.OutputAmount), new(big.Int).SetUint64(f.event.OutputTransferFee))
+}
+
+// decodeSwapEvent reads a `Program data:` payload as a SwapEvent. full reports whether the payload went as far as the
+// trade fee, ok is false when it's some other event.
+func decodeSwapEvent(data []byte) (event raydium_cp_swap.SwapEvent, full, ok bool) {
+	full = len(data) >= swapEventTradeFeeEnd
+	if len(data) < swapEventLen {
+		data = append(append([]byte{}, data...), make([]byte, swapEventLen-len(data))...)
+	}
+	parsed, err := raydium_cp_swap.ParseEvent_SwapEvent(data)
+	if err != nil {
+		return raydium_cp_swap.SwapEvent{}, false, false
+	}
+	return *parsed, full, true
+}
+
+// loggedSwapEvent is a SwapEvent as it turned up in a transaction's logs.
+type loggedSwapEvent struct {
+	event       raydium_cp_swap.SwapEvent
+	hasTradeFee bool
+	instruction int // the top level instruction it was emitted under
+	depth       int // 1 when the program emitting it was the top level instruction, more when it was called into
+}
+
+// programData is a `Program data:` payload as it turned up in a transaction's logs.
+type programData struct {
+	data        []byte
+	instruction int // the top level instruction it was logged under
+	depth       int // 1 when the program logging it was the top level instruction, more when it was called into
+}
+
+// programDataLogs returns the `Program data:` payloads programID logged, in order. Only lines logged while the program
+// itself is executing count, anything else could be another program's event with a colliding prefix.
+func programDataLogs(logs []string, programID solana.PublicKey) []programData {
+	var (
+		stack []string // the program invocation stack, innermost last
+		top   = -1
+		out   []programData
+	)
+	for _, line := range logs {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) >= 4 && fields[0] == "Program" && fields[2] == "invoke":
+			if fields[3] == "[1]" {
+				top++
+				stack = stack[:0]
+			}
+			stack = append(stack, fields[1])
+		case len(fields) >= 3 && fields[0] == "Program" && (fields[2] == "success" || strings.HasPrefix(fields[2], "failed")):
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case len(fields) == 3 && fields[0] == "Program" && fields[1] == "data:":
+			if len(stack) == 0 || stack[len(stack)-1] != programID.String() {
+				continue
+			}
+			data, err := base64.StdEncoding.DecodeString(fields[2])
+			if err != nil {
+				continue
+			}
+			out = append(out, programData{data: data, instruction: top, depth: len(stack)})
+		}
+	}
+	return out
+}
+
+// loggedSwapEvents returns the SwapEvents the program emitted, in order, see programDataLogs.
+func loggedSwapEvents(logs []string, programID solana.PublicKey) []loggedSwapEvent {
+	var events []loggedSwapEvent
+	for _, pd := range programDataLogs(logs, programID) {
+		if ev, full, ok := decodeSwapEvent(pd.data); ok {
+			events = append(events, loggedSwapEvent{event: ev, hasTradeFee: full, instruction: pd.instruction, depth: pd.depth})
+		}
+	}
+	return events
+}
+
+// swapFillsFromResult pairs the SwapEvents cp-swap emitted in a transaction with the top level swap instructions they
+// came from. ok is false when the logs are missing or were truncated, the fills can't be trusted to be all of them.
+func swapFillsFromResult(result *rpc.GetTransactionResult) ([]swapFill, bool) {
+	if result == nil || result.Meta == nil || result.Transaction == nil || len(result.Meta.LogMessages) == 0 || logsTruncated(result.Meta.LogMessages) {
+		return nil, false
+	}
+	tx, err := result.Transaction.GetTransaction()
+	if err != nil || tx == nil {
+		return nil, false
+	}
+	keys := txAccountKeys(tx, result.Meta)
+	account := func(ix solana.CompiledInstruction, pos int) (solana.PublicKey, bool) {
+		if pos >= len(ix.Accounts) || int(ix.Accounts[pos]) >= len(keys) {
+			return solana.PublicKey{}, false
+		}
+		return keys[ix.Accounts[pos]], true
+	}
+	var fills []swapFill
+	for _, le := range loggedSwapEvents(result.Meta.LogMessages, raydium_cp_swap.ProgramID) {
+		// A router calling into the pool isn't a swap of ours.
+		if le.depth != 1 || le.instruction < 0 || le.instruction >= len(tx.Message.Instructions) {
+			continue
+		}
+		ix := tx.Message.Instructions[le.instruction]
+		fill := swapFill{event: le.event, hasTradeFee: le.hasTradeFee}
+		var okPool, okIn, okOut bool
+		fill.pool, okPool = account(ix, swapAccPool)
+		fill.inputVault, okIn = account(ix, swapAccInputVault)
+		fill.outputVault, okOut = account(ix, swapAccOutputVault)
+		if okPool && okIn && okOut && fill.pool.Equals(le.event.PoolId) {
+			fills = append(fills, fill)
+		}
+	}
+	return fills, true
+}
+
+// logsTruncated reports whether the node cut the logs short, events past the cut are missing.
+func logsTruncated(logs []string) bool {
+	for _, line := range logs {
+		if strings.HasPrefix(line, "Log truncated") {
+			return true
+		}
+	}
+	return false
+}
+
+// routeFills finds the fill of every leg of a route, legs paired by index, in and out. ok is false unless every leg
+// has exactly one.
+func routeFills(result *rpc.GetTransactionResult, legsIn, legsOut []SwapLeg) ([]swapFill, bool) {
+	fills, ok := swapFillsFromResult(result)
+	if !ok || len(legsIn) != len(legsOut) {
+		return nil, false
+	}
+	matched := make([]swapFill, len(legsIn))
+	for i := range legsIn {
+		found := 0
+		for _, f := range fills {
+			if f.inputVault.Equals(legsIn[i].Vault) && f.outputVault.Equals(legsOut[i].Vault) {
+				matched[i] = f
+				found++
+			}
+		}
+		if found != 1 {
+			return nil, false
+		}
+	}
+	return matched, true
+}
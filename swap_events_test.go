@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	computebudget "github.com/gagliardetto/solana-go/programs/compute-budget"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// eventTx is a swap transaction on pool, as getTransaction returns it: a compute budget instruction, the swap, and
+// whatever logs and vault balances a test gives it.
+type eventTx struct {
+	payer, pool, inVault, outVault, inMint, outMint solana.PublicKey
+}
+
+func newEventTx() eventTx {
+	key := func() solana.PublicKey { return solana.NewWallet().PublicKey() }
+	return eventTx{payer: key(), pool: key(), inVault: key(), outVault: key(), inMint: key(), outMint: key()}
+}
+
+func (et eventTx) result(t *testing.T, logs []string, pre, post [2]string) *rpc.GetTransactionResult {
+	t.Helper()
+	accounts := make(solana.AccountMetaSlice, swapAccOutputMint+2)
+	for i := range accounts {
+		accounts[i] = solana.Meta(solana.NewWallet().PublicKey())
+	}
+	accounts[0] = solana.Meta(et.payer).SIGNER().WRITE()
+	accounts[swapAccPool] = solana.Meta(et.pool).WRITE()
+	accounts[swapAccInputVault] = solana.Meta(et.inVault).WRITE()
+	accounts[swapAccOutputVault] = solana.Meta(et.outVault).WRITE()
+	tx, err := solana.NewTransaction([]solana.Instruction{
+		computebudget.NewSetComputeUnitLimitInstruction(400_000).Build(),
+		solana.NewInstruction(raydium_cp_swap.ProgramID, accounts, raydium_cp_swap.Instruction_SwapBaseInput[:]),
+	}, solana.Hash{1}, solana.TransactionPayer(et.payer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &rpc.TransactionResultEnvelope{}
+	if err := env.UnmarshalJSON(raw); err != nil {
+		t.Fatal(err)
+	}
+	index := func(key solana.PublicKey) int {
+		for i, k := range tx.Message.AccountKeys {
+			if k.Equals(key) {
+				return i
+			}
+		}
+		t.Fatalf("%s isn't in the transaction", key)
+		return -1
+	}
+	return &rpc.GetTransactionResult{
+		Transaction: env,
+		Meta: &rpc.TransactionMeta{
+			LogMessages: logs,
+			PreTokenBalances: []rpc.TokenBalance{
+				makeTokenBalance(index(et.inVault), et.inMint, pre[0], 6),
+				makeTokenBalance(index(et.outVault), et.outMint, pre[1], 9),
+			},
+			PostTokenBalances: []rpc.TokenBalance{
+				makeTokenBalance(index(et.inVault), et.inMint, post[0], 6),
+				makeTokenBalance(index(et.outVault), et.outMint, post[1], 9),
+			},
+		},
+	}
+}
+
+func (et eventTx) legs() ([]SwapLeg, []SwapLeg) {
+	return []SwapLeg{{Mint: et.inMint, Vault: et.inVault, Decimals: 6}}, []SwapLeg{{Mint: et.outMint, Vault: et.outVault, Decimals: 9}}
+}
+
+// swapLogs are the logs of the swap instruction emitting ev, after a compute budget instruction.
+func swapLogs(t *testing.T, ev raydium_cp_swap.SwapEvent) []string {
+	cpSwap := raydium_cp_swap.ProgramID.String()
+	return []string{
+		"Program ComputeBudget111111111111111111111111111111 invoke [1]",
+		"Program ComputeBudget111111111111111111111111111111 success",
+		"Program " + cpSwap + " invoke [1]",
+		"Program log: Instruction: SwapBaseInput",
+		"Program TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA invoke [2]",
+		"Program TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA success",
+		programDataLine(t, ev),
+		"Program " + cpSwap + " consumed 40000 of 400000 compute units",
+		"Program " + cpSwap + " success",
+	}
+}
+
+func TestSummarizeRouteFromEvents(t *testing.T) {
+	et := newEventTx()
+	ev := raydium_cp_swap.SwapEvent{PoolId: et.pool, InputAmount: 990, InputTransferFee: 10, OutputAmount: 500, OutputTransferFee: 5, BaseInput: true, TradeFee: 3}
+	// The vaults moved by more than this swap, something else in the transaction traded the same pool.
+	result := et.result(t, swapLogs(t, ev), [2]string{"10000", "20000"}, [2]string{"12000", "19000"})
+	legsIn, legsOut := et.legs()
+	summary := summarizeRoute(solana.Signature{}, "confirmed", result, legsIn, legsOut, "IN", "OUT")
+	if summary.PaidAmount.Cmp(big.NewInt(1000)) != 0 || summary.ReceivedAmount.Cmp(big.NewInt(495)) != 0 {
+		t.Errorf("paid %s received %s, want 1000 and 495", summary.PaidAmount, summary.ReceivedAmount)
+	}
+	if summary.TradeFee == nil || summary.TradeFee.Cmp(big.NewInt(3)) != 0 {
+		t.Errorf("trade fee %v", summary.TradeFee)
+	}
+}
+
+func TestSummarizeRouteFallsBackToVaults(t *testing.T) {
+	et := newEventTx()
+	ev := raydium_cp_swap.SwapEvent{PoolId: et.pool, InputAmount: 990, OutputAmount: 500, BaseInput: true}
+	other := solana.NewWallet().PublicKey().String()
+	cpSwap := raydium_cp_swap.ProgramID.String()
+	truncated := append(swapLogs(t, ev)[:6], "Log truncated")
+	wrongPool := ev
+	wrongPool.PoolId = solana.NewWallet().PublicKey()
+	for name, logs := range map[string][]string{
+		"no logs":   nil,
+		"truncated": truncated,
+		// The pool was called into by a router, not by us.
+		"cpi": {
+			"Program " + other + " invoke [1]",
+			"Program " + cpSwap + " invoke [2]",
+			programDataLine(t, ev),
+			"Program " + cpSwap + " success",
+			"Program " + other + " success",
+		},
+		"another pool's event": swapLogs(t, wrongPool),
+	} {
+		t.Run(name, func(t *testing.T) {
+			result := et.result(t, logs, [2]string{"10000", "20000"}, [2]string{"11000", "19500"})
+			legsIn, legsOut := et.legs()
+			summary := summarizeRoute(solana.Signature{}, "confirmed", result, legsIn, legsOut, "IN", "OUT")
+			if summary.PaidAmount.Cmp(big.NewInt(1000)) != 0 || summary.ReceivedAmount.Cmp(big.NewInt(500)) != 0 || summary.TradeFee != nil {
+				t.Errorf("paid %s received %s trade fee %v, want the vault deltas 1000 and 500", summary.PaidAmount, summary.ReceivedAmount, summary.TradeFee)
+			}
+		})
+	}
+}
+
+func TestDecodeSwapEventOlderLayout(t *testing.T) {
+	ev := raydium_cp_swap.SwapEvent{PoolId: solana.NewWallet().PublicKey(), InputAmount: 7, OutputAmount: 9, BaseInput: true}
+	body, err := ev.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	full := append(raydium_cp_swap.Event_SwapEvent[:], body...)
+	if len(full) != swapEventLen {
+		t.Fatalf("a SwapEvent is %d bytes, swapEventLen says %d", len(full), swapEventLen)
+	}
+	// Before the mints were added the event stopped after base_input.
+	older := full[:8+32+6*8+1]
+	got, hasTradeFee, ok := decodeSwapEvent(older)
+	if !ok || hasTradeFee || got.InputAmount != 7 || got.OutputAmount != 9 || !got.PoolId.Equals(ev.PoolId) {
+		t.Errorf("decoded %+v, trade fee %v, ok %v", got, hasTradeFee, ok)
+	}
+	if _, _, ok := decodeSwapEvent([]byte("not an event")); ok {
+		t.Error("decoded something that isn't a SwapEvent")
+	}
+}
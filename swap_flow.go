@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"math/rand/v2"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// swapFlow is the steps of the default command (load the pool, compare, quote, send), each one taking whatever client
+// it's given, a replayed one included. main wires the flags in and turns the errors into exits.
+type swapFlow struct {
+	client      *rpc.Client
+	payer       solana.PrivateKey // nil when nothing gets signed, e.g. watching
+	network     string
+	intentLine  string
+	slippagePct float64
+	out         io.Writer
+}
+
+// errRetryDeclined is the user saying no to retrying a failed swap on another pool.
+var errRetryDeclined = errors.New("retry on another pool declined")
+
+// openPool resolves target, a pool address or a pair, and loads a builder on it. A pair with no pool comes back as
+// errNoPoolForPair.
+func (f *swapFlow) openPool(ctx context.Context, target string) (*TableBuilder, solana.PublicKey, error) {
+	poolPubK, err := resolvePoolTarget(ctx, f.client, target, SymbolMapping{})
+	if err != nil {
+		return nil, solana.PublicKey{}, err
+	}
+	loaded, err := loadPool(ctx, f.client, poolPubK)
+	if err != nil {
+		return nil, solana.PublicKey{}, err
+	}
+	builder, err := newTableBuilder(ctx, f.client, loaded, f.slippagePct)
+	if err != nil {
+		return nil, solana.PublicKey{}, err
+	}
+	if f.payer != nil {
+		builder.useWallet(f.payer.PublicKey())
+	}
+	return builder, poolPubK, nil
+}
+
+// compare prints the intent quoted on every CP-Swap pool for the pair and, with pick, moves builder to the best one.
+func (f *swapFlow) compare(ctx context.Context, builder *TableBuilder, current solana.PublicKey, pick bool) error {
+	candidates, err := comparePools(ctx, f.client, builder, f.intentLine)
+	if err != nil {
+		return fmt.Errorf("comparing pools failed: %w", err)
+	}
+	fmt.Fprint(f.out, renderPoolComparison(candidates, current, builder.symbols()))
+	if !pick {
+		return nil
+	}
+	best, ok := bestPool(candidates)
+	if !ok {
+		return errors.New("no CP-Swap pool for the pair can take this intent")
+	}
+	builder.usePool(best.loaded)
+	return nil
+}
+
+// split plans the intent across up to maxPools pools, prints the route and sends it.
+func (f *swapFlow) split(ctx context.Context, builder *TableBuilder, maxPools int) error {
+	route, err := planSplit(ctx, f.client, builder, f.intentLine, maxPools)
+	if err != nil {
+		return fmt.Errorf("splitting the intent failed: %w", err)
+	}
+	fmt.Fprint(f.out, renderSplitRoute(route, builder.symbols()))
+	summary, sig, err := executeSplit(ctx, f.client, f.payer, builder, route)
+	if !sig.IsZero() {
+		fmt.Fprintln(f.out, renderTxSummary(summary))
+		fmt.Fprintln(f.out, explorerTxURL(f.network, sig))
+	}
+	return err
+}
+
+// chunked sends intent in the plan's chunks, each quoted off the pool's reserves just before it goes, and prints what
+// filled.
+func (f *swapFlow) chunked(ctx context.Context, builder *TableBuilder, intent *CPIntent, plan chunkPlan) error {
+	share, err := reserveShare(intent)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(f.out, "%s is %s of the pool's reserve, sending it in %d chunks %s apart\n", intent, pctString(share), plan.chunks, plan.interval)
+	order, err := runChunkedOrder(ctx, plan, intent.Amounts.KnownAmount,
+		func(ctx context.Context, amount *big.Int) (*CPIntent, error) {
+			return quoteChunk(ctx, f.client, builder, intent, amount)
+		},
+		func(ctx context.Context, chunk *CPIntent) (txSummaryData, solana.Signature, error) {
+			return executeIntent(ctx, f.client, f.payer, builder, chunk)
+		},
+	)
+	fmt.Fprint(f.out, renderChunkedOrder(order, builder.symbols(), f.network))
+	return err
+}
+
+// twap sends intent in slices spread over the plan's window, each quoted off the pool's reserves just before it goes,
+// and prints what filled against the arrival price.
+func (f *swapFlow) twap(ctx context.Context, builder *TableBuilder, intent *CPIntent, plan twapPlan) error {
+	slices := plan.schedule(intent.Amounts.KnownAmount, rand.Float64)
+	fmt.Fprintf(f.out, "Sending %s over %s in %d slices about %s apart\n", intent, plan.window, len(slices), plan.every)
+	order, err := runTWAP(ctx, intent, plan, slices,
+		func(ctx context.Context, amount *big.Int) (*CPIntent, error) {
+			return quoteChunk(ctx, f.client, builder, intent, amount)
+		},
+		func(ctx context.Context, slice *CPIntent) (txSummaryData, solana.Signature, error) {
+			return executeIntent(ctx, f.client, f.payer, builder, slice)
+		},
+	)
+	if order != nil {
+		fmt.Fprint(f.out, renderTWAPOrder(order, builder.symbols(), f.network))
+	}
+	return err
+}
+
+// quote builds the intent's report, asking askMapping about every symbol the pool's tokens don't resolve to.
+func (f *swapFlow) quote(builder *TableBuilder, askMapping func(symbol, mint string) (bool, error)) (string, *CPIntent, error) {
+	for {
+		report, intent, err := builder.Build(f.intentLine)
+		var unquotable *unquotableError
+		if err == nil || errors.As(err, &unquotable) {
+			return report, intent, err
+		}
+		var mapErr *MissingSymbolMappingError
+		if !errors.As(err, &mapErr) {
+			return "", nil, fmt.Errorf("building intent report failed: %w", err)
+		}
+		mapped, err := askMapping(mapErr.Symbol, mapErr.Mint)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to prompt for symbol mapping: %w", err)
+		}
+		if !mapped {
+			return "", nil, fmt.Errorf("symbol %s remains unmapped; aborting", mapErr.Symbol)
+		}
+		builder.mapSymbol(mapErr.Symbol, mapErr.Mint)
+	}
+}
+
+// jupiterOnly routes the intent through Jupiter for a pair with no CP-Swap pool to compare against.
+func (f *swapFlow) jupiterOnly(ctx context.Context, target string) error {
+	rc, symm, err := jupiterOnlyRoute(ctx, f.client, target, f.intentLine, f.slippagePct)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(f.out, renderRouteComparison(rc, symm))
+	return runJupiterRoute(ctx, f.client, f.payer, rc, symm, f.network)
+}
+
+// preferJupiter compares intent with Jupiter's quote and sends through Jupiter when it pays better, true when it did.
+func (f *swapFlow) preferJupiter(ctx context.Context, builder *TableBuilder, intent *CPIntent) (bool, error) {
+	rc := compareWithJupiter(ctx, intent, f.slippagePct)
+	fmt.Fprint(f.out, renderRouteComparison(rc, builder.symbols()))
+	if !rc.useJupiter {
+		return false, nil
+	}
+	return true, runJupiterRoute(ctx, f.client, f.payer, rc, builder.symbols(), f.network)
+}
+
+// exportBundle writes the swap for intent to a bundle at path for review, an approval request when it's over
+// -approval-above. Nothing is sent.
+func (f *swapFlow) exportBundle(ctx context.Context, builder *TableBuilder, intent *CPIntent, path string) error {
+	bundle, err := f.planBundle(ctx, builder, intent)
+	if err != nil {
+		return err
+	}
+	return exportTxBundle(ctx, path, bundle, f.payer, f.out)
+}
+
+// requestApproval writes the swap for intent to an approval request at path, signed by the hot wallet, nothing is
+// sent. An empty path names the request after its hash.
+func (f *swapFlow) requestApproval(ctx context.Context, builder *TableBuilder, intent *CPIntent, path, why string) error {
+	bundle, err := f.planBundle(ctx, builder, intent)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		file, err := newTxBundleFile(bundle)
+		if err != nil {
+			return err
+		}
+		path = fmt.Sprintf("approval-%s.json", file.SHA256[:12])
+	}
+	hash, err := writeApprovalRequest(path, bundle, f.payer)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(f.out, "%s, so it wasn't sent.\nApproval request written to %s\nSHA-256: %s\n", why, path, hash)
+	fmt.Fprintf(f.out, "A second keyholder approves it with `approve %s -keypair <their keypair>`, then execute it with -execute-bundle %s -bundle-hash %s\n", path, path, hash)
+	return nil
+}
+
+// proposeSquads opens a proposal on the -squads-vault multisig for the vault to make the swap for intent, nothing is
+// sent from the hot wallet but the proposal.
+func (f *swapFlow) proposeSquads(ctx context.Context, intent *CPIntent, sf *squadsFlags) error {
+	vault, err := sf.vault()
+	if err != nil {
+		return err
+	}
+	proposed, err := proposeToSquads(ctx, f.client, f.payer, sf.multisig, uint8(*sf.vaultIndex), intent)
+	if err != nil {
+		if !proposed.sig.IsZero() {
+			fmt.Fprintln(f.out, explorerTxURL(f.network, proposed.sig))
+		}
+		return err
+	}
+	fmt.Fprintf(f.out, "Proposed to multisig %s as transaction #%d, the swap is made from vault %s once %d members approve it and it's executed.\n", sf.multisig, proposed.index, vault, proposed.threshold)
+	fmt.Fprintf(f.out, "Proposal: %s\nVault transaction: %s\n%s\n", proposed.proposal, proposed.transaction, explorerTxURL(f.network, proposed.sig))
+	return nil
+}
+
+// planBundle plans the swap for intent as a single entry bundle.
+func (f *swapFlow) planBundle(ctx context.Context, builder *TableBuilder, intent *CPIntent) (txBundle, error) {
+	plan, err := planSwap(ctx, f.client, f.payer.PublicKey(), intent)
+	if err != nil {
+		return txBundle{}, err
+	}
+	entry, err := newTxBundleEntry(plan, builder.symbols())
+	if err != nil {
+		return txBundle{}, fmt.Errorf("preparing bundle failed: %w", err)
+	}
+	return txBundle{
+		Version:   txBundleVersion,
+		CreatedAt: time.Now().UTC(),
+		Network:   f.network,
+		ProgramID: raydium_cp_swap.ProgramID.String(),
+		Payer:     f.payer.PublicKey().String(),
+		Entries:   []txBundleEntry{entry},
+	}, nil
+}
+
+// confirmPrice re-quotes the intent right before it's sent and refuses to go on when the price has moved further from
+// expected, what -confirm-price was given, than the slippage allows. The fresh quote is the one to send.
+func (f *swapFlow) confirmPrice(builder *TableBuilder, expected *big.Rat) (*CPIntent, error) {
+	_, intent, err := builder.Build(f.intentLine)
+	if err != nil {
+		return nil, fmt.Errorf("re-quoting for -confirm-price failed: %w", err)
+	}
+	price := targetPrice(intent)
+	if price == nil {
+		return nil, errors.New("the quote has no price to hold -confirm-price against")
+	}
+	if drift := priceDrift(price, expected); drift > f.slippagePct {
+		counter := intent.CounterLeg()
+		target := intent.TokenIn
+		if intent.SwapKind == SwapKindBaseOutput {
+			target = intent.TokenOut
+		}
+		symm := builder.symbols()
+		decimals := int(counter.Decimals)
+		return nil, classify(ErrSlippageExceeded, fmt.Errorf("%s is quoted at %s %s, %.2f%% off the expected %s, more than the %.2f%% slippage, nothing was sent",
+			symm.SymFrom(target.Mint), price.FloatString(decimals), symm.SymFrom(counter.Mint), drift, expected.FloatString(decimals), f.slippagePct))
+	}
+	return intent, nil
+}
+
+// priceDrift is how far price is from expected, in percent of expected.
+func priceDrift(price, expected *big.Rat) float64 {
+	drift := new(big.Rat).Sub(price, expected)
+	drift.Abs(drift).Quo(drift, expected).Mul(drift, big.NewRat(100, 1))
+	pct, _ := drift.Float64()
+	return pct
+}
+
+// swap sends intent. With fallback, a swap the pool failed is offered to the next best pool for the pair, confirm
+// deciding whether to take it, until one lands or there's no pool left.
+func (f *swapFlow) swap(ctx context.Context, builder *TableBuilder, intent *CPIntent, fallback bool, confirm func(question string) (bool, error)) error {
+	current, _ := builder.currentPool()
+	tried := map[solana.PublicKey]bool{current: true}
+	for {
+		summary, sig, err := executeIntent(ctx, f.client, f.payer, builder, intent)
+		if err == nil {
+			fmt.Fprintln(f.out, renderTxSummary(summary))
+			fmt.Fprintln(f.out, explorerTxURL(f.network, sig))
+			if summary.Status == "pending" && ctx.Err() != nil {
+				// NOTE(@hadydotai): Interrupted between sending and seeing it land. The transaction is out of our hands,
+				// the signature above is all there is to follow it up with, so don't let it scroll by as a success.
+				log.Printf("interrupted before %s confirmed, it can still land until its blockhash expires, check it with `why %s`", Addr(sig.String()), sig)
+				return ctx.Err()
+			}
+			return nil
+		}
+		if !sig.IsZero() {
+			fmt.Fprintln(f.out, renderTxSummary(summary))
+		}
+		current, _ := builder.currentPool()
+		pf := asPoolFailure(current, err)
+		if pf == nil || !fallback {
+			return err
+		}
+		log.Printf("swap failed, %s\n", pf)
+		candidates, err := alternatePools(ctx, f.client, builder, intent.String(), tried)
+		if err != nil {
+			return fmt.Errorf("looking for an alternate pool failed: %w", err)
+		}
+		if len(candidates) == 0 {
+			return errors.New("no other pool for the pair can take this swap, giving up")
+		}
+		next := candidates[0]
+		fmt.Fprintln(f.out, next.report)
+		retry, err := confirm(fmt.Sprintf("Retry on pool %s with the quote above?", Addr(next.loaded.address.String())))
+		if err != nil || !retry {
+			return errRetryDeclined
+		}
+		builder.usePool(next.loaded)
+		intent = next.intent
+		tried[next.loaded.address] = true
+	}
+}
+
+// dryRun simulates the swap for intent an instruction at a time and prints the ledger of what each one moves, nothing
+// is sent.
+func (f *swapFlow) dryRun(ctx context.Context, builder *TableBuilder, intent *CPIntent) error {
+	plan, err := planSwap(ctx, f.client, f.payer.PublicKey(), intent)
+	if err != nil {
+		return err
+	}
+	ledger, err := simulateLedger(ctx, f.client, plan, builder.symbols())
+	if ledger != nil {
+		fmt.Fprint(f.out, renderLedger(ledger))
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(f.out, "Dry run, nothing was sent.")
+	return nil
+}
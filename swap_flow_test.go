@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	bin "github.com/gagliardetto/binary"
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+// anchorAccount is v as its program stores it, discriminator first.
+func anchorAccount(t *testing.T, discriminator [8]byte, v interface {
+	MarshalWithEncoder(*bin.Encoder) error
+}) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	enc := bin.NewBorshEncoder(buf)
+	if err := enc.WriteBytes(discriminator[:], false); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.MarshalWithEncoder(enc); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// snapshotChain is a node holding snapshotPool: the pool, its AmmConfig, both mints and the vault balances.
+func snapshotChain(t *testing.T, served *atomic.Int64) (string, solana.PublicKey) {
+	t.Helper()
+	pool, addr, balances := snapshotPool()
+	srv := chainServer(t, map[solana.PublicKey]chainAccount{
+		addr:            {owner: raydium_cp_swap.ProgramID, data: anchorAccount(t, raydium_cp_swap.Account_PoolState, *pool)},
+		pool.AmmConfig:  {owner: raydium_cp_swap.ProgramID, data: anchorAccount(t, raydium_cp_swap.Account_AmmConfig, raydium_cp_swap.AmmConfig{TradeFeeRate: 2500})},
+		pool.Token0Mint: {owner: solana.TokenProgramID, data: mintData(10_000_000_000_000, 9, nil, nil)},
+		pool.Token1Mint: {owner: solana.TokenProgramID, data: mintData(80_000_000_000_000, 6, nil, nil)},
+	}, map[solana.PublicKey]*PoolBalance{pool.Token0Vault: balances[0], pool.Token1Vault: balances[1]}, served)
+	return srv.URL, addr
+}
+
+func TestSwapFlowQuoteReplayed(t *testing.T) {
+	var served atomic.Int64
+	endpoint, addr := snapshotChain(t, &served)
+	path := filepath.Join(t.TempDir(), "quote.json")
+	quote := func(client *rpc.Client) (string, *CPIntent) {
+		t.Helper()
+		flow := &swapFlow{client: client, network: "devnet", intentLine: "sell 1 SOL", slippagePct: 0.5, out: &bytes.Buffer{}}
+		builder, current, err := flow.openPool(context.Background(), addr.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !current.Equals(addr) {
+			t.Fatalf("opened %s, want %s", current, addr)
+		}
+		report, intent, err := flow.quote(builder, func(symbol, mint string) (bool, error) {
+			t.Fatalf("asked to map %s, the token list knows both tokens", symbol)
+			return false, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return report, intent
+	}
+
+	recordedReport, recorded := quote(rpc.NewWithCustomRPCClient(newRecordingTransport(jsonrpc.NewClient(endpoint), path)))
+	fixtures, err := loadRPCFixtures(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := served.Load()
+	replayedReport, replayed := quote(rpc.NewWithCustomRPCClient(newReplayTransport(fixtures)))
+	if served.Load() != before {
+		t.Error("replaying went to the node")
+	}
+	if replayed.Amounts.QuoteAmount.Cmp(recorded.Amounts.QuoteAmount) != 0 || replayedReport != recordedReport {
+		t.Errorf("replayed quote %s, recorded %s", replayed.Amounts.QuoteAmount, recorded.Amounts.QuoteAmount)
+	}
+
+	// A pool that wasn't recorded can't be loaded off the fixtures.
+	flow := &swapFlow{client: rpc.NewWithCustomRPCClient(newReplayTransport(fixtures)), intentLine: "sell 1 SOL", slippagePct: 0.5}
+	if _, _, err := flow.openPool(context.Background(), snapshotKey(73).String()); err == nil || !strings.Contains(err.Error(), "no rpc fixture") {
+		t.Errorf("unrecorded pool: %v", err)
+	}
+}
+
+func TestSwapFlowConfirmPrice(t *testing.T) {
+	endpoint, addr := snapshotChain(t, &atomic.Int64{})
+	flow := &swapFlow{client: rpc.New(endpoint), network: "devnet", intentLine: "sell 1 SOL", slippagePct: 0.5, out: &bytes.Buffer{}}
+	builder, _, err := flow.openPool(t.Context(), addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, quoted, err := builder.Build(flow.intentLine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	price := targetPrice(quoted)
+
+	// Within the slippage either way, the fresh quote comes back to be sent.
+	for _, expected := range []*big.Rat{price, new(big.Rat).Mul(price, big.NewRat(1004, 1000)), new(big.Rat).Mul(price, big.NewRat(996, 1000))} {
+		intent, err := flow.confirmPrice(builder, expected)
+		if err != nil {
+			t.Fatalf("expected %s: %v", expected.FloatString(6), err)
+		}
+		if intent.Amounts.QuoteAmount.Cmp(quoted.Amounts.QuoteAmount) != 0 {
+			t.Errorf("re-quoted %s, want %s", intent.Amounts.QuoteAmount, quoted.Amounts.QuoteAmount)
+		}
+	}
+	// Past it, nothing is sent.
+	_, err = flow.confirmPrice(builder, new(big.Rat).Mul(price, big.NewRat(101, 100)))
+	if err == nil || !strings.Contains(err.Error(), "nothing was sent") || !strings.Contains(err.Error(), "SOL is quoted at") {
+		t.Errorf("1%% off the price: %v", err)
+	}
+
+	if drift := priceDrift(big.NewRat(99, 1), big.NewRat(100, 1)); drift != 1 {
+		t.Errorf("drift of 99 from 100 is %v%%, want 1%%", drift)
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// legProgram is the token program a leg's mint belongs to, the classic one for a leg put together by hand that doesn't
+// say.
+func legProgram(leg SwapLeg) solana.PublicKey {
+	if leg.Program.IsZero() {
+		return solana.TokenProgramID
+	}
+	return leg.Program
+}
+
+// poolTokenProgram is the token program of mint, one of pool's tokens.
+func poolTokenProgram(pool *raydium_cp_swap.PoolState, mint solana.PublicKey) solana.PublicKey {
+	if mint.Equals(pool.Token1Mint) {
+		return legProgram(SwapLeg{Program: pool.Token1Program})
+	}
+	return legProgram(SwapLeg{Program: pool.Token0Program})
+}
+
+// makeATAIfMissing is owner's associated account for mint under program, with the instruction creating it when it
+// doesn't exist yet. The program is part of the derivation, a Token-2022 mint's account isn't the classic one.
+func makeATAIfMissing(ctx context.Context, c *rpc.Client, payer, owner, mint, program solana.PublicKey) (solana.PublicKey, []solana.Instruction, error) {
+	ata, err := associatedTokenAddress(owner, mint, program)
+	if err != nil {
+		return solana.PublicKey{}, nil, err
+	}
+	_, err = c.GetAccountInfoWithOpts(ctx, ata, &rpc.GetAccountInfoOpts{
+		Commitment: rpc.CommitmentProcessed,
+	})
+	switch {
+	case err == nil:
+		// account exists, nothing to do
+		return ata, nil, nil
+	case !isAccountMissingErr(err):
+		return solana.PublicKey{}, nil, err
+	}
+	return ata, []solana.Instruction{createATAInstruction(payer, ata, owner, mint, program)}, nil
+}
+
+func swapAuthority() (solana.PublicKey, error) {
+	auth, _, err := solana.FindProgramAddress(
+		[][]byte{[]byte("vault_and_lp_mint_auth_seed")}, // https://github.com/raydium-io/raydium-cp-swap/blob/master/programs/cp-swap/src/lib.rs#L43
+		raydium_cp_swap.ProgramID,
+	)
+	if err != nil {
+		return solana.PublicKey{}, fmt.Errorf("rpc call findProgramAddress failed: %w", err)
+	}
+	return auth, nil
+}
+
+// swapPlan is the complete, ordered instruction set for a single swap. It carries no blockhash and no signatures,
+// so it can be shown to someone, stored, and turned into a transaction later.
+type swapPlan struct {
+	intent       *CPIntent // the first leg, the only one unless the swap was split across pools
+	legs         []*CPIntent
+	payer        solana.PublicKey
+	inputATA     solana.PublicKey
+	outputATA    solana.PublicKey
+	instructions []solana.Instruction
+}
+
+// planSwap works out every instruction needed to execute the intent for the payer: compute budget, ATA creation,
+// SOL wrapping, the swap itself, and closing a temporary wSOL account.
+func planSwap(ctx context.Context, client *rpc.Client, payerPub solana.PublicKey, intentMeta *CPIntent) (*swapPlan, error) {
+	if intentMeta == nil {
+		return nil, errors.New("intent resolution failed, no transaction to build")
+	}
+	return planRoute(ctx, client, payerPub, []*CPIntent{intentMeta})
+}
+
+// planRoute is planSwap for one or more legs trading the same pair, each on its own pool. The accounts are set up
+// once for all of them and every leg's swap instruction goes in the same transaction, so they land or fail together.
+func planRoute(ctx context.Context, client *rpc.Client, payerPub solana.PublicKey, legs []*CPIntent) (*swapPlan, error) {
+	if len(legs) == 0 || legs[0] == nil {
+		return nil, errors.New("intent resolution failed, no transaction to build")
+	}
+	intentMeta := legs[0]
+	requiredInput := new(big.Int)
+	for _, leg := range legs {
+		if leg == nil {
+			return nil, errors.New("a leg of the route has no intent")
+		}
+		if !leg.TokenIn.Mint.Equals(intentMeta.TokenIn.Mint) || !leg.TokenOut.Mint.Equals(intentMeta.TokenOut.Mint) {
+			return nil, errors.New("every leg of a route must trade the same pair in the same direction")
+		}
+		required := leg.RequiredInputAmount()
+		if required == nil {
+			return nil, errors.New("required input amount missing for swap")
+		}
+		requiredInput.Add(requiredInput, required)
+	}
+	wsol, err := newWSOLManager(client, payerPub)
+	if err != nil {
+		return nil, err
+	}
+	var inATA, outATA solana.PublicKey
+	var inIxs, outIxs []solana.Instruction
+	if isNativeSOL(intentMeta.TokenIn.Mint) {
+		inATA, inIxs, err = wsol.prepareInput(ctx, requiredInput)
+	} else {
+		inATA, inIxs, err = makeATAIfMissing(ctx, client, payerPub, payerPub, intentMeta.TokenIn.Mint, legProgram(intentMeta.TokenIn))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("attempts to get/make ATA for input token failed: %w", err)
+	}
+	if isNativeSOL(intentMeta.TokenOut.Mint) {
+		outATA, outIxs, err = wsol.prepareOutput(ctx)
+	} else {
+		outATA, outIxs, err = makeATAIfMissing(ctx, client, payerPub, payerPub, intentMeta.TokenOut.Mint, legProgram(intentMeta.TokenOut))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("attempts to get/make ATA for output token failed: %w", err)
+	}
+
+	var swapIxs []solana.Instruction
+	for _, leg := range legs {
+		ixs, err := venueOf(leg).BuildInstructions(leg, payerPub, inATA, outATA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build swap instruction: %w", err)
+		}
+		// A Token-2022 mint with a transfer hook needs the hook's accounts on the end of the swap, see transfer_hook.go.
+		hookAccounts, err := transferHookAccounts(ctx, client, leg, payerPub, inATA, outATA)
+		if err != nil {
+			return nil, fmt.Errorf("resolving transfer hook accounts failed: %w", err)
+		}
+		if len(hookAccounts) > 0 {
+			last := len(ixs) - 1
+			if ixs[last], err = withRemainingAccounts(ixs[last], hookAccounts); err != nil {
+				return nil, fmt.Errorf("failed to build swap instruction: %w", err)
+			}
+		}
+		swapIxs = append(swapIxs, ixs...)
+	}
+
+	// Closing a wSOL account is only ever the WSOLManager's call, and only after the swap, see wsol.go.
+	closeIxs, err := wsol.closeInstructions()
+	if err != nil {
+		return nil, err
+	}
+	var ixs []solana.Instruction
+	// The unit limit and priority fee, see compute_budget.go. An intent quoted with a budget of its own (a batch order's)
+	// pays that one.
+	budget := computeBudget.resolved
+	if intentMeta.ComputeBudget != nil {
+		budget = *intentMeta.ComputeBudget
+	}
+	ixs = append(ixs, budget.instructions()...)
+	ixs = append(ixs, inIxs...)
+	ixs = append(ixs, outIxs...)
+	ixs = append(ixs, swapIxs...)
+	ixs = append(ixs, closeIxs...)
+	return &swapPlan{
+		intent:       intentMeta,
+		legs:         legs,
+		payer:        payerPub,
+		inputATA:     inATA,
+		outputATA:    outATA,
+		instructions: ixs,
+	}, nil
+}
+
+// unsignedTransaction attaches a fresh blockhash to the instructions, leaving the signing to whoever holds the key. See
+// blockhash.go.
+func unsignedTransaction(ctx context.Context, client *rpc.Client, payerPub solana.PublicKey, ixs []solana.Instruction) (*solana.Transaction, error) {
+	recent, err := blockhashes.latest(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := solana.NewTransaction(
+		ixs,
+		recent,
+		solana.TransactionPayer(payerPub),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("building transaction failed: %w", err)
+	}
+	return tx, nil
+}
+
+// signTransaction attaches a fresh blockhash to the instructions and signs them with the payer.
+func signTransaction(ctx context.Context, client *rpc.Client, payer solana.PrivateKey, ixs []solana.Instruction) (*solana.Transaction, error) {
+	payerPub := payer.PublicKey()
+	tx, err := unsignedTransaction(ctx, client, payerPub, ixs)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
+		if key.Equals(payerPub) {
+			return &payer
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("signing transaction failed: %w", err)
+	}
+	return tx, nil
+}
+
+func sendTransaction(ctx context.Context, client *rpc.Client, tx *solana.Transaction) (solana.Signature, error) {
+	sig, err := client.SendTransaction(ctx, tx)
+	if err != nil {
+		if failure, ok := decodeSendFailure(err); ok {
+			return solana.Signature{}, fmt.Errorf("sending transaction failed, simulating it hit %s: %w", failure, err)
+		}
+		return solana.Signature{}, fmt.Errorf("sending transaction failed: %w", err)
+	}
+	return sig, nil
+}
+
+// signAndSend is signTransaction followed by sendTransaction, for callers that don't care about the steps in between.
+func signAndSend(ctx context.Context, client *rpc.Client, payer solana.PrivateKey, ixs []solana.Instruction) (solana.Signature, error) {
+	tx, err := signTransaction(ctx, client, payer, ixs)
+	if err != nil {
+		return solana.Signature{}, err
+	}
+	return sendTransaction(ctx, client, tx)
+}
+
+// awaitSwapSummary waits for the transaction to land and works out what was actually paid and received from the
+// swap's event, or by diffing the pool vault balances touched by the transaction when there isn't one. A summary is
+// always returned, the error only reports that waiting for confirmation failed for reasons other than running out of
+// time, it's a warning, not a failure.
+func awaitSwapSummary(ctx context.Context, client *rpc.Client, sig solana.Signature, tokenIn, tokenOut SwapLeg, inSymbol, outSymbol string) (txSummaryData, error) {
+	return awaitRouteSummary(ctx, client, sig, []SwapLeg{tokenIn}, []SwapLeg{tokenOut}, inSymbol, outSymbol)
+}
+
+// awaitRouteSummary is awaitSwapSummary for a swap split across pools, what was paid and received is summed over
+// every leg. When cp-swap's events name every leg's swap they're what counts, otherwise the vaults are diffed.
+func awaitRouteSummary(ctx context.Context, client *rpc.Client, sig solana.Signature, legsIn, legsOut []SwapLeg, inSymbol, outSymbol string) (txSummaryData, error) {
+	status, txResult, waitErr := waitForTransactionResult(ctx, client, sig)
+	if waitErr != nil && (errors.Is(waitErr, context.DeadlineExceeded) || errors.Is(waitErr, context.Canceled)) {
+		waitErr = nil
+	}
+	return summarizeRoute(sig, status, txResult, legsIn, legsOut, inSymbol, outSymbol), waitErr
+}
+
+// summarizeRoute is what awaitRouteSummary makes of the transaction once it has it, txResult is nil when it never
+// showed up.
+func summarizeRoute(sig solana.Signature, status string, txResult *rpc.GetTransactionResult, legsIn, legsOut []SwapLeg, inSymbol, outSymbol string) txSummaryData {
+	var txMeta *rpc.TransactionMeta
+	if txResult != nil {
+		txMeta = txResult.Meta
+	}
+	feeLamports := uint64(0)
+	var txErr any
+	var logs []string
+	if txMeta != nil {
+		feeLamports = txMeta.Fee
+		txErr = txMeta.Err
+		logs = txMeta.LogMessages
+	}
+	var paidDelta, receivedDelta, tradeFee *big.Int
+	if fills, ok := routeFills(txResult, legsIn, legsOut); ok {
+		// What cp-swap says it swapped, see swap_events.go.
+		paidDelta, receivedDelta = new(big.Int), new(big.Int)
+		for _, f := range fills {
+			paidDelta.Add(paidDelta, f.paid())
+			receivedDelta.Add(receivedDelta, f.received())
+			if f.hasTradeFee {
+				if tradeFee == nil {
+					tradeFee = new(big.Int)
+				}
+				tradeFee.Add(tradeFee, new(big.Int).SetUint64(f.event.TradeFee))
+			}
+		}
+	} else {
+		paidDelta, receivedDelta = vaultDeltas(txResult, legsIn), vaultDeltas(txResult, legsOut)
+	}
+	return txSummaryData{
+		Signature:        sig,
+		Status:           status,
+		FeeLamports:      feeLamports,
+		PaidAmount:       paidDelta,
+		PaidDecimals:     legsIn[0].Decimals,
+		PaidSymbol:       inSymbol,
+		ReceivedAmount:   receivedDelta,
+		ReceivedDecimals: legsOut[0].Decimals,
+		ReceivedSymbol:   outSymbol,
+		TradeFee:         tradeFee,
+		TxErr:            txErr,
+		Logs:             logs,
+	}
+}
+
+// vaultDeltas sums how much the legs' vaults moved, nil when none of their balances are in the transaction.
+func vaultDeltas(txResult *rpc.GetTransactionResult, legs []SwapLeg) *big.Int {
+	var total *big.Int
+	for _, leg := range legs {
+		if delta, ok := tokenDeltaFromResult(txResult, leg.Vault, leg.Mint); ok {
+			if total == nil {
+				total = new(big.Int)
+			}
+			total.Add(total, delta.Abs(delta))
+		}
+	}
+	return total
+}
+
+// txFailedError is a transaction that landed but was rejected by the program.
+type txFailedError struct {
+	sig   solana.Signature
+	txErr any
+	logs  []string
+}
+
+func (e *txFailedError) Error() string {
+	if failure, ok := decodeProgramFailure(e.txErr, e.logs); ok {
+		return fmt.Sprintf("transaction %s failed on chain: %s", e.sig, failure)
+	}
+	return fmt.Sprintf("transaction %s failed on chain: %v", e.sig, e.txErr)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/base64"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+func programDataLine(t *testing.T, ev raydium_cp_swap.SwapEvent) string {
+	t.Helper()
+	body, err := ev.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := append(raydium_cp_swap.Event_SwapEvent[:], body...)
+	return "Program data: " + base64.StdEncoding.EncodeToString(data)
+}
+
+func TestSwapEventsFromLogs(t *testing.T) {
+	program := solana.NewWallet().PublicKey()
+	other := solana.NewWallet().PublicKey()
+	ev := raydium_cp_swap.SwapEvent{InputVaultBefore: 1000, OutputVaultBefore: 2000, InputAmount: 100, OutputAmount: 181, BaseInput: true, TradeFee: 1}
+	logs := []string{
+		"Program ComputeBudget111111111111111111111111111111 invoke [1]",
+		"Program ComputeBudget111111111111111111111111111111 success",
+		"Program " + other.String() + " invoke [1]",
+		// Same bytes, wrong program: not ours.
+		programDataLine(t, ev),
+		"Program " + program.String() + " invoke [2]",
+		"Program log: Instruction: SwapBaseInput",
+		"Program TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA invoke [3]",
+		"Program TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA success",
+		programDataLine(t, ev),
+		"Program " + program.String() + " consumed 40000 of 200000 compute units",
+		"Program " + program.String() + " success",
+		"Program " + other.String() + " success",
+	}
+	events := swapEventsFromLogs(logs, program)
+	if len(events) != 1 || events[0].OutputAmount != 181 {
+		t.Fatalf("expected exactly the event logged by the program, got %+v", events)
+	}
+}
+
+func TestVectorFromEvent(t *testing.T) {
+	ev := &raydium_cp_swap.SwapEvent{InputVaultBefore: 1000, OutputVaultBefore: 2000, InputAmount: 100, OutputAmount: 180, BaseInput: true, TradeFee: 1}
+	v, err := vectorFromEvent(solana.Signature{}, 1, ev, 3000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.check(); err != nil {
+		t.Fatalf("vector should replay: %v", err)
+	}
+	v.AmountOut++
+	if v.check() == nil {
+		t.Fatalf("expected an off by one output to be caught")
+	}
+	if _, err := vectorFromEvent(solana.Signature{}, 1, ev, 25000); err == nil {
+		t.Fatalf("expected a trade fee that doesn't fit the rate to be rejected")
+	}
+	ev.CreatorFee = 1
+	if _, err := vectorFromEvent(solana.Signature{}, 1, ev, 3000); err == nil {
+		t.Fatalf("expected creator fee swaps to be left out")
+	}
+}
+
+func TestRenderVectorsFile(t *testing.T) {
+	src, err := renderVectorsFile("testdata/sigs.txt", []swapVector{
+		{Signature: "sig", Slot: 7, Pool: "pool", BaseInput: true, InputReserve: 1000, OutputReserve: 2000, TradeFeeRate: 3000, AmountIn: 100, AmountOut: 181},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "vectors_test.go", src, 0); err != nil {
+		t.Fatalf("generated file doesn't parse: %v\n%s", err, src)
+	}
+	if !strings.Contains(string(src), "AmountOut: 181}") {
+		t.Fatalf("vector missing from output:\n%s", src)
+	}
+}
+
+func TestReadSignatures(t *testing.T) {
+	sig := solana.Signature{1, 2, 3}
+	path := filepath.Join(t.TempDir(), "sigs.txt")
+	content := "# swaps worth keeping\n\n" + sig.String() + "  # base input\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sigs, err := readSignatures(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sigs) != 1 || sigs[0] != sig {
+		t.Fatalf("got %v", sigs)
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+/*
+NOTE(@hadydotai): Symbol aliases.
+
+Mapping a symbol to a mint when asked only lasts the session, the next run asks again. The aliases file remembers
+them, a JSON object of symbol to mint, by default in the user's config directory (-aliases to use another). It's read
+once at startup and applied to every pool loaded after, on top of the on-chain metadata: an alias for one of a
+pool's mints maps the symbol to it and is what the mint is shown as.
+
+`alias add/remove/list` edit it from the command line, and in the TUI, `a` instead of `y` on the "map it?" prompt
+maps the symbol and saves it. The file is rewritten whole through a temp file and a rename, like the DCA state.
+*/
+
+type symbolAliases struct {
+	mu       sync.RWMutex
+	path     string
+	bySymbol map[string]string // symbol -> base58 mint
+}
+
+// userAliases are the aliases loaded at startup, empty until then.
+var userAliases = &symbolAliases{bySymbol: map[string]string{}}
+
+func defaultAliasesPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "aliases.json"
+	}
+	return filepath.Join(dir, "raydium-client", "aliases.json")
+}
+
+func addAliasesFlag(fs *flag.FlagSet) *string {
+	return fs.String("aliases", defaultAliasesPath(), "File of symbol to mint aliases applied to every pool, see `alias`")
+}
+
+// loadSymbolAliases reads the aliases at path, a missing file is no aliases.
+func loadSymbolAliases(path string) (*symbolAliases, error) {
+	a := &symbolAliases{path: path, bySymbol: map[string]string{}}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading aliases %s: %w", path, err)
+	}
+	if err := json.Unmarshal(raw, &a.bySymbol); err != nil {
+		return nil, fmt.Errorf("aliases %s are corrupt: %w", path, err)
+	}
+	return a, nil
+}
+
+// useAliasesFile loads the aliases every pool loaded from now on gets, a file that can't be read is warned about and
+// otherwise ignored, it's a convenience and shouldn't stop a swap.
+func useAliasesFile(path string) {
+	a, err := loadSymbolAliases(path)
+	if err != nil {
+		log.Printf("warning: %v", err)
+		a = &symbolAliases{path: path, bySymbol: map[string]string{}}
+	}
+	userAliases = a
+}
+
+// add aliases sym to mint, replacing whatever sym was before, and saves the file.
+func (a *symbolAliases) add(sym, mint string) (string, error) {
+	sym = normalizeSymbol(sym)
+	if sym == "" || isIntentKeyword(sym) {
+		return "", fmt.Errorf("%q can't be used as a symbol", sym)
+	}
+	if _, err := solana.PublicKeyFromBase58(mint); err != nil {
+		return "", fmt.Errorf("mint %q isn't a base58 address: %w", mint, err)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	prev, had := a.bySymbol[sym]
+	a.bySymbol[sym] = mint
+	if err := a.save(); err != nil {
+		if had {
+			a.bySymbol[sym] = prev
+		} else {
+			delete(a.bySymbol, sym)
+		}
+		return "", err
+	}
+	return sym, nil
+}
+
+// remove drops the alias for sym and saves the file, false when there wasn't one.
+func (a *symbolAliases) remove(sym string) (bool, error) {
+	sym = normalizeSymbol(sym)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	mint, ok := a.bySymbol[sym]
+	if !ok {
+		return false, nil
+	}
+	delete(a.bySymbol, sym)
+	if err := a.save(); err != nil {
+		a.bySymbol[sym] = mint
+		return false, err
+	}
+	return true, nil
+}
+
+// symbols are the aliased symbols, sorted.
+func (a *symbolAliases) symbols() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	syms := make([]string, 0, len(a.bySymbol))
+	for sym := range a.bySymbol {
+		syms = append(syms, sym)
+	}
+	sort.Strings(syms)
+	return syms
+}
+
+func (a *symbolAliases) mint(sym string) string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.bySymbol[sym]
+}
+
+// apply maps every alias for one of mints into symm, in symbol order so a mint with two aliases shows the same one
+// every time.
+func (a *symbolAliases) apply(symm SymbolMapping, mints ...solana.PublicKey) {
+	for _, sym := range a.symbols() {
+		mint := a.mint(sym)
+		for _, m := range mints {
+			if m.String() == mint {
+				symm.mapSymToMint(sym, mint, symbolSourceAlias)
+			}
+		}
+	}
+}
+
+// save writes the file, the caller holds the lock.
+func (a *symbolAliases) save() error {
+	raw, err := json.MarshalIndent(a.bySymbol, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(a.path), 0o755); err != nil {
+		return fmt.Errorf("writing aliases: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(a.path), filepath.Base(a.path)+".*")
+	if err != nil {
+		return fmt.Errorf("writing aliases: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(raw, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing aliases: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing aliases: %w", err)
+	}
+	return os.Rename(tmp.Name(), a.path)
+}
+
+func runAliasCommand(args []string) error {
+	return dispatchSubcommand("alias", map[string]func([]string) error{
+		"add":    runAliasAddCommand,
+		"remove": runAliasRemoveCommand,
+		"list":   runAliasListCommand,
+	}, args)
+}
+
+// parseAliasFlags parses the subcommand's flags and loads the aliases file, wantArgs is how many positional
+// arguments it takes.
+func parseAliasFlags(name, usage string, args []string, wantArgs int) (*symbolAliases, []string, error) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	path := addAliasesFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	ValidateConfigOrExit(fs, []FlagSpec{{Name: "aliases", Value: path, Rules: []FlagRule{NotEmpty()}}})
+	if fs.NArg() != wantArgs {
+		return nil, nil, fmt.Errorf("usage: %s", usage)
+	}
+	a, err := loadSymbolAliases(*path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return a, fs.Args(), nil
+}
+
+func runAliasAddCommand(args []string) error {
+	a, rest, err := parseAliasFlags("alias add", "alias add [-aliases FILE] <symbol> <mint>", args, 2)
+	if err != nil {
+		return err
+	}
+	sym, err := a.add(rest[0], rest[1])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s is now %s (%s)\n", sym, rest[1], a.path)
+	return nil
+}
+
+func runAliasRemoveCommand(args []string) error {
+	a, rest, err := parseAliasFlags("alias remove", "alias remove [-aliases FILE] <symbol>", args, 1)
+	if err != nil {
+		return err
+	}
+	removed, err := a.remove(rest[0])
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return fmt.Errorf("there's no alias %s in %s", normalizeSymbol(rest[0]), a.path)
+	}
+	fmt.Printf("removed %s (%s)\n", normalizeSymbol(rest[0]), a.path)
+	return nil
+}
+
+func runAliasListCommand(args []string) error {
+	a, _, err := parseAliasFlags("alias list", "alias list [-aliases FILE]", args, 0)
+	if err != nil {
+		return err
+	}
+	syms := a.symbols()
+	if len(syms) == 0 {
+		fmt.Printf("no aliases in %s\n", a.path)
+		return nil
+	}
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.SetTitle(a.path)
+	t.AppendHeader(table.Row{"Symbol", "Mint"})
+	for _, sym := range syms {
+		t.AppendRow(table.Row{sym, a.mint(sym)})
+	}
+	t.Render()
+	return nil
+}
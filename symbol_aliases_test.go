@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+func TestSymbolAliasesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "aliases.json")
+	a, err := loadSymbolAliases(path)
+	if err != nil || len(a.symbols()) != 0 {
+		t.Fatalf("a missing file should be no aliases, got %v, %v", a.symbols(), err)
+	}
+	bonk := "DezXAZ8z7PnrnRJjz3wXBoRgixCa6xjnB7YaB1pPB263"
+	if sym, err := a.add(" bonk ", bonk); err != nil || sym != "BONK" {
+		t.Fatalf("add: %q, %v", sym, err)
+	}
+	if _, err := a.add("USDC", "not-a-mint"); err == nil {
+		t.Error("want an invalid mint refused")
+	}
+	if _, err := a.add("with", bonk); err == nil {
+		t.Error("want an intent keyword refused as a symbol")
+	}
+
+	again, err := loadSymbolAliases(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := again.mint("BONK"); got != bonk || len(again.symbols()) != 1 {
+		t.Errorf("reloaded aliases %v, BONK is %q", again.symbols(), got)
+	}
+	if removed, err := again.remove("bonk"); err != nil || !removed {
+		t.Errorf("remove: %v, %v", removed, err)
+	}
+	if removed, _ := again.remove("BONK"); removed {
+		t.Error("removing twice should report nothing removed")
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil || strings.TrimSpace(string(raw)) != "{}" {
+		t.Errorf("file after removing everything: %q, %v", raw, err)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadSymbolAliases(path); err == nil || !strings.Contains(err.Error(), "corrupt") {
+		t.Errorf("want a corrupt file reported, got %v", err)
+	}
+}
+
+func TestSymbolAliasesApply(t *testing.T) {
+	pool, _, _ := snapshotPool()
+	symm := SymbolMapping{
+		mintToSymbol: map[string]string{pool.Token0Mint.String(): "SOL", pool.Token1Mint.String(): "EPJF"},
+		symbolToMint: map[string]solana.PublicKey{"SOL": pool.Token0Mint, "EPJF": pool.Token1Mint},
+		unresolved:   map[string]struct{}{pool.Token1Mint.String(): {}},
+	}
+	a := &symbolAliases{bySymbol: map[string]string{
+		"USDC": pool.Token1Mint.String(),
+		"BONK": "DezXAZ8z7PnrnRJjz3wXBoRgixCa6xjnB7YaB1pPB263", // not in the pool, left out
+	}}
+	a.apply(symm, pool.Token0Mint, pool.Token1Mint)
+	if mint, ok := symm.MaybeMintFromSym("USDC"); !ok || !mint.Equals(pool.Token1Mint) || symm.SymFrom(pool.Token1Mint) != "USDC" {
+		t.Errorf("USDC alias wasn't applied: %v", symm.mintToSymbol)
+	}
+	if _, ok := symm.MaybeMintFromSym("BONK"); ok {
+		t.Error("an alias for a mint outside the pool was applied")
+	}
+	if _, ok := symm.UnresolvedCandidate(); ok {
+		t.Error("an aliased mint should no longer be unresolved")
+	}
+}
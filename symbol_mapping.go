@@ -4,7 +4,10 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"maps"
 	"strings"
+	"sync"
+	"time"
 
 	solana "github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/rpc"
@@ -32,16 +35,50 @@ type SymbolMapping struct {
 	// in the symbol, it's still not exactly the correct mapping if we had otherwise managed to fetch something. But we still
 	// need a flag to tell us which of the mappings didn't resolve
 	unresolved map[string]struct{} // mint -> void
+	sources    map[string]string   // mint -> where its symbol came from, one of the symbolSource constants or the -enhanced-api provider
 }
 
+// Where a mint's symbol came from, shown next to it so a symbol that was guessed or typed in reads differently from
+// one the token itself carries.
+const (
+	symbolSourceMetaplex  = "metaplex"
+	symbolSourceToken2022 = "token-2022"
+	symbolSourceTokenList = "tokenlist"
+	symbolSourceAlias     = "alias"
+	symbolSourceUser      = "mapped"
+	symbolSourceMint      = "mint address"
+)
+
 func (symm SymbolMapping) MapSymToMint(sym, mint string) {
+	symm.mapSymToMint(sym, mint, symbolSourceUser)
+}
+
+func (symm SymbolMapping) mapSymToMint(sym, mint, source string) {
 	delete(symm.unresolved, mint)
 	symm.mintToSymbol[mint] = sym
+	if symm.sources != nil {
+		symm.sources[mint] = source
+	}
 
 	mintPubK, _ := solana.PublicKeyFromBase58(mint)
 	symm.symbolToMint[sym] = mintPubK
 }
 
+// clone returns a mapping that can be changed without touching symm, whose maps may be shared with running quotes.
+func (symm SymbolMapping) clone() SymbolMapping {
+	c := SymbolMapping{
+		mintToSymbol: make(map[string]string, len(symm.mintToSymbol)),
+		symbolToMint: make(map[string]solana.PublicKey, len(symm.symbolToMint)),
+		unresolved:   make(map[string]struct{}, len(symm.unresolved)),
+		sources:      make(map[string]string, len(symm.sources)),
+	}
+	maps.Copy(c.sources, symm.sources)
+	maps.Copy(c.mintToSymbol, symm.mintToSymbol)
+	maps.Copy(c.symbolToMint, symm.symbolToMint)
+	maps.Copy(c.unresolved, symm.unresolved)
+	return c
+}
+
 func (symm SymbolMapping) MaybeSymFrom(mint solana.PublicKey) (string, bool) {
 	sym, ok := symm.mintToSymbol[mint.String()]
 	return sym, ok
@@ -52,6 +89,11 @@ func (symm SymbolMapping) SymFrom(mint solana.PublicKey) string {
 	return sym
 }
 
+// SourceOf is where mint's symbol came from, empty when that isn't known.
+func (symm SymbolMapping) SourceOf(mint solana.PublicKey) string {
+	return symm.sources[mint.String()]
+}
+
 func (symm SymbolMapping) MaybeMintFromSym(sym string) (solana.PublicKey, bool) {
 	mint, ok := symm.symbolToMint[sym]
 	return mint, ok
@@ -62,6 +104,46 @@ func (symm SymbolMapping) MintFromSym(sym string) solana.PublicKey {
 	return mint
 }
 
+// mintPrefixMin is how much of a mint address an intent has to give for it to be taken as one, shorter reads as a
+// symbol that happens to start an address.
+const mintPrefixMin = 4
+
+// MaybeMintFromToken is the mint a token in an intent names: a symbol, or failing that one of the mapping's mints by
+// its address, whole or an unambiguous prefix of at least mintPrefixMin characters. Intents upper case their tokens, so
+// a prefix matches regardless of case, a whole address doesn't need to, it's kept as typed.
+func (symm SymbolMapping) MaybeMintFromToken(token string) (solana.PublicKey, bool) {
+	if mint, ok := symm.symbolToMint[token]; ok {
+		return mint, true
+	}
+	if len(token) < mintPrefixMin {
+		return solana.PublicKey{}, false
+	}
+	var found []string
+	for mint := range symm.mintToSymbol {
+		if mint == token {
+			found = []string{mint}
+			break
+		}
+		if len(token) < len(mint) && strings.EqualFold(mint[:len(token)], token) {
+			found = append(found, mint)
+		}
+	}
+	if len(found) != 1 {
+		return solana.PublicKey{}, false
+	}
+	mint, err := solana.PublicKeyFromBase58(found[0])
+	return mint, err == nil
+}
+
+// namesMint reports whether token, a symbol or mint address in an intent, is mint.
+func (symm SymbolMapping) namesMint(token string, mint solana.PublicKey) bool {
+	if strings.EqualFold(token, symm.SymFrom(mint)) {
+		return true
+	}
+	named, ok := symm.MaybeMintFromToken(token)
+	return ok && named.Equals(mint)
+}
+
 func (symm SymbolMapping) UnresolvedCandidate() (string, bool) {
 	res := []string{}
 	for k := range symm.unresolved {
@@ -86,22 +168,72 @@ func normalizeSymbol(raw string) string {
 	return sym
 }
 
+// metadataConcurrency is how many mints makeSymbolMapping looks up at once.
+const metadataConcurrency = 4
+
+// makeSymbolMapping names mints from their metadata (asking -enhanced-api first), the token list or the mint itself, in
+// that order. The lookups run a few at a time under one metadata deadline for all of them, a mint that didn't make it
+// falls back like one without metadata.
 func makeSymbolMapping(ctx context.Context, client *rpc.Client, mints []solana.PublicKey) SymbolMapping {
 	symm := SymbolMapping{
 		mintToSymbol: make(map[string]string, len(mints)),
 		symbolToMint: make(map[string]solana.PublicKey, len(mints)),
 		unresolved:   make(map[string]struct{}),
+		sources:      make(map[string]string, len(mints)),
 	}
-	for _, mint := range mints {
-		tokenMeta, err := tokenMetadata(ctx, client, mint)
+	metaCtx, cancel := deadlines.forMetadata(ctx)
+	defer cancel()
+	metas := make([]Token, len(mints))
+	var named map[solana.PublicKey]Token
+	if enhanced != nil {
+		// The provider names what it can in one call, only the mints it doesn't know are looked up one by one.
+		start := time.Now()
+		found, err := enhanced.assets(metaCtx, mints)
+		debugf("%s metadata for %d mints took %s", enhanced.name(), len(mints), time.Since(start).Round(time.Millisecond))
 		if err != nil {
-			log.Printf("warning: failed to fetch metadata for mint %s: %v", Addr(mint.String()), err)
+			log.Printf("warning: %v, looking the metadata up over plain RPC", err)
 		}
-		symbol := normalizeSymbol(tokenMeta.Symbol)
+		named = found
+	}
+	sem := make(chan struct{}, metadataConcurrency)
+	var wg sync.WaitGroup
+	for i, mint := range mints {
+		if token, ok := named[mint]; ok {
+			metas[i] = token
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			start := time.Now()
+			tokenMeta, err := tokenMetadata(metaCtx, client, mint)
+			debugf("metadata for mint %s took %s", Addr(mint.String()), time.Since(start).Round(time.Millisecond))
+			if err != nil {
+				log.Printf("warning: failed to fetch metadata for mint %s: %v", Addr(mint.String()), err)
+			}
+			metas[i] = tokenMeta
+		}()
+	}
+	wg.Wait()
+	// NOTE(@hadydotai): Filled in the order the mints came in, not the order the lookups finished, two mints with the
+	// same symbol always resolve the same way.
+	for i, mint := range mints {
+		tokenMeta := metas[i]
+		symbol, source := normalizeSymbol(tokenMeta.Symbol), tokenMeta.Source
 		if len(symbol) == 0 {
-			symm.unresolved[mint.String()] = struct{}{}
-			symbol = normalizeSymbol(mint.String()[:4])
+			// NOTE(@hadydotai): No metadata on chain, which is the case for a fair few older mints. The token list
+			// knows the common ones, anything else falls back to the first characters of the mint and is left
+			// unresolved for the user to map.
+			if entry, ok := knownTokens.lookup(mint); ok {
+				symbol, source = normalizeSymbol(entry.Symbol), symbolSourceTokenList
+			} else {
+				symm.unresolved[mint.String()] = struct{}{}
+				symbol, source = normalizeSymbol(mint.String()[:4]), symbolSourceMint
+			}
 		}
+		symm.sources[mint.String()] = source
 		symm.mintToSymbol[mint.String()] = symbol
 		symm.symbolToMint[symbol] = mint
 	}
@@ -1,9 +1,18 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
 )
 
 func TestMissingSymbolMappingErrorFormatting(t *testing.T) {
@@ -58,6 +67,31 @@ func TestSymbolMappingMapSymToMint(t *testing.T) {
 	}
 }
 
+func TestSymbolMappingMintFromToken(t *testing.T) {
+	var a, b solana.PublicKey
+	for i := range a {
+		a[i], b[i] = 0xee, 0xee
+	}
+	a[31], b[31] = 1, 2
+	symm := SymbolMapping{
+		mintToSymbol: map[string]string{a.String(): "SOL", b.String(): "SO"},
+		symbolToMint: map[string]solana.PublicKey{"SOL": a, "SO": b},
+	}
+	if mint, ok := symm.MaybeMintFromToken(b.String()); !ok || !mint.Equals(b) {
+		t.Errorf("whole address gave %v", mint)
+	}
+	// The two mints only differ at the end, a prefix is ambiguous, and a symbol that starts an address is the symbol.
+	if _, ok := symm.MaybeMintFromToken(strings.ToUpper(a.String()[:10])); ok {
+		t.Error("ambiguous prefix matched a mint")
+	}
+	if mint, ok := symm.MaybeMintFromToken("SO"); !ok || !mint.Equals(b) {
+		t.Errorf("symbol SO gave %v", mint)
+	}
+	if !symm.namesMint(a.String(), a) || symm.namesMint(b.String(), a) || !symm.namesMint("sol", a) {
+		t.Error("namesMint")
+	}
+}
+
 func TestSymbolMappingUnknownSymbol(t *testing.T) {
 	symm := SymbolMapping{
 		mintToSymbol: make(map[string]string),
@@ -69,3 +103,42 @@ func TestSymbolMappingUnknownSymbol(t *testing.T) {
 		t.Fatalf("expected empty mint when symbol missing")
 	}
 }
+
+func TestMakeSymbolMappingConcurrent(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		inFlight int
+		peak     int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		// No account, every mint falls back on its address.
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"context":{"slot":1},"value":null}}`, req.ID)
+	}))
+	t.Cleanup(srv.Close)
+
+	var mints []solana.PublicKey
+	for i := range 10 {
+		mints = append(mints, snapshotKey(byte(80+i)))
+	}
+	symm := makeSymbolMapping(context.Background(), rpc.New(srv.URL), mints)
+	for _, mint := range mints {
+		if symm.SymFrom(mint) != normalizeSymbol(mint.String()[:4]) || symm.SourceOf(mint) != symbolSourceMint {
+			t.Errorf("mint %s came out as %q from %q", mint, symm.SymFrom(mint), symm.SourceOf(mint))
+		}
+	}
+	if peak < 2 || peak > metadataConcurrency {
+		t.Errorf("%d lookups at once, want between 2 and %d", peak, metadataConcurrency)
+	}
+}
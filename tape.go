@@ -0,0 +1,480 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/ws"
+	"github.com/nsf/termbox-go"
+)
+
+/*
+NOTE(@hadydotai): Trade tape.
+
+`tape <pool>` prints every swap on the pool as it lands. It subscribes to the logs of every transaction that mentions
+the pool (logsSubscribe with the pool in mentions) and reads the swaps out of them as SwapEvents, the same way pool
+stats does it from history (see pool_stats.go), routers calling into the pool included. The logs carry everything
+about the swap but who made it, that takes the transaction itself, so every trade with a swap in it is fetched once for
+its fee payer. Fetches run a few at a time and the tape waits for them in order, a slow fetch holds back the trades
+after it rather than shuffling them.
+
+A trade is a buy or a sell of token0, sized in token0, at the price it actually filled at (token1 per token0, what
+came out over what went in, trade fee included). The running volume is since the tape started.
+
+WebSockets drop, and a dropped subscription doesn't say so until the next read fails. The tape reconnects after a
+second, the swaps that landed while it was down aren't replayed, the status line says it reconnected so a gap doesn't
+go unnoticed. It runs in a small termbox screen, newest trade at the bottom, or with -json as one JSON object per trade
+on stdout for piping elsewhere.
+*/
+
+const (
+	tapeSignerConcurrency = 4
+	tapeReconnectDelay    = time.Second
+	tapeMaxLines          = 1000
+)
+
+// tapeTrade is one swap on the pool.
+type tapeTrade struct {
+	at        time.Time
+	signature solana.Signature
+	signer    solana.PublicKey // zero until it's known
+	buy       bool             // token0 came out of the pool
+	amounts   [2]*big.Int      // token0 and token1 that changed hands
+	price     *big.Rat         // token1 per token0
+	volume    [2]*big.Int      // running volume once this trade is counted
+}
+
+// tapeBook keeps the pool's tokens and the tape's running volume.
+type tapeBook struct {
+	pool     solana.PublicKey
+	mints    [2]solana.PublicKey
+	decimals [2]uint8
+	symbols  [2]string
+	trades   int
+	volume   [2]*big.Int
+}
+
+// trade turns a swap on the pool into a trade and counts it, ok is false for an event that isn't a swap on the pool
+// or doesn't say which way it went.
+func (tb *tapeBook) trade(ev raydium_cp_swap.SwapEvent, sig solana.Signature, at time.Time) (tapeTrade, bool) {
+	if !ev.PoolId.Equals(tb.pool) {
+		return tapeTrade{}, false
+	}
+	in := -1
+	for i, mint := range tb.mints {
+		if ev.InputMint.Equals(mint) {
+			in = i
+		}
+	}
+	if in < 0 || ev.InputAmount == 0 || ev.OutputAmount == 0 {
+		return tapeTrade{}, false
+	}
+	tr := tapeTrade{at: at, signature: sig, buy: in == 1}
+	tr.amounts[in] = new(big.Int).SetUint64(ev.InputAmount)
+	tr.amounts[1-in] = new(big.Int).SetUint64(ev.OutputAmount)
+	tr.price = new(big.Rat).SetFrac(tr.amounts[1], tr.amounts[0])
+	tr.price.Mul(tr.price, new(big.Rat).SetFrac(fixedPointScale(tb.decimals[0]), fixedPointScale(tb.decimals[1])))
+	tb.trades++
+	for i := range tb.volume {
+		if tb.volume[i] == nil {
+			tb.volume[i] = new(big.Int)
+		}
+		tb.volume[i].Add(tb.volume[i], tr.amounts[i])
+		tr.volume[i] = new(big.Int).Set(tb.volume[i])
+	}
+	return tr, true
+}
+
+func (tb *tapeBook) priceString(price *big.Rat) string {
+	return trimDecimal(price.FloatString(int(tb.decimals[0]) + int(tb.decimals[1])))
+}
+
+// line is a trade on one row of the tape.
+func (tb *tapeBook) line(tr tapeTrade) string {
+	side := "SELL"
+	if tr.buy {
+		side = "BUY "
+	}
+	signer := "?"
+	if !tr.signer.IsZero() {
+		signer = Addr(tr.signer.String()).String()
+	}
+	return fmt.Sprintf("%s  %s %s %s @ %s %s  by %s  %s",
+		tr.at.Format("15:04:05"), side, fmtAmount(tr.amounts[0], tb.decimals[0]), tb.symbols[0],
+		tb.priceString(tr.price), tb.symbols[1], signer, Addr(tr.signature.String()))
+}
+
+type tapeTradeJSON struct {
+	Time      time.Time     `json:"time"`
+	Signature string        `json:"signature"`
+	Signer    string        `json:"signer,omitempty"`
+	Side      string        `json:"side"` // buy or sell of token0
+	Amount    amountJSON    `json:"amount"`
+	Symbol    string        `json:"symbol"`
+	Counter   amountJSON    `json:"counter"`
+	CounterSy string        `json:"counterSymbol"`
+	Price     string        `json:"price"`  // counter per token
+	Volume    [2]amountJSON `json:"volume"` // token0 and token1 since the tape started
+}
+
+func (tb *tapeBook) json(tr tapeTrade) tapeTradeJSON {
+	out := tapeTradeJSON{
+		Time:      tr.at,
+		Signature: tr.signature.String(),
+		Side:      "sell",
+		Amount:    newAmountJSON(tr.amounts[0], tb.decimals[0]),
+		Symbol:    tb.symbols[0],
+		Counter:   newAmountJSON(tr.amounts[1], tb.decimals[1]),
+		CounterSy: tb.symbols[1],
+		Price:     tb.priceString(tr.price),
+		Volume:    [2]amountJSON{newAmountJSON(tr.volume[0], tb.decimals[0]), newAmountJSON(tr.volume[1], tb.decimals[1])},
+	}
+	if tr.buy {
+		out.Side = "buy"
+	}
+	if !tr.signer.IsZero() {
+		out.Signer = tr.signer.String()
+	}
+	return out
+}
+
+// tapeEvent is what the tape shows next, a trade or a change in the subscription.
+type tapeEvent struct {
+	trade  *tapeTrade
+	status string
+}
+
+// pendingEvent is the next thing for the tape, a transaction's trades that may still be waiting on its signer, or a
+// status.
+type pendingEvent struct {
+	trades []tapeTrade
+	status string
+	done   chan struct{}
+}
+
+// streamTape subscribes to the pool's logs until ctx ends, sending every trade to events in the order it landed, and
+// closes events when it's done.
+func streamTape(ctx context.Context, client *rpc.Client, wsEP string, book *tapeBook, events chan<- tapeEvent) {
+	queue := make(chan *pendingEvent, 64)
+	go func() {
+		defer close(events)
+		for p := range queue {
+			<-p.done
+			next := []tapeEvent{{status: p.status}}
+			if p.status == "" {
+				next = next[:0]
+				for i := range p.trades {
+					next = append(next, tapeEvent{trade: &p.trades[i]})
+				}
+			}
+			for _, ev := range next {
+				select {
+				case events <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	defer close(queue)
+	push := func(p *pendingEvent) bool {
+		select {
+		case queue <- p:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	status := func(s string) bool {
+		done := make(chan struct{})
+		close(done)
+		return push(&pendingEvent{status: s, done: done})
+	}
+	sem := make(chan struct{}, tapeSignerConcurrency)
+	for first := true; ctx.Err() == nil; first = false {
+		if !first {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(tapeReconnectDelay):
+			}
+		}
+		sub, closeSub, err := subscribeMentions(ctx, wsEP, book.pool)
+		if err != nil {
+			status(fmt.Sprintf("subscribing failed, retrying: %v", err))
+			continue
+		}
+		if first {
+			status("streaming")
+		} else {
+			status("reconnected, swaps that landed while it was down aren't shown")
+		}
+		for {
+			res, err := sub.Recv(ctx)
+			if err != nil {
+				closeSub()
+				if ctx.Err() == nil {
+					status(fmt.Sprintf("subscription dropped, reconnecting: %v", err))
+				}
+				break
+			}
+			if res == nil || res.Value.Err != nil {
+				continue
+			}
+			p := &pendingEvent{done: make(chan struct{})}
+			now := time.Now()
+			for _, le := range loggedSwapEvents(res.Value.Logs, raydium_cp_swap.ProgramID) {
+				if tr, ok := book.trade(le.event, res.Value.Signature, now); ok {
+					p.trades = append(p.trades, tr)
+				}
+			}
+			if len(p.trades) == 0 {
+				continue
+			}
+			if !push(p) {
+				closeSub()
+				return
+			}
+			go func(sig solana.Signature) {
+				defer close(p.done)
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				signer := feePayerOf(ctx, client, sig)
+				for i := range p.trades {
+					p.trades[i].signer = signer
+				}
+			}(res.Value.Signature)
+		}
+	}
+}
+
+// subscribeMentions subscribes to the logs of transactions mentioning account, a pool for the tape, a wallet for
+// `monitor wallet`.
+func subscribeMentions(ctx context.Context, wsEP string, account solana.PublicKey) (*ws.LogSubscription, func(), error) {
+	client, err := ws.Connect(ctx, wsEP)
+	if err != nil {
+		return nil, nil, fmt.Errorf("websocket connection to %s failed: %w", wsEP, err)
+	}
+	sub, err := client.LogsSubscribeMentions(account, rpc.CommitmentConfirmed)
+	if err != nil {
+		client.Close()
+		return nil, nil, fmt.Errorf("logsSubscribe failed: %w", err)
+	}
+	return sub, func() {
+		sub.Unsubscribe()
+		client.Close()
+	}, nil
+}
+
+// feePayerOf is who paid for the transaction, zero when it can't be fetched.
+func feePayerOf(ctx context.Context, client *rpc.Client, sig solana.Signature) solana.PublicKey {
+	ctx, cancel := deadlines.forQuote(ctx)
+	defer cancel()
+	maxVersion := uint64(0)
+	res, err := client.GetTransaction(ctx, sig, &rpc.GetTransactionOpts{
+		Encoding:                       solana.EncodingBase64,
+		Commitment:                     rpc.CommitmentConfirmed,
+		MaxSupportedTransactionVersion: &maxVersion,
+	})
+	if err != nil || res == nil || res.Transaction == nil {
+		return solana.PublicKey{}
+	}
+	tx, err := res.Transaction.GetTransaction()
+	if err != nil || tx == nil || len(tx.Message.AccountKeys) == 0 {
+		return solana.PublicKey{}
+	}
+	return tx.Message.AccountKeys[0]
+}
+
+// writeTapeJSON writes every trade as a JSON line, statuses go to the log.
+func writeTapeJSON(w io.Writer, book *tapeBook, events <-chan tapeEvent) error {
+	enc := json.NewEncoder(w)
+	for ev := range events {
+		if ev.trade == nil {
+			log.Printf("tape: %s", ev.status)
+			continue
+		}
+		if err := enc.Encode(book.json(*ev.trade)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tapeScreen is the live tape in a termbox screen: a header with the running totals, the trades newest last, a status
+// line and the keys.
+type tapeScreen struct {
+	book   *tapeBook // read only here, streamTape counts the trades on it
+	trades scrollView
+	count  int
+	volume [2]*big.Int
+	status string
+}
+
+func (ts *tapeScreen) add(tr tapeTrade) {
+	ts.count++
+	ts.volume = tr.volume
+	sv := &ts.trades
+	following := sv.offset >= sv.maxOffset()
+	sv.lines = append(sv.lines, ts.book.line(tr))
+	if over := len(sv.lines) - tapeMaxLines; over > 0 {
+		sv.lines = sv.lines[over:]
+		sv.offset = max(sv.offset-over, 0)
+	}
+	if following {
+		// Stay on the newest trade unless the user scrolled up to look at older ones.
+		sv.offset = sv.maxOffset()
+	}
+}
+
+func (ts *tapeScreen) header() string {
+	b := ts.book
+	vol := func(i int) string {
+		v := ts.volume[i]
+		if v == nil {
+			v = new(big.Int)
+		}
+		return fmtAmount(v, b.decimals[i]) + " " + b.symbols[i]
+	}
+	return fmt.Sprintf(" %s/%s │ pool %s │ %d trades │ volume %s, %s", b.symbols[0], b.symbols[1], Addr(b.pool.String()), ts.count, vol(0), vol(1))
+}
+
+func (ts *tapeScreen) draw() {
+	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+	width, height := termbox.Size()
+	if height > 0 {
+		helpBar{bindings: []keyBinding{{"↑/↓", "scroll"}, {"End", "newest"}, {"q", "quit"}}}.draw(rect{x: 0, y: height - 1, w: width, h: 1})
+	}
+	if height > 1 {
+		drawText(0, height-2, width, ts.status, termbox.ColorDefault, termbox.ColorDefault)
+	}
+	if height > 2 {
+		fillRow(0, 0, width, termbox.ColorDefault|termbox.AttrReverse)
+		drawText(0, 0, width, ts.header(), termbox.ColorDefault|termbox.AttrReverse, termbox.ColorDefault)
+	}
+	ts.trades.draw(rect{x: 0, y: 1, w: width, h: height - 3})
+	termbox.Flush()
+}
+
+// run shows the tape until q, Esc or Ctrl-C, or until events closes.
+func (ts *tapeScreen) run(events <-chan tapeEvent) error {
+	if err := termbox.Init(); err != nil {
+		return err
+	}
+	defer termbox.Close()
+	keys := make(chan termbox.Event)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			ev := termbox.PollEvent()
+			select {
+			case keys <- ev:
+			case <-done:
+				return
+			}
+		}
+	}()
+	defer termbox.Interrupt()
+	for {
+		ts.draw()
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if ev.trade != nil {
+				ts.add(*ev.trade)
+			} else {
+				ts.status = ev.status
+			}
+		case ev := <-keys:
+			switch {
+			case ev.Type == termbox.EventError:
+				return ev.Err
+			case ev.Type != termbox.EventKey:
+			case ev.Ch == 'q' || ev.Key == termbox.KeyEsc || ev.Key == termbox.KeyCtrlC:
+				return nil
+			default:
+				ts.trades.handleKey(ev)
+			}
+		}
+	}
+}
+
+func runTapeCommand(args []string) error {
+	fs := flag.NewFlagSet("tape", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: tape [flags] <pool address or pair>\n")
+		fs.PrintDefaults()
+	}
+	nf := addNetworkFlags(fs)
+	var (
+		wsEP   = fs.String("ws", "", "WebSocket endpoint to subscribe on, derived from -rpc when empty")
+		asJSON = fs.Bool("json", false, "Print every trade as a line of JSON instead of showing the tape")
+	)
+	addAmountFlags(fs)
+	// The pool reads naturally first, `tape <pool> -network mainnet`, flag stops at the first argument.
+	var target string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		target, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, nf.specs())
+	if target == "" && fs.NArg() > 0 {
+		target = fs.Arg(0)
+	}
+	if target == "" {
+		fs.Usage()
+		return errors.New("missing pool")
+	}
+	client := nf.connect()
+	if *wsEP == "" {
+		*wsEP = wsEndpointFor(*nf.rpcEP)
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+
+	quoteCtx, cancel := deadlines.forQuote(ctx)
+	poolAddr, err := resolvePoolTarget(quoteCtx, client, target, SymbolMapping{})
+	cancel()
+	if err != nil {
+		return err
+	}
+	lp, err := loadPool(ctx, client, poolAddr)
+	if err != nil {
+		return err
+	}
+	if lp.mints[0] == nil || lp.mints[1] == nil {
+		return fmt.Errorf("couldn't read the mints of pool %s", Addr(poolAddr.String()))
+	}
+	book := &tapeBook{
+		pool:     poolAddr,
+		mints:    [2]solana.PublicKey{lp.pool.Token0Mint, lp.pool.Token1Mint},
+		decimals: [2]uint8{lp.mints[0].Decimals, lp.mints[1].Decimals},
+		symbols:  [2]string{lp.symbolsMap.SymFrom(lp.pool.Token0Mint), lp.symbolsMap.SymFrom(lp.pool.Token1Mint)},
+	}
+	streamCtx, cancelStream := context.WithCancel(ctx)
+	defer cancelStream()
+	events := make(chan tapeEvent)
+	go streamTape(streamCtx, client, *wsEP, book, events)
+	if *asJSON {
+		return writeTapeJSON(os.Stdout, book, events)
+	}
+	return (&tapeScreen{book: book, status: "connecting to " + *wsEP}).run(events)
+}
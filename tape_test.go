@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+func testTapeBook() *tapeBook {
+	return &tapeBook{
+		pool:     snapshotKey(60),
+		mints:    [2]solana.PublicKey{wSOLMint, snapshotKey(61)},
+		decimals: [2]uint8{9, 6},
+		symbols:  [2]string{"SOL", "USDC"},
+	}
+}
+
+func TestTapeBookTrade(t *testing.T) {
+	tb := testTapeBook()
+	usdc := tb.mints[1]
+	at := time.Date(2026, 1, 1, 12, 30, 0, 0, time.UTC)
+
+	// 2 SOL in for 300 USDC, a sell at 150.
+	sell, ok := tb.trade(raydium_cp_swap.SwapEvent{PoolId: tb.pool, InputMint: wSOLMint, OutputMint: usdc, InputAmount: 2_000_000_000, OutputAmount: 300_000_000}, solana.Signature{1}, at)
+	if !ok || sell.buy || tb.priceString(sell.price) != "150" {
+		t.Fatalf("sell came out as buy=%v at %s", sell.buy, tb.priceString(sell.price))
+	}
+	// 160 USDC in for 1 SOL, a buy at 160.
+	buy, ok := tb.trade(raydium_cp_swap.SwapEvent{PoolId: tb.pool, InputMint: usdc, OutputMint: wSOLMint, InputAmount: 160_000_000, OutputAmount: 1_000_000_000}, solana.Signature{2}, at)
+	if !ok || !buy.buy || tb.priceString(buy.price) != "160" {
+		t.Fatalf("buy came out as buy=%v at %s", buy.buy, tb.priceString(buy.price))
+	}
+	if buy.volume[0].Cmp(big.NewInt(3_000_000_000)) != 0 || buy.volume[1].Cmp(big.NewInt(460_000_000)) != 0 {
+		t.Errorf("volume after the buy %s SOL, %s USDC", buy.volume[0], buy.volume[1])
+	}
+	if sell.volume[0].Cmp(big.NewInt(2_000_000_000)) != 0 {
+		t.Errorf("the sell's volume moved to %s when the buy was counted", sell.volume[0])
+	}
+
+	for name, ev := range map[string]raydium_cp_swap.SwapEvent{
+		"other pool":    {PoolId: snapshotKey(62), InputMint: wSOLMint, InputAmount: 1, OutputAmount: 1},
+		"no input mint": {PoolId: tb.pool, InputAmount: 1, OutputAmount: 1},
+		"empty":         {PoolId: tb.pool, InputMint: wSOLMint},
+	} {
+		if _, ok := tb.trade(ev, solana.Signature{3}, at); ok {
+			t.Errorf("%s made a trade", name)
+		}
+	}
+	if tb.trades != 2 {
+		t.Errorf("counted %d trades", tb.trades)
+	}
+
+	line := tb.line(buy)
+	if !strings.HasPrefix(line, "12:30:00  BUY  1.000000000 SOL @ 160 USDC  by ?") {
+		t.Errorf("line %q", line)
+	}
+	buy.signer = snapshotKey(63)
+	out := tb.json(buy)
+	if out.Side != "buy" || out.Signer != snapshotKey(63).String() || out.Counter.Display != "160.000000" || out.Volume[1].Raw != "460000000" {
+		t.Errorf("json %+v", out)
+	}
+}
+
+func TestWriteTapeJSON(t *testing.T) {
+	tb := testTapeBook()
+	tr, _ := tb.trade(raydium_cp_swap.SwapEvent{PoolId: tb.pool, InputMint: wSOLMint, InputAmount: 1_000_000_000, OutputAmount: 150_000_000}, solana.Signature{1}, time.Now())
+	events := make(chan tapeEvent, 3)
+	events <- tapeEvent{status: "streaming"}
+	events <- tapeEvent{trade: &tr}
+	close(events)
+	var buf bytes.Buffer
+	if err := writeTapeJSON(&buf, tb, events); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("wrote %d lines, want only the trade: %q", len(lines), buf.String())
+	}
+	var got tapeTradeJSON
+	if err := json.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Side != "sell" || got.Price != "150" || got.Signer != "" {
+		t.Errorf("wrote %+v", got)
+	}
+}
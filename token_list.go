@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+/*
+NOTE(@hadydotai): Token list.
+
+Plenty of mints carry no metadata at all, USDT and older tokens minted before anyone bothered, so the pool shows
+`ES9V` and asks the user to map USDT by hand, every time. The token list is the fallback for those, consulted only
+when the chain has nothing to say: a handful of common mints are bundled (bundledTokens), and `tokens refresh`
+downloads Jupiter's verified list into the user's config directory (-token-list), which is read at startup on top of
+the bundled one.
+
+The list only ever supplies a symbol. Decimals always come from the chain, the vaults report them with every
+balance, a list can be wrong and the chain can't. Where a symbol came from is shown next to it in the quote table,
+metaplex, token-2022, tokenlist, alias, mapped (by hand, this session) or mint address when nothing knew.
+*/
+
+type tokenListEntry struct {
+	Mint     string `json:"id"`
+	Symbol   string `json:"symbol"`
+	Name     string `json:"name"`
+	Decimals uint8  `json:"decimals"`
+}
+
+type tokenList map[string]tokenListEntry // mint -> entry
+
+// bundledTokens are known without a refresh, mainnet mints plus devnet USDC.
+var bundledTokens = tokenList{
+	"So11111111111111111111111111111111111111112":  {Symbol: "SOL", Name: "Wrapped SOL", Decimals: 9},
+	"EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v": {Symbol: "USDC", Name: "USD Coin", Decimals: 6},
+	"Es9vMFrzaCERmJfrF4H2FYD4KCoNkY11McCe8BenwNYB": {Symbol: "USDT", Name: "USDT", Decimals: 6},
+	"DezXAZ8z7PnrnRJjz3wXBoRgixCa6xjnB7YaB1pPB263": {Symbol: "BONK", Name: "Bonk", Decimals: 5},
+	"JUPyiwrYJFskUPiHa7hkeR8VUtAeFoSYbKedZNsDvCN":  {Symbol: "JUP", Name: "Jupiter", Decimals: 6},
+	"4k3Dyjzvzp8eMZWUXbBCjEvwSkkk59S5iCNLY3QrkX6R": {Symbol: "RAY", Name: "Raydium", Decimals: 6},
+	"mSoLzYCxHdYgdzU16g5QSh3i5K3z3KZK7ytfqcJm7So":  {Symbol: "MSOL", Name: "Marinade staked SOL", Decimals: 9},
+	"J1toso1uCk3RLmjorhTtrVwY9HJ7X8V9yYac6Y7kGCPn": {Symbol: "JITOSOL", Name: "Jito Staked SOL", Decimals: 9},
+	"EKpQGSJtjMFqKZ9KQanSqYXRcF8fBopzLHYxdM65zcjm": {Symbol: "WIF", Name: "dogwifhat", Decimals: 6},
+	"HZ1JovNiVvGrGNiiYvEozEVgZ58xaU3RKwX8eACQBCt3": {Symbol: "PYTH", Name: "Pyth Network", Decimals: 6},
+	"4zMMC9srt5Ri5X14GAgXhaHii3GnPAEERYPJgZJDncDU": {Symbol: "USDC", Name: "USD Coin (devnet)", Decimals: 6},
+}
+
+var (
+	// knownTokens is what symbol resolution falls back on, the bundled list until a refreshed one is loaded.
+	knownTokens = bundledTokens
+
+	jupiterTokenListURL = "https://lite-api.jup.ag/tokens/v2/tag?query=verified"
+)
+
+func (l tokenList) lookup(mint solana.PublicKey) (tokenListEntry, bool) {
+	entry, ok := l[mint.String()]
+	return entry, ok && normalizeSymbol(entry.Symbol) != ""
+}
+
+func defaultTokenListPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "tokens.json"
+	}
+	return filepath.Join(dir, "raydium-client", "tokens.json")
+}
+
+func addTokenListFlag(fs *flag.FlagSet) *string {
+	return fs.String("token-list", defaultTokenListPath(), "Token list symbols fall back on when a mint has no metadata, see `tokens refresh`")
+}
+
+// loadTokenList reads a list written by `tokens refresh` and lays it over the bundled one. A missing file is just the
+// bundled list.
+func loadTokenList(path string) (tokenList, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return bundledTokens, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading token list %s: %w", path, err)
+	}
+	var entries []tokenListEntry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, fmt.Errorf("token list %s is corrupt: %w", path, err)
+	}
+	l := make(tokenList, len(bundledTokens)+len(entries))
+	for mint, entry := range bundledTokens {
+		l[mint] = entry
+	}
+	for _, entry := range entries {
+		if _, err := solana.PublicKeyFromBase58(entry.Mint); err == nil {
+			l[entry.Mint] = entry
+		}
+	}
+	return l, nil
+}
+
+// useTokenListFile makes the list at path what symbols fall back on, warning and keeping the bundled list when it
+// can't be read.
+func useTokenListFile(path string) {
+	l, err := loadTokenList(path)
+	if err != nil {
+		log.Printf("warning: %v, using the bundled token list", err)
+		l = bundledTokens
+	}
+	knownTokens = l
+}
+
+func runTokensCommand(args []string) error {
+	return dispatchSubcommand("tokens", map[string]func([]string) error{
+		"refresh": runTokensRefreshCommand,
+		"lookup":  runTokensLookupCommand,
+	}, args)
+}
+
+func runTokensRefreshCommand(args []string) error {
+	fs := flag.NewFlagSet("tokens refresh", flag.ExitOnError)
+	path := addTokenListFlag(fs)
+	source := fs.String("source", jupiterTokenListURL, "Where to download the list from, Jupiter's token API format")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, []FlagSpec{
+		{Name: "token-list", Value: path, Rules: []FlagRule{NotEmpty()}},
+		{Name: "source", Value: source, Rules: []FlagRule{NotEmpty()}},
+	})
+	ctx, stop := interruptContext()
+	defer stop()
+	n, err := refreshTokenList(ctx, *source, *path)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("saved %d tokens to %s\n", n, *path)
+	return nil
+}
+
+// refreshTokenList downloads the list at source and writes what's usable of it to path.
+func refreshTokenList(ctx context.Context, source, path string) (int, error) {
+	ctx, cancel := deadlines.forQuote(ctx)
+	defer cancel()
+	var entries []tokenListEntry
+	if err := fetchJSON(ctx, source, &entries); err != nil {
+		return 0, fmt.Errorf("downloading the token list failed: %w", err)
+	}
+	kept := entries[:0]
+	for _, entry := range entries {
+		if _, err := solana.PublicKeyFromBase58(entry.Mint); err == nil && normalizeSymbol(entry.Symbol) != "" {
+			kept = append(kept, entry)
+		}
+	}
+	if len(kept) == 0 {
+		return 0, errors.New("the downloaded token list has no usable tokens, keeping the one we have")
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].Mint < kept[j].Mint })
+	raw, err := json.MarshalIndent(kept, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, fmt.Errorf("writing token list: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return 0, fmt.Errorf("writing token list: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(raw, '\n')); err != nil {
+		tmp.Close()
+		return 0, fmt.Errorf("writing token list: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("writing token list: %w", err)
+	}
+	return len(kept), os.Rename(tmp.Name(), path)
+}
+
+func runTokensLookupCommand(args []string) error {
+	fs := flag.NewFlagSet("tokens lookup", flag.ExitOnError)
+	path := addTokenListFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: tokens lookup [-token-list FILE] <mint or symbol>")
+	}
+	l, err := loadTokenList(*path)
+	if err != nil {
+		return err
+	}
+	query := fs.Arg(0)
+	var found []tokenListEntry
+	for mint, entry := range l {
+		if mint == query || strings.EqualFold(normalizeSymbol(entry.Symbol), normalizeSymbol(query)) {
+			entry.Mint = mint
+			found = append(found, entry)
+		}
+	}
+	if len(found) == 0 {
+		return fmt.Errorf("%s isn't in the token list", query)
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Mint < found[j].Mint })
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Symbol", "Name", "Decimals", "Mint"})
+	for _, entry := range found {
+		t.AppendRow(table.Row{entry.Symbol, entry.Name, entry.Decimals, entry.Mint})
+	}
+	t.Render()
+	return nil
+}
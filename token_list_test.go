@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestRefreshTokenList(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[
+			{"id":"2b1kV6DkPAnxd5ixfnxCpjxmKwqjjaYmCZfHsFu24GXo","symbol":"PYUSD","name":"PayPal USD","decimals":6},
+			{"id":"Es9vMFrzaCERmJfrF4H2FYD4KCoNkY11McCe8BenwNYB","symbol":"USDT","name":"Tether USD","decimals":6},
+			{"id":"not-a-mint","symbol":"BAD","decimals":6},
+			{"id":"DezXAZ8z7PnrnRJjz3wXBoRgixCa6xjnB7YaB1pPB263","symbol":"  ","decimals":5}
+		]`)
+	}))
+	t.Cleanup(srv.Close)
+	path := filepath.Join(t.TempDir(), "cfg", "tokens.json")
+	n, err := refreshTokenList(context.Background(), srv.URL, path)
+	if err != nil || n != 2 {
+		t.Fatalf("refresh kept %d tokens, %v", n, err)
+	}
+	l, err := loadTokenList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := l.lookup(solana.MustPublicKeyFromBase58("2b1kV6DkPAnxd5ixfnxCpjxmKwqjjaYmCZfHsFu24GXo")); !ok || e.Symbol != "PYUSD" {
+		t.Errorf("refreshed token missing: %+v", e)
+	}
+	if e, ok := l.lookup(solana.MustPublicKeyFromBase58("Es9vMFrzaCERmJfrF4H2FYD4KCoNkY11McCe8BenwNYB")); !ok || e.Name != "Tether USD" {
+		t.Errorf("refreshed entries should win over bundled ones: %+v", e)
+	}
+	// Bundled tokens the download didn't carry, or carried without a symbol, are still known.
+	if e, ok := l.lookup(solana.MustPublicKeyFromBase58("DezXAZ8z7PnrnRJjz3wXBoRgixCa6xjnB7YaB1pPB263")); !ok || e.Symbol != "BONK" {
+		t.Errorf("bundled BONK lost: %+v", e)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadTokenList(path); err == nil {
+		t.Error("want a corrupt list reported")
+	}
+}
+
+func TestSymbolMappingFallsBackOnTokenList(t *testing.T) {
+	// The stub answers nothing but token balances, so every metadata lookup fails and only the list is left.
+	srv := vaultBalanceServer(t, nil)
+	usdt := solana.MustPublicKeyFromBase58("Es9vMFrzaCERmJfrF4H2FYD4KCoNkY11McCe8BenwNYB")
+	unknown := snapshotKey(7)
+	symm := makeSymbolMapping(context.Background(), rpc.New(srv.URL), []solana.PublicKey{usdt, unknown})
+
+	if symm.SymFrom(usdt) != "USDT" || symm.SourceOf(usdt) != symbolSourceTokenList {
+		t.Errorf("USDT resolved as %q from %q", symm.SymFrom(usdt), symm.SourceOf(usdt))
+	}
+	if symm.SourceOf(unknown) != symbolSourceMint {
+		t.Errorf("unknown mint from %q", symm.SourceOf(unknown))
+	}
+	if candidate, ok := symm.UnresolvedCandidate(); !ok || candidate != unknown.String() {
+		t.Errorf("only the unknown mint should be left to map, got %q", candidate)
+	}
+
+	symm.MapSymToMint("SEVEN", unknown.String())
+	if symm.SourceOf(unknown) != symbolSourceUser {
+		t.Errorf("mapped by hand shows as %q", symm.SourceOf(unknown))
+	}
+}
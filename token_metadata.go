@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/rpc"
@@ -216,7 +218,8 @@ func (r *binaryReader) le32Len() (int, bool) {
 }
 
 func (r *binaryReader) bytes(n int) ([]byte, bool) {
-	if n < 0 || r.i+n > len(r.b) {
+	// n is compared against what's left rather than r.i+n against the end, a huge n would overflow the sum.
+	if n < 0 || n > len(r.b)-r.i {
 		return nil, false
 	}
 	v := r.b[r.i : r.i+n]
@@ -240,13 +243,33 @@ func (r *binaryReader) borshString() (string, bool) {
 	return s, true
 }
 
+// maxMetaRunes is as much of a name or symbol as we keep. Metaplex caps them at 32 and 10 bytes, Token-2022 doesn't cap
+// them at all, and both end up in tables and prompts.
+const maxMetaRunes = 64
+
+// trimMeta cleans a name or symbol read off the chain. Anyone can mint a token and write whatever they like in there,
+// so control characters (terminal escapes included) and invalid UTF-8 are dropped and it's cut to maxMetaRunes.
 func trimMeta(s string) string {
-	return strings.TrimSpace(strings.TrimRight(s, "\x00"))
+	s = strings.Map(func(r rune) rune {
+		if r == utf8.RuneError || !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return -1
+		}
+		if unicode.IsSpace(r) {
+			return ' '
+		}
+		return r
+	}, strings.TrimRight(s, "\x00"))
+	if runes := []rune(s); len(runes) > maxMetaRunes {
+		s = string(runes[:maxMetaRunes])
+	}
+	return strings.TrimSpace(s)
 }
 
 type Token struct {
 	Name   string
 	Symbol string
+	// Source is where the metadata was found, symbolSourceMetaplex, symbolSourceToken2022 or the -enhanced-api provider.
+	Source string
 }
 
 const (
@@ -254,6 +277,7 @@ const (
 	baseAccountLen               = 165
 	mintExtensionPaddingBytes    = baseAccountLen - baseMintLen
 	accountTypeMint              = 1
+	accountTypeAccount           = 2
 	extensionTypeUninitialized   = 0
 	extensionTypeMetadataPointer = 18
 	extensionTypeTokenMetadata   = 19
@@ -280,10 +304,10 @@ func parseToken2022Metadata(ctx context.Context, client *rpc.Client, mint solana
 }
 
 func token2022TLVRegion(data []byte) ([]byte, error) {
-	rest := data[baseMintLen:]
-	if len(rest) == 0 {
+	if len(data) <= baseMintLen {
 		return nil, errors.New("token2022 mint missing extension bytes")
 	}
+	rest := data[baseMintLen:]
 
 	if len(rest) >= mintExtensionPaddingBytes+1 {
 		padding := rest[:mintExtensionPaddingBytes]
@@ -505,7 +529,12 @@ func parseMetaplexMetadata(ctx context.Context, client *rpc.Client, mint solana.
 		return Token{}, fmt.Errorf("account %s not owned by mpl-token-metadata (owner=%s)", Addr(mint.String()), Addr(res.Value.Owner.String()))
 	}
 
-	r := &binaryReader{b: res.Value.Data.GetBinary()}
+	return decodeMetaplexMetadata(res.Value.Data.GetBinary())
+}
+
+// decodeMetaplexMetadata reads the name and symbol off a Metaplex metadata account, layout (1) above.
+func decodeMetaplexMetadata(data []byte) (Token, error) {
+	r := &binaryReader{b: data}
 
 	if _, ok := r.bytes(1); !ok { // key
 		return Token{}, errors.New("failed skipping token key")
@@ -549,9 +578,13 @@ func tokenMetadata(ctx context.Context, client *rpc.Client, mint solana.PublicKe
 	owner := res.Value.Owner
 	switch owner.String() {
 	case solana.Token2022ProgramID.String():
-		return parseToken2022Metadata(ctx, client, mint, data)
+		token, err := parseToken2022Metadata(ctx, client, mint, data)
+		token.Source = symbolSourceToken2022
+		return token, err
 	case solana.TokenProgramID.String():
-		return parseMetaplexMetadata(ctx, client, mint)
+		token, err := parseMetaplexMetadata(ctx, client, mint)
+		token.Source = symbolSourceMetaplex
+		return token, err
 	}
 	return Token{}, fmt.Errorf("couldn't get metadata for token %s", Addr(mint.String()))
 }
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+/*
+NOTE(@hadydotai): Everything in token_metadata.go reads bytes whoever minted the token wrote, so the decoders are
+fuzzed. `go test` only runs the seeds, fuzz one properly with e.g.
+
+	go test -run '^$' -fuzz FuzzParseToken2022TLVEntries -fuzztime 1m
+
+The seeds are well formed encodings, the fuzzer mutates them into the hostile ones. Besides not panicking, whatever
+decodes has to come out clean (see checkMetaToken), that's what reaches the terminal.
+*/
+
+func borshString(s string) []byte {
+	return append(binary.LittleEndian.AppendUint32(nil, uint32(len(s))), s...)
+}
+
+// tokenMetadataValue is a TokenMetadata extension payload, layout (4.b).
+func tokenMetadataValue(mint solana.PublicKey, name, symbol string, additional ...string) []byte {
+	v := append(snapshotKey(90).Bytes(), mint.Bytes()...)
+	v = append(v, borshString(name)...)
+	v = append(v, borshString(symbol)...)
+	v = append(v, borshString("https://example.com/meta.json")...)
+	v = binary.LittleEndian.AppendUint32(v, uint32(len(additional)/2))
+	for _, s := range additional {
+		v = append(v, borshString(s)...)
+	}
+	return v
+}
+
+func tlvEntry(typ uint16, value []byte) []byte {
+	entry := binary.LittleEndian.AppendUint16(nil, typ)
+	entry = binary.LittleEndian.AppendUint16(entry, uint16(len(value)))
+	return append(entry, value...)
+}
+
+func metadataPointerValue(authority, metadata solana.PublicKey) []byte {
+	return append(authority.Bytes(), metadata.Bytes()...)
+}
+
+func metaplexAccount(mint solana.PublicKey, name, symbol string) []byte {
+	data := append([]byte{4}, snapshotKey(90).Bytes()...)
+	data = append(data, mint.Bytes()...)
+	data = append(data, borshString(name)...)
+	data = append(data, borshString(symbol)...)
+	return append(data, borshString("https://example.com/meta.json")...)
+}
+
+// checkMetaToken fails on a decoded name or symbol that isn't safe to print.
+func checkMetaToken(t *testing.T, token Token) {
+	t.Helper()
+	for _, s := range []string{token.Name, token.Symbol} {
+		if !utf8.ValidString(s) || utf8.RuneCountInString(s) > maxMetaRunes || strings.TrimSpace(s) != s {
+			t.Fatalf("decoded %q", s)
+		}
+		for _, r := range s {
+			if !unicode.IsPrint(r) && r != ' ' {
+				t.Fatalf("decoded %q with unprintable %U", s, r)
+			}
+		}
+	}
+}
+
+func TestDecodeTokenMetadata(t *testing.T) {
+	mint := snapshotKey(91)
+	token, err := decodeToken2022MetadataEntry(tokenMetadataValue(mint, "Bonk\x00\x00", " BONK ", "k", "v"), mint)
+	if err != nil || token.Name != "Bonk" || token.Symbol != "BONK" {
+		t.Errorf("decoded %+v, %v", token, err)
+	}
+	if _, err := decodeToken2022MetadataEntry(tokenMetadataValue(snapshotKey(92), "Bonk", "BONK"), mint); err == nil {
+		t.Error("metadata for another mint should be refused")
+	}
+
+	// A symbol carrying a terminal escape and a newline comes out inert.
+	token, err = decodeMetaplexMetadata(metaplexAccount(mint, "Evil\x1b[2J Coin", "EV\nIL\xff"))
+	if err != nil || token.Name != "Evil[2J Coin" || token.Symbol != "EV IL" {
+		t.Errorf("decoded %+v, %v", token, err)
+	}
+	if token, _ := decodeMetaplexMetadata(metaplexAccount(mint, strings.Repeat("x", 10_000), "X")); len(token.Name) != maxMetaRunes {
+		t.Errorf("name kept %d characters, want %d", len(token.Name), maxMetaRunes)
+	}
+
+	// The pointer is only followed when the metadata isn't on the mint.
+	pointer := snapshotKey(93)
+	tlv := tlvEntry(extensionTypeMetadataPointer, metadataPointerValue(snapshotKey(94), pointer))
+	if _, got, err := parseToken2022TLVEntries(tlv, mint); err != nil || got == nil || !got.Equals(pointer) {
+		t.Errorf("pointer %v, %v", got, err)
+	}
+	tlv = append(tlv, tlvEntry(extensionTypeTokenMetadata, tokenMetadataValue(mint, "Bonk", "BONK"))...)
+	if token, got, err := parseToken2022TLVEntries(tlv, mint); err != nil || got != nil || token.Symbol != "BONK" {
+		t.Errorf("decoded %+v, pointer %v, %v", token, got, err)
+	}
+}
+
+func FuzzParseToken2022TLVEntries(f *testing.F) {
+	mint := snapshotKey(91)
+	meta := tlvEntry(extensionTypeTokenMetadata, tokenMetadataValue(mint, "Bonk", "BONK", "k", "v"))
+	pointer := tlvEntry(extensionTypeMetadataPointer, metadataPointerValue(snapshotKey(94), snapshotKey(93)))
+	f.Add(meta)
+	f.Add(append(pointer, meta...))
+	f.Add(append(tlvEntry(7, []byte{1, 2, 3}), pointer...))
+	f.Add([]byte{19, 0, 0xff, 0xff})
+	f.Fuzz(func(t *testing.T, tlv []byte) {
+		token, pointer, err := parseToken2022TLVEntries(tlv, mint)
+		if err == nil {
+			if pointer != nil && (pointer.IsZero() || token != (Token{})) {
+				t.Fatalf("pointer %v alongside %+v", pointer, token)
+			}
+			checkMetaToken(t, token)
+		}
+		// Whatever the entries are, the mint around them mustn't trip the region scan either.
+		mintData := append(make([]byte, baseMintLen), accountTypeMint)
+		if region, err := token2022TLVRegion(append(mintData, tlv...)); err != nil || len(region) != len(tlv) {
+			t.Fatalf("region %d bytes, %v, want the %d TLV bytes", len(region), err, len(tlv))
+		}
+	})
+}
+
+func FuzzDecodeToken2022MetadataEntry(f *testing.F) {
+	mint := snapshotKey(91)
+	f.Add(tokenMetadataValue(mint, "Bonk", "BONK"))
+	f.Add(tokenMetadataValue(mint, "Bonk", "BONK", "k", "v", "k2", "v2"))
+	f.Add(tokenMetadataValue(mint, "\x1b]0;pwned\x07", "\xff\xfe"))
+	f.Fuzz(func(t *testing.T, val []byte) {
+		token, err := decodeToken2022MetadataEntry(val, mint)
+		if err == nil {
+			checkMetaToken(t, token)
+		}
+	})
+}
+
+func FuzzDecodeMetadataPointer(f *testing.F) {
+	f.Add(metadataPointerValue(snapshotKey(94), snapshotKey(93)))
+	f.Add(metadataPointerValue(snapshotKey(94), solana.PublicKey{}))
+	f.Add([]byte{1, 2, 3})
+	f.Fuzz(func(t *testing.T, val []byte) {
+		pk, ok := decodeMetadataPointer(val)
+		if ok && (pk.IsZero() || len(val) < 64 || !equal32(pk.Bytes(), val[32:64])) {
+			t.Fatalf("decoded pointer %s from %x", pk, val)
+		}
+	})
+}
+
+func FuzzDecodeMetaplexMetadata(f *testing.F) {
+	mint := snapshotKey(91)
+	f.Add(metaplexAccount(mint, "Wrapped SOL", "SOL"))
+	f.Add(metaplexAccount(mint, "", ""))
+	f.Add(append(metaplexAccount(mint, "Bonk", "BONK")[:65], 0xff, 0xff, 0xff, 0x7f))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		token, err := decodeMetaplexMetadata(data)
+		if err == nil {
+			checkMetaToken(t, token)
+		}
+	})
+}
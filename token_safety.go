@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): Token safety.
+
+A pool is only as safe as its tokens. What a mint lets its owner do after you've bought in is all in the mint account:
+the base layout's mint and freeze authorities (more supply, frozen token accounts), and for Token-2022 the extensions
+after it. The ones that can take something from a holder are reported as risks:
+
+  | risk               | what it is                                                          |
+  |--------------------|---------------------------------------------------------------------|
+  | mint-authority     | someone can still mint more                                         |
+  | freeze-authority   | someone can freeze your token account                               |
+  | transfer-fee       | every transfer pays a cut, selling back included (TransferFeeConfig) |
+  | permanent-delegate | someone can move or burn tokens out of any account                  |
+  | transfer-hook      | a program of the issuer's runs on every transfer and can refuse it  |
+  | non-transferable   | the token can't be moved at all, so it can't be sold                |
+  | default-frozen     | new token accounts start frozen (DefaultAccountState)               |
+  | close-authority    | someone can close the mint (MintCloseAuthority)                     |
+  | pausable           | someone can pause every transfer                                    |
+
+A risk only counts while its authority is set, a transfer fee of 0 or an extension whose authority was revoked isn't
+reported. A risk isn't a verdict, USDC has a freeze authority, it's what to look at before trading a token you don't
+know. Reading it takes the mint account and nothing else, one getMultipleAccounts for any number of mints.
+*/
+
+// Token-2022 extension types the safety inspection reads, the others in transfer_hook.go and token_metadata.go.
+const (
+	extensionTypeTransferFeeConfig   = 1
+	extensionTypeMintCloseAuthority  = 3
+	extensionTypeDefaultAccountState = 6
+	extensionTypeNonTransferable     = 9
+	extensionTypePermanentDelegate   = 12
+	extensionTypePausable            = 26
+)
+
+// accountStateFrozen is DefaultAccountState's value for accounts that start frozen.
+const accountStateFrozen = 2
+
+const (
+	riskMintAuthority     = "mint-authority"
+	riskFreezeAuthority   = "freeze-authority"
+	riskTransferFee       = "transfer-fee"
+	riskPermanentDelegate = "permanent-delegate"
+	riskTransferHook      = "transfer-hook"
+	riskNonTransferable   = "non-transferable"
+	riskDefaultFrozen     = "default-frozen"
+	riskCloseAuthority    = "close-authority"
+	riskPausable          = "pausable"
+)
+
+var tokenRiskNames = []string{riskMintAuthority, riskFreezeAuthority, riskTransferFee, riskPermanentDelegate, riskTransferHook,
+	riskNonTransferable, riskDefaultFrozen, riskCloseAuthority, riskPausable}
+
+// tokenRisk is something a mint lets someone do to holders, detail says who or how much.
+type tokenRisk struct {
+	Name   string `json:"name"`
+	Detail string `json:"detail"`
+}
+
+// tokenSafety is what the inspection found in a mint.
+type tokenSafety struct {
+	mint  *mintAccount
+	risks []tokenRisk
+}
+
+func (ts tokenSafety) has(name string) bool {
+	for _, r := range ts.risks {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (ts tokenSafety) String() string {
+	if len(ts.risks) == 0 {
+		return "no risks found"
+	}
+	out := ""
+	for i, r := range ts.risks {
+		if i > 0 {
+			out += ", "
+		}
+		out += r.Name + " (" + r.Detail + ")"
+	}
+	return out
+}
+
+// extensionAuthority reads the optional authority at the start of an extension's value, nil when it's unset. Unlike
+// the base layout's COption, Token-2022 extensions keep an all zero key for none.
+func extensionAuthority(value []byte) *solana.PublicKey {
+	if len(value) < 32 || allZero(value[:32]) {
+		return nil
+	}
+	pk := solana.PublicKeyFromBytes(value[:32])
+	return &pk
+}
+
+// assessMint inspects a mint account's data, program is the token program that owns it.
+func assessMint(address, program solana.PublicKey, data []byte) (tokenSafety, error) {
+	mint, err := decodeMintAccount(data)
+	if err != nil {
+		return tokenSafety{}, err
+	}
+	mint.Address, mint.Program = address, program
+	ts := tokenSafety{mint: mint}
+	add := func(name, detail string) { ts.risks = append(ts.risks, tokenRisk{Name: name, Detail: detail}) }
+	if mint.MintAuthority != nil {
+		add(riskMintAuthority, describeAuthority(mint.MintAuthority))
+	}
+	if mint.FreezeAuthority != nil {
+		add(riskFreezeAuthority, describeAuthority(mint.FreezeAuthority))
+	}
+	if !program.Equals(solana.Token2022ProgramID) || len(data) <= baseAccountLen {
+		return ts, nil
+	}
+	tlv, err := token2022TLVRegion(data)
+	if err != nil {
+		return tokenSafety{}, err
+	}
+	r := binaryReader{b: tlv}
+	for r.remaining() >= 4 {
+		typ, _ := r.le16()
+		if typ == extensionTypeUninitialized {
+			break
+		}
+		length, _ := r.le16()
+		value, ok := r.bytes(int(length))
+		if !ok {
+			return tokenSafety{}, fmt.Errorf("malformed token2022 TLV: length %d exceeds remaining %d", length, r.remaining())
+		}
+		switch typ {
+		case extensionTypeTransferFeeConfig:
+			// Two authorities, the withheld amount, then the older and the newer fee (epoch, maximum fee, basis points).
+			if len(value) < 108 {
+				return tokenSafety{}, fmt.Errorf("transfer fee extension is %d bytes, want 108", len(value))
+			}
+			bps, maxFee := binary.LittleEndian.Uint16(value[106:108]), binary.LittleEndian.Uint64(value[98:106])
+			if bps > 0 {
+				add(riskTransferFee, fmt.Sprintf("%s per transfer, at most %s", pctString(big.NewRat(int64(bps), 10_000)), fmtAmount(new(big.Int).SetUint64(maxFee), mint.Decimals)))
+			}
+		case extensionTypePermanentDelegate:
+			if delegate := extensionAuthority(value); delegate != nil {
+				add(riskPermanentDelegate, describeAuthority(delegate))
+			}
+		case extensionTypeTransferHook:
+			if len(value) == 64 && !allZero(value[32:64]) {
+				add(riskTransferHook, "program "+Addr(solana.PublicKeyFromBytes(value[32:64]).String()).String())
+			}
+		case extensionTypeNonTransferable:
+			add(riskNonTransferable, "can't be transferred")
+		case extensionTypeDefaultAccountState:
+			if len(value) >= 1 && value[0] == accountStateFrozen {
+				add(riskDefaultFrozen, "new accounts start frozen")
+			}
+		case extensionTypeMintCloseAuthority:
+			if authority := extensionAuthority(value); authority != nil {
+				add(riskCloseAuthority, describeAuthority(authority))
+			}
+		case extensionTypePausable:
+			if authority := extensionAuthority(value); authority != nil {
+				detail := describeAuthority(authority)
+				if len(value) >= 33 && value[32] != 0 {
+					detail += ", paused now"
+				}
+				add(riskPausable, detail)
+			}
+		}
+	}
+	return ts, nil
+}
+
+// inspectTokenSafety reads mints in one getMultipleAccounts and inspects each, in the order given. It reads at confirmed
+// commitment, a mint made a moment ago for a new pool isn't finalized yet.
+func inspectTokenSafety(ctx context.Context, client *rpc.Client, mints ...solana.PublicKey) ([]tokenSafety, error) {
+	res, err := client.GetMultipleAccountsWithOpts(ctx, mints, &rpc.GetMultipleAccountsOpts{Encoding: solana.EncodingBase64, Commitment: rpc.CommitmentConfirmed})
+	if err != nil {
+		return nil, fmt.Errorf("rpc call getMultipleAccounts for mints failed: %w", classifyRPC(err))
+	}
+	if res == nil || len(res.Value) != len(mints) {
+		return nil, errors.New("rpc call getMultipleAccounts for mints returned the wrong number of accounts")
+	}
+	out := make([]tokenSafety, len(mints))
+	for i, acc := range res.Value {
+		if acc == nil {
+			return nil, classify(ErrAccountMissing, fmt.Errorf("mint %s doesn't exist", Addr(mints[i].String())))
+		}
+		if !acc.Owner.Equals(solana.TokenProgramID) && !acc.Owner.Equals(solana.Token2022ProgramID) {
+			return nil, fmt.Errorf("%s isn't a token mint, it's owned by %s", Addr(mints[i].String()), Addr(acc.Owner.String()))
+		}
+		ts, err := assessMint(mints[i], acc.Owner, acc.Data.GetBinary())
+		if err != nil {
+			return nil, fmt.Errorf("inspecting mint %s failed: %w", Addr(mints[i].String()), err)
+		}
+		out[i] = ts
+	}
+	return out, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+// token2022MintData is a Token-2022 mint with the given TLV entries after the base layout.
+func token2022MintData(mintAuthority, freezeAuthority *solana.PublicKey, entries ...[]byte) []byte {
+	data := append(mintData(1_000_000, 6, mintAuthority, freezeAuthority), make([]byte, baseAccountLen-baseMintLen)...)
+	data = append(data, accountTypeMint)
+	for _, e := range entries {
+		data = append(data, e...)
+	}
+	return data
+}
+
+func transferFeeValue(maxFee uint64, bps uint16) []byte {
+	value := make([]byte, 108)
+	binary.LittleEndian.PutUint64(value[98:106], maxFee)
+	binary.LittleEndian.PutUint16(value[106:108], bps)
+	return value
+}
+
+func TestAssessMint(t *testing.T) {
+	mint := solana.NewWallet().PublicKey()
+	authority := solana.NewWallet().PublicKey()
+	hook := solana.NewWallet().PublicKey()
+
+	clean, err := assessMint(mint, solana.TokenProgramID, mintData(1_000_000, 6, nil, nil))
+	if err != nil || len(clean.risks) != 0 {
+		t.Fatalf("a revoked SPL mint: %v, %+v", err, clean.risks)
+	}
+	if clean.String() != "no risks found" {
+		t.Errorf("clean mint reads %q", clean.String())
+	}
+
+	spl, err := assessMint(mint, solana.TokenProgramID, mintData(1_000_000, 6, &authority, &authority))
+	if err != nil || !spl.has(riskMintAuthority) || !spl.has(riskFreezeAuthority) || len(spl.risks) != 2 {
+		t.Fatalf("an SPL mint with both authorities: %v, %+v", err, spl.risks)
+	}
+
+	data := token2022MintData(nil, nil,
+		tlvEntry(extensionTypeTransferFeeConfig, transferFeeValue(5_000_000, 250)),
+		tlvEntry(extensionTypePermanentDelegate, authority.Bytes()),
+		tlvEntry(extensionTypeTransferHook, append(make([]byte, 32), hook.Bytes()...)),
+		tlvEntry(extensionTypeDefaultAccountState, []byte{accountStateFrozen}),
+		tlvEntry(extensionTypeNonTransferable, nil),
+		tlvEntry(extensionTypeMintCloseAuthority, authority.Bytes()),
+		tlvEntry(extensionTypePausable, append(authority.Bytes(), 1)),
+	)
+	ts, err := assessMint(mint, solana.Token2022ProgramID, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{riskTransferFee, riskPermanentDelegate, riskTransferHook, riskDefaultFrozen, riskNonTransferable, riskCloseAuthority, riskPausable} {
+		if !ts.has(name) {
+			t.Errorf("missing %s in %s", name, ts)
+		}
+	}
+	if ts.has(riskMintAuthority) || ts.has(riskFreezeAuthority) {
+		t.Errorf("revoked authorities reported: %s", ts)
+	}
+	for _, want := range []string{"2.5% per transfer, at most 5", "paused now"} {
+		if !strings.Contains(ts.String(), want) {
+			t.Errorf("%q is missing %q", ts.String(), want)
+		}
+	}
+
+	// Extensions that are there but off aren't risks.
+	off, err := assessMint(mint, solana.Token2022ProgramID, token2022MintData(nil, nil,
+		tlvEntry(extensionTypeTransferFeeConfig, transferFeeValue(0, 0)),
+		tlvEntry(extensionTypePermanentDelegate, make([]byte, 32)),
+		tlvEntry(extensionTypeTransferHook, make([]byte, 64)),
+		tlvEntry(extensionTypeDefaultAccountState, []byte{1}),
+		tlvEntry(extensionTypeMetadataPointer, make([]byte, 64)),
+	))
+	if err != nil || len(off.risks) != 0 {
+		t.Errorf("extensions switched off: %v, %+v", err, off.risks)
+	}
+
+	bad := token2022MintData(nil, nil, tlvEntry(extensionTypePermanentDelegate, authority.Bytes()))
+	if _, err := assessMint(mint, solana.Token2022ProgramID, bad[:len(bad)-4]); err == nil {
+		t.Error("a truncated TLV entry decoded")
+	}
+}
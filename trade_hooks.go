@@ -0,0 +1,430 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+/*
+NOTE(@hadydotai): Trade hooks.
+
+Everyone who runs this against real money ends up wanting a check of their own: never touch these mints, never trade
+more than this much a day, tell this other system about every fill. None of that belongs in the client for everybody,
+and a fork to add it goes stale. So there are three points every swap passes through where a hook gets a say:
+
+  - PreQuote: the intent and the pool, before it's quoted. An error refuses the quote and shows in its place
+  - PreSend: the quoted swap, what it can pay at most and get at least, right before it's signed. An error refuses it,
+    nothing is sent
+  - PostConfirm: the swap and how it landed (or that we stopped waiting on it). Nothing can be undone by then, an
+    error is only logged
+
+A hook is a TradeHook registered under a name with registerTradeHook, from an init() in a file of its own, and
+turned on with -hook name or -hook name=config, as many as needed. They run in the order they were turned on and the
+first refusal wins. Embed noTradeHook for the points a hook doesn't care about.
+
+Every path that sends a quoted swap goes through them: the TUI, -no-tui, split and chunked orders, Jupiter routes,
+the engines (limit, stop, dca, serve) and executing a review bundle. A bundle's entries reach the hooks as reviewed,
+paying at most and getting at least what the reviewer saw, and a refusal stops the bundle there, nothing after it is
+sent.
+
+Two come built in. denylist=<file> refuses any swap touching a mint in the file (one per line, # comments).
+daily-limit=<amount>:<symbol> caps how much of that token goes through swaps each UTC day, counted from what actually
+landed and kept in the config dir so a restart doesn't reset it. A swap that doesn't pay or receive the token isn't
+counted, so the limit goes in a token every trade goes through, USDC or SOL. The same hook and ledger keep
+-max-day-usd, the cap in dollars (see spend_limits.go), so there's one idea of what went through today.
+*/
+
+// TradeHook is a check or side effect around every swap, see registerTradeHook.
+type TradeHook interface {
+	PreQuote(ctx context.Context, q hookQuote) error
+	PreSend(ctx context.Context, s hookSwap) error
+	PostConfirm(ctx context.Context, s hookSwap, summary txSummaryData) error
+}
+
+// hookQuote is an intent about to be quoted.
+type hookQuote struct {
+	Pool        solana.PublicKey
+	Mints       [2]solana.PublicKey
+	Symbols     SymbolMapping
+	Instruction *IntentInstruction
+}
+
+// hookSwap is a quoted swap about to be sent, with the worst it can do under its slippage guard.
+type hookSwap struct {
+	Intent   string
+	Wallet   solana.PublicKey // the payer
+	Pool     solana.PublicKey // zero for a Jupiter route
+	TokenIn  SwapLeg
+	TokenOut SwapLeg
+	MaxIn    *big.Int
+	MinOut   *big.Int
+	Symbols  SymbolMapping
+	Approved bool // a countersigned approval request, -approval-above has had its say (see approval.go)
+}
+
+func newHookSwap(intent *CPIntent, symm SymbolMapping, wallet solana.PublicKey) hookSwap {
+	s := hookSwap{Intent: intent.String(), Wallet: wallet, Pool: intent.Pool.Address, TokenIn: intent.TokenIn, TokenOut: intent.TokenOut, Symbols: symm}
+	switch intent.SwapKind {
+	case SwapKindBaseInput:
+		s.MaxIn, s.MinOut = intent.Amounts.KnownAmount, intent.Amounts.MinAmountOut
+	case SwapKindBaseOutput:
+		s.MaxIn, s.MinOut = intent.Amounts.MaxAmountIn, intent.Amounts.KnownAmount
+	}
+	return s
+}
+
+// noTradeHook lets everything through, embed it and override what matters.
+type noTradeHook struct{}
+
+func (noTradeHook) PreQuote(context.Context, hookQuote) error                  { return nil }
+func (noTradeHook) PreSend(context.Context, hookSwap) error                    { return nil }
+func (noTradeHook) PostConfirm(context.Context, hookSwap, txSummaryData) error { return nil }
+
+// tradeHookFactory makes a hook from what followed "=" in -hook, empty when nothing did.
+type tradeHookFactory func(config string) (TradeHook, error)
+
+var tradeHookFactories = map[string]tradeHookFactory{
+	"denylist":    newDenylistHook,
+	"daily-limit": newDailyLimitHook,
+}
+
+// registerTradeHook makes a hook available to -hook under name. Call it from an init().
+func registerTradeHook(name string, factory tradeHookFactory) {
+	if _, ok := tradeHookFactories[name]; ok {
+		panic(fmt.Sprintf("trade hook %q registered twice", name))
+	}
+	tradeHookFactories[name] = factory
+}
+
+type namedTradeHook struct {
+	name string
+	hook TradeHook
+}
+
+// tradeHooks are the hooks turned on with -hook, in order.
+var tradeHooks []namedTradeHook
+
+func tradeHookNames() []string {
+	names := make([]string, 0, len(tradeHookFactories))
+	for name := range tradeHookFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func addTradeHookFlags(fs *flag.FlagSet) {
+	fs.Func("hook", fmt.Sprintf("Turn on a trade hook, name or name=config, repeatable. One of [%s]", strings.Join(tradeHookNames(), ", ")), func(s string) error {
+		name, config, _ := strings.Cut(s, "=")
+		factory, ok := tradeHookFactories[name]
+		if !ok {
+			return fmt.Errorf("unknown trade hook %q, expected one of [%s]", name, strings.Join(tradeHookNames(), ", "))
+		}
+		hook, err := factory(config)
+		if err != nil {
+			return fmt.Errorf("trade hook %s: %w", name, err)
+		}
+		tradeHooks = append(tradeHooks, namedTradeHook{name: name, hook: hook})
+		return nil
+	})
+}
+
+// hookRefusal is a hook saying no to a quote or a send.
+type hookRefusal struct {
+	hook string
+	err  error
+}
+
+func (e *hookRefusal) Error() string {
+	return fmt.Sprintf("refused by the %s hook: %v", e.hook, e.err)
+}
+
+func (e *hookRefusal) Unwrap() error {
+	return e.err
+}
+
+// turnOnTradeHook puts hook ahead of the ones turned on with -hook, for checks the client's own flags turn on. A hook
+// already on stays where it is.
+func turnOnTradeHook(name string, hook TradeHook) {
+	for _, h := range tradeHooks {
+		if h.hook == hook {
+			return
+		}
+	}
+	tradeHooks = append([]namedTradeHook{{name: name, hook: hook}}, tradeHooks...)
+}
+
+func preQuoteHooks(ctx context.Context, q hookQuote) error {
+	for _, h := range tradeHooks {
+		if err := h.hook.PreQuote(ctx, q); err != nil {
+			return &hookRefusal{hook: h.name, err: err}
+		}
+	}
+	return nil
+}
+
+func preSendHooks(ctx context.Context, s hookSwap) error {
+	for _, h := range tradeHooks {
+		if err := h.hook.PreSend(ctx, s); err != nil {
+			return &hookRefusal{hook: h.name, err: err}
+		}
+	}
+	return nil
+}
+
+func postConfirmHooks(ctx context.Context, s hookSwap, summary txSummaryData) {
+	for _, h := range tradeHooks {
+		if err := h.hook.PostConfirm(ctx, s, summary); err != nil {
+			log.Printf("warning: %s hook after %s: %v", h.name, Addr(summary.Signature.String()), err)
+		}
+	}
+}
+
+// denylistHook refuses swaps touching any of its mints.
+type denylistHook struct {
+	noTradeHook
+	denied map[solana.PublicKey]bool
+}
+
+func newDenylistHook(path string) (TradeHook, error) {
+	if path == "" {
+		return nil, errors.New("takes the file of mints to deny, denylist=<file>")
+	}
+	denied, err := readKeyList(path)
+	if err != nil {
+		return nil, err
+	}
+	return &denylistHook{denied: denied}, nil
+}
+
+func (h *denylistHook) check(symm SymbolMapping, mints ...solana.PublicKey) error {
+	for _, mint := range mints {
+		if h.denied[mint] {
+			return fmt.Errorf("%s (%s) is on the denylist", symm.SymFrom(mint), Addr(mint.String()))
+		}
+	}
+	return nil
+}
+
+func (h *denylistHook) PreQuote(_ context.Context, q hookQuote) error {
+	return h.check(q.Symbols, q.Mints[:]...)
+}
+
+func (h *denylistHook) PreSend(_ context.Context, s hookSwap) error {
+	return h.check(s.Symbols, s.TokenIn.Mint, s.TokenOut.Mint)
+}
+
+// dailyLimitHook caps how much of one token, or how many dollars for -max-day-usd, goes through swaps each UTC day.
+type dailyLimitHook struct {
+	noTradeHook
+	symbol string // dailyLimitUSD for a cap in dollars
+	limit  *big.Rat
+	path   string
+	now    func() time.Time
+	// price values swaps for a cap in dollars.
+	price func(ctx context.Context, mint solana.PublicKey, sym string) (usdPrice, time.Duration, error)
+}
+
+// dailyLimitUSD is the symbol of a cap in dollars, kept per wallet.
+const dailyLimitUSD = "USD"
+
+// dailyLedgerMu guards the ledger file, every daily-limit hook keeps its token in the same one.
+var dailyLedgerMu sync.Mutex
+
+// dailyLedger is what went through swaps on a day, by mint in whole tokens, and by "usd:<wallet>" in dollars.
+type dailyLedger struct {
+	Day   string            `json:"day"`
+	Spent map[string]string `json:"spent"`
+}
+
+// dailyTally is what a swap moves of a daily limit, under its key in the ledger.
+type dailyTally struct {
+	key      string
+	amount   *big.Rat
+	decimals int
+}
+
+func defaultDailyLedgerPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "daily-limit.json"
+	}
+	return filepath.Join(dir, "raydium-client", "daily-limit.json")
+}
+
+func newDailyLimitHook(config string) (TradeHook, error) {
+	amount, symbol, ok := strings.Cut(config, ":")
+	if !ok || symbol == "" {
+		return nil, errors.New("takes the amount and the token, daily-limit=<amount>:<symbol>, e.g. 1000:USDC")
+	}
+	if normalizeSymbol(symbol) == dailyLimitUSD {
+		return nil, errors.New("a cap in dollars is -max-day-usd, it's kept per wallet and can be overridden")
+	}
+	limit, ok := new(big.Rat).SetString(amount)
+	if !ok || limit.Sign() <= 0 {
+		return nil, fmt.Errorf("%q isn't a positive amount", amount)
+	}
+	return &dailyLimitHook{symbol: normalizeSymbol(symbol), limit: limit, path: defaultDailyLedgerPath(), now: time.Now}, nil
+}
+
+// newDailyUSDLimit caps the dollars each wallet swaps in a UTC day, valued at price.
+func newDailyUSDLimit(limit *big.Rat, price func(context.Context, solana.PublicKey, string) (usdPrice, time.Duration, error)) *dailyLimitHook {
+	return &dailyLimitHook{symbol: dailyLimitUSD, limit: limit, path: defaultDailyLedgerPath(), now: time.Now, price: price}
+}
+
+// tally is how much of the limit s moves, nil when it doesn't touch the limited token. paid and received are what it
+// moved, nil for what it can move at worst. A cap in dollars refuses what it can't value.
+func (h *dailyLimitHook) tally(ctx context.Context, s hookSwap, paid, received *big.Int) (*dailyTally, error) {
+	if h.symbol == dailyLimitUSD {
+		usd, err := usdNotional(ctx, h.price, s, paid, received)
+		if err != nil {
+			return nil, fmt.Errorf("can't value the swap for the daily limit: %w", err)
+		}
+		return &dailyTally{key: "usd:" + s.Wallet.String(), amount: usd, decimals: 2}, nil
+	}
+	mint, ok := s.Symbols.MaybeMintFromSym(h.symbol)
+	if !ok {
+		return nil, nil
+	}
+	var (
+		raw      *big.Int
+		decimals uint8
+	)
+	switch {
+	case s.TokenIn.Mint.Equals(mint):
+		raw, decimals = cmp.Or(paid, s.MaxIn), s.TokenIn.Decimals
+	case s.TokenOut.Mint.Equals(mint):
+		raw, decimals = cmp.Or(received, s.MinOut), s.TokenOut.Decimals
+	}
+	if raw == nil {
+		return nil, nil
+	}
+	return &dailyTally{key: mint.String(), amount: new(big.Rat).SetFrac(raw, fixedPointScale(decimals)), decimals: int(decimals)}, nil
+}
+
+// show is amount in the limit's denomination.
+func (h *dailyLimitHook) show(amount *big.Rat, decimals int) string {
+	if h.symbol == dailyLimitUSD {
+		return fmtUSD(amount)
+	}
+	return trimDecimal(amount.FloatString(decimals)) + " " + h.symbol
+}
+
+// ledger is today's ledger, a ledger from an earlier day is a fresh one. Callers hold dailyLedgerMu.
+func (h *dailyLimitHook) ledger() (*dailyLedger, error) {
+	today := &dailyLedger{Day: h.now().UTC().Format(time.DateOnly), Spent: map[string]string{}}
+	raw, err := os.ReadFile(h.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return today, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", h.path, err)
+	}
+	var l dailyLedger
+	if err := json.Unmarshal(raw, &l); err != nil {
+		return nil, fmt.Errorf("%s is corrupt: %w", h.path, err)
+	}
+	if l.Day != today.Day || l.Spent == nil {
+		return today, nil
+	}
+	return &l, nil
+}
+
+func spentOn(l *dailyLedger, key string) *big.Rat {
+	spent, ok := new(big.Rat).SetString(l.Spent[key])
+	if !ok {
+		return new(big.Rat)
+	}
+	return spent
+}
+
+// over is why s would take today over the limit, empty when it fits.
+func (h *dailyLimitHook) over(ctx context.Context, s hookSwap) (string, error) {
+	t, err := h.tally(ctx, s, nil, nil)
+	if err != nil || t == nil {
+		return "", err
+	}
+	dailyLedgerMu.Lock()
+	defer dailyLedgerMu.Unlock()
+	l, err := h.ledger()
+	if err != nil {
+		return "", err
+	}
+	spent := spentOn(l, t.key)
+	if total := new(big.Rat).Add(spent, t.amount); total.Cmp(h.limit) <= 0 {
+		return "", nil
+	}
+	return fmt.Sprintf("up to %s more would go over today's %s limit, %s already went through",
+		h.show(t.amount, t.decimals), h.show(h.limit, t.decimals), h.show(spent, t.decimals)), nil
+}
+
+func (h *dailyLimitHook) PreSend(ctx context.Context, s hookSwap) error {
+	reason, err := h.over(ctx, s)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		return errors.New(reason)
+	}
+	return nil
+}
+
+func (h *dailyLimitHook) PostConfirm(ctx context.Context, s hookSwap, summary txSummaryData) error {
+	if summary.Status == "failed" {
+		return nil
+	}
+	// A swap we stopped waiting on may well land, it's counted at its worst.
+	paid, received := summary.PaidAmount, summary.ReceivedAmount
+	if summary.Status == "pending" {
+		paid, received = nil, nil
+	}
+	t, err := h.tally(ctx, s, paid, received)
+	if err != nil || t == nil {
+		return err
+	}
+	dailyLedgerMu.Lock()
+	defer dailyLedgerMu.Unlock()
+	l, err := h.ledger()
+	if err != nil {
+		return err
+	}
+	l.Spent[t.key] = trimDecimal(new(big.Rat).Add(spentOn(l, t.key), t.amount).FloatString(t.decimals))
+	return h.save(l)
+}
+
+func (h *dailyLimitHook) save(l *dailyLedger) error {
+	raw, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
+		return fmt.Errorf("writing %s: %w", h.path, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(h.path), filepath.Base(h.path)+".*")
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", h.path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(raw, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing %s: %w", h.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing %s: %w", h.path, err)
+	}
+	return os.Rename(tmp.Name(), h.path)
+}
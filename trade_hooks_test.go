@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+func hookTestSymbols() (SymbolMapping, solana.PublicKey, solana.PublicKey) {
+	sol, usdc := snapshotKey(90), snapshotKey(91)
+	return SymbolMapping{
+		mintToSymbol: map[string]string{sol.String(): "SOL", usdc.String(): "USDC"},
+		symbolToMint: map[string]solana.PublicKey{"SOL": sol, "USDC": usdc},
+		unresolved:   map[string]struct{}{},
+	}, sol, usdc
+}
+
+func TestDenylistHook(t *testing.T) {
+	symm, sol, usdc := hookTestSymbols()
+	path := filepath.Join(t.TempDir(), "denylist")
+	if err := os.WriteFile(path, []byte("# rugs\n"+usdc.String()+"  # this one\n\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	hook, err := newDenylistHook(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.PreQuote(t.Context(), hookQuote{Mints: [2]solana.PublicKey{sol, usdc}, Symbols: symm}); err == nil || !strings.Contains(err.Error(), "USDC") {
+		t.Errorf("quoting on a denied mint's pool: %v", err)
+	}
+	if err := hook.PreSend(t.Context(), hookSwap{TokenIn: SwapLeg{Mint: sol}, TokenOut: SwapLeg{Mint: snapshotKey(92)}, Symbols: symm}); err != nil {
+		t.Errorf("a swap clear of the denylist was refused: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("not-a-mint\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newDenylistHook(path); err == nil || !strings.Contains(err.Error(), ":1:") {
+		t.Errorf("a bad line should say where it is, got %v", err)
+	}
+}
+
+func TestDailyLimitHook(t *testing.T) {
+	symm, sol, usdc := hookTestSymbols()
+	h, err := newDailyLimitHook("2000:usdc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook := h.(*dailyLimitHook)
+	hook.path = filepath.Join(t.TempDir(), "daily-limit.json")
+	now := time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC)
+	hook.now = func() time.Time { return now }
+
+	usdcLeg, solLeg := SwapLeg{Mint: usdc, Decimals: 6}, SwapLeg{Mint: sol, Decimals: 9}
+	buy := func(maxUSDC int64) hookSwap {
+		return hookSwap{TokenIn: usdcLeg, TokenOut: solLeg, MaxIn: big.NewInt(maxUSDC * 1_000_000), MinOut: big.NewInt(1), Symbols: symm}
+	}
+	ctx := t.Context()
+	if err := hook.PreSend(ctx, buy(1500)); err != nil {
+		t.Fatal(err)
+	}
+	// It paid less than its worst, that's what's counted.
+	if err := hook.PostConfirm(ctx, buy(1500), txSummaryData{Status: "confirmed", PaidAmount: big.NewInt(1_450_000_000)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.PostConfirm(ctx, buy(1500), txSummaryData{Status: "failed", PaidAmount: big.NewInt(1_500_000_000)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.PreSend(ctx, buy(550)); err != nil {
+		t.Errorf("1450 + 550 is the limit, not over it: %v", err)
+	}
+	if err := hook.PreSend(ctx, buy(551)); err == nil || !strings.Contains(err.Error(), "1450 USDC already") {
+		t.Errorf("going over the limit: %v", err)
+	}
+	// A swap that doesn't pay or receive USDC isn't counted.
+	other := hookSwap{TokenIn: solLeg, TokenOut: SwapLeg{Mint: snapshotKey(92)}, MaxIn: big.NewInt(1e12), MinOut: big.NewInt(1), Symbols: symm}
+	if err := hook.PreSend(ctx, other); err != nil {
+		t.Errorf("a swap without USDC was refused: %v", err)
+	}
+
+	// The next day starts over, and a swap that didn't confirm counts at its worst.
+	now = now.Add(2 * time.Hour)
+	sell := hookSwap{TokenIn: solLeg, TokenOut: usdcLeg, MaxIn: big.NewInt(1e10), MinOut: big.NewInt(1_900_000_000), Symbols: symm}
+	if err := hook.PreSend(ctx, sell); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.PostConfirm(ctx, sell, txSummaryData{Status: "pending", ReceivedAmount: new(big.Int)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.PreSend(ctx, buy(101)); err == nil {
+		t.Error("the pending swap wasn't counted")
+	}
+}
+
+func TestTradeHookFlag(t *testing.T) {
+	defer func(saved []namedTradeHook) { tradeHooks = saved }(tradeHooks)
+	tradeHooks = nil
+	var calls []string
+	registerTradeHook("test-refuse", func(config string) (TradeHook, error) {
+		return refusingHook{reason: config, calls: &calls}, nil
+	})
+	defer delete(tradeHookFactories, "test-refuse")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(&strings.Builder{})
+	addTradeHookFlags(fs)
+	if err := fs.Parse([]string{"-hook", "test-refuse=too risky", "-hook", "daily-limit=5:SOL"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(tradeHooks) != 2 || tradeHooks[0].name != "test-refuse" || tradeHooks[1].name != "daily-limit" {
+		t.Fatalf("turned on %+v", tradeHooks)
+	}
+	err := preSendHooks(context.Background(), hookSwap{})
+	var refusal *hookRefusal
+	if !errors.As(err, &refusal) || err.Error() != "refused by the test-refuse hook: too risky" {
+		t.Errorf("refusal %v", err)
+	}
+	if err := preQuoteHooks(context.Background(), hookQuote{}); err != nil {
+		t.Errorf("the embedded no-op PreQuote refused: %v", err)
+	}
+	postConfirmHooks(context.Background(), hookSwap{}, txSummaryData{})
+	if strings.Join(calls, ",") != "PreSend,PostConfirm" {
+		t.Errorf("calls %v", calls)
+	}
+
+	for _, bad := range []string{"nope", "daily-limit=5", "daily-limit=-1:SOL", "daily-limit=100:usd", "denylist"} {
+		if err := fs.Parse([]string{"-hook", bad}); err == nil {
+			t.Errorf("-hook %s was taken", bad)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a name twice didn't panic")
+		}
+	}()
+	registerTradeHook("denylist", newDenylistHook)
+}
+
+type refusingHook struct {
+	noTradeHook
+	reason string
+	calls  *[]string
+}
+
+func (h refusingHook) PreSend(context.Context, hookSwap) error {
+	*h.calls = append(*h.calls, "PreSend")
+	return errors.New(h.reason)
+}
+
+func (h refusingHook) PostConfirm(context.Context, hookSwap, txSummaryData) error {
+	*h.calls = append(*h.calls, "PostConfirm")
+	return nil
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	tokenprog "github.com/gagliardetto/solana-go/programs/token"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+/*
+NOTE(@hadydotai): Sending tokens.
+
+`send 5 USDC <address>` moves tokens out of the wallet to someone else's, the one thing a wallet does that isn't a
+swap. The token is named the way an intent names it: a symbol, a mint address or a prefix of one, resolved among what
+the wallet holds with the same metadata, token list and aliases the quote table uses. Only what the wallet holds can
+be sent, so that's all that's looked at, and two held tokens calling themselves USDC is an error, not a guess, a
+transfer can't be swapped back. The amount is an intent's too, a decimal amount, a percentage or `all` of the balance.
+
+SOL goes as native SOL, a system transfer, less solFeeReserve for a percentage or `all`. Anything else is a
+transfer_checked from the wallet's associated account, under whichever token program owns the mint, so the mint and
+its decimals are checked by the program as well as by us. The recipient gets it in their associated account, which
+is created (idempotently, the wallet pays its rent) when it isn't there yet unless -create-ata=false. A recipient
+that's itself a token account of the mint is sent to directly, one of another mint is refused.
+
+Token-2022 mints with a transfer fee deliver the amount less the fee, mints with a transfer hook need accounts we
+don't pass and fail in simulation, nothing is sent.
+
+The transfer is shown and has to be confirmed with y before it goes out, -yes skips the question for scripts.
+*/
+
+// ataCreateIdempotent is the associated token account program's CreateIdempotent, which succeeds when the account
+// already exists instead of failing the transaction.
+const ataCreateIdempotent = 1
+
+// transferToken is what's being sent, native SOL when native is set.
+type transferToken struct {
+	mint     solana.PublicKey
+	program  solana.PublicKey
+	decimals uint8
+	symbol   string
+	native   bool
+}
+
+// transferPlan is a transfer ready to be confirmed and sent.
+type transferPlan struct {
+	token        transferToken
+	amount       *big.Int
+	balance      *big.Int // what the wallet could send of the token
+	from         solana.PublicKey
+	to           solana.PublicKey // the recipient's wallet, or the token account when that's what was given
+	source       solana.PublicKey // the wallet's token account, zero for SOL
+	destination  solana.PublicKey // the recipient's token account, zero for SOL
+	createATA    bool             // destination doesn't exist yet and is created
+	instructions []solana.Instruction
+}
+
+// associatedTokenAddress is owner's associated account for mint under program, which is part of the derivation.
+func associatedTokenAddress(owner, mint, program solana.PublicKey) (solana.PublicKey, error) {
+	ata, _, err := solana.FindProgramAddress([][]byte{owner[:], program[:], mint[:]}, solana.SPLAssociatedTokenAccountProgramID)
+	return ata, err
+}
+
+// createATAInstruction creates owner's associated account for mint under program, paid by payer, unless it's there.
+func createATAInstruction(payer, ata, owner, mint, program solana.PublicKey) solana.Instruction {
+	return solana.NewInstruction(solana.SPLAssociatedTokenAccountProgramID, solana.AccountMetaSlice{
+		solana.Meta(payer).SIGNER().WRITE(),
+		solana.Meta(ata).WRITE(),
+		solana.Meta(owner),
+		solana.Meta(mint),
+		solana.Meta(solana.SystemProgramID),
+		solana.Meta(program),
+	}, []byte{ataCreateIdempotent})
+}
+
+// transferCheckedInstruction moves amount of mint from source to destination under program, TransferChecked is the
+// same instruction in both token programs.
+func transferCheckedInstruction(program, source, mint, destination, owner solana.PublicKey, amount uint64, decimals uint8) (solana.Instruction, error) {
+	ix := tokenprog.NewTransferCheckedInstruction(amount, decimals, source, mint, destination, owner, nil).Build()
+	data, err := ix.Data()
+	if err != nil {
+		return nil, err
+	}
+	return solana.NewInstruction(program, ix.Accounts(), data), nil
+}
+
+// pickHeldMint is the mint token names among held, the mints the wallet holds, named in symm. A whole mint address is
+// taken as is, a symbol has to name exactly one of them.
+func pickHeldMint(symm SymbolMapping, held []solana.PublicKey, token string) (solana.PublicKey, error) {
+	if mint, err := solana.PublicKeyFromBase58(token); err == nil {
+		return mint, nil
+	}
+	sym := normalizeSymbol(token)
+	var named []string
+	for _, mint := range held {
+		if symm.SymFrom(mint) == sym {
+			named = append(named, mint.String())
+		}
+	}
+	if len(named) > 1 {
+		return solana.PublicKey{}, fmt.Errorf("you hold more than one token called %s (%s), name the one to send by its mint",
+			sym, strings.Join(named, ", "))
+	}
+	mint, ok := symm.MaybeMintFromToken(sym)
+	if !ok {
+		return solana.PublicKey{}, fmt.Errorf("the wallet holds nothing called %s, name the token by its mint or map it with `alias add`", sym)
+	}
+	return mint, nil
+}
+
+// resolveTransferToken is the token named by token, a symbol, mint or mint prefix, among what owner holds. SOL is
+// native SOL.
+func resolveTransferToken(ctx context.Context, client *rpc.Client, owner solana.PublicKey, token string) (transferToken, error) {
+	if normalizeSymbol(token) == "SOL" || token == wSOLMint.String() {
+		return transferToken{mint: wSOLMint, program: solana.SystemProgramID, decimals: 9, symbol: "SOL", native: true}, nil
+	}
+	holdings, err := walletHoldings(ctx, client, owner)
+	if err != nil {
+		return transferToken{}, err
+	}
+	held := make([]solana.PublicKey, 0, len(holdings))
+	for _, h := range holdings {
+		if !isNativeSOL(h.mint) {
+			held = append(held, h.mint)
+		}
+	}
+	symm := makeSymbolMapping(ctx, client, held)
+	userAliases.apply(symm, held...)
+	mint, err := pickHeldMint(symm, held, token)
+	if err != nil {
+		return transferToken{}, err
+	}
+	mints, err := fetchMintAccounts(ctx, client, mint)
+	if err != nil {
+		return transferToken{}, err
+	}
+	symbol := symm.SymFrom(mint)
+	if symbol == "" {
+		symbol = knownSymbol(mint)
+	}
+	if symbol == "" {
+		symbol = Addr(mint.String()).String()
+	}
+	return transferToken{mint: mint, program: mints[0].Program, decimals: mints[0].Decimals, symbol: symbol}, nil
+}
+
+// transferAmount is amount, decimal or relative to balance ("50%", "all"), in raw units of a token with decimals.
+func transferAmount(amount string, balance *big.Int, decimals uint8) (*big.Int, error) {
+	frac, relative, err := parsePercentAmount(amount)
+	if err != nil {
+		return nil, err
+	}
+	var raw *big.Int
+	if relative {
+		share := new(big.Rat).Mul(new(big.Rat).SetInt(balance), frac)
+		raw = new(big.Int).Quo(share.Num(), share.Denom())
+		if raw.Sign() == 0 {
+			return nil, fmt.Errorf("%s of the balance (%s) is nothing to send", amount, fmtAmount(balance, decimals))
+		}
+	} else if raw, err = fmtForMath(amount, decimals); err != nil {
+		return nil, err
+	}
+	if raw.Cmp(balance) > 0 {
+		return nil, fmt.Errorf("the wallet has %s to send, %s is more than that", fmtAmount(balance, decimals), fmtAmount(raw, decimals))
+	}
+	if !raw.IsUint64() {
+		return nil, fmt.Errorf("%s is more than a single transfer can move", amount)
+	}
+	return raw, nil
+}
+
+// recipientTokenAccount reports whether acc, the recipient's account as read, is a token account, and which mint it
+// holds when it is.
+func recipientTokenAccount(acc *rpc.Account) (solana.PublicKey, bool) {
+	if acc == nil || (!acc.Owner.Equals(solana.TokenProgramID) && !acc.Owner.Equals(solana.Token2022ProgramID)) {
+		return solana.PublicKey{}, false
+	}
+	data := acc.Data.GetBinary()
+	if len(data) < baseAccountLen || (len(data) > baseAccountLen && data[baseAccountLen] != accountTypeAccount) {
+		return solana.PublicKey{}, false
+	}
+	return solana.PublicKeyFromBytes(data[:32]), true
+}
+
+// planTransfer works out sending amount of token from payer to recipient.
+func planTransfer(ctx context.Context, client *rpc.Client, payer, recipient solana.PublicKey, token transferToken, amount string, createATA bool) (*transferPlan, error) {
+	if recipient.Equals(payer) {
+		return nil, errors.New("the recipient is the wallet itself")
+	}
+	plan := &transferPlan{token: token, from: payer, to: recipient}
+	recipientAcc, err := accountOrNil(ctx, client, recipient)
+	if err != nil {
+		return nil, err
+	}
+	if token.native {
+		if _, ok := recipientTokenAccount(recipientAcc); ok {
+			return nil, fmt.Errorf("%s is a token account, SOL goes to a wallet", recipient)
+		}
+		native, err := client.GetBalance(ctx, payer, rpc.CommitmentConfirmed)
+		if err != nil {
+			return nil, fmt.Errorf("rpc call getBalance failed: %w", err)
+		}
+		plan.balance = new(big.Int)
+		if native.Value > solFeeReserve {
+			plan.balance.SetUint64(native.Value - solFeeReserve)
+		}
+		if plan.amount, err = transferAmount(amount, plan.balance, token.decimals); err != nil {
+			return nil, err
+		}
+		plan.instructions = []solana.Instruction{system.NewTransferInstruction(plan.amount.Uint64(), payer, recipient).Build()}
+		return plan, nil
+	}
+
+	if plan.source, err = associatedTokenAddress(payer, token.mint, token.program); err != nil {
+		return nil, err
+	}
+	plan.balance = new(big.Int)
+	resp, err := client.GetTokenAccountBalance(ctx, plan.source, rpc.CommitmentConfirmed)
+	switch {
+	case err != nil && !isAccountMissingErr(err):
+		return nil, fmt.Errorf("rpc call getTokenAccountBalance for your %s account failed: %w", token.symbol, err)
+	case err == nil && resp != nil && resp.Value != nil:
+		if _, ok := plan.balance.SetString(resp.Value.Amount, 10); !ok {
+			return nil, fmt.Errorf("token account balance is an invalid amount %q", resp.Value.Amount)
+		}
+	}
+	if plan.amount, err = transferAmount(amount, plan.balance, token.decimals); err != nil {
+		return nil, err
+	}
+
+	if mint, ok := recipientTokenAccount(recipientAcc); ok {
+		if !mint.Equals(token.mint) {
+			return nil, fmt.Errorf("%s is a token account for %s, not %s", recipient, Addr(mint.String()), token.symbol)
+		}
+		plan.destination = recipient
+	} else {
+		if plan.destination, err = associatedTokenAddress(recipient, token.mint, token.program); err != nil {
+			return nil, err
+		}
+		destAcc, err := accountOrNil(ctx, client, plan.destination)
+		if err != nil {
+			return nil, err
+		}
+		if destAcc == nil {
+			if !createATA {
+				return nil, fmt.Errorf("%s has no %s account and -create-ata=false, nothing was sent", recipient, token.symbol)
+			}
+			plan.createATA = true
+			plan.instructions = append(plan.instructions, createATAInstruction(payer, plan.destination, recipient, token.mint, token.program))
+		}
+	}
+	ix, err := transferCheckedInstruction(token.program, plan.source, token.mint, plan.destination, payer, plan.amount.Uint64(), token.decimals)
+	if err != nil {
+		return nil, err
+	}
+	plan.instructions = append(plan.instructions, ix)
+	return plan, nil
+}
+
+// accountOrNil reads address, nil when there's no account there.
+func accountOrNil(ctx context.Context, client *rpc.Client, address solana.PublicKey) (*rpc.Account, error) {
+	res, err := client.GetAccountInfoWithOpts(ctx, address, &rpc.GetAccountInfoOpts{Encoding: solana.EncodingBase64, Commitment: rpc.CommitmentConfirmed})
+	if isAccountMissingErr(err) || (err == nil && (res == nil || res.Value == nil)) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rpc call getAccountInfo for %s failed: %w", Addr(address.String()), err)
+	}
+	return res.Value, nil
+}
+
+func renderTransferPlan(plan *transferPlan) string {
+	builder := &strings.Builder{}
+	t := table.NewWriter()
+	t.SetOutputMirror(builder)
+	t.SetTitle("Transfer")
+	t.AppendHeader(table.Row{"Field", "Value"})
+	t.AppendRow(table.Row{"From", plan.from})
+	t.AppendRow(table.Row{"To", plan.to})
+	t.AppendRow(table.Row{"Amount", fmt.Sprintf("%s %s", fmtAmount(plan.amount, plan.token.decimals), plan.token.symbol)})
+	if plan.token.native {
+		t.AppendRow(table.Row{"Token", "native SOL"})
+	} else {
+		t.AppendRow(table.Row{"Mint", plan.token.mint})
+		if plan.token.program.Equals(solana.Token2022ProgramID) {
+			t.AppendRow(table.Row{"Program", "Token-2022"})
+		}
+		destination := plan.destination.String()
+		if plan.createATA {
+			destination += " (created, the wallet pays its rent)"
+		}
+		t.AppendRow(table.Row{"Recipient account", destination})
+	}
+	t.AppendRow(table.Row{"Balance after", fmt.Sprintf("%s %s", fmtAmount(new(big.Int).Sub(plan.balance, plan.amount), plan.token.decimals), plan.token.symbol)})
+	t.Render()
+	return builder.String()
+}
+
+func runSendCommand(args []string) error {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: raydium-client send [flags] <amount> <token> <recipient>\n")
+		fs.PrintDefaults()
+	}
+	nf := addNetworkFlags(fs)
+	addComputeBudgetFlags(fs)
+	var (
+		hotwalletPath = fs.String("hotwallet", "", "Path to the hotwallet the tokens are sent from")
+		createATA     = fs.Bool("create-ata", true, "Create the recipient's token account when they don't have one yet, the wallet pays its rent")
+		yes           = fs.Bool("yes", false, "Send without asking to confirm the transfer first")
+	)
+	addAmountFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, append(nf.specs(),
+		FlagSpec{Name: "hotwallet", Value: hotwalletPath, Rules: []FlagRule{NotEmpty()}},
+	))
+	if fs.NArg() != 3 {
+		return errors.New("send takes the amount, the token and the recipient, e.g. `send 5 USDC <address>`")
+	}
+	amount, token, to := fs.Arg(0), fs.Arg(1), fs.Arg(2)
+	recipient, err := solana.PublicKeyFromBase58(to)
+	if err != nil {
+		return fmt.Errorf("recipient %q isn't a base58 address: %w", to, err)
+	}
+	payer, err := solana.PrivateKeyFromSolanaKeygenFile(*hotwalletPath)
+	if err != nil {
+		return fmt.Errorf("failed to load private key from hot wallet: %w", err)
+	}
+	client := nf.connect()
+	ctx, stop := interruptContext()
+	defer stop()
+
+	quoteCtx, cancel := deadlines.forQuote(ctx)
+	resolved, err := resolveTransferToken(quoteCtx, client, payer.PublicKey(), token)
+	if err != nil {
+		cancel()
+		return err
+	}
+	plan, err := planTransfer(quoteCtx, client, payer.PublicKey(), recipient, resolved, amount, *createATA)
+	cancel()
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(os.Stdout, renderTransferPlan(plan))
+	if !*yes {
+		ok, err := promptYesNo("Send it?")
+		if err != nil {
+			return fmt.Errorf("reading the confirmation failed, pass -yes to send without asking: %w", err)
+		}
+		if !ok {
+			fmt.Println("Nothing was sent.")
+			return nil
+		}
+	}
+
+	sendCtx, cancel := deadlines.forSend(ctx)
+	defer cancel()
+	sig, err := signAndSend(sendCtx, client, payer, append(computeBudget.instructions(), plan.instructions...))
+	if err != nil {
+		return err
+	}
+	fmt.Println(explorerTxURL(*nf.network, sig))
+	status, result, err := waitForTransactionResult(sendCtx, client, sig)
+	if err != nil {
+		return fmt.Errorf("waiting for %s failed: %w", sig, err)
+	}
+	if status == "failed" {
+		failed := &txFailedError{sig: sig}
+		if result != nil && result.Meta != nil {
+			failed.txErr, failed.logs = result.Meta.Err, result.Meta.LogMessages
+		}
+		return failed
+	}
+	fmt.Printf("sent %s %s to %s\n", fmtAmount(plan.amount, plan.token.decimals), plan.token.symbol, plan.to)
+	return nil
+}
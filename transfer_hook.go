@@ -0,0 +1,399 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): Token-2022 transfer hooks.
+
+A Token-2022 mint can name a hook program that every transfer of it calls into, with accounts the hook program
+decides on. Token-2022 can't know those accounts up front, so whoever builds the transaction has to pass them along,
+and planRoute appends them to the swap instruction.
+
+That's only half of it on cp-swap today. Its transfers (transfer_from_user_to_pool_vault and
+transfer_from_pool_vault_to_user in utils/token.rs) CPI into TransferChecked with the four accounts that takes and
+none of the swap's remaining accounts, so what we append never reaches the hook. The program doesn't expect it to
+either, is_supported_mint refuses a pool for a mint with a transfer hook (NotSupportMint) unless the mint is on its
+hardcoded whitelist. A swap on such a pool fails in the hook and is reported with the program's logs like any other
+failure. The accounts go on the end regardless, Anchor ignores accounts it wasn't asked for, so they cost nothing but
+transaction size, and a program that does pass them through needs nothing else from us.
+
+The hook sits in the mint's TransferHook extension (TLV type 14), an authority[32] then the program_id[32], a program
+id of all zeroes is no hook. What the hook needs is listed in its validation account, a PDA of the hook program seeded
+with ["extra-account-metas", mint], laid out as TLV too:
+
+	 0  discriminator  [8]u8   sha256("spl-transfer-hook-interface:execute")[:8]
+	 8  length         u32
+	12  count          u32
+	16  count entries of 35 bytes, each an ExtraAccountMeta:
+	     0  discriminator   u8
+	     1  address_config  [32]u8
+	    33  is_signer       u8
+	    34  is_writable     u8
+
+An entry's discriminator says how to get to its address. 0 is the address_config itself, 1 a PDA of the hook program
+with seeds read out of address_config, 128+i a PDA of the program at account i with the same seeds, and 2 a pubkey
+read out of instruction data or an account's data. Seeds are packed one after another, each behind a tag:
+
+	1  literal            {len u8, bytes}
+	2  instruction data   {index u8, len u8}
+	3  account key        {index u8}
+	4  account data       {account index u8, data index u8, len u8}
+	0  end
+
+Account indexes count through the hook's Execute instruction, 0 source, 1 mint, 2 destination, 3 authority, 4 the
+validation account and then the extras in order, so an entry can refer to one resolved before it. The instruction
+data is the discriminator above followed by the amount, a u64 little endian. The amount the swap actually moves is only
+known once it runs, the quoted one stands in for it, a hook seeding an account off the amount is rare enough to live
+with that. Token-2022 finds the extras among the accounts it's handed by address, the hook program and the validation
+account go after them.
+*/
+
+const extensionTypeTransferHook = 14
+
+// extraAccountMetaLen is the size of one ExtraAccountMeta in a validation account.
+const extraAccountMetaLen = 35
+
+// Seed tags in an ExtraAccountMeta's address_config.
+const (
+	hookSeedEnd         = 0
+	hookSeedLiteral     = 1
+	hookSeedInstruction = 2
+	hookSeedAccountKey  = 3
+	hookSeedAccountData = 4
+)
+
+// errHookAccountMissing is an account a hook's seeds or list live in that doesn't exist yet.
+var errHookAccountMissing = errors.New("doesn't exist yet")
+
+// hookExecuteDiscriminator opens both the hook's Execute instruction and the entry listing its extra accounts.
+var hookExecuteDiscriminator = anchorDiscriminator("spl-transfer-hook-interface:execute")
+
+// extraAccountMeta is one entry of a validation account.
+type extraAccountMeta struct {
+	discriminator uint8
+	addressConfig [32]byte
+	isSigner      bool
+	isWritable    bool
+}
+
+// mintTransferHook reads the hook program out of a Token-2022 mint's extensions, ok is false when it has none.
+func mintTransferHook(data []byte) (program solana.PublicKey, ok bool, err error) {
+	if len(data) <= baseAccountLen {
+		return solana.PublicKey{}, false, nil
+	}
+	tlv, err := token2022TLVRegion(data)
+	if err != nil {
+		return solana.PublicKey{}, false, err
+	}
+	r := binaryReader{b: tlv}
+	for r.remaining() >= 4 {
+		typ, _ := r.le16()
+		if typ == extensionTypeUninitialized {
+			break
+		}
+		length, _ := r.le16()
+		value, ok := r.bytes(int(length))
+		if !ok {
+			return solana.PublicKey{}, false, fmt.Errorf("malformed token2022 TLV: length %d exceeds remaining %d", length, r.remaining())
+		}
+		if typ != extensionTypeTransferHook {
+			continue
+		}
+		if len(value) != 64 {
+			return solana.PublicKey{}, false, fmt.Errorf("transfer hook extension is %d bytes, want 64", len(value))
+		}
+		program = solana.PublicKeyFromBytes(value[32:64])
+		return program, !program.IsZero(), nil
+	}
+	return solana.PublicKey{}, false, nil
+}
+
+// hookValidationAddress is where hookProgram lists the extra accounts transfers of mint need.
+func hookValidationAddress(mint, hookProgram solana.PublicKey) (solana.PublicKey, error) {
+	addr, _, err := solana.FindProgramAddress([][]byte{[]byte("extra-account-metas"), mint.Bytes()}, hookProgram)
+	return addr, err
+}
+
+// parseExtraAccountMetas reads the Execute entry out of a validation account, an account without one lists nothing.
+func parseExtraAccountMetas(data []byte) ([]extraAccountMeta, error) {
+	r := binaryReader{b: data}
+	for r.remaining() >= 12 {
+		disc, _ := r.bytes(8)
+		length, _ := r.le32()
+		value, ok := r.bytes(int(length))
+		if !ok {
+			return nil, fmt.Errorf("malformed validation account: length %d exceeds remaining %d", length, r.remaining())
+		}
+		if string(disc) != string(hookExecuteDiscriminator) {
+			continue
+		}
+		vr := binaryReader{b: value}
+		count, ok := vr.le32()
+		if !ok || uint64(count)*extraAccountMetaLen > uint64(vr.remaining()) {
+			return nil, errors.New("malformed validation account: extra account list is truncated")
+		}
+		metas := make([]extraAccountMeta, count)
+		for i := range metas {
+			b, _ := vr.bytes(extraAccountMetaLen)
+			metas[i] = extraAccountMeta{discriminator: b[0], isSigner: b[33] != 0, isWritable: b[34] != 0}
+			copy(metas[i].addressConfig[:], b[1:33])
+		}
+		return metas, nil
+	}
+	return nil, nil
+}
+
+// hookExecuteData is the Execute instruction's data for a transfer of amount.
+func hookExecuteData(amount uint64) []byte {
+	return binary.LittleEndian.AppendUint64(append([]byte(nil), hookExecuteDiscriminator...), amount)
+}
+
+// resolveExtraAccountMetas turns metas into accounts. execute is the Execute instruction's accounts so far (source,
+// mint, destination, authority and the validation account), accountData reads an account for seeds taken out of one.
+func resolveExtraAccountMetas(metas []extraAccountMeta, hookProgram solana.PublicKey, execute solana.AccountMetaSlice, data []byte, accountData func(solana.PublicKey) ([]byte, error)) (solana.AccountMetaSlice, error) {
+	accounts := append(solana.AccountMetaSlice(nil), execute...)
+	var extras solana.AccountMetaSlice
+	for i, meta := range metas {
+		var addr solana.PublicKey
+		switch {
+		case meta.discriminator == 0:
+			addr = solana.PublicKeyFromBytes(meta.addressConfig[:])
+		case meta.discriminator == 1 || meta.discriminator >= 128:
+			program := hookProgram
+			if meta.discriminator >= 128 {
+				idx := int(meta.discriminator - 128)
+				if idx >= len(accounts) {
+					return nil, fmt.Errorf("extra account %d derives off account %d, there are %d", i, idx, len(accounts))
+				}
+				program = accounts[idx].PublicKey
+			}
+			seeds, err := hookSeeds(meta.addressConfig[:], accounts, data, accountData)
+			if err != nil {
+				return nil, fmt.Errorf("extra account %d: %w", i, err)
+			}
+			if addr, _, err = solana.FindProgramAddress(seeds, program); err != nil {
+				return nil, fmt.Errorf("extra account %d: %w", i, err)
+			}
+		case meta.discriminator == 2:
+			b, err := hookPubkeyData(meta.addressConfig[:], accounts, data, accountData)
+			if err != nil {
+				return nil, fmt.Errorf("extra account %d: %w", i, err)
+			}
+			addr = solana.PublicKeyFromBytes(b)
+		default:
+			return nil, fmt.Errorf("extra account %d has an unknown discriminator %d", i, meta.discriminator)
+		}
+		am := solana.NewAccountMeta(addr, meta.isWritable, meta.isSigner)
+		accounts = append(accounts, am)
+		extras = append(extras, am)
+	}
+	return extras, nil
+}
+
+// hookSeeds unpacks the seeds packed into an address_config.
+func hookSeeds(config []byte, accounts solana.AccountMetaSlice, data []byte, accountData func(solana.PublicKey) ([]byte, error)) ([][]byte, error) {
+	r := binaryReader{b: config}
+	var seeds [][]byte
+	for r.remaining() > 0 {
+		tag, _ := r.bytes(1)
+		var (
+			seed []byte
+			ok   bool
+		)
+		switch tag[0] {
+		case hookSeedEnd:
+			return seeds, nil
+		case hookSeedLiteral:
+			var n []byte
+			if n, ok = r.bytes(1); ok {
+				seed, ok = r.bytes(int(n[0]))
+			}
+		case hookSeedInstruction:
+			var arg []byte
+			if arg, ok = r.bytes(2); ok {
+				seed, ok = hookBytes(data, int(arg[0]), int(arg[1]))
+				if !ok {
+					return nil, fmt.Errorf("seed reads instruction data [%d:+%d] past its %d bytes", arg[0], arg[1], len(data))
+				}
+			}
+		case hookSeedAccountKey:
+			var arg []byte
+			if arg, ok = r.bytes(1); ok {
+				if int(arg[0]) >= len(accounts) {
+					return nil, fmt.Errorf("seed is account %d's key, there are %d", arg[0], len(accounts))
+				}
+				seed = accounts[arg[0]].PublicKey.Bytes()
+			}
+		case hookSeedAccountData:
+			var arg []byte
+			if arg, ok = r.bytes(3); ok {
+				b, err := hookAccountData(accounts, int(arg[0]), accountData)
+				if err != nil {
+					return nil, err
+				}
+				seed, ok = hookBytes(b, int(arg[1]), int(arg[2]))
+				if !ok {
+					return nil, fmt.Errorf("seed reads account %d's data [%d:+%d] past its %d bytes", arg[0], arg[1], arg[2], len(b))
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unknown seed tag %d", tag[0])
+		}
+		if !ok {
+			return nil, errors.New("seed config is truncated")
+		}
+		seeds = append(seeds, seed)
+	}
+	return seeds, nil
+}
+
+// hookPubkeyData reads the pubkey a discriminator 2 entry points at, out of instruction data (tag 1, {index}) or an
+// account's data (tag 2, {account index, data index}).
+func hookPubkeyData(config []byte, accounts solana.AccountMetaSlice, data []byte, accountData func(solana.PublicKey) ([]byte, error)) ([]byte, error) {
+	switch config[0] {
+	case 1:
+		if b, ok := hookBytes(data, int(config[1]), 32); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("pubkey at instruction data %d is past its %d bytes", config[1], len(data))
+	case 2:
+		b, err := hookAccountData(accounts, int(config[1]), accountData)
+		if err != nil {
+			return nil, err
+		}
+		if b, ok := hookBytes(b, int(config[2]), 32); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("pubkey at account %d's data %d is past its %d bytes", config[1], config[2], len(b))
+	default:
+		return nil, fmt.Errorf("unknown pubkey data tag %d", config[0])
+	}
+}
+
+func hookAccountData(accounts solana.AccountMetaSlice, idx int, accountData func(solana.PublicKey) ([]byte, error)) ([]byte, error) {
+	if idx >= len(accounts) {
+		return nil, fmt.Errorf("seed is account %d's data, there are %d", idx, len(accounts))
+	}
+	b, err := accountData(accounts[idx].PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("reading account %d (%s) for a seed failed: %w", idx, Addr(accounts[idx].PublicKey.String()), err)
+	}
+	return b, nil
+}
+
+// hookBytes is b[off:off+n], ok is false when that runs past the end.
+func hookBytes(b []byte, off, n int) ([]byte, bool) {
+	if off+n > len(b) {
+		return nil, false
+	}
+	return b[off : off+n], true
+}
+
+// hookTransfer is one of the token transfers a swap makes.
+type hookTransfer struct {
+	source, mint, destination, authority solana.PublicKey
+	amount                               *big.Int
+}
+
+// swapHookTransfers are the two transfers leg makes, the input into its vault and the output out of the other.
+func swapHookTransfers(leg *CPIntent, payer, inputATA, outputATA solana.PublicKey) ([2]hookTransfer, error) {
+	auth, err := swapAuthority()
+	if err != nil {
+		return [2]hookTransfer{}, err
+	}
+	in, out := leg.Amounts.KnownAmount, leg.Amounts.QuoteAmount
+	if leg.SwapKind == SwapKindBaseOutput {
+		in, out = leg.Amounts.QuoteAmount, leg.Amounts.KnownAmount
+	}
+	return [2]hookTransfer{
+		{source: inputATA, mint: leg.TokenIn.Mint, destination: leg.TokenIn.Vault, authority: payer, amount: in},
+		{source: leg.TokenOut.Vault, mint: leg.TokenOut.Mint, destination: outputATA, authority: auth, amount: out},
+	}, nil
+}
+
+// transferHookAccounts are the accounts leg's swap instruction needs on the end for the hooks its mints call, nil
+// when neither mint is hooked. Classic SPL Token mints can't have one and cost no reads.
+func transferHookAccounts(ctx context.Context, client *rpc.Client, leg *CPIntent, payer, inputATA, outputATA solana.PublicKey) (solana.AccountMetaSlice, error) {
+	transfers, err := swapHookTransfers(leg, payer, inputATA, outputATA)
+	if err != nil {
+		return nil, err
+	}
+	cache := map[solana.PublicKey][]byte{}
+	accountData := func(key solana.PublicKey) ([]byte, error) {
+		if b, ok := cache[key]; ok {
+			return b, nil
+		}
+		res, err := client.GetAccountInfoWithOpts(ctx, key, &rpc.GetAccountInfoOpts{Encoding: solana.EncodingBase64, Commitment: rpc.CommitmentProcessed})
+		if err != nil {
+			if isAccountMissingErr(err) {
+				return nil, fmt.Errorf("account %s %w", Addr(key.String()), errHookAccountMissing)
+			}
+			return nil, fmt.Errorf("rpc call getAccountInfo failed: %w", err)
+		}
+		cache[key] = res.Value.Data.GetBinary()
+		return cache[key], nil
+	}
+
+	var extra solana.AccountMetaSlice
+	for i, side := range []SwapLeg{leg.TokenIn, leg.TokenOut} {
+		if !side.Program.Equals(solana.Token2022ProgramID) {
+			continue
+		}
+		mintData, err := accountData(side.Mint)
+		if err != nil {
+			return nil, fmt.Errorf("reading mint %s for a transfer hook failed: %w", Addr(side.Mint.String()), err)
+		}
+		hook, ok, err := mintTransferHook(mintData)
+		if err != nil {
+			return nil, fmt.Errorf("mint %s: %w", Addr(side.Mint.String()), err)
+		}
+		if !ok {
+			continue
+		}
+		validation, err := hookValidationAddress(side.Mint, hook)
+		if err != nil {
+			return nil, err
+		}
+		// A hook that needs no extra accounts doesn't have to create a validation account at all.
+		var metas []extraAccountMeta
+		data, err := accountData(validation)
+		if err == nil {
+			metas, err = parseExtraAccountMetas(data)
+		}
+		if err != nil && !errors.Is(err, errHookAccountMissing) {
+			return nil, fmt.Errorf("transfer hook of %s: %w", Addr(side.Mint.String()), err)
+		}
+		t := transfers[i]
+		if t.amount == nil || !t.amount.IsUint64() {
+			return nil, fmt.Errorf("transfer hook of %s: the swap's amounts aren't quoted", Addr(side.Mint.String()))
+		}
+		execute := solana.AccountMetaSlice{
+			solana.Meta(t.source), solana.Meta(t.mint), solana.Meta(t.destination), solana.Meta(t.authority), solana.Meta(validation),
+		}
+		resolved, err := resolveExtraAccountMetas(metas, hook, execute, hookExecuteData(t.amount.Uint64()), accountData)
+		if err != nil {
+			return nil, fmt.Errorf("transfer hook of %s: %w", Addr(side.Mint.String()), err)
+		}
+		extra = append(extra, resolved...)
+		extra = append(extra, solana.Meta(hook), solana.Meta(validation))
+	}
+	return extra, nil
+}
+
+// withRemainingAccounts is ix with accounts appended to the ones it has.
+func withRemainingAccounts(ix solana.Instruction, accounts solana.AccountMetaSlice) (solana.Instruction, error) {
+	data, err := ix.Data()
+	if err != nil {
+		return nil, err
+	}
+	return solana.NewInstruction(ix.ProgramID(), append(append(solana.AccountMetaSlice(nil), ix.Accounts()...), accounts...), data), nil
+}
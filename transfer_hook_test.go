@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"math/big"
+	"strings"
+	"testing"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// hookedMintData is a Token-2022 mint with a TransferHook extension naming program.
+func hookedMintData(program solana.PublicKey) []byte {
+	data := make([]byte, baseAccountLen+1)
+	data[44], data[45] = 6, 1
+	data[baseAccountLen] = accountTypeMint
+	data = binary.LittleEndian.AppendUint16(data, extensionTypeTransferHook)
+	data = binary.LittleEndian.AppendUint16(data, 64)
+	data = append(data, make([]byte, 32)...) // authority
+	return append(data, program.Bytes()...)
+}
+
+// validationData is a validation account listing metas for Execute.
+func validationData(metas ...extraAccountMeta) []byte {
+	list := binary.LittleEndian.AppendUint32(nil, uint32(len(metas)))
+	for _, m := range metas {
+		list = append(list, m.discriminator)
+		list = append(list, m.addressConfig[:]...)
+		list = append(list, boolByte(m.isSigner), boolByte(m.isWritable))
+	}
+	data := append([]byte(nil), hookExecuteDiscriminator...)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(list)))
+	return append(data, list...)
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func seedConfig(b ...byte) [32]byte {
+	var c [32]byte
+	copy(c[:], b)
+	return c
+}
+
+func TestMintTransferHook(t *testing.T) {
+	program := snapshotKey(40)
+	got, ok, err := mintTransferHook(hookedMintData(program))
+	if err != nil || !ok || !got.Equals(program) {
+		t.Errorf("hook %s %v %v, want %s", got, ok, err, program)
+	}
+	if _, ok, err := mintTransferHook(hookedMintData(solana.PublicKey{})); ok || err != nil {
+		t.Errorf("a zeroed hook program read as a hook (%v)", err)
+	}
+	if _, ok, err := mintTransferHook(make([]byte, baseMintLen)); ok || err != nil {
+		t.Errorf("a mint without extensions read as hooked (%v)", err)
+	}
+	truncated := hookedMintData(program)
+	if _, _, err := mintTransferHook(truncated[:len(truncated)-10]); err == nil {
+		t.Error("a truncated extension read fine")
+	}
+}
+
+func TestParseExtraAccountMetas(t *testing.T) {
+	fixed := extraAccountMeta{discriminator: 0, addressConfig: [32]byte(snapshotKey(41).Bytes()), isWritable: true}
+	pda := extraAccountMeta{discriminator: 1, addressConfig: seedConfig(hookSeedLiteral, 3, 'c', 'f', 'g')}
+	metas, err := parseExtraAccountMetas(validationData(fixed, pda))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metas) != 2 || metas[0] != fixed || metas[1] != pda {
+		t.Errorf("metas %+v", metas)
+	}
+	// Another interface's entry before Execute's is skipped over.
+	other := append([]byte{1, 2, 3, 4, 5, 6, 7, 8}, binary.LittleEndian.AppendUint32(nil, 3)...)
+	other = append(other, 9, 9, 9)
+	if metas, err = parseExtraAccountMetas(append(other, validationData(fixed)...)); err != nil || len(metas) != 1 {
+		t.Errorf("behind another entry: %+v %v", metas, err)
+	}
+	data := validationData(fixed, pda)
+	if _, err := parseExtraAccountMetas(data[:len(data)-1]); err == nil {
+		t.Error("a truncated list parsed")
+	}
+}
+
+func TestResolveExtraAccountMetas(t *testing.T) {
+	hook, source, mint, dest, owner, validation := snapshotKey(50), snapshotKey(51), snapshotKey(52), snapshotKey(53), snapshotKey(54), snapshotKey(55)
+	fixed := snapshotKey(56)
+	execute := solana.AccountMetaSlice{solana.Meta(source), solana.Meta(mint), solana.Meta(dest), solana.Meta(owner), solana.Meta(validation)}
+	sourceData := tokenAccountData(mint, 0) // its owner, at 32, is what the hook seeds with
+	copy(sourceData[32:64], owner.Bytes())
+	accountData := func(key solana.PublicKey) ([]byte, error) {
+		if key.Equals(source) {
+			return sourceData, nil
+		}
+		return nil, errHookAccountMissing
+	}
+	metas := []extraAccountMeta{
+		{discriminator: 0, addressConfig: [32]byte(fixed.Bytes()), isWritable: true},
+		// ["counter", mint] under the hook program.
+		{discriminator: 1, addressConfig: seedConfig(hookSeedLiteral, 7, 'c', 'o', 'u', 'n', 't', 'e', 'r', hookSeedAccountKey, 1)},
+		// [source's owner, the amount] under the fixed account resolved first, account 5.
+		{discriminator: 128 + 5, addressConfig: seedConfig(hookSeedAccountData, 0, 32, 32, hookSeedInstruction, 8, 8)},
+	}
+	data := hookExecuteData(1_500_000)
+	got, err := resolveExtraAccountMetas(metas, hook, execute, data, accountData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter, _, _ := solana.FindProgramAddress([][]byte{[]byte("counter"), mint.Bytes()}, hook)
+	byOwner, _, _ := solana.FindProgramAddress([][]byte{owner.Bytes(), data[8:16]}, fixed)
+	want := []solana.PublicKey{fixed, counter, byOwner}
+	if len(got) != len(want) {
+		t.Fatalf("resolved %d accounts, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !got[i].PublicKey.Equals(want[i]) {
+			t.Errorf("account %d is %s, want %s", i, got[i].PublicKey, want[i])
+		}
+	}
+	if !got[0].IsWritable || got[1].IsWritable {
+		t.Errorf("writability %v %v", got[0].IsWritable, got[1].IsWritable)
+	}
+
+	for _, tc := range []struct {
+		name string
+		meta extraAccountMeta
+		want string
+	}{
+		{"a missing account's data", extraAccountMeta{discriminator: 1, addressConfig: seedConfig(hookSeedAccountData, 2, 0, 32)}, "doesn't exist yet"},
+		{"an account past the end", extraAccountMeta{discriminator: 1, addressConfig: seedConfig(hookSeedAccountKey, 9)}, "there are 5"},
+		{"data past the end", extraAccountMeta{discriminator: 1, addressConfig: seedConfig(hookSeedInstruction, 10, 8)}, "past its 16 bytes"},
+		{"an unknown seed", extraAccountMeta{discriminator: 1, addressConfig: seedConfig(9)}, "unknown seed tag"},
+		{"an unknown discriminator", extraAccountMeta{discriminator: 7}, "unknown discriminator"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := resolveExtraAccountMetas([]extraAccountMeta{tc.meta}, hook, execute, data, accountData)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("error %v, want it to mention %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestTransferHookAccounts(t *testing.T) {
+	payer, inATA, outATA := snapshotKey(60), snapshotKey(61), snapshotKey(62)
+	hook, hooked, plain := snapshotKey(63), snapshotKey(64), snapshotKey(65)
+	leg := &CPIntent{
+		SwapKind: SwapKindBaseInput,
+		Amounts:  SwapAmounts{KnownAmount: big.NewInt(1_000), QuoteAmount: big.NewInt(2_000)},
+		TokenIn:  SwapLeg{Mint: plain, Vault: snapshotKey(66), Program: solana.TokenProgramID},
+		TokenOut: SwapLeg{Mint: hooked, Vault: snapshotKey(67), Program: solana.Token2022ProgramID},
+	}
+	validation, _ := hookValidationAddress(hooked, hook)
+	fixed := snapshotKey(68)
+	client := transferRPC(t, map[solana.PublicKey]*rpc.Account{
+		hooked:     {Owner: solana.Token2022ProgramID, Data: rpc.DataBytesOrJSONFromBytes(hookedMintData(hook))},
+		validation: {Owner: hook, Data: rpc.DataBytesOrJSONFromBytes(validationData(extraAccountMeta{addressConfig: [32]byte(fixed.Bytes())}))},
+	}, nil)
+	got, err := transferHookAccounts(context.Background(), client, leg, payer, inATA, outATA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || !got[0].PublicKey.Equals(fixed) || !got[1].PublicKey.Equals(hook) || !got[2].PublicKey.Equals(validation) {
+		t.Errorf("accounts %v, want the extra, the hook program and its validation account", got)
+	}
+
+	// Neither side on Token-2022 costs no reads at all, the RPC here answers nothing.
+	leg.TokenOut.Program = solana.TokenProgramID
+	if got, err := transferHookAccounts(context.Background(), rpc.New("http://127.0.0.1:0"), leg, payer, inATA, outATA); err != nil || got != nil {
+		t.Errorf("a classic pair: %v %v", got, err)
+	}
+
+	swap := solana.NewInstruction(solana.TokenProgramID, solana.AccountMetaSlice{solana.Meta(payer)}, []byte{1, 2})
+	ix, err := withRemainingAccounts(swap, solana.AccountMetaSlice{solana.Meta(hook)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ix.Data(); len(ix.Accounts()) != 2 || len(swap.Accounts()) != 1 || string(data) != "\x01\x02" {
+		t.Errorf("remaining accounts appended as %v", ix.Accounts())
+	}
+}
+
+func TestPlanSwapToken2022Leg(t *testing.T) {
+	pool, poolAddr, balances := snapshotPool()
+	pool.Token0Mint = snapshotKey(70)
+	pool.Token1Mint = snapshotKey(71)
+	pool.Token1Program = solana.Token2022ProgramID
+	instruction, err := parseIntent("buy 10 USDC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	intent, err := NewCPIntent(ConstantProduct{TradeFeeRate: 2500}, pool, poolAddr, instruction, pool.Token1Mint, balances...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A Token-2022 mint without a hook, and neither of the payer's accounts there yet.
+	client := transferRPC(t, map[solana.PublicKey]*rpc.Account{
+		pool.Token1Mint: {Owner: solana.Token2022ProgramID, Data: rpc.DataBytesOrJSONFromBytes(token2022MintData(nil, nil))},
+	}, nil)
+	payer := snapshotKey(72)
+	plan, err := planSwap(context.Background(), client, payer, intent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantIn, _ := associatedTokenAddress(payer, pool.Token0Mint, solana.TokenProgramID)
+	wantOut, _ := associatedTokenAddress(payer, pool.Token1Mint, solana.Token2022ProgramID)
+	classicOut, _ := associatedTokenAddress(payer, pool.Token1Mint, solana.TokenProgramID)
+	if !plan.inputATA.Equals(wantIn) {
+		t.Errorf("input account %s, want the classic %s", plan.inputATA, wantIn)
+	}
+	if !plan.outputATA.Equals(wantOut) || plan.outputATA.Equals(classicOut) {
+		t.Errorf("output account %s, want the Token-2022 %s", plan.outputATA, wantOut)
+	}
+	created := map[solana.PublicKey]solana.PublicKey{}
+	for _, ix := range plan.instructions {
+		if ix.ProgramID().Equals(solana.SPLAssociatedTokenAccountProgramID) {
+			accs := ix.Accounts()
+			created[accs[1].PublicKey] = accs[len(accs)-1].PublicKey
+		}
+	}
+	if len(created) != 2 || !created[wantIn].Equals(solana.TokenProgramID) || !created[wantOut].Equals(solana.Token2022ProgramID) {
+		t.Errorf("created %v, want each account under its mint's program", created)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestAssociatedTokenAddress(t *testing.T) {
+	owner, mint := snapshotKey(1), snapshotKey(2)
+	classic, err := associatedTokenAddress(owner, mint, solana.TokenProgramID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _, _ := solana.FindAssociatedTokenAddress(owner, mint)
+	if !classic.Equals(want) {
+		t.Errorf("classic account %s, want %s", classic, want)
+	}
+	t22, err := associatedTokenAddress(owner, mint, solana.Token2022ProgramID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if t22.Equals(classic) {
+		t.Error("a Token-2022 mint's account derived like a classic one")
+	}
+}
+
+func TestTransferInstructions(t *testing.T) {
+	payer, owner, mint, ata := snapshotKey(1), snapshotKey(2), snapshotKey(3), snapshotKey(4)
+	create := createATAInstruction(payer, ata, owner, mint, solana.Token2022ProgramID)
+	data, _ := create.Data()
+	accounts := create.Accounts()
+	if !create.ProgramID().Equals(solana.SPLAssociatedTokenAccountProgramID) || len(data) != 1 || data[0] != ataCreateIdempotent {
+		t.Errorf("create instruction %s %x", create.ProgramID(), data)
+	}
+	if len(accounts) != 6 || !accounts[0].IsSigner || !accounts[1].PublicKey.Equals(ata) || !accounts[5].PublicKey.Equals(solana.Token2022ProgramID) {
+		t.Errorf("create accounts %v", accounts)
+	}
+
+	source, dest := snapshotKey(5), snapshotKey(6)
+	ix, err := transferCheckedInstruction(solana.Token2022ProgramID, source, mint, dest, payer, 5_000_000, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = ix.Data()
+	if !ix.ProgramID().Equals(solana.Token2022ProgramID) {
+		t.Errorf("transfer goes to %s", ix.ProgramID())
+	}
+	if len(data) != 10 || data[0] != 12 || binary.LittleEndian.Uint64(data[1:9]) != 5_000_000 || data[9] != 6 {
+		t.Errorf("transfer_checked data %x", data)
+	}
+	accounts = ix.Accounts()
+	if len(accounts) != 4 || !accounts[0].PublicKey.Equals(source) || !accounts[1].PublicKey.Equals(mint) || !accounts[2].PublicKey.Equals(dest) || !accounts[3].IsSigner {
+		t.Errorf("transfer accounts %v", accounts)
+	}
+}
+
+func TestPickHeldMint(t *testing.T) {
+	usdc, fake, bonk := snapshotKey(10), snapshotKey(11), snapshotKey(12)
+	symm := SymbolMapping{
+		mintToSymbol: map[string]string{usdc.String(): "USDC", bonk.String(): "BONK"},
+		symbolToMint: map[string]solana.PublicKey{"USDC": usdc, "BONK": bonk},
+	}
+	held := []solana.PublicKey{usdc, bonk}
+	for token, want := range map[string]solana.PublicKey{"usdc": usdc, "BONK": bonk, bonk.String(): bonk, bonk.String()[:6]: bonk} {
+		got, err := pickHeldMint(symm, held, token)
+		if err != nil || !got.Equals(want) {
+			t.Errorf("%s resolved to %s (%v), want %s", token, got, err, want)
+		}
+	}
+	if _, err := pickHeldMint(symm, held, "WIF"); err == nil || !strings.Contains(err.Error(), "holds nothing called WIF") {
+		t.Errorf("an unheld symbol: %v", err)
+	}
+	symm.mintToSymbol[fake.String()] = "USDC"
+	held = append(held, fake)
+	if _, err := pickHeldMint(symm, held, "USDC"); err == nil || !strings.Contains(err.Error(), "more than one token called USDC") {
+		t.Errorf("two tokens called USDC: %v", err)
+	}
+	if got, err := pickHeldMint(symm, held, usdc.String()); err != nil || !got.Equals(usdc) {
+		t.Errorf("naming it by its mint: %s, %v", got, err)
+	}
+}
+
+func TestTransferAmount(t *testing.T) {
+	balance := big.NewInt(12_500_000)
+	for _, tc := range []struct {
+		amount string
+		want   int64
+		err    string
+	}{
+		{"5", 5_000_000, ""},
+		{"0.25", 250_000, ""},
+		{"50%", 6_250_000, ""},
+		{"all", 12_500_000, ""},
+		{"13", 0, "more than that"},
+		{"0.0000001", 0, "precision"},
+		{"0", 0, "greater than zero"},
+		{"150%", 0, "at most 100%"},
+		{"five", 0, "invalid decimal"},
+	} {
+		got, err := transferAmount(tc.amount, balance, 6)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: error %v, want it to mention %q", tc.amount, err, tc.err)
+			}
+			continue
+		}
+		if err != nil || got.Int64() != tc.want {
+			t.Errorf("%s: got %v (%v), want %d", tc.amount, got, err, tc.want)
+		}
+	}
+	if _, err := transferAmount("1%", big.NewInt(10), 6); err == nil || !strings.Contains(err.Error(), "nothing to send") {
+		t.Errorf("a percentage of dust: %v", err)
+	}
+}
+
+// transferRPC answers the reads planTransfer makes from accounts and balances, anything missing isn't there.
+func transferRPC(t *testing.T, accounts map[solana.PublicKey]*rpc.Account, balances map[solana.PublicKey]uint64) *rpc.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params []any           `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Params) == 0 {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		key := solana.MustPublicKeyFromBase58(req.Params[0].(string))
+		switch req.Method {
+		case "getAccountInfo":
+			acc, ok := accounts[key]
+			if !ok {
+				fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"context":{"slot":1},"value":null}}`, req.ID)
+				return
+			}
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"context":{"slot":1},"value":{"data":["%s","base64"],"executable":false,"lamports":%d,"owner":"%s","rentEpoch":0}}}`,
+				req.ID, base64.StdEncoding.EncodeToString(acc.Data.GetBinary()), acc.Lamports, acc.Owner)
+		case "getBalance":
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"context":{"slot":1},"value":%d}}`, req.ID, balances[key])
+		case "getTokenAccountBalance":
+			bal, ok := balances[key]
+			if !ok {
+				fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"error":{"code":-32602,"message":"Invalid param: could not find account"}}`, req.ID)
+				return
+			}
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"context":{"slot":1},"value":{"amount":"%d","decimals":6,"uiAmountString":"0"}}}`, req.ID, bal)
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return rpc.New(srv.URL)
+}
+
+func TestPlanTransfer(t *testing.T) {
+	payer, recipient, mint, other := snapshotKey(20), snapshotKey(21), snapshotKey(22), snapshotKey(23)
+	usdc := transferToken{mint: mint, program: solana.TokenProgramID, decimals: 6, symbol: "USDC"}
+	source, _ := associatedTokenAddress(payer, mint, solana.TokenProgramID)
+	dest, _ := associatedTokenAddress(recipient, mint, solana.TokenProgramID)
+	balances := map[solana.PublicKey]uint64{source: 10_000_000, payer: 2_000_000_000}
+	ctx := context.Background()
+
+	// The recipient has no account yet, it's created first.
+	client := transferRPC(t, map[solana.PublicKey]*rpc.Account{}, balances)
+	plan, err := planTransfer(ctx, client, payer, recipient, usdc, "5", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.createATA || len(plan.instructions) != 2 || !plan.destination.Equals(dest) || plan.amount.Int64() != 5_000_000 {
+		t.Errorf("plan %+v", plan)
+	}
+	if out := renderTransferPlan(plan); !strings.Contains(out, "5.000000 USDC") || !strings.Contains(out, "created") {
+		t.Errorf("rendered:\n%s", out)
+	}
+	if _, err := planTransfer(ctx, client, payer, recipient, usdc, "5", false); err == nil || !strings.Contains(err.Error(), "-create-ata=false") {
+		t.Errorf("without -create-ata: %v", err)
+	}
+	if _, err := planTransfer(ctx, client, payer, payer, usdc, "5", true); err == nil {
+		t.Error("sending to the wallet itself was planned")
+	}
+
+	// The recipient has one, only the transfer goes out.
+	client = transferRPC(t, map[solana.PublicKey]*rpc.Account{
+		dest: {Owner: solana.TokenProgramID, Data: rpc.DataBytesOrJSONFromBytes(tokenAccountData(mint, 0))},
+	}, balances)
+	if plan, err = planTransfer(ctx, client, payer, recipient, usdc, "all", true); err != nil {
+		t.Fatal(err)
+	}
+	if plan.createATA || len(plan.instructions) != 1 || plan.amount.Int64() != 10_000_000 {
+		t.Errorf("plan %+v", plan)
+	}
+
+	// A token account given as the recipient is sent to directly, when it's the mint's.
+	client = transferRPC(t, map[solana.PublicKey]*rpc.Account{
+		recipient: {Owner: solana.TokenProgramID, Data: rpc.DataBytesOrJSONFromBytes(tokenAccountData(mint, 0))},
+		other:     {Owner: solana.TokenProgramID, Data: rpc.DataBytesOrJSONFromBytes(tokenAccountData(snapshotKey(99), 0))},
+	}, balances)
+	if plan, err = planTransfer(ctx, client, payer, recipient, usdc, "1", true); err != nil {
+		t.Fatal(err)
+	}
+	if !plan.destination.Equals(recipient) || plan.createATA {
+		t.Errorf("sent to %s, want the token account %s", plan.destination, recipient)
+	}
+	if _, err := planTransfer(ctx, client, payer, other, usdc, "1", true); err == nil || !strings.Contains(err.Error(), "not USDC") {
+		t.Errorf("another mint's token account: %v", err)
+	}
+
+	// SOL is a system transfer, less the fee reserve for all of it, and never to a token account.
+	sol := transferToken{mint: wSOLMint, program: solana.SystemProgramID, decimals: 9, symbol: "SOL", native: true}
+	if plan, err = planTransfer(ctx, client, payer, snapshotKey(24), sol, "all", true); err != nil {
+		t.Fatal(err)
+	}
+	if plan.amount.Int64() != 2_000_000_000-solFeeReserve || !plan.instructions[0].ProgramID().Equals(solana.SystemProgramID) {
+		t.Errorf("sol plan %+v", plan)
+	}
+	if _, err := planTransfer(ctx, client, payer, recipient, sol, "1", true); err == nil || !strings.Contains(err.Error(), "token account") {
+		t.Errorf("SOL to a token account: %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
@@ -9,6 +10,9 @@ import (
 	"time"
 	"unicode/utf8"
 
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/nsf/termbox-go"
 )
 
@@ -18,6 +22,8 @@ const (
 	modeBusy uiMode = iota
 	modeAwaitDecision
 	modePrompt
+	modeExecuting
+	modeResult
 )
 
 type userDecision uint8
@@ -34,6 +40,7 @@ type promptKind uint8
 const (
 	promptKindIntent promptKind = iota
 	promptKindSlippage
+	promptKindPool
 )
 
 var spinnerFrames = []rune{'|', '/', '-', '\\'}
@@ -42,6 +49,36 @@ type renderResult struct {
 	intentMeta *CPIntent
 	table      string
 	err        error
+	// poolSwitch is set when the result comes from switching pools rather than recomputing an intent, poolErr
+	// carries the reason the switch itself failed (in which case we stay on the previous pool).
+	poolSwitch bool
+	poolErr    error
+	// candles is set when the result is the pool's candle chart, in table, to show under the quote.
+	candles bool
+}
+
+// swapExecutor carries what the TUI needs to sign and send a swap by itself. Without one, the TUI only resolves the
+// intent and hands it back to the caller.
+type swapExecutor struct {
+	ctx     context.Context
+	client  *rpc.Client
+	payer   solana.PrivateKey
+	network string
+	// fallbackPools offers the next best pool for the pair when a swap fails because of the pool.
+	fallbackPools bool
+}
+
+// execUpdate reports execution progress back to the UI loop, done marks the final update.
+type execUpdate struct {
+	stage    string
+	done     bool
+	sig      solana.Signature
+	summary  *txSummaryData
+	err      error
+	warning  error
+	fallback *poolCandidate
+	// requote is the swap quoted again after it drifted past its slippage guard before it was sent.
+	requote *renderResult
 }
 
 type symbolMappingRequest struct {
@@ -49,37 +86,98 @@ type symbolMappingRequest struct {
 	mint   string
 }
 
+// renderSymbolMapping is what the mapping prompt shows in place of the quote: the mint in full, the pool's other token
+// and the alias the mapping would replace, everything to check before answering y.
+func renderSymbolMapping(req symbolMappingRequest, snap quoteSnapshot, aliases *symbolAliases) string {
+	t := table.NewWriter()
+	t.SetTitle("Map %s to a mint?", req.symbol)
+	t.AppendRow(table.Row{"Symbol", req.symbol})
+	t.AppendRow(table.Row{"Mint", req.mint})
+	for i, mint := range snap.venue.Mints() {
+		if mint.String() != req.mint {
+			t.AppendRow(table.Row{"Other token", fmt.Sprintf("%s %s", snap.symm.SymFrom(mint), mint)})
+			continue
+		}
+		t.AppendRow(table.Row{"Shown as", fmt.Sprintf("%s (%s)", snap.symm.SymFrom(mint), symbolSourceMint)})
+		if acc := snap.mints[i]; acc != nil {
+			program := "SPL Token"
+			if acc.Program.Equals(solana.Token2022ProgramID) {
+				program = "Token-2022"
+			}
+			t.AppendRow(table.Row{"Token program", program})
+			t.AppendRow(table.Row{"Decimals", acc.Decimals})
+		}
+	}
+	t.AppendRow(table.Row{"Saved to", aliases.path})
+	if prev := aliases.mint(normalizeSymbol(req.symbol)); prev != "" && prev != req.mint {
+		t.AppendRow(table.Row{"Replaces", fmt.Sprintf("%s → %s", req.symbol, prev)})
+	}
+	return t.Render() + "\nAnyone can make a token and call it " + req.symbol + ", check the mint on an explorer before answering y.\n"
+}
+
 type termUI struct {
-	builder         *TableBuilder
-	resultCh        chan renderResult
-	done            chan struct{}
-	mode            uiMode
-	promptBuffer    []rune
-	promptKind      promptKind
-	tableLines      []string
-	busy            bool
-	busyIntent      string
-	currentIntent   string
-	intentInput     string
-	spinnerFrame    int
-	statusMessage   string
-	intentMeta      *CPIntent
-	cursorVisible   bool
-	tableFlashUntil time.Time
-	lastTable       string
-	pendingMapping  *symbolMappingRequest
-}
-
-func newTermUI(builder *TableBuilder) *termUI {
+	builder        *TableBuilder
+	resultCh       chan renderResult
+	done           chan struct{}
+	mode           uiMode
+	promptKind     promptKind
+	inputs         map[promptKind]*inputField
+	table          scrollView
+	errPane        errorPane
+	busy           bool
+	busyIntent     string
+	busyPool       string
+	busyCandles    bool
+	currentIntent  string
+	intentInput    string
+	spinnerFrame   int
+	statusMessage  string
+	intentMeta     *CPIntent
+	cursorVisible  bool
+	lastTable      string
+	pendingMapping *symbolMappingRequest
+	executor       *swapExecutor
+	readOnly       bool // no wallet loaded, there's nothing to execute with
+	execCh         chan execUpdate
+	execStage      string
+	receipts       []string
+	triedPools     map[solana.PublicKey]bool
+	help           helpOverlay
+}
+
+func newTermUI(builder *TableBuilder, executor *swapExecutor) *termUI {
 	return &termUI{
 		builder:       builder,
+		executor:      executor,
 		resultCh:      make(chan renderResult),
+		execCh:        make(chan execUpdate),
 		done:          make(chan struct{}),
 		cursorVisible: true,
+		triedPools:    make(map[solana.PublicKey]bool),
+		inputs: map[promptKind]*inputField{
+			promptKindIntent:   {},
+			promptKindSlippage: {},
+			promptKindPool:     {},
+		},
 	}
 }
 
-func (ui *termUI) Run(initialIntent string) (*CPIntent, string, error) {
+func (ui *termUI) input() *inputField {
+	return ui.inputs[ui.promptKind]
+}
+
+// openPrompt switches to prompt mode, editing a fresh line of the given kind.
+func (ui *termUI) openPrompt(kind promptKind, message string) {
+	ui.pendingMapping = nil
+	ui.mode = modePrompt
+	ui.promptKind = kind
+	ui.input().reset()
+	ui.statusMessage = message
+	ui.cursorVisible = true
+}
+
+// Run shows the TUI until the user is done with it or ctx is, the terminal is restored either way.
+func (ui *termUI) Run(ctx context.Context, initialIntent string) (*CPIntent, string, error) {
 	if err := termbox.Init(); err != nil {
 		return nil, "", err
 	}
@@ -95,10 +193,39 @@ func (ui *termUI) Run(initialIntent string) (*CPIntent, string, error) {
 	ticker := time.NewTicker(120 * time.Millisecond)
 	defer ticker.Stop()
 
-	ui.startCompute(initialIntent)
+	if initialIntent == "" {
+		// Nothing worth quoting yet, straight to the intent prompt. The status set before Run says why.
+		message := ui.statusMessage
+		if message == "" {
+			message = "Enter an intent, e.g. pay 1 SOL, ? for help."
+		}
+		ui.openPrompt(promptKindIntent, message)
+	} else {
+		ui.inputs[promptKindIntent].remember(initialIntent)
+		ui.startCompute(initialIntent)
+	}
+	stopping := false
 	for {
 		ui.draw()
 		select {
+		case <-ctx.Done():
+			if ui.mode != modeExecuting {
+				return nil, "", ctx.Err()
+			}
+			// NOTE(@hadydotai): The swap in flight runs under the same context, so it's already unwinding. Its receipt
+			// is what tells the user whether anything went out, leaving before it would lose that.
+			if !stopping {
+				stopping = true
+				ui.statusMessage = "Interrupted, waiting for the swap in flight to wrap up..."
+			}
+			select {
+			case upd := <-ui.execCh:
+				ui.handleExecUpdate(upd)
+				if upd.done {
+					return nil, "", ctx.Err()
+				}
+			case <-ticker.C:
+			}
 		case ev := <-eventCh:
 			if ev.Type == termbox.EventInterrupt {
 				continue
@@ -107,6 +234,7 @@ func (ui *termUI) Run(initialIntent string) (*CPIntent, string, error) {
 			case termbox.EventError:
 				return nil, "", ev.Err
 			case termbox.EventResize:
+				// NOTE(@hadydotai): Nothing to do, every component lays itself out from termbox.Size() on the next draw.
 				continue
 			case termbox.EventKey:
 				if decision, ok := ui.handleKey(ev); ok {
@@ -127,30 +255,9 @@ func (ui *termUI) Run(initialIntent string) (*CPIntent, string, error) {
 				}
 			}
 		case res := <-ui.resultCh:
-			ui.busy = false
-			ui.spinnerFrame = 0
-			ui.intentMeta = res.intentMeta
-			ui.lastTable = res.table
-			if res.intentMeta != nil {
-				ui.currentIntent = res.intentMeta.String()
-			}
-			if res.err != nil {
-				var mapErr *MissingSymbolMappingError
-				if errors.As(res.err, &mapErr) {
-					ui.pendingMapping = &symbolMappingRequest{symbol: mapErr.Symbol, mint: mapErr.Mint}
-					ui.tableLines = nil
-					ui.statusMessage = fmt.Sprintf("Symbol %s is unknown. Map it to %s? (y=yes, n=no)", mapErr.Symbol, mapErr.MintDisplay())
-					ui.mode = modeAwaitDecision
-				} else {
-					ui.statusMessage = fmt.Sprintf("failed to compute intent: %v", res.err)
-					ui.mode = modeAwaitDecision
-				}
-			} else {
-				ui.tableLines = splitLines(res.table)
-				ui.statusMessage = "Press y=yes, n=no, c=change intent, s=slippage."
-				ui.tableFlashUntil = time.Now().Add(350 * time.Millisecond)
-				ui.mode = modeAwaitDecision
-			}
+			ui.handleResult(res)
+		case upd := <-ui.execCh:
+			ui.handleExecUpdate(upd)
 		case <-ticker.C:
 			if ui.busy {
 				ui.spinnerFrame = (ui.spinnerFrame + 1) % len(spinnerFrames)
@@ -164,6 +271,61 @@ func (ui *termUI) Run(initialIntent string) (*CPIntent, string, error) {
 	}
 }
 
+// handleResult shows a finished quote, pool switch or candles chart.
+func (ui *termUI) handleResult(res renderResult) {
+	ui.busy = false
+	ui.busyCandles = false
+	ui.spinnerFrame = 0
+	if res.candles {
+		if res.err != nil {
+			ui.errPane.set(fmt.Sprintf("failed to read the pool's candles: %v", res.err))
+		} else {
+			ui.table.setLines(splitLines(ui.lastTable + "\n" + res.table))
+		}
+		ui.mode = modeAwaitDecision
+		return
+	}
+	if res.poolSwitch && res.poolErr != nil {
+		ui.errPane.set(fmt.Sprintf("failed to switch pool: %v", res.poolErr))
+		ui.statusMessage = fmt.Sprintf("Still on pool %s.", Addr(ui.builder.snapshot().address.String()))
+		ui.mode = modeAwaitDecision
+		return
+	}
+	ui.intentMeta = res.intentMeta
+	ui.lastTable = res.table
+	if res.intentMeta != nil {
+		ui.currentIntent = res.intentMeta.String()
+	}
+	var unquotable *unquotableError
+	if errors.As(res.err, &unquotable) {
+		// The table says why it couldn't be quoted, it's shown like any other.
+		res.err = nil
+	}
+	if res.err != nil {
+		var mapErr *MissingSymbolMappingError
+		if errors.As(res.err, &mapErr) {
+			ui.pendingMapping = &symbolMappingRequest{symbol: mapErr.Symbol, mint: mapErr.Mint}
+			ui.table.setLines(splitLines(renderSymbolMapping(*ui.pendingMapping, ui.builder.snapshot(), userAliases)))
+			ui.statusMessage = fmt.Sprintf("Map %s → %s? (y/n, s for this session only)", mapErr.Symbol, mapErr.Mint)
+			ui.mode = modeAwaitDecision
+		} else if res.poolSwitch {
+			// NOTE(@hadydotai): The old table belongs to the old pool, keeping it around is just lying to the user.
+			ui.table.setLines(nil)
+			ui.errPane.set(fmt.Sprintf("intent failed on the new pool: %v", res.err))
+			ui.statusMessage = fmt.Sprintf("Switched to pool %s. Press c to change intent.", Addr(ui.builder.snapshot().address.String()))
+			ui.mode = modeAwaitDecision
+		} else {
+			ui.errPane.set(fmt.Sprintf("failed to compute intent: %v", res.err))
+			ui.mode = modeAwaitDecision
+		}
+	} else {
+		ui.table.setLines(splitLines(res.table))
+		ui.table.flash(350 * time.Millisecond)
+		ui.statusMessage = ""
+		ui.mode = modeAwaitDecision
+	}
+}
+
 func (ui *termUI) pollEvents(ctx context.Context, eventCh chan<- termbox.Event) {
 	for {
 		ev := termbox.PollEvent()
@@ -178,10 +340,14 @@ func (ui *termUI) pollEvents(ctx context.Context, eventCh chan<- termbox.Event)
 func (ui *termUI) startCompute(intent string) {
 	ui.busy = true
 	ui.mode = modeBusy
+	ui.busyPool = ""
 	ui.busyIntent = intent
 	ui.intentInput = intent
 	ui.spinnerFrame = 0
 	ui.statusMessage = ""
+	ui.errPane.clear()
+	// A fresh intent gets a fresh shot at every pool.
+	clear(ui.triedPools)
 	go func(intent string) {
 		tableStr, intentMeta, err := ui.builder.Build(intent)
 		select {
@@ -191,6 +357,258 @@ func (ui *termUI) startCompute(intent string) {
 	}(intent)
 }
 
+// startCandles charts the pool's price history from its observation ring in the background, shown under the quote.
+func (ui *termUI) startCandles() {
+	ui.busy = true
+	ui.busyCandles = true
+	ui.mode = modeBusy
+	ui.spinnerFrame = 0
+	ui.statusMessage = ""
+	ui.errPane.clear()
+	go func() {
+		res := renderResult{candles: true}
+		tb := ui.builder
+		snap := tb.snapshot()
+		var candles []candle
+		var interval time.Duration
+		ctx, cancel := deadlines.forQuote(tb.ctx)
+		candles, interval, res.err = poolCandles(ctx, tb.client, snap.pool, 0, 60)
+		cancel()
+		if res.err == nil {
+			unit := snap.symm.SymFrom(snap.pool.Token1Mint) + " per " + snap.symm.SymFrom(snap.pool.Token0Mint)
+			res.table = renderCandles(candles, interval, 12, unit, int(snap.pool.Mint0Decimals)+int(snap.pool.Mint1Decimals))
+		}
+		select {
+		case ui.resultCh <- res:
+		case <-ui.done:
+		}
+	}()
+}
+
+// startPoolSwitch resolves the target to a pool, loads it in the background and recomputes the current intent
+// against it. If anything goes wrong before the pool is swapped in, the builder stays on the previous pool.
+func (ui *termUI) startPoolSwitch(target string) {
+	ui.busy = true
+	ui.mode = modeBusy
+	ui.busyPool = target
+	ui.spinnerFrame = 0
+	ui.statusMessage = ""
+	ui.errPane.clear()
+	intent := ui.intentInput
+	if strings.TrimSpace(intent) == "" {
+		intent = ui.currentIntent
+	}
+	go func(target, intent string) {
+		res := renderResult{poolSwitch: true}
+		tb := ui.builder
+		poolPubK, err := resolvePoolTarget(tb.ctx, tb.client, target, tb.symbols())
+		var loaded *loadedPool
+		if err == nil {
+			loaded, err = loadPool(tb.ctx, tb.client, poolPubK)
+		}
+		if err != nil {
+			res.poolErr = err
+		} else {
+			tb.usePool(loaded)
+			res.table, res.intentMeta, res.err = tb.Build(intent)
+		}
+		select {
+		case ui.resultCh <- res:
+		case <-ui.done:
+		}
+	}(target, intent)
+}
+
+// startExecution plans, signs, sends and confirms the swap in the background, reporting each stage on execCh.
+func (ui *termUI) startExecution(intent *CPIntent) {
+	ui.busy = true
+	ui.mode = modeExecuting
+	ui.spinnerFrame = 0
+	ui.statusMessage = ""
+	ui.errPane.clear()
+	ui.execStage = "planning transaction"
+	ex := ui.executor
+	builder := ui.builder
+	snap := builder.snapshot()
+	poolPubKey := snap.address
+	ui.triedPools[poolPubKey] = true
+	tried := make(map[solana.PublicKey]bool, len(ui.triedPools))
+	for pk := range ui.triedPools {
+		tried[pk] = true
+	}
+	inSymbol := snap.symm.SymFrom(intent.TokenIn.Mint)
+	outSymbol := snap.symm.SymFrom(intent.TokenOut.Mint)
+	go func() {
+		send := func(upd execUpdate) bool {
+			select {
+			case ui.execCh <- upd:
+				return true
+			case <-ui.done:
+				return false
+			}
+		}
+		// fail reports the failure, and when it's down to the pool and fallback is on, the next best pool to retry on.
+		fail := func(err error, upd execUpdate) {
+			upd.done, upd.err = true, err
+			if ex.fallbackPools && asPoolFailure(poolPubKey, err) != nil {
+				if !send(execUpdate{stage: "looking for another pool for the pair"}) {
+					return
+				}
+				if candidates, ferr := alternatePools(ex.ctx, ex.client, builder, intent.String(), tried); ferr == nil && len(candidates) > 0 {
+					upd.fallback = &candidates[0]
+				}
+			}
+			send(upd)
+		}
+		if err := snap.venue.CheckTradable(time.Now()); err != nil {
+			fail(err, execUpdate{})
+			return
+		}
+		sendCtx, cancel := deadlines.forSend(ex.ctx)
+		defer cancel()
+		intent, err := refreshPercentIntent(sendCtx, ex.client, builder, intent)
+		if err != nil {
+			fail(err, execUpdate{})
+			return
+		}
+		if err := checkQuoteDrift(sendCtx, ex.client, builder, intent); err != nil {
+			upd := execUpdate{done: true, err: err}
+			var drift *quoteDriftError
+			if errors.As(err, &drift) {
+				// Quoted again in full, report and all, for the user to confirm at the new price or leave.
+				if report, fresh, berr := builder.Build(intent.String()); berr == nil && fresh != nil {
+					upd.requote = &renderResult{table: report, intentMeta: fresh}
+				}
+			}
+			send(upd)
+			return
+		}
+		if minOutFromSim {
+			if intent, err = guardFromSimulation(sendCtx, ex.client, ex.payer.PublicKey(), intent, snap.slippageRat); err != nil {
+				fail(err, execUpdate{})
+				return
+			}
+		}
+		hooked := newHookSwap(intent, snap.symm, ex.payer.PublicKey())
+		if err := preSendHooks(sendCtx, hooked); err != nil {
+			fail(err, execUpdate{})
+			return
+		}
+		plan, err := planSwap(sendCtx, ex.client, ex.payer.PublicKey(), intent)
+		if err != nil {
+			fail(err, execUpdate{})
+			return
+		}
+		if !send(execUpdate{stage: "signing transaction"}) {
+			return
+		}
+		tx, err := signTransaction(sendCtx, ex.client, ex.payer, plan.instructions)
+		if err != nil {
+			fail(err, execUpdate{})
+			return
+		}
+		if !send(execUpdate{stage: "sending transaction"}) {
+			return
+		}
+		sig, err := sendTransaction(sendCtx, ex.client, tx)
+		if err != nil {
+			upd := execUpdate{}
+			if !sendRejected(err) {
+				upd.sig = tx.Signatures[0] // it may have gone out, see execFailure
+			}
+			fail(err, upd)
+			return
+		}
+		if sig, err = landTransaction(sendCtx, ex.client, ex.payer, tx, plan.instructions, nil); err != nil {
+			fail(err, execUpdate{sig: sig})
+			return
+		}
+		if !send(execUpdate{stage: fmt.Sprintf("waiting for confirmation of %s", Addr(sig.String())), sig: sig}) {
+			return
+		}
+		summary, waitErr := awaitSwapSummary(sendCtx, ex.client, sig, intent.TokenIn, intent.TokenOut, inSymbol, outSymbol)
+		postConfirmHooks(sendCtx, hooked, summary)
+		if summary.Status == "failed" {
+			fail(&txFailedError{sig: sig, txErr: summary.TxErr, logs: summary.Logs}, execUpdate{sig: sig, summary: &summary})
+			return
+		}
+		if closeEmptyATAs {
+			if _, err := closeEmptySwapAccounts(sendCtx, ex.client, ex.payer, intent); err != nil && waitErr == nil {
+				waitErr = err
+			}
+		}
+		send(execUpdate{done: true, sig: sig, summary: &summary, warning: waitErr})
+	}()
+}
+
+func (ui *termUI) handleExecUpdate(upd execUpdate) {
+	if !upd.done {
+		ui.execStage = upd.stage
+		return
+	}
+	ui.busy = false
+	ui.spinnerFrame = 0
+	ui.mode = modeResult
+	// NOTE(@hadydotai): The quote we just executed is spent, holding on to it would let a stray 'y' send it twice.
+	ui.intentMeta = nil
+	var receipt string
+	if upd.summary != nil {
+		receipt = renderTxSummary(*upd.summary) + "Explorer: " + explorerTxURL(ui.executor.network, upd.sig) + "\n"
+		ui.receipts = append(ui.receipts, receipt)
+	}
+	if upd.err != nil {
+		ui.errPane.set(execFailure(upd))
+		ui.statusMessage = ""
+		ui.table.setLines(splitLines(receipt))
+		if rq := upd.requote; rq != nil {
+			// NOTE(@hadydotai): Same as a fallback pool, the new quote only goes up on screen, y sends it.
+			ui.intentMeta = rq.intentMeta
+			ui.lastTable = rq.table
+			ui.table.setLines(splitLines(rq.table))
+			ui.table.flash(350 * time.Millisecond)
+			ui.statusMessage = "The price moved before sending, the new quote is above, press y to swap at it."
+			ui.mode = modeAwaitDecision
+		}
+		if next := upd.fallback; next != nil {
+			// NOTE(@hadydotai): The user still has to say yes, this only gets the next best pool quoted and on screen.
+			ui.builder.usePool(next.loaded)
+			ui.intentMeta = next.intent
+			ui.lastTable = next.report
+			ui.table.setLines(splitLines(next.report))
+			ui.table.flash(350 * time.Millisecond)
+			ui.statusMessage = fmt.Sprintf("Next best pool %s is quoted above, press y to retry there.", Addr(next.loaded.address.String()))
+			ui.mode = modeAwaitDecision
+		}
+		return
+	}
+	ui.table.setLines(splitLines(receipt))
+	ui.table.flash(350 * time.Millisecond)
+	ui.statusMessage = "Swap sent."
+	if upd.warning != nil {
+		ui.errPane.set(fmt.Sprintf("confirmation check failed: %v", upd.warning))
+	}
+}
+
+// execFailure is what the error pane says about a swap that didn't go through. A signature without a summary is a
+// send that failed without the node saying no, it may have gone out and land yet, and telling the user nothing was
+// sent would have them swap again.
+func execFailure(upd execUpdate) string {
+	var expired *expiredTxError
+	switch {
+	case upd.summary != nil, errors.As(upd.err, &expired):
+		return fmt.Sprintf("swap failed: %v", upd.err)
+	case upd.sig.IsZero():
+		return fmt.Sprintf("swap failed, nothing was sent: %v", upd.err)
+	default:
+		return fmt.Sprintf("send failed, the transaction may still land: %s, check it with `why %s` before swapping again: %v", upd.sig, upd.sig, upd.err)
+	}
+}
+
+// Receipts returns the rendered result of every swap executed during the session.
+func (ui *termUI) Receipts() []string {
+	return ui.receipts
+}
+
 func (ui *termUI) rerunLastIntent() {
 	intent := ui.intentInput
 	if strings.TrimSpace(intent) == "" {
@@ -198,56 +616,203 @@ func (ui *termUI) rerunLastIntent() {
 	}
 	if strings.TrimSpace(intent) == "" {
 		ui.statusMessage = "No previous intent to recompute. Press c to enter a new intent."
+		ui.mode = modeAwaitDecision
 		return
 	}
 	ui.startCompute(intent)
 }
 
+// rewriteIntent quotes what rewrite makes of the intent on screen, for a quick what-if next to it. The new intent goes
+// into the prompt's history so c and Up gets back to either.
+func (ui *termUI) rewriteIntent(rewrite func(line string) (string, error)) {
+	line := ui.intentInput
+	if strings.TrimSpace(line) == "" {
+		line = ui.currentIntent
+	}
+	if strings.TrimSpace(line) == "" {
+		ui.statusMessage = "No intent to change. Press c to enter one."
+		return
+	}
+	next, err := rewrite(line)
+	if err != nil {
+		ui.errPane.set(fmt.Sprintf("can't change %q: %v", line, err))
+		return
+	}
+	ui.inputs[promptKindIntent].remember(next)
+	ui.startCompute(next)
+}
+
+// poolSide is which of the pool's tokens token is, 0 or 1.
+func (snap quoteSnapshot) poolSide(token string) (int, error) {
+	if mint, ok := snap.symm.MaybeMintFromToken(token); ok {
+		switch {
+		case mint.Equals(snap.pool.Token0Mint):
+			return 0, nil
+		case mint.Equals(snap.pool.Token1Mint):
+			return 1, nil
+		}
+	}
+	return 0, fmt.Errorf("%s isn't one of the pool's tokens", token)
+}
+
+// otherToken is the pool token token isn't, by symbol, or by mint when it has none.
+func (snap quoteSnapshot) otherToken(token string) (string, error) {
+	side, err := snap.poolSide(token)
+	if err != nil {
+		return "", err
+	}
+	other := snap.pool.Token1Mint
+	if side == 1 {
+		other = snap.pool.Token0Mint
+	}
+	return cmp.Or(snap.symm.SymFrom(other), other.String()), nil
+}
+
+// nudgeAmount quotes the intent on screen again with its amount steps -nudge-steps up, or down when negative.
+func (ui *termUI) nudgeAmount(steps int) {
+	ui.rewriteIntent(func(line string) (string, error) {
+		ii, err := parseIntent(line)
+		if err != nil {
+			return "", err
+		}
+		snap := ui.builder.snapshot()
+		decimals := snap.pool.Mint0Decimals
+		if side, err := snap.poolSide(ii.TargetSymbol); err != nil {
+			return "", err
+		} else if side == 1 {
+			decimals = snap.pool.Mint1Decimals
+		}
+		return nudgeIntent(line, steps, int(decimals))
+	})
+}
+
 func (ui *termUI) handleKey(ev termbox.Event) (userDecision, bool) {
 	if ev.Key == termbox.KeyCtrlC {
+		if ui.mode == modeExecuting {
+			// NOTE(@hadydotai): Bailing out here doesn't unsend anything, the transaction may still land and we'd never
+			// show the user what happened. Make them wait it out.
+			ui.statusMessage = "A transaction is in flight, wait for it to confirm before quitting."
+			return userDecisionNOOP, false
+		}
 		return userDecisionBailout, true
 	}
+	if ui.help.visible {
+		ui.help.handleKey(ev)
+		return userDecisionNOOP, false
+	}
+	// NOTE(@hadydotai): ? is a character like any other while typing into a prompt, F1 works everywhere.
+	if ev.Key == termbox.KeyF1 || (ev.Ch == '?' && ui.mode != modePrompt) {
+		ui.help.open()
+		return userDecisionNOOP, false
+	}
+	// NOTE(@hadydotai): Page keys always scroll the table, arrows only when there's no input field wanting them.
+	if ev.Key == termbox.KeyPgup || ev.Key == termbox.KeyPgdn {
+		ui.table.handleKey(ev)
+		return userDecisionNOOP, false
+	}
 	switch ui.mode {
+	case modeExecuting:
+		ui.table.handleKey(ev)
+		return userDecisionNOOP, false
+	case modeResult:
+		if ui.table.handleKey(ev) {
+			return userDecisionNOOP, false
+		}
+		switch ev.Ch {
+		case 'a', 'A':
+			ui.rerunLastIntent()
+		case 'q', 'Q', 'n', 'N':
+			return userDecisionBailout, true
+		}
+		if ev.Key == termbox.KeyEsc {
+			return userDecisionBailout, true
+		}
 	case modeBusy:
 		if ev.Key == termbox.KeyEsc {
 			return userDecisionBailout, true
 		}
+		ui.table.handleKey(ev)
 	case modeAwaitDecision:
+		if ui.table.handleKey(ev) {
+			return userDecisionNOOP, false
+		}
 		if ui.pendingMapping != nil {
 			switch ev.Ch {
 			case 'y', 'Y':
 				symbol := ui.pendingMapping.symbol
 				mint := ui.pendingMapping.mint
-				ui.builder.symm.MapSymToMint(symbol, mint)
+				ui.builder.mapSymbol(symbol, mint)
 				ui.pendingMapping = nil
-				ui.statusMessage = fmt.Sprintf("Mapped %s to %s. Recomputing...", symbol, Addr(mint))
+				if _, err := userAliases.add(symbol, mint); err != nil {
+					ui.errPane.set(fmt.Sprintf("mapped %s for this session, saving the alias failed: %v", symbol, err))
+					ui.statusMessage = fmt.Sprintf("Mapped %s to %s. Recomputing...", symbol, Addr(mint))
+				} else {
+					ui.statusMessage = fmt.Sprintf("Mapped %s to %s and saved it to %s. Recomputing...", symbol, Addr(mint), userAliases.path)
+				}
+				ui.rerunLastIntent()
+				return userDecisionNOOP, false
+			case 's', 'S':
+				symbol := ui.pendingMapping.symbol
+				mint := ui.pendingMapping.mint
+				ui.builder.mapSymbol(symbol, mint)
+				ui.pendingMapping = nil
+				ui.statusMessage = fmt.Sprintf("Mapped %s to %s for this session. Recomputing...", symbol, Addr(mint))
 				ui.rerunLastIntent()
 				return userDecisionNOOP, false
 			case 'n', 'N':
 				ui.statusMessage = fmt.Sprintf("Symbol %s remains unmapped. Press c to change intent.", ui.pendingMapping.symbol)
 				ui.pendingMapping = nil
+				ui.table.setLines(nil)
 				return userDecisionNOOP, false
 			}
 		}
 		switch ev.Ch {
 		case 'y', 'Y':
-			return userDecisionProceed, true
+			if ui.readOnly {
+				ui.statusMessage = "Read-only, no -hotwallet loaded. Quotes only, restart with -hotwallet to execute."
+				return userDecisionNOOP, false
+			}
+			if ui.executor == nil {
+				return userDecisionProceed, true
+			}
+			if ui.intentMeta == nil {
+				ui.statusMessage = "Nothing to execute. Press c to enter an intent."
+				return userDecisionNOOP, false
+			}
+			ui.startExecution(ui.intentMeta)
+			return userDecisionNOOP, false
 		case 'n', 'N':
 			return userDecisionReject, true
 		case 'c', 'C':
-			ui.pendingMapping = nil
-			ui.mode = modePrompt
-			ui.promptBuffer = ui.promptBuffer[:0]
-			ui.statusMessage = "Enter a new intent (<verb> <amount> <token-symbol>) and press Enter."
-			ui.cursorVisible = true
-			ui.promptKind = promptKindIntent
+			ui.openPrompt(promptKindIntent, "Enter a new intent (<verb> <amount> <token-symbol>) and press Enter.")
 		case 's', 'S':
-			ui.pendingMapping = nil
-			ui.mode = modePrompt
-			ui.promptBuffer = ui.promptBuffer[:0]
-			ui.statusMessage = "Enter slippage percent (e.g. 0.5) and press Enter."
-			ui.cursorVisible = true
-			ui.promptKind = promptKindSlippage
+			ui.openPrompt(promptKindSlippage, "Enter slippage percent (e.g. 0.5) and press Enter.")
+		case 'p', 'P':
+			ui.openPrompt(promptKindPool, "Enter a pool address or pair (e.g. SOL/USDC) and press Enter.")
+		case 'k', 'K':
+			ui.startCandles()
+		case '=':
+			ui.nudgeAmount(1)
+		case '-':
+			ui.nudgeAmount(-1)
+		case '+':
+			ui.nudgeAmount(10)
+		case '_':
+			ui.nudgeAmount(-10)
+		case 'f', 'F':
+			ui.rewriteIntent(flipIntent)
+		case 'd', 'D':
+			ui.rewriteIntent(func(line string) (string, error) {
+				ii, err := parseIntent(line)
+				if err != nil {
+					return "", err
+				}
+				other, err := ui.builder.snapshot().otherToken(ii.TargetSymbol)
+				if err != nil {
+					return "", err
+				}
+				return retargetIntent(line, other)
+			})
 		}
 		if ev.Key == termbox.KeyEsc {
 			return userDecisionReject, true
@@ -255,137 +820,209 @@ func (ui *termUI) handleKey(ev termbox.Event) (userDecision, bool) {
 	case modePrompt:
 		switch ev.Key {
 		case termbox.KeyEnter:
-			value := strings.TrimSpace(string(ui.promptBuffer))
-			switch ui.promptKind {
-			case promptKindIntent:
-				if value == "" {
-					ui.statusMessage = "Intent cannot be empty."
-					return userDecisionNOOP, false
-				}
-				ui.promptBuffer = ui.promptBuffer[:0]
-				ui.startCompute(value)
-				return userDecisionNOOP, false
-			case promptKindSlippage:
-				if value == "" {
-					ui.statusMessage = "Slippage cannot be empty."
-					return userDecisionNOOP, false
-				}
-				parsed, err := strconv.ParseFloat(value, 64)
-				if err != nil {
-					ui.statusMessage = fmt.Sprintf("invalid slippage: %v", err)
-					return userDecisionNOOP, false
-				}
-				if err := ui.builder.SetSlippagePct(parsed); err != nil {
-					ui.statusMessage = err.Error()
-					return userDecisionNOOP, false
-				}
-				ui.promptBuffer = ui.promptBuffer[:0]
-				ui.mode = modeBusy
-				intent := ui.intentInput
-				if intent == "" {
-					intent = ui.currentIntent
-				}
-				if strings.TrimSpace(intent) == "" {
-					intent = "pay 100"
-				}
-				ui.startCompute(intent)
-				return userDecisionNOOP, false
-			}
+			ui.submitPrompt()
+			return userDecisionNOOP, false
 		case termbox.KeyEsc:
+			ui.input().reset()
 			ui.mode = modeAwaitDecision
-			ui.promptBuffer = ui.promptBuffer[:0]
-			ui.statusMessage = "Press y=yes, n=no, c=change intent, s=slippage."
+			ui.statusMessage = ""
 			ui.cursorVisible = true
 			return userDecisionNOOP, false
-		case termbox.KeyBackspace, termbox.KeyBackspace2:
-			if len(ui.promptBuffer) > 0 {
-				ui.promptBuffer = ui.promptBuffer[:len(ui.promptBuffer)-1]
-			}
-			return userDecisionNOOP, false
 		}
-		if ev.Ch != 0 {
-			ui.promptBuffer = append(ui.promptBuffer, ev.Ch)
-		} else if ev.Key == termbox.KeySpace {
-			ui.promptBuffer = append(ui.promptBuffer, ' ')
+		if ui.input().handleKey(ev) {
+			ui.cursorVisible = true
 		}
 	}
 	return userDecisionNOOP, false
 }
 
+// submitPrompt acts on the value typed into the prompt. Invalid values keep the prompt open so they can be fixed,
+// rather than making the user retype them.
+func (ui *termUI) submitPrompt() {
+	field := ui.input()
+	value := strings.TrimSpace(field.value())
+	switch ui.promptKind {
+	case promptKindIntent:
+		if value == "" {
+			ui.errPane.set("intent cannot be empty")
+			return
+		}
+		field.submit()
+		ui.startCompute(value)
+	case promptKindSlippage:
+		if value == "" {
+			ui.errPane.set("slippage cannot be empty")
+			return
+		}
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			ui.errPane.set(fmt.Sprintf("invalid slippage: %v", err))
+			return
+		}
+		if err := ui.builder.SetSlippagePct(parsed); err != nil {
+			ui.errPane.set(err.Error())
+			return
+		}
+		field.submit()
+		intent := ui.intentInput
+		if intent == "" {
+			intent = ui.currentIntent
+		}
+		if strings.TrimSpace(intent) == "" {
+			intent = "pay 100"
+		}
+		ui.startCompute(intent)
+	case promptKindPool:
+		if value == "" {
+			ui.errPane.set("pool cannot be empty")
+			return
+		}
+		field.submit()
+		ui.startPoolSwitch(value)
+	}
+}
+
+// bindings are the keys that do something in the current mode, shown in the help bar.
+func (ui *termUI) bindings() []keyBinding {
+	scroll := keyBinding{"↑↓←→/PgUp/PgDn", "scroll"}
+	help := keyBinding{"?", "help"}
+	if ui.help.visible {
+		return []keyBinding{{"Esc/?", "close help"}, {"↑↓/PgUp/PgDn", "scroll"}}
+	}
+	switch ui.mode {
+	case modePrompt:
+		return []keyBinding{{"Enter", "submit"}, {"Esc", "cancel"}, {"↑↓", "history"}, {"←→", "move"}, {"Ctrl+U", "clear"}, {"PgUp/PgDn", "scroll"}, {"F1", "help"}}
+	case modeBusy:
+		return []keyBinding{{"Esc", "quit"}, help}
+	case modeExecuting:
+		return []keyBinding{scroll, help}
+	case modeResult:
+		return []keyBinding{{"a", "another swap"}, {"q", "quit"}, scroll, help}
+	}
+	if ui.pendingMapping != nil {
+		return []keyBinding{{"y", "map and save"}, {"s", "this session only"}, {"n", "don't map"}, {"c", "change intent"}, {"Esc", "quit"}, help}
+	}
+	if ui.readOnly {
+		return []keyBinding{{"n", "quit"}, {"c", "change intent"}, {"s", "slippage"}, {"p", "switch pool"}, {"-/=", "size"}, {"f", "flip"}, {"d", "other token"}, {"k", "candles"}, scroll, help}
+	}
+	proceed := "proceed"
+	if ui.executor != nil {
+		proceed = "execute"
+	}
+	return []keyBinding{{"y", proceed}, {"n", "quit"}, {"c", "change intent"}, {"s", "slippage"}, {"p", "switch pool"}, {"-/=", "size"}, {"f", "flip"}, {"d", "other token"}, {"k", "candles"}, scroll, help}
+}
+
+/*
+NOTE(@hadydotai): Layout, top to bottom:
+
+	header     pool and slippage, one row
+	table      whatever is left, scrollable
+	errors     up to three rows, only when there's an error
+	status     one row
+	prompt     one row
+	help bar   one row
+
+The help overlay, when open, covers everything above the status line.
+
+When the terminal gets too short, the table gives up its rows first, then the header.
+*/
 func (ui *termUI) draw() {
 	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
 	width, height := termbox.Size()
-	tableArea := max(height-2, 0)
-	linesToShow := min(len(ui.tableLines), tableArea)
-	startRow := 0
-	if linesToShow < tableArea {
-		startRow = tableArea - linesToShow
+	bottom := height
+	if bottom > 0 {
+		bottom--
+		helpBar{bindings: ui.bindings()}.draw(rect{x: 0, y: bottom, w: width, h: 1})
 	}
-	fg := termbox.ColorDefault
-	bg := termbox.ColorDefault
-	flashActive := time.Now().Before(ui.tableFlashUntil)
-	if flashActive {
-		fg = termbox.ColorWhite | termbox.AttrBold
-		bg = termbox.ColorGreen
+	if bottom > 0 {
+		bottom--
+		ui.drawPrompt(rect{x: 0, y: bottom, w: width, h: 1})
 	}
-	for i := range linesToShow {
-		ui.drawTextColor(0, startRow+i, width, ui.tableLines[i], fg, bg)
+	if bottom > 0 {
+		bottom--
+		ui.drawStatus(rect{x: 0, y: bottom, w: width, h: 1})
 	}
-	if height >= 2 {
-		ui.drawText(0, height-2, width, ui.statusLine())
+	if errLines := ui.errPane.lines(width, min(3, max(bottom-1, 0))); len(errLines) > 0 {
+		bottom -= len(errLines)
+		ui.errPane.draw(rect{x: 0, y: bottom, w: width, h: len(errLines)})
 	}
-	if height >= 1 {
-		ui.drawText(0, height-1, width, ui.promptLine())
-		ui.drawCursor(width, height-1)
+	top := 0
+	if bottom > 1 {
+		ui.drawHeader(rect{x: 0, y: 0, w: width, h: 1})
+		top = 1
+	}
+	ui.table.draw(rect{x: 0, y: top, w: width, h: bottom - top})
+	if ui.help.visible {
+		ui.help.draw(rect{x: 0, y: 0, w: width, h: bottom})
 	}
 	termbox.Flush()
 }
 
-func (ui *termUI) drawText(x, y, width int, text string) {
-	ui.drawTextColor(x, y, width, text, termbox.ColorDefault, termbox.ColorDefault)
+func (ui *termUI) drawHeader(r rect) {
+	fillRow(r.x, r.y, r.w, termbox.ColorDefault|termbox.AttrReverse)
+	snap := ui.builder.snapshot()
+	header := fmt.Sprintf(" pool %s │ slippage %.2f%%", Addr(snap.address.String()), snap.slippagePct)
+	if stats := ui.builder.cache.stats(); stats.quotes > 1 {
+		header += fmt.Sprintf(" │ cached %.0f%% of %d quotes", stats.hitRate(), stats.quotes)
+	}
+	drawText(r.x, r.y, r.w, header, termbox.ColorDefault|termbox.AttrReverse, termbox.ColorDefault)
 }
 
-func (ui *termUI) drawTextColor(x, y, width int, text string, fg, bg termbox.Attribute) {
-	if y < 0 {
-		return
-	}
-	col := 0
-	for _, ch := range text {
-		if col >= width {
-			break
+func (ui *termUI) drawStatus(r rect) {
+	status := ui.statusLine()
+	used := drawText(r.x, r.y, r.w, status, termbox.ColorDefault, termbox.ColorDefault)
+	if pos := ui.table.position(); pos != "" {
+		if x := r.w - utf8.RuneCountInString(pos); x > used {
+			drawText(r.x+x, r.y, r.w-x, pos, termbox.ColorDefault|termbox.AttrDim, termbox.ColorDefault)
 		}
-		termbox.SetCell(x+col, y, ch, fg, bg)
-		col++
 	}
 }
 
+func (ui *termUI) drawPrompt(r rect) {
+	if ui.mode == modePrompt {
+		ui.input().draw(r, "> ", true, ui.cursorVisible)
+		return
+	}
+	drawText(r.x, r.y, r.w, ui.promptLine(), termbox.ColorDefault, termbox.ColorDefault)
+}
+
 func (ui *termUI) statusLine() string {
 	if ui.busy {
 		frame := spinnerFrames[ui.spinnerFrame%len(spinnerFrames)]
+		if ui.mode == modeExecuting {
+			return fmt.Sprintf("%c %s", frame, ui.execStage)
+		}
+		if ui.busyCandles {
+			return fmt.Sprintf("%c reading the pool's price history", frame)
+		}
+		if ui.busyPool != "" {
+			return fmt.Sprintf("%c loading pool %q", frame, ui.busyPool)
+		}
 		return fmt.Sprintf("%c computing intent %q", frame, ui.busyIntent)
 	}
 	if ui.statusMessage != "" {
 		return ui.statusMessage
 	}
-	if ui.mode == modePrompt {
+	switch ui.mode {
+	case modeResult:
+		return "Done."
+	case modePrompt:
 		return "Enter a new intent and press Enter."
 	}
-	return "Press y=yes, n=no, c=change intent, s=slippage."
+	if ui.intentMeta != nil {
+		return "Review the quote above."
+	}
+	return ""
 }
 
 func (ui *termUI) promptLine() string {
-	switch ui.mode {
-	case modePrompt:
-		return "> " + string(ui.promptBuffer)
-	default:
-		if ui.busy {
-			return "> ..."
-		}
-		if ui.currentIntent != "" {
-			return fmt.Sprintf("> current intent: %s", ui.currentIntent)
-		}
-		return "> press c to enter a new intent"
+	if ui.busy {
+		return "> ..."
 	}
+	if ui.currentIntent != "" {
+		return fmt.Sprintf("> current intent: %s", ui.currentIntent)
+	}
+	return "> press c to enter a new intent"
 }
 
 func splitLines(s string) []string {
@@ -395,31 +1032,3 @@ func splitLines(s string) []string {
 	}
 	return strings.Split(s, "\n")
 }
-
-func (ui *termUI) drawCursor(width, row int) {
-	if row < 0 || width <= 0 {
-		return
-	}
-	if ui.mode != modePrompt {
-		return
-	}
-	col := ui.promptCursorColumn()
-	if col >= width {
-		col = width - 1
-	}
-	if col < 0 {
-		return
-	}
-	ch := ' '
-	if ui.cursorVisible {
-		ch = '_'
-	}
-	termbox.SetCell(col, row, ch, termbox.ColorDefault, termbox.ColorDefault)
-}
-
-func (ui *termUI) promptCursorColumn() int {
-	if ui.mode != modePrompt {
-		return -1
-	}
-	return utf8.RuneCountInString("> " + string(ui.promptBuffer))
-}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nsf/termbox-go"
+)
+
+/*
+NOTE(@hadydotai): TUI components.
+
+The first TUI drew everything straight into termbox from one big draw function, which was fine until the tables grew
+past the terminal height and there was no way to see the top of them. These are the small building blocks the TUI is
+now laid out from. Each one owns its state, knows how to draw itself into a rect, and (where it makes sense) handles
+its own keys. None of them know anything about swaps, TableBuilder is still where the content comes from.
+*/
+
+type rect struct {
+	x, y, w, h int
+}
+
+// drawText draws text on a single row, clipped to width, and returns the number of cells used.
+func drawText(x, y, width int, text string, fg, bg termbox.Attribute) int {
+	if y < 0 || width <= 0 {
+		return 0
+	}
+	col := 0
+	for _, ch := range text {
+		if col >= width {
+			break
+		}
+		termbox.SetCell(x+col, y, ch, fg, bg)
+		col++
+	}
+	return col
+}
+
+func fillRow(x, y, width int, bg termbox.Attribute) {
+	for col := range max(width, 0) {
+		termbox.SetCell(x+col, y, ' ', termbox.ColorDefault, bg)
+	}
+}
+
+// scrollView shows a block of lines that may be taller or wider than the space it gets.
+type scrollView struct {
+	lines      []string
+	offset     int // first visible line
+	colOffset  int // first visible column
+	height     int // height of the last draw, used for paging
+	flashUntil time.Time
+}
+
+func (sv *scrollView) setLines(lines []string) {
+	sv.lines = lines
+	sv.offset = 0
+	sv.colOffset = 0
+}
+
+func (sv *scrollView) flash(d time.Duration) {
+	sv.flashUntil = time.Now().Add(d)
+}
+
+func (sv *scrollView) maxOffset() int {
+	return max(len(sv.lines)-sv.height, 0)
+}
+
+func (sv *scrollView) maxColOffset() int {
+	widest := 0
+	for _, line := range sv.lines {
+		widest = max(widest, utf8.RuneCountInString(line))
+	}
+	return max(widest-1, 0)
+}
+
+func (sv *scrollView) scrollBy(lines int) {
+	sv.offset = min(max(sv.offset+lines, 0), sv.maxOffset())
+}
+
+func (sv *scrollView) scrollColsBy(cols int) {
+	sv.colOffset = min(max(sv.colOffset+cols, 0), sv.maxColOffset())
+}
+
+func (sv *scrollView) page(pages int) {
+	sv.scrollBy(pages * max(sv.height-1, 1))
+}
+
+// handleKey scrolls the view, it reports whether the key was meant for it.
+func (sv *scrollView) handleKey(ev termbox.Event) bool {
+	switch ev.Key {
+	case termbox.KeyArrowUp:
+		sv.scrollBy(-1)
+	case termbox.KeyArrowDown:
+		sv.scrollBy(1)
+	case termbox.KeyArrowLeft:
+		sv.scrollColsBy(-4)
+	case termbox.KeyArrowRight:
+		sv.scrollColsBy(4)
+	case termbox.KeyPgup:
+		sv.page(-1)
+	case termbox.KeyPgdn:
+		sv.page(1)
+	case termbox.KeyHome:
+		sv.offset, sv.colOffset = 0, 0
+	case termbox.KeyEnd:
+		sv.offset = sv.maxOffset()
+	default:
+		return false
+	}
+	return true
+}
+
+// scrollable reports whether the content doesn't fit the last drawn height.
+func (sv *scrollView) scrollable() bool {
+	return len(sv.lines) > sv.height
+}
+
+func (sv *scrollView) draw(r rect) {
+	sv.height = max(r.h, 0)
+	sv.offset = min(sv.offset, sv.maxOffset())
+	if r.h <= 0 || r.w <= 0 {
+		return
+	}
+	fg, bg := termbox.ColorDefault, termbox.ColorDefault
+	if time.Now().Before(sv.flashUntil) {
+		fg = termbox.ColorWhite | termbox.AttrBold
+		bg = termbox.ColorGreen
+	}
+	textWidth := r.w
+	if sv.scrollable() {
+		textWidth-- // the last column is the scrollbar
+	}
+	visible := min(len(sv.lines)-sv.offset, r.h)
+	// NOTE(@hadydotai): Short content sits at the bottom, right above the status line, which is where the eye already is.
+	startRow := r.y + r.h - visible
+	for i := range visible {
+		line := []rune(sv.lines[sv.offset+i])
+		if sv.colOffset < len(line) {
+			line = line[sv.colOffset:]
+		} else {
+			line = nil
+		}
+		drawText(r.x, startRow+i, textWidth, string(line), fg, bg)
+	}
+	if sv.scrollable() {
+		sv.drawScrollbar(rect{x: r.x + r.w - 1, y: r.y, w: 1, h: r.h})
+	}
+}
+
+func (sv *scrollView) drawScrollbar(r rect) {
+	thumb := max(r.h*r.h/len(sv.lines), 1)
+	thumbStart := 0
+	if maxOff := sv.maxOffset(); maxOff > 0 {
+		thumbStart = (r.h - thumb) * sv.offset / maxOff
+	}
+	for row := range r.h {
+		ch := '│'
+		if row >= thumbStart && row < thumbStart+thumb {
+			ch = '█'
+		}
+		termbox.SetCell(r.x, r.y+row, ch, termbox.ColorDefault, termbox.ColorDefault)
+	}
+}
+
+// position describes which lines are on screen, e.g. "lines 1-20 of 45".
+func (sv *scrollView) position() string {
+	if !sv.scrollable() {
+		return ""
+	}
+	last := min(sv.offset+sv.height, len(sv.lines))
+	return fmt.Sprintf("lines %d-%d of %d", sv.offset+1, last, len(sv.lines))
+}
+
+// inputField is a single line editor with its own history, Up and Down walk through previously submitted values.
+type inputField struct {
+	buffer  []rune
+	cursor  int
+	history []string
+	histPos int    // index into history, len(history) means we're editing a fresh line
+	draft   []rune // the fresh line, kept while browsing history
+}
+
+func (f *inputField) value() string {
+	return string(f.buffer)
+}
+
+func (f *inputField) set(v string) {
+	f.buffer = []rune(v)
+	f.cursor = len(f.buffer)
+}
+
+func (f *inputField) reset() {
+	f.buffer = f.buffer[:0]
+	f.cursor = 0
+	f.draft = nil
+	f.histPos = len(f.history)
+}
+
+// remember appends v to the history, skipping blanks and immediate repeats.
+func (f *inputField) remember(v string) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return
+	}
+	if n := len(f.history); n == 0 || f.history[n-1] != v {
+		f.history = append(f.history, v)
+	}
+	f.histPos = len(f.history)
+}
+
+// submit records the current value in the history, clears the field and returns the value.
+func (f *inputField) submit() string {
+	v := strings.TrimSpace(f.value())
+	f.remember(v)
+	f.reset()
+	return v
+}
+
+func (f *inputField) historyPrev() {
+	if f.histPos == 0 || len(f.history) == 0 {
+		return
+	}
+	if f.histPos == len(f.history) {
+		f.draft = append([]rune(nil), f.buffer...)
+	}
+	f.histPos--
+	f.set(f.history[f.histPos])
+}
+
+func (f *inputField) historyNext() {
+	if f.histPos >= len(f.history) {
+		return
+	}
+	f.histPos++
+	if f.histPos == len(f.history) {
+		f.set(string(f.draft))
+		f.draft = nil
+		return
+	}
+	f.set(f.history[f.histPos])
+}
+
+func (f *inputField) insert(ch rune) {
+	f.buffer = append(f.buffer, 0)
+	copy(f.buffer[f.cursor+1:], f.buffer[f.cursor:])
+	f.buffer[f.cursor] = ch
+	f.cursor++
+}
+
+// handleKey edits the field, it reports whether the key was meant for it. Enter and Esc are left to the caller.
+func (f *inputField) handleKey(ev termbox.Event) bool {
+	switch ev.Key {
+	case termbox.KeyBackspace, termbox.KeyBackspace2:
+		if f.cursor > 0 {
+			f.buffer = append(f.buffer[:f.cursor-1], f.buffer[f.cursor:]...)
+			f.cursor--
+		}
+	case termbox.KeyDelete, termbox.KeyCtrlD:
+		if f.cursor < len(f.buffer) {
+			f.buffer = append(f.buffer[:f.cursor], f.buffer[f.cursor+1:]...)
+		}
+	case termbox.KeyArrowLeft, termbox.KeyCtrlB:
+		f.cursor = max(f.cursor-1, 0)
+	case termbox.KeyArrowRight, termbox.KeyCtrlF:
+		f.cursor = min(f.cursor+1, len(f.buffer))
+	case termbox.KeyHome, termbox.KeyCtrlA:
+		f.cursor = 0
+	case termbox.KeyEnd, termbox.KeyCtrlE:
+		f.cursor = len(f.buffer)
+	case termbox.KeyCtrlU:
+		f.buffer = f.buffer[:0]
+		f.cursor = 0
+	case termbox.KeyArrowUp, termbox.KeyCtrlP:
+		f.historyPrev()
+	case termbox.KeyArrowDown, termbox.KeyCtrlN:
+		f.historyNext()
+	case termbox.KeySpace:
+		f.insert(' ')
+	default:
+		if ev.Ch == 0 {
+			return false
+		}
+		f.insert(ev.Ch)
+	}
+	return true
+}
+
+// draw renders prefix followed by the field, scrolled horizontally so the cursor is always on screen.
+func (f *inputField) draw(r rect, prefix string, showCursor, cursorOn bool) {
+	if r.w <= 0 || r.h <= 0 {
+		return
+	}
+	used := drawText(r.x, r.y, r.w, prefix, termbox.ColorDefault, termbox.ColorDefault)
+	avail := r.w - used
+	if avail <= 0 {
+		return
+	}
+	start := 0
+	if f.cursor >= avail {
+		start = f.cursor - avail + 1
+	}
+	end := min(len(f.buffer), start+avail)
+	drawText(r.x+used, r.y, avail, string(f.buffer[start:end]), termbox.ColorDefault, termbox.ColorDefault)
+	if !showCursor {
+		return
+	}
+	col := r.x + used + f.cursor - start
+	if cursorOn {
+		ch := ' '
+		if f.cursor < len(f.buffer) {
+			ch = f.buffer[f.cursor]
+		}
+		termbox.SetCell(col, r.y, ch, termbox.ColorDefault|termbox.AttrReverse, termbox.ColorDefault)
+	}
+}
+
+type keyBinding struct {
+	key  string
+	desc string
+}
+
+// helpBar lists the keys that do something right now.
+type helpBar struct {
+	bindings []keyBinding
+}
+
+func (hb helpBar) draw(r rect) {
+	if r.w <= 0 || r.h <= 0 {
+		return
+	}
+	fillRow(r.x, r.y, r.w, termbox.ColorBlue)
+	col := 0
+	for i, b := range hb.bindings {
+		if i > 0 {
+			col += drawText(r.x+col, r.y, r.w-col, "  ", termbox.ColorWhite, termbox.ColorBlue)
+		}
+		col += drawText(r.x+col, r.y, r.w-col, b.key, termbox.ColorYellow|termbox.AttrBold, termbox.ColorBlue)
+		col += drawText(r.x+col, r.y, r.w-col, " "+b.desc, termbox.ColorWhite, termbox.ColorBlue)
+		if col >= r.w {
+			return
+		}
+	}
+}
+
+// errorPane shows the last error until it's cleared, wrapped to the width it's drawn in.
+type errorPane struct {
+	message string
+}
+
+func (ep *errorPane) set(msg string) {
+	ep.message = msg
+}
+
+func (ep *errorPane) clear() {
+	ep.message = ""
+}
+
+// lines wraps the message to width, capped at maxLines with the last line marked as truncated.
+func (ep *errorPane) lines(width, maxLines int) []string {
+	if ep.message == "" || width <= 2 || maxLines <= 0 {
+		return nil
+	}
+	wrapped := wrapText("✗ "+ep.message, width)
+	if len(wrapped) > maxLines {
+		wrapped = wrapped[:maxLines]
+		last := []rune(wrapped[maxLines-1])
+		if len(last) >= width {
+			last = last[:width-1]
+		}
+		wrapped[maxLines-1] = string(last) + "…"
+	}
+	return wrapped
+}
+
+func (ep *errorPane) draw(r rect) {
+	for i, line := range ep.lines(r.w, r.h) {
+		fillRow(r.x, r.y+i, r.w, termbox.ColorRed)
+		drawText(r.x, r.y+i, r.w, line, termbox.ColorWhite|termbox.AttrBold, termbox.ColorRed)
+	}
+}
+
+// wrapText breaks s into lines of at most width runes, preferring to break on spaces.
+func wrapText(s string, width int) []string {
+	if width <= 0 {
+		return nil
+	}
+	var out []string
+	for _, para := range strings.Split(s, "\n") {
+		line := []rune{}
+		for _, word := range strings.Fields(para) {
+			w := []rune(word)
+			for len(w) > width {
+				if len(line) > 0 {
+					out = append(out, string(line))
+					line = line[:0]
+				}
+				out = append(out, string(w[:width]))
+				w = w[width:]
+			}
+			switch {
+			case len(line) == 0:
+				line = append(line, w...)
+			case len(line)+1+len(w) <= width:
+				line = append(append(line, ' '), w...)
+			default:
+				out = append(out, string(line))
+				line = append([]rune{}, w...)
+			}
+		}
+		out = append(out, string(line))
+	}
+	return out
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/nsf/termbox-go"
+)
+
+func typeInto(f *inputField, s string) {
+	for _, ch := range s {
+		f.handleKey(termbox.Event{Type: termbox.EventKey, Ch: ch})
+	}
+}
+
+func key(k termbox.Key) termbox.Event {
+	return termbox.Event{Type: termbox.EventKey, Key: k}
+}
+
+func TestInputFieldEditing(t *testing.T) {
+	f := &inputField{}
+	typeInto(f, "pay SOL")
+	for range 3 {
+		f.handleKey(key(termbox.KeyArrowLeft))
+	}
+	f.handleKey(key(termbox.KeySpace))
+	typeInto(f, "1")
+	if got := f.value(); got != "pay  1SOL" {
+		t.Fatalf("got %q", got)
+	}
+	f.handleKey(key(termbox.KeyBackspace2))
+	f.handleKey(key(termbox.KeyBackspace2))
+	typeInto(f, "1 ")
+	if got := f.value(); got != "pay 1 SOL" {
+		t.Fatalf("got %q", got)
+	}
+	f.handleKey(key(termbox.KeyCtrlU))
+	if f.value() != "" || f.cursor != 0 {
+		t.Fatalf("expected cleared field, got %q at %d", f.value(), f.cursor)
+	}
+}
+
+func TestInputFieldHistory(t *testing.T) {
+	f := &inputField{}
+	f.remember("pay 1 SOL")
+	typeInto(f, "buy 5 USDC")
+	f.submit()
+	typeInto(f, "buy 5 USDC")
+	f.submit()
+	if len(f.history) != 2 {
+		t.Fatalf("expected repeats to be collapsed, got %v", f.history)
+	}
+
+	typeInto(f, "draft")
+	f.handleKey(key(termbox.KeyArrowUp))
+	if f.value() != "buy 5 USDC" {
+		t.Fatalf("got %q", f.value())
+	}
+	f.handleKey(key(termbox.KeyArrowUp))
+	f.handleKey(key(termbox.KeyArrowUp))
+	if f.value() != "pay 1 SOL" {
+		t.Fatalf("expected to stop at the oldest entry, got %q", f.value())
+	}
+	f.handleKey(key(termbox.KeyArrowDown))
+	f.handleKey(key(termbox.KeyArrowDown))
+	if f.value() != "draft" {
+		t.Fatalf("expected the draft back, got %q", f.value())
+	}
+}
+
+func TestScrollViewClamps(t *testing.T) {
+	sv := &scrollView{height: 10}
+	lines := make([]string, 25)
+	for i := range lines {
+		lines[i] = "row"
+	}
+	sv.setLines(lines)
+	sv.page(1)
+	if sv.offset != 9 {
+		t.Fatalf("expected a page to move by height-1, got %d", sv.offset)
+	}
+	sv.page(5)
+	if sv.offset != 15 {
+		t.Fatalf("expected offset clamped to 15, got %d", sv.offset)
+	}
+	if got := sv.position(); got != "lines 16-25 of 25" {
+		t.Fatalf("got %q", got)
+	}
+	sv.scrollBy(-100)
+	if sv.offset != 0 {
+		t.Fatalf("expected offset clamped to 0, got %d", sv.offset)
+	}
+	sv.setLines(lines[:3])
+	if sv.scrollable() || sv.position() != "" {
+		t.Fatalf("short content shouldn't scroll")
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	got := wrapText("failed to compute intent: unknown symbol FOO", 16)
+	want := []string{"failed to", "compute intent:", "unknown symbol", "FOO"}
+	if len(got) != len(want) {
+		t.Fatalf("got %q", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("line %d: got %q want %q", i, got[i], want[i])
+		}
+	}
+	ep := &errorPane{}
+	ep.set("a very long error message that will not fit in two lines of this width")
+	lines := ep.lines(20, 2)
+	if len(lines) != 2 || []rune(lines[1])[len([]rune(lines[1]))-1] != '…' {
+		t.Fatalf("expected truncated error pane, got %q", lines)
+	}
+}
+
+func TestSymbolMappingPrompt(t *testing.T) {
+	defer func(saved *symbolAliases) { userAliases = saved }(userAliases)
+	userAliases = &symbolAliases{path: filepath.Join(t.TempDir(), "aliases.json"), bySymbol: map[string]string{}}
+	api, addr := newTestAPIServer(t)
+	lp := *api.pools[addr].loaded
+	usdc := lp.pool.Token1Mint
+	lp.symbolsMap = SymbolMapping{
+		mintToSymbol: map[string]string{lp.pool.Token0Mint.String(): "SOL", usdc.String(): "ABCD"},
+		symbolToMint: map[string]solana.PublicKey{"SOL": lp.pool.Token0Mint, "ABCD": usdc},
+		unresolved:   map[string]struct{}{usdc.String(): {}},
+	}
+	tb, err := newTableBuilder(t.Context(), api.client, &lp, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ui := newTermUI(tb, nil)
+	t.Cleanup(func() { close(ui.done) })
+	ui.startCompute("buy 10 USDC")
+	ui.handleResult(<-ui.resultCh)
+	if ui.pendingMapping == nil || !strings.Contains(ui.statusMessage, "Map USDC → "+usdc.String()+"? (y/n") {
+		t.Fatalf("no mapping prompt, status %q", ui.statusMessage)
+	}
+	if panel := strings.Join(ui.table.lines, "\n"); !strings.Contains(panel, usdc.String()) || !strings.Contains(panel, "Other token") {
+		t.Errorf("mapping panel\n%s", panel)
+	}
+
+	// y saves the alias and quotes the intent again, this time it resolves.
+	ui.handleKey(termbox.Event{Type: termbox.EventKey, Ch: 'y'})
+	if userAliases.mint("USDC") != usdc.String() || !ui.busy {
+		t.Fatalf("alias %q, recomputing %v", userAliases.mint("USDC"), ui.busy)
+	}
+	res := <-ui.resultCh
+	if res.err != nil || res.intentMeta == nil {
+		t.Fatalf("recomputed intent failed: %v", res.err)
+	}
+	if saved, err := loadSymbolAliases(userAliases.path); err != nil || saved.mint("USDC") != usdc.String() {
+		t.Errorf("aliases file %v, %v", saved, err)
+	}
+}
+
+func TestExecFailure(t *testing.T) {
+	sig := solana.Signature{7}
+	timeout := errors.New("sending transaction failed: context deadline exceeded")
+	for _, tc := range []struct {
+		name string
+		upd  execUpdate
+		want string
+	}{
+		{"nothing sent", execUpdate{err: errors.New("planning failed")}, "swap failed, nothing was sent: planning failed"},
+		{"an ambiguous send", execUpdate{sig: sig, err: timeout}, "send failed, the transaction may still land: " + sig.String()},
+		{"expired", execUpdate{sig: sig, err: &expiredTxError{sig: sig, attempts: 1}}, "swap failed: transaction " + sig.String() + " expired"},
+		{"failed on chain", execUpdate{sig: sig, summary: &txSummaryData{Status: "failed"}, err: timeout}, "swap failed: sending"},
+	} {
+		if got := execFailure(tc.upd); !strings.HasPrefix(got, tc.want) {
+			t.Errorf("%s: %q, want it to start %q", tc.name, got, tc.want)
+		}
+	}
+}
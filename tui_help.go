@@ -0,0 +1,126 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+/*
+NOTE(@hadydotai): Help overlay.
+
+The help bar at the bottom says which keys do something, not what any of it means. F1 (or ? when not typing into a
+prompt) opens an overlay over the table that explains the intent language, slippage, price impact and the keys, for
+someone who's never used the client before. It's plain text wrapped to whatever width the overlay gets, so it stays
+readable on small terminals, and it scrolls like the table does.
+*/
+
+var helpText = `INTENTS
+
+An intent says what you want in three words: <verb> <amount> <token-symbol>.
+
+  pay 1 SOL     spend exactly 1 SOL, get as much of the other token as the pool gives (sell and swap mean the same)
+  buy 50 USDC   get exactly 50 USDC, pay whatever it costs in the other token (get means the same)
+
+Naming both tokens works too, and "at" adds a price limit, on the price of the token the amount is in:
+
+  swap 2 SOL for USDC        same as pay 2 SOL, checking the other token is USDC
+  buy USDC with 1 SOL        spend exactly 1 SOL on USDC
+  pay 1 SOL at >= 150 USDC   only if 1 SOL gets at least 150 USDC, never filling below it
+  sell 50% SOL               half of your SOL, "sell all SOL" keeps 0.01 SOL back for fees
+  buy $50 of BONK            spend $50 worth of the other token on BONK, at the -price-source price
+
+The symbol has to be one of the pool's two tokens. When a token has no symbol on chain you'll be asked to map the one you typed to its mint, check the mint, shown in full, before saying yes. y maps it, saves it to the aliases file so it's known next time too, and quotes the intent again. s maps it for this session only.
+
+SLIPPAGE
+
+The quote is what the pool would do right now. Other swaps can land before yours and move the price, slippage is how far it's allowed to move against you. Selling, the swap fails rather than pay out less than "min receive". Buying, it fails rather than take more than "max pay". A failed swap only costs the network fee, and right before sending the pool is quoted again: if the price already moved past your slippage nothing is sent, the new quote is shown for you to accept with y or leave. 0.5% is a sensible start, raise it (s) for volatile pools, knowing you may get a worse price.
+
+PRICE IMPACT
+
+Your own trade moves the price along the curve, so you get a worse rate than the pool's current price, the bigger the trade against a smaller pool, the worse. Impact (trade fee included) is known up front and is already in the quote, slippage is on top of it. If the impact is large, trade less or find a deeper pool (p).
+
+KEYS
+
+  y        execute the quote on screen, nothing is ever sent before you press y
+  n, Esc   quit without sending anything
+  c        enter a new intent
+  s        change slippage
+  p        switch pool, by address or by pair like SOL/USDC
+  - =      nudge the amount a -nudge-step down or up and quote again, _ and + (with shift) ten steps
+  f        flip the intent, buy what it sells or sell what it buys, same amount of the same token
+  d        put the amount in the pool's other token, buy 50 USDC becomes buy 50 SOL
+  k        chart the pool's recent price as candles under the quote
+  a        after a swap, start another one
+  ↑↓ PgUp PgDn   scroll
+  ? or F1  this help, Esc closes it
+  Ctrl+C   quit, except while a transaction is in flight
+
+New here? raydium-client tutorial walks you through a first swap on devnet.`
+
+// helpOverlay is the help text in a box, drawn over everything else while it's open.
+type helpOverlay struct {
+	visible bool
+	view    scrollView
+	width   int // width the text was last wrapped to
+}
+
+func (h *helpOverlay) open() {
+	h.visible = true
+	h.width = 0 // rewrap and start from the top
+}
+
+// handleKey closes the overlay on Esc, q, ? or F1 and scrolls it otherwise.
+func (h *helpOverlay) handleKey(ev termbox.Event) {
+	switch {
+	case ev.Key == termbox.KeyEsc, ev.Key == termbox.KeyF1, ev.Ch == '?', ev.Ch == 'q', ev.Ch == 'Q':
+		h.visible = false
+	default:
+		h.view.handleKey(ev)
+	}
+}
+
+// wrapHelp wraps the help text to width, keeping the indentation of the key and example lines.
+func wrapHelp(width int) []string {
+	var lines []string
+	for _, line := range strings.Split(helpText, "\n") {
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if line == "" || indent == 0 || width <= indent+1 {
+			lines = append(lines, wrapText(line, width)...)
+			continue
+		}
+		for _, w := range wrapText(line[indent:], width-indent) {
+			lines = append(lines, strings.Repeat(" ", indent)+w)
+		}
+	}
+	return lines
+}
+
+func (h *helpOverlay) draw(r rect) {
+	w := min(r.w-2, 100)
+	if w < 12 || r.h < 4 {
+		return
+	}
+	if h.width != w-4 {
+		h.view.setLines(wrapHelp(w - 4))
+		h.width = w - 4
+	}
+	// NOTE(@hadydotai): The box hugs the text, scrollView keeps short content at the bottom and help reads top down.
+	box := rect{x: r.x + (r.w-w)/2, y: r.y, w: w, h: min(r.h, len(h.view.lines)+2)}
+	inner := rect{x: box.x + 2, y: box.y + 1, w: box.w - 4, h: box.h - 2}
+	for row := range box.h {
+		fillRow(box.x, box.y+row, box.w, termbox.ColorDefault)
+		termbox.SetCell(box.x, box.y+row, '│', termbox.ColorCyan, termbox.ColorDefault)
+		termbox.SetCell(box.x+box.w-1, box.y+row, '│', termbox.ColorCyan, termbox.ColorDefault)
+	}
+	for col := range box.w {
+		termbox.SetCell(box.x+col, box.y, '─', termbox.ColorCyan, termbox.ColorDefault)
+		termbox.SetCell(box.x+col, box.y+box.h-1, '─', termbox.ColorCyan, termbox.ColorDefault)
+	}
+	termbox.SetCell(box.x, box.y, '┌', termbox.ColorCyan, termbox.ColorDefault)
+	termbox.SetCell(box.x+box.w-1, box.y, '┐', termbox.ColorCyan, termbox.ColorDefault)
+	termbox.SetCell(box.x, box.y+box.h-1, '└', termbox.ColorCyan, termbox.ColorDefault)
+	termbox.SetCell(box.x+box.w-1, box.y+box.h-1, '┘', termbox.ColorCyan, termbox.ColorDefault)
+	drawText(box.x+2, box.y, box.w-4, " Help ", termbox.ColorCyan|termbox.AttrBold, termbox.ColorDefault)
+	h.view.draw(inner)
+}
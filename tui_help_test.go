@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/nsf/termbox-go"
+)
+
+func TestWrapHelpKeepsIndentation(t *testing.T) {
+	for _, width := range []int{30, 60, 96} {
+		for _, line := range wrapHelp(width) {
+			if n := utf8.RuneCountInString(line); n > width {
+				t.Errorf("width %d: line %q is %d wide", width, line, n)
+			}
+		}
+	}
+	lines := wrapHelp(30)
+	for i, line := range lines {
+		if strings.HasPrefix(line, "  pay 1 SOL") {
+			if next := lines[i+1]; !strings.HasPrefix(next, "  ") {
+				t.Errorf("continuation of an example lost its indentation: %q", next)
+			}
+			return
+		}
+	}
+	t.Fatal("example line missing from the help")
+}
+
+func TestHelpOverlayKeys(t *testing.T) {
+	ui := newTermUI(nil, nil)
+	ui.mode = modeAwaitDecision
+	ui.handleKey(termbox.Event{Type: termbox.EventKey, Ch: '?'})
+	if !ui.help.visible {
+		t.Fatal("? should open the help")
+	}
+	// Keys go to the overlay while it's open, y must not execute anything.
+	if decision, _ := ui.handleKey(termbox.Event{Type: termbox.EventKey, Ch: 'y'}); decision != userDecisionNOOP {
+		t.Errorf("y with the help open = %v", decision)
+	}
+	ui.handleKey(key(termbox.KeyEsc))
+	if ui.help.visible {
+		t.Fatal("Esc should close the help")
+	}
+
+	ui.openPrompt(promptKindIntent, "")
+	ui.handleKey(termbox.Event{Type: termbox.EventKey, Ch: '?'})
+	if ui.help.visible || ui.input().value() != "?" {
+		t.Errorf("? in a prompt should be typed, got help %v, input %q", ui.help.visible, ui.input().value())
+	}
+	ui.handleKey(key(termbox.KeyF1))
+	if !ui.help.visible {
+		t.Error("F1 should open the help from a prompt")
+	}
+}
+
+func TestReadOnlyRefusesExecute(t *testing.T) {
+	ui := newTermUI(nil, &swapExecutor{})
+	ui.readOnly = true
+	ui.mode = modeAwaitDecision
+	if decision, done := ui.handleKey(termbox.Event{Type: termbox.EventKey, Ch: 'y'}); decision != userDecisionNOOP || done {
+		t.Errorf("y without a wallet = %v, %v", decision, done)
+	}
+	if ui.mode != modeAwaitDecision || !strings.Contains(ui.statusMessage, "-hotwallet") {
+		t.Errorf("mode %v, status %q", ui.mode, ui.statusMessage)
+	}
+	for _, kb := range ui.bindings() {
+		if kb.key == "y" {
+			t.Error("y is offered in the help bar without a wallet")
+		}
+	}
+}
+
+func TestFlipAndOtherTokenKeys(t *testing.T) {
+	sol, usdc := wSOLMint, snapshotKey(81)
+	snap := quoteSnapshot{
+		pool: &raydium_cp_swap.PoolState{Token0Mint: sol, Token1Mint: usdc},
+		symm: SymbolMapping{
+			mintToSymbol: map[string]string{sol.String(): "SOL"},
+			symbolToMint: map[string]solana.PublicKey{"SOL": sol},
+		},
+	}
+	// USDC has no symbol here, the mint stands in for it.
+	if other, err := snap.otherToken("SOL"); err != nil || other != usdc.String() {
+		t.Errorf("other token of SOL = %q, %v", other, err)
+	}
+	if _, err := snap.otherToken("BONK"); err == nil {
+		t.Error("BONK isn't in the pool")
+	}
+
+	ui := newTermUI(nil, nil)
+	ui.mode = modeAwaitDecision
+	ui.handleKey(termbox.Event{Type: termbox.EventKey, Ch: 'f'})
+	if ui.mode != modeAwaitDecision || !strings.Contains(ui.statusMessage, "No intent") {
+		t.Errorf("f without an intent: mode %v, status %q", ui.mode, ui.statusMessage)
+	}
+	ui.intentInput = "sell 50% SOL"
+	ui.handleKey(termbox.Event{Type: termbox.EventKey, Ch: 'f'})
+	if ui.mode != modeAwaitDecision || !strings.Contains(ui.errPane.message, "wallet's balance") {
+		t.Errorf("f on a percentage: mode %v, error %q", ui.mode, ui.errPane.message)
+	}
+}
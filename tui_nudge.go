@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+/*
+NOTE(@hadydotai): Nudging the amount.
+
+Sizing an order in the TUI used to mean c, retyping the intent with another amount, Enter, again and again. The +/-
+keys move the amount of the intent on screen a step and quote it again: - and = (the + key without shift) a step down
+and up, _ and + (the same keys with shift) ten steps. termbox doesn't say whether shift was held, only which character
+it made, so the shifted characters are the ten steps.
+
+-nudge-step sets the step, a percentage of the amount (the default, 10%) or an amount of the intent's token. A
+percentage compounds, ten steps of 10% up is the amount times 1.1^10, and down divides by the same, so going down never
+gets to zero. The result is kept to four significant digits, an amount like 1.331 reads as 1.331 but 1.4641 as 1.464,
+and never more decimals than the token has. A fixed step stops short of zero.
+
+Dollar amounts ($50) move the same way, to the cent. An amount that's a share of the wallet (50%, all) doesn't, the
+share is the point of it, type the one you want instead.
+*/
+
+// nudgeStep is -nudge-step.
+type nudgeStep struct {
+	step     *big.Rat
+	relative bool // step is a fraction of the amount
+}
+
+var nudgeBy = nudgeStep{step: big.NewRat(1, 10), relative: true}
+
+const nudgeStepUsage = "How far the TUI's +/- keys move the intent's amount, a percentage of it (e.g. 10%) or an amount of its token (e.g. 0.5), with shift ten steps"
+
+func addNudgeFlag(fs *flag.FlagSet) {
+	fs.Var(&nudgeBy, "nudge-step", nudgeStepUsage)
+}
+
+func (n *nudgeStep) String() string {
+	if n == nil || n.step == nil {
+		return ""
+	}
+	if n.relative {
+		return trimDecimal(new(big.Rat).Mul(n.step, big.NewRat(100, 1)).FloatString(6)) + "%"
+	}
+	return trimDecimal(n.step.FloatString(18))
+}
+
+func (n *nudgeStep) Set(v string) error {
+	v = strings.TrimSpace(v)
+	pct, relative := strings.CutSuffix(v, "%")
+	step, ok := new(big.Rat).SetString(pct)
+	if !ok || step.Sign() <= 0 {
+		return fmt.Errorf("step must be a positive percentage like 10%% or a positive amount like 0.5, got %q", v)
+	}
+	if relative {
+		step.Quo(step, big.NewRat(100, 1))
+	}
+	n.step, n.relative = step, relative
+	return nil
+}
+
+// nudge moves amount steps steps, up when positive and down when negative, rounded to at most decimals places.
+func (n nudgeStep) nudge(amount *big.Rat, steps, decimals int) (*big.Rat, error) {
+	out := new(big.Rat)
+	places := decimals
+	if n.relative {
+		factor := new(big.Rat).Add(big.NewRat(1, 1), n.step)
+		factor = ratPow(factor, abs(steps))
+		if steps < 0 {
+			out.Quo(amount, factor)
+		} else {
+			out.Mul(amount, factor)
+		}
+		f, _ := out.Float64()
+		places = min(decimals, max(0, 3-int(math.Floor(math.Log10(f)))))
+	} else {
+		out.Mul(n.step, big.NewRat(int64(steps), 1))
+		out.Add(out, amount)
+		if out.Sign() <= 0 {
+			return nil, fmt.Errorf("%s less would be nothing", n.String())
+		}
+	}
+	rounded, _ := new(big.Rat).SetString(out.FloatString(places))
+	if rounded.Sign() <= 0 {
+		return nil, errors.New("the amount can't get any smaller")
+	}
+	if rounded.Cmp(amount) == 0 {
+		return nil, fmt.Errorf("a step of %s doesn't move the amount", n.String())
+	}
+	return rounded, nil
+}
+
+func ratPow(r *big.Rat, n int) *big.Rat {
+	out := big.NewRat(1, 1)
+	for range n {
+		out.Mul(out, r)
+	}
+	return out
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// nudgeIntent is line with its amount moved steps steps, decimals being those of the token the amount is in.
+func nudgeIntent(line string, steps, decimals int) (string, error) {
+	ii, err := parseIntent(line)
+	if err != nil {
+		return "", err
+	}
+	nudged := *ii
+	switch {
+	case ii.AmountPct != nil:
+		return "", fmt.Errorf("%s is a share of the wallet's balance, press c to type another", ii.AmountStr)
+	case ii.AmountUSD != nil:
+		usd, err := nudgeBy.nudge(ii.AmountUSD, steps, 2)
+		if err != nil {
+			return "", err
+		}
+		nudged.AmountUSD, nudged.AmountStr = usd, "$"+trimDecimal(usd.FloatString(2))
+	default:
+		amount, _ := new(big.Rat).SetString(ii.AmountStr)
+		moved, err := nudgeBy.nudge(amount, steps, decimals)
+		if err != nil {
+			return "", err
+		}
+		nudged.AmountStr = trimDecimal(moved.FloatString(decimals))
+	}
+	return nudged.String(), nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestNudgeIntent(t *testing.T) {
+	t.Cleanup(func() { nudgeBy = nudgeStep{step: big.NewRat(1, 10), relative: true} })
+	tests := []struct {
+		step  string
+		line  string
+		steps int
+		want  string
+	}{
+		{"10%", "pay 1 SOL", 1, "pay 1.1 SOL"},
+		{"10%", "pay 1 SOL", -1, "pay 0.9091 SOL"},
+		{"10%", "pay 1 SOL", 10, "pay 2.594 SOL"},
+		{"10%", "buy 50 USDC with SOL", 1, "buy 55 USDC with SOL"},
+		{"10%", "buy $50 of BONK", -1, "buy $45.45 of BONK"},
+		{"10%", "pay 1 SOL at >= 150 USDC", 1, "pay 1.1 SOL at >= 150 USDC"},
+		{"0.5", "pay 1 SOL", 1, "pay 1.5 SOL"},
+		{"0.5", "pay 1 SOL", 10, "pay 6 SOL"},
+		{"0.25", "swap 2 SOL for USDC", -1, "swap 1.75 SOL for USDC"},
+	}
+	for _, tc := range tests {
+		if err := nudgeBy.Set(tc.step); err != nil {
+			t.Fatal(err)
+		}
+		got, err := nudgeIntent(tc.line, tc.steps, 9)
+		if err != nil || got != tc.want {
+			t.Errorf("%s %+d steps of %s = %q, %v, want %q", tc.line, tc.steps, tc.step, got, err, tc.want)
+		}
+	}
+
+	nudgeBy.Set("0.5")
+	if _, err := nudgeIntent("pay 1 SOL", -10, 9); err == nil {
+		t.Error("a fixed step took the amount to nothing")
+	}
+	if _, err := nudgeIntent("sell 50% SOL", 1, 9); err == nil {
+		t.Error("a share of the wallet was nudged")
+	}
+	nudgeBy.Set("10%")
+	// No decimals to move into.
+	if _, err := nudgeIntent("pay 1 NFT", 1, 0); err == nil {
+		t.Error("a step that rounds away moved the amount")
+	}
+	for _, bad := range []string{"", "0", "-5%", "abc"} {
+		if err := nudgeBy.Set(bad); err == nil {
+			t.Errorf("-nudge-step %q accepted", bad)
+		}
+	}
+	if nudgeBy.String() != "10%" {
+		t.Errorf("step %s", nudgeBy.String())
+	}
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): Tutorial.
+
+`tutorial` walks someone who's never used the client through a first swap, one step at a time, explaining each thing
+as it comes up: a wallet (made for them if they don't have one), devnet SOL from the faucet, a pool, an intent, the
+quote, slippage and price impact, and finally sending it and reading the result. It only ever runs on devnet, there's
+no flag to point it anywhere else, the whole point is a first swap that can't cost anything real.
+
+It talks plain stdin/stdout rather than the TUI, every step waits for the user, and nothing is sent without a yes.
+*/
+
+// devnetUSDCMint is Circle's devnet USDC, the tutorial's suggested pair is SOL against it.
+const devnetUSDCMint = "4zMMC9srt5Ri5X14GAgXhaHii3GnPAEERYPJgZJDncDU"
+
+// tutorialMinLamports is what we'd like the wallet to hold before swapping: the swap itself, fees, and rent for the
+// token accounts it opens.
+const tutorialMinLamports = 50_000_000
+
+// tutor is the conversation with the user.
+type tutor struct {
+	in   *bufio.Reader
+	out  io.Writer
+	step int
+}
+
+func newTutor(in io.Reader, out io.Writer) *tutor {
+	return &tutor{in: bufio.NewReader(in), out: out}
+}
+
+// say prints a paragraph, wrapped for a regular terminal.
+func (t *tutor) say(format string, args ...any) {
+	for _, line := range wrapText(fmt.Sprintf(format, args...), 80) {
+		fmt.Fprintln(t.out, line)
+	}
+	fmt.Fprintln(t.out)
+}
+
+func (t *tutor) heading(title string) {
+	t.step++
+	fmt.Fprintf(t.out, "── Step %d: %s ──\n\n", t.step, title)
+}
+
+// ask reads a line, an empty answer takes def.
+func (t *tutor) ask(question, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(t.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(t.out, "%s: ", question)
+	}
+	line, err := t.in.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		return "", err
+	}
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer, nil
+	}
+	return def, nil
+}
+
+// confirm asks until it gets a yes or a no.
+func (t *tutor) confirm(question string) (bool, error) {
+	for {
+		answer, err := t.ask(question+" (y/n)", "")
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		fmt.Fprintln(t.out, "Please answer y or n.")
+	}
+}
+
+func (t *tutor) pause() error {
+	_, err := t.ask("Press Enter to continue", "")
+	return err
+}
+
+// writeKeygenFile saves a key the way solana-keygen does, a JSON array of the 64 secret key bytes.
+func writeKeygenFile(path string, key solana.PrivateKey) error {
+	ints := make([]int, len(key))
+	for i, b := range key {
+		ints[i] = int(b)
+	}
+	data, err := json.Marshal(ints)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// suggestIntent is a small first swap for the pool: a little SOL when the pool has it, otherwise one unit of token0.
+func suggestIntent(pool *raydium_cp_swap.PoolState, symm SymbolMapping) string {
+	switch {
+	case pool.Token0Mint.Equals(wSOLMint), pool.Token1Mint.Equals(wSOLMint):
+		return "pay 0.01 " + symm.SymFrom(wSOLMint)
+	}
+	return "pay 1 " + symm.SymFrom(pool.Token0Mint)
+}
+
+func fmtSOL(lamports uint64) string {
+	return display.format(new(big.Int).SetUint64(lamports), 9, 9) + " SOL"
+}
+
+type tutorial struct {
+	*tutor
+	ctx    context.Context
+	client *rpc.Client
+	payer  solana.PrivateKey
+	// walletPath is the keypair file in use, for the command line we suggest at the end.
+	walletPath string
+}
+
+func (tu *tutorial) wallet(path string) error {
+	tu.heading("A wallet")
+	tu.say("Every transaction is signed by a keypair, the client reads it from a file made by solana-keygen. On devnet any keypair will do, if you don't have one we'll make one just for this tutorial. Never reuse a tutorial wallet for real funds.")
+	if path == "" {
+		var err error
+		if path, err = tu.ask("Keypair file to use (created if it doesn't exist)", "tutorial-wallet.json"); err != nil {
+			return err
+		}
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		key, err := solana.NewRandomPrivateKey()
+		if err != nil {
+			return err
+		}
+		if err := writeKeygenFile(path, key); err != nil {
+			return fmt.Errorf("saving the new keypair: %w", err)
+		}
+		tu.say("Created a new keypair in %s, readable only by you.", path)
+	}
+	key, err := solana.PrivateKeyFromSolanaKeygenFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to load private key from %s: %w", path, err)
+	}
+	tu.payer, tu.walletPath = key, path
+	tu.say("Your wallet address is %s. That's public, share it freely, the file is the secret.", key.PublicKey())
+	return nil
+}
+
+func (tu *tutorial) balance() (uint64, error) {
+	res, err := tu.client.GetBalance(tu.ctx, tu.payer.PublicKey(), rpc.CommitmentConfirmed)
+	if err != nil {
+		return 0, fmt.Errorf("rpc call getBalance failed: %w", err)
+	}
+	return res.Value, nil
+}
+
+func (tu *tutorial) fund() error {
+	tu.heading("Some devnet SOL")
+	tu.say("Transactions cost a small fee in SOL, and a swap may have to open token accounts, which hold a little SOL as rent. Devnet SOL is free from the faucet.")
+	for {
+		lamports, err := tu.balance()
+		if err != nil {
+			return err
+		}
+		tu.say("The wallet holds %s.", fmtSOL(lamports))
+		if lamports >= tutorialMinLamports {
+			return nil
+		}
+		airdrop, err := tu.confirm("That's not quite enough. Ask the devnet faucet for 1 SOL?")
+		if err != nil {
+			return err
+		}
+		if !airdrop {
+			tu.say("You can also get some at https://faucet.solana.com, paste in %s.", tu.payer.PublicKey())
+			if err := tu.pause(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := tu.airdrop(solana.LAMPORTS_PER_SOL); err != nil {
+			tu.say("The airdrop didn't come through (%v). The public faucet is rate limited, try https://faucet.solana.com with %s and come back.", err, tu.payer.PublicKey())
+			if err := tu.pause(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// airdrop requests lamports and waits for the balance to show them, see requestAirdrop in devnet.go.
+func (tu *tutorial) airdrop(lamports uint64) error {
+	fmt.Fprintln(tu.out, "Waiting for the airdrop...")
+	return requestAirdrop(tu.ctx, tu.client, tu.payer.PublicKey(), lamports)
+}
+
+func (tu *tutorial) pool(addr string) (*loadedPool, error) {
+	tu.heading("A pool")
+	tu.say("A CP-Swap pool holds two tokens and trades one for the other along a constant product curve: the more of one side you take, the more expensive it gets. You can name a pool by its address, or by a pair of tokens (mint addresses, SOL works as is) and we'll find one.")
+	for {
+		target := addr
+		addr = ""
+		if target == "" {
+			var err error
+			if target, err = tu.ask("Pool address or pair", "SOL/"+devnetUSDCMint); err != nil {
+				return nil, err
+			}
+		}
+		poolPubK, err := resolvePoolTarget(tu.ctx, tu.client, target, SymbolMapping{})
+		if err == nil {
+			var loaded *loadedPool
+			if loaded, err = loadPool(tu.ctx, tu.client, poolPubK); err == nil {
+				tu.say("Found pool %s trading %s against %s.", poolPubK,
+					loaded.symbolsMap.SymFrom(loaded.pool.Token0Mint), loaded.symbolsMap.SymFrom(loaded.pool.Token1Mint))
+				return loaded, nil
+			}
+		}
+		tu.say("That didn't work: %v. Devnet pools come and go, any CP-Swap pool address from a devnet explorer will do.", err)
+	}
+}
+
+func (tu *tutorial) quote(builder *TableBuilder, pool *raydium_cp_swap.PoolState) (*CPIntent, error) {
+	tu.heading("An intent")
+	symm := builder.symbols()
+	tu.say("You tell the client what you want as <verb> <amount> <token-symbol>. \"pay\" (or sell, swap) spends exactly the amount and gets as much of the other token as the pool gives. \"buy\" (or get) receives exactly the amount and pays whatever it costs. This pool's symbols are %s and %s.",
+		symm.SymFrom(pool.Token0Mint), symm.SymFrom(pool.Token1Mint))
+	def := suggestIntent(pool, symm)
+	for {
+		line, err := tu.ask("Your intent", def)
+		if err != nil {
+			return nil, err
+		}
+		report, intent, err := builder.Build(line)
+		var mapErr *MissingSymbolMappingError
+		if errors.As(err, &mapErr) {
+			tu.say("One of the pool's tokens has no symbol on chain, we only know it by its mint %s.", mapErr.Mint)
+			ok, err := tu.confirm(fmt.Sprintf("Call mint %s %s from now on?", Addr(mapErr.Mint), mapErr.Symbol))
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				builder.mapSymbol(mapErr.Symbol, mapErr.Mint)
+			}
+			continue
+		}
+		if err != nil {
+			tu.say("That intent didn't work: %v", err)
+			continue
+		}
+		fmt.Fprintln(tu.out, report)
+		tu.explainQuote(intent)
+		return intent, nil
+	}
+}
+
+func (tu *tutorial) explainQuote(intent *CPIntent) {
+	tu.heading("Reading the quote")
+	tu.say("The table is the pool right now: its balances, the price, and what your intent gets at that price after the trade fee.")
+	if impact, err := intent.PriceImpact(); err == nil {
+		tu.say("Price impact is %s: your own trade moves the price along the curve, so your rate is that much worse than the pool's current price, trade fee included. It's already in the quote. Large impact means the trade is big for the pool, trade less or use a deeper pool.", pctString(impact))
+	}
+	if intent.SwapKind == SwapKindBaseOutput {
+		tu.say("The slippage guard is \"max pay\". Other swaps can land before yours and move the price, if paying for your amount would take more than max pay, the swap fails instead and only costs the network fee.")
+		return
+	}
+	tu.say("The slippage guard is \"min receive\". Other swaps can land before yours and move the price, if you'd receive less than min receive, the swap fails instead and only costs the network fee.")
+}
+
+func (tu *tutorial) slippage(builder *TableBuilder) (bool, error) {
+	tu.heading("Slippage")
+	current := builder.snapshot().slippagePct
+	tu.say("Slippage is how far the price may move against you between the quote and the swap landing, it's %s%% now. Tight slippage protects your price but fails more in busy pools, loose slippage goes through more often at worse prices.", strconv.FormatFloat(current, 'f', -1, 64))
+	for {
+		answer, err := tu.ask("Slippage percent", strconv.FormatFloat(current, 'f', -1, 64))
+		if err != nil {
+			return false, err
+		}
+		pct, err := strconv.ParseFloat(answer, 64)
+		if err == nil {
+			err = builder.SetSlippagePct(pct)
+		}
+		if err != nil {
+			tu.say("That's not a slippage we can use: %v", err)
+			continue
+		}
+		return pct != current, nil
+	}
+}
+
+func (tu *tutorial) send(builder *TableBuilder, intent *CPIntent, network string) error {
+	tu.heading("Sending it")
+	tu.say("Nothing has been sent yet. Saying yes signs a transaction for %s with your wallet and sends it to devnet.", intent)
+	ok, err := tu.confirm("Send this swap?")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		tu.say("Nothing was sent. Run the tutorial again whenever you like.")
+		return nil
+	}
+	summary, sig, err := executeIntent(tu.ctx, tu.client, tu.payer, builder, intent)
+	if !sig.IsZero() {
+		fmt.Fprintln(tu.out, renderTxSummary(summary))
+		fmt.Fprintln(tu.out, explorerTxURL(network, sig))
+		fmt.Fprintln(tu.out)
+	}
+	if err != nil {
+		tu.say("The swap failed: %v", err)
+		if !sig.IsZero() {
+			tu.say("The transaction landed but failed, which only cost its fee. `raydium-client why %s` explains what went wrong.", sig)
+		}
+		return nil
+	}
+	tu.say("Done, your first swap. The summary above is what actually moved, the explorer link shows the full transaction.")
+	return nil
+}
+
+func runTutorialCommand(args []string) error {
+	fs := flag.NewFlagSet("tutorial", flag.ExitOnError)
+	var (
+		rpcEP         = fs.String("rpc", rpc.DevNet_RPC, "Devnet RPC to connect to")
+		hotwalletPath = fs.String("hotwallet", "", "Keypair file to use, you're asked for one (or one is made) when empty")
+		poolAddr      = fs.String("pool", "", "Devnet pool to swap on, you're asked for one when empty")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	const network = "devnet"
+	raydium_cp_swap.ProgramID = networks[network][RaydiumProgramID].(solana.PublicKey)
+	ctx, stop := interruptContext()
+	defer stop()
+	tu := &tutorial{tutor: newTutor(os.Stdin, os.Stdout), ctx: ctx, client: rpc.New(*rpcEP)}
+
+	tu.say("Welcome. This walks you through a first swap on Solana's devnet, where tokens are free and nothing you do costs real money. Every step explains what's going on and waits for you, nothing is sent until you say so at the end. Ctrl+C stops at any point.")
+	if err := tu.wallet(*hotwalletPath); err != nil {
+		return err
+	}
+	if err := tu.fund(); err != nil {
+		return err
+	}
+	loaded, err := tu.pool(*poolAddr)
+	if err != nil {
+		return err
+	}
+	builder, err := newTableBuilder(ctx, tu.client, loaded, 0.5)
+	if err != nil {
+		return err
+	}
+	builder.useWallet(tu.payer.PublicKey())
+	intent, err := tu.quote(builder, loaded.pool)
+	if err != nil {
+		return err
+	}
+	changed, err := tu.slippage(builder)
+	if err != nil {
+		return err
+	}
+	if changed {
+		// The guard was computed with the old slippage, quote again so what's sent matches what's shown.
+		report, requoted, err := builder.Build(intent.String())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(tu.out, report)
+		intent = requoted
+	}
+	if err := tu.send(builder, intent, network); err != nil {
+		return err
+	}
+	tu.say("From here, the interactive client does all of this on one screen:")
+	fmt.Fprintf(tu.out, "  raydium-client -network devnet -hotwallet %s -pool %s\n\n", tu.walletPath, loaded.address)
+	tu.say("Press ? in it any time for help.")
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+func TestTutorAsk(t *testing.T) {
+	out := &bytes.Buffer{}
+	tu := newTutor(strings.NewReader("\nbuy 5 USDC\nmaybe\nY\n"), out)
+	if got, err := tu.ask("Intent", "pay 1 SOL"); err != nil || got != "pay 1 SOL" {
+		t.Errorf("empty answer = %q, %v, want the default", got, err)
+	}
+	if got, err := tu.ask("Intent", "pay 1 SOL"); err != nil || got != "buy 5 USDC" {
+		t.Errorf("answer = %q, %v", got, err)
+	}
+	if ok, err := tu.confirm("Send?"); err != nil || !ok {
+		t.Errorf("confirm = %v, %v", ok, err)
+	}
+	if !strings.Contains(out.String(), "Please answer y or n.") {
+		t.Error("an unclear answer should be asked again")
+	}
+	if _, err := tu.confirm("Send?"); !errors.Is(err, io.EOF) {
+		t.Errorf("confirm at end of input = %v, want EOF", err)
+	}
+}
+
+func TestWriteKeygenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.json")
+	key, err := solana.NewRandomPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeKeygenFile(path, key); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := solana.PrivateKeyFromSolanaKeygenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.PublicKey().Equals(key.PublicKey()) {
+		t.Error("the saved keypair doesn't load back")
+	}
+	if err := writeKeygenFile(path, key); err == nil {
+		t.Error("an existing keypair file was overwritten")
+	}
+}
+
+func TestSuggestIntent(t *testing.T) {
+	usdc := solana.MustPublicKeyFromBase58(devnetUSDCMint)
+	symm := SymbolMapping{
+		mintToSymbol: map[string]string{wSOLMint.String(): "WSOL", usdc.String(): "USDC"},
+		symbolToMint: map[string]solana.PublicKey{"WSOL": wSOLMint, "USDC": usdc},
+	}
+	if got := suggestIntent(&raydium_cp_swap.PoolState{Token0Mint: usdc, Token1Mint: wSOLMint}, symm); got != "pay 0.01 WSOL" {
+		t.Errorf("SOL pool: %q", got)
+	}
+	if got := suggestIntent(&raydium_cp_swap.PoolState{Token0Mint: usdc, Token1Mint: solana.NewWallet().PublicKey()}, symm); got != "pay 1 USDC" {
+		t.Errorf("no SOL: %q", got)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+/*
+NOTE(@hadydotai): TWAP orders.
+
+-chunk-above spreads an order over a few transactions because the pool is too shallow to take it at once. A TWAP
+spreads it over a window because the order shouldn't lean on the market at any one moment: -twap-window 1h with
+-twap-every 2m sends the intent as 30 slices, one roughly every two minutes, each quoted off the pool as it is when
+it goes and carrying its own slippage guard, like a chunk.
+
+Slices that are all the same size and go out on the minute are easy to spot and trade ahead of, so -twap-jitter
+(percent, 10 by default) moves every slice's size and time by up to that much either way. The sizes are drawn first
+and scaled so they still add up to the whole intent, the rounding remainder going with the first slice. The first
+slice goes out right away and every other one within a quarter of -twap-every of its mark, so the slices never swap
+places and the last one still lands inside the window.
+
+The benchmark is the arrival price, the pool's mid-price (reserves, no fee) when the order was quoted. Every slice and
+the order as a whole are reported as a price, output per input in display units, and how far that is from arrival.
+Worse is positive, it's the order's implementation shortfall and includes the pool's fee. A slice that fails to quote
+or send is reported and skipped, the order carries on with the next one and what the failed slice would have traded
+stays unsent. Interrupting the order stops it where it is.
+*/
+
+const (
+	maxTWAPSlices    = 1000
+	maxTWAPJitterPct = 50
+)
+
+type twapFlags struct {
+	window *time.Duration
+	every  *time.Duration
+	jitter *float64
+}
+
+func addTWAPFlags(fs *flag.FlagSet) *twapFlags {
+	return &twapFlags{
+		window: fs.Duration("twap-window", 0, "Send -intent in slices spread over this long (e.g. 1h), each quoted as it goes, needs -no-tui"),
+		every:  fs.Duration("twap-every", 2*time.Minute, "How often a TWAP order sends a slice, see -twap-window"),
+		jitter: fs.Float64("twap-jitter", 10, "Move every TWAP slice's size and time by up to this percentage either way"),
+	}
+}
+
+func (tf *twapFlags) enabled() bool {
+	return *tf.window > 0
+}
+
+func (tf *twapFlags) plan() (twapPlan, error) {
+	switch {
+	case *tf.every <= 0:
+		return twapPlan{}, errors.New("-twap-every has to be greater than zero")
+	case *tf.window < 2**tf.every:
+		return twapPlan{}, errors.New("-twap-window has to fit at least two slices of -twap-every")
+	case *tf.window / *tf.every > maxTWAPSlices:
+		return twapPlan{}, fmt.Errorf("-twap-window over -twap-every comes to more than %d slices", maxTWAPSlices)
+	case *tf.jitter < 0 || *tf.jitter > maxTWAPJitterPct:
+		return twapPlan{}, fmt.Errorf("-twap-jitter takes 0 to %d percent", maxTWAPJitterPct)
+	}
+	return twapPlan{window: *tf.window, every: *tf.every, jitter: *tf.jitter / 100}, nil
+}
+
+type twapPlan struct {
+	window time.Duration
+	every  time.Duration
+	jitter float64 // a fraction, not a percentage
+}
+
+// twapSlice is a part of the order, sent at after the order started.
+type twapSlice struct {
+	at     time.Duration
+	amount *big.Int
+}
+
+// schedule divides total into the plan's slices, jittering their sizes and times with rnd, which returns [0, 1). An
+// amount too small to divide into that many slices gets as many as it has units, and one too small to jitter goes out
+// in equal slices.
+func (p twapPlan) schedule(total *big.Int, rnd func() float64) []twapSlice {
+	n := int(p.window / p.every)
+	if total.Cmp(big.NewInt(int64(n))) < 0 {
+		n = int(total.Int64())
+	}
+	const unit = 1_000_000
+	weights, sum := make([]*big.Int, n), new(big.Int)
+	for i := range weights {
+		weights[i] = big.NewInt(int64(unit * (1 + p.jitter*(2*rnd()-1))))
+		sum.Add(sum, weights[i])
+	}
+	slices, assigned := make([]twapSlice, n), new(big.Int)
+	for i := range slices {
+		amount := new(big.Int).Mul(total, weights[i])
+		slices[i] = twapSlice{at: time.Duration(i) * p.every, amount: amount.Quo(amount, sum)}
+		if i > 0 {
+			slices[i].at += time.Duration(p.jitter * float64(p.every) * (rnd() - 0.5) / 2)
+		}
+		assigned.Add(assigned, amount)
+	}
+	if n > 0 {
+		slices[0].amount.Add(slices[0].amount, assigned.Sub(total, assigned))
+	}
+	for _, slice := range slices {
+		if slice.amount.Sign() == 0 {
+			for i, size := range chunkSizes(total, n) {
+				slices[i].amount = size
+			}
+			break
+		}
+	}
+	return slices
+}
+
+type twapFill struct {
+	at       time.Time
+	intent   *CPIntent // nil when the slice couldn't be quoted
+	summary  txSummaryData
+	sig      solana.Signature
+	paid     *big.Int // nil unless the slice filled
+	received *big.Int
+	err      error
+}
+
+// twapOrder is what a TWAP order did, as far as it got.
+type twapOrder struct {
+	base     *CPIntent
+	plan     twapPlan
+	slices   []twapSlice
+	arrival  *big.Rat // the pool's mid-price when the order was quoted, output per input in raw units
+	fills    []twapFill
+	paid     *big.Int
+	received *big.Int
+	// stopped says why the order ended before its last slice, empty when it didn't.
+	stopped string
+}
+
+// midRate is the pool's output per input off intent's reserves, raw units, no fee and no impact.
+func midRate(intent *CPIntent) (*big.Rat, error) {
+	if intent.ReserveIn == nil || intent.ReserveOut == nil || intent.ReserveIn.Balance == nil || intent.ReserveOut.Balance == nil || intent.ReserveIn.Balance.Sign() == 0 {
+		return nil, errors.New("the pool's reserves are unavailable")
+	}
+	return new(big.Rat).SetFrac(intent.ReserveOut.Balance, intent.ReserveIn.Balance), nil
+}
+
+// shortfall is how much worse than arrival paying paid for received is, negative when it's better. Nil while nothing
+// was paid.
+func (o *twapOrder) shortfall(paid, received *big.Int) *big.Rat {
+	if paid == nil || paid.Sign() == 0 {
+		return nil
+	}
+	rate := new(big.Rat).SetFrac(received, paid)
+	return new(big.Rat).Sub(big.NewRat(1, 1), rate.Quo(rate, o.arrival))
+}
+
+// price is paying paid for received in display units, output per input.
+func (o *twapOrder) price(paid, received *big.Int) string {
+	if paid == nil || paid.Sign() == 0 {
+		return "n/a"
+	}
+	return o.displayRate(new(big.Rat).SetFrac(received, paid))
+}
+
+func (o *twapOrder) displayRate(rate *big.Rat) string {
+	in, out := o.base.TokenIn.Decimals, o.base.TokenOut.Decimals
+	scaled := new(big.Rat).Mul(rate, new(big.Rat).SetFrac(fixedPointScale(in), fixedPointScale(out)))
+	return trimDecimal(scaled.FloatString(int(out)))
+}
+
+// runTWAP sends slices of base as they come due, quoting each one just before it's sent. The order comes back with
+// whatever filled, an error only when it was interrupted.
+func runTWAP(ctx context.Context, base *CPIntent, plan twapPlan, slices []twapSlice, quote chunkQuoter, send chunkSender) (*twapOrder, error) {
+	arrival, err := midRate(base)
+	if err != nil {
+		return nil, err
+	}
+	order := &twapOrder{base: base, plan: plan, slices: slices, arrival: arrival, paid: new(big.Int), received: new(big.Int)}
+	start := time.Now()
+	for i, slice := range slices {
+		if wait := time.Until(start.Add(slice.at)); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				order.stopped = "interrupted"
+				return order, ctx.Err()
+			case <-timer.C:
+			}
+		}
+		fill := twapFill{at: time.Now()}
+		fill.intent, fill.err = quote(ctx, slice.amount)
+		if fill.err == nil {
+			fill.summary, fill.sig, fill.err = send(ctx, fill.intent)
+		}
+		if fill.err == nil {
+			fill.paid, fill.received = fill.summary.PaidAmount, fill.summary.ReceivedAmount
+			if fill.paid == nil || fill.received == nil {
+				// Sent but the balances couldn't be read back, the quote is the best record there is.
+				fill.paid, fill.received = fill.intent.QuotedInOut()
+			}
+			order.paid.Add(order.paid, fill.paid)
+			order.received.Add(order.received, fill.received)
+		}
+		order.fills = append(order.fills, fill)
+		if ctx.Err() != nil && i < len(slices)-1 {
+			order.stopped = "interrupted"
+			return order, ctx.Err()
+		}
+	}
+	return order, nil
+}
+
+// renderTWAPOrder shows every slice, the blended price against the arrival price, and why the order stopped if it
+// did.
+func renderTWAPOrder(order *twapOrder, symm SymbolMapping, network string) string {
+	builder := &strings.Builder{}
+	inSym, outSym := symm.SymFrom(order.base.TokenIn.Mint), symm.SymFrom(order.base.TokenOut.Mint)
+	inDec, outDec := order.base.TokenIn.Decimals, order.base.TokenOut.Decimals
+	vsArrival := func(paid, received *big.Int) string {
+		if s := order.shortfall(paid, received); s != nil {
+			return pctString(s)
+		}
+		return "n/a"
+	}
+	t := table.NewWriter()
+	t.SetOutputMirror(builder)
+	t.SetTitle("TWAP order")
+	t.Style().Size.WidthMax = 160
+	t.AppendHeader(table.Row{"Slice", "Time", "Status", "Paid", "Received", fmt.Sprintf("Price (%s per %s)", outSym, inSym), "vs Arrival", "Transaction"})
+	var failures []string
+	for i, fill := range order.fills {
+		status, tx := fill.summary.Status, ""
+		if status == "" {
+			status = "pending"
+		}
+		if fill.err != nil {
+			status = "failed"
+			failures = append(failures, fmt.Sprintf("Slice %d/%d failed: %v", i+1, len(order.slices), fill.err))
+		}
+		if !fill.sig.IsZero() {
+			tx = explorerTxURL(network, fill.sig)
+		}
+		t.AppendRow(table.Row{fmt.Sprintf("%d/%d", i+1, len(order.slices)), fill.at.Format(time.TimeOnly), strings.ToUpper(status),
+			formatTokenAmount(fill.paid, inDec, inSym), formatTokenAmount(fill.received, outDec, outSym),
+			order.price(fill.paid, fill.received), vsArrival(fill.paid, fill.received), tx})
+	}
+	t.AppendFooter(table.Row{"Total", "", "", formatTokenAmount(order.paid, inDec, inSym), formatTokenAmount(order.received, outDec, outSym),
+		order.price(order.paid, order.received), vsArrival(order.paid, order.received), ""})
+	t.Render()
+	fmt.Fprintf(builder, "Arrival price %s %s per %s, the pool's mid-price when the order was quoted. vs Arrival is how much worse a price is, negative is better.\n",
+		order.displayRate(order.arrival), outSym, inSym)
+	for _, failure := range failures {
+		fmt.Fprintln(builder, failure)
+	}
+	if order.stopped != "" {
+		fmt.Fprintf(builder, "Stopped, %s. %d of %d slices went out, the rest of the order wasn't sent.\n", order.stopped, len(order.fills), len(order.slices))
+	}
+	return builder.String()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+func TestTWAPSchedule(t *testing.T) {
+	plan := twapPlan{window: time.Hour, every: 2 * time.Minute, jitter: 0.1}
+	total := big.NewInt(1_000_000_000)
+	slices := plan.schedule(total, rand.New(rand.NewPCG(1, 2)).Float64)
+	if len(slices) != 30 {
+		t.Fatalf("%d slices, want 30", len(slices))
+	}
+	sum, equal := new(big.Int), new(big.Int).Quo(total, big.NewInt(30))
+	lo, hi := new(big.Int).Quo(new(big.Int).Mul(equal, big.NewInt(89)), big.NewInt(100)), new(big.Int).Quo(new(big.Int).Mul(equal, big.NewInt(111)), big.NewInt(100))
+	varied := false
+	for i, slice := range slices {
+		sum.Add(sum, slice.amount)
+		if i > 0 && (slice.amount.Cmp(lo) < 0 || slice.amount.Cmp(hi) > 0) {
+			t.Errorf("slice %d is %s, more than 10%% off %s", i, slice.amount, equal)
+		}
+		if slice.amount.Cmp(slices[0].amount) != 0 {
+			varied = true
+		}
+		mark := time.Duration(i) * plan.every
+		if i == 0 && slice.at != 0 || slice.at < mark-plan.every/40 || slice.at > mark+plan.every/40 {
+			t.Errorf("slice %d at %s, its mark is %s", i, slice.at, mark)
+		}
+		if i > 0 && slice.at <= slices[i-1].at {
+			t.Errorf("slice %d at %s isn't after slice %d at %s", i, slice.at, i-1, slices[i-1].at)
+		}
+	}
+	if sum.Cmp(total) != 0 {
+		t.Errorf("slices add up to %s, want %s", sum, total)
+	}
+	if !varied {
+		t.Error("every slice is the same size with 10% jitter")
+	}
+
+	// Without jitter the slices are equal and on the mark, and an amount smaller than the slices goes out a unit at a
+	// time.
+	plain := twapPlan{window: 10 * time.Minute, every: 2 * time.Minute}
+	for i, slice := range plain.schedule(big.NewInt(10), rand.Float64) {
+		if slice.amount.Int64() != 2 || slice.at != time.Duration(i)*plain.every {
+			t.Errorf("unjittered slice %d is %s at %s", i, slice.amount, slice.at)
+		}
+	}
+	if small := plan.schedule(big.NewInt(3), rand.Float64); len(small) != 3 || small[0].amount.Int64() != 1 {
+		t.Errorf("3 units in %d slices", len(small))
+	}
+}
+
+func TestTWAPFlags(t *testing.T) {
+	for _, tc := range []struct {
+		window, every time.Duration
+		jitter        float64
+		err           string
+	}{
+		{time.Hour, 2 * time.Minute, 10, ""},
+		{time.Minute, 2 * time.Minute, 10, "at least two slices"},
+		{time.Hour, 0, 10, "greater than zero"},
+		{time.Hour, time.Second, 10, "more than 1000 slices"},
+		{time.Hour, time.Minute, 60, "0 to 50 percent"},
+	} {
+		tf := &twapFlags{window: &tc.window, every: &tc.every, jitter: &tc.jitter}
+		_, err := tf.plan()
+		if tc.err == "" && err != nil || tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("-twap-window %s -twap-every %s -twap-jitter %v: %v, want %q", tc.window, tc.every, tc.jitter, err, tc.err)
+		}
+	}
+}
+
+func TestRunTWAP(t *testing.T) {
+	pool := newChunkPool(t, "sell 200 SOL", true)
+	plan := twapPlan{window: 8 * time.Millisecond, every: 2 * time.Millisecond}
+	slices := plan.schedule(pool.base.Amounts.KnownAmount, rand.Float64)
+	send := func(ctx context.Context, slice *CPIntent) (txSummaryData, solana.Signature, error) {
+		if pool.sent == 1 {
+			pool.sent++
+			return txSummaryData{}, solana.Signature{}, errors.New("blockhash expired")
+		}
+		return pool.send(ctx, slice)
+	}
+	order, err := runTWAP(context.Background(), pool.base, plan, slices, pool.quote, send)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The failed slice is skipped and the order carries on with the rest.
+	if len(order.fills) != 4 || order.fills[1].err == nil || order.stopped != "" {
+		t.Fatalf("%d slices, stopped %q", len(order.fills), order.stopped)
+	}
+	unsent := new(big.Int).Sub(pool.base.Amounts.KnownAmount, slices[1].amount)
+	if order.paid.Cmp(unsent) != 0 {
+		t.Errorf("paid %s, want everything but the failed slice, %s", order.paid, unsent)
+	}
+	// The pool is back at arrival for every slice, so they all fall short of the mid-price by about the same, the fee
+	// and each slice's own impact.
+	shortfall := order.shortfall(order.paid, order.received)
+	if shortfall.Cmp(big.NewRat(25, 10000)) <= 0 || shortfall.Cmp(big.NewRat(10, 100)) >= 0 {
+		t.Errorf("shortfall %s", pctString(shortfall))
+	}
+	out := renderTWAPOrder(order, SymbolMapping{}, "devnet")
+	for _, want := range []string{"4/4", "FAILED", "Slice 2/4 failed: blockhash expired", "Arrival price 150 "} {
+		if !strings.Contains(out, want) {
+			t.Errorf("rendered without %q:\n%s", want, out)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	slow := twapPlan{window: time.Hour, every: time.Minute}
+	order, err = runTWAP(ctx, pool.base, slow, slow.schedule(pool.base.Amounts.KnownAmount, rand.Float64), pool.quote, pool.send)
+	if !errors.Is(err, context.Canceled) || order.stopped != "interrupted" || len(order.fills) != 1 {
+		t.Errorf("interrupted: %v, %d slices, stopped %q", err, len(order.fills), order.stopped)
+	}
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): Review bundles.
+
+The idea is borrowed from how Gnosis Safe treasuries operate: whoever plans a trade isn't necessarily the one who
+approves it. So instead of sending, we write out every instruction we would have sent, alongside the numbers that
+produced them, into a JSON file a human can read. The bundle gets a SHA-256 hash, the reviewer signs off on the hash,
+and executing the bundle later requires handing that hash back. If a single byte of the reviewed content changed in
+between, the hashes won't match and we refuse to execute.
+
+What the bundle deliberately doesn't carry is a blockhash or signatures. Blockhashes expire in about a minute, reviews
+don't. We attach a fresh one at execution time, which is safe because the slippage guards (min out/max in) are baked
+into the swap instruction data, and that's covered by the hash.
+
+The hash is computed over the compact JSON encoding of the bundle, so re-indenting the file for reading doesn't
+invalidate it, but changing any value does. Bundles are JSON and only JSON: a YAML copy would be a second encoding of
+the same content for reviewers to compare, and JSON reads well enough indented.
+*/
+
+const txBundleVersion = 1
+
+type txBundleLeg struct {
+	Mint     string `json:"mint"`
+	Symbol   string `json:"symbol"`
+	Decimals uint8  `json:"decimals"`
+	Vault    string `json:"vault"`
+}
+
+type txBundleAccount struct {
+	Pubkey   string `json:"pubkey"`
+	Signer   bool   `json:"signer"`
+	Writable bool   `json:"writable"`
+}
+
+type txBundleInstruction struct {
+	Program  string            `json:"program"`
+	Accounts []txBundleAccount `json:"accounts"`
+	Data     string            `json:"data"` // base64
+}
+
+type txBundleEntry struct {
+	Intent       string                `json:"intent"`
+	Pool         string                `json:"pool"`
+	SwapKind     string                `json:"swapKind"`
+	TokenIn      txBundleLeg           `json:"tokenIn"`
+	TokenOut     txBundleLeg           `json:"tokenOut"`
+	Amounts      map[string]amountJSON `json:"amounts"`
+	Instructions []txBundleInstruction `json:"instructions"`
+}
+
+type txBundle struct {
+	Version   int             `json:"version"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Network   string          `json:"network"`
+	ProgramID string          `json:"programId"`
+	Payer     string          `json:"payer"`
+	Entries   []txBundleEntry `json:"entries"`
+
+	// approved is set by readTxBundle for an approval request a second keyholder countersigned.
+	approved bool
+}
+
+// txBundleFile is what lands on disk, the bundle itself plus the hash the reviewer approves. An approval request
+// (approval.go) also carries the proposer's signature over the hash and the approvals collected so far.
+type txBundleFile struct {
+	Bundle    json.RawMessage     `json:"bundle"`
+	SHA256    string              `json:"sha256"`
+	Proposal  *approvalSignature  `json:"proposal,omitempty"`
+	Approvals []approvalSignature `json:"approvals,omitempty"`
+}
+
+func (sk SwapKind) String() string {
+	switch sk {
+	case SwapKindBaseInput:
+		return "swap_base_input"
+	case SwapKindBaseOutput:
+		return "swap_base_output"
+	default:
+		return "unknown"
+	}
+}
+
+func bundleLeg(leg SwapLeg, symm SymbolMapping) txBundleLeg {
+	return txBundleLeg{
+		Mint:     leg.Mint.String(),
+		Symbol:   symm.SymFrom(leg.Mint),
+		Decimals: leg.Decimals,
+		Vault:    leg.Vault.String(),
+	}
+}
+
+// newTxBundleEntry flattens a swap plan into its reviewable form.
+func newTxBundleEntry(plan *swapPlan, symm SymbolMapping) (txBundleEntry, error) {
+	intent := plan.intent
+	knownDecimals, counterDecimals := intent.TokenIn.Decimals, intent.TokenOut.Decimals
+	if intent.SwapKind == SwapKindBaseOutput {
+		knownDecimals, counterDecimals = counterDecimals, knownDecimals
+	}
+	entry := txBundleEntry{
+		Intent:   intent.String(),
+		Pool:     intent.Pool.Address.String(),
+		SwapKind: intent.SwapKind.String(),
+		TokenIn:  bundleLeg(intent.TokenIn, symm),
+		TokenOut: bundleLeg(intent.TokenOut, symm),
+		Amounts: map[string]amountJSON{
+			"known": newAmountJSON(intent.Amounts.KnownAmount, knownDecimals),
+			"quote": newAmountJSON(intent.Amounts.QuoteAmount, counterDecimals),
+		},
+	}
+	if intent.Amounts.MinAmountOut != nil {
+		entry.Amounts["minAmountOut"] = newAmountJSON(intent.Amounts.MinAmountOut, intent.TokenOut.Decimals)
+	}
+	if intent.Amounts.MaxAmountIn != nil {
+		entry.Amounts["maxAmountIn"] = newAmountJSON(intent.Amounts.MaxAmountIn, intent.TokenIn.Decimals)
+	}
+	for i, ix := range plan.instructions {
+		data, err := ix.Data()
+		if err != nil {
+			return txBundleEntry{}, fmt.Errorf("encoding instruction %d failed: %w", i, err)
+		}
+		bix := txBundleInstruction{
+			Program: ix.ProgramID().String(),
+			Data:    base64.StdEncoding.EncodeToString(data),
+		}
+		for _, acc := range ix.Accounts() {
+			bix.Accounts = append(bix.Accounts, txBundleAccount{
+				Pubkey:   acc.PublicKey.String(),
+				Signer:   acc.IsSigner,
+				Writable: acc.IsWritable,
+			})
+		}
+		entry.Instructions = append(entry.Instructions, bix)
+	}
+	return entry, nil
+}
+
+func bundleHash(compact []byte) string {
+	sum := sha256.Sum256(compact)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeTxBundle writes the bundle to path and returns the hash a reviewer has to approve.
+func writeTxBundle(path string, bundle txBundle) (string, error) {
+	file, err := newTxBundleFile(bundle)
+	if err != nil {
+		return "", err
+	}
+	return file.SHA256, saveTxBundleFile(path, file)
+}
+
+func newTxBundleFile(bundle txBundle) (txBundleFile, error) {
+	compact, err := json.Marshal(bundle)
+	if err != nil {
+		return txBundleFile{}, fmt.Errorf("encoding bundle failed: %w", err)
+	}
+	return txBundleFile{Bundle: compact, SHA256: bundleHash(compact)}, nil
+}
+
+func saveTxBundleFile(path string, file txBundleFile) error {
+	out, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding bundle file failed: %w", err)
+	}
+	if err := os.WriteFile(path, append(out, '\n'), 0o600); err != nil {
+		return fmt.Errorf("writing bundle to %s failed: %w", path, err)
+	}
+	return nil
+}
+
+// loadTxBundleFile reads a bundle file and what its content actually hashes to, nothing is checked against it yet.
+func loadTxBundleFile(path string) (txBundleFile, *txBundle, string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return txBundleFile{}, nil, "", fmt.Errorf("reading bundle %s failed: %w", path, err)
+	}
+	var file txBundleFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return txBundleFile{}, nil, "", fmt.Errorf("decoding bundle %s failed, bundles are JSON: %w", path, err)
+	}
+	compact := &bytes.Buffer{}
+	if err := json.Compact(compact, file.Bundle); err != nil {
+		return txBundleFile{}, nil, "", fmt.Errorf("bundle %s has malformed content: %w", path, err)
+	}
+	actual := bundleHash(compact.Bytes())
+	if file.SHA256 != "" && !strings.EqualFold(file.SHA256, actual) {
+		// NOTE(@hadydotai): The approved hash is the one that matters, but if the recorded one disagrees somebody
+		// edited the file by hand after approval, and that's worth shouting about.
+		return txBundleFile{}, nil, "", fmt.Errorf("bundle content hashes to %s but the file records %s", actual, file.SHA256)
+	}
+	var bundle txBundle
+	if err := json.Unmarshal(file.Bundle, &bundle); err != nil {
+		return txBundleFile{}, nil, "", fmt.Errorf("decoding bundle content failed: %w", err)
+	}
+	if bundle.Version != txBundleVersion {
+		return txBundleFile{}, nil, "", fmt.Errorf("bundle version %d is not supported (expected %d)", bundle.Version, txBundleVersion)
+	}
+	return file, &bundle, actual, nil
+}
+
+// readTxBundle loads a bundle and refuses it unless its content hashes to the approved hash, and when it's an
+// approval request, unless a second keyholder approved it.
+func readTxBundle(path string, approvedHash string) (*txBundle, error) {
+	approvedHash = strings.ToLower(strings.TrimSpace(approvedHash))
+	if approvedHash == "" {
+		return nil, errors.New("an approved bundle hash is required to execute a bundle")
+	}
+	file, bundle, actual, err := loadTxBundleFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if actual != approvedHash {
+		return nil, fmt.Errorf("bundle content hashes to %s, which doesn't match the approved hash %s; refusing to execute", actual, approvedHash)
+	}
+	if err := checkApprovals(file, bundle, actual); err != nil {
+		return nil, err
+	}
+	bundle.approved = file.Proposal != nil
+	return bundle, nil
+}
+
+// instructions rebuilds the solana instructions of a bundle entry exactly as they were reviewed.
+func (e txBundleEntry) instructions() ([]solana.Instruction, error) {
+	ixs := make([]solana.Instruction, 0, len(e.Instructions))
+	for i, bix := range e.Instructions {
+		program, err := solana.PublicKeyFromBase58(bix.Program)
+		if err != nil {
+			return nil, fmt.Errorf("instruction %d has an invalid program id: %w", i, err)
+		}
+		data, err := base64.StdEncoding.DecodeString(bix.Data)
+		if err != nil {
+			return nil, fmt.Errorf("instruction %d has invalid data: %w", i, err)
+		}
+		accounts := make(solana.AccountMetaSlice, 0, len(bix.Accounts))
+		for j, acc := range bix.Accounts {
+			pk, err := solana.PublicKeyFromBase58(acc.Pubkey)
+			if err != nil {
+				return nil, fmt.Errorf("instruction %d account %d is invalid: %w", i, j, err)
+			}
+			accounts = append(accounts, solana.NewAccountMeta(pk, acc.Writable, acc.Signer))
+		}
+		ixs = append(ixs, solana.NewInstruction(program, accounts, data))
+	}
+	return ixs, nil
+}
+
+// leg turns the reviewed leg back into a SwapLeg, enough to diff balances after execution.
+func (l txBundleLeg) leg() (SwapLeg, error) {
+	mint, err := solana.PublicKeyFromBase58(l.Mint)
+	if err != nil {
+		return SwapLeg{}, fmt.Errorf("invalid mint %q: %w", l.Mint, err)
+	}
+	vault, err := solana.PublicKeyFromBase58(l.Vault)
+	if err != nil {
+		return SwapLeg{}, fmt.Errorf("invalid vault %q: %w", l.Vault, err)
+	}
+	return SwapLeg{Mint: mint, Vault: vault, Decimals: l.Decimals}, nil
+}
+
+// hookSwap is the entry the way the trade hooks see a swap, paying at most and getting at least what was reviewed.
+func (e txBundleEntry) hookSwap(wallet solana.PublicKey, approved bool) (hookSwap, error) {
+	tokenIn, err := e.TokenIn.leg()
+	if err != nil {
+		return hookSwap{}, err
+	}
+	tokenOut, err := e.TokenOut.leg()
+	if err != nil {
+		return hookSwap{}, err
+	}
+	pool, err := solana.PublicKeyFromBase58(e.Pool)
+	if err != nil {
+		return hookSwap{}, fmt.Errorf("invalid pool %q: %w", e.Pool, err)
+	}
+	symm := SymbolMapping{mintToSymbol: map[string]string{}, symbolToMint: map[string]solana.PublicKey{}}
+	name := func(leg SwapLeg, sym string) {
+		if sym != "" {
+			symm.mintToSymbol[leg.Mint.String()], symm.symbolToMint[sym] = sym, leg.Mint
+		}
+	}
+	name(tokenIn, e.TokenIn.Symbol)
+	name(tokenOut, e.TokenOut.Symbol)
+	raw := func(key string) *big.Int {
+		amount, ok := e.Amounts[key]
+		if !ok {
+			return nil
+		}
+		v, ok := new(big.Int).SetString(amount.Raw, 10)
+		if !ok {
+			return nil
+		}
+		return v
+	}
+	s := hookSwap{Intent: e.Intent, Wallet: wallet, Pool: pool, TokenIn: tokenIn, TokenOut: tokenOut, Symbols: symm, Approved: approved}
+	switch e.SwapKind {
+	case SwapKindBaseInput.String():
+		s.MaxIn, s.MinOut = raw("known"), raw("minAmountOut")
+	case SwapKindBaseOutput.String():
+		s.MaxIn, s.MinOut = raw("maxAmountIn"), raw("known")
+	}
+	return s, nil
+}
+
+// executeTxBundle sends every entry of an approved bundle in order, one after the other landed. It stops at the first
+// entry that fails to send, fails on chain, or isn't seen landing, the entries after it were reviewed as coming after
+// it and aren't sent.
+func executeTxBundle(ctx context.Context, client *rpc.Client, payer solana.PrivateKey, bundle *txBundle, network string) error {
+	if bundle.Payer != payer.PublicKey().String() {
+		return fmt.Errorf("bundle was planned for payer %s, but the hot wallet is %s", Addr(bundle.Payer), Addr(payer.PublicKey().String()))
+	}
+	if !strings.EqualFold(bundle.Network, network) {
+		return fmt.Errorf("bundle was planned on %s, refusing to execute it on %s", bundle.Network, network)
+	}
+	if bundle.ProgramID != raydium_cp_swap.ProgramID.String() {
+		return fmt.Errorf("bundle targets program %s, expected %s", Addr(bundle.ProgramID), Addr(raydium_cp_swap.ProgramID.String()))
+	}
+	over, why, err := bundleNeedsApproval(ctx, bundle)
+	if err != nil {
+		return err
+	}
+	if over {
+		return fmt.Errorf("%s, nothing was sent, it takes an approval request a second keyholder countersigned, export it again with -approval-above to write one", why)
+	}
+	for i, entry := range bundle.Entries {
+		ixs, err := entry.instructions()
+		if err != nil {
+			return fmt.Errorf("bundle entry %d (%s): %w", i, entry.Intent, err)
+		}
+		hooked, err := entry.hookSwap(payer.PublicKey(), bundle.approved)
+		if err != nil {
+			return fmt.Errorf("bundle entry %d (%s): %w", i, entry.Intent, err)
+		}
+		if err := preSendHooks(ctx, hooked); err != nil {
+			return fmt.Errorf("bundle entry %d (%s), nothing from it on was sent: %w", i, entry.Intent, err)
+		}
+		sendCtx, cancel := deadlines.forSend(ctx)
+		sig, err := signAndSend(sendCtx, client, payer, ixs)
+		if err != nil {
+			cancel()
+			return fmt.Errorf("bundle entry %d (%s): %w", i, entry.Intent, err)
+		}
+		log.Printf("Tx %d/%d (%s): %s", i+1, len(bundle.Entries), entry.Intent, sig)
+		summary, waitErr := awaitSwapSummary(sendCtx, client, sig, hooked.TokenIn, hooked.TokenOut, entry.TokenIn.Symbol, entry.TokenOut.Symbol)
+		cancel()
+		postConfirmHooks(ctx, hooked, summary)
+		if waitErr != nil {
+			log.Printf("warning: waiting for transaction confirmation failed: %v", waitErr)
+		}
+		fmt.Fprintln(os.Stdout, renderTxSummary(summary))
+		switch summary.Status {
+		case "failed":
+			return fmt.Errorf("bundle entry %d (%s), nothing after it was sent: %w", i, entry.Intent, &txFailedError{sig: sig, txErr: summary.TxErr, logs: summary.Logs})
+		case "pending":
+			return fmt.Errorf("bundle entry %d (%s) wasn't seen landing, nothing after it was sent, it can still land until its blockhash expires, check it with `why %s`", i, entry.Intent, sig)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func newTestBundle(t *testing.T) (txBundle, solana.Instruction) {
+	t.Helper()
+	program := solana.NewWallet().PublicKey()
+	signer := solana.NewWallet().PublicKey()
+	other := solana.NewWallet().PublicKey()
+	ix := solana.NewInstruction(program, solana.AccountMetaSlice{
+		solana.NewAccountMeta(signer, true, true),
+		solana.NewAccountMeta(other, false, false),
+	}, []byte{1, 2, 3, 4})
+	entry, err := newTxBundleEntry(&swapPlan{intent: testBundleIntent(), payer: signer, instructions: []solana.Instruction{ix}}, SymbolMapping{})
+	if err != nil {
+		t.Fatalf("newTxBundleEntry: %v", err)
+	}
+	return txBundle{
+		Version:   txBundleVersion,
+		CreatedAt: time.Unix(1_700_000_000, 0).UTC(),
+		Network:   "devnet",
+		Payer:     signer.String(),
+		Entries:   []txBundleEntry{entry},
+	}, ix
+}
+
+func TestTxBundleRoundTrip(t *testing.T) {
+	bundle, ix := newTestBundle(t)
+	path := filepath.Join(t.TempDir(), "bundle.json")
+	hash, err := writeTxBundle(path, bundle)
+	if err != nil {
+		t.Fatalf("writeTxBundle: %v", err)
+	}
+	got, err := readTxBundle(path, strings.ToUpper(hash))
+	if err != nil {
+		t.Fatalf("readTxBundle: %v", err)
+	}
+	if len(got.Entries) != 1 {
+		t.Fatalf("expected one entry, got %d", len(got.Entries))
+	}
+	entry := got.Entries[0]
+	if entry.Amounts["minAmountOut"].Raw != "1990000000" || entry.Amounts["minAmountOut"].Display != "1.990000000" {
+		t.Fatalf("unexpected min out %+v", entry.Amounts["minAmountOut"])
+	}
+	ixs, err := entry.instructions()
+	if err != nil {
+		t.Fatalf("instructions: %v", err)
+	}
+	gotData, _ := ixs[0].Data()
+	wantData, _ := ix.Data()
+	if !bytes.Equal(gotData, wantData) || !ixs[0].ProgramID().Equals(ix.ProgramID()) {
+		t.Fatalf("instruction did not survive the round trip")
+	}
+	for i, acc := range ixs[0].Accounts() {
+		want := ix.Accounts()[i]
+		if !acc.PublicKey.Equals(want.PublicKey) || acc.IsSigner != want.IsSigner || acc.IsWritable != want.IsWritable {
+			t.Fatalf("account %d mismatch: %+v vs %+v", i, acc, want)
+		}
+	}
+}
+
+func TestTxBundleRejectsTampering(t *testing.T) {
+	bundle, _ := newTestBundle(t)
+	path := filepath.Join(t.TempDir(), "bundle.json")
+	hash, err := writeTxBundle(path, bundle)
+	if err != nil {
+		t.Fatalf("writeTxBundle: %v", err)
+	}
+	if _, err := readTxBundle(path, ""); err == nil {
+		t.Fatalf("expected missing approval hash to be rejected")
+	}
+	if _, err := readTxBundle(path, strings.Repeat("0", 64)); err == nil {
+		t.Fatalf("expected wrong approval hash to be rejected")
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	tampered := bytes.Replace(raw, []byte("1990000000"), []byte("1000000000"), 1)
+	if err := os.WriteFile(path, tampered, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := readTxBundle(path, hash); err == nil {
+		t.Fatalf("expected tampered bundle to be rejected")
+	}
+}
+
+// bundleNode is a node that lands what it's sent, failing the transaction sent failAt-th (from 0) on chain and never
+// showing the one sent lostAt-th. Both are -1 for none. It returns every signature sent, in order.
+func bundleNode(t *testing.T, failAt, lostAt int) (*rpc.Client, func() []solana.Signature) {
+	t.Helper()
+	// Its blockhashes are small numbers like the journal tests', keep them out of the process-wide tracking.
+	saved := blockhashes
+	blockhashes = &blockhashManager{lastValid: map[solana.Hash]uint64{}}
+	t.Cleanup(func() { blockhashes = saved })
+	var (
+		mu   sync.Mutex
+		sent []solana.Signature
+	)
+	index := func(sig solana.Signature) int {
+		for i, s := range sent {
+			if s == sig {
+				return i
+			}
+		}
+		return -1
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		result := func(value string) {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"context":{"slot":1},"value":%s}}`, req.ID, value)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch req.Method {
+		case "getLatestBlockhash":
+			result(fmt.Sprintf(`{"blockhash":%q,"lastValidBlockHeight":250}`, solana.Hash{byte(len(sent) + 1)}))
+		case "sendTransaction":
+			var raw string
+			json.Unmarshal(req.Params[0], &raw)
+			tx, err := solana.TransactionFromBase64(raw)
+			if err != nil {
+				http.Error(w, "unexpected request", http.StatusBadRequest)
+				return
+			}
+			sent = append(sent, tx.Signatures[0])
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":%q}`, req.ID, tx.Signatures[0])
+		case "getTransaction":
+			var sig string
+			json.Unmarshal(req.Params[0], &sig)
+			switch index(solana.MustSignatureFromBase58(sig)) {
+			case lostAt:
+				fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":null}`, req.ID)
+			case failAt:
+				fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"slot":1,"meta":{"err":{"InstructionError":[2,{"Custom":6005}]},"fee":5000,"logMessages":[]},"transaction":null}}`, req.ID)
+			default:
+				fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"slot":1,"meta":{"err":null,"fee":5000,"logMessages":[]},"transaction":null}}`, req.ID)
+			}
+		case "getSignatureStatuses":
+			var sigs []string
+			json.Unmarshal(req.Params[0], &sigs)
+			if index(solana.MustSignatureFromBase58(sigs[0])) == failAt {
+				result("[" + statusFailed + "]")
+			} else {
+				result("[" + statusConfirmed + "]")
+			}
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return rpc.New(srv.URL), func() []solana.Signature {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]solana.Signature(nil), sent...)
+	}
+}
+
+// transferBundle is an approved bundle of n entries, transfers to self of 1, 2, ... lamports standing in for the swaps,
+// something for every entry to sign and send.
+func transferBundle(t *testing.T, payer solana.PrivateKey, n int) *txBundle {
+	t.Helper()
+	bundle := txBundle{
+		Version:   txBundleVersion,
+		CreatedAt: time.Unix(1_700_000_000, 0).UTC(),
+		Network:   "devnet",
+		ProgramID: raydium_cp_swap.ProgramID.String(),
+		Payer:     payer.PublicKey().String(),
+	}
+	for i := range n {
+		transfer := system.NewTransferInstruction(uint64(i+1), payer.PublicKey(), payer.PublicKey()).Build()
+		entry, err := newTxBundleEntry(&swapPlan{intent: testBundleIntent(), payer: payer.PublicKey(), instructions: []solana.Instruction{transfer}}, SymbolMapping{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bundle.Entries = append(bundle.Entries, entry)
+	}
+	path := filepath.Join(t.TempDir(), "bundle.json")
+	hash, err := writeTxBundle(path, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	approved, err := readTxBundle(path, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return approved
+}
+
+func TestExecuteTxBundleEntries(t *testing.T) {
+	deadline := deadlines
+	t.Cleanup(func() { deadlines = deadline })
+	deadlines.Send = 100 * time.Millisecond
+
+	payer := solana.NewWallet().PrivateKey
+	approved := transferBundle(t, payer, 3)
+	client, sent := bundleNode(t, -1, -1)
+	if err := executeTxBundle(context.Background(), client, payer, approved, "devnet"); err != nil || len(sent()) != 3 {
+		t.Fatalf("sent %d of 3 entries: %v", len(sent()), err)
+	}
+
+	// The second entry fails on chain, the third isn't sent.
+	client, sent = bundleNode(t, 1, -1)
+	err := executeTxBundle(context.Background(), client, payer, approved, "devnet")
+	var failed *txFailedError
+	if !errors.As(err, &failed) || !strings.Contains(err.Error(), "bundle entry 1") || len(sent()) != 2 || failed.sig != sent()[1] {
+		t.Errorf("after a failed entry: sent %d, %v", len(sent()), err)
+	}
+
+	// The first is never seen landing, nothing after it goes out.
+	client, sent = bundleNode(t, -1, 0)
+	err = executeTxBundle(context.Background(), client, payer, approved, "devnet")
+	if err == nil || !strings.Contains(err.Error(), "wasn't seen landing") || len(sent()) != 1 {
+		t.Errorf("after an entry that didn't land: sent %d, %v", len(sent()), err)
+	}
+}
+
+// testBundleIntent is selling 1 AAA on a pool of its own with a 1990000000 minimum out.
+func testBundleIntent() *CPIntent {
+	pool, poolAddr := newTestPoolState()
+	intent := &CPIntent{
+		Instruction: &IntentInstruction{Verb: "sell", AmountStr: "1", Dir: SwapDirSell, TargetSymbol: "AAA"},
+		SwapKind:    SwapKindBaseInput,
+		TokenIn:     SwapLeg{Mint: pool.Token0Mint, Vault: pool.Token0Vault, Decimals: 6},
+		TokenOut:    SwapLeg{Mint: pool.Token1Mint, Vault: pool.Token1Vault, Decimals: 9},
+		Pool:        PoolAccounts{Address: poolAddr},
+	}
+	intent.Amounts.KnownAmount = big.NewInt(1_000_000)
+	intent.Amounts.QuoteAmount = big.NewInt(2_000_000_000)
+	intent.Amounts.MinAmountOut = big.NewInt(1_990_000_000)
+	return intent
+}
+
+// recordingHook refuses what touches refused and keeps what it was told landed.
+type recordingHook struct {
+	noTradeHook
+	refused   solana.PublicKey
+	confirmed []hookSwap
+}
+
+func (h *recordingHook) PreSend(_ context.Context, s hookSwap) error {
+	if s.TokenIn.Mint.Equals(h.refused) {
+		return errors.New("not this one")
+	}
+	return nil
+}
+
+func (h *recordingHook) PostConfirm(_ context.Context, s hookSwap, _ txSummaryData) error {
+	h.confirmed = append(h.confirmed, s)
+	return nil
+}
+
+func TestExecuteTxBundleHooks(t *testing.T) {
+	defer func(saved []namedTradeHook) { tradeHooks = saved }(tradeHooks)
+	payer := solana.NewWallet().PrivateKey
+	approved := transferBundle(t, payer, 3)
+	hook := &recordingHook{refused: solana.MustPublicKeyFromBase58(approved.Entries[1].TokenIn.Mint)}
+	tradeHooks = []namedTradeHook{{name: "test", hook: hook}}
+
+	client, sent := bundleNode(t, -1, -1)
+	err := executeTxBundle(context.Background(), client, payer, approved, "devnet")
+	var refusal *hookRefusal
+	if !errors.As(err, &refusal) || !strings.Contains(err.Error(), "bundle entry 1") || len(sent()) != 1 {
+		t.Fatalf("sent %d after a refusal: %v", len(sent()), err)
+	}
+	// The hooks see the entry as reviewed.
+	if len(hook.confirmed) != 1 {
+		t.Fatalf("%d confirmations", len(hook.confirmed))
+	}
+	got := hook.confirmed[0]
+	if got.MaxIn.Int64() != 1_000_000 || got.MinOut.Int64() != 1_990_000_000 || got.Wallet != payer.PublicKey() || got.Approved ||
+		got.TokenIn.Mint.String() != approved.Entries[0].TokenIn.Mint || got.TokenOut.Decimals != 9 {
+		t.Errorf("hooked %+v", got)
+	}
+}
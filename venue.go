@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): Venues.
+
+Everything that quotes or sends (the TableBuilder, pool comparison, splitting, the send path) used to reach straight
+into a CP-Swap PoolState and its AmmConfig. A Venue is the handful of things they actually need from a place to swap:
+which two tokens it trades, what it holds, what it charges, a quote for an amount, and the instructions for the swap.
+CP-Swap is the only one today (cpSwapVenue); CLMM, AMM v4, Whirlpools or an aggregator plug in by implementing it.
+
+A quote still comes back as a CPIntent. Nothing in it is CP specific apart from PoolAccounts, which a venue that has
+no AmmConfig or observation account leaves empty, and the intent remembers which venue quoted it so the send path
+can hand it back for the instructions. Renaming it can wait for the second venue.
+
+What stays CP specific on purpose: the pool monitor and -why, they're about CP-Swap's own accounts and errors.
+*/
+
+// Venue is somewhere a pair can be swapped.
+type Venue interface {
+	// Name is the kind of venue, for reports.
+	Name() string
+	// Address is the venue's pool on chain.
+	Address() solana.PublicKey
+	// Mints are the two tokens the venue trades, in its own order. Reserves and quotes take balances in this order.
+	Mints() [2]solana.PublicKey
+	// FeeRate is the trade fee, in feeRateDenom parts.
+	FeeRate() uint64
+	// CheckTradable catches a venue that can't take a swap right now, before a transaction is spent finding out.
+	CheckTradable(now time.Time) error
+	// Reserves reads what the venue holds of each token, with an error for each one that couldn't be read.
+	Reserves(ctx context.Context, client *rpc.Client) ([]*PoolBalance, []error)
+	// Quote prices req against reserves as Reserves returned them. The intent has no instruction, the caller sets it.
+	Quote(req swapQuoteRequest, reserves []*PoolBalance) (*CPIntent, error)
+	// BuildInstructions are the instructions that swap intent for payer, between its inputATA and outputATA.
+	BuildInstructions(intent *CPIntent, payer, inputATA, outputATA solana.PublicKey) ([]solana.Instruction, error)
+	// Accounts are the venue's own accounts a swap on it touches, everything but the payer's.
+	Accounts() []solana.PublicKey
+}
+
+// swapQuoteRequest is a swap in base units, the way venues quote it.
+type swapQuoteRequest struct {
+	TargetMint solana.PublicKey // the token Amount is in
+	Amount     *big.Int
+	Dir        SwapDir // selling Amount of TargetMint, or buying it
+	Slippage   *big.Rat
+}
+
+// venueOf is the venue intent was quoted on. Intents put together by hand don't say, they're CP-Swap intents.
+func venueOf(intent *CPIntent) Venue {
+	if intent.Venue != nil {
+		return intent.Venue
+	}
+	return &cpSwapVenue{address: intent.Pool.Address}
+}
+
+// venueReserves puts the reserves intent was quoted against back in the venue's order, for quoting it again.
+func venueReserves(v Venue, intent *CPIntent) []*PoolBalance {
+	if intent.TokenIn.Mint.Equals(v.Mints()[0]) {
+		return []*PoolBalance{intent.ReserveIn, intent.ReserveOut}
+	}
+	return []*PoolBalance{intent.ReserveOut, intent.ReserveIn}
+}
+
+// requote is intent's swap for a different amount of the same token, on the same venue and reserves.
+func requote(intent *CPIntent, amount *big.Int, slippage *big.Rat) (*CPIntent, error) {
+	v := venueOf(intent)
+	req := swapQuoteRequest{TargetMint: intent.TokenIn.Mint, Amount: amount, Dir: SwapDirSell, Slippage: slippage}
+	if intent.SwapKind == SwapKindBaseOutput {
+		req.TargetMint, req.Dir = intent.TokenOut.Mint, SwapDirBuy
+	}
+	return v.Quote(req, venueReserves(v, intent))
+}
+
+// cpSwapVenue is a Raydium CP-Swap pool.
+type cpSwapVenue struct {
+	address   solana.PublicKey
+	pool      *raydium_cp_swap.PoolState
+	ammConfig *raydium_cp_swap.AmmConfig
+	mints     [2]*mintAccount
+}
+
+func (lp *loadedPool) venue() *cpSwapVenue {
+	return &cpSwapVenue{address: lp.address, pool: lp.pool, ammConfig: lp.ammConfig, mints: lp.mints}
+}
+
+func (v *cpSwapVenue) Name() string {
+	return "CPMM/CP-Swap Raydium Pool"
+}
+
+func (v *cpSwapVenue) Address() solana.PublicKey {
+	return v.address
+}
+
+func (v *cpSwapVenue) Mints() [2]solana.PublicKey {
+	return [2]solana.PublicKey{v.pool.Token0Mint, v.pool.Token1Mint}
+}
+
+func (v *cpSwapVenue) FeeRate() uint64 {
+	return v.ammConfig.TradeFeeRate
+}
+
+func (v *cpSwapVenue) CheckTradable(now time.Time) error {
+	if pf := checkPoolTradable(v.address, v.pool, now); pf != nil {
+		return pf
+	}
+	return nil
+}
+
+func (v *cpSwapVenue) Reserves(ctx context.Context, client *rpc.Client) ([]*PoolBalance, []error) {
+	return poolReserves(ctx, client, v.pool, v.mints)
+}
+
+func (v *cpSwapVenue) Quote(req swapQuoteRequest, reserves []*PoolBalance) (*CPIntent, error) {
+	cp := ConstantProduct{TradeFeeRate: v.ammConfig.TradeFeeRate, SlippageRatio: req.Slippage}
+	intent, err := quoteCPIntent(cp, v.pool, v.address, req.Dir, req.TargetMint, req.Amount, reserves...)
+	if err != nil {
+		return nil, err
+	}
+	intent.Venue = v
+	return intent, nil
+}
+
+func (v *cpSwapVenue) BuildInstructions(intent *CPIntent, payer, inputATA, outputATA solana.PublicKey) ([]solana.Instruction, error) {
+	auth, err := swapAuthority()
+	if err != nil {
+		return nil, err
+	}
+	ix, err := intent.BuildSwapInstruction(payer, auth, inputATA, outputATA)
+	if err != nil {
+		return nil, err
+	}
+	return []solana.Instruction{ix}, nil
+}
+
+func (v *cpSwapVenue) Accounts() []solana.PublicKey {
+	auth, _ := swapAuthority()
+	return []solana.PublicKey{
+		raydium_cp_swap.ProgramID, auth, v.address, v.pool.AmmConfig,
+		v.pool.Token0Vault, v.pool.Token1Vault, v.pool.Token0Mint, v.pool.Token1Mint,
+		v.pool.Token0Program, v.pool.Token1Program, v.pool.ObservationKey,
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+)
+
+func TestCPSwapVenueQuote(t *testing.T) {
+	pool, _, balances := snapshotPool()
+	addr := snapshotKey(60)
+	slippage, _ := makeSlippageRatio(0.5)
+	venue := (&loadedPool{address: addr, pool: pool, ammConfig: &raydium_cp_swap.AmmConfig{TradeFeeRate: 2500}}).venue()
+	if got := venue.Mints(); !got[0].Equals(pool.Token0Mint) || !got[1].Equals(pool.Token1Mint) {
+		t.Fatalf("mints %v, want the pool's token order", got)
+	}
+
+	for _, line := range []string{"sell 2 SOL", "buy 2 SOL"} {
+		instruction, err := parseIntent(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := NewCPIntent(ConstantProduct{TradeFeeRate: 2500, SlippageRatio: slippage}, pool, addr, instruction, pool.Token0Mint, balances...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := quoteInstruction(venue, instruction, pool.Token0Mint, slippage, balances)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Venue != Venue(venue) {
+			t.Errorf("%s: intent doesn't remember the venue that quoted it", line)
+		}
+		if got.Amounts.QuoteAmount.Cmp(want.Amounts.QuoteAmount) != 0 || got.SwapKind != want.SwapKind {
+			t.Errorf("%s: venue quoted %s, NewCPIntent %s", line, got.Amounts.QuoteAmount, want.Amounts.QuoteAmount)
+		}
+
+		// Quoting the same amount again on the intent's own reserves lands on the same quote.
+		again, err := requote(got, got.Amounts.KnownAmount, slippage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again.Amounts.QuoteAmount.Cmp(got.Amounts.QuoteAmount) != 0 {
+			t.Errorf("%s: requote gave %s, want %s", line, again.Amounts.QuoteAmount, got.Amounts.QuoteAmount)
+		}
+		half, err := requote(got, new(big.Int).Quo(got.Amounts.KnownAmount, big.NewInt(2)), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if half.SwapKind != got.SwapKind || half.Amounts.QuoteAmount.Cmp(got.Amounts.QuoteAmount) >= 0 {
+			t.Errorf("%s: half the amount quoted %s, want less than %s", line, half.Amounts.QuoteAmount, got.Amounts.QuoteAmount)
+		}
+	}
+
+	if _, err := venue.Quote(swapQuoteRequest{TargetMint: pool.Token0Mint, Amount: big.NewInt(1), Dir: SwapDirSell}, balances[:1]); err == nil {
+		t.Error("quoting with one reserve should fail")
+	}
+}
+
+func TestVenueOfHandBuiltIntent(t *testing.T) {
+	addr := snapshotKey(61)
+	intent := &CPIntent{Pool: PoolAccounts{Address: addr}}
+	v := venueOf(intent)
+	if _, ok := v.(*cpSwapVenue); !ok || !v.Address().Equals(addr) {
+		t.Errorf("venueOf = %#v, want a CP-Swap venue at %s", v, addr)
+	}
+}
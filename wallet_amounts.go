@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): Amounts relative to the wallet.
+
+`sell 50% TOKEN` and `sell all TOKEN` size the swap off what the wallet holds of the token being sold, read when the
+intent is quoted. A percentage only makes sense for what's being spent, so it's for pay/sell/swap (and `buy X with
+50% Y`), a `buy 50% USDC` is refused.
+
+The balance is the wallet's associated token account. For SOL it's that plus the wallet's native SOL, planSwap wraps
+what's missing, minus solFeeReserve so `sell all SOL` leaves enough behind to pay for this transaction and the ones
+after it. Selling literally every lamport would make the swap itself unpayable.
+
+The quote table shows the percentage and the amount it came to. A balance can change between quoting and sending
+(another swap, a transfer in), so right before sending the balance is read again and, when it moved, the intent is
+quoted again against the new amount, it's the percentage that was asked for, not the number it once came to.
+*/
+
+// solFeeReserve is how much native SOL `sell all SOL` and friends leave in the wallet, 0.01 SOL.
+const solFeeReserve = 10_000_000
+
+// parsePercentAmount reads "all" or "<n>%" as a fraction of the balance, ok is false for any other amount.
+func parsePercentAmount(amount string) (frac *big.Rat, ok bool, err error) {
+	if strings.EqualFold(amount, "all") {
+		return big.NewRat(1, 1), true, nil
+	}
+	pct, found := strings.CutSuffix(amount, "%")
+	if !found {
+		return nil, false, nil
+	}
+	frac, valid := new(big.Rat).SetString(pct)
+	if !valid || frac.Sign() <= 0 || frac.Cmp(big.NewRat(100, 1)) > 0 {
+		return nil, true, fmt.Errorf("percentage %s must be above 0%% and at most 100%%", amount)
+	}
+	return frac.Quo(frac, big.NewRat(100, 1)), true, nil
+}
+
+// walletTokenBalance is how much of mint the wallet can spend, in raw units, program is the token program mint belongs
+// to. See the note on SOL above.
+func walletTokenBalance(ctx context.Context, client *rpc.Client, owner, mint, program solana.PublicKey) (*big.Int, error) {
+	ata, err := associatedTokenAddress(owner, mint, program)
+	if err != nil {
+		return nil, err
+	}
+	balance := new(big.Int)
+	resp, err := client.GetTokenAccountBalance(ctx, ata, rpc.CommitmentProcessed)
+	switch {
+	case err != nil && !isAccountMissingErr(err):
+		return nil, fmt.Errorf("rpc call getTokenAccountBalance for your %s account failed: %w", Addr(mint.String()), err)
+	case err == nil && resp != nil && resp.Value != nil:
+		if _, ok := balance.SetString(resp.Value.Amount, 10); !ok {
+			return nil, fmt.Errorf("token account balance is an invalid amount %q", resp.Value.Amount)
+		}
+	}
+	if isNativeSOL(mint) {
+		native, err := client.GetBalance(ctx, owner, rpc.CommitmentProcessed)
+		if err != nil {
+			return nil, fmt.Errorf("rpc call getBalance failed: %w", err)
+		}
+		if native.Value > solFeeReserve {
+			balance.Add(balance, new(big.Int).SetUint64(native.Value-solFeeReserve))
+		}
+	}
+	return balance, nil
+}
+
+// resolvePercent turns the instruction's percentage into an absolute amount of the wallet's balance, returning a copy
+// of the instruction carrying that amount, and the balance it was taken from. program is the token program of mint.
+func resolvePercent(ctx context.Context, client *rpc.Client, wallet solana.PublicKey, ii *IntentInstruction, mint, program solana.PublicKey, decimals uint8) (*IntentInstruction, *big.Int, error) {
+	if wallet.IsZero() {
+		return nil, nil, fmt.Errorf("%s is relative to your balance, that needs a wallet (-hotwallet)", ii.AmountStr)
+	}
+	quoteCtx, cancel := deadlines.forQuote(ctx)
+	defer cancel()
+	balance, err := walletTokenBalance(quoteCtx, client, wallet, mint, program)
+	if err != nil {
+		return nil, nil, err
+	}
+	amount := new(big.Rat).Mul(new(big.Rat).SetInt(balance), ii.AmountPct)
+	raw := new(big.Int).Quo(amount.Num(), amount.Denom())
+	if raw.Sign() == 0 {
+		return nil, nil, fmt.Errorf("%s of your %s balance (%s) is nothing to swap", ii.AmountStr, ii.TargetSymbol, fmtAmount(balance, decimals))
+	}
+	resolved := *ii
+	resolved.AmountStr = fmtForDisplay(raw, decimals, int(decimals))
+	resolved.AmountPct = nil
+	return &resolved, balance, nil
+}
+
+// refreshPercentIntent re-reads the wallet balance a percentage intent was sized off, and quotes it again when the
+// balance moved. Any other intent is returned as is.
+func refreshPercentIntent(ctx context.Context, client *rpc.Client, builder *TableBuilder, intent *CPIntent) (*CPIntent, error) {
+	if intent.WalletBalance == nil {
+		return intent, nil
+	}
+	wallet := builder.snapshot().wallet
+	balance, err := walletTokenBalance(ctx, client, wallet, intent.TokenIn.Mint, legProgram(intent.TokenIn))
+	if err != nil {
+		return nil, err
+	}
+	if balance.Cmp(intent.WalletBalance) == 0 {
+		return intent, nil
+	}
+	log.Printf("your %s balance moved from %s to %s since the quote, quoting %s again",
+		builder.symbols().SymFrom(intent.TokenIn.Mint),
+		fmtAmount(intent.WalletBalance, intent.TokenIn.Decimals), fmtAmount(balance, intent.TokenIn.Decimals), intent)
+	_, fresh, err := builder.Build(intent.String())
+	var unquotable *unquotableError
+	if errors.As(err, &unquotable) {
+		return nil, fmt.Errorf("your balance changed since the quote and the intent no longer resolves, nothing was sent: %w", unquotable.err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fresh, nil
+}
+
+// defaultIntentShare is how much of the held token the TUI's default intent pays.
+const defaultIntentShare = "10%"
+
+// defaultIntent is what the TUI quotes when it's started without -intent: defaultIntentShare of whichever of the
+// pool's tokens the wallet holds, of the one worth more at the pool's price when it holds both. Read-only there's no
+// balance to go by, it's suggestIntent's small swap. When the wallet holds neither, or its balances can't be read, the
+// intent is empty and why says so, the TUI asks for an intent instead.
+func defaultIntent(ctx context.Context, tb *TableBuilder) (intent, why string) {
+	snap := tb.snapshot()
+	if snap.wallet.IsZero() {
+		return suggestIntent(snap.pool, snap.symm), ""
+	}
+	mints := snap.venue.Mints()
+	quoteCtx, cancel := deadlines.forQuote(ctx)
+	defer cancel()
+	var held [2]*big.Int
+	for i, mint := range mints {
+		balance, err := walletTokenBalance(quoteCtx, tb.client, snap.wallet, mint, poolTokenProgram(snap.pool, mint))
+		if err != nil {
+			return "", fmt.Sprintf("Couldn't read your balances (%v). Enter an intent.", err)
+		}
+		held[i] = balance
+	}
+	pick := 0
+	switch {
+	case held[0].Sign() > 0 && held[1].Sign() > 0:
+		// NOTE(@hadydotai): held0 is worth held0*reserve1/reserve0 of token1 at the pool's price, comparing
+		// held0*reserve1 with held1*reserve0 picks the bigger holding without dividing.
+		reserves, _, _ := readReserves(quoteCtx, tb.client, snap.venue)
+		if len(reserves) == 2 && reserves[0] != nil && reserves[1] != nil && reserves[0].Balance != nil && reserves[1].Balance != nil {
+			worth0 := new(big.Int).Mul(held[0], reserves[1].Balance)
+			worth1 := new(big.Int).Mul(held[1], reserves[0].Balance)
+			if worth1.Cmp(worth0) > 0 {
+				pick = 1
+			}
+		}
+	case held[1].Sign() > 0:
+		pick = 1
+	case held[0].Sign() == 0:
+		return "", fmt.Sprintf("Your wallet holds neither %s nor %s. Enter an intent.", snap.symm.SymFrom(mints[0]), snap.symm.SymFrom(mints[1]))
+	}
+	// A symbol both tokens share would pick the wrong one, the mint can't.
+	token := snap.symm.SymFrom(mints[pick])
+	if mint, ok := snap.symm.MaybeMintFromSym(token); !ok || !mint.Equals(mints[pick]) {
+		token = mints[pick].String()
+	}
+	return fmt.Sprintf("pay %s %s", defaultIntentShare, token), ""
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestParsePercentAmount(t *testing.T) {
+	tests := []struct {
+		amount string
+		frac   *big.Rat
+		ok     bool
+		err    string
+	}{
+		{amount: "all", frac: big.NewRat(1, 1), ok: true},
+		{amount: "ALL", frac: big.NewRat(1, 1), ok: true},
+		{amount: "50%", frac: big.NewRat(1, 2), ok: true},
+		{amount: "12.5%", frac: big.NewRat(1, 8), ok: true},
+		{amount: "100%", frac: big.NewRat(1, 1), ok: true},
+		{amount: "1.5"},
+		{amount: "0%", ok: true, err: "must be above 0%"},
+		{amount: "150%", ok: true, err: "at most 100%"},
+		{amount: "x%", ok: true, err: "must be above 0%"},
+	}
+	for _, tc := range tests {
+		frac, ok, err := parsePercentAmount(tc.amount)
+		if ok != tc.ok {
+			t.Errorf("%q: ok = %v, want %v", tc.amount, ok, tc.ok)
+		}
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%q: got %v, want an error containing %q", tc.amount, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tc.amount, err)
+			continue
+		}
+		if (frac == nil) != (tc.frac == nil) || (frac != nil && frac.Cmp(tc.frac) != 0) {
+			t.Errorf("%q: frac = %v, want %v", tc.amount, frac, tc.frac)
+		}
+	}
+}
+
+func TestParsePercentIntent(t *testing.T) {
+	for line, want := range map[string]string{
+		"sell 50% sol":          "sell 50% SOL",
+		"Sell ALL SOL for usdc": "sell all SOL for USDC",
+		"buy usdc with 25% SOL": "buy USDC with 25% SOL",
+	} {
+		ii, err := parseIntent(line)
+		if err != nil {
+			t.Errorf("%q: %v", line, err)
+			continue
+		}
+		if ii.AmountPct == nil || ii.Dir != SwapDirSell || ii.String() != want {
+			t.Errorf("%q: parsed %+v, String() = %q, want %q", line, ii, ii.String(), want)
+		}
+	}
+	for line, want := range map[string]string{
+		"buy 50% USDC":  "50% can only be of what you pay, try `buy USDC with 50% <token>`",
+		"get all USDC":  "all can only be of what you pay",
+		"sell 120% SOL": `at most 100%, got "120%" at column 6`,
+		"sell all":      "expected a token symbol at the end of the intent",
+	} {
+		if _, err := parseIntent(line); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: got %v, want it to contain %q", line, err, want)
+		}
+	}
+}
+
+// percentBuilder quotes against the snapshot pool, with a wallet holding solLamports of native SOL, no wrapped SOL,
+// and usdc raw units of USDC.
+func percentBuilder(t *testing.T, solLamports, usdc int64) (*TableBuilder, *rpc.Client, solana.PublicKey) {
+	t.Helper()
+	pool, addr, balances := snapshotPool()
+	symm := SymbolMapping{
+		mintToSymbol: map[string]string{pool.Token0Mint.String(): "SOL", pool.Token1Mint.String(): "USDC"},
+		symbolToMint: map[string]solana.PublicKey{"SOL": pool.Token0Mint, "USDC": pool.Token1Mint},
+		unresolved:   map[string]struct{}{},
+	}
+	wallet := snapshotKey(42)
+	usdcATA, _, err := solana.FindAssociatedTokenAddress(wallet, pool.Token1Mint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := vaultBalanceServer(t, map[solana.PublicKey]*PoolBalance{
+		pool.Token0Vault: balances[0],
+		pool.Token1Vault: balances[1],
+		wallet:           {Balance: big.NewInt(solLamports)},
+		usdcATA:          {Balance: big.NewInt(usdc), Decimals: 6},
+	})
+	client := rpc.New(srv.URL)
+	lp := &loadedPool{address: addr, pool: pool, ammConfig: &raydium_cp_swap.AmmConfig{TradeFeeRate: 2500}, symbolsMap: symm}
+	tb, err := newTableBuilder(context.Background(), client, lp, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tb, client, wallet
+}
+
+func TestBuildPercentIntent(t *testing.T) {
+	tb, client, wallet := percentBuilder(t, 2_000_000_000+solFeeReserve, 300_000_000)
+
+	// Without a wallet there's nothing to take a percentage of.
+	report, intent, err := tb.Build("sell 50% USDC")
+	if !errors.As(err, new(*unquotableError)) || intent != nil || !strings.Contains(report, "needs a wallet") {
+		t.Fatalf("got intent %v, err %v, report\n%s", intent, err, report)
+	}
+
+	tb.useWallet(wallet)
+	report, intent, err = tb.Build("sell 50% USDC")
+	if err != nil || intent == nil {
+		t.Fatalf("got intent %v, err %v, report\n%s", intent, err, report)
+	}
+	if intent.Amounts.KnownAmount.Cmp(big.NewInt(150_000_000)) != 0 || intent.WalletBalance.Cmp(big.NewInt(300_000_000)) != 0 {
+		t.Errorf("50%% of 300 USDC quoted %v of %v", intent.Amounts.KnownAmount, intent.WalletBalance)
+	}
+	if intent.String() != "sell 50% USDC" || !strings.Contains(report, "50% of 300.000000 USDC = 150.000000 USDC") {
+		t.Errorf("intent %q, report\n%s", intent, report)
+	}
+
+	// SOL counts native lamports, less what's kept back for fees.
+	_, all, err := tb.Build("sell all SOL")
+	if err != nil || all == nil {
+		t.Fatalf("sell all SOL: %v, %v", all, err)
+	}
+	if all.Amounts.KnownAmount.Cmp(big.NewInt(2_000_000_000)) != 0 {
+		t.Errorf("sell all SOL sized %v lamports, want 2 SOL", all.Amounts.KnownAmount)
+	}
+
+	// An unchanged balance sends the intent as quoted.
+	same, err := refreshPercentIntent(context.Background(), client, tb, intent)
+	if err != nil || same != intent {
+		t.Errorf("unchanged balance re-quoted: %v, %v", same, err)
+	}
+	// A balance that moved since the quote is quoted again, the percentage is what was asked for.
+	moved, movedClient, _ := percentBuilder(t, 0, 200_000_000)
+	moved.useWallet(wallet)
+	fresh, err := refreshPercentIntent(context.Background(), movedClient, moved, intent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fresh.Amounts.KnownAmount.Cmp(big.NewInt(100_000_000)) != 0 {
+		t.Errorf("re-quoted 50%% of 200 USDC as %v", fresh.Amounts.KnownAmount)
+	}
+
+	// A wallet holding nothing has nothing to sell.
+	empty, _, _ := percentBuilder(t, solFeeReserve/2, 0)
+	empty.useWallet(wallet)
+	if report, intent, err := empty.Build("sell all SOL"); !errors.As(err, new(*unquotableError)) || intent != nil || !strings.Contains(report, "is nothing to swap") {
+		t.Errorf("got intent %v, err %v, report\n%s", intent, err, report)
+	}
+}
+
+func TestDefaultIntent(t *testing.T) {
+	tests := []struct {
+		sol, usdc int64
+		want, why string
+	}{
+		{sol: 0, usdc: 300_000_000, want: "pay 10% USDC"},
+		{sol: 2_000_000_000 + solFeeReserve, usdc: 0, want: "pay 10% SOL"},
+		// Both held, the one worth more at the pool's ~150 USDC a SOL.
+		{sol: 1_000_000_000 + solFeeReserve, usdc: 300_000_000, want: "pay 10% USDC"},
+		{sol: 10_000_000_000 + solFeeReserve, usdc: 300_000_000, want: "pay 10% SOL"},
+		// What's kept back for fees isn't something to pay with.
+		{sol: solFeeReserve / 2, usdc: 0, why: "holds neither SOL nor USDC"},
+	}
+	for _, tc := range tests {
+		tb, _, wallet := percentBuilder(t, tc.sol, tc.usdc)
+		if intent, _ := defaultIntent(t.Context(), tb); intent != "pay 0.01 SOL" {
+			t.Errorf("read-only default %q", intent)
+		}
+		tb.useWallet(wallet)
+		intent, why := defaultIntent(t.Context(), tb)
+		if intent != tc.want || !strings.Contains(why, tc.why) {
+			t.Errorf("%d lamports, %d USDC: got %q, %q", tc.sol, tc.usdc, intent, why)
+		}
+		if intent == "" {
+			continue
+		}
+		if _, quoted, err := tb.Build(intent); err != nil || quoted == nil {
+			t.Errorf("%q doesn't quote: %v", intent, err)
+		}
+	}
+}
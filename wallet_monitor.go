@@ -0,0 +1,487 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): Watching a wallet.
+
+`monitor wallet <pubkey>` prints every cp-swap swap the wallet makes as it lands, what it paid, what it got, the price
+and the pool, one line each or one JSON object each with -json. It's `tape` (see tape.go) turned around: it subscribes
+to the logs of transactions mentioning the wallet instead of the pool, and reads the swaps out of them as SwapEvents.
+A wallet is mentioned by transactions it didn't make too (a transfer to it), so each transaction with a swap in it is
+fetched to check the wallet signed it, which also gives the decimals of the mints from its token balances. A swap an
+aggregator routed through a cp-swap pool counts, it's marked as routed, and a route over two pools is two swaps.
+
+With -mirror, every swap the wallet makes directly on cp-swap is mirrored from our -hotwallet: the same pool, the same
+direction, -mirror-pct of what it paid. Copying someone else's trades is how you lose money fast, so the caps aren't
+optional. -mirror-max-usd caps each mirrored swap (it's shrunk to fit, valued at -price-source's price for what it
+pays, a token that can't be priced isn't mirrored at all), -mirror-max-trades how many are sent before mirroring stops
+(sent, not landed, a swap that failed on chain still counts) and -max-impact skips a swap that would move the pool too
+much. Mirrored swaps are sent like any `-no-tui` swap, so the trade hooks, mint policy and spend limits
+(-max-trade-usd, -max-day-usd) guard them as well. Routed swaps aren't mirrored, the hops of a route only make sense
+together, and neither is a swap on our own wallet.
+
+Mirroring happens one swap at a time, after the swap is printed, and the stream keeps reading meanwhile. Like the
+tape, a dropped subscription reconnects after a second and what landed in between is missed, not replayed.
+*/
+
+// walletSwap is one swap the watched wallet made.
+type walletSwap struct {
+	at          time.Time
+	signature   solana.Signature
+	pool        solana.PublicKey
+	inMint      solana.PublicKey
+	outMint     solana.PublicKey
+	inDecimals  uint8
+	outDecimals uint8
+	inSymbol    string
+	outSymbol   string
+	paid        *big.Int
+	received    *big.Int
+	routed      bool // cp-swap was called by another program, an aggregator, rather than by the transaction itself
+}
+
+// price is what one of the token paid bought, in the token received.
+func (s walletSwap) price() *big.Rat {
+	price := new(big.Rat).SetFrac(s.received, s.paid)
+	return price.Mul(price, new(big.Rat).SetFrac(fixedPointScale(s.inDecimals), fixedPointScale(s.outDecimals)))
+}
+
+func (s walletSwap) priceString() string {
+	return trimDecimal(s.price().FloatString(int(s.outDecimals)))
+}
+
+func (s walletSwap) line() string {
+	routed := ""
+	if s.routed {
+		routed = " (routed)"
+	}
+	return fmt.Sprintf("%s  %s for %s @ %s %s/%s  pool %s%s  %s",
+		s.at.Local().Format("15:04:05"), formatTokenAmount(s.paid, s.inDecimals, s.inSymbol), formatTokenAmount(s.received, s.outDecimals, s.outSymbol),
+		s.priceString(), s.outSymbol, s.inSymbol, Addr(s.pool.String()), routed, Addr(s.signature.String()))
+}
+
+type walletSwapJSON struct {
+	Time         time.Time  `json:"time"`
+	Signature    string     `json:"signature"`
+	Pool         string     `json:"pool"`
+	InputMint    string     `json:"inputMint"`
+	InputSymbol  string     `json:"inputSymbol"`
+	Paid         amountJSON `json:"paid"`
+	OutputMint   string     `json:"outputMint"`
+	OutputSymbol string     `json:"outputSymbol"`
+	Received     amountJSON `json:"received"`
+	Price        string     `json:"price"` // output per input
+	Routed       bool       `json:"routed"`
+}
+
+func (s walletSwap) json() walletSwapJSON {
+	return walletSwapJSON{
+		Time:         s.at,
+		Signature:    s.signature.String(),
+		Pool:         s.pool.String(),
+		InputMint:    s.inMint.String(),
+		InputSymbol:  s.inSymbol,
+		Paid:         newAmountJSON(s.paid, s.inDecimals),
+		OutputMint:   s.outMint.String(),
+		OutputSymbol: s.outSymbol,
+		Received:     newAmountJSON(s.received, s.outDecimals),
+		Price:        s.priceString(),
+		Routed:       s.routed,
+	}
+}
+
+// walletSwapsIn reads the cp-swap swaps out of transaction sig, nil when wallet didn't sign it. Symbols are left to the
+// caller. A swap whose event doesn't name its mints (older deployments) or whose mints' decimals aren't in the
+// transaction's token balances is left out, there's no telling what it traded.
+func walletSwapsIn(res *rpc.GetTransactionResult, sig solana.Signature, wallet solana.PublicKey, at time.Time) []walletSwap {
+	if res == nil || res.Meta == nil || res.Transaction == nil || res.Meta.Err != nil {
+		return nil
+	}
+	tx, err := res.Transaction.GetTransaction()
+	if err != nil || tx == nil {
+		return nil
+	}
+	signers := tx.Message.AccountKeys[:min(int(tx.Message.Header.NumRequiredSignatures), len(tx.Message.AccountKeys))]
+	if !signers.Contains(wallet) {
+		return nil
+	}
+	if res.BlockTime != nil {
+		at = res.BlockTime.Time()
+	}
+	decimals := map[solana.PublicKey]uint8{}
+	for _, bal := range append(append([]rpc.TokenBalance{}, res.Meta.PreTokenBalances...), res.Meta.PostTokenBalances...) {
+		if bal.UiTokenAmount != nil {
+			decimals[bal.Mint] = bal.UiTokenAmount.Decimals
+		}
+	}
+	var swaps []walletSwap
+	for _, le := range loggedSwapEvents(res.Meta.LogMessages, raydium_cp_swap.ProgramID) {
+		ev := le.event
+		inDecimals, okIn := decimals[ev.InputMint]
+		outDecimals, okOut := decimals[ev.OutputMint]
+		if ev.InputMint.IsZero() || ev.OutputMint.IsZero() || !okIn || !okOut {
+			continue
+		}
+		fill := swapFill{event: ev}
+		s := walletSwap{
+			at:          at,
+			signature:   sig,
+			pool:        ev.PoolId,
+			inMint:      ev.InputMint,
+			outMint:     ev.OutputMint,
+			inDecimals:  inDecimals,
+			outDecimals: outDecimals,
+			paid:        fill.paid(),
+			received:    fill.received(),
+			routed:      le.depth > 1,
+		}
+		if s.paid.Sign() > 0 && s.received.Sign() > 0 {
+			swaps = append(swaps, s)
+		}
+	}
+	return swaps
+}
+
+// walletWatcher streams the swaps wallet makes.
+type walletWatcher struct {
+	client  *rpc.Client
+	wsEP    string
+	wallet  solana.PublicKey
+	symbols map[solana.PublicKey]string
+}
+
+// symbolize names the swaps' tokens, looking up the mints it hasn't seen yet.
+func (w *walletWatcher) symbolize(ctx context.Context, swaps []walletSwap) {
+	var unknown []solana.PublicKey
+	for _, s := range swaps {
+		for _, mint := range []solana.PublicKey{s.inMint, s.outMint} {
+			if _, ok := w.symbols[mint]; !ok {
+				unknown = append(unknown, mint)
+			}
+		}
+	}
+	if len(unknown) > 0 {
+		symm := makeSymbolMapping(ctx, w.client, unknown)
+		for _, mint := range unknown {
+			w.symbols[mint] = symm.SymFrom(mint)
+		}
+	}
+	for i := range swaps {
+		swaps[i].inSymbol, swaps[i].outSymbol = w.symbols[swaps[i].inMint], w.symbols[swaps[i].outMint]
+	}
+}
+
+// fetch reads a transaction that mentioned the wallet and returns the swaps the wallet made in it.
+func (w *walletWatcher) fetch(ctx context.Context, sig solana.Signature) ([]walletSwap, error) {
+	ctx, cancel := deadlines.forQuote(ctx)
+	defer cancel()
+	maxVersion := uint64(0)
+	res, err := w.client.GetTransaction(ctx, sig, &rpc.GetTransactionOpts{
+		Encoding:                       solana.EncodingBase64,
+		Commitment:                     rpc.CommitmentConfirmed,
+		MaxSupportedTransactionVersion: &maxVersion,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("rpc call getTransaction for %s failed: %w", sig, err)
+	}
+	swaps := walletSwapsIn(res, sig, w.wallet, time.Now())
+	w.symbolize(ctx, swaps)
+	return swaps, nil
+}
+
+// stream subscribes to the wallet's logs until ctx ends, sending every swap it makes to swaps in the order they
+// landed, and closes swaps when it's done.
+func (w *walletWatcher) stream(ctx context.Context, swaps chan<- walletSwap) {
+	defer close(swaps)
+	for first := true; ctx.Err() == nil; first = false {
+		if !first {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(tapeReconnectDelay):
+			}
+		}
+		sub, closeSub, err := subscribeMentions(ctx, w.wsEP, w.wallet)
+		if err != nil {
+			log.Printf("monitor wallet: subscribing failed, retrying: %v", err)
+			continue
+		}
+		if first {
+			log.Printf("monitor wallet: watching %s", w.wallet)
+		} else {
+			log.Printf("monitor wallet: reconnected, swaps that landed while it was down aren't shown")
+		}
+		for {
+			res, err := sub.Recv(ctx)
+			if err != nil {
+				closeSub()
+				if ctx.Err() == nil {
+					log.Printf("monitor wallet: subscription dropped, reconnecting: %v", err)
+				}
+				break
+			}
+			if res == nil || res.Value.Err != nil || len(loggedSwapEvents(res.Value.Logs, raydium_cp_swap.ProgramID)) == 0 {
+				continue
+			}
+			found, err := w.fetch(ctx, res.Value.Signature)
+			if err != nil {
+				log.Printf("warning: %v, its swaps aren't shown", err)
+				continue
+			}
+			for _, s := range found {
+				select {
+				case swaps <- s:
+				case <-ctx.Done():
+					closeSub()
+					return
+				}
+			}
+		}
+	}
+}
+
+// walletMirror sends a small proportional copy of the watched wallet's swaps, within its caps.
+type walletMirror struct {
+	ctx       context.Context
+	client    *rpc.Client
+	payer     solana.PrivateKey
+	network   string
+	pct       *big.Rat // the fraction of each swap to mirror
+	maxUSD    *big.Rat // the most a mirrored swap may be worth
+	maxTrades int
+	maxImpact *big.Rat
+	slippage  float64
+	receipts  string
+	price     func(ctx context.Context, mint solana.PublicKey, sym string) (usdPrice, time.Duration, error)
+
+	builders map[solana.PublicKey]*TableBuilder
+	sent     int
+}
+
+// size is how much of what s paid to mirror it with, -mirror-pct of it shrunk to at most maxUSD at usd, the dollar
+// price of one of the token paid. Zero when that rounds to nothing.
+func (m *walletMirror) size(s walletSwap, usd *big.Rat) *big.Int {
+	amount := new(big.Rat).Mul(new(big.Rat).SetInt(s.paid), m.pct)
+	scale := new(big.Rat).SetInt(fixedPointScale(s.inDecimals))
+	worth := new(big.Rat).Quo(new(big.Rat).Mul(amount, usd), scale)
+	if worth.Cmp(m.maxUSD) > 0 {
+		amount = new(big.Rat).Mul(new(big.Rat).Quo(m.maxUSD, usd), scale)
+	}
+	return ratAmount(amount)
+}
+
+func (m *walletMirror) builder(pool solana.PublicKey) (*TableBuilder, error) {
+	if b, ok := m.builders[pool]; ok {
+		return b, nil
+	}
+	loaded, err := loadPool(m.ctx, m.client, pool)
+	if err != nil {
+		return nil, err
+	}
+	b, err := newTableBuilder(m.ctx, m.client, loaded, m.slippage)
+	if err != nil {
+		return nil, err
+	}
+	b.useWallet(m.payer.PublicKey())
+	m.builders[pool] = b
+	return b, nil
+}
+
+// mirror sends our copy of s, or says why it didn't.
+func (m *walletMirror) mirror(s walletSwap) error {
+	switch {
+	case s.routed:
+		return errors.New("routed swaps aren't mirrored")
+	case m.sent >= m.maxTrades:
+		return fmt.Errorf("already mirrored %d swaps, the -mirror-max-trades cap", m.sent)
+	}
+	usd, _, err := m.price(m.ctx, s.inMint, s.inSymbol)
+	if err != nil {
+		return fmt.Errorf("%s can't be valued against -mirror-max-usd: %w", s.inSymbol, err)
+	}
+	if usd.Price == nil || usd.Price.Sign() <= 0 {
+		return fmt.Errorf("%s has no dollar price to check -mirror-max-usd against", s.inSymbol)
+	}
+	size := m.size(s, usd.Price)
+	if size.Sign() <= 0 {
+		return fmt.Errorf("-mirror-pct of %s %s rounds to nothing", fmtAmount(s.paid, s.inDecimals), s.inSymbol)
+	}
+	b, err := m.builder(s.pool)
+	if err != nil {
+		return err
+	}
+	line := fmt.Sprintf("sell %s %s", fmtForDisplay(size, s.inDecimals, int(s.inDecimals)), b.symbols().SymFrom(s.inMint))
+	_, intent, err := b.Build(line)
+	if err != nil {
+		return err
+	}
+	impact, err := intent.PriceImpact()
+	if err != nil {
+		return err
+	}
+	if impact.Cmp(m.maxImpact) > 0 {
+		return fmt.Errorf("%s: price impact %s is over the %s cap", line, pctString(impact), pctString(m.maxImpact))
+	}
+	summary, sig, err := executeIntent(m.ctx, m.client, m.payer, b, intent)
+	if sig.IsZero() {
+		return err
+	}
+	m.sent++
+	url := explorerTxURL(m.network, sig)
+	log.Printf("mirror: %s (%d of %d), %s", line, m.sent, m.maxTrades, url)
+	if m.receipts != "" {
+		rcpt := newSwapReceipt("monitor wallet", s.pool.String(), intent.String(), summary, url)
+		rcpt.Trigger = "mirror of " + s.signature.String()
+		if rerr := appendReceipt(m.receipts, rcpt); rerr != nil {
+			log.Printf("warning: recording the receipt failed: %v", rerr)
+		}
+	}
+	return err
+}
+
+// followWallet prints every swap as it comes and mirrors it when mirror isn't nil.
+func followWallet(w io.Writer, asJSON bool, swaps <-chan walletSwap, mirror *walletMirror) error {
+	enc := json.NewEncoder(w)
+	for s := range swaps {
+		if asJSON {
+			if err := enc.Encode(s.json()); err != nil {
+				return err
+			}
+		} else {
+			fmt.Fprintln(w, s.line())
+		}
+		if mirror == nil {
+			continue
+		}
+		if err := mirror.mirror(s); err != nil {
+			log.Printf("mirror: not mirroring %s: %v", Addr(s.signature.String()), err)
+		}
+	}
+	return nil
+}
+
+func runMonitorWalletCommand(args []string) error {
+	fs := flag.NewFlagSet("monitor wallet", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: monitor wallet [flags] <pubkey>\n")
+		fs.PrintDefaults()
+	}
+	nf := addNetworkFlags(fs)
+	addTradeHookFlags(fs)
+	addMintPolicyFlags(fs)
+	addSpendLimitFlags(fs)
+	addComputeBudgetFlags(fs)
+	var (
+		wsEP          = fs.String("ws", "", "WebSocket endpoint to subscribe on, derived from -rpc when empty")
+		asJSON        = fs.Bool("json", false, "Print every swap as a line of JSON")
+		mirrorOn      = fs.Bool("mirror", false, "Mirror the wallet's swaps from -hotwallet, within -mirror-max-usd and -mirror-max-trades")
+		hotwalletPath = fs.String("hotwallet", "", "Path to the hotwallet mirrored swaps are sent from")
+		mirrorPct     = fs.Float64("mirror-pct", 1, "Percentage of each of the wallet's swaps to mirror")
+		maxTrades     = fs.Int("mirror-max-trades", 5, "Stop mirroring after sending this many swaps")
+		maxImpactPct  = fs.Float64("max-impact", 1, "Don't mirror a swap whose price impact (including the trade fee) is above this percentage")
+		slippagePct   = fs.Float64("slippage", 0.5, "Slippage tolerance percentage for mirrored swaps")
+		receiptsPath  = fs.String("receipts", "", "Append mirrored swaps to this receipts file (JSON lines)")
+		maxUSD        *big.Rat
+	)
+	fs.Func("mirror-max-usd", "The most a single mirrored swap may be worth in dollars, required with -mirror", func(s string) error {
+		limit, ok := new(big.Rat).SetString(strings.TrimPrefix(s, "$"))
+		if !ok || limit.Sign() <= 0 {
+			return fmt.Errorf("mirror-max-usd has to be a positive dollar amount, got %q", s)
+		}
+		maxUSD = limit
+		return nil
+	})
+	addAmountFlags(fs)
+	// The wallet reads naturally first, `monitor wallet <pubkey> -network mainnet`, flag stops at the first argument.
+	var target string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		target, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, nf.specs())
+	if target == "" && fs.NArg() > 0 {
+		target = fs.Arg(0)
+	}
+	if target == "" {
+		fs.Usage()
+		return errors.New("missing wallet")
+	}
+	wallet, err := solana.PublicKeyFromBase58(target)
+	if err != nil {
+		return fmt.Errorf("invalid wallet %q: %w", target, err)
+	}
+	client := nf.connect()
+	if *wsEP == "" {
+		*wsEP = wsEndpointFor(*nf.rpcEP)
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+
+	var mirror *walletMirror
+	if *mirrorOn {
+		switch {
+		case *hotwalletPath == "":
+			return errors.New("-mirror needs -hotwallet to send from")
+		case maxUSD == nil:
+			return errors.New("-mirror needs -mirror-max-usd, mirrored swaps aren't sent uncapped")
+		case *mirrorPct <= 0 || *mirrorPct > 100:
+			return errors.New("-mirror-pct must be a percentage between 0 and 100")
+		case *maxTrades <= 0:
+			return errors.New("-mirror-max-trades must be greater than zero")
+		case *maxImpactPct <= 0:
+			return errors.New("-max-impact must be greater than zero")
+		}
+		payer, err := solana.PrivateKeyFromSolanaKeygenFile(*hotwalletPath)
+		if err != nil {
+			return fmt.Errorf("failed to load private key from hot wallet: %w", err)
+		}
+		if payer.PublicKey().Equals(wallet) {
+			return errors.New("-hotwallet is the wallet being watched, it would mirror itself")
+		}
+		pct, okPct := new(big.Rat).SetString(fmt.Sprintf("%g", *mirrorPct))
+		maxImpact, okImpact := new(big.Rat).SetString(fmt.Sprintf("%g", *maxImpactPct))
+		if !okPct || !okImpact {
+			return fmt.Errorf("invalid -mirror-pct %v or -max-impact %v", *mirrorPct, *maxImpactPct)
+		}
+		mirror = &walletMirror{
+			ctx:       ctx,
+			client:    client,
+			payer:     payer,
+			network:   *nf.network,
+			pct:       pct.Quo(pct, big.NewRat(100, 1)),
+			maxUSD:    maxUSD,
+			maxTrades: *maxTrades,
+			maxImpact: maxImpact.Quo(maxImpact, big.NewRat(100, 1)),
+			slippage:  *slippagePct,
+			receipts:  *receiptsPath,
+			price:     currentUSDPrice,
+			builders:  map[solana.PublicKey]*TableBuilder{},
+		}
+		log.Printf("monitor wallet: mirroring %g%% of each swap, at most %s each and %d in all", *mirrorPct, fmtUSD(maxUSD), *maxTrades)
+	}
+
+	watcher := &walletWatcher{client: client, wsEP: *wsEP, wallet: wallet, symbols: map[solana.PublicKey]string{}}
+	swaps := make(chan walletSwap, 16)
+	go watcher.stream(ctx, swaps)
+	return followWallet(os.Stdout, *asJSON, swaps, mirror)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+func TestWalletSwapsIn(t *testing.T) {
+	wallet := solana.NewWallet().PublicKey()
+	p := newInspectPool(9, 6, [2]string{"1000000000000", "150000000000"}, [2]string{"1002000000000", "149700000000"})
+	ev := raydium_cp_swap.SwapEvent{PoolId: p.pool, InputAmount: 2_000_000_000, OutputAmount: 300_000_000, BaseInput: true, InputMint: p.inMint, OutputMint: p.outMint}
+	swap := p.swap(t, wallet, true, 2_000_000_000, 290_000_000)
+	at := time.Unix(1_700_000_000, 0)
+	sig := solana.Signature{1}
+
+	res := inspectResult(t, wallet, []solana.Instruction{swap}, swapLogs(t, ev)[2:], p)
+	swaps := walletSwapsIn(res, sig, wallet, at)
+	if len(swaps) != 1 {
+		t.Fatalf("%d swaps, want 1", len(swaps))
+	}
+	s := swaps[0]
+	if !s.pool.Equals(p.pool) || s.paid.Int64() != 2_000_000_000 || s.received.Int64() != 300_000_000 || s.routed || !s.at.Equal(at) || s.signature != sig {
+		t.Errorf("got %+v", s)
+	}
+	if s.priceString() != "150" {
+		t.Errorf("price %s, want 150", s.priceString())
+	}
+	s.inSymbol, s.outSymbol = "SOL", "USDC"
+	if js := s.json(); js.Paid.Raw != "2000000000" || js.Received.Raw != "300000000" || js.Price != "150" || js.InputSymbol != "SOL" {
+		t.Errorf("as JSON: %+v", js)
+	}
+
+	// Someone else's transaction that mentions the wallet isn't the wallet's swap.
+	if got := walletSwapsIn(res, sig, solana.NewWallet().PublicKey(), at); got != nil {
+		t.Errorf("a transaction the wallet didn't sign: %+v", got)
+	}
+
+	// Through an aggregator, cp-swap is a CPI.
+	router := solana.NewWallet().PublicKey().String()
+	cpSwap := raydium_cp_swap.ProgramID.String()
+	routed := inspectResult(t, wallet, []solana.Instruction{swap}, []string{
+		"Program " + router + " invoke [1]",
+		"Program " + cpSwap + " invoke [2]",
+		programDataLine(t, ev),
+		"Program " + cpSwap + " success",
+		"Program " + router + " success",
+	}, p)
+	if got := walletSwapsIn(routed, sig, wallet, at); len(got) != 1 || !got[0].routed {
+		t.Errorf("routed swap: %+v", got)
+	}
+
+	// An older event doesn't name its mints.
+	old := ev
+	old.InputMint, old.OutputMint = solana.PublicKey{}, solana.PublicKey{}
+	if got := walletSwapsIn(inspectResult(t, wallet, []solana.Instruction{swap}, swapLogs(t, old)[2:], p), sig, wallet, at); len(got) != 0 {
+		t.Errorf("an event without mints: %+v", got)
+	}
+}
+
+func TestWalletMirror(t *testing.T) {
+	sol := func(amount int64) walletSwap {
+		return walletSwap{inSymbol: "SOL", inDecimals: 9, paid: big.NewInt(amount), received: big.NewInt(1), outDecimals: 6}
+	}
+	price := big.NewRat(150, 1)
+	m := &walletMirror{ctx: context.Background(), pct: big.NewRat(1, 100), maxUSD: big.NewRat(5, 1), maxTrades: 2}
+
+	// 1% of 2 SOL is 0.02 SOL, $3, under the cap.
+	if got := m.size(sol(2_000_000_000), price); got.Int64() != 20_000_000 {
+		t.Errorf("under the cap: %s", got)
+	}
+	// 1% of 10 SOL is $15, shrunk to $5 of SOL.
+	if got := m.size(sol(10_000_000_000), price); got.Int64() != 33_333_333 {
+		t.Errorf("over the cap: %s", got)
+	}
+
+	m.price = func(context.Context, solana.PublicKey, string) (usdPrice, time.Duration, error) {
+		return usdPrice{}, 0, errors.New("no price")
+	}
+	routed := sol(1_000_000_000)
+	routed.routed = true
+	for name, c := range map[string]struct {
+		swap walletSwap
+		sent int
+		want string
+	}{
+		"routed":      {routed, 0, "routed"},
+		"capped":      {sol(1_000_000_000), 2, "-mirror-max-trades"},
+		"unpriceable": {sol(1_000_000_000), 0, "-mirror-max-usd"},
+	} {
+		m.sent = c.sent
+		if err := m.mirror(c.swap); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: got %v, want it refused for %s", name, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+)
+
+// quoteEvent is one re-quote of a watched intent, printed either as a line or as JSON.
+type quoteEvent struct {
+	Time       time.Time   `json:"time"`
+	Pool       string      `json:"pool"`
+	Intent     string      `json:"intent"`
+	SwapKind   string      `json:"swapKind,omitempty"`
+	Pay        *amountJSON `json:"pay,omitempty"`
+	PaySymbol  string      `json:"paySymbol,omitempty"`
+	Receive    *amountJSON `json:"receive,omitempty"`
+	ReceiveSym string      `json:"receiveSymbol,omitempty"`
+	MinReceive *amountJSON `json:"minReceive,omitempty"`
+	MaxPay     *amountJSON `json:"maxPay,omitempty"`
+	Price      string      `json:"price,omitempty"` // counter token per target token
+	PriceUnit  string      `json:"priceUnit,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Code       string      `json:"code,omitempty"` // the error's, see error_codes.go
+}
+
+// targetPrice is how many counter tokens one target token goes for in this quote, in display units.
+func targetPrice(intent *CPIntent) *big.Rat {
+	counter := intent.CounterLeg()
+	if counter == nil || intent.Amounts.KnownAmount == nil || intent.Amounts.QuoteAmount == nil || intent.Amounts.KnownAmount.Sign() == 0 {
+		return nil
+	}
+	knownDecimals := intent.TokenIn.Decimals
+	if intent.SwapKind == SwapKindBaseOutput {
+		knownDecimals = intent.TokenOut.Decimals
+	}
+	price := new(big.Rat).SetFrac(intent.Amounts.QuoteAmount, intent.Amounts.KnownAmount)
+	// Both amounts are raw, scale the ratio by 10^(known decimals - counter decimals) to get display units.
+	price.Mul(price, new(big.Rat).SetFrac(fixedPointScale(knownDecimals), fixedPointScale(counter.Decimals)))
+	return price
+}
+
+func newQuoteEvent(at time.Time, pool string, intent *CPIntent, symm SymbolMapping) quoteEvent {
+	inSym, outSym := symm.SymFrom(intent.TokenIn.Mint), symm.SymFrom(intent.TokenOut.Mint)
+	ev := quoteEvent{
+		Time:       at,
+		Pool:       pool,
+		Intent:     intent.String(),
+		SwapKind:   intent.SwapKind.String(),
+		PaySymbol:  inSym,
+		ReceiveSym: outSym,
+	}
+	inDec, outDec := intent.TokenIn.Decimals, intent.TokenOut.Decimals
+	switch intent.SwapKind {
+	case SwapKindBaseInput:
+		ev.Pay = ptrTo(newAmountJSON(intent.Amounts.KnownAmount, inDec))
+		ev.Receive = ptrTo(newAmountJSON(intent.Amounts.QuoteAmount, outDec))
+		ev.MinReceive = ptrTo(newAmountJSON(intent.Amounts.MinAmountOut, outDec))
+		ev.PriceUnit = outSym + "/" + inSym
+	case SwapKindBaseOutput:
+		ev.Pay = ptrTo(newAmountJSON(intent.Amounts.QuoteAmount, inDec))
+		ev.Receive = ptrTo(newAmountJSON(intent.Amounts.KnownAmount, outDec))
+		ev.MaxPay = ptrTo(newAmountJSON(intent.Amounts.MaxAmountIn, inDec))
+		ev.PriceUnit = inSym + "/" + outSym
+	}
+	if price := targetPrice(intent); price != nil {
+		counter := intent.CounterLeg()
+		ev.Price = price.FloatString(int(counter.Decimals))
+	}
+	return ev
+}
+
+func (ev quoteEvent) String() string {
+	ts := ev.Time.Format(time.RFC3339)
+	if ev.Error != "" {
+		return fmt.Sprintf("%s %s: error: %s", ts, ev.Intent, ev.Error)
+	}
+	guard := ""
+	if ev.MinReceive != nil {
+		guard = fmt.Sprintf(" (min receive %s %s)", ev.MinReceive, ev.ReceiveSym)
+	} else if ev.MaxPay != nil {
+		guard = fmt.Sprintf(" (max pay %s %s)", ev.MaxPay, ev.PaySymbol)
+	}
+	return fmt.Sprintf("%s %s: pay %s %s, receive %s %s%s, price %s %s",
+		ts, ev.Intent, ev.Pay, ev.PaySymbol, ev.Receive, ev.ReceiveSym, guard, ev.Price, ev.PriceUnit)
+}
+
+// quoteOnce re-runs the builder for the intent and turns the result into an event.
+func quoteOnce(builder *TableBuilder, intentLine string) (quoteEvent, error) {
+	now := time.Now().UTC()
+	_, intent, err := builder.Build(intentLine)
+	if err != nil {
+		return quoteEvent{}, err
+	}
+	snap := builder.snapshot()
+	return newQuoteEvent(now, snap.address.String(), intent, snap.symm), nil
+}
+
+// watchIntent re-quotes the intent every interval until ctx is done. The first quote has to succeed, that's where
+// typos and unknown symbols surface, after that failures are reported as events and watching carries on.
+func watchIntent(ctx context.Context, builder *TableBuilder, intentLine string, interval time.Duration, asJSON bool, w io.Writer) error {
+	emit := func(ev quoteEvent) error {
+		if asJSON {
+			return json.NewEncoder(w).Encode(ev)
+		}
+		_, err := fmt.Fprintln(w, ev.String())
+		return err
+	}
+	ev, err := quoteOnce(builder, intentLine)
+	if err != nil {
+		return err
+	}
+	if err := emit(ev); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			ev, err := quoteOnce(builder, intentLine)
+			if err != nil {
+				if errors.Is(err, context.Canceled) || ctx.Err() != nil {
+					return nil
+				}
+				ev = quoteEvent{Time: time.Now().UTC(), Pool: builder.snapshot().address.String(), Intent: intentLine, Error: err.Error(), Code: errorCode(err)}
+			}
+			if err := emit(ev); err != nil {
+				return err
+			}
+		}
+	}
+}
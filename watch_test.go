@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+func TestNewQuoteEvent(t *testing.T) {
+	pool, poolAddr, balances := snapshotPool()
+	symm := SymbolMapping{
+		mintToSymbol: map[string]string{},
+		symbolToMint: map[string]solana.PublicKey{},
+		unresolved:   map[string]struct{}{},
+	}
+	symm.MapSymToMint("SOL", pool.Token0Mint.String())
+	symm.MapSymToMint("USDC", pool.Token1Mint.String())
+	cp := ConstantProduct{TradeFeeRate: 2500, SlippageRatio: mustSlippageRatio(t, 0.5)}
+	at := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	sell, err := NewCPIntent(cp, pool, poolAddr, &IntentInstruction{Verb: "sell", AmountStr: "1", Dir: SwapDirSell, TargetSymbol: "SOL"}, pool.Token0Mint, balances...)
+	if err != nil {
+		t.Fatalf("NewCPIntent: %v", err)
+	}
+	ev := newQuoteEvent(at, poolAddr.String(), sell, symm)
+	want := "2025-01-02T03:04:05Z sell 1 SOL: pay 1.000000000 SOL, receive 149.475897 USDC (min receive 148.728517 USDC), price 149.475897 USDC/SOL"
+	if got := ev.String(); got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+
+	buy, err := NewCPIntent(cp, pool, poolAddr, &IntentInstruction{Verb: "buy", AmountStr: "150", Dir: SwapDirBuy, TargetSymbol: "USDC"}, pool.Token1Mint, balances...)
+	if err != nil {
+		t.Fatalf("NewCPIntent: %v", err)
+	}
+	ev = newQuoteEvent(at, poolAddr.String(), buy, symm)
+	if ev.PriceUnit != "SOL/USDC" || !strings.HasPrefix(ev.Price, "0.0066") {
+		t.Fatalf("unexpected buy price %s %s", ev.Price, ev.PriceUnit)
+	}
+	raw, err := json.Marshal(ev)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if strings.Contains(string(raw), "minReceive") || !strings.Contains(string(raw), `"maxPay"`) {
+		t.Fatalf("buy event should carry max pay only: %s", raw)
+	}
+	if !strings.Contains(string(raw), `"receive":{"raw":"150000000","display":"150.000000"}`) {
+		t.Fatalf("structured amounts should carry raw and display: %s", raw)
+	}
+}
+
+func TestQuoteEventRawAmounts(t *testing.T) {
+	rawAmounts = true
+	defer func() { rawAmounts = false }()
+	ev := quoteEvent{
+		Time:       time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Intent:     "sell 1 SOL",
+		Pay:        ptrTo(newAmountJSON(big.NewInt(1_000_000_000), 9)),
+		PaySymbol:  "SOL",
+		Receive:    ptrTo(newAmountJSON(big.NewInt(149_475_898), 6)),
+		ReceiveSym: "USDC",
+		Price:      "149.475897",
+		PriceUnit:  "USDC/SOL",
+	}
+	want := "2025-01-02T03:04:05Z sell 1 SOL: pay 1000000000 SOL, receive 149475898 USDC, price 149.475897 USDC/SOL"
+	if got := ev.String(); got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+	if got := formatTokenAmount(big.NewInt(123_456_789_123), 9, "SOL"); got != "123456789123 SOL" {
+		t.Fatalf("got %q", got)
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+/*
+NOTE(@hadydotai): Webhooks.
+
+Limit orders, stops, DCA and the API server run without anyone watching the terminal, and the only trace of a fill
+was a log line or a receipt. With -webhook every swap they send is announced as it moves along, as JSON POSTed to
+the URL, for a Discord/Slack/Telegram bridge or whatever else wants to know:
+
+  - quote.accepted: the quote passed the command's checks and is about to be sent
+  - tx.sent: the transaction is out, with its signature
+  - tx.confirmed: it landed
+  - tx.failed: it failed to send, or landed and the program rejected it
+  - fill.realized: what was actually paid and received, next to what was quoted
+  - order.expired: a limit order, stop or DCA plan ran out of time unfilled (see order_expiry.go)
+  - pool.created: `monitor new-pools` saw a pool created that passed its filters (see new_pools.go)
+
+Every payload has a "text" line a bridge can forward as is. With -webhook-secret (or RAYDIUM_CLIENT_WEBHOOK_SECRET)
+the request carries X-Raydium-Timestamp and X-Raydium-Signature: sha256=<hex HMAC-SHA256 of "<timestamp>.<body>">,
+the receiver recomputes it to know the payload came from us, and checks the timestamp to refuse replays.
+
+Delivery happens off the swap path, a slow or dead endpoint never holds up a swap. Events go out in order from one
+queue, each retried a couple of times. When the queue is full an event is dropped with a warning rather than blocking.
+*/
+
+const (
+	webhookQuoteAccepted = "quote.accepted"
+	webhookTxSent        = "tx.sent"
+	webhookTxConfirmed   = "tx.confirmed"
+	webhookTxFailed      = "tx.failed"
+	webhookFillRealized  = "fill.realized"
+	webhookOrderExpired  = "order.expired"
+	webhookPoolCreated   = "pool.created"
+
+	webhookQueueSize = 64
+	webhookAttempts  = 3
+	webhookTimeout   = 10 * time.Second
+)
+
+type webhookEvent struct {
+	Event       string       `json:"event"`
+	Time        time.Time    `json:"time"`
+	Command     string       `json:"command"`
+	Network     string       `json:"network"`
+	Text        string       `json:"text"`
+	Pool        string       `json:"pool"`
+	Intent      string       `json:"intent"`
+	Quote       *quoteEvent  `json:"quote,omitempty"`
+	Signature   string       `json:"signature,omitempty"`
+	Explorer    string       `json:"explorer,omitempty"`
+	Status      string       `json:"status,omitempty"`
+	Paid        *amountJSON  `json:"paid,omitempty"`
+	PaidSymbol  string       `json:"paidSymbol,omitempty"`
+	Received    *amountJSON  `json:"received,omitempty"`
+	RecvSymbol  string       `json:"receivedSymbol,omitempty"`
+	FeeLamports uint64       `json:"feeLamports,omitempty"`
+	Error       string       `json:"error,omitempty"`
+	Code        string       `json:"code,omitempty"` // the error's, see error_codes.go
+	NewPool     *newPoolJSON `json:"newPool,omitempty"`
+}
+
+type webhookNotifier struct {
+	url     string
+	secret  string
+	command string
+	network string
+	client  *http.Client
+	queue   chan webhookEvent
+	done    chan struct{}
+	retry   time.Duration // wait before the first retry, doubled after that
+}
+
+// notifier is set by the commands that take -webhook, nil means nobody's listening.
+var notifier *webhookNotifier
+
+func newWebhookNotifier(endpoint, secret, command, network string) *webhookNotifier {
+	n := &webhookNotifier{
+		url:     endpoint,
+		secret:  secret,
+		command: command,
+		network: network,
+		client:  &http.Client{Timeout: webhookTimeout},
+		queue:   make(chan webhookEvent, webhookQueueSize),
+		done:    make(chan struct{}),
+		retry:   time.Second,
+	}
+	go n.run()
+	return n
+}
+
+// notify queues the event, it never blocks.
+func (n *webhookNotifier) notify(ev webhookEvent) {
+	if n == nil {
+		return
+	}
+	ev.Time = time.Now().UTC()
+	ev.Command, ev.Network = n.command, n.network
+	select {
+	case n.queue <- ev:
+	default:
+		log.Printf("warning: webhook queue is full, dropping %s for %s", ev.Event, ev.Intent)
+	}
+}
+
+func (n *webhookNotifier) run() {
+	defer close(n.done)
+	for ev := range n.queue {
+		if err := n.deliver(ev); err != nil {
+			log.Printf("warning: webhook %s: %v", ev.Event, err)
+		}
+	}
+}
+
+// close stops taking events and waits up to timeout for the queued ones to go out.
+func (n *webhookNotifier) close(timeout time.Duration) {
+	if n == nil {
+		return
+	}
+	close(n.queue)
+	select {
+	case <-n.done:
+	case <-time.After(timeout):
+		log.Printf("warning: gave up on %d undelivered webhook events", len(n.queue))
+	}
+}
+
+// signWebhook is the hex HMAC-SHA256 of "<timestamp>.<body>" under the secret.
+func signWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (n *webhookNotifier) deliver(ev webhookEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	wait := n.retry
+	for attempt := 1; ; attempt++ {
+		retryable, err := n.post(ev.Event, body)
+		if err == nil {
+			return nil
+		}
+		if !retryable || attempt == webhookAttempts {
+			return err
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// post sends one attempt, reporting whether a failure is worth retrying. The endpoint saying no (4xx) isn't, unless
+// it's asking us to slow down.
+func (n *webhookNotifier) post(event string, body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Raydium-Event", event)
+	if n.secret != "" {
+		ts := time.Now().Unix()
+		req.Header.Set("X-Raydium-Timestamp", strconv.FormatInt(ts, 10))
+		req.Header.Set("X-Raydium-Signature", "sha256="+signWebhook(n.secret, ts, body))
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	retryable := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retryable, fmt.Errorf("%s answered %s", n.url, resp.Status)
+}
+
+// orderExpired announces an order that ran out of time before it filled, deadline is when it was good until.
+func (n *webhookNotifier) orderExpired(pool, intent string, deadline time.Time) {
+	if n == nil {
+		return
+	}
+	n.notify(webhookEvent{
+		Event:  webhookOrderExpired,
+		Text:   fmt.Sprintf("%s: %s expired unfilled at %s", n.command, intent, deadline.UTC().Format(time.RFC3339)),
+		Pool:   pool,
+		Intent: intent,
+		Status: receiptExpired,
+	})
+}
+
+// poolCreated announces a new pool `monitor new-pools` let through.
+func (n *webhookNotifier) poolCreated(p newPool) {
+	if n == nil {
+		return
+	}
+	js := p.json()
+	n.notify(webhookEvent{
+		Event:     webhookPoolCreated,
+		Text:      fmt.Sprintf("%s: new %s/%s pool %s, %s", n.command, p.symbols[0], p.symbols[1], p.pool, explorerTxURL(n.network, p.signature)),
+		Pool:      js.Pool,
+		Signature: js.Signature,
+		Explorer:  explorerTxURL(n.network, p.signature),
+		NewPool:   &js,
+	})
+}
+
+// swapHook emits the events of a single swap, it's nil (and does nothing) when there's no notifier.
+type swapHook struct {
+	n      *webhookNotifier
+	base   webhookEvent
+	quote  quoteEvent
+	inSym  string
+	outSym string
+}
+
+func newSwapHook(n *webhookNotifier, snap quoteSnapshot, intent *CPIntent) *swapHook {
+	if n == nil {
+		return nil
+	}
+	quote := newQuoteEvent(time.Now().UTC(), snap.address.String(), intent, snap.symm)
+	return &swapHook{
+		n:      n,
+		base:   webhookEvent{Pool: quote.Pool, Intent: quote.Intent},
+		quote:  quote,
+		inSym:  quote.PaySymbol,
+		outSym: quote.ReceiveSym,
+	}
+}
+
+func (h *swapHook) emit(event, text string, fill func(*webhookEvent)) {
+	ev := h.base
+	ev.Event = event
+	ev.Text = fmt.Sprintf("%s: %s", h.n.command, text)
+	if fill != nil {
+		fill(&ev)
+	}
+	h.n.notify(ev)
+}
+
+func (h *swapHook) quoteAccepted() {
+	if h == nil {
+		return
+	}
+	text := fmt.Sprintf("sending %s, quoted %s %s for %s %s", h.quote.Intent, h.quote.Pay, h.inSym, h.quote.Receive, h.outSym)
+	h.emit(webhookQuoteAccepted, text, func(ev *webhookEvent) { ev.Quote = &h.quote })
+}
+
+func (h *swapHook) sendFailed(err error) {
+	if h == nil {
+		return
+	}
+	h.emit(webhookTxFailed, fmt.Sprintf("%s failed to send: %v", h.quote.Intent, err), func(ev *webhookEvent) { ev.Error, ev.Code = err.Error(), errorCode(err) })
+}
+
+func (h *swapHook) sent(sig solana.Signature) {
+	if h == nil {
+		return
+	}
+	h.base.Signature = sig.String()
+	h.base.Explorer = explorerTxURL(h.n.network, sig)
+	h.emit(webhookTxSent, fmt.Sprintf("%s sent, %s", h.quote.Intent, h.base.Explorer), nil)
+}
+
+// landed reports how the transaction ended up, a pending one (we stopped waiting) only gets logged on our side.
+func (h *swapHook) landed(summary txSummaryData) {
+	if h == nil {
+		return
+	}
+	switch summary.Status {
+	case "failed":
+		h.emit(webhookTxFailed, fmt.Sprintf("%s landed but failed, %s", h.quote.Intent, h.base.Explorer), func(ev *webhookEvent) {
+			ev.Status, ev.FeeLamports, ev.Error = summary.Status, summary.FeeLamports, fmt.Sprint(summary.TxErr)
+			ev.Code = errorCode(errors.New(ev.Error))
+		})
+		return
+	case "confirmed", "finalized":
+	default:
+		return
+	}
+	h.emit(webhookTxConfirmed, fmt.Sprintf("%s %s, %s", h.quote.Intent, summary.Status, h.base.Explorer), func(ev *webhookEvent) {
+		ev.Status, ev.FeeLamports = summary.Status, summary.FeeLamports
+	})
+	if summary.PaidAmount == nil || summary.ReceivedAmount == nil {
+		return
+	}
+	paid := newAmountJSON(summary.PaidAmount, summary.PaidDecimals)
+	received := newAmountJSON(summary.ReceivedAmount, summary.ReceivedDecimals)
+	text := fmt.Sprintf("filled %s, paid %s %s for %s %s (quoted %s %s for %s %s)",
+		h.quote.Intent, paid, summary.PaidSymbol, received, summary.ReceivedSymbol, h.quote.Pay, h.inSym, h.quote.Receive, h.outSym)
+	h.emit(webhookFillRealized, text, func(ev *webhookEvent) {
+		ev.Status, ev.FeeLamports, ev.Quote = summary.Status, summary.FeeLamports, &h.quote
+		ev.Paid, ev.PaidSymbol = &paid, summary.PaidSymbol
+		ev.Received, ev.RecvSymbol = &received, summary.ReceivedSymbol
+	})
+}
+
+type webhookFlags struct {
+	url    *string
+	secret *string
+}
+
+func addWebhookFlags(fs *flag.FlagSet) *webhookFlags {
+	return &webhookFlags{
+		url:    fs.String("webhook", "", "POST swap lifecycle events (quote accepted, sent, confirmed, failed, filled, order expired, pool created) as JSON to this URL"),
+		secret: fs.String("webhook-secret", "", "Sign webhook payloads with this HMAC secret (also read from RAYDIUM_CLIENT_WEBHOOK_SECRET)"),
+	}
+}
+
+// start sets up the notifier for the command, the returned function flushes it on the way out.
+func (wf *webhookFlags) start(command, network string) (func(), error) {
+	if *wf.url == "" {
+		return func() {}, nil
+	}
+	u, err := url.Parse(*wf.url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("webhook %q must be an http(s) URL", *wf.url)
+	}
+	if *wf.secret == "" {
+		*wf.secret = os.Getenv("RAYDIUM_CLIENT_WEBHOOK_SECRET")
+	}
+	notifier = newWebhookNotifier(*wf.url, *wf.secret, command, network)
+	return func() { notifier.close(webhookTimeout) }, nil
+}
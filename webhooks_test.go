@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+// webhookSink records what was POSTed to it, answering with the given statuses in turn (200 once they run out).
+type webhookSink struct {
+	mu       sync.Mutex
+	statuses []int
+	events   []webhookEvent
+	headers  []http.Header
+	bodies   [][]byte
+}
+
+func (ws *webhookSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	status := http.StatusOK
+	if len(ws.statuses) > 0 {
+		status, ws.statuses = ws.statuses[0], ws.statuses[1:]
+	}
+	if status == http.StatusOK {
+		var ev webhookEvent
+		_ = json.Unmarshal(body, &ev)
+		ws.events = append(ws.events, ev)
+		ws.headers = append(ws.headers, r.Header.Clone())
+		ws.bodies = append(ws.bodies, body)
+	}
+	w.WriteHeader(status)
+}
+
+func newTestNotifier(t *testing.T, sink *webhookSink, secret string) *webhookNotifier {
+	t.Helper()
+	srv := httptest.NewServer(sink)
+	t.Cleanup(srv.Close)
+	n := newWebhookNotifier(srv.URL, secret, "limit", "devnet")
+	n.retry = time.Millisecond
+	return n
+}
+
+func TestWebhookSignature(t *testing.T) {
+	sink := &webhookSink{}
+	n := newTestNotifier(t, sink, "hush")
+	n.notify(webhookEvent{Event: webhookTxSent, Intent: "pay 1 SOL"})
+	n.close(5 * time.Second)
+
+	if len(sink.events) != 1 {
+		t.Fatalf("got %d events, want 1", len(sink.events))
+	}
+	ev, h := sink.events[0], sink.headers[0]
+	if ev.Command != "limit" || ev.Network != "devnet" || ev.Time.IsZero() || h.Get("X-Raydium-Event") != webhookTxSent {
+		t.Errorf("unexpected event %+v, headers %v", ev, h)
+	}
+	ts, err := strconv.ParseInt(h.Get("X-Raydium-Timestamp"), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := h.Get("X-Raydium-Signature"), "sha256="+signWebhook("hush", ts, sink.bodies[0]); got != want {
+		t.Errorf("signature %s, want %s", got, want)
+	}
+	if signWebhook("other", ts, sink.bodies[0]) == signWebhook("hush", ts, sink.bodies[0]) {
+		t.Error("signature doesn't depend on the secret")
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	sink := &webhookSink{statuses: []int{http.StatusBadGateway, http.StatusTooManyRequests}}
+	n := newTestNotifier(t, sink, "")
+	n.notify(webhookEvent{Event: webhookTxSent})
+	n.close(5 * time.Second)
+	if len(sink.events) != 1 || sink.headers[0].Get("X-Raydium-Signature") != "" {
+		t.Fatalf("want the event delivered unsigned on the third attempt, got %d events", len(sink.events))
+	}
+
+	// The endpoint refusing the payload isn't retried, the next event still goes out.
+	sink = &webhookSink{statuses: []int{http.StatusBadRequest}}
+	n = newTestNotifier(t, sink, "")
+	n.notify(webhookEvent{Event: webhookTxSent})
+	n.notify(webhookEvent{Event: webhookTxConfirmed})
+	n.close(5 * time.Second)
+	if len(sink.events) != 1 || sink.events[0].Event != webhookTxConfirmed {
+		t.Errorf("got %+v, want only the second event", sink.events)
+	}
+}
+
+func TestSwapHookLifecycle(t *testing.T) {
+	sink := &webhookSink{}
+	n := newTestNotifier(t, sink, "")
+	api, addr := newTestAPIServer(t)
+	tb, intent, _, err := api.quote(context.Background(), quoteRequest{Pool: addr.String(), Intent: "pay 1 SOL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook := newSwapHook(n, tb.snapshot(), intent)
+	sig := solana.Signature{1}
+	hook.quoteAccepted()
+	hook.sent(sig)
+	hook.landed(txSummaryData{
+		Signature: sig, Status: "confirmed", FeeLamports: 5000,
+		PaidAmount: big.NewInt(1_000_000_000), PaidDecimals: 9, PaidSymbol: "SOL",
+		ReceivedAmount: big.NewInt(149_000_000), ReceivedDecimals: 6, ReceivedSymbol: "USDC",
+	})
+	n.close(5 * time.Second)
+
+	var got []string
+	for _, ev := range sink.events {
+		got = append(got, ev.Event)
+	}
+	want := []string{webhookQuoteAccepted, webhookTxSent, webhookTxConfirmed, webhookFillRealized}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("events %v, want %v", got, want)
+	}
+	fill := sink.events[3]
+	if fill.Signature != sig.String() || fill.Received == nil || fill.Received.Raw != "149000000" || fill.Quote == nil || fill.FeeLamports != 5000 {
+		t.Errorf("unexpected fill %+v", fill)
+	}
+	if !strings.Contains(fill.Text, "paid 1.000000000 SOL for 149.000000 USDC") {
+		t.Errorf("fill text %q", fill.Text)
+	}
+
+	// A failed transaction only says so, and no notifier means no hook.
+	sink = &webhookSink{}
+	n = newTestNotifier(t, sink, "")
+	newSwapHook(n, tb.snapshot(), intent).landed(txSummaryData{Status: "failed", TxErr: "custom program error: 0x1775"})
+	n.close(5 * time.Second)
+	if len(sink.events) != 1 || sink.events[0].Event != webhookTxFailed || sink.events[0].Error == "" {
+		t.Errorf("got %+v, want one tx.failed", sink.events)
+	}
+	if newSwapHook(nil, tb.snapshot(), intent) != nil {
+		t.Error("a hook without a notifier should be nil")
+	}
+	var nilHook *swapHook
+	nilHook.quoteAccepted()
+	nilHook.landed(txSummaryData{Status: "confirmed"})
+}
+
+func TestWebhookFlagsStart(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	wf := addWebhookFlags(fs)
+	if err := fs.Parse([]string{"-webhook", "ftp://example.com/hook"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wf.start("limit", "devnet"); err == nil {
+		t.Error("want an error for a non-http webhook")
+	}
+	*wf.url = ""
+	flush, err := wf.start("limit", "devnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	flush()
+}
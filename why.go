@@ -0,0 +1,529 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+/*
+NOTE(@hadydotai): Failure forensics.
+
+"custom program error: 0x1775" tells you nothing you can act on. `why <signature>` fetches a failed transaction, finds
+the instruction that failed and which program failed it, names the error, and when the failing instruction is a
+cp-swap swap, replays the quote against the reserves the swap actually saw to say why in plain words.
+
+The reserves come from the transaction's own pre token balances for the two vaults, which is exactly what the program
+read, nothing else in between. The trade fee rate is the AmmConfig's current one, it rarely changes but if it did
+since, the numbers are off by the difference. Like everywhere else, fees sitting in the vaults count as reserves.
+
+The slippage guard is in the instruction (minimum out or maximum in) but the quote it was derived from isn't, so we
+back it out of the guard with -slippage, the same percentage the swap was made with. That gives us "the price moved
+0.9% but slippage was 0.5%". When -slippage is off, the move is off by the same amount, the guard itself isn't.
+*/
+
+const tokenErrInsufficientFunds = 1
+
+// txFailure is where and how a transaction failed, as reported in its meta.
+type txFailure struct {
+	instruction int // index of the failing top level instruction, -1 when the failure isn't tied to one
+	custom      uint64
+	hasCustom   bool
+	reason      string // the runtime's name for the error, e.g. ComputationalBudgetExceeded
+}
+
+// parseTxFailure reads meta.err, which comes in as "InsufficientFundsForFee", {"InstructionError":[1,{"Custom":6005}]},
+// {"InstructionError":[0,"ComputationalBudgetExceeded"]} and so on.
+func parseTxFailure(v any) txFailure {
+	f := txFailure{instruction: -1}
+	switch t := v.(type) {
+	case string:
+		f.reason = t
+		return f
+	case map[string]any:
+		ie, ok := t["InstructionError"].([]any)
+		if !ok || len(ie) != 2 {
+			for k := range t {
+				f.reason = k
+			}
+			return f
+		}
+		if idx, ok := jsonUint(ie[0]); ok {
+			f.instruction = int(idx)
+		}
+		switch d := ie[1].(type) {
+		case string:
+			f.reason = d
+		case map[string]any:
+			if code, ok := jsonUint(d["Custom"]); ok {
+				f.custom, f.hasCustom = code, true
+				f.reason = "Custom"
+				return f
+			}
+			for k := range d {
+				f.reason = k
+			}
+		}
+		return f
+	}
+	if code, ok := customErrorCode(v); ok {
+		f.custom, f.hasCustom = code, true
+	}
+	f.reason = fmt.Sprintf("%v", v)
+	return f
+}
+
+func jsonUint(v any) (uint64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return uint64(n), n >= 0
+	case json.Number:
+		u, err := strconv.ParseUint(n.String(), 10, 64)
+		return u, err == nil
+	case uint64:
+		return n, true
+	case int:
+		return uint64(n), n >= 0
+	}
+	return 0, false
+}
+
+// failingProgram is the program that gave up first, the innermost `Program <id> failed:` line in the logs. An
+// instruction error is reported against the top level instruction even when a CPI it made is what failed.
+func failingProgram(logs []string) (solana.PublicKey, bool) {
+	for _, line := range logs {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && fields[0] == "Program" && fields[2] == "failed:" {
+			if pk, err := solana.PublicKeyFromBase58(fields[1]); err == nil {
+				return pk, true
+			}
+		}
+	}
+	return solana.PublicKey{}, false
+}
+
+// describeProgramError names a custom error code for the program that raised it.
+func describeProgramError(program solana.PublicKey, code uint64) string {
+	var names map[uint64]programErrorInfo
+	switch {
+	case program.Equals(raydium_cp_swap.ProgramID):
+		names = cpSwapErrorNames
+	case program.Equals(solana.TokenProgramID), program.Equals(solana.Token2022ProgramID):
+		names = tokenProgramErrorNames
+	}
+	if info, ok := names[code]; ok {
+		return fmt.Sprintf("%d %s: %s", code, info.name, info.meaning)
+	}
+	return fmt.Sprintf("custom error %d (0x%x)", code, code)
+}
+
+// swapArgs are the arguments of a swap_base_input (amount in, minimum out) or swap_base_output (maximum in, amount
+// out) instruction.
+type swapArgs struct {
+	baseInput bool
+	amount    uint64 // the known side: amount in for base input, amount out for base output
+	limit     uint64 // the slippage guard: minimum out for base input, maximum in for base output
+}
+
+func decodeSwapArgs(data []byte) (swapArgs, bool) {
+	if len(data) < 24 {
+		return swapArgs{}, false
+	}
+	var disc [8]byte
+	copy(disc[:], data[:8])
+	first, second := binary.LittleEndian.Uint64(data[8:16]), binary.LittleEndian.Uint64(data[16:24])
+	switch disc {
+	case raydium_cp_swap.Instruction_SwapBaseInput:
+		return swapArgs{baseInput: true, amount: first, limit: second}, true
+	case raydium_cp_swap.Instruction_SwapBaseOutput:
+		return swapArgs{baseInput: false, amount: second, limit: first}, true
+	}
+	return swapArgs{}, false
+}
+
+// Account positions in both swap instructions.
+const (
+	swapAccPool        = 3
+	swapAccInputToken  = 4
+	swapAccInputVault  = 6
+	swapAccOutputVault = 7
+	swapAccInputMint   = 10
+	swapAccOutputMint  = 11
+)
+
+// swapForensics is everything we know about a failed swap, enough to replay its quote.
+type swapForensics struct {
+	args          swapArgs
+	inReserve     *big.Int
+	outReserve    *big.Int
+	tradeFeeRate  uint64
+	slippage      *big.Rat // what the user says the swap was quoted with
+	inSymbol      string
+	outSymbol     string
+	inDecimals    uint8
+	outDecimals   uint8
+	walletBalance *big.Int // the input token account before the swap, nil when unknown
+}
+
+func (f swapForensics) cp() ConstantProduct {
+	return ConstantProduct{
+		TokenInReserve:  &PoolBalance{Balance: f.inReserve},
+		TokenOutReserve: &PoolBalance{Balance: f.outReserve},
+		TradeFeeRate:    f.tradeFeeRate,
+	}
+}
+
+func pctString(r *big.Rat) string {
+	return trimDecimal(new(big.Rat).Mul(r, big.NewRat(100, 1)).FloatString(2)) + "%"
+}
+
+// explainSlippage replays the swap against the reserves it saw and compares the result with its guard.
+func (f swapForensics) explainSlippage() (string, error) {
+	cp := f.cp()
+	one := big.NewRat(1, 1)
+	if f.args.baseInput {
+		amountIn := new(big.Int).SetUint64(f.args.amount)
+		out, err := cp.QuoteOut(amountIn)
+		if err != nil {
+			return "", err
+		}
+		minOut := new(big.Int).SetUint64(f.args.limit)
+		if out.Cmp(minOut) >= 0 {
+			return fmt.Sprintf("At the reserves the swap saw, %s %s pays %s %s, which clears the %s %s minimum. The trade fee rate probably changed since, or the pool charges fees we don't model.",
+				fmtAmount(amountIn, f.inDecimals), f.inSymbol, fmtAmount(out, f.outDecimals), f.outSymbol, fmtAmount(minOut, f.outDecimals), f.outSymbol), nil
+		}
+		// quote = minOut / (1 - slippage), moved = 1 - out/quote
+		quote := new(big.Rat).Quo(new(big.Rat).SetInt(minOut), new(big.Rat).Sub(one, f.slippage))
+		moved := new(big.Rat).Sub(one, new(big.Rat).Quo(new(big.Rat).SetInt(out), quote))
+		return fmt.Sprintf("By the time the swap ran, %s %s only paid %s %s, short of the %s %s minimum. The price moved %s against you but slippage was %s. It would have gone through with a minimum of %s %s, or slippage of at least %s.",
+			fmtAmount(amountIn, f.inDecimals), f.inSymbol, fmtAmount(out, f.outDecimals), f.outSymbol, fmtAmount(minOut, f.outDecimals), f.outSymbol,
+			pctString(moved), pctString(f.slippage), fmtAmount(out, f.outDecimals), f.outSymbol, pctString(moved)), nil
+	}
+	amountOut := new(big.Int).SetUint64(f.args.amount)
+	in, err := cp.QuoteIn(amountOut)
+	if err != nil {
+		return "", err
+	}
+	maxIn := new(big.Int).SetUint64(f.args.limit)
+	if in.Cmp(maxIn) <= 0 {
+		return fmt.Sprintf("At the reserves the swap saw, %s %s costs %s %s, within the %s %s maximum. The trade fee rate probably changed since, or the pool charges fees we don't model.",
+			fmtAmount(amountOut, f.outDecimals), f.outSymbol, fmtAmount(in, f.inDecimals), f.inSymbol, fmtAmount(maxIn, f.inDecimals), f.inSymbol), nil
+	}
+	// quote = maxIn / (1 + slippage), moved = in/quote - 1
+	quote := new(big.Rat).Quo(new(big.Rat).SetInt(maxIn), new(big.Rat).Add(one, f.slippage))
+	moved := new(big.Rat).Sub(new(big.Rat).Quo(new(big.Rat).SetInt(in), quote), one)
+	return fmt.Sprintf("By the time the swap ran, %s %s cost %s %s, over the %s %s maximum. The price moved %s against you but slippage was %s. It would have gone through with a maximum of %s %s, or slippage of at least %s.",
+		fmtAmount(amountOut, f.outDecimals), f.outSymbol, fmtAmount(in, f.inDecimals), f.inSymbol, fmtAmount(maxIn, f.inDecimals), f.inSymbol,
+		pctString(moved), pctString(f.slippage), fmtAmount(in, f.inDecimals), f.inSymbol, pctString(moved)), nil
+}
+
+// explainFunds compares what the wallet held with what the swap had to take from it.
+func (f swapForensics) explainFunds() (string, error) {
+	if f.walletBalance == nil {
+		return "", errors.New("the input token account's balance isn't in the transaction")
+	}
+	need := new(big.Int).SetUint64(f.args.amount)
+	if !f.args.baseInput {
+		in, err := f.cp().QuoteIn(new(big.Int).SetUint64(f.args.amount))
+		if err != nil {
+			return "", err
+		}
+		need = in
+	}
+	return fmt.Sprintf("The wallet held %s %s but the swap needed %s %s, %s %s short.",
+		fmtAmount(f.walletBalance, f.inDecimals), f.inSymbol, fmtAmount(need, f.inDecimals), f.inSymbol,
+		fmtAmount(new(big.Int).Sub(need, f.walletBalance), f.inDecimals), f.inSymbol), nil
+}
+
+// explainNotApproved looks at the pool as it is now, the best we can do without its state at the time.
+func explainNotApproved(pool *raydium_cp_swap.PoolState, landed time.Time) string {
+	switch {
+	case pool.Status&poolStatusDisableSwap != 0:
+		return "Swapping is disabled on the pool (" + describePoolStatus(pool.Status) + "), it still is."
+	case !landed.IsZero() && pool.OpenTime > uint64(landed.Unix()):
+		return fmt.Sprintf("The pool wasn't open yet, it opened at %s and the swap landed at %s.",
+			unixString(pool.OpenTime), landed.UTC().Format(time.RFC3339))
+	}
+	return "The pool allows swaps now, it must have been paused when the swap landed."
+}
+
+// whyReport is what we found, ready to print.
+type whyReport struct {
+	facts       [][2]string
+	explanation string
+	logTail     []string
+}
+
+func (r whyReport) String() string {
+	builder := &strings.Builder{}
+	t := table.NewWriter()
+	t.SetOutputMirror(builder)
+	t.SetTitle("Failed Transaction")
+	for _, f := range r.facts {
+		t.AppendRow(table.Row{f[0], f[1]})
+	}
+	t.Render()
+	fmt.Fprintf(builder, "\nWhy: %s\n", r.explanation)
+	if len(r.logTail) > 0 {
+		fmt.Fprintf(builder, "\nLast program logs:\n")
+		for _, line := range r.logTail {
+			fmt.Fprintf(builder, "  %s\n", line)
+		}
+	}
+	return builder.String()
+}
+
+// txAccountKeys is every account a landed transaction's instructions index into: the message's own, then the ones
+// loaded from lookup tables, writable first.
+func txAccountKeys(tx *solana.Transaction, meta *rpc.TransactionMeta) solana.PublicKeySlice {
+	keys := append(solana.PublicKeySlice{}, tx.Message.AccountKeys...)
+	return append(append(keys, meta.LoadedAddresses.Writable...), meta.LoadedAddresses.ReadOnly...)
+}
+
+func tokenBalanceAt(balances []rpc.TokenBalance, accountIndex int) (*big.Int, uint8, bool) {
+	for _, bal := range balances {
+		if int(bal.AccountIndex) != accountIndex || bal.UiTokenAmount == nil {
+			continue
+		}
+		amount, ok := new(big.Int).SetString(bal.UiTokenAmount.Amount, 10)
+		return amount, bal.UiTokenAmount.Decimals, ok
+	}
+	return nil, 0, false
+}
+
+type whyInvestigator struct {
+	ctx      context.Context
+	client   *rpc.Client
+	network  string
+	slippage *big.Rat
+}
+
+func (w *whyInvestigator) investigate(sig solana.Signature) (whyReport, error) {
+	maxVersion := uint64(0)
+	res, err := w.client.GetTransaction(w.ctx, sig, &rpc.GetTransactionOpts{
+		Encoding:                       solana.EncodingBase64,
+		Commitment:                     rpc.CommitmentConfirmed,
+		MaxSupportedTransactionVersion: &maxVersion,
+	})
+	if err != nil {
+		return whyReport{}, fmt.Errorf("rpc call getTransaction for %s failed: %w", sig, err)
+	}
+	if res.Meta == nil {
+		return whyReport{}, fmt.Errorf("transaction %s has no metadata", sig)
+	}
+	report := whyReport{facts: [][2]string{{"Signature", sig.String()}, {"Slot", strconv.FormatUint(res.Slot, 10)}}}
+	var landed time.Time
+	if res.BlockTime != nil {
+		landed = res.BlockTime.Time()
+		report.facts = append(report.facts, [2]string{"Landed", landed.UTC().Format(time.RFC3339)})
+	}
+	report.facts = append(report.facts, [2]string{"Explorer", explorerTxURL(w.network, sig)})
+	if res.Meta.Err == nil {
+		report.explanation = "It didn't fail, the transaction succeeded."
+		return report, nil
+	}
+	logs := res.Meta.LogMessages
+	report.logTail = logs[max(0, len(logs)-6):]
+
+	failure := parseTxFailure(res.Meta.Err)
+	if failure.instruction < 0 {
+		report.facts = append(report.facts, [2]string{"Error", failure.reason})
+		report.explanation = runtimeFailureReason(failure.reason)
+		return report, nil
+	}
+	tx, err := res.Transaction.GetTransaction()
+	if err != nil {
+		return whyReport{}, fmt.Errorf("decoding transaction %s: %w", sig, err)
+	}
+	keys := txAccountKeys(tx, res.Meta)
+	if failure.instruction >= len(tx.Message.Instructions) {
+		return whyReport{}, fmt.Errorf("transaction %s failed in instruction %d, it only has %d", sig, failure.instruction, len(tx.Message.Instructions))
+	}
+	ix := tx.Message.Instructions[failure.instruction]
+	if int(ix.ProgramIDIndex) >= len(keys) {
+		return whyReport{}, fmt.Errorf("instruction %d references account %d, the transaction only has %d", failure.instruction, ix.ProgramIDIndex, len(keys))
+	}
+	program := keys[ix.ProgramIDIndex]
+	failedIn := program
+	if p, ok := failingProgram(logs); ok {
+		failedIn = p
+	}
+	args, isSwap := decodeSwapArgs(ix.Data)
+	isSwap = isSwap && program.Equals(raydium_cp_swap.ProgramID) && len(ix.Accounts) > swapAccOutputMint
+	ixName := "program " + program.String()
+	if isSwap {
+		ixName = "cp-swap swap_base_output"
+		if args.baseInput {
+			ixName = "cp-swap swap_base_input"
+		}
+	}
+	report.facts = append(report.facts, [2]string{"Instruction", fmt.Sprintf("#%d, %s", failure.instruction, ixName)})
+	errText := failure.reason
+	if failure.hasCustom {
+		errText = describeProgramError(failedIn, failure.custom)
+	}
+	if !failedIn.Equals(program) {
+		errText += " (raised by " + failedIn.String() + ")"
+	}
+	report.facts = append(report.facts, [2]string{"Error", errText})
+
+	if !isSwap || !failure.hasCustom {
+		report.explanation = runtimeFailureReason(failure.reason)
+		if failure.hasCustom {
+			report.explanation = "The instruction isn't a cp-swap swap, the error above is all we can say about it."
+		}
+		return report, nil
+	}
+	account := func(pos int) (int, solana.PublicKey) {
+		idx := int(ix.Accounts[pos])
+		if idx >= len(keys) {
+			return idx, solana.PublicKey{}
+		}
+		return idx, keys[idx]
+	}
+	_, poolPubK := account(swapAccPool)
+	report.facts = append(report.facts, [2]string{"Pool", poolPubK.String()})
+
+	isCPSwap := failedIn.Equals(raydium_cp_swap.ProgramID)
+	isToken := failedIn.Equals(solana.TokenProgramID) || failedIn.Equals(solana.Token2022ProgramID)
+	switch {
+	case isCPSwap && failure.custom == cpSwapErrNotApproved:
+		pool, err := fetchPoolState(w.ctx, w.client, poolPubK)
+		if err != nil {
+			return whyReport{}, err
+		}
+		report.explanation = explainNotApproved(pool, landed)
+		return report, nil
+	case isCPSwap && (failure.custom == cpSwapErrExceededSlippage || failure.custom == cpSwapErrInsufficientVault || failure.custom == cpSwapErrZeroTradingTokens),
+		isToken && failure.custom == tokenErrInsufficientFunds:
+		// replayed below
+	default:
+		report.explanation = "There's nothing more to work out for this error than its description above."
+		if info, ok := cpSwapErrorNames[failure.custom]; ok && isCPSwap {
+			report.explanation = strings.ToUpper(info.meaning[:1]) + info.meaning[1:] + "."
+			if info.hint != "" {
+				report.explanation += " To get past it, " + info.hint + "."
+			}
+		}
+		return report, nil
+	}
+
+	f := swapForensics{args: args, slippage: w.slippage}
+	inVaultIdx, _ := account(swapAccInputVault)
+	outVaultIdx, _ := account(swapAccOutputVault)
+	userInIdx, _ := account(swapAccInputToken)
+	var ok bool
+	if f.inReserve, f.inDecimals, ok = tokenBalanceAt(res.Meta.PreTokenBalances, inVaultIdx); !ok {
+		return whyReport{}, errors.New("the input vault's balance isn't in the transaction's token balances")
+	}
+	if f.outReserve, f.outDecimals, ok = tokenBalanceAt(res.Meta.PreTokenBalances, outVaultIdx); !ok {
+		return whyReport{}, errors.New("the output vault's balance isn't in the transaction's token balances")
+	}
+	f.walletBalance, _, _ = tokenBalanceAt(res.Meta.PreTokenBalances, userInIdx)
+	pool, err := fetchPoolState(w.ctx, w.client, poolPubK)
+	if err != nil {
+		return whyReport{}, err
+	}
+	cfg, err := fetchAmmConfig(w.ctx, w.client, pool.AmmConfig)
+	if err != nil {
+		return whyReport{}, err
+	}
+	f.tradeFeeRate = cfg.TradeFeeRate
+	_, inMint := account(swapAccInputMint)
+	_, outMint := account(swapAccOutputMint)
+	symm := makeSymbolMapping(w.ctx, w.client, []solana.PublicKey{inMint, outMint})
+	f.inSymbol, f.outSymbol = symm.SymFrom(inMint), symm.SymFrom(outMint)
+	report.facts = append(report.facts,
+		[2]string{"Reserves", fmt.Sprintf("%s %s / %s %s", fmtAmount(f.inReserve, f.inDecimals), f.inSymbol, fmtAmount(f.outReserve, f.outDecimals), f.outSymbol)})
+
+	switch {
+	case isToken:
+		report.explanation, err = f.explainFunds()
+	case failure.custom == cpSwapErrInsufficientVault:
+		report.explanation = fmt.Sprintf("The swap asked for %s %s but the vault only held %s %s.",
+			fmtAmount(new(big.Int).SetUint64(args.amount), f.outDecimals), f.outSymbol, fmtAmount(f.outReserve, f.outDecimals), f.outSymbol)
+	case failure.custom == cpSwapErrZeroTradingTokens:
+		report.explanation = fmt.Sprintf("%s %s is too small to get anything out of a pool with these reserves.",
+			fmtAmount(new(big.Int).SetUint64(args.amount), f.inDecimals), f.inSymbol)
+	default:
+		report.explanation, err = f.explainSlippage()
+	}
+	if err != nil {
+		return whyReport{}, fmt.Errorf("replaying the swap: %w", err)
+	}
+	return report, nil
+}
+
+// runtimeFailureReason explains the failures the runtime raises itself rather than a program.
+func runtimeFailureReason(reason string) string {
+	switch reason {
+	case "ComputationalBudgetExceeded", "ProgramFailedToComplete":
+		return "The transaction ran out of compute units before the instruction finished, it needs a bigger compute budget."
+	case "InsufficientFundsForFee":
+		return "The fee payer couldn't cover the transaction fee."
+	case "InsufficientFundsForRent":
+		return "An account would have been left below rent exemption, usually not enough SOL to open a token account."
+	case "BlockhashNotFound":
+		return "The blockhash expired before the transaction landed."
+	case "AccountInUse", "AccountLoadedTwice":
+		return "The transaction tried to use an account twice or one that was locked."
+	}
+	return "The runtime rejected it with " + reason + "."
+}
+
+func runWhyCommand(args []string) error {
+	fs := flag.NewFlagSet("why", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: why [flags] <signature>\n")
+		fs.PrintDefaults()
+	}
+	nf := addNetworkFlags(fs)
+	slippagePct := fs.Float64("slippage", 0.5, "Slippage percentage the swap was made with, used to work out how far the price moved")
+	addAmountFlags(fs)
+	// The signature reads naturally first, `why <sig> -network mainnet`, flag stops at the first argument.
+	var sigArg string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		sigArg, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ValidateConfigOrExit(fs, nf.specs())
+	if sigArg == "" && fs.NArg() > 0 {
+		sigArg = fs.Arg(0)
+	}
+	if sigArg == "" {
+		fs.Usage()
+		return errors.New("missing transaction signature")
+	}
+	sig, err := solana.SignatureFromBase58(sigArg)
+	if err != nil {
+		return fmt.Errorf("invalid signature %q: %w", sigArg, err)
+	}
+	slippage, err := makeSlippageRatio(*slippagePct)
+	if err != nil {
+		return err
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	w := &whyInvestigator{ctx: ctx, client: nf.connect(), network: *nf.network, slippage: slippage}
+	report, err := w.investigate(sig)
+	if err != nil {
+		return err
+	}
+	fmt.Print(report.String())
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"hadydotai/raydium-client/raydium_cp_swap"
+
+	solana "github.com/gagliardetto/solana-go"
+)
+
+func TestParseTxFailure(t *testing.T) {
+	cases := []struct {
+		name string
+		err  any
+		want txFailure
+	}{
+		{"runtime", "InsufficientFundsForFee", txFailure{instruction: -1, reason: "InsufficientFundsForFee"}},
+		{"custom", map[string]any{"InstructionError": []any{float64(2), map[string]any{"Custom": float64(6005)}}},
+			txFailure{instruction: 2, custom: 6005, hasCustom: true, reason: "Custom"}},
+		{"builtin", map[string]any{"InstructionError": []any{float64(0), "ComputationalBudgetExceeded"}},
+			txFailure{instruction: 0, reason: "ComputationalBudgetExceeded"}},
+		{"rent", map[string]any{"InsufficientFundsForRent": map[string]any{"account_index": float64(2)}},
+			txFailure{instruction: -1, reason: "InsufficientFundsForRent"}},
+	}
+	for _, tc := range cases {
+		if got := parseTxFailure(tc.err); got != tc.want {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestDecodeSwapArgs(t *testing.T) {
+	var k solana.PublicKey
+	in, err := raydium_cp_swap.NewSwapBaseInputInstruction(1000, 990, k, k, k, k, k, k, k, k, k, k, k, k, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := in.Data()
+	if got, ok := decodeSwapArgs(data); !ok || got != (swapArgs{baseInput: true, amount: 1000, limit: 990}) {
+		t.Errorf("base input: got %+v, %v", got, ok)
+	}
+	out, err := raydium_cp_swap.NewSwapBaseOutputInstruction(1010, 1000, k, k, k, k, k, k, k, k, k, k, k, k, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = out.Data()
+	if got, ok := decodeSwapArgs(data); !ok || got != (swapArgs{baseInput: false, amount: 1000, limit: 1010}) {
+		t.Errorf("base output: got %+v, %v", got, ok)
+	}
+	if _, ok := decodeSwapArgs(data[:20]); ok {
+		t.Error("short data decoded")
+	}
+}
+
+func TestFailingProgram(t *testing.T) {
+	logs := []string{
+		"Program " + raydium_cp_swap.ProgramID.String() + " invoke [1]",
+		"Program " + solana.TokenProgramID.String() + " invoke [2]",
+		"Program log: Error: insufficient funds",
+		"Program " + solana.TokenProgramID.String() + " failed: custom program error: 0x1",
+		"Program " + raydium_cp_swap.ProgramID.String() + " failed: custom program error: 0x1",
+	}
+	got, ok := failingProgram(logs)
+	if !ok || !got.Equals(solana.TokenProgramID) {
+		t.Fatalf("got %s, %v, want the token program", got, ok)
+	}
+	if d := describeProgramError(got, 1); !strings.Contains(d, "InsufficientFunds") {
+		t.Errorf("describeProgramError = %q", d)
+	}
+	if d := describeProgramError(raydium_cp_swap.ProgramID, 6005); !strings.Contains(d, "ExceededSlippage") {
+		t.Errorf("describeProgramError = %q", d)
+	}
+}
+
+func newForensics(args swapArgs) swapForensics {
+	return swapForensics{
+		args:       args,
+		inReserve:  big.NewInt(1_000_000),
+		outReserve: big.NewInt(1_000_000),
+		slippage:   big.NewRat(5, 1000),
+		inSymbol:   "IN",
+		outSymbol:  "OUT",
+	}
+}
+
+func TestExplainSlippageBaseInput(t *testing.T) {
+	// 10000 in pays 9900 out. A 9950 minimum at 0.5% slippage means a quote of 10000, so the price moved 1%.
+	got, err := newForensics(swapArgs{baseInput: true, amount: 10_000, limit: 9950}).explainSlippage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"only paid 9900 OUT", "moved 1% against you but slippage was 0.5%", "minimum of 9900 OUT"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%q is missing %q", got, want)
+		}
+	}
+	got, err = newForensics(swapArgs{baseInput: true, amount: 10_000, limit: 9800}).explainSlippage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "clears the 9800 OUT minimum") {
+		t.Errorf("a swap that should have cleared its guard: %q", got)
+	}
+}
+
+func TestExplainSlippageBaseOutput(t *testing.T) {
+	// 9900 out costs 9999 in. A 9950 maximum at 0.5% slippage means a quote of ~9900.5, the price moved 0.99%.
+	got, err := newForensics(swapArgs{baseInput: false, amount: 9900, limit: 9950}).explainSlippage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"cost 9999 IN", "over the 9950 IN maximum", "moved 0.99% against you", "slippage of at least 0.99%"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%q is missing %q", got, want)
+		}
+	}
+}
+
+func TestExplainFunds(t *testing.T) {
+	f := newForensics(swapArgs{baseInput: true, amount: 10_000, limit: 9000})
+	f.walletBalance = big.NewInt(4000)
+	got, err := f.explainFunds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "held 4000 IN but the swap needed 10000 IN, 6000 IN short"; !strings.Contains(got, want) {
+		t.Errorf("%q is missing %q", got, want)
+	}
+}
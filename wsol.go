@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	tokenprog "github.com/gagliardetto/solana-go/programs/token"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+/*
+NOTE(@hadydotai): wSOL lifecycle.
+
+SOL doesn't go through a pool as SOL, it goes through as wrapped SOL, a token account of the native mint whose token
+balance is lamports. Every swap touching SOL has to decide what happens to that account before the swap and after,
+and after is where money burns: close the wrong account, or close it before the swap is done with it, and we're
+cooking money. So it's all here, in one place, instead of being threaded through planSwap.
+
+The WSOLManager decides for each side of the swap that's SOL, off what the owner's wSOL account holds right now:
+
+  - paying SOL, no account: create it, wrap what the swap needs and close it after. Closing hands back everything in
+    it, including what a buy didn't spend, a buy wraps the most it may pay (max pay) and rarely needs all of it.
+  - paying SOL, the account holds enough: reuse it, wrap nothing, leave it open.
+  - paying SOL, the account holds less: top it up by the difference, leave it open. What a buy doesn't spend of the
+    top-up stays wrapped in the user's own account, it isn't ours to close.
+  - receiving SOL, no account: create it and close it after, which unwraps what was received into native SOL.
+  - receiving SOL, the account is there: leave it, what's received stays wrapped.
+
+Ownership is explicit. The manager records every account it creates, and only those get closed, asking it to close
+anything else is an error, not an instruction. Closes go at the very end of the transaction, after the swap, and
+always send the lamports back to the owner.
+*/
+
+type wsolAction int
+
+const (
+	wsolCreate wsolAction = iota + 1 // no account yet, we make one and close it after
+	wsolReuse                        // the account already holds enough
+	wsolTopUp                        // the account is there but short, we wrap the difference
+)
+
+// wsolDecision is what to do with the wSOL account for one side of the swap.
+type wsolDecision struct {
+	action wsolAction
+	wrap   uint64 // lamports to wrap before the swap
+}
+
+// decideWSOLInput settles what to do with the account when paying required lamports out of it. balance is what the
+// account holds, nil when there's no account.
+func decideWSOLInput(balance, required *big.Int) (wsolDecision, error) {
+	if required == nil || required.Sign() <= 0 {
+		return wsolDecision{}, errors.New("nothing to wrap, the swap requires no SOL")
+	}
+	deficit := new(big.Int).Set(required)
+	action := wsolCreate
+	if balance != nil {
+		deficit.Sub(deficit, balance)
+		if deficit.Sign() <= 0 {
+			return wsolDecision{action: wsolReuse}, nil
+		}
+		action = wsolTopUp
+	}
+	if !deficit.IsUint64() {
+		return wsolDecision{}, errors.New("wrap deficit exceeds uint64")
+	}
+	return wsolDecision{action: action, wrap: deficit.Uint64()}, nil
+}
+
+// decideWSOLOutput settles what to do with the account when receiving into it.
+func decideWSOLOutput(balance *big.Int) wsolDecision {
+	if balance == nil {
+		return wsolDecision{action: wsolCreate}
+	}
+	return wsolDecision{action: wsolReuse}
+}
+
+// WSOLManager plans the owner's wSOL account around a single swap, see the note at the top. One manager per swap
+// plan, it remembers what it created for that plan only.
+type WSOLManager struct {
+	client  *rpc.Client
+	owner   solana.PublicKey
+	ata     solana.PublicKey
+	created map[solana.PublicKey]bool
+}
+
+func newWSOLManager(client *rpc.Client, owner solana.PublicKey) (*WSOLManager, error) {
+	ata, _, err := solana.FindAssociatedTokenAddress(owner, wSOLMint)
+	if err != nil {
+		return nil, fmt.Errorf("deriving the wSOL account failed: %w", err)
+	}
+	return &WSOLManager{client: client, owner: owner, ata: ata, created: map[solana.PublicKey]bool{}}, nil
+}
+
+// balance is what the owner's wSOL account holds, nil when it doesn't exist.
+func (m *WSOLManager) balance(ctx context.Context) (*big.Int, error) {
+	resp, err := m.client.GetTokenAccountBalance(ctx, m.ata, rpc.CommitmentProcessed)
+	if isAccountMissingErr(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rpc call getTokenAccountBalance for the wSOL account failed: %w", err)
+	}
+	if resp == nil || resp.Value == nil {
+		return nil, errors.New("rpc call getTokenAccountBalance for the wSOL account returned no balance")
+	}
+	amount, ok := new(big.Int).SetString(resp.Value.Amount, 10)
+	if !ok {
+		return nil, fmt.Errorf("wSOL balance is an invalid amount %q", resp.Value.Amount)
+	}
+	return amount, nil
+}
+
+// prepareInput returns the account to pay required lamports from and the instructions that get it ready.
+func (m *WSOLManager) prepareInput(ctx context.Context, required *big.Int) (solana.PublicKey, []solana.Instruction, error) {
+	balance, err := m.balance(ctx)
+	if err != nil {
+		return solana.PublicKey{}, nil, err
+	}
+	decision, err := decideWSOLInput(balance, required)
+	if err != nil {
+		return solana.PublicKey{}, nil, err
+	}
+	var ixs []solana.Instruction
+	if decision.action == wsolCreate {
+		ixs = append(ixs, m.create())
+	}
+	if decision.wrap > 0 {
+		ixs = append(ixs,
+			system.NewTransferInstruction(decision.wrap, m.owner, m.ata).Build(),
+			tokenprog.NewSyncNativeInstruction(m.ata).Build(),
+		)
+	}
+	return m.ata, ixs, nil
+}
+
+// prepareOutput returns the account to receive into and the instructions that get it ready.
+func (m *WSOLManager) prepareOutput(ctx context.Context) (solana.PublicKey, []solana.Instruction, error) {
+	balance, err := m.balance(ctx)
+	if err != nil {
+		return solana.PublicKey{}, nil, err
+	}
+	if decideWSOLOutput(balance).action == wsolCreate {
+		return m.ata, []solana.Instruction{m.create()}, nil
+	}
+	return m.ata, nil, nil
+}
+
+// create is the instruction making the owner's wSOL account, recording it as ours to close. It's the idempotent
+// create, a bundle executed later, a rebroadcast or a retry may find the account already there.
+func (m *WSOLManager) create() solana.Instruction {
+	m.created[m.ata] = true
+	return createATAInstruction(m.owner, m.ata, m.owner, wSOLMint, solana.TokenProgramID)
+}
+
+// closeAccount closes account into the owner, refusing any account this manager didn't create.
+func (m *WSOLManager) closeAccount(account solana.PublicKey) (solana.Instruction, error) {
+	if !m.created[account] {
+		return nil, fmt.Errorf("refusing to close %s, it wasn't created for this swap", account)
+	}
+	return tokenprog.NewCloseAccountInstructionBuilder().
+		SetAccount(account).
+		SetDestinationAccount(m.owner).
+		SetOwnerAccount(m.owner).
+		Build(), nil
+}
+
+// closeInstructions close every account created for this swap, they go after the swap.
+func (m *WSOLManager) closeInstructions() ([]solana.Instruction, error) {
+	var ixs []solana.Instruction
+	for account := range m.created {
+		ix, err := m.closeAccount(account)
+		if err != nil {
+			return nil, err
+		}
+		ixs = append(ixs, ix)
+	}
+	return ixs, nil
+}
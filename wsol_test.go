@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	solana "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestDecideWSOLInput(t *testing.T) {
+	for name, tc := range map[string]struct {
+		balance *big.Int
+		want    wsolDecision
+	}{
+		"no account":  {nil, wsolDecision{action: wsolCreate, wrap: 1_000}},
+		"enough":      {big.NewInt(1_500), wsolDecision{action: wsolReuse}},
+		"exactly":     {big.NewInt(1_000), wsolDecision{action: wsolReuse}},
+		"short":       {big.NewInt(400), wsolDecision{action: wsolTopUp, wrap: 600}},
+		"empty there": {big.NewInt(0), wsolDecision{action: wsolTopUp, wrap: 1_000}},
+	} {
+		got, err := decideWSOLInput(tc.balance, big.NewInt(1_000))
+		if err != nil || got != tc.want {
+			t.Errorf("%s: got %+v, %v, want %+v", name, got, err, tc.want)
+		}
+	}
+	if _, err := decideWSOLInput(nil, big.NewInt(0)); err == nil {
+		t.Error("want nothing to wrap refused")
+	}
+	if _, err := decideWSOLInput(nil, new(big.Int).Lsh(big.NewInt(1), 70)); err == nil {
+		t.Error("want a deficit past uint64 refused")
+	}
+}
+
+func programsOf(ixs []solana.Instruction) []solana.PublicKey {
+	ids := make([]solana.PublicKey, len(ixs))
+	for i, ix := range ixs {
+		ids[i] = ix.ProgramID()
+	}
+	return ids
+}
+
+func TestWSOLManager(t *testing.T) {
+	owner := snapshotKey(11)
+	ata, _, _ := solana.FindAssociatedTokenAddress(owner, wSOLMint)
+
+	// No wSOL account: paying creates, wraps and closes it after.
+	m, err := newWSOLManager(rpc.New(vaultBalanceServer(t, nil).URL), owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, ixs, err := m.prepareInput(context.Background(), big.NewInt(2_000_000_000))
+	if err != nil || !account.Equals(ata) {
+		t.Fatalf("prepareInput: %s, %v", account, err)
+	}
+	want := []solana.PublicKey{solana.SPLAssociatedTokenAccountProgramID, solana.SystemProgramID, solana.TokenProgramID}
+	if got := programsOf(ixs); len(got) != len(want) || !got[0].Equals(want[0]) || !got[1].Equals(want[1]) || !got[2].Equals(want[2]) {
+		t.Errorf("setup runs %v, want create, transfer, sync", got)
+	}
+	// Idempotent, a replayed bundle or a retry that finds the account made doesn't fail on it.
+	if data, _ := ixs[0].Data(); len(data) != 1 || data[0] != ataCreateIdempotent || !ixs[0].Accounts()[1].PublicKey.Equals(ata) {
+		t.Errorf("create is %v for %s", data, ixs[0].Accounts()[1].PublicKey)
+	}
+	closes, err := m.closeInstructions()
+	if err != nil || len(closes) != 1 || !closes[0].ProgramID().Equals(solana.TokenProgramID) {
+		t.Fatalf("closes %v, %v", closes, err)
+	}
+	if accounts := closes[0].Accounts(); !accounts[0].PublicKey.Equals(ata) || !accounts[1].PublicKey.Equals(owner) {
+		t.Errorf("close goes from %s to %s", accounts[0].PublicKey, accounts[1].PublicKey)
+	}
+
+	// An account that's already there is topped up and never closed.
+	m, _ = newWSOLManager(rpc.New(vaultBalanceServer(t, map[solana.PublicKey]*PoolBalance{ata: {Balance: big.NewInt(500_000_000), Decimals: 9}}).URL), owner)
+	if _, ixs, err := m.prepareInput(context.Background(), big.NewInt(2_000_000_000)); err != nil || len(ixs) != 2 {
+		t.Errorf("top up: %d instructions, %v", len(ixs), err)
+	}
+	if _, ixs, err := m.prepareOutput(context.Background()); err != nil || len(ixs) != 0 {
+		t.Errorf("receiving into an existing account: %d instructions, %v", len(ixs), err)
+	}
+	if closes, err := m.closeInstructions(); err != nil || len(closes) != 0 {
+		t.Errorf("closed an account we didn't create: %v, %v", closes, err)
+	}
+	if _, err := m.closeAccount(ata); err == nil {
+		t.Error("want closing the user's own account refused")
+	}
+}